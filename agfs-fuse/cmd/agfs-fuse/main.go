@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -18,18 +19,33 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "prefetch" {
+		os.Exit(runPrefetch(os.Args[2:]))
+	}
+
 	var (
-		serverURL   = flag.String("agfs-server-url", "http://localhost:8080", "AGFS server URL")
+		serverURL   = flag.String("agfs-server-url", "http://localhost:8080", "AGFS server URL, or unix:///path/to/socket")
+		token       = flag.String("token", os.Getenv("AGFS_TOKEN"), "Bearer token for servers that require one (or $AGFS_TOKEN)")
 		mountpoint  = flag.String("mount", "", "Mount point directory")
 		cacheTTL    = flag.Duration("cache-ttl", 5*time.Second, "Cache TTL duration")
 		debug       = flag.Bool("debug", false, "Enable debug output")
 		logLevel    = flag.String("log-level", "info", "Log level (debug, info, warn, error)")
 		allowOther  = flag.Bool("allow-other", false, "Allow other users to access the mount")
 		showVersion = flag.Bool("version", false, "Show version information")
+		compression = flag.String("compression", "", "Compress file contents on the wire (zstd or lz4) if the server supports it")
+		debugAddr   = flag.String("debug-addr", "", "Serve mount metrics on this address (e.g. localhost:9100), at GET /debug/stats")
+		backend     = flag.String("backend", "", "macOS FUSE backend: macfuse (default) or fskit")
+		volname     = flag.String("volname", "agfs", "Volume name shown by Finder (macOS)")
+		caseInsens  = flag.Bool("case-insensitive", false, "Match names differing only in case on lookup, as macOS applications expect")
+		prefetchTTL = flag.Duration("prefetch-ttl", fusefs.DefaultPrefetchTTL, "How long file contents cached by prefetch are kept")
+		openFlags   = flag.String("open-flags", "", "What to do with open flags AGFS has no equivalent of, e.g. direct=reject,noatime=ignore (actions: ignore, emulate, reject)")
+		servers     serverFlags
 	)
+	flag.Var(&servers, "server", "Mount the server at url under the directory name of the mount, as name=url; repeat for each server, whose token is $AGFS_TOKEN_<NAME> (or --token)")
 
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s prefetch <path glob>...\n\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "Mount AGFS server as a FUSE filesystem.\n\n")
 		fmt.Fprintf(os.Stderr, "Options:\n")
 		flag.PrintDefaults()
@@ -37,6 +53,10 @@ func main() {
 		fmt.Fprintf(os.Stderr, "  %s --agfs-server-url http://localhost:8080 --mount /mnt/agfs\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s --agfs-server-url http://localhost:8080 --mount /mnt/agfs --cache-ttl=10s\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s --agfs-server-url http://localhost:8080 --mount /mnt/agfs --debug\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s --agfs-server-url unix:///run/agfs.sock --mount /mnt/agfs\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s --agfs-server-url http://localhost:8080 --mount /mnt/agfs --debug-addr localhost:9100\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s --server prod=https://prod:8080 --server dev=http://localhost:8080 --mount /mnt/agfs\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s prefetch '/mnt/agfs/repo/src/*'\n", os.Args[0])
 	}
 
 	flag.Parse()
@@ -73,12 +93,44 @@ func main() {
 		os.Exit(1)
 	}
 
+	flagPolicy, err := fusefs.ParseOpenFlagPolicy(*openFlags)
+	if err != nil {
+		log.Fatalf("Invalid --open-flags: %v", err)
+	}
+
 	// Create filesystem
-	root := fusefs.NewAGFSFS(fusefs.Config{
+	config := fusefs.Config{
 		ServerURL: *serverURL,
+		Token:     *token,
 		CacheTTL:  *cacheTTL,
 		Debug:     *debug,
-	})
+
+		Compression:     *compression,
+		CaseInsensitive: *caseInsens,
+		OpenFlags:       flagPolicy,
+		PrefetchTTL:     *prefetchTTL,
+	}
+	var root mountRoot
+	if len(servers) == 0 {
+		root = fusefs.NewAGFSFS(config)
+	} else {
+		// Union mount: each server under its own directory
+		var union []fusefs.UnionServer
+		for _, s := range servers {
+			c := config
+			c.ServerURL = s.url
+			c.Token = serverToken(s.name, *token)
+			union = append(union, fusefs.UnionServer{Name: s.name, Config: c})
+		}
+		if root, err = fusefs.NewUnionFS(union); err != nil {
+			log.Fatalf("Invalid --server: %v", err)
+		}
+	}
+
+	platformOpts, err := fusefs.PlatformOptions{Backend: *backend, VolumeName: *volname}.MountOptions()
+	if err != nil {
+		log.Fatalf("Invalid mount options: %v", err)
+	}
 
 	// Setup FUSE mount options
 	opts := &fs.Options{
@@ -89,6 +141,7 @@ func main() {
 			FsName:        "agfs",
 			DisableXAttrs: true,
 			Debug:         *debug,
+			Options:       platformOpts,
 		},
 	}
 
@@ -96,6 +149,16 @@ func main() {
 		opts.MountOptions.AllowOther = true
 	}
 
+	// Serve metrics for diagnosing slow mounts
+	if *debugAddr != "" {
+		go func() {
+			log.Infof("Debug endpoint: http://%s/debug/stats", *debugAddr)
+			if err := http.ListenAndServe(*debugAddr, root.StatsHandler()); err != nil {
+				log.Errorf("Debug endpoint failed: %v", err)
+			}
+		}()
+	}
+
 	// Mount the filesystem
 	server, err := fs.Mount(*mountpoint, root, opts)
 	if err != nil {
@@ -103,7 +166,12 @@ func main() {
 	}
 
 	log.Infof("AGFS mounted at %s", *mountpoint)
-	log.Infof("Server: %s", *serverURL)
+	if len(servers) == 0 {
+		log.Infof("Server: %s", *serverURL)
+	}
+	for _, s := range servers {
+		log.Infof("Server: %s at %s", s.url, filepath.Join(*mountpoint, s.name))
+	}
 	log.Infof("Cache TTL: %v", *cacheTTL)
 
 	if level > log.DebugLevel {
@@ -134,3 +202,10 @@ func main() {
 
 	log.Info("AGFS unmounted successfully")
 }
+
+// mountRoot is the root of a mount: of one server, or of a union of several
+type mountRoot interface {
+	fs.InodeEmbedder
+	StatsHandler() http.Handler
+	Close() error
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dongxuny/agfs-fuse/pkg/fusefs"
+)
+
+// runPrefetch implements "agfs-fuse prefetch <path glob>...": it asks the
+// agfs-fuse mount holding each path to warm its caches for the paths
+// matching the glob, through the mount's prefetch control file
+func runPrefetch(args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprintf(os.Stderr, "Usage: %s prefetch <path glob>...\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Warm the caches of an agfs-fuse mount for the files matching each glob\n")
+		fmt.Fprintf(os.Stderr, "(quoted, so that the shell does not expand it), e.g. before an agent run:\n\n")
+		fmt.Fprintf(os.Stderr, "  %s prefetch '/mnt/agfs/repo/src/*'\n", os.Args[0])
+		return 2
+	}
+
+	// Group the patterns by mount, to prefetch them in one write each
+	patterns := map[string][]string{}
+	var mounts []string
+	for _, arg := range args {
+		abs, err := filepath.Abs(arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "prefetch: %v\n", err)
+			return 1
+		}
+		mount, err := findMount(abs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "prefetch: %s: %v\n", arg, err)
+			return 1
+		}
+		if _, seen := patterns[mount]; !seen {
+			mounts = append(mounts, mount)
+		}
+		rel, err := filepath.Rel(mount, abs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "prefetch: %v\n", err)
+			return 1
+		}
+		patterns[mount] = append(patterns[mount], "/"+filepath.ToSlash(rel))
+	}
+
+	for _, mount := range mounts {
+		control := filepath.Join(mount, fusefs.ControlDirName, fusefs.PrefetchFileName)
+		f, err := os.OpenFile(control, os.O_RDWR, 0)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "prefetch: %v\n", err)
+			return 1
+		}
+		_, err = f.Write([]byte(strings.Join(patterns[mount], "\n") + "\n"))
+		if err == nil {
+			_, err = io.Copy(os.Stdout, io.NewSectionReader(f, 0, 1<<20))
+		}
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "prefetch: %v\n", err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// findMount returns the root of the agfs-fuse mount holding path, the first
+// directory from the part of path without wildcards up that has the prefetch
+// control file
+func findMount(path string) (string, error) {
+	dir := path
+	for strings.ContainsAny(dir, `*?[\`) {
+		dir = filepath.Dir(dir)
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, fusefs.ControlDirName, fusefs.PrefetchFileName)); err == nil {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("not on an agfs-fuse mount")
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// serverFlags collects the --server flags of union mounts
+type serverFlags []serverFlag
+
+// serverFlag is a server of a union mount, given as name=url
+type serverFlag struct {
+	name string
+	url  string
+}
+
+func (s *serverFlags) String() string {
+	var parts []string
+	for _, f := range *s {
+		parts = append(parts, f.name+"="+f.url)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (s *serverFlags) Set(value string) error {
+	name, url, ok := strings.Cut(value, "=")
+	if !ok || name == "" || url == "" {
+		return fmt.Errorf("expected name=url, got %q", value)
+	}
+	*s = append(*s, serverFlag{name: name, url: url})
+	return nil
+}
+
+// serverToken returns the token of the server named name of a union mount:
+// $AGFS_TOKEN_<NAME>, the name upper-cased with other characters than letters
+// and digits replaced by '_', or fallback if that is not set
+func serverToken(name, fallback string) string {
+	key := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+	if token, ok := os.LookupEnv("AGFS_TOKEN_" + key); ok {
+		return token
+	}
+	return fallback
+}
@@ -7,6 +7,8 @@ require github.com/c4pt0r/agfs/agfs-sdk/go v0.0.0-00010101000000-000000000000
 require (
 	github.com/hanwen/go-fuse/v2 v2.9.0
 	github.com/sirupsen/logrus v1.9.3
+	github.com/klauspost/compress v1.15.9 // indirect
+	github.com/pierrec/lz4/v4 v4.1.15 // indirect
 	golang.org/x/sys v0.28.0 // indirect
 )
 
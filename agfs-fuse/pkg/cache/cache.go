@@ -2,6 +2,7 @@ package cache
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 
 	agfs "github.com/c4pt0r/agfs/agfs-sdk/go"
@@ -23,6 +24,24 @@ type Cache struct {
 	mu      sync.RWMutex
 	entries map[string]*entry
 	ttl     time.Duration
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// Stats holds the lookup counts of a cache
+type Stats struct {
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+	Entries int    `json:"entries"`
+}
+
+// HitRate returns the fraction of lookups served from the cache
+func (s Stats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
 }
 
 // NewCache creates a new cache with the given TTL
@@ -55,17 +74,22 @@ func (c *Cache) Get(key string) (interface{}, bool) {
 	defer c.mu.RUnlock()
 
 	e, ok := c.entries[key]
-	if !ok {
-		return nil, false
-	}
-
-	if e.isExpired() {
+	if !ok || e.isExpired() {
+		c.misses.Add(1)
 		return nil, false
 	}
 
+	c.hits.Add(1)
 	return e.value, true
 }
 
+// Stats returns the lookup counts of the cache since it was created
+func (c *Cache) Stats() Stats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return Stats{Hits: c.hits.Load(), Misses: c.misses.Load(), Entries: len(c.entries)}
+}
+
 // Delete removes a value from the cache
 func (c *Cache) Delete(key string) {
 	c.mu.Lock()
@@ -153,6 +177,11 @@ func (mc *MetadataCache) Clear() {
 	mc.cache.Clear()
 }
 
+// Stats returns the lookup counts of the cache
+func (mc *MetadataCache) Stats() Stats {
+	return mc.cache.Stats()
+}
+
 // DirectoryCache caches directory listings
 type DirectoryCache struct {
 	cache *Cache
@@ -194,3 +223,68 @@ func (dc *DirectoryCache) InvalidatePrefix(prefix string) {
 func (dc *DirectoryCache) Clear() {
 	dc.cache.Clear()
 }
+
+// Stats returns the lookup counts of the cache
+func (dc *DirectoryCache) Stats() Stats {
+	return dc.cache.Stats()
+}
+
+// dataEntry is the contents of a file and the metadata it was read with
+type dataEntry struct {
+	data    []byte
+	size    int64
+	modTime time.Time
+}
+
+// DataCache caches the contents of files. Contents are only returned while
+// the file's size and modification time are still those it was read with.
+type DataCache struct {
+	cache *Cache
+}
+
+// NewDataCache creates a new data cache
+func NewDataCache(ttl time.Duration) *DataCache {
+	return &DataCache{
+		cache: NewCache(ttl),
+	}
+}
+
+// Get retrieves the contents of a file from cache, if stat, which returns the
+// current metadata of the file and is only called for cached files, shows it
+// has not changed since
+func (dc *DataCache) Get(path string, stat func() (*agfs.FileInfo, error)) ([]byte, bool) {
+	value, ok := dc.cache.Get(path)
+	if !ok {
+		return nil, false
+	}
+	e, ok := value.(*dataEntry)
+	if !ok {
+		return nil, false
+	}
+	info, err := stat()
+	if err != nil || e.size != info.Size || !e.modTime.Equal(info.ModTime) {
+		dc.cache.Delete(path)
+		return nil, false
+	}
+	return e.data, true
+}
+
+// Set stores the contents of a file, read when it had the metadata info
+func (dc *DataCache) Set(path string, info *agfs.FileInfo, data []byte) {
+	dc.cache.Set(path, &dataEntry{data: data, size: info.Size, modTime: info.ModTime})
+}
+
+// Invalidate removes the contents of a file from cache
+func (dc *DataCache) Invalidate(path string) {
+	dc.cache.Delete(path)
+}
+
+// Clear clears all cached contents
+func (dc *DataCache) Clear() {
+	dc.cache.Clear()
+}
+
+// Stats returns the lookup counts of the cache
+func (dc *DataCache) Stats() Stats {
+	return dc.cache.Stats()
+}
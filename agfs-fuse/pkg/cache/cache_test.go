@@ -152,3 +152,49 @@ func TestCacheConcurrency(t *testing.T) {
 
 	// If we got here without panic, concurrency is safe
 }
+
+func TestCacheStats(t *testing.T) {
+	c := NewCache(1 * time.Second)
+	c.Set("key1", "value1")
+	c.Get("key1")
+	c.Get("key1")
+	c.Get("missing")
+
+	s := c.Stats()
+	if s.Hits != 2 || s.Misses != 1 || s.Entries != 1 {
+		t.Errorf("Stats() = %+v", s)
+	}
+	if rate := s.HitRate(); rate < 0.66 || rate > 0.67 {
+		t.Errorf("HitRate() = %v", rate)
+	}
+	if rate := (Stats{}).HitRate(); rate != 0 {
+		t.Errorf("HitRate() without lookups = %v", rate)
+	}
+}
+
+func TestDataCache(t *testing.T) {
+	dc := NewDataCache(1 * time.Second)
+	info := &agfs.FileInfo{Name: "a.txt", Size: 5, ModTime: time.Unix(100, 0)}
+	dc.Set("/a.txt", info, []byte("hello"))
+
+	stat := func() (*agfs.FileInfo, error) { return info, nil }
+	if data, ok := dc.Get("/a.txt", stat); !ok || string(data) != "hello" {
+		t.Errorf("Get = %q, %v", data, ok)
+	}
+	if _, ok := dc.Get("/b.txt", func() (*agfs.FileInfo, error) {
+		t.Error("stat called for a file not cached")
+		return nil, nil
+	}); ok {
+		t.Error("expected a miss")
+	}
+
+	// Contents of a file changed since are dropped
+	info = &agfs.FileInfo{Name: "a.txt", Size: 5, ModTime: time.Unix(200, 0)}
+	if _, ok := dc.Get("/a.txt", stat); ok {
+		t.Error("expected contents of a modified file to be a miss")
+	}
+	dc.Set("/a.txt", info, []byte("hallo"))
+	if data, _ := dc.Get("/a.txt", stat); string(data) != "hallo" {
+		t.Errorf("Get = %q after update", data)
+	}
+}
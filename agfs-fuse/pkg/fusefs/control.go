@@ -0,0 +1,143 @@
+package fusefs
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+
+	agfs "github.com/c4pt0r/agfs/agfs-sdk/go"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ControlDirName is the directory at the root of the mount holding the
+	// control files of the mount. It is not listed, and hides a directory of
+	// the same name on the server.
+	ControlDirName = ".agfs"
+
+	// PrefetchFileName is the control file prefetching the path globs
+	// written to it, one per line (see AGFSFS.Prefetch); reading it returns
+	// the results
+	PrefetchFileName = "prefetch"
+)
+
+// controlDir is the directory of the control files
+type controlDir struct {
+	fs.Inode
+	root *AGFSFS
+}
+
+var _ = (fs.NodeGetattrer)((*controlDir)(nil))
+var _ = (fs.NodeLookuper)((*controlDir)(nil))
+var _ = (fs.NodeReaddirer)((*controlDir)(nil))
+
+func (d *controlDir) Getattr(ctx context.Context, f fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0755 | syscall.S_IFDIR
+	return 0
+}
+
+func (d *controlDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if name != PrefetchFileName {
+		return nil, syscall.ENOENT
+	}
+	out.Mode = 0644 | syscall.S_IFREG
+	return d.NewInode(ctx, &prefetchFile{root: d.root}, fs.StableAttr{Mode: syscall.S_IFREG}), 0
+}
+
+func (d *controlDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	return fs.NewListDirStream([]fuse.DirEntry{{Name: PrefetchFileName, Mode: syscall.S_IFREG}}), 0
+}
+
+// prefetchFile is the control file of prefetches
+type prefetchFile struct {
+	fs.Inode
+	root *AGFSFS
+}
+
+var _ = (fs.NodeGetattrer)((*prefetchFile)(nil))
+var _ = (fs.NodeSetattrer)((*prefetchFile)(nil))
+var _ = (fs.NodeOpener)((*prefetchFile)(nil))
+
+func (f *prefetchFile) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0644 | syscall.S_IFREG
+	return 0
+}
+
+// Setattr accepts the truncation of shell redirections
+func (f *prefetchFile) Setattr(ctx context.Context, fh fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	return f.Getattr(ctx, fh, out)
+}
+
+func (f *prefetchFile) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
+	return &prefetchHandle{root: f.root}, fuse.FOPEN_DIRECT_IO, 0
+}
+
+// prefetchHandle is an open prefetch control file. Reads return the results
+// of the prefetches written through the handle, or else of the last ones.
+type prefetchHandle struct {
+	root *AGFSFS
+
+	mu      sync.Mutex
+	results string
+}
+
+var _ = (fs.FileReader)((*prefetchHandle)(nil))
+var _ = (fs.FileWriter)((*prefetchHandle)(nil))
+
+func (h *prefetchHandle) Write(ctx context.Context, data []byte, off int64) (uint32, syscall.Errno) {
+	var results strings.Builder
+	for _, pattern := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(pattern) == "" {
+			continue
+		}
+		result, err := h.root.Prefetch(ctx, pattern)
+		if err != nil {
+			log.Warnf("Prefetch of %s failed: %v", pattern, err)
+			switch {
+			case errors.Is(err, path.ErrBadPattern):
+				return 0, syscall.EINVAL
+			case errors.Is(err, agfs.ErrNotFound):
+				return 0, syscall.ENOENT
+			case ctx.Err() != nil:
+				return 0, syscall.EINTR
+			}
+			return 0, syscall.EIO
+		}
+		results.WriteString(result.String())
+	}
+
+	h.mu.Lock()
+	h.results += results.String()
+	h.mu.Unlock()
+	h.root.mu.Lock()
+	h.root.prefetchResults = results.String()
+	h.root.mu.Unlock()
+	return uint32(len(data)), 0
+}
+
+func (h *prefetchHandle) Read(ctx context.Context, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	h.mu.Lock()
+	results := h.results
+	h.mu.Unlock()
+	if results == "" {
+		h.root.mu.RLock()
+		results = h.root.prefetchResults
+		h.root.mu.RUnlock()
+	}
+	if off >= int64(len(results)) {
+		return fuse.ReadResultData(nil), 0
+	}
+	end := min(off+int64(len(dest)), int64(len(results)))
+	return fuse.ReadResultData([]byte(results[off:end])), 0
+}
+
+// controlDir returns the inode of the control directory
+func (root *AGFSFS) controlDir(ctx context.Context, out *fuse.EntryOut) *fs.Inode {
+	out.Mode = 0755 | syscall.S_IFDIR
+	return root.NewInode(ctx, &controlDir{root: root}, fs.StableAttr{Mode: syscall.S_IFDIR})
+}
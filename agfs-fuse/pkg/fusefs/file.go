@@ -3,6 +3,7 @@ package fusefs
 import (
 	"context"
 	"syscall"
+	"time"
 
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
@@ -22,7 +23,8 @@ var _ = (fs.FileReleaser)((*AGFSFileHandle)(nil))
 var _ = (fs.FileGetattrer)((*AGFSFileHandle)(nil))
 
 // Read reads data from the file
-func (fh *AGFSFileHandle) Read(ctx context.Context, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+func (fh *AGFSFileHandle) Read(ctx context.Context, dest []byte, off int64) (result fuse.ReadResult, errno syscall.Errno) {
+	defer fh.node.root.stats.track("read", time.Now(), &errno)
 	data, err := fh.node.root.handles.Read(fh.handle, off, len(dest))
 	if err != nil {
 		return nil, syscall.EIO
@@ -33,6 +35,7 @@ func (fh *AGFSFileHandle) Read(ctx context.Context, dest []byte, off int64) (fus
 
 // Write writes data to the file
 func (fh *AGFSFileHandle) Write(ctx context.Context, data []byte, off int64) (written uint32, errno syscall.Errno) {
+	defer fh.node.root.stats.track("write", time.Now(), &errno)
 	path := fh.node.getPath()
 	log.Debugf("[file] Write called: path=%s, len=%d, off=%d, handle=%d", path, len(data), off, fh.handle)
 
@@ -44,13 +47,15 @@ func (fh *AGFSFileHandle) Write(ctx context.Context, data []byte, off int64) (wr
 
 	// Invalidate metadata cache since file size may have changed
 	fh.node.root.metaCache.Invalidate(path)
+	fh.node.root.dataCache.Invalidate(path)
 
 	log.Debugf("[file] Write success: path=%s, written=%d", path, n)
 	return uint32(n), 0
 }
 
 // Fsync syncs file data to storage
-func (fh *AGFSFileHandle) Fsync(ctx context.Context, flags uint32) syscall.Errno {
+func (fh *AGFSFileHandle) Fsync(ctx context.Context, flags uint32) (errno syscall.Errno) {
+	defer fh.node.root.stats.track("fsync", time.Now(), &errno)
 	err := fh.node.root.handles.Sync(fh.handle)
 	if err != nil {
 		return syscall.EIO
@@ -60,7 +65,8 @@ func (fh *AGFSFileHandle) Fsync(ctx context.Context, flags uint32) syscall.Errno
 }
 
 // Release releases the file handle
-func (fh *AGFSFileHandle) Release(ctx context.Context) syscall.Errno {
+func (fh *AGFSFileHandle) Release(ctx context.Context) (errno syscall.Errno) {
+	defer fh.node.root.stats.track("release", time.Now(), &errno)
 	err := fh.node.root.handles.Close(fh.handle)
 	if err != nil {
 		return syscall.EIO
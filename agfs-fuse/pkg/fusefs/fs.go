@@ -3,7 +3,11 @@ package fusefs
 import (
 	"context"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -11,6 +15,7 @@ import (
 	"github.com/dongxuny/agfs-fuse/pkg/cache"
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
+	log "github.com/sirupsen/logrus"
 )
 
 // AGFSFS is the root of the FUSE file system
@@ -21,15 +26,45 @@ type AGFSFS struct {
 	handles   *HandleManager
 	metaCache *cache.MetadataCache
 	dirCache  *cache.DirectoryCache
+	dataCache *cache.DataCache
 	cacheTTL  time.Duration
-	mu        sync.RWMutex
+	stats     *Stats
+	serverURL string
+	// caseInsensitive makes lookups match names differing only in case
+	caseInsensitive bool
+	// openFlags converts the flags of opens
+	openFlags *flagPolicy
+	// prefetchResults are the results of the last prefetches written to
+	// the control file
+	prefetchResults string
+	// noBatch is set once the server turned out to predate batch reads;
+	// prefetches then read the small files of a directory one by one
+	noBatch atomic.Bool
+	mu      sync.RWMutex
 }
 
 // Config contains filesystem configuration
 type Config struct {
-	ServerURL string
+	ServerURL string // http(s)://host:port or unix:///path/to/socket
+	Token     string // Bearer token, if the server's listener requires one
 	CacheTTL  time.Duration
 	Debug     bool
+
+	// Compression is the encoding of file contents on the wire ("zstd" or
+	// "lz4"), empty for none
+	Compression string
+
+	// CaseInsensitive makes lookups fall back to a name differing only in
+	// case, as macOS applications expect
+	CaseInsensitive bool
+
+	// OpenFlags overrides what the mount does with open flags AGFS has no
+	// direct equivalent of (O_DIRECT, O_NOATIME, ...)
+	OpenFlags OpenFlagPolicy
+
+	// PrefetchTTL is how long file contents cached by Prefetch are kept
+	// (DefaultPrefetchTTL if 0)
+	PrefetchTTL time.Duration
 }
 
 // NewAGFSFS creates a new AGFS FUSE filesystem
@@ -39,19 +74,104 @@ func NewAGFSFS(config Config) *AGFSFS {
 		Timeout: 60 * time.Second,
 	}
 	client := agfs.NewClientWithHTTPClient(config.ServerURL, httpClient)
+	stats := NewStats()
+	client.WrapTransport(stats.transport)
+	if config.Token != "" {
+		client.SetToken(config.Token)
+	}
+	// Name the mount to the server, for administrators revoking its handles
+	if hostname, err := os.Hostname(); err == nil {
+		client.SetClientName("agfs-fuse@" + hostname)
+	}
+	if config.Compression != "" {
+		if err := client.SetCompression(config.Compression); err != nil {
+			log.Warnf("Compression %s disabled: %v", config.Compression, err)
+		}
+	}
+
+	handles := NewHandleManager(client)
+	handles.StartRenewal(leaseRenewInterval)
+
+	prefetchTTL := config.PrefetchTTL
+	if prefetchTTL <= 0 {
+		prefetchTTL = DefaultPrefetchTTL
+	}
 
 	return &AGFSFS{
 		client:    client,
-		handles:   NewHandleManager(client),
+		handles:   handles,
 		metaCache: cache.NewMetadataCache(config.CacheTTL),
 		dirCache:  cache.NewDirectoryCache(config.CacheTTL),
+		dataCache: cache.NewDataCache(prefetchTTL),
 		cacheTTL:  config.CacheTTL,
+		stats:     stats,
+		serverURL: config.ServerURL,
+
+		caseInsensitive: config.CaseInsensitive,
+		openFlags:       newFlagPolicy(config.OpenFlags),
 	}
 }
 
+// lookup returns the path and metadata of the child name of the directory
+// parentPath. On case-insensitive mounts a child whose name differs only in
+// case is found too; the path returned is the one on the server.
+func (root *AGFSFS) lookup(parentPath, name string) (string, *agfs.FileInfo, error) {
+	childPath := filepath.Join(parentPath, name)
+	info, err := root.stat(childPath)
+	if err == nil || !root.caseInsensitive {
+		return childPath, info, err
+	}
+
+	files, listErr := root.readDir(parentPath)
+	if listErr != nil {
+		return "", nil, err
+	}
+	for _, f := range files {
+		if strings.EqualFold(f.Name, name) {
+			childPath = filepath.Join(parentPath, f.Name)
+			info, err := root.stat(childPath)
+			return childPath, info, err
+		}
+	}
+	return "", nil, err
+}
+
+// stat returns the metadata of path, from the cache if possible
+func (root *AGFSFS) stat(path string) (*agfs.FileInfo, error) {
+	if cached, ok := root.metaCache.Get(path); ok {
+		return cached, nil
+	}
+	info, err := root.client.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	root.metaCache.Set(path, info)
+	return info, nil
+}
+
+// readDir returns the listing of path, from the cache if possible. The
+// metadata of the entries of a fetched listing is cached too: a listing is
+// mostly followed by a lookup of each entry (ls -l, find), which would
+// otherwise wait for the server one entry at a time.
+func (root *AGFSFS) readDir(path string) ([]agfs.FileInfo, error) {
+	if cached, ok := root.dirCache.Get(path); ok {
+		return cached, nil
+	}
+	files, err := root.client.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	root.dirCache.Set(path, files)
+	for i := range files {
+		root.metaCache.Set(filepath.Join(path, files[i].Name), &files[i])
+	}
+	return files, nil
+}
+
 // Close closes the filesystem and releases resources
 func (root *AGFSFS) Close() error {
 	// Close all open handles
+	root.handles.StopRenewal()
 	if err := root.handles.CloseAll(); err != nil {
 		return err
 	}
@@ -59,6 +179,7 @@ func (root *AGFSFS) Close() error {
 	// Clear caches
 	root.metaCache.Clear()
 	root.dirCache.Clear()
+	root.dataCache.Clear()
 
 	return nil
 }
@@ -81,6 +202,7 @@ func (root *AGFSFS) Statfs(ctx context.Context, out *fuse.StatfsOut) syscall.Err
 // invalidateCache invalidates cache for a path and its parent directory
 func (root *AGFSFS) invalidateCache(path string) {
 	root.metaCache.Invalidate(path)
+	root.dataCache.Invalidate(path)
 
 	// Invalidate parent directory listing
 	parent := getParentPath(path)
@@ -144,22 +266,18 @@ func (root *AGFSFS) Getattr(ctx context.Context, f fs.FileHandle, out *fuse.Attr
 }
 
 // Lookup looks up a child node in the root directory
-func (root *AGFSFS) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
-	childPath := "/" + name
+func (root *AGFSFS) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (inode *fs.Inode, errno syscall.Errno) {
+	defer root.stats.track("lookup", time.Now(), &errno)
+	if name == ControlDirName {
+		return root.controlDir(ctx, out), 0
+	}
+	if isReservedName(name) {
+		return nil, syscall.ENOENT
+	}
 
-	// Try cache first
-	var info *agfs.FileInfo
-	if cached, ok := root.metaCache.Get(childPath); ok {
-		info = cached
-	} else {
-		// Fetch from server
-		var err error
-		info, err = root.client.Stat(childPath)
-		if err != nil {
-			return nil, syscall.ENOENT
-		}
-		// Cache the result
-		root.metaCache.Set(childPath, info)
+	childPath, info, err := root.lookup("/", name)
+	if err != nil {
+		return nil, syscall.ENOENT
 	}
 
 	fillAttr(&out.Attr, info)
@@ -172,28 +290,20 @@ func (root *AGFSFS) Lookup(ctx context.Context, name string, out *fuse.EntryOut)
 	child := &AGFSNode{
 		root: root,
 		path: childPath,
+		name: filepath.Base(childPath),
 	}
 
 	return root.NewInode(ctx, child, stable), 0
 }
 
 // Readdir reads root directory contents
-func (root *AGFSFS) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+func (root *AGFSFS) Readdir(ctx context.Context) (stream fs.DirStream, errno syscall.Errno) {
+	defer root.stats.track("readdir", time.Now(), &errno)
 	rootPath := "/"
 
-	// Try cache first
-	var files []agfs.FileInfo
-	if cached, ok := root.dirCache.Get(rootPath); ok {
-		files = cached
-	} else {
-		// Fetch from server
-		var err error
-		files, err = root.client.ReadDir(rootPath)
-		if err != nil {
-			return nil, syscall.EIO
-		}
-		// Cache the result
-		root.dirCache.Set(rootPath, files)
+	files, err := root.readDir(rootPath)
+	if err != nil {
+		return nil, syscall.EIO
 	}
 
 	// Convert to FUSE entries
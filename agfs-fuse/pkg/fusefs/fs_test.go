@@ -0,0 +1,44 @@
+package fusefs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-sdk/go/agfstest"
+)
+
+func TestReadDirCachesEntries(t *testing.T) {
+	srv := agfstest.NewServer()
+	defer srv.Close()
+	srv.WriteFile("/dir/a.txt", []byte("a"))
+	srv.WriteFile("/dir/b.txt", []byte("bb"))
+	srv.WriteFile("/dir/sub/c.txt", []byte("c"))
+
+	root := NewAGFSFS(Config{ServerURL: srv.URL, CacheTTL: time.Minute})
+	defer root.Close()
+
+	before := root.stats.report(srv.URL).Server.Requests
+	if _, err := root.readDir("/dir"); err != nil {
+		t.Fatal(err)
+	}
+	if requests := root.stats.report(srv.URL).Server.Requests - before; requests != 1 {
+		t.Errorf("expected one request for the listing, got %d", requests)
+	}
+
+	// The entries are looked up from the listing, without requests
+	before = root.stats.report(srv.URL).Server.Requests
+	for path, size := range map[string]int64{"/dir/a.txt": 1, "/dir/b.txt": 2} {
+		if info, err := root.stat(path); err != nil || info.Size != size {
+			t.Errorf("%s: expected the metadata of the listing, got %+v, %v", path, info, err)
+		}
+	}
+	if info, err := root.stat("/dir/sub"); err != nil || !info.IsDir {
+		t.Errorf("expected the subdirectory to be cached, got %+v, %v", info, err)
+	}
+	if _, err := root.readDir("/dir"); err != nil {
+		t.Fatal(err)
+	}
+	if requests := root.stats.report(srv.URL).Server.Requests - before; requests != 0 {
+		t.Errorf("expected no request after the listing, got %d", requests)
+	}
+}
@@ -41,6 +41,10 @@ type handleInfo struct {
 	streamCancel context.CancelFunc
 }
 
+// leaseRenewInterval is how often the leases of remote handles are renewed,
+// well within the server's default lease of 60 seconds
+const leaseRenewInterval = 20 * time.Second
+
 // HandleManager manages the mapping between FUSE handles and AGFS handles
 type HandleManager struct {
 	client *agfs.Client
@@ -49,6 +53,10 @@ type HandleManager struct {
 	handles map[uint64]*handleInfo
 	// Counter for generating unique FUSE handle IDs
 	nextHandle uint64
+	// Closed to stop renewing leases (see StartRenewal)
+	renewStop chan struct{}
+	// Set once the server turns out not to support batch renewal
+	renewUnsupported atomic.Bool
 }
 
 // NewHandleManager creates a new handle manager
@@ -125,6 +133,22 @@ func (hm *HandleManager) Open(path string, flags agfs.OpenFlag, mode uint32) (ui
 	return fuseHandle, nil
 }
 
+// OpenBuffered returns a FUSE handle ID reading data, the contents of the
+// file at path (e.g. prefetched), without opening the file on the server
+func (hm *HandleManager) OpenBuffered(path string, flags agfs.OpenFlag, data []byte) uint64 {
+	fuseHandle := atomic.AddUint64(&hm.nextHandle, 1)
+
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	hm.handles[fuseHandle] = &handleInfo{
+		htype:      handleTypeLocal,
+		path:       path,
+		flags:      flags,
+		readBuffer: data,
+	}
+	return fuseHandle
+}
+
 // Close closes a handle
 func (hm *HandleManager) Close(fuseHandle uint64) error {
 	hm.mu.Lock()
@@ -178,7 +202,11 @@ func (hm *HandleManager) Read(fuseHandle uint64, offset int64, size int) ([]byte
 	if info.htype == handleTypeRemote {
 		hm.mu.Unlock()
 		// Use server-side handle
-		data, err := hm.client.ReadHandle(info.agfsHandle, offset, size)
+		var data []byte
+		err := hm.withRemote(fuseHandle, info, func(id int64) (err error) {
+			data, err = hm.client.ReadHandle(id, offset, size)
+			return err
+		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to read handle: %w", err)
 		}
@@ -391,13 +419,24 @@ func (hm *HandleManager) Write(fuseHandle uint64, data []byte, offset int64) (in
 		return 0, fmt.Errorf("handle %d not found", fuseHandle)
 	}
 
-	if info.htype == handleTypeRemote {
+	syncWrites := info.flags&agfs.OpenFlagSync != 0
+	if info.htype == handleTypeRemote || info.htype == handleTypeRemoteStream {
 		hm.mu.Unlock()
 		// Use server-side handle (write directly)
-		written, err := hm.client.WriteHandle(info.agfsHandle, data, offset)
+		var written int
+		err := hm.withRemote(fuseHandle, info, func(id int64) (err error) {
+			written, err = hm.client.WriteHandle(id, data, offset)
+			return err
+		})
 		if err != nil {
 			return 0, fmt.Errorf("failed to write handle: %w", err)
 		}
+		// O_SYNC: the write completes once the backend made it durable
+		if syncWrites {
+			if err := hm.withRemote(fuseHandle, info, hm.client.SyncHandle); err != nil {
+				return 0, fmt.Errorf("failed to sync handle: %w", err)
+			}
+		}
 		return written, nil
 	}
 
@@ -411,7 +450,12 @@ func (hm *HandleManager) Write(fuseHandle uint64, data []byte, offset int64) (in
 	log.Debugf("[handles] Local handle write: path=%s, len=%d, offset=%d", path, len(data), offset)
 
 	// Send directly to server
-	_, err := hm.client.Write(path, data)
+	var err error
+	if syncWrites {
+		_, err = hm.client.WriteSync(path, data)
+	} else {
+		_, err = hm.client.Write(path, data)
+	}
 	if err != nil {
 		log.Errorf("[handles] Write failed for %s: %v", path, err)
 		return 0, fmt.Errorf("failed to write to server: %w", err)
@@ -431,19 +475,99 @@ func (hm *HandleManager) Sync(fuseHandle uint64) error {
 	}
 
 	// Remote handles: sync on server
-	if info.htype == handleTypeRemote {
+	if info.htype == handleTypeRemote || info.htype == handleTypeRemoteStream {
 		hm.mu.Unlock()
-		if err := hm.client.SyncHandle(info.agfsHandle); err != nil {
+		if err := hm.withRemote(fuseHandle, info, hm.client.SyncHandle); err != nil {
 			return fmt.Errorf("failed to sync handle: %w", err)
 		}
 		return nil
 	}
 
-	// Local handles: nothing to sync since writes are sent immediately
+	// Local handles: writes were sent immediately, but the backend may
+	// still hold them in its own buffers
+	path := info.path
 	hm.mu.Unlock()
+	if err := hm.client.Sync(path); err != nil {
+		if errors.Is(err, agfs.ErrNotSupported) {
+			// Servers without the sync endpoint only acknowledge durable writes
+			log.Debugf("[handles] Sync not supported for %s", path)
+			return nil
+		}
+		return fmt.Errorf("failed to sync: %w", err)
+	}
 	return nil
 }
 
+// withRemote runs op on the server-side handle of a remote handle. If an
+// administrator revoked the handle, e.g. before maintenance of its backend,
+// the file is opened again and op retried once on the new handle.
+func (hm *HandleManager) withRemote(fuseHandle uint64, info *handleInfo, op func(id int64) error) error {
+	hm.mu.RLock()
+	id := info.agfsHandle
+	hm.mu.RUnlock()
+
+	err := op(id)
+	if !errors.Is(err, agfs.ErrRevoked) {
+		return err
+	}
+	newID, reopenErr := hm.reopen(fuseHandle, info, id)
+	if reopenErr != nil {
+		log.Warnf("[handles] Failed to re-open revoked handle %d on %s: %v", id, info.path, reopenErr)
+		return err
+	}
+	return op(newID)
+}
+
+// reopen replaces the revoked server-side handle oldID of a remote handle by
+// a new one on the same file and returns it. The file is not created or
+// truncated again; streaming handles fall back to reading by offset.
+func (hm *HandleManager) reopen(fuseHandle uint64, info *handleInfo, oldID int64) (int64, error) {
+	hm.mu.RLock()
+	current := info.agfsHandle
+	hm.mu.RUnlock()
+	if current != oldID {
+		// Another operation re-opened it already
+		return current, nil
+	}
+
+	flags := info.flags &^ (agfs.OpenFlagCreate | agfs.OpenFlagExclusive | agfs.OpenFlagTruncate)
+	newID, err := hm.client.OpenHandle(info.path, flags, info.mode)
+	if err != nil {
+		return 0, err
+	}
+	// Closing the revoked handle only tells the server the client is done with it
+	hm.client.CloseHandle(oldID)
+
+	hm.mu.Lock()
+	if hm.handles[fuseHandle] != info {
+		// Closed meanwhile
+		hm.mu.Unlock()
+		hm.client.CloseHandle(newID)
+		return 0, fmt.Errorf("handle %d not found", fuseHandle)
+	}
+	if info.agfsHandle != oldID {
+		hm.mu.Unlock()
+		hm.client.CloseHandle(newID)
+		return info.agfsHandle, nil
+	}
+	info.agfsHandle = newID
+	if info.htype == handleTypeRemoteStream {
+		if info.streamCancel != nil {
+			info.streamCancel()
+		}
+		if info.streamReader != nil {
+			info.streamReader.Close()
+		}
+		info.htype = handleTypeRemote
+		info.streamReader = nil
+		info.streamBuffer = nil
+	}
+	hm.mu.Unlock()
+
+	log.Infof("[handles] Re-opened %s after its handle %d was revoked (new handle %d)", info.path, oldID, newID)
+	return newID, nil
+}
+
 // CloseAll closes all open handles
 func (hm *HandleManager) CloseAll() error {
 	hm.mu.Lock()
@@ -476,6 +600,86 @@ func (hm *HandleManager) CloseAll() error {
 	return lastErr
 }
 
+// RenewAll renews the leases of all open remote handles with one request and
+// returns how many were renewed. Handles the server no longer knows (e.g.
+// closed after their lease expired while the client was suspended) are
+// logged; their next use fails. Servers that predate batch renewal do not
+// expire handles, so nothing is renewed on them.
+func (hm *HandleManager) RenewAll() (int, error) {
+	if hm.renewUnsupported.Load() {
+		return 0, nil
+	}
+	hm.mu.RLock()
+	ids := make([]int64, 0, len(hm.handles))
+	paths := make(map[int64]string, len(hm.handles))
+	for _, info := range hm.handles {
+		if info.htype == handleTypeRemote || info.htype == handleTypeRemoteStream {
+			ids = append(ids, info.agfsHandle)
+			paths[info.agfsHandle] = info.path
+		}
+	}
+	hm.mu.RUnlock()
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	renewals, err := hm.client.RenewHandles(ids, 0)
+	if errors.Is(err, agfs.ErrNotSupported) {
+		log.Debugf("[handles] Server does not support lease renewal, handles do not expire")
+		hm.renewUnsupported.Store(true)
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to renew handle leases: %w", err)
+	}
+	renewed := 0
+	for _, r := range renewals {
+		if r.Error != "" {
+			log.Warnf("[handles] Failed to renew lease of handle %d (%s): %s", r.HandleID, paths[r.HandleID], r.Error)
+			continue
+		}
+		renewed++
+	}
+	return renewed, nil
+}
+
+// StartRenewal renews the leases of open remote handles in the background
+// every interval, until StopRenewal is called
+func (hm *HandleManager) StartRenewal(interval time.Duration) {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	if hm.renewStop != nil || interval <= 0 {
+		return
+	}
+	stop := make(chan struct{})
+	hm.renewStop = stop
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if _, err := hm.RenewAll(); err != nil {
+					log.Warnf("[handles] %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// StopRenewal stops the renewal started by StartRenewal
+func (hm *HandleManager) StopRenewal() {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	if hm.renewStop != nil {
+		close(hm.renewStop)
+		hm.renewStop = nil
+	}
+}
+
 // Count returns the number of open handles
 func (hm *HandleManager) Count() int {
 	hm.mu.RLock()
@@ -483,3 +687,21 @@ func (hm *HandleManager) Count() int {
 	return len(hm.handles)
 }
 
+// CountByType returns the number of open handles of each type and in total
+func (hm *HandleManager) CountByType() map[string]int {
+	hm.mu.RLock()
+	defer hm.mu.RUnlock()
+	counts := map[string]int{"remote": 0, "remote_stream": 0, "local": 0, "total": len(hm.handles)}
+	for _, info := range hm.handles {
+		switch info.htype {
+		case handleTypeRemote:
+			counts["remote"]++
+		case handleTypeRemoteStream:
+			counts["remote_stream"]++
+		case handleTypeLocal:
+			counts["local"]++
+		}
+	}
+	return counts
+}
+
@@ -98,3 +98,178 @@ func TestHandleManager_OpenHandleNotSupportedFallback(t *testing.T) {
 		t.Errorf("Expected 0 handles after close, got %d", count)
 	}
 }
+
+func TestHandleManager_SyncLocalHandle(t *testing.T) {
+	var synced, writeSync []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/handles/open":
+			w.WriteHeader(http.StatusNotImplemented)
+			json.NewEncoder(w).Encode(agfs.ErrorResponse{Error: "handlefs not supported"})
+		case "/api/v1/sync":
+			synced = append(synced, r.URL.Query().Get("path"))
+			json.NewEncoder(w).Encode(agfs.SuccessResponse{Message: "synced"})
+		case "/api/v1/files":
+			writeSync = append(writeSync, r.URL.Query().Get("sync"))
+			json.NewEncoder(w).Encode(agfs.SuccessResponse{Message: "written"})
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer testServer.Close()
+
+	hm := NewHandleManager(agfs.NewClient(testServer.URL))
+
+	// fsync of a local handle syncs the file on the server
+	fh, err := hm.Open("/db/wal", agfs.OpenFlagWriteOnly, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hm.Write(fh, []byte("entry"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := hm.Sync(fh); err != nil {
+		t.Fatal(err)
+	}
+	if len(synced) != 1 || synced[0] != "/db/wal" {
+		t.Errorf("synced = %v", synced)
+	}
+
+	// Writes to O_SYNC handles wait for durability
+	syncFh, err := hm.Open("/db/journal", agfs.OpenFlagWriteOnly|agfs.OpenFlagSync, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hm.Write(syncFh, []byte("entry"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(writeSync) != 2 || writeSync[0] != "" || writeSync[1] != "true" {
+		t.Errorf("sync parameter of writes = %q", writeSync)
+	}
+}
+
+func TestHandleManager_RenewAll(t *testing.T) {
+	var batches [][]int64
+	nextID := int64(100)
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/handles/open":
+			nextID++
+			json.NewEncoder(w).Encode(agfs.HandleResponse{HandleID: nextID})
+		case "/api/v1/handles/renew":
+			var req struct {
+				HandleIDs []int64 `json:"handle_ids"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			batches = append(batches, req.HandleIDs)
+			renewals := []agfs.HandleRenewal{}
+			for _, id := range req.HandleIDs {
+				r := agfs.HandleRenewal{HandleID: id, Lease: 60}
+				if id == 102 {
+					r = agfs.HandleRenewal{HandleID: id, Error: "not found"}
+				}
+				renewals = append(renewals, r)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"renewals": renewals})
+		default:
+			json.NewEncoder(w).Encode(agfs.SuccessResponse{Message: "ok"})
+		}
+	}))
+	defer testServer.Close()
+
+	hm := NewHandleManager(agfs.NewClient(testServer.URL))
+	if n, err := hm.RenewAll(); n != 0 || err != nil || len(batches) != 0 {
+		t.Errorf("expected no request without handles, got %d, %v, %v", n, err, batches)
+	}
+	for _, p := range []string{"/a", "/b", "/c"} {
+		if _, err := hm.Open(p, agfs.OpenFlagReadOnly, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// All handles are renewed with one request
+	n, err := hm.RenewAll()
+	if err != nil {
+		t.Fatalf("RenewAll failed: %v", err)
+	}
+	if n != 2 || len(batches) != 1 || len(batches[0]) != 3 {
+		t.Errorf("renewed %d handles in batches %v", n, batches)
+	}
+}
+
+func TestHandleManager_RenewAllOlderServer(t *testing.T) {
+	renewRequests := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/handles/open":
+			json.NewEncoder(w).Encode(agfs.HandleResponse{HandleID: 1})
+		case "/api/v1/handles/renew":
+			renewRequests++
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(agfs.ErrorResponse{Error: "method not allowed"})
+		}
+	}))
+	defer testServer.Close()
+
+	hm := NewHandleManager(agfs.NewClient(testServer.URL))
+	if _, err := hm.Open("/a", agfs.OpenFlagReadOnly, 0); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if n, err := hm.RenewAll(); n != 0 || err != nil {
+			t.Errorf("RenewAll = %d, %v; want nothing renewed", n, err)
+		}
+	}
+	if renewRequests != 1 {
+		t.Errorf("expected renewal to stop after the server rejected it, got %d requests", renewRequests)
+	}
+}
+
+func TestHandleManager_ReopenRevoked(t *testing.T) {
+	var opens []string
+	var closed []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/handles/open":
+			opens = append(opens, r.URL.Query().Get("flags")+" "+r.Header.Get("X-AGFS-Client"))
+			json.NewEncoder(w).Encode(agfs.HandleResponse{HandleID: int64(len(opens))})
+		case "/api/v1/handles/1/write":
+			w.WriteHeader(http.StatusGone)
+			json.NewEncoder(w).Encode(agfs.ErrorResponse{Error: "handle 1: handle revoked: maintenance"})
+		case "/api/v1/handles/2/write":
+			json.NewEncoder(w).Encode(map[string]int{"bytes_written": 5})
+		case "/api/v1/handles/1", "/api/v1/handles/2":
+			closed = append(closed, r.URL.Path)
+			json.NewEncoder(w).Encode(agfs.SuccessResponse{Message: "handle closed"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	client := agfs.NewClient(testServer.URL)
+	client.SetClientName("agfs-fuse@test")
+	hm := NewHandleManager(client)
+	fh, err := hm.Open("/a", agfs.OpenFlagWriteOnly|agfs.OpenFlagCreate|agfs.OpenFlagTruncate, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The revoked handle is re-opened, without truncating the file again
+	if n, err := hm.Write(fh, []byte("hello"), 0); n != 5 || err != nil {
+		t.Fatalf("Write = %d, %v; want the write retried on a new handle", n, err)
+	}
+	if len(opens) != 2 || opens[0] != "577 agfs-fuse@test" || opens[1] != "1 agfs-fuse@test" {
+		t.Errorf("unexpected opens %v", opens)
+	}
+	if err := hm.Close(fh); err != nil {
+		t.Fatal(err)
+	}
+	if len(closed) != 2 || closed[0] != "/api/v1/handles/1" || closed[1] != "/api/v1/handles/2" {
+		t.Errorf("unexpected closes %v", closed)
+	}
+}
@@ -2,8 +2,11 @@ package fusefs
 
 import (
 	"context"
+	"errors"
 	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
 	agfs "github.com/c4pt0r/agfs/agfs-sdk/go"
 	"github.com/hanwen/go-fuse/v2/fs"
@@ -17,6 +20,9 @@ type AGFSNode struct {
 
 	root *AGFSFS
 	path string
+	// name is the name of the node on the server, which on case-insensitive
+	// mounts may differ in case from the name it was looked up with
+	name string
 }
 
 // getPath returns the full path of this node by walking up the inode tree
@@ -25,7 +31,8 @@ func (n *AGFSNode) getPath() string {
 	var pathComponents []string
 	current := &n.Inode
 
-	for current != nil {
+	// The root of the server is below the root of union mounts
+	for current != nil && current != &n.root.Inode {
 		name, parent := current.Parent()
 		if parent == nil {
 			// We've reached the root
@@ -33,6 +40,7 @@ func (n *AGFSNode) getPath() string {
 		}
 
 		if name != "" {
+			name = serverName(current, name)
 			pathComponents = append([]string{name}, pathComponents...)
 		}
 
@@ -46,6 +54,23 @@ func (n *AGFSNode) getPath() string {
 	return "/" + filepath.Join(pathComponents...)
 }
 
+// serverName returns the name on the server of inode, known to its parent as
+// name; a name set by a rename since the lookup wins
+func serverName(inode *fs.Inode, name string) string {
+	if node, ok := inode.Operations().(*AGFSNode); ok && node.name != "" && strings.EqualFold(node.name, name) {
+		return node.name
+	}
+	return name
+}
+
+// childPath returns the server path of the child name of the node at path
+func (n *AGFSNode) childPath(path, name string) string {
+	if child := n.GetChild(name); child != nil {
+		name = serverName(child, name)
+	}
+	return filepath.Join(path, name)
+}
+
 var _ = (fs.NodeGetattrer)((*AGFSNode)(nil))
 var _ = (fs.NodeLookuper)((*AGFSNode)(nil))
 var _ = (fs.NodeReaddirer)((*AGFSNode)(nil))
@@ -60,7 +85,8 @@ var _ = (fs.NodeReadlinker)((*AGFSNode)(nil))
 var _ = (fs.NodeSymlinker)((*AGFSNode)(nil))
 
 // Getattr returns file attributes
-func (n *AGFSNode) Getattr(ctx context.Context, f fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+func (n *AGFSNode) Getattr(ctx context.Context, f fs.FileHandle, out *fuse.AttrOut) (errno syscall.Errno) {
+	defer n.root.stats.track("getattr", time.Now(), &errno)
 	path := n.getPath()
 
 	// Try cache first
@@ -85,23 +111,16 @@ func (n *AGFSNode) Getattr(ctx context.Context, f fs.FileHandle, out *fuse.AttrO
 }
 
 // Lookup looks up a child node
-func (n *AGFSNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+func (n *AGFSNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (inode *fs.Inode, errno syscall.Errno) {
+	defer n.root.stats.track("lookup", time.Now(), &errno)
+	if isReservedName(name) {
+		return nil, syscall.ENOENT
+	}
 	path := n.getPath()
-	childPath := filepath.Join(path, name)
 
-	// Try cache first
-	var info *agfs.FileInfo
-	if cached, ok := n.root.metaCache.Get(childPath); ok {
-		info = cached
-	} else {
-		// Fetch from server
-		var err error
-		info, err = n.root.client.Stat(childPath)
-		if err != nil {
-			return nil, syscall.ENOENT
-		}
-		// Cache the result
-		n.root.metaCache.Set(childPath, info)
+	childPath, info, err := n.root.lookup(path, name)
+	if err != nil {
+		return nil, syscall.ENOENT
 	}
 
 	fillAttr(&out.Attr, info)
@@ -114,28 +133,20 @@ func (n *AGFSNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut)
 	child := &AGFSNode{
 		root: n.root,
 		path: childPath,
+		name: filepath.Base(childPath),
 	}
 
 	return n.NewInode(ctx, child, stable), 0
 }
 
 // Readdir reads directory contents
-func (n *AGFSNode) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+func (n *AGFSNode) Readdir(ctx context.Context) (stream fs.DirStream, errno syscall.Errno) {
+	defer n.root.stats.track("readdir", time.Now(), &errno)
 	path := n.getPath()
 
-	// Try cache first
-	var files []agfs.FileInfo
-	if cached, ok := n.root.dirCache.Get(path); ok {
-		files = cached
-	} else {
-		// Fetch from server
-		var err error
-		files, err = n.root.client.ReadDir(path)
-		if err != nil {
-			return nil, syscall.EIO
-		}
-		// Cache the result
-		n.root.dirCache.Set(path, files)
+	files, err := n.root.readDir(path)
+	if err != nil {
+		return nil, syscall.EIO
 	}
 
 	// Convert to FUSE entries
@@ -152,7 +163,11 @@ func (n *AGFSNode) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
 }
 
 // Mkdir creates a directory
-func (n *AGFSNode) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+func (n *AGFSNode) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (inode *fs.Inode, errno syscall.Errno) {
+	defer n.root.stats.track("mkdir", time.Now(), &errno)
+	if isReservedName(name) {
+		return nil, syscall.EPERM
+	}
 	path := n.getPath()
 	childPath := filepath.Join(path, name)
 
@@ -185,9 +200,10 @@ func (n *AGFSNode) Mkdir(ctx context.Context, name string, mode uint32, out *fus
 }
 
 // Rmdir removes a directory
-func (n *AGFSNode) Rmdir(ctx context.Context, name string) syscall.Errno {
+func (n *AGFSNode) Rmdir(ctx context.Context, name string) (errno syscall.Errno) {
+	defer n.root.stats.track("rmdir", time.Now(), &errno)
 	path := n.getPath()
-	childPath := filepath.Join(path, name)
+	childPath := n.childPath(path, name)
 
 	err := n.root.client.Remove(childPath)
 	if err != nil {
@@ -201,9 +217,10 @@ func (n *AGFSNode) Rmdir(ctx context.Context, name string) syscall.Errno {
 }
 
 // Unlink removes a file
-func (n *AGFSNode) Unlink(ctx context.Context, name string) syscall.Errno {
+func (n *AGFSNode) Unlink(ctx context.Context, name string) (errno syscall.Errno) {
+	defer n.root.stats.track("unlink", time.Now(), &errno)
 	path := n.getPath()
-	childPath := filepath.Join(path, name)
+	childPath := n.childPath(path, name)
 
 	err := n.root.client.Remove(childPath)
 	if err != nil {
@@ -217,18 +234,31 @@ func (n *AGFSNode) Unlink(ctx context.Context, name string) syscall.Errno {
 }
 
 // Rename renames a file or directory
-func (n *AGFSNode) Rename(ctx context.Context, name string, newParent fs.InodeEmbedder, newName string, flags uint32) syscall.Errno {
+func (n *AGFSNode) Rename(ctx context.Context, name string, newParent fs.InodeEmbedder, newName string, flags uint32) (errno syscall.Errno) {
+	defer n.root.stats.track("rename", time.Now(), &errno)
+	if isReservedName(newName) {
+		return syscall.EPERM
+	}
 	path := n.getPath()
-	oldPath := filepath.Join(path, name)
+	oldPath := n.childPath(path, name)
 
 	// Get new parent path
 	var newParentPath string
-	if _, ok := newParent.(*AGFSFS); ok {
+	// Renames across the servers of a union mount are left to copies
+	if root, ok := newParent.(*AGFSFS); ok {
 		// New parent is root
+		if root != n.root {
+			return syscall.EXDEV
+		}
 		newParentPath = "/"
 	} else if newParentNode, ok := newParent.(*AGFSNode); ok {
 		// New parent is a regular node
+		if newParentNode.root != n.root {
+			return syscall.EXDEV
+		}
 		newParentPath = newParentNode.getPath()
+	} else if _, ok := newParent.(*UnionFS); ok {
+		return syscall.EXDEV
 	} else {
 		return syscall.EINVAL
 	}
@@ -238,6 +268,11 @@ func (n *AGFSNode) Rename(ctx context.Context, name string, newParent fs.InodeEm
 	if err != nil {
 		return syscall.EIO
 	}
+	if child := n.GetChild(name); child != nil {
+		if node, ok := child.Operations().(*AGFSNode); ok {
+			node.name = newName
+		}
+	}
 
 	// Invalidate caches
 	n.root.invalidateCache(oldPath)
@@ -248,13 +283,31 @@ func (n *AGFSNode) Rename(ctx context.Context, name string, newParent fs.InodeEm
 
 // Create creates a new file
 func (n *AGFSNode) Create(ctx context.Context, name string, flags uint32, mode uint32, out *fuse.EntryOut) (node *fs.Inode, fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	defer n.root.stats.track("create", time.Now(), &errno)
+	if isReservedName(name) {
+		return nil, nil, 0, syscall.EPERM
+	}
 	path := n.getPath()
 	childPath := filepath.Join(path, name)
 
 	log.Debugf("[node] Create called: path=%s, name=%s, childPath=%s", path, name, childPath)
 
-	// Create the file
-	err := n.root.client.Create(childPath)
+	openFlags, errno := n.root.openFlags.convert(childPath, flags)
+	if errno != 0 {
+		return nil, nil, 0, errno
+	}
+
+	// Create the file. With O_EXCL the server creates it atomically, so that
+	// of several clients creating the same file only one succeeds.
+	var err error
+	if openFlags&agfs.OpenFlagExclusive != 0 {
+		err = n.root.client.CreateExclusive(childPath)
+	} else {
+		err = n.root.client.Create(childPath)
+	}
+	if errors.Is(err, agfs.ErrAlreadyExists) && openFlags&agfs.OpenFlagExclusive != 0 {
+		return nil, nil, 0, syscall.EEXIST
+	}
 	if err != nil {
 		log.Errorf("[node] Create failed for %s: %v", childPath, err)
 		return nil, nil, 0, syscall.EIO
@@ -265,8 +318,8 @@ func (n *AGFSNode) Create(ctx context.Context, name string, flags uint32, mode u
 	// Invalidate caches
 	n.root.invalidateCache(childPath)
 
-	// Open the file with the requested flags
-	openFlags := convertOpenFlags(flags)
+	// Open the file, which exists now, with the requested flags
+	openFlags &^= agfs.OpenFlagCreate | agfs.OpenFlagExclusive
 	fuseHandle, err := n.root.handles.Open(childPath, openFlags, mode)
 	if err != nil {
 		log.Errorf("[node] Open handle failed for %s: %v", childPath, err)
@@ -306,11 +359,27 @@ func (n *AGFSNode) Create(ctx context.Context, name string, flags uint32, mode u
 
 // Open opens a file
 func (n *AGFSNode) Open(ctx context.Context, flags uint32) (fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	defer n.root.stats.track("open", time.Now(), &errno)
 	path := n.getPath()
-	openFlags := convertOpenFlags(flags)
-	fuseHandle, err := n.root.handles.Open(path, openFlags, 0644)
-	if err != nil {
-		return nil, 0, syscall.EIO
+	openFlags, errno := n.root.openFlags.convert(path, flags)
+	if errno != 0 {
+		return nil, 0, errno
+	}
+
+	// Files opened for reading are served from prefetched contents if the
+	// file has not changed since
+	var fuseHandle uint64
+	if openFlags&(agfs.OpenFlagWriteOnly|agfs.OpenFlagReadWrite|agfs.OpenFlagTruncate) == 0 {
+		if data, ok := n.root.cachedData(path); ok {
+			fuseHandle = n.root.handles.OpenBuffered(path, openFlags, data)
+		}
+	}
+	if fuseHandle == 0 {
+		var err error
+		fuseHandle, err = n.root.handles.Open(path, openFlags, 0644)
+		if err != nil {
+			return nil, 0, syscall.EIO
+		}
 	}
 
 	fileHandle := &AGFSFileHandle{
@@ -324,7 +393,8 @@ func (n *AGFSNode) Open(ctx context.Context, flags uint32) (fh fs.FileHandle, fu
 }
 
 // Setattr sets file attributes
-func (n *AGFSNode) Setattr(ctx context.Context, f fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+func (n *AGFSNode) Setattr(ctx context.Context, f fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) (errno syscall.Errno) {
+	defer n.root.stats.track("setattr", time.Now(), &errno)
 	path := n.getPath()
 
 	// Handle chmod
@@ -347,6 +417,7 @@ func (n *AGFSNode) Setattr(ctx context.Context, f fs.FileHandle, in *fuse.SetAtt
 
 		// Invalidate cache
 		n.root.metaCache.Invalidate(path)
+		n.root.dataCache.Invalidate(path)
 	}
 
 	// Return updated attributes
@@ -354,7 +425,8 @@ func (n *AGFSNode) Setattr(ctx context.Context, f fs.FileHandle, in *fuse.SetAtt
 }
 
 // Readlink reads the target of a symbolic link
-func (n *AGFSNode) Readlink(ctx context.Context) ([]byte, syscall.Errno) {
+func (n *AGFSNode) Readlink(ctx context.Context) (link []byte, errno syscall.Errno) {
+	defer n.root.stats.track("readlink", time.Now(), &errno)
 	path := n.getPath()
 	target, err := n.root.client.Readlink(path)
 	if err != nil {
@@ -364,7 +436,11 @@ func (n *AGFSNode) Readlink(ctx context.Context) ([]byte, syscall.Errno) {
 }
 
 // Symlink creates a symbolic link
-func (n *AGFSNode) Symlink(ctx context.Context, target, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+func (n *AGFSNode) Symlink(ctx context.Context, target, name string, out *fuse.EntryOut) (inode *fs.Inode, errno syscall.Errno) {
+	defer n.root.stats.track("symlink", time.Now(), &errno)
+	if isReservedName(name) {
+		return nil, syscall.EPERM
+	}
 	path := n.getPath()
 	linkPath := filepath.Join(path, name)
 
@@ -419,37 +495,3 @@ func fillAttr(out *fuse.Attr, info *agfs.FileInfo) {
 		out.Mode |= syscall.S_IFREG
 	}
 }
-
-// convertOpenFlags converts FUSE open flags to AGFS OpenFlag
-func convertOpenFlags(flags uint32) agfs.OpenFlag {
-	accessMode := flags & syscall.O_ACCMODE
-
-	var openFlag agfs.OpenFlag
-
-	switch accessMode {
-	case syscall.O_RDONLY:
-		openFlag = agfs.OpenFlagReadOnly
-	case syscall.O_WRONLY:
-		openFlag = agfs.OpenFlagWriteOnly
-	case syscall.O_RDWR:
-		openFlag = agfs.OpenFlagReadWrite
-	}
-
-	if flags&syscall.O_APPEND != 0 {
-		openFlag |= agfs.OpenFlagAppend
-	}
-	if flags&syscall.O_CREAT != 0 {
-		openFlag |= agfs.OpenFlagCreate
-	}
-	if flags&syscall.O_EXCL != 0 {
-		openFlag |= agfs.OpenFlagExclusive
-	}
-	if flags&syscall.O_TRUNC != 0 {
-		openFlag |= agfs.OpenFlagTruncate
-	}
-	if flags&syscall.O_SYNC != 0 {
-		openFlag |= agfs.OpenFlagSync
-	}
-
-	return openFlag
-}
@@ -0,0 +1,177 @@
+package fusefs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	agfs "github.com/c4pt0r/agfs/agfs-sdk/go"
+	log "github.com/sirupsen/logrus"
+)
+
+// FlagAction is what the mount does with an open(2) flag AGFS has no direct
+// equivalent of
+type FlagAction string
+
+const (
+	FlagIgnore  FlagAction = "ignore"  // Open the file as if the flag were not set
+	FlagEmulate FlagAction = "emulate" // Give the flag's semantics with AGFS operations
+	FlagReject  FlagAction = "reject"  // Fail the open with EINVAL
+)
+
+// openFlag is an open(2) flag the policy applies to
+type openFlag struct {
+	name     string
+	bits     uint32
+	fallback FlagAction    // Action unless the policy says otherwise
+	emulable bool          // Whether FlagEmulate is possible
+	emulate  agfs.OpenFlag // AGFS flags emulating it
+}
+
+// commonOpenFlags are the flags the policy applies to on every platform, in
+// the order they are matched
+var commonOpenFlags = []openFlag{
+	// Emulated by an atomic create-exclusive on the server (see Create)
+	{name: "excl", bits: syscall.O_EXCL, fallback: FlagEmulate, emulable: true, emulate: agfs.OpenFlagExclusive},
+	{name: "sync", bits: syscall.O_SYNC, fallback: FlagEmulate, emulable: true, emulate: agfs.OpenFlagSync},
+	// Signal-driven I/O: there is nothing to signal
+	{name: "async", bits: syscall.O_ASYNC, fallback: FlagIgnore},
+}
+
+// passedFlags are flags converted directly, or already handled by the kernel
+const passedFlags = syscall.O_ACCMODE | syscall.O_APPEND | syscall.O_CREAT | syscall.O_TRUNC |
+	syscall.O_NONBLOCK | syscall.O_NOCTTY | syscall.O_NOFOLLOW | syscall.O_CLOEXEC | syscall.O_DIRECTORY
+
+// policyFlags returns the flags the policy applies to on this platform
+func policyFlags() []openFlag {
+	return append(append([]openFlag{}, commonOpenFlags...), platformOpenFlags...)
+}
+
+// OpenFlagPolicy maps names of open flags ("excl", "sync", "direct",
+// "noatime", ...) to what the mount does with them; flags it leaves out get
+// their default action
+type OpenFlagPolicy map[string]FlagAction
+
+// ParseOpenFlagPolicy parses a policy of comma-separated name=action pairs,
+// e.g. "direct=reject,noatime=ignore"
+func ParseOpenFlagPolicy(s string) (OpenFlagPolicy, error) {
+	flags := map[string]openFlag{}
+	var names []string
+	for _, f := range policyFlags() {
+		flags[f.name] = f
+		names = append(names, f.name)
+	}
+	sort.Strings(names)
+
+	policy := OpenFlagPolicy{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, action, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid open flag policy %q: expected name=action", pair)
+		}
+		name = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(name)), "o_")
+		f, known := flags[name]
+		if !known {
+			return nil, fmt.Errorf("unknown open flag %q (known: %s)", name, strings.Join(names, ", "))
+		}
+		switch a := FlagAction(strings.ToLower(action)); a {
+		case FlagIgnore, FlagReject:
+			policy[name] = a
+		case FlagEmulate:
+			if !f.emulable {
+				return nil, fmt.Errorf("open flag %s cannot be emulated", name)
+			}
+			policy[name] = a
+		default:
+			return nil, fmt.Errorf("invalid action %q for open flag %s: expected ignore, emulate or reject", action, name)
+		}
+	}
+	return policy, nil
+}
+
+// flagPolicy converts the flags of opens, logging the first time it meets
+// each flag AGFS has no direct equivalent of
+type flagPolicy struct {
+	actions OpenFlagPolicy
+	flags   []openFlag
+	logged  sync.Map // Names of the flags logged
+}
+
+func newFlagPolicy(actions OpenFlagPolicy) *flagPolicy {
+	return &flagPolicy{actions: actions, flags: policyFlags()}
+}
+
+// action returns what the policy does with a flag
+func (p *flagPolicy) action(f openFlag) FlagAction {
+	if a, ok := p.actions[f.name]; ok {
+		return a
+	}
+	return f.fallback
+}
+
+// convert converts the flags of a FUSE open to AGFS open flags, applying the
+// policy to the flags AGFS has no direct equivalent of. It fails with EINVAL
+// if the policy rejects one of them.
+func (p *flagPolicy) convert(path string, flags uint32) (agfs.OpenFlag, syscall.Errno) {
+	var openFlag agfs.OpenFlag
+	switch flags & syscall.O_ACCMODE {
+	case syscall.O_RDONLY:
+		openFlag = agfs.OpenFlagReadOnly
+	case syscall.O_WRONLY:
+		openFlag = agfs.OpenFlagWriteOnly
+	case syscall.O_RDWR:
+		openFlag = agfs.OpenFlagReadWrite
+	}
+	if flags&syscall.O_APPEND != 0 {
+		openFlag |= agfs.OpenFlagAppend
+	}
+	if flags&syscall.O_CREAT != 0 {
+		openFlag |= agfs.OpenFlagCreate
+	}
+	if flags&syscall.O_TRUNC != 0 {
+		openFlag |= agfs.OpenFlagTruncate
+	}
+
+	rest := flags &^ passedFlags
+	for _, f := range p.flags {
+		if f.bits == 0 || rest&f.bits != f.bits {
+			continue
+		}
+		rest &^= f.bits
+
+		action := p.action(f)
+		p.log(f.name, action)
+		log.Debugf("[openflags] %s: O_%s %s", path, strings.ToUpper(f.name), action)
+		switch action {
+		case FlagReject:
+			return 0, syscall.EINVAL
+		case FlagEmulate:
+			openFlag |= f.emulate
+		}
+	}
+	if rest != 0 {
+		log.Debugf("[openflags] %s: ignoring unknown open flags %#o", path, rest)
+	}
+	return openFlag, 0
+}
+
+// log logs the action on a flag the first time the flag is met
+func (p *flagPolicy) log(name string, action FlagAction) {
+	if _, seen := p.logged.LoadOrStore(name, true); seen {
+		return
+	}
+	switch action {
+	case FlagReject:
+		log.Warnf("Rejecting opens with O_%s (open flag policy)", strings.ToUpper(name))
+	case FlagIgnore:
+		log.Infof("Ignoring O_%s on open: AGFS has no equivalent", strings.ToUpper(name))
+	case FlagEmulate:
+		log.Infof("Emulating O_%s on open", strings.ToUpper(name))
+	}
+}
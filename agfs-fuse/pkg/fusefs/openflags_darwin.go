@@ -0,0 +1,15 @@
+//go:build darwin
+
+package fusefs
+
+import (
+	"syscall"
+
+	agfs "github.com/c4pt0r/agfs/agfs-sdk/go"
+)
+
+// platformOpenFlags are the flags of macOS the policy applies to, besides
+// commonOpenFlags
+var platformOpenFlags = []openFlag{
+	{name: "dsync", bits: syscall.O_DSYNC, fallback: FlagEmulate, emulable: true, emulate: agfs.OpenFlagSync},
+}
@@ -0,0 +1,20 @@
+//go:build linux
+
+package fusefs
+
+import (
+	"syscall"
+
+	agfs "github.com/c4pt0r/agfs/agfs-sdk/go"
+)
+
+// platformOpenFlags are the flags of Linux the policy applies to, besides
+// commonOpenFlags
+var platformOpenFlags = []openFlag{
+	// O_SYNC includes O_DSYNC on Linux, so it is matched first
+	{name: "dsync", bits: syscall.O_DSYNC, fallback: FlagEmulate, emulable: true, emulate: agfs.OpenFlagSync},
+	// Files are opened with FOPEN_DIRECT_IO already, bypassing the page cache
+	{name: "direct", bits: syscall.O_DIRECT, fallback: FlagEmulate, emulable: true},
+	// AGFS keeps no access times for reads to update
+	{name: "noatime", bits: syscall.O_NOATIME, fallback: FlagEmulate, emulable: true},
+}
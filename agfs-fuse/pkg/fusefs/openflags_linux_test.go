@@ -0,0 +1,22 @@
+package fusefs
+
+import (
+	"syscall"
+	"testing"
+
+	agfs "github.com/c4pt0r/agfs/agfs-sdk/go"
+)
+
+func TestFlagPolicyConvertLinux(t *testing.T) {
+	p := newFlagPolicy(OpenFlagPolicy{"noatime": FlagReject})
+	if flags, errno := p.convert("/f", syscall.O_RDONLY|syscall.O_DIRECT|syscall.O_DSYNC); errno != 0 || flags != agfs.OpenFlagReadOnly|agfs.OpenFlagSync {
+		t.Errorf("convert = %v, %v", flags, errno)
+	}
+	// O_SYNC includes the bits of O_DSYNC, and is matched as O_SYNC only
+	if flags, _ := p.convert("/f", syscall.O_WRONLY|syscall.O_SYNC); flags != agfs.OpenFlagWriteOnly|agfs.OpenFlagSync {
+		t.Errorf("O_SYNC converted to %v", flags)
+	}
+	if _, errno := p.convert("/f", syscall.O_RDONLY|syscall.O_NOATIME); errno != syscall.EINVAL {
+		t.Errorf("expected O_NOATIME to be rejected, got %v", errno)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !linux && !darwin
+
+package fusefs
+
+// platformOpenFlags are the flags of this platform the policy applies to,
+// besides commonOpenFlags
+var platformOpenFlags []openFlag
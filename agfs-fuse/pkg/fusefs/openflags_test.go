@@ -0,0 +1,40 @@
+package fusefs
+
+import (
+	"syscall"
+	"testing"
+
+	agfs "github.com/c4pt0r/agfs/agfs-sdk/go"
+)
+
+func TestParseOpenFlagPolicy(t *testing.T) {
+	policy, err := ParseOpenFlagPolicy(" O_SYNC=ignore, excl=reject,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policy) != 2 || policy["sync"] != FlagIgnore || policy["excl"] != FlagReject {
+		t.Errorf("unexpected policy %v", policy)
+	}
+	for _, s := range []string{"sync", "bogus=ignore", "sync=drop", "async=emulate"} {
+		if _, err := ParseOpenFlagPolicy(s); err == nil {
+			t.Errorf("expected %q to be rejected", s)
+		}
+	}
+}
+
+func TestFlagPolicyConvert(t *testing.T) {
+	defaults := newFlagPolicy(nil)
+	flags, errno := defaults.convert("/f", syscall.O_RDWR|syscall.O_CREAT|syscall.O_EXCL|syscall.O_SYNC|syscall.O_ASYNC|syscall.O_CLOEXEC)
+	want := agfs.OpenFlagReadWrite | agfs.OpenFlagCreate | agfs.OpenFlagExclusive | agfs.OpenFlagSync
+	if errno != 0 || flags != want {
+		t.Errorf("convert = %v, %v; want %v", flags, errno, want)
+	}
+
+	policy := newFlagPolicy(OpenFlagPolicy{"excl": FlagIgnore, "async": FlagReject})
+	if flags, _ := policy.convert("/f", syscall.O_WRONLY|syscall.O_CREAT|syscall.O_EXCL); flags != agfs.OpenFlagWriteOnly|agfs.OpenFlagCreate {
+		t.Errorf("ignored O_EXCL converted to %v", flags)
+	}
+	if _, errno := policy.convert("/f", syscall.O_RDONLY|syscall.O_ASYNC); errno != syscall.EINVAL {
+		t.Errorf("expected rejected O_ASYNC to fail with EINVAL, got %v", errno)
+	}
+}
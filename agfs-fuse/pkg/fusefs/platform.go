@@ -0,0 +1,25 @@
+package fusefs
+
+import (
+	"fmt"
+)
+
+// PlatformOptions are the mount settings that depend on the FUSE
+// implementation of the operating system
+type PlatformOptions struct {
+	// Backend selects the macOS FUSE backend: "macfuse" (kernel extension,
+	// the default) or "fskit" (macFUSE 5 on macOS 15.4 and later)
+	Backend string
+	// VolumeName is the name of the volume shown by Finder (macOS only)
+	VolumeName string
+}
+
+// MountOptions returns the options to pass to the mount helper
+func (o PlatformOptions) MountOptions() ([]string, error) {
+	switch o.Backend {
+	case "", "macfuse", "fskit":
+	default:
+		return nil, fmt.Errorf("unknown FUSE backend %q (want macfuse or fskit)", o.Backend)
+	}
+	return platformMountOptions(o)
+}
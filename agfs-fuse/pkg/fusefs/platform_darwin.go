@@ -0,0 +1,26 @@
+//go:build darwin
+
+package fusefs
+
+import "strings"
+
+// platformMountOptions tells macFUSE to keep Finder metadata off the mount:
+// resource forks and other com.apple.* extended attributes are refused instead
+// of being stored as AppleDouble ("._name") files on the server
+func platformMountOptions(o PlatformOptions) ([]string, error) {
+	opts := []string{"noappledouble", "noapplexattr"}
+	if o.VolumeName != "" {
+		opts = append(opts, "volname="+o.VolumeName)
+	}
+	if o.Backend == "fskit" {
+		opts = append(opts, "backend=fskit")
+	}
+	return opts, nil
+}
+
+// isReservedName reports names the mount refuses to create or look up.
+// The FSKit backend ignores noappledouble, so AppleDouble files are also
+// refused here, which spares the server a lookup for every file Finder shows.
+func isReservedName(name string) bool {
+	return strings.HasPrefix(name, "._")
+}
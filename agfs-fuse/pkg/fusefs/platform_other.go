@@ -0,0 +1,17 @@
+//go:build !darwin
+
+package fusefs
+
+import "fmt"
+
+func platformMountOptions(o PlatformOptions) ([]string, error) {
+	if o.Backend != "" {
+		return nil, fmt.Errorf("FUSE backend %q is only available on macOS", o.Backend)
+	}
+	return nil, nil
+}
+
+// isReservedName reports names the mount refuses to create or look up
+func isReservedName(name string) bool {
+	return false
+}
@@ -0,0 +1,71 @@
+package fusefs
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+
+	agfs "github.com/c4pt0r/agfs/agfs-sdk/go"
+)
+
+func TestPlatformMountOptions(t *testing.T) {
+	if _, err := (PlatformOptions{Backend: "fuse-t"}).MountOptions(); err == nil {
+		t.Error("unknown backend accepted")
+	}
+
+	opts, err := PlatformOptions{Backend: "fskit", VolumeName: "agfs"}.MountOptions()
+	if runtime.GOOS != "darwin" {
+		if err == nil {
+			t.Errorf("fskit backend accepted on %s", runtime.GOOS)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"noappledouble", "noapplexattr", "volname=agfs", "backend=fskit"} {
+		if !slices.Contains(opts, want) {
+			t.Errorf("options %v lack %s", opts, want)
+		}
+	}
+	if !isReservedName("._notes.txt") || isReservedName("notes.txt") {
+		t.Error("AppleDouble files not reserved")
+	}
+}
+
+func TestCaseInsensitiveLookup(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		path := r.URL.Query().Get("path")
+		switch {
+		case r.URL.Path == "/api/v1/stat" && path == "/docs/README.md":
+			json.NewEncoder(w).Encode(agfs.FileInfoResponse{Name: "README.md", Size: 5, Mode: 0644})
+		case r.URL.Path == "/api/v1/directories" && path == "/docs":
+			json.NewEncoder(w).Encode(agfs.ListResponse{Files: []agfs.FileInfoResponse{{Name: "README.md", Size: 5, Mode: 0644}}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(agfs.ErrorResponse{Error: "no such file"})
+		}
+	}))
+	defer server.Close()
+
+	sensitive := NewAGFSFS(Config{ServerURL: server.URL, CacheTTL: time.Second})
+	defer sensitive.Close()
+	if _, _, err := sensitive.lookup("/docs", "readme.md"); err == nil {
+		t.Error("case-sensitive mount matched a name differing in case")
+	}
+
+	insensitive := NewAGFSFS(Config{ServerURL: server.URL, CacheTTL: time.Second, CaseInsensitive: true})
+	defer insensitive.Close()
+	path, info, err := insensitive.lookup("/docs", "readme.md")
+	if err != nil || path != "/docs/README.md" || info.Size != 5 {
+		t.Errorf("lookup = %q, %+v, %v", path, info, err)
+	}
+	if _, _, err := insensitive.lookup("/docs", "missing.md"); err == nil {
+		t.Error("lookup of a missing name succeeded")
+	}
+}
@@ -0,0 +1,269 @@
+package fusefs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	agfs "github.com/c4pt0r/agfs/agfs-sdk/go"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultPrefetchTTL is how long prefetched file contents are kept,
+	// unless Config.PrefetchTTL says otherwise
+	DefaultPrefetchTTL = 10 * time.Minute
+
+	// prefetchWorkers is how many requests a prefetch has in flight
+	prefetchWorkers = 8
+
+	// prefetchMaxFileSize is the size of the largest file whose contents
+	// are prefetched; larger files only have their metadata cached
+	prefetchMaxFileSize = 1 << 20
+
+	// prefetchMaxBytes bounds the contents cached by one prefetch
+	prefetchMaxBytes = 256 << 20
+)
+
+// PrefetchResult sums up a prefetch
+type PrefetchResult struct {
+	Pattern  string `json:"pattern"`
+	Dirs     int64  `json:"dirs"`     // Directories listed
+	Files    int64  `json:"files"`    // Files whose metadata was cached
+	Cached   int64  `json:"cached"`   // Files whose contents were cached
+	Bytes    int64  `json:"bytes"`    // Size of the contents cached
+	Errors   int64  `json:"errors"`   // Listings and reads that failed
+	Duration string `json:"duration"` // How long the prefetch took
+}
+
+// String formats the result as the prefetch control file shows it
+func (r *PrefetchResult) String() string {
+	return fmt.Sprintf("%s: %d dirs, %d files, %d cached (%d bytes), %d errors in %s\n",
+		r.Pattern, r.Dirs, r.Files, r.Cached, r.Bytes, r.Errors, r.Duration)
+}
+
+// prefetch is one run of Prefetch
+type prefetch struct {
+	root    *AGFSFS
+	ctx     context.Context
+	pattern []string // Components of the pattern
+	sem     chan struct{}
+	wg      sync.WaitGroup
+	budget  atomic.Int64 // Bytes of contents left to cache
+
+	dirs, files, cached, bytes, errors atomic.Int64
+}
+
+// Prefetch warms the caches for the paths matching pattern, a path glob (see
+// path.Match) on the server: the directories matching it are listed and the
+// metadata of their whole subtree cached, along with the contents of files
+// up to 1 MiB, so that an agent's first accesses do not all wait for the
+// server. Requests are made in parallel.
+func (root *AGFSFS) Prefetch(ctx context.Context, pattern string) (*PrefetchResult, error) {
+	pattern = path.Clean("/" + strings.TrimSpace(pattern))
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	start := time.Now()
+
+	p := &prefetch{
+		root: root,
+		ctx:  ctx,
+		sem:  make(chan struct{}, prefetchWorkers),
+	}
+	if pattern != "/" {
+		p.pattern = strings.Split(pattern[1:], "/")
+	}
+	p.budget.Store(prefetchMaxBytes)
+
+	// Start at the longest part of the pattern without wildcards
+	base, depth := "/", 0
+	for depth < len(p.pattern) && !hasMeta(p.pattern[depth]) {
+		base = path.Join(base, p.pattern[depth])
+		depth++
+	}
+	if depth == len(p.pattern) {
+		info, err := root.client.Stat(base)
+		if err != nil {
+			return nil, err
+		}
+		root.metaCache.Set(base, info)
+		p.visit(base, info, depth, true, nil)
+	} else {
+		p.walk(base, depth, false)
+	}
+	p.wg.Wait()
+
+	result := &PrefetchResult{
+		Pattern:  pattern,
+		Dirs:     p.dirs.Load(),
+		Files:    p.files.Load(),
+		Cached:   p.cached.Load(),
+		Bytes:    p.bytes.Load(),
+		Errors:   p.errors.Load(),
+		Duration: time.Since(start).Round(time.Millisecond).String(),
+	}
+	log.Infof("Prefetched %s", strings.TrimSpace(result.String()))
+	return result, ctx.Err()
+}
+
+// hasMeta reports whether a component of a pattern has wildcards
+func hasMeta(s string) bool {
+	return strings.ContainsAny(s, `*?[\`)
+}
+
+// do runs a request in the background, at most prefetchWorkers at a time
+func (p *prefetch) do(f func()) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		select {
+		case p.sem <- struct{}{}:
+		case <-p.ctx.Done():
+			return
+		}
+		defer func() { <-p.sem }()
+		f()
+	}()
+}
+
+// walk lists the directory dir, depth components deep, and visits its
+// entries; selected is set once dir matches the whole pattern
+func (p *prefetch) walk(dir string, depth int, selected bool) {
+	p.do(func() {
+		files, err := p.root.client.ReadDir(dir)
+		if err != nil {
+			log.Debugf("[prefetch] ReadDir %s: %v", dir, err)
+			p.errors.Add(1)
+			return
+		}
+		p.dirs.Add(1)
+		p.root.dirCache.Set(dir, files)
+
+		// The small files of the directory are read with one request
+		var batch []fetchEntry
+		for i := range files {
+			info := &files[i]
+			child := path.Join(dir, info.Name)
+			p.root.metaCache.Set(child, info)
+
+			if selected {
+				p.visit(child, info, depth+1, true, &batch)
+				continue
+			}
+			if ok, _ := path.Match(p.pattern[depth], info.Name); ok {
+				p.visit(child, info, depth+1, depth+1 == len(p.pattern), &batch)
+			}
+		}
+		p.fetchMany(batch)
+	})
+}
+
+// visit prefetches an entry depth components deep matching the pattern so
+// far, or all of it if selected. Files to fetch are added to batch, or
+// fetched on their own if it is nil.
+func (p *prefetch) visit(child string, info *agfs.FileInfo, depth int, selected bool, batch *[]fetchEntry) {
+	switch {
+	case info.IsDir && !info.IsSymlink:
+		p.walk(child, depth, selected)
+	case selected:
+		p.files.Add(1)
+		if info.IsSymlink || !p.reserve(info) {
+			return
+		}
+		if batch != nil {
+			*batch = append(*batch, fetchEntry{child, info})
+		} else {
+			p.fetch(fetchEntry{child, info})
+		}
+	}
+}
+
+// fetchEntry is a file whose contents are prefetched
+type fetchEntry struct {
+	path string
+	info *agfs.FileInfo
+}
+
+// reserve checks if the contents of a file fit the limits, taking them from
+// the budget. Empty files are skipped: their size says nothing for control
+// files (e.g. of queuefs), whose reads have effects.
+func (p *prefetch) reserve(info *agfs.FileInfo) bool {
+	if info.Size <= 0 || info.Size > prefetchMaxFileSize {
+		return false
+	}
+	return p.budget.Add(-info.Size) >= 0
+}
+
+// fetch caches the contents of a file
+func (p *prefetch) fetch(e fetchEntry) {
+	p.do(func() {
+		data, err := p.root.client.Read(e.path, 0, e.info.Size)
+		if err != nil {
+			log.Debugf("[prefetch] Read %s: %v", e.path, err)
+			p.errors.Add(1)
+			return
+		}
+		p.store(e, data)
+	})
+}
+
+// fetchMany caches the contents of files with one request, or one request
+// each from servers without batch reads
+func (p *prefetch) fetchMany(batch []fetchEntry) {
+	if len(batch) < 2 || p.root.noBatch.Load() {
+		for _, e := range batch {
+			p.fetch(e)
+		}
+		return
+	}
+	p.do(func() {
+		paths := make([]string, len(batch))
+		for i, e := range batch {
+			paths[i] = e.path
+		}
+		results, err := p.root.client.ReadMany(paths, prefetchMaxFileSize)
+		if err != nil {
+			log.Debugf("[prefetch] ReadMany: %v", err)
+			if errors.Is(err, agfs.ErrNotSupported) {
+				p.root.noBatch.Store(true)
+			}
+			for _, e := range batch {
+				p.fetch(e)
+			}
+			return
+		}
+		for i, r := range results {
+			if r.Err != nil {
+				log.Debugf("[prefetch] Read %s: %v", r.Path, r.Err)
+				p.errors.Add(1)
+				continue
+			}
+			p.store(batch[i], r.Data)
+		}
+	})
+}
+
+// store caches the contents of a file read by a prefetch
+func (p *prefetch) store(e fetchEntry, data []byte) {
+	if int64(len(data)) != e.info.Size {
+		// Changed since it was listed
+		return
+	}
+	p.root.dataCache.Set(e.path, e.info, data)
+	p.cached.Add(1)
+	p.bytes.Add(e.info.Size)
+}
+
+// cachedData returns the prefetched contents of a file, if it has not
+// changed since
+func (root *AGFSFS) cachedData(path string) ([]byte, bool) {
+	return root.dataCache.Get(path, func() (*agfs.FileInfo, error) {
+		return root.stat(path)
+	})
+}
@@ -0,0 +1,88 @@
+package fusefs
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-sdk/go/agfstest"
+)
+
+func TestPrefetch(t *testing.T) {
+	srv := agfstest.NewServer()
+	defer srv.Close()
+	srv.WriteFile("/repo/a/src/main.go", []byte("package main"))
+	srv.WriteFile("/repo/a/src/lib/lib.go", []byte("package lib"))
+	srv.WriteFile("/repo/a/docs/README.md", []byte("docs"))
+	srv.WriteFile("/repo/b/src/big.bin", []byte(strings.Repeat("x", prefetchMaxFileSize+1)))
+	srv.WriteFile("/repo/b/src/empty", nil)
+
+	root := NewAGFSFS(Config{ServerURL: srv.URL, CacheTTL: time.Minute})
+	defer root.Close()
+
+	result, err := root.Prefetch(context.Background(), "repo/*/src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Pattern != "/repo/*/src" || result.Dirs != 6 || result.Files != 4 || result.Cached != 2 || result.Errors != 0 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if _, ok := root.dirCache.Get("/repo/a/src/lib"); !ok {
+		t.Error("directory listing not cached")
+	}
+	if _, ok := root.metaCache.Get("/repo/b/src/big.bin"); !ok {
+		t.Error("metadata of a large file not cached")
+	}
+	if data, ok := root.cachedData("/repo/a/src/lib/lib.go"); !ok || string(data) != "package lib" {
+		t.Errorf("cachedData = %q, %v", data, ok)
+	}
+	if _, ok := root.cachedData("/repo/a/docs/README.md"); ok {
+		t.Error("file outside the pattern prefetched")
+	}
+
+	// Prefetched contents are dropped once the file changed
+	srv.WriteFile("/repo/a/src/main.go", []byte("package main // changed"))
+	root.metaCache.Invalidate("/repo/a/src/main.go")
+	if _, ok := root.cachedData("/repo/a/src/main.go"); ok {
+		t.Error("contents of a changed file served from cache")
+	}
+
+	if _, err := root.Prefetch(context.Background(), "/repo/[a"); err == nil {
+		t.Error("expected an invalid pattern to be rejected")
+	}
+	if _, err := root.Prefetch(context.Background(), "/missing"); err == nil {
+		t.Error("expected a missing path to fail")
+	}
+}
+
+func TestPrefetchBatchesReads(t *testing.T) {
+	srv := agfstest.NewServer()
+	defer srv.Close()
+	for _, name := range []string{"a.go", "b.go", "c.go"} {
+		srv.WriteFile("/dir/"+name, []byte("package dir"))
+	}
+
+	root := NewAGFSFS(Config{ServerURL: srv.URL, CacheTTL: time.Minute})
+	defer root.Close()
+
+	// A stat of the directory, its listing and one read of all its files
+	before := root.stats.report(srv.URL).Server.Requests
+	result, err := root.Prefetch(context.Background(), "/dir")
+	if err != nil || result.Cached != 3 {
+		t.Fatalf("Prefetch = %+v, %v", result, err)
+	}
+	if requests := root.stats.report(srv.URL).Server.Requests - before; requests != 3 {
+		t.Errorf("expected 3 requests, got %d", requests)
+	}
+
+	// Servers without batch reads get one read per file
+	root.noBatch.Store(true)
+	before = root.stats.report(srv.URL).Server.Requests
+	if result, err := root.Prefetch(context.Background(), "/dir"); err != nil || result.Cached != 3 {
+		t.Fatalf("Prefetch = %+v, %v", result, err)
+	}
+	if requests := root.stats.report(srv.URL).Server.Requests - before; requests != 5 {
+		t.Errorf("expected 5 requests without batch reads, got %d", requests)
+	}
+}
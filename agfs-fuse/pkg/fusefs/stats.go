@@ -0,0 +1,257 @@
+package fusefs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/dongxuny/agfs-fuse/pkg/cache"
+)
+
+const (
+	// rttSamples is the number of recent server round trips kept for percentiles
+	rttSamples = 1024
+	// maxConnEvents is the number of connection state changes kept
+	maxConnEvents = 32
+)
+
+// Stats collects the metrics of a mount: FUSE operations, server round trips
+// and changes of server reachability
+type Stats struct {
+	start time.Time
+
+	mu  sync.Mutex
+	ops map[string]*opStats
+
+	requests      uint64
+	requestErrors uint64
+	rtts          [rttSamples]time.Duration
+	rttCount      uint64
+
+	// connected is nil until the first request completes
+	connected  *bool
+	connEvents []ConnEvent
+	reconnects int
+}
+
+type opStats struct {
+	count  uint64
+	errors uint64
+	total  time.Duration
+}
+
+// ConnEvent is a change of the reachability of the server
+type ConnEvent struct {
+	Time      time.Time `json:"time"`
+	Connected bool      `json:"connected"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// NewStats creates an empty metrics collector
+func NewStats() *Stats {
+	return &Stats{start: time.Now(), ops: make(map[string]*opStats)}
+}
+
+// track records a FUSE operation started at start; it is meant to be
+// deferred with a pointer to the operation's result
+func (s *Stats) track(op string, start time.Time, errno *syscall.Errno) {
+	elapsed := time.Since(start)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.ops[op]
+	if !ok {
+		st = &opStats{}
+		s.ops[op] = st
+	}
+	st.count++
+	st.total += elapsed
+	if *errno != 0 {
+		st.errors++
+	}
+}
+
+// recordRequest records a round trip to the server; err is a transport
+// error, not an HTTP error status
+func (s *Stats) recordRequest(rtt time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	if err != nil {
+		s.requestErrors++
+	} else {
+		s.rtts[s.rttCount%rttSamples] = rtt
+		s.rttCount++
+	}
+
+	// Cancelled requests (e.g. closed streams) say nothing about the server
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	connected := err == nil
+	if s.connected != nil && *s.connected == connected {
+		return
+	}
+	if s.connected != nil && connected {
+		s.reconnects++
+	}
+	s.connected = &connected
+	event := ConnEvent{Time: time.Now(), Connected: connected}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	s.connEvents = append(s.connEvents, event)
+	if len(s.connEvents) > maxConnEvents {
+		s.connEvents = s.connEvents[len(s.connEvents)-maxConnEvents:]
+	}
+}
+
+// transport wraps base so that every request to the server is recorded
+func (s *Stats) transport(base http.RoundTripper) http.RoundTripper {
+	return &statsTransport{base: base, stats: s}
+}
+
+type statsTransport struct {
+	base  http.RoundTripper
+	stats *Stats
+}
+
+func (t *statsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	t.stats.recordRequest(time.Since(start), err)
+	return resp, err
+}
+
+// OpReport summarizes the calls of a FUSE operation
+type OpReport struct {
+	Count      uint64  `json:"count"`
+	Errors     uint64  `json:"errors"`
+	AvgLatency string  `json:"avg_latency"`
+	ErrorRate  float64 `json:"error_rate"`
+}
+
+// CacheReport summarizes the lookups of a cache
+type CacheReport struct {
+	cache.Stats
+	HitRate float64 `json:"hit_rate"`
+}
+
+// ServerReport summarizes the requests sent to the server
+type ServerReport struct {
+	URL        string      `json:"url"`
+	Requests   uint64      `json:"requests"`
+	Errors     uint64      `json:"errors"`
+	RTT        RTTReport   `json:"rtt"`
+	Connected  bool        `json:"connected"`
+	Reconnects int         `json:"reconnects"`
+	History    []ConnEvent `json:"history"`
+}
+
+// RTTReport holds percentiles of the recent round trips to the server
+type RTTReport struct {
+	Samples int    `json:"samples"`
+	P50     string `json:"p50"`
+	P90     string `json:"p90"`
+	P99     string `json:"p99"`
+	Max     string `json:"max"`
+}
+
+// StatsReport is the state of a mount served by the debug endpoint
+type StatsReport struct {
+	Uptime  string                 `json:"uptime"`
+	Ops     map[string]OpReport    `json:"ops"`
+	Caches  map[string]CacheReport `json:"caches"`
+	Handles map[string]int         `json:"handles"`
+	Server  ServerReport           `json:"server"`
+}
+
+// report summarizes the operations and requests recorded so far
+func (s *Stats) report(serverURL string) StatsReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := StatsReport{
+		Uptime: time.Since(s.start).Round(time.Second).String(),
+		Ops:    make(map[string]OpReport, len(s.ops)),
+		Server: ServerReport{
+			URL:        serverURL,
+			Requests:   s.requests,
+			Errors:     s.requestErrors,
+			Connected:  s.connected != nil && *s.connected,
+			Reconnects: s.reconnects,
+			History:    append([]ConnEvent{}, s.connEvents...),
+		},
+	}
+	for op, st := range s.ops {
+		r.Ops[op] = OpReport{
+			Count:      st.count,
+			Errors:     st.errors,
+			AvgLatency: (st.total / time.Duration(st.count)).String(),
+			ErrorRate:  float64(st.errors) / float64(st.count),
+		}
+	}
+
+	n := int(min(s.rttCount, rttSamples))
+	samples := append([]time.Duration{}, s.rtts[:n]...)
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	r.Server.RTT = RTTReport{
+		Samples: n,
+		P50:     percentile(samples, 0.50).String(),
+		P90:     percentile(samples, 0.90).String(),
+		P99:     percentile(samples, 0.99).String(),
+		Max:     percentile(samples, 1).String(),
+	}
+	return r
+}
+
+// percentile returns the p-th percentile of sorted samples, 0 if there are none
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(p*float64(len(sorted))+0.5) - 1
+	return sorted[max(0, min(i, len(sorted)-1))]
+}
+
+// Stats returns the metrics of the mount
+func (root *AGFSFS) Stats() StatsReport {
+	r := root.stats.report(root.serverURL)
+	r.Caches = map[string]CacheReport{
+		"metadata":  cacheReport(root.metaCache.Stats()),
+		"directory": cacheReport(root.dirCache.Stats()),
+		"data":      cacheReport(root.dataCache.Stats()),
+	}
+	r.Handles = root.handles.CountByType()
+	return r
+}
+
+func cacheReport(s cache.Stats) CacheReport {
+	return CacheReport{Stats: s, HitRate: s.HitRate()}
+}
+
+// StatsHandler serves the metrics of the mount as JSON on GET /debug/stats
+func (root *AGFSFS) StatsHandler() http.Handler {
+	return statsHandler(func() interface{} { return root.Stats() })
+}
+
+// statsHandler serves the metrics returned by report as JSON on GET
+// /debug/stats
+func statsHandler(report func() interface{}) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/stats", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(report())
+	})
+	return mux
+}
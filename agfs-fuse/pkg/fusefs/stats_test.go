@@ -0,0 +1,76 @@
+package fusefs
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestStatsOps(t *testing.T) {
+	s := NewStats()
+	ok, failed := syscall.Errno(0), syscall.ENOENT
+	s.track("lookup", time.Now(), &ok)
+	s.track("lookup", time.Now(), &failed)
+	s.track("read", time.Now(), &ok)
+
+	r := s.report("http://agfs")
+	if lookup := r.Ops["lookup"]; lookup.Count != 2 || lookup.Errors != 1 || lookup.ErrorRate != 0.5 {
+		t.Errorf("lookup = %+v", lookup)
+	}
+	if r.Ops["read"].Count != 1 {
+		t.Errorf("read = %+v", r.Ops["read"])
+	}
+}
+
+func TestStatsConnectionHistory(t *testing.T) {
+	s := NewStats()
+	for i := 1; i <= 100; i++ {
+		s.recordRequest(time.Duration(i)*time.Millisecond, nil)
+	}
+	s.recordRequest(0, errors.New("connection refused"))
+	s.recordRequest(0, errors.New("connection refused"))
+	s.recordRequest(time.Millisecond, nil)
+
+	r := s.report("http://agfs").Server
+	if r.Requests != 103 || r.Errors != 2 || !r.Connected || r.Reconnects != 1 {
+		t.Errorf("server = %+v", r)
+	}
+	if len(r.History) != 3 || r.History[1].Connected || r.History[1].Error == "" {
+		t.Errorf("history = %+v", r.History)
+	}
+	if r.RTT.Samples != 101 || r.RTT.P50 != "50ms" || r.RTT.P99 != "99ms" || r.RTT.Max != "100ms" {
+		t.Errorf("rtt = %+v", r.RTT)
+	}
+}
+
+func TestStatsHandler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"a.txt","size":1,"mode":420}`))
+	}))
+	defer server.Close()
+
+	root := NewAGFSFS(Config{ServerURL: server.URL, CacheTTL: time.Second})
+	defer root.Close()
+	if _, err := root.client.Stat("/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	root.metaCache.Get("/a.txt")
+
+	rec := httptest.NewRecorder()
+	root.StatsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/stats", nil))
+	var report StatsReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Server.Requests != 1 || !report.Server.Connected || report.Server.URL != server.URL {
+		t.Errorf("server = %+v", report.Server)
+	}
+	if report.Caches["metadata"].Misses != 1 || report.Handles["total"] != 0 {
+		t.Errorf("caches = %+v, handles = %+v", report.Caches, report.Handles)
+	}
+}
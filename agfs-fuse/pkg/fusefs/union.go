@@ -0,0 +1,123 @@
+package fusefs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// UnionServer is one of the servers of a union mount
+type UnionServer struct {
+	// Name is the directory of the mount the server is exposed under
+	Name   string
+	Config Config
+}
+
+// UnionFS is the root of a mount exposing several AGFS servers, each under a
+// directory of the mount named after it. Each server has its own client,
+// credentials, caches and handles: one being unreachable fails only the
+// operations below its directory, and the others carry on while its
+// connection comes back.
+type UnionFS struct {
+	fs.Inode
+
+	names []string
+	roots map[string]*AGFSFS
+}
+
+// NewUnionFS creates the root of a mount exposing servers
+func NewUnionFS(servers []UnionServer) (*UnionFS, error) {
+	if len(servers) == 0 {
+		return nil, errors.New("no servers")
+	}
+	seen := make(map[string]bool, len(servers))
+	for _, s := range servers {
+		if err := validateUnionName(s.Name); err != nil {
+			return nil, err
+		}
+		if seen[s.Name] {
+			return nil, fmt.Errorf("duplicate server name %q", s.Name)
+		}
+		seen[s.Name] = true
+	}
+
+	u := &UnionFS{roots: make(map[string]*AGFSFS, len(servers))}
+	for _, s := range servers {
+		u.names = append(u.names, s.Name)
+		u.roots[s.Name] = NewAGFSFS(s.Config)
+	}
+	sort.Strings(u.names)
+	return u, nil
+}
+
+// validateUnionName checks that name can be the directory of a server
+func validateUnionName(name string) error {
+	switch {
+	case name == "" || name == "." || name == "..":
+		return fmt.Errorf("invalid server name %q", name)
+	case strings.ContainsRune(name, '/'):
+		return fmt.Errorf("invalid server name %q: must not contain '/'", name)
+	case name == ControlDirName || isReservedName(name):
+		return fmt.Errorf("server name %q is reserved", name)
+	}
+	return nil
+}
+
+// Interface assertions for the union root
+var _ = (fs.NodeOnAdder)((*UnionFS)(nil))
+var _ = (fs.NodeGetattrer)((*UnionFS)(nil))
+
+// OnAdd adds the directory of each server; lookups and listings of the root
+// are answered from them by go-fuse
+func (u *UnionFS) OnAdd(ctx context.Context) {
+	for _, name := range u.names {
+		child := u.NewPersistentInode(ctx, u.roots[name], fs.StableAttr{Mode: syscall.S_IFDIR})
+		u.AddChild(name, child, false)
+	}
+}
+
+// Getattr returns attributes for the union root
+func (u *UnionFS) Getattr(ctx context.Context, f fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555 | syscall.S_IFDIR
+	out.Size = 4096
+	return 0
+}
+
+// Server returns the file system of the server named name, nil if there is
+// none
+func (u *UnionFS) Server(name string) *AGFSFS {
+	return u.roots[name]
+}
+
+// Close closes the file systems of all servers
+func (u *UnionFS) Close() error {
+	var errs []error
+	for _, name := range u.names {
+		if err := u.roots[name].Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Stats returns the metrics of each server of the mount, by name
+func (u *UnionFS) Stats() map[string]StatsReport {
+	reports := make(map[string]StatsReport, len(u.names))
+	for _, name := range u.names {
+		reports[name] = u.roots[name].Stats()
+	}
+	return reports
+}
+
+// StatsHandler serves the metrics of each server of the mount as JSON on GET
+// /debug/stats
+func (u *UnionFS) StatsHandler() http.Handler {
+	return statsHandler(func() interface{} { return u.Stats() })
+}
@@ -0,0 +1,69 @@
+package fusefs
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-sdk/go/agfstest"
+	"github.com/hanwen/go-fuse/v2/fs"
+)
+
+func TestUnionFS(t *testing.T) {
+	a, b := agfstest.NewServer(), agfstest.NewServer()
+	defer a.Close()
+	defer b.Close()
+	u, err := NewUnionFS([]UnionServer{
+		{Name: "b", Config: Config{ServerURL: b.URL, CacheTTL: time.Minute}},
+		{Name: "a", Config: Config{ServerURL: a.URL, CacheTTL: time.Minute}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer u.Close()
+	fs.NewNodeFS(u, &fs.Options{})
+
+	for _, name := range []string{"a", "b"} {
+		child := u.GetChild(name)
+		if child == nil || child.Operations() != u.Server(name) {
+			t.Fatalf("expected the directory of server %s, got %v", name, child)
+		}
+	}
+
+	// Paths on a server start at its directory
+	ctx := context.Background()
+	rootA, rootB := u.Server("a"), u.Server("b")
+	dir := &AGFSNode{root: rootA, name: "dir"}
+	rootA.AddChild("dir", rootA.NewPersistentInode(ctx, dir, fs.StableAttr{Mode: syscall.S_IFDIR}), false)
+	if path := dir.getPath(); path != "/dir" {
+		t.Errorf("expected /dir, got %s", path)
+	}
+
+	if errno := dir.Rename(ctx, "f", rootB, "f", 0); errno != syscall.EXDEV {
+		t.Errorf("expected renames across servers to fail with EXDEV, got %v", errno)
+	}
+	if errno := dir.Rename(ctx, "f", u, "f", 0); errno != syscall.EXDEV {
+		t.Errorf("expected renames to the union root to fail with EXDEV, got %v", errno)
+	}
+
+	if _, err := rootA.stat("/"); err != nil {
+		t.Fatal(err)
+	}
+	stats := u.Stats()
+	if len(stats) != 2 || stats["a"].Server.URL != a.URL || stats["a"].Server.Requests == 0 || stats["b"].Server.Requests != 0 {
+		t.Errorf("expected the requests of each server apart, got %+v", stats)
+	}
+
+	for _, servers := range [][]UnionServer{
+		nil,
+		{{Name: "a/b"}},
+		{{Name: ".."}},
+		{{Name: ControlDirName}},
+		{{Name: "a"}, {Name: "a"}},
+	} {
+		if _, err := NewUnionFS(servers); err == nil {
+			t.Errorf("%+v: expected an error", servers)
+		}
+	}
+}
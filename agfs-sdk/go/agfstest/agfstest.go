@@ -0,0 +1,159 @@
+// Package agfstest provides an in-memory AGFS server for testing code that
+// uses the AGFS SDK, in the manner of net/http/httptest:
+//
+//	srv := agfstest.NewServer()
+//	defer srv.Close()
+//	client := srv.Client()
+//
+// The server speaks the same HTTP API as agfs-server, backed by a memfs-like
+// tree: files, directories, symlinks, file handles, conditional writes, grep,
+// MD5 digests and batch stats and reads. Errors carry the status codes of agfs-server, so the
+// sentinel errors of the SDK (agfs.ErrNotFound, agfs.ErrConflict, ...) work
+// as they do against a real server.
+package agfstest
+
+import (
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	agfs "github.com/c4pt0r/agfs/agfs-sdk/go"
+)
+
+// Server is an in-memory AGFS server listening on a local address
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	nodes    map[string]*node
+	handles  map[int64]*handle
+	nextID   int64
+	version  int64
+	watchers []func(path string)
+}
+
+type node struct {
+	dir     bool
+	data    []byte
+	mode    uint32
+	target  string // symlink target, empty for files and directories
+	modTime time.Time
+	version int64
+}
+
+type handle struct {
+	path  string
+	flags agfs.OpenFlag
+	pos   int64
+}
+
+// NewServer starts a server with an empty root directory; call Close when
+// done with it
+func NewServer() *Server {
+	s := &Server{
+		nodes:   map[string]*node{"/": {dir: true, mode: 0755, modTime: time.Now()}},
+		handles: make(map[int64]*handle),
+		nextID:  1,
+	}
+	s.Server = httptest.NewServer(s.routes())
+	return s
+}
+
+// Client returns a client of the server
+func (s *Server) Client() *agfs.Client {
+	return agfs.NewClient(s.URL)
+}
+
+// WriteFile creates or replaces a file, creating its missing parent
+// directories; it is meant for seeding the server before a test
+func (s *Server) WriteFile(p string, data []byte) {
+	p = clean(p)
+	s.mu.Lock()
+	for dir := path.Dir(p); dir != "/"; dir = path.Dir(dir) {
+		if _, ok := s.nodes[dir]; !ok {
+			s.nodes[dir] = &node{dir: true, mode: 0755, modTime: time.Now()}
+		}
+	}
+	s.nodes[p] = &node{data: append([]byte(nil), data...), mode: 0644, modTime: time.Now(), version: s.nextVersion()}
+	s.mu.Unlock()
+	s.notify(p)
+}
+
+// ReadFile returns the contents of a file, and false if there is none
+func (s *Server) ReadFile(p string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.nodes[clean(p)]
+	if !ok || n.dir || n.target != "" {
+		return nil, false
+	}
+	return append([]byte(nil), n.data...), true
+}
+
+// Paths returns the paths of all files, directories and symlinks, sorted
+func (s *Server) Paths() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	paths := make([]string, 0, len(s.nodes))
+	for p := range s.nodes {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// OpenHandles returns the number of file handles that are open
+func (s *Server) OpenHandles() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.handles)
+}
+
+// Watch calls fn with the path of every change made on the server, after
+// the change. agfs-server has no watch API, so clients can't subscribe to
+// changes; Watch stands in for it in tests of code that reacts to changes
+// made by others, e.g.
+//
+//	srv.Watch(client.InvalidateCache)
+func (s *Server) Watch(fn func(path string)) {
+	s.mu.Lock()
+	s.watchers = append(s.watchers, fn)
+	s.mu.Unlock()
+}
+
+// notify calls the watchers with a changed path; s.mu must not be held
+func (s *Server) notify(p string) {
+	s.mu.Lock()
+	watchers := append([]func(string){}, s.watchers...)
+	s.mu.Unlock()
+	for _, fn := range watchers {
+		fn(p)
+	}
+}
+
+// nextVersion returns a new version for a changed file; s.mu is held
+func (s *Server) nextVersion() int64 {
+	s.version++
+	return s.version
+}
+
+// children returns the paths directly below dir, sorted; s.mu is held
+func (s *Server) children(dir string) []string {
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	var paths []string
+	for p := range s.nodes {
+		if p != "/" && strings.HasPrefix(p, prefix) && !strings.Contains(p[len(prefix):], "/") {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// clean normalizes a path the way agfs-server does
+func clean(p string) string {
+	return path.Clean("/" + p)
+}
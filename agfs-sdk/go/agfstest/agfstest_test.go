@@ -0,0 +1,183 @@
+package agfstest
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	agfs "github.com/c4pt0r/agfs/agfs-sdk/go"
+)
+
+func TestServerFiles(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	client := srv.Client()
+
+	if err := client.Mkdir("/docs", 0755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	if _, err := client.Write("/docs/a.txt", []byte("hello\nworld\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	data, err := client.Read("/docs/a.txt", 6, 5)
+	if err != nil || string(data) != "world" {
+		t.Fatalf("Read = %q, %v", data, err)
+	}
+
+	files, err := client.ReadDir("/docs")
+	if err != nil || len(files) != 1 || files[0].Name != "a.txt" || files[0].Size != 12 {
+		t.Fatalf("ReadDir = %+v, %v", files, err)
+	}
+
+	if err := client.Rename("/docs/a.txt", "/docs/b.txt"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if _, err := client.Stat("/docs/a.txt"); !errors.Is(err, agfs.ErrNotFound) {
+		t.Fatalf("Stat of renamed file: %v, want ErrNotFound", err)
+	}
+	if err := client.Truncate("/docs/b.txt", 5); err != nil {
+		t.Fatalf("Truncate: %v", err)
+	}
+	if data, ok := srv.ReadFile("/docs/b.txt"); !ok || string(data) != "hello" {
+		t.Fatalf("ReadFile = %q, %v", data, ok)
+	}
+
+	if err := client.Mkdir("/docs", 0755); !errors.Is(err, agfs.ErrAlreadyExists) {
+		t.Fatalf("Mkdir of existing dir: %v, want ErrAlreadyExists", err)
+	}
+	if err := client.Remove("/docs"); !errors.Is(err, agfs.ErrInvalidArgument) {
+		t.Fatalf("Remove of non-empty dir: %v, want ErrInvalidArgument", err)
+	}
+	if err := client.RemoveAll("/docs"); err != nil {
+		t.Fatalf("RemoveAll: %v", err)
+	}
+	if paths := srv.Paths(); len(paths) != 1 {
+		t.Fatalf("Paths after RemoveAll = %v, want only /", paths)
+	}
+}
+
+func TestServerConditionalWrites(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	client := srv.Client()
+
+	srv.WriteFile("/notes.md", []byte("v1"))
+	info, err := client.Stat("/notes.md")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	version, err := client.WriteIf("/notes.md", []byte("v2"), info.Version)
+	if err != nil {
+		t.Fatalf("WriteIf: %v", err)
+	}
+	if _, err := client.WriteIf("/notes.md", []byte("v3"), info.Version); !errors.Is(err, agfs.ErrConflict) {
+		t.Fatalf("WriteIf with stale version: %v, want ErrConflict", err)
+	}
+	if err := client.RemoveIf("/notes.md", version); err != nil {
+		t.Fatalf("RemoveIf: %v", err)
+	}
+}
+
+func TestServerHandles(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	client := srv.Client()
+
+	id, err := client.OpenHandle("/log", agfs.OpenFlagReadWrite|agfs.OpenFlagCreate, 0644)
+	if err != nil {
+		t.Fatalf("OpenHandle: %v", err)
+	}
+	if _, err := client.WriteHandle(id, []byte("0123456789"), 0); err != nil {
+		t.Fatalf("WriteHandle: %v", err)
+	}
+	if pos, err := client.SeekHandle(id, -4, io.SeekEnd); err != nil || pos != 6 {
+		t.Fatalf("SeekHandle = %d, %v", pos, err)
+	}
+	if data, err := client.ReadHandle(id, 2, 3); err != nil || string(data) != "234" {
+		t.Fatalf("ReadHandle = %q, %v", data, err)
+	}
+	if info, err := client.StatHandle(id); err != nil || info.Size != 10 {
+		t.Fatalf("StatHandle = %+v, %v", info, err)
+	}
+	if err := client.SyncHandle(id); err != nil {
+		t.Fatalf("SyncHandle: %v", err)
+	}
+	if err := client.CloseHandle(id); err != nil {
+		t.Fatalf("CloseHandle: %v", err)
+	}
+	if srv.OpenHandles() != 0 {
+		t.Fatalf("OpenHandles = %d after close", srv.OpenHandles())
+	}
+
+	_, err = client.OpenHandle("/log", agfs.OpenFlagWriteOnly|agfs.OpenFlagCreate|agfs.OpenFlagExclusive, 0644)
+	if !errors.Is(err, agfs.ErrAlreadyExists) {
+		t.Fatalf("exclusive OpenHandle of existing file: %v, want ErrAlreadyExists", err)
+	}
+	if _, err := client.ReadHandle(id, 0, 1); !errors.Is(err, agfs.ErrNotFound) {
+		t.Fatalf("ReadHandle of closed handle: %v, want ErrNotFound", err)
+	}
+}
+
+func TestServerSymlinksGrepDigest(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	client := srv.Client()
+
+	srv.WriteFile("/src/main.go", []byte("package main\n// TODO: tests\n"))
+	if err := client.Symlink("main.go", "/src/link"); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+	if target, err := client.Readlink("/src/link"); err != nil || target != "main.go" {
+		t.Fatalf("Readlink = %q, %v", target, err)
+	}
+	if data, err := client.Read("/src/link", 0, -1); err != nil || string(data) != "package main\n// TODO: tests\n" {
+		t.Fatalf("Read through symlink = %q, %v", data, err)
+	}
+
+	res, err := client.Grep("/", "todo", true, true)
+	if err != nil || res.Count != 1 || res.Matches[0].File != "/src/main.go" || res.Matches[0].Line != 2 {
+		t.Fatalf("Grep = %+v, %v", res, err)
+	}
+
+	digest, err := client.Digest("/src/main.go", "md5")
+	if err != nil || len(digest.Digest) != 32 {
+		t.Fatalf("Digest = %+v, %v", digest, err)
+	}
+}
+
+func TestServerBatch(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	client := srv.Client()
+	srv.WriteFile("/a.txt", []byte("aaa"))
+	srv.WriteFile("/big.bin", make([]byte, 100))
+
+	reads, err := client.ReadMany([]string{"/a.txt", "/big.bin"}, 10)
+	if err != nil || string(reads[0].Data) != "aaa" || !errors.Is(reads[1].Err, agfs.ErrQuotaExceeded) {
+		t.Fatalf("ReadMany = %+v, %v", reads, err)
+	}
+}
+
+func TestServerWatch(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	client := srv.Client()
+	client.EnableCache(time.Hour)
+	srv.Watch(client.InvalidateCache)
+
+	srv.WriteFile("/state.json", []byte("{}"))
+	if _, err := client.Stat("/state.json"); err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+
+	// A change by another client reaches the cache through the watcher
+	other := srv.Client()
+	if _, err := other.Write("/state.json", []byte(`{"done":true}`)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	info, err := client.Stat("/state.json")
+	if err != nil || info.Size != 13 {
+		t.Fatalf("Stat after change = %+v, %v; want the new size", info, err)
+	}
+}
@@ -0,0 +1,658 @@
+package agfstest
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	agfs "github.com/c4pt0r/agfs/agfs-sdk/go"
+)
+
+// apiError is an error response, with the status code agfs-server uses for it
+type apiError struct {
+	code    int
+	message string
+}
+
+func errorf(code int, format string, args ...interface{}) *apiError {
+	return &apiError{code: code, message: fmt.Sprintf(format, args...)}
+}
+
+func notFound(p string) *apiError {
+	return errorf(http.StatusNotFound, "no such file or directory: %s", p)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err *apiError) {
+	writeJSON(w, err.code, agfs.ErrorResponse{Error: err.message})
+}
+
+func writeMessage(w http.ResponseWriter, message string) {
+	writeJSON(w, http.StatusOK, agfs.SuccessResponse{Message: message})
+}
+
+// routes returns the handler of the API under /api/v1
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/", func(w http.ResponseWriter, r *http.Request) {
+		endpoint := strings.TrimPrefix(r.URL.Path, "/api/v1")
+		if strings.HasPrefix(endpoint, "/handles/") {
+			s.handleRequest(w, r, strings.TrimPrefix(endpoint, "/handles/"))
+			return
+		}
+		route := r.Method + " " + endpoint
+		switch route {
+		case "GET /health":
+			writeJSON(w, http.StatusOK, map[string]string{"status": "healthy", "version": "agfstest"})
+		case "GET /capabilities":
+			writeJSON(w, http.StatusOK, agfs.CapabilitiesResponse{
+				Version:  "agfstest",
+				Features: []string{"handlefs", "grep", "digest", "stream", "sync", "batch"},
+			})
+		case "POST /files":
+			p, err := s.create(r.URL.Query().Get("path"))
+			s.serve(w, p, err)
+		case "GET /files":
+			s.read(w, r)
+		case "PUT /files":
+			s.write(w, r)
+		case "DELETE /files":
+			s.remove(w, r)
+		case "GET /directories":
+			s.list(w, r.URL.Query().Get("path"))
+		case "POST /directories":
+			p, err := s.mkdir(r.URL.Query().Get("path"), r.URL.Query().Get("mode"))
+			s.serve(w, p, err)
+		case "GET /stat":
+			s.stat(w, r.URL.Query().Get("path"))
+		case "POST /files/batch":
+			s.readMany(w, r)
+		case "POST /rename":
+			s.rename(w, r)
+		case "POST /chmod":
+			s.chmod(w, r)
+		case "POST /truncate":
+			p, err := s.truncate(r.URL.Query().Get("path"), r.URL.Query().Get("size"))
+			s.serve(w, p, err)
+		case "POST /sync":
+			s.serve(w, "", s.sync(r.URL.Query().Get("path")))
+		case "POST /symlink":
+			s.symlink(w, r)
+		case "GET /readlink":
+			s.readlink(w, r.URL.Query().Get("path"))
+		case "POST /grep":
+			s.grep(w, r)
+		case "POST /digest":
+			s.digest(w, r)
+		default:
+			writeError(w, errorf(http.StatusNotFound, "no route for %s", route))
+		}
+	})
+	return mux
+}
+
+// serve answers a request that changes the tree, notifying the watchers of
+// the changed path on success
+func (s *Server) serve(w http.ResponseWriter, changed string, err *apiError) {
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if changed != "" {
+		s.notify(changed)
+	}
+	writeMessage(w, "ok")
+}
+
+// resolve follows symlinks from p; s.mu is held
+func (s *Server) resolve(p string) (string, *node, *apiError) {
+	for i := 0; i < 40; i++ {
+		n, ok := s.nodes[p]
+		if !ok {
+			return p, nil, notFound(p)
+		}
+		if n.target == "" {
+			return p, n, nil
+		}
+		if strings.HasPrefix(n.target, "/") {
+			p = clean(n.target)
+		} else {
+			p = clean(path.Join(path.Dir(p), n.target))
+		}
+	}
+	return p, nil, errorf(http.StatusBadRequest, "too many levels of symbolic links: %s", p)
+}
+
+// parentDir checks that the parent of p is a directory; s.mu is held
+func (s *Server) parentDir(p string) *apiError {
+	parent, ok := s.nodes[path.Dir(p)]
+	if !ok {
+		return notFound(path.Dir(p))
+	}
+	if !parent.dir {
+		return errorf(http.StatusBadRequest, "not a directory: %s", path.Dir(p))
+	}
+	return nil
+}
+
+// file returns the regular file at p, following symlinks; s.mu is held
+func (s *Server) file(p string) (string, *node, *apiError) {
+	p, n, err := s.resolve(p)
+	if err != nil {
+		return p, nil, err
+	}
+	if n.dir {
+		return p, nil, errorf(http.StatusBadRequest, "is a directory: %s", p)
+	}
+	return p, n, nil
+}
+
+// createFile creates an empty file at p; s.mu is held
+func (s *Server) createFile(p string, mode uint32) (*node, *apiError) {
+	if p == "/" {
+		return nil, errorf(http.StatusConflict, "already exists: %s", p)
+	}
+	if err := s.parentDir(p); err != nil {
+		return nil, err
+	}
+	n := &node{mode: mode, modTime: time.Now(), version: s.nextVersion()}
+	s.nodes[p] = n
+	return n, nil
+}
+
+func (s *Server) create(p string) (string, *apiError) {
+	p = clean(p)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.nodes[p]; ok {
+		return "", errorf(http.StatusConflict, "already exists: %s", p)
+	}
+	_, err := s.createFile(p, 0644)
+	return p, err
+}
+
+func (s *Server) mkdir(p, mode string) (string, *apiError) {
+	p = clean(p)
+	perm := uint64(0755)
+	if mode != "" {
+		var err error
+		if perm, err = strconv.ParseUint(mode, 8, 32); err != nil {
+			return "", errorf(http.StatusBadRequest, "invalid mode: %s", mode)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.nodes[p]; ok {
+		return "", errorf(http.StatusConflict, "already exists: %s", p)
+	}
+	if err := s.parentDir(p); err != nil {
+		return "", err
+	}
+	s.nodes[p] = &node{dir: true, mode: uint32(perm), modTime: time.Now()}
+	return p, nil
+}
+
+func (s *Server) read(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	s.mu.Lock()
+	_, n, err := s.file(clean(q.Get("path")))
+	var data []byte
+	if err == nil {
+		data = append([]byte(nil), n.data...)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	offset, _ := strconv.ParseInt(q.Get("offset"), 10, 64)
+	size := int64(-1)
+	if v := q.Get("size"); v != "" {
+		size, _ = strconv.ParseInt(v, 10, 64)
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(section(data, offset, size))
+}
+
+// section returns size bytes of data from offset, all of them for size < 0
+func section(data []byte, offset, size int64) []byte {
+	if offset < 0 || offset >= int64(len(data)) {
+		return nil
+	}
+	data = data[offset:]
+	if size >= 0 && size < int64(len(data)) {
+		data = data[:size]
+	}
+	return data
+}
+
+func (s *Server) write(w http.ResponseWriter, r *http.Request) {
+	p := clean(r.URL.Query().Get("path"))
+	data, readErr := io.ReadAll(r.Body)
+	if readErr != nil {
+		writeError(w, errorf(http.StatusBadRequest, "failed to read request body: %v", readErr))
+		return
+	}
+	ifMatch := strings.Trim(r.Header.Get("If-Match"), `"`)
+
+	s.mu.Lock()
+	target, n, err := s.file(p)
+	switch {
+	case err != nil && err.code == http.StatusNotFound && ifMatch == "":
+		// Writing through a dangling symlink creates its target
+		n, err = s.createFile(target, 0644)
+	case err == nil && ifMatch != "" && ifMatch != "*" && ifMatch != version(n):
+		err = errorf(http.StatusConflict, "version conflict: %s is at version %s", p, version(n))
+	}
+	if err == nil {
+		n.data = data
+		n.modTime = time.Now()
+		n.version = s.nextVersion()
+		w.Header().Set("ETag", `"`+version(n)+`"`)
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.notify(target)
+	writeMessage(w, fmt.Sprintf("Written %d bytes", len(data)))
+}
+
+func (s *Server) remove(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	p := clean(q.Get("path"))
+	recursive := q.Get("recursive") == "true"
+	ifMatch := strings.Trim(r.Header.Get("If-Match"), `"`)
+
+	err := func() *apiError {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		n, ok := s.nodes[p]
+		if !ok {
+			return notFound(p)
+		}
+		if p == "/" {
+			return errorf(http.StatusBadRequest, "cannot remove the root directory")
+		}
+		if ifMatch != "" {
+			if recursive {
+				return errorf(http.StatusBadRequest, "If-Match is not supported for recursive deletes")
+			}
+			if n.dir {
+				return errorf(http.StatusBadRequest, "is a directory: %s", p)
+			}
+			if ifMatch != "*" && ifMatch != version(n) {
+				return errorf(http.StatusConflict, "version conflict: %s is at version %s", p, version(n))
+			}
+		}
+		if n.dir && !recursive && len(s.children(p)) > 0 {
+			return errorf(http.StatusBadRequest, "directory not empty: %s", p)
+		}
+		prefix := p + "/"
+		for k := range s.nodes {
+			if k == p || strings.HasPrefix(k, prefix) {
+				delete(s.nodes, k)
+			}
+		}
+		return nil
+	}()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.notify(p)
+	writeMessage(w, "deleted")
+}
+
+func (s *Server) list(w http.ResponseWriter, p string) {
+	p = clean(p)
+	s.mu.Lock()
+	p, n, err := s.resolve(p)
+	var resp agfs.ListResponse
+	if err == nil && !n.dir {
+		err = errorf(http.StatusBadRequest, "not a directory: %s", p)
+	}
+	if err == nil {
+		resp.Files = []agfs.FileInfoResponse{}
+		for _, child := range s.children(p) {
+			resp.Files = append(resp.Files, info(child, s.nodes[child]))
+		}
+	}
+	s.mu.Unlock()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) stat(w http.ResponseWriter, p string) {
+	p = clean(p)
+	s.mu.Lock()
+	n, ok := s.nodes[p]
+	var resp agfs.FileInfoResponse
+	if ok {
+		resp = info(p, n)
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, notFound(p))
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// batchResult is the outcome of one path of a batch read
+type batchResult struct {
+	Path   string `json:"path"`
+	Status int    `json:"status"`
+	Data   []byte `json:"data,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// decodeBatch decodes the paths and max_size of a batch request
+func decodeBatch(w http.ResponseWriter, r *http.Request) ([]string, int64, bool) {
+	var req struct {
+		Paths   []string `json:"paths"`
+		MaxSize int64    `json:"max_size"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errorf(http.StatusBadRequest, "invalid request body"))
+		return nil, 0, false
+	}
+	return req.Paths, req.MaxSize, true
+}
+
+func (s *Server) readMany(w http.ResponseWriter, r *http.Request) {
+	paths, maxSize, ok := decodeBatch(w, r)
+	if !ok {
+		return
+	}
+	if maxSize <= 0 {
+		maxSize = 1 << 20
+	}
+	results := make([]batchResult, len(paths))
+	s.mu.Lock()
+	for i, p := range paths {
+		results[i] = batchResult{Path: p, Status: http.StatusOK}
+		_, n, err := s.file(clean(p))
+		if err == nil && int64(len(n.data)) > maxSize {
+			err = errorf(http.StatusRequestEntityTooLarge, "quota exceeded: %s is larger than %d bytes", p, maxSize)
+		}
+		if err != nil {
+			results[i].Status = err.code
+			results[i].Error = err.message
+			continue
+		}
+		results[i].Data = append([]byte{}, n.data...)
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{"results": results})
+}
+
+// info returns the file info of the node at p, not following symlinks
+func info(p string, n *node) agfs.FileInfoResponse {
+	fi := agfs.FileInfoResponse{
+		Name:    path.Base(p),
+		Size:    int64(len(n.data)),
+		Mode:    n.mode,
+		ModTime: n.modTime.Format(time.RFC3339Nano),
+		IsDir:   n.dir,
+		Meta:    agfs.MetaData{Name: "agfstest", Type: "file"},
+		Version: version(n),
+	}
+	switch {
+	case n.dir:
+		fi.Meta.Type = "directory"
+	case n.target != "":
+		fi.Meta.Type = "symlink"
+		fi.Size = int64(len(n.target))
+	}
+	return fi
+}
+
+// version returns the version of a file, empty for directories
+func version(n *node) string {
+	if n.dir {
+		return ""
+	}
+	return strconv.FormatInt(n.version, 10)
+}
+
+func (s *Server) rename(w http.ResponseWriter, r *http.Request) {
+	oldPath := clean(r.URL.Query().Get("path"))
+	var req agfs.RenameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.NewPath == "" {
+		writeError(w, errorf(http.StatusBadRequest, "invalid rename request"))
+		return
+	}
+	newPath := clean(req.NewPath)
+
+	err := func() *apiError {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.nodes[oldPath]; !ok {
+			return notFound(oldPath)
+		}
+		if oldPath == "/" || strings.HasPrefix(newPath, oldPath+"/") {
+			return errorf(http.StatusBadRequest, "cannot move %s into itself", oldPath)
+		}
+		if err := s.parentDir(newPath); err != nil {
+			return err
+		}
+		if dst, ok := s.nodes[newPath]; ok && dst.dir {
+			if len(s.children(newPath)) > 0 {
+				return errorf(http.StatusBadRequest, "directory not empty: %s", newPath)
+			}
+		}
+		moved := make(map[string]*node)
+		for k, n := range s.nodes {
+			if k == oldPath || strings.HasPrefix(k, oldPath+"/") {
+				moved[newPath+strings.TrimPrefix(k, oldPath)] = n
+				delete(s.nodes, k)
+			}
+		}
+		for k, n := range moved {
+			s.nodes[k] = n
+		}
+		for _, h := range s.handles {
+			if h.path == oldPath || strings.HasPrefix(h.path, oldPath+"/") {
+				h.path = newPath + strings.TrimPrefix(h.path, oldPath)
+			}
+		}
+		return nil
+	}()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.notify(oldPath)
+	s.notify(newPath)
+	writeJSON(w, http.StatusOK, agfs.RenameResponse{Message: "renamed", Atomic: true})
+}
+
+func (s *Server) chmod(w http.ResponseWriter, r *http.Request) {
+	p := clean(r.URL.Query().Get("path"))
+	var req agfs.ChmodRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errorf(http.StatusBadRequest, "invalid chmod request"))
+		return
+	}
+	s.mu.Lock()
+	p, n, err := s.resolve(p)
+	if err == nil {
+		n.mode = req.Mode
+	}
+	s.mu.Unlock()
+	s.serve(w, p, err)
+}
+
+func (s *Server) truncate(p, size string) (string, *apiError) {
+	n, parseErr := strconv.ParseInt(size, 10, 64)
+	if parseErr != nil || n < 0 {
+		return "", errorf(http.StatusBadRequest, "invalid size: %s", size)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, f, err := s.file(clean(p))
+	if err != nil {
+		return "", err
+	}
+	f.data = resize(f.data, n)
+	f.modTime = time.Now()
+	f.version = s.nextVersion()
+	return p, nil
+}
+
+// resize returns data cut or zero-padded to size bytes
+func resize(data []byte, size int64) []byte {
+	if size <= int64(len(data)) {
+		return data[:size]
+	}
+	return append(data, make([]byte, size-int64(len(data)))...)
+}
+
+// sync has nothing to flush, it only checks that p exists
+func (s *Server) sync(p string) *apiError {
+	p = clean(p)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.nodes[p]; !ok {
+		return notFound(p)
+	}
+	return nil
+}
+
+func (s *Server) symlink(w http.ResponseWriter, r *http.Request) {
+	p := clean(r.URL.Query().Get("path"))
+	var req agfs.SymlinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Target == "" {
+		writeError(w, errorf(http.StatusBadRequest, "invalid symlink request"))
+		return
+	}
+	err := func() *apiError {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.nodes[p]; ok {
+			return errorf(http.StatusConflict, "already exists: %s", p)
+		}
+		if err := s.parentDir(p); err != nil {
+			return err
+		}
+		s.nodes[p] = &node{mode: 0777, target: req.Target, modTime: time.Now(), version: s.nextVersion()}
+		return nil
+	}()
+	s.serve(w, p, err)
+}
+
+func (s *Server) readlink(w http.ResponseWriter, p string) {
+	p = clean(p)
+	s.mu.Lock()
+	n, ok := s.nodes[p]
+	s.mu.Unlock()
+	switch {
+	case !ok:
+		writeError(w, notFound(p))
+	case n.target == "":
+		writeError(w, errorf(http.StatusBadRequest, "not a symlink: %s", p))
+	default:
+		writeJSON(w, http.StatusOK, agfs.ReadlinkResponse{Target: n.target})
+	}
+}
+
+func (s *Server) grep(w http.ResponseWriter, r *http.Request) {
+	var req agfs.GrepRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errorf(http.StatusBadRequest, "invalid grep request"))
+		return
+	}
+	pattern := req.Pattern
+	if req.CaseInsensitive {
+		pattern = "(?i)" + pattern
+	}
+	re, reErr := regexp.Compile(pattern)
+	if reErr != nil {
+		writeError(w, errorf(http.StatusBadRequest, "invalid pattern: %v", reErr))
+		return
+	}
+
+	root := clean(req.Path)
+	resp := agfs.GrepResponse{Matches: []agfs.GrepMatch{}}
+	s.mu.Lock()
+	root, n, err := s.resolve(root)
+	if err == nil {
+		files := []string{root}
+		if n.dir {
+			files = s.files(root, req.Recursive)
+		}
+		for _, p := range files {
+			for i, line := range strings.Split(string(s.nodes[p].data), "\n") {
+				if re.MatchString(line) {
+					resp.Matches = append(resp.Matches, agfs.GrepMatch{File: p, Line: i + 1, Content: line})
+				}
+			}
+		}
+	}
+	s.mu.Unlock()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	resp.Count = len(resp.Matches)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// files returns the regular files below dir, sorted; s.mu is held
+func (s *Server) files(dir string, recursive bool) []string {
+	var files []string
+	for _, p := range s.children(dir) {
+		n := s.nodes[p]
+		switch {
+		case n.dir && recursive:
+			files = append(files, s.files(p, true)...)
+		case !n.dir && n.target == "":
+			files = append(files, p)
+		}
+	}
+	return files
+}
+
+func (s *Server) digest(w http.ResponseWriter, r *http.Request) {
+	var req agfs.DigestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errorf(http.StatusBadRequest, "invalid digest request"))
+		return
+	}
+	if req.Algorithm != "md5" {
+		writeError(w, errorf(http.StatusBadRequest, "unsupported algorithm: %s (agfstest supports md5)", req.Algorithm))
+		return
+	}
+	s.mu.Lock()
+	_, n, err := s.file(clean(req.Path))
+	var sum [md5.Size]byte
+	if err == nil {
+		sum = md5.Sum(n.data)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, agfs.DigestResponse{Algorithm: req.Algorithm, Path: req.Path, Digest: hex.EncodeToString(sum[:])})
+}
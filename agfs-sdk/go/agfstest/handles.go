@@ -0,0 +1,283 @@
+package agfstest
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	agfs "github.com/c4pt0r/agfs/agfs-sdk/go"
+)
+
+// accessMode masks the read/write mode of open flags
+const accessMode = 3
+
+// handleRequest serves the requests under /handles/, of which endpoint is
+// the rest of the path
+func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request, endpoint string) {
+	if endpoint == "open" {
+		if r.Method != http.MethodPost {
+			writeError(w, errorf(http.StatusMethodNotAllowed, "method not allowed"))
+			return
+		}
+		s.openHandle(w, r)
+		return
+	}
+
+	idStr, op, _ := strings.Cut(endpoint, "/")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		writeError(w, errorf(http.StatusBadRequest, "invalid handle ID: %s", idStr))
+		return
+	}
+	switch r.Method + " " + op {
+	case "DELETE ":
+		s.closeHandle(w, id)
+	case "GET ":
+		s.withHandle(w, id, func(h *handle) {
+			writeJSON(w, http.StatusOK, agfs.HandleInfo{ID: id, Path: h.path, Flags: h.flags})
+		})
+	case "GET read":
+		s.readHandle(w, r, id)
+	case "GET stream":
+		s.readHandle(w, r, id)
+	case "PUT write":
+		s.writeHandle(w, r, id)
+	case "POST seek":
+		s.seekHandle(w, r, id)
+	case "POST sync":
+		s.withHandle(w, id, func(*handle) { writeMessage(w, "synced") })
+	case "GET stat":
+		s.mu.Lock()
+		h, ok := s.handles[id]
+		var resp agfs.FileInfoResponse
+		var apiErr *apiError
+		if !ok {
+			apiErr = handleNotFound(id)
+		} else if n, found := s.nodes[h.path]; found {
+			resp = info(h.path, n)
+		} else {
+			apiErr = notFound(h.path)
+		}
+		s.mu.Unlock()
+		if apiErr != nil {
+			writeError(w, apiErr)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	default:
+		writeError(w, errorf(http.StatusNotFound, "no route for %s /handles/%s", r.Method, endpoint))
+	}
+}
+
+func handleNotFound(id int64) *apiError {
+	return errorf(http.StatusNotFound, "handle not found: %d", id)
+}
+
+// withHandle calls fn with the open handle id, or answers 404; fn is called
+// without s.mu held
+func (s *Server) withHandle(w http.ResponseWriter, id int64, fn func(*handle)) {
+	s.mu.Lock()
+	h, ok := s.handles[id]
+	var snapshot handle
+	if ok {
+		snapshot = *h
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, handleNotFound(id))
+		return
+	}
+	fn(&snapshot)
+}
+
+func (s *Server) openHandle(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	p := clean(q.Get("path"))
+	flagsValue, err := strconv.Atoi(q.Get("flags"))
+	if err != nil {
+		writeError(w, errorf(http.StatusBadRequest, "invalid flags: %s", q.Get("flags")))
+		return
+	}
+	flags := agfs.OpenFlag(flagsValue)
+	mode := uint64(0644)
+	if v := q.Get("mode"); v != "" {
+		if mode, err = strconv.ParseUint(v, 8, 32); err != nil {
+			writeError(w, errorf(http.StatusBadRequest, "invalid mode: %s", v))
+			return
+		}
+	}
+
+	s.mu.Lock()
+	target, n, apiErr := s.file(p)
+	created := false
+	switch {
+	case apiErr != nil && apiErr.code == http.StatusNotFound && flags&agfs.OpenFlagCreate != 0:
+		n, apiErr = s.createFile(target, uint32(mode))
+		created = apiErr == nil
+	case apiErr == nil && flags&agfs.OpenFlagCreate != 0 && flags&agfs.OpenFlagExclusive != 0:
+		apiErr = errorf(http.StatusConflict, "already exists: %s", p)
+	}
+	var id int64
+	if apiErr == nil {
+		if flags&agfs.OpenFlagTruncate != 0 && flags&accessMode != agfs.OpenFlagReadOnly && len(n.data) > 0 {
+			n.data = nil
+			n.modTime = time.Now()
+			n.version = s.nextVersion()
+			created = true
+		}
+		id = s.nextID
+		s.nextID++
+		s.handles[id] = &handle{path: target, flags: flags}
+	}
+	s.mu.Unlock()
+
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	if created {
+		s.notify(target)
+	}
+	writeJSON(w, http.StatusOK, agfs.HandleResponse{HandleID: id})
+}
+
+func (s *Server) closeHandle(w http.ResponseWriter, id int64) {
+	s.mu.Lock()
+	_, ok := s.handles[id]
+	delete(s.handles, id)
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, handleNotFound(id))
+		return
+	}
+	writeMessage(w, "closed")
+}
+
+func (s *Server) readHandle(w http.ResponseWriter, r *http.Request, id int64) {
+	q := r.URL.Query()
+	s.mu.Lock()
+	var data []byte
+	apiErr := func() *apiError {
+		h, ok := s.handles[id]
+		if !ok {
+			return handleNotFound(id)
+		}
+		if h.flags&accessMode == agfs.OpenFlagWriteOnly {
+			return errorf(http.StatusBadRequest, "handle %d is not open for reading", id)
+		}
+		n, ok := s.nodes[h.path]
+		if !ok {
+			return notFound(h.path)
+		}
+		offset := h.pos
+		if v := q.Get("offset"); v != "" {
+			offset, _ = strconv.ParseInt(v, 10, 64)
+		}
+		size := int64(-1)
+		if v := q.Get("size"); v != "" {
+			size, _ = strconv.ParseInt(v, 10, 64)
+		}
+		data = append([]byte(nil), section(n.data, offset, size)...)
+		h.pos = offset + int64(len(data))
+		return nil
+	}()
+	s.mu.Unlock()
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(data)
+}
+
+func (s *Server) writeHandle(w http.ResponseWriter, r *http.Request, id int64) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, errorf(http.StatusBadRequest, "failed to read request body: %v", err))
+		return
+	}
+	s.mu.Lock()
+	var changed string
+	apiErr := func() *apiError {
+		h, ok := s.handles[id]
+		if !ok {
+			return handleNotFound(id)
+		}
+		if h.flags&accessMode == agfs.OpenFlagReadOnly {
+			return errorf(http.StatusBadRequest, "handle %d is not open for writing", id)
+		}
+		n, ok := s.nodes[h.path]
+		if !ok {
+			return notFound(h.path)
+		}
+		offset := h.pos
+		if v := r.URL.Query().Get("offset"); v != "" {
+			offset, _ = strconv.ParseInt(v, 10, 64)
+		}
+		if h.flags&agfs.OpenFlagAppend != 0 {
+			offset = int64(len(n.data))
+		}
+		if end := offset + int64(len(data)); end > int64(len(n.data)) {
+			n.data = resize(n.data, end)
+		}
+		copy(n.data[offset:], data)
+		n.modTime = time.Now()
+		n.version = s.nextVersion()
+		h.pos = offset + int64(len(data))
+		changed = h.path
+		return nil
+	}()
+	s.mu.Unlock()
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	s.notify(changed)
+	writeJSON(w, http.StatusOK, map[string]int{"bytes_written": len(data)})
+}
+
+func (s *Server) seekHandle(w http.ResponseWriter, r *http.Request, id int64) {
+	q := r.URL.Query()
+	offset, err := strconv.ParseInt(q.Get("offset"), 10, 64)
+	if err != nil {
+		writeError(w, errorf(http.StatusBadRequest, "invalid offset: %s", q.Get("offset")))
+		return
+	}
+	whence, _ := strconv.Atoi(q.Get("whence"))
+
+	s.mu.Lock()
+	var pos int64
+	apiErr := func() *apiError {
+		h, ok := s.handles[id]
+		if !ok {
+			return handleNotFound(id)
+		}
+		switch whence {
+		case io.SeekStart:
+			pos = offset
+		case io.SeekCurrent:
+			pos = h.pos + offset
+		case io.SeekEnd:
+			n, ok := s.nodes[h.path]
+			if !ok {
+				return notFound(h.path)
+			}
+			pos = int64(len(n.data)) + offset
+		default:
+			return errorf(http.StatusBadRequest, "invalid whence: %d", whence)
+		}
+		if pos < 0 {
+			return errorf(http.StatusBadRequest, "negative position: %d", pos)
+		}
+		h.pos = pos
+		return nil
+	}()
+	s.mu.Unlock()
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int64{"offset": pos})
+}
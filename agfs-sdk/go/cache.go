@@ -0,0 +1,195 @@
+package agfs
+
+import (
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCacheEntries bounds the cached entries; beyond it expired ones are dropped
+const maxCacheEntries = 10000
+
+// EnableCache makes Stat and ReadDir answer from a client-side cache for up
+// to ttl after fetching from the server; ttl <= 0 disables the cache. Changes
+// made through the client invalidate the affected entries right away, changes
+// made by others are seen once the entries expire or InvalidateCache is called.
+func (c *Client) EnableCache(ttl time.Duration) {
+	if ttl <= 0 {
+		c.cache = nil
+		return
+	}
+	c.cache = newMetadataCache(ttl)
+}
+
+// InvalidateCache drops the cached metadata of path, everything below it and
+// the listing of its parent. It is the hook for sources of change
+// notifications, e.g. a watcher of the paths the client caches.
+func (c *Client) InvalidateCache(path string) {
+	c.cache.invalidate(path)
+}
+
+// ClearCache drops all cached metadata
+func (c *Client) ClearCache() {
+	c.cache.clear()
+}
+
+// metadataCache caches the results of Stat and ReadDir. All methods are
+// no-ops on a nil cache, which is the cache of clients without one.
+type metadataCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	stats   map[string]cachedStat
+	dirs    map[string]cachedDir
+	handles map[int64]string // open handles, by path, to invalidate on writes
+}
+
+type cachedStat struct {
+	info    FileInfo
+	expires time.Time
+}
+
+type cachedDir struct {
+	files   []FileInfo
+	expires time.Time
+}
+
+func newMetadataCache(ttl time.Duration) *metadataCache {
+	return &metadataCache{
+		ttl:     ttl,
+		stats:   make(map[string]cachedStat),
+		dirs:    make(map[string]cachedDir),
+		handles: make(map[int64]string),
+	}
+}
+
+// cacheKey normalizes a path the way the server does
+func cacheKey(p string) string {
+	return path.Clean("/" + p)
+}
+
+func (mc *metadataCache) stat(p string) (*FileInfo, bool) {
+	if mc == nil {
+		return nil, false
+	}
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	e, ok := mc.stats[cacheKey(p)]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false
+	}
+	info := e.info
+	return &info, true
+}
+
+func (mc *metadataCache) putStat(p string, info *FileInfo) {
+	if mc == nil {
+		return
+	}
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.prune()
+	mc.stats[cacheKey(p)] = cachedStat{info: *info, expires: time.Now().Add(mc.ttl)}
+}
+
+func (mc *metadataCache) readDir(p string) ([]FileInfo, bool) {
+	if mc == nil {
+		return nil, false
+	}
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	e, ok := mc.dirs[cacheKey(p)]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false
+	}
+	return append([]FileInfo(nil), e.files...), true
+}
+
+func (mc *metadataCache) putDir(p string, files []FileInfo) {
+	if mc == nil {
+		return
+	}
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.prune()
+	mc.dirs[cacheKey(p)] = cachedDir{files: append([]FileInfo(nil), files...), expires: time.Now().Add(mc.ttl)}
+}
+
+// prune drops the expired entries once the cache is full; mc.mu is held
+func (mc *metadataCache) prune() {
+	if len(mc.stats)+len(mc.dirs) < maxCacheEntries {
+		return
+	}
+	now := time.Now()
+	for k, e := range mc.stats {
+		if now.After(e.expires) {
+			delete(mc.stats, k)
+		}
+	}
+	for k, e := range mc.dirs {
+		if now.After(e.expires) {
+			delete(mc.dirs, k)
+		}
+	}
+}
+
+func (mc *metadataCache) invalidate(p string) {
+	if mc == nil {
+		return
+	}
+	key := cacheKey(p)
+	parent := path.Dir(key)
+	prefix := strings.TrimSuffix(key, "/") + "/"
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	for k := range mc.stats {
+		if k == key || k == parent || strings.HasPrefix(k, prefix) {
+			delete(mc.stats, k)
+		}
+	}
+	for k := range mc.dirs {
+		if k == key || k == parent || strings.HasPrefix(k, prefix) {
+			delete(mc.dirs, k)
+		}
+	}
+}
+
+func (mc *metadataCache) clear() {
+	if mc == nil {
+		return
+	}
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.stats = make(map[string]cachedStat)
+	mc.dirs = make(map[string]cachedDir)
+}
+
+// openHandle remembers the path of a handle, whose writes change it
+func (mc *metadataCache) openHandle(id int64, p string) {
+	if mc == nil {
+		return
+	}
+	mc.mu.Lock()
+	mc.handles[id] = p
+	mc.mu.Unlock()
+	mc.invalidate(p)
+}
+
+// invalidateHandle invalidates the path of a handle, forgetting the handle
+// once it is closed
+func (mc *metadataCache) invalidateHandle(id int64, closed bool) {
+	if mc == nil {
+		return
+	}
+	mc.mu.Lock()
+	p, ok := mc.handles[id]
+	if closed {
+		delete(mc.handles, id)
+	}
+	mc.mu.Unlock()
+	if ok {
+		mc.invalidate(p)
+	}
+}
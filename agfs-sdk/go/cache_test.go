@@ -0,0 +1,94 @@
+package agfs
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingServer answers stats and listings, counting the requests by path
+func countingServer(t *testing.T) (*httptest.Server, map[string]*atomic.Int32) {
+	counts := map[string]*atomic.Int32{"/api/v1/stat": {}, "/api/v1/directories": {}, "/api/v1/files": {}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n, ok := counts[r.URL.Path]; ok {
+			n.Add(1)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/stat":
+			json.NewEncoder(w).Encode(FileInfoResponse{Name: "a.txt", Size: 1})
+		case "/api/v1/directories":
+			json.NewEncoder(w).Encode(ListResponse{Files: []FileInfoResponse{{Name: "a.txt", Size: 1}}})
+		default:
+			json.NewEncoder(w).Encode(SuccessResponse{Message: "ok"})
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, counts
+}
+
+func TestClient_Cache(t *testing.T) {
+	server, counts := countingServer(t)
+	client := NewClient(server.URL)
+	client.EnableCache(time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.Stat("/dir/a.txt"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.ReadDir("/dir/"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if counts["/api/v1/stat"].Load() != 1 || counts["/api/v1/directories"].Load() != 1 {
+		t.Fatalf("stat requests = %d, listings = %d", counts["/api/v1/stat"].Load(), counts["/api/v1/directories"].Load())
+	}
+
+	// Cached results are copies
+	info, _ := client.Stat("/dir/a.txt")
+	info.Size = 100
+	if info, _ := client.Stat("/dir/a.txt"); info.Size != 1 {
+		t.Errorf("cached stat modified through a result: %+v", info)
+	}
+
+	// Writes through the client invalidate the file and its parent listing
+	if _, err := client.Write("/dir/a.txt", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	client.Stat("/dir/a.txt")
+	client.ReadDir("/dir")
+	if counts["/api/v1/stat"].Load() != 2 || counts["/api/v1/directories"].Load() != 2 {
+		t.Errorf("after write: stat requests = %d, listings = %d", counts["/api/v1/stat"].Load(), counts["/api/v1/directories"].Load())
+	}
+
+	// So does the invalidation hook, for everything below a path
+	client.InvalidateCache("/dir")
+	client.Stat("/dir/a.txt")
+	if counts["/api/v1/stat"].Load() != 3 {
+		t.Errorf("after InvalidateCache: stat requests = %d", counts["/api/v1/stat"].Load())
+	}
+}
+
+func TestClient_CacheExpiry(t *testing.T) {
+	server, counts := countingServer(t)
+	client := NewClient(server.URL)
+	client.EnableCache(10 * time.Millisecond)
+
+	client.Stat("/a.txt")
+	time.Sleep(20 * time.Millisecond)
+	client.Stat("/a.txt")
+	if counts["/api/v1/stat"].Load() != 2 {
+		t.Errorf("stat requests = %d", counts["/api/v1/stat"].Load())
+	}
+
+	// Without a cache every call reaches the server
+	client.EnableCache(0)
+	client.Stat("/a.txt")
+	client.Stat("/a.txt")
+	if counts["/api/v1/stat"].Load() != 4 {
+		t.Errorf("stat requests without cache = %d", counts["/api/v1/stat"].Load())
+	}
+}
@@ -2,11 +2,16 @@ package agfs
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -21,29 +26,139 @@ var (
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
+	cache      *metadataCache // nil unless EnableCache was called
+	name       string         // Sent as X-AGFS-Client, see SetClientName
 }
 
 // NewClient creates a new AGFS client
 // baseURL can be either full URL with "/api/v1" or just the base.
 // If "/api/v1" is not present, it will be automatically appended.
 // e.g., "http://localhost:8080" or "http://localhost:8080/api/v1"
+// A server listening on a unix socket is reached with "unix:///path/to/socket".
 func NewClient(baseURL string) *Client {
-	return &Client{
-		baseURL: normalizeBaseURL(baseURL),
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-	}
+	return NewClientWithHTTPClient(baseURL, &http.Client{
+		Timeout: 10 * time.Second,
+	})
 }
 
 // NewClientWithHTTPClient creates a new AGFS client with custom HTTP client
 func NewClientWithHTTPClient(baseURL string, httpClient *http.Client) *Client {
+	if socket, ok := strings.CutPrefix(baseURL, unixURLPrefix); ok {
+		httpClient = withTransport(httpClient, unixTransport(httpClient.Transport, socket))
+		baseURL = "http://agfs"
+	}
 	return &Client{
 		baseURL:    normalizeBaseURL(baseURL),
 		httpClient: httpClient,
 	}
 }
 
+// SetToken makes the client send token as a bearer token with every request,
+// for servers whose listener requires one
+func (c *Client) SetToken(token string) {
+	c.httpClient = withTransport(c.httpClient, &tokenTransport{base: c.httpClient.Transport, token: token})
+}
+
+// SetClientName names the client to the server, e.g. "agfs-fuse@host", so
+// that administrators can tell its handles apart and revoke them
+func (c *Client) SetClientName(name string) {
+	c.name = name
+}
+
+// Priority classes of requests (see WithPriority)
+type Priority string
+
+const (
+	PriorityInteractive Priority = "interactive" // Someone waits on the requests (the default)
+	PriorityBatch       Priority = "batch"       // Bulk work, e.g. imports, which yields to interactive requests
+)
+
+// WithPriority returns a client like c whose requests are of priority class
+// p, which servers use to run interactive requests first on busy mounts with
+// a max_concurrency. Bulk jobs use a batch client so that they do not hold
+// up the interactive requests of agents sharing the server.
+func (c *Client) WithPriority(p Priority) *Client {
+	clone := *c
+	clone.httpClient = withTransport(c.httpClient, &priorityTransport{base: c.httpClient.Transport, priority: p})
+	return &clone
+}
+
+// WrapTransport replaces the transport of the client with wrap(transport),
+// e.g. to instrument the requests it sends
+func (c *Client) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	base := c.httpClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	c.httpClient = withTransport(c.httpClient, wrap(base))
+}
+
+// unixURLPrefix starts base URLs that name a unix socket
+const unixURLPrefix = "unix://"
+
+// withTransport returns a copy of httpClient using transport, leaving the
+// caller's client untouched
+func withTransport(httpClient *http.Client, transport http.RoundTripper) *http.Client {
+	clone := *httpClient
+	clone.Transport = transport
+	return &clone
+}
+
+// unixTransport returns a transport like base that connects to a unix socket
+func unixTransport(base http.RoundTripper, socket string) http.RoundTripper {
+	t, ok := base.(*http.Transport)
+	if !ok || t == nil {
+		t = http.DefaultTransport.(*http.Transport)
+	}
+	t = t.Clone()
+	t.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", socket)
+	}
+	return t
+}
+
+// tokenTransport adds a bearer token to every request
+type tokenTransport struct {
+	base  http.RoundTripper // nil means http.DefaultTransport
+	token string
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	return base.RoundTrip(req)
+}
+
+// priorityTransport sets the priority class of every request, unless a
+// client derived from the client set it already
+type priorityTransport struct {
+	base     http.RoundTripper // nil means http.DefaultTransport
+	priority Priority
+}
+
+func (t *priorityTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if req.Header.Get("X-AGFS-Priority") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("X-AGFS-Priority", string(t.priority))
+	}
+	return base.RoundTrip(req)
+}
+
+// streamClient returns a client like the client's own but without timeout,
+// for streaming responses
+func (c *Client) streamClient() *http.Client {
+	return &http.Client{Transport: c.httpClient.Transport}
+}
+
 // normalizeBaseURL ensures the base URL ends with /api/v1
 func normalizeBaseURL(baseURL string) string {
 	// Remove trailing slash
@@ -85,6 +200,7 @@ type FileInfoResponse struct {
 	ModTime string   `json:"modTime"`
 	IsDir   bool     `json:"isDir"`
 	Meta    MetaData `json:"meta,omitempty"`
+	Version string   `json:"version,omitempty"`
 }
 
 // IsSymlink checks if the file info represents a symbolic link
@@ -92,6 +208,21 @@ func (f *FileInfoResponse) IsSymlink() bool {
 	return f.Meta.Type == "symlink"
 }
 
+// fileInfo converts the response to a FileInfo
+func (f *FileInfoResponse) fileInfo() FileInfo {
+	modTime, _ := time.Parse(time.RFC3339Nano, f.ModTime)
+	return FileInfo{
+		Name:      f.Name,
+		Size:      f.Size,
+		Mode:      f.Mode,
+		ModTime:   modTime,
+		IsDir:     f.IsDir,
+		IsSymlink: f.IsSymlink(),
+		Meta:      f.Meta,
+		Version:   f.Version,
+	}
+}
+
 // SymlinkRequest represents a symlink creation request
 type SymlinkRequest struct {
 	Target string `json:"target"`
@@ -112,12 +243,27 @@ type RenameRequest struct {
 	NewPath string `json:"newPath"`
 }
 
+// RenameResponse represents a rename response. Atomic is false when the
+// server renamed across mounts by copying and deleting.
+type RenameResponse struct {
+	Message     string `json:"message"`
+	Atomic      bool   `json:"atomic"`
+	FilesCopied int64  `json:"files_copied,omitempty"`
+	BytesCopied int64  `json:"bytes_copied,omitempty"`
+}
+
 // ChmodRequest represents a chmod request
 type ChmodRequest struct {
 	Mode uint32 `json:"mode"`
 }
 
 func (c *Client) doRequest(method, endpoint string, query url.Values, body io.Reader) (*http.Response, error) {
+	return c.doIdempotentRequest(method, endpoint, query, body, "")
+}
+
+// doIdempotentRequest is doRequest sending an Idempotency-Key, unless key is
+// empty: the server runs requests sent again with the same key only once
+func (c *Client) doIdempotentRequest(method, endpoint string, query url.Values, body io.Reader, key string) (*http.Response, error) {
 	u := c.baseURL + endpoint
 	if len(query) > 0 {
 		u += "?" + query.Encode()
@@ -131,6 +277,12 @@ func (c *Client) doRequest(method, endpoint string, query url.Values, body io.Re
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	if c.name != "" {
+		req.Header.Set("X-AGFS-Client", c.name)
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -140,7 +292,7 @@ func (c *Client) doRequest(method, endpoint string, query url.Values, body io.Re
 	return resp, nil
 }
 
-func (c *Client) handleErrorResponse(resp *http.Response) error {
+func (c *Client) handleErrorResponse(op, path string, resp *http.Response) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
@@ -151,29 +303,46 @@ func (c *Client) handleErrorResponse(resp *http.Response) error {
 		return ErrNotSupported
 	}
 
-	var errResp ErrorResponse
-	if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
-		return fmt.Errorf("HTTP %d: failed to decode error response", resp.StatusCode)
-	}
-
-	return fmt.Errorf("HTTP %d: %s", resp.StatusCode, errResp.Error)
+	return responseError(op, path, resp)
 }
 
 // Create creates a new file
 func (c *Client) Create(path string) error {
+	defer c.cache.invalidate(path)
+
+	query := url.Values{}
+	query.Set("path", path)
+
+	resp, err := c.doRequest(http.MethodPost, "/files", query, nil)
+	if err != nil {
+		return err
+	}
+
+	return c.handleErrorResponse("create", path, resp)
+}
+
+// CreateExclusive atomically creates an empty file, failing with
+// ErrAlreadyExists if it exists, as open(2) with O_CREAT|O_EXCL does. Servers
+// without the "create_exclusive" capability treat it as Create.
+func (c *Client) CreateExclusive(path string) error {
+	defer c.cache.invalidate(path)
+
 	query := url.Values{}
 	query.Set("path", path)
+	query.Set("exclusive", "true")
 
 	resp, err := c.doRequest(http.MethodPost, "/files", query, nil)
 	if err != nil {
 		return err
 	}
 
-	return c.handleErrorResponse(resp)
+	return c.handleErrorResponse("create", path, resp)
 }
 
 // Mkdir creates a new directory
 func (c *Client) Mkdir(path string, perm uint32) error {
+	defer c.cache.invalidate(path)
+
 	query := url.Values{}
 	query.Set("path", path)
 	query.Set("mode", fmt.Sprintf("%o", perm))
@@ -183,11 +352,13 @@ func (c *Client) Mkdir(path string, perm uint32) error {
 		return err
 	}
 
-	return c.handleErrorResponse(resp)
+	return c.handleErrorResponse("mkdir", path, resp)
 }
 
 // Remove removes a file or empty directory
 func (c *Client) Remove(path string) error {
+	defer c.cache.invalidate(path)
+
 	query := url.Values{}
 	query.Set("path", path)
 	query.Set("recursive", "false")
@@ -197,11 +368,13 @@ func (c *Client) Remove(path string) error {
 		return err
 	}
 
-	return c.handleErrorResponse(resp)
+	return c.handleErrorResponse("remove", path, resp)
 }
 
 // RemoveAll removes a path and any children it contains
 func (c *Client) RemoveAll(path string) error {
+	defer c.cache.invalidate(path)
+
 	query := url.Values{}
 	query.Set("path", path)
 	query.Set("recursive", "true")
@@ -211,7 +384,7 @@ func (c *Client) RemoveAll(path string) error {
 		return err
 	}
 
-	return c.handleErrorResponse(resp)
+	return c.handleErrorResponse("removeall", path, resp)
 }
 
 // Read reads file content with optional offset and size
@@ -235,11 +408,7 @@ func (c *Client) Read(path string, offset int64, size int64) ([]byte, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		var errResp ErrorResponse
-		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
-			return nil, fmt.Errorf("HTTP %d: failed to decode error response", resp.StatusCode)
-		}
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, errResp.Error)
+		return nil, responseError("read", path, resp)
 	}
 
 	data, err := io.ReadAll(resp.Body)
@@ -260,11 +429,28 @@ func (c *Client) Write(path string, data []byte) ([]byte, error) {
 func (c *Client) WriteWithRetry(path string, data []byte, maxRetries int) ([]byte, error) {
 	query := url.Values{}
 	query.Set("path", path)
+	return c.writeWithRetry(query, data, maxRetries)
+}
+
+// WriteSync writes data to a file like Write, returning only once the server
+// has made it durable
+func (c *Client) WriteSync(path string, data []byte) ([]byte, error) {
+	query := url.Values{}
+	query.Set("path", path)
+	query.Set("sync", "true")
+	return c.writeWithRetry(query, data, 3)
+}
+
+func (c *Client) writeWithRetry(query url.Values, data []byte, maxRetries int) ([]byte, error) {
+	defer c.cache.invalidate(query.Get("path"))
 
 	var lastErr error
 
+	// Retries send the key of the first attempt, so that a write that went
+	// through before a timeout is not applied twice
+	key := newIdempotencyKey()
 	for attempt := 0; attempt <= maxRetries; attempt++ {
-		resp, err := c.doRequest(http.MethodPut, "/files", query, bytes.NewReader(data))
+		resp, err := c.doIdempotentRequest(http.MethodPut, "/files", query, bytes.NewReader(data), key)
 		if err != nil {
 			lastErr = err
 
@@ -286,12 +472,7 @@ func (c *Client) WriteWithRetry(path string, data []byte, maxRetries int) ([]byt
 		defer resp.Body.Close()
 
 		if resp.StatusCode != http.StatusOK {
-			var errResp ErrorResponse
-			if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
-				return nil, fmt.Errorf("HTTP %d: failed to decode error response", resp.StatusCode)
-			}
-
-			lastErr = fmt.Errorf("HTTP %d: %s", resp.StatusCode, errResp.Error)
+			lastErr = responseError("write", query.Get("path"), resp)
 
 			// Retry on server errors (5xx)
 			if resp.StatusCode >= 500 && resp.StatusCode < 600 && attempt < maxRetries {
@@ -324,6 +505,15 @@ func (c *Client) WriteWithRetry(path string, data []byte, maxRetries int) ([]byt
 	return nil, lastErr
 }
 
+// newIdempotencyKey returns a random Idempotency-Key
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
 // isRetryableError checks if an error is retryable (network/timeout errors)
 func isRetryableError(err error) bool {
 	if err == nil {
@@ -350,6 +540,10 @@ func isRetryableError(err error) bool {
 
 // ReadDir lists the contents of a directory
 func (c *Client) ReadDir(path string) ([]FileInfo, error) {
+	if files, ok := c.cache.readDir(path); ok {
+		return files, nil
+	}
+
 	query := url.Values{}
 	query.Set("path", path)
 
@@ -360,11 +554,7 @@ func (c *Client) ReadDir(path string) ([]FileInfo, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		var errResp ErrorResponse
-		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
-			return nil, fmt.Errorf("HTTP %d: failed to decode error response", resp.StatusCode)
-		}
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, errResp.Error)
+		return nil, responseError("readdir", path, resp)
 	}
 
 	var listResp ListResponse
@@ -374,23 +564,19 @@ func (c *Client) ReadDir(path string) ([]FileInfo, error) {
 
 	files := make([]FileInfo, 0, len(listResp.Files))
 	for _, f := range listResp.Files {
-		modTime, _ := time.Parse(time.RFC3339Nano, f.ModTime)
-		files = append(files, FileInfo{
-			Name:      f.Name,
-			Size:      f.Size,
-			Mode:      f.Mode,
-			ModTime:   modTime,
-			IsDir:     f.IsDir,
-			IsSymlink: f.IsSymlink(),
-			Meta:      f.Meta,
-		})
+		files = append(files, f.fileInfo())
 	}
 
+	c.cache.putDir(path, files)
 	return files, nil
 }
 
 // Stat returns file information
 func (c *Client) Stat(path string) (*FileInfo, error) {
+	if info, ok := c.cache.stat(path); ok {
+		return info, nil
+	}
+
 	query := url.Values{}
 	query.Set("path", path)
 
@@ -401,52 +587,216 @@ func (c *Client) Stat(path string) (*FileInfo, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		var errResp ErrorResponse
-		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
-			return nil, fmt.Errorf("HTTP %d: failed to decode error response", resp.StatusCode)
+		return nil, responseError("stat", path, resp)
+	}
+
+	var fileInfo FileInfoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&fileInfo); err != nil {
+		return nil, fmt.Errorf("failed to decode file info response: %w", err)
+	}
+
+	info := fileInfo.fileInfo()
+	c.cache.putStat(path, &info)
+	return &info, nil
+}
+
+// maxBatchPaths is the most paths the server takes in one batch request;
+// ReadMany sends larger batches in several
+const maxBatchPaths = 1000
+
+// ReadMany reads many small files with one request instead of one each. The
+// results are in the order of paths, failed reads having an Err; files larger
+// than maxSize (1 MiB if 0) fail with ErrQuotaExceeded, to be read with Read.
+// It returns ErrNotSupported if the server predates batch requests.
+func (c *Client) ReadMany(paths []string, maxSize int64) ([]ReadResult, error) {
+	results := make([]ReadResult, 0, len(paths))
+	for start := 0; start < len(paths); start += maxBatchPaths {
+		end := start + maxBatchPaths
+		if end > len(paths) {
+			end = len(paths)
+		}
+		batch := paths[start:end]
+		req := struct {
+			Paths   []string `json:"paths"`
+			MaxSize int64    `json:"max_size,omitempty"`
+		}{Paths: batch, MaxSize: maxSize}
+		var resp struct {
+			Results []struct {
+				Status  int    `json:"status"`
+				Data    []byte `json:"data"`
+				Message string `json:"error"`
+			} `json:"results"`
+		}
+		if err := c.postBatch("readmany", "/files/batch", req, &resp); err != nil {
+			return nil, err
+		}
+		if len(resp.Results) != len(batch) {
+			return nil, fmt.Errorf("readmany: expected %d results, got %d", len(batch), len(resp.Results))
+		}
+		for j, r := range resp.Results {
+			result := ReadResult{Path: batch[j], Data: r.Data}
+			if r.Status != http.StatusOK {
+				result.Err = &PathError{Op: "read", Path: batch[j], Code: r.Status, Message: r.Message, Err: statusError(r.Status, r.Message)}
+			}
+			results = append(results, result)
 		}
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, errResp.Error)
 	}
+	return results, nil
+}
+
+// postBatch sends a batch request and decodes its response into out
+func (c *Client) postBatch(op, endpoint string, req interface{}, out interface{}) error {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s request: %w", op, err)
+	}
+	resp, err := c.doRequest(http.MethodPost, endpoint, nil, bytes.NewReader(jsonData))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Older servers do not route the batch endpoints
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+		return fmt.Errorf("%s: %w", op, ErrNotSupported)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return responseError(op, "", resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", op, err)
+	}
+	return nil
+}
+
+// ReadAsOf reads a file as it was at a past time, from mounts that keep the
+// history of their files (e.g. s3fs on a versioned bucket). Returns
+// ErrNotFound if the file did not exist then, and ErrNotSupported if the
+// mount or the server keeps no history.
+func (c *Client) ReadAsOf(path string, asOf time.Time, offset int64, size int64) ([]byte, error) {
+	query := url.Values{}
+	query.Set("path", path)
+	if offset > 0 {
+		query.Set("offset", strconv.FormatInt(offset, 10))
+	}
+	if size >= 0 {
+		query.Set("size", strconv.FormatInt(size, 10))
+	}
+	resp, err := c.getAsOf("read", "/files", path, asOf, query)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return data, nil
+}
+
+// StatAsOf returns the information of a file as it was at a past time (see
+// ReadAsOf)
+func (c *Client) StatAsOf(path string, asOf time.Time) (*FileInfo, error) {
+	query := url.Values{}
+	query.Set("path", path)
+	resp, err := c.getAsOf("stat", "/stat", path, asOf, query)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
 
 	var fileInfo FileInfoResponse
 	if err := json.NewDecoder(resp.Body).Decode(&fileInfo); err != nil {
 		return nil, fmt.Errorf("failed to decode file info response: %w", err)
 	}
+	info := fileInfo.fileInfo()
+	return &info, nil
+}
 
-	modTime, _ := time.Parse(time.RFC3339Nano, fileInfo.ModTime)
+// ReadDirAsOf lists a directory as it was at a past time (see ReadAsOf)
+func (c *Client) ReadDirAsOf(path string, asOf time.Time) ([]FileInfo, error) {
+	query := url.Values{}
+	query.Set("path", path)
+	resp, err := c.getAsOf("readdir", "/directories", path, asOf, query)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
 
-	return &FileInfo{
-		Name:      fileInfo.Name,
-		Size:      fileInfo.Size,
-		Mode:      fileInfo.Mode,
-		ModTime:   modTime,
-		IsDir:     fileInfo.IsDir,
-		IsSymlink: fileInfo.IsSymlink(),
-		Meta:      fileInfo.Meta,
-	}, nil
+	var listResp ListResponse
+	if err := json.NewDecoder(resp.Body).Decode(&listResp); err != nil {
+		return nil, fmt.Errorf("failed to decode list response: %w", err)
+	}
+	files := make([]FileInfo, 0, len(listResp.Files))
+	for _, f := range listResp.Files {
+		files = append(files, f.fileInfo())
+	}
+	return files, nil
+}
+
+// getAsOf sends a read as of a past time, returning the response if it
+// succeeded. Past states are not cached.
+func (c *Client) getAsOf(op, endpoint, path string, asOf time.Time, query url.Values) (*http.Response, error) {
+	query.Set("asOf", asOf.Format(time.RFC3339Nano))
+	resp, err := c.doRequest(http.MethodGet, endpoint, query, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, responseError(op, path, resp)
+	}
+	if resp.Header.Get("X-As-Of") == "" {
+		// Older servers ignore asOf and answer with the current state
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s as of %s: %w", op, path, asOf.Format(time.RFC3339), ErrNotSupported)
+	}
+	return resp, nil
 }
 
 // Rename renames/moves a file or directory
 func (c *Client) Rename(oldPath, newPath string) error {
+	_, err := c.Move(oldPath, newPath)
+	return err
+}
+
+// Move renames/moves a file or directory like Rename, and reports whether
+// the server did it atomically
+func (c *Client) Move(oldPath, newPath string) (*RenameResponse, error) {
+	defer c.cache.invalidate(newPath)
+	defer c.cache.invalidate(oldPath)
+
 	query := url.Values{}
 	query.Set("path", oldPath)
 
 	reqBody := RenameRequest{NewPath: newPath}
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
-		return fmt.Errorf("failed to marshal rename request: %w", err)
+		return nil, fmt.Errorf("failed to marshal rename request: %w", err)
 	}
 
 	resp, err := c.doRequest(http.MethodPost, "/rename", query, bytes.NewReader(jsonData))
 	if err != nil {
-		return err
+		return nil, err
 	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, c.handleErrorResponse("move", oldPath, resp)
+	}
+	defer resp.Body.Close()
 
-	return c.handleErrorResponse(resp)
+	// Older servers only rename within a mount, and do not report it
+	result := RenameResponse{Atomic: true}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode rename response: %w", err)
+	}
+	return &result, nil
 }
 
 // Chmod changes file permissions
 func (c *Client) Chmod(path string, mode uint32) error {
+	defer c.cache.invalidate(path)
+
 	query := url.Values{}
 	query.Set("path", path)
 
@@ -461,13 +811,15 @@ func (c *Client) Chmod(path string, mode uint32) error {
 		return err
 	}
 
-	return c.handleErrorResponse(resp)
+	return c.handleErrorResponse("chmod", path, resp)
 }
 
 // Truncate truncates a file to the specified size
 // For size=0, it clears the file content
 // For size>0, it either pads with zeros or truncates the content
 func (c *Client) Truncate(path string, size int64) error {
+	defer c.cache.invalidate(path)
+
 	query := url.Values{}
 	query.Set("path", path)
 	query.Set("size", fmt.Sprintf("%d", size))
@@ -477,7 +829,26 @@ func (c *Client) Truncate(path string, size int64) error {
 		return err
 	}
 
-	return c.handleErrorResponse(resp)
+	return c.handleErrorResponse("truncate", path, resp)
+}
+
+// Sync flushes a file to durable storage on the server. It returns
+// ErrNotSupported if the server predates the sync endpoint.
+func (c *Client) Sync(path string) error {
+	query := url.Values{}
+	query.Set("path", path)
+
+	resp, err := c.doRequest(http.MethodPost, "/sync", query, nil)
+	if err != nil {
+		return err
+	}
+	// Older servers answer unknown endpoints with a plain-text 404
+	if resp.StatusCode == http.StatusNotFound && !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		resp.Body.Close()
+		return ErrNotSupported
+	}
+
+	return c.handleErrorResponse("sync", path, resp)
 }
 
 // Health checks the health of the AGFS server
@@ -517,11 +888,7 @@ func (c *Client) GetCapabilities() (*CapabilitiesResponse, error) {
 				Features: []string{},
 			}, nil
 		}
-		var errResp ErrorResponse
-		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
-			return nil, fmt.Errorf("HTTP %d: failed to decode error response", resp.StatusCode)
-		}
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, errResp.Error)
+		return nil, responseError("getcapabilities", "", resp)
 	}
 
 	var caps CapabilitiesResponse
@@ -541,9 +908,7 @@ func (c *Client) ReadStream(path string) (io.ReadCloser, error) {
 	query.Set("stream", "true") // Enable streaming mode
 
 	// Create request with no timeout for streaming
-	streamClient := &http.Client{
-		Timeout: 0, // No timeout for streaming
-	}
+	streamClient := c.streamClient()
 
 	reqURL := fmt.Sprintf("%s/files?%s", c.baseURL, query.Encode())
 	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
@@ -558,11 +923,7 @@ func (c *Client) ReadStream(path string) (io.ReadCloser, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		defer resp.Body.Close()
-		var errResp ErrorResponse
-		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
-			return nil, fmt.Errorf("HTTP %d: failed to decode error response", resp.StatusCode)
-		}
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, errResp.Error)
+		return nil, responseError("readstream", path, resp)
 	}
 
 	// Return the response body as a ReadCloser
@@ -570,6 +931,110 @@ func (c *Client) ReadStream(path string) (io.ReadCloser, error) {
 	return resp.Body, nil
 }
 
+// Formats of ReadDecompressed
+const (
+	DecompressAuto = "auto" // Detected from the file; files not compressed are read as they are
+	DecompressGzip = "gzip"
+	DecompressZstd = "zstd"
+)
+
+// ReadDecompressed streams the contents of a gzip or zstd compressed file,
+// decompressed by the server, e.g. to read archived logs without
+// decompression tools. offset and size (-1 for all) select from the
+// decompressed contents. Returns ErrNotSupported if the server cannot
+// decompress, rather than the compressed contents it would send.
+// Caller must close the reader.
+func (c *Client) ReadDecompressed(path string, format string, offset int64, size int64) (io.ReadCloser, error) {
+	query := url.Values{}
+	query.Set("path", path)
+	query.Set("decompress", format)
+	if offset > 0 {
+		query.Set("offset", strconv.FormatInt(offset, 10))
+	}
+	if size >= 0 {
+		query.Set("size", strconv.FormatInt(size, 10))
+	}
+
+	reqURL := fmt.Sprintf("%s/files?%s", c.baseURL, query.Encode())
+	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Decompressed files may be large: no timeout, as for ReadStream
+	resp, err := c.streamClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, responseError("read", path, resp)
+	}
+	if resp.Header.Get("X-Decompressed") == "" {
+		resp.Body.Close()
+		return nil, fmt.Errorf("decompress %s: %w", path, ErrNotSupported)
+	}
+	return resp.Body, nil
+}
+
+// Tail returns the last lines of a file and the offset of its end, from
+// which Follow waits for the lines appended next. The server reads the end of
+// the file only, however large it is. Returns ErrNotSupported if the server
+// cannot tail files.
+func (c *Client) Tail(path string, lines int) ([]byte, int64, error) {
+	query := url.Values{}
+	query.Set("path", path)
+	query.Set("tail", strconv.Itoa(lines))
+	return c.readEnd(c.httpClient, path, query)
+}
+
+// Follow waits up to wait for data appended to a file after offset, as tail
+// -f does, and returns it with the offset to follow from next; the data is
+// empty if none was appended in time. A file truncated below offset is
+// returned from its start.
+func (c *Client) Follow(path string, offset int64, wait time.Duration) ([]byte, int64, error) {
+	query := url.Values{}
+	query.Set("path", path)
+	query.Set("follow", "true")
+	query.Set("offset", strconv.FormatInt(offset, 10))
+	query.Set("wait", strconv.Itoa(int(wait/time.Second)))
+
+	// The server holds the request for up to wait
+	client := &http.Client{Transport: c.httpClient.Transport}
+	if c.httpClient.Timeout > 0 {
+		client.Timeout = c.httpClient.Timeout + wait
+	}
+	return c.readEnd(client, path, query)
+}
+
+// readEnd sends a tail or follow read and returns its data and next offset
+func (c *Client) readEnd(client *http.Client, path string, query url.Values) ([]byte, int64, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/files?%s", c.baseURL, query.Encode()), nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, responseError("read", path, resp)
+	}
+	next, err := strconv.ParseInt(resp.Header.Get("X-Next-Offset"), 10, 64)
+	if err != nil {
+		// Older servers ignore tail and follow and send the whole file
+		return nil, 0, fmt.Errorf("tail %s: %w", path, ErrNotSupported)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return data, next, nil
+}
+
 // GrepRequest represents a grep search request
 type GrepRequest struct {
 	Path            string `json:"path"`
@@ -632,11 +1097,7 @@ func (c *Client) Grep(path, pattern string, recursive, caseInsensitive bool) (*G
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		var errResp ErrorResponse
-		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
-			return nil, fmt.Errorf("HTTP %d: failed to decode error response", resp.StatusCode)
-		}
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, errResp.Error)
+		return nil, responseError("grep", path, resp)
 	}
 
 	var grepResp GrepResponse
@@ -673,11 +1134,7 @@ func (c *Client) Digest(path, algorithm string) (*DigestResponse, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		var errResp ErrorResponse
-		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
-			return nil, fmt.Errorf("HTTP %d: failed to decode error response", resp.StatusCode)
-		}
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, errResp.Error)
+		return nil, responseError("digest", path, resp)
 	}
 
 	var digestResp DigestResponse
@@ -705,11 +1162,7 @@ func (c *Client) OpenHandle(path string, flags OpenFlag, mode uint32) (int64, er
 		if resp.StatusCode == http.StatusNotImplemented {
 			return 0, ErrNotSupported
 		}
-		var errResp ErrorResponse
-		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
-			return 0, fmt.Errorf("HTTP %d: failed to decode error response", resp.StatusCode)
-		}
-		return 0, fmt.Errorf("HTTP %d: %s", resp.StatusCode, errResp.Error)
+		return 0, responseError("openhandle", path, resp)
 	}
 
 	var handleResp HandleResponse
@@ -717,11 +1170,14 @@ func (c *Client) OpenHandle(path string, flags OpenFlag, mode uint32) (int64, er
 		return 0, fmt.Errorf("failed to decode handle response: %w", err)
 	}
 
+	c.cache.openHandle(handleResp.HandleID, path)
 	return handleResp.HandleID, nil
 }
 
 // CloseHandle closes a file handle
 func (c *Client) CloseHandle(handleID int64) error {
+	defer c.cache.invalidateHandle(handleID, true)
+
 	endpoint := fmt.Sprintf("/handles/%d", handleID)
 
 	resp, err := c.doRequest(http.MethodDelete, endpoint, nil, nil)
@@ -731,11 +1187,7 @@ func (c *Client) CloseHandle(handleID int64) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		var errResp ErrorResponse
-		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
-			return fmt.Errorf("HTTP %d: failed to decode error response", resp.StatusCode)
-		}
-		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, errResp.Error)
+		return responseError("closehandle", "", resp)
 	}
 
 	return nil
@@ -755,11 +1207,7 @@ func (c *Client) ReadHandle(handleID int64, offset int64, size int) ([]byte, err
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		var errResp ErrorResponse
-		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
-			return nil, fmt.Errorf("HTTP %d: failed to decode error response", resp.StatusCode)
-		}
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, errResp.Error)
+		return nil, responseError("readhandle", "", resp)
 	}
 
 	data, err := io.ReadAll(resp.Body)
@@ -777,9 +1225,7 @@ func (c *Client) ReadHandleStream(handleID int64) (io.ReadCloser, error) {
 	endpoint := fmt.Sprintf("/handles/%d/stream", handleID)
 
 	// Create request with no timeout for streaming
-	streamClient := &http.Client{
-		Timeout: 0, // No timeout for streaming
-	}
+	streamClient := c.streamClient()
 
 	reqURL := fmt.Sprintf("%s%s", c.baseURL, endpoint)
 	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
@@ -794,11 +1240,7 @@ func (c *Client) ReadHandleStream(handleID int64) (io.ReadCloser, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		defer resp.Body.Close()
-		var errResp ErrorResponse
-		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
-			return nil, fmt.Errorf("HTTP %d: failed to decode error response", resp.StatusCode)
-		}
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, errResp.Error)
+		return nil, responseError("readhandlestream", "", resp)
 	}
 
 	return resp.Body, nil
@@ -806,6 +1248,8 @@ func (c *Client) ReadHandleStream(handleID int64) (io.ReadCloser, error) {
 
 // WriteHandle writes data to a file handle
 func (c *Client) WriteHandle(handleID int64, data []byte, offset int64) (int, error) {
+	defer c.cache.invalidateHandle(handleID, false)
+
 	endpoint := fmt.Sprintf("/handles/%d/write", handleID)
 	query := url.Values{}
 	query.Set("offset", fmt.Sprintf("%d", offset))
@@ -824,11 +1268,7 @@ func (c *Client) WriteHandle(handleID int64, data []byte, offset int64) (int, er
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		var errResp ErrorResponse
-		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
-			return 0, fmt.Errorf("HTTP %d: failed to decode error response", resp.StatusCode)
-		}
-		return 0, fmt.Errorf("HTTP %d: %s", resp.StatusCode, errResp.Error)
+		return 0, responseError("writehandle", "", resp)
 	}
 
 	// Parse bytes written from response
@@ -843,6 +1283,116 @@ func (c *Client) WriteHandle(handleID int64, data []byte, offset int64) (int, er
 	return result.BytesWritten, nil
 }
 
+// formatRanges formats ranges as the server's "<offset>:<size>,..." parameter
+func formatRanges(n int, rangeAt func(i int) (int64, int64)) string {
+	parts := make([]string, n)
+	for i := range parts {
+		offset, size := rangeAt(i)
+		parts[i] = fmt.Sprintf("%d:%d", offset, size)
+	}
+	return strings.Join(parts, ",")
+}
+
+// unknownHandleOperation reports whether a response is that of a server
+// without the handle operation requested; the body can be read again after
+func unknownHandleOperation(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusNotFound {
+		return false
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	var errResp ErrorResponse
+	json.Unmarshal(body, &errResp)
+	return strings.HasPrefix(errResp.Error, "unknown operation")
+}
+
+// ReadHandleV reads several ranges of a file handle with one request, which
+// spares random readers such as databases a round trip per range. The data
+// of each range is returned in the order of ranges, short past the end of
+// the file. It returns ErrNotSupported if the server predates vectored reads.
+func (c *Client) ReadHandleV(handleID int64, ranges []ByteRange) ([][]byte, error) {
+	endpoint := fmt.Sprintf("/handles/%d/readv", handleID)
+	query := url.Values{}
+	query.Set("ranges", formatRanges(len(ranges), func(i int) (int64, int64) {
+		return ranges[i].Offset, ranges[i].Size
+	}))
+
+	resp, err := c.doRequest(http.MethodGet, endpoint, query, nil)
+	if err != nil {
+		return nil, fmt.Errorf("readv handle request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if unknownHandleOperation(resp) {
+			return nil, ErrNotSupported
+		}
+		return nil, responseError("readhandlev", "", resp)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	sizes := strings.Split(resp.Header.Get("X-Range-Sizes"), ",")
+	if len(sizes) != len(ranges) {
+		return nil, fmt.Errorf("invalid readv response: %d range sizes for %d ranges", len(sizes), len(ranges))
+	}
+	result := make([][]byte, len(ranges))
+	for i, s := range sizes {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 || n > len(data) {
+			return nil, fmt.Errorf("invalid readv response: range size %q", s)
+		}
+		result[i], data = data[:n:n], data[n:]
+	}
+	return result, nil
+}
+
+// WriteHandleV writes several ranges of a file handle with one request, in
+// order, and returns the total number of bytes written. It returns
+// ErrNotSupported if the server predates vectored writes.
+func (c *Client) WriteHandleV(handleID int64, vecs []IOVec) (int, error) {
+	defer c.cache.invalidateHandle(handleID, false)
+
+	endpoint := fmt.Sprintf("/handles/%d/writev", handleID)
+	query := url.Values{}
+	query.Set("ranges", formatRanges(len(vecs), func(i int) (int64, int64) {
+		return vecs[i].Offset, int64(len(vecs[i].Data))
+	}))
+	var body bytes.Buffer
+	for _, vec := range vecs {
+		body.Write(vec.Data)
+	}
+
+	req, err := http.NewRequest(http.MethodPut, c.baseURL+endpoint+"?"+query.Encode(), &body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("writev handle request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if unknownHandleOperation(resp) {
+			return 0, ErrNotSupported
+		}
+		return 0, responseError("writehandlev", "", resp)
+	}
+
+	var result struct {
+		BytesWritten int `json:"bytes_written"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return result.BytesWritten, nil
+}
+
 // SyncHandle syncs a file handle
 func (c *Client) SyncHandle(handleID int64) error {
 	endpoint := fmt.Sprintf("/handles/%d/sync", handleID)
@@ -854,11 +1404,7 @@ func (c *Client) SyncHandle(handleID int64) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		var errResp ErrorResponse
-		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
-			return fmt.Errorf("HTTP %d: failed to decode error response", resp.StatusCode)
-		}
-		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, errResp.Error)
+		return responseError("synchandle", "", resp)
 	}
 
 	return nil
@@ -878,11 +1424,7 @@ func (c *Client) SeekHandle(handleID int64, offset int64, whence int) (int64, er
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		var errResp ErrorResponse
-		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
-			return 0, fmt.Errorf("HTTP %d: failed to decode error response", resp.StatusCode)
-		}
-		return 0, fmt.Errorf("HTTP %d: %s", resp.StatusCode, errResp.Error)
+		return 0, responseError("seekhandle", "", resp)
 	}
 
 	var result struct {
@@ -906,11 +1448,7 @@ func (c *Client) GetHandle(handleID int64) (*HandleInfo, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		var errResp ErrorResponse
-		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
-			return nil, fmt.Errorf("HTTP %d: failed to decode error response", resp.StatusCode)
-		}
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, errResp.Error)
+		return nil, responseError("gethandle", "", resp)
 	}
 
 	var handleInfo HandleInfo
@@ -921,6 +1459,74 @@ func (c *Client) GetHandle(handleID int64) (*HandleInfo, error) {
 	return &handleInfo, nil
 }
 
+// RenewHandle renews the lease of a handle for lease seconds (0 for the
+// server's default lease) and returns when it expires. Using a handle renews
+// its lease too; handles that are neither used nor renewed are closed by the
+// server when their lease expires.
+func (c *Client) RenewHandle(handleID int64, lease int) (time.Time, error) {
+	endpoint := fmt.Sprintf("/handles/%d/renew", handleID)
+	query := url.Values{}
+	if lease > 0 {
+		query.Set("lease", fmt.Sprintf("%d", lease))
+	}
+
+	resp, err := c.doRequest(http.MethodPost, endpoint, query, nil)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("renew handle request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return time.Time{}, responseError("renewhandle", "", resp)
+	}
+
+	var result struct {
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return time.Time{}, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return result.ExpiresAt, nil
+}
+
+// RenewHandles renews the leases of several handles with one request, which
+// spares clients holding many handles a request per handle. The renewals are
+// in the order of handleIDs; handles that could not be renewed (e.g. because
+// they were closed) have an Error. It returns ErrNotSupported if the server
+// predates batch renewal, whose handles do not expire.
+func (c *Client) RenewHandles(handleIDs []int64, lease int) ([]HandleRenewal, error) {
+	reqBody := struct {
+		HandleIDs []int64 `json:"handle_ids"`
+		Lease     int     `json:"lease,omitempty"`
+	}{HandleIDs: handleIDs, Lease: lease}
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal renew request: %w", err)
+	}
+
+	resp, err := c.doRequest(http.MethodPost, "/handles/renew", nil, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("renew handles request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Older servers route the path to the handle "renew", which does not exist
+	if resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotSupported
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError("renewhandles", "", resp)
+	}
+
+	var result struct {
+		Renewals []HandleRenewal `json:"renewals"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return result.Renewals, nil
+}
+
 // StatHandle gets file info via a handle
 func (c *Client) StatHandle(handleID int64) (*FileInfo, error) {
 	endpoint := fmt.Sprintf("/handles/%d/stat", handleID)
@@ -932,11 +1538,7 @@ func (c *Client) StatHandle(handleID int64) (*FileInfo, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		var errResp ErrorResponse
-		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
-			return nil, fmt.Errorf("HTTP %d: failed to decode error response", resp.StatusCode)
-		}
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, errResp.Error)
+		return nil, responseError("stathandle", "", resp)
 	}
 
 	var fileInfo FileInfoResponse
@@ -954,11 +1556,14 @@ func (c *Client) StatHandle(handleID int64) (*FileInfo, error) {
 		IsDir:     fileInfo.IsDir,
 		IsSymlink: fileInfo.IsSymlink(),
 		Meta:      fileInfo.Meta,
+		Version:   fileInfo.Version,
 	}, nil
 }
 
 // Symlink creates a symbolic link at linkPath pointing to targetPath
 func (c *Client) Symlink(targetPath, linkPath string) error {
+	defer c.cache.invalidate(linkPath)
+
 	query := url.Values{}
 	query.Set("path", linkPath)
 
@@ -973,7 +1578,7 @@ func (c *Client) Symlink(targetPath, linkPath string) error {
 		return err
 	}
 
-	return c.handleErrorResponse(resp)
+	return c.handleErrorResponse("symlink", linkPath, resp)
 }
 
 // Readlink reads the target of a symbolic link
@@ -991,11 +1596,7 @@ func (c *Client) Readlink(linkPath string) (string, error) {
 		if resp.StatusCode == http.StatusNotImplemented {
 			return "", ErrNotSupported
 		}
-		var errResp ErrorResponse
-		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
-			return "", fmt.Errorf("HTTP %d: failed to decode error response", resp.StatusCode)
-		}
-		return "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, errResp.Error)
+		return "", responseError("readlink", linkPath, resp)
 	}
 
 	var readlinkResp ReadlinkResponse
@@ -1,11 +1,20 @@
 package agfs
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
 )
 
 func TestClient_Create(t *testing.T) {
@@ -31,6 +40,33 @@ func TestClient_Create(t *testing.T) {
 	}
 }
 
+func TestClient_CreateExclusive(t *testing.T) {
+	created := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("exclusive") != "true" {
+			t.Errorf("expected exclusive=true, got %q", r.URL.RawQuery)
+		}
+		path := r.URL.Query().Get("path")
+		if created[path] {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "file already exists: " + path})
+			return
+		}
+		created[path] = true
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(SuccessResponse{Message: "file created"})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	if err := client.CreateExclusive("/test/lock"); err != nil {
+		t.Fatalf("CreateExclusive failed: %v", err)
+	}
+	if err := client.CreateExclusive("/test/lock"); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists, got %v", err)
+	}
+}
+
 func TestClient_Read(t *testing.T) {
 	expectedData := []byte("hello world")
 
@@ -56,6 +92,164 @@ func TestClient_Read(t *testing.T) {
 	}
 }
 
+func TestClient_ReadDecompressed(t *testing.T) {
+	decompresses := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("decompress") != DecompressAuto || q.Get("offset") != "5" || q.Get("size") != "" {
+			t.Errorf("unexpected query %q", r.URL.RawQuery)
+		}
+		if decompresses {
+			w.Header().Set("X-Decompressed", "gzip")
+		}
+		w.Write([]byte("log line"))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	rc, err := client.ReadDecompressed("/logs/app.log.gz", DecompressAuto, 5, -1)
+	if err != nil {
+		t.Fatalf("ReadDecompressed failed: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "log line" {
+		t.Errorf("expected decompressed contents, got %q", data)
+	}
+
+	// Servers ignoring decompress would send the compressed contents
+	decompresses = false
+	if _, err := client.ReadDecompressed("/logs/app.log.gz", DecompressAuto, 5, -1); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestClient_TailAndFollow(t *testing.T) {
+	supported := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case q.Get("tail") == "2":
+			if supported {
+				w.Header().Set("X-Next-Offset", "12")
+			}
+			w.Write([]byte("b\nc\n"))
+		case q.Get("follow") == "true" && q.Get("offset") == "12" && q.Get("wait") == "3":
+			w.Header().Set("X-Next-Offset", "14")
+			w.Write([]byte("d\n"))
+		default:
+			t.Errorf("unexpected query %q", r.URL.RawQuery)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	data, next, err := client.Tail("/logs/app.log", 2)
+	if err != nil || string(data) != "b\nc\n" || next != 12 {
+		t.Fatalf("Tail returned %q, %d, %v", data, next, err)
+	}
+	data, next, err = client.Follow("/logs/app.log", next, 3*time.Second)
+	if err != nil || string(data) != "d\n" || next != 14 {
+		t.Fatalf("Follow returned %q, %d, %v", data, next, err)
+	}
+
+	// Servers ignoring tail would send the whole file
+	supported = false
+	if _, _, err := client.Tail("/logs/app.log", 2); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestClient_AsOf(t *testing.T) {
+	asOf := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	supported := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("asOf") != "2026-03-01T12:00:00Z" {
+			t.Errorf("unexpected query %q", r.URL.RawQuery)
+		}
+		if supported {
+			w.Header().Set("X-As-Of", r.URL.Query().Get("asOf"))
+		}
+		switch r.URL.Path {
+		case "/api/v1/files":
+			w.Write([]byte("old"))
+		case "/api/v1/stat":
+			json.NewEncoder(w).Encode(FileInfoResponse{Name: "a.txt", Size: 3})
+		case "/api/v1/directories":
+			json.NewEncoder(w).Encode(ListResponse{Files: []FileInfoResponse{{Name: "a.txt", Size: 3}}})
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	if data, err := client.ReadAsOf("/s3fs/a.txt", asOf, 0, -1); err != nil || string(data) != "old" {
+		t.Errorf("ReadAsOf returned %q, %v", data, err)
+	}
+	if info, err := client.StatAsOf("/s3fs/a.txt", asOf); err != nil || info.Size != 3 {
+		t.Errorf("StatAsOf returned %+v, %v", info, err)
+	}
+	if files, err := client.ReadDirAsOf("/s3fs", asOf); err != nil || len(files) != 1 || files[0].Name != "a.txt" {
+		t.Errorf("ReadDirAsOf returned %+v, %v", files, err)
+	}
+
+	// Servers ignoring asOf would return the current content
+	supported = false
+	if _, err := client.ReadAsOf("/s3fs/a.txt", asOf, 0, -1); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestClient_ReadMany(t *testing.T) {
+	supported := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !supported {
+			http.NotFound(w, r)
+			return
+		}
+		var req struct {
+			Paths   []string `json:"paths"`
+			MaxSize int64    `json:"max_size"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		if r.URL.Path != "/api/v1/files/batch" || strings.Join(req.Paths, ",") != "/a.txt,/big.bin,/missing" {
+			t.Errorf("unexpected request %s %v", r.URL.Path, req.Paths)
+		}
+		if req.MaxSize != 10 {
+			t.Errorf("unexpected max_size %d", req.MaxSize)
+		}
+		w.Write([]byte(`{"results": [{"path": "/a.txt", "status": 200, "data": "YWFh"},
+			{"path": "/big.bin", "status": 413, "data": null, "error": "quota exceeded"},
+			{"path": "/missing", "status": 404, "error": "not found"}], "failed": 2}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	reads, err := client.ReadMany([]string{"/a.txt", "/big.bin", "/missing"}, 10)
+	if err != nil || len(reads) != 3 {
+		t.Fatalf("ReadMany returned %+v, %v", reads, err)
+	}
+	if reads[0].Err != nil || string(reads[0].Data) != "aaa" {
+		t.Errorf("unexpected result %+v", reads[0])
+	}
+	// Files too large are told apart from missing ones
+	if !errors.Is(reads[1].Err, ErrQuotaExceeded) || errors.Is(reads[1].Err, ErrNotFound) {
+		t.Errorf("expected ErrQuotaExceeded, got %+v", reads[1])
+	}
+	if !errors.Is(reads[2].Err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %+v", reads[2])
+	}
+
+	supported = false
+	if _, err := client.ReadMany([]string{"/a.txt"}, 10); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+	if results, err := client.ReadMany(nil, 10); err != nil || len(results) != 0 {
+		t.Errorf("expected no request for no paths, got %v, %v", results, err)
+	}
+}
+
 func TestClient_Write(t *testing.T) {
 	testData := []byte("test content")
 
@@ -81,6 +275,28 @@ func TestClient_Write(t *testing.T) {
 	}
 }
 
+func TestClient_WriteRetryIdempotencyKey(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if len(keys) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "unavailable"})
+			return
+		}
+		json.NewEncoder(w).Encode(SuccessResponse{Message: "OK"})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	if _, err := client.WriteWithRetry("/queuefs/q/enqueue", []byte("job"), 1); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] {
+		t.Errorf("expected the retry to send the key of the first attempt, got %q", keys)
+	}
+}
+
 func TestClient_Mkdir(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -139,6 +355,49 @@ func TestClient_OpenHandleNotSupported(t *testing.T) {
 	}
 }
 
+func TestClient_RenewHandles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/handles/renew" || r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var req struct {
+			HandleIDs []int64 `json:"handle_ids"`
+			Lease     int     `json:"lease"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		var renewals []HandleRenewal
+		for _, id := range req.HandleIDs {
+			if id == 2 {
+				renewals = append(renewals, HandleRenewal{HandleID: id, Error: "not found"})
+				continue
+			}
+			renewals = append(renewals, HandleRenewal{HandleID: id, Lease: req.Lease, ExpiresAt: time.Now().Add(time.Duration(req.Lease) * time.Second)})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"renewals": renewals})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	renewals, err := client.RenewHandles([]int64{1, 2, 3}, 120)
+	if err != nil {
+		t.Fatalf("RenewHandles failed: %v", err)
+	}
+	if len(renewals) != 3 || renewals[0].Lease != 120 || renewals[1].Error == "" || renewals[2].HandleID != 3 {
+		t.Errorf("unexpected renewals %+v", renewals)
+	}
+
+	// Older servers route the batch path to a handle operation
+	old := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "method not allowed"})
+	}))
+	defer old.Close()
+	if _, err := NewClient(old.URL).RenewHandles([]int64{1}, 0); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported from an older server, got %v", err)
+	}
+}
+
 func TestClient_OpenHandleModeOctalFormat(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -262,3 +521,260 @@ func TestNormalizeBaseURL(t *testing.T) {
 		})
 	}
 }
+
+func TestClient_UnixSocketAndToken(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "agfs.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets not available: %v", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "missing or invalid token"})
+			return
+		}
+		w.Write([]byte("over the socket"))
+	}))
+	server.Listener = ln
+	server.Start()
+	defer server.Close()
+
+	client := NewClient("unix://" + socket)
+	if _, err := client.Read("/memfs/f", 0, -1); err == nil {
+		t.Fatal("expected an error without token")
+	}
+
+	client.SetToken("s3cret")
+	data, err := client.Read("/memfs/f", 0, -1)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(data) != "over the socket" {
+		t.Errorf("unexpected data: %s", data)
+	}
+
+	stream, err := client.ReadStream("/memfs/f")
+	if err != nil {
+		t.Fatalf("ReadStream failed: %v", err)
+	}
+	stream.Close()
+}
+
+func TestClient_WithPriority(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("X-AGFS-Priority"))
+		w.Write([]byte("data"))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	batch := client.WithPriority(PriorityBatch)
+	for _, c := range []*Client{client, batch, batch.WithPriority(PriorityInteractive)} {
+		if _, err := c.Read("/file", 0, -1); err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+	}
+	if want := []string{"", "batch", "interactive"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected priorities %v, got %v", want, got)
+	}
+}
+
+func TestClient_SetCompression(t *testing.T) {
+	content := []byte(strings.Repeat("compressible agent data\n", 100))
+	var written []byte
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v1/capabilities":
+			json.NewEncoder(w).Encode(CapabilitiesResponse{Version: "test", Features: []string{"compression:zstd"}})
+		case r.Method == http.MethodGet:
+			if r.Header.Get("Accept-Encoding") != EncodingZstd {
+				t.Errorf("expected Accept-Encoding zstd, got %q", r.Header.Get("Accept-Encoding"))
+			}
+			compressed, _ := compress(EncodingZstd, content)
+			w.Header().Set("Content-Encoding", EncodingZstd)
+			w.Write(compressed)
+		case r.Method == http.MethodPut:
+			if r.Header.Get("Content-Encoding") != EncodingZstd {
+				t.Errorf("expected Content-Encoding zstd, got %q", r.Header.Get("Content-Encoding"))
+			}
+			zr, err := zstd.NewReader(r.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			written, _ = io.ReadAll(zr)
+			zr.Close()
+			json.NewEncoder(w).Encode(SuccessResponse{Message: "ok"})
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	if err := client.SetCompression(EncodingLZ4); err != ErrNotSupported {
+		t.Errorf("expected ErrNotSupported for lz4, got %v", err)
+	}
+	if err := client.SetCompression(EncodingZstd); err != nil {
+		t.Fatalf("SetCompression failed: %v", err)
+	}
+
+	data, err := client.Read("/a.txt", 0, -1)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if !bytes.Equal(data, content) {
+		t.Errorf("expected decompressed content, got %d bytes", len(data))
+	}
+
+	if _, err := client.Write("/a.txt", content); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if !bytes.Equal(written, content) {
+		t.Errorf("expected server to receive content, got %d bytes", len(written))
+	}
+}
+
+func TestClient_Move(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/rename" {
+			t.Errorf("expected /api/v1/rename, got %s", r.URL.Path)
+		}
+		var req RenameRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.NewPath == "/s3fs/out.json" {
+			json.NewEncoder(w).Encode(RenameResponse{Message: "renamed", FilesCopied: 1, BytesCopied: 42})
+			return
+		}
+		// Older servers
+		json.NewEncoder(w).Encode(SuccessResponse{Message: "renamed"})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	result, err := client.Move("/memfs/tmp.json", "/s3fs/out.json")
+	if err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+	if result.Atomic || result.FilesCopied != 1 || result.BytesCopied != 42 {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	result, err = client.Move("/memfs/a", "/memfs/b")
+	if err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+	if !result.Atomic {
+		t.Error("expected renames of older servers reported atomic")
+	}
+}
+
+func TestClient_WriteIf(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-Match") != `"v1"` {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "write: /plan.md: version conflict (expected v0, found v1)"})
+			return
+		}
+		w.Header().Set("ETag", `"v2"`)
+		json.NewEncoder(w).Encode(SuccessResponse{Message: "Written 4 bytes"})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	version, err := client.WriteIf("/plan.md", []byte("plan"), "v1")
+	if err != nil {
+		t.Fatalf("WriteIf failed: %v", err)
+	}
+	if version != "v2" {
+		t.Errorf("expected new version v2, got %q", version)
+	}
+
+	if _, err := client.WriteIf("/plan.md", []byte("stale"), "v0"); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected ErrConflict, got %v", err)
+	}
+	if err := client.RemoveIf("/plan.md", "v0"); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected ErrConflict, got %v", err)
+	}
+}
+
+func TestClient_Sync(t *testing.T) {
+	var gotSync string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/sync":
+			if r.URL.Query().Get("path") != "/db/wal" {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(ErrorResponse{Error: "no such file"})
+				return
+			}
+			json.NewEncoder(w).Encode(SuccessResponse{Message: "synced"})
+		case "/api/v1/files":
+			gotSync = r.URL.Query().Get("sync")
+			json.NewEncoder(w).Encode(SuccessResponse{Message: "written"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	if err := client.Sync("/db/wal"); err != nil {
+		t.Errorf("Sync: %v", err)
+	}
+	if err := client.Sync("/db/missing"); err == nil || err == ErrNotSupported {
+		t.Errorf("Sync of a missing file: %v", err)
+	}
+	if _, err := client.WriteSync("/db/wal", []byte("x")); err != nil || gotSync != "true" {
+		t.Errorf("WriteSync: sync=%q, %v", gotSync, err)
+	}
+
+	// Servers without the endpoint answer with a plain-text 404
+	old := httptest.NewServer(http.NotFoundHandler())
+	defer old.Close()
+	if err := NewClient(old.URL).Sync("/db/wal"); err != ErrNotSupported {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestClient_HandleVectoredIO(t *testing.T) {
+	file := []byte("0123456789")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/handles/1/readv":
+			if r.URL.Query().Get("ranges") != "8:4,0:2" {
+				t.Errorf("unexpected ranges %q", r.URL.Query().Get("ranges"))
+			}
+			w.Header().Set("X-Range-Sizes", "2,2")
+			w.Write([]byte("8901"))
+		case "/api/v1/handles/1/writev":
+			body, _ := io.ReadAll(r.Body)
+			if r.URL.Query().Get("ranges") != "0:1,5:2" || string(body) != "abc" {
+				t.Errorf("unexpected writev %q: %q", r.URL.Query().Get("ranges"), body)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"bytes_written": 3, "written": []int{1, 2}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "unknown operation: readv"})
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	data, err := client.ReadHandleV(1, []ByteRange{{Offset: 8, Size: 4}, {Offset: 0, Size: 2}})
+	if err != nil {
+		t.Fatalf("ReadHandleV failed: %v", err)
+	}
+	if len(data) != 2 || string(data[0]) != string(file[8:]) || string(data[1]) != string(file[:2]) {
+		t.Errorf("ReadHandleV = %q", data)
+	}
+	if n, err := client.WriteHandleV(1, []IOVec{{Offset: 0, Data: []byte("a")}, {Offset: 5, Data: []byte("bc")}}); n != 3 || err != nil {
+		t.Errorf("WriteHandleV = %d, %v", n, err)
+	}
+
+	// Older servers do not know the operations
+	if _, err := client.ReadHandleV(2, []ByteRange{{Offset: 0, Size: 1}}); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported from an older server, got %v", err)
+	}
+}
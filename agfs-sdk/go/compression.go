@@ -0,0 +1,150 @@
+package agfs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+)
+
+// Encodings of file contents on the wire
+const (
+	EncodingZstd = "zstd"
+	EncodingLZ4  = "lz4"
+)
+
+// compressMinSize is the size below which written data is sent uncompressed
+const compressMinSize = 1024
+
+// SetCompression makes the client exchange file contents of reads and writes
+// compressed with encoding (EncodingZstd or EncodingLZ4). It checks that the
+// server supports the encoding first, and returns ErrNotSupported if not, as
+// an older server would store compressed writes as they are.
+func (c *Client) SetCompression(encoding string) error {
+	if encoding != EncodingZstd && encoding != EncodingLZ4 {
+		return fmt.Errorf("unsupported encoding: %s", encoding)
+	}
+	caps, err := c.GetCapabilities()
+	if err != nil {
+		return err
+	}
+	supported := false
+	for _, feature := range caps.Features {
+		if feature == "compression:"+encoding {
+			supported = true
+		}
+	}
+	if !supported {
+		return ErrNotSupported
+	}
+	c.httpClient = withTransport(c.httpClient, &compressionTransport{base: c.httpClient.Transport, encoding: encoding})
+	return nil
+}
+
+// compressionTransport asks for compressed responses, decodes them, and
+// compresses the bodies of file writes
+type compressionTransport struct {
+	base     http.RoundTripper // nil means http.DefaultTransport
+	encoding string
+}
+
+func (t *compressionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Accept-Encoding", t.encoding)
+
+	if isFileWrite(req) && req.ContentLength >= compressMinSize && req.Header.Get("Content-Encoding") == "" {
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		compressed, err := compress(t.encoding, data)
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(compressed))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(compressed)), nil
+		}
+		req.ContentLength = int64(len(compressed))
+		req.Header.Set("Content-Encoding", t.encoding)
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.Header.Get("Content-Encoding") {
+	case EncodingZstd:
+		zr, err := zstd.NewReader(resp.Body)
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		resp.Body = &decodedBody{Reader: zr.IOReadCloser(), body: resp.Body}
+	case EncodingLZ4:
+		resp.Body = &decodedBody{Reader: lz4.NewReader(resp.Body), body: resp.Body}
+	default:
+		return resp, nil
+	}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// isFileWrite checks if a request writes file contents, the only request
+// bodies the server decodes
+func isFileWrite(req *http.Request) bool {
+	if req.Method != http.MethodPut || req.Body == nil {
+		return false
+	}
+	return strings.HasSuffix(req.URL.Path, "/files") || strings.HasSuffix(req.URL.Path, "/write")
+}
+
+// compress encodes data with encoding
+func compress(encoding string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case EncodingZstd:
+		zw, err := zstd.NewWriter(&buf)
+		if err != nil {
+			return nil, err
+		}
+		w = zw
+	case EncodingLZ4:
+		w = lz4.NewWriter(&buf)
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %s", encoding)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodedBody reads a decoded response body and closes the original one
+type decodedBody struct {
+	io.Reader
+	body io.ReadCloser
+}
+
+func (d *decodedBody) Close() error {
+	if c, ok := d.Reader.(io.Closer); ok {
+		c.Close()
+	}
+	return d.body.Close()
+}
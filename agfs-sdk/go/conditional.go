@@ -0,0 +1,83 @@
+package agfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrConflict is returned by conditional operations when the file is not at
+// the expected version (HTTP 409)
+var ErrConflict = errors.New("version conflict")
+
+// WriteIf writes data to a file if it is at version (FileInfo.Version, or
+// "*" for any existing file), and returns its new version. It fails with
+// ErrConflict if another writer changed the file first, so that agents
+// editing the same file can re-read it and retry instead of overwriting each
+// other's changes.
+func (c *Client) WriteIf(path string, data []byte, version string) (string, error) {
+	defer c.cache.invalidate(path)
+
+	resp, err := c.conditionalRequest(http.MethodPut, path, bytes.NewReader(data), version)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if err := conditionalError("writeif", path, resp); err != nil {
+		return "", err
+	}
+	return strings.Trim(resp.Header.Get("ETag"), `"`), nil
+}
+
+// RemoveIf removes a file if it is at version, failing with ErrConflict
+// otherwise (see WriteIf)
+func (c *Client) RemoveIf(path string, version string) error {
+	defer c.cache.invalidate(path)
+
+	resp, err := c.conditionalRequest(http.MethodDelete, path, nil, version)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return conditionalError("removeif", path, resp)
+}
+
+// conditionalRequest sends a request on /files with an If-Match header
+func (c *Client) conditionalRequest(method, path string, body io.Reader, version string) (*http.Response, error) {
+	query := url.Values{}
+	query.Set("path", path)
+	req, err := http.NewRequest(method, c.baseURL+"/files?"+query.Encode(), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if version == "*" {
+		req.Header.Set("If-Match", "*")
+	} else {
+		req.Header.Set("If-Match", `"`+version+`"`)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	return resp, nil
+}
+
+// conditionalError returns the error of a conditional request's response
+func conditionalError(op, path string, resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	if resp.StatusCode == http.StatusNotImplemented {
+		return ErrNotSupported
+	}
+	err := responseError(op, path, resp)
+	if resp.StatusCode == http.StatusConflict {
+		// A conditional operation conflicts only on the version
+		err.(*PathError).Err = ErrConflict
+	}
+	return err
+}
@@ -0,0 +1,108 @@
+package agfs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Errors matching the error responses of the server, by status code; test
+// for them with errors.Is
+var (
+	// ErrNotFound is returned when the path does not exist (HTTP 404)
+	ErrNotFound = errors.New("not found")
+	// ErrPermissionDenied is returned when the operation is not allowed
+	// (HTTP 403)
+	ErrPermissionDenied = errors.New("permission denied")
+	// ErrUnauthorized is returned when the server requires a token and none
+	// or a wrong one was sent (HTTP 401)
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrInvalidArgument is returned for invalid paths or parameters (HTTP 400)
+	ErrInvalidArgument = errors.New("invalid argument")
+	// ErrAlreadyExists is returned when the path already exists (HTTP 409)
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrQuotaExceeded is returned when a request or file exceeds a size limit
+	// (HTTP 413)
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrUnavailable is returned when the mount serving the path is down
+	// (HTTP 503)
+	ErrUnavailable = errors.New("unavailable")
+	// ErrNoSpace is returned when the backend is out of space (HTTP 507)
+	ErrNoSpace = errors.New("no space left")
+	// ErrRevoked is returned when an administrator closed the handle, e.g.
+	// before maintenance of its backend; open the file again (HTTP 410)
+	ErrRevoked = errors.New("handle revoked")
+)
+
+// PathError is an error response of the server. It matches the sentinel
+// error of its status code with errors.Is, and can be inspected with
+// errors.As:
+//
+//	var pe *agfs.PathError
+//	if errors.As(err, &pe) && pe.Code == http.StatusNotFound { ... }
+type PathError struct {
+	Op      string // Client method, e.g. "stat" or "readhandle"
+	Path    string // Path the operation was on, empty for handle operations
+	Code    int    // HTTP status code
+	Message string // Error message of the server
+	Err     error  // Sentinel error of the status code, nil if there is none
+}
+
+// Error returns "HTTP <code>: <message>", as errors of the client always have
+func (e *PathError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.Code, e.Message)
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// statusError returns the sentinel error of a status code of the server
+func statusError(code int, message string) error {
+	switch code {
+	case http.StatusBadRequest:
+		return ErrInvalidArgument
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusForbidden:
+		return ErrPermissionDenied
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusConflict:
+		// Both existing paths and failed conditional operations are conflicts
+		if strings.Contains(message, ErrConflict.Error()) {
+			return ErrConflict
+		}
+		return ErrAlreadyExists
+	case http.StatusRequestEntityTooLarge:
+		return ErrQuotaExceeded
+	case http.StatusNotImplemented:
+		return ErrNotSupported
+	case http.StatusServiceUnavailable:
+		return ErrUnavailable
+	case http.StatusInsufficientStorage:
+		return ErrNoSpace
+	case http.StatusGone:
+		return ErrRevoked
+	}
+	return nil
+}
+
+// responseError returns the error of a failed response of the server, which
+// carries an ErrorResponse
+func responseError(op, path string, resp *http.Response) error {
+	message := "failed to decode error response"
+	var errResp ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&errResp); err == nil {
+		message = errResp.Error
+	}
+	return &PathError{
+		Op:      op,
+		Path:    path,
+		Code:    resp.StatusCode,
+		Message: message,
+		Err:     statusError(resp.StatusCode, message),
+	}
+}
@@ -0,0 +1,67 @@
+package agfs
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_StructuredErrors(t *testing.T) {
+	status := http.StatusNotFound
+	message := "file not found: /missing"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: message})
+	}))
+	defer server.Close()
+	client := NewClient(server.URL)
+
+	_, err := client.Stat("/missing")
+	if !errors.Is(err, ErrNotFound) || errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("stat: %v", err)
+	}
+	var pe *PathError
+	if !errors.As(err, &pe) || pe.Op != "stat" || pe.Path != "/missing" || pe.Code != 404 || pe.Message != message {
+		t.Errorf("PathError = %+v", pe)
+	}
+	if err.Error() != "HTTP 404: "+message {
+		t.Errorf("Error() = %q", err.Error())
+	}
+
+	for _, tc := range []struct {
+		status  int
+		message string
+		want    error
+	}{
+		{http.StatusBadRequest, "invalid path", ErrInvalidArgument},
+		{http.StatusUnauthorized, "missing or invalid token", ErrUnauthorized},
+		{http.StatusForbidden, "read-only mount", ErrPermissionDenied},
+		{http.StatusConflict, "already exists: /a", ErrAlreadyExists},
+		{http.StatusConflict, "version conflict on /a", ErrConflict},
+		{http.StatusRequestEntityTooLarge, "file too large", ErrQuotaExceeded},
+		{http.StatusServiceUnavailable, "mount /db is unavailable", ErrUnavailable},
+		{http.StatusInsufficientStorage, "no space left", ErrNoSpace},
+		{http.StatusGone, "handle 3: handle revoked: maintenance", ErrRevoked},
+	} {
+		status, message = tc.status, tc.message
+		if err := client.Mkdir("/a", 0755); !errors.Is(err, tc.want) {
+			t.Errorf("HTTP %d %q: %v, want %v", tc.status, tc.message, err, tc.want)
+		}
+	}
+
+	// Statuses without a sentinel still carry their code
+	status, message = http.StatusInternalServerError, "boom"
+	_, err = client.ReadDir("/")
+	if !errors.As(err, &pe) || pe.Code != 500 || pe.Err != nil || pe.Op != "readdir" {
+		t.Errorf("500: %+v", pe)
+	}
+
+	// Conditional operations conflict on versions only
+	status, message = http.StatusConflict, "precondition failed"
+	if _, err := client.WriteIf("/a", []byte("x"), "v1"); !errors.Is(err, ErrConflict) {
+		t.Errorf("WriteIf: %v", err)
+	}
+}
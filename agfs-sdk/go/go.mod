@@ -1,3 +1,8 @@
 module github.com/c4pt0r/agfs/agfs-sdk/go
 
 go 1.20
+
+require (
+	github.com/klauspost/compress v1.15.9
+	github.com/pierrec/lz4/v4 v4.1.15
+)
@@ -18,6 +18,7 @@ type FileInfo struct {
 	IsDir     bool
 	IsSymlink bool     // True if this is a symbolic link
 	Meta      MetaData // Structured metadata for additional information
+	Version   string   // Changes whenever the file changes; see WriteIf
 }
 
 // OpenFlag represents file open flags
@@ -41,6 +42,34 @@ type HandleInfo struct {
 	Flags OpenFlag `json:"flags"`
 }
 
+// HandleRenewal is the outcome of renewing the lease of one handle with
+// RenewHandles
+type HandleRenewal struct {
+	HandleID  int64     `json:"handle_id"`
+	Lease     int       `json:"lease,omitempty"`      // Seconds
+	ExpiresAt time.Time `json:"expires_at,omitempty"` // Zero if the server's handles do not expire
+	Error     string    `json:"error,omitempty"`      // Set if the handle could not be renewed (e.g. it was closed)
+}
+
+// ReadResult is the outcome of the read of one file with ReadMany
+type ReadResult struct {
+	Path string
+	Data []byte
+	Err  error // A *PathError, as Read would have returned; ErrQuotaExceeded for files too large
+}
+
+// ByteRange is a range of a file read by ReadHandleV
+type ByteRange struct {
+	Offset int64
+	Size   int64
+}
+
+// IOVec is a range of a file written by WriteHandleV: Data at Offset
+type IOVec struct {
+	Offset int64
+	Data   []byte
+}
+
 // HandleResponse is the response for handle operations
 type HandleResponse struct {
 	HandleID int64 `json:"handle_id"`
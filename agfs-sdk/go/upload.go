@@ -0,0 +1,212 @@
+package agfs
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// UploadOptions configures UploadFile; the zero value uses the defaults
+type UploadOptions struct {
+	// ChunkSize is the size of each write (default 8 MiB)
+	ChunkSize int64
+	// MaxRetries is the number of times a failed chunk is retried before the
+	// upload gives up (default 5); the upload can still be resumed later
+	MaxRetries int
+	// RetryDelay is the wait before the first retry, doubled for each further
+	// one (default 1s)
+	RetryDelay time.Duration
+	// Progress, if set, is called after each chunk with the bytes uploaded
+	Progress func(uploaded, total int64)
+}
+
+func (o *UploadOptions) withDefaults() UploadOptions {
+	var opts UploadOptions
+	if o != nil {
+		opts = *o
+	}
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = 8 << 20
+	}
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = 5
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = time.Second
+	}
+	return opts
+}
+
+// UploadFile uploads a local file to remotePath in chunks written through a
+// file handle, retrying failed chunks with backoff. The data goes to a
+// temporary file next to remotePath, which is renamed into place once
+// complete, so readers never see a partial file. If the upload is interrupted,
+// calling UploadFile again for the same unchanged local file resumes from
+// what the temporary file already holds; resumed uploads are checked against
+// an MD5 digest of the local file before the rename.
+func (c *Client) UploadFile(ctx context.Context, localPath, remotePath string, opts *UploadOptions) error {
+	o := opts.withDefaults()
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := st.Size()
+
+	tempPath, err := uploadTempPath(localPath, remotePath, st)
+	if err != nil {
+		return err
+	}
+
+	// Resume from the data already uploaded, unless it can't belong to this file
+	var offset int64
+	info, err := c.Stat(tempPath)
+	if err == nil && !info.IsDir && info.Size <= size {
+		offset = info.Size
+	} else {
+		if err == nil {
+			c.Remove(tempPath)
+		}
+		if err := c.Create(tempPath); err != nil {
+			return fmt.Errorf("failed to create %s: %w", tempPath, err)
+		}
+	}
+	resumed := offset > 0
+
+	// Handles are opened without create or truncate, so that a reopened
+	// handle keeps the data written so far
+	var handle int64 = -1
+	defer func() {
+		if handle >= 0 {
+			c.CloseHandle(handle)
+		}
+	}()
+
+	buf := make([]byte, o.ChunkSize)
+	retries := 0
+	for offset < size || handle < 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err := func() error {
+			if handle < 0 {
+				id, err := c.OpenHandle(tempPath, OpenFlagWriteOnly, 0644)
+				if err != nil {
+					return err
+				}
+				handle = id
+			}
+			if offset == size {
+				return nil
+			}
+			chunk := buf
+			if remaining := size - offset; remaining < int64(len(chunk)) {
+				chunk = chunk[:remaining]
+			}
+			n, err := f.ReadAt(chunk, offset)
+			if err != nil && err != io.EOF {
+				return err
+			}
+			written, err := c.WriteHandle(handle, buf[:n], offset)
+			if err != nil {
+				return err
+			}
+			offset += int64(written)
+			return nil
+		}()
+		if errors.Is(err, ErrNotSupported) {
+			return fmt.Errorf("upload to %s: %w", remotePath, err)
+		}
+		if err != nil {
+			if retries >= o.MaxRetries {
+				return fmt.Errorf("upload to %s failed at offset %d after %d retries: %w", remotePath, offset, retries, err)
+			}
+			// The handle may be gone with the connection: start over with a new one
+			if handle >= 0 {
+				c.CloseHandle(handle)
+				handle = -1
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(o.RetryDelay << retries):
+			}
+			retries++
+			continue
+		}
+		retries = 0
+		if o.Progress != nil {
+			o.Progress(offset, size)
+		}
+	}
+
+	if err := c.CloseHandle(handle); err != nil {
+		return fmt.Errorf("failed to close %s: %w", tempPath, err)
+	}
+	handle = -1
+
+	if err := c.verifyUpload(f, tempPath, size, resumed); err != nil {
+		return err
+	}
+	if err := c.Rename(tempPath, remotePath); err != nil {
+		return fmt.Errorf("failed to move %s into place: %w", tempPath, err)
+	}
+	return nil
+}
+
+// verifyUpload checks the size of the uploaded temporary file, and for
+// resumed uploads its digest
+func (c *Client) verifyUpload(f *os.File, tempPath string, size int64, resumed bool) error {
+	info, err := c.Stat(tempPath)
+	if err != nil {
+		return err
+	}
+	if info.Size != size {
+		return fmt.Errorf("uploaded %s has %d bytes, want %d", tempPath, info.Size, size)
+	}
+	if !resumed {
+		return nil
+	}
+
+	remote, err := c.Digest(tempPath, "md5")
+	if err != nil {
+		return fmt.Errorf("failed to verify %s: %w", tempPath, err)
+	}
+	h := md5.New()
+	if _, err := io.Copy(h, io.NewSectionReader(f, 0, size)); err != nil {
+		return err
+	}
+	if local := hex.EncodeToString(h.Sum(nil)); remote.Digest != local {
+		// Drop the bad data, so that the next attempt starts over
+		c.Remove(tempPath)
+		return fmt.Errorf("resumed upload to %s does not match the local file (md5 %s, want %s)", tempPath, remote.Digest, local)
+	}
+	return nil
+}
+
+// uploadTempPath returns the temporary file of an upload, a hidden file next
+// to remotePath named after the local file, its size and modification time,
+// so that only an upload of the same file resumes from it
+func uploadTempPath(localPath, remotePath string, st os.FileInfo) (string, error) {
+	abs, err := filepath.Abs(localPath)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%d", abs, st.Size(), st.ModTime().UnixNano())))
+	dir, name := path.Split(remotePath)
+	return path.Join(dir, fmt.Sprintf(".%s.upload-%s", name, hex.EncodeToString(sum[:8]))), nil
+}
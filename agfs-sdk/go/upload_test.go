@@ -0,0 +1,188 @@
+package agfs
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// uploadServer is an in-memory server with the endpoints UploadFile uses;
+// writes fail while failWrites is set
+type uploadServer struct {
+	mu         sync.Mutex
+	files      map[string][]byte
+	handles    map[int64]string
+	nextHandle int64
+	written    int
+	failWrites bool
+}
+
+func (s *uploadServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	fail := func(status int, msg string) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: msg})
+	}
+	path := r.URL.Query().Get("path")
+	route := strings.TrimPrefix(r.URL.Path, "/api/v1")
+
+	switch {
+	case route == "/stat":
+		data, ok := s.files[path]
+		if !ok {
+			fail(http.StatusNotFound, "no such file")
+			return
+		}
+		json.NewEncoder(w).Encode(FileInfoResponse{Name: filepath.Base(path), Size: int64(len(data))})
+	case route == "/files" && r.Method == http.MethodPost:
+		s.files[path] = nil
+		json.NewEncoder(w).Encode(SuccessResponse{Message: "created"})
+	case route == "/files" && r.Method == http.MethodDelete:
+		delete(s.files, path)
+		json.NewEncoder(w).Encode(SuccessResponse{Message: "deleted"})
+	case route == "/handles/open":
+		s.nextHandle++
+		s.handles[s.nextHandle] = path
+		json.NewEncoder(w).Encode(HandleResponse{HandleID: s.nextHandle})
+	case strings.HasSuffix(route, "/write"):
+		if s.failWrites {
+			fail(http.StatusServiceUnavailable, "backend unavailable")
+			return
+		}
+		id, _ := strconv.ParseInt(strings.Split(route, "/")[2], 10, 64)
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		data, _ := io.ReadAll(r.Body)
+		file := s.files[s.handles[id]]
+		for len(file) < offset+len(data) {
+			file = append(file, 0)
+		}
+		copy(file[offset:], data)
+		s.files[s.handles[id]] = file
+		s.written += len(data)
+		json.NewEncoder(w).Encode(map[string]int{"bytes_written": len(data)})
+	case strings.HasPrefix(route, "/handles/") && r.Method == http.MethodDelete:
+		json.NewEncoder(w).Encode(SuccessResponse{Message: "closed"})
+	case route == "/rename":
+		var req RenameRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		s.files[req.NewPath] = s.files[path]
+		delete(s.files, path)
+		json.NewEncoder(w).Encode(RenameResponse{Atomic: true})
+	case route == "/digest":
+		var req DigestRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		sum := md5.Sum(s.files[req.Path])
+		json.NewEncoder(w).Encode(DigestResponse{Algorithm: "md5", Path: req.Path, Digest: hex.EncodeToString(sum[:])})
+	default:
+		fail(http.StatusNotFound, "not found")
+	}
+}
+
+func TestClient_UploadFile(t *testing.T) {
+	srv := &uploadServer{files: map[string][]byte{}, handles: map[int64]string{}}
+	server := httptest.NewServer(srv)
+	defer server.Close()
+	client := NewClient(server.URL)
+
+	content := []byte(strings.Repeat("artifact-", 1000))
+	local := filepath.Join(t.TempDir(), "build.tar")
+	if err := os.WriteFile(local, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var progress int64
+	opts := &UploadOptions{ChunkSize: 1024, MaxRetries: 1, RetryDelay: time.Millisecond,
+		Progress: func(uploaded, total int64) { progress = uploaded }}
+	if err := client.UploadFile(context.Background(), local, "/ci/build.tar", opts); err != nil {
+		t.Fatal(err)
+	}
+	if string(srv.files["/ci/build.tar"]) != string(content) || progress != int64(len(content)) {
+		t.Fatalf("uploaded %d bytes, progress %d", len(srv.files["/ci/build.tar"]), progress)
+	}
+	if len(srv.files) != 1 {
+		t.Errorf("temporary files left: %d files", len(srv.files))
+	}
+}
+
+func TestClient_UploadFileResume(t *testing.T) {
+	srv := &uploadServer{files: map[string][]byte{}, handles: map[int64]string{}}
+	server := httptest.NewServer(srv)
+	defer server.Close()
+	client := NewClient(server.URL)
+
+	content := []byte(strings.Repeat("0123456789", 1000))
+	local := filepath.Join(t.TempDir(), "build.tar")
+	if err := os.WriteFile(local, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The server goes away after the first chunks: the upload gives up
+	// without touching the destination
+	opts := &UploadOptions{ChunkSize: 1000, MaxRetries: 2, RetryDelay: time.Millisecond,
+		Progress: func(uploaded, total int64) {
+			if uploaded >= 3000 {
+				srv.failWrites = true
+			}
+		}}
+	if err := client.UploadFile(context.Background(), local, "/ci/build.tar", opts); err == nil {
+		t.Fatal("upload succeeded while the server failed")
+	}
+	if _, ok := srv.files["/ci/build.tar"]; ok {
+		t.Fatal("partial upload visible at the destination")
+	}
+
+	// Uploading again resumes where the first attempt stopped
+	srv.failWrites = false
+	srv.written = 0
+	opts.Progress = nil
+	if err := client.UploadFile(context.Background(), local, "/ci/build.tar", opts); err != nil {
+		t.Fatal(err)
+	}
+	if string(srv.files["/ci/build.tar"]) != string(content) {
+		t.Fatal("resumed upload differs from the local file")
+	}
+	if srv.written != len(content)-3000 {
+		t.Errorf("resumed upload wrote %d bytes, want %d", srv.written, len(content)-3000)
+	}
+}
+
+func TestClient_UploadFileResumeMismatch(t *testing.T) {
+	srv := &uploadServer{files: map[string][]byte{}, handles: map[int64]string{}}
+	server := httptest.NewServer(srv)
+	defer server.Close()
+	client := NewClient(server.URL)
+
+	local := filepath.Join(t.TempDir(), "build.tar")
+	if err := os.WriteFile(local, []byte("the real content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	st, _ := os.Stat(local)
+	temp, _ := uploadTempPath(local, "/ci/build.tar", st)
+	srv.files[temp] = []byte("corrupt")
+
+	if err := client.UploadFile(context.Background(), local, "/ci/build.tar", nil); err == nil {
+		t.Fatal("resumed upload of corrupt data succeeded")
+	}
+	if _, ok := srv.files[temp]; ok {
+		t.Error("corrupt temporary file kept")
+	}
+	if err := client.UploadFile(context.Background(), local, "/ci/build.tar", nil); err != nil {
+		t.Fatal(err)
+	}
+	if string(srv.files["/ci/build.tar"]) != "the real content" {
+		t.Errorf("destination = %q", srv.files["/ci/build.tar"])
+	}
+}
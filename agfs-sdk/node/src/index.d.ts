@@ -0,0 +1,122 @@
+/** Class of an error, matching the error responses of the server by status code */
+export type ErrorCode =
+  | 'invalid_argument' // 400
+  | 'unauthorized' // 401
+  | 'permission_denied' // 403
+  | 'not_found' // 404
+  | 'already_exists' // 409
+  | 'conflict' // 409, failed conditional operations
+  | 'revoked' // 410
+  | 'quota_exceeded' // 413
+  | 'not_supported' // 501, or an operation the server predates
+  | 'unavailable' // 503
+  | 'no_space' // 507
+  | 'unknown';
+
+export declare class AGFSError extends Error {
+  /** Client method, e.g. "stat" */
+  readonly op: string;
+  /** Path the operation was on */
+  readonly path: string;
+  /** HTTP status of the response, 0 if there was none */
+  readonly status: number;
+  readonly code: ErrorCode;
+}
+
+/** Returns true if err is an AGFSError for a path that does not exist */
+export declare function isNotFound(err: unknown): boolean;
+
+export interface MetaData {
+  Name?: string;
+  Type?: string;
+  Content?: Record<string, string> | null;
+}
+
+/** Metadata of a file or directory */
+export interface FileInfo {
+  name: string;
+  size: number;
+  mode: number;
+  modTime: Date;
+  isDir: boolean;
+  isSymlink: boolean;
+  meta: MetaData;
+  /** Changes whenever the file changes, empty if the server does not say */
+  version: string;
+}
+
+export interface Capabilities {
+  version: string;
+  features: string[];
+}
+
+export interface RenameResult {
+  message?: string;
+  /** False when the server moved across mounts by copying and deleting */
+  atomic: boolean;
+  files_copied?: number;
+  bytes_copied?: number;
+}
+
+export interface TailResult {
+  data: Uint8Array;
+  /** Offset to follow the file from */
+  offset: number;
+}
+
+export interface WatchEvent {
+  type: 'created' | 'modified' | 'deleted';
+  path: string;
+  /** Info of the file, the last known for deleted files */
+  info: FileInfo;
+}
+
+export interface ClientOptions {
+  /** Bearer token, for servers whose listener requires one */
+  token?: string;
+  /** Name of the client shown to administrators, e.g. "indexer@host" */
+  clientName?: string;
+  /** Priority class of the requests (interactive by default) */
+  priority?: 'interactive' | 'batch';
+  /** Timeout of requests in ms (10000 by default) */
+  timeout?: number;
+  /** Delay before the first retry of writes in ms, doubled on each (1000 by default) */
+  retryDelay?: number;
+  /** fetch implementation (the global fetch by default) */
+  fetch?: typeof fetch;
+}
+
+export type WriteData = string | Uint8Array | ArrayBuffer | Blob;
+
+export declare class AGFSClient {
+  constructor(baseUrl?: string, options?: ClientOptions);
+
+  /** URL of the API, ending with "/api/v1" */
+  readonly baseUrl: string;
+
+  health(): Promise<Record<string, unknown>>;
+  capabilities(): Promise<Capabilities>;
+
+  stat(path: string): Promise<FileInfo>;
+  readDir(path: string): Promise<FileInfo[]>;
+  read(path: string, options?: { offset?: number; size?: number }): Promise<Uint8Array>;
+  readText(path: string, options?: { offset?: number; size?: number }): Promise<string>;
+  readStream(path: string): Promise<ReadableStream<Uint8Array>>;
+  write(path: string, data: WriteData, options?: { sync?: boolean }): Promise<string>;
+  writeStream(path: string, source: ReadableStream<Uint8Array> | AsyncIterable<Uint8Array | string>): Promise<string>;
+
+  create(path: string, options?: { exclusive?: boolean }): Promise<void>;
+  mkdir(path: string, mode?: number): Promise<void>;
+  remove(path: string, options?: { recursive?: boolean }): Promise<void>;
+  rename(oldPath: string, newPath: string): Promise<RenameResult>;
+  chmod(path: string, mode: number): Promise<void>;
+  truncate(path: string, size: number): Promise<void>;
+  symlink(target: string, linkPath: string): Promise<void>;
+  readlink(linkPath: string): Promise<string>;
+
+  tail(path: string, lines?: number): Promise<TailResult>;
+  follow(path: string, offset: number, options?: { wait?: number }): Promise<TailResult>;
+  watch(path: string, options?: { interval?: number; signal?: AbortSignal }): AsyncGenerator<WatchEvent, void, undefined>;
+}
+
+export default AGFSClient;
@@ -0,0 +1,418 @@
+// AGFS Node.js SDK: a client of the AGFS HTTP API for JavaScript agent
+// frameworks and web backends. It has no dependencies: requests are sent
+// with the global fetch of Node.js 20+.
+
+const DEFAULT_TIMEOUT = 10000;
+const WRITE_RETRIES = 3;
+
+// Error codes of the error responses of the server, by status code
+const STATUS_CODES = {
+  400: 'invalid_argument',
+  401: 'unauthorized',
+  403: 'permission_denied',
+  404: 'not_found',
+  409: 'already_exists',
+  410: 'revoked',
+  413: 'quota_exceeded',
+  501: 'not_supported',
+  503: 'unavailable',
+  507: 'no_space',
+};
+
+/**
+ * Error of the client. `code` classifies it as the other SDKs do, e.g.
+ * "not_found"; `status` is the HTTP status of the response, 0 if there was
+ * none.
+ */
+export class AGFSError extends Error {
+  constructor(message, { op = '', path = '', status = 0, code = 'unknown', cause } = {}) {
+    super(message, cause ? { cause } : undefined);
+    this.name = 'AGFSError';
+    this.op = op;
+    this.path = path;
+    this.status = status;
+    this.code = code;
+  }
+}
+
+/** Returns true if err is an AGFSError for a path that does not exist */
+export function isNotFound(err) {
+  return err instanceof AGFSError && err.code === 'not_found';
+}
+
+function notSupported(op, path, message) {
+  return new AGFSError(message, { op, path, code: 'not_supported' });
+}
+
+/** Returns the error of a failed response, which carries an error message */
+async function responseError(op, path, resp) {
+  let message = 'failed to decode error response';
+  try {
+    message = (await resp.json()).error ?? message;
+  } catch {
+    // Not JSON, e.g. from a proxy
+  }
+  let code = STATUS_CODES[resp.status] ?? 'unknown';
+  // Both existing paths and failed conditional operations are conflicts
+  if (resp.status === 409 && message.includes('version conflict')) {
+    code = 'conflict';
+  }
+  return new AGFSError(`HTTP ${resp.status}: ${message}`, { op, path, status: resp.status, code });
+}
+
+/** Converts file info of the API to a FileInfo */
+function fileInfo(f) {
+  return {
+    name: f.name,
+    size: f.size,
+    mode: f.mode,
+    modTime: new Date(f.modTime),
+    isDir: f.isDir,
+    isSymlink: f.meta?.Type === 'symlink',
+    meta: f.meta ?? {},
+    version: f.version ?? '',
+  };
+}
+
+/** Appends "/api/v1" to base URLs without it */
+function normalizeBaseUrl(baseUrl) {
+  baseUrl = baseUrl.replace(/\/+$/, '');
+  return baseUrl.endsWith('/api/v1') ? baseUrl : `${baseUrl}/api/v1`;
+}
+
+function sleep(ms, signal) {
+  return new Promise((resolve) => {
+    const timer = setTimeout(resolve, ms);
+    signal?.addEventListener('abort', () => {
+      clearTimeout(timer);
+      resolve();
+    }, { once: true });
+  });
+}
+
+/** Converts the sources of writeStream to a ReadableStream */
+function toReadableStream(source) {
+  if (source instanceof ReadableStream) {
+    return source;
+  }
+  const encoder = new TextEncoder();
+  const it = source[Symbol.asyncIterator]();
+  return new ReadableStream({
+    async pull(controller) {
+      const { value, done } = await it.next();
+      if (done) {
+        controller.close();
+      } else {
+        controller.enqueue(typeof value === 'string' ? encoder.encode(value) : value);
+      }
+    },
+    async cancel() {
+      await it.return?.();
+    },
+  });
+}
+
+/** Returns true if a and b differ, comparing versions if the server has them */
+function changed(a, b) {
+  if (a.version && b.version) {
+    return a.version !== b.version;
+  }
+  return a.size !== b.size || a.modTime.getTime() !== b.modTime.getTime() || a.mode !== b.mode;
+}
+
+export class AGFSClient {
+  #baseUrl;
+  #headers;
+  #timeout;
+  #retryDelay;
+  #fetch;
+
+  /**
+   * @param baseUrl URL of the server, with or without "/api/v1"
+   * @param options token, clientName, priority, timeout (ms), retryDelay
+   *   (ms before the first retry of writes, doubled on each), fetch
+   */
+  constructor(baseUrl = 'http://localhost:8080', options = {}) {
+    this.#baseUrl = normalizeBaseUrl(baseUrl);
+    this.#headers = {};
+    if (options.token) {
+      this.#headers.Authorization = `Bearer ${options.token}`;
+    }
+    if (options.clientName) {
+      this.#headers['X-AGFS-Client'] = options.clientName;
+    }
+    if (options.priority) {
+      this.#headers['X-AGFS-Priority'] = options.priority;
+    }
+    this.#timeout = options.timeout ?? DEFAULT_TIMEOUT;
+    this.#retryDelay = options.retryDelay ?? 1000;
+    this.#fetch = options.fetch ?? globalThis.fetch;
+  }
+
+  /** URL of the API, ending with "/api/v1" */
+  get baseUrl() {
+    return this.#baseUrl;
+  }
+
+  /**
+   * Sends a request, returning its response if it succeeded. timeout is in
+   * ms, 0 for none (streams).
+   */
+  async #request(op, path, method, endpoint, { query = {}, body, headers = {}, timeout = this.#timeout, check = true } = {}) {
+    const url = new URL(this.#baseUrl + endpoint);
+    for (const [k, v] of Object.entries(query)) {
+      if (v !== undefined) {
+        url.searchParams.set(k, String(v));
+      }
+    }
+    const init = { method, headers: { ...this.#headers, ...headers } };
+    if (body !== undefined) {
+      init.body = body;
+      if (body instanceof ReadableStream) {
+        init.duplex = 'half';
+      }
+    }
+    if (timeout > 0) {
+      init.signal = AbortSignal.timeout(timeout);
+    }
+
+    let resp;
+    try {
+      resp = await this.#fetch(url, init);
+    } catch (err) {
+      throw new AGFSError(`request failed: ${err.message}`, { op, path, cause: err });
+    }
+    if (check && !resp.ok) {
+      throw await responseError(op, path, resp);
+    }
+    return resp;
+  }
+
+  async #json(op, path, method, endpoint, options) {
+    const resp = await this.#request(op, path, method, endpoint, options);
+    return resp.json();
+  }
+
+  #jsonBody(value) {
+    return { body: JSON.stringify(value), headers: { 'Content-Type': 'application/json' } };
+  }
+
+  /** Checks the health of the server */
+  async health() {
+    return this.#json('health', '', 'GET', '/health');
+  }
+
+  /** Returns the version and features of the server */
+  async capabilities() {
+    const resp = await this.#request('capabilities', '', 'GET', '/capabilities', { check: false });
+    // Older servers do not have the endpoint
+    if (resp.status === 404) {
+      return { version: 'unknown', features: [] };
+    }
+    if (!resp.ok) {
+      throw await responseError('capabilities', '', resp);
+    }
+    return resp.json();
+  }
+
+  /** Returns the metadata of a file or directory */
+  async stat(path) {
+    return fileInfo(await this.#json('stat', path, 'GET', '/stat', { query: { path } }));
+  }
+
+  /** Lists the contents of a directory */
+  async readDir(path) {
+    const { files } = await this.#json('readdir', path, 'GET', '/directories', { query: { path } });
+    // Empty directories are listed as null
+    return (files ?? []).map(fileInfo);
+  }
+
+  /** Reads size bytes of a file from offset, the rest of the file by default */
+  async read(path, { offset = 0, size = -1 } = {}) {
+    const query = { path, offset: offset > 0 ? offset : undefined, size: size >= 0 ? size : undefined };
+    const resp = await this.#request('read', path, 'GET', '/files', { query });
+    return new Uint8Array(await resp.arrayBuffer());
+  }
+
+  /** Reads a file as UTF-8 text */
+  async readText(path, options) {
+    return new TextDecoder().decode(await this.read(path, options));
+  }
+
+  /**
+   * Streams the contents of a file, for files too large to hold in memory.
+   * The stream is not subject to the timeout of the client.
+   */
+  async readStream(path) {
+    const resp = await this.#request('readstream', path, 'GET', '/files', { query: { path, stream: 'true' }, timeout: 0 });
+    return resp.body;
+  }
+
+  /**
+   * Writes data (a string, bytes or Blob) to a file, creating it if
+   * necessary, and returns the message of the server. Writes failing on
+   * network or server errors are sent again, up to 3 times, with an
+   * Idempotency-Key so that the server applies them once. With sync, it
+   * returns only once the server has made the data durable.
+   */
+  async write(path, data, { sync = false } = {}) {
+    // Retries send the key of the first attempt, so that a write that went
+    // through before a timeout is not applied twice
+    const headers = { 'Idempotency-Key': globalThis.crypto.randomUUID() };
+    const query = { path, sync: sync ? 'true' : undefined };
+    for (let attempt = 0; ; attempt++) {
+      let resp;
+      try {
+        resp = await this.#request('write', path, 'PUT', '/files', { query, body: data, headers, check: false });
+      } catch (err) {
+        if (attempt >= WRITE_RETRIES) {
+          throw err;
+        }
+      }
+      if (resp && !(resp.status >= 500 && attempt < WRITE_RETRIES)) {
+        if (!resp.ok) {
+          throw await responseError('write', path, resp);
+        }
+        return (await resp.json()).message ?? '';
+      }
+      await sleep(this.#retryDelay * 2 ** attempt);
+    }
+  }
+
+  /**
+   * Writes the chunks of source (a ReadableStream or async iterable of
+   * strings or bytes) to a file, without holding them in memory. It is sent
+   * once, without retries, and is not subject to the timeout of the client.
+   */
+  async writeStream(path, source) {
+    const body = toReadableStream(source);
+    const resp = await this.#request('write', path, 'PUT', '/files', { query: { path }, body, timeout: 0 });
+    return (await resp.json()).message ?? '';
+  }
+
+  /** Creates an empty file; with exclusive, fails with "already_exists" if it exists */
+  async create(path, { exclusive = false } = {}) {
+    await this.#request('create', path, 'POST', '/files', { query: { path, exclusive: exclusive ? 'true' : undefined } });
+  }
+
+  /** Creates a directory */
+  async mkdir(path, mode = 0o755) {
+    await this.#request('mkdir', path, 'POST', '/directories', { query: { path, mode: mode.toString(8) } });
+  }
+
+  /** Removes a file or empty directory; with recursive, any children too */
+  async remove(path, { recursive = false } = {}) {
+    await this.#request('remove', path, 'DELETE', '/files', { query: { path, recursive: String(recursive) } });
+  }
+
+  /**
+   * Renames or moves a file or directory. `atomic` is false in the result
+   * when the server moved across mounts by copying and deleting.
+   */
+  async rename(oldPath, newPath) {
+    const result = await this.#json('rename', oldPath, 'POST', '/rename', { query: { path: oldPath }, ...this.#jsonBody({ newPath }) });
+    // Older servers only rename within a mount, and do not report it
+    return { atomic: true, ...result };
+  }
+
+  /** Changes the permissions of a file */
+  async chmod(path, mode) {
+    await this.#request('chmod', path, 'POST', '/chmod', { query: { path }, ...this.#jsonBody({ mode }) });
+  }
+
+  /** Truncates a file to size bytes */
+  async truncate(path, size) {
+    await this.#request('truncate', path, 'POST', '/truncate', { query: { path, size } });
+  }
+
+  /** Creates a symbolic link at linkPath pointing to target */
+  async symlink(target, linkPath) {
+    await this.#request('symlink', linkPath, 'POST', '/symlink', { query: { path: linkPath }, ...this.#jsonBody({ target }) });
+  }
+
+  /** Returns the target of a symbolic link */
+  async readlink(linkPath) {
+    return (await this.#json('readlink', linkPath, 'GET', '/readlink', { query: { path: linkPath } })).target;
+  }
+
+  /** Returns the last lines of a file, and the offset of its end to follow it from */
+  async tail(path, lines = 10) {
+    return this.#readEnd(path, { path, tail: lines }, this.#timeout);
+  }
+
+  /**
+   * Waits up to wait seconds for data appended to a file after offset (tail
+   * -f), and returns it with the offset to follow from next; the data is
+   * empty if none was appended in time.
+   */
+  async follow(path, offset, { wait = 30 } = {}) {
+    return this.#readEnd(path, { path, follow: 'true', offset, wait }, this.#timeout + wait * 1000);
+  }
+
+  async #readEnd(path, query, timeout) {
+    const resp = await this.#request('read', path, 'GET', '/files', { query, timeout });
+    const next = resp.headers.get('X-Next-Offset');
+    if (next === null) {
+      // Older servers ignore tail and follow and send the whole file
+      await resp.body?.cancel();
+      throw notSupported('read', path, `server cannot tail ${path}`);
+    }
+    return { data: new Uint8Array(await resp.arrayBuffer()), offset: Number(next) };
+  }
+
+  /**
+   * Watches a file, or the entries of a directory, for changes, yielding
+   * { type: "created" | "modified" | "deleted", path, info } events. The
+   * server has no change feed: the path is polled every interval ms, so
+   * changes undone within an interval are missed. It returns when signal
+   * is aborted.
+   */
+  async *watch(path, { interval = 1000, signal } = {}) {
+    let prev = await this.#snapshot(path);
+    while (!signal?.aborted) {
+      await sleep(interval, signal);
+      if (signal?.aborted) {
+        return;
+      }
+      const next = await this.#snapshot(path);
+      for (const [p, info] of next) {
+        const old = prev.get(p);
+        if (!old) {
+          yield { type: 'created', path: p, info };
+        } else if (changed(old, info)) {
+          yield { type: 'modified', path: p, info };
+        }
+      }
+      for (const [p, info] of prev) {
+        if (!next.has(p)) {
+          yield { type: 'deleted', path: p, info };
+        }
+      }
+      prev = next;
+    }
+  }
+
+  /** Returns the info of path by path: of its entries if it is a directory */
+  async #snapshot(path) {
+    const entries = new Map();
+    let info;
+    try {
+      info = await this.stat(path);
+      if (!info.isDir) {
+        entries.set(path, info);
+        return entries;
+      }
+      const dir = path.replace(/\/+$/, '');
+      for (const f of await this.readDir(path)) {
+        entries.set(`${dir}/${f.name}`, f);
+      }
+    } catch (err) {
+      if (!isNotFound(err)) {
+        throw err;
+      }
+    }
+    return entries;
+  }
+}
+
+export default AGFSClient;
@@ -0,0 +1,162 @@
+import assert from 'node:assert/strict';
+import { createServer } from 'node:http';
+import { after, before, test } from 'node:test';
+
+import { AGFSClient, AGFSError, isNotFound } from '../src/index.js';
+
+// A fake server keeping files in memory, with a few endpoints of the API
+const files = new Map();
+const requests = [];
+let failWrites = 0;
+
+function info(path) {
+  const data = files.get(path);
+  return { name: path.split('/').pop(), size: data.length, mode: 0o644, modTime: '2026-01-02T15:04:05Z', isDir: false, version: String(data.length) };
+}
+
+async function body(req) {
+  const chunks = [];
+  for await (const chunk of req) {
+    chunks.push(chunk);
+  }
+  return Buffer.concat(chunks);
+}
+
+const server = createServer(async (req, res) => {
+  const url = new URL(req.url, 'http://localhost');
+  const path = url.searchParams.get('path');
+  requests.push({ method: req.method, url: req.url, headers: req.headers });
+  const json = (status, value) => {
+    res.writeHead(status, { 'Content-Type': 'application/json' });
+    res.end(JSON.stringify(value));
+  };
+  const route = `${req.method} ${url.pathname}`;
+
+  if (path && path !== '/dir' && !files.has(path) && route !== 'PUT /api/v1/files') {
+    return json(404, { error: `no such file: ${path}` });
+  }
+  switch (route) {
+    case 'PUT /api/v1/files': {
+      const data = await body(req);
+      if (failWrites > 0) {
+        failWrites--;
+        return json(503, { error: 'mount unavailable' });
+      }
+      files.set(path, data);
+      return json(200, { message: `Written ${data.length} bytes` });
+    }
+    case 'GET /api/v1/files': {
+      const data = files.get(path);
+      if (url.searchParams.has('tail')) {
+        res.setHeader('X-Next-Offset', String(data.length));
+        return res.end(data.subarray(data.lastIndexOf('\n', data.length - 2) + 1));
+      }
+      const offset = Number(url.searchParams.get('offset') ?? 0);
+      const size = Number(url.searchParams.get('size') ?? data.length);
+      return res.end(data.subarray(offset, offset + size));
+    }
+    case 'GET /api/v1/stat':
+      if (path === '/dir') {
+        return json(200, { name: 'dir', size: 0, mode: 0o755, modTime: '2026-01-02T15:04:05Z', isDir: true });
+      }
+      return json(200, info(path));
+    case 'GET /api/v1/directories': {
+      const entries = [...files.keys()].filter((p) => p.startsWith(`${path}/`)).map(info);
+      return json(200, { files: entries.length ? entries : null });
+    }
+    case 'DELETE /api/v1/files':
+      files.delete(path);
+      return json(200, { message: 'deleted' });
+    case 'POST /api/v1/rename':
+      return json(200, { message: 'renamed' });
+  }
+  res.writeHead(404, { 'Content-Type': 'text/plain' });
+  res.end('404 page not found');
+});
+
+let client;
+
+before(async () => {
+  await new Promise((resolve) => server.listen(0, '127.0.0.1', resolve));
+  client = new AGFSClient(`http://127.0.0.1:${server.address().port}`, { token: 'secret', retryDelay: 1 });
+});
+
+after(() => server.close());
+
+test('files', async () => {
+  assert.equal(await client.write('/dir/a.txt', 'hello world'), 'Written 11 bytes');
+  assert.equal(await client.readText('/dir/a.txt'), 'hello world');
+  assert.deepEqual(await client.read('/dir/a.txt', { offset: 6, size: 3 }), new TextEncoder().encode('wor'));
+
+  const info = await client.stat('/dir/a.txt');
+  assert.equal(info.size, 11);
+  assert.ok(info.modTime instanceof Date && !info.isDir);
+  const entries = await client.readDir('/dir');
+  assert.deepEqual(entries.map((f) => f.name), ['a.txt']);
+  assert.equal((await client.rename('/dir/a.txt', '/dir/b.txt')).atomic, true);
+
+  await assert.rejects(client.stat('/missing'), (err) => {
+    assert.ok(err instanceof AGFSError && isNotFound(err));
+    assert.equal(err.message, 'HTTP 404: no such file: /missing');
+    return true;
+  });
+  await client.remove('/dir/a.txt');
+  assert.deepEqual(await client.readDir('/dir'), []);
+
+  const write = requests.find((r) => r.method === 'PUT');
+  assert.equal(write.headers.authorization, 'Bearer secret');
+  assert.ok(write.headers['idempotency-key']);
+});
+
+test('write retries with the same idempotency key', async () => {
+  failWrites = 2;
+  requests.length = 0;
+  await client.write('/retry.txt', new Uint8Array([1, 2, 3]));
+  const keys = requests.map((r) => r.headers['idempotency-key']);
+  assert.equal(keys.length, 3);
+  assert.equal(new Set(keys).size, 1);
+  assert.equal(files.get('/retry.txt').length, 3);
+});
+
+test('streams', async () => {
+  async function* chunks() {
+    yield 'line 1\n';
+    yield new TextEncoder().encode('line 2\n');
+  }
+  await client.writeStream('/log.txt', chunks());
+  assert.equal(files.get('/log.txt').toString(), 'line 1\nline 2\n');
+
+  let text = '';
+  for await (const chunk of await client.readStream('/log.txt')) {
+    text += new TextDecoder().decode(chunk);
+  }
+  assert.equal(text, 'line 1\nline 2\n');
+
+  const { data, offset } = await client.tail('/log.txt', 1);
+  assert.equal(new TextDecoder().decode(data), 'line 2\n');
+  assert.equal(offset, 14);
+});
+
+test('watch', async () => {
+  await client.write('/dir/w.txt', 'a');
+  const controller = new AbortController();
+  const events = [];
+  const watching = (async () => {
+    for await (const event of client.watch('/dir', { interval: 20, signal: controller.signal })) {
+      events.push(`${event.type} ${event.path}`);
+      if (events.length === 3) {
+        controller.abort();
+      }
+    }
+  })();
+
+  await new Promise((resolve) => setTimeout(resolve, 50));
+  await client.write('/dir/new.txt', 'n');
+  await client.write('/dir/w.txt', 'changed');
+  await new Promise((resolve) => setTimeout(resolve, 50));
+  await client.remove('/dir/new.txt');
+  await watching;
+
+  assert.deepEqual(events.slice(0, 2).sort(), ['created /dir/new.txt', 'modified /dir/w.txt']);
+  assert.equal(events[2], 'deleted /dir/new.txt');
+});
@@ -3,7 +3,7 @@
 __version__ = "0.1.6"
 
 from .client import AGFSClient, FileHandle
-from .exceptions import AGFSClientError, AGFSConnectionError, AGFSTimeoutError, AGFSHTTPError
+from .exceptions import AGFSClientError, AGFSConnectionError, AGFSTimeoutError, AGFSHTTPError, AGFSHandleRevokedError
 from .helpers import cp, upload, download
 
 __all__ = [
@@ -13,6 +13,7 @@ __all__ = [
     "AGFSConnectionError",
     "AGFSTimeoutError",
     "AGFSHTTPError",
+    "AGFSHandleRevokedError",
     "cp",
     "upload",
     "download",
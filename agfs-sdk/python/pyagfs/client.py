@@ -2,16 +2,18 @@
 
 import requests
 import time
-from typing import List, Dict, Any, Optional, Union, Iterator, BinaryIO
+import uuid
+from datetime import datetime
+from typing import List, Dict, Any, Optional, Tuple, Union, Iterator, BinaryIO
 from requests.exceptions import ConnectionError, Timeout, RequestException
 
-from .exceptions import AGFSClientError, AGFSNotSupportedError
+from .exceptions import AGFSClientError, AGFSNotSupportedError, AGFSHandleRevokedError
 
 
 class AGFSClient:
     """Client for interacting with AGFS (Plugin-based File System) Server API"""
 
-    def __init__(self, api_base_url="http://localhost:8080", timeout=10):
+    def __init__(self, api_base_url="http://localhost:8080", timeout=10, token=None, priority=None):
         """
         Initialize AGFS client.
 
@@ -20,6 +22,10 @@ class AGFSClient:
                          If "/api/v1" is not present, it will be automatically appended.
                          e.g., "http://localhost:8080" or "http://localhost:8080/api/v1"
             timeout: Request timeout in seconds (default: 10)
+            token: Bearer token, for servers whose listener requires one
+            priority: Priority class of the requests, "interactive" (the default) or
+                      "batch" for bulk work, which yields to interactive requests on
+                      busy mounts with a max_concurrency
         """
         api_base_url = api_base_url.rstrip("/")
         # Auto-append /api/v1 if not present
@@ -27,6 +33,10 @@ class AGFSClient:
             api_base_url = api_base_url + "/api/v1"
         self.api_base = api_base_url
         self.session = requests.Session()
+        if token:
+            self.session.headers["Authorization"] = f"Bearer {token}"
+        if priority:
+            self.session.headers["X-AGFS-Priority"] = priority
         self.timeout = timeout
 
     def _handle_request_error(self, e: Exception, operation: str = "request") -> None:
@@ -55,6 +65,14 @@ class AGFSClient:
                         error_msg = "Operation not supported"
                     raise AGFSNotSupportedError(error_msg)
 
+                # 410 Gone: an administrator revoked the handle
+                if status_code == 410:
+                    try:
+                        error_msg = e.response.json().get("error", "Handle revoked")
+                    except (ValueError, KeyError, TypeError):
+                        error_msg = "Handle revoked"
+                    raise AGFSHandleRevokedError(error_msg)
+
                 # Try to get error message from JSON response first (priority)
                 try:
                     error_data = e.response.json()
@@ -117,8 +135,16 @@ class AGFSClient:
                 self._handle_request_error(e)
             return {"version": "unknown", "features": []}
 
-    def ls(self, path: str = "/") -> List[Dict[str, Any]]:
-        """List directory contents"""
+    def ls(self, path: str = "/", as_of: Union[datetime, str, None] = None) -> List[Dict[str, Any]]:
+        """List directory contents
+
+        Args:
+            path: Directory path
+            as_of: List the directory as it was at this time (see cat)
+        """
+        if as_of is not None:
+            files = self._get_as_of("/directories", path, {"path": path}, as_of).json().get("files")
+            return files if files is not None else []
         try:
             response = self.session.get(
                 f"{self.api_base}/directories",
@@ -132,10 +158,12 @@ class AGFSClient:
         except Exception as e:
             self._handle_request_error(e)
 
-    def read(self, path: str, offset: int = 0, size: int = -1, stream: bool = False):
-        return self.cat(path, offset, size, stream)
+    def read(self, path: str, offset: int = 0, size: int = -1, stream: bool = False,
+             decompress: Optional[str] = None, as_of: Union[datetime, str, None] = None):
+        return self.cat(path, offset, size, stream, decompress, as_of)
 
-    def cat(self, path: str, offset: int = 0, size: int = -1, stream: bool = False):
+    def cat(self, path: str, offset: int = 0, size: int = -1, stream: bool = False,
+            decompress: Optional[str] = None, as_of: Union[datetime, str, None] = None):
         """Read file content with optional offset and size
 
         Args:
@@ -143,11 +171,28 @@ class AGFSClient:
             offset: Starting position (default: 0)
             size: Number of bytes to read (default: -1, read all)
             stream: Enable streaming mode for continuous reads (default: False)
+            decompress: Have the server decompress a gzip or zstd file:
+                "auto" (detected from the file), "gzip" or "zstd". offset and
+                size then apply to the decompressed content (default: None)
+            as_of: Read the file as it was at this time, a datetime (local
+                time if naive) or an RFC 3339 string, from mounts that keep
+                history, e.g. s3fs on a versioned bucket; stream and
+                decompress are ignored. Raises AGFSNotSupportedError if the
+                mount or the server keeps no history (default: None)
 
         Returns:
             If stream=False: bytes content
             If stream=True: Response object for iteration
         """
+        if as_of is not None:
+            params = {"path": path}
+            if offset > 0:
+                params["offset"] = str(offset)
+            if size >= 0:
+                params["size"] = str(size)
+            return self._get_as_of("/files", path, params, as_of).content
+        if decompress:
+            return self._read_decompressed(path, offset, size, stream, decompress)
         try:
             params = {"path": path}
 
@@ -179,6 +224,76 @@ class AGFSClient:
         except Exception as e:
             self._handle_request_error(e)
 
+    def _read_decompressed(self, path: str, offset: int, size: int, stream: bool, decompress: str):
+        """Read the content of a compressed file, decompressed by the server"""
+        params = {"path": path, "decompress": decompress}
+        if offset > 0:
+            params["offset"] = str(offset)
+        if size >= 0:
+            params["size"] = str(size)
+        try:
+            response = self.session.get(
+                f"{self.api_base}/files",
+                params=params,
+                stream=stream,
+                timeout=None if stream else self.timeout
+            )
+            response.raise_for_status()
+        except Exception as e:
+            self._handle_request_error(e)
+        if "X-Decompressed" not in response.headers:
+            # Older servers ignore decompress and send the compressed content
+            response.close()
+            raise AGFSNotSupportedError(f"Server cannot decompress {path}")
+        return response if stream else response.content
+
+    def tail(self, path: str, lines: int = 10) -> Tuple[bytes, int]:
+        """Read the last lines of a file without reading it whole
+
+        Returns:
+            The lines and the offset of the end of the file, to follow it from
+        """
+        return self._read_end(path, {"path": path, "tail": str(lines)}, self.timeout)
+
+    def follow(self, path: str, offset: int, wait: int = 30) -> Tuple[bytes, int]:
+        """Wait up to wait seconds for data appended to a file after offset (tail -f)
+
+        Returns:
+            The appended data (empty if none was appended in time) and the
+            offset to follow from next. A file truncated below offset is
+            returned from its start.
+        """
+        params = {"path": path, "follow": "true", "offset": str(offset), "wait": str(wait)}
+        return self._read_end(path, params, self.timeout + wait)
+
+    def _read_end(self, path: str, params: Dict[str, str], timeout) -> Tuple[bytes, int]:
+        """Send a tail or follow read, returning its data and next offset"""
+        try:
+            response = self.session.get(f"{self.api_base}/files", params=params, timeout=timeout)
+            response.raise_for_status()
+        except Exception as e:
+            self._handle_request_error(e)
+        if "X-Next-Offset" not in response.headers:
+            # Older servers ignore tail and follow and send the whole file
+            raise AGFSNotSupportedError(f"Server cannot tail {path}")
+        return response.content, int(response.headers["X-Next-Offset"])
+
+    def _get_as_of(self, endpoint: str, path: str, params: Dict[str, str],
+                   as_of: Union[datetime, str]) -> requests.Response:
+        """Send a read as of a past time, returning its response"""
+        if isinstance(as_of, datetime):
+            as_of = as_of.astimezone().isoformat()
+        params["asOf"] = as_of
+        try:
+            response = self.session.get(f"{self.api_base}{endpoint}", params=params, timeout=self.timeout)
+            response.raise_for_status()
+        except Exception as e:
+            self._handle_request_error(e)
+        if "X-As-Of" not in response.headers:
+            # Older servers ignore asOf and answer with the current state
+            raise AGFSNotSupportedError(f"Server cannot read {path} as of {as_of}")
+        return response
+
     def write(self, path: str, data: Union[bytes, Iterator[bytes], BinaryIO], max_retries: int = 3) -> str:
         """Write data to file and return the response message
 
@@ -201,12 +316,17 @@ class AGFSClient:
 
         last_error = None
 
+        # Retries send the key of the first attempt, so that a write that went
+        # through before a timeout is not applied twice
+        headers = {"Idempotency-Key": uuid.uuid4().hex}
+
         for attempt in range(max_retries + 1):
             try:
                 response = self.session.put(
                     f"{self.api_base}/files",
                     params={"path": path},
                     data=data,  # requests supports bytes, iterator, or file-like object
+                    headers=headers,
                     timeout=write_timeout
                 )
                 response.raise_for_status()
@@ -269,12 +389,21 @@ class AGFSClient:
         if last_error:
             self._handle_request_error(last_error)
 
-    def create(self, path: str) -> Dict[str, Any]:
-        """Create a new file"""
+    def create(self, path: str, exclusive: bool = False) -> Dict[str, Any]:
+        """Create a new file
+
+        Args:
+            path: Path of the file
+            exclusive: Create the file atomically only if it does not exist,
+                raising AGFSClientError otherwise
+        """
+        params = {"path": path}
+        if exclusive:
+            params["exclusive"] = "true"
         try:
             response = self.session.post(
                 f"{self.api_base}/files",
-                params={"path": path},
+                params=params,
                 timeout=self.timeout
             )
             response.raise_for_status()
@@ -311,8 +440,10 @@ class AGFSClient:
         except Exception as e:
             self._handle_request_error(e)
 
-    def stat(self, path: str) -> Dict[str, Any]:
-        """Get file/directory information"""
+    def stat(self, path: str, as_of: Union[datetime, str, None] = None) -> Dict[str, Any]:
+        """Get file/directory information, as it was at as_of if set (see cat)"""
+        if as_of is not None:
+            return self._get_as_of("/stat", path, {"path": path}, as_of).json()
         try:
             response = self.session.get(
                 f"{self.api_base}/stat",
@@ -803,6 +934,55 @@ class AGFSClient:
         except Exception as e:
             self._handle_request_error(e)
 
+    def handle_readv(self, handle_id: int, ranges: List[tuple]) -> List[bytes]:
+        """Read several ranges of a file handle with one request
+
+        Args:
+            handle_id: The handle ID (int64)
+            ranges: (offset, size) pairs
+
+        Returns:
+            The data of each range, in order; short past the end of the file
+        """
+        try:
+            response = self.session.get(
+                f"{self.api_base}/handles/{handle_id}/readv",
+                params={"ranges": ",".join(f"{offset}:{size}" for offset, size in ranges)},
+                timeout=self.timeout
+            )
+            response.raise_for_status()
+            data = response.content
+            result = []
+            pos = 0
+            for n in response.headers.get("X-Range-Sizes", "").split(","):
+                result.append(data[pos:pos + int(n)])
+                pos += int(n)
+            return result
+        except Exception as e:
+            self._handle_request_error(e)
+
+    def handle_writev(self, handle_id: int, vecs: List[tuple]) -> int:
+        """Write several ranges of a file handle with one request, in order
+
+        Args:
+            handle_id: The handle ID (int64)
+            vecs: (offset, data) pairs
+
+        Returns:
+            Total number of bytes written
+        """
+        try:
+            response = self.session.put(
+                f"{self.api_base}/handles/{handle_id}/writev",
+                params={"ranges": ",".join(f"{offset}:{len(data)}" for offset, data in vecs)},
+                data=b"".join(data for _, data in vecs),
+                timeout=self.timeout
+            )
+            response.raise_for_status()
+            return response.json().get("bytes_written", 0)
+        except Exception as e:
+            self._handle_request_error(e)
+
     def handle_seek(self, handle_id: int, offset: int, whence: int = 0) -> int:
         """Seek within a file handle
 
@@ -906,6 +1086,29 @@ class AGFSClient:
         except Exception as e:
             self._handle_request_error(e)
 
+    def renew_handles(self, handle_ids: List[int], lease: int = 60) -> Dict[str, Any]:
+        """Renew the leases on several file handles with one request
+
+        Args:
+            handle_ids: The handle IDs (int64)
+            lease: New lease duration in seconds
+
+        Returns:
+            Response with "renewals", one per handle in the order given (with
+            expires_at, or error if the handle could not be renewed), and the
+            number of "failed" renewals
+        """
+        try:
+            response = self.session.post(
+                f"{self.api_base}/handles/renew",
+                json={"handle_ids": list(handle_ids), "lease": lease},
+                timeout=self.timeout
+            )
+            response.raise_for_status()
+            return response.json()
+        except Exception as e:
+            self._handle_request_error(e)
+
 
 class FileHandle:
     """A file handle for stateful file operations
@@ -1014,6 +1217,32 @@ class FileHandle:
             raise AGFSClientError("Handle is closed")
         return self._client.handle_write(self._handle_id, data, offset)
 
+    def readv(self, ranges: List[tuple]) -> List[bytes]:
+        """Read several (offset, size) ranges with one request
+
+        Args:
+            ranges: (offset, size) pairs
+
+        Returns:
+            The data of each range, in order
+        """
+        if self._closed:
+            raise AGFSClientError("Handle is closed")
+        return self._client.handle_readv(self._handle_id, ranges)
+
+    def writev(self, vecs: List[tuple]) -> int:
+        """Write several (offset, data) ranges with one request
+
+        Args:
+            vecs: (offset, data) pairs
+
+        Returns:
+            Total number of bytes written
+        """
+        if self._closed:
+            raise AGFSClientError("Handle is closed")
+        return self._client.handle_writev(self._handle_id, vecs)
+
     def seek(self, offset: int, whence: int = 0) -> int:
         """Seek to position
 
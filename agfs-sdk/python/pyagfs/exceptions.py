@@ -27,3 +27,8 @@ class AGFSHTTPError(AGFSClientError):
 class AGFSNotSupportedError(AGFSClientError):
     """Operation not supported by the server or filesystem (HTTP 501)"""
     pass
+
+
+class AGFSHandleRevokedError(AGFSClientError):
+    """File handle closed by an administrator; open the file again (HTTP 410)"""
+    pass
@@ -0,0 +1,168 @@
+//! Synchronous client, for programs without an async runtime
+//!
+//! Its methods are those of [`crate::Client`], run to completion on a
+//! runtime of the client. They must not be called from async code: use
+//! [`crate::Client`] there.
+
+use crate::error::Result;
+use crate::types::{Capabilities, FileInfo, HandleInfo, OpenFlags, Priority, RenameResult, Whence};
+
+/// Synchronous client of the AGFS HTTP API
+#[derive(Debug)]
+pub struct Client {
+    inner: crate::Client,
+    rt: tokio::runtime::Runtime,
+}
+
+impl Client {
+    /// Creates a client of the server at base_url (see [`crate::Client::new`])
+    pub fn new(base_url: &str) -> Self {
+        Self::from_async(crate::Client::new(base_url))
+    }
+
+    /// Creates a client sending its requests with inner
+    pub fn from_async(inner: crate::Client) -> Self {
+        let rt = tokio::runtime::Builder::new_current_thread()
+            .enable_all()
+            .build()
+            .expect("failed to create the runtime of the client");
+        Client { inner, rt }
+    }
+
+    /// Sends token as a bearer token with every request
+    pub fn with_token(self, token: impl Into<String>) -> Self {
+        Client {
+            inner: self.inner.with_token(token),
+            rt: self.rt,
+        }
+    }
+
+    /// Names the client to the server
+    pub fn with_client_name(self, name: impl Into<String>) -> Self {
+        Client {
+            inner: self.inner.with_client_name(name),
+            rt: self.rt,
+        }
+    }
+
+    /// Returns a client like this one whose requests are of priority class p
+    pub fn with_priority(&self, p: Priority) -> Self {
+        Self::from_async(self.inner.with_priority(p))
+    }
+
+    /// Returns the asynchronous client the requests are sent with
+    pub fn as_async(&self) -> &crate::Client {
+        &self.inner
+    }
+
+    pub fn health(&self) -> Result<()> {
+        self.rt.block_on(self.inner.health())
+    }
+
+    pub fn capabilities(&self) -> Result<Capabilities> {
+        self.rt.block_on(self.inner.capabilities())
+    }
+
+    pub fn create(&self, path: &str) -> Result<()> {
+        self.rt.block_on(self.inner.create(path))
+    }
+
+    pub fn create_exclusive(&self, path: &str) -> Result<()> {
+        self.rt.block_on(self.inner.create_exclusive(path))
+    }
+
+    pub fn mkdir(&self, path: &str, perm: u32) -> Result<()> {
+        self.rt.block_on(self.inner.mkdir(path, perm))
+    }
+
+    pub fn remove(&self, path: &str) -> Result<()> {
+        self.rt.block_on(self.inner.remove(path))
+    }
+
+    pub fn remove_all(&self, path: &str) -> Result<()> {
+        self.rt.block_on(self.inner.remove_all(path))
+    }
+
+    pub fn read(&self, path: &str, offset: i64, size: i64) -> Result<Vec<u8>> {
+        self.rt.block_on(self.inner.read(path, offset, size))
+    }
+
+    pub fn write(&self, path: &str, data: &[u8]) -> Result<String> {
+        self.rt.block_on(self.inner.write(path, data))
+    }
+
+    pub fn write_sync(&self, path: &str, data: &[u8]) -> Result<String> {
+        self.rt.block_on(self.inner.write_sync(path, data))
+    }
+
+    pub fn read_dir(&self, path: &str) -> Result<Vec<FileInfo>> {
+        self.rt.block_on(self.inner.read_dir(path))
+    }
+
+    pub fn stat(&self, path: &str) -> Result<FileInfo> {
+        self.rt.block_on(self.inner.stat(path))
+    }
+
+    pub fn rename(&self, old_path: &str, new_path: &str) -> Result<RenameResult> {
+        self.rt.block_on(self.inner.rename(old_path, new_path))
+    }
+
+    pub fn chmod(&self, path: &str, mode: u32) -> Result<()> {
+        self.rt.block_on(self.inner.chmod(path, mode))
+    }
+
+    pub fn truncate(&self, path: &str, size: i64) -> Result<()> {
+        self.rt.block_on(self.inner.truncate(path, size))
+    }
+
+    pub fn sync(&self, path: &str) -> Result<()> {
+        self.rt.block_on(self.inner.sync(path))
+    }
+
+    pub fn symlink(&self, target_path: &str, link_path: &str) -> Result<()> {
+        self.rt.block_on(self.inner.symlink(target_path, link_path))
+    }
+
+    pub fn readlink(&self, link_path: &str) -> Result<String> {
+        self.rt.block_on(self.inner.readlink(link_path))
+    }
+
+    pub fn open_handle(&self, path: &str, flags: OpenFlags, mode: u32) -> Result<i64> {
+        self.rt.block_on(self.inner.open_handle(path, flags, mode))
+    }
+
+    pub fn close_handle(&self, handle_id: i64) -> Result<()> {
+        self.rt.block_on(self.inner.close_handle(handle_id))
+    }
+
+    pub fn read_handle(&self, handle_id: i64, offset: i64, size: usize) -> Result<Vec<u8>> {
+        self.rt
+            .block_on(self.inner.read_handle(handle_id, offset, size))
+    }
+
+    pub fn write_handle(&self, handle_id: i64, data: &[u8], offset: i64) -> Result<usize> {
+        self.rt
+            .block_on(self.inner.write_handle(handle_id, data, offset))
+    }
+
+    pub fn seek_handle(&self, handle_id: i64, offset: i64, whence: Whence) -> Result<i64> {
+        self.rt
+            .block_on(self.inner.seek_handle(handle_id, offset, whence))
+    }
+
+    pub fn sync_handle(&self, handle_id: i64) -> Result<()> {
+        self.rt.block_on(self.inner.sync_handle(handle_id))
+    }
+
+    pub fn stat_handle(&self, handle_id: i64) -> Result<FileInfo> {
+        self.rt.block_on(self.inner.stat_handle(handle_id))
+    }
+
+    pub fn get_handle(&self, handle_id: i64) -> Result<HandleInfo> {
+        self.rt.block_on(self.inner.get_handle(handle_id))
+    }
+
+    pub fn renew_handle(&self, handle_id: i64, lease: u32) -> Result<String> {
+        self.rt.block_on(self.inner.renew_handle(handle_id, lease))
+    }
+}
@@ -0,0 +1,524 @@
+//! Asynchronous client
+
+use std::collections::hash_map::RandomState;
+use std::hash::{BuildHasher, Hasher};
+use std::sync::atomic::{AtomicU64, Ordering};
+use std::time::Duration;
+
+use reqwest::header::CONTENT_TYPE;
+use reqwest::{Method, RequestBuilder, Response, StatusCode};
+use serde::Deserialize;
+use serde_json::json;
+
+use crate::error::{Error, Result};
+use crate::types::{Capabilities, FileInfo, HandleInfo, OpenFlags, Priority, RenameResult, Whence};
+
+/// Timeout of the requests of clients created with [`Client::new`]
+const DEFAULT_TIMEOUT: Duration = Duration::from_secs(10);
+
+/// Times writes are sent again after network or server errors
+const WRITE_RETRIES: u32 = 3;
+
+#[derive(Deserialize)]
+struct ErrorResponse {
+    error: String,
+}
+
+#[derive(Deserialize)]
+struct SuccessResponse {
+    #[serde(default)]
+    message: String,
+}
+
+#[derive(Deserialize)]
+struct ListResponse {
+    // Empty directories are listed as null
+    files: Option<Vec<FileInfo>>,
+}
+
+/// Client of the AGFS HTTP API. Clones share the connections of the client.
+#[derive(Debug, Clone)]
+pub struct Client {
+    base_url: String,
+    http: reqwest::Client,
+    token: Option<String>,
+    name: Option<String>,
+    priority: Option<Priority>,
+}
+
+impl Client {
+    /// Creates a client of the server at base_url, either the full URL with
+    /// "/api/v1" or just the base, e.g. "http://localhost:8080"
+    pub fn new(base_url: &str) -> Self {
+        let http = reqwest::Client::builder()
+            .timeout(DEFAULT_TIMEOUT)
+            .build()
+            .expect("failed to create the HTTP client");
+        Self::with_http_client(base_url, http)
+    }
+
+    /// Creates a client sending its requests with http, e.g. to set other
+    /// timeouts or TLS settings
+    pub fn with_http_client(base_url: &str, http: reqwest::Client) -> Self {
+        Client {
+            base_url: normalize_base_url(base_url),
+            http,
+            token: None,
+            name: None,
+            priority: None,
+        }
+    }
+
+    /// Sends token as a bearer token with every request, for servers whose
+    /// listener requires one
+    pub fn with_token(mut self, token: impl Into<String>) -> Self {
+        self.token = Some(token.into());
+        self
+    }
+
+    /// Names the client to the server, e.g. "indexer@host", so that
+    /// administrators can tell its handles apart and revoke them
+    pub fn with_client_name(mut self, name: impl Into<String>) -> Self {
+        self.name = Some(name.into());
+        self
+    }
+
+    /// Returns a client like this one whose requests are of priority class p,
+    /// which servers use to run interactive requests first on busy mounts
+    pub fn with_priority(&self, p: Priority) -> Self {
+        let mut clone = self.clone();
+        clone.priority = Some(p);
+        clone
+    }
+
+    /// Returns the URL of the API, ending with "/api/v1"
+    pub fn base_url(&self) -> &str {
+        &self.base_url
+    }
+
+    fn request(&self, method: Method, endpoint: &str) -> RequestBuilder {
+        let mut rb = self
+            .http
+            .request(method, format!("{}{}", self.base_url, endpoint));
+        if let Some(token) = &self.token {
+            rb = rb.bearer_auth(token);
+        }
+        if let Some(name) = &self.name {
+            rb = rb.header("X-AGFS-Client", name);
+        }
+        if let Some(p) = self.priority {
+            rb = rb.header("X-AGFS-Priority", p.as_str());
+        }
+        rb
+    }
+
+    /// Sends a request about path, returning the error response of the
+    /// server as an error
+    async fn send(&self, op: &'static str, path: &str, rb: RequestBuilder) -> Result<Response> {
+        check(op, path, rb.send().await?).await
+    }
+
+    /// Checks the health of the server
+    pub async fn health(&self) -> Result<()> {
+        self.send("health", "", self.request(Method::GET, "/health"))
+            .await?;
+        Ok(())
+    }
+
+    /// Returns the version and features of the server
+    pub async fn capabilities(&self) -> Result<Capabilities> {
+        let resp = self.request(Method::GET, "/capabilities").send().await?;
+        // Older servers do not have the endpoint
+        if resp.status() == StatusCode::NOT_FOUND {
+            return Ok(Capabilities {
+                version: "unknown".to_string(),
+                features: Vec::new(),
+            });
+        }
+        Ok(check("capabilities", "", resp).await?.json().await?)
+    }
+
+    /// Creates an empty file
+    pub async fn create(&self, path: &str) -> Result<()> {
+        let rb = self
+            .request(Method::POST, "/files")
+            .query(&[("path", path)]);
+        self.send("create", path, rb).await?;
+        Ok(())
+    }
+
+    /// Atomically creates an empty file, failing with
+    /// [`ErrorKind::AlreadyExists`](crate::ErrorKind::AlreadyExists) if it
+    /// exists, as open(2) with O_CREAT|O_EXCL does
+    pub async fn create_exclusive(&self, path: &str) -> Result<()> {
+        let rb = self
+            .request(Method::POST, "/files")
+            .query(&[("path", path), ("exclusive", "true")]);
+        self.send("create", path, rb).await?;
+        Ok(())
+    }
+
+    /// Creates a directory
+    pub async fn mkdir(&self, path: &str, perm: u32) -> Result<()> {
+        let mode = format!("{:o}", perm);
+        let rb = self
+            .request(Method::POST, "/directories")
+            .query(&[("path", path), ("mode", &mode)]);
+        self.send("mkdir", path, rb).await?;
+        Ok(())
+    }
+
+    /// Removes a file or empty directory
+    pub async fn remove(&self, path: &str) -> Result<()> {
+        let rb = self
+            .request(Method::DELETE, "/files")
+            .query(&[("path", path), ("recursive", "false")]);
+        self.send("remove", path, rb).await?;
+        Ok(())
+    }
+
+    /// Removes a path and any children it contains
+    pub async fn remove_all(&self, path: &str) -> Result<()> {
+        let rb = self
+            .request(Method::DELETE, "/files")
+            .query(&[("path", path), ("recursive", "true")]);
+        self.send("removeall", path, rb).await?;
+        Ok(())
+    }
+
+    /// Reads size bytes of a file from offset, the rest of the file if size
+    /// is negative
+    pub async fn read(&self, path: &str, offset: i64, size: i64) -> Result<Vec<u8>> {
+        let mut rb = self.request(Method::GET, "/files").query(&[("path", path)]);
+        if offset > 0 {
+            rb = rb.query(&[("offset", offset)]);
+        }
+        if size >= 0 {
+            rb = rb.query(&[("size", size)]);
+        }
+        let resp = self.send("read", path, rb).await?;
+        Ok(resp.bytes().await?.to_vec())
+    }
+
+    /// Writes data to a file, creating it if necessary, and returns the
+    /// message of the server. Writes failing on network or server errors are
+    /// sent again, up to 3 times, with an Idempotency-Key so that the server
+    /// applies them once.
+    pub async fn write(&self, path: &str, data: &[u8]) -> Result<String> {
+        self.write_with_retry(path, data, false).await
+    }
+
+    /// Writes data to a file like [`write`](Self::write), returning only
+    /// once the server has made it durable
+    pub async fn write_sync(&self, path: &str, data: &[u8]) -> Result<String> {
+        self.write_with_retry(path, data, true).await
+    }
+
+    async fn write_with_retry(&self, path: &str, data: &[u8], sync: bool) -> Result<String> {
+        // Retries send the key of the first attempt, so that a write that
+        // went through before a timeout is not applied twice
+        let key = idempotency_key();
+        let mut attempt = 0;
+        loop {
+            let mut rb = self
+                .request(Method::PUT, "/files")
+                .query(&[("path", path)])
+                .header("Idempotency-Key", &key)
+                .body(data.to_vec());
+            if sync {
+                rb = rb.query(&[("sync", "true")]);
+            }
+            match rb.send().await {
+                Ok(resp) if resp.status().is_server_error() && attempt < WRITE_RETRIES => {}
+                Ok(resp) => {
+                    let resp: SuccessResponse = check("write", path, resp).await?.json().await?;
+                    return Ok(resp.message);
+                }
+                Err(err) if (err.is_connect() || err.is_timeout()) && attempt < WRITE_RETRIES => {}
+                Err(err) => return Err(err.into()),
+            }
+            // 1s, 2s, 4s
+            tokio::time::sleep(Duration::from_secs(1 << attempt)).await;
+            attempt += 1;
+        }
+    }
+
+    /// Lists the contents of a directory
+    pub async fn read_dir(&self, path: &str) -> Result<Vec<FileInfo>> {
+        let rb = self
+            .request(Method::GET, "/directories")
+            .query(&[("path", path)]);
+        let resp: ListResponse = self.send("readdir", path, rb).await?.json().await?;
+        Ok(resp.files.unwrap_or_default())
+    }
+
+    /// Returns the metadata of a file or directory
+    pub async fn stat(&self, path: &str) -> Result<FileInfo> {
+        let rb = self.request(Method::GET, "/stat").query(&[("path", path)]);
+        Ok(self.send("stat", path, rb).await?.json().await?)
+    }
+
+    /// Renames or moves a file or directory, and reports whether the server
+    /// did it atomically
+    pub async fn rename(&self, old_path: &str, new_path: &str) -> Result<RenameResult> {
+        let rb = self
+            .request(Method::POST, "/rename")
+            .query(&[("path", old_path)])
+            .json(&json!({ "newPath": new_path }));
+        Ok(self.send("rename", old_path, rb).await?.json().await?)
+    }
+
+    /// Changes the permissions of a file
+    pub async fn chmod(&self, path: &str, mode: u32) -> Result<()> {
+        let rb = self
+            .request(Method::POST, "/chmod")
+            .query(&[("path", path)])
+            .json(&json!({ "mode": mode }));
+        self.send("chmod", path, rb).await?;
+        Ok(())
+    }
+
+    /// Truncates a file to size bytes, padding it with zeros if it is shorter
+    pub async fn truncate(&self, path: &str, size: i64) -> Result<()> {
+        let rb = self
+            .request(Method::POST, "/truncate")
+            .query(&[("path", path)])
+            .query(&[("size", size)]);
+        self.send("truncate", path, rb).await?;
+        Ok(())
+    }
+
+    /// Flushes a file to durable storage on the server. It returns
+    /// [`Error::NotSupported`] if the server predates the sync endpoint.
+    pub async fn sync(&self, path: &str) -> Result<()> {
+        let resp = self
+            .request(Method::POST, "/sync")
+            .query(&[("path", path)])
+            .send()
+            .await?;
+        // Older servers answer unknown endpoints with a plain-text 404
+        if resp.status() == StatusCode::NOT_FOUND && !is_json(&resp) {
+            return Err(Error::NotSupported);
+        }
+        check("sync", path, resp).await?;
+        Ok(())
+    }
+
+    /// Creates a symbolic link at link_path pointing to target_path
+    pub async fn symlink(&self, target_path: &str, link_path: &str) -> Result<()> {
+        let rb = self
+            .request(Method::POST, "/symlink")
+            .query(&[("path", link_path)])
+            .json(&json!({ "target": target_path }));
+        self.send("symlink", link_path, rb).await?;
+        Ok(())
+    }
+
+    /// Returns the target of a symbolic link
+    pub async fn readlink(&self, link_path: &str) -> Result<String> {
+        #[derive(Deserialize)]
+        struct ReadlinkResponse {
+            target: String,
+        }
+        let rb = self
+            .request(Method::GET, "/readlink")
+            .query(&[("path", link_path)]);
+        let resp: ReadlinkResponse = self.send("readlink", link_path, rb).await?.json().await?;
+        Ok(resp.target)
+    }
+
+    /// Opens a file and returns the ID of its handle, which the `*_handle`
+    /// methods operate on. Close it with [`close_handle`](Self::close_handle).
+    /// It returns [`Error::NotSupported`] if the mount has no handles.
+    pub async fn open_handle(&self, path: &str, flags: OpenFlags, mode: u32) -> Result<i64> {
+        #[derive(Deserialize)]
+        struct HandleResponse {
+            handle_id: i64,
+        }
+        let mode = format!("{:o}", mode);
+        let rb = self
+            .request(Method::POST, "/handles/open")
+            .query(&[("path", path), ("mode", &mode)])
+            .query(&[("flags", flags.0)]);
+        let resp = rb.send().await?;
+        if resp.status() == StatusCode::NOT_IMPLEMENTED {
+            return Err(Error::NotSupported);
+        }
+        let resp: HandleResponse = check("openhandle", path, resp).await?.json().await?;
+        Ok(resp.handle_id)
+    }
+
+    /// Closes a file handle
+    pub async fn close_handle(&self, handle_id: i64) -> Result<()> {
+        self.send(
+            "closehandle",
+            "",
+            self.request(Method::DELETE, &handle_endpoint(handle_id, "")),
+        )
+        .await?;
+        Ok(())
+    }
+
+    /// Reads up to size bytes of a file handle from offset
+    pub async fn read_handle(&self, handle_id: i64, offset: i64, size: usize) -> Result<Vec<u8>> {
+        let rb = self
+            .request(Method::GET, &handle_endpoint(handle_id, "/read"))
+            .query(&[("offset", offset)])
+            .query(&[("size", size)]);
+        let resp = self.send("readhandle", "", rb).await?;
+        Ok(resp.bytes().await?.to_vec())
+    }
+
+    /// Writes data to a file handle at offset, and returns the bytes written
+    pub async fn write_handle(&self, handle_id: i64, data: &[u8], offset: i64) -> Result<usize> {
+        #[derive(Deserialize)]
+        struct WriteResponse {
+            bytes_written: usize,
+        }
+        let rb = self
+            .request(Method::PUT, &handle_endpoint(handle_id, "/write"))
+            .query(&[("offset", offset)])
+            .header(CONTENT_TYPE, "application/octet-stream")
+            .body(data.to_vec());
+        let resp = self.send("writehandle", "", rb).await?;
+        // Assume all bytes were written if the server does not say
+        match resp.json::<WriteResponse>().await {
+            Ok(resp) => Ok(resp.bytes_written),
+            Err(_) => Ok(data.len()),
+        }
+    }
+
+    /// Moves the offset of a file handle, and returns the new offset
+    pub async fn seek_handle(&self, handle_id: i64, offset: i64, whence: Whence) -> Result<i64> {
+        #[derive(Deserialize)]
+        struct SeekResponse {
+            offset: i64,
+        }
+        let rb = self
+            .request(Method::POST, &handle_endpoint(handle_id, "/seek"))
+            .query(&[("offset", offset), ("whence", whence as i64)]);
+        let resp: SeekResponse = self.send("seekhandle", "", rb).await?.json().await?;
+        Ok(resp.offset)
+    }
+
+    /// Flushes the writes of a file handle to durable storage
+    pub async fn sync_handle(&self, handle_id: i64) -> Result<()> {
+        self.send(
+            "synchandle",
+            "",
+            self.request(Method::POST, &handle_endpoint(handle_id, "/sync")),
+        )
+        .await?;
+        Ok(())
+    }
+
+    /// Returns the metadata of the file of a handle
+    pub async fn stat_handle(&self, handle_id: i64) -> Result<FileInfo> {
+        let rb = self.request(Method::GET, &handle_endpoint(handle_id, "/stat"));
+        Ok(self.send("stathandle", "", rb).await?.json().await?)
+    }
+
+    /// Returns the path and flags of an open handle
+    pub async fn get_handle(&self, handle_id: i64) -> Result<HandleInfo> {
+        let rb = self.request(Method::GET, &handle_endpoint(handle_id, ""));
+        Ok(self.send("gethandle", "", rb).await?.json().await?)
+    }
+
+    /// Renews the lease of a handle for lease seconds (0 for the server's
+    /// default lease), and returns when it expires as an RFC 3339 timestamp.
+    /// Handles that are neither used nor renewed are closed by the server
+    /// when their lease expires.
+    pub async fn renew_handle(&self, handle_id: i64, lease: u32) -> Result<String> {
+        #[derive(Deserialize)]
+        struct RenewResponse {
+            #[serde(default)]
+            expires_at: String,
+        }
+        let mut rb = self.request(Method::POST, &handle_endpoint(handle_id, "/renew"));
+        if lease > 0 {
+            rb = rb.query(&[("lease", lease)]);
+        }
+        let resp: RenewResponse = self.send("renewhandle", "", rb).await?.json().await?;
+        Ok(resp.expires_at)
+    }
+}
+
+/// Returns the error of a failed response of the server, which carries an
+/// error message
+async fn check(op: &'static str, path: &str, resp: Response) -> Result<Response> {
+    if resp.status().is_success() {
+        return Ok(resp);
+    }
+    let code = resp.status().as_u16();
+    let message = match resp.json::<ErrorResponse>().await {
+        Ok(body) => body.error,
+        Err(_) => "failed to decode error response".to_string(),
+    };
+    Err(Error::Status {
+        op,
+        path: path.to_string(),
+        code,
+        message,
+    })
+}
+
+fn is_json(resp: &Response) -> bool {
+    resp.headers()
+        .get(CONTENT_TYPE)
+        .and_then(|v| v.to_str().ok())
+        .is_some_and(|v| v.starts_with("application/json"))
+}
+
+fn handle_endpoint(handle_id: i64, action: &str) -> String {
+    format!("/handles/{}{}", handle_id, action)
+}
+
+/// Appends "/api/v1" to base URLs without it
+fn normalize_base_url(base_url: &str) -> String {
+    let base_url = base_url.trim_end_matches('/');
+    // Leave malformed URLs to fail with the error of the HTTP client
+    if !base_url.contains("://") || base_url.ends_with("/api/v1") {
+        return base_url.to_string();
+    }
+    format!("{}/api/v1", base_url)
+}
+
+/// Returns a random Idempotency-Key. Each RandomState is seeded differently,
+/// which spares the crate a dependency on rand.
+fn idempotency_key() -> String {
+    static COUNTER: AtomicU64 = AtomicU64::new(0);
+    let n = COUNTER.fetch_add(1, Ordering::Relaxed);
+    let half = || {
+        let mut h = RandomState::new().build_hasher();
+        h.write_u64(n);
+        h.finish()
+    };
+    format!("{:016x}{:016x}", half(), half())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_normalize_base_url() {
+        for (url, want) in [
+            ("http://localhost:8080", "http://localhost:8080/api/v1"),
+            ("http://localhost:8080/", "http://localhost:8080/api/v1"),
+            (
+                "http://localhost:8080/api/v1",
+                "http://localhost:8080/api/v1",
+            ),
+            ("localhost:8080", "localhost:8080"),
+        ] {
+            assert_eq!(normalize_base_url(url), want);
+        }
+    }
+
+    #[test]
+    fn test_idempotency_key() {
+        let (a, b) = (idempotency_key(), idempotency_key());
+        assert_eq!(a.len(), 32);
+        assert_ne!(a, b);
+    }
+}
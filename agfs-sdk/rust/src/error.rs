@@ -0,0 +1,125 @@
+//! Errors of the client
+
+use std::fmt;
+
+/// Result type of the client
+pub type Result<T> = std::result::Result<T, Error>;
+
+/// Class of an error, matching the error responses of the server by status
+/// code
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum ErrorKind {
+    /// The path does not exist (HTTP 404)
+    NotFound,
+    /// The operation is not allowed (HTTP 403)
+    PermissionDenied,
+    /// The server requires a token and none or a wrong one was sent (HTTP 401)
+    Unauthorized,
+    /// Invalid path or parameters (HTTP 400)
+    InvalidArgument,
+    /// The path already exists (HTTP 409)
+    AlreadyExists,
+    /// A conditional operation found the file at another version (HTTP 409)
+    Conflict,
+    /// A request or file exceeds a size limit (HTTP 413)
+    QuotaExceeded,
+    /// The server or the mount does not support the operation (HTTP 501)
+    NotSupported,
+    /// The mount serving the path is down (HTTP 503)
+    Unavailable,
+    /// The backend is out of space (HTTP 507)
+    NoSpace,
+    /// An administrator closed the handle; open the file again (HTTP 410)
+    Revoked,
+    /// Any other error, including transport errors
+    Other,
+}
+
+impl ErrorKind {
+    /// Returns the kind of an error response of the server
+    fn from_status(code: u16, message: &str) -> Self {
+        match code {
+            400 => ErrorKind::InvalidArgument,
+            401 => ErrorKind::Unauthorized,
+            403 => ErrorKind::PermissionDenied,
+            404 => ErrorKind::NotFound,
+            // Both existing paths and failed conditional operations are conflicts
+            409 if message.contains("version conflict") => ErrorKind::Conflict,
+            409 => ErrorKind::AlreadyExists,
+            410 => ErrorKind::Revoked,
+            413 => ErrorKind::QuotaExceeded,
+            501 => ErrorKind::NotSupported,
+            503 => ErrorKind::Unavailable,
+            507 => ErrorKind::NoSpace,
+            _ => ErrorKind::Other,
+        }
+    }
+}
+
+/// Error of the client
+#[derive(Debug)]
+pub enum Error {
+    /// Error response of the server
+    Status {
+        /// Client method, e.g. "stat" or "readhandle"
+        op: &'static str,
+        /// Path the operation was on, empty for handle operations
+        path: String,
+        /// HTTP status code
+        code: u16,
+        /// Error message of the server
+        message: String,
+    },
+    /// The server predates the operation
+    NotSupported,
+    /// The request could not be sent, or its response not received
+    Http(reqwest::Error),
+    /// The response of the server could not be decoded
+    Decode(String),
+}
+
+impl Error {
+    /// Returns the class of the error, e.g. to tell missing paths apart
+    pub fn kind(&self) -> ErrorKind {
+        match self {
+            Error::Status { code, message, .. } => ErrorKind::from_status(*code, message),
+            Error::NotSupported => ErrorKind::NotSupported,
+            Error::Http(_) | Error::Decode(_) => ErrorKind::Other,
+        }
+    }
+
+    /// Returns true if the path does not exist
+    pub fn is_not_found(&self) -> bool {
+        self.kind() == ErrorKind::NotFound
+    }
+}
+
+impl fmt::Display for Error {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            // As errors of the other SDKs are
+            Error::Status { code, message, .. } => write!(f, "HTTP {}: {}", code, message),
+            Error::NotSupported => write!(f, "operation not supported"),
+            Error::Http(err) => write!(f, "request failed: {}", err),
+            Error::Decode(msg) => write!(f, "failed to decode response: {}", msg),
+        }
+    }
+}
+
+impl std::error::Error for Error {
+    fn source(&self) -> Option<&(dyn std::error::Error + 'static)> {
+        match self {
+            Error::Http(err) => Some(err),
+            _ => None,
+        }
+    }
+}
+
+impl From<reqwest::Error> for Error {
+    fn from(err: reqwest::Error) -> Self {
+        if err.is_decode() {
+            return Error::Decode(err.to_string());
+        }
+        Error::Http(err)
+    }
+}
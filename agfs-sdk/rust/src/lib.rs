@@ -0,0 +1,37 @@
+//! AGFS Rust SDK
+//!
+//! A client of the AGFS HTTP API, for services embedding agents that read
+//! and write AGFS without shelling out to the CLI.
+//!
+//! [`Client`] is asynchronous and runs on tokio; [`blocking::Client`] has
+//! the same methods for synchronous programs (the default `blocking`
+//! feature).
+//!
+//! # Example
+//!
+//! ```no_run
+//! # async fn example() -> agfs::Result<()> {
+//! let client = agfs::Client::new("http://localhost:8080");
+//! client.write("/memfs/hello.txt", b"Hello, AGFS!").await?;
+//! let data = client.read("/memfs/hello.txt", 0, -1).await?;
+//! assert_eq!(data, b"Hello, AGFS!");
+//!
+//! match client.stat("/memfs/missing").await {
+//!     Err(err) if err.is_not_found() => println!("no such file"),
+//!     other => println!("{:?}", other),
+//! }
+//! # Ok(())
+//! # }
+//! ```
+
+#[cfg(feature = "blocking")]
+pub mod blocking;
+mod client;
+mod error;
+mod types;
+
+pub use client::Client;
+pub use error::{Error, ErrorKind, Result};
+pub use types::{
+    Capabilities, FileInfo, HandleInfo, MetaData, OpenFlags, Priority, RenameResult, Whence,
+};
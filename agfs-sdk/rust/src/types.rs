@@ -0,0 +1,141 @@
+//! Types of the AGFS HTTP API
+
+use std::collections::HashMap;
+use std::ops::BitOr;
+
+use serde::{Deserialize, Serialize};
+
+/// Structured metadata of a file or directory
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct MetaData {
+    /// Plugin name or identifier
+    #[serde(rename = "Name", default)]
+    pub name: String,
+    /// Type classification of the file or directory, e.g. "symlink"
+    #[serde(rename = "Type", default)]
+    pub type_: String,
+    /// Additional extensible metadata
+    #[serde(rename = "Content", default)]
+    pub content: Option<HashMap<String, String>>,
+}
+
+/// Metadata of a file or directory
+#[derive(Debug, Clone, Default, PartialEq, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct FileInfo {
+    pub name: String,
+    pub size: i64,
+    pub mode: u32,
+    /// Modification time, as an RFC 3339 timestamp
+    pub mod_time: String,
+    pub is_dir: bool,
+    #[serde(default)]
+    pub meta: MetaData,
+    /// Changes whenever the file changes, empty if the server does not say
+    #[serde(default)]
+    pub version: String,
+}
+
+impl FileInfo {
+    /// Returns true if the file is a symbolic link
+    pub fn is_symlink(&self) -> bool {
+        self.meta.type_ == "symlink"
+    }
+}
+
+/// Version and features of a server
+#[derive(Debug, Clone, Default, PartialEq, Deserialize)]
+pub struct Capabilities {
+    pub version: String,
+    #[serde(default)]
+    pub features: Vec<String>,
+}
+
+impl Capabilities {
+    /// Returns true if the server has the feature, e.g. "handles"
+    pub fn has(&self, feature: &str) -> bool {
+        self.features.iter().any(|f| f == feature)
+    }
+}
+
+/// Outcome of a rename. `atomic` is false when the server renamed across
+/// mounts by copying and deleting.
+#[derive(Debug, Clone, Default, PartialEq, Deserialize)]
+pub struct RenameResult {
+    #[serde(default)]
+    pub message: String,
+    #[serde(default = "default_atomic")]
+    pub atomic: bool,
+    #[serde(default)]
+    pub files_copied: i64,
+    #[serde(default)]
+    pub bytes_copied: i64,
+}
+
+// Older servers only rename within a mount, and do not report it
+fn default_atomic() -> bool {
+    true
+}
+
+/// Flags of an open file handle, combined with `|`
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Hash, Serialize, Deserialize)]
+#[serde(transparent)]
+pub struct OpenFlags(pub i32);
+
+impl OpenFlags {
+    pub const READ_ONLY: OpenFlags = OpenFlags(0);
+    pub const WRITE_ONLY: OpenFlags = OpenFlags(1);
+    pub const READ_WRITE: OpenFlags = OpenFlags(2);
+    pub const CREATE: OpenFlags = OpenFlags(64);
+    pub const EXCLUSIVE: OpenFlags = OpenFlags(128);
+    pub const TRUNCATE: OpenFlags = OpenFlags(512);
+    pub const APPEND: OpenFlags = OpenFlags(1024);
+    pub const SYNC: OpenFlags = OpenFlags(1052672);
+
+    /// Returns true if all flags of other are set
+    pub fn contains(self, other: OpenFlags) -> bool {
+        self.0 & other.0 == other.0
+    }
+}
+
+impl BitOr for OpenFlags {
+    type Output = OpenFlags;
+
+    fn bitor(self, rhs: OpenFlags) -> OpenFlags {
+        OpenFlags(self.0 | rhs.0)
+    }
+}
+
+/// Where the offset of a seek is from
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum Whence {
+    Start = 0,
+    Current = 1,
+    End = 2,
+}
+
+/// An open file handle
+#[derive(Debug, Clone, PartialEq, Deserialize)]
+pub struct HandleInfo {
+    pub id: i64,
+    pub path: String,
+    pub flags: OpenFlags,
+}
+
+/// Priority class of requests (see [`Client::with_priority`](crate::Client::with_priority))
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum Priority {
+    /// Someone waits on the requests (the default)
+    Interactive,
+    /// Bulk work, e.g. imports, which yields to interactive requests
+    Batch,
+}
+
+impl Priority {
+    pub(crate) fn as_str(self) -> &'static str {
+        match self {
+            Priority::Interactive => "interactive",
+            Priority::Batch => "batch",
+        }
+    }
+}
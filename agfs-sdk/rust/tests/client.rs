@@ -0,0 +1,213 @@
+use std::io::{BufRead, BufReader, Read, Write};
+use std::net::TcpListener;
+use std::sync::{Arc, Mutex};
+use std::thread;
+
+use agfs::{ErrorKind, OpenFlags, Whence};
+
+/// A request received by the mock server
+#[derive(Debug)]
+struct Request {
+    method: String,
+    target: String,
+    headers: Vec<(String, String)>,
+    body: Vec<u8>,
+}
+
+impl Request {
+    fn header(&self, name: &str) -> Option<&str> {
+        self.headers
+            .iter()
+            .find(|(k, _)| k.eq_ignore_ascii_case(name))
+            .map(|(_, v)| v.as_str())
+    }
+}
+
+/// Serves the responses, (status, body), to the requests in order, one
+/// connection each, and returns the base URL and the requests received
+fn serve(responses: Vec<(u16, &'static str)>) -> (String, Arc<Mutex<Vec<Request>>>) {
+    let listener = TcpListener::bind("127.0.0.1:0").unwrap();
+    let url = format!("http://{}", listener.local_addr().unwrap());
+    let requests = Arc::new(Mutex::new(Vec::new()));
+    let received = requests.clone();
+    thread::spawn(move || {
+        for (status, body) in responses {
+            let (stream, _) = listener.accept().unwrap();
+            let mut reader = BufReader::new(stream.try_clone().unwrap());
+            let mut line = String::new();
+            reader.read_line(&mut line).unwrap();
+            let mut parts = line.split_whitespace();
+            let (method, target) = (
+                parts.next().unwrap().to_string(),
+                parts.next().unwrap().to_string(),
+            );
+            let mut headers = Vec::new();
+            loop {
+                let mut line = String::new();
+                reader.read_line(&mut line).unwrap();
+                let line = line.trim_end();
+                if line.is_empty() {
+                    break;
+                }
+                let (k, v) = line.split_once(':').unwrap();
+                headers.push((k.to_string(), v.trim().to_string()));
+            }
+            let mut request = Request {
+                method,
+                target,
+                headers,
+                body: Vec::new(),
+            };
+            let length = request
+                .header("content-length")
+                .map_or(0, |v| v.parse().unwrap());
+            request.body = vec![0; length];
+            reader.read_exact(&mut request.body).unwrap();
+            received.lock().unwrap().push(request);
+
+            let mut stream = stream;
+            write!(
+                stream,
+                "HTTP/1.1 {} X\r\nContent-Type: application/json\r\nContent-Length: {}\r\nConnection: close\r\n\r\n{}",
+                status,
+                body.len(),
+                body
+            )
+            .unwrap();
+        }
+    });
+    (url, requests)
+}
+
+#[tokio::test]
+async fn test_files() {
+    let (url, requests) = serve(vec![
+        (200, r#"{"message": "written"}"#),
+        (200, "hello"),
+        (
+            200,
+            r#"{"files": [{"name": "a.txt", "size": 5, "mode": 420, "modTime": "2026-01-02T15:04:05Z", "isDir": false, "meta": {"Name": "memfs", "Type": "file", "Content": null}}]}"#,
+        ),
+        (200, r#"{"files": null}"#),
+        (404, r#"{"error": "no such file: /missing"}"#),
+        (200, r#"{"message": "renamed"}"#),
+    ]);
+    let client = agfs::Client::new(&url)
+        .with_token("secret")
+        .with_client_name("test");
+
+    assert_eq!(
+        client.write("/dir/a.txt", b"hello").await.unwrap(),
+        "written"
+    );
+    assert_eq!(client.read("/dir/a.txt", 1, 3).await.unwrap(), b"hello");
+    let files = client.read_dir("/dir").await.unwrap();
+    assert_eq!(files.len(), 1);
+    assert_eq!(
+        (
+            files[0].name.as_str(),
+            files[0].size,
+            files[0].meta.name.as_str()
+        ),
+        ("a.txt", 5, "memfs")
+    );
+    assert!(client.read_dir("/empty").await.unwrap().is_empty());
+
+    let err = client.stat("/missing").await.unwrap_err();
+    assert!(err.is_not_found());
+    assert_eq!(err.to_string(), "HTTP 404: no such file: /missing");
+    assert!(
+        client
+            .rename("/dir/a.txt", "/dir/b.txt")
+            .await
+            .unwrap()
+            .atomic
+    );
+
+    let requests = requests.lock().unwrap();
+    let targets: Vec<_> = requests
+        .iter()
+        .map(|r| format!("{} {}", r.method, r.target))
+        .collect();
+    assert_eq!(
+        targets,
+        [
+            "PUT /api/v1/files?path=%2Fdir%2Fa.txt",
+            "GET /api/v1/files?path=%2Fdir%2Fa.txt&offset=1&size=3",
+            "GET /api/v1/directories?path=%2Fdir",
+            "GET /api/v1/directories?path=%2Fempty",
+            "GET /api/v1/stat?path=%2Fmissing",
+            "POST /api/v1/rename?path=%2Fdir%2Fa.txt",
+        ]
+    );
+    assert_eq!(requests[0].body, b"hello");
+    assert_eq!(requests[0].header("authorization"), Some("Bearer secret"));
+    assert_eq!(requests[0].header("x-agfs-client"), Some("test"));
+    assert!(requests[0].header("idempotency-key").is_some());
+    assert_eq!(requests[5].body, br#"{"newPath":"/dir/b.txt"}"#);
+}
+
+#[tokio::test]
+async fn test_handles() {
+    let (url, requests) = serve(vec![
+        (200, r#"{"handle_id": 7}"#),
+        (200, r#"{"bytes_written": 3}"#),
+        (200, r#"{"offset": 3}"#),
+        (410, r#"{"error": "handle 7 revoked"}"#),
+        (501, r#"{"error": "handles not supported"}"#),
+    ]);
+    let client = agfs::Client::new(&url);
+
+    let id = client
+        .open_handle("/f", OpenFlags::READ_WRITE | OpenFlags::CREATE, 0o644)
+        .await
+        .unwrap();
+    assert_eq!(id, 7);
+    assert_eq!(client.write_handle(id, b"abc", 0).await.unwrap(), 3);
+    assert_eq!(client.seek_handle(id, 0, Whence::End).await.unwrap(), 3);
+    assert_eq!(
+        client.read_handle(id, 0, 3).await.unwrap_err().kind(),
+        ErrorKind::Revoked
+    );
+    assert!(matches!(
+        client.open_handle("/g", OpenFlags::READ_ONLY, 0).await,
+        Err(agfs::Error::NotSupported)
+    ));
+
+    let requests = requests.lock().unwrap();
+    let targets: Vec<_> = requests
+        .iter()
+        .map(|r| format!("{} {}", r.method, r.target))
+        .collect();
+    assert_eq!(
+        targets,
+        [
+            "POST /api/v1/handles/open?path=%2Ff&mode=644&flags=66",
+            "PUT /api/v1/handles/7/write?offset=0",
+            "POST /api/v1/handles/7/seek?offset=0&whence=2",
+            "GET /api/v1/handles/7/read?offset=0&size=3",
+            "POST /api/v1/handles/open?path=%2Fg&mode=0&flags=0",
+        ]
+    );
+    assert_eq!(
+        requests[1].header("content-type"),
+        Some("application/octet-stream")
+    );
+}
+
+#[cfg(feature = "blocking")]
+#[test]
+fn test_blocking() {
+    let (url, _) = serve(vec![
+        (200, r#"{"version": "1.4.0", "features": ["handles"]}"#),
+        (409, r#"{"error": "version conflict: /a.txt changed"}"#),
+    ]);
+    let client = agfs::blocking::Client::new(&url);
+
+    let caps = client.capabilities().unwrap();
+    assert!(caps.has("handles") && !caps.has("batch"));
+    assert_eq!(
+        client.create_exclusive("/a.txt").unwrap_err().kind(),
+        ErrorKind::Conflict
+    );
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/handlers"
+)
+
+// client talks to the REST and admin API of an agfs-server
+type client struct {
+	baseURL    string // Server URL ending in /api/v1
+	token      string // Bearer token, if the server requires one
+	httpClient *http.Client
+}
+
+// newClient creates a client; serverURL may be unix:///path/to/socket for a
+// server listening on a unix socket
+func newClient(serverURL, token string, timeout time.Duration) *client {
+	httpClient := &http.Client{Timeout: timeout}
+	if socket, ok := strings.CutPrefix(serverURL, "unix://"); ok {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}
+		httpClient.Transport = transport
+		serverURL = "http://agfs"
+	}
+
+	base := strings.TrimSuffix(serverURL, "/")
+	if !strings.HasSuffix(base, "/api/v1") {
+		base += "/api/v1"
+	}
+	return &client{
+		baseURL:    base,
+		token:      token,
+		httpClient: httpClient,
+	}
+}
+
+// do sends a request with an optional JSON body and decodes the JSON response
+// into out (unless out is nil); error responses become Go errors
+func (c *client) do(method, endpoint string, query url.Values, body, out interface{}) error {
+	u := c.baseURL + endpoint
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, u, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		var errResp handlers.ErrorResponse
+		if json.Unmarshal(data, &errResp) == nil && errResp.Error != "" {
+			return fmt.Errorf("%s (HTTP %d)", errResp.Error, resp.StatusCode)
+		}
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if out == nil {
+		return nil
+	}
+	if raw, ok := out.(*json.RawMessage); ok {
+		*raw = data
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+func (c *client) get(endpoint string, query url.Values, out interface{}) error {
+	return c.do(http.MethodGet, endpoint, query, nil, out)
+}
+
+func (c *client) post(endpoint string, body, out interface{}) error {
+	return c.do(http.MethodPost, endpoint, nil, body, out)
+}
@@ -0,0 +1,694 @@
+// agfsctl is the admin CLI of agfs-server
+//
+// Unlike the interactive agfs shell, which works on files, agfsctl manages the
+// server itself: mounts, plugins and their config, open handles, metrics, the
+// admin audit log and background tasks.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/config"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/handlers"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/mountablefs"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+)
+
+// command is an agfsctl subcommand
+type command struct {
+	name string
+	args string
+	help string
+	run  func(c *client, args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"mounts", "", "List mounts with their plugin and health", cmdMounts},
+		{"plugins", "[name]", "List plugins, or show the config parameters of one", cmdPlugins},
+		{"schema", "<plugin>", "Print the JSON Schema of a plugin config", cmdSchema},
+		{"validate-config", "<config.yaml>", "Check the plugin configs of a server config file", cmdValidateConfig},
+		{"handles", "[-path path] [-client client]", "List open file handles", cmdHandles},
+		{"drain", "<path>", "Close open handles at or below path (\"/\" for all)", cmdDrain},
+		{"revoke", "[path] [-client client] [-reason text]", "Revoke open handles, telling their clients to open the files again", cmdRevoke},
+		{"metrics", "[-f] [-interval 2s]", "Show server metrics, or follow them", cmdMetrics},
+		{"audit", "[-f] [-interval 2s]", "Show the admin audit log, or follow it", cmdAudit},
+		{"gc", "", "Run garbage collection on the server", cmdGC},
+		{"tasks", "", "List background tasks and the tasks each mount offers", cmdTasks},
+		{"task", "start <path> <task> [key=value...] [-wait] | status <id> | cancel <id>", "Start, inspect or cancel a background task", cmdTask},
+		{"migrate", "<src> <dst> [-parallel 4] [-bwlimit 50MB] [-status path] [-wait]", "Copy a tree between mounts on the server, resumably and verified", cmdMigrate},
+	}
+}
+
+var jsonOutput bool
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: agfsctl [flags] <command> [args]\n\nCommands:\n")
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s %s\t%s\n", cmd.name, cmd.args, cmd.help)
+	}
+	tw.Flush()
+	fmt.Fprintf(out, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	defaultServer := os.Getenv("AGFS_SERVER_URL")
+	if defaultServer == "" {
+		defaultServer = "http://localhost:8080"
+	}
+	server := flag.String("server", defaultServer, "agfs-server URL or unix:///path/to/socket (or $AGFS_SERVER_URL)")
+	token := flag.String("token", os.Getenv("AGFS_TOKEN"), "Bearer token, if the server requires one (or $AGFS_TOKEN)")
+	timeout := flag.Duration("timeout", 30*time.Second, "Request timeout")
+	flag.BoolVar(&jsonOutput, "json", false, "Print raw JSON responses")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	name, args := flag.Arg(0), flag.Args()[1:]
+	for _, cmd := range commands {
+		if cmd.name == name {
+			if err := cmd.run(newClient(*server, *token, *timeout), args); err != nil {
+				fmt.Fprintf(os.Stderr, "agfsctl %s: %v\n", name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "agfsctl: unknown command %q\n\n", name)
+	usage()
+	os.Exit(2)
+}
+
+// printJSON prints a value as indented JSON
+func printJSON(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// table returns a tab writer for aligned columns on stdout
+func table() *tabwriter.Writer {
+	return tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+}
+
+// followFlags parses the -f and -interval flags of follow-capable commands
+func followFlags(name string, args []string) (follow bool, interval time.Duration, err error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.BoolVar(&follow, "f", false, "Keep polling and print new data")
+	fs.DurationVar(&interval, "interval", 2*time.Second, "Polling interval with -f")
+	err = fs.Parse(args)
+	return follow, interval, err
+}
+
+func cmdMounts(c *client, args []string) error {
+	var resp handlers.ListMountsResponse
+	if err := c.get("/mounts", nil, &resp); err != nil {
+		return err
+	}
+	if jsonOutput {
+		return printJSON(resp)
+	}
+
+	sort.Slice(resp.Mounts, func(i, j int) bool { return resp.Mounts[i].Path < resp.Mounts[j].Path })
+	tw := table()
+	fmt.Fprintln(tw, "PATH\tPLUGIN\tHEALTH")
+	for _, m := range resp.Mounts {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", m.Path, m.PluginName, healthString(m.Health))
+	}
+	return tw.Flush()
+}
+
+func healthString(h *mountablefs.HealthStatus) string {
+	switch {
+	case h == nil:
+		return "-"
+	case h.Healthy():
+		return h.Status
+	default:
+		return fmt.Sprintf("%s (%d failures: %s)", h.Status, h.Failures, h.Error)
+	}
+}
+
+func cmdPlugins(c *client, args []string) error {
+	var resp handlers.ListPluginsResponse
+	if err := c.get("/plugins", nil, &resp); err != nil {
+		return err
+	}
+	sort.Slice(resp.Plugins, func(i, j int) bool { return resp.Plugins[i].Name < resp.Plugins[j].Name })
+
+	if len(args) == 0 {
+		if jsonOutput {
+			return printJSON(resp)
+		}
+		tw := table()
+		fmt.Fprintln(tw, "NAME\tTYPE\tMOUNTS")
+		for _, p := range resp.Plugins {
+			kind := "builtin"
+			if p.IsExternal {
+				kind = "external"
+			}
+			var paths []string
+			for _, m := range p.MountedPaths {
+				paths = append(paths, m.Path)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", p.Name, kind, strings.Join(paths, ", "))
+		}
+		return tw.Flush()
+	}
+
+	for _, p := range resp.Plugins {
+		if p.Name != args[0] {
+			continue
+		}
+		if jsonOutput {
+			return printJSON(p)
+		}
+		fmt.Printf("Plugin: %s\n", p.Name)
+		if p.LibraryPath != "" {
+			fmt.Printf("Library: %s\n", p.LibraryPath)
+		}
+		for _, m := range p.MountedPaths {
+			fmt.Printf("Mounted at: %s\n", m.Path)
+		}
+		if len(p.ConfigParams) == 0 {
+			fmt.Println("\nNo config parameters declared")
+			return nil
+		}
+		fmt.Println()
+		tw := table()
+		fmt.Fprintln(tw, "PARAMETER\tTYPE\tREQUIRED\tDEFAULT\tDESCRIPTION")
+		for _, param := range p.ConfigParams {
+			fmt.Fprintf(tw, "%s\t%s\t%v\t%s\t%s\n", param.Name, param.Type, param.Required, param.Default, paramDescription(param))
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("unknown plugin: %s", args[0])
+}
+
+// paramDescription returns the description of a parameter with its constraints
+func paramDescription(p plugin.ConfigParameter) string {
+	var notes []string
+	if len(p.Enum) > 0 {
+		notes = append(notes, "one of "+strings.Join(p.Enum, "|"))
+	}
+	if p.Minimum != nil {
+		notes = append(notes, fmt.Sprintf(">= %v", *p.Minimum))
+	}
+	if p.Maximum != nil {
+		notes = append(notes, fmt.Sprintf("<= %v", *p.Maximum))
+	}
+	if p.Secret {
+		notes = append(notes, "secret")
+	}
+	if p.Deprecated != "" {
+		notes = append(notes, "deprecated: "+p.Deprecated)
+	}
+	if len(notes) == 0 {
+		return p.Description
+	}
+	return fmt.Sprintf("%s [%s]", p.Description, strings.Join(notes, "; "))
+}
+
+func cmdSchema(c *client, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: agfsctl schema <plugin>")
+	}
+	var schema json.RawMessage
+	if err := c.get("/plugins/schema", url.Values{"name": {args[0]}}, &schema); err != nil {
+		return err
+	}
+	var v interface{}
+	if err := json.Unmarshal(schema, &v); err != nil {
+		return err
+	}
+	return printJSON(v)
+}
+
+// cmdValidateConfig checks the plugin sections of a server config file
+// against the config parameters the server reports for each plugin, so a
+// config can be checked before a server is started with it
+func cmdValidateConfig(c *client, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: agfsctl validate-config <config.yaml>")
+	}
+	cfg, err := config.LoadConfig(args[0])
+	if err != nil {
+		return err
+	}
+
+	var resp handlers.ListPluginsResponse
+	if err := c.get("/plugins", nil, &resp); err != nil {
+		return fmt.Errorf("failed to get plugin parameters from the server: %w", err)
+	}
+	params := make(map[string][]plugin.ConfigParameter, len(resp.Plugins))
+	for _, p := range resp.Plugins {
+		params[p.Name] = p.ConfigParams
+	}
+
+	names := make([]string, 0, len(cfg.Plugins))
+	for name := range cfg.Plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	problems := 0
+	report := func(instance, format string, a ...interface{}) {
+		problems++
+		fmt.Printf("  %s: %s\n", instance, fmt.Sprintf(format, a...))
+	}
+	for _, name := range names {
+		pc := cfg.Plugins[name]
+		instances := pc.Instances
+		if len(instances) == 0 {
+			instances = []config.PluginInstance{{Name: name, Enabled: pc.Enabled, Path: pc.Path, Config: pc.Config}}
+		}
+
+		pluginParams, known := params[name]
+		for _, inst := range instances {
+			if !inst.Enabled {
+				continue
+			}
+			label := name
+			if inst.Name != "" && inst.Name != name {
+				label = name + "/" + inst.Name
+			}
+			switch {
+			case !known:
+				report(label, "unknown plugin (not built in or loaded on the server)")
+				continue
+			case inst.Path == "":
+				report(label, "path is required")
+			}
+			if err := plugin.ValidateConfigParams(pluginParams, inst.Config); err != nil {
+				report(label, "%v", err)
+			}
+			// Declared parameters may not cover every key a plugin accepts,
+			// so the plugin's own Validate has the last word on unknown keys
+			if unknown := unknownKeys(pluginParams, inst.Config); len(unknown) > 0 {
+				fmt.Printf("  %s: warning: undeclared parameter(s): %s\n", label, strings.Join(unknown, ", "))
+			}
+			for _, warning := range plugin.DeprecatedParamsIn(pluginParams, inst.Config) {
+				fmt.Printf("  %s: warning: %s\n", label, warning)
+			}
+		}
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%s: %d problem(s) found", args[0], problems)
+	}
+	fmt.Printf("%s: OK\n", args[0])
+	return nil
+}
+
+// unknownKeys returns the config keys that are not declared parameters; a
+// plugin without declared parameters accepts any key
+func unknownKeys(params []plugin.ConfigParameter, cfg map[string]interface{}) []string {
+	if len(params) == 0 {
+		return nil
+	}
+	declared := make(map[string]bool, len(params))
+	for _, p := range params {
+		declared[p.Name] = true
+	}
+	var unknown []string
+	for key := range cfg {
+		if !declared[key] && key != "mount_path" {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+func cmdHandles(c *client, args []string) error {
+	fs := flag.NewFlagSet("handles", flag.ContinueOnError)
+	path := fs.String("path", "", "Only handles at or below this path")
+	clientName := fs.String("client", "", "Only handles opened by this client")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	query := url.Values{}
+	if *path != "" {
+		query.Set("path", *path)
+	}
+	if *clientName != "" {
+		query.Set("client", *clientName)
+	}
+
+	var resp handlers.AdminHandlesResponse
+	if err := c.get("/admin/handles", query, &resp); err != nil {
+		return err
+	}
+	if jsonOutput {
+		return printJSON(resp)
+	}
+	printHandles(resp.Handles)
+	return nil
+}
+
+// printHandles prints open handles as a table
+func printHandles(handles []mountablefs.OpenHandleInfo) {
+	tw := table()
+	fmt.Fprintln(tw, "ID\tPATH\tMOUNT\tFLAGS\tCLIENT")
+	for _, h := range handles {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%s\n", h.ID, h.Path, h.Mount, h.Flags, h.Client)
+	}
+	tw.Flush()
+}
+
+func cmdDrain(c *client, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: agfsctl drain <path>")
+	}
+	var resp handlers.DrainHandlesResponse
+	if err := c.post("/admin/handles/drain", handlers.DrainHandlesRequest{Path: args[0]}, &resp); err != nil {
+		return err
+	}
+	if jsonOutput {
+		return printJSON(resp)
+	}
+	fmt.Printf("Closed %d handle(s) under %s\n", resp.Closed, args[0])
+	return nil
+}
+
+func cmdRevoke(c *client, args []string) error {
+	fs := flag.NewFlagSet("revoke", flag.ContinueOnError)
+	clientName := fs.String("client", "", "Revoke the handles opened by this client")
+	reason := fs.String("reason", "", "Reason told to the clients")
+	var positional []string
+	for rest := args; len(rest) > 0; rest = fs.Args()[1:] {
+		if err := fs.Parse(rest); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+	}
+	if len(positional) > 1 || (len(positional) == 0 && *clientName == "") {
+		return fmt.Errorf("usage: agfsctl revoke [path] [-client client] [-reason text]")
+	}
+	var path string
+	if len(positional) == 1 {
+		path = positional[0]
+	}
+
+	var resp handlers.RevokeHandlesResponse
+	req := handlers.RevokeHandlesRequest{Path: path, Client: *clientName, Reason: *reason}
+	if err := c.post("/admin/handles/revoke", req, &resp); err != nil {
+		return err
+	}
+	if jsonOutput {
+		return printJSON(resp)
+	}
+	fmt.Printf("Revoked %d handle(s)\n", resp.Revoked)
+	if resp.Revoked > 0 {
+		printHandles(resp.Handles)
+	}
+	return nil
+}
+
+func cmdMetrics(c *client, args []string) error {
+	follow, interval, err := followFlags("metrics", args)
+	if err != nil {
+		return err
+	}
+	for {
+		var m handlers.AdminMetrics
+		if err := c.get("/admin/metrics", nil, &m); err != nil {
+			return err
+		}
+		if jsonOutput {
+			if err := printJSON(m); err != nil {
+				return err
+			}
+		} else {
+			fmt.Printf("%s uptime=%ds goroutines=%d alloc=%s sys=%s gc=%d mounts=%d unhealthy=%d handles=%d tasks=%d\n",
+				m.Time.Format(time.RFC3339), m.UptimeSeconds, m.Goroutines,
+				formatBytes(m.Memory.AllocBytes), formatBytes(m.Memory.SysBytes), m.Memory.NumGC,
+				m.Mounts, len(m.UnhealthyMounts), m.OpenHandles, m.RunningTasks)
+		}
+		if !follow {
+			return nil
+		}
+		time.Sleep(interval)
+	}
+}
+
+func cmdAudit(c *client, args []string) error {
+	follow, interval, err := followFlags("audit", args)
+	if err != nil {
+		return err
+	}
+	var since int64
+	for {
+		var resp handlers.AuditResponse
+		if err := c.get("/admin/audit", url.Values{"since": {strconv.FormatInt(since, 10)}}, &resp); err != nil {
+			return err
+		}
+		for _, e := range resp.Events {
+			since = e.Seq
+			if jsonOutput {
+				data, _ := json.Marshal(e)
+				fmt.Println(string(data))
+				continue
+			}
+			line := fmt.Sprintf("%s #%d %s %s", e.Time.Format(time.RFC3339), e.Seq, e.Action, e.Target)
+			if e.Detail != "" {
+				line += " (" + e.Detail + ")"
+			}
+			if e.Remote != "" {
+				line += " from " + e.Remote
+			}
+			if e.Error != "" {
+				line += " FAILED: " + e.Error
+			}
+			fmt.Println(line)
+		}
+		if !follow {
+			return nil
+		}
+		time.Sleep(interval)
+	}
+}
+
+func cmdGC(c *client, args []string) error {
+	var resp handlers.GCResponse
+	if err := c.post("/admin/gc", nil, &resp); err != nil {
+		return err
+	}
+	if jsonOutput {
+		return printJSON(resp)
+	}
+	fmt.Printf("GC done in %dms: heap %s -> %s, sys %s -> %s\n", resp.DurationMs,
+		formatBytes(resp.Before.HeapInuseBytes), formatBytes(resp.After.HeapInuseBytes),
+		formatBytes(resp.Before.SysBytes), formatBytes(resp.After.SysBytes))
+	return nil
+}
+
+func cmdTasks(c *client, args []string) error {
+	var resp handlers.AdminTasksResponse
+	if err := c.get("/admin/tasks", nil, &resp); err != nil {
+		return err
+	}
+	if jsonOutput {
+		return printJSON(resp)
+	}
+
+	tw := table()
+	fmt.Fprintln(tw, "ID\tMOUNT\tTASK\tSTATUS\tPROGRESS\tSTARTED")
+	for _, t := range resp.Tasks {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", t.ID, t.Mount, t.Task, t.Status, progressString(t), t.StartedAt.Format(time.RFC3339))
+	}
+	tw.Flush()
+
+	if len(resp.Available) > 0 {
+		fmt.Println("\nAvailable tasks:")
+		tw = table()
+		for _, m := range resp.Available {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\n", m.Mount, m.Plugin, strings.Join(m.Tasks, ", "))
+		}
+		tw.Flush()
+	}
+	return nil
+}
+
+func progressString(t mountablefs.TaskInfo) string {
+	s := strconv.FormatInt(t.Done, 10)
+	if t.Total > 0 {
+		s = fmt.Sprintf("%d/%d", t.Done, t.Total)
+	}
+	if t.Error != "" {
+		return s + " " + t.Error
+	}
+	if t.Message != "" {
+		return s + " " + t.Message
+	}
+	return s
+}
+
+func cmdTask(c *client, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: agfsctl task start <path> <task> [key=value...] [-wait] | status <id> | cancel <id>")
+	}
+
+	switch args[0] {
+	case "start":
+		fs := flag.NewFlagSet("task start", flag.ContinueOnError)
+		wait := fs.Bool("wait", false, "Wait for the task to finish, printing progress")
+		var positional []string
+		rest := args[1:]
+		for len(rest) > 0 {
+			if err := fs.Parse(rest); err != nil {
+				return err
+			}
+			if fs.NArg() == 0 {
+				break
+			}
+			positional = append(positional, fs.Arg(0))
+			rest = fs.Args()[1:]
+		}
+		if len(positional) < 2 {
+			return fmt.Errorf("usage: agfsctl task start <path> <task> [key=value...] [-wait]")
+		}
+		req := handlers.StartTaskRequest{Path: positional[0], Task: positional[1], Args: map[string]string{}}
+		for _, kv := range positional[2:] {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok {
+				return fmt.Errorf("invalid task argument %q (expected key=value)", kv)
+			}
+			req.Args[k] = v
+		}
+
+		var t mountablefs.TaskInfo
+		if err := c.post("/admin/tasks", req, &t); err != nil {
+			return err
+		}
+		if !*wait {
+			if jsonOutput {
+				return printJSON(t)
+			}
+			fmt.Printf("Started task %d (%s on %s)\n", t.ID, t.Task, t.Mount)
+			return nil
+		}
+		return waitTask(c, t.ID)
+
+	case "status":
+		var t mountablefs.TaskInfo
+		if err := c.get("/admin/tasks/"+args[1], nil, &t); err != nil {
+			return err
+		}
+		if jsonOutput {
+			return printJSON(t)
+		}
+		fmt.Printf("Task %d: %s on %s: %s %s\n", t.ID, t.Task, t.Mount, t.Status, progressString(t))
+		return nil
+
+	case "cancel":
+		if err := c.do("DELETE", "/admin/tasks/"+args[1], nil, nil, nil); err != nil {
+			return err
+		}
+		fmt.Printf("Cancellation of task %s requested\n", args[1])
+		return nil
+	}
+	return fmt.Errorf("unknown task subcommand %q (expected start, status or cancel)", args[0])
+}
+
+func cmdMigrate(c *client, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	parallel := fs.Int("parallel", 0, "Files copied at once (default 4)")
+	bwlimit := fs.String("bwlimit", "", "Bytes copied per second, e.g. 50MB (default unlimited)")
+	status := fs.String("status", "", "Status file (default <dst>/.agfs-migrate.json)")
+	wait := fs.Bool("wait", false, "Wait for the migration to finish, printing progress")
+	var positional []string
+	for rest := args; len(rest) > 0; rest = fs.Args()[1:] {
+		if err := fs.Parse(rest); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+	}
+	if len(positional) != 2 {
+		return fmt.Errorf("usage: agfsctl migrate <src> <dst> [-parallel 4] [-bwlimit 50MB] [-status path] [-wait]")
+	}
+
+	req := handlers.MigrateRequest{
+		Src:        positional[0],
+		Dst:        positional[1],
+		Parallel:   *parallel,
+		Bandwidth:  *bwlimit,
+		StatusFile: *status,
+	}
+	var t mountablefs.TaskInfo
+	if err := c.post("/admin/migrate", req, &t); err != nil {
+		return err
+	}
+	if !*wait {
+		if jsonOutput {
+			return printJSON(t)
+		}
+		fmt.Printf("Started migration of %s to %s as task %d\n", req.Src, req.Dst, t.ID)
+		return nil
+	}
+	return waitTask(c, t.ID)
+}
+
+// waitTask polls a task until it finishes, printing progress changes
+func waitTask(c *client, id int64) error {
+	last := ""
+	for {
+		var t mountablefs.TaskInfo
+		if err := c.get("/admin/tasks/"+strconv.FormatInt(id, 10), nil, &t); err != nil {
+			return err
+		}
+		line := fmt.Sprintf("Task %d: %s %s", t.ID, t.Status, progressString(t))
+		if line != last {
+			fmt.Println(line)
+			last = line
+		}
+		switch t.Status {
+		case mountablefs.TaskStatusRunning:
+			time.Sleep(time.Second)
+		case mountablefs.TaskStatusSucceeded:
+			return nil
+		default:
+			return fmt.Errorf("task %d %s", t.ID, t.Status)
+		}
+	}
+}
+
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
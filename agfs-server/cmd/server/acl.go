@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// networkACL decides which clients may connect to a listener, by address
+type networkACL struct {
+	allow []netip.Prefix // Empty allows every client not denied
+	deny  []netip.Prefix
+}
+
+// parseACL parses the allow and deny lists of a listener, CIDRs such as
+// "10.0.0.0/8" or single IPs; nil if both are empty
+func parseACL(allow, deny []string) (*networkACL, error) {
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil, nil
+	}
+	acl := &networkACL{}
+	var err error
+	if acl.allow, err = parsePrefixes("allow", allow); err != nil {
+		return nil, err
+	}
+	if acl.deny, err = parsePrefixes("deny", deny); err != nil {
+		return nil, err
+	}
+	return acl, nil
+}
+
+func parsePrefixes(key string, entries []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid CIDR %q", key, entry)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid IP or CIDR %q", key, entry)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// permits reports whether a client may connect: it must not be in a denied
+// range, and must be in an allowed one if there are any
+func (a *networkACL) permits(addr netip.Addr) bool {
+	// IPv4 clients of a dual-stack listener come as ::ffff:a.b.c.d
+	addr = addr.Unmap()
+	for _, prefix := range a.deny {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	if len(a.allow) == 0 {
+		return true
+	}
+	for _, prefix := range a.allow {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *networkACL) String() string {
+	var rules []string
+	if len(a.allow) > 0 {
+		rules = append(rules, fmt.Sprintf("allow %d range(s)", len(a.allow)))
+	}
+	if len(a.deny) > 0 {
+		rules = append(rules, fmt.Sprintf("deny %d range(s)", len(a.deny)))
+	}
+	return strings.Join(rules, ", ")
+}
+
+// aclListener closes the connections of clients its ACL does not permit as
+// soon as they are accepted, before TLS handshakes and authentication
+type aclListener struct {
+	net.Listener
+	acl *networkACL
+}
+
+func (l *aclListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		addrPort, err := netip.ParseAddrPort(conn.RemoteAddr().String())
+		if err == nil && l.acl.permits(addrPort.Addr()) {
+			return conn, nil
+		}
+		log.Debugf("Refused connection from %s to %s (network ACL)", conn.RemoteAddr(), l.Addr())
+		conn.Close()
+	}
+}
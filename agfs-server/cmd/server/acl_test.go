@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestParseACL(t *testing.T) {
+	if acl, err := parseACL(nil, nil); acl != nil || err != nil {
+		t.Errorf("parseACL(nil, nil) = %v, %v; want no ACL", acl, err)
+	}
+
+	for _, tc := range []struct {
+		allow, deny []string
+		wantErr     bool
+	}{
+		{allow: []string{"10.0.0.0/8", " 192.168.1.7 "}},
+		{allow: []string{"fd00::/8", "2001:db8::1"}},
+		{deny: []string{"10.0.0.1/8"}}, // Host bits are masked
+		{allow: []string{"10.0.0.0/33"}, wantErr: true},
+		{allow: []string{"example.com"}, wantErr: true},
+		{deny: []string{"10.0.0.256"}, wantErr: true},
+		{deny: []string{""}, wantErr: true},
+		{deny: []string{"fd00::/129"}, wantErr: true},
+	} {
+		acl, err := parseACL(tc.allow, tc.deny)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("parseACL(%q, %q) error = %v, want error %v", tc.allow, tc.deny, err, tc.wantErr)
+			continue
+		}
+		if err == nil && acl == nil {
+			t.Errorf("parseACL(%q, %q) returned no ACL", tc.allow, tc.deny)
+		}
+	}
+}
+
+func TestNetworkACLPermits(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		allow, deny []string
+		addr        string
+		want        bool
+	}{
+		{"v4 CIDR allowed", []string{"10.0.0.0/8"}, nil, "10.1.2.3", true},
+		{"v4 outside allowed", []string{"10.0.0.0/8"}, nil, "11.0.0.1", false},
+		{"v4 bare IP allowed", []string{"192.168.1.7"}, nil, "192.168.1.7", true},
+		{"v4 bare IP is a single address", []string{"192.168.1.7"}, nil, "192.168.1.8", false},
+		{"v6 CIDR allowed", []string{"fd00::/8"}, nil, "fd12::1", true},
+		{"v6 outside allowed", []string{"fd00::/8"}, nil, "2001:db8::1", false},
+		{"v6 bare IP allowed", []string{"2001:db8::1"}, nil, "2001:db8::1", true},
+		{"v6 bare IP is a single address", []string{"2001:db8::1"}, nil, "2001:db8::2", false},
+		{"deny wins over allow", []string{"10.0.0.0/8"}, []string{"10.0.0.0/24"}, "10.0.0.5", false},
+		{"allowed outside denied", []string{"10.0.0.0/8"}, []string{"10.0.0.0/24"}, "10.0.1.5", true},
+		{"empty allow permits the rest", nil, []string{"10.0.0.0/8"}, "172.16.0.1", true},
+		{"empty allow still denies", nil, []string{"10.0.0.0/8"}, "10.9.9.9", false},
+		{"empty allow v6", nil, []string{"10.0.0.0/8"}, "::1", true},
+		{"mapped peer allowed by v4 range", []string{"10.0.0.0/8"}, nil, "::ffff:10.1.2.3", true},
+		{"mapped peer outside v4 range", []string{"10.0.0.0/8"}, nil, "::ffff:11.1.2.3", false},
+		{"mapped peer denied by v4 range", nil, []string{"127.0.0.1"}, "::ffff:127.0.0.1", false},
+		{"v4 range does not match v6", []string{"0.0.0.0/0"}, nil, "::1", false},
+	} {
+		acl, err := parseACL(tc.allow, tc.deny)
+		if err != nil {
+			t.Fatalf("%s: parseACL failed: %v", tc.name, err)
+		}
+		if got := acl.permits(netip.MustParseAddr(tc.addr)); got != tc.want {
+			t.Errorf("%s: permits(%s) = %v, want %v", tc.name, tc.addr, got, tc.want)
+		}
+	}
+}
+
+// fakeConn is a connection from a remote address that records its closing
+type fakeConn struct {
+	net.Conn
+	remote net.Addr
+	closed bool
+}
+
+func (c *fakeConn) RemoteAddr() net.Addr { return c.remote }
+func (c *fakeConn) Close() error         { c.closed = true; return nil }
+
+// fakeListener accepts its connections in order, then fails
+type fakeListener struct {
+	net.Listener
+	conns []*fakeConn
+}
+
+func (l *fakeListener) Accept() (net.Conn, error) {
+	if len(l.conns) == 0 {
+		return nil, net.ErrClosed
+	}
+	conn := l.conns[0]
+	l.conns = l.conns[1:]
+	return conn, nil
+}
+
+func (l *fakeListener) Addr() net.Addr { return &net.TCPAddr{Port: 8080} }
+
+// mappedAddr is a remote address given as a string
+type mappedAddr string
+
+func (a mappedAddr) Network() string { return "tcp" }
+func (a mappedAddr) String() string  { return string(a) }
+
+func TestACLListenerAccept(t *testing.T) {
+	acl, err := parseACL([]string{"10.0.0.0/8"}, []string{"10.0.0.66"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcp := func(ip string) net.Addr { return &net.TCPAddr{IP: net.ParseIP(ip), Port: 40000} }
+	denied := &fakeConn{remote: tcp("192.168.0.1")}
+	deniedToo := &fakeConn{remote: tcp("10.0.0.66")}
+	allowed := &fakeConn{remote: tcp("10.0.0.1")}
+	// As dual-stack listeners report IPv4 clients
+	mapped := &fakeConn{remote: mappedAddr("[::ffff:10.0.0.2]:40001")}
+	l := &aclListener{Listener: &fakeListener{conns: []*fakeConn{denied, deniedToo, allowed, mapped}}, acl: acl}
+
+	// Denied connections are closed, and the listener goes on accepting
+	conn, err := l.Accept()
+	if err != nil || conn != allowed {
+		t.Fatalf("Accept = %v, %v; want the allowed connection", conn, err)
+	}
+	if !denied.closed || !deniedToo.closed {
+		t.Error("expected the denied connections to be closed")
+	}
+	if allowed.closed {
+		t.Error("allowed connection was closed")
+	}
+	if conn, err := l.Accept(); err != nil || conn != mapped {
+		t.Errorf("Accept = %v, %v; want the IPv4-mapped connection", conn, err)
+	}
+
+	// Errors of the listener are returned
+	if _, err := l.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected the listener error, got %v", err)
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/config"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/middleware"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/mountablefs"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/api"
+	log "github.com/sirupsen/logrus"
+)
+
+// Check statuses
+const (
+	checkOK       = "ok"
+	checkWarning  = "warning"
+	checkError    = "error"
+	checkSkipped  = "skipped"  // Check not applicable
+	checkDisabled = "disabled" // Instance not enabled, so not checked
+)
+
+// probeTimeout bounds initializing a plugin and probing its backends
+const probeTimeout = 30 * time.Second
+
+// checkItem is the outcome of one check
+type checkItem struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// mountCheck holds the checks of one configured plugin instance
+type mountCheck struct {
+	Path     string      `json:"path"`
+	Plugin   string      `json:"plugin"`
+	Instance string      `json:"instance"`
+	Status   string      `json:"status"`
+	Checks   []checkItem `json:"checks"`
+}
+
+func (m *mountCheck) add(name string, err error) bool {
+	if err != nil {
+		m.Checks = append(m.Checks, checkItem{Name: name, Status: checkError, Message: err.Error()})
+		return false
+	}
+	m.Checks = append(m.Checks, checkItem{Name: name, Status: checkOK})
+	return true
+}
+
+// checkReport is the result of --check-config
+type checkReport struct {
+	Config   string       `json:"config"`
+	OK       bool         `json:"ok"` // No errors (warnings are fine)
+	Errors   int          `json:"errors"`
+	Warnings int          `json:"warnings"`
+	Server   []checkItem  `json:"server"`
+	Mounts   []mountCheck `json:"mounts"`
+}
+
+// checkConfig validates a configuration without serving: the server
+// settings, then every enabled plugin instance with the plugin's Validate.
+// With probe, plugins that have backends to check (those implementing
+// HealthChecker or Prober) are also initialized, checked and shut down; other
+// plugins are only validated, since initializing them may have side effects
+// such as listening on a port.
+func checkConfig(configFile string, cfg *config.Config, probe bool) *checkReport {
+	report := &checkReport{Config: configFile}
+
+	for _, l := range cfg.GetListeners("") {
+		item := checkItem{Name: "listener", Status: checkOK}
+		if spec, err := prepareListener(l); err != nil {
+			item.Status, item.Message = checkError, fmt.Sprintf("%s: %v", l.Address, err)
+		} else {
+			item.Message = spec.String()
+		}
+		report.Server = append(report.Server, item)
+	}
+	if cfg.Server.LogLevel != "" {
+		item := checkItem{Name: "log_level", Status: checkOK}
+		if _, err := log.ParseLevel(cfg.Server.LogLevel); err != nil {
+			item.Status, item.Message = checkWarning, "unknown level, info is used: "+err.Error()
+		}
+		report.Server = append(report.Server, item)
+	}
+
+	mfs := mountablefs.NewMountableFS(api.PoolConfig{})
+	loadExternalPlugins(mfs, cfg)
+
+	pluginNames := make([]string, 0, len(cfg.Plugins))
+	for pluginName := range cfg.Plugins {
+		pluginNames = append(pluginNames, pluginName)
+	}
+	sort.Strings(pluginNames)
+
+	mountedBy := make(map[string]string) // mount path -> instance name
+	for _, pluginName := range pluginNames {
+		for _, instance := range pluginInstances(pluginName, cfg.Plugins[pluginName]) {
+			mc := mountCheck{Path: instance.Path, Plugin: pluginName, Instance: instance.Name}
+			if !instance.Enabled {
+				mc.Status = checkDisabled
+				report.Mounts = append(report.Mounts, mc)
+				continue
+			}
+			checkInstance(&mc, mfs, instance, probe)
+
+			path := filesystem.NormalizePath(instance.Path)
+			if other, ok := mountedBy[path]; ok {
+				mc.add("mount path", fmt.Errorf("also used by instance '%s'", other))
+			} else {
+				mountedBy[path] = instance.Name
+			}
+			report.Mounts = append(report.Mounts, mc)
+		}
+	}
+	sort.Slice(report.Mounts, func(i, j int) bool {
+		if report.Mounts[i].Path != report.Mounts[j].Path {
+			return report.Mounts[i].Path < report.Mounts[j].Path
+		}
+		return report.Mounts[i].Instance < report.Mounts[j].Instance
+	})
+
+	count := func(items []checkItem) {
+		for _, item := range items {
+			switch item.Status {
+			case checkError:
+				report.Errors++
+			case checkWarning:
+				report.Warnings++
+			}
+		}
+	}
+	count(report.Server)
+	for i := range report.Mounts {
+		mc := &report.Mounts[i]
+		count(mc.Checks)
+		if mc.Status == "" {
+			mc.Status = checkOK
+			for _, item := range mc.Checks {
+				if item.Status == checkError || (item.Status == checkWarning && mc.Status == checkOK) {
+					mc.Status = item.Status
+				}
+			}
+		}
+	}
+	report.OK = report.Errors == 0
+	return report
+}
+
+// checkInstance validates one enabled plugin instance and, with probe,
+// checks its backends
+func checkInstance(mc *mountCheck, mfs *mountablefs.MountableFS, instance config.PluginInstance, probe bool) {
+	var p plugin.ServicePlugin
+	if factory, ok := availablePlugins[mc.Plugin]; ok {
+		p = factory()
+	} else if p = mfs.CreatePlugin(mc.Plugin); p == nil {
+		mc.add("plugin", fmt.Errorf("unknown plugin %s", mc.Plugin))
+		return
+	}
+
+	if instance.Path == "" {
+		mc.add("mount path", fmt.Errorf("path is required"))
+		return
+	}
+
+	cfg, err := instanceConfig(instance.Config, instance.Path)
+	if !mc.add("secrets", err) {
+		return
+	}
+	_, cfg, err = middleware.FromConfig(cfg)
+	if !mc.add("middleware", err) {
+		return
+	}
+	_, cfg, err = mountablefs.TakeInitOptions(cfg)
+	if !mc.add("init options", err) {
+		return
+	}
+	_, cfg, err = mountablefs.TakeTTLPolicy(cfg)
+	if !mc.add("ttl", err) {
+		return
+	}
+	_, cfg, err = mountablefs.TakePathOptions(cfg)
+	if !mc.add("paths", err) {
+		return
+	}
+	_, cfg, err = mountablefs.TakeScheduler(cfg)
+	if !mc.add("scheduler", err) {
+		return
+	}
+	for _, warning := range plugin.DeprecatedParamsIn(p.GetConfigParams(), instance.Config) {
+		mc.Checks = append(mc.Checks, checkItem{Name: "deprecated", Status: checkWarning, Message: warning})
+	}
+	if !mc.add("validate", p.Validate(cfg)) || !probe {
+		return
+	}
+
+	_, isHealthChecker := p.(plugin.HealthChecker)
+	_, isProber := p.(plugin.Prober)
+	if !isHealthChecker && !isProber {
+		mc.Checks = append(mc.Checks, checkItem{Name: "probe", Status: checkSkipped, Message: "no backends to probe"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	done := make(chan []checkItem, 1)
+	go func() {
+		done <- probePlugin(ctx, p, cfg)
+	}()
+	select {
+	case items := <-done:
+		mc.Checks = append(mc.Checks, items...)
+	case <-ctx.Done():
+		mc.add("probe", fmt.Errorf("timed out after %v", probeTimeout))
+	}
+}
+
+// probePlugin initializes a plugin, runs its health check and probes, and
+// shuts it down
+func probePlugin(ctx context.Context, p plugin.ServicePlugin, cfg map[string]interface{}) []checkItem {
+	mc := &mountCheck{}
+	if !mc.add("initialize", p.Initialize(cfg)) {
+		return mc.Checks
+	}
+	defer p.Shutdown()
+
+	if hc, ok := p.(plugin.HealthChecker); ok {
+		mc.add("health check", hc.HealthCheck())
+	}
+	if pr, ok := p.(plugin.Prober); ok {
+		for _, result := range pr.Probe(ctx) {
+			mc.add("probe "+result.Backend, result.Err)
+		}
+	}
+	return mc.Checks
+}
+
+// writeCheckReport prints a report as text or, with format "json", as JSON
+func writeCheckReport(w io.Writer, report *checkReport, format string) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+
+	line := func(indent string, item checkItem) {
+		if item.Message != "" {
+			fmt.Fprintf(w, "%s%-8s %s: %s\n", indent, item.Status, item.Name, item.Message)
+		} else {
+			fmt.Fprintf(w, "%s%-8s %s\n", indent, item.Status, item.Name)
+		}
+	}
+
+	fmt.Fprintf(w, "Config: %s\n\nServer:\n", report.Config)
+	for _, item := range report.Server {
+		line("  ", item)
+	}
+	fmt.Fprintf(w, "\nMounts:\n")
+	for _, mc := range report.Mounts {
+		fmt.Fprintf(w, "  %-8s %s (%s instance '%s')\n", mc.Status, mc.Path, mc.Plugin, mc.Instance)
+		for _, item := range mc.Checks {
+			line("    ", item)
+		}
+	}
+
+	result := "OK"
+	if !report.OK {
+		result = "FAILED"
+	}
+	fmt.Fprintf(w, "\n%s: %d error(s), %d warning(s)\n", result, report.Errors, report.Warnings)
+	return nil
+}
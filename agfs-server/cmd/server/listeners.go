@@ -0,0 +1,179 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/config"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/handlers"
+	pluginconfig "github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// unixPrefix marks the address of a unix socket listener
+const unixPrefix = "unix:"
+
+// listenerSpec is a validated listener configuration, ready to listen
+type listenerSpec struct {
+	network    string // "tcp" or "unix"
+	address    string // host:port or socket path
+	socketMode os.FileMode
+	tlsConfig  *tls.Config // nil for plain HTTP
+	tokens     []string    // Resolved tokens, empty if no authentication
+	acl        *networkACL // Clients that may connect, nil for all
+}
+
+func (s *listenerSpec) String() string {
+	var policy []string
+	if s.tlsConfig != nil {
+		policy = append(policy, "TLS")
+	}
+	if len(s.tokens) > 0 {
+		policy = append(policy, fmt.Sprintf("%d token(s)", len(s.tokens)))
+	}
+	if len(policy) == 0 {
+		policy = append(policy, "no auth")
+	}
+	if s.acl != nil {
+		policy = append(policy, s.acl.String())
+	}
+	return fmt.Sprintf("%s %s (%s)", s.network, s.address, strings.Join(policy, ", "))
+}
+
+// prepareListener validates a listener configuration: it parses the
+// address and network ACL, loads the TLS key pair and resolves token
+// references
+func prepareListener(l config.ListenerConfig) (*listenerSpec, error) {
+	spec := &listenerSpec{network: "tcp", address: l.Address}
+	if strings.HasPrefix(l.Address, unixPrefix) {
+		spec.network, spec.address = "unix", strings.TrimPrefix(l.Address, unixPrefix)
+		if spec.address == "" {
+			return nil, fmt.Errorf("unix socket path is empty")
+		}
+		if l.SocketMode != "" {
+			mode, err := strconv.ParseUint(l.SocketMode, 8, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid socket_mode %q: %w", l.SocketMode, err)
+			}
+			spec.socketMode = os.FileMode(mode)
+		}
+	} else {
+		if _, _, err := net.SplitHostPort(l.Address); err != nil {
+			return nil, err
+		}
+		if l.SocketMode != "" {
+			return nil, fmt.Errorf("socket_mode is only valid for unix sockets")
+		}
+	}
+
+	// Evaluated on connect, before TLS and tokens
+	acl, err := parseACL(l.Allow, l.Deny)
+	if err != nil {
+		return nil, err
+	}
+	if acl != nil && spec.network == "unix" {
+		return nil, fmt.Errorf("allow and deny are only valid for TCP listeners (use socket_mode for unix sockets)")
+	}
+	spec.acl = acl
+
+	if l.TLSCert != "" || l.TLSKey != "" {
+		if l.TLSCert == "" || l.TLSKey == "" {
+			return nil, fmt.Errorf("tls_cert and tls_key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(l.TLSCert, l.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS key pair: %w", err)
+		}
+		spec.tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+
+	for i, t := range l.Tokens {
+		token, err := pluginconfig.ResolveSecret(t)
+		if err != nil {
+			return nil, fmt.Errorf("token %d: %w", i+1, err)
+		}
+		if token == "" {
+			return nil, fmt.Errorf("token %d is empty", i+1)
+		}
+		spec.tokens = append(spec.tokens, token)
+	}
+	return spec, nil
+}
+
+// listen opens the listener; a stale socket file left by a previous run
+// is removed first
+func (s *listenerSpec) listen() (net.Listener, error) {
+	if s.network == "unix" {
+		if info, err := os.Lstat(s.address); err == nil && info.Mode()&os.ModeSocket != 0 {
+			if conn, err := net.Dial("unix", s.address); err == nil {
+				conn.Close()
+				return nil, fmt.Errorf("%s is in use by another server", s.address)
+			}
+			os.Remove(s.address)
+		}
+	}
+
+	ln, err := net.Listen(s.network, s.address)
+	if err != nil {
+		return nil, err
+	}
+	if s.socketMode != 0 {
+		if err := os.Chmod(s.address, s.socketMode); err != nil {
+			ln.Close()
+			return nil, fmt.Errorf("failed to set socket mode: %w", err)
+		}
+	}
+	if s.acl != nil {
+		ln = &aclListener{Listener: ln, acl: s.acl}
+	}
+	if s.tlsConfig != nil {
+		ln = tls.NewListener(ln, s.tlsConfig)
+	}
+	return ln, nil
+}
+
+// startServers starts one HTTP server per listener, each with the auth
+// policy of its listener; errors of running servers are sent to serveErr
+func startServers(listeners []config.ListenerConfig, handler http.Handler) ([]*http.Server, <-chan error, error) {
+	var specs []*listenerSpec
+	for _, l := range listeners {
+		spec, err := prepareListener(l)
+		if err != nil {
+			return nil, nil, fmt.Errorf("listener %s: %w", l.Address, err)
+		}
+		specs = append(specs, spec)
+	}
+
+	var servers []*http.Server
+	serveErr := make(chan error, len(specs))
+	for _, spec := range specs {
+		ln, err := spec.listen()
+		if err != nil {
+			for _, server := range servers {
+				server.Close()
+			}
+			return nil, nil, fmt.Errorf("listener %s: %w", spec.address, err)
+		}
+
+		h := handler
+		if len(spec.tokens) > 0 {
+			h = handlers.TokenAuthMiddleware(handler, spec.tokens)
+		}
+		server := &http.Server{Handler: h}
+		servers = append(servers, server)
+
+		log.Infof("Listening on %s", spec)
+		go func() {
+			if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serveErr <- err
+			}
+		}()
+	}
+	return servers, serveErr, nil
+}
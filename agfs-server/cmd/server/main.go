@@ -1,34 +1,60 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/c4pt0r/agfs/agfs-server/pkg/config"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/handlers"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/middleware"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/mountablefs"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/api"
+	pluginconfig "github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/agfsfs"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/archivefs"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/azblobfs"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/cronfs"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/devfs"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/dockerfs"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/gcsfs"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/gptfs"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/heartbeatfs"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/hellofs"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/httpfs"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/imagefs"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/kafkafs"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/kvfs"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/llmfs"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/localfs"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/lockfs"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/memfs"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/notebookfs"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/observabilityfs"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/promptfs"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/proxyfs"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/queuefs"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/remotefs"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/s3fs"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/secretsfs"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/serverinfofs"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/sqlfs"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/sqlfs2"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/statefs"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/streamfs"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/streamrotatefs"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/timefs"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/transcriptfs"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/vectorfs"
 	log "github.com/sirupsen/logrus"
 )
@@ -45,23 +71,41 @@ type PluginFactory func() plugin.ServicePlugin
 
 // availablePlugins maps plugin names to their factory functions
 var availablePlugins = map[string]PluginFactory{
-	"devfs":          func() plugin.ServicePlugin { return devfs.NewDevFSPlugin() },
-	"serverinfofs":   func() plugin.ServicePlugin { return serverinfofs.NewServerInfoFSPlugin() },
-	"memfs":          func() plugin.ServicePlugin { return memfs.NewMemFSPlugin() },
-	"queuefs":        func() plugin.ServicePlugin { return queuefs.NewQueueFSPlugin() },
-	"kvfs":           func() plugin.ServicePlugin { return kvfs.NewKVFSPlugin() },
-	"hellofs":        func() plugin.ServicePlugin { return hellofs.NewHelloFSPlugin() },
-	"heartbeatfs":    func() plugin.ServicePlugin { return heartbeatfs.NewHeartbeatFSPlugin() },
-	"httpfs":         func() plugin.ServicePlugin { return httpfs.NewHTTPFSPlugin() },
-	"proxyfs":        func() plugin.ServicePlugin { return proxyfs.NewProxyFSPlugin("") },
-	"s3fs":           func() plugin.ServicePlugin { return s3fs.NewS3FSPlugin() },
-	"streamfs":       func() plugin.ServicePlugin { return streamfs.NewStreamFSPlugin() },
-	"streamrotatefs": func() plugin.ServicePlugin { return streamrotatefs.NewStreamRotateFSPlugin() },
-	"sqlfs":          func() plugin.ServicePlugin { return sqlfs.NewSQLFSPlugin() },
-	"sqlfs2":         func() plugin.ServicePlugin { return sqlfs2.NewSQLFS2Plugin() },
-	"localfs":        func() plugin.ServicePlugin { return localfs.NewLocalFSPlugin() },
-	"gptfs":          func() plugin.ServicePlugin { return gptfs.NewGptfs() },
-	"vectorfs":       func() plugin.ServicePlugin { return vectorfs.NewVectorFSPlugin() },
+	"devfs":           func() plugin.ServicePlugin { return devfs.NewDevFSPlugin() },
+	"serverinfofs":    func() plugin.ServicePlugin { return serverinfofs.NewServerInfoFSPlugin() },
+	"timefs":          func() plugin.ServicePlugin { return timefs.NewTimeFSPlugin() },
+	"memfs":           func() plugin.ServicePlugin { return memfs.NewMemFSPlugin() },
+	"queuefs":         func() plugin.ServicePlugin { return queuefs.NewQueueFSPlugin() },
+	"kvfs":            func() plugin.ServicePlugin { return kvfs.NewKVFSPlugin() },
+	"kafkafs":         func() plugin.ServicePlugin { return kafkafs.NewKafkaFSPlugin() },
+	"cronfs":          func() plugin.ServicePlugin { return cronfs.NewCronFSPlugin() },
+	"hellofs":         func() plugin.ServicePlugin { return hellofs.NewHelloFSPlugin() },
+	"heartbeatfs":     func() plugin.ServicePlugin { return heartbeatfs.NewHeartbeatFSPlugin() },
+	"httpfs":          func() plugin.ServicePlugin { return httpfs.NewHTTPFSPlugin() },
+	"proxyfs":         func() plugin.ServicePlugin { return proxyfs.NewProxyFSPlugin("") },
+	"agfsfs":          func() plugin.ServicePlugin { return agfsfs.NewAGFSFSPlugin() },
+	"s3fs":            func() plugin.ServicePlugin { return s3fs.NewS3FSPlugin() },
+	"gcsfs":           func() plugin.ServicePlugin { return gcsfs.NewGCSFSPlugin() },
+	"azblobfs":        func() plugin.ServicePlugin { return azblobfs.NewAzBlobFSPlugin() },
+	"streamfs":        func() plugin.ServicePlugin { return streamfs.NewStreamFSPlugin() },
+	"streamrotatefs":  func() plugin.ServicePlugin { return streamrotatefs.NewStreamRotateFSPlugin() },
+	"sqlfs":           func() plugin.ServicePlugin { return sqlfs.NewSQLFSPlugin() },
+	"sqlfs2":          func() plugin.ServicePlugin { return sqlfs2.NewSQLFS2Plugin() },
+	"localfs":         func() plugin.ServicePlugin { return localfs.NewLocalFSPlugin() },
+	"remotefs":        func() plugin.ServicePlugin { return remotefs.NewRemoteFSPlugin() },
+	"gptfs":           func() plugin.ServicePlugin { return gptfs.NewGptfs() },
+	"llmfs":           func() plugin.ServicePlugin { return llmfs.NewLLMFSPlugin() },
+	"promptfs":        func() plugin.ServicePlugin { return promptfs.NewPromptFSPlugin() },
+	"secretsfs":       func() plugin.ServicePlugin { return secretsfs.NewSecretsFSPlugin() },
+	"archivefs":       func() plugin.ServicePlugin { return archivefs.NewArchiveFSPlugin() },
+	"dockerfs":        func() plugin.ServicePlugin { return dockerfs.NewDockerFSPlugin() },
+	"notebookfs":      func() plugin.ServicePlugin { return notebookfs.NewNotebookFSPlugin() },
+	"observabilityfs": func() plugin.ServicePlugin { return observabilityfs.NewObservabilityFSPlugin() },
+	"vectorfs":        func() plugin.ServicePlugin { return vectorfs.NewVectorFSPlugin() },
+	"lockfs":          func() plugin.ServicePlugin { return lockfs.NewLockFSPlugin() },
+	"statefs":         func() plugin.ServicePlugin { return statefs.NewStateFSPlugin() },
+	"transcriptfs":    func() plugin.ServicePlugin { return transcriptfs.NewTranscriptFSPlugin() },
+	"imagefs":         func() plugin.ServicePlugin { return imagefs.NewImageFSPlugin() },
 }
 
 const sampleConfig = `# AGFS Server Configuration File
@@ -70,6 +114,8 @@ const sampleConfig = `# AGFS Server Configuration File
 server:
   address: ":8080"          # Server listen address
   log_level: "info"         # Log level: debug, info, warn, error
+  health_check_interval: 30 # Plugin health check interval in seconds (negative disables)
+  shutdown_timeout: 30      # Seconds to drain requests and shut plugins down on SIGTERM
 
 # Plugin configurations
 plugins:
@@ -165,6 +211,9 @@ func main() {
 	addr := flag.String("addr", "", "Server listen address (will override addr in config file)")
 	printSampleConfig := flag.Bool("print-sample-config", false, "Print a sample configuration file and exit")
 	version := flag.Bool("version", false, "Print version information and exit")
+	checkCfg := flag.Bool("check-config", false, "Validate the configuration file and every enabled plugin, print a report and exit")
+	probe := flag.Bool("probe", false, "With --check-config, also check that plugin backends (databases, buckets, APIs) are reachable")
+	checkFormat := flag.String("check-format", "text", "Format of the --check-config report: text or json")
 	flag.Parse()
 
 	// Handle --version
@@ -187,6 +236,20 @@ func main() {
 		log.Fatalf("Failed to load config file: %v", err)
 	}
 
+	// Handle --check-config
+	if *checkCfg {
+		// Keep plugin logs from interleaving with the report
+		log.SetLevel(log.WarnLevel)
+		report := checkConfig(*configFile, cfg, *probe)
+		if err := writeCheckReport(os.Stdout, report, *checkFormat); err != nil {
+			log.Fatal(err)
+		}
+		if !report.OK {
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Configure logrus
 	logLevel := log.InfoLevel
 	if cfg.Server.LogLevel != "" {
@@ -204,15 +267,6 @@ func main() {
 	log.SetReportCaller(true)
 	log.SetLevel(logLevel)
 
-	// Determine server address
-	serverAddr := cfg.Server.Address
-	if *addr != "" {
-		serverAddr = *addr // Command line override
-	}
-	if serverAddr == "" {
-		serverAddr = ":8080" // Default
-	}
-
 	// Create WASM instance pool configuration from config
 	wasmConfig := cfg.GetWASMConfig()
 	poolConfig := api.PoolConfig{
@@ -225,6 +279,12 @@ func main() {
 
 	// Create mountable file system
 	mfs := mountablefs.NewMountableFS(poolConfig)
+	mfs.SetWASMSandbox(api.SandboxConfig{
+		FSRoot:         wasmConfig.HostFSRoot,
+		DisableHTTP:    wasmConfig.DisableHTTP,
+		HTTPAllowHosts: wasmConfig.HTTPAllowHosts,
+		MemoryLimitMB:  wasmConfig.MemoryLimitMB,
+	})
 
 	// Create traffic monitor early so it can be injected into plugins during mounting
 	trafficMonitor := handlers.NewTrafficMonitor()
@@ -238,100 +298,8 @@ func main() {
 		})
 	}
 
-	// mountPlugin initializes and mounts a plugin asynchronously
-	mountPlugin := func(pluginName, instanceName, mountPath string, pluginConfig map[string]interface{}) {
-		// Get plugin factory (try built-in first, then external)
-		factory, ok := availablePlugins[pluginName]
-		var p plugin.ServicePlugin
-
-		if !ok {
-			// Try to get external plugin from mfs
-			p = mfs.CreatePlugin(pluginName)
-			if p == nil {
-				log.Warnf("Unknown plugin: %s, skipping instance '%s'", pluginName, instanceName)
-				return
-			}
-		} else {
-			// Create plugin instance from built-in factory
-			p = factory()
-		}
-
-		// Special handling for httpfs: inject rootFS reference
-		if pluginName == "httpfs" {
-			if httpfsPlugin, ok := p.(*httpfs.HTTPFSPlugin); ok {
-				httpfsPlugin.SetRootFS(mfs)
-			}
-		}
-
-		// Special handling for serverinfofs: inject traffic monitor
-		if pluginName == "serverinfofs" {
-			if serverInfoPlugin, ok := p.(*serverinfofs.ServerInfoFSPlugin); ok {
-				serverInfoPlugin.SetTrafficMonitor(trafficMonitor)
-			}
-		}
-
-		// Mount asynchronously
-		go func() {
-			// Inject mount_path into config
-			configWithPath := make(map[string]interface{})
-			for k, v := range pluginConfig {
-				configWithPath[k] = v
-			}
-			configWithPath["mount_path"] = mountPath
-
-			// Validate plugin configuration
-			if err := p.Validate(configWithPath); err != nil {
-				log.Errorf("Failed to validate %s instance '%s': %v", pluginName, instanceName, err)
-				return
-			}
-
-			// Initialize plugin
-			if err := p.Initialize(configWithPath); err != nil {
-				log.Errorf("Failed to initialize %s instance '%s': %v", pluginName, instanceName, err)
-				return
-			}
-
-			// Mount plugin
-			if err := mfs.Mount(mountPath, p); err != nil {
-				log.Errorf("Failed to mount %s instance '%s' at %s: %v", pluginName, instanceName, mountPath, err)
-				return
-			}
-
-			// Log success
-			log.Infof("%s instance '%s' mounted at %s", pluginName, instanceName, mountPath)
-		}()
-	}
-
 	// Load external plugins if enabled
-	if cfg.ExternalPlugins.Enabled {
-		log.Info("Loading external plugins...")
-
-		// Auto-load from plugin directory
-		if cfg.ExternalPlugins.AutoLoad && cfg.ExternalPlugins.PluginDir != "" {
-			log.Infof("Auto-loading plugins from: %s", cfg.ExternalPlugins.PluginDir)
-			loaded, errors := mfs.LoadExternalPluginsFromDirectory(cfg.ExternalPlugins.PluginDir)
-			if len(errors) > 0 {
-				log.Warnf("Encountered %d error(s) while loading plugins:", len(errors))
-				for _, err := range errors {
-					log.Warnf("- %v", err)
-				}
-			}
-			if len(loaded) > 0 {
-				log.Infof("Auto-loaded %d plugin(s)", len(loaded))
-			}
-		}
-
-		// Load specific plugin paths
-		for _, pluginPath := range cfg.ExternalPlugins.PluginPaths {
-			log.Infof("Loading plugin: %s", pluginPath)
-			p, err := mfs.LoadExternalPlugin(pluginPath)
-			if err != nil {
-				log.Errorf("Failed to load plugin %s: %v", pluginPath, err)
-			} else {
-				log.Infof("Loaded plugin: %s", p.Name())
-			}
-		}
-	}
+	loadExternalPlugins(mfs, cfg)
 
 	// Mount DevFS by default (always enabled)
 	log.Info("Mounting default DevFS at /dev...")
@@ -352,47 +320,290 @@ func main() {
 	// Mount all enabled plugins
 	log.Info("Mounting plugin filesytems...")
 	for pluginName, pluginCfg := range cfg.Plugins {
-		// Normalize to instance array (convert single instance to array of one)
-		instances := pluginCfg.Instances
-		if len(instances) == 0 {
-			// Single instance mode: treat as array with one instance
-			instances = []config.PluginInstance{
-				{
-					Name:    pluginName, // Use plugin name as instance name
-					Enabled: pluginCfg.Enabled,
-					Path:    pluginCfg.Path,
-					Config:  pluginCfg.Config,
-				},
-			}
-		}
-
 		// Mount all instances
-		for _, instance := range instances {
+		for _, instance := range pluginInstances(pluginName, pluginCfg) {
 			if !instance.Enabled {
 				log.Infof("%s instance '%s' is disabled, skipping", pluginName, instance.Name)
 				continue
 			}
 
-			mountPlugin(pluginName, instance.Name, instance.Path, instance.Config)
+			mountPlugin(mfs, trafficMonitor, pluginName, instance.Name, instance.Path, instance.Config)
 		}
 	}
 
+	// Poll plugin health checks; unhealthy mounts fail fast with 503
+	mfs.StartHealthChecks(cfg.GetHealthCheckInterval())
+
+	// Expire the files of mounts with a TTL policy
+	mfs.StartTTLSweeper(cfg.GetTTLSweepInterval())
+
+	// Close file handles whose lease was neither used nor renewed in time
+	if lease := cfg.GetHandleLease(); lease > 0 {
+		mfs.HandleLease = lease
+		mfs.StartHandleReaper(lease / 4)
+	} else {
+		mfs.HandleLease = -1
+	}
+
+	// Log slow operations with their backend breakdown
+	mfs.SlowOpThreshold = cfg.GetSlowOpThreshold()
+
+	// Reject oversized requests before they reach plugins
+	mfs.Limits = mountablefs.Limits{
+		MaxReadSize:   cfg.Server.MaxReadSize,
+		MaxWriteSize:  cfg.Server.MaxWriteSize,
+		MaxPathLength: cfg.Server.MaxPathLength,
+	}
+
 	// Create handlers
 	handler := handlers.NewHandler(mfs, trafficMonitor)
 	handler.SetVersionInfo(Version, GitCommit, BuildTime)
+	auditLog := handlers.NewAuditLog(0)
 	pluginHandler := handlers.NewPluginHandler(mfs)
+	pluginHandler.SetAuditLog(auditLog)
+	adminHandler := handlers.NewAdminHandler(mfs, trafficMonitor, auditLog)
 
 	// Setup routes
 	mux := http.NewServeMux()
 	handler.SetupRoutes(mux)
 	pluginHandler.SetupRoutes(mux)
+	adminHandler.SetupRoutes(mux)
+
+	// Queue requests on mounts with a max_concurrency by priority class
+	root := handlers.PriorityMiddleware(mfs, mux)
+
+	// Deduplicate retried mutations that carry an Idempotency-Key
+	if window := cfg.GetIdempotencyWindow(); window > 0 {
+		idempotency := handlers.NewIdempotencyCache(window)
+		idempotency.MaxBodySize = cfg.Server.MaxWriteSize
+		root = idempotency.Middleware(root)
+	}
 
 	// Wrap with logging middleware
-	loggedMux := handlers.LoggingMiddleware(mux)
-	// Start server
-	log.Infof("Starting AGFS server on %s", serverAddr)
+	loggedMux := handlers.LoggingMiddleware(root)
+	// Start server, on every listener (the -addr flag overrides them)
+	log.Info("Starting AGFS server")
+	servers, serveErr, err := startServers(cfg.GetListeners(*addr), loggedMux)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
 
-	if err := http.ListenAndServe(serverAddr, loggedMux); err != nil {
+	select {
+	case err := <-serveErr:
 		log.Fatal(err)
+	case sig := <-signals:
+		// A second signal skips the drain
+		go func() {
+			<-signals
+			log.Warn("Received second signal, exiting immediately")
+			os.Exit(1)
+		}()
+		shutdown(sig, servers, mfs, cfg.GetShutdownTimeout())
+	}
+}
+
+// loadExternalPlugins loads the external plugins of the configuration, if enabled
+func loadExternalPlugins(mfs *mountablefs.MountableFS, cfg *config.Config) {
+	if !cfg.ExternalPlugins.Enabled {
+		return
+	}
+	log.Info("Loading external plugins...")
+
+	// Auto-load from plugin directory
+	if cfg.ExternalPlugins.AutoLoad && cfg.ExternalPlugins.PluginDir != "" {
+		log.Infof("Auto-loading plugins from: %s", cfg.ExternalPlugins.PluginDir)
+		loaded, errors := mfs.LoadExternalPluginsFromDirectory(cfg.ExternalPlugins.PluginDir)
+		if len(errors) > 0 {
+			log.Warnf("Encountered %d error(s) while loading plugins:", len(errors))
+			for _, err := range errors {
+				log.Warnf("- %v", err)
+			}
+		}
+		if len(loaded) > 0 {
+			log.Infof("Auto-loaded %d plugin(s)", len(loaded))
+		}
+	}
+
+	// Load the plugins dropped into the plugin directory while running
+	mfs.StartPluginWatcher(cfg.ExternalPlugins.PluginDir, cfg.GetPluginWatchInterval())
+
+	// Load specific plugin paths
+	for _, pluginPath := range cfg.ExternalPlugins.PluginPaths {
+		log.Infof("Loading plugin: %s", pluginPath)
+		p, err := mfs.LoadExternalPlugin(pluginPath)
+		if err != nil {
+			log.Errorf("Failed to load plugin %s: %v", pluginPath, err)
+		} else {
+			log.Infof("Loaded plugin: %s", p.Name())
+		}
+	}
+}
+
+// pluginInstances returns the instances of a configured plugin, treating the
+// single instance form as an array of one
+func pluginInstances(pluginName string, pluginCfg config.PluginConfig) []config.PluginInstance {
+	if len(pluginCfg.Instances) > 0 {
+		return pluginCfg.Instances
+	}
+	return []config.PluginInstance{
+		{
+			Name:    pluginName, // Use plugin name as instance name
+			Enabled: pluginCfg.Enabled,
+			Path:    pluginCfg.Path,
+			Config:  pluginCfg.Config,
+		},
+	}
+}
+
+// mountPlugin mounts a plugin instance of the config file; it is initialized
+// in the background, with retries, or on first access if the instance is lazy
+func mountPlugin(mfs *mountablefs.MountableFS, trafficMonitor *handlers.TrafficMonitor, pluginName, instanceName, mountPath string, pluginConfig map[string]interface{}) {
+	// Get plugin factory (try built-in first, then external)
+	factory, ok := availablePlugins[pluginName]
+	if !ok {
+		factory = func() plugin.ServicePlugin { return mfs.CreatePlugin(pluginName) }
+	}
+	p := factory()
+	if p == nil {
+		log.Warnf("Unknown plugin: %s, skipping instance '%s'", pluginName, instanceName)
+		return
+	}
+
+	// Special handling for httpfs: inject rootFS reference
+	if pluginName == "httpfs" {
+		if httpfsPlugin, ok := p.(*httpfs.HTTPFSPlugin); ok {
+			httpfsPlugin.SetRootFS(mfs)
+		}
+	}
+
+	// Special handling for serverinfofs: inject traffic monitor
+	if pluginName == "serverinfofs" {
+		if serverInfoPlugin, ok := p.(*serverinfofs.ServerInfoFSPlugin); ok {
+			serverInfoPlugin.SetTrafficMonitor(trafficMonitor)
+			serverInfoPlugin.SetMountHealthProvider(mfs)
+		}
+	}
+
+	configWithPath, err := instanceConfig(pluginConfig, mountPath)
+	if err != nil {
+		log.Errorf("Failed to resolve config of %s instance '%s': %v", pluginName, instanceName, err)
+		return
+	}
+	middlewares, configWithPath, err := middleware.FromConfig(configWithPath)
+	if err != nil {
+		log.Errorf("Invalid middleware config of %s instance '%s': %v", pluginName, instanceName, err)
+		return
+	}
+	initOpts, configWithPath, err := mountablefs.TakeInitOptions(configWithPath)
+	if err != nil {
+		log.Errorf("Invalid init options of %s instance '%s': %v", pluginName, instanceName, err)
+		return
+	}
+	ttlPolicy, configWithPath, err := mountablefs.TakeTTLPolicy(configWithPath)
+	if err != nil {
+		log.Errorf("Invalid TTL policy of %s instance '%s': %v", pluginName, instanceName, err)
+		return
+	}
+	pathOpts, configWithPath, err := mountablefs.TakePathOptions(configWithPath)
+	if err != nil {
+		log.Errorf("Invalid path options of %s instance '%s': %v", pluginName, instanceName, err)
+		return
+	}
+	scheduler, configWithPath, err := mountablefs.TakeScheduler(configWithPath)
+	if err != nil {
+		log.Errorf("Invalid concurrency limit of %s instance '%s': %v", pluginName, instanceName, err)
+		return
+	}
+	// Take out the read replicas last, so that they get none of the keys above
+	replicaConfigs, configWithPath, err := mountablefs.TakeReplicaConfigs(configWithPath)
+	if err != nil {
+		log.Errorf("Invalid read replicas of %s instance '%s': %v", pluginName, instanceName, err)
+		return
+	}
+
+	for _, warning := range plugin.DeprecatedParamsIn(p.GetConfigParams(), pluginConfig) {
+		log.Warnf("%s instance '%s': %s", pluginName, instanceName, warning)
+	}
+
+	// Validate plugin configuration
+	if err := p.Validate(configWithPath); err != nil {
+		log.Errorf("Failed to validate %s instance '%s': %v", pluginName, instanceName, err)
+		return
+	}
+
+	initOpts.Replicas, err = mountablefs.NewReplicas(mountablefs.PluginFactory(factory), mountPath, replicaConfigs)
+	if err != nil {
+		log.Errorf("Invalid read replicas of %s instance '%s': %v", pluginName, instanceName, err)
+		return
+	}
+
+	// Mount plugin before initializing it, so that an unreachable backend
+	// only makes its own mount unavailable until it can be reached
+	initialize := func() error { return p.Initialize(configWithPath) }
+	if err := mfs.MountDeferred(mountPath, p, initialize, initOpts, middlewares...); err != nil {
+		log.Errorf("Failed to mount %s instance '%s' at %s: %v", pluginName, instanceName, mountPath, err)
+		return
+	}
+	mfs.SetTTLPolicy(mountPath, ttlPolicy)
+	mfs.SetPathOptions(mountPath, pathOpts)
+	mfs.SetScheduler(mountPath, scheduler)
+
+	if initOpts.Lazy {
+		log.Infof("%s instance '%s' mounted at %s (initialized on first access)", pluginName, instanceName, mountPath)
+	} else {
+		log.Infof("%s instance '%s' mounted at %s", pluginName, instanceName, mountPath)
+	}
+}
+
+// instanceConfig resolves the secret references (env:, file:, vault:) of a
+// plugin instance's config and injects its mount_path
+func instanceConfig(pluginConfig map[string]interface{}, mountPath string) (map[string]interface{}, error) {
+	resolved, err := pluginconfig.ResolveSecrets(pluginConfig)
+	if err != nil {
+		return nil, err
+	}
+	configWithPath := make(map[string]interface{})
+	for k, v := range resolved {
+		configWithPath[k] = v
+	}
+	configWithPath["mount_path"] = mountPath
+	return configWithPath, nil
+}
+
+// shutdown stops accepting connections, waits up to timeout for in-flight
+// requests to complete, then shuts plugins down with another timeout
+func shutdown(sig os.Signal, servers []*http.Server, mfs *mountablefs.MountableFS, timeout time.Duration) {
+	log.Infof("Received %v, draining in-flight requests (timeout: %v)", sig, timeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	var drained atomic.Bool
+	drained.Store(true)
+	for _, server := range servers {
+		wg.Add(1)
+		go func(server *http.Server) {
+			defer wg.Done()
+			if err := server.Shutdown(ctx); err != nil {
+				drained.Store(false)
+				server.Close()
+			}
+		}(server)
+	}
+	wg.Wait()
+	if drained.Load() {
+		log.Info("All in-flight requests completed")
+	} else {
+		log.Warnf("In-flight requests not done after %v, closed their connections", timeout)
+	}
+
+	pluginCtx, pluginCancel := context.WithTimeout(context.Background(), timeout)
+	defer pluginCancel()
+	if err := mfs.Shutdown(pluginCtx); err != nil {
+		log.Warnf("Plugins not shut down after %v: %v", timeout, err)
 	}
+	log.Info("AGFS server stopped")
 }
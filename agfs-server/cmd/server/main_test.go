@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/config"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/mountablefs"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/api"
+	pluginconfig "github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/memfs"
+	"gopkg.in/yaml.v3"
+)
+
+// routedPlugin is a memfs whose listings can be served by replicas; each
+// instance creates a directory named after its instance key
+type routedPlugin struct {
+	*memfs.MemFSPlugin
+}
+
+func (p *routedPlugin) Validate(cfg map[string]interface{}) error {
+	return pluginconfig.ValidateOnlyKnownKeys(cfg, []string{"instance", "mount_path"})
+}
+
+func (p *routedPlugin) Initialize(cfg map[string]interface{}) error {
+	if err := p.MemFSPlugin.Initialize(map[string]interface{}{}); err != nil {
+		return err
+	}
+	return p.GetFileSystem().Mkdir("/"+cfg["instance"].(string), 0755)
+}
+
+func (p *routedPlugin) IsRead(op, path string) bool {
+	return op == "readdir"
+}
+
+func TestMountPluginReplicas(t *testing.T) {
+	availablePlugins["routedtest"] = func() plugin.ServicePlugin {
+		return &routedPlugin{MemFSPlugin: memfs.NewMemFSPlugin()}
+	}
+	defer delete(availablePlugins, "routedtest")
+
+	var cfg config.Config
+	err := yaml.Unmarshal([]byte(`
+plugins:
+  routedtest:
+    enabled: true
+    path: /db
+    config:
+      instance: primary
+      lazy: true
+      replicas:
+        - instance: replica1
+        - instance: replica2
+`), &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mfs := mountablefs.NewMountableFS(api.PoolConfig{})
+	for name, pluginCfg := range cfg.Plugins {
+		for _, instance := range pluginInstances(name, pluginCfg) {
+			mountPlugin(mfs, nil, name, instance.Name, instance.Path, instance.Config)
+		}
+	}
+	defer mfs.Unmount("/db")
+
+	// Listings alternate between the replicas, once the mount is initialized
+	seen := map[string]int{}
+	for i := 0; i < 4; i++ {
+		infos, err := mfs.ReadDir("/db")
+		if err != nil {
+			t.Fatalf("readdir of the mount: %v", err)
+		}
+		for _, info := range infos {
+			if info.IsDir {
+				seen[info.Name]++
+			}
+		}
+	}
+	if seen["replica1"] != 2 || seen["replica2"] != 2 {
+		t.Errorf("listings served by %v", seen)
+	}
+	if _, err := mfs.Stat("/db/primary"); err != nil {
+		t.Errorf("stat not served by the primary: %v", err)
+	}
+
+	report := mfs.GetMountHealth().([]mountablefs.MountHealth)
+	if len(report) != 1 || len(report[0].Replicas) != 2 {
+		t.Errorf("health report = %+v", report)
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -16,8 +17,32 @@ type Config struct {
 
 // ServerConfig contains server-level configuration
 type ServerConfig struct {
-	Address  string `yaml:"address"`
-	LogLevel string `yaml:"log_level"`
+	Address             string `yaml:"address"`
+	LogLevel            string `yaml:"log_level"`
+	HealthCheckInterval int    `yaml:"health_check_interval"` // Plugin health check interval in seconds (default: 30, negative = disabled)
+	ShutdownTimeout     int    `yaml:"shutdown_timeout"`      // Seconds to drain in-flight requests and to shut plugins down on SIGTERM (default: 30)
+	SlowOpThreshold     int    `yaml:"slow_op_threshold"`     // Log file system operations slower than this, in milliseconds (0 = disabled)
+	MaxReadSize         int64  `yaml:"max_read_size"`         // Bytes one read may return (0 = unlimited)
+	MaxWriteSize        int64  `yaml:"max_write_size"`        // Bytes one write may carry (0 = unlimited)
+	MaxPathLength       int    `yaml:"max_path_length"`       // Bytes in a path (0 = unlimited)
+	IdempotencyWindow   int    `yaml:"idempotency_window"`    // Seconds the responses of requests with an Idempotency-Key are remembered (default: 600, negative = disabled)
+	TTLSweepInterval    int    `yaml:"ttl_sweep_interval"`    // Seconds between sweeps of the expired files of mounts with a ttl (default: 60, negative = disabled)
+	HandleLease         int    `yaml:"handle_lease"`          // Seconds a file handle stays open without being used or renewed (default: 60, negative = never expire)
+
+	// Listeners, each with its own transport and auth policy; if empty, the
+	// server listens on Address without TLS or authentication
+	Listeners []ListenerConfig `yaml:"listeners"`
+}
+
+// ListenerConfig configures one address the server listens on
+type ListenerConfig struct {
+	Address    string   `yaml:"address"`     // host:port, or unix:/path/to/socket
+	SocketMode string   `yaml:"socket_mode"` // Permissions of a unix socket, e.g. "0660" (default: umask)
+	TLSCert    string   `yaml:"tls_cert"`    // Certificate file; serves HTTPS if set together with TLSKey
+	TLSKey     string   `yaml:"tls_key"`     // Private key file
+	Tokens     []string `yaml:"tokens"`      // If set, requests must send one as a bearer token (env:/file:/vault: references allowed)
+	Allow      []string `yaml:"allow"`       // If set, only clients in these CIDRs (or IPs) may connect
+	Deny       []string `yaml:"deny"`        // Clients in these CIDRs (or IPs) may not connect, even if allowed
 }
 
 // ExternalPluginsConfig contains configuration for external plugins
@@ -27,6 +52,7 @@ type ExternalPluginsConfig struct {
 	AutoLoad      bool              `yaml:"auto_load"`
 	PluginPaths   []string          `yaml:"plugin_paths"`
 	WASIMountPath string            `yaml:"wasi_mount_path"` // Directory to mount for WASI filesystem access
+	WatchInterval int               `yaml:"watch_interval"`  // Seconds between scans of plugin_dir for new plugins (0 = only load at startup)
 	WASM          WASMPluginConfig  `yaml:"wasm"`            // WASM plugin specific configuration
 }
 
@@ -37,6 +63,12 @@ type WASMPluginConfig struct {
 	InstanceMaxRequests  int `yaml:"instance_max_requests"`   // Maximum requests per instance (0 = unlimited)
 	HealthCheckInterval  int `yaml:"health_check_interval"`   // Health check interval in seconds (0 = disabled)
 	EnablePoolStatistics bool `yaml:"enable_pool_statistics"` // Enable pool statistics collection
+
+	// Sandbox of the host functions, for plugins not trusted with the whole server
+	HostFSRoot     string   `yaml:"host_fs_root"`     // Subtree of the AGFS namespace host_fs_* calls are confined to (default: all of it)
+	DisableHTTP    bool     `yaml:"disable_http"`     // Deny host_http_request
+	HTTPAllowHosts []string `yaml:"http_allow_hosts"` // Hosts host_http_request may reach, "*.example.com" for subdomains (default: any)
+	MemoryLimitMB  int      `yaml:"memory_limit_mb"`  // Memory limit of each instance in MiB (0 = 4 GiB)
 }
 
 // PluginConfig can be either a single plugin or an array of plugin instances
@@ -114,3 +146,92 @@ func (c *Config) GetWASMConfig() WASMPluginConfig {
 
 	return cfg
 }
+
+// GetPluginWatchInterval returns how often plugin_dir is scanned for new
+// plugins, 0 if it is only loaded at startup
+func (c *Config) GetPluginWatchInterval() time.Duration {
+	if !c.ExternalPlugins.Enabled || c.ExternalPlugins.WatchInterval <= 0 {
+		return 0
+	}
+	return time.Duration(c.ExternalPlugins.WatchInterval) * time.Second
+}
+
+// GetHealthCheckInterval returns how often plugin health checks run, 0 if disabled
+func (c *Config) GetHealthCheckInterval() time.Duration {
+	switch {
+	case c.Server.HealthCheckInterval < 0:
+		return 0
+	case c.Server.HealthCheckInterval == 0:
+		return 30 * time.Second // Default: 30 seconds
+	}
+	return time.Duration(c.Server.HealthCheckInterval) * time.Second
+}
+
+// GetShutdownTimeout returns how long shutdown waits for in-flight requests to
+// complete, and then for plugins to shut down, before giving up on them
+func (c *Config) GetShutdownTimeout() time.Duration {
+	if c.Server.ShutdownTimeout <= 0 {
+		return 30 * time.Second // Default: 30 seconds
+	}
+	return time.Duration(c.Server.ShutdownTimeout) * time.Second
+}
+
+// GetSlowOpThreshold returns the duration above which file system operations
+// are logged as slow, 0 if disabled
+func (c *Config) GetSlowOpThreshold() time.Duration {
+	if c.Server.SlowOpThreshold <= 0 {
+		return 0
+	}
+	return time.Duration(c.Server.SlowOpThreshold) * time.Millisecond
+}
+
+// GetIdempotencyWindow returns how long the responses of mutating requests
+// sent with an Idempotency-Key are remembered, 0 if disabled
+func (c *Config) GetIdempotencyWindow() time.Duration {
+	switch {
+	case c.Server.IdempotencyWindow < 0:
+		return 0
+	case c.Server.IdempotencyWindow == 0:
+		return 10 * time.Minute // Default: 10 minutes
+	}
+	return time.Duration(c.Server.IdempotencyWindow) * time.Second
+}
+
+// GetTTLSweepInterval returns how often the expired files of mounts with a
+// TTL policy are swept, 0 if disabled
+func (c *Config) GetTTLSweepInterval() time.Duration {
+	switch {
+	case c.Server.TTLSweepInterval < 0:
+		return 0
+	case c.Server.TTLSweepInterval == 0:
+		return time.Minute // Default: 60 seconds
+	}
+	return time.Duration(c.Server.TTLSweepInterval) * time.Second
+}
+
+// GetHandleLease returns how long file handles stay open without being used
+// or renewed, 0 if they never expire
+func (c *Config) GetHandleLease() time.Duration {
+	switch {
+	case c.Server.HandleLease < 0:
+		return 0
+	case c.Server.HandleLease == 0:
+		return 60 * time.Second // Default: 60 seconds
+	}
+	return time.Duration(c.Server.HandleLease) * time.Second
+}
+
+// GetListeners returns the listeners to serve on; addrOverride (the -addr
+// flag), if set, replaces them with a single plain listener
+func (c *Config) GetListeners(addrOverride string) []ListenerConfig {
+	if addrOverride != "" {
+		return []ListenerConfig{{Address: addrOverride}}
+	}
+	if len(c.Server.Listeners) > 0 {
+		return c.Server.Listeners
+	}
+	if c.Server.Address != "" {
+		return []ListenerConfig{{Address: c.Server.Address}}
+	}
+	return []ListenerConfig{{Address: ":8080"}} // Default
+}
@@ -1,5 +1,7 @@
 package filesystem
 
+import "time"
+
 // Capabilities describes the features supported by a file system
 type Capabilities struct {
 	// Basic capabilities
@@ -56,6 +58,22 @@ type Syncer interface {
 	Sync(path string) error
 }
 
+// AsOfReader is implemented by file systems that keep previous versions of
+// their files, such as versioned buckets, so that clients can inspect the
+// state of a path at a past time
+// Each method answers as its counterpart would have at asOf: files created
+// later, or deleted by then, are not found.
+type AsOfReader interface {
+	// ReadAsOf reads a file as it was at asOf
+	ReadAsOf(path string, asOf time.Time, offset, size int64) ([]byte, error)
+
+	// StatAsOf returns the info of a file or directory as it was at asOf
+	StatAsOf(path string, asOf time.Time) (*FileInfo, error)
+
+	// ReadDirAsOf lists the files a directory had at asOf
+	ReadDirAsOf(path string, asOf time.Time) ([]FileInfo, error)
+}
+
 // === Special Semantics Interfaces ===
 
 // AppendOnlyFS marks file systems where certain paths only support append operations
@@ -26,6 +26,24 @@ var (
 
 	// ErrNotSupported indicates the operation is not supported by this filesystem
 	ErrNotSupported = errors.New("operation not supported")
+
+	// ErrNoSpace indicates the filesystem or device has no room for the data (ENOSPC)
+	ErrNoSpace = errors.New("no space left on device")
+
+	// ErrUnavailable indicates the filesystem is temporarily unable to serve
+	// requests, e.g. its backend is down; retrying later may succeed (EAGAIN)
+	ErrUnavailable = errors.New("resource temporarily unavailable")
+
+	// ErrQuotaExceeded indicates a request exceeds a size limit of the server
+	ErrQuotaExceeded = errors.New("quota exceeded")
+
+	// ErrConflict indicates a conditional operation found the file at another
+	// version than the expected one
+	ErrConflict = errors.New("version conflict")
+
+	// ErrRevoked indicates a file handle was closed by an administrator, e.g.
+	// before maintenance of its backend; the file can be opened again
+	ErrRevoked = errors.New("handle revoked")
 )
 
 // NotFoundError represents a file or directory not found error with context
@@ -131,6 +149,77 @@ func (e *NotSupportedError) Is(target error) bool {
 	return target == ErrNotSupported
 }
 
+// NoSpaceError represents a write that failed because no space is left
+type NoSpaceError struct {
+	Path string
+	Op   string
+}
+
+func (e *NoSpaceError) Error() string {
+	if e.Op != "" {
+		return fmt.Sprintf("%s: %s: no space left on device", e.Op, e.Path)
+	}
+	return fmt.Sprintf("%s: no space left on device", e.Path)
+}
+
+func (e *NoSpaceError) Is(target error) bool {
+	return target == ErrNoSpace
+}
+
+// UnavailableError represents an operation on a mount that is unhealthy
+type UnavailableError struct {
+	Path   string
+	Reason string // Why the mount is unavailable (e.g., the failed health check)
+}
+
+func (e *UnavailableError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("%s: resource temporarily unavailable (%s)", e.Path, e.Reason)
+	}
+	return fmt.Sprintf("%s: resource temporarily unavailable", e.Path)
+}
+
+func (e *UnavailableError) Is(target error) bool {
+	return target == ErrUnavailable
+}
+
+// QuotaExceededError represents a request rejected for exceeding a limit
+type QuotaExceededError struct {
+	Path  string
+	Op    string
+	Limit string // Name of the exceeded limit (e.g., "max_write_size")
+	Size  int64  // Size of the request
+	Max   int64  // Value of the limit
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s: %s: quota exceeded (%d > %s %d)", e.Op, e.Path, e.Size, e.Limit, e.Max)
+}
+
+func (e *QuotaExceededError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// ConflictError represents a conditional operation on a file whose version
+// is not the expected one
+type ConflictError struct {
+	Path     string
+	Op       string
+	Expected string // Version the operation was conditioned on
+	Actual   string // Current version, empty if the file does not exist
+}
+
+func (e *ConflictError) Error() string {
+	if e.Actual == "" {
+		return fmt.Sprintf("%s: %s: version conflict (expected %s, file does not exist)", e.Op, e.Path, e.Expected)
+	}
+	return fmt.Sprintf("%s: %s: version conflict (expected %s, found %s)", e.Op, e.Path, e.Expected, e.Actual)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
 // Helper functions to create common errors
 
 // NewNotFoundError creates a new NotFoundError
@@ -158,7 +247,27 @@ func NewNotDirectoryError(path string) error {
 	return &NotDirectoryError{Path: path}
 }
 
+// NewConflictError creates a new ConflictError
+func NewConflictError(op, path, expected, actual string) error {
+	return &ConflictError{Op: op, Path: path, Expected: expected, Actual: actual}
+}
+
 // NewNotSupportedError creates a new NotSupportedError
 func NewNotSupportedError(op, path string) error {
 	return &NotSupportedError{Op: op, Path: path}
 }
+
+// NewNoSpaceError creates a new NoSpaceError
+func NewNoSpaceError(op, path string) error {
+	return &NoSpaceError{Op: op, Path: path}
+}
+
+// NewUnavailableError creates a new UnavailableError
+func NewUnavailableError(path, reason string) error {
+	return &UnavailableError{Path: path, Reason: reason}
+}
+
+// NewQuotaExceededError creates a new QuotaExceededError
+func NewQuotaExceededError(op, path, limit string, size, max int64) error {
+	return &QuotaExceededError{Op: op, Path: path, Limit: limit, Size: size, Max: max}
+}
@@ -1,6 +1,7 @@
 package filesystem
 
 import (
+	"fmt"
 	"io"
 	"time"
 )
@@ -56,6 +57,20 @@ type FileInfo struct {
 	ModTime time.Time
 	IsDir   bool
 	Meta    MetaData // Structured metadata for additional information
+
+	// Version is a token that changes whenever the file changes, for
+	// conditional operations; plugins that keep one, like an object's ETag,
+	// may set it, otherwise FileVersion derives one from the metadata
+	Version string
+}
+
+// FileVersion returns the version of a file: the one its plugin set, or one
+// derived from its size and modification time
+func FileVersion(info *FileInfo) string {
+	if info.Version != "" {
+		return info.Version
+	}
+	return fmt.Sprintf("%x-%x", info.Size, info.ModTime.UnixNano())
 }
 
 // FileSystem defines the interface for a POSIX-like file system
@@ -0,0 +1,1133 @@
+// Package filesystemtest checks that a filesystem.FileSystem behaves the way
+// agfs-server, the FUSE client and the SDKs expect
+//
+// A plugin runs the suite from one of its tests:
+//
+//	func TestConformance(t *testing.T) {
+//		filesystemtest.Run(t, func(t *testing.T) filesystem.FileSystem {
+//			return NewMyFS()
+//		}, filesystemtest.Options{})
+//	}
+//
+// Every subtest gets a new file system from the factory. Options turn off the
+// checks of features a file system does not have (directories, offset writes,
+// rename, ...), so that the remaining checks still apply.
+package filesystemtest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+// Options describes what the file system under test supports
+type Options struct {
+	// Root is the directory the suite works in (default "/"); it must exist
+	Root string
+
+	// NoDirectories skips checks that create directories (flat namespaces)
+	NoDirectories bool
+
+	// NoOffsetWrites skips writes at an offset and appends; the file system
+	// only replaces whole files (object stores)
+	NoOffsetWrites bool
+
+	// NoRename skips Rename checks
+	NoRename bool
+
+	// NoChmod skips Chmod checks
+	NoChmod bool
+
+	// UntypedErrors only checks that failing operations return an error, not
+	// that it matches the filesystem sentinels (ErrNotFound, ...). New file
+	// systems should return typed errors and leave this off.
+	UntypedErrors bool
+}
+
+// Factory returns a new, empty file system for one subtest
+type Factory func(t *testing.T) filesystem.FileSystem
+
+// Run runs the conformance suite against the file systems made by newFS
+func Run(t *testing.T, newFS Factory, opts Options) {
+	if opts.Root == "" {
+		opts.Root = "/"
+	}
+
+	groups := []struct {
+		name  string
+		run   func(s *suite)
+		skip  bool
+		cause string
+	}{
+		{"Create", testCreate, false, ""},
+		{"Mkdir", testMkdir, opts.NoDirectories, "NoDirectories"},
+		{"ReadWrite", testReadWrite, false, ""},
+		{"RangeRead", testRangeRead, false, ""},
+		{"WriteFlags", testWriteFlags, false, ""},
+		{"WriteFlagMatrix", testWriteFlagMatrix, false, ""},
+		{"OffsetWrite", testOffsetWrite, opts.NoOffsetWrites, "NoOffsetWrites"},
+		{"OffsetWriteFlags", testOffsetWriteFlags, opts.NoOffsetWrites, "NoOffsetWrites"},
+		{"ReadDir", testReadDir, false, ""},
+		{"ReadDirNested", testReadDirNested, opts.NoDirectories, "NoDirectories"},
+		{"ReadDirAfterChanges", testReadDirAfterChanges, false, ""},
+		{"ReadDirAfterMoves", testReadDirAfterMoves, opts.NoRename || opts.NoDirectories, "NoRename or NoDirectories"},
+		{"Stat", testStat, false, ""},
+		{"Remove", testRemove, false, ""},
+		{"RemoveDir", testRemoveDir, opts.NoDirectories, "NoDirectories"},
+		{"Rename", testRename, opts.NoRename, "NoRename"},
+		{"RenameDir", testRenameDir, opts.NoRename || opts.NoDirectories, "NoRename or NoDirectories"},
+		{"Chmod", testChmod, opts.NoChmod, "NoChmod"},
+		{"OpenAndOpenWrite", testOpen, false, ""},
+	}
+	for _, g := range groups {
+		g := g
+		t.Run(g.name, func(t *testing.T) {
+			if g.skip {
+				t.Skipf("skipped by Options.%s", g.cause)
+			}
+			g.run(&suite{t: t, fs: newFS(t), opts: opts})
+		})
+	}
+}
+
+// suite is the state of one group of checks
+type suite struct {
+	t    *testing.T
+	fs   filesystem.FileSystem
+	opts Options
+}
+
+// p returns a path under the suite root
+func (s *suite) p(elem ...string) string {
+	return path.Join(append([]string{s.opts.Root}, elem...)...)
+}
+
+// must fails the test at once on an unexpected error of a setup step
+func (s *suite) must(err error, format string, args ...interface{}) {
+	s.t.Helper()
+	if err != nil {
+		s.t.Fatalf("%s: %v", fmt.Sprintf(format, args...), err)
+	}
+}
+
+// expectErr checks that an operation failed, with the given sentinel unless
+// the file system has untyped errors
+func (s *suite) expectErr(err, target error, format string, args ...interface{}) {
+	s.t.Helper()
+	what := fmt.Sprintf(format, args...)
+	if err == nil {
+		s.t.Errorf("%s: expected an error, got nil", what)
+		return
+	}
+	if !s.opts.UntypedErrors && target != nil && !errors.Is(err, target) {
+		s.t.Errorf("%s: expected an error matching %q, got %v", what, target, err)
+	}
+}
+
+// writeFile creates a file with content
+func (s *suite) writeFile(p string, data []byte) {
+	s.t.Helper()
+	n, err := s.fs.Write(p, data, -1, filesystem.WriteFlagCreate|filesystem.WriteFlagTruncate)
+	s.must(err, "write %s", p)
+	if n != int64(len(data)) {
+		s.t.Fatalf("write %s: expected %d bytes written, got %d", p, len(data), n)
+	}
+}
+
+// mkdir creates a directory
+func (s *suite) mkdir(p string) {
+	s.t.Helper()
+	s.must(s.fs.Mkdir(p, 0755), "mkdir %s", p)
+}
+
+// readAll reads a whole file; io.EOF is the normal end of a read
+func (s *suite) readAll(p string) ([]byte, error) {
+	data, err := s.fs.Read(p, 0, -1)
+	if err == io.EOF {
+		err = nil
+	}
+	return data, err
+}
+
+// expectContent checks the whole content of a file
+func (s *suite) expectContent(p string, want []byte) {
+	s.t.Helper()
+	got, err := s.readAll(p)
+	if err != nil {
+		s.t.Errorf("read %s: %v", p, err)
+		return
+	}
+	if !bytes.Equal(got, want) {
+		s.t.Errorf("read %s: expected %s, got %s", p, show(want), show(got))
+	}
+	info, err := s.fs.Stat(p)
+	if err != nil {
+		s.t.Errorf("stat %s: %v", p, err)
+		return
+	}
+	if info.Size != int64(len(want)) {
+		s.t.Errorf("stat %s: expected size %d, got %d", p, len(want), info.Size)
+	}
+}
+
+// expectMissing checks that a path does not exist
+func (s *suite) expectMissing(p string) {
+	s.t.Helper()
+	_, err := s.fs.Stat(p)
+	s.expectErr(err, filesystem.ErrNotFound, "stat of missing %s", p)
+}
+
+// names returns the sorted names of a directory listing
+func (s *suite) names(p string) []string {
+	s.t.Helper()
+	infos, err := s.fs.ReadDir(p)
+	s.must(err, "readdir %s", p)
+	names := make([]string, 0, len(infos))
+	for _, info := range infos {
+		names = append(names, info.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// show formats file content for error messages
+func show(data []byte) string {
+	if len(data) > 64 {
+		return fmt.Sprintf("%d bytes %q...", len(data), data[:64])
+	}
+	return fmt.Sprintf("%q", data)
+}
+
+func testCreate(s *suite) {
+	f := s.p("created.txt")
+	s.must(s.fs.Create(f), "create %s", f)
+
+	info, err := s.fs.Stat(f)
+	s.must(err, "stat %s", f)
+	if info.IsDir {
+		s.t.Errorf("stat %s: expected a file, got a directory", f)
+	}
+	if info.Size != 0 {
+		s.t.Errorf("stat %s: expected size 0, got %d", f, info.Size)
+	}
+	s.expectContent(f, nil)
+
+	if !s.opts.NoDirectories {
+		s.expectErr(s.fs.Create(s.p("missing-dir", "file.txt")), filesystem.ErrNotFound, "create in a missing directory")
+	}
+
+	// A file with content is not emptied by a second Create
+	s.writeFile(f, []byte("data"))
+	if err := s.fs.Create(f); err == nil {
+		s.expectContent(f, []byte("data"))
+	} else {
+		s.expectErr(err, filesystem.ErrAlreadyExists, "create of an existing file")
+	}
+}
+
+func testMkdir(s *suite) {
+	d := s.p("dir")
+	s.mkdir(d)
+
+	info, err := s.fs.Stat(d)
+	s.must(err, "stat %s", d)
+	if !info.IsDir {
+		s.t.Errorf("stat %s: expected a directory", d)
+	}
+	if got := s.names(d); len(got) != 0 {
+		s.t.Errorf("readdir of new directory %s: expected no entries, got %v", d, got)
+	}
+
+	s.expectErr(s.fs.Mkdir(d, 0755), filesystem.ErrAlreadyExists, "mkdir of an existing directory")
+	s.expectErr(s.fs.Mkdir(s.p("missing", "child"), 0755), filesystem.ErrNotFound, "mkdir in a missing directory")
+
+	nested := s.p("dir", "a", "b", "c")
+	s.mkdir(s.p("dir", "a"))
+	s.mkdir(s.p("dir", "a", "b"))
+	s.mkdir(nested)
+	if info, err := s.fs.Stat(nested); err != nil || !info.IsDir {
+		s.t.Errorf("stat of nested directory %s: %+v, %v", nested, info, err)
+	}
+
+	f := s.p("dir", "a", "file.txt")
+	s.writeFile(f, []byte("x"))
+	s.expectContent(f, []byte("x"))
+
+	s.writeFile(s.p("plain.txt"), []byte("x"))
+	if err := s.fs.Mkdir(s.p("plain.txt"), 0755); err == nil {
+		s.t.Errorf("mkdir over an existing file: expected an error")
+	}
+	s.expectErr(s.fs.Mkdir(s.p("plain.txt", "child"), 0755), nil, "mkdir under a file")
+}
+
+func testReadWrite(s *suite) {
+	binary := make([]byte, 256)
+	for i := range binary {
+		binary[i] = byte(i)
+	}
+	large := bytes.Repeat([]byte("0123456789abcdef"), 4096) // 64 KiB
+
+	payloads := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", []byte{}},
+		{"one-byte", []byte("x")},
+		{"text", []byte("hello, world\n")},
+		{"unicode", []byte("héllo wörld ✓ 日本語")},
+		{"binary", binary},
+		{"nul-bytes", []byte{0, 0, 0, 1, 0}},
+		{"large", large},
+	}
+	for _, pl := range payloads {
+		f := s.p(pl.name + ".bin")
+		s.writeFile(f, pl.data)
+		s.expectContent(f, pl.data)
+	}
+
+	// Overwriting replaces the content, also with shorter data
+	f := s.p("overwrite.txt")
+	s.writeFile(f, []byte("a long first version"))
+	s.writeFile(f, []byte("short"))
+	s.expectContent(f, []byte("short"))
+	s.writeFile(f, []byte{})
+	s.expectContent(f, nil)
+
+	// Files are independent
+	s.writeFile(s.p("one.txt"), []byte("one"))
+	s.writeFile(s.p("two.txt"), []byte("two"))
+	s.expectContent(s.p("one.txt"), []byte("one"))
+	s.expectContent(s.p("two.txt"), []byte("two"))
+
+	// The caller may reuse its buffer after Write returns
+	buf := []byte("original")
+	s.writeFile(s.p("buffer.txt"), buf)
+	copy(buf, "XXXXXXXX")
+	s.expectContent(s.p("buffer.txt"), []byte("original"))
+
+	_, err := s.fs.Read(s.p("missing.txt"), 0, -1)
+	s.expectErr(err, filesystem.ErrNotFound, "read of a missing file")
+
+	if !s.opts.NoDirectories {
+		d := s.p("a-dir")
+		s.mkdir(d)
+		_, err := s.fs.Read(d, 0, -1)
+		s.expectErr(err, nil, "read of a directory")
+		_, err = s.fs.Write(d, []byte("x"), -1, filesystem.WriteFlagCreate)
+		s.expectErr(err, nil, "write to a directory")
+	}
+}
+
+func testRangeRead(s *suite) {
+	content := []byte("0123456789abcdefghij") // 20 bytes
+	f := s.p("range.txt")
+	s.writeFile(f, content)
+	size := int64(len(content))
+
+	// Sizes far beyond the file must not overflow offset+size
+	offsets := []int64{0, 1, 5, 10, 18, 19, 20, 21, 100, 1 << 40, math.MaxInt64}
+	sizes := []int64{-1, 0, 1, 2, 5, 10, 19, 20, 21, 1000, 1 << 40, math.MaxInt64 - 1, math.MaxInt64}
+	for _, off := range offsets {
+		for _, n := range sizes {
+			data, err := s.fs.Read(f, off, n)
+			what := fmt.Sprintf("read(offset=%d, size=%d)", off, n)
+
+			start := off
+			if start > size {
+				start = size
+			}
+			end := size
+			if n >= 0 && n < size-start {
+				end = start + n
+			}
+			want := content[start:end]
+
+			if err != nil && err != io.EOF {
+				s.t.Errorf("%s: unexpected error %v", what, err)
+				continue
+			}
+			if !bytes.Equal(data, want) {
+				s.t.Errorf("%s: expected %q, got %q", what, want, data)
+			}
+			// io.EOF means the read reached the end of the file, so it must
+			// not be returned for a read that stops before the end
+			if err == io.EOF && end < size {
+				s.t.Errorf("%s: got io.EOF before the end of the file", what)
+			}
+		}
+	}
+
+	// Reading piecewise with the returned data yields the whole file
+	var got []byte
+	for off := int64(0); off < size; off += 3 {
+		data, err := s.fs.Read(f, off, 3)
+		if err != nil && err != io.EOF {
+			s.t.Fatalf("piecewise read at %d: %v", off, err)
+		}
+		got = append(got, data...)
+	}
+	if !bytes.Equal(got, content) {
+		s.t.Errorf("piecewise read: expected %q, got %q", content, got)
+	}
+
+	// Reads see the current content after the file grows and shrinks
+	for _, version := range []string{"short", "a much longer second version", "x"} {
+		s.writeFile(f, []byte(version))
+		for _, off := range []int64{0, 1, 4, 5, 6, 27, 28} {
+			data, err := s.fs.Read(f, off, 4)
+			if err != nil && err != io.EOF {
+				s.t.Errorf("read(offset=%d, size=4) of %q: unexpected error %v", off, version, err)
+				continue
+			}
+			want := ""
+			if off < int64(len(version)) {
+				want = version[off:]
+				if len(want) > 4 {
+					want = want[:4]
+				}
+			}
+			if string(data) != want {
+				s.t.Errorf("read(offset=%d, size=4) of %q: expected %q, got %q", off, version, want, data)
+			}
+		}
+	}
+
+	// Reads of an empty file
+	empty := s.p("empty.txt")
+	s.writeFile(empty, nil)
+	for _, off := range []int64{0, 1, 100} {
+		for _, n := range []int64{-1, 0, 10, math.MaxInt64} {
+			data, err := s.fs.Read(empty, off, n)
+			if err != nil && err != io.EOF {
+				s.t.Errorf("read of empty file (offset=%d, size=%d): unexpected error %v", off, n, err)
+			}
+			if len(data) != 0 {
+				s.t.Errorf("read of empty file (offset=%d, size=%d): expected no data, got %q", off, n, data)
+			}
+		}
+	}
+}
+
+func testWriteFlags(s *suite) {
+	f := s.p("flags.txt")
+
+	// Without WriteFlagCreate a missing file is not created. Offset -1 with no
+	// flags is the legacy whole-file overwrite, which may create, so an
+	// explicit offset is used.
+	_, err := s.fs.Write(f, []byte("x"), 0, filesystem.WriteFlagNone)
+	s.expectErr(err, filesystem.ErrNotFound, "write without create to a missing file")
+	s.expectMissing(f)
+
+	// WriteFlagCreate creates it
+	n, err := s.fs.Write(f, []byte("created"), -1, filesystem.WriteFlagCreate)
+	s.must(err, "write with create")
+	if n != 7 {
+		s.t.Errorf("write with create: expected 7 bytes written, got %d", n)
+	}
+	s.expectContent(f, []byte("created"))
+
+	// Exclusive create fails on an existing file and leaves it alone
+	_, err = s.fs.Write(f, []byte("clobbered"), -1, filesystem.WriteFlagCreate|filesystem.WriteFlagExclusive)
+	s.expectErr(err, filesystem.ErrAlreadyExists, "exclusive create of an existing file")
+	s.expectContent(f, []byte("created"))
+
+	// and succeeds on a new one
+	g := s.p("exclusive.txt")
+	_, err = s.fs.Write(g, []byte("new"), -1, filesystem.WriteFlagCreate|filesystem.WriteFlagExclusive)
+	s.must(err, "exclusive create of a new file")
+	s.expectContent(g, []byte("new"))
+
+	// Without WriteFlagCreate an existing file is written
+	_, err = s.fs.Write(f, []byte("rewritten"), -1, filesystem.WriteFlagNone)
+	s.must(err, "write without create to an existing file")
+	s.expectContent(f, []byte("rewritten"))
+
+	// Truncate drops the old content
+	_, err = s.fs.Write(f, []byte("t"), -1, filesystem.WriteFlagTruncate)
+	s.must(err, "write with truncate")
+	s.expectContent(f, []byte("t"))
+
+	// Sync is accepted with any other flag
+	_, err = s.fs.Write(f, []byte("synced"), -1, filesystem.WriteFlagCreate|filesystem.WriteFlagTruncate|filesystem.WriteFlagSync)
+	s.must(err, "write with sync")
+	s.expectContent(f, []byte("synced"))
+
+	// Writing empty data with truncate empties the file
+	_, err = s.fs.Write(f, nil, -1, filesystem.WriteFlagTruncate)
+	s.must(err, "empty write with truncate")
+	s.expectContent(f, nil)
+
+	if !s.opts.NoOffsetWrites {
+		// Append adds to the end and ignores the offset
+		a := s.p("append.log")
+		for i, line := range []string{"one\n", "two\n", "three\n"} {
+			n, err := s.fs.Write(a, []byte(line), int64(i), filesystem.WriteFlagCreate|filesystem.WriteFlagAppend)
+			s.must(err, "append %q", line)
+			if n != int64(len(line)) {
+				s.t.Errorf("append %q: expected %d bytes written, got %d", line, len(line), n)
+			}
+		}
+		s.expectContent(a, []byte("one\ntwo\nthree\n"))
+	}
+}
+
+func testWriteFlagMatrix(s *suite) {
+	const (
+		create    = filesystem.WriteFlagCreate
+		exclusive = filesystem.WriteFlagExclusive
+		truncate  = filesystem.WriteFlagTruncate
+		appendTo  = filesystem.WriteFlagAppend
+		sync      = filesystem.WriteFlagSync
+	)
+	old, data := "old content", "new!"
+
+	// Whole-file writes (offset -1) of data to a missing or an existing file.
+	// The data is shorter than the old content, so that a write that does not
+	// truncate shows.
+	cases := []struct {
+		exists  bool
+		flags   filesystem.WriteFlag
+		offsets bool   // Needs offset writes (appends)
+		err     error  // Expected error; the file is then left as it was
+		want    string // Content after the write
+	}{
+		{false, create, false, nil, data},
+		{false, create | truncate, false, nil, data},
+		{false, create | exclusive, false, nil, data},
+		{false, create | exclusive | truncate, false, nil, data},
+		{false, create | sync, false, nil, data},
+		{false, create | truncate | sync, false, nil, data},
+		{false, create | appendTo, true, nil, data},
+		{false, create | exclusive | appendTo, true, nil, data},
+		{false, truncate, false, filesystem.ErrNotFound, ""},
+		{false, sync, false, filesystem.ErrNotFound, ""},
+		{false, truncate | sync, false, filesystem.ErrNotFound, ""},
+		{false, appendTo, true, filesystem.ErrNotFound, ""},
+		{true, filesystem.WriteFlagNone, false, nil, data},
+		{true, create | truncate, false, nil, data},
+		{true, truncate, false, nil, data},
+		{true, truncate | sync, false, nil, data},
+		{true, create | truncate | sync, false, nil, data},
+		{true, create | exclusive, false, filesystem.ErrAlreadyExists, old},
+		{true, create | exclusive | truncate, false, filesystem.ErrAlreadyExists, old},
+		{true, create | exclusive | appendTo, true, filesystem.ErrAlreadyExists, old},
+		{true, appendTo, true, nil, old + data},
+		{true, create | appendTo, true, nil, old + data},
+		{true, appendTo | sync, true, nil, old + data},
+	}
+	for i, c := range cases {
+		if c.offsets && s.opts.NoOffsetWrites {
+			continue
+		}
+		f := s.p(fmt.Sprintf("matrix-%d.txt", i))
+		if c.exists {
+			s.writeFile(f, []byte(old))
+		}
+		what := fmt.Sprintf("write with flags %s to %s file", flagNames(c.flags), map[bool]string{false: "a missing", true: "an existing"}[c.exists])
+
+		n, err := s.fs.Write(f, []byte(data), -1, c.flags)
+		if c.err != nil {
+			s.expectErr(err, c.err, "%s", what)
+		} else if err != nil {
+			s.t.Errorf("%s: %v", what, err)
+			continue
+		} else if n != int64(len(data)) {
+			s.t.Errorf("%s: expected %d bytes written, got %d", what, len(data), n)
+		}
+
+		if !c.exists && c.err != nil {
+			s.expectMissing(f)
+		} else {
+			s.expectContent(f, []byte(c.want))
+		}
+	}
+
+	// Creating a file needs its directory
+	if !s.opts.NoDirectories {
+		for _, flags := range []filesystem.WriteFlag{create, create | truncate, create | exclusive} {
+			_, err := s.fs.Write(s.p("missing-dir", "file.txt"), []byte(data), -1, flags)
+			s.expectErr(err, filesystem.ErrNotFound, "write with flags %s in a missing directory", flagNames(flags))
+		}
+		s.expectMissing(s.p("missing-dir"))
+	}
+}
+
+// flagNames formats write flags for error messages
+func flagNames(flags filesystem.WriteFlag) string {
+	var names []string
+	for _, f := range []struct {
+		flag filesystem.WriteFlag
+		name string
+	}{
+		{filesystem.WriteFlagCreate, "create"},
+		{filesystem.WriteFlagExclusive, "exclusive"},
+		{filesystem.WriteFlagTruncate, "truncate"},
+		{filesystem.WriteFlagAppend, "append"},
+		{filesystem.WriteFlagSync, "sync"},
+	} {
+		if flags&f.flag != 0 {
+			names = append(names, f.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
+
+func testOffsetWrite(s *suite) {
+	f := s.p("offset.txt")
+	s.writeFile(f, []byte("hello world"))
+
+	cases := []struct {
+		offset int64
+		data   string
+		want   string
+	}{
+		{6, "WORLD", "hello WORLD"},           // inside the file
+		{0, "J", "Jello WORLD"},               // at the start
+		{11, "!", "Jello WORLD!"},             // at the end
+		{10, "D??", "Jello WORLD??"},          // across the end
+		{15, "x", "Jello WORLD??\x00\x00x"},   // past the end: the gap is zero filled
+		{0, "", "Jello WORLD??\x00\x00x"},     // empty write changes nothing
+		{2, "LL", "JeLLo WORLD??\x00\x00x"},   // in the middle, same length
+		{15, "yz", "JeLLo WORLD??\x00\x00yz"}, // over the last byte and beyond
+	}
+	for _, c := range cases {
+		n, err := s.fs.Write(f, []byte(c.data), c.offset, filesystem.WriteFlagNone)
+		s.must(err, "write %q at %d", c.data, c.offset)
+		if n != int64(len(c.data)) {
+			s.t.Errorf("write %q at %d: expected %d bytes written, got %d", c.data, c.offset, len(c.data), n)
+		}
+		s.expectContent(f, []byte(c.want))
+	}
+
+	// Offset writes create the file with WriteFlagCreate
+	g := s.p("sparse.bin")
+	_, err := s.fs.Write(g, []byte("ab"), 3, filesystem.WriteFlagCreate)
+	s.must(err, "offset write creating a file")
+	s.expectContent(g, []byte("\x00\x00\x00ab"))
+
+	// Truncate with an offset empties the file first
+	_, err = s.fs.Write(g, []byte("c"), 1, filesystem.WriteFlagTruncate)
+	s.must(err, "offset write with truncate")
+	s.expectContent(g, []byte("\x00c"))
+}
+
+func testOffsetWriteFlags(s *suite) {
+	const old = "0123456789"
+
+	cases := []struct {
+		exists bool
+		offset int64
+		data   string
+		flags  filesystem.WriteFlag
+		err    error
+		want   string
+	}{
+		{true, 0, "ab", filesystem.WriteFlagNone, nil, "ab23456789"},
+		{true, 4, "XY", filesystem.WriteFlagCreate, nil, "0123XY6789"}, // Create does not truncate
+		{true, 8, "XYZ", filesystem.WriteFlagCreate, nil, "01234567XYZ"},
+		{true, 10, "", filesystem.WriteFlagNone, nil, old},
+		{true, 12, "", filesystem.WriteFlagNone, nil, old}, // An empty write does not extend the file
+		{true, 2, "ab", filesystem.WriteFlagTruncate, nil, "\x00\x00ab"},
+		{true, 0, "ab", filesystem.WriteFlagCreate | filesystem.WriteFlagTruncate, nil, "ab"},
+		{true, 100, "ab", filesystem.WriteFlagAppend, nil, old + "ab"}, // Append ignores the offset
+		{true, 0, "ab", filesystem.WriteFlagAppend | filesystem.WriteFlagCreate, nil, old + "ab"},
+		{true, 3, "ab", filesystem.WriteFlagCreate | filesystem.WriteFlagExclusive, filesystem.ErrAlreadyExists, old},
+		{false, 2, "ab", filesystem.WriteFlagNone, filesystem.ErrNotFound, ""},
+		{false, 2, "ab", filesystem.WriteFlagTruncate, filesystem.ErrNotFound, ""},
+		{false, 0, "ab", filesystem.WriteFlagAppend, filesystem.ErrNotFound, ""},
+		{false, 2, "ab", filesystem.WriteFlagCreate | filesystem.WriteFlagExclusive, nil, "\x00\x00ab"},
+		{false, 0, "ab", filesystem.WriteFlagCreate | filesystem.WriteFlagAppend, nil, "ab"},
+		{false, 0, "", filesystem.WriteFlagCreate, nil, ""},
+	}
+	for i, c := range cases {
+		f := s.p(fmt.Sprintf("offset-flags-%d.txt", i))
+		if c.exists {
+			s.writeFile(f, []byte(old))
+		}
+		what := fmt.Sprintf("write %q at %d with flags %s", c.data, c.offset, flagNames(c.flags))
+
+		n, err := s.fs.Write(f, []byte(c.data), c.offset, c.flags)
+		if c.err != nil {
+			s.expectErr(err, c.err, "%s", what)
+		} else if err != nil {
+			s.t.Errorf("%s: %v", what, err)
+			continue
+		} else if n != int64(len(c.data)) {
+			s.t.Errorf("%s: expected %d bytes written, got %d", what, len(c.data), n)
+		}
+
+		if !c.exists && c.err != nil {
+			s.expectMissing(f)
+		} else {
+			s.expectContent(f, []byte(c.want))
+		}
+	}
+
+	// A file written in blocks out of order reads back in order
+	f := s.p("blocks.bin")
+	blocks := []string{"aaaa", "bbbb", "cccc", "dddd"}
+	for _, i := range []int{2, 0, 3, 1} {
+		_, err := s.fs.Write(f, []byte(blocks[i]), int64(i*4), filesystem.WriteFlagCreate)
+		s.must(err, "write block %d", i)
+	}
+	s.expectContent(f, []byte(strings.Join(blocks, "")))
+}
+
+func testReadDir(s *suite) {
+	want := []string{"a.txt", "b.txt", "c.bin", "with space.txt", "UPPER.txt"}
+	sizes := map[string]int64{}
+	for i, name := range want {
+		data := bytes.Repeat([]byte("x"), i*10)
+		s.writeFile(s.p(name), data)
+		sizes[name] = int64(len(data))
+	}
+	sort.Strings(want)
+
+	infos, err := s.fs.ReadDir(s.opts.Root)
+	s.must(err, "readdir %s", s.opts.Root)
+
+	seen := map[string]bool{}
+	for _, info := range infos {
+		if info.Name == "." || info.Name == ".." || info.Name == "" {
+			s.t.Errorf("readdir: unexpected entry %q", info.Name)
+		}
+		if strings.Contains(info.Name, "/") {
+			s.t.Errorf("readdir: entry %q is a path, not a name", info.Name)
+		}
+		if seen[info.Name] {
+			s.t.Errorf("readdir: duplicate entry %q", info.Name)
+		}
+		seen[info.Name] = true
+
+		wantSize, ok := sizes[info.Name]
+		if !ok {
+			continue // Entries the file system always has
+		}
+		if info.IsDir {
+			s.t.Errorf("readdir: %s listed as a directory", info.Name)
+		}
+		if info.Size != wantSize {
+			s.t.Errorf("readdir: %s has size %d, expected %d", info.Name, info.Size, wantSize)
+		}
+
+		// The listing agrees with Stat
+		stat, err := s.fs.Stat(s.p(info.Name))
+		if err != nil {
+			s.t.Errorf("stat of listed %s: %v", info.Name, err)
+			continue
+		}
+		if stat.Size != info.Size || stat.IsDir != info.IsDir {
+			s.t.Errorf("stat of %s (%d bytes, dir=%v) disagrees with readdir (%d bytes, dir=%v)",
+				info.Name, stat.Size, stat.IsDir, info.Size, info.IsDir)
+		}
+	}
+	for _, name := range want {
+		if !seen[name] {
+			s.t.Errorf("readdir: %s is missing", name)
+		}
+	}
+
+	// Removed and renamed files leave the listing
+	s.must(s.fs.Remove(s.p("a.txt")), "remove a.txt")
+	if got := s.names(s.opts.Root); contains(got, "a.txt") {
+		s.t.Errorf("readdir after remove: a.txt still listed in %v", got)
+	}
+	if !s.opts.NoRename {
+		s.must(s.fs.Rename(s.p("b.txt"), s.p("renamed.txt")), "rename b.txt")
+		got := s.names(s.opts.Root)
+		if contains(got, "b.txt") || !contains(got, "renamed.txt") {
+			s.t.Errorf("readdir after rename: expected renamed.txt instead of b.txt, got %v", got)
+		}
+	}
+
+	// Listing a file or a missing path fails
+	_, err = s.fs.ReadDir(s.p("c.bin"))
+	s.expectErr(err, filesystem.ErrNotDirectory, "readdir of a file")
+	_, err = s.fs.ReadDir(s.p("missing-dir"))
+	s.expectErr(err, filesystem.ErrNotFound, "readdir of a missing directory")
+
+	// Listing twice gives the same entries
+	if a, b := s.names(s.opts.Root), s.names(s.opts.Root); strings.Join(a, "/") != strings.Join(b, "/") {
+		s.t.Errorf("readdir is not stable: %v then %v", a, b)
+	}
+}
+
+func testReadDirNested(s *suite) {
+	s.mkdir(s.p("top"))
+	s.mkdir(s.p("top", "sub"))
+	s.writeFile(s.p("top", "file.txt"), []byte("12345"))
+	s.writeFile(s.p("top", "sub", "deep.txt"), []byte("deep"))
+
+	infos, err := s.fs.ReadDir(s.p("top"))
+	s.must(err, "readdir top")
+	if len(infos) != 2 {
+		s.t.Fatalf("readdir top: expected 2 entries, got %+v", infos)
+	}
+	for _, info := range infos {
+		switch info.Name {
+		case "sub":
+			if !info.IsDir {
+				s.t.Errorf("readdir top: sub should be a directory")
+			}
+		case "file.txt":
+			if info.IsDir || info.Size != 5 {
+				s.t.Errorf("readdir top: unexpected file.txt %+v", info)
+			}
+		default:
+			s.t.Errorf("readdir top: unexpected entry %q", info.Name)
+		}
+	}
+
+	// Only direct children are listed
+	if got := s.names(s.p("top", "sub")); len(got) != 1 || got[0] != "deep.txt" {
+		s.t.Errorf("readdir top/sub: expected [deep.txt], got %v", got)
+	}
+	if got := s.names(s.opts.Root); contains(got, "deep.txt") || contains(got, "file.txt") {
+		s.t.Errorf("readdir root lists nested files: %v", got)
+	}
+
+	// A path with a trailing slash names the same directory
+	if got := s.names(s.p("top") + "/"); len(got) != 2 {
+		s.t.Errorf("readdir top/: expected 2 entries, got %v", got)
+	}
+}
+
+func testReadDirAfterChanges(s *suite) {
+	// The root may have entries of its own
+	baseline := s.names(s.opts.Root)
+	expect := func(what string, extra ...string) {
+		s.t.Helper()
+		want := append(append([]string{}, baseline...), extra...)
+		sort.Strings(want)
+		if got := s.names(s.opts.Root); strings.Join(got, "/") != strings.Join(want, "/") {
+			s.t.Errorf("readdir %s: expected %v, got %v", what, want, got)
+		}
+	}
+	expectSize := func(what, name string, size int64) {
+		s.t.Helper()
+		infos, err := s.fs.ReadDir(s.opts.Root)
+		s.must(err, "readdir %s", s.opts.Root)
+		for _, info := range infos {
+			if info.Name == name {
+				if info.Size != size {
+					s.t.Errorf("readdir %s: %s has size %d, expected %d", what, name, info.Size, size)
+				}
+				return
+			}
+		}
+		s.t.Errorf("readdir %s: %s is missing", what, name)
+	}
+
+	names := []string{"f1", "f2", "f3", "f4", "f5"}
+	for i, name := range names {
+		s.writeFile(s.p(name), bytes.Repeat([]byte("x"), i+1))
+	}
+	expect("after writes", names...)
+	expectSize("after writes", "f3", 3)
+
+	s.writeFile(s.p("f2"), bytes.Repeat([]byte("y"), 40))
+	expectSize("after growing f2", "f2", 40)
+	s.writeFile(s.p("f2"), nil)
+	expectSize("after emptying f2", "f2", 0)
+
+	s.must(s.fs.Remove(s.p("f1")), "remove f1")
+	s.must(s.fs.Remove(s.p("f3")), "remove f3")
+	expect("after remove", "f2", "f4", "f5")
+	for _, name := range []string{"f1", "f3"} {
+		s.expectMissing(s.p(name))
+		_, err := s.fs.Read(s.p(name), 0, -1)
+		s.expectErr(err, filesystem.ErrNotFound, "read of removed %s", name)
+	}
+
+	// A removed name can be used again, with the new content
+	s.writeFile(s.p("f1"), []byte("recreated"))
+	expect("after recreating f1", "f1", "f2", "f4", "f5")
+	expectSize("after recreating f1", "f1", 9)
+
+	if !s.opts.NoRename {
+		s.must(s.fs.Rename(s.p("f4"), s.p("g4")), "rename f4")
+		expect("after rename", "f1", "f2", "f5", "g4")
+		expectSize("after rename", "g4", 4)
+		s.expectMissing(s.p("f4"))
+		s.expectContent(s.p("g4"), []byte("xxxx"))
+
+		s.must(s.fs.Rename(s.p("g4"), s.p("f4")), "rename g4 back")
+		expect("after renaming back", "f1", "f2", "f4", "f5")
+		s.expectMissing(s.p("g4"))
+	}
+
+	for _, name := range []string{"f1", "f2", "f4", "f5"} {
+		s.must(s.fs.Remove(s.p(name)), "remove %s", name)
+	}
+	expect("after removing everything")
+}
+
+func testReadDirAfterMoves(s *suite) {
+	expect := func(dir string, want ...string) {
+		s.t.Helper()
+		sort.Strings(want)
+		if got := s.names(dir); strings.Join(got, "/") != strings.Join(want, "/") {
+			s.t.Errorf("readdir %s: expected %v, got %v", dir, want, got)
+		}
+	}
+	a, b, c := s.p("a"), s.p("b"), s.p("c")
+	s.mkdir(a)
+	s.mkdir(b)
+	s.mkdir(s.p("a", "sub"))
+	s.writeFile(s.p("a", "x"), []byte("x"))
+	s.writeFile(s.p("a", "y"), []byte("yy"))
+	s.writeFile(s.p("a", "sub", "z"), []byte("zzz"))
+
+	// A file moved between directories
+	s.must(s.fs.Rename(s.p("a", "x"), s.p("b", "x")), "move a/x")
+	expect(a, "sub", "y")
+	expect(b, "x")
+
+	// A directory moved with its content
+	s.must(s.fs.Rename(s.p("a", "sub"), s.p("b", "sub")), "move a/sub")
+	expect(a, "y")
+	expect(b, "sub", "x")
+	expect(s.p("b", "sub"), "z")
+	_, err := s.fs.ReadDir(s.p("a", "sub"))
+	s.expectErr(err, filesystem.ErrNotFound, "readdir of moved a/sub")
+
+	// A renamed directory is listed under its new name only
+	s.must(s.fs.Rename(a, c), "rename a")
+	if got := s.names(s.opts.Root); contains(got, "a") || !contains(got, "c") {
+		s.t.Errorf("readdir after renaming a to c: got %v", got)
+	}
+	expect(c, "y")
+	_, err = s.fs.ReadDir(a)
+	s.expectErr(err, filesystem.ErrNotFound, "readdir of renamed a")
+
+	// Directories emptied by Remove are listed empty
+	s.must(s.fs.Remove(s.p("c", "y")), "remove c/y")
+	expect(c)
+
+	s.must(s.fs.RemoveAll(b), "removeall b")
+	_, err = s.fs.ReadDir(s.p("b", "sub"))
+	s.expectErr(err, filesystem.ErrNotFound, "readdir under removed b")
+	s.must(s.fs.Remove(c), "remove c")
+	if got := s.names(s.opts.Root); contains(got, "b") || contains(got, "c") {
+		s.t.Errorf("readdir after removing b and c: got %v", got)
+	}
+}
+
+func testStat(s *suite) {
+	root, err := s.fs.Stat(s.opts.Root)
+	s.must(err, "stat of root %s", s.opts.Root)
+	if !root.IsDir {
+		s.t.Errorf("stat of root %s: expected a directory", s.opts.Root)
+	}
+
+	before := time.Now().Add(-time.Minute)
+	f := s.p("stat.txt")
+	s.writeFile(f, []byte("123456789"))
+
+	info, err := s.fs.Stat(f)
+	s.must(err, "stat %s", f)
+	if info.Name != "stat.txt" {
+		s.t.Errorf("stat %s: expected name stat.txt, got %q", f, info.Name)
+	}
+	if info.Size != 9 {
+		s.t.Errorf("stat %s: expected size 9, got %d", f, info.Size)
+	}
+	if info.IsDir {
+		s.t.Errorf("stat %s: expected a file", f)
+	}
+	if info.ModTime.IsZero() || info.ModTime.Before(before) {
+		s.t.Errorf("stat %s: expected a recent modification time, got %v", f, info.ModTime)
+	}
+
+	// Size follows writes
+	s.writeFile(f, []byte("1"))
+	if info, err := s.fs.Stat(f); err != nil || info.Size != 1 {
+		s.t.Errorf("stat after shrinking write: %+v, %v", info, err)
+	}
+
+	s.expectMissing(s.p("missing.txt"))
+	s.expectMissing(s.p("missing-dir", "file.txt"))
+
+	if !s.opts.NoDirectories {
+		s.mkdir(s.p("statdir"))
+		info, err := s.fs.Stat(s.p("statdir"))
+		s.must(err, "stat statdir")
+		if !info.IsDir || info.Name != "statdir" {
+			s.t.Errorf("stat statdir: expected directory named statdir, got %+v", info)
+		}
+	}
+}
+
+func testRemove(s *suite) {
+	f := s.p("remove.txt")
+	s.writeFile(f, []byte("bye"))
+	s.must(s.fs.Remove(f), "remove %s", f)
+	s.expectMissing(f)
+	_, err := s.fs.Read(f, 0, -1)
+	s.expectErr(err, filesystem.ErrNotFound, "read of a removed file")
+
+	s.expectErr(s.fs.Remove(f), filesystem.ErrNotFound, "remove of a removed file")
+	s.expectErr(s.fs.Remove(s.p("never-existed.txt")), filesystem.ErrNotFound, "remove of a missing file")
+
+	// A removed file can be created again, empty
+	s.writeFile(f, []byte("again"))
+	s.expectContent(f, []byte("again"))
+
+	// RemoveAll of a single file
+	s.must(s.fs.RemoveAll(f), "removeall of a file")
+	s.expectMissing(f)
+}
+
+func testRemoveDir(s *suite) {
+	d := s.p("rmdir")
+	s.mkdir(d)
+	s.must(s.fs.Remove(d), "remove of an empty directory")
+	s.expectMissing(d)
+
+	s.mkdir(d)
+	s.writeFile(s.p("rmdir", "child.txt"), []byte("x"))
+	if err := s.fs.Remove(d); err == nil {
+		s.t.Errorf("remove of a non-empty directory: expected an error")
+	}
+	s.expectContent(s.p("rmdir", "child.txt"), []byte("x"))
+
+	s.mkdir(s.p("rmdir", "sub"))
+	s.writeFile(s.p("rmdir", "sub", "deep.txt"), []byte("y"))
+	s.must(s.fs.RemoveAll(d), "removeall %s", d)
+	s.expectMissing(d)
+	s.expectMissing(s.p("rmdir", "child.txt"))
+	s.expectMissing(s.p("rmdir", "sub", "deep.txt"))
+
+	// Siblings are not touched
+	s.mkdir(s.p("keep"))
+	s.writeFile(s.p("keep", "file.txt"), []byte("kept"))
+	s.mkdir(s.p("drop"))
+	s.must(s.fs.RemoveAll(s.p("drop")), "removeall drop")
+	s.expectContent(s.p("keep", "file.txt"), []byte("kept"))
+
+	// A directory name that prefixes another is a different directory
+	s.mkdir(s.p("pre"))
+	s.mkdir(s.p("prefix"))
+	s.writeFile(s.p("prefix", "file.txt"), []byte("p"))
+	s.must(s.fs.RemoveAll(s.p("pre")), "removeall pre")
+	s.expectContent(s.p("prefix", "file.txt"), []byte("p"))
+}
+
+func testRename(s *suite) {
+	src, dst := s.p("old.txt"), s.p("new.txt")
+	s.writeFile(src, []byte("moved content"))
+	s.must(s.fs.Rename(src, dst), "rename %s to %s", src, dst)
+	s.expectMissing(src)
+	s.expectContent(dst, []byte("moved content"))
+
+	if info, err := s.fs.Stat(dst); err == nil && info.Name != "new.txt" {
+		s.t.Errorf("stat after rename: expected name new.txt, got %q", info.Name)
+	}
+
+	s.expectErr(s.fs.Rename(s.p("missing.txt"), s.p("other.txt")), filesystem.ErrNotFound, "rename of a missing file")
+	s.expectMissing(s.p("other.txt"))
+
+	// Renamed files can be written under their new name only
+	s.writeFile(dst, []byte("updated"))
+	s.expectContent(dst, []byte("updated"))
+	s.expectMissing(src)
+}
+
+func testRenameDir(s *suite) {
+	s.mkdir(s.p("from"))
+	s.mkdir(s.p("from", "sub"))
+	s.writeFile(s.p("from", "a.txt"), []byte("a"))
+	s.writeFile(s.p("from", "sub", "b.txt"), []byte("b"))
+
+	s.must(s.fs.Rename(s.p("from"), s.p("to")), "rename directory")
+	s.expectMissing(s.p("from"))
+	s.expectMissing(s.p("from", "a.txt"))
+	s.expectContent(s.p("to", "a.txt"), []byte("a"))
+	s.expectContent(s.p("to", "sub", "b.txt"), []byte("b"))
+
+	// Moving a file into another directory
+	s.mkdir(s.p("other"))
+	s.must(s.fs.Rename(s.p("to", "a.txt"), s.p("other", "a.txt")), "move file between directories")
+	s.expectMissing(s.p("to", "a.txt"))
+	s.expectContent(s.p("other", "a.txt"), []byte("a"))
+	if got := s.names(s.p("other")); len(got) != 1 || got[0] != "a.txt" {
+		s.t.Errorf("readdir of move target: expected [a.txt], got %v", got)
+	}
+}
+
+func testChmod(s *suite) {
+	f := s.p("mode.txt")
+	s.writeFile(f, []byte("x"))
+	for _, mode := range []uint32{0600, 0644, 0755, 0400} {
+		s.must(s.fs.Chmod(f, mode), "chmod %o", mode)
+		info, err := s.fs.Stat(f)
+		s.must(err, "stat after chmod %o", mode)
+		if info.Mode&0777 != mode {
+			s.t.Errorf("chmod %o: stat reports mode %o", mode, info.Mode&0777)
+		}
+	}
+	// Content is not affected
+	s.expectContent(f, []byte("x"))
+
+	s.expectErr(s.fs.Chmod(s.p("missing.txt"), 0644), filesystem.ErrNotFound, "chmod of a missing file")
+}
+
+func testOpen(s *suite) {
+	content := bytes.Repeat([]byte("stream "), 1000)
+	f := s.p("open.txt")
+	s.writeFile(f, content)
+
+	r, err := s.fs.Open(f)
+	s.must(err, "open %s", f)
+	got, err := io.ReadAll(r)
+	if err != nil {
+		s.t.Errorf("reading opened %s: %v", f, err)
+	}
+	if err := r.Close(); err != nil {
+		s.t.Errorf("closing opened %s: %v", f, err)
+	}
+	if !bytes.Equal(got, content) {
+		s.t.Errorf("open %s: expected %s, got %s", f, show(content), show(got))
+	}
+
+	_, err = s.fs.Open(s.p("missing.txt"))
+	s.expectErr(err, filesystem.ErrNotFound, "open of a missing file")
+
+	// OpenWrite replaces the content once closed, written in pieces
+	w, err := s.fs.OpenWrite(s.p("written.txt"))
+	s.must(err, "openwrite")
+	for _, piece := range []string{"first ", "second ", "third"} {
+		if _, err := io.WriteString(w, piece); err != nil {
+			s.t.Fatalf("writing %q: %v", piece, err)
+		}
+	}
+	s.must(w.Close(), "closing writer")
+	s.expectContent(s.p("written.txt"), []byte("first second third"))
+
+	w, err = s.fs.OpenWrite(s.p("written.txt"))
+	s.must(err, "openwrite of an existing file")
+	io.WriteString(w, "new")
+	s.must(w.Close(), "closing writer")
+	s.expectContent(s.p("written.txt"), []byte("new"))
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -1,5 +1,7 @@
 package filesystem
 
+import "io"
+
 // FileHandle represents an open file handle with stateful operations
 // This interface is used for FUSE-like operations that require maintaining
 // file position and state across multiple read/write operations
@@ -59,3 +61,55 @@ type HandleFS interface {
 	// only the ID is available (e.g., from REST API)
 	CloseHandle(id int64) error
 }
+
+// IOVec is one range of a vectored read or write: the bytes of Data at Offset
+type IOVec struct {
+	Offset int64
+	Data   []byte // Filled by reads, written by writes
+}
+
+// VectoredHandle is implemented by file handles that read or write several
+// ranges at once more cheaply than one at a time, e.g. with a single backend
+// request. Handles that do not implement it get the ranges one by one from
+// ReadV and WriteV.
+type VectoredHandle interface {
+	// ReadV reads each range like ReadAt, returning how many bytes were read
+	// into each; ranges past the end of the file are read short
+	ReadV(vecs []IOVec) ([]int, error)
+
+	// WriteV writes each range like WriteAt, in order, returning how many
+	// bytes were written from each
+	WriteV(vecs []IOVec) ([]int, error)
+}
+
+// ReadV reads several ranges of a handle, at once if it is a VectoredHandle
+func ReadV(h FileHandle, vecs []IOVec) ([]int, error) {
+	if v, ok := h.(VectoredHandle); ok {
+		return v.ReadV(vecs)
+	}
+	counts := make([]int, len(vecs))
+	for i, vec := range vecs {
+		n, err := h.ReadAt(vec.Data, vec.Offset)
+		counts[i] = n
+		if err != nil && err != io.EOF {
+			return counts, err
+		}
+	}
+	return counts, nil
+}
+
+// WriteV writes several ranges of a handle, at once if it is a VectoredHandle
+func WriteV(h FileHandle, vecs []IOVec) ([]int, error) {
+	if v, ok := h.(VectoredHandle); ok {
+		return v.WriteV(vecs)
+	}
+	counts := make([]int, len(vecs))
+	for i, vec := range vecs {
+		n, err := h.WriteAt(vec.Data, vec.Offset)
+		counts[i] = n
+		if err != nil {
+			return counts, err
+		}
+	}
+	return counts, nil
+}
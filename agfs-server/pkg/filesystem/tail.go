@@ -0,0 +1,152 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"time"
+)
+
+// tailChunk is how much of a file Tail reads at a time, from its end
+const tailChunk = 64 << 10
+
+// readRange reads size bytes at offset, tolerating the io.EOF of reads that
+// reach the end of the file
+func readRange(fs FileSystem, path string, offset, size int64) ([]byte, error) {
+	data, err := fs.Read(path, offset, size)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Tail returns the last lines of a file and the offset of its end, to follow
+// it from (see WaitForGrowth). The file is read backwards in chunks, so that
+// the end of a large log costs a few reads rather than reading it whole; only
+// files whose size is not known from Stat are read whole. If maxBytes > 0,
+// at most that many bytes are returned, the first line cut if needed.
+func Tail(fs FileSystem, path string, lines int, maxBytes int64) ([]byte, int64, error) {
+	info, err := fs.Stat(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	if info.IsDir {
+		return nil, 0, NewInvalidArgumentError("path", path, "is a directory")
+	}
+	if lines <= 0 {
+		return []byte{}, info.Size, nil
+	}
+
+	size := info.Size
+	if size <= 0 {
+		// Generated files may report no size: read them whole
+		data, err := readRange(fs, path, 0, -1)
+		if err != nil {
+			return nil, 0, err
+		}
+		return capTail(lastLines(data, lines), maxBytes), int64(len(data)), nil
+	}
+
+	var buf []byte
+	pos := size
+	for pos > 0 && countLines(buf) <= lines && (maxBytes <= 0 || int64(len(buf)) < maxBytes) {
+		start := max(0, pos-tailChunk)
+		chunk, err := readRange(fs, path, start, pos-start)
+		if err != nil {
+			return nil, 0, err
+		}
+		if int64(len(chunk)) != pos-start {
+			// The file changed size while it was read: start over whole
+			data, err := readRange(fs, path, 0, -1)
+			if err != nil {
+				return nil, 0, err
+			}
+			return capTail(lastLines(data, lines), maxBytes), int64(len(data)), nil
+		}
+		// Copy the chunk: plugins may return their own buffers
+		buf = append(append(make([]byte, 0, len(chunk)+len(buf)), chunk...), buf...)
+		pos = start
+	}
+	return capTail(lastLines(buf, lines), maxBytes), size, nil
+}
+
+// TailBytes returns the last n bytes of a file and the offset of its end
+func TailBytes(fs FileSystem, path string, n int64) ([]byte, int64, error) {
+	info, err := fs.Stat(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	if info.IsDir {
+		return nil, 0, NewInvalidArgumentError("path", path, "is a directory")
+	}
+	if info.Size <= 0 {
+		data, err := readRange(fs, path, 0, -1)
+		if err != nil {
+			return nil, 0, err
+		}
+		return data[max(0, int64(len(data))-n):], int64(len(data)), nil
+	}
+	start := max(0, info.Size-n)
+	data, err := readRange(fs, path, start, info.Size-start)
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, start + int64(len(data)), nil
+}
+
+// countLines counts the lines of data, a last line without a newline included
+func countLines(data []byte) int {
+	n := bytes.Count(data, []byte{'\n'})
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		n++
+	}
+	return n
+}
+
+// lastLines returns the last n lines of data
+func lastLines(data []byte, n int) []byte {
+	end := len(data)
+	if end > 0 && data[end-1] == '\n' {
+		end-- // The newline ending the last line
+	}
+	for i := end - 1; i >= 0; i-- {
+		if data[i] == '\n' {
+			n--
+			if n == 0 {
+				return data[i+1:]
+			}
+		}
+	}
+	return data
+}
+
+// capTail keeps the last maxBytes of data, if maxBytes > 0
+func capTail(data []byte, maxBytes int64) []byte {
+	if maxBytes > 0 && int64(len(data)) > maxBytes {
+		return data[int64(len(data))-maxBytes:]
+	}
+	return data
+}
+
+// WaitForGrowth polls the size of a file every interval until it differs
+// from offset, i.e. data was appended (or the file was truncated), and
+// returns the new size. It returns offset unchanged once ctx is done, which is
+// how long polls time out.
+func WaitForGrowth(ctx context.Context, fs FileSystem, path string, offset int64, interval time.Duration) (int64, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		info, err := fs.Stat(path)
+		if err != nil {
+			return 0, err
+		}
+		if info.Size != offset {
+			return info.Size, nil
+		}
+		select {
+		case <-ctx.Done():
+			return offset, nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package filesystem
+
+import "testing"
+
+func TestLastLines(t *testing.T) {
+	for _, tc := range []struct {
+		data string
+		n    int
+		want string
+	}{
+		{"a\nb\nc\n", 2, "b\nc\n"},
+		{"a\nb\nc", 2, "b\nc"},
+		{"a\nb\nc\n", 3, "a\nb\nc\n"},
+		{"a\nb\nc\n", 5, "a\nb\nc\n"},
+		{"a\n\n\n", 2, "\n\n"},
+		{"", 1, ""},
+	} {
+		if got := string(lastLines([]byte(tc.data), tc.n)); got != tc.want {
+			t.Errorf("lastLines(%q, %d) = %q, want %q", tc.data, tc.n, got, tc.want)
+		}
+	}
+	if n := countLines([]byte("a\nb")); n != 2 {
+		t.Errorf("expected 2 lines, got %d", n)
+	}
+}
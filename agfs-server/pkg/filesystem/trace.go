@@ -0,0 +1,75 @@
+package filesystem
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OpTrace records how long the steps of one file system operation take, so
+// that slow operations can be logged with a breakdown by backend.
+// A nil *OpTrace records nothing, so plugins can call Span unconditionally.
+type OpTrace struct {
+	mu    sync.Mutex
+	spans []TraceSpan
+}
+
+// TraceSpan is the total time spent in one step of an operation
+type TraceSpan struct {
+	Name     string
+	Duration time.Duration
+	Count    int // Number of times the step ran
+}
+
+// Span records that the step name, started at start, is done; repeated
+// steps (e.g. one embedding request per chunk) add up
+func (t *OpTrace) Span(name string, start time.Time) {
+	if t == nil {
+		return
+	}
+	d := time.Since(start)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range t.spans {
+		if t.spans[i].Name == name {
+			t.spans[i].Duration += d
+			t.spans[i].Count++
+			return
+		}
+	}
+	t.spans = append(t.spans, TraceSpan{Name: name, Duration: d, Count: 1})
+}
+
+// Spans returns the recorded steps, in the order they first ran
+func (t *OpTrace) Spans() []TraceSpan {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]TraceSpan(nil), t.spans...)
+}
+
+// String formats the steps as "s3 upload=120ms, embedding=2.1s (4x)"
+func (t *OpTrace) String() string {
+	var parts []string
+	for _, s := range t.Spans() {
+		part := fmt.Sprintf("%s=%v", s.Name, s.Duration.Round(time.Millisecond))
+		if s.Count > 1 {
+			part += fmt.Sprintf(" (%dx)", s.Count)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Traceable is implemented by file systems that report the steps of their
+// operations; WithTrace returns a file system for a single operation that
+// records its steps in trace.
+// The returned file system must implement the same optional interfaces
+// (HandleFS, Truncater, ...) as the original.
+type Traceable interface {
+	WithTrace(trace *OpTrace) FileSystem
+}
@@ -0,0 +1,487 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/mountablefs"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultAuditLogSize is the number of admin events kept by NewAuditLog
+const defaultAuditLogSize = 1000
+
+// AuditEvent records one administrative action (mount, unmount, plugin load,
+// handle drain, task start, ...)
+type AuditEvent struct {
+	Seq    int64     `json:"seq"`
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	Target string    `json:"target"`
+	Remote string    `json:"remote,omitempty"`
+	Detail string    `json:"detail,omitempty"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// AuditLog keeps the latest admin events in memory, numbered so that clients
+// can follow it by asking for the events after the last one they saw
+type AuditLog struct {
+	mu     sync.Mutex
+	events []AuditEvent
+	size   int
+	seq    int64
+}
+
+// NewAuditLog creates an audit log keeping the latest size events
+func NewAuditLog(size int) *AuditLog {
+	if size <= 0 {
+		size = defaultAuditLogSize
+	}
+	return &AuditLog{size: size}
+}
+
+// Record adds an event to the log and writes it to the server log
+func (a *AuditLog) Record(r *http.Request, action, target, detail string, err error) {
+	if a == nil {
+		return
+	}
+	event := AuditEvent{
+		Time:   time.Now(),
+		Action: action,
+		Target: target,
+		Detail: detail,
+	}
+	if r != nil {
+		event.Remote = r.RemoteAddr
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+
+	a.mu.Lock()
+	a.seq++
+	event.Seq = a.seq
+	a.events = append(a.events, event)
+	if len(a.events) > a.size {
+		a.events = a.events[len(a.events)-a.size:]
+	}
+	a.mu.Unlock()
+
+	log.WithFields(log.Fields{
+		"action": action,
+		"target": target,
+		"remote": event.Remote,
+		"error":  event.Error,
+	}).Info("admin audit")
+}
+
+// Since returns the events after sequence number seq, oldest first
+func (a *AuditLog) Since(seq int64) []AuditEvent {
+	if a == nil {
+		return []AuditEvent{}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	events := []AuditEvent{}
+	for _, e := range a.events {
+		if e.Seq > seq {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// AdminHandler serves the admin API used by agfsctl
+type AdminHandler struct {
+	mfs            *mountablefs.MountableFS
+	trafficMonitor *TrafficMonitor
+	audit          *AuditLog
+	startTime      time.Time
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(mfs *mountablefs.MountableFS, trafficMonitor *TrafficMonitor, audit *AuditLog) *AdminHandler {
+	return &AdminHandler{
+		mfs:            mfs,
+		trafficMonitor: trafficMonitor,
+		audit:          audit,
+		startTime:      time.Now(),
+	}
+}
+
+// AdminHandlesResponse represents the response for listing open handles
+type AdminHandlesResponse struct {
+	Handles []mountablefs.OpenHandleInfo `json:"handles"`
+}
+
+// DrainHandlesRequest represents a request to close open handles
+type DrainHandlesRequest struct {
+	Path string `json:"path"` // Close handles at or below this path ("/" for all)
+}
+
+// DrainHandlesResponse represents the response for closing handles
+type DrainHandlesResponse struct {
+	Closed int `json:"closed"`
+}
+
+// RevokeHandlesRequest represents a request to forcibly close open handles,
+// whose clients are then told they were revoked
+type RevokeHandlesRequest struct {
+	Path   string `json:"path,omitempty"`   // Revoke handles at or below this path ("/" for all)
+	Client string `json:"client,omitempty"` // Revoke handles opened by this client
+	Reason string `json:"reason,omitempty"` // Told to the clients
+}
+
+// RevokeHandlesResponse represents the response for revoking handles
+type RevokeHandlesResponse struct {
+	Revoked int                          `json:"revoked"`
+	Handles []mountablefs.OpenHandleInfo `json:"handles"`
+}
+
+// MemoryStats is the memory part of AdminMetrics
+type MemoryStats struct {
+	AllocBytes     uint64 `json:"alloc_bytes"`
+	HeapInuseBytes uint64 `json:"heap_inuse_bytes"`
+	SysBytes       uint64 `json:"sys_bytes"`
+	NumGC          uint32 `json:"num_gc"`
+}
+
+// AdminMetrics represents a snapshot of server metrics
+type AdminMetrics struct {
+	Time            time.Time   `json:"time"`
+	UptimeSeconds   int64       `json:"uptime_seconds"`
+	Goroutines      int         `json:"goroutines"`
+	Memory          MemoryStats `json:"memory"`
+	Traffic         interface{} `json:"traffic,omitempty"`
+	Mounts          int         `json:"mounts"`
+	UnhealthyMounts []string    `json:"unhealthy_mounts"`
+	OpenHandles     int         `json:"open_handles"`
+	RunningTasks    int         `json:"running_tasks"`
+
+	// Schedulers of the mounts with a max_concurrency, by mount path
+	Schedulers map[string]mountablefs.SchedulerStats `json:"schedulers,omitempty"`
+}
+
+// GCResponse represents the result of a forced garbage collection
+type GCResponse struct {
+	Before     MemoryStats `json:"before"`
+	After      MemoryStats `json:"after"`
+	DurationMs int64       `json:"duration_ms"`
+}
+
+// AdminTasksResponse represents the response for listing tasks
+type AdminTasksResponse struct {
+	Tasks     []mountablefs.TaskInfo   `json:"tasks"`
+	Available []mountablefs.MountTasks `json:"available"`
+}
+
+// StartTaskRequest represents a request to start a background task
+type StartTaskRequest struct {
+	Path string            `json:"path"` // Mount (or path inside it) to run the task on
+	Task string            `json:"task"`
+	Args map[string]string `json:"args,omitempty"`
+}
+
+// MigrateRequest represents a request to copy a tree between mounts
+type MigrateRequest struct {
+	Src        string `json:"src"`
+	Dst        string `json:"dst"`
+	Parallel   int    `json:"parallel,omitempty"`    // Files copied at once (default 4)
+	Bandwidth  string `json:"bandwidth,omitempty"`   // Bytes per second, e.g. "50MB" (default unlimited)
+	StatusFile string `json:"status_file,omitempty"` // Default <dst>/.agfs-migrate.json
+}
+
+// AuditResponse represents the response for reading the audit log
+type AuditResponse struct {
+	Events []AuditEvent `json:"events"`
+}
+
+func memoryStats() MemoryStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return MemoryStats{
+		AllocBytes:     m.Alloc,
+		HeapInuseBytes: m.HeapInuse,
+		SysBytes:       m.Sys,
+		NumGC:          m.NumGC,
+	}
+}
+
+// ListHandles handles GET /admin/handles?path=<path>&client=<client>; both
+// filters are optional
+func (ah *AdminHandler) ListHandles(w http.ResponseWriter, r *http.Request) {
+	filter := mountablefs.HandleFilter{
+		Path:   r.URL.Query().Get("path"),
+		Client: r.URL.Query().Get("client"),
+	}
+	writeJSON(w, http.StatusOK, AdminHandlesResponse{Handles: ah.mfs.FindOpenHandles(filter)})
+}
+
+// DrainHandles handles POST /admin/handles/drain
+func (ah *AdminHandler) DrainHandles(w http.ResponseWriter, r *http.Request) {
+	var req DrainHandlesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Path == "" {
+		writeError(w, http.StatusBadRequest, "path is required")
+		return
+	}
+
+	closed := ah.mfs.CloseHandlesUnder(req.Path)
+	ah.audit.Record(r, "drain_handles", req.Path, strconv.Itoa(closed)+" closed", nil)
+	writeJSON(w, http.StatusOK, DrainHandlesResponse{Closed: closed})
+}
+
+// RevokeHandles handles POST /admin/handles/revoke
+func (ah *AdminHandler) RevokeHandles(w http.ResponseWriter, r *http.Request) {
+	var req RevokeHandlesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Path == "" && req.Client == "" {
+		writeError(w, http.StatusBadRequest, "path or client is required")
+		return
+	}
+
+	revoked := ah.mfs.RevokeHandles(mountablefs.HandleFilter{Path: req.Path, Client: req.Client}, req.Reason)
+	target := req.Path
+	if req.Client != "" {
+		target = strings.TrimSpace(target + " client=" + req.Client)
+	}
+	ah.audit.Record(r, "revoke_handles", target, strconv.Itoa(len(revoked))+" revoked", nil)
+	writeJSON(w, http.StatusOK, RevokeHandlesResponse{Revoked: len(revoked), Handles: revoked})
+}
+
+// Metrics handles GET /admin/metrics
+func (ah *AdminHandler) Metrics(w http.ResponseWriter, r *http.Request) {
+	metrics := AdminMetrics{
+		Time:            time.Now(),
+		UptimeSeconds:   int64(time.Since(ah.startTime).Seconds()),
+		Goroutines:      runtime.NumGoroutine(),
+		Memory:          memoryStats(),
+		UnhealthyMounts: []string{},
+		OpenHandles:     len(ah.mfs.ListOpenHandles()),
+	}
+	if ah.trafficMonitor != nil {
+		metrics.Traffic = ah.trafficMonitor.GetStats()
+	}
+	for _, mount := range ah.mfs.GetMounts() {
+		metrics.Mounts++
+		if !mount.Health().Healthy() {
+			metrics.UnhealthyMounts = append(metrics.UnhealthyMounts, mount.Path)
+		}
+		if s := mount.Scheduler(); s != nil {
+			if metrics.Schedulers == nil {
+				metrics.Schedulers = map[string]mountablefs.SchedulerStats{}
+			}
+			metrics.Schedulers[mount.Path] = s.Stats()
+		}
+	}
+	for _, task := range ah.mfs.ListTasks() {
+		if task.Status == mountablefs.TaskStatusRunning {
+			metrics.RunningTasks++
+		}
+	}
+	writeJSON(w, http.StatusOK, metrics)
+}
+
+// GC handles POST /admin/gc: it runs the Go garbage collector and returns
+// freed memory to the operating system
+func (ah *AdminHandler) GC(w http.ResponseWriter, r *http.Request) {
+	before := memoryStats()
+	start := time.Now()
+	debug.FreeOSMemory()
+	resp := GCResponse{
+		Before:     before,
+		After:      memoryStats(),
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	ah.audit.Record(r, "gc", "server", "", nil)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// ListTasks handles GET /admin/tasks
+func (ah *AdminHandler) ListTasks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, AdminTasksResponse{
+		Tasks:     ah.mfs.ListTasks(),
+		Available: ah.mfs.AvailableTasks(),
+	})
+}
+
+// StartTask handles POST /admin/tasks
+func (ah *AdminHandler) StartTask(w http.ResponseWriter, r *http.Request) {
+	var req StartTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Path == "" || req.Task == "" {
+		writeError(w, http.StatusBadRequest, "path and task are required")
+		return
+	}
+
+	task, err := ah.mfs.StartTask(req.Path, req.Task, req.Args)
+	ah.audit.Record(r, "start_task", req.Path, req.Task, err)
+	if err != nil {
+		writeError(w, mapErrorToStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, task)
+}
+
+// Task handles GET and DELETE /admin/tasks/<id>
+func (ah *AdminHandler) Task(w http.ResponseWriter, r *http.Request, idStr string) {
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid task ID")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		task, err := ah.mfs.GetTask(id)
+		if err != nil {
+			writeError(w, mapErrorToStatus(err), err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, task)
+	case http.MethodDelete:
+		err := ah.mfs.CancelTask(id)
+		ah.audit.Record(r, "cancel_task", idStr, "", err)
+		if err != nil {
+			writeError(w, mapErrorToStatus(err), err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, SuccessResponse{Message: "task cancellation requested"})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// Migrate handles POST /admin/migrate: it starts a migration task
+func (ah *AdminHandler) Migrate(w http.ResponseWriter, r *http.Request) {
+	var req MigrateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	args := map[string]string{
+		mountablefs.MigrateArgSource:      req.Src,
+		mountablefs.MigrateArgDestination: req.Dst,
+		mountablefs.MigrateArgBandwidth:   req.Bandwidth,
+		mountablefs.MigrateArgStatusFile:  req.StatusFile,
+	}
+	if req.Parallel != 0 {
+		args[mountablefs.MigrateArgParallel] = strconv.Itoa(req.Parallel)
+	}
+
+	task, err := ah.mfs.StartMigration(args)
+	ah.audit.Record(r, "migrate", req.Src, req.Dst, err)
+	if err != nil {
+		writeError(w, mapErrorToStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, task)
+}
+
+// Audit handles GET /admin/audit?since=<seq>
+func (ah *AdminHandler) Audit(w http.ResponseWriter, r *http.Request) {
+	var since int64
+	if s := r.URL.Query().Get("since"); s != "" {
+		var err error
+		if since, err = strconv.ParseInt(s, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid since parameter")
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, AuditResponse{Events: ah.audit.Since(since)})
+}
+
+// SetupRoutes sets up admin routes with /api/v1/admin prefix
+func (ah *AdminHandler) SetupRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/api/v1/admin/handles", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		ah.ListHandles(w, r)
+	})
+
+	mux.HandleFunc("/api/v1/admin/handles/drain", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		ah.DrainHandles(w, r)
+	})
+
+	mux.HandleFunc("/api/v1/admin/handles/revoke", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		ah.RevokeHandles(w, r)
+	})
+
+	mux.HandleFunc("/api/v1/admin/metrics", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		ah.Metrics(w, r)
+	})
+
+	mux.HandleFunc("/api/v1/admin/gc", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		ah.GC(w, r)
+	})
+
+	mux.HandleFunc("/api/v1/admin/audit", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		ah.Audit(w, r)
+	})
+
+	mux.HandleFunc("/api/v1/admin/tasks", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			ah.ListTasks(w, r)
+		case http.MethodPost:
+			ah.StartTask(w, r)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	})
+
+	mux.HandleFunc("/api/v1/admin/migrate", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		ah.Migrate(w, r)
+	})
+
+	mux.HandleFunc("/api/v1/admin/tasks/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/api/v1/admin/tasks/")
+		if id == "" {
+			writeError(w, http.StatusBadRequest, "task ID required")
+			return
+		}
+		ah.Task(w, r, id)
+	})
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+// AsOfHeader echoes the asOf parameter of reads as of a past time, telling
+// clients that the server honored it
+const AsOfHeader = "X-As-Of"
+
+// asOfReader returns the time of the asOf parameter of a request, an RFC 3339
+// timestamp, and the file system to answer it from. It writes the error and
+// returns false if the timestamp is invalid or the file system keeps no
+// history; whether a path does is answered by its mount (501 otherwise).
+func (h *Handler) asOfReader(w http.ResponseWriter, r *http.Request) (filesystem.AsOfReader, time.Time, bool) {
+	asOf, err := time.Parse(time.RFC3339Nano, r.URL.Query().Get("asOf"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid asOf parameter: expected an RFC 3339 timestamp")
+		return nil, time.Time{}, false
+	}
+	reader, ok := h.fs.(filesystem.AsOfReader)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "reads as of a past time are not supported")
+		return nil, time.Time{}, false
+	}
+	w.Header().Set(AsOfHeader, asOf.Format(time.RFC3339Nano))
+	return reader, asOf, true
+}
+
+// readAsOf handles GET /files?path=<path>&asOf=<time>[&offset=<n>][&size=<n>],
+// reading a file as it was at a past time from mounts that keep history
+func (h *Handler) readAsOf(w http.ResponseWriter, r *http.Request, path string) {
+	reader, asOf, ok := h.asOfReader(w, r)
+	if !ok {
+		return
+	}
+	offset, size := int64(0), int64(-1)
+	if s := r.URL.Query().Get("offset"); s != "" {
+		var err error
+		if offset, err = strconv.ParseInt(s, 10, 64); err != nil || offset < 0 {
+			writeError(w, http.StatusBadRequest, "invalid offset parameter")
+			return
+		}
+	}
+	if s := r.URL.Query().Get("size"); s != "" {
+		var err error
+		if size, err = strconv.ParseInt(s, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid size parameter")
+			return
+		}
+	}
+
+	data, err := reader.ReadAsOf(path, asOf, offset, size)
+	if err != nil && err != io.EOF {
+		writeError(w, mapErrorToStatus(err), err.Error())
+		return
+	}
+	writeData(w, r, data)
+	if h.trafficMonitor != nil && len(data) > 0 {
+		h.trafficMonitor.RecordRead(int64(len(data)))
+	}
+}
+
+// statAsOf handles GET /stat?path=<path>&asOf=<time>. Unlike Stat, it sets no
+// ETag: the version of a past file cannot be written against.
+func (h *Handler) statAsOf(w http.ResponseWriter, r *http.Request, path string) {
+	reader, asOf, ok := h.asOfReader(w, r)
+	if !ok {
+		return
+	}
+	info, err := reader.StatAsOf(path, asOf)
+	if err != nil {
+		writeError(w, mapErrorToStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, fileInfoResponse(info))
+}
+
+// listDirectoryAsOf handles GET /directories?path=<path>&asOf=<time>
+func (h *Handler) listDirectoryAsOf(w http.ResponseWriter, r *http.Request, path string) {
+	reader, asOf, ok := h.asOfReader(w, r)
+	if !ok {
+		return
+	}
+	files, err := reader.ReadDirAsOf(path, asOf)
+	if err != nil {
+		writeError(w, mapErrorToStatus(err), err.Error())
+		return
+	}
+	var response ListResponse
+	for i := range files {
+		response.Files = append(response.Files, fileInfoResponse(&files[i]))
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// fileInfoResponse returns the response describing a file
+func fileInfoResponse(info *filesystem.FileInfo) FileInfoResponse {
+	return FileInfoResponse{
+		Name:    info.Name,
+		Size:    info.Size,
+		Mode:    info.Mode,
+		ModTime: info.ModTime.Format(time.RFC3339Nano),
+		IsDir:   info.IsDir,
+		Meta:    info.Meta,
+		Version: filesystem.FileVersion(info),
+	}
+}
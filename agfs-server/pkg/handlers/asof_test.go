@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/memfs"
+)
+
+// pastFS is a memfs whose reads as of any time see another one, the past
+type pastFS struct {
+	*memfs.MemoryFS
+	past *memfs.MemoryFS
+}
+
+func (f *pastFS) ReadAsOf(path string, asOf time.Time, offset, size int64) ([]byte, error) {
+	return f.past.Read(path, offset, size)
+}
+
+func (f *pastFS) StatAsOf(path string, asOf time.Time) (*filesystem.FileInfo, error) {
+	return f.past.Stat(path)
+}
+
+func (f *pastFS) ReadDirAsOf(path string, asOf time.Time) ([]filesystem.FileInfo, error) {
+	return f.past.ReadDir(path)
+}
+
+func TestAsOf(t *testing.T) {
+	fs := &pastFS{MemoryFS: memfs.NewMemoryFS(), past: memfs.NewMemoryFS()}
+	for f, content := range map[filesystem.FileSystem]string{fs: "hello world", fs.past: "hello"} {
+		if _, err := f.Write("/a.txt", []byte(content), -1, filesystem.WriteFlagCreate); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := NewHandler(fs, nil)
+	get := func(handler http.HandlerFunc, query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/api/v1/x?"+query, nil))
+		return w
+	}
+
+	if w := get(h.ReadFile, "path=/a.txt&asOf=2026-01-02T15:04:05Z&offset=1&size=3"); w.Code != http.StatusOK || w.Body.String() != "ell" {
+		t.Errorf("read: %d %q", w.Code, w.Body.String())
+	}
+	if w := get(h.ReadFile, "path=/a.txt&asOf=2026-01-02T15:04:05Z"); w.Header().Get(AsOfHeader) != "2026-01-02T15:04:05Z" {
+		t.Errorf("expected the %s header, got %q", AsOfHeader, w.Header().Get(AsOfHeader))
+	}
+	w := get(h.Stat, "path=/a.txt&asOf=2026-01-02T15:04:05.5%2B02:00")
+	var info FileInfoResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil || info.Size != 5 {
+		t.Errorf("stat: %d %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("ETag") != "" {
+		t.Errorf("expected no ETag for a past version")
+	}
+	w = get(h.ListDirectory, "path=/&asOf=2026-01-02T15:04:05Z")
+	var list ListResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || len(list.Files) != 1 || list.Files[0].Size != 5 {
+		t.Errorf("list: %d %s", w.Code, w.Body.String())
+	}
+
+	if w := get(h.ReadFile, "path=/a.txt&asOf=yesterday"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected an invalid timestamp to be rejected, got %d", w.Code)
+	}
+	plain := NewHandler(memfs.NewMemoryFS(), nil)
+	if w := get(plain.Stat, "path=/&asOf=2026-01-02T15:04:05Z"); w.Code != http.StatusNotImplemented {
+		t.Errorf("expected file systems without history to return 501, got %d", w.Code)
+	}
+}
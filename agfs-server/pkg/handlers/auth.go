@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// TokenAuthMiddleware rejects requests that do not send one of tokens as a
+// bearer token ("Authorization: Bearer <token>") with 401 Unauthorized
+// The health endpoint is left open so that load balancers can probe it.
+func TokenAuthMiddleware(next http.Handler, tokens []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/health" || validToken(r, tokens) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="agfs"`)
+		writeError(w, http.StatusUnauthorized, "missing or invalid token")
+	})
+}
+
+func validToken(r *http.Request, tokens []string) bool {
+	auth := r.Header.Get("Authorization")
+	scheme, token, ok := strings.Cut(auth, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return false
+	}
+	valid := false
+	for _, t := range tokens {
+		// Compare with every token in constant time, not to leak which matched
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
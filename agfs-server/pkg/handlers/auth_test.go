@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTokenAuthMiddleware(t *testing.T) {
+	h := TokenAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), []string{"first", "second"})
+
+	tests := []struct {
+		path   string
+		auth   string
+		status int
+	}{
+		{"/api/v1/files", "", http.StatusUnauthorized},
+		{"/api/v1/files", "Bearer wrong", http.StatusUnauthorized},
+		{"/api/v1/files", "Basic second", http.StatusUnauthorized},
+		{"/api/v1/files", "Bearer second", http.StatusOK},
+		{"/api/v1/files", "bearer first", http.StatusOK},
+		{"/api/v1/health", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s with %q: status %d, want %d", tt.path, tt.auth, rec.Code, tt.status)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+const (
+	// maxBatchPaths bounds the paths of one batch stat or read
+	maxBatchPaths = 1000
+	// batchWorkers is how many paths of a batch are stat'ed or read at once
+	batchWorkers = 8
+	// defaultBatchReadSize is the largest file a batch read returns, unless
+	// the request says otherwise
+	defaultBatchReadSize = 1 << 20
+	// maxBatchReadSize bounds the max_size of batch reads
+	maxBatchReadSize = 16 << 20
+)
+
+// maxBatchReadBytes bounds the contents returned by one batch read; the files
+// past it fail with 413 and are read by another request. Tests lower it.
+var maxBatchReadBytes int64 = 64 << 20
+
+// BatchRequest lists the paths of a batch stat or read
+type BatchRequest struct {
+	Paths   []string `json:"paths"`
+	MaxSize int64    `json:"max_size,omitempty"` // Largest file a batch read returns (default 1 MiB)
+}
+
+// BatchStatResult is the outcome of the stat of one path of a batch
+type BatchStatResult struct {
+	Path   string            `json:"path"`
+	Status int               `json:"status"` // HTTP status the stat alone would have had
+	Info   *FileInfoResponse `json:"info,omitempty"`
+	Error  string            `json:"error,omitempty"`
+}
+
+// BatchStatResponse represents the outcome of a batch stat
+type BatchStatResponse struct {
+	Results []BatchStatResult `json:"results"` // In the order of the request
+	Failed  int               `json:"failed"`
+}
+
+// BatchReadResult is the outcome of the read of one file of a batch
+type BatchReadResult struct {
+	Path   string `json:"path"`
+	Status int    `json:"status"` // HTTP status the read alone would have had
+	Data   []byte `json:"data"`   // Base64 in JSON
+	Error  string `json:"error,omitempty"`
+}
+
+// BatchReadResponse represents the outcome of a batch read
+type BatchReadResponse struct {
+	Results []BatchReadResult `json:"results"` // In the order of the request
+	Failed  int               `json:"failed"`
+}
+
+// decodeBatch decodes the body of a batch request, writing the error if it is
+// invalid
+func decodeBatch(w http.ResponseWriter, r *http.Request) (*BatchRequest, bool) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return nil, false
+	}
+	var req BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return nil, false
+	}
+	if len(req.Paths) > maxBatchPaths {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("too many paths: at most %d per request", maxBatchPaths))
+		return nil, false
+	}
+	return &req, true
+}
+
+// forEach runs f for each index of n, batchWorkers at a time
+func forEach(n int, f func(i int)) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, batchWorkers)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			f(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// StatMany handles POST /api/v1/stat/batch: it stats the paths listed in the
+// body with one request, so that clients resolving the entries of a
+// directory (e.g. FUSE mounts on ls -l) do not need one round trip each.
+// Paths that fail have the status and error their stat alone would have had.
+func (h *Handler) StatMany(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeBatch(w, r)
+	if !ok {
+		return
+	}
+
+	response := BatchStatResponse{Results: make([]BatchStatResult, len(req.Paths))}
+	forEach(len(req.Paths), func(i int) {
+		result := BatchStatResult{Path: req.Paths[i], Status: http.StatusOK}
+		if info, err := h.fs.Stat(req.Paths[i]); err != nil {
+			result.Status = mapErrorToStatus(err)
+			result.Error = err.Error()
+		} else {
+			resp := fileInfoResponse(info)
+			result.Info = &resp
+		}
+		response.Results[i] = result
+	})
+	for _, result := range response.Results {
+		if result.Error != "" {
+			response.Failed++
+		}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// ReadMany handles POST /api/v1/files/batch: it reads the small files listed
+// in the body with one request. Files larger than max_size, or past the
+// bytes one response carries, fail with 413; clients read them on their own.
+func (h *Handler) ReadMany(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeBatch(w, r)
+	if !ok {
+		return
+	}
+	maxSize := req.MaxSize
+	switch {
+	case maxSize < 0:
+		writeError(w, http.StatusBadRequest, "max_size must not be negative")
+		return
+	case maxSize == 0:
+		maxSize = defaultBatchReadSize
+	case maxSize > maxBatchReadSize:
+		maxSize = maxBatchReadSize
+	}
+	if max := h.limits().MaxReadSize; max > 0 && maxSize >= max {
+		maxSize = max - 1 // Files are read one byte past max_size
+	}
+
+	var budget atomic.Int64
+	budget.Store(maxBatchReadBytes)
+	response := BatchReadResponse{Results: make([]BatchReadResult, len(req.Paths))}
+	forEach(len(req.Paths), func(i int) {
+		path := req.Paths[i]
+		result := BatchReadResult{Path: path, Status: http.StatusOK}
+		// One byte more than allowed tells files that are too large apart
+		data, err := h.fs.Read(path, 0, maxSize+1)
+		if err == io.EOF {
+			err = nil
+		}
+		if err == nil && int64(len(data)) > maxSize {
+			err = filesystem.NewQuotaExceededError("read", path, "max_size", int64(len(data)), maxSize)
+		}
+		if err == nil && budget.Add(-int64(len(data))) < 0 {
+			err = filesystem.NewQuotaExceededError("read", path, "batch_bytes", int64(len(data)), maxBatchReadBytes)
+		}
+		if err != nil {
+			result.Status = mapErrorToStatus(err)
+			result.Error = err.Error()
+		} else {
+			// Copy the data: plugins may return their own buffers
+			result.Data = append([]byte{}, data...)
+		}
+		response.Results[i] = result
+	})
+
+	var bytes int64
+	for _, result := range response.Results {
+		if result.Error != "" {
+			response.Failed++
+		}
+		bytes += int64(len(result.Data))
+	}
+	if h.trafficMonitor != nil && bytes > 0 {
+		h.trafficMonitor.RecordRead(bytes)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/memfs"
+)
+
+func TestBatch(t *testing.T) {
+	fs := memfs.NewMemoryFS()
+	if err := fs.Mkdir("/dir", 0755); err != nil {
+		t.Fatal(err)
+	}
+	for path, content := range map[string]string{"/dir/a.txt": "aaa", "/dir/big.bin": strings.Repeat("x", 100), "/dir/empty": ""} {
+		if _, err := fs.Write(path, []byte(content), -1, filesystem.WriteFlagCreate); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := NewHandler(fs, nil)
+	post := func(handler http.HandlerFunc, body string, v interface{}) int {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodPost, "/api/v1/x/batch", strings.NewReader(body)))
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+				t.Fatalf("invalid response %s: %v", w.Body.String(), err)
+			}
+		}
+		return w.Code
+	}
+
+	var stats BatchStatResponse
+	if code := post(h.StatMany, `{"paths": ["/dir/a.txt", "/dir/missing", "/dir"]}`, &stats); code != http.StatusOK {
+		t.Fatalf("StatMany returned %d", code)
+	}
+	if len(stats.Results) != 3 || stats.Failed != 1 {
+		t.Fatalf("unexpected results %+v", stats)
+	}
+	if r := stats.Results[0]; r.Path != "/dir/a.txt" || r.Status != http.StatusOK || r.Info == nil || r.Info.Size != 3 {
+		t.Errorf("unexpected result %+v", r)
+	}
+	if r := stats.Results[1]; r.Status == http.StatusOK || r.Error == "" || r.Info != nil {
+		t.Errorf("expected the missing path to fail, got %+v", r)
+	}
+	if r := stats.Results[2]; r.Info == nil || !r.Info.IsDir {
+		t.Errorf("unexpected result %+v", r)
+	}
+
+	var reads BatchReadResponse
+	if code := post(h.ReadMany, `{"paths": ["/dir/a.txt", "/dir/big.bin", "/dir/empty"], "max_size": 10}`, &reads); code != http.StatusOK {
+		t.Fatalf("ReadMany returned %d", code)
+	}
+	if len(reads.Results) != 3 || reads.Failed != 1 {
+		t.Fatalf("unexpected results %+v", reads)
+	}
+	if r := reads.Results[0]; string(r.Data) != "aaa" || r.Status != http.StatusOK {
+		t.Errorf("unexpected result %+v", r)
+	}
+	if r := reads.Results[1]; r.Status != http.StatusRequestEntityTooLarge || r.Data != nil {
+		t.Errorf("expected files over max_size to fail with 413, got %+v", r)
+	}
+	if r := reads.Results[2]; r.Status != http.StatusOK || len(r.Data) != 0 {
+		t.Errorf("unexpected result %+v", r)
+	}
+
+	// Files past the bytes of one response fail on their own, unlike missing ones
+	defer func(max int64) { maxBatchReadBytes = max }(maxBatchReadBytes)
+	maxBatchReadBytes = 5
+	if code := post(h.ReadMany, `{"paths": ["/dir/a.txt", "/dir/a.txt", "/dir/missing"]}`, &reads); code != http.StatusOK {
+		t.Fatalf("ReadMany returned %d", code)
+	}
+	if len(reads.Results) != 3 || reads.Failed != 2 {
+		t.Fatalf("unexpected results %+v", reads)
+	}
+	statuses := map[int]int{}
+	for _, r := range reads.Results[:2] {
+		statuses[r.Status]++
+	}
+	if statuses[http.StatusOK] != 1 || statuses[http.StatusRequestEntityTooLarge] != 1 {
+		t.Errorf("expected one read past the budget to fail with 413, got %+v", reads.Results)
+	}
+	if r := reads.Results[2]; r.Status != http.StatusNotFound {
+		t.Errorf("expected the missing file to fail with 404, got %+v", r)
+	}
+
+	tooMany := `{"paths": [` + strings.Repeat(`"/a",`, maxBatchPaths) + `"/a"]}`
+	if code := post(h.StatMany, tooMany, &stats); code != http.StatusBadRequest {
+		t.Errorf("expected too many paths to be rejected, got %d", code)
+	}
+	if code := post(h.ReadMany, `{"paths": [], "max_size": -1}`, &reads); code != http.StatusBadRequest {
+		t.Errorf("expected a negative max_size to be rejected, got %d", code)
+	}
+}
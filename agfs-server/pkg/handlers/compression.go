@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+)
+
+// File contents are compressed on the wire when the client asks for it:
+// reads with Accept-Encoding, writes with Content-Encoding. Both "zstd" and
+// "lz4" (an LZ4 frame) are supported.
+const (
+	encodingZstd = "zstd"
+	encodingLZ4  = "lz4"
+
+	// compressMinSize is the size below which responses are not compressed
+	compressMinSize = 1024
+)
+
+// negotiateEncoding returns the first encoding of an Accept-Encoding header
+// that the server supports, "" if none
+func negotiateEncoding(acceptEncoding string) string {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if name != encodingZstd && name != encodingLZ4 {
+			continue
+		}
+		rejected := false
+		for _, param := range fields[1:] {
+			if q, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				weight, err := strconv.ParseFloat(q, 64)
+				rejected = err == nil && weight == 0
+			}
+		}
+		if !rejected {
+			return name
+		}
+	}
+	return ""
+}
+
+// compress encodes data with a supported encoding
+func compress(encoding string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case encodingZstd:
+		zw, err := zstd.NewWriter(&buf)
+		if err != nil {
+			return nil, err
+		}
+		w = zw
+	case encodingLZ4:
+		w = lz4.NewWriter(&buf)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeBody replaces the body of a request sent with a supported
+// Content-Encoding by its decoded content. Decoding is streamed, so limits on
+// the body (see readBody) apply to the decoded size.
+func decodeBody(r *http.Request) error {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	switch encoding {
+	case "", "identity":
+		return nil
+	case encodingZstd:
+		zr, err := zstd.NewReader(r.Body)
+		if err != nil {
+			return err
+		}
+		r.Body = zr.IOReadCloser()
+	case encodingLZ4:
+		r.Body = io.NopCloser(lz4.NewReader(r.Body))
+	default:
+		return nil
+	}
+	r.Header.Del("Content-Encoding")
+	r.ContentLength = -1
+	return nil
+}
+
+// writeData writes file contents as the response, compressed if the client
+// accepts a supported encoding and they are large enough
+func writeData(w http.ResponseWriter, r *http.Request, data []byte) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Add("Vary", "Accept-Encoding")
+	if len(data) >= compressMinSize {
+		if encoding := negotiateEncoding(r.Header.Get("Accept-Encoding")); encoding != "" {
+			if compressed, err := compress(encoding, data); err == nil {
+				w.Header().Set("Content-Encoding", encoding)
+				data = compressed
+			}
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":                      "",
+		"gzip":                  "",
+		"zstd":                  "zstd",
+		"gzip, LZ4":             "lz4",
+		"zstd;q=0, lz4":         "lz4",
+		"zstd;q=0.5, lz4;q=1.0": "zstd",
+	}
+	for header, want := range tests {
+		if got := negotiateEncoding(header); got != want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestCompressionRoundTrip(t *testing.T) {
+	data := []byte(strings.Repeat("agent transcript line\n", 200))
+
+	for _, encoding := range []string{encodingZstd, encodingLZ4} {
+		// Response: compressed when accepted
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/files?path=/a", nil)
+		r.Header.Set("Accept-Encoding", encoding)
+		w := httptest.NewRecorder()
+		writeData(w, r, data)
+		if got := w.Header().Get("Content-Encoding"); got != encoding {
+			t.Fatalf("expected Content-Encoding %q, got %q", encoding, got)
+		}
+		if w.Body.Len() >= len(data) {
+			t.Errorf("%s: expected compressed response, got %d bytes", encoding, w.Body.Len())
+		}
+
+		// Request: the compressed body decodes to the data
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/files?path=/a", bytes.NewReader(w.Body.Bytes()))
+		req.Header.Set("Content-Encoding", encoding)
+		if err := decodeBody(req); err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := io.ReadAll(req.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decoded, data) {
+			t.Errorf("%s: round trip mismatch", encoding)
+		}
+	}
+
+	// Small responses are sent as they are
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/files?path=/a", nil)
+	r.Header.Set("Accept-Encoding", encodingZstd)
+	w := httptest.NewRecorder()
+	writeData(w, r, []byte("small"))
+	if w.Header().Get("Content-Encoding") != "" || w.Body.String() != "small" {
+		t.Errorf("expected small response uncompressed")
+	}
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/mountablefs"
+)
+
+// ifMatch returns the version in the If-Match header of a request, "" if it
+// has none. Versions are sent as ETags: quoted, maybe weak ("W/" prefix).
+func ifMatch(r *http.Request) string {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	value = strings.TrimPrefix(value, "W/")
+	return strings.Trim(value, `"`)
+}
+
+// quoteETag returns a version as an ETag header value
+func quoteETag(version string) string {
+	return `"` + version + `"`
+}
+
+// conditional returns the file system that runs conditional operations, or
+// writes an error and returns nil if the handler's cannot
+func (h *Handler) conditional(w http.ResponseWriter) *mountablefs.MountableFS {
+	mfs, ok := h.fs.(*mountablefs.MountableFS)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "conditional operations are not supported")
+		return nil
+	}
+	return mfs
+}
+
+// setETag sets the ETag header to the version of a file after a conditional
+// write, for the writer's next condition
+func setETag(w http.ResponseWriter, mfs *mountablefs.MountableFS, path string) {
+	if version, err := mfs.Version(path); err == nil {
+		w.Header().Set("ETag", quoteETag(version))
+	}
+}
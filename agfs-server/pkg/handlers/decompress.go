@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/klauspost/compress/zstd"
+	log "github.com/sirupsen/logrus"
+)
+
+// Formats of compressed files that reads can decompress (see readDecompressed)
+const (
+	formatGzip = "gzip"
+	formatZstd = "zstd"
+	formatNone = "none"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// DecompressedHeader names the format a read with decompress decoded
+// ("gzip", "zstd", or "none" for a file that was not compressed)
+const DecompressedHeader = "X-Decompressed"
+
+// readDecompressed handles GET /files?path=<path>&decompress=<auto|gzip|zstd>
+// by streaming the decompressed contents of a .gz or .zst file, so that
+// clients need no decompression tools of their own, e.g. for archived logs in
+// s3fs. "true" or "auto" detect the format from the file's magic bytes and
+// return files that are not compressed as they are. offset and size apply to
+// the decompressed contents.
+func (h *Handler) readDecompressed(w http.ResponseWriter, r *http.Request, path, format string, offset, size int64) {
+	format = strings.ToLower(format)
+	if format == "true" {
+		format = "auto"
+	}
+	if format != "auto" && format != formatGzip && format != formatZstd {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid decompress parameter: %s (supported: auto, gzip, zstd)", format))
+		return
+	}
+
+	if err := h.limits().CheckRead("read", path, size); err != nil {
+		writeError(w, mapErrorToStatus(err), err.Error())
+		return
+	}
+
+	file, err := h.fs.Open(path)
+	if err != nil {
+		writeError(w, mapErrorToStatus(err), err.Error())
+		return
+	}
+	defer file.Close()
+
+	src := bufio.NewReader(file)
+	magic, _ := src.Peek(len(zstdMagic))
+	if format == "auto" {
+		switch {
+		case bytes.HasPrefix(magic, gzipMagic):
+			format = formatGzip
+		case bytes.HasPrefix(magic, zstdMagic):
+			format = formatZstd
+		default:
+			format = formatNone
+		}
+	}
+
+	var reader io.Reader = src
+	switch format {
+	case formatGzip:
+		zr, err := gzip.NewReader(src)
+		if err != nil {
+			err = filesystem.NewInvalidArgumentError("decompress", path, "not a gzip file: "+err.Error())
+			writeError(w, mapErrorToStatus(err), err.Error())
+			return
+		}
+		defer zr.Close()
+		reader = zr
+	case formatZstd:
+		if !bytes.HasPrefix(magic, zstdMagic) {
+			err := filesystem.NewInvalidArgumentError("decompress", path, "not a zstd file")
+			writeError(w, mapErrorToStatus(err), err.Error())
+			return
+		}
+		zr, err := zstd.NewReader(src)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		defer zr.Close()
+		reader = zr
+	}
+
+	if offset > 0 {
+		if _, err := io.CopyN(io.Discard, reader, offset); err != nil && err != io.EOF {
+			writeError(w, http.StatusBadRequest, "failed to decompress "+path+": "+err.Error())
+			return
+		}
+	}
+	if size >= 0 {
+		reader = io.LimitReader(reader, size)
+	} else if max := h.limits().MaxReadSize; max > 0 {
+		// A small file can decompress to any size: read one byte past the
+		// limit before sending anything, to fail with a status of its own
+		data, err := io.ReadAll(io.LimitReader(reader, max+1))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "failed to decompress "+path+": "+err.Error())
+			return
+		}
+		if int64(len(data)) > max {
+			err := filesystem.NewQuotaExceededError("read", path, "max_read_size", int64(len(data)), max)
+			writeError(w, mapErrorToStatus(err), err.Error())
+			return
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set(DecompressedHeader, format)
+	w.WriteHeader(http.StatusOK)
+
+	n, err := io.Copy(w, reader)
+	if h.trafficMonitor != nil && n > 0 {
+		h.trafficMonitor.RecordRead(n)
+	}
+	if err != nil && r.Context().Err() == nil {
+		// The status is sent already: abort the response, so that the client
+		// sees an error rather than contents cut short
+		log.Warnf("Failed to decompress %s after %d bytes: %v", path, n, err)
+		panic(http.ErrAbortHandler)
+	}
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/mountablefs"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/api"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/memfs"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestReadDecompressed(t *testing.T) {
+	logs := strings.Repeat("2026-10-15 request served\n", 100)
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(logs))
+	zw.Close()
+	enc, _ := zstd.NewWriter(nil)
+	zst := enc.EncodeAll([]byte(logs), nil)
+
+	fs := memfs.NewMemoryFS()
+	for p, data := range map[string][]byte{"/app.log.gz": gz.Bytes(), "/app.log.zst": zst, "/app.log": []byte(logs)} {
+		if _, err := fs.Write(p, data, -1, filesystem.WriteFlagCreate); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := NewHandler(fs, nil)
+	read := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ReadFile(w, httptest.NewRequest(http.MethodGet, "/api/v1/files?"+query, nil))
+		return w
+	}
+
+	for query, format := range map[string]string{
+		"path=/app.log.gz&decompress=true":  formatGzip,
+		"path=/app.log.zst&decompress=auto": formatZstd,
+		"path=/app.log.zst&decompress=zstd": formatZstd,
+		"path=/app.log&decompress=auto":     formatNone,
+	} {
+		w := read(query)
+		if w.Code != http.StatusOK || w.Body.String() != logs || w.Header().Get(DecompressedHeader) != format {
+			t.Errorf("%s: %d, %s, %d bytes", query, w.Code, w.Header().Get(DecompressedHeader), w.Body.Len())
+		}
+	}
+
+	// offset and size select from the decompressed contents
+	if w := read("path=/app.log.gz&decompress=gzip&offset=26&size=10"); w.Body.String() != "2026-10-15" {
+		t.Errorf("range read returned %q", w.Body.String())
+	}
+
+	for query, status := range map[string]int{
+		"path=/app.log&decompress=gzip":     http.StatusBadRequest,
+		"path=/app.log.gz&decompress=zstd":  http.StatusBadRequest,
+		"path=/app.log.gz&decompress=bzip2": http.StatusBadRequest,
+	} {
+		if w := read(query); w.Code != status {
+			t.Errorf("%s: expected %d, got %d", query, status, w.Code)
+		}
+	}
+	if w := read("path=/missing.gz&decompress=auto"); w.Code == http.StatusOK {
+		t.Error("expected reading a missing file to fail")
+	}
+}
+
+func TestReadDecompressedMaxReadSize(t *testing.T) {
+	// 1 MiB of zeros compresses to about a kilobyte
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(make([]byte, 1<<20))
+	zw.Close()
+
+	mfs := mountablefs.NewMountableFS(api.PoolConfig{})
+	plugin := memfs.NewMemFSPlugin()
+	if err := plugin.Initialize(map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.Mount("/memfs", plugin); err != nil {
+		t.Fatal(err)
+	}
+	defer mfs.Unmount("/memfs")
+	mfs.Limits = mountablefs.Limits{MaxReadSize: 64 << 10}
+	if _, err := mfs.Write("/memfs/zeros.gz", gz.Bytes(), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(mfs, nil)
+	read := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ReadFile(w, httptest.NewRequest(http.MethodGet, "/api/v1/files?"+query, nil))
+		return w
+	}
+
+	// Whole-file reads are limited by the decompressed size, not the stored one
+	if w := read("path=/memfs/zeros.gz&decompress=auto"); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %d with %d bytes", w.Code, w.Body.Len())
+	}
+	if w := read("path=/memfs/zeros.gz&decompress=auto&offset=1040000"); w.Code != http.StatusOK || w.Body.Len() != 1<<20-1040000 {
+		t.Errorf("expected the rest of the file under the limit, got %d with %d bytes", w.Code, w.Body.Len())
+	}
+	if w := read("path=/memfs/zeros.gz&decompress=auto&size=1000"); w.Code != http.StatusOK || w.Body.Len() != 1000 {
+		t.Errorf("expected a range under the limit, got %d with %d bytes", w.Code, w.Body.Len())
+	}
+}
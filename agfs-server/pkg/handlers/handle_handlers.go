@@ -1,14 +1,18 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/mountablefs"
 )
 
 // HandleOpenRequest represents the request to open a file handle
@@ -57,6 +61,12 @@ type HandleWriteResponse struct {
 	Position     int64 `json:"position"` // Current position after write
 }
 
+// HandleWriteVResponse represents the response for vectored writes
+type HandleWriteVResponse struct {
+	BytesWritten int   `json:"bytes_written"`
+	Written      []int `json:"written"` // Bytes written from each range, in order
+}
+
 // HandleSeekResponse represents the response for seek operations
 type HandleSeekResponse struct {
 	Position int64 `json:"position"`
@@ -68,6 +78,39 @@ type HandleRenewResponse struct {
 	Lease     int       `json:"lease"`
 }
 
+// HandleRenewBatchRequest represents a request to renew the leases of several
+// handles at once
+type HandleRenewBatchRequest struct {
+	HandleIDs []int64 `json:"handle_ids"`
+	Lease     int     `json:"lease,omitempty"` // Seconds (default: the server's lease)
+}
+
+// HandleRenewBatchResponse represents the outcome of a batch renewal
+type HandleRenewBatchResponse struct {
+	Renewals []mountablefs.HandleRenewal `json:"renewals"` // In the order of the request
+	Failed   int                         `json:"failed"`
+}
+
+// maxRenewBatch bounds the handles of one batch renewal
+const maxRenewBatch = 10000
+
+// ClientHeader names the client opening a handle, e.g. "agfs-fuse@host", so
+// that administrators can list or revoke the handles of one client. Without
+// it, handles are attributed to the host the request came from.
+const ClientHeader = "X-AGFS-Client"
+
+// handleClient returns the identity of the client of a request
+func handleClient(r *http.Request) string {
+	if client := strings.TrimSpace(r.Header.Get(ClientHeader)); client != "" {
+		return client
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 // parseOpenFlags parses numeric flag parameter to OpenFlag
 func parseOpenFlags(flagStr string) (filesystem.OpenFlag, error) {
 	if flagStr == "" {
@@ -123,7 +166,12 @@ func (h *Handler) OpenHandle(w http.ResponseWriter, r *http.Request) {
 		mode = uint32(m)
 	}
 
-	handle, err := handleFS.OpenHandle(path, flags, mode)
+	var handle filesystem.FileHandle
+	if mfs, ok := h.fs.(*mountablefs.MountableFS); ok {
+		handle, err = mfs.OpenHandleFor(handleClient(r), path, flags, mode)
+	} else {
+		handle, err = handleFS.OpenHandle(path, flags, mode)
+	}
 	if err != nil {
 		status := mapErrorToStatus(err)
 		writeError(w, status, err.Error())
@@ -131,17 +179,102 @@ func (h *Handler) OpenHandle(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Handle opened successfully
+	lease, expiresAt := h.handleLease(handle.ID())
 	response := HandleOpenResponse{
 		HandleID:  handle.ID(),
 		Path:      handle.Path(),
 		Flags:     int(handle.Flags()),
-		Lease:     60,
-		ExpiresAt: time.Now().Add(60 * time.Second),
+		Lease:     lease,
+		ExpiresAt: expiresAt,
 	}
 
 	writeJSON(w, http.StatusOK, response)
 }
 
+// handleLease returns the lease of a handle in seconds and when it expires.
+// Leases are tracked by MountableFS; handles of other file systems, and of a
+// server whose handles do not expire, report a nominal 60 second lease.
+func (h *Handler) handleLease(id int64) (int, time.Time) {
+	if mfs, ok := h.fs.(*mountablefs.MountableFS); ok {
+		if lease, expiresAt, err := mfs.HandleLeaseOf(id); err == nil && lease > 0 {
+			return int(lease / time.Second), expiresAt
+		}
+	}
+	return 60, time.Now().Add(60 * time.Second)
+}
+
+// parseLease parses a lease in seconds; "" and 0 mean the server's lease
+func parseLease(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid lease parameter: must be a number of seconds")
+	}
+	return time.Duration(n) * time.Second, nil
+}
+
+// RenewHandle handles POST /api/v1/handles/<id>/renew?lease=<seconds>
+func (h *Handler) RenewHandle(w http.ResponseWriter, r *http.Request, handleIDStr string) {
+	handleID, err := strconv.ParseInt(handleIDStr, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid handle ID: must be a number")
+		return
+	}
+	lease, err := parseLease(r.URL.Query().Get("lease"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	mfs, ok := h.fs.(*mountablefs.MountableFS)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "filesystem does not support handle leases")
+		return
+	}
+	if _, err := mfs.RenewHandle(handleID, lease); err != nil {
+		writeError(w, mapErrorToStatus(err), err.Error())
+		return
+	}
+	seconds, expiresAt := h.handleLease(handleID)
+	writeJSON(w, http.StatusOK, HandleRenewResponse{ExpiresAt: expiresAt, Lease: seconds})
+}
+
+// RenewHandles handles POST /api/v1/handles/renew: it renews the leases of
+// the handles listed in the body with one request, so that clients holding
+// many handles (e.g. FUSE mounts) need not renew each of them
+func (h *Handler) RenewHandles(w http.ResponseWriter, r *http.Request) {
+	var req HandleRenewBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(req.HandleIDs) > maxRenewBatch {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("too many handles: at most %d per request", maxRenewBatch))
+		return
+	}
+	if req.Lease < 0 {
+		writeError(w, http.StatusBadRequest, "lease must not be negative")
+		return
+	}
+
+	mfs, ok := h.fs.(*mountablefs.MountableFS)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "filesystem does not support handle leases")
+		return
+	}
+	response := HandleRenewBatchResponse{
+		Renewals: mfs.RenewHandles(req.HandleIDs, time.Duration(req.Lease)*time.Second),
+	}
+	for _, renewal := range response.Renewals {
+		if renewal.Error != "" {
+			response.Failed++
+		}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
 // GetHandle handles GET /api/v1/handles/<id>
 func (h *Handler) GetHandle(w http.ResponseWriter, r *http.Request, handleIDStr string) {
 	handleFS, err := h.getHandleFS()
@@ -163,12 +296,13 @@ func (h *Handler) GetHandle(w http.ResponseWriter, r *http.Request, handleIDStr
 		return
 	}
 
+	lease, expiresAt := h.handleLease(handle.ID())
 	response := HandleInfoResponse{
 		HandleID:   handle.ID(),
 		Path:       handle.Path(),
 		Flags:      int(handle.Flags()),
-		Lease:      60,
-		ExpiresAt:  time.Now().Add(60 * time.Second),
+		Lease:      lease,
+		ExpiresAt:  expiresAt,
 		CreatedAt:  time.Now(), // Placeholder - actual implementation would track this
 		LastAccess: time.Now(),
 	}
@@ -236,6 +370,10 @@ func (h *Handler) HandleRead(w http.ResponseWriter, r *http.Request, handleIDStr
 			size = s
 		}
 	}
+	if err := h.limits().CheckRead("read", handle.Path(), size); err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
 
 	// Check if offset is specified (use ReadAt)
 	offsetStr := r.URL.Query().Get("offset")
@@ -271,10 +409,8 @@ func (h *Handler) HandleRead(w http.ResponseWriter, r *http.Request, handleIDStr
 	}
 
 	// Return binary data
-	w.Header().Set("Content-Type", "application/octet-stream")
 	w.Header().Set("X-Bytes-Read", strconv.Itoa(n))
-	w.WriteHeader(http.StatusOK)
-	w.Write(data)
+	writeData(w, r, data)
 }
 
 // HandleWrite handles PUT /api/v1/handles/<id>/write?offset=<offset>
@@ -298,7 +434,11 @@ func (h *Handler) HandleWrite(w http.ResponseWriter, r *http.Request, handleIDSt
 		return
 	}
 
-	data, err := io.ReadAll(r.Body)
+	data, err := h.readBody(r, handle.Path())
+	if errors.Is(err, filesystem.ErrQuotaExceeded) {
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusBadRequest, "failed to read request body")
 		return
@@ -338,6 +478,165 @@ func (h *Handler) HandleWrite(w http.ResponseWriter, r *http.Request, handleIDSt
 	writeJSON(w, http.StatusOK, response)
 }
 
+// maxVectorRanges bounds the ranges of one vectored read or write
+const maxVectorRanges = 1024
+
+// byteRange is one range of a vectored read or write
+type byteRange struct {
+	offset, size int64
+}
+
+// parseRanges parses the ranges of a vectored operation,
+// "<offset>:<size>,<offset>:<size>,...", and returns them with their total size
+func parseRanges(s string) ([]byteRange, int64, error) {
+	if s == "" {
+		return nil, 0, fmt.Errorf("ranges parameter is required")
+	}
+	parts := strings.Split(s, ",")
+	if len(parts) > maxVectorRanges {
+		return nil, 0, fmt.Errorf("too many ranges: at most %d", maxVectorRanges)
+	}
+	ranges := make([]byteRange, len(parts))
+	var total int64
+	for i, part := range parts {
+		offsetStr, sizeStr, ok := strings.Cut(part, ":")
+		offset, err1 := strconv.ParseInt(offsetStr, 10, 64)
+		size, err2 := strconv.ParseInt(sizeStr, 10, 64)
+		if !ok || err1 != nil || err2 != nil || offset < 0 || size < 0 {
+			return nil, 0, fmt.Errorf("invalid range %q: must be <offset>:<size>", part)
+		}
+		ranges[i] = byteRange{offset: offset, size: size}
+		total += size
+	}
+	return ranges, total, nil
+}
+
+// HandleReadV handles GET /api/v1/handles/<id>/readv?ranges=<offset>:<size>,...
+// It reads several ranges with one request and returns their data one after
+// the other; the X-Range-Sizes header lists how many bytes were read for each
+// range, fewer than asked for past the end of the file.
+func (h *Handler) HandleReadV(w http.ResponseWriter, r *http.Request, handleIDStr string) {
+	handleFS, err := h.getHandleFS()
+	if err != nil {
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	}
+
+	handleID, err := strconv.ParseInt(handleIDStr, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid handle ID: must be a number")
+		return
+	}
+
+	handle, err := handleFS.GetHandle(handleID)
+	if err != nil {
+		status := mapErrorToStatus(err)
+		writeError(w, status, err.Error())
+		return
+	}
+
+	ranges, size, err := parseRanges(r.URL.Query().Get("ranges"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := h.limits().CheckRead("readv", handle.Path(), size); err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+	vecs := make([]filesystem.IOVec, len(ranges))
+	for i, rg := range ranges {
+		vecs[i] = filesystem.IOVec{Offset: rg.offset, Data: make([]byte, rg.size)}
+	}
+
+	counts, err := filesystem.ReadV(handle, vecs)
+	if err != nil {
+		writeError(w, mapErrorToStatus(err), err.Error())
+		return
+	}
+
+	data := make([]byte, 0, size)
+	sizes := make([]string, len(counts))
+	for i, n := range counts {
+		data = append(data, vecs[i].Data[:n]...)
+		sizes[i] = strconv.Itoa(n)
+	}
+
+	// Record traffic
+	if h.trafficMonitor != nil && len(data) > 0 {
+		h.trafficMonitor.RecordRead(int64(len(data)))
+	}
+
+	w.Header().Set("X-Bytes-Read", strconv.Itoa(len(data)))
+	w.Header().Set("X-Range-Sizes", strings.Join(sizes, ","))
+	writeData(w, r, data)
+}
+
+// HandleWriteV handles PUT /api/v1/handles/<id>/writev?ranges=<offset>:<size>,...
+// The body holds the data of the ranges one after the other, which are
+// written in order.
+func (h *Handler) HandleWriteV(w http.ResponseWriter, r *http.Request, handleIDStr string) {
+	handleFS, err := h.getHandleFS()
+	if err != nil {
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	}
+
+	handleID, err := strconv.ParseInt(handleIDStr, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid handle ID: must be a number")
+		return
+	}
+
+	handle, err := handleFS.GetHandle(handleID)
+	if err != nil {
+		status := mapErrorToStatus(err)
+		writeError(w, status, err.Error())
+		return
+	}
+
+	ranges, size, err := parseRanges(r.URL.Query().Get("ranges"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := h.readBody(r, handle.Path())
+	if errors.Is(err, filesystem.ErrQuotaExceeded) {
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "failed to read request body")
+		return
+	}
+	if int64(len(data)) != size {
+		writeError(w, http.StatusBadRequest, "body size does not match the sizes of the ranges")
+		return
+	}
+	vecs := make([]filesystem.IOVec, len(ranges))
+	for i, rg := range ranges {
+		vecs[i] = filesystem.IOVec{Offset: rg.offset, Data: data[:rg.size:rg.size]}
+		data = data[rg.size:]
+	}
+
+	// Record traffic
+	if h.trafficMonitor != nil && size > 0 {
+		h.trafficMonitor.RecordWrite(size)
+	}
+
+	counts, err := filesystem.WriteV(handle, vecs)
+	if err != nil {
+		writeError(w, mapErrorToStatus(err), err.Error())
+		return
+	}
+	response := HandleWriteVResponse{Written: counts}
+	for _, n := range counts {
+		response.BytesWritten += n
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
 // HandleSeek handles POST /api/v1/handles/<id>/seek?offset=<offset>&whence=<0|1|2>
 func (h *Handler) HandleSeek(w http.ResponseWriter, r *http.Request, handleIDStr string) {
 	handleFS, err := h.getHandleFS()
@@ -415,7 +714,7 @@ func (h *Handler) HandleSync(w http.ResponseWriter, r *http.Request, handleIDStr
 	}
 
 	if err := handle.Sync(); err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeError(w, mapErrorToStatus(err), err.Error())
 		return
 	}
 
@@ -457,6 +756,7 @@ func (h *Handler) HandleStat(w http.ResponseWriter, r *http.Request, handleIDStr
 		ModTime: info.ModTime.Format(time.RFC3339Nano),
 		IsDir:   info.IsDir,
 		Meta:    info.Meta,
+		Version: filesystem.FileVersion(info),
 	}
 
 	writeJSON(w, http.StatusOK, response)
@@ -544,6 +844,15 @@ func (h *Handler) SetupHandleRoutes(mux *http.ServeMux) {
 		h.OpenHandle(w, r)
 	})
 
+	// POST /api/v1/handles/renew - Renew the leases of several handles
+	mux.HandleFunc("/api/v1/handles/renew", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		h.RenewHandles(w, r)
+	})
+
 	// Handle operations on specific handles: /api/v1/handles/<id>/*
 	mux.HandleFunc("/api/v1/handles/", func(w http.ResponseWriter, r *http.Request) {
 		// Extract handle ID and operation from path
@@ -596,6 +905,18 @@ func (h *Handler) SetupHandleRoutes(mux *http.ServeMux) {
 				return
 			}
 			h.HandleWrite(w, r, handleID)
+		case "readv":
+			if r.Method != http.MethodGet {
+				writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+				return
+			}
+			h.HandleReadV(w, r, handleID)
+		case "writev":
+			if r.Method != http.MethodPut {
+				writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+				return
+			}
+			h.HandleWriteV(w, r, handleID)
 		case "seek":
 			if r.Method != http.MethodPost {
 				writeError(w, http.StatusMethodNotAllowed, "method not allowed")
@@ -620,6 +941,12 @@ func (h *Handler) SetupHandleRoutes(mux *http.ServeMux) {
 				return
 			}
 			h.HandleStream(w, r, handleID)
+		case "renew":
+			if r.Method != http.MethodPost {
+				writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+				return
+			}
+			h.RenewHandle(w, r, handleID)
 		default:
 			writeError(w, http.StatusNotFound, "unknown operation: "+operation)
 		}
@@ -627,15 +954,21 @@ func (h *Handler) SetupHandleRoutes(mux *http.ServeMux) {
 }
 
 // ListHandles handles GET /api/v1/handles - list all active handles
-// Note: This returns an empty list as handles are managed per-request
-// and there is no central registry. Handles are tracked within each
-// mounted filesystem instance.
 func (h *Handler) ListHandles(w http.ResponseWriter, r *http.Request) {
-	// Return empty list - handles are managed by individual filesystem instances
 	response := HandleListResponse{
 		Handles: []HandleInfoResponse{},
-		Count:   0,
 		Max:     10000,
 	}
+	// Handles are tracked by MountableFS; other file systems have no registry
+	if mfs, ok := h.fs.(*mountablefs.MountableFS); ok {
+		for _, handle := range mfs.ListOpenHandles() {
+			response.Handles = append(response.Handles, HandleInfoResponse{
+				HandleID: handle.ID,
+				Path:     handle.Path,
+				Flags:    handle.Flags,
+			})
+		}
+	}
+	response.Count = len(response.Handles)
 	writeJSON(w, http.StatusOK, response)
 }
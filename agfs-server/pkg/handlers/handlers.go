@@ -67,7 +67,8 @@ type FileInfoResponse struct {
 	Mode    uint32              `json:"mode"`
 	ModTime string              `json:"modTime"`
 	IsDir   bool                `json:"isDir"`
-	Meta    filesystem.MetaData `json:"meta,omitempty"` // Structured metadata
+	Meta    filesystem.MetaData `json:"meta,omitempty"`    // Structured metadata
+	Version string              `json:"version,omitempty"` // For If-Match conditions
 }
 
 // ListResponse represents directory listing response
@@ -85,6 +86,15 @@ type RenameRequest struct {
 	NewPath string `json:"newPath"`
 }
 
+// RenameResponse represents a rename response. Atomic is false for paths on
+// different mounts, renamed by copying and deleting.
+type RenameResponse struct {
+	Message     string `json:"message"`
+	Atomic      bool   `json:"atomic"`
+	FilesCopied int64  `json:"files_copied,omitempty"`
+	BytesCopied int64  `json:"bytes_copied,omitempty"`
+}
+
 // ChmodRequest represents a chmod request
 type ChmodRequest struct {
 	Mode uint32 `json:"mode"`
@@ -140,10 +150,60 @@ func mapErrorToStatus(err error) int {
 	if errors.Is(err, filesystem.ErrNotSupported) {
 		return http.StatusNotImplemented
 	}
+	if errors.Is(err, filesystem.ErrNoSpace) {
+		return http.StatusInsufficientStorage
+	}
+	if errors.Is(err, filesystem.ErrUnavailable) {
+		return http.StatusServiceUnavailable
+	}
+	if errors.Is(err, filesystem.ErrQuotaExceeded) {
+		return http.StatusRequestEntityTooLarge
+	}
+	if errors.Is(err, filesystem.ErrConflict) {
+		return http.StatusConflict
+	}
+	if errors.Is(err, filesystem.ErrRevoked) {
+		return http.StatusGone
+	}
 	return http.StatusInternalServerError
 }
 
-// CreateFile handles POST /files?path=<path>
+// limits returns the request size limits of the file system
+func (h *Handler) limits() mountablefs.Limits {
+	if mfs, ok := h.fs.(*mountablefs.MountableFS); ok {
+		return mfs.Limits
+	}
+	return mountablefs.Limits{}
+}
+
+// readBody reads the (decoded) body of a write request, failing with a
+// QuotaExceeded error instead of buffering more than the max write size
+func (h *Handler) readBody(r *http.Request, path string) ([]byte, error) {
+	if err := decodeBody(r); err != nil {
+		return nil, err
+	}
+
+	limits := h.limits()
+	if limits.MaxWriteSize <= 0 {
+		return io.ReadAll(r.Body)
+	}
+	if err := limits.CheckWrite("write", path, r.ContentLength); err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, limits.MaxWriteSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if err := limits.CheckWrite("write", path, int64(len(data))); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// CreateFile handles POST /files?path=<path>[&exclusive=true]
+// With exclusive=true the file is created atomically and only if it does not
+// exist yet (409 otherwise), as for open(2) with O_CREAT|O_EXCL
 func (h *Handler) CreateFile(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Query().Get("path")
 	if path == "" {
@@ -151,7 +211,13 @@ func (h *Handler) CreateFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.fs.Create(path); err != nil {
+	var err error
+	if r.URL.Query().Get("exclusive") == "true" {
+		_, err = h.fs.Write(path, nil, -1, filesystem.WriteFlagCreate|filesystem.WriteFlagExclusive)
+	} else {
+		err = h.fs.Create(path)
+	}
+	if err != nil {
 		status := mapErrorToStatus(err)
 		writeError(w, status, err.Error())
 		return
@@ -188,7 +254,7 @@ func (h *Handler) CreateDirectory(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusCreated, SuccessResponse{Message: "directory created"})
 }
 
-// ReadFile handles GET /files?path=<path>&offset=<offset>&size=<size>&stream=<true|false>
+// ReadFile handles GET /files?path=<path>&offset=<offset>&size=<size>&stream=<true|false>[&decompress=<auto|gzip|zstd>]
 func (h *Handler) ReadFile(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Query().Get("path")
 	if path == "" {
@@ -196,6 +262,12 @@ func (h *Handler) ReadFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A past version of the file (see asof.go)
+	if r.URL.Query().Has("asOf") {
+		h.readAsOf(w, r, path)
+		return
+	}
+
 	// Check if streaming mode is requested
 	stream := r.URL.Query().Get("stream") == "true"
 	if stream {
@@ -207,7 +279,8 @@ func (h *Handler) ReadFile(w http.ResponseWriter, r *http.Request) {
 	offset := int64(0)
 	size := int64(-1) // -1 means read all
 
-	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+	offsetStr := r.URL.Query().Get("offset")
+	if offsetStr != "" {
 		if parsedOffset, err := strconv.ParseInt(offsetStr, 10, 64); err == nil {
 			offset = parsedOffset
 		} else {
@@ -216,6 +289,30 @@ func (h *Handler) ReadFile(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// The end of the file: the last lines, the last bytes (a negative
+	// offset), or the data appended after offset (see tail.go)
+	if r.URL.Query().Get("follow") == "true" {
+		if offset < 0 {
+			writeError(w, http.StatusBadRequest, "invalid offset parameter")
+			return
+		}
+		h.followFile(w, r, path, offset, offsetStr != "")
+		return
+	}
+	if tailStr := r.URL.Query().Get("tail"); tailStr != "" {
+		lines, err := strconv.Atoi(tailStr)
+		if err != nil || lines < 0 {
+			writeError(w, http.StatusBadRequest, "invalid tail parameter")
+			return
+		}
+		h.readTail(w, r, path, lines, 0)
+		return
+	}
+	if offset < 0 {
+		h.readTail(w, r, path, -1, -offset)
+		return
+	}
+
 	if sizeStr := r.URL.Query().Get("size"); sizeStr != "" {
 		if parsedSize, err := strconv.ParseInt(sizeStr, 10, 64); err == nil {
 			size = parsedSize
@@ -225,13 +322,16 @@ func (h *Handler) ReadFile(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if format := r.URL.Query().Get("decompress"); format != "" && format != "false" {
+		h.readDecompressed(w, r, path, format, offset, size)
+		return
+	}
+
 	data, err := h.fs.Read(path, offset, size)
 	if err != nil {
 		// Check if it's EOF (reached end of file)
 		if err == io.EOF {
-			w.Header().Set("Content-Type", "application/octet-stream")
-			w.WriteHeader(http.StatusOK)
-			w.Write(data) // Return partial data with 200 OK
+			writeData(w, r, data) // Return partial data with 200 OK
 			// Record downstream traffic
 			if h.trafficMonitor != nil && len(data) > 0 {
 				h.trafficMonitor.RecordRead(int64(len(data)))
@@ -244,9 +344,7 @@ func (h *Handler) ReadFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/octet-stream")
-	w.WriteHeader(http.StatusOK)
-	w.Write(data)
+	writeData(w, r, data)
 
 	// Record downstream traffic
 	if h.trafficMonitor != nil && len(data) > 0 {
@@ -254,7 +352,7 @@ func (h *Handler) ReadFile(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// WriteFile handles PUT /files?path=<path>
+// WriteFile handles PUT /files?path=<path>[&sync=true]
 func (h *Handler) WriteFile(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Query().Get("path")
 	if path == "" {
@@ -262,7 +360,11 @@ func (h *Handler) WriteFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, err := io.ReadAll(r.Body)
+	data, err := h.readBody(r, path)
+	if errors.Is(err, filesystem.ErrQuotaExceeded) {
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusBadRequest, "failed to read request body")
 		return
@@ -276,7 +378,25 @@ func (h *Handler) WriteFile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Use default flags: create if not exists, truncate (like the old behavior)
-	bytesWritten, err := h.fs.Write(path, data, -1, filesystem.WriteFlagCreate|filesystem.WriteFlagTruncate)
+	flags := filesystem.WriteFlagCreate | filesystem.WriteFlagTruncate
+	if r.URL.Query().Get("sync") == "true" {
+		// Answer once the data is durable
+		flags |= filesystem.WriteFlagSync
+	}
+	var bytesWritten int64
+	version := ifMatch(r)
+	if version != "" {
+		mfs := h.conditional(w)
+		if mfs == nil {
+			return
+		}
+		bytesWritten, err = mfs.WriteIf(path, data, -1, flags, version)
+		if err == nil {
+			setETag(w, mfs, path)
+		}
+	} else {
+		bytesWritten, err = h.fs.Write(path, data, -1, flags)
+	}
 	if err != nil {
 		log.Errorf("[handler] WriteFile failed: path=%s, err=%v", path, err)
 		status := mapErrorToStatus(err)
@@ -298,11 +418,22 @@ func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
 	}
 
 	recursive := r.URL.Query().Get("recursive") == "true"
+	version := ifMatch(r)
 
 	var err error
-	if recursive {
+	switch {
+	case version != "" && recursive:
+		writeError(w, http.StatusBadRequest, "If-Match is not supported for recursive deletes")
+		return
+	case version != "":
+		mfs := h.conditional(w)
+		if mfs == nil {
+			return
+		}
+		err = mfs.RemoveIf(path, version)
+	case recursive:
 		err = h.fs.RemoveAll(path)
-	} else {
+	default:
 		err = h.fs.Remove(path)
 	}
 
@@ -321,6 +452,10 @@ func (h *Handler) ListDirectory(w http.ResponseWriter, r *http.Request) {
 	if path == "" {
 		path = "/"
 	}
+	if r.URL.Query().Has("asOf") {
+		h.listDirectoryAsOf(w, r, path)
+		return
+	}
 
 	files, err := h.fs.ReadDir(path)
 	if err != nil {
@@ -339,6 +474,7 @@ func (h *Handler) ListDirectory(w http.ResponseWriter, r *http.Request) {
 			ModTime: f.ModTime.Format(time.RFC3339Nano),
 			IsDir:   f.IsDir,
 			Meta:    f.Meta,
+			Version: filesystem.FileVersion(&f),
 		})
 	}
 
@@ -352,6 +488,10 @@ func (h *Handler) Stat(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "path parameter is required")
 		return
 	}
+	if r.URL.Query().Has("asOf") {
+		h.statAsOf(w, r, path)
+		return
+	}
 
 	info, err := h.fs.Stat(path)
 	if err != nil {
@@ -373,8 +513,10 @@ func (h *Handler) Stat(w http.ResponseWriter, r *http.Request) {
 		ModTime: info.ModTime.Format(time.RFC3339Nano),
 		IsDir:   info.IsDir,
 		Meta:    info.Meta,
+		Version: filesystem.FileVersion(info),
 	}
 
+	w.Header().Set("ETag", quoteETag(response.Version))
 	writeJSON(w, http.StatusOK, response)
 }
 
@@ -397,13 +539,29 @@ func (h *Handler) Rename(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.fs.Rename(path, req.NewPath); err != nil {
+	mfs, ok := h.fs.(*mountablefs.MountableFS)
+	if !ok {
+		if err := h.fs.Rename(path, req.NewPath); err != nil {
+			writeError(w, mapErrorToStatus(err), err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, RenameResponse{Message: "renamed", Atomic: true})
+		return
+	}
+
+	result, err := mfs.Move(path, req.NewPath)
+	if err != nil {
 		status := mapErrorToStatus(err)
 		writeError(w, status, err.Error())
 		return
 	}
 
-	writeJSON(w, http.StatusOK, SuccessResponse{Message: "renamed"})
+	writeJSON(w, http.StatusOK, RenameResponse{
+		Message:     "renamed",
+		Atomic:      result.Atomic,
+		FilesCopied: result.Files,
+		BytesCopied: result.Bytes,
+	})
 }
 
 // Chmod handles POST /chmod?path=<path>
@@ -548,11 +706,18 @@ func (h *Handler) Capabilities(w http.ResponseWriter, r *http.Request) {
 	response := CapabilitiesResponse{
 		Version: h.version,
 		Features: []string{
-			"handlefs", // File handles for stateful operations
-			"grep",     // Server-side grep
-			"digest",   // Server-side checksums
-			"stream",   // Streaming read
-			"touch",    // Touch/update timestamp
+			"handlefs",         // File handles for stateful operations
+			"grep",             // Server-side grep
+			"digest",           // Server-side checksums
+			"stream",           // Streaming read
+			"touch",            // Touch/update timestamp
+			"sync",             // Path fsync and synchronous writes
+			"create_exclusive", // Atomic create-if-absent (POST /files?exclusive=true)
+			"decompress",       // Decompressed reads of gzip/zstd files (GET /files?decompress=auto)
+			"as_of",            // Reads as of a past time on mounts that keep history (GET /files?asOf=<time>)
+			"batch",            // Batch stats and small-file reads (POST /stat/batch, POST /files/batch)
+			"compression:zstd", // zstd Content-Encoding of file contents
+			"compression:lz4",  // lz4 Content-Encoding of file contents
 		},
 	}
 	writeJSON(w, http.StatusOK, response)
@@ -737,6 +902,31 @@ func (h *Handler) Truncate(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, SuccessResponse{Message: "truncated"})
 }
 
+// Sync handles POST /sync?path=<path>
+// Flushes a file to durable storage; it succeeds once the plugin has done
+// so, or right away for plugins whose writes are durable when they return
+func (h *Handler) Sync(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		writeError(w, http.StatusBadRequest, "path parameter is required")
+		return
+	}
+
+	if syncer, ok := h.fs.(filesystem.Syncer); ok {
+		if err := syncer.Sync(path); err != nil {
+			status := mapErrorToStatus(err)
+			writeError(w, status, err.Error())
+			return
+		}
+	} else if _, err := h.fs.Stat(path); err != nil {
+		status := mapErrorToStatus(err)
+		writeError(w, status, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, SuccessResponse{Message: "synced"})
+}
+
 // SetupRoutes sets up all HTTP routes with /api/v1 prefix
 func (h *Handler) SetupRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/v1/health", h.Health)
@@ -863,6 +1053,8 @@ func (h *Handler) SetupRoutes(mux *http.ServeMux) {
 		}
 		h.Stat(w, r)
 	})
+	mux.HandleFunc("/api/v1/stat/batch", h.StatMany)
+	mux.HandleFunc("/api/v1/files/batch", h.ReadMany)
 	mux.HandleFunc("/api/v1/rename", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
@@ -884,6 +1076,13 @@ func (h *Handler) SetupRoutes(mux *http.ServeMux) {
 		}
 		h.Truncate(w, r)
 	})
+	mux.HandleFunc("/api/v1/sync", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		h.Sync(w, r)
+	})
 	mux.HandleFunc("/api/v1/grep", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
@@ -1079,6 +1278,11 @@ func (h *Handler) Grep(w http.ResponseWriter, r *http.Request) {
 		}
 		// Attempt custom grep (e.g., vector search)
 		customResults, err := cg.CustomGrep(req.Path, req.Pattern, limit)
+		if errors.Is(err, filesystem.ErrInvalidArgument) {
+			// e.g. an invalid option of a vector search query
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		if err == nil && len(customResults) > 0 {
 			// Convert custom results to GrepMatch format
 			var matches []GrepMatch
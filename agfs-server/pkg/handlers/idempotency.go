@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/mountablefs"
+)
+
+const (
+	// IdempotencyKeyHeader carries the client-supplied key of a mutating
+	// request; requests sent again with the same key within the window get
+	// the response of the first one instead of being executed again
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// idempotentReplayedHeader is set on responses replayed from the cache
+	idempotentReplayedHeader = "Idempotent-Replayed"
+
+	// maxIdempotencyKeyLength bounds the keys clients can send
+	maxIdempotencyKeyLength = 255
+)
+
+// IdempotencyCache remembers the responses of mutating requests sent with an
+// Idempotency-Key, so that clients retrying after a timeout do not run an
+// operation twice, e.g. enqueue a queuefs message or insert sqlfs2 rows again
+type IdempotencyCache struct {
+	// MaxBodySize is the largest body of a request with a key, which is read
+	// into memory to be hashed; larger ones fail with 413 (0: no limit)
+	MaxBodySize int64
+
+	window    time.Duration
+	mu        sync.Mutex
+	entries   map[string]*idempotencyEntry
+	lastSweep time.Time
+}
+
+// idempotencyEntry is the response to a key, or the request running for it
+type idempotencyEntry struct {
+	request string        // Method, URI and body hash the key was first used for
+	done    chan struct{} // Closed when the response is recorded
+	expires time.Time     // Zero while the request runs
+
+	status int
+	header http.Header
+	body   []byte
+}
+
+// NewIdempotencyCache creates a cache remembering responses for window
+func NewIdempotencyCache(window time.Duration) *IdempotencyCache {
+	return &IdempotencyCache{
+		window:    window,
+		entries:   make(map[string]*idempotencyEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+// isMutating checks if a request method modifies the file system
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// Middleware deduplicates the mutating requests of next that carry an
+// Idempotency-Key. The first request with a key runs; the ones sent with it
+// while it runs wait for it, and the ones sent after it get its response,
+// until the window expires. A key reused for another request, including the
+// same one with another body, is rejected with 422. The bodies of requests
+// with a key are read into memory to be hashed before they run, up to
+// MaxBodySize. Server errors (5xx) are not remembered, so that a retry after
+// one runs the operation again.
+func (c *IdempotencyCache) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" || !isMutating(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			writeError(w, http.StatusBadRequest, "Idempotency-Key is too long")
+			return
+		}
+
+		// Keys are scoped to the credentials of the client
+		scoped := r.Header.Get("Authorization") + "\x00" + key
+		body, err := c.readBody(r)
+		if err != nil {
+			writeError(w, mapErrorToStatus(err), err.Error())
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(body)
+		request := r.Method + " " + r.URL.RequestURI() + " " + hex.EncodeToString(sum[:])
+		for {
+			entry, owner := c.acquire(scoped, request)
+			if owner {
+				c.record(scoped, entry, w, r, next)
+				return
+			}
+			if entry.request != request {
+				writeError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used for another request")
+				return
+			}
+
+			select {
+			case <-entry.done:
+			case <-r.Context().Done():
+				return
+			}
+			if entry.status != 0 {
+				entry.replay(w)
+				return
+			}
+			// The first request failed and was forgotten: run this one
+		}
+	})
+}
+
+// readBody reads the body of a request with a key, failing with a
+// QuotaExceeded error instead of buffering more than MaxBodySize
+func (c *IdempotencyCache) readBody(r *http.Request) ([]byte, error) {
+	limits := mountablefs.Limits{MaxWriteSize: c.MaxBodySize}
+	path := r.URL.Query().Get("path")
+	if err := limits.CheckWrite("write", path, r.ContentLength); err != nil {
+		return nil, err
+	}
+
+	body := io.Reader(r.Body)
+	if c.MaxBodySize > 0 {
+		body = io.LimitReader(r.Body, c.MaxBodySize+1)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, filesystem.NewInvalidArgumentError("body", nil, "failed to read request body: "+err.Error())
+	}
+	if err := limits.CheckWrite("write", path, int64(len(data))); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// acquire returns the entry of a key, creating it if there is none, in which
+// case the caller owns it and must record the response
+func (c *IdempotencyCache) acquire(key, request string) (*idempotencyEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.lastSweep) >= c.window {
+		for k, e := range c.entries {
+			if !e.expires.IsZero() && now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.lastSweep = now
+	}
+
+	if entry, ok := c.entries[key]; ok && (entry.expires.IsZero() || now.Before(entry.expires)) {
+		return entry, false
+	}
+	entry := &idempotencyEntry{request: request, done: make(chan struct{})}
+	c.entries[key] = entry
+	return entry, true
+}
+
+// record runs the request and remembers its response for the key
+func (c *IdempotencyCache) record(key string, entry *idempotencyEntry, w http.ResponseWriter, r *http.Request, next http.Handler) {
+	rec := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+	defer func() {
+		c.mu.Lock()
+		if rec.status >= 500 {
+			delete(c.entries, key)
+		} else {
+			entry.status = rec.status
+			entry.header = w.Header().Clone()
+			entry.body = rec.body.Bytes()
+			entry.expires = time.Now().Add(c.window)
+		}
+		c.mu.Unlock()
+		close(entry.done)
+	}()
+	next.ServeHTTP(rec, r)
+}
+
+// replay writes the recorded response
+func (e *idempotencyEntry) replay(w http.ResponseWriter) {
+	for name, values := range e.header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(idempotentReplayedHeader, "true")
+	w.WriteHeader(e.status)
+	w.Write(e.body)
+}
+
+// recordingWriter writes a response through and keeps a copy of it
+type recordingWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.wroteHeader = true
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	var calls atomic.Int32
+	status := http.StatusOK
+	h := NewIdempotencyCache(time.Minute).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("X-Call", strconv.Itoa(int(n)))
+		writeJSON(w, status, SuccessResponse{Message: "enqueued"})
+	}))
+	send := func(method, target, key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, nil)
+		if key != "" {
+			r.Header.Set(IdempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	first := send(http.MethodPut, "/api/v1/files?path=/queue/enqueue", "k1")
+	retry := send(http.MethodPut, "/api/v1/files?path=/queue/enqueue", "k1")
+	if calls.Load() != 1 {
+		t.Fatalf("expected the retry not to run, got %d calls", calls.Load())
+	}
+	if retry.Code != first.Code || retry.Body.String() != first.Body.String() || retry.Header().Get("X-Call") != "1" {
+		t.Errorf("retry got %d %q, want the first response", retry.Code, retry.Body.String())
+	}
+	if retry.Header().Get(idempotentReplayedHeader) != "true" || first.Header().Get(idempotentReplayedHeader) != "" {
+		t.Error("expected only the retry to be marked as replayed")
+	}
+
+	if w := send(http.MethodPut, "/api/v1/files?path=/other", "k1"); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("key reused for another request: got %d, want 422", w.Code)
+	}
+
+	// The same request with another body is another request
+	sendBody := func(body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPut, "/api/v1/files?path=/queue/enqueue", strings.NewReader(body))
+		r.Header.Set(IdempotencyKeyHeader, "k5")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	if w := sendBody("job 1"); w.Code != http.StatusOK || calls.Load() != 2 {
+		t.Fatalf("first request with a body: got %d (%d calls)", w.Code, calls.Load())
+	}
+	if w := sendBody("job 1"); w.Header().Get(idempotentReplayedHeader) != "true" {
+		t.Errorf("retry with the same body: expected a replay, got %d", w.Code)
+	}
+	if w := sendBody("job 2"); w.Code != http.StatusUnprocessableEntity || calls.Load() != 2 {
+		t.Errorf("key reused with another body: got %d (%d calls), want 422", w.Code, calls.Load())
+	}
+
+	send(http.MethodPut, "/api/v1/files?path=/queue/enqueue", "k2")
+	send(http.MethodPut, "/api/v1/files?path=/queue/enqueue", "")
+	send(http.MethodGet, "/api/v1/files?path=/queue/enqueue", "k3")
+	send(http.MethodGet, "/api/v1/files?path=/queue/enqueue", "k3")
+	if calls.Load() != 6 {
+		t.Errorf("expected other keys, no key and reads to run, got %d calls", calls.Load())
+	}
+
+	// Server errors are not remembered
+	status = http.StatusServiceUnavailable
+	send(http.MethodPost, "/api/v1/directories?path=/a", "k4")
+	status = http.StatusOK
+	if w := send(http.MethodPost, "/api/v1/directories?path=/a", "k4"); w.Code != http.StatusOK || calls.Load() != 8 {
+		t.Errorf("expected retry after a server error to run, got %d (%d calls)", w.Code, calls.Load())
+	}
+}
+
+func TestIdempotencyMiddlewareBodyLimit(t *testing.T) {
+	var calls atomic.Int32
+	c := NewIdempotencyCache(time.Minute)
+	c.MaxBodySize = 16
+	h := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		writeJSON(w, http.StatusOK, SuccessResponse{Message: "written"})
+	}))
+	send := func(body string, contentLength int64) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPut, "/api/v1/files?path=/big", strings.NewReader(body))
+		r.ContentLength = contentLength
+		r.Header.Set(IdempotencyKeyHeader, "big")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	big := strings.Repeat("x", 17)
+	if w := send(big, int64(len(big))); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized write: got %d, want 413", w.Code)
+	}
+	// A chunked body is cut off at the limit
+	if w := send(big, -1); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized chunked write: got %d, want 413", w.Code)
+	}
+	if calls.Load() != 0 {
+		t.Errorf("expected oversized writes not to run, got %d calls", calls.Load())
+	}
+
+	// The rejected key is not taken
+	if w := send("small", -1); w.Code != http.StatusOK || calls.Load() != 1 {
+		t.Errorf("write within the limit: got %d (%d calls)", w.Code, calls.Load())
+	}
+}
+
+func TestIdempotencyMiddlewareConcurrent(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	h := NewIdempotencyCache(time.Minute).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		writeJSON(w, http.StatusOK, SuccessResponse{Message: "inserted"})
+	}))
+
+	var wg sync.WaitGroup
+	codes := make([]int, 4)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := httptest.NewRequest(http.MethodPut, "/api/v1/files?path=/sqlfs2/db/t/1/data", nil)
+			r.Header.Set(IdempotencyKeyHeader, "insert-1")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			codes[i] = w.Code
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("expected concurrent duplicates to run once, got %d calls", calls.Load())
+	}
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("request %d got %d", i, code)
+		}
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"strings"
 
 	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/middleware"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/mountablefs"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
 	log "github.com/sirupsen/logrus"
@@ -20,7 +21,8 @@ import (
 
 // PluginHandler handles plugin management operations
 type PluginHandler struct {
-	mfs *mountablefs.MountableFS
+	mfs   *mountablefs.MountableFS
+	audit *AuditLog // Records mounts and plugin loads, if set
 }
 
 // NewPluginHandler creates a new plugin handler
@@ -28,11 +30,18 @@ func NewPluginHandler(mfs *mountablefs.MountableFS) *PluginHandler {
 	return &PluginHandler{mfs: mfs}
 }
 
+// SetAuditLog sets the audit log that mount and plugin changes are recorded in
+func (ph *PluginHandler) SetAuditLog(audit *AuditLog) {
+	ph.audit = audit
+}
+
 // MountInfo represents information about a mounted plugin
 type MountInfo struct {
-	Path       string                 `json:"path"`
-	PluginName string                 `json:"pluginName"`
-	Config     map[string]interface{} `json:"config,omitempty"`
+	Path       string                    `json:"path"`
+	PluginName string                    `json:"pluginName"`
+	Config     map[string]interface{}    `json:"config,omitempty"`
+	Health     *mountablefs.HealthStatus `json:"health,omitempty"` // Only for plugins with a health check
+	Init       *mountablefs.InitStatus   `json:"init,omitempty"`   // Only for mounts initialized after mounting
 }
 
 // ListMountsResponse represents the response for listing mounts
@@ -49,7 +58,9 @@ func (ph *PluginHandler) ListMounts(w http.ResponseWriter, r *http.Request) {
 		mountInfos = append(mountInfos, MountInfo{
 			Path:       mount.Path,
 			PluginName: mount.Plugin.Name(),
-			Config:     mount.Config,
+			Config:     mountablefs.RedactConfig(mount.Plugin, mount.Config),
+			Health:     mount.Health(),
+			Init:       mount.InitStatus(),
 		})
 	}
 
@@ -74,7 +85,9 @@ func (ph *PluginHandler) Unmount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := ph.mfs.Unmount(req.Path); err != nil {
+	err := ph.mfs.Unmount(req.Path)
+	ph.audit.Record(r, "unmount", req.Path, "", err)
+	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -107,7 +120,9 @@ func (ph *PluginHandler) Mount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := ph.mfs.MountPlugin(req.FSType, req.Path, req.Config); err != nil {
+	err := ph.mfs.MountPlugin(req.FSType, req.Path, req.Config)
+	ph.audit.Record(r, "mount", req.Path, req.FSType, err)
+	if err != nil {
 		// First check for typed errors
 		if errors.Is(err, filesystem.ErrAlreadyExists) {
 			writeError(w, http.StatusConflict, err.Error())
@@ -129,7 +144,6 @@ func (ph *PluginHandler) Mount(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, SuccessResponse{Message: "plugin mounted"})
 }
 
-
 // LoadPluginRequest represents a request to load an external plugin
 type LoadPluginRequest struct {
 	LibraryPath string `json:"library_path"`
@@ -285,6 +299,7 @@ func (ph *PluginHandler) LoadPlugin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	plugin, err := ph.mfs.LoadExternalPlugin(libraryPath)
+	ph.audit.Record(r, "load_plugin", req.LibraryPath, "", err)
 	if err != nil {
 		// Clean up temporary file if it was downloaded
 		if tmpFile != "" {
@@ -327,7 +342,9 @@ func (ph *PluginHandler) UnloadPlugin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := ph.mfs.UnloadExternalPlugin(req.LibraryPath); err != nil {
+	err := ph.mfs.UnloadExternalPlugin(req.LibraryPath)
+	ph.audit.Record(r, "unload_plugin", req.LibraryPath, "", err)
+	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -370,7 +387,7 @@ func (ph *PluginHandler) ListPlugins(w http.ResponseWriter, r *http.Request) {
 		pluginNamesSet[pluginName] = true
 		pluginMountsMap[pluginName] = append(pluginMountsMap[pluginName], PluginMountInfo{
 			Path:   mount.Path,
-			Config: mount.Config,
+			Config: mountablefs.RedactConfig(mount.Plugin, mount.Config),
 		})
 		// Store plugin instance for getting config params
 		if _, exists := pluginInstanceMap[pluginName]; !exists {
@@ -424,6 +441,68 @@ func (ph *PluginHandler) ListPlugins(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, ListPluginsResponse{Plugins: plugins})
 }
 
+// configParams returns the config parameters of a plugin, from a mounted
+// instance if there is one, or nil if no plugin has that name
+func (ph *PluginHandler) configParams(pluginName string) ([]plugin.ConfigParameter, bool) {
+	for _, mount := range ph.mfs.GetMounts() {
+		if mount.Plugin.Name() == pluginName {
+			return mount.Plugin.GetConfigParams(), true
+		}
+	}
+	if p := ph.mfs.CreatePlugin(pluginName); p != nil {
+		return p.GetConfigParams(), true
+	}
+	return nil, false
+}
+
+// mountParams returns the config parameters of a mount of a plugin: the
+// plugin's and, if it declares any, those of the middlewares
+func mountParams(params []plugin.ConfigParameter) []plugin.ConfigParameter {
+	if len(params) == 0 {
+		return params
+	}
+	return append(append([]plugin.ConfigParameter{}, params...), middleware.Params...)
+}
+
+// PluginSchemasResponse represents the response for listing config schemas
+type PluginSchemasResponse struct {
+	Schemas map[string]map[string]interface{} `json:"schemas"`
+}
+
+// GetPluginSchema handles GET /plugins/schema?name=<plugin>
+// It returns the JSON Schema of the config of one plugin, or of every plugin
+// that can be mounted by name when no name is given.
+func (ph *PluginHandler) GetPluginSchema(w http.ResponseWriter, r *http.Request) {
+	if name := r.URL.Query().Get("name"); name != "" {
+		params, ok := ph.configParams(name)
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("unknown plugin: %s", name))
+			return
+		}
+		writeJSON(w, http.StatusOK, plugin.ConfigSchema(name, mountParams(params)))
+		return
+	}
+
+	names := map[string]bool{}
+	for _, name := range ph.mfs.GetBuiltinPluginNames() {
+		names[name] = true
+	}
+	for name := range ph.mfs.GetPluginNameToPathMap() {
+		names[name] = true
+	}
+	for _, mount := range ph.mfs.GetMounts() {
+		names[mount.Plugin.Name()] = true
+	}
+
+	schemas := make(map[string]map[string]interface{}, len(names))
+	for name := range names {
+		if params, ok := ph.configParams(name); ok {
+			schemas[name] = plugin.ConfigSchema(name, mountParams(params))
+		}
+	}
+	writeJSON(w, http.StatusOK, PluginSchemasResponse{Schemas: schemas})
+}
+
 // SetupRoutes sets up plugin management routes with /api/v1 prefix
 func (ph *PluginHandler) SetupRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/v1/mounts", func(w http.ResponseWriter, r *http.Request) {
@@ -474,4 +553,12 @@ func (ph *PluginHandler) SetupRoutes(mux *http.ServeMux) {
 		}
 		ph.UnloadPlugin(w, r)
 	})
+
+	mux.HandleFunc("/api/v1/plugins/schema", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		ph.GetPluginSchema(w, r)
+	})
 }
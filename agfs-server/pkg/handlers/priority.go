@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/mountablefs"
+)
+
+// PriorityHeader carries the priority class of a request, "interactive" (the
+// default) or "batch", which the scheduler of a mount with max_concurrency
+// uses to run interactive requests first when the mount is busy
+const PriorityHeader = "X-AGFS-Priority"
+
+// PriorityMiddleware makes the requests on files of a mount with a scheduler
+// wait for it to let them run (see mountablefs.Scheduler), in the priority
+// class of their X-AGFS-Priority header. Streams, which run until the client
+// stops reading, are left out of scheduling so that they do not hold slots.
+func PriorityMiddleware(mfs *mountablefs.MountableFS, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		priority, err := mountablefs.ParsePriority(r.Header.Get(PriorityHeader))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		var release func()
+		if id, ok := scheduledHandle(r); ok {
+			release, err = mfs.ScheduleHandle(r.Context(), id, priority)
+		} else if path, ok := scheduledPath(r); ok {
+			release, err = mfs.Schedule(r.Context(), path, priority)
+		} else {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err != nil {
+			// The client gave up waiting
+			writeError(w, http.StatusServiceUnavailable, "request canceled while queued: "+err.Error())
+			return
+		}
+		defer release()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// scheduledPath returns the path of a request on files, unless it streams
+func scheduledPath(r *http.Request) (string, bool) {
+	if strings.HasPrefix(r.URL.Path, "/api/v1/admin/") || r.URL.Query().Get("stream") == "true" {
+		return "", false
+	}
+	path := r.URL.Query().Get("path")
+	return path, path != ""
+}
+
+// scheduledHandle returns the ID of the handle of a request on one, unless it
+// streams
+func scheduledHandle(r *http.Request) (int64, bool) {
+	rest, ok := strings.CutPrefix(r.URL.Path, "/api/v1/handles/")
+	if !ok {
+		return 0, false
+	}
+	idStr, operation, _ := strings.Cut(rest, "/")
+	if operation == "stream" {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	return id, err == nil
+}
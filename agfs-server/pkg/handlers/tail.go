@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+// NextOffsetHeader is the offset to follow a file from after a tail or
+// follow read, i.e. the end of the data returned
+const NextOffsetHeader = "X-Next-Offset"
+
+const (
+	// defaultFollowWait is how long a follow read waits for new data
+	defaultFollowWait = 30 * time.Second
+	// maxFollowWait bounds the wait parameter of follow reads
+	maxFollowWait = 5 * time.Minute
+	// followPollInterval is how often a follow read checks for new data
+	followPollInterval = 200 * time.Millisecond
+	// maxFollowRead bounds the data returned by one follow read; the rest
+	// is returned by the next one
+	maxFollowRead = 4 << 20
+)
+
+// readTail handles GET /files?path=<path>&tail=<lines> and
+// GET /files?path=<path>&offset=-<bytes>, returning the last lines or bytes of
+// a file without reading it whole, e.g. for tail -n 100 on a large log. The
+// NextOffsetHeader tells where to follow the file from.
+func (h *Handler) readTail(w http.ResponseWriter, r *http.Request, path string, lines int, n int64) {
+	var data []byte
+	var next int64
+	var err error
+	if lines >= 0 {
+		data, next, err = filesystem.Tail(h.fs, path, lines, h.limits().MaxReadSize)
+	} else {
+		if max := h.limits().MaxReadSize; max > 0 && n > max {
+			err = filesystem.NewQuotaExceededError("read", path, "max_read_size", n, max)
+		} else {
+			data, next, err = filesystem.TailBytes(h.fs, path, n)
+		}
+	}
+	if err != nil {
+		writeError(w, mapErrorToStatus(err), err.Error())
+		return
+	}
+	w.Header().Set(NextOffsetHeader, strconv.FormatInt(next, 10))
+	writeData(w, r, data)
+	if h.trafficMonitor != nil && len(data) > 0 {
+		h.trafficMonitor.RecordRead(int64(len(data)))
+	}
+}
+
+// followFile handles GET /files?path=<path>&follow=true[&offset=<n>][&wait=<seconds>],
+// a long poll for tail -f: it returns the data appended after offset (the
+// end of the file if omitted) as soon as there is some, or nothing once wait
+// elapses. If the file was truncated below offset, it is returned from its
+// start. The NextOffsetHeader is the offset of the next follow read.
+func (h *Handler) followFile(w http.ResponseWriter, r *http.Request, path string, offset int64, hasOffset bool) {
+	wait := defaultFollowWait
+	if s := r.URL.Query().Get("wait"); s != "" {
+		seconds, err := strconv.Atoi(s)
+		if err != nil || seconds < 0 {
+			writeError(w, http.StatusBadRequest, "invalid wait parameter")
+			return
+		}
+		wait = min(time.Duration(seconds)*time.Second, maxFollowWait)
+	}
+
+	info, err := h.fs.Stat(path)
+	if err != nil {
+		writeError(w, mapErrorToStatus(err), err.Error())
+		return
+	}
+	if info.IsDir {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("%s is a directory", path))
+		return
+	}
+	if !hasOffset {
+		offset = info.Size
+	}
+
+	size := info.Size
+	if size == offset {
+		ctx, cancel := context.WithTimeout(r.Context(), wait)
+		size, err = filesystem.WaitForGrowth(ctx, h.fs, path, offset, followPollInterval)
+		cancel()
+		if err != nil {
+			writeError(w, mapErrorToStatus(err), err.Error())
+			return
+		}
+	}
+	if size < offset {
+		offset = 0 // Truncated
+	}
+
+	var data []byte
+	if size > offset {
+		data, err = h.fs.Read(path, offset, min(size-offset, maxFollowRead))
+		if err != nil && err != io.EOF {
+			writeError(w, mapErrorToStatus(err), err.Error())
+			return
+		}
+	}
+	w.Header().Set(NextOffsetHeader, strconv.FormatInt(offset+int64(len(data)), 10))
+	writeData(w, r, data)
+	if h.trafficMonitor != nil && len(data) > 0 {
+		h.trafficMonitor.RecordRead(int64(len(data)))
+	}
+}
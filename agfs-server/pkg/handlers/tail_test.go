@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/memfs"
+)
+
+func TestReadTail(t *testing.T) {
+	// Larger than the chunks Tail reads, so that it reads several
+	var lines []string
+	for i := 1; i <= 20000; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	logs := strings.Join(lines, "\n") + "\n"
+	fs := memfs.NewMemoryFS()
+	if _, err := fs.Write("/app.log", []byte(logs), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(fs, nil)
+	read := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ReadFile(w, httptest.NewRequest(http.MethodGet, "/api/v1/files?"+query, nil))
+		return w
+	}
+
+	w := read("path=/app.log&tail=3")
+	if w.Code != http.StatusOK || w.Body.String() != "line 19998\nline 19999\nline 20000\n" {
+		t.Fatalf("tail=3: %d %q", w.Code, w.Body.String())
+	}
+	if next := w.Header().Get(NextOffsetHeader); next != fmt.Sprint(len(logs)) {
+		t.Errorf("expected the next offset to be the end of the file, got %s", next)
+	}
+	if w := read("path=/app.log&tail=15000"); w.Body.String() != strings.Join(lines[5000:], "\n")+"\n" {
+		t.Errorf("tail=15000 returned %d bytes", w.Body.Len())
+	}
+	if w := read("path=/app.log&tail=50000"); w.Body.String() != logs {
+		t.Errorf("a tail longer than the file should return it whole, got %d bytes", w.Body.Len())
+	}
+	if w := read("path=/app.log&offset=-11"); w.Body.String() != "line 20000\n" {
+		t.Errorf("offset=-11 returned %q", w.Body.String())
+	}
+
+	for query, status := range map[string]int{
+		"path=/app.log&tail=x":                http.StatusBadRequest,
+		"path=/app.log&tail=-1":               http.StatusBadRequest,
+		"path=/app.log&follow=true&offset=-1": http.StatusBadRequest,
+	} {
+		if w := read(query); w.Code != status {
+			t.Errorf("%s: expected %d, got %d", query, status, w.Code)
+		}
+	}
+	if w := read("path=/missing&tail=10"); w.Code == http.StatusOK {
+		t.Errorf("expected tailing a missing file to fail")
+	}
+}
+
+func TestFollowFile(t *testing.T) {
+	fs := memfs.NewMemoryFS()
+	if _, err := fs.Write("/app.log", []byte("first\n"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(fs, nil)
+	follow := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ReadFile(w, httptest.NewRequest(http.MethodGet, "/api/v1/files?path=/app.log&follow=true&"+query, nil))
+		return w
+	}
+
+	// Nothing new: the poll times out empty
+	if w := follow("offset=6&wait=0"); w.Code != http.StatusOK || w.Body.Len() != 0 || w.Header().Get(NextOffsetHeader) != "6" {
+		t.Fatalf("expected an empty poll, got %d %q %s", w.Code, w.Body.String(), w.Header().Get(NextOffsetHeader))
+	}
+
+	// Data appended while polling is returned
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		fs.Write("/app.log", []byte("second\n"), -1, filesystem.WriteFlagAppend)
+	}()
+	w := follow("offset=6&wait=5")
+	if w.Body.String() != "second\n" || w.Header().Get(NextOffsetHeader) != "13" {
+		t.Fatalf("expected the appended line, got %q %s", w.Body.String(), w.Header().Get(NextOffsetHeader))
+	}
+
+	// Data already there after offset is returned at once
+	if w := follow("offset=0"); w.Body.String() != "first\nsecond\n" {
+		t.Errorf("expected the whole file, got %q", w.Body.String())
+	}
+
+	// A truncated file is followed from its start
+	if _, err := fs.Write("/app.log", []byte("new\n"), -1, filesystem.WriteFlagTruncate); err != nil {
+		t.Fatal(err)
+	}
+	if w := follow("offset=13&wait=1"); w.Body.String() != "new\n" || w.Header().Get(NextOffsetHeader) != "4" {
+		t.Errorf("expected the truncated file from its start, got %q %s", w.Body.String(), w.Header().Get(NextOffsetHeader))
+	}
+}
@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	log "github.com/sirupsen/logrus"
+)
+
+// AdmissionRequest is posted to an admission webhook before a write. Paths
+// are within the mount.
+type AdmissionRequest struct {
+	Operation string `json:"operation"`          // "write", "create" or "rename"
+	Path      string `json:"path"`               // File written, created or renamed to
+	OldPath   string `json:"old_path,omitempty"` // File renamed, for "rename"
+	Offset    int64  `json:"offset"`             // Write position, -1 to overwrite or append
+	Append    bool   `json:"append,omitempty"`   // The write appends
+	Content   []byte `json:"content,omitempty"`  // Data written, base64 encoded in JSON
+}
+
+// AdmissionResponse is the answer of an admission webhook. Content, if set,
+// replaces the data written, e.g. with personal data scrubbed.
+type AdmissionResponse struct {
+	Allowed bool    `json:"allowed"`
+	Reason  string  `json:"reason,omitempty"`  // Why the write is rejected
+	Content *[]byte `json:"content,omitempty"` // Data to write instead
+}
+
+// AdmissionConfig configures an admission webhook
+type AdmissionConfig struct {
+	URL      string        // Webhook posted an AdmissionRequest before writes
+	Paths    []string      // Prefixes of the paths admitted, within the mount (default: all)
+	Timeout  time.Duration // Timeout of each call (default: 5s)
+	FailOpen bool          // Let writes through when the webhook cannot be reached
+}
+
+// Admission returns a middleware that asks a webhook whether writes to some
+// paths are allowed, and what to write. Writes the webhook rejects fail with
+// filesystem.ErrPermissionDenied; unless the config fails open, so do writes
+// when the webhook is unreachable or answers with an error status, with
+// filesystem.ErrUnavailable.
+func Admission(cfg AdmissionConfig) (Middleware, error) {
+	if !strings.HasPrefix(cfg.URL, "http://") && !strings.HasPrefix(cfg.URL, "https://") {
+		return nil, unsupported("admission_webhook", cfg.URL)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	for i, p := range cfg.Paths {
+		cfg.Paths[i] = filesystem.NormalizePath(p)
+	}
+	client := &http.Client{Timeout: cfg.Timeout}
+	return func(fs filesystem.FileSystem) filesystem.FileSystem {
+		return &admissionFS{FileSystem: fs, cfg: cfg, client: client}
+	}, nil
+}
+
+// admissionFS passes writes to the paths of an admission webhook through it.
+// Reads are not changed; handles and streams of the wrapped file system are
+// not exposed, so that all writes are admitted.
+type admissionFS struct {
+	filesystem.FileSystem
+	cfg    AdmissionConfig
+	client *http.Client
+}
+
+// admitted returns true if writes to a path go through the webhook
+func (a *admissionFS) admitted(path string) bool {
+	if len(a.cfg.Paths) == 0 {
+		return true
+	}
+	path = filesystem.NormalizePath(path)
+	for _, prefix := range a.cfg.Paths {
+		if prefix == "/" || path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// admit asks the webhook about a request, returning the content to write
+func (a *admissionFS) admit(req AdmissionRequest) ([]byte, error) {
+	resp, err := a.call(req)
+	if err != nil {
+		if a.cfg.FailOpen {
+			log.Warnf("Admission webhook failed, allowing %s of %s: %v", req.Operation, req.Path, err)
+			return req.Content, nil
+		}
+		return nil, filesystem.NewUnavailableError(req.Path, "admission webhook: "+err.Error())
+	}
+	if !resp.Allowed {
+		reason := resp.Reason
+		if reason == "" {
+			reason = "rejected by admission webhook"
+		}
+		return nil, filesystem.NewPermissionDeniedError(req.Operation, req.Path, reason)
+	}
+	if resp.Content != nil {
+		return *resp.Content, nil
+	}
+	return req.Content, nil
+}
+
+// call posts a request to the webhook
+func (a *admissionFS) call(req AdmissionRequest) (*AdmissionResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), a.cfg.Timeout)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, a.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	httpResp, err := a.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(httpResp.Body, 512))
+		return nil, fmt.Errorf("HTTP %d: %s", httpResp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	var resp AdmissionResponse
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return &resp, nil
+}
+
+func (a *admissionFS) Write(path string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	if !a.admitted(path) {
+		return a.FileSystem.Write(path, data, offset, flags)
+	}
+	content, err := a.admit(AdmissionRequest{
+		Operation: "write",
+		Path:      path,
+		Offset:    offset,
+		Append:    flags&filesystem.WriteFlagAppend != 0,
+		Content:   data,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if _, err := a.FileSystem.Write(path, content, offset, flags); err != nil {
+		return 0, err
+	}
+	// The caller wrote data, whatever the webhook made of it
+	return int64(len(data)), nil
+}
+
+func (a *admissionFS) Create(path string) error {
+	if a.admitted(path) {
+		if _, err := a.admit(AdmissionRequest{Operation: "create", Path: path, Offset: -1}); err != nil {
+			return err
+		}
+	}
+	return a.FileSystem.Create(path)
+}
+
+// Rename admits files moved into the paths of the webhook, which would
+// otherwise get there unchecked
+func (a *admissionFS) Rename(oldPath, newPath string) error {
+	if a.admitted(newPath) && !a.admitted(oldPath) {
+		if _, err := a.admit(AdmissionRequest{Operation: "rename", Path: newPath, OldPath: oldPath, Offset: -1}); err != nil {
+			return err
+		}
+	}
+	return a.FileSystem.Rename(oldPath, newPath)
+}
+
+// Truncate implements filesystem.Truncater; truncating is not admitted
+func (a *admissionFS) Truncate(path string, size int64) error {
+	if truncater, ok := a.FileSystem.(filesystem.Truncater); ok {
+		return truncater.Truncate(path, size)
+	}
+	return filesystem.NewNotSupportedError("truncate", path)
+}
+
+func (a *admissionFS) OpenWrite(path string) (io.WriteCloser, error) {
+	if !a.admitted(path) {
+		return a.FileSystem.OpenWrite(path)
+	}
+	return filesystem.NewBufferedWriter(path, a.Write), nil
+}
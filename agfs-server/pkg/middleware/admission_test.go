@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+// newAdmissionWebhook serves a webhook that rejects contents containing
+// "forbidden" and redacts "secret", recording the requests it gets
+func newAdmissionWebhook(t *testing.T, requests *[]AdmissionRequest) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req AdmissionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid admission request: %v", err)
+		}
+		*requests = append(*requests, req)
+
+		resp := AdmissionResponse{Allowed: true}
+		switch {
+		case bytes.Contains(req.Content, []byte("forbidden")):
+			resp = AdmissionResponse{Allowed: false, Reason: "policy violation"}
+		case bytes.Contains(req.Content, []byte("secret")):
+			redacted := bytes.ReplaceAll(req.Content, []byte("secret"), []byte("******"))
+			resp.Content = &redacted
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestAdmission(t *testing.T) {
+	var requests []AdmissionRequest
+	srv := newAdmissionWebhook(t, &requests)
+	inner := newMemFS(t)
+	if err := inner.Mkdir("/in", 0755); err != nil {
+		t.Fatal(err)
+	}
+	mw, err := Admission(AdmissionConfig{URL: srv.URL, Paths: []string{"/in/"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := mw(inner)
+
+	n, err := fs.Write("/in/a.txt", []byte("my secret"), -1, filesystem.WriteFlagCreate)
+	if err != nil || n != 9 {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	if data, _ := inner.Read("/in/a.txt", 0, -1); string(data) != "my ******" {
+		t.Errorf("expected the content rewritten, got %q", data)
+	}
+
+	_, err = fs.Write("/in/a.txt", []byte("forbidden"), -1, filesystem.WriteFlagCreate)
+	if !errors.Is(err, filesystem.ErrPermissionDenied) {
+		t.Errorf("expected the write rejected, got %v", err)
+	}
+
+	// Other paths are not admitted
+	count := len(requests)
+	if _, err := fs.Write("/out.txt", []byte("forbidden secret"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != count {
+		t.Error("expected writes outside the admission paths not to call the webhook")
+	}
+
+	// ... unless moved in
+	if err := fs.Rename("/out.txt", "/in/moved.txt"); err != nil {
+		t.Fatal(err)
+	}
+	last := requests[len(requests)-1]
+	if last.Operation != "rename" || last.Path != "/in/moved.txt" || last.OldPath != "/out.txt" {
+		t.Errorf("unexpected rename request %+v", last)
+	}
+
+	w, err := fs.OpenWrite("/in/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, "another secret")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := inner.Read("/in/b.txt", 0, -1); string(data) != "another ******" {
+		t.Errorf("expected streamed writes admitted, got %q", data)
+	}
+}
+
+func TestAdmissionUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down for maintenance", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	mw, err := Admission(AdmissionConfig{URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = mw(newMemFS(t)).Write("/a.txt", []byte("x"), -1, filesystem.WriteFlagCreate)
+	if !errors.Is(err, filesystem.ErrUnavailable) {
+		t.Errorf("expected writes to fail closed, got %v", err)
+	}
+
+	mw, err = Admission(AdmissionConfig{URL: srv.URL, FailOpen: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mw(newMemFS(t)).Write("/a.txt", []byte("x"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Errorf("expected writes to fail open, got %v", err)
+	}
+}
+
+func TestAdmissionFromConfig(t *testing.T) {
+	mws, rest, err := FromConfig(map[string]interface{}{
+		"admission_webhook": "http://localhost:9000/admit",
+		"admission_paths":   []interface{}{"/uploads"},
+		"admission_timeout": "2s",
+		"bucket":            "b",
+	})
+	if err != nil || len(mws) != 1 {
+		t.Fatalf("FromConfig = %d middlewares, %v", len(mws), err)
+	}
+	if len(rest) != 1 || rest["bucket"] != "b" {
+		t.Errorf("expected admission keys removed, got %v", rest)
+	}
+
+	for _, cfg := range []map[string]interface{}{
+		{"admission_webhook": "ftp://localhost/admit"},
+		{"admission_webhook": "http://localhost/admit", "admission_paths": 3},
+		{"admission_webhook": "http://localhost/admit", "admission_timeout": "soon"},
+	} {
+		if _, _, err := FromConfig(cfg); err == nil {
+			t.Errorf("expected error for %v", cfg)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+)
+
+// Compressed files start with a header: compressMagic, the codec and the
+// uncompressed size (uint64, big endian). Files without it are read as they
+// are, so compression can be enabled on a mount that already has files.
+const (
+	compressMagic     = "\x89AGZ"
+	compressHeaderLen = len(compressMagic) + 1 + 8
+)
+
+// Codec IDs in the header
+const (
+	codecZstd byte = 1
+	codecLZ4  byte = 2
+)
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// Compression returns a middleware that compresses file contents at rest with
+// algorithm ("zstd" or "lz4")
+func Compression(algorithm string) (Middleware, error) {
+	var codec compressCodec
+	switch algorithm {
+	case "zstd":
+		codec = compressCodec(codecZstd)
+	case "lz4":
+		codec = compressCodec(codecLZ4)
+	default:
+		return nil, unsupported("compression", algorithm)
+	}
+	mu := &sync.Mutex{}
+	return func(fs filesystem.FileSystem) filesystem.FileSystem {
+		return &contentFS{FileSystem: fs, codec: codec, mu: mu}
+	}, nil
+}
+
+// compressCodec compresses contents with the codec of its ID
+type compressCodec byte
+
+// encode compresses data with its header, or returns data as is if it does
+// not get smaller
+func (c compressCodec) encode(data []byte) ([]byte, error) {
+	header := make([]byte, compressHeaderLen)
+	copy(header, compressMagic)
+	header[len(compressMagic)] = byte(c)
+	binary.BigEndian.PutUint64(header[len(compressMagic)+1:], uint64(len(data)))
+
+	var out []byte
+	switch byte(c) {
+	case codecZstd:
+		out = zstdEncoder.EncodeAll(data, header)
+	case codecLZ4:
+		buf := make([]byte, compressHeaderLen+lz4.CompressBlockBound(len(data)))
+		copy(buf, header)
+		n, err := lz4.CompressBlock(data, buf[compressHeaderLen:], nil)
+		if err == nil && n > 0 {
+			out = buf[:compressHeaderLen+n]
+		}
+	}
+
+	if out == nil || (len(out) >= len(data) && !bytes.HasPrefix(data, []byte(compressMagic))) {
+		return data, nil
+	}
+	return out, nil
+}
+
+// decode returns the content of a stored file
+func (c compressCodec) decode(stored []byte) ([]byte, error) {
+	size, ok := compressedSize(stored)
+	if !ok {
+		return stored, nil
+	}
+	payload := stored[compressHeaderLen:]
+	switch stored[len(compressMagic)] {
+	case codecZstd:
+		return zstdDecoder.DecodeAll(payload, make([]byte, 0, size))
+	case codecLZ4:
+		data := make([]byte, size)
+		n, err := lz4.UncompressBlock(payload, data)
+		if err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	}
+	return nil, fmt.Errorf("unknown compression codec %d", stored[len(compressMagic)])
+}
+
+// size returns the uncompressed size in the header of a stored file
+func (c compressCodec) size(info *filesystem.FileInfo, read func(offset, size int64) ([]byte, error)) int64 {
+	if info.Size < int64(compressHeaderLen) {
+		return info.Size
+	}
+	header, err := read(0, int64(compressHeaderLen))
+	if err != nil && err != io.EOF {
+		return info.Size
+	}
+	if size, ok := compressedSize(header); ok {
+		return size
+	}
+	return info.Size
+}
+
+// compressedSize returns the uncompressed size in the header of a stored file
+func compressedSize(stored []byte) (int64, bool) {
+	if len(stored) < compressHeaderLen || !bytes.HasPrefix(stored, []byte(compressMagic)) {
+		return 0, false
+	}
+	return int64(binary.BigEndian.Uint64(stored[len(compressMagic)+1:])), true
+}
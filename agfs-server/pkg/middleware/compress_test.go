@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/memfs"
+)
+
+func newMemFS(t *testing.T) filesystem.FileSystem {
+	p := memfs.NewMemFSPlugin()
+	if err := p.Initialize(map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	return p.GetFileSystem()
+}
+
+func TestCompression(t *testing.T) {
+	for _, algorithm := range []string{"zstd", "lz4"} {
+		t.Run(algorithm, func(t *testing.T) {
+			inner := newMemFS(t)
+			mw, err := Compression(algorithm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fs := mw(inner)
+
+			text := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog\n", 100))
+			if _, err := fs.Write("/a.txt", text, -1, filesystem.WriteFlagCreate|filesystem.WriteFlagTruncate); err != nil {
+				t.Fatalf("write failed: %v", err)
+			}
+
+			stored, err := inner.Read("/a.txt", 0, -1)
+			if err != nil && err != io.EOF {
+				t.Fatal(err)
+			}
+			if len(stored) >= len(text) || !bytes.HasPrefix(stored, []byte(compressMagic)) {
+				t.Errorf("expected compressed content, stored %d bytes for %d", len(stored), len(text))
+			}
+
+			data, err := fs.Read("/a.txt", 0, -1)
+			if err != nil && err != io.EOF {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, text) {
+				t.Errorf("round trip mismatch")
+			}
+			data, err = fs.Read("/a.txt", 4, 5)
+			if err != nil && err != io.EOF {
+				t.Fatal(err)
+			}
+			if string(data) != "quick" {
+				t.Errorf("expected ranged read %q, got %q", "quick", data)
+			}
+
+			info, err := fs.Stat("/a.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Size != int64(len(text)) {
+				t.Errorf("expected Stat size %d, got %d", len(text), info.Size)
+			}
+			entries, err := fs.ReadDir("/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range entries {
+				if e.Name == "a.txt" && e.Size != int64(len(text)) {
+					t.Errorf("expected ReadDir size %d, got %d", len(text), e.Size)
+				}
+			}
+
+			// Writes at an offset and appends patch the decompressed content
+			if _, err := fs.Write("/a.txt", []byte("QUICK"), 4, 0); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := fs.Write("/a.txt", []byte("end"), -1, filesystem.WriteFlagAppend); err != nil {
+				t.Fatal(err)
+			}
+			want := append(append([]byte{}, text...), "end"...)
+			copy(want[4:], "QUICK")
+			data, _ = fs.Read("/a.txt", 0, -1)
+			if !bytes.Equal(data, want) {
+				t.Errorf("patched content mismatch")
+			}
+
+			// New files can be created by appending
+			if _, err := fs.Write("/b.txt", []byte("new"), -1, filesystem.WriteFlagCreate|filesystem.WriteFlagAppend); err != nil {
+				t.Fatal(err)
+			}
+			if data, _ := fs.Read("/b.txt", 0, -1); string(data) != "new" {
+				t.Errorf("expected %q, got %q", "new", data)
+			}
+
+			if err := fs.(filesystem.Truncater).Truncate("/a.txt", 9); err != nil {
+				t.Fatal(err)
+			}
+			if data, _ := fs.Read("/a.txt", 0, -1); string(data) != "the QUICK" {
+				t.Errorf("expected truncated content, got %q", data)
+			}
+		})
+	}
+}
+
+func TestCompressionExistingFiles(t *testing.T) {
+	inner := newMemFS(t)
+	if _, err := inner.Write("/plain.txt", []byte("stored before compression"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatal(err)
+	}
+	mw, _ := Compression("zstd")
+	fs := mw(inner)
+
+	data, err := fs.Read("/plain.txt", 0, -1)
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	if string(data) != "stored before compression" {
+		t.Errorf("expected uncompressed file as is, got %q", data)
+	}
+
+	// Small files that do not compress are stored as they are
+	if _, err := fs.Write("/small.txt", []byte("hi"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := inner.Read("/small.txt", 0, -1); string(stored) != "hi" {
+		t.Errorf("expected small file stored raw, got %q", stored)
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	mws, rest, err := FromConfig(map[string]interface{}{"compression": "lz4", "bucket": "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mws) != 1 {
+		t.Errorf("expected 1 middleware, got %d", len(mws))
+	}
+	if _, ok := rest["compression"]; ok || rest["bucket"] != "b" {
+		t.Errorf("expected middleware keys removed, got %v", rest)
+	}
+
+	if _, _, err := FromConfig(map[string]interface{}{"compression": "gzip"}); err == nil {
+		t.Error("expected error for unsupported compression")
+	}
+	mws, _, err = FromConfig(map[string]interface{}{})
+	if err != nil || len(mws) != 0 {
+		t.Errorf("expected no middleware, got %d (%v)", len(mws), err)
+	}
+}
@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"sync"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+)
+
+// contentCodec transforms whole file contents on their way to and from the
+// wrapped file system
+type contentCodec interface {
+	encode(data []byte) ([]byte, error)
+	decode(stored []byte) ([]byte, error)
+
+	// size returns the decoded size of a file, reading its stored content
+	// with read if its info is not enough
+	size(info *filesystem.FileInfo, read func(offset, size int64) ([]byte, error)) int64
+}
+
+// contentFS stores file contents encoded by a codec in the wrapped file
+// system. Reads decode the whole file, and writes at an offset re-encode it,
+// so it suits files that are written whole, like most agent data. Names are
+// not changed, and handles and streams of the wrapped file system are not
+// exposed.
+type contentFS struct {
+	filesystem.FileSystem
+	codec contentCodec
+	mu    *sync.Mutex // Serializes read-modify-write updates of the mount
+}
+
+// load reads and decodes a whole file
+func (c *contentFS) load(path string) ([]byte, error) {
+	stored, err := c.FileSystem.Read(path, 0, -1)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(stored) == 0 {
+		return stored, nil
+	}
+	data, err := c.codec.decode(stored)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return data, nil
+}
+
+// store encodes and writes a whole file
+func (c *contentFS) store(path string, data []byte, flags filesystem.WriteFlag) error {
+	stored, err := c.codec.encode(data)
+	if err != nil {
+		return err
+	}
+	_, err = c.FileSystem.Write(path, stored, -1, (flags&^filesystem.WriteFlagAppend)|filesystem.WriteFlagTruncate)
+	return err
+}
+
+// size returns the decoded size of a file
+func (c *contentFS) size(path string, info *filesystem.FileInfo) int64 {
+	if info.IsDir || info.Size == 0 {
+		return info.Size
+	}
+	return c.codec.size(info, func(offset, size int64) ([]byte, error) {
+		return c.FileSystem.Read(path, offset, size)
+	})
+}
+
+func (c *contentFS) Read(path string, offset int64, size int64) ([]byte, error) {
+	data, err := c.load(path)
+	if err != nil {
+		return nil, err
+	}
+	return plugin.ApplyRangeRead(data, offset, size)
+}
+
+func (c *contentFS) Write(path string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	// Overwriting the whole file
+	if offset < 0 && flags&filesystem.WriteFlagAppend == 0 {
+		if err := c.store(path, data, flags); err != nil {
+			return 0, err
+		}
+		return int64(len(data)), nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Patch the current content, unless the file is truncated or created
+	var content []byte
+	if flags&filesystem.WriteFlagTruncate == 0 {
+		_, statErr := c.FileSystem.Stat(path)
+		if statErr == nil || flags&filesystem.WriteFlagCreate == 0 {
+			existing, err := c.load(path)
+			if err != nil {
+				return 0, err
+			}
+			content = existing
+		}
+	}
+	if flags&filesystem.WriteFlagAppend != 0 || offset < 0 {
+		offset = int64(len(content))
+	}
+	if end := offset + int64(len(data)); end > int64(len(content)) {
+		content = append(content, make([]byte, end-int64(len(content)))...)
+	}
+	copy(content[offset:], data)
+
+	if err := c.store(path, content, flags); err != nil {
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
+
+// Truncate implements filesystem.Truncater
+func (c *contentFS) Truncate(path string, size int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	content, err := c.load(path)
+	if err != nil {
+		return err
+	}
+	if size <= int64(len(content)) {
+		content = content[:size]
+	} else {
+		content = append(content, make([]byte, size-int64(len(content)))...)
+	}
+	return c.store(path, content, 0)
+}
+
+func (c *contentFS) Stat(path string) (*filesystem.FileInfo, error) {
+	info, err := c.FileSystem.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	info.Size = c.size(path, info)
+	return info, nil
+}
+
+func (c *contentFS) ReadDir(dir string) ([]filesystem.FileInfo, error) {
+	entries, err := c.FileSystem.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		entries[i].Size = c.size(path.Join(dir, entries[i].Name), &entries[i])
+	}
+	return entries, nil
+}
+
+func (c *contentFS) Open(path string) (io.ReadCloser, error) {
+	data, err := c.load(path)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (c *contentFS) OpenWrite(path string) (io.WriteCloser, error) {
+	return filesystem.NewBufferedWriter(path, c.Write), nil
+}
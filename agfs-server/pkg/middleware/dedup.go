@@ -0,0 +1,290 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+)
+
+// A deduplicated file is stored as a manifest: a header (dedupMagic, the
+// format version, the file size (uint64) and the number of chunks (uint32),
+// big endian) and, for every chunk, its SHA-256 and size (uint32). Chunks
+// are stored once, under dedupDir, named by their SHA-256.
+const (
+	dedupMagic       = "\x89AGD"
+	dedupVersion     = 1
+	dedupHeaderLen   = len(dedupMagic) + 1 + 8 + 4
+	dedupEntryLen    = sha256.Size + 4
+	dedupDir         = "/.dedup"
+	dedupChunkDir    = dedupDir + "/chunks"
+	dedupMinChunk    = 2 << 10
+	dedupMaxChunk    = 64 << 10
+	dedupAverageBits = 13 // 8 KB average chunks
+)
+
+// gearTable maps bytes to the random values of the gear rolling hash. It is
+// derived from SHA-256 so that chunk boundaries never change across builds.
+var gearTable = func() (table [256]uint64) {
+	for i := range table {
+		sum := sha256.Sum256([]byte{'a', 'g', 'f', 's', byte(i)})
+		table[i] = binary.BigEndian.Uint64(sum[:8])
+	}
+	return table
+}()
+
+// Dedup returns a middleware that splits file contents into content-defined
+// chunks and stores every distinct chunk once, in the wrapped file system
+func Dedup() Middleware {
+	mu := &sync.Mutex{}
+	dirs := &sync.Map{}
+	return func(fs filesystem.FileSystem) filesystem.FileSystem {
+		codec := &dedupCodec{fs: fs, dirs: dirs}
+		return &dedupFS{contentFS: &contentFS{FileSystem: fs, codec: codec, mu: mu}, codec: codec}
+	}
+}
+
+// chunkBoundaries splits data into chunks of dedupMinChunk to dedupMaxChunk
+// bytes, cut where the gear hash of the last bytes has its top bits zero, so
+// that an insertion only changes the chunks around it
+func chunkBoundaries(data []byte) [][]byte {
+	var chunks [][]byte
+	for len(data) > 0 {
+		n := cutPoint(data)
+		chunks = append(chunks, data[:n])
+		data = data[n:]
+	}
+	return chunks
+}
+
+func cutPoint(data []byte) int {
+	if len(data) <= dedupMinChunk {
+		return len(data)
+	}
+	limit := min(len(data), dedupMaxChunk)
+	var hash uint64
+	for i := dedupMinChunk; i < limit; i++ {
+		hash = (hash << 1) + gearTable[data[i]]
+		if hash>>(64-dedupAverageBits) == 0 {
+			return i + 1
+		}
+	}
+	return limit
+}
+
+// manifestChunk is a chunk of a deduplicated file
+type manifestChunk struct {
+	sum  [sha256.Size]byte
+	size int64
+}
+
+// manifest lists the chunks of a deduplicated file
+type manifest struct {
+	size   int64
+	chunks []manifestChunk
+}
+
+// parseManifest parses a stored file, returning false if it is not a manifest
+func parseManifest(stored []byte) (*manifest, bool) {
+	if len(stored) < dedupHeaderLen || !bytes.HasPrefix(stored, []byte(dedupMagic)) {
+		return nil, false
+	}
+	m := &manifest{size: int64(binary.BigEndian.Uint64(stored[len(dedupMagic)+1:]))}
+	count := int(binary.BigEndian.Uint32(stored[len(dedupMagic)+9:]))
+	if len(stored) != dedupHeaderLen+count*dedupEntryLen {
+		return nil, false
+	}
+	for i := 0; i < count; i++ {
+		entry := stored[dedupHeaderLen+i*dedupEntryLen:]
+		var c manifestChunk
+		copy(c.sum[:], entry)
+		c.size = int64(binary.BigEndian.Uint32(entry[sha256.Size:]))
+		m.chunks = append(m.chunks, c)
+	}
+	return m, true
+}
+
+// dedupCodec stores the chunks of a file and returns its manifest
+type dedupCodec struct {
+	fs   filesystem.FileSystem
+	dirs *sync.Map // Chunk directories known to exist
+}
+
+// chunkPath returns the path of a chunk, in a directory named by its first
+// byte to keep directories small
+func chunkPath(sum [sha256.Size]byte) string {
+	name := hex.EncodeToString(sum[:])
+	return dedupChunkDir + "/" + name[:2] + "/" + name
+}
+
+// mkdirAll creates a chunk directory and its parents
+func (c *dedupCodec) mkdirAll(dir string) error {
+	if _, ok := c.dirs.Load(dir); ok {
+		return nil
+	}
+	if dir != dedupDir {
+		if err := c.mkdirAll(path.Dir(dir)); err != nil {
+			return err
+		}
+	}
+	if err := c.fs.Mkdir(dir, 0755); err != nil {
+		if info, statErr := c.fs.Stat(dir); statErr != nil || !info.IsDir {
+			return err
+		}
+	}
+	c.dirs.Store(dir, true)
+	return nil
+}
+
+func (c *dedupCodec) encode(data []byte) ([]byte, error) {
+	// Files smaller than a chunk are stored as they are
+	if len(data) < dedupMinChunk && !bytes.HasPrefix(data, []byte(dedupMagic)) {
+		return data, nil
+	}
+
+	chunks := chunkBoundaries(data)
+	out := make([]byte, dedupHeaderLen, dedupHeaderLen+len(chunks)*dedupEntryLen)
+	copy(out, dedupMagic)
+	out[len(dedupMagic)] = dedupVersion
+	binary.BigEndian.PutUint64(out[len(dedupMagic)+1:], uint64(len(data)))
+	binary.BigEndian.PutUint32(out[len(dedupMagic)+9:], uint32(len(chunks)))
+
+	for _, chunk := range chunks {
+		sum := sha256.Sum256(chunk)
+		if err := c.storeChunk(sum, chunk); err != nil {
+			return nil, err
+		}
+		out = append(out, sum[:]...)
+		out = binary.BigEndian.AppendUint32(out, uint32(len(chunk)))
+	}
+	return out, nil
+}
+
+// storeChunk writes a chunk unless it is stored already
+func (c *dedupCodec) storeChunk(sum [sha256.Size]byte, chunk []byte) error {
+	p := chunkPath(sum)
+	if _, err := c.fs.Stat(p); err == nil {
+		return nil
+	}
+	if err := c.mkdirAll(path.Dir(p)); err != nil {
+		return fmt.Errorf("failed to create chunk directory: %w", err)
+	}
+	if _, err := c.fs.Write(p, chunk, -1, filesystem.WriteFlagCreate|filesystem.WriteFlagTruncate); err != nil {
+		return fmt.Errorf("failed to store chunk: %w", err)
+	}
+	return nil
+}
+
+// readChunk reads and verifies a chunk
+func (c *dedupCodec) readChunk(chunk manifestChunk) ([]byte, error) {
+	data, err := c.fs.Read(chunkPath(chunk.sum), 0, -1)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read chunk %x: %w", chunk.sum, err)
+	}
+	if int64(len(data)) != chunk.size || sha256.Sum256(data) != chunk.sum {
+		return nil, fmt.Errorf("chunk %x is corrupt", chunk.sum)
+	}
+	return data, nil
+}
+
+func (c *dedupCodec) decode(stored []byte) ([]byte, error) {
+	m, ok := parseManifest(stored)
+	if !ok {
+		return stored, nil
+	}
+	data := make([]byte, 0, m.size)
+	for _, chunk := range m.chunks {
+		b, err := c.readChunk(chunk)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, b...)
+	}
+	return data, nil
+}
+
+// size returns the file size in the header of a manifest
+func (c *dedupCodec) size(info *filesystem.FileInfo, read func(offset, size int64) ([]byte, error)) int64 {
+	if info.Size < int64(dedupHeaderLen) {
+		return info.Size
+	}
+	header, err := read(0, int64(dedupHeaderLen))
+	if err != nil && err != io.EOF {
+		return info.Size
+	}
+	if len(header) == dedupHeaderLen && bytes.HasPrefix(header, []byte(dedupMagic)) {
+		return int64(binary.BigEndian.Uint64(header[len(dedupMagic)+1:]))
+	}
+	return info.Size
+}
+
+// dedupFS is a contentFS that reads only the chunks of a range, and hides the
+// chunk store
+type dedupFS struct {
+	*contentFS
+	codec *dedupCodec
+}
+
+func (d *dedupFS) Read(path string, offset int64, size int64) ([]byte, error) {
+	stored, err := d.FileSystem.Read(path, 0, -1)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	m, ok := parseManifest(stored)
+	if !ok {
+		return plugin.ApplyRangeRead(stored, offset, size)
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= m.size {
+		return nil, io.EOF
+	}
+	end := m.size
+	if size >= 0 && offset+size < end {
+		end = offset + size
+	}
+
+	var out []byte
+	var pos int64
+	for _, chunk := range m.chunks {
+		if pos >= end {
+			break
+		}
+		if pos+chunk.size > offset {
+			data, err := d.codec.readChunk(chunk)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			out = append(out, data[max(offset-pos, 0):min(end-pos, chunk.size)]...)
+		}
+		pos += chunk.size
+	}
+	if end >= m.size {
+		return out, io.EOF
+	}
+	return out, nil
+}
+
+func (d *dedupFS) ReadDir(dir string) ([]filesystem.FileInfo, error) {
+	entries, err := d.contentFS.ReadDir(dir)
+	if err != nil || strings.Trim(dir, "/") != "" {
+		return entries, err
+	}
+	visible := entries[:0]
+	for _, e := range entries {
+		if "/"+e.Name != dedupDir {
+			visible = append(visible, e)
+		}
+	}
+	return visible, nil
+}
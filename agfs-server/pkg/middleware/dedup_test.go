@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+// countChunks returns the number of chunks stored in fs
+func countChunks(t *testing.T, fs filesystem.FileSystem) int {
+	dirs, err := fs.ReadDir(dedupChunkDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, d := range dirs {
+		entries, err := fs.ReadDir(dedupChunkDir + "/" + d.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n += len(entries)
+	}
+	return n
+}
+
+func TestDedup(t *testing.T) {
+	inner := newMemFS(t)
+	fs := Dedup()(inner)
+
+	data := make([]byte, 512<<10)
+	rand.New(rand.NewSource(1)).Read(data)
+	if _, err := fs.Write("/a.bin", data, -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatal(err)
+	}
+	chunks := countChunks(t, inner)
+	if chunks < 2 {
+		t.Fatalf("expected the file split into chunks, got %d", chunks)
+	}
+
+	// A near-identical file only adds the chunks around the change
+	edited := append(append(append([]byte{}, data[:100<<10]...), "inserted"...), data[100<<10:]...)
+	if _, err := fs.Write("/b.bin", edited, -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatal(err)
+	}
+	if added := countChunks(t, inner) - chunks; added < 1 || added > 3 {
+		t.Errorf("expected 1 to 3 new chunks, got %d", added)
+	}
+
+	for name, want := range map[string][]byte{"/a.bin": data, "/b.bin": edited} {
+		got, err := fs.Read(name, 0, -1)
+		if err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: round trip mismatch", name)
+		}
+		info, err := fs.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size != int64(len(want)) {
+			t.Errorf("%s: expected Stat size %d, got %d", name, len(want), info.Size)
+		}
+	}
+
+	// Range reads across chunk boundaries
+	for _, r := range [][2]int64{{0, 10}, {100 << 10, 100 << 10}, {int64(len(data)) - 5, 100}} {
+		got, err := fs.Read("/a.bin", r[0], r[1])
+		if err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+		end := min(r[0]+r[1], int64(len(data)))
+		if !bytes.Equal(got, data[r[0]:end]) {
+			t.Errorf("range %v mismatch", r)
+		}
+		if (err == io.EOF) != (end == int64(len(data))) {
+			t.Errorf("range %v: unexpected error %v", r, err)
+		}
+	}
+
+	// The chunk store is hidden, small files are stored as they are
+	if _, err := fs.Write("/small.txt", []byte("small"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := inner.Read("/small.txt", 0, -1); string(stored) != "small" {
+		t.Errorf("expected small file stored raw, got %q", stored)
+	}
+	entries, err := fs.ReadDir("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name == strings.TrimPrefix(dedupDir, "/") {
+			t.Error("expected chunk store hidden from ReadDir")
+		}
+	}
+
+	// Appends rewrite the manifest
+	if _, err := fs.Write("/a.bin", []byte("tail"), -1, filesystem.WriteFlagAppend); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := fs.Read("/a.bin", int64(len(data)), -1)
+	if string(got) != "tail" {
+		t.Errorf("expected appended data, got %q", got)
+	}
+}
+
+func TestDedupCorruptChunk(t *testing.T) {
+	inner := newMemFS(t)
+	fs := Dedup()(inner)
+
+	data := []byte(strings.Repeat("0123456789abcdef", 1024))
+	if _, err := fs.Write("/a.txt", data, -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := inner.Read("/a.txt", 0, -1)
+	m, ok := parseManifest(stored)
+	if !ok {
+		t.Fatal("expected a manifest")
+	}
+	if _, err := inner.Write(chunkPath(m.chunks[0].sum), []byte("garbage"), -1, filesystem.WriteFlagTruncate); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Read("/a.txt", 0, -1); err == nil || !strings.Contains(err.Error(), "corrupt") {
+		t.Errorf("expected corrupt chunk error, got %v", err)
+	}
+}
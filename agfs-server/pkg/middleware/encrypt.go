@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"sync"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+// Encrypted files are stored as a header (encryptMagic, the format version
+// and the ID of the key), a random nonce, and the AES-GCM sealed content,
+// authenticated together with the header. The path is not authenticated, so
+// that renames move files as they are: whoever can write to the backend can
+// swap the contents of two files of a mount without either failing to read.
+const (
+	encryptMagic     = "\x89AGE"
+	encryptVersion   = 1
+	encryptKeyIDLen  = 4
+	encryptHeaderLen = len(encryptMagic) + 1 + encryptKeyIDLen
+)
+
+// ErrNotEncrypted is returned when reading a file of an encrypted mount that
+// was not written through it
+var ErrNotEncrypted = errors.New("file is not encrypted")
+
+// ErrWrongKey is returned when reading a file encrypted with another key
+var ErrWrongKey = errors.New("file is encrypted with a different key")
+
+// Encryption returns a middleware that encrypts file contents at rest with
+// AES-256-GCM. key is 32 bytes, hex or base64 encoded. File names are not
+// encrypted.
+func Encryption(key string) (Middleware, error) {
+	codec, err := newEncryptCodec(key)
+	if err != nil {
+		return nil, err
+	}
+	mu := &sync.Mutex{}
+	return func(fs filesystem.FileSystem) filesystem.FileSystem {
+		return &contentFS{FileSystem: fs, codec: codec, mu: mu}
+	}, nil
+}
+
+// encryptCodec seals contents with one key
+type encryptCodec struct {
+	aead   cipher.AEAD
+	header []byte
+}
+
+func newEncryptCodec(key string) (*encryptCodec, error) {
+	raw, err := parseKey(key)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(raw)
+	header := make([]byte, 0, encryptHeaderLen)
+	header = append(header, encryptMagic...)
+	header = append(header, encryptVersion)
+	header = append(header, sum[:encryptKeyIDLen]...)
+	return &encryptCodec{aead: aead, header: header}, nil
+}
+
+// parseKey decodes a 32-byte key, without echoing it in errors
+func parseKey(key string) ([]byte, error) {
+	if raw, err := hex.DecodeString(key); err == nil && len(raw) == 32 {
+		return raw, nil
+	}
+	if raw, err := base64.StdEncoding.DecodeString(key); err == nil && len(raw) == 32 {
+		return raw, nil
+	}
+	return nil, errors.New("invalid encryption_key: must be 32 bytes, hex or base64 encoded")
+}
+
+// overhead is the number of bytes an encrypted file has over its content
+func (c *encryptCodec) overhead() int {
+	return encryptHeaderLen + c.aead.NonceSize() + c.aead.Overhead()
+}
+
+func (c *encryptCodec) encode(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data)+c.overhead())
+	out = append(out, c.header...)
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out = append(out, nonce...)
+	return c.aead.Seal(out, nonce, data, c.header), nil
+}
+
+func (c *encryptCodec) decode(stored []byte) ([]byte, error) {
+	if len(stored) < c.overhead() || !bytes.HasPrefix(stored, []byte(encryptMagic)) {
+		return nil, ErrNotEncrypted
+	}
+	if !bytes.Equal(stored[:encryptHeaderLen], c.header) {
+		return nil, ErrWrongKey
+	}
+	nonce := stored[encryptHeaderLen : encryptHeaderLen+c.aead.NonceSize()]
+	data, err := c.aead.Open(nil, nonce, stored[encryptHeaderLen+c.aead.NonceSize():], c.header)
+	if err != nil {
+		return nil, errors.New("file failed authentication")
+	}
+	return data, nil
+}
+
+// size derives the content size from the stored size, without reading
+func (c *encryptCodec) size(info *filesystem.FileInfo, read func(offset, size int64) ([]byte, error)) int64 {
+	if info.Size < int64(c.overhead()) {
+		return info.Size
+	}
+	return info.Size - int64(c.overhead())
+}
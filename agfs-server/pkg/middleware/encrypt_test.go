@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+const (
+	testKey      = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+	testOtherKey = "ICEiIyQlJicoKSorLC0uLzAxMjM0NTY3ODk6Ozw9Pj8="
+)
+
+func TestEncryption(t *testing.T) {
+	inner := newMemFS(t)
+	mw, err := Encryption(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := mw(inner)
+
+	secret := []byte("api token: sk-0123456789")
+	if _, err := fs.Write("/notes.txt", secret, -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatal(err)
+	}
+
+	// The backing file keeps its name, but not its content
+	stored, err := inner.Read("/notes.txt", 0, -1)
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	if bytes.Contains(stored, []byte("sk-0123456789")) {
+		t.Error("expected content encrypted at rest")
+	}
+
+	data, err := fs.Read("/notes.txt", 0, -1)
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, secret) {
+		t.Errorf("expected %q, got %q", secret, data)
+	}
+	info, err := fs.Stat("/notes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != int64(len(secret)) {
+		t.Errorf("expected Stat size %d, got %d", len(secret), info.Size)
+	}
+
+	// Renames move the file as it is
+	if err := fs.Rename("/notes.txt", "/moved.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := fs.Read("/moved.txt", 0, -1); !bytes.Equal(data, secret) {
+		t.Errorf("expected renamed file readable, got %q", data)
+	}
+
+	// Same content, different ciphertext
+	if _, err := fs.Write("/copy.txt", secret, -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatal(err)
+	}
+	copied, _ := inner.Read("/copy.txt", 0, -1)
+	moved, _ := inner.Read("/moved.txt", 0, -1)
+	if bytes.Equal(copied, moved) {
+		t.Error("expected a fresh nonce for every write")
+	}
+
+	// Another key, a plaintext file or a tampered file fail to read
+	other, err := Encryption(testOtherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other(inner).Read("/moved.txt", 0, -1); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("expected ErrWrongKey, got %v", err)
+	}
+	if _, err := inner.Write("/plain.txt", []byte("written around the middleware"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Read("/plain.txt", 0, -1); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("expected ErrNotEncrypted, got %v", err)
+	}
+	moved[len(moved)-1] ^= 1
+	if _, err := inner.Write("/moved.txt", moved, -1, filesystem.WriteFlagTruncate); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Read("/moved.txt", 0, -1); err == nil {
+		t.Error("expected tampered file to fail authentication")
+	}
+}
+
+func TestEncryptionSwappedFiles(t *testing.T) {
+	inner := newMemFS(t)
+	mw, err := Encryption(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := mw(inner)
+	for path, content := range map[string]string{"/a.txt": "content of a", "/b.txt": "content of b"} {
+		if _, err := fs.Write(path, []byte(content), -1, filesystem.WriteFlagCreate); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Ciphertexts are not bound to their paths, so that renames work: one
+	// copied over another in the backend reads as the other's content
+	stored, _ := inner.Read("/b.txt", 0, -1)
+	if _, err := inner.Write("/a.txt", stored, -1, filesystem.WriteFlagTruncate); err != nil {
+		t.Fatal(err)
+	}
+	data, err := fs.Read("/a.txt", 0, -1)
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	if string(data) != "content of b" {
+		t.Errorf("expected the swapped content, got %q", data)
+	}
+}
+
+func TestEncryptionKey(t *testing.T) {
+	for _, key := range []string{"", "short", strings.Repeat("zz", 32), testKey[:62]} {
+		if _, err := Encryption(key); err == nil {
+			t.Errorf("expected error for key %q", key)
+		} else if key != "" && strings.Contains(err.Error(), key) {
+			t.Errorf("error must not contain the key: %v", err)
+		}
+	}
+}
+
+func TestCompressionWithEncryption(t *testing.T) {
+	inner := newMemFS(t)
+	mws, rest, err := FromConfig(map[string]interface{}{"compression": "zstd", "encryption_key": testKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mws) != 2 || len(rest) != 0 {
+		t.Fatalf("expected 2 middlewares and no plugin config, got %d and %v", len(mws), rest)
+	}
+	fs := Wrap(inner, mws)
+
+	text := []byte(strings.Repeat("compressed, then encrypted\n", 200))
+	if _, err := fs.Write("/log.txt", text, -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Write("/log.txt", []byte("tail\n"), -1, filesystem.WriteFlagAppend); err != nil {
+		t.Fatal(err)
+	}
+	want := append(append([]byte{}, text...), "tail\n"...)
+
+	stored, _ := inner.Read("/log.txt", 0, -1)
+	if len(stored) >= len(text) || !bytes.HasPrefix(stored, []byte(encryptMagic)) {
+		t.Errorf("expected compressed and encrypted content, stored %d bytes", len(stored))
+	}
+	data, err := fs.Read("/log.txt", 0, -1)
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Error("round trip mismatch")
+	}
+	info, err := fs.Stat("/log.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != int64(len(want)) {
+		t.Errorf("expected Stat size %d, got %d", len(want), info.Size)
+	}
+}
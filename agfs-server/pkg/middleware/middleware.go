@@ -0,0 +1,193 @@
+// Package middleware provides file system wrappers that any mount can enable
+// through reserved keys of its config, independently of the plugin mounted.
+package middleware
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+)
+
+// Middleware wraps the file system of a mount
+type Middleware func(filesystem.FileSystem) filesystem.FileSystem
+
+// Params are the mount config parameters of the middlewares. They are taken
+// out of the config before it is passed to the plugin.
+var Params = []plugin.ConfigParameter{
+	{
+		Name:        "compression",
+		Type:        "string",
+		Required:    false,
+		Default:     "",
+		Description: "Compress file contents at rest",
+		Enum:        []string{"zstd", "lz4"},
+	},
+	{
+		Name:        "encryption_key",
+		Type:        "string",
+		Required:    false,
+		Default:     "",
+		Description: "AES-256 key (32 bytes, hex or base64) to encrypt file contents at rest; use an env:, file: or vault: reference",
+		Secret:      true,
+	},
+	{
+		Name:        "dedup",
+		Type:        "bool",
+		Required:    false,
+		Default:     "false",
+		Description: "Store file contents as content-defined chunks, each distinct chunk once",
+	},
+	{
+		Name:        "scan_clamav",
+		Type:        "string",
+		Required:    false,
+		Default:     "",
+		Description: "clamd address (unix socket path or host:port) scanning written content for malware; offending content is quarantined in /.quarantine",
+	},
+	{
+		Name:        "scan_secrets",
+		Type:        "bool",
+		Required:    false,
+		Default:     "false",
+		Description: "Quarantine written content containing credentials (cloud keys, tokens, private keys) in /.quarantine",
+	},
+	{
+		Name:        "scan_fail_open",
+		Type:        "bool",
+		Required:    false,
+		Default:     "false",
+		Description: "Allow writes when clamd cannot scan them, instead of failing them",
+	},
+	{
+		Name:        "admission_webhook",
+		Type:        "string",
+		Required:    false,
+		Default:     "",
+		Description: "URL of a webhook that allows, rejects or rewrites writes before they reach the plugin",
+	},
+	{
+		Name:        "admission_paths",
+		Type:        "array",
+		Required:    false,
+		Default:     "",
+		Description: "Prefixes of the paths, within the mount, whose writes go through the admission webhook (empty for all)",
+	},
+	{
+		Name:        "admission_timeout",
+		Type:        "string",
+		Required:    false,
+		Default:     "5s",
+		Description: "Timeout of the admission webhook",
+	},
+	{
+		Name:        "admission_fail_open",
+		Type:        "bool",
+		Required:    false,
+		Default:     "false",
+		Description: "Allow writes when the admission webhook cannot be reached, instead of failing them",
+	},
+}
+
+// FromConfig returns the middlewares enabled by a mount config, in the order
+// they wrap the plugin's file system, and the config without their keys
+func FromConfig(cfg map[string]interface{}) ([]Middleware, map[string]interface{}, error) {
+	var middlewares []Middleware
+
+	for _, key := range []string{"compression", "encryption_key", "scan_clamav", "admission_webhook", "admission_timeout"} {
+		if err := config.ValidateStringType(cfg, key); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, key := range []string{"dedup", "scan_secrets", "scan_fail_open", "admission_fail_open"} {
+		if err := config.ValidateBoolType(cfg, key); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Contents are chunked, then compressed, then encrypted
+	if key := config.GetStringConfig(cfg, "encryption_key", ""); key != "" {
+		mw, err := Encryption(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		middlewares = append(middlewares, mw)
+	}
+	if algorithm := config.GetStringConfig(cfg, "compression", ""); algorithm != "" {
+		mw, err := Compression(algorithm)
+		if err != nil {
+			return nil, nil, err
+		}
+		middlewares = append(middlewares, mw)
+	}
+	if config.GetBoolConfig(cfg, "dedup", false) {
+		middlewares = append(middlewares, Dedup())
+	}
+	// Contents are scanned as written, after admission webhooks rewrite them
+	clamav := config.GetStringConfig(cfg, "scan_clamav", "")
+	if secrets := config.GetBoolConfig(cfg, "scan_secrets", false); clamav != "" || secrets {
+		mw, err := Scan(ScanConfig{ClamAV: clamav, Secrets: secrets, FailOpen: config.GetBoolConfig(cfg, "scan_fail_open", false)})
+		if err != nil {
+			return nil, nil, err
+		}
+		middlewares = append(middlewares, mw)
+	}
+	// Writes are admitted before anything else sees them
+	if url := config.GetStringConfig(cfg, "admission_webhook", ""); url != "" {
+		mw, err := admissionFromConfig(url, cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		middlewares = append(middlewares, mw)
+	}
+
+	rest := make(map[string]interface{}, len(cfg))
+	for k, v := range cfg {
+		rest[k] = v
+	}
+	for _, p := range Params {
+		delete(rest, p.Name)
+	}
+	return middlewares, rest, nil
+}
+
+// admissionFromConfig returns the admission middleware of a mount config
+func admissionFromConfig(url string, cfg map[string]interface{}) (Middleware, error) {
+	admission := AdmissionConfig{URL: url, FailOpen: config.GetBoolConfig(cfg, "admission_fail_open", false)}
+	switch paths := cfg["admission_paths"].(type) {
+	case nil:
+	case string:
+		admission.Paths = []string{paths}
+	case []interface{}:
+		for i, p := range paths {
+			s, ok := p.(string)
+			if !ok {
+				return nil, fmt.Errorf("admission_paths[%d] must be a path", i)
+			}
+			admission.Paths = append(admission.Paths, s)
+		}
+	default:
+		return nil, fmt.Errorf("admission_paths must be a path or a list of them")
+	}
+	timeout, err := time.ParseDuration(config.GetStringConfig(cfg, "admission_timeout", "5s"))
+	if err != nil || timeout <= 0 {
+		return nil, unsupported("admission_timeout", config.GetStringConfig(cfg, "admission_timeout", ""))
+	}
+	admission.Timeout = timeout
+	return Admission(admission)
+}
+
+// Wrap wraps fs with middlewares, the first one innermost
+func Wrap(fs filesystem.FileSystem, middlewares []Middleware) filesystem.FileSystem {
+	for _, mw := range middlewares {
+		fs = mw(fs)
+	}
+	return fs
+}
+
+// unsupported returns the error of a middleware for an invalid setting
+func unsupported(key, value string) error {
+	return fmt.Errorf("unsupported %s: %s", key, value)
+}
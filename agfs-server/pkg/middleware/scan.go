@@ -0,0 +1,305 @@
+package middleware
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	log "github.com/sirupsen/logrus"
+)
+
+// QuarantineDir is where a scanning mount keeps the files it rejected, each
+// next to its audit record, <id>.json
+const QuarantineDir = "/.quarantine"
+
+// QuarantineRecord is the audit record of a quarantined file
+type QuarantineRecord struct {
+	ID       string    `json:"id"`
+	Time     time.Time `json:"time"`
+	Path     string    `json:"path"`   // Path written, within the mount
+	Offset   int64     `json:"offset"` // Write position, -1 to overwrite or append
+	Size     int       `json:"size"`
+	SHA256   string    `json:"sha256"`
+	Findings []string  `json:"findings"` // What the scanners found, e.g. "clamav: Eicar-Test-Signature"
+}
+
+// ScanConfig configures the scanning of written content
+type ScanConfig struct {
+	ClamAV   string        // clamd address: a unix socket path or host:port ("unix:" and "tcp:" prefixes allowed)
+	Secrets  bool          // Detect credentials, like cloud keys, tokens and private keys
+	Timeout  time.Duration // Timeout of each clamd scan (default: 30s)
+	FailOpen bool          // Let writes through when clamd cannot scan them
+}
+
+// Scan returns a middleware that scans the content of writes with ClamAV and
+// secret detectors. Offending writes do not reach their path: the content is
+// quarantined under QuarantineDir with an audit record, and the write fails
+// with filesystem.ErrPermissionDenied. Unless the config fails open, writes
+// that clamd cannot scan fail with filesystem.ErrUnavailable.
+func Scan(cfg ScanConfig) (Middleware, error) {
+	var scanners []contentScanner
+	if cfg.Secrets {
+		scanners = append(scanners, secretScanner{})
+	}
+	if cfg.ClamAV != "" {
+		network, address := "tcp", cfg.ClamAV
+		switch {
+		case strings.HasPrefix(address, "unix:"):
+			network, address = "unix", strings.TrimPrefix(address, "unix:")
+		case strings.HasPrefix(address, "tcp:"):
+			address = strings.TrimPrefix(address, "tcp:")
+		case strings.HasPrefix(address, "/"):
+			network = "unix"
+		}
+		if address == "" {
+			return nil, unsupported("scan_clamav", cfg.ClamAV)
+		}
+		if cfg.Timeout <= 0 {
+			cfg.Timeout = 30 * time.Second
+		}
+		scanners = append(scanners, &clamavScanner{network: network, address: address, timeout: cfg.Timeout})
+	}
+	return func(fs filesystem.FileSystem) filesystem.FileSystem {
+		return &scanFS{FileSystem: fs, scanners: scanners, failOpen: cfg.FailOpen}
+	}, nil
+}
+
+// contentScanner finds offending content
+type contentScanner interface {
+	// scan returns what it found in data, nothing if data is clean
+	scan(data []byte) ([]string, error)
+}
+
+// secretPatterns detect credentials by their well-known formats
+var secretPatterns = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"aws-access-key", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"private-key", regexp.MustCompile(`-----BEGIN ((RSA|EC|DSA|OPENSSH|ENCRYPTED|PGP) )?PRIVATE KEY( BLOCK)?-----`)},
+	{"github-token", regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{60,})\b`)},
+	{"slack-token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{"google-api-key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{"openai-api-key", regexp.MustCompile(`\bsk-(proj-)?[A-Za-z0-9_-]{32,}`)},
+	{"anthropic-api-key", regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_-]{32,}`)},
+}
+
+// secretScanner detects credentials in content. Findings name the kind of
+// credential, never its value.
+type secretScanner struct{}
+
+func (secretScanner) scan(data []byte) ([]string, error) {
+	var findings []string
+	for _, p := range secretPatterns {
+		if p.pattern.Match(data) {
+			findings = append(findings, "secret: "+p.name)
+		}
+	}
+	return findings, nil
+}
+
+// clamavScanner scans content with clamd, streaming it with INSTREAM
+type clamavScanner struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// clamavChunk is the size of the chunks content is streamed to clamd in
+const clamavChunk = 64 << 10
+
+func (c *clamavScanner) scan(data []byte) ([]string, error) {
+	conn, err := net.DialTimeout(c.network, c.address, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(c.timeout))
+
+	w := bufio.NewWriter(conn)
+	w.WriteString("zINSTREAM\x00")
+	var size [4]byte
+	for len(data) > 0 {
+		n := len(data)
+		if n > clamavChunk {
+			n = clamavChunk
+		}
+		binary.BigEndian.PutUint32(size[:], uint32(n))
+		w.Write(size[:])
+		w.Write(data[:n])
+		data = data[n:]
+	}
+	binary.BigEndian.PutUint32(size[:], 0)
+	w.Write(size[:])
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+
+	// "stream: OK", "stream: <signature> FOUND" or "<message> ERROR"
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	switch {
+	case strings.HasSuffix(reply, " OK"):
+		return nil, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		signature := strings.TrimSuffix(strings.TrimPrefix(reply, "stream: "), " FOUND")
+		return []string{"clamav: " + signature}, nil
+	}
+	return nil, fmt.Errorf("clamd: %s", reply)
+}
+
+// scanFS scans the content written to the wrapped file system. The
+// quarantine is hidden from listings and cannot be written to. Handles and
+// streams of the wrapped file system are not exposed, so that all writes are
+// scanned.
+type scanFS struct {
+	filesystem.FileSystem
+	scanners []contentScanner
+	failOpen bool
+}
+
+// inQuarantine returns true if a path is in the quarantine
+func inQuarantine(p string) bool {
+	p = filesystem.NormalizePath(p)
+	return p == QuarantineDir || strings.HasPrefix(p, QuarantineDir+"/")
+}
+
+// check scans data, returning what was found
+func (s *scanFS) check(p string, data []byte) ([]string, error) {
+	var findings []string
+	for _, scanner := range s.scanners {
+		found, err := scanner.scan(data)
+		if err != nil {
+			if s.failOpen {
+				log.Warnf("Content scan of %s failed, allowing the write: %v", p, err)
+				continue
+			}
+			return nil, filesystem.NewUnavailableError(p, "content scan: "+err.Error())
+		}
+		findings = append(findings, found...)
+	}
+	return findings, nil
+}
+
+// quarantine stores offending content and its audit record in the quarantine
+func (s *scanFS) quarantine(p string, data []byte, offset int64, findings []string) (string, error) {
+	var random [4]byte
+	rand.Read(random[:])
+	now := time.Now().UTC()
+	sum := sha256.Sum256(data)
+	rec := QuarantineRecord{
+		ID:       now.Format("20060102T150405Z") + "-" + hex.EncodeToString(random[:]),
+		Time:     now,
+		Path:     p,
+		Offset:   offset,
+		Size:     len(data),
+		SHA256:   hex.EncodeToString(sum[:]),
+		Findings: findings,
+	}
+
+	if err := s.FileSystem.Mkdir(QuarantineDir, 0700); err != nil {
+		if _, statErr := s.FileSystem.Stat(QuarantineDir); statErr != nil {
+			return "", err
+		}
+	}
+	target := path.Join(QuarantineDir, rec.ID)
+	if _, err := s.FileSystem.Write(target, data, -1, filesystem.WriteFlagCreate|filesystem.WriteFlagTruncate); err != nil {
+		return "", err
+	}
+	record, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if _, err := s.FileSystem.Write(target+".json", record, -1, filesystem.WriteFlagCreate|filesystem.WriteFlagTruncate); err != nil {
+		return "", err
+	}
+
+	log.WithFields(log.Fields{
+		"path":     p,
+		"id":       rec.ID,
+		"size":     rec.Size,
+		"findings": strings.Join(findings, ", "),
+	}).Warn("Quarantined written content")
+	return target, nil
+}
+
+func (s *scanFS) Write(p string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	if inQuarantine(p) {
+		return 0, filesystem.NewPermissionDeniedError("write", p, "the quarantine is read-only")
+	}
+	findings, err := s.check(p, data)
+	if err != nil {
+		return 0, err
+	}
+	if len(findings) > 0 {
+		target, err := s.quarantine(p, data, offset, findings)
+		if err != nil {
+			return 0, fmt.Errorf("failed to quarantine %s: %w", p, err)
+		}
+		return 0, filesystem.NewPermissionDeniedError("write", p,
+			fmt.Sprintf("content quarantined as %s (%s)", target, strings.Join(findings, ", ")))
+	}
+	return s.FileSystem.Write(p, data, offset, flags)
+}
+
+func (s *scanFS) Create(p string) error {
+	if inQuarantine(p) {
+		return filesystem.NewPermissionDeniedError("create", p, "the quarantine is read-only")
+	}
+	return s.FileSystem.Create(p)
+}
+
+func (s *scanFS) Mkdir(p string, perm uint32) error {
+	if inQuarantine(p) {
+		return filesystem.NewPermissionDeniedError("mkdir", p, "the quarantine is read-only")
+	}
+	return s.FileSystem.Mkdir(p, perm)
+}
+
+// Rename keeps quarantined files in the quarantine
+func (s *scanFS) Rename(oldPath, newPath string) error {
+	if inQuarantine(oldPath) || inQuarantine(newPath) {
+		return filesystem.NewPermissionDeniedError("rename", oldPath, "the quarantine is read-only")
+	}
+	return s.FileSystem.Rename(oldPath, newPath)
+}
+
+// Truncate implements filesystem.Truncater
+func (s *scanFS) Truncate(p string, size int64) error {
+	if truncater, ok := s.FileSystem.(filesystem.Truncater); ok {
+		return truncater.Truncate(p, size)
+	}
+	return filesystem.NewNotSupportedError("truncate", p)
+}
+
+func (s *scanFS) ReadDir(dir string) ([]filesystem.FileInfo, error) {
+	entries, err := s.FileSystem.ReadDir(dir)
+	if err != nil || strings.Trim(dir, "/") != "" {
+		return entries, err
+	}
+	visible := entries[:0]
+	for _, e := range entries {
+		if "/"+e.Name != QuarantineDir {
+			visible = append(visible, e)
+		}
+	}
+	return visible, nil
+}
+
+func (s *scanFS) OpenWrite(p string) (io.WriteCloser, error) {
+	return filesystem.NewBufferedWriter(p, s.Write), nil
+}
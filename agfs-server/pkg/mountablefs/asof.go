@@ -0,0 +1,76 @@
+package mountablefs
+
+import (
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+// asOfFS returns the file system of the mount of a path if it keeps history,
+// with the path relative to it. Mounts with middlewares do not: their files
+// are stored transformed, e.g. compressed, and their history would be too.
+func (mfs *MountableFS) asOfFS(op, path string) (filesystem.AsOfReader, string, func(), error) {
+	if err := mfs.Limits.CheckPath(op, path); err != nil {
+		return nil, "", nil, err
+	}
+	resolved, err := mfs.resolvePath(path)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if _, _, ok := mfs.ctlPath(resolved); ok {
+		return nil, "", nil, filesystem.NewNotSupportedError(op, path)
+	}
+
+	mount, relPath, found := mfs.findMount(resolved)
+	if !found {
+		return nil, "", nil, filesystem.NewNotFoundError(op, path)
+	}
+	if err := mount.checkAvailable(); err != nil {
+		return nil, "", nil, err
+	}
+	fs, done := mfs.fsFor(op, path, mount)
+	reader, ok := fs.(filesystem.AsOfReader)
+	if !ok {
+		done()
+		return nil, "", nil, filesystem.NewNotSupportedError(op, path)
+	}
+	return reader, relPath, done, nil
+}
+
+// ReadAsOf reads a file as it was at asOf, if its mount keeps history
+func (mfs *MountableFS) ReadAsOf(path string, asOf time.Time, offset, size int64) ([]byte, error) {
+	if err := mfs.Limits.CheckRead("read", path, size); err != nil {
+		return nil, err
+	}
+	fs, relPath, done, err := mfs.asOfFS("read", path)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	data, err := fs.ReadAsOf(relPath, asOf, offset, size)
+	if max := mfs.Limits.MaxReadSize; size < 0 && max > 0 && int64(len(data)) > max {
+		return nil, filesystem.NewQuotaExceededError("read", path, "max_read_size", int64(len(data)), max)
+	}
+	return data, err
+}
+
+// StatAsOf returns the info of a path as it was at asOf, if its mount keeps
+// history
+func (mfs *MountableFS) StatAsOf(path string, asOf time.Time) (*filesystem.FileInfo, error) {
+	fs, relPath, done, err := mfs.asOfFS("stat", path)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	return fs.StatAsOf(relPath, asOf)
+}
+
+// ReadDirAsOf lists a directory as it was at asOf, if its mount keeps history
+func (mfs *MountableFS) ReadDirAsOf(path string, asOf time.Time) ([]filesystem.FileInfo, error) {
+	fs, relPath, done, err := mfs.asOfFS("readdir", path)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	return fs.ReadDirAsOf(relPath, asOf)
+}
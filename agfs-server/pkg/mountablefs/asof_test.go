@@ -0,0 +1,117 @@
+package mountablefs
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/api"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/memfs"
+)
+
+// historyPlugin is a memfs with one snapshot: reads as of a time before
+// snapshotAt see the snapshot
+type historyPlugin struct {
+	plugin.ServicePlugin
+	snapshot   *memfs.MemoryFS
+	snapshotAt time.Time
+}
+
+func (p *historyPlugin) GetFileSystem() filesystem.FileSystem {
+	return &historyFS{FileSystem: p.ServicePlugin.GetFileSystem(), p: p}
+}
+
+type historyFS struct {
+	filesystem.FileSystem
+	p *historyPlugin
+}
+
+func (f *historyFS) at(asOf time.Time) filesystem.FileSystem {
+	if asOf.Before(f.p.snapshotAt) {
+		return f.p.snapshot
+	}
+	return f.FileSystem
+}
+
+func (f *historyFS) ReadAsOf(path string, asOf time.Time, offset, size int64) ([]byte, error) {
+	return f.at(asOf).Read(path, offset, size)
+}
+
+func (f *historyFS) StatAsOf(path string, asOf time.Time) (*filesystem.FileInfo, error) {
+	return f.at(asOf).Stat(path)
+}
+
+func (f *historyFS) ReadDirAsOf(path string, asOf time.Time) ([]filesystem.FileInfo, error) {
+	return f.at(asOf).ReadDir(path)
+}
+
+func TestAsOf(t *testing.T) {
+	mfs := NewMountableFS(api.PoolConfig{})
+	p := &historyPlugin{ServicePlugin: memfs.NewMemFSPlugin(), snapshot: memfs.NewMemoryFS()}
+	if err := p.Initialize(map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.Mount("/hist", p); err != nil {
+		t.Fatal(err)
+	}
+	plain := memfs.NewMemFSPlugin()
+	if err := plain.Initialize(map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.Mount("/plain", plain); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.snapshot.Write("/a.txt", []byte("old"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatal(err)
+	}
+	p.snapshotAt = time.Now()
+	before := p.snapshotAt.Add(-time.Minute)
+	if _, err := mfs.Write("/hist/a.txt", []byte("new"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mfs.Write("/hist/b.txt", []byte("b"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := mfs.ReadAsOf("/hist/a.txt", before, 0, -1)
+	if err != nil && err != io.EOF {
+		t.Fatalf("ReadAsOf failed: %v", err)
+	}
+	if string(data) != "old" {
+		t.Errorf("expected the old content, got %q", data)
+	}
+	if data, _ := mfs.ReadAsOf("/hist/a.txt", time.Now(), 0, -1); string(data) != "new" {
+		t.Errorf("expected the current content, got %q", data)
+	}
+	if info, err := mfs.StatAsOf("/hist/a.txt", before); err != nil || info.Size != 3 {
+		t.Errorf("unexpected info %+v, %v", info, err)
+	}
+	infos, err := mfs.ReadDirAsOf("/hist", before)
+	if err != nil || len(infos) != 1 || infos[0].Name != "a.txt" {
+		t.Errorf("expected only a.txt, got %+v, %v", infos, err)
+	}
+
+	if _, err := mfs.ReadAsOf("/plain/a.txt", before, 0, -1); !errors.Is(err, filesystem.ErrNotSupported) {
+		t.Errorf("expected mounts without history to be unsupported, got %v", err)
+	}
+	if _, err := mfs.StatAsOf("/hist/.ctl/stats.json", before); !errors.Is(err, filesystem.ErrNotSupported) {
+		t.Errorf("expected control files to be unsupported, got %v", err)
+	}
+	if _, err := mfs.ReadDirAsOf("/nowhere", before); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("expected not found, got %v", err)
+	}
+
+	for _, m := range mfs.Manifest().Mounts {
+		hasAsOf := false
+		for _, c := range m.Capabilities {
+			hasAsOf = hasAsOf || c == "as_of"
+		}
+		if hasAsOf != (m.Path == "/hist") {
+			t.Errorf("%s: unexpected capabilities %v", m.Path, m.Capabilities)
+		}
+	}
+}
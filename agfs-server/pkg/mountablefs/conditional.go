@@ -0,0 +1,80 @@
+package mountablefs
+
+import (
+	"errors"
+	"hash/fnv"
+	"os"
+	"sync"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+// conditionalStripes is the number of locks conditional operations are
+// spread over by path
+const conditionalStripes = 64
+
+// conditionalLock returns the lock of the stripe of a path
+func (mfs *MountableFS) conditionalLock(path string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(filesystem.NormalizePath(path)))
+	return &mfs.conditionalLocks[h.Sum32()%conditionalStripes]
+}
+
+// Version returns the version of a file (see filesystem.FileVersion)
+func (mfs *MountableFS) Version(path string) (string, error) {
+	info, err := mfs.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	return filesystem.FileVersion(info), nil
+}
+
+// checkVersion fails with a ConflictError unless the file is at version;
+// version "*" matches any version of an existing file
+func (mfs *MountableFS) checkVersion(op, path, version string) error {
+	current, err := mfs.Version(path)
+	if errors.Is(err, filesystem.ErrNotFound) || errors.Is(err, os.ErrNotExist) {
+		return filesystem.NewConflictError(op, path, version, "")
+	}
+	if err != nil {
+		return err
+	}
+	if version != "*" && current != version {
+		return filesystem.NewConflictError(op, path, version, current)
+	}
+	return nil
+}
+
+// WriteIf writes like Write if the file is at version, for optimistic
+// concurrency. Conditional operations on a path are serialized, so of two
+// writers that read the same version only the first one succeeds; writes
+// without a condition are not held back. An empty version writes
+// unconditionally.
+func (mfs *MountableFS) WriteIf(path string, data []byte, offset int64, flags filesystem.WriteFlag, version string) (int64, error) {
+	if version == "" {
+		return mfs.Write(path, data, offset, flags)
+	}
+	mu := mfs.conditionalLock(path)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := mfs.checkVersion("write", path, version); err != nil {
+		return 0, err
+	}
+	return mfs.Write(path, data, offset, flags)
+}
+
+// RemoveIf removes a file like Remove if it is at version (see WriteIf)
+func (mfs *MountableFS) RemoveIf(path string, version string) error {
+	if version == "" {
+		return mfs.Remove(path)
+	}
+	mu := mfs.conditionalLock(path)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := mfs.checkVersion("remove", path, version); err != nil {
+		return err
+	}
+	return mfs.Remove(path)
+}
@@ -0,0 +1,91 @@
+package mountablefs
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/api"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/memfs"
+)
+
+func TestConditionalOperations(t *testing.T) {
+	mfs := NewMountableFS(api.PoolConfig{})
+	p := memfs.NewMemFSPlugin()
+	if err := p.Initialize(map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.Mount("/mem", p); err != nil {
+		t.Fatal(err)
+	}
+	flags := filesystem.WriteFlagCreate | filesystem.WriteFlagTruncate
+
+	if _, err := mfs.WriteIf("/mem/plan.md", []byte("v1"), -1, flags, "*"); err == nil {
+		t.Error("expected conditional write of a missing file to fail")
+	}
+	if _, err := mfs.Write("/mem/plan.md", []byte("v1"), -1, flags); err != nil {
+		t.Fatal(err)
+	}
+	v1, err := mfs.Version("/mem/plan.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := mfs.WriteIf("/mem/plan.md", []byte("v2"), -1, flags, v1); err != nil {
+		t.Fatalf("expected write at the current version to succeed: %v", err)
+	}
+	v2, _ := mfs.Version("/mem/plan.md")
+	if v2 == v1 {
+		t.Fatal("expected the version to change with the write")
+	}
+
+	// A writer holding the old version loses
+	_, err = mfs.WriteIf("/mem/plan.md", []byte("stale"), -1, flags, v1)
+	var conflict *filesystem.ConflictError
+	if !errors.As(err, &conflict) || conflict.Actual != v2 {
+		t.Errorf("expected conflict with current version %s, got %v", v2, err)
+	}
+
+	if err := mfs.RemoveIf("/mem/plan.md", v1); !errors.Is(err, filesystem.ErrConflict) {
+		t.Errorf("expected conflict for stale remove, got %v", err)
+	}
+	if err := mfs.RemoveIf("/mem/plan.md", v2); err != nil {
+		t.Errorf("expected remove at the current version to succeed: %v", err)
+	}
+}
+
+func TestConditionalWritesRace(t *testing.T) {
+	mfs := NewMountableFS(api.PoolConfig{})
+	p := memfs.NewMemFSPlugin()
+	if err := p.Initialize(map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.Mount("/mem", p); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mfs.Write("/mem/counter", []byte("0"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatal(err)
+	}
+	version, _ := mfs.Version("/mem/counter")
+
+	// Of the agents that read the same version, only one can write
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := mfs.WriteIf("/mem/counter", []byte("1"), -1, filesystem.WriteFlagTruncate, version); err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if succeeded != 1 {
+		t.Errorf("expected exactly one conditional write to succeed, got %d", succeeded)
+	}
+}
@@ -0,0 +1,163 @@
+package mountablefs
+
+import (
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/middleware"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	pluginconfig "github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/ctl"
+)
+
+// Standard files of the control directory of a mount (see ctl.DirName)
+const (
+	CtlStatsFile  = "stats.json"
+	CtlConfigFile = "config.json"
+	CtlHealthFile = "health.json"
+	CtlTasksFile  = "tasks.json"
+)
+
+// MountStats is the content of the stats.json control file of a mount
+type MountStats struct {
+	Mount        string          `json:"mount"`
+	Plugin       string          `json:"plugin"`
+	Ready        bool            `json:"ready"` // The plugin is initialized (see MountDeferred)
+	OpenHandles  int             `json:"open_handles"`
+	RunningTasks int             `json:"running_tasks"`
+	Replicas     int             `json:"replicas,omitempty"`
+	Scheduler    *SchedulerStats `json:"scheduler,omitempty"` // nil if operations are unbounded
+}
+
+// CtlTasks is the content of the tasks.json control file of a mount
+type CtlTasks struct {
+	Available []string   `json:"available"` // Tasks the plugin can run
+	Tasks     []TaskInfo `json:"tasks"`     // Running and recently finished tasks of the mount
+}
+
+// ctlTaskRequest is written to tasks.json to start a task
+type ctlTaskRequest struct {
+	Task string            `json:"task"`
+	Args map[string]string `json:"args,omitempty"`
+}
+
+// RedactConfig hides the values of the secret parameters of a mount config,
+// those of the plugin and of the middlewares, in replica overrides too
+func RedactConfig(p plugin.ServicePlugin, cfg map[string]interface{}) map[string]interface{} {
+	secrets := append(plugin.SecretParamNames(p.GetConfigParams()), plugin.SecretParamNames(middleware.Params)...)
+	redacted := pluginconfig.RedactSecrets(cfg, secrets)
+
+	// Replicas override keys of the config, secrets among them
+	if replicas, ok := redacted[ReplicasKey].([]interface{}); ok && len(secrets) > 0 {
+		out := make([]interface{}, len(replicas))
+		for i, r := range replicas {
+			out[i] = r
+			if m, ok := r.(map[string]interface{}); ok {
+				out[i] = pluginconfig.RedactSecrets(m, secrets)
+			}
+		}
+		redacted[ReplicasKey] = out
+	}
+	return redacted
+}
+
+// ctlProvider returns the control files provider of a plugin, looking
+// through renames
+func ctlProvider(p plugin.ServicePlugin) (ctl.Provider, bool) {
+	if rp, ok := p.(*RenamedPlugin); ok {
+		p = rp.ServicePlugin
+	}
+	cp, ok := p.(ctl.Provider)
+	return cp, ok
+}
+
+// ctlPath checks if a path is in the control directory of a mount, returning
+// the mount and the file name ("" for the directory itself)
+func (mfs *MountableFS) ctlPath(path string) (*MountPoint, string, bool) {
+	mount, relPath, found := mfs.findMount(path)
+	if !found {
+		return nil, "", false
+	}
+	name, ok := ctl.Split(relPath)
+	if !ok {
+		return nil, "", false
+	}
+	return mount, name, true
+}
+
+// ctlDir returns the control directory of a mount. It is served by the
+// server rather than the plugin, so that it can be read while the mount is
+// unhealthy or not initialized yet; the plugin's own files are listed once
+// it is initialized.
+func (mfs *MountableFS) ctlDir(mount *MountPoint) *ctl.Dir {
+	files := []ctl.File{
+		{Name: CtlStatsFile, Read: func() (interface{}, error) {
+			return mfs.mountStats(mount), nil
+		}},
+		{Name: CtlConfigFile, Read: func() (interface{}, error) {
+			return RedactConfig(mount.Plugin, mount.Config), nil
+		}},
+		{Name: CtlHealthFile, Read: func() (interface{}, error) {
+			return mount.mountHealth(), nil
+		}},
+		{Name: CtlTasksFile, Read: func() (interface{}, error) {
+			return mfs.ctlTasks(mount), nil
+		}, Write: func(data []byte) error {
+			return mfs.ctlStartTask(mount, data)
+		}},
+	}
+	if mount.Ready() {
+		if p, ok := ctlProvider(mount.Plugin); ok {
+			files = append(files, p.ControlFiles()...)
+		}
+	}
+	return ctl.NewDir(files...)
+}
+
+// mountStats returns the stats of a mount
+func (mfs *MountableFS) mountStats(mount *MountPoint) MountStats {
+	stats := MountStats{
+		Mount:       mount.Path,
+		Plugin:      mount.Plugin.Name(),
+		Ready:       mount.Ready(),
+		OpenHandles: len(mfs.FindOpenHandles(HandleFilter{Path: mount.Path})),
+		Replicas:    len(mount.replicas),
+	}
+	for _, t := range mfs.ListTasks() {
+		if t.Mount == mount.Path && t.Status == TaskStatusRunning {
+			stats.RunningTasks++
+		}
+	}
+	if s := mount.Scheduler(); s != nil {
+		s := s.Stats()
+		stats.Scheduler = &s
+	}
+	return stats
+}
+
+// ctlTasks returns the tasks a mount can run and those it ran
+func (mfs *MountableFS) ctlTasks(mount *MountPoint) CtlTasks {
+	tasks := CtlTasks{Available: []string{}, Tasks: []TaskInfo{}}
+	if tr, ok := taskRunner(mount.Plugin); ok {
+		tasks.Available = append(tasks.Available, tr.Tasks()...)
+	}
+	for _, t := range mfs.ListTasks() {
+		if t.Mount == mount.Path {
+			tasks.Tasks = append(tasks.Tasks, t)
+		}
+	}
+	return tasks
+}
+
+// ctlStartTask starts a task of a mount from a document written to tasks.json,
+// e.g. {"task": "reindex", "args": {"path": "/ns"}}
+func (mfs *MountableFS) ctlStartTask(mount *MountPoint, data []byte) error {
+	path := joinMountPath(mount.Path, "/"+ctl.DirName+"/"+CtlTasksFile)
+	var req ctlTaskRequest
+	if err := ctl.Decode(path, data, &req); err != nil {
+		return err
+	}
+	if req.Task == "" {
+		return filesystem.NewInvalidArgumentError("write", path, `expected {"task": "<name>", "args": {...}}`)
+	}
+	_, err := mfs.StartTask(mount.Path, req.Task, req.Args)
+	return err
+}
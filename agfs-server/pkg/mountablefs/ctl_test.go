@@ -0,0 +1,186 @@
+package mountablefs
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/api"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/ctl"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/memfs"
+)
+
+// ctlPlugin is a task plugin with a secret parameter and a control file of
+// its own
+type ctlPlugin struct {
+	taskPlugin
+	mode string
+}
+
+func (p *ctlPlugin) GetConfigParams() []plugin.ConfigParameter {
+	return []plugin.ConfigParameter{{Name: "token", Type: "string", Secret: true}}
+}
+
+func (p *ctlPlugin) ControlFiles() []ctl.File {
+	return []ctl.File{
+		{Name: "mode.json", Read: func() (interface{}, error) {
+			return map[string]string{"mode": p.mode}, nil
+		}, Write: func(data []byte) error {
+			var v struct{ Mode string }
+			if err := ctl.Decode("mode.json", data, &v); err != nil {
+				return err
+			}
+			p.mode = v.Mode
+			return nil
+		}},
+		// Standard files cannot be replaced
+		{Name: CtlStatsFile, Read: func() (interface{}, error) {
+			return "shadowed", nil
+		}},
+	}
+}
+
+func readCtlJSON(t *testing.T, mfs *MountableFS, path string, v interface{}) {
+	t.Helper()
+	data, err := mfs.Read(path, 0, -1)
+	if err != nil && err != io.EOF {
+		t.Fatalf("Read %s failed: %v", path, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("%s is not JSON: %v\n%s", path, err, data)
+	}
+}
+
+func TestCtlDir(t *testing.T) {
+	mfs := NewMountableFS(api.PoolConfig{})
+	p := &ctlPlugin{taskPlugin: taskPlugin{MemFSPlugin: memfs.NewMemFSPlugin()}, mode: "fast"}
+	if err := p.Initialize(map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.Mount("/jobs", p); err != nil {
+		t.Fatal(err)
+	}
+	mount, _, _ := mfs.findMount("/jobs")
+	mount.Config = map[string]interface{}{"token": "s3cr3t", "region": "eu"}
+
+	infos, err := mfs.ReadDir("/jobs/.ctl")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name)
+	}
+	if got := strings.Join(names, ","); got != "config.json,health.json,mode.json,stats.json,tasks.json" {
+		t.Errorf("unexpected control files %s", got)
+	}
+	if info, err := mfs.Stat("/jobs/.ctl"); err != nil || !info.IsDir {
+		t.Errorf("expected .ctl to be a directory, got %+v, %v", info, err)
+	}
+	if info, err := mfs.Stat("/jobs/.ctl/tasks.json"); err != nil || info.Size == 0 || info.Mode != 0644 {
+		t.Errorf("unexpected tasks.json info %+v, %v", info, err)
+	}
+
+	// The control directory is not listed in the mount
+	root, err := mfs.ReadDir("/jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, info := range root {
+		if info.Name == ctl.DirName {
+			t.Errorf("expected %s to be hidden from the mount listing", ctl.DirName)
+		}
+	}
+
+	var cfg map[string]interface{}
+	readCtlJSON(t, mfs, "/jobs/.ctl/config.json", &cfg)
+	if cfg["token"] == "s3cr3t" || cfg["region"] != "eu" {
+		t.Errorf("expected the token to be redacted, got %v", cfg)
+	}
+
+	var stats MountStats
+	readCtlJSON(t, mfs, "/jobs/.ctl/stats.json", &stats)
+	if stats.Mount != "/jobs" || !stats.Ready {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	var mode map[string]string
+	if _, err := mfs.Write("/jobs/.ctl/mode.json", []byte(`{"mode": "safe"}`), 0, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("Write mode.json failed: %v", err)
+	}
+	readCtlJSON(t, mfs, "/jobs/.ctl/mode.json", &mode)
+	if mode["mode"] != "safe" {
+		t.Errorf("expected the plugin's control file to be written, got %v", mode)
+	}
+
+	_, err = mfs.Write("/jobs/.ctl/stats.json", []byte(`{}`), 0, filesystem.WriteFlagNone)
+	if !errors.Is(err, filesystem.ErrPermissionDenied) {
+		t.Errorf("expected stats.json to be read-only, got %v", err)
+	}
+	if _, err := mfs.Read("/jobs/.ctl/missing.json", 0, -1); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("expected not found, got %v", err)
+	}
+	if _, err := mfs.OpenHandle("/jobs/.ctl/stats.json", filesystem.O_RDONLY, 0); !errors.Is(err, filesystem.ErrNotSupported) {
+		t.Errorf("expected handles on control files to be unsupported, got %v", err)
+	}
+}
+
+func TestCtlTasks(t *testing.T) {
+	mfs := newTaskFS(t)
+
+	_, err := mfs.Write("/jobs/.ctl/tasks.json", []byte(`{"task": "count", "args": {"path": "/docs"}}`), 0, filesystem.WriteFlagNone)
+	if err != nil {
+		t.Fatalf("starting a task failed: %v", err)
+	}
+	var tasks CtlTasks
+	readCtlJSON(t, mfs, "/jobs/.ctl/tasks.json", &tasks)
+	if len(tasks.Available) != 2 || len(tasks.Tasks) != 1 || tasks.Tasks[0].Task != "count" {
+		t.Fatalf("unexpected tasks %+v", tasks)
+	}
+	if info := waitForTask(t, mfs, tasks.Tasks[0].ID); info.Status != TaskStatusSucceeded || info.Args["path"] != "/docs" {
+		t.Errorf("unexpected task %+v", info)
+	}
+
+	for _, body := range []string{`{"task": "nope"}`, `{}`, `not json`, `{"task": "count", "extra": 1}`} {
+		_, err := mfs.Write("/jobs/.ctl/tasks.json", []byte(body), 0, filesystem.WriteFlagNone)
+		if !errors.Is(err, filesystem.ErrInvalidArgument) {
+			t.Errorf("%s: expected an invalid argument error, got %v", body, err)
+		}
+	}
+}
+
+func TestCtlHealthWhileUnhealthy(t *testing.T) {
+	mfs := NewMountableFS(api.PoolConfig{})
+	p := newHealthPlugin(t)
+	if err := mfs.Mount("/db", p); err != nil {
+		t.Fatal(err)
+	}
+	p.setHealth(errors.New("connection refused"))
+	mfs.CheckHealth()
+
+	if _, err := mfs.Read("/db/a.txt", 0, -1); !errors.Is(err, filesystem.ErrUnavailable) {
+		t.Fatalf("expected the mount to be unavailable, got %v", err)
+	}
+	var health MountHealth
+	readCtlJSON(t, mfs, "/db/.ctl/health.json", &health)
+	if health.Health == nil || health.Health.Healthy() || health.Health.Error != "connection refused" {
+		t.Errorf("unexpected health %+v", health.Health)
+	}
+}
+
+func TestCtlSplit(t *testing.T) {
+	for path, want := range map[string]string{"/.ctl": "", "/.ctl/": "", "/.ctl/stats.json": "stats.json"} {
+		if name, ok := ctl.Split(path); !ok || name != want {
+			t.Errorf("Split(%q) = %q, %v", path, name, ok)
+		}
+	}
+	for _, path := range []string{"/", "/.ctlx", "/a/.ctl", "/.ctl/a/b"} {
+		if _, ok := ctl.Split(path); ok {
+			t.Errorf("Split(%q): expected no control path", path)
+		}
+	}
+}
@@ -0,0 +1,410 @@
+package mountablefs
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/middleware"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	pluginconfig "github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	iradix "github.com/hashicorp/go-immutable-radix"
+	log "github.com/sirupsen/logrus"
+)
+
+// Mount config keys controlling when the plugin of a mount is initialized
+// (see TakeInitOptions)
+const (
+	LazyKey      = "lazy"
+	DependsOnKey = "depends_on"
+)
+
+// DefaultInitRetryBackoff is the wait after the first failed initialization of
+// a mount; it doubles with each further failure, up to MaxInitRetryBackoff
+const (
+	DefaultInitRetryBackoff = time.Second
+	MaxInitRetryBackoff     = time.Minute
+)
+
+// Initialization states of a mount
+const (
+	InitStatePending      = "pending"      // Lazy mount not accessed yet
+	InitStateInitializing = "initializing" // First attempt running
+	InitStateFailed       = "failed"       // Last attempt failed, will be retried
+	InitStateReady        = "ready"
+)
+
+// InitOptions control when the plugin of a mount is initialized
+type InitOptions struct {
+	// Lazy initializes the plugin on the first access to the mount instead
+	// of at startup, e.g. to connect to a database only once it is used
+	Lazy bool
+	// DependsOn lists mount paths whose plugins must be initialized first
+	DependsOn []string
+	// Replicas are the read replicas of the mount (see ReplicasKey), which
+	// are initialized after its plugin
+	Replicas []Replica
+}
+
+// Replica is a read replica of a mount initialized after mounting: a plugin
+// instance and the config it is initialized with
+type Replica struct {
+	Plugin plugin.ServicePlugin
+	Config map[string]interface{}
+}
+
+// NewReplicas creates and validates a plugin instance for each replica config
+// of a mount at path (see TakeReplicaConfigs)
+func NewReplicas(factory PluginFactory, path string, configs []map[string]interface{}) ([]Replica, error) {
+	replicas := make([]Replica, 0, len(configs))
+	for i, cfg := range configs {
+		cfg["mount_path"] = path
+		instance := factory()
+		if _, ok := readRouter(instance); !ok {
+			return nil, fmt.Errorf("plugin %s does not support read replicas", instance.Name())
+		}
+		if err := instance.Validate(cfg); err != nil {
+			return nil, fmt.Errorf("replica %d: %v", i, err)
+		}
+		replicas = append(replicas, Replica{Plugin: instance, Config: cfg})
+	}
+	return replicas, nil
+}
+
+// initializeReplicas initializes the plugin of a mount with initialize, then
+// its replicas; if a replica fails, the ones initialized and the plugin are
+// shut down so that the next attempt starts over
+func initializeReplicas(p plugin.ServicePlugin, initialize func() error, replicas []Replica) func() error {
+	if len(replicas) == 0 {
+		return initialize
+	}
+	return func() error {
+		if err := initialize(); err != nil {
+			return err
+		}
+		for i, r := range replicas {
+			if err := r.Plugin.Initialize(r.Config); err != nil {
+				for _, started := range replicas[:i] {
+					started.Plugin.Shutdown()
+				}
+				p.Shutdown()
+				return fmt.Errorf("replica %d: %v", i, err)
+			}
+		}
+		return nil
+	}
+}
+
+// TakeInitOptions takes the init options (lazy, depends_on) out of a mount
+// config, returning them and the config without their keys
+func TakeInitOptions(cfg map[string]interface{}) (InitOptions, map[string]interface{}, error) {
+	var opts InitOptions
+	if err := pluginconfig.ValidateBoolType(cfg, LazyKey); err != nil {
+		return opts, nil, err
+	}
+	opts.Lazy = pluginconfig.GetBoolConfig(cfg, LazyKey, false)
+
+	switch deps := cfg[DependsOnKey].(type) {
+	case nil:
+	case string:
+		opts.DependsOn = []string{filesystem.NormalizePath(deps)}
+	case []interface{}:
+		for i, dep := range deps {
+			s, ok := dep.(string)
+			if !ok {
+				return opts, nil, fmt.Errorf("%s[%d] must be a mount path", DependsOnKey, i)
+			}
+			opts.DependsOn = append(opts.DependsOn, filesystem.NormalizePath(s))
+		}
+	default:
+		return opts, nil, fmt.Errorf("%s must be a mount path or a list of them", DependsOnKey)
+	}
+
+	rest := make(map[string]interface{}, len(cfg))
+	for k, v := range cfg {
+		if k != LazyKey && k != DependsOnKey {
+			rest[k] = v
+		}
+	}
+	return opts, rest, nil
+}
+
+// InitStatus is the initialization state of a mount whose plugin was
+// mounted before being initialized
+type InitStatus struct {
+	State       string    `json:"state"`
+	Lazy        bool      `json:"lazy,omitempty"`
+	DependsOn   []string  `json:"depends_on,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt,omitempty"` // Earliest retry after a failure
+}
+
+// mountInit initializes the plugin of a mount after it was mounted
+type mountInit struct {
+	path       string
+	opts       InitOptions
+	initialize func() error
+	deps       func() error // Checks that the dependencies are ready
+	backoff    time.Duration
+
+	mu     sync.Mutex // Serializes attempts
+	ready  atomic.Bool
+	status atomic.Pointer[InitStatus]
+	stop   chan struct{} // Closed when the mount is removed
+	once   sync.Once
+}
+
+// Ready reports whether the plugin of the mount is initialized
+func (m *MountPoint) Ready() bool {
+	return m.init == nil || m.init.ready.Load()
+}
+
+// InitStatus returns the initialization state of the mount, nil if its
+// plugin was initialized before mounting
+func (m *MountPoint) InitStatus() *InitStatus {
+	if m.init == nil {
+		return nil
+	}
+	return m.init.status.Load()
+}
+
+// checkReady initializes a lazy mount on its first access; it returns an
+// error while the plugin of the mount is not initialized
+func (m *MountPoint) checkReady() error {
+	if m.Ready() {
+		return nil
+	}
+	if err := m.init.ensure(); err != nil {
+		return filesystem.NewUnavailableError(m.Path, err.Error())
+	}
+	return nil
+}
+
+// ensure initializes a lazy mount if it is not ready and its retry is due;
+// for other mounts it returns the state of the background initialization
+func (i *mountInit) ensure() error {
+	if i.ready.Load() {
+		return nil
+	}
+	if !i.opts.Lazy {
+		return i.status.Load().err()
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.ready.Load() {
+		return nil
+	}
+	select {
+	case <-i.stop:
+		return fmt.Errorf("unmounted")
+	default:
+	}
+	if st := i.status.Load(); time.Now().Before(st.NextAttempt) {
+		return st.err()
+	}
+	return i.attempt()
+}
+
+// err returns the error of a mount that is not ready
+func (st *InitStatus) err() error {
+	if st.Error != "" {
+		return fmt.Errorf("initialization failed (%d attempts): %s", st.Attempts, st.Error)
+	}
+	return fmt.Errorf("initializing")
+}
+
+// attempt initializes the plugin once; i.mu is held
+func (i *mountInit) attempt() error {
+	prev := i.status.Load()
+	next := *prev
+	next.Attempts++
+	if prev.Attempts == 0 {
+		next.State = InitStateInitializing
+		i.status.Store(&next)
+	}
+
+	err := i.deps()
+	if err == nil {
+		err = i.initialize()
+	}
+	if err == nil {
+		i.ready.Store(true)
+		next.State = InitStateReady
+		next.Error = ""
+		next.NextAttempt = time.Time{}
+		i.status.Store(&next)
+		if next.Attempts > 1 {
+			log.Infof("Mount %s initialized after %d attempts", i.path, next.Attempts)
+		} else {
+			log.Infof("Mount %s initialized", i.path)
+		}
+		return nil
+	}
+
+	wait := i.backoff << (next.Attempts - 1)
+	if wait > MaxInitRetryBackoff || wait <= 0 {
+		wait = MaxInitRetryBackoff
+	}
+	next.State = InitStateFailed
+	next.Error = err.Error()
+	next.NextAttempt = time.Now().Add(wait)
+	i.status.Store(&next)
+	log.Warnf("Failed to initialize mount %s (attempt %d, retrying in %v): %v", i.path, next.Attempts, wait, err)
+	return next.err()
+}
+
+// run initializes the plugin in the background, retrying with backoff until
+// it succeeds or the mount is removed
+func (i *mountInit) run() {
+	for {
+		i.mu.Lock()
+		err := i.attempt()
+		i.mu.Unlock()
+		if err == nil {
+			return
+		}
+		select {
+		case <-i.stop:
+			return
+		case <-time.After(time.Until(i.status.Load().NextAttempt)):
+		}
+	}
+}
+
+// close stops the initialization of a removed mount, and reports whether the
+// plugin was initialized and needs to be shut down
+func (i *mountInit) close() bool {
+	i.once.Do(func() { close(i.stop) })
+	// Wait for an attempt in progress
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.ready.Load()
+}
+
+// MountDeferred mounts a plugin before initializing it, so that a backend that
+// is slow or unreachable does not hold up the server. Until initialize has
+// succeeded, requests to the mount fail with ErrUnavailable. Unless the mount
+// is lazy, initialization starts right away in the background and failed
+// attempts are retried with backoff; a lazy mount is initialized by its first
+// request, and a failed attempt is retried by a later request once the
+// backoff has passed. Mounts listed in opts.DependsOn are initialized first.
+func (mfs *MountableFS) MountDeferred(path string, p plugin.ServicePlugin, initialize func() error, opts InitOptions, middlewares ...middleware.Middleware) error {
+	mfs.mu.Lock()
+	defer mfs.mu.Unlock()
+
+	path = filesystem.NormalizePath(path)
+	tree := mfs.mountTree.Load().(*iradix.Tree)
+	if _, exists := tree.Get([]byte(path)); exists {
+		return filesystem.NewAlreadyExistsError("mount", path)
+	}
+	if cycle := dependencyCycle(tree, path, opts.DependsOn); cycle != nil {
+		return fmt.Errorf("mount dependency cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	type parentFSSetter interface {
+		SetParentFileSystem(filesystem.FileSystem)
+	}
+	if setter, ok := p.(parentFSSetter); ok {
+		setter.SetParentFileSystem(mfs)
+	}
+
+	mfs.insertDeferred(tree, path, p, make(map[string]interface{}), middlewares, initialize, opts)
+	return nil
+}
+
+// insertDeferred adds a mount whose plugin is initialized by initialize, and
+// starts its initialization unless it is lazy; mfs.mu is held
+func (mfs *MountableFS) insertDeferred(tree *iradix.Tree, path string, p plugin.ServicePlugin, config map[string]interface{}, middlewares []middleware.Middleware, initialize func() error, opts InitOptions) {
+	backoff := mfs.InitRetryBackoff
+	if backoff <= 0 {
+		backoff = DefaultInitRetryBackoff
+	}
+	init := &mountInit{
+		path:       path,
+		opts:       opts,
+		initialize: initializeReplicas(p, initialize, opts.Replicas),
+		deps:       func() error { return mfs.dependenciesReady(opts.DependsOn) },
+		backoff:    backoff,
+		stop:       make(chan struct{}),
+	}
+	state := InitStateInitializing
+	if opts.Lazy {
+		state = InitStatePending
+	}
+	init.status.Store(&InitStatus{State: state, Lazy: opts.Lazy, DependsOn: opts.DependsOn})
+
+	// Replicas are ready once the mount is
+	var replicas []*MountPoint
+	for _, r := range opts.Replicas {
+		replicas = append(replicas, &MountPoint{Path: path, Plugin: r.Plugin, init: init})
+	}
+
+	newTree, _, _ := tree.Insert([]byte(path), &MountPoint{
+		Path:        path,
+		Plugin:      p,
+		Config:      config,
+		middlewares: middlewares,
+		replicas:    replicas,
+		init:        init,
+	})
+	mfs.mountTree.Store(newTree)
+
+	if !opts.Lazy {
+		go init.run()
+	}
+}
+
+// deferred reports whether the options ask for initialization after mounting
+func (o InitOptions) deferred() bool {
+	return o.Lazy || len(o.DependsOn) > 0
+}
+
+// dependenciesReady checks that the mounts at paths are initialized,
+// initializing lazy ones
+func (mfs *MountableFS) dependenciesReady(paths []string) error {
+	tree := mfs.mountTree.Load().(*iradix.Tree)
+	for _, dep := range paths {
+		v, ok := tree.Get([]byte(dep))
+		if !ok {
+			return fmt.Errorf("waiting for mount %s", dep)
+		}
+		if mount := v.(*MountPoint); !mount.Ready() {
+			if err := mount.init.ensure(); err != nil {
+				return fmt.Errorf("waiting for mount %s: %v", dep, err)
+			}
+		}
+	}
+	return nil
+}
+
+// dependencyCycle returns the cycle that mounting path with dependencies deps
+// would close, nil if there is none. Each mount is checked as it is added, so
+// the existing mounts have no cycle.
+func dependencyCycle(tree *iradix.Tree, path string, deps []string) []string {
+	var visit func(p string, chain []string) []string
+	visit = func(p string, chain []string) []string {
+		if p == path {
+			return append(chain, p)
+		}
+		v, ok := tree.Get([]byte(p))
+		if !ok || v.(*MountPoint).init == nil {
+			return nil
+		}
+		for _, dep := range v.(*MountPoint).init.opts.DependsOn {
+			if cycle := visit(dep, append(chain, p)); cycle != nil {
+				return cycle
+			}
+		}
+		return nil
+	}
+	for _, dep := range deps {
+		if cycle := visit(dep, []string{path}); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
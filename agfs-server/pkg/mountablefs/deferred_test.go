@@ -0,0 +1,193 @@
+package mountablefs
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/api"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/memfs"
+)
+
+// flakyPlugin is a memfs whose initialization fails a number of times
+type flakyPlugin struct {
+	*memfs.MemFSPlugin
+	failures  int32 // Initializations left to fail
+	attempts  atomic.Int32
+	shutdowns atomic.Int32
+}
+
+func newFlakyPlugin(failures int32) *flakyPlugin {
+	return &flakyPlugin{MemFSPlugin: memfs.NewMemFSPlugin(), failures: failures}
+}
+
+func (p *flakyPlugin) initialize() error {
+	if p.attempts.Add(1) <= p.failures {
+		return fmt.Errorf("connection refused")
+	}
+	return p.Initialize(map[string]interface{}{})
+}
+
+func (p *flakyPlugin) Shutdown() error {
+	p.shutdowns.Add(1)
+	return p.MemFSPlugin.Shutdown()
+}
+
+func waitReady(t *testing.T, mfs *MountableFS, path string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		mount, _, ok := mfs.findMount(path)
+		if ok && mount.Ready() {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("mount %s not ready in time", path)
+}
+
+func TestMountDeferredRetriesInitialization(t *testing.T) {
+	mfs := NewMountableFS(api.PoolConfig{})
+	mfs.InitRetryBackoff = time.Millisecond
+	p := newFlakyPlugin(3)
+	block := make(chan struct{})
+	initialize := func() error {
+		<-block
+		return p.initialize()
+	}
+	if err := mfs.MountDeferred("/db", p, initialize, InitOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The mount exists but is unavailable until its plugin is initialized
+	if err := mfs.Create("/db/a.txt"); !errors.Is(err, filesystem.ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable while initializing, got %v", err)
+	}
+	if st := mfs.GetMounts()[0].InitStatus(); st.State != InitStateInitializing {
+		t.Fatalf("expected state %s, got %+v", InitStateInitializing, st)
+	}
+
+	close(block)
+	waitReady(t, mfs, "/db")
+	if got := p.attempts.Load(); got != 4 {
+		t.Fatalf("expected 4 attempts, got %d", got)
+	}
+	if st := mfs.GetMounts()[0].InitStatus(); st.State != InitStateReady || st.Error != "" {
+		t.Fatalf("expected state %s, got %+v", InitStateReady, st)
+	}
+	if err := mfs.Create("/db/a.txt"); err != nil {
+		t.Fatalf("Create after initialization failed: %v", err)
+	}
+}
+
+func TestLazyMountInitializesOnFirstAccess(t *testing.T) {
+	mfs := NewMountableFS(api.PoolConfig{})
+	mfs.InitRetryBackoff = time.Hour
+	p := newFlakyPlugin(1)
+	if err := mfs.MountDeferred("/s3", p, p.initialize, InitOptions{Lazy: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Health checks and listings of the root don't initialize the mount
+	mfs.CheckHealth()
+	if _, err := mfs.ReadDir("/"); err != nil {
+		t.Fatalf("ReadDir of root failed: %v", err)
+	}
+	if got := p.attempts.Load(); got != 0 {
+		t.Fatalf("lazy mount initialized before access (%d attempts)", got)
+	}
+	if st := mfs.GetMounts()[0].InitStatus(); st.State != InitStatePending {
+		t.Fatalf("expected state %s, got %+v", InitStatePending, st)
+	}
+
+	// The first access fails, and the next one waits for the backoff
+	if err := mfs.Create("/s3/a.txt"); !errors.Is(err, filesystem.ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable, got %v", err)
+	}
+	if _, err := mfs.Stat("/s3/a.txt"); !errors.Is(err, filesystem.ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable during backoff, got %v", err)
+	}
+	if got := p.attempts.Load(); got != 1 {
+		t.Fatalf("expected 1 attempt during backoff, got %d", got)
+	}
+
+	// Once the backoff has passed, an access retries
+	mount, _, _ := mfs.findMount("/s3")
+	st := *mount.InitStatus()
+	st.NextAttempt = time.Time{}
+	mount.init.status.Store(&st)
+	if err := mfs.Create("/s3/a.txt"); err != nil {
+		t.Fatalf("Create after retry failed: %v", err)
+	}
+	if got := p.attempts.Load(); got != 2 {
+		t.Fatalf("expected 2 attempts, got %d", got)
+	}
+}
+
+func TestMountDependencies(t *testing.T) {
+	mfs := NewMountableFS(api.PoolConfig{})
+	db := newFlakyPlugin(0)
+	index := newFlakyPlugin(0)
+	if err := mfs.MountDeferred("/index", index, index.initialize, InitOptions{Lazy: true, DependsOn: []string{"/db"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.MountDeferred("/db", db, db.initialize, InitOptions{Lazy: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Accessing the dependent mount initializes its dependency first
+	if err := mfs.Create("/index/a.txt"); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if db.attempts.Load() != 1 || index.attempts.Load() != 1 {
+		t.Fatalf("expected one initialization each, got db=%d index=%d", db.attempts.Load(), index.attempts.Load())
+	}
+
+	// Cycles are rejected when the mount closing them is added
+	cache := newFlakyPlugin(0)
+	if err := mfs.MountDeferred("/cache", cache, cache.initialize, InitOptions{DependsOn: []string{"/cache2"}}); err != nil {
+		t.Fatal(err)
+	}
+	err := mfs.MountDeferred("/cache2", cache, cache.initialize, InitOptions{Lazy: true, DependsOn: []string{"/cache"}})
+	if err == nil {
+		t.Fatal("expected a dependency cycle error")
+	}
+}
+
+func TestUnmountUninitializedMount(t *testing.T) {
+	mfs := NewMountableFS(api.PoolConfig{})
+	p := newFlakyPlugin(0)
+	if err := mfs.MountDeferred("/lazy", p, p.initialize, InitOptions{Lazy: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.Unmount("/lazy"); err != nil {
+		t.Fatalf("Unmount failed: %v", err)
+	}
+	if p.attempts.Load() != 0 || p.shutdowns.Load() != 0 {
+		t.Fatalf("uninitialized plugin was initialized (%d) or shut down (%d)", p.attempts.Load(), p.shutdowns.Load())
+	}
+}
+
+func TestTakeInitOptions(t *testing.T) {
+	opts, rest, err := TakeInitOptions(map[string]interface{}{
+		"lazy":       true,
+		"depends_on": []interface{}{"/db/", "vectors"},
+		"dsn":        "x",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !opts.Lazy || len(opts.DependsOn) != 2 || opts.DependsOn[0] != "/db" || opts.DependsOn[1] != "/vectors" {
+		t.Fatalf("unexpected options %+v", opts)
+	}
+	if len(rest) != 1 || rest["dsn"] != "x" {
+		t.Fatalf("expected only dsn left, got %v", rest)
+	}
+
+	if _, _, err := TakeInitOptions(map[string]interface{}{"depends_on": 3}); err == nil {
+		t.Fatal("expected an error for an invalid depends_on")
+	}
+}
@@ -1,6 +1,7 @@
 package mountablefs
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
@@ -238,3 +239,40 @@ func TestMultipleHandlesSameFile(t *testing.T) {
 		}
 	}
 }
+
+func TestHandleVectoredIO(t *testing.T) {
+	mfs := newMoveTestFS(t)
+	mfs.Limits = Limits{MaxReadSize: 64}
+	h, err := mfs.OpenHandle("/a/db", filesystem.O_RDWR|filesystem.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	written, err := filesystem.WriteV(h, []filesystem.IOVec{
+		{Offset: 0, Data: []byte("header")},
+		{Offset: 10, Data: []byte("page1")},
+		{Offset: 20, Data: []byte("page2")},
+	})
+	if err != nil || len(written) != 3 || written[2] != 5 {
+		t.Fatalf("WriteV = %v, %v", written, err)
+	}
+
+	vecs := []filesystem.IOVec{
+		{Offset: 20, Data: make([]byte, 5)},
+		{Offset: 0, Data: make([]byte, 6)},
+		{Offset: 22, Data: make([]byte, 10)}, // Past the end of the file
+	}
+	read, err := filesystem.ReadV(h, vecs)
+	if err != nil {
+		t.Fatalf("ReadV failed: %v", err)
+	}
+	if read[0] != 5 || string(vecs[0].Data) != "page2" || string(vecs[1].Data) != "header" || read[2] != 3 {
+		t.Errorf("ReadV read %v: %q, %q, %q", read, vecs[0].Data, vecs[1].Data, vecs[2].Data[:read[2]])
+	}
+
+	// Limits apply to the total size of the ranges
+	big := []filesystem.IOVec{{Offset: 0, Data: make([]byte, 40)}, {Offset: 0, Data: make([]byte, 40)}}
+	if _, err := filesystem.ReadV(h, big); !errors.Is(err, filesystem.ErrQuotaExceeded) {
+		t.Errorf("expected ReadV beyond the read limit to fail, got %v", err)
+	}
+}
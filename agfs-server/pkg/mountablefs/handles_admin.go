@@ -0,0 +1,169 @@
+package mountablefs
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	log "github.com/sirupsen/logrus"
+)
+
+// OpenHandleInfo describes an open file handle, as listed by ListOpenHandles
+type OpenHandleInfo struct {
+	ID     int64  `json:"id"`
+	Path   string `json:"path"`
+	Mount  string `json:"mount"`
+	Flags  int    `json:"flags"`            // filesystem.OpenFlag bits
+	Client string `json:"client,omitempty"` // Who opened it (see OpenHandleFor)
+}
+
+// HandleFilter selects open handles; its zero value selects all of them
+type HandleFilter struct {
+	Path   string // Handles on files at or below this path, if set
+	Client string // Handles opened by this client, if set
+}
+
+func (f HandleFilter) matches(h OpenHandleInfo) bool {
+	return (f.Path == "" || isUnder(h.Path, filesystem.NormalizePath(f.Path))) &&
+		(f.Client == "" || h.Client == f.Client)
+}
+
+// maxRevokedHandles bounds how many revoked handles are remembered to tell
+// their clients; older ones are reported as not found
+const maxRevokedHandles = 10000
+
+func (info *handleInfo) describe(id int64) OpenHandleInfo {
+	return OpenHandleInfo{
+		ID:     id,
+		Path:   joinMountPath(info.mount.Path, info.localHandle.Path()),
+		Mount:  info.mount.Path,
+		Flags:  int(info.localHandle.Flags()),
+		Client: info.client,
+	}
+}
+
+// ListOpenHandles returns the open file handles, ordered by ID
+func (mfs *MountableFS) ListOpenHandles() []OpenHandleInfo {
+	return mfs.FindOpenHandles(HandleFilter{})
+}
+
+// FindOpenHandles returns the open file handles matching filter, ordered by ID
+func (mfs *MountableFS) FindOpenHandles(filter HandleFilter) []OpenHandleInfo {
+	mfs.handleInfosMu.RLock()
+	handles := make([]OpenHandleInfo, 0, len(mfs.handleInfos))
+	for id, info := range mfs.handleInfos {
+		if h := info.describe(id); filter.matches(h) {
+			handles = append(handles, h)
+		}
+	}
+	mfs.handleInfosMu.RUnlock()
+
+	sort.Slice(handles, func(i, j int) bool { return handles[i].ID < handles[j].ID })
+	return handles
+}
+
+// RevokeHandles forcibly closes the open handles matching filter, e.g. before
+// maintenance on a backend, and returns them. Unlike handles closed by
+// CloseHandlesUnder, the clients of revoked handles get an ErrRevoked error
+// when they use them next, telling them to open the file again.
+func (mfs *MountableFS) RevokeHandles(filter HandleFilter, reason string) []OpenHandleInfo {
+	if reason == "" {
+		reason = "revoked by an administrator"
+	}
+
+	mfs.handleInfosMu.Lock()
+	var revoked []OpenHandleInfo
+	var closing []*handleInfo
+	for id, info := range mfs.handleInfos {
+		h := info.describe(id)
+		if !filter.matches(h) {
+			continue
+		}
+		revoked = append(revoked, h)
+		closing = append(closing, info)
+		delete(mfs.handleInfos, id)
+		mfs.revoked[id] = reason
+		mfs.revokedOrder = append(mfs.revokedOrder, id)
+	}
+	for len(mfs.revokedOrder) > maxRevokedHandles {
+		delete(mfs.revoked, mfs.revokedOrder[0])
+		mfs.revokedOrder = mfs.revokedOrder[1:]
+	}
+	mfs.handleInfosMu.Unlock()
+
+	for _, info := range closing {
+		if err := info.localHandle.Close(); err != nil {
+			log.Warnf("Failed to close revoked handle on %s: %v", info.localHandle.Path(), err)
+		}
+	}
+	sort.Slice(revoked, func(i, j int) bool { return revoked[i].ID < revoked[j].ID })
+	if len(revoked) > 0 {
+		log.Infof("Revoked %d handle(s) (path: %q, client: %q): %s", len(revoked), filter.Path, filter.Client, reason)
+	}
+	return revoked
+}
+
+// missingHandle returns the error for a handle ID that is not open: an
+// ErrRevoked error if the handle was revoked, ErrNotFound otherwise
+func (mfs *MountableFS) missingHandle(id int64) error {
+	mfs.handleInfosMu.RLock()
+	reason, revoked := mfs.revoked[id]
+	mfs.handleInfosMu.RUnlock()
+	if revoked {
+		return fmt.Errorf("handle %d: %w: %s", id, filesystem.ErrRevoked, reason)
+	}
+	return filesystem.ErrNotFound
+}
+
+// forgetRevoked forgets a revoked handle, once its client closed it, and
+// reports whether it was revoked
+func (mfs *MountableFS) forgetRevoked(id int64) bool {
+	mfs.handleInfosMu.Lock()
+	defer mfs.handleInfosMu.Unlock()
+	if _, revoked := mfs.revoked[id]; !revoked {
+		return false
+	}
+	delete(mfs.revoked, id)
+	// revokedOrder keeps the ID until it is trimmed, deleting it again then
+	return true
+}
+
+// CloseHandlesUnder closes every open handle on a file at or below prefix
+// ("/" closes all of them) and returns the number of handles closed
+// Handles that fail to close are dropped as well, since their owner cannot use
+// them any more either.
+func (mfs *MountableFS) CloseHandlesUnder(prefix string) int {
+	prefix = filesystem.NormalizePath(prefix)
+
+	mfs.handleInfosMu.Lock()
+	var drained []*handleInfo
+	for id, info := range mfs.handleInfos {
+		if isUnder(joinMountPath(info.mount.Path, info.localHandle.Path()), prefix) {
+			drained = append(drained, info)
+			delete(mfs.handleInfos, id)
+		}
+	}
+	mfs.handleInfosMu.Unlock()
+
+	for _, info := range drained {
+		if err := info.localHandle.Close(); err != nil {
+			log.Warnf("Failed to close handle on %s: %v", info.localHandle.Path(), err)
+		}
+	}
+	if len(drained) > 0 {
+		log.Infof("Closed %d handle(s) under %s", len(drained), prefix)
+	}
+	return len(drained)
+}
+
+// joinMountPath returns the full path of a path inside a mount
+func joinMountPath(mountPath, relPath string) string {
+	return path.Join(mountPath, "/"+strings.TrimPrefix(relPath, "/"))
+}
+
+// isUnder reports whether p is prefix or inside it
+func isUnder(p, prefix string) bool {
+	return prefix == "/" || p == prefix || strings.HasPrefix(p, prefix+"/")
+}
@@ -0,0 +1,220 @@
+package mountablefs
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultHealthCheckTimeout bounds a single plugin health check; a check that
+// hangs (e.g. on a dead database connection) counts as failed
+const DefaultHealthCheckTimeout = 10 * time.Second
+
+// Health states of a mount
+const (
+	HealthStatusHealthy   = "healthy"
+	HealthStatusUnhealthy = "unhealthy"
+)
+
+// HealthStatus is the result of the latest health check of a mount
+type HealthStatus struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Failures  int       `json:"consecutive_failures"`
+	Checks    int64     `json:"checks"`
+	CheckedAt time.Time `json:"checked_at"`
+	Since     time.Time `json:"since"` // When the mount entered its current state
+}
+
+// Healthy reports whether the mount can serve requests
+func (h *HealthStatus) Healthy() bool {
+	return h == nil || h.Status == HealthStatusHealthy
+}
+
+// Health returns the latest health of the mount, or nil if its plugin has no
+// health check or it was not checked yet
+func (m *MountPoint) Health() *HealthStatus {
+	return m.health.Load()
+}
+
+// checkAvailable returns an ErrUnavailable error if the mount is unhealthy
+// or its plugin is not initialized yet
+func (m *MountPoint) checkAvailable() error {
+	if err := m.checkReady(); err != nil {
+		return err
+	}
+	if h := m.health.Load(); !h.Healthy() {
+		return filesystem.NewUnavailableError(m.Path, h.Error)
+	}
+	return nil
+}
+
+// healthChecker returns the health check of a plugin, looking through renames
+func healthChecker(p plugin.ServicePlugin) (plugin.HealthChecker, bool) {
+	if rp, ok := p.(*RenamedPlugin); ok {
+		p = rp.ServicePlugin
+	}
+	hc, ok := p.(plugin.HealthChecker)
+	return hc, ok
+}
+
+// MountHealth is the health of one mount, as reported by GetMountHealth
+type MountHealth struct {
+	Path   string        `json:"path"`
+	Plugin string        `json:"plugin"`
+	Health *HealthStatus `json:"health,omitempty"` // nil if the plugin has no health check
+	Init   *InitStatus   `json:"init,omitempty"`   // nil if the plugin was initialized before mounting
+
+	Replicas []*HealthStatus `json:"replicas,omitempty"` // Health of each read replica
+}
+
+// GetMountHealth returns the health of every mount
+func (mfs *MountableFS) GetMountHealth() interface{} {
+	mounts := mfs.GetMounts()
+	report := make([]MountHealth, 0, len(mounts))
+	for _, mount := range mounts {
+		report = append(report, mount.mountHealth())
+	}
+	return report
+}
+
+// mountHealth returns the health of the mount
+func (m *MountPoint) mountHealth() MountHealth {
+	mh := MountHealth{
+		Path:   m.Path,
+		Plugin: m.Plugin.Name(),
+		Health: m.Health(),
+		Init:   m.InitStatus(),
+	}
+	for _, r := range m.replicas {
+		mh.Replicas = append(mh.Replicas, r.Health())
+	}
+	return mh
+}
+
+// StartHealthChecks polls the health check of every mounted plugin that has
+// one at the given interval, until StopHealthChecks is called
+func (mfs *MountableFS) StartHealthChecks(interval time.Duration) {
+	mfs.healthMu.Lock()
+	defer mfs.healthMu.Unlock()
+
+	if mfs.healthStop != nil || interval <= 0 {
+		return
+	}
+	stop := make(chan struct{})
+	mfs.healthStop = stop
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				mfs.CheckHealth()
+			}
+		}
+	}()
+	log.Infof("Plugin health checks enabled (interval: %v)", interval)
+}
+
+// StopHealthChecks stops the polling started by StartHealthChecks
+func (mfs *MountableFS) StopHealthChecks() {
+	mfs.healthMu.Lock()
+	defer mfs.healthMu.Unlock()
+
+	if mfs.healthStop != nil {
+		close(mfs.healthStop)
+		mfs.healthStop = nil
+	}
+}
+
+// CheckHealth runs the health check of every mount (and read replica) that
+// has one, in parallel
+func (mfs *MountableFS) CheckHealth() {
+	var wg sync.WaitGroup
+	var mounts []*MountPoint
+	for _, mount := range mfs.GetMounts() {
+		mounts = append(append(mounts, mount), mount.replicas...)
+	}
+	for _, mount := range mounts {
+		hc, ok := healthChecker(mount.Plugin)
+		if !ok || !mount.Ready() {
+			continue
+		}
+		// A check that is still running from an earlier round is not started again
+		if !mount.checking.CompareAndSwap(false, true) {
+			continue
+		}
+		wg.Add(1)
+		go func(mount *MountPoint) {
+			defer wg.Done()
+			mount.recordHealth(runHealthCheck(hc, mfs.healthTimeout()))
+		}(mount)
+	}
+	wg.Wait()
+}
+
+// healthTimeout returns the timeout of a single health check
+func (mfs *MountableFS) healthTimeout() time.Duration {
+	if mfs.HealthCheckTimeout > 0 {
+		return mfs.HealthCheckTimeout
+	}
+	return DefaultHealthCheckTimeout
+}
+
+// runHealthCheck runs a health check, failing it if it does not return in time
+func runHealthCheck(hc plugin.HealthChecker, timeout time.Duration) error {
+	result := make(chan error, 1)
+	go func() {
+		result <- hc.HealthCheck()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-result:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("health check timed out after %v", timeout)
+	}
+}
+
+// recordHealth stores the result of a health check and logs state changes
+func (m *MountPoint) recordHealth(err error) {
+	defer m.checking.Store(false)
+
+	now := time.Now()
+	prev := m.health.Load()
+	next := &HealthStatus{Status: HealthStatusHealthy, CheckedAt: now, Since: now}
+	if prev != nil {
+		next.Checks = prev.Checks
+		next.Failures = prev.Failures
+	}
+	next.Checks++
+
+	if err != nil {
+		next.Status = HealthStatusUnhealthy
+		next.Error = err.Error()
+		next.Failures++
+	} else {
+		next.Failures = 0
+	}
+	if prev != nil && prev.Status == next.Status {
+		next.Since = prev.Since
+	}
+	m.health.Store(next)
+
+	switch {
+	case err != nil && prev.Healthy():
+		log.Warnf("Mount %s (%s) is unhealthy: %v", m.Path, m.Plugin.Name(), err)
+	case err == nil && !prev.Healthy():
+		log.Infof("Mount %s (%s) is healthy again after %v", m.Path, m.Plugin.Name(), now.Sub(prev.Since).Round(time.Second))
+	}
+}
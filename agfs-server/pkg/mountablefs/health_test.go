@@ -0,0 +1,118 @@
+package mountablefs
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/api"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/memfs"
+)
+
+// healthPlugin is a memfs whose health check result can be switched
+type healthPlugin struct {
+	*memfs.MemFSPlugin
+	err   atomic.Value // error to return, wrapped in a struct to allow nil
+	block chan struct{}
+}
+
+type healthResult struct{ err error }
+
+func (p *healthPlugin) HealthCheck() error {
+	if p.block != nil {
+		<-p.block
+	}
+	if r, ok := p.err.Load().(healthResult); ok {
+		return r.err
+	}
+	return nil
+}
+
+func (p *healthPlugin) setHealth(err error) {
+	p.err.Store(healthResult{err})
+}
+
+func newHealthPlugin(t *testing.T) *healthPlugin {
+	t.Helper()
+	p := &healthPlugin{MemFSPlugin: memfs.NewMemFSPlugin()}
+	if err := p.Initialize(map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestUnhealthyMountFailsFast(t *testing.T) {
+	mfs := NewMountableFS(api.PoolConfig{})
+	p := newHealthPlugin(t)
+	if err := mfs.Mount("/db", p); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.Mount("/plain", &MockPlugin{name: "mock"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.Create("/db/a.txt"); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	p.setHealth(errors.New("connection refused"))
+	mfs.CheckHealth()
+
+	mount, _, _ := mfs.findMount("/db")
+	h := mount.Health()
+	if h == nil || h.Healthy() || h.Failures != 1 || h.Error != "connection refused" {
+		t.Fatalf("unexpected health %+v", h)
+	}
+	if plain, _, _ := mfs.findMount("/plain"); plain.Health() != nil {
+		t.Errorf("expected no health for a plugin without a health check")
+	}
+
+	_, err := mfs.Read("/db/a.txt", 0, -1)
+	if !errors.Is(err, filesystem.ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable, got %v", err)
+	}
+	if _, err := mfs.Stat("/db/a.txt"); !errors.Is(err, filesystem.ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable from stat, got %v", err)
+	}
+	if _, err := mfs.Write("/db/b.txt", []byte("x"), -1, filesystem.WriteFlagCreate); !errors.Is(err, filesystem.ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable from write, got %v", err)
+	}
+
+	// Still unhealthy: failures accumulate, the state start does not move
+	since := h.Since
+	mfs.CheckHealth()
+	if h := mount.Health(); h.Failures != 2 || !h.Since.Equal(since) {
+		t.Errorf("unexpected health after second failure %+v", h)
+	}
+
+	p.setHealth(nil)
+	mfs.CheckHealth()
+	if h := mount.Health(); !h.Healthy() || h.Failures != 0 || h.Checks != 3 {
+		t.Errorf("unexpected health after recovery %+v", h)
+	}
+	if _, err := mfs.Stat("/db/a.txt"); err != nil {
+		t.Errorf("expected the mount to serve again, got %v", err)
+	}
+}
+
+func TestHealthCheckTimeout(t *testing.T) {
+	mfs := NewMountableFS(api.PoolConfig{})
+	mfs.HealthCheckTimeout = 20 * time.Millisecond
+
+	p := newHealthPlugin(t)
+	p.block = make(chan struct{})
+	defer close(p.block)
+	if err := mfs.Mount("/db", p); err != nil {
+		t.Fatal(err)
+	}
+
+	mfs.CheckHealth()
+	mount, _, _ := mfs.findMount("/db")
+	if h := mount.Health(); h == nil || h.Healthy() {
+		t.Fatalf("expected a hanging check to fail, got %+v", h)
+	}
+	if _, err := mfs.ReadDir("/db"); !errors.Is(err, filesystem.ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable, got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package mountablefs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultHandleLease is how long a handle stays open without being used
+	// or renewed, unless HandleLease says otherwise
+	DefaultHandleLease = 60 * time.Second
+
+	// MaxHandleLease bounds the leases clients can ask for when renewing
+	MaxHandleLease = time.Hour
+)
+
+// HandleRenewal is the outcome of renewing the lease of one handle
+type HandleRenewal struct {
+	HandleID  int64     `json:"handle_id"`
+	Lease     int       `json:"lease,omitempty"`      // Seconds
+	ExpiresAt time.Time `json:"expires_at,omitempty"` // Zero if leases do not expire
+	Error     string    `json:"error,omitempty"`      // Set if the handle could not be renewed
+}
+
+// handleLease returns the default lease of handles, 0 if they do not expire
+func (mfs *MountableFS) handleLease() time.Duration {
+	switch {
+	case mfs.HandleLease < 0:
+		return 0
+	case mfs.HandleLease == 0:
+		return DefaultHandleLease
+	}
+	return mfs.HandleLease
+}
+
+// extend sets the lease of a handle and starts it from now, returning its
+// expiry
+func (info *handleInfo) extend(lease time.Duration) time.Time {
+	if lease <= 0 {
+		return time.Time{}
+	}
+	expires := time.Now().Add(lease)
+	info.lease.Store(int64(lease))
+	info.expires.Store(expires.UnixNano())
+	return expires
+}
+
+// touch extends the lease of a handle by its current lease, as any use of
+// the handle does
+func (info *handleInfo) touch() {
+	if lease := time.Duration(info.lease.Load()); lease > 0 {
+		info.expires.Store(time.Now().Add(lease).UnixNano())
+	}
+}
+
+// HandleLeaseOf returns the lease of an open handle and when it expires; the
+// expiry is zero if handles do not expire
+func (mfs *MountableFS) HandleLeaseOf(id int64) (time.Duration, time.Time, error) {
+	mfs.handleInfosMu.RLock()
+	info, found := mfs.handleInfos[id]
+	mfs.handleInfosMu.RUnlock()
+	if !found {
+		return 0, time.Time{}, mfs.missingHandle(id)
+	}
+	lease := time.Duration(info.lease.Load())
+	if lease <= 0 {
+		return 0, time.Time{}, nil
+	}
+	return lease, time.Unix(0, info.expires.Load()), nil
+}
+
+// RenewHandle extends the lease of an open handle from now, by lease or, if
+// it is 0, by the default lease, returning when it expires
+func (mfs *MountableFS) RenewHandle(id int64, lease time.Duration) (time.Time, error) {
+	if lease < 0 || lease > MaxHandleLease {
+		return time.Time{}, filesystem.NewInvalidArgumentError("renew", fmt.Sprintf("%d", id),
+			fmt.Sprintf("lease must be between 0 and %v", MaxHandleLease))
+	}
+	mfs.handleInfosMu.RLock()
+	info, found := mfs.handleInfos[id]
+	mfs.handleInfosMu.RUnlock()
+	if !found {
+		return time.Time{}, mfs.missingHandle(id)
+	}
+	if mfs.handleLease() == 0 {
+		return time.Time{}, nil
+	}
+	if lease == 0 {
+		lease = mfs.handleLease()
+	}
+	return info.extend(lease), nil
+}
+
+// RenewHandles renews the leases of several handles at once, e.g. all the
+// handles of a FUSE client, and reports the outcome for each of them
+func (mfs *MountableFS) RenewHandles(ids []int64, lease time.Duration) []HandleRenewal {
+	renewals := make([]HandleRenewal, 0, len(ids))
+	for _, id := range ids {
+		renewal := HandleRenewal{HandleID: id}
+		expires, err := mfs.RenewHandle(id, lease)
+		if err != nil {
+			renewal.Error = err.Error()
+		} else if !expires.IsZero() {
+			renewal.ExpiresAt = expires
+			renewal.Lease = int(time.Until(expires).Round(time.Second).Seconds())
+		}
+		renewals = append(renewals, renewal)
+	}
+	return renewals
+}
+
+// CloseExpiredHandles closes the handles whose lease has expired, i.e. that
+// were neither used nor renewed in time, and returns how many it closed
+func (mfs *MountableFS) CloseExpiredHandles() int {
+	now := time.Now().UnixNano()
+	mfs.handleInfosMu.Lock()
+	var expired []*handleInfo
+	for id, info := range mfs.handleInfos {
+		if info.lease.Load() > 0 && info.expires.Load() < now {
+			expired = append(expired, info)
+			delete(mfs.handleInfos, id)
+		}
+	}
+	mfs.handleInfosMu.Unlock()
+
+	for _, info := range expired {
+		if err := info.localHandle.Close(); err != nil {
+			log.Warnf("Failed to close expired handle on %s: %v", info.localHandle.Path(), err)
+		}
+	}
+	if len(expired) > 0 {
+		log.Infof("Closed %d handle(s) whose lease expired", len(expired))
+	}
+	return len(expired)
+}
+
+// StartHandleReaper closes handles whose lease has expired at the given
+// interval, until StopHandleReaper is called
+func (mfs *MountableFS) StartHandleReaper(interval time.Duration) {
+	mfs.reaperMu.Lock()
+	defer mfs.reaperMu.Unlock()
+
+	if mfs.reaperStop != nil || interval <= 0 || mfs.handleLease() == 0 {
+		return
+	}
+	stop := make(chan struct{})
+	mfs.reaperStop = stop
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				mfs.CloseExpiredHandles()
+			}
+		}
+	}()
+	log.Infof("Handle leases enabled (lease: %v)", mfs.handleLease())
+}
+
+// StopHandleReaper stops the reaping started by StartHandleReaper
+func (mfs *MountableFS) StopHandleReaper() {
+	mfs.reaperMu.Lock()
+	defer mfs.reaperMu.Unlock()
+
+	if mfs.reaperStop != nil {
+		close(mfs.reaperStop)
+		mfs.reaperStop = nil
+	}
+}
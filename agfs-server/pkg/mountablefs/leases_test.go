@@ -0,0 +1,75 @@
+package mountablefs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+func TestHandleLeases(t *testing.T) {
+	mfs := newMoveTestFS(t)
+	mfs.HandleLease = time.Minute
+
+	var ids []int64
+	for _, p := range []string{"/a/one", "/a/two", "/a/three"} {
+		h, err := mfs.OpenHandle(p, filesystem.O_RDWR|filesystem.O_CREATE, 0644)
+		if err != nil {
+			t.Fatalf("OpenHandle %s failed: %v", p, err)
+		}
+		ids = append(ids, h.ID())
+	}
+	lease, expires, err := mfs.HandleLeaseOf(ids[0])
+	if err != nil || lease != time.Minute || time.Until(expires) <= 50*time.Second {
+		t.Errorf("HandleLeaseOf = %v, %v, %v", lease, expires, err)
+	}
+
+	renewals := mfs.RenewHandles([]int64{ids[0], 999, ids[1]}, 10*time.Minute)
+	if len(renewals) != 3 || renewals[0].Error != "" || renewals[1].Error == "" || renewals[2].Lease != 600 {
+		t.Errorf("unexpected renewals %+v", renewals)
+	}
+	if _, err := mfs.RenewHandle(ids[0], 2*MaxHandleLease); err == nil {
+		t.Error("expected a lease beyond MaxHandleLease to be rejected")
+	}
+
+	// Let every lease run out, then use the first handle and renew the
+	// second: only the third one is closed
+	mfs.handleInfosMu.RLock()
+	for _, info := range mfs.handleInfos {
+		info.expires.Store(time.Now().Add(-time.Second).UnixNano())
+	}
+	mfs.handleInfosMu.RUnlock()
+	if _, err := mfs.GetHandle(ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mfs.RenewHandle(ids[1], 0); err != nil {
+		t.Fatal(err)
+	}
+	if n := mfs.CloseExpiredHandles(); n != 1 {
+		t.Errorf("expected 1 expired handle, closed %d", n)
+	}
+	if _, err := mfs.GetHandle(ids[2]); err == nil {
+		t.Error("expected the expired handle to be closed")
+	}
+	for _, id := range ids[:2] {
+		if _, err := mfs.GetHandle(id); err != nil {
+			t.Errorf("handle %d: %v", id, err)
+		}
+	}
+}
+
+func TestHandleLeasesDisabled(t *testing.T) {
+	mfs := newMoveTestFS(t)
+	mfs.HandleLease = -1
+
+	h, err := mfs.OpenHandle("/a/file", filesystem.O_RDWR|filesystem.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expires, err := mfs.RenewHandle(h.ID(), 0); err != nil || !expires.IsZero() {
+		t.Errorf("RenewHandle = %v, %v; want no expiry", expires, err)
+	}
+	if n := mfs.CloseExpiredHandles(); n != 0 {
+		t.Errorf("expected handles not to expire, closed %d", n)
+	}
+}
@@ -0,0 +1,39 @@
+package mountablefs
+
+import (
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+// Limits bounds the size of requests before they are dispatched to a plugin,
+// so that one client cannot exhaust the memory of the server. Requests over a
+// limit fail with filesystem.ErrQuotaExceeded. Zero disables a limit.
+type Limits struct {
+	MaxReadSize   int64 // Bytes returned by one read
+	MaxWriteSize  int64 // Bytes written by one write
+	MaxPathLength int   // Bytes in a path
+}
+
+// CheckPath checks the length of a path
+func (l Limits) CheckPath(op, path string) error {
+	if l.MaxPathLength > 0 && len(path) > l.MaxPathLength {
+		return filesystem.NewQuotaExceededError(op, path, "max_path_length", int64(len(path)), int64(l.MaxPathLength))
+	}
+	return nil
+}
+
+// CheckWrite checks the size of a write
+func (l Limits) CheckWrite(op, path string, size int64) error {
+	if l.MaxWriteSize > 0 && size > l.MaxWriteSize {
+		return filesystem.NewQuotaExceededError(op, path, "max_write_size", size, l.MaxWriteSize)
+	}
+	return nil
+}
+
+// CheckRead checks the size of a read; size is negative for reading to the
+// end of the file, which is only checked once the data is read (see Read)
+func (l Limits) CheckRead(op, path string, size int64) error {
+	if l.MaxReadSize > 0 && size > l.MaxReadSize {
+		return filesystem.NewQuotaExceededError(op, path, "max_read_size", size, l.MaxReadSize)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package mountablefs
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/api"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/memfs"
+)
+
+func TestLimits(t *testing.T) {
+	mfs := NewMountableFS(api.PoolConfig{})
+	p := memfs.NewMemFSPlugin()
+	if err := p.Initialize(map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.Mount("/mem", p); err != nil {
+		t.Fatal(err)
+	}
+	mfs.Limits = Limits{MaxReadSize: 8, MaxWriteSize: 16, MaxPathLength: 32}
+
+	isQuotaExceeded := func(err error) bool { return errors.Is(err, filesystem.ErrQuotaExceeded) }
+
+	if _, err := mfs.Write("/mem/big", make([]byte, 17), -1, filesystem.WriteFlagCreate); !isQuotaExceeded(err) {
+		t.Errorf("expected quota exceeded for large write, got %v", err)
+	}
+	if _, err := mfs.Write("/mem/small", []byte("0123456789"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if _, err := mfs.Write("/mem/tiny", []byte("0123"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	// Ranged reads are checked before dispatch, reads to the end by their result
+	if _, err := mfs.Read("/mem/small", 0, 9); !isQuotaExceeded(err) {
+		t.Errorf("expected quota exceeded for large ranged read, got %v", err)
+	}
+	if _, err := mfs.Read("/mem/small", 0, -1); !isQuotaExceeded(err) {
+		t.Errorf("expected quota exceeded for reading a large file, got %v", err)
+	}
+	if data, err := mfs.Read("/mem/small", 4, -1); (err != nil && err != io.EOF) || string(data) != "456789" {
+		t.Errorf("expected tail of file, got %q, %v", data, err)
+	}
+	if data, err := mfs.Read("/mem/tiny", 0, -1); (err != nil && err != io.EOF) || string(data) != "0123" {
+		t.Errorf("expected small file, got %q, %v", data, err)
+	}
+
+	longPath := "/mem/" + strings.Repeat("a", 32)
+	if _, err := mfs.Stat(longPath); !isQuotaExceeded(err) {
+		t.Errorf("expected quota exceeded for long path, got %v", err)
+	}
+	if err := mfs.Rename("/mem/tiny", longPath); !isQuotaExceeded(err) {
+		t.Errorf("expected quota exceeded for renaming to a long path, got %v", err)
+	}
+
+	var quotaErr *filesystem.QuotaExceededError
+	_, err := mfs.Write("/mem/big", make([]byte, 20), -1, filesystem.WriteFlagCreate)
+	if !errors.As(err, &quotaErr) || quotaErr.Limit != "max_write_size" || quotaErr.Size != 20 || quotaErr.Max != 16 {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
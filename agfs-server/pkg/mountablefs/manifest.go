@@ -0,0 +1,210 @@
+package mountablefs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+)
+
+// Virtual files at the root describing the mounts, so that agents can
+// discover what the server offers (see Manifest)
+const (
+	ManifestFile = "/.mounts.json"
+	ReadmeFile   = "/README"
+)
+
+// MetaValueManifest is the meta type of the manifest files
+const MetaValueManifest = "manifest"
+
+// MountManifest is the content of /.mounts.json
+type MountManifest struct {
+	Mounts []MountManifestEntry `json:"mounts"`
+}
+
+// MountManifestEntry describes a mount
+type MountManifestEntry struct {
+	Path         string   `json:"path"`
+	Plugin       string   `json:"plugin"`
+	Description  string   `json:"description,omitempty"`  // First line of the plugin's README
+	Capabilities []string `json:"capabilities,omitempty"` // Only for initialized mounts
+	Status       string   `json:"status"`                 // ready, an init state or unhealthy
+}
+
+// Manifest describes the mounts, sorted by path. Mounts that are not
+// initialized yet are not initialized by it, and have no capabilities.
+func (mfs *MountableFS) Manifest() MountManifest {
+	mounts := mfs.GetMounts()
+	sort.Slice(mounts, func(i, j int) bool { return mounts[i].Path < mounts[j].Path })
+
+	manifest := MountManifest{Mounts: []MountManifestEntry{}}
+	for _, m := range mounts {
+		entry := MountManifestEntry{
+			Path:        m.Path,
+			Plugin:      pluginType(m.Plugin),
+			Description: readmeSummary(m.Plugin.GetReadme()),
+			Status:      InitStateReady,
+		}
+		if st := m.InitStatus(); st != nil && st.State != InitStateReady {
+			entry.Status = st.State
+		} else {
+			entry.Capabilities = capabilities(m.fileSystem())
+			if !m.Health().Healthy() {
+				entry.Status = HealthStatusUnhealthy
+			}
+		}
+		manifest.Mounts = append(manifest.Mounts, entry)
+	}
+	return manifest
+}
+
+// pluginType returns the name of the plugin type of a mount, looking through
+// renames of plugin instances
+func pluginType(p plugin.ServicePlugin) string {
+	if rp, ok := p.(*RenamedPlugin); ok {
+		return rp.OriginalName()
+	}
+	return p.Name()
+}
+
+// readmeSummary returns the one-line description of a plugin from its
+// README: the first non-empty line, without markdown heading marks
+func readmeSummary(readme string) string {
+	for _, line := range strings.Split(readme, "\n") {
+		if line = strings.TrimSpace(strings.TrimLeft(line, "# ")); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// capabilities lists the optional operations a file system supports and its
+// special semantics
+func capabilities(fs filesystem.FileSystem) []string {
+	var caps []string
+	if _, ok := fs.(filesystem.HandleFS); ok {
+		caps = append(caps, "handles")
+	}
+	if _, ok := fs.(filesystem.Streamer); ok {
+		caps = append(caps, "stream")
+	}
+	if _, ok := fs.(filesystem.Toucher); ok {
+		caps = append(caps, "touch")
+	}
+	if _, ok := fs.(filesystem.Syncer); ok {
+		caps = append(caps, "sync")
+	}
+	if _, ok := fs.(filesystem.RandomWriter); ok {
+		caps = append(caps, "random_write")
+	}
+	if _, ok := fs.(filesystem.Symlinker); ok {
+		caps = append(caps, "symlink")
+	}
+	if _, ok := fs.(CustomGrepper); ok {
+		caps = append(caps, "search")
+	}
+	if _, ok := fs.(filesystem.AsOfReader); ok {
+		caps = append(caps, "as_of")
+	}
+	if p, ok := fs.(filesystem.CapabilityProvider); ok {
+		c := p.GetCapabilities()
+		for _, flag := range []struct {
+			set  bool
+			name string
+		}{
+			{c.IsAppendOnly, "append_only"},
+			{c.IsReadDestructive, "read_destructive"},
+			{c.IsObjectStore, "object_store"},
+			{c.IsBroadcast, "broadcast"},
+			{c.IsReadOnly, "read_only"},
+		} {
+			if flag.set {
+				caps = append(caps, flag.name)
+			}
+		}
+	}
+	return caps
+}
+
+// manifestData returns the content of a manifest file
+func (mfs *MountableFS) manifestData(path string) ([]byte, error) {
+	manifest := mfs.Manifest()
+	if path == ManifestFile {
+		data, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	}
+
+	var b strings.Builder
+	b.WriteString("AGFS Server\n\n")
+	b.WriteString("Each mount below is a plugin; read <mount>/README for its usage.\n")
+	b.WriteString("The same list is in " + ManifestFile + " as JSON.\n\n")
+	b.WriteString("MOUNTS:\n")
+	for _, m := range manifest.Mounts {
+		fmt.Fprintf(&b, "  %s (%s)", m.Path, m.Plugin)
+		if m.Status != InitStateReady {
+			fmt.Fprintf(&b, " [%s]", m.Status)
+		}
+		b.WriteString("\n")
+		if m.Description != "" {
+			fmt.Fprintf(&b, "    %s\n", m.Description)
+		}
+		if len(m.Capabilities) > 0 {
+			fmt.Fprintf(&b, "    capabilities: %s\n", strings.Join(m.Capabilities, ", "))
+		}
+	}
+	return []byte(b.String()), nil
+}
+
+// isManifestFile checks if a path is a manifest file. They exist unless a
+// mount covers them, i.e. a plugin is mounted at the root.
+func (mfs *MountableFS) isManifestFile(path string) bool {
+	path = filesystem.NormalizePath(path)
+	if path != ManifestFile && path != ReadmeFile {
+		return false
+	}
+	_, _, found := mfs.findMount(path)
+	return !found
+}
+
+// readManifest reads a manifest file
+func (mfs *MountableFS) readManifest(path string, offset, size int64) ([]byte, error) {
+	data, err := mfs.manifestData(filesystem.NormalizePath(path))
+	if err != nil {
+		return nil, err
+	}
+	return plugin.ApplyRangeRead(data, offset, size)
+}
+
+// manifestInfo returns the file info of a manifest file
+func (mfs *MountableFS) manifestInfo(path string) (*filesystem.FileInfo, error) {
+	path = filesystem.NormalizePath(path)
+	data, err := mfs.manifestData(path)
+	if err != nil {
+		return nil, err
+	}
+	return &filesystem.FileInfo{
+		Name:    strings.TrimPrefix(path, "/"),
+		Size:    int64(len(data)),
+		Mode:    0444,
+		ModTime: time.Now(),
+		IsDir:   false,
+		Meta:    filesystem.MetaData{Name: "rootfs", Type: MetaValueManifest},
+	}, nil
+}
+
+// openManifest opens a manifest file for reading
+func (mfs *MountableFS) openManifest(path string) (io.ReadCloser, error) {
+	data, err := mfs.manifestData(filesystem.NormalizePath(path))
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(strings.NewReader(string(data))), nil
+}
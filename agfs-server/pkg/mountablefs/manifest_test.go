@@ -0,0 +1,91 @@
+package mountablefs
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/api"
+)
+
+func TestReadmeSummary(t *testing.T) {
+	tests := map[string]string{
+		"": "",
+		"MemFS Plugin - In-memory file system\n\nUSAGE": "MemFS Plugin - In-memory file system",
+		"\n  # HelloFS\n\nSays hello":                   "HelloFS",
+	}
+	for readme, want := range tests {
+		if got := readmeSummary(readme); got != want {
+			t.Errorf("readmeSummary(%q) = %q, want %q", readme, got, want)
+		}
+	}
+}
+
+func TestManifest(t *testing.T) {
+	mfs := NewMountableFS(api.PoolConfig{})
+	if err := mfs.Mount("/queue", NewMockServicePlugin("queuefs")); err != nil {
+		t.Fatalf("Mount failed: %v", err)
+	}
+	if err := mfs.Mount("/data/mem", NewMockServicePlugin("memfs")); err != nil {
+		t.Fatalf("Mount failed: %v", err)
+	}
+
+	data, err := mfs.Read(ManifestFile, 0, -1)
+	if err != nil && err != io.EOF {
+		t.Fatalf("Read %s failed: %v", ManifestFile, err)
+	}
+	var manifest MountManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("invalid manifest %q: %v", data, err)
+	}
+	var paths []string
+	for _, m := range manifest.Mounts {
+		paths = append(paths, m.Path)
+		if m.Description != "Mock Service Plugin" || m.Status != InitStateReady {
+			t.Errorf("unexpected entry %+v", m)
+		}
+	}
+	if want := []string{"/data/mem", "/queue"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("manifest paths = %v, want %v", paths, want)
+	}
+
+	readme, err := mfs.Read(ReadmeFile, 0, -1)
+	if err != nil && err != io.EOF {
+		t.Fatalf("Read %s failed: %v", ReadmeFile, err)
+	}
+	if !strings.Contains(string(readme), "/queue (queuefs)") {
+		t.Errorf("README does not list /queue:\n%s", readme)
+	}
+
+	// Listed and stat-able at the root, but read-only
+	infos, err := mfs.ReadDir("/")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	names := map[string]bool{}
+	for _, info := range infos {
+		names[info.Name] = true
+	}
+	if !names[".mounts.json"] || !names["README"] || !names["queue"] || !names["data"] {
+		t.Errorf("unexpected root entries: %v", names)
+	}
+	info, err := mfs.Stat(ManifestFile)
+	if err != nil || info.Size != int64(len(data)) || info.IsDir {
+		t.Errorf("Stat %s = %+v, %v", ManifestFile, info, err)
+	}
+	if _, err := mfs.Write(ReadmeFile, []byte("x"), 0, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrPermissionDenied) {
+		t.Errorf("expected writing %s to be denied, got %v", ReadmeFile, err)
+	}
+
+	// A plugin mounted at the root has its own files
+	if err := mfs.Mount("/", NewMockServicePlugin("rootfs")); err != nil {
+		t.Fatalf("Mount failed: %v", err)
+	}
+	if _, err := mfs.Stat(ReadmeFile); err == nil {
+		t.Error("expected the root mount to cover the manifest files")
+	}
+}
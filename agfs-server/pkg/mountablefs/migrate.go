@@ -0,0 +1,462 @@
+package mountablefs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	pluginconfig "github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+// MigrateTask is the name of the tasks started by StartMigration
+const MigrateTask = "migrate"
+
+// Arguments of a migration (see StartMigration)
+const (
+	MigrateArgSource      = "src"
+	MigrateArgDestination = "dst"
+	MigrateArgParallel    = "parallel"    // Files copied at once (default 4)
+	MigrateArgBandwidth   = "bandwidth"   // Bytes per second copied, e.g. "50MB" (default unlimited)
+	MigrateArgStatusFile  = "status_file" // Progress file (default <dst>/.agfs-migrate.json)
+)
+
+const (
+	defaultMigrateParallel = 4
+	maxMigrateParallel     = 64
+
+	// migrateStatusFileName is the name of the status file in the destination
+	migrateStatusFileName = ".agfs-migrate.json"
+
+	// migrateStatusInterval is how often the status file is written while a
+	// migration runs
+	migrateStatusInterval = time.Second
+)
+
+// MigrationStatus is the content of the status file of a migration. It lets
+// a migration started again with the same source and destination resume:
+// files listed as completed whose copy is still in place are not copied again.
+type MigrationStatus struct {
+	Source       string                  `json:"source"`
+	Destination  string                  `json:"destination"`
+	State        string                  `json:"state"` // A task status
+	FilesTotal   int64                   `json:"files_total"`
+	FilesDone    int64                   `json:"files_done"`
+	FilesSkipped int64                   `json:"files_skipped"` // Completed by an earlier run
+	BytesTotal   int64                   `json:"bytes_total"`
+	BytesDone    int64                   `json:"bytes_done"`
+	Failed       map[string]string       `json:"failed,omitempty"` // Relative path -> error
+	Completed    map[string]MigratedFile `json:"completed"`        // Relative path -> copy
+	UpdatedAt    time.Time               `json:"updated_at"`
+}
+
+// MigratedFile is a file copied by a migration
+type MigratedFile struct {
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// migrateOptions are the parsed arguments of a migration
+type migrateOptions struct {
+	src, dst   string
+	parallel   int
+	bandwidth  int64 // Bytes per second, 0 for unlimited
+	statusFile string
+}
+
+// parseMigrateArgs parses and checks the arguments of a migration
+func parseMigrateArgs(args map[string]string) (migrateOptions, error) {
+	opts := migrateOptions{
+		src:      filesystem.NormalizePath(args[MigrateArgSource]),
+		dst:      filesystem.NormalizePath(args[MigrateArgDestination]),
+		parallel: defaultMigrateParallel,
+	}
+	if args[MigrateArgSource] == "" || args[MigrateArgDestination] == "" {
+		return opts, fmt.Errorf("%s and %s are required", MigrateArgSource, MigrateArgDestination)
+	}
+	if isUnder(opts.dst, opts.src) || isUnder(opts.src, opts.dst) {
+		return opts, fmt.Errorf("source %s and destination %s overlap", opts.src, opts.dst)
+	}
+	if s, ok := args[MigrateArgParallel]; ok {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxMigrateParallel {
+			return opts, fmt.Errorf("invalid %s %q: must be between 1 and %d", MigrateArgParallel, s, maxMigrateParallel)
+		}
+		opts.parallel = n
+	}
+	if s, ok := args[MigrateArgBandwidth]; ok && s != "" {
+		n, err := pluginconfig.ParseSize(s)
+		if err != nil || n < 0 {
+			return opts, fmt.Errorf("invalid %s %q: must be bytes per second (e.g. 50MB)", MigrateArgBandwidth, s)
+		}
+		opts.bandwidth = n
+	}
+	opts.statusFile = path.Join(opts.dst, migrateStatusFileName)
+	if s := args[MigrateArgStatusFile]; s != "" {
+		opts.statusFile = filesystem.NormalizePath(s)
+	}
+	return opts, nil
+}
+
+// StartMigration starts copying the tree at args["src"] to args["dst"] in
+// the background, e.g. from a memfs mount to an s3fs mount, and returns the
+// task doing it. Files are copied in parallel, optionally within a
+// bandwidth, and each copy is verified by reading it back and comparing its
+// SHA-256 with the source's. Progress is in the task and in a status file,
+// from which a migration started again resumes.
+func (mfs *MountableFS) StartMigration(args map[string]string) (TaskInfo, error) {
+	opts, err := parseMigrateArgs(args)
+	if err != nil {
+		return TaskInfo{}, filesystem.NewInvalidArgumentError("migrate", "", err.Error())
+	}
+	mount, _, found := mfs.findMount(opts.src)
+	if !found {
+		return TaskInfo{}, filesystem.NewNotFoundError("migrate", opts.src)
+	}
+	info, err := mfs.Stat(opts.src)
+	if err != nil {
+		return TaskInfo{}, err
+	}
+	if !info.IsDir {
+		return TaskInfo{}, filesystem.NewInvalidArgumentError("migrate", opts.src, "source must be a directory")
+	}
+
+	taskArgs := make(map[string]string, len(args))
+	for k, v := range args {
+		taskArgs[k] = v
+	}
+	return mfs.startTask(mount.Path, MigrateTask, taskArgs, migrationRunner{mfs}), nil
+}
+
+// migrationRunner runs migrations as tasks
+type migrationRunner struct {
+	mfs *MountableFS
+}
+
+func (r migrationRunner) Tasks() []string {
+	return []string{MigrateTask}
+}
+
+func (r migrationRunner) RunTask(ctx context.Context, task string, args map[string]string, progress plugin.TaskProgress) error {
+	opts, err := parseMigrateArgs(args)
+	if err != nil {
+		return err
+	}
+	m := &migration{mfs: r.mfs, opts: opts, progress: progress}
+	if opts.bandwidth > 0 {
+		m.limiter = rate.NewLimiter(rate.Limit(opts.bandwidth), moveChunkSize)
+	}
+	return m.run(ctx)
+}
+
+// migration is a running migration
+type migration struct {
+	mfs      *MountableFS
+	opts     migrateOptions
+	progress plugin.TaskProgress
+	limiter  *rate.Limiter // nil if the bandwidth is unlimited
+
+	mu          sync.Mutex
+	status      MigrationStatus
+	lastWritten time.Time
+}
+
+// migrateFile is a file to copy, relative to the source and destination
+type migrateFile struct {
+	rel  string
+	size int64
+}
+
+func (m *migration) run(ctx context.Context) error {
+	m.status = m.loadStatus()
+	m.status.State = TaskStatusRunning
+	m.status.FilesDone, m.status.FilesSkipped, m.status.BytesDone = 0, 0, 0
+	m.status.Failed = nil
+
+	// List the files and create the directories first, so that workers only
+	// copy files
+	var files []migrateFile
+	if err := m.walk(ctx, "/", 0755, &files); err != nil {
+		m.finish(ctx, err)
+		return err
+	}
+	m.status.FilesTotal = int64(len(files))
+	m.status.BytesTotal = 0
+	for _, f := range files {
+		m.status.BytesTotal += f.size
+	}
+	log.Infof("Migrating %s to %s: %d files, %d bytes", m.opts.src, m.opts.dst, len(files), m.status.BytesTotal)
+
+	jobs := make(chan migrateFile)
+	var wg sync.WaitGroup
+	for i := 0; i < m.opts.parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range jobs {
+				m.migrate(ctx, f)
+			}
+		}()
+	}
+	for _, f := range files {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- f
+	}
+	close(jobs)
+	wg.Wait()
+
+	err := ctx.Err()
+	if err == nil && len(m.status.Failed) > 0 {
+		err = fmt.Errorf("%d of %d files failed (see %s)", len(m.status.Failed), len(files), m.opts.statusFile)
+	}
+	m.finish(ctx, err)
+	return err
+}
+
+// walk lists the files below a directory of the source, creating the
+// directories in the destination
+func (m *migration) walk(ctx context.Context, rel string, mode uint32, files *[]migrateFile) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	dst := path.Join(m.opts.dst, rel)
+	if info, err := m.mfs.Stat(dst); err != nil {
+		if err := m.mfs.Mkdir(dst, mode&0777); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dst, err)
+		}
+	} else if !info.IsDir {
+		return filesystem.NewAlreadyExistsError("file", dst)
+	}
+
+	entries, err := m.mfs.ReadDir(path.Join(m.opts.src, rel))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		child := path.Join(rel, entry.Name)
+		if entry.IsDir {
+			if err := m.walk(ctx, child, entry.Mode, files); err != nil {
+				return err
+			}
+		} else if path.Join(m.opts.dst, child) != m.opts.statusFile {
+			*files = append(*files, migrateFile{rel: child, size: entry.Size})
+		}
+	}
+	return nil
+}
+
+// migrate copies a file unless an earlier run did, recording the outcome
+func (m *migration) migrate(ctx context.Context, f migrateFile) {
+	dst := path.Join(m.opts.dst, f.rel)
+	m.mu.Lock()
+	done, ok := m.status.Completed[f.rel]
+	m.mu.Unlock()
+	if ok && done.Size == f.size {
+		if info, err := m.mfs.Stat(dst); err == nil && info.Size == f.size {
+			m.record(f, &done, true, nil)
+			return
+		}
+	}
+
+	sum, err := m.copyFile(ctx, path.Join(m.opts.src, f.rel), dst)
+	if err == nil {
+		err = m.verify(dst, sum)
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Warnf("Migration of %s to %s: %s: %v", m.opts.src, m.opts.dst, f.rel, err)
+		}
+		m.record(f, nil, false, err)
+		return
+	}
+	m.record(f, &MigratedFile{Size: f.size, SHA256: sum}, false, nil)
+}
+
+// copyFile copies a file within the bandwidth, returning the SHA-256 of the
+// data read from the source
+func (m *migration) copyFile(ctx context.Context, src, dst string) (string, error) {
+	w, err := m.mfs.OpenWrite(dst)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	for offset := int64(0); ; {
+		if err := ctx.Err(); err != nil {
+			w.Close()
+			return "", err
+		}
+		// Chunks are copied as batch operations, giving way to interactive
+		// ones on mounts with a scheduler
+		var data []byte
+		err := m.batch(ctx, src, func() (err error) {
+			data, err = m.mfs.Read(src, offset, moveChunkSize)
+			return err
+		})
+		if err != nil && err != io.EOF {
+			w.Close()
+			return "", err
+		}
+		if len(data) > 0 {
+			if m.limiter != nil {
+				if werr := m.limiter.WaitN(ctx, len(data)); werr != nil {
+					w.Close()
+					return "", werr
+				}
+			}
+			werr := m.batch(ctx, dst, func() error {
+				_, err := w.Write(data)
+				return err
+			})
+			if werr != nil {
+				w.Close()
+				return "", werr
+			}
+			h.Write(data)
+			offset += int64(len(data))
+			m.addBytes(int64(len(data)))
+		}
+		if err == io.EOF || len(data) < moveChunkSize {
+			break
+		}
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// batch runs op on path once the scheduler of its mount lets a batch
+// operation run
+func (m *migration) batch(ctx context.Context, path string, op func() error) error {
+	release, err := m.mfs.Schedule(ctx, path, PriorityBatch)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return op()
+}
+
+// verify reads a copy back and compares its SHA-256 with the source's
+func (m *migration) verify(dst, sum string) error {
+	h := sha256.New()
+	for offset := int64(0); ; {
+		data, err := m.mfs.Read(dst, offset, moveChunkSize)
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("failed to verify copy: %w", err)
+		}
+		h.Write(data)
+		offset += int64(len(data))
+		if err == io.EOF || len(data) < moveChunkSize {
+			break
+		}
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != sum {
+		return fmt.Errorf("checksum mismatch: copy has sha256 %s, source %s", got, sum)
+	}
+	return nil
+}
+
+// addBytes counts bytes copied and reports progress
+func (m *migration) addBytes(n int64) {
+	m.mu.Lock()
+	m.status.BytesDone += n
+	done, total := m.status.BytesDone, m.status.BytesTotal
+	message := fmt.Sprintf("%d/%d files", m.status.FilesDone, m.status.FilesTotal)
+	m.mu.Unlock()
+	m.progress(done, total, message)
+}
+
+// record records the outcome of a file, and writes the status file if it is
+// due
+func (m *migration) record(f migrateFile, done *MigratedFile, skipped bool, err error) {
+	m.mu.Lock()
+	m.status.FilesDone++
+	switch {
+	case err != nil:
+		if m.status.Failed == nil {
+			m.status.Failed = make(map[string]string)
+		}
+		m.status.Failed[f.rel] = err.Error()
+		delete(m.status.Completed, f.rel)
+	case skipped:
+		m.status.FilesSkipped++
+		m.status.BytesDone += f.size
+	default:
+		m.status.Completed[f.rel] = *done
+	}
+	bytesDone, bytesTotal := m.status.BytesDone, m.status.BytesTotal
+	message := fmt.Sprintf("%d/%d files", m.status.FilesDone, m.status.FilesTotal)
+	if time.Since(m.lastWritten) >= migrateStatusInterval {
+		m.writeStatus()
+	}
+	m.mu.Unlock()
+
+	m.progress(bytesDone, bytesTotal, message)
+}
+
+// finish records the final state in the status file
+func (m *migration) finish(ctx context.Context, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch {
+	case ctx.Err() != nil:
+		m.status.State = TaskStatusCancelled
+	case err != nil:
+		m.status.State = TaskStatusFailed
+	default:
+		m.status.State = TaskStatusSucceeded
+	}
+	m.writeStatus()
+	log.Infof("Migration of %s to %s %s: %d files (%d already copied), %d bytes",
+		m.opts.src, m.opts.dst, m.status.State, m.status.FilesDone, m.status.FilesSkipped, m.status.BytesDone)
+}
+
+// loadStatus reads the status file of an earlier run of the migration, to
+// resume it; a status file of another migration is ignored
+func (m *migration) loadStatus() MigrationStatus {
+	fresh := MigrationStatus{
+		Source:      m.opts.src,
+		Destination: m.opts.dst,
+		Completed:   make(map[string]MigratedFile),
+	}
+	data, err := m.mfs.Read(m.opts.statusFile, 0, -1)
+	if err != nil && err != io.EOF {
+		return fresh
+	}
+	var status MigrationStatus
+	if err := json.Unmarshal(data, &status); err != nil || status.Source != m.opts.src || status.Destination != m.opts.dst {
+		return fresh
+	}
+	if status.Completed == nil {
+		status.Completed = make(map[string]MigratedFile)
+	}
+	log.Infof("Resuming migration of %s to %s: %d files already copied", m.opts.src, m.opts.dst, len(status.Completed))
+	return status
+}
+
+// writeStatus writes the status file; m.mu is held, which keeps the writes
+// in order
+func (m *migration) writeStatus() {
+	m.status.UpdatedAt = time.Now()
+	m.lastWritten = m.status.UpdatedAt
+	data, err := json.MarshalIndent(m.status, "", "  ")
+	if err == nil {
+		_, err = m.mfs.Write(m.opts.statusFile, append(data, '\n'), 0, filesystem.WriteFlagCreate|filesystem.WriteFlagTruncate)
+	}
+	if err != nil {
+		log.Warnf("Migration of %s to %s: failed to write status file %s: %v", m.opts.src, m.opts.dst, m.opts.statusFile, err)
+	}
+}
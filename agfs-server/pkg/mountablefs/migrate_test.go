@@ -0,0 +1,104 @@
+package mountablefs
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+func TestMigrate(t *testing.T) {
+	mfs := newMoveTestFS(t)
+	if err := mfs.Mkdir("/a/data", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.Mkdir("/a/data/sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"/data/one.txt":     "one",
+		"/data/sub/two.txt": strings.Repeat("two", 1000),
+		"/data/empty":       "",
+	}
+	for p, content := range files {
+		if _, err := mfs.Write("/a"+p, []byte(content), 0, filesystem.WriteFlagCreate); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	start, err := mfs.StartMigration(map[string]string{"src": "/a/data", "dst": "/b/copy", "parallel": "2", "bandwidth": "10MB"})
+	if err != nil {
+		t.Fatalf("StartMigration failed: %v", err)
+	}
+	if start.Mount != "/a" || start.Task != MigrateTask {
+		t.Errorf("unexpected task %+v", start)
+	}
+	if info := waitForTask(t, mfs, start.ID); info.Status != TaskStatusSucceeded {
+		t.Fatalf("migration %s: %s", info.Status, info.Error)
+	}
+	for p, content := range files {
+		if got := readAll(t, mfs, "/b/copy"+strings.TrimPrefix(p, "/data")); got != content {
+			t.Errorf("%s copied as %q", p, got)
+		}
+	}
+	if got := readAll(t, mfs, "/a/data/one.txt"); got != "one" {
+		t.Errorf("source changed: %q", got)
+	}
+
+	status := readMigrationStatus(t, mfs, "/b/copy/.agfs-migrate.json")
+	if status.State != TaskStatusSucceeded || status.FilesDone != 3 || status.FilesSkipped != 0 || len(status.Completed) != 3 {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if status.Completed["/one.txt"].SHA256 != "7692c3ad3540bb803c020b3aee66cd8887123234ea0c6e7143c0add73ff431ed" {
+		t.Errorf("unexpected checksum %+v", status.Completed["/one.txt"])
+	}
+
+	// Started again, the migration resumes and only copies what changed
+	if _, err := mfs.Write("/a/data/three.txt", []byte("three"), 0, filesystem.WriteFlagCreate); err != nil {
+		t.Fatal(err)
+	}
+	again, err := mfs.StartMigration(map[string]string{"src": "/a/data", "dst": "/b/copy"})
+	if err != nil {
+		t.Fatalf("StartMigration failed: %v", err)
+	}
+	if info := waitForTask(t, mfs, again.ID); info.Status != TaskStatusSucceeded {
+		t.Fatalf("migration %s: %s", info.Status, info.Error)
+	}
+	status = readMigrationStatus(t, mfs, "/b/copy/.agfs-migrate.json")
+	if status.FilesDone != 4 || status.FilesSkipped != 3 || len(status.Completed) != 4 {
+		t.Errorf("expected 3 files to be skipped on resume, got %+v", status)
+	}
+	if got := readAll(t, mfs, "/b/copy/three.txt"); got != "three" {
+		t.Errorf("three.txt copied as %q", got)
+	}
+}
+
+func TestMigrateErrors(t *testing.T) {
+	mfs := newMoveTestFS(t)
+	if _, err := mfs.Write("/a/file", []byte("x"), 0, filesystem.WriteFlagCreate); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range []map[string]string{
+		{"src": "/a"},
+		{"src": "/a", "dst": "/a/copy"},
+		{"src": "/a/sub", "dst": "/a"},
+		{"src": "/a", "dst": "/b", "parallel": "0"},
+		{"src": "/a", "dst": "/b", "bandwidth": "fast"},
+		{"src": "/a/file", "dst": "/b/file"},
+		{"src": "/a/missing", "dst": "/b/missing"},
+	} {
+		if _, err := mfs.StartMigration(args); err == nil {
+			t.Errorf("expected migration %v to be rejected", args)
+		}
+	}
+}
+
+func readMigrationStatus(t *testing.T, mfs *MountableFS, path string) MigrationStatus {
+	t.Helper()
+	var status MigrationStatus
+	if err := json.Unmarshal([]byte(readAll(t, mfs, path)), &status); err != nil {
+		t.Fatalf("invalid status file: %v", err)
+	}
+	return status
+}
@@ -1,6 +1,7 @@
 package mountablefs
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"path/filepath"
@@ -10,8 +11,11 @@ import (
 	"time"
 
 	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/middleware"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/api"
+	pluginconfig "github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/ctl"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/loader"
 	iradix "github.com/hashicorp/go-immutable-radix"
 	log "github.com/sirupsen/logrus"
@@ -28,6 +32,29 @@ type MountPoint struct {
 	Path   string
 	Plugin plugin.ServicePlugin
 	Config map[string]interface{} // Plugin configuration
+
+	middlewares []middleware.Middleware // Wrap the plugin's file system (see fileSystem)
+
+	replicas    []*MountPoint // Read replicas (see pluginFor); nil if none
+	nextReplica atomic.Uint64 // Round-robin counter over replicas
+
+	health   atomic.Pointer[HealthStatus] // Latest health check, nil if never checked
+	checking atomic.Bool                  // A health check is running
+
+	init *mountInit // Deferred initialization of the plugin (see MountDeferred); nil if initialized before mounting
+
+	ttl     atomic.Pointer[TTLPolicy] // Expiry of the mount's files (see ttl.go); nil if they do not expire
+	trashed map[string]time.Time      // When soft-deleted files were moved to the trash; guarded by MountableFS.sweepMu
+
+	paths atomic.Pointer[PathOptions] // How request paths match files (see pathnames.go); nil to match them exactly
+
+	scheduler atomic.Pointer[Scheduler] // Bounds the operations running at once (see scheduler.go); nil if unbounded
+}
+
+// fileSystem returns the file system of the mount's plugin wrapped in its
+// middlewares
+func (m *MountPoint) fileSystem() filesystem.FileSystem {
+	return middleware.Wrap(m.Plugin.GetFileSystem(), m.middlewares)
 }
 
 // PluginFactory is a function that creates a new plugin instance
@@ -53,16 +80,64 @@ type MountableFS struct {
 	handleInfos   map[int64]*handleInfo
 	handleInfosMu sync.RWMutex
 
+	// Handles revoked by an administrator, with the reason, so that their
+	// clients are told (see RevokeHandles); also protected by handleInfosMu
+	revoked      map[int64]string
+	revokedOrder []int64 // Oldest first, to forget the oldest beyond maxRevokedHandles
+
 	// Symlink mapping table: linkPath -> targetPath
 	// This allows symlinks to work across all filesystems without backend support
 	symlinks   map[string]string // Key: link path, Value: target path
 	symlinksMu sync.RWMutex
+
+	// SlowOpThreshold makes operations that take longer be logged (see fsFor); 0 disables
+	SlowOpThreshold time.Duration
+
+	// Limits bounds the size of requests (see limits.go)
+	Limits Limits
+
+	// Conditional operations on paths of the same stripe are serialized
+	// (see conditional.go)
+	conditionalLocks [conditionalStripes]sync.Mutex
+
+	// HealthCheckTimeout bounds each plugin health check (DefaultHealthCheckTimeout if zero)
+	HealthCheckTimeout time.Duration
+	healthStop         chan struct{} // Closed to stop the health check loop
+	healthMu           sync.Mutex
+
+	// InitRetryBackoff is the wait after the first failed initialization of a
+	// deferred mount (DefaultInitRetryBackoff if zero; see MountDeferred)
+	InitRetryBackoff time.Duration
+
+	// HandleLease is how long handles stay open without being used or
+	// renewed (DefaultHandleLease if zero, never expire if negative; see
+	// leases.go)
+	HandleLease time.Duration
+	reaperStop  chan struct{} // Closed to stop closing expired handles
+	reaperMu    sync.Mutex
+
+	// TTL sweeper (see ttl.go)
+	ttlStop chan struct{} // Closed to stop the TTL sweeper
+	ttlMu   sync.Mutex
+	sweepMu sync.Mutex // Serializes sweeps
+
+	// Background tasks started through the admin API (see tasks.go)
+	tasks   map[int64]*task
+	tasksMu sync.Mutex
+	taskID  atomic.Int64
+
+	// Plugin directory watcher (see plugindir.go)
+	pluginDirStop chan struct{} // Closed to stop watching the plugin directory
+	pluginDirMu   sync.Mutex
 }
 
 // handleInfo stores information about a handle, including its mount point and local handle
 type handleInfo struct {
 	mount       *MountPoint           // The mount point where this handle was opened
 	localHandle filesystem.FileHandle // The underlying handle from the plugin
+	client      string                // Who opened the handle, e.g. a host (see OpenHandleFor)
+	lease       atomic.Int64          // Lease duration, 0 if the handle does not expire (see leases.go)
+	expires     atomic.Int64          // Unix nanoseconds when the lease expires
 }
 
 // NewMountableFS creates a new mountable file system with the specified WASM pool configuration
@@ -72,7 +147,9 @@ func NewMountableFS(poolConfig api.PoolConfig) *MountableFS {
 		pluginLoader:       loader.NewPluginLoader(poolConfig),
 		pluginNameCounters: make(map[string]int),
 		handleInfos:        make(map[int64]*handleInfo),
+		revoked:            make(map[int64]string),
 		symlinks:           make(map[string]string),
+		tasks:              make(map[int64]*task),
 	}
 	mfs.mountTree.Store(iradix.New())
 	// Start global handle IDs from 1
@@ -147,8 +224,9 @@ func (mfs *MountableFS) CreatePlugin(name string) plugin.ServicePlugin {
 	return factory()
 }
 
-// Mount mounts a service plugin at the specified path
-func (mfs *MountableFS) Mount(path string, plugin plugin.ServicePlugin) error {
+// Mount mounts a service plugin at the specified path, with middlewares
+// wrapping its file system
+func (mfs *MountableFS) Mount(path string, plugin plugin.ServicePlugin, middlewares ...middleware.Middleware) error {
 	mfs.mu.Lock()
 	defer mfs.mu.Unlock()
 
@@ -174,9 +252,10 @@ func (mfs *MountableFS) Mount(path string, plugin plugin.ServicePlugin) error {
 
 	// Create new tree with added mount
 	newTree, _, _ := tree.Insert([]byte(path), &MountPoint{
-		Path:   path,
-		Plugin: plugin,
-		Config: make(map[string]interface{}),
+		Path:        path,
+		Plugin:      plugin,
+		Config:      make(map[string]interface{}),
+		middlewares: middlewares,
 	})
 
 	// Atomically update tree
@@ -228,33 +307,117 @@ func (mfs *MountableFS) MountPlugin(fstype string, path string, config map[strin
 		log.Debugf("Set parentFS for plugin %s at %s", fstype, path)
 	}
 
+	// Resolve secret references (env:, file:, vault:); the mount keeps the
+	// unresolved config so that listing mounts does not reveal secrets
+	resolved, err := pluginconfig.ResolveSecrets(config)
+	if err != nil {
+		return fmt.Errorf("failed to resolve plugin config: %v", err)
+	}
+
+	// Take out the config of middlewares (e.g. compression)
+	middlewares, resolved, err := middleware.FromConfig(resolved)
+	if err != nil {
+		return fmt.Errorf("invalid middleware config: %v", err)
+	}
+
+	// Take out when to initialize the plugin (lazy, depends_on)
+	initOpts, resolved, err := TakeInitOptions(resolved)
+	if err != nil {
+		return err
+	}
+	if cycle := dependencyCycle(tree, path, initOpts.DependsOn); cycle != nil {
+		return fmt.Errorf("mount dependency cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	// Take out when the mount's files expire (ttl, ttl_dirs, ttl_trash)
+	ttlPolicy, resolved, err := TakeTTLPolicy(resolved)
+	if err != nil {
+		return err
+	}
+	// ... and how its paths are matched (case_insensitive, unicode_normalization)
+	pathOpts, resolved, err := TakePathOptions(resolved)
+	if err != nil {
+		return err
+	}
+	// ... and how many of its operations run at once (max_concurrency)
+	scheduler, resolved, err := TakeScheduler(resolved)
+	if err != nil {
+		return err
+	}
+	// Take out the read replicas last, so that they get none of the keys above
+	replicaConfigs, resolved, err := TakeReplicaConfigs(resolved)
+	if err != nil {
+		return err
+	}
+	if _, ok := readRouter(pluginInstance); len(replicaConfigs) > 0 && !ok {
+		return fmt.Errorf("plugin %s does not support read replicas", fstype)
+	}
+
 	// Inject mount_path into config
 	configWithPath := make(map[string]interface{})
-	for k, v := range config {
+	for k, v := range resolved {
 		configWithPath[k] = v
 	}
 	configWithPath["mount_path"] = path
 
+	for _, warning := range plugin.DeprecatedParamsIn(pluginInstance.GetConfigParams(), config) {
+		log.Warnf("Mount %s (%s): %s", path, fstype, warning)
+	}
+
 	// Validate plugin configuration
 	if err := pluginInstance.Validate(configWithPath); err != nil {
 		return fmt.Errorf("failed to validate plugin: %v", err)
 	}
 
+	if initOpts.deferred() {
+		initOpts.Replicas, err = NewReplicas(factory, path, replicaConfigs)
+		if err != nil {
+			return fmt.Errorf("invalid read replica config: %v", err)
+		}
+		mfs.insertDeferred(tree, path, pluginInstance, config, middlewares, func() error {
+			return pluginInstance.Initialize(configWithPath)
+		}, initOpts)
+		mfs.SetTTLPolicy(path, ttlPolicy)
+		mfs.SetPathOptions(path, pathOpts)
+		mfs.SetScheduler(path, scheduler)
+		log.Infof("mounted %s at %s (initialization deferred)", fstype, path)
+		return nil
+	}
+
 	// Initialize plugin with config
 	if err := pluginInstance.Initialize(configWithPath); err != nil {
 		return fmt.Errorf("failed to initialize plugin: %v", err)
 	}
 
+	for _, replicaCfg := range replicaConfigs {
+		replicaCfg["mount_path"] = path
+	}
+	replicas, err := startReplicas(factory, path, replicaConfigs)
+	if err != nil {
+		pluginInstance.Shutdown()
+		return fmt.Errorf("failed to start read replicas: %v", err)
+	}
+
 	// Create new tree with added mount
-	newTree, _, _ := tree.Insert([]byte(path), &MountPoint{
-		Path:   path,
-		Plugin: pluginInstance,
-		Config: config,
-	})
+	mount := &MountPoint{
+		Path:        path,
+		Plugin:      pluginInstance,
+		Config:      config,
+		middlewares: middlewares,
+		replicas:    replicas,
+	}
+	mount.ttl.Store(ttlPolicy)
+	mount.paths.Store(pathOpts)
+	mount.scheduler.Store(scheduler)
+	newTree, _, _ := tree.Insert([]byte(path), mount)
 
 	// Atomically update tree
 	mfs.mountTree.Store(newTree)
 
+	if len(replicas) > 0 {
+		log.Infof("mounted %s at %s with %d read replica(s)", fstype, path, len(replicas))
+		return nil
+	}
 	log.Infof("mounted %s at %s", fstype, path)
 	return nil
 }
@@ -275,9 +438,13 @@ func (mfs *MountableFS) Unmount(path string) error {
 	}
 	mount := val.(*MountPoint)
 
-	// Shutdown the plugin
-	if err := mount.Plugin.Shutdown(); err != nil {
-		return fmt.Errorf("failed to shutdown plugin: %v", err)
+	// Shutdown the plugin, unless it was never initialized
+	if mount.init == nil || mount.init.close() {
+		err := mount.Plugin.Shutdown()
+		shutdownReplicas(mount.replicas)
+		if err != nil {
+			return fmt.Errorf("failed to shutdown plugin: %v", err)
+		}
 	}
 
 	// Create new tree without the mount
@@ -407,11 +574,6 @@ func (mfs *MountableFS) GetBuiltinPluginNames() []string {
 	return names
 }
 
-// LoadExternalPluginsFromDirectory loads all plugins from a directory
-func (mfs *MountableFS) LoadExternalPluginsFromDirectory(dir string) ([]string, []error) {
-	return mfs.pluginLoader.LoadPluginsFromDirectory(dir)
-}
-
 // GetMounts returns all mount points
 func (mfs *MountableFS) GetMounts() []*MountPoint {
 	// Lock-free read
@@ -452,14 +614,14 @@ func (mfs *MountableFS) findMount(path string) (*MountPoint, string, bool) {
 	// Case A: mountPath is "/" -> path matches "/..." which is correct
 	if mountPath == "/" {
 		mount := v.(*MountPoint)
-		return mount, path, true
+		return mount, mount.resolvePath(path), true
 	}
 
 	// Case B: mountPath is "/mnt" -> path must be "/mnt/..."
 	if len(path) > len(mountPath) && path[len(mountPath)] == '/' {
 		mount := v.(*MountPoint)
 		relPath := path[len(mountPath):]
-		return mount, relPath, true
+		return mount, mount.resolvePath(relPath), true
 	}
 
 	// Partial match failed (e.g. "/mnt-foo" matched "/mnt")
@@ -469,6 +631,10 @@ func (mfs *MountableFS) findMount(path string) (*MountPoint, string, bool) {
 // Delegate all FileSystem methods to either base FS or mounted plugin
 
 func (mfs *MountableFS) Create(path string) error {
+	if err := mfs.Limits.CheckPath("create", path); err != nil {
+		return err
+	}
+
 	// Resolve symlinks in all path components
 	resolved, err := mfs.resolvePath(path)
 	if err != nil {
@@ -478,12 +644,21 @@ func (mfs *MountableFS) Create(path string) error {
 	mount, relPath, found := mfs.findMount(resolved)
 
 	if found {
-		return mount.Plugin.GetFileSystem().Create(relPath)
+		if err := mount.checkAvailable(); err != nil {
+			return err
+		}
+		fs, done := mfs.fsFor("create", path, mount)
+		defer done()
+		return fs.Create(relPath)
 	}
 	return filesystem.NewPermissionDeniedError("create", path, "not allowed to create file in rootfs, use mount instead")
 }
 
 func (mfs *MountableFS) Mkdir(path string, perm uint32) error {
+	if err := mfs.Limits.CheckPath("mkdir", path); err != nil {
+		return err
+	}
+
 	// Resolve symlinks in all path components
 	resolved, err := mfs.resolvePath(path)
 	if err != nil {
@@ -493,12 +668,21 @@ func (mfs *MountableFS) Mkdir(path string, perm uint32) error {
 	mount, relPath, found := mfs.findMount(resolved)
 
 	if found {
-		return mount.Plugin.GetFileSystem().Mkdir(relPath, perm)
+		if err := mount.checkAvailable(); err != nil {
+			return err
+		}
+		fs, done := mfs.fsFor("mkdir", path, mount)
+		defer done()
+		return fs.Mkdir(relPath, perm)
 	}
 	return filesystem.NewPermissionDeniedError("mkdir", path, "not allowed to create directory in rootfs, use mount instead")
 }
 
 func (mfs *MountableFS) Remove(path string) error {
+	if err := mfs.Limits.CheckPath("remove", path); err != nil {
+		return err
+	}
+
 	// Check if it's a symlink first - remove the symlink itself, not the target
 	path = filesystem.NormalizePath(path)
 	mfs.symlinksMu.Lock()
@@ -519,51 +703,120 @@ func (mfs *MountableFS) Remove(path string) error {
 	mount, relPath, found := mfs.findMount(resolved)
 
 	if found {
-		return mount.Plugin.GetFileSystem().Remove(relPath)
+		if err := mount.checkAvailable(); err != nil {
+			return err
+		}
+		fs, done := mfs.fsFor("remove", path, mount)
+		defer done()
+		return fs.Remove(relPath)
 	}
 	return filesystem.NewNotFoundError("remove", path)
 }
 
 func (mfs *MountableFS) RemoveAll(path string) error {
+	if err := mfs.Limits.CheckPath("removeall", path); err != nil {
+		return err
+	}
+
 	mount, relPath, found := mfs.findMount(path)
 
 	if found {
-		return mount.Plugin.GetFileSystem().RemoveAll(relPath)
+		if err := mount.checkAvailable(); err != nil {
+			return err
+		}
+		fs, done := mfs.fsFor("removeall", path, mount)
+		defer done()
+		return fs.RemoveAll(relPath)
 	}
 	return filesystem.NewNotFoundError("removeall", path)
 }
 
 func (mfs *MountableFS) Read(path string, offset int64, size int64) ([]byte, error) {
+	if err := mfs.Limits.CheckPath("read", path); err != nil {
+		return nil, err
+	}
+
+	if err := mfs.Limits.CheckRead("read", path, size); err != nil {
+		return nil, err
+	}
+
 	// Resolve symlinks in all path components
 	resolved, err := mfs.resolvePath(path)
 	if err != nil {
 		return nil, err
 	}
 
+	if mount, name, ok := mfs.ctlPath(resolved); ok {
+		return mfs.ctlDir(mount).Read(path, name, offset, size)
+	}
+
 	mount, relPath, found := mfs.findMount(resolved)
 
 	if found {
-		return mount.Plugin.GetFileSystem().Read(relPath, offset, size)
+		if err := mount.checkAvailable(); err != nil {
+			return nil, err
+		}
+		fs, done := mfs.fsFor("read", path, mount)
+		defer done()
+		if size >= 0 || mfs.Limits.MaxReadSize <= 0 {
+			return fs.Read(relPath, offset, size)
+		}
+
+		// Read to the end of the file: read one byte more than allowed,
+		// which tells files that are too large apart without reading them
+		// whole
+		data, err := fs.Read(relPath, offset, mfs.Limits.MaxReadSize+1)
+		if int64(len(data)) > mfs.Limits.MaxReadSize {
+			return nil, filesystem.NewQuotaExceededError("read", path, "max_read_size", int64(len(data)), mfs.Limits.MaxReadSize)
+		}
+		return data, err
+	}
+	if mfs.isManifestFile(resolved) {
+		return mfs.readManifest(resolved, offset, size)
 	}
 	return nil, filesystem.NewNotFoundError("read", path)
 }
 
 func (mfs *MountableFS) Write(path string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	if err := mfs.Limits.CheckPath("write", path); err != nil {
+		return 0, err
+	}
+
+	if err := mfs.Limits.CheckWrite("write", path, int64(len(data))); err != nil {
+		return 0, err
+	}
+
 	// Resolve symlinks in all path components
 	resolved, err := mfs.resolvePath(path)
 	if err != nil {
 		return 0, err
 	}
 
+	if mount, name, ok := mfs.ctlPath(resolved); ok {
+		return mfs.ctlDir(mount).Write(path, name, data, offset)
+	}
+
 	mount, relPath, found := mfs.findMount(resolved)
 
 	if found {
-		return mount.Plugin.GetFileSystem().Write(relPath, data, offset, flags)
+		if err := mount.checkAvailable(); err != nil {
+			return 0, err
+		}
+		fs, done := mfs.fsFor("write", path, mount)
+		defer done()
+		return fs.Write(relPath, data, offset, flags)
+	}
+	if mfs.isManifestFile(resolved) {
+		return 0, filesystem.NewPermissionDeniedError("write", path, "generated from the mounts")
 	}
 	return 0, filesystem.NewNotFoundError("write", path)
 }
 
 func (mfs *MountableFS) ReadDir(path string) ([]filesystem.FileInfo, error) {
+	if err := mfs.Limits.CheckPath("readdir", path); err != nil {
+		return nil, err
+	}
+
 	// Lock-free implementation
 	path = filesystem.NormalizePath(path)
 
@@ -573,11 +826,24 @@ func (mfs *MountableFS) ReadDir(path string) ([]filesystem.FileInfo, error) {
 		return nil, err
 	}
 
+	if mount, name, ok := mfs.ctlPath(resolved); ok {
+		if name != "" {
+			return nil, filesystem.NewNotDirectoryError(path)
+		}
+		return mfs.ctlDir(mount).ReadDir(), nil
+	}
+
 	// 1. Check if we are listing a directory inside a mount
 	mount, relPath, found := mfs.findMount(resolved)
 	if found {
+		if err := mount.checkAvailable(); err != nil {
+			return nil, err
+		}
+
 		// Get contents from the mounted filesystem
-		infos, err := mount.Plugin.GetFileSystem().ReadDir(relPath)
+		fs, done := mfs.fsFor("readdir", path, mount)
+		infos, err := fs.ReadDir(relPath)
+		done()
 		if err != nil {
 			return nil, err
 		}
@@ -748,6 +1014,15 @@ func (mfs *MountableFS) ReadDir(path string) ([]filesystem.FileInfo, error) {
 	}
 	mfs.symlinksMu.RUnlock()
 
+	// The root has the manifest files describing the mounts
+	if resolved == "/" {
+		for _, file := range []string{ManifestFile, ReadmeFile} {
+			if info, err := mfs.manifestInfo(file); err == nil {
+				infos = append(infos, *info)
+			}
+		}
+	}
+
 	if len(infos) > 0 {
 		return infos, nil
 	}
@@ -756,6 +1031,10 @@ func (mfs *MountableFS) ReadDir(path string) ([]filesystem.FileInfo, error) {
 }
 
 func (mfs *MountableFS) Stat(path string) (*filesystem.FileInfo, error) {
+	if err := mfs.Limits.CheckPath("stat", path); err != nil {
+		return nil, err
+	}
+
 	path = filesystem.NormalizePath(path)
 
 	// Check if path is root
@@ -816,10 +1095,19 @@ func (mfs *MountableFS) statWithoutSymlinkCheck(path string) (*filesystem.FileIn
 		return nil, err
 	}
 
+	if mount, name, ok := mfs.ctlPath(resolved); ok {
+		return mfs.ctlDir(mount).Stat(path, name)
+	}
+
 	// Check if path is a mount point or within a mount
 	mount, relPath, found := mfs.findMount(resolved)
 	if found {
-		stat, err := mount.Plugin.GetFileSystem().Stat(relPath)
+		if err := mount.checkAvailable(); err != nil {
+			return nil, err
+		}
+		fs, done := mfs.fsFor("stat", path, mount)
+		stat, err := fs.Stat(relPath)
+		done()
 		if err != nil {
 			return nil, err
 		}
@@ -839,6 +1127,10 @@ func (mfs *MountableFS) statWithoutSymlinkCheck(path string) (*filesystem.FileIn
 		return stat, nil
 	}
 
+	if mfs.isManifestFile(path) {
+		return mfs.manifestInfo(path)
+	}
+
 	// Check if path is a parent directory of any mount points
 	// e.g. /mnt when /mnt/foo exists
 	tree := mfs.mountTree.Load().(*iradix.Tree)
@@ -876,22 +1168,17 @@ func (mfs *MountableFS) statWithoutSymlinkCheck(path string) (*filesystem.FileIn
 	return nil, filesystem.NewNotFoundError("stat", path)
 }
 
+// Rename renames a path; across mounts, it copies and deletes (see Move)
 func (mfs *MountableFS) Rename(oldPath, newPath string) error {
-	// findMount is now lock-free
-	oldMount, oldRelPath, oldFound := mfs.findMount(oldPath)
-	newMount, newRelPath, newFound := mfs.findMount(newPath)
-
-	if oldFound && newFound {
-		if oldMount != newMount {
-			return fmt.Errorf("cannot rename across different mounts")
-		}
-		return oldMount.Plugin.GetFileSystem().Rename(oldRelPath, newRelPath)
-	}
-
-	return fmt.Errorf("cannot rename: paths not in same mounted filesystem")
+	_, err := mfs.Move(oldPath, newPath)
+	return err
 }
 
 func (mfs *MountableFS) Chmod(path string, mode uint32) error {
+	if err := mfs.Limits.CheckPath("chmod", path); err != nil {
+		return err
+	}
+
 	// Resolve symlinks in all path components
 	resolved, err := mfs.resolvePath(path)
 	if err != nil {
@@ -901,20 +1188,33 @@ func (mfs *MountableFS) Chmod(path string, mode uint32) error {
 	mount, relPath, found := mfs.findMount(resolved)
 
 	if found {
-		return mount.Plugin.GetFileSystem().Chmod(relPath, mode)
+		if err := mount.checkAvailable(); err != nil {
+			return err
+		}
+		fs, done := mfs.fsFor("chmod", path, mount)
+		defer done()
+		return fs.Chmod(relPath, mode)
 	}
 	return filesystem.NewNotFoundError("chmod", path)
 }
 
 // Truncate implements filesystem.Truncater interface
 func (mfs *MountableFS) Truncate(path string, size int64) error {
+	if err := mfs.Limits.CheckPath("truncate", path); err != nil {
+		return err
+	}
+
 	mount, relPath, found := mfs.findMount(path)
 
 	if !found {
 		return filesystem.NewNotFoundError("truncate", path)
 	}
+	if err := mount.checkAvailable(); err != nil {
+		return err
+	}
 
-	fs := mount.Plugin.GetFileSystem()
+	fs, done := mfs.fsFor("truncate", path, mount)
+	defer done()
 	if truncater, ok := fs.(filesystem.Truncater); ok {
 		return truncater.Truncate(relPath, size)
 	}
@@ -923,10 +1223,18 @@ func (mfs *MountableFS) Truncate(path string, size int64) error {
 
 // Touch implements filesystem.Toucher interface
 func (mfs *MountableFS) Touch(path string) error {
+	if err := mfs.Limits.CheckPath("touch", path); err != nil {
+		return err
+	}
+
 	mount, relPath, found := mfs.findMount(path)
 
 	if found {
-		fs := mount.Plugin.GetFileSystem()
+		if err := mount.checkAvailable(); err != nil {
+			return err
+		}
+		fs, done := mfs.fsFor("touch", path, mount)
+		defer done()
 		if toucher, ok := fs.(filesystem.Toucher); ok {
 			return toucher.Touch(relPath)
 		}
@@ -949,22 +1257,70 @@ func (mfs *MountableFS) Touch(path string) error {
 	return filesystem.NewNotFoundError("touch", path)
 }
 
+// Sync implements filesystem.Syncer; files of plugins that don't implement it
+// are durable once written, so only their existence is checked
+func (mfs *MountableFS) Sync(path string) error {
+	if err := mfs.Limits.CheckPath("sync", path); err != nil {
+		return err
+	}
+
+	mount, relPath, found := mfs.findMount(path)
+	if !found {
+		return filesystem.NewNotFoundError("sync", path)
+	}
+	if err := mount.checkAvailable(); err != nil {
+		return err
+	}
+
+	fs, done := mfs.fsFor("sync", path, mount)
+	defer done()
+	if syncer, ok := fs.(filesystem.Syncer); ok {
+		return syncer.Sync(relPath)
+	}
+	_, err := fs.Stat(relPath)
+	return err
+}
+
 func (mfs *MountableFS) Open(path string) (io.ReadCloser, error) {
+	if err := mfs.Limits.CheckPath("open", path); err != nil {
+		return nil, err
+	}
+
 	// Resolve symlinks in all path components
 	resolved, err := mfs.resolvePath(path)
 	if err != nil {
 		return nil, err
 	}
 
+	if mount, name, ok := mfs.ctlPath(resolved); ok {
+		data, err := mfs.ctlDir(mount).Read(path, name, 0, -1)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+
 	mount, relPath, found := mfs.findMount(resolved)
 
 	if found {
-		return mount.Plugin.GetFileSystem().Open(relPath)
+		if err := mount.checkAvailable(); err != nil {
+			return nil, err
+		}
+		fs, done := mfs.fsFor("open", path, mount)
+		defer done()
+		return fs.Open(relPath)
+	}
+	if mfs.isManifestFile(resolved) {
+		return mfs.openManifest(resolved)
 	}
 	return nil, filesystem.NewNotFoundError("open", path)
 }
 
 func (mfs *MountableFS) OpenWrite(path string) (io.WriteCloser, error) {
+	if err := mfs.Limits.CheckPath("openwrite", path); err != nil {
+		return nil, err
+	}
+
 	// Resolve symlinks in all path components
 	resolved, err := mfs.resolvePath(path)
 	if err != nil {
@@ -974,20 +1330,32 @@ func (mfs *MountableFS) OpenWrite(path string) (io.WriteCloser, error) {
 	mount, relPath, found := mfs.findMount(resolved)
 
 	if found {
-		return mount.Plugin.GetFileSystem().OpenWrite(relPath)
+		if err := mount.checkAvailable(); err != nil {
+			return nil, err
+		}
+		fs, done := mfs.fsFor("openwrite", path, mount)
+		defer done()
+		return fs.OpenWrite(relPath)
 	}
 	return nil, filesystem.NewNotFoundError("openwrite", path)
 }
 
 // OpenStream implements filesystem.Streamer interface
 func (mfs *MountableFS) OpenStream(path string) (filesystem.StreamReader, error) {
+	if err := mfs.Limits.CheckPath("openstream", path); err != nil {
+		return nil, err
+	}
+
 	mount, relPath, found := mfs.findMount(path)
 
 	if !found {
 		return nil, filesystem.NewNotFoundError("openstream", path)
 	}
+	if err := mount.checkAvailable(); err != nil {
+		return nil, err
+	}
 
-	fs := mount.Plugin.GetFileSystem()
+	fs := mount.fileSystem()
 	if streamer, ok := fs.(filesystem.Streamer); ok {
 		log.Debugf("[mountablefs] OpenStream: found streamer for path %s (relPath: %s, fs type: %T)", path, relPath, fs)
 		return streamer.OpenStream(relPath)
@@ -1005,12 +1373,15 @@ func (mfs *MountableFS) GetStream(path string) (interface{}, error) {
 	if !found {
 		return nil, filesystem.NewNotFoundError("getstream", path)
 	}
+	if err := mount.checkAvailable(); err != nil {
+		return nil, err
+	}
 
 	type streamGetter interface {
 		GetStream(path string) (interface{}, error)
 	}
 
-	fs := mount.Plugin.GetFileSystem()
+	fs := mount.fileSystem()
 	if sg, ok := fs.(streamGetter); ok {
 		log.Debugf("[mountablefs] GetStream: found stream getter for path %s (relPath: %s, fs type: %T)", path, relPath, fs)
 		return sg.GetStream(relPath)
@@ -1027,13 +1398,30 @@ func (mfs *MountableFS) GetStream(path string) (interface{}, error) {
 // OpenHandle opens a file and returns a handle for stateful operations
 // This delegates to the underlying filesystem if it supports HandleFS
 func (mfs *MountableFS) OpenHandle(path string, flags filesystem.OpenFlag, mode uint32) (filesystem.FileHandle, error) {
+	return mfs.OpenHandleFor("", path, flags, mode)
+}
+
+// OpenHandleFor is OpenHandle on behalf of client, which identifies who holds
+// the handle to administrators, e.g. to revoke the handles of one client
+func (mfs *MountableFS) OpenHandleFor(client, path string, flags filesystem.OpenFlag, mode uint32) (filesystem.FileHandle, error) {
+	if err := mfs.Limits.CheckPath("openhandle", path); err != nil {
+		return nil, err
+	}
+
 	mount, relPath, found := mfs.findMount(path)
 
 	if !found {
 		return nil, filesystem.NewNotFoundError("openhandle", path)
 	}
+	if _, ok := ctl.Split(relPath); ok {
+		// Control files are read and written whole
+		return nil, filesystem.NewNotSupportedError("openhandle", path)
+	}
+	if err := mount.checkAvailable(); err != nil {
+		return nil, err
+	}
 
-	fs := mount.Plugin.GetFileSystem()
+	fs := mount.fileSystem()
 	handleFS, ok := fs.(filesystem.HandleFS)
 	if !ok {
 		return nil, filesystem.NewNotSupportedError("openhandle", path)
@@ -1049,11 +1437,14 @@ func (mfs *MountableFS) OpenHandle(path string, flags filesystem.OpenFlag, mode
 	globalID := mfs.globalHandleID.Add(1)
 
 	// Store the mapping: globalID -> (mount, localHandle)
-	mfs.handleInfosMu.Lock()
-	mfs.handleInfos[globalID] = &handleInfo{
+	info := &handleInfo{
 		mount:       mount,
 		localHandle: localHandle,
+		client:      client,
 	}
+	info.extend(mfs.handleLease())
+	mfs.handleInfosMu.Lock()
+	mfs.handleInfos[globalID] = info
 	mfs.handleInfosMu.Unlock()
 
 	// Return a wrapper that uses the global ID
@@ -1062,6 +1453,7 @@ func (mfs *MountableFS) OpenHandle(path string, flags filesystem.OpenFlag, mode
 		localHandle: localHandle,
 		mountPath:   mount.Path,
 		fullPath:    path,
+		limits:      mfs.Limits,
 	}, nil
 }
 
@@ -1073,8 +1465,9 @@ func (mfs *MountableFS) GetHandle(id int64) (filesystem.FileHandle, error) {
 	mfs.handleInfosMu.RUnlock()
 
 	if !found {
-		return nil, filesystem.ErrNotFound
+		return nil, mfs.missingHandle(id)
 	}
+	info.touch()
 
 	// Return a wrapper with the global ID
 	return &globalFileHandle{
@@ -1082,6 +1475,7 @@ func (mfs *MountableFS) GetHandle(id int64) (filesystem.FileHandle, error) {
 		localHandle: info.localHandle,
 		mountPath:   info.mount.Path,
 		fullPath:    info.mount.Path + info.localHandle.Path(),
+		limits:      mfs.Limits,
 	}, nil
 }
 
@@ -1093,6 +1487,10 @@ func (mfs *MountableFS) CloseHandle(id int64) error {
 	mfs.handleInfosMu.RUnlock()
 
 	if !found {
+		// A revoked handle is already closed; its client is done with it now
+		if mfs.forgetRevoked(id) {
+			return nil
+		}
 		return filesystem.ErrNotFound
 	}
 
@@ -1115,6 +1513,7 @@ type globalFileHandle struct {
 	localHandle filesystem.FileHandle // Underlying handle from the plugin
 	mountPath   string                // Mount path for this handle
 	fullPath    string                // Full path including mount point
+	limits      Limits                // Limits on reads and writes
 }
 
 // ID returns the globally unique handle ID
@@ -1129,24 +1528,61 @@ func (h *globalFileHandle) Path() string {
 
 // Read delegates to the underlying handle
 func (h *globalFileHandle) Read(buf []byte) (int, error) {
+	if err := h.limits.CheckRead("read", h.fullPath, int64(len(buf))); err != nil {
+		return 0, err
+	}
 	return h.localHandle.Read(buf)
 }
 
 // ReadAt delegates to the underlying handle
 func (h *globalFileHandle) ReadAt(buf []byte, offset int64) (int, error) {
+	if err := h.limits.CheckRead("read", h.fullPath, int64(len(buf))); err != nil {
+		return 0, err
+	}
 	return h.localHandle.ReadAt(buf, offset)
 }
 
 // Write delegates to the underlying handle
 func (h *globalFileHandle) Write(data []byte) (int, error) {
+	if err := h.limits.CheckWrite("write", h.fullPath, int64(len(data))); err != nil {
+		return 0, err
+	}
 	return h.localHandle.Write(data)
 }
 
 // WriteAt delegates to the underlying handle
 func (h *globalFileHandle) WriteAt(data []byte, offset int64) (int, error) {
+	if err := h.limits.CheckWrite("write", h.fullPath, int64(len(data))); err != nil {
+		return 0, err
+	}
 	return h.localHandle.WriteAt(data, offset)
 }
 
+// ReadV delegates to the underlying handle, bounding the total size read
+func (h *globalFileHandle) ReadV(vecs []filesystem.IOVec) ([]int, error) {
+	if err := h.limits.CheckRead("readv", h.fullPath, vecsSize(vecs)); err != nil {
+		return nil, err
+	}
+	return filesystem.ReadV(h.localHandle, vecs)
+}
+
+// WriteV delegates to the underlying handle, bounding the total size written
+func (h *globalFileHandle) WriteV(vecs []filesystem.IOVec) ([]int, error) {
+	if err := h.limits.CheckWrite("writev", h.fullPath, vecsSize(vecs)); err != nil {
+		return nil, err
+	}
+	return filesystem.WriteV(h.localHandle, vecs)
+}
+
+// vecsSize returns the total size of the ranges of a vectored operation
+func vecsSize(vecs []filesystem.IOVec) int64 {
+	var size int64
+	for _, vec := range vecs {
+		size += int64(len(vec.Data))
+	}
+	return size
+}
+
 // Seek delegates to the underlying handle
 func (h *globalFileHandle) Seek(offset int64, whence int) (int64, error) {
 	return h.localHandle.Seek(offset, whence)
@@ -1254,6 +1690,11 @@ func (mfs *MountableFS) resolvePath(path string) (string, error) {
 // Symlink implements filesystem.Symlinker interface
 // Creates a virtual symlink at the mountablefs layer without requiring backend support
 func (mfs *MountableFS) Symlink(targetPath, linkPath string) error {
+	for _, path := range []string{targetPath, linkPath} {
+		if err := mfs.Limits.CheckPath("symlink", path); err != nil {
+			return err
+		}
+	}
 	linkPath = filesystem.NormalizePath(linkPath)
 
 	// Check if link path already exists (as a file/directory or symlink)
@@ -1336,15 +1777,20 @@ func (mfs *MountableFS) CustomGrep(path, query string, limit int) ([]CustomGrepR
 	if !found {
 		return nil, filesystem.NewNotFoundError("path", path)
 	}
+	if err := mount.checkAvailable(); err != nil {
+		return nil, err
+	}
 
 	// Check if the plugin's filesystem implements CustomGrepper
-	grepper, ok := mount.Plugin.GetFileSystem().(CustomGrepper)
+	fs, done := mfs.fsFor("grep", path, mount)
+	grepper, ok := fs.(CustomGrepper)
 	if !ok {
 		return nil, fmt.Errorf("path does not support custom grep: %s", path)
 	}
 
 	// Call the plugin's CustomGrep method with the relative path
 	results, err := grepper.CustomGrep(relPath, query, limit)
+	done()
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,168 @@
+package mountablefs
+
+import (
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// moveChunkSize is the size of the reads of a copy across mounts
+	moveChunkSize = 4 << 20
+
+	// moveProgressBytes is how often a copy across mounts logs its progress
+	moveProgressBytes = 64 << 20
+)
+
+// MoveResult describes how a rename was done
+type MoveResult struct {
+	Atomic bool  // Renamed by the plugin; false for a copy and delete across mounts
+	Files  int64 // Files copied
+	Bytes  int64 // Bytes copied
+}
+
+// Move renames oldPath to newPath like Rename, and reports how. Paths on
+// different mounts are renamed by copying oldPath (a file or a directory
+// tree) to newPath and removing it, which is not atomic: if the copy fails,
+// what was copied is removed and oldPath is left as it was; if removing
+// oldPath fails, both copies are left and the error says so.
+func (mfs *MountableFS) Move(oldPath, newPath string) (*MoveResult, error) {
+	for _, path := range []string{oldPath, newPath} {
+		if err := mfs.Limits.CheckPath("rename", path); err != nil {
+			return nil, err
+		}
+	}
+
+	// findMount is now lock-free
+	oldMount, oldRelPath, oldFound := mfs.findMount(oldPath)
+	newMount, newRelPath, newFound := mfs.findMount(newPath)
+	if !oldFound || !newFound {
+		return nil, fmt.Errorf("cannot rename: paths not in same mounted filesystem")
+	}
+	if err := oldMount.checkAvailable(); err != nil {
+		return nil, err
+	}
+
+	if oldMount == newMount {
+		fs, done := mfs.fsFor("rename", oldPath, oldMount)
+		defer done()
+		if err := fs.Rename(oldRelPath, newRelPath); err != nil {
+			return nil, err
+		}
+		return &MoveResult{Atomic: true}, nil
+	}
+
+	if err := newMount.checkAvailable(); err != nil {
+		return nil, err
+	}
+	if oldRelPath == "/" {
+		return nil, fmt.Errorf("cannot rename mount point %s", oldPath)
+	}
+	srcFS, srcDone := mfs.fsFor("rename", oldPath, oldMount)
+	defer srcDone()
+	dstFS, dstDone := mfs.fsFor("rename", newPath, newMount)
+	defer dstDone()
+	return moveAcross(srcFS, oldRelPath, dstFS, newRelPath, oldPath, newPath)
+}
+
+// moveAcross copies a file or directory tree to another file system and
+// removes it; oldPath and newPath are the full paths, for messages
+func moveAcross(srcFS filesystem.FileSystem, src string, dstFS filesystem.FileSystem, dst string, oldPath, newPath string) (*MoveResult, error) {
+	info, err := srcFS.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+	dstInfo, err := dstFS.Stat(dst)
+	existed := err == nil
+	if existed && (dstInfo.IsDir || info.IsDir) {
+		return nil, filesystem.NewAlreadyExistsError("file", newPath)
+	}
+
+	log.Infof("Renaming %s to %s across mounts by copy and delete", oldPath, newPath)
+	result := &MoveResult{}
+	m := &mover{src: srcFS, dst: dstFS, result: result, oldPath: oldPath}
+	if err := m.copy(src, dst, info); err != nil {
+		// A file that existed was overwritten in place and cannot be restored
+		if !existed {
+			if cleanupErr := dstFS.RemoveAll(dst); cleanupErr != nil {
+				log.Warnf("Failed to clean up partial copy %s: %v", newPath, cleanupErr)
+			}
+		}
+		return nil, fmt.Errorf("failed to copy %s to %s: %w", oldPath, newPath, err)
+	}
+
+	if info.IsDir {
+		err = srcFS.RemoveAll(src)
+	} else {
+		err = srcFS.Remove(src)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("copied %s to %s but failed to remove the source: %w", oldPath, newPath, err)
+	}
+	log.Infof("Renamed %s to %s across mounts (%d files, %d bytes)", oldPath, newPath, result.Files, result.Bytes)
+	return result, nil
+}
+
+// mover copies trees between file systems, counting what it copied
+type mover struct {
+	src, dst filesystem.FileSystem
+	result   *MoveResult
+	oldPath  string // For progress messages
+	logged   int64  // Bytes copied at the last progress message
+}
+
+func (m *mover) copy(src, dst string, info *filesystem.FileInfo) error {
+	if !info.IsDir {
+		return m.copyFile(src, dst)
+	}
+	if err := m.dst.Mkdir(dst, info.Mode&0777); err != nil {
+		return err
+	}
+	entries, err := m.src.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		if err := m.copy(path.Join(src, entries[i].Name), path.Join(dst, entries[i].Name), &entries[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *mover) copyFile(src, dst string) error {
+	w, err := m.dst.OpenWrite(dst)
+	if err != nil {
+		return err
+	}
+	for offset := int64(0); ; {
+		data, err := m.src.Read(src, offset, moveChunkSize)
+		if err != nil && err != io.EOF {
+			w.Close()
+			return err
+		}
+		if len(data) > 0 {
+			if _, werr := w.Write(data); werr != nil {
+				w.Close()
+				return werr
+			}
+			offset += int64(len(data))
+			m.result.Bytes += int64(len(data))
+		}
+		if err == io.EOF || len(data) < moveChunkSize {
+			break
+		}
+		if m.result.Bytes-m.logged >= moveProgressBytes {
+			m.logged = m.result.Bytes
+			log.Infof("Renaming %s across mounts: %d files, %d bytes copied", m.oldPath, m.result.Files, m.result.Bytes)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	m.result.Files++
+	return nil
+}
@@ -0,0 +1,125 @@
+package mountablefs
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/api"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/memfs"
+)
+
+// failingPlugin is a memfs whose writes fail for paths containing "bad"
+type failingPlugin struct {
+	plugin.ServicePlugin
+}
+
+func (p *failingPlugin) GetFileSystem() filesystem.FileSystem {
+	return &failingFS{FileSystem: p.ServicePlugin.GetFileSystem()}
+}
+
+type failingFS struct {
+	filesystem.FileSystem
+}
+
+func (f *failingFS) OpenWrite(path string) (io.WriteCloser, error) {
+	if strings.Contains(path, "bad") {
+		return nil, errors.New("disk full")
+	}
+	return f.FileSystem.OpenWrite(path)
+}
+
+func newMoveTestFS(t *testing.T) *MountableFS {
+	mfs := NewMountableFS(api.PoolConfig{})
+	for _, path := range []string{"/a", "/b"} {
+		p := memfs.NewMemFSPlugin()
+		if err := p.Initialize(map[string]interface{}{}); err != nil {
+			t.Fatal(err)
+		}
+		var sp plugin.ServicePlugin = p
+		if path == "/b" {
+			sp = &failingPlugin{ServicePlugin: p}
+		}
+		if err := mfs.Mount(path, sp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return mfs
+}
+
+func readAll(t *testing.T, fs filesystem.FileSystem, path string) string {
+	data, err := fs.Read(path, 0, -1)
+	if err != nil && err != io.EOF {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestMoveAcrossMounts(t *testing.T) {
+	mfs := newMoveTestFS(t)
+
+	if _, err := mfs.Write("/a/tmp.json", []byte(`{"ok":true}`), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatal(err)
+	}
+	result, err := mfs.Move("/a/tmp.json", "/b/out.json")
+	if err != nil {
+		t.Fatalf("move failed: %v", err)
+	}
+	if result.Atomic || result.Files != 1 || result.Bytes != 11 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if got := readAll(t, mfs, "/b/out.json"); got != `{"ok":true}` {
+		t.Errorf("unexpected content %q", got)
+	}
+	if _, err := mfs.Stat("/a/tmp.json"); err == nil {
+		t.Error("expected source removed")
+	}
+
+	// Directory trees
+	mfs.Mkdir("/a/dir", 0755)
+	mfs.Mkdir("/a/dir/sub", 0755)
+	mfs.Write("/a/dir/one", []byte("1"), -1, filesystem.WriteFlagCreate)
+	mfs.Write("/a/dir/sub/two", []byte("22"), -1, filesystem.WriteFlagCreate)
+	if err := mfs.Rename("/a/dir", "/b/dir"); err != nil {
+		t.Fatalf("rename failed: %v", err)
+	}
+	if readAll(t, mfs, "/b/dir/one") != "1" || readAll(t, mfs, "/b/dir/sub/two") != "22" {
+		t.Error("expected directory tree copied")
+	}
+	if _, err := mfs.Stat("/a/dir"); err == nil {
+		t.Error("expected source tree removed")
+	}
+
+	// Directories are not merged
+	mfs.Mkdir("/a/dir", 0755)
+	if _, err := mfs.Move("/a/dir", "/b/dir"); !errors.Is(err, filesystem.ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists, got %v", err)
+	}
+
+	// Renames in one mount stay atomic
+	result, err = mfs.Move("/b/out.json", "/b/renamed.json")
+	if err != nil || !result.Atomic {
+		t.Errorf("expected atomic rename, got %+v, %v", result, err)
+	}
+}
+
+func TestMoveAcrossMountsCleanup(t *testing.T) {
+	mfs := newMoveTestFS(t)
+
+	mfs.Mkdir("/a/dir", 0755)
+	mfs.Write("/a/dir/good", []byte("good"), -1, filesystem.WriteFlagCreate)
+	mfs.Write("/a/dir/bad", []byte("bad"), -1, filesystem.WriteFlagCreate)
+
+	if _, err := mfs.Move("/a/dir", "/b/dir"); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Fatalf("expected copy to fail, got %v", err)
+	}
+	if _, err := mfs.Stat("/b/dir"); err == nil {
+		t.Error("expected partial copy removed")
+	}
+	if readAll(t, mfs, "/a/dir/good") != "good" || readAll(t, mfs, "/a/dir/bad") != "bad" {
+		t.Error("expected source left as it was")
+	}
+}
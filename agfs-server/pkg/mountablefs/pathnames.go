@@ -0,0 +1,152 @@
+package mountablefs
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Mount config keys of how paths of a mount are matched (see TakePathOptions)
+const (
+	CaseInsensitiveKey = "case_insensitive"
+	NormalizationKey   = "unicode_normalization"
+)
+
+// Unicode normalization forms of PathOptions.Normalization
+const (
+	NormalizationNFC = "nfc"
+	NormalizationNFD = "nfd"
+)
+
+// PathOptions changes how the paths of requests are matched to the files of
+// a mount. macOS clients, for instance, expect "Readme.md" to find
+// "README.md" and send names in NFD ("e" followed by a combining accent)
+// where Linux clients send NFC ("é"), which object stores such as s3fs keep
+// as different keys.
+type PathOptions struct {
+	// CaseInsensitive makes paths match files whose names differ only in
+	// case; a file whose name matches exactly is preferred
+	CaseInsensitive bool
+	// Normalization is the Unicode normalization form ("nfc" or "nfd") paths
+	// are converted to before they reach the plugin, so that new files are
+	// named in it; existing files named in the other form are matched too.
+	// Empty leaves paths alone.
+	Normalization string
+}
+
+// TakePathOptions takes the path options (case_insensitive,
+// unicode_normalization) out of a mount config, returning them (nil if the
+// mount has none) and the config without their keys
+func TakePathOptions(cfg map[string]interface{}) (*PathOptions, map[string]interface{}, error) {
+	ci, hasCI := cfg[CaseInsensitiveKey]
+	nf, hasNF := cfg[NormalizationKey]
+	if !hasCI && !hasNF {
+		return nil, cfg, nil
+	}
+
+	opts := &PathOptions{}
+	if hasCI {
+		b, ok := ci.(bool)
+		if !ok {
+			return nil, nil, fmt.Errorf("%s must be a boolean", CaseInsensitiveKey)
+		}
+		opts.CaseInsensitive = b
+	}
+	if hasNF {
+		s, ok := nf.(string)
+		s = strings.ToLower(strings.TrimSpace(s))
+		if !ok || (s != "" && s != NormalizationNFC && s != NormalizationNFD) {
+			return nil, nil, fmt.Errorf("%s must be %q or %q", NormalizationKey, NormalizationNFC, NormalizationNFD)
+		}
+		opts.Normalization = s
+	}
+
+	rest := make(map[string]interface{}, len(cfg))
+	for k, v := range cfg {
+		if k != CaseInsensitiveKey && k != NormalizationKey {
+			rest[k] = v
+		}
+	}
+	if !opts.CaseInsensitive && opts.Normalization == "" {
+		return nil, rest, nil
+	}
+	return opts, rest, nil
+}
+
+// SetPathOptions sets the path options of the mount at a path; nil removes
+// them
+func (mfs *MountableFS) SetPathOptions(mountPath string, opts *PathOptions) error {
+	mountPath = filesystem.NormalizePath(mountPath)
+	mount, _, found := mfs.findMount(mountPath)
+	if !found || mount.Path != mountPath {
+		return filesystem.NewNotFoundError("mount", mountPath)
+	}
+	mount.paths.Store(opts)
+	return nil
+}
+
+// normalize converts a name or path to the normalization form of the options
+func (o *PathOptions) normalize(s string) string {
+	switch o.Normalization {
+	case NormalizationNFC:
+		return norm.NFC.String(s)
+	case NormalizationNFD:
+		return norm.NFD.String(s)
+	}
+	return s
+}
+
+// same reports whether a name on the backend matches a name of a request
+// (already normalized)
+func (o *PathOptions) same(name, want string) bool {
+	name = o.normalize(name)
+	if o.CaseInsensitive {
+		return strings.EqualFold(name, want)
+	}
+	return name == want
+}
+
+// resolvePath returns the path of the mount's plugin that relPath, a path of
+// a request, refers to. Paths are normalized; if no file has the normalized
+// path, it is looked up one directory at a time, matching names that differ
+// in case or normalization. The part of the path that does not exist (e.g.
+// a file being created) is kept as requested.
+func (m *MountPoint) resolvePath(relPath string) string {
+	opts := m.paths.Load()
+	if opts == nil || relPath == "/" || !m.Ready() {
+		return relPath
+	}
+	p := opts.normalize(relPath)
+
+	fs := m.fileSystem()
+	if _, err := fs.Stat(p); err == nil {
+		return p
+	}
+
+	resolved := "/"
+	names := strings.Split(strings.Trim(p, "/"), "/")
+	for i, name := range names {
+		entries, err := fs.ReadDir(resolved)
+		if err != nil {
+			return path.Join(append([]string{resolved}, names[i:]...)...)
+		}
+		match := ""
+		for _, entry := range entries {
+			if entry.Name == name {
+				match = name
+				break
+			}
+			if match == "" && opts.same(entry.Name, name) {
+				match = entry.Name
+			}
+		}
+		if match == "" {
+			return path.Join(append([]string{resolved}, names[i:]...)...)
+		}
+		resolved = path.Join(resolved, match)
+	}
+	return resolved
+}
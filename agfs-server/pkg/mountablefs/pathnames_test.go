@@ -0,0 +1,90 @@
+package mountablefs
+
+import (
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/api"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/memfs"
+)
+
+func TestTakePathOptions(t *testing.T) {
+	opts, rest, err := TakePathOptions(map[string]interface{}{
+		"case_insensitive":      true,
+		"unicode_normalization": "NFC",
+		"other":                 "x",
+	})
+	if err != nil {
+		t.Fatalf("TakePathOptions failed: %v", err)
+	}
+	if !opts.CaseInsensitive || opts.Normalization != NormalizationNFC || len(rest) != 1 {
+		t.Errorf("unexpected options %+v, rest %v", opts, rest)
+	}
+	if opts, rest, err := TakePathOptions(map[string]interface{}{"case_insensitive": false}); opts != nil || len(rest) != 0 || err != nil {
+		t.Errorf("expected no options, got %+v, %v, %v", opts, rest, err)
+	}
+	for _, cfg := range []map[string]interface{}{
+		{"case_insensitive": "yes"},
+		{"unicode_normalization": "nfkc"},
+	} {
+		if _, _, err := TakePathOptions(cfg); err == nil {
+			t.Errorf("expected %v to be rejected", cfg)
+		}
+	}
+}
+
+func TestPathOptions(t *testing.T) {
+	mfs := NewMountableFS(api.PoolConfig{})
+	mfs.RegisterPluginFactory("memfs", func() plugin.ServicePlugin { return memfs.NewMemFSPlugin() })
+	if err := mfs.MountPlugin("memfs", "/mac", map[string]interface{}{
+		"case_insensitive":      true,
+		"unicode_normalization": "nfc",
+	}); err != nil {
+		t.Fatalf("MountPlugin failed: %v", err)
+	}
+	if err := mfs.MountPlugin("memfs", "/linux", map[string]interface{}{}); err != nil {
+		t.Fatalf("MountPlugin failed: %v", err)
+	}
+	for _, dir := range []string{"/mac/Docs", "/linux/Docs"} {
+		if err := mfs.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, p := range []string{"/mac/Docs/README.md", "/linux/Docs/README.md"} {
+		if _, err := mfs.Write(p, []byte("readme"), 0, filesystem.WriteFlagCreate); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := readAll(t, mfs, "/mac/docs/readme.MD"); got != "readme" {
+		t.Errorf("case-insensitive read = %q", got)
+	}
+	if _, err := mfs.Stat("/linux/docs/readme.MD"); err == nil {
+		t.Error("expected the other mount to stay case-sensitive")
+	}
+
+	// New files go to the existing directory, named as requested
+	if _, err := mfs.Write("/mac/DOCS/Notes.txt", []byte("notes"), 0, filesystem.WriteFlagCreate); err != nil {
+		t.Fatal(err)
+	}
+	// NFD names from macOS clients are stored in NFC, and found in either form
+	nfd, nfc := "/mac/Docs/cafe\u0301.txt", "/mac/Docs/caf\u00e9.txt"
+	if _, err := mfs.Write(nfd, []byte("menu"), 0, filesystem.WriteFlagCreate); err != nil {
+		t.Fatal(err)
+	}
+	if got := readAll(t, mfs, nfc); got != "menu" {
+		t.Errorf("NFC read = %q", got)
+	}
+	entries, err := mfs.ReadDir("/mac/Docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]bool{}
+	for _, e := range entries {
+		names[e.Name] = true
+	}
+	if len(entries) != 3 || !names["README.md"] || !names["Notes.txt"] || !names["caf\u00e9.txt"] {
+		t.Errorf("unexpected entries %v", names)
+	}
+}
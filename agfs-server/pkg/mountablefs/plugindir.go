@@ -0,0 +1,116 @@
+package mountablefs
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/api"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/loader"
+	log "github.com/sirupsen/logrus"
+)
+
+// SetWASMSandbox sets the restrictions of the host functions of WASM plugins
+// loaded from now on (see api.SandboxConfig)
+func (mfs *MountableFS) SetWASMSandbox(sandbox api.SandboxConfig) {
+	mfs.pluginLoader.SetWASMSandbox(sandbox)
+}
+
+// LoadExternalPluginsFromDirectory loads the plugins of a directory that are
+// not loaded yet, registering them like LoadExternalPlugin, and returns the
+// paths of the plugins it loaded
+func (mfs *MountableFS) LoadExternalPluginsFromDirectory(dir string) ([]string, []error) {
+	return mfs.loadPluginDirectory(dir, nil)
+}
+
+// pluginStamp identifies a version of a plugin file
+type pluginStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// loadPluginDirectory loads the new plugins of a directory. If failed is not
+// nil, it records the plugins that failed to load, which are only tried again
+// once their file changes, e.g. when it was still being copied.
+func (mfs *MountableFS) loadPluginDirectory(dir string, failed map[string]pluginStamp) ([]string, []error) {
+	plugins, err := loader.DiscoverPlugins(dir)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	var loaded []string
+	var errors []error
+	for _, info := range plugins {
+		if mfs.pluginLoader.IsLoaded(info.Path) {
+			continue
+		}
+		var stamp pluginStamp
+		if failed != nil {
+			st, err := os.Stat(info.Path)
+			if err != nil {
+				continue
+			}
+			stamp = pluginStamp{size: st.Size(), modTime: st.ModTime()}
+			if last, ok := failed[info.Path]; ok && last == stamp {
+				continue
+			}
+		}
+
+		p, err := mfs.LoadExternalPlugin(info.Path)
+		if err != nil {
+			errors = append(errors, fmt.Errorf("failed to load %s: %w", info.Name, err))
+			log.Errorf("Failed to load plugin %s: %v", info.Path, err)
+			if failed != nil {
+				failed[info.Path] = stamp
+			}
+			continue
+		}
+		if failed != nil {
+			delete(failed, info.Path)
+		}
+		loaded = append(loaded, info.Path)
+		log.Infof("Loaded plugin: %s (%s)", p.Name(), info.Path)
+	}
+	return loaded, errors
+}
+
+// StartPluginWatcher loads the plugins dropped into a directory at the given
+// interval, until StopPluginWatcher is called. Plugins removed from the
+// directory stay loaded; they are unloaded through the API.
+func (mfs *MountableFS) StartPluginWatcher(dir string, interval time.Duration) {
+	mfs.pluginDirMu.Lock()
+	defer mfs.pluginDirMu.Unlock()
+
+	if mfs.pluginDirStop != nil || dir == "" || interval <= 0 {
+		return
+	}
+	stop := make(chan struct{})
+	mfs.pluginDirStop = stop
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		failed := make(map[string]pluginStamp)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				mfs.loadPluginDirectory(dir, failed)
+			}
+		}
+	}()
+	log.Infof("Watching plugin directory %s (interval: %v)", dir, interval)
+}
+
+// StopPluginWatcher stops the watching started by StartPluginWatcher
+func (mfs *MountableFS) StopPluginWatcher() {
+	mfs.pluginDirMu.Lock()
+	defer mfs.pluginDirMu.Unlock()
+
+	if mfs.pluginDirStop != nil {
+		close(mfs.pluginDirStop)
+		mfs.pluginDirStop = nil
+	}
+}
@@ -0,0 +1,50 @@
+package mountablefs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/api"
+)
+
+// emptyWASMModule is the smallest valid WASM module: the magic number and version
+var emptyWASMModule = []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+
+func TestPluginWatcher(t *testing.T) {
+	dir := t.TempDir()
+	mfs := NewMountableFS(api.PoolConfig{})
+	defer mfs.StopPluginWatcher()
+
+	// A half-copied plugin fails to load, and is only tried again once it changes
+	bad := filepath.Join(dir, "bad.wasm")
+	if err := os.WriteFile(bad, emptyWASMModule[:6], 0644); err != nil {
+		t.Fatal(err)
+	}
+	failed := make(map[string]pluginStamp)
+	if loaded, errs := mfs.loadPluginDirectory(dir, failed); len(loaded) != 0 || len(errs) != 1 {
+		t.Fatalf("expected bad.wasm to fail, got %v, %v", loaded, errs)
+	}
+	if _, errs := mfs.loadPluginDirectory(dir, failed); len(errs) != 0 {
+		t.Errorf("expected an unchanged plugin not to be tried again, got %v", errs)
+	}
+
+	mfs.StartPluginWatcher(dir, 10*time.Millisecond)
+	if err := os.WriteFile(filepath.Join(dir, "dropped.wasm"), emptyWASMModule, 0644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for mfs.CreatePlugin("wasm-plugin") == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("dropped plugin was not loaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Loaded plugins are not loaded again
+	time.Sleep(50 * time.Millisecond)
+	if loaded := mfs.GetLoadedExternalPlugins(); len(loaded) != 1 {
+		t.Errorf("expected one loaded plugin, got %v", loaded)
+	}
+}
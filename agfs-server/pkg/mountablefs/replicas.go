@@ -0,0 +1,127 @@
+package mountablefs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	log "github.com/sirupsen/logrus"
+)
+
+// ReplicasKey is the mount config key listing the read replicas of a mount of
+// a plugin.ReadRouter: a list of config maps, each overriding keys of the
+// mount config (e.g. the DSN of a database read replica)
+const ReplicasKey = "replicas"
+
+// readOps are the operations that may be routed to a replica
+var readOps = map[string]bool{"read": true, "readdir": true, "stat": true, "open": true, "grep": true}
+
+// readRouter returns the read routing of a plugin, looking through renames
+func readRouter(p plugin.ServicePlugin) (plugin.ReadRouter, bool) {
+	if rp, ok := p.(*RenamedPlugin); ok {
+		p = rp.ServicePlugin
+	}
+	router, ok := p.(plugin.ReadRouter)
+	return router, ok
+}
+
+// TakeReplicaConfigs takes the replicas out of a mount config, returning the
+// config of each replica (the mount config overridden by its entry) and the
+// config without them
+func TakeReplicaConfigs(cfg map[string]interface{}) ([]map[string]interface{}, map[string]interface{}, error) {
+	value, ok := cfg[ReplicasKey]
+	if !ok {
+		return nil, cfg, nil
+	}
+	entries, ok := value.([]interface{})
+	if !ok {
+		return nil, nil, fmt.Errorf("%s must be a list of config maps", ReplicasKey)
+	}
+
+	rest := make(map[string]interface{}, len(cfg))
+	for k, v := range cfg {
+		if k != ReplicasKey {
+			rest[k] = v
+		}
+	}
+	configs := make([]map[string]interface{}, 0, len(entries))
+	for i, entry := range entries {
+		overrides, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, nil, fmt.Errorf("%s[%d] must be a config map", ReplicasKey, i)
+		}
+		replicaCfg := make(map[string]interface{}, len(rest)+len(overrides))
+		for k, v := range rest {
+			replicaCfg[k] = v
+		}
+		for k, v := range overrides {
+			replicaCfg[k] = v
+		}
+		configs = append(configs, replicaCfg)
+	}
+	return configs, rest, nil
+}
+
+// startReplicas creates and initializes a plugin instance for each replica
+// config; on failure the replicas already started are shut down
+func startReplicas(factory PluginFactory, path string, configs []map[string]interface{}) ([]*MountPoint, error) {
+	var replicas []*MountPoint
+	for i, cfg := range configs {
+		instance := factory()
+		err := instance.Validate(cfg)
+		if err == nil {
+			err = instance.Initialize(cfg)
+		}
+		if err != nil {
+			shutdownReplicas(replicas)
+			return nil, fmt.Errorf("replica %d: %v", i, err)
+		}
+		replicas = append(replicas, &MountPoint{Path: path, Plugin: instance})
+	}
+	return replicas, nil
+}
+
+// shutdownReplicas shuts down the plugin instances of replicas
+func shutdownReplicas(replicas []*MountPoint) {
+	for i, r := range replicas {
+		if err := r.Plugin.Shutdown(); err != nil {
+			log.Warnf("Failed to shut down replica %d of %s: %v", i, r.Path, err)
+		}
+	}
+}
+
+// pluginFor returns the plugin instance serving an operation on a path of the
+// mount: for the reads of a mount with replicas, the next healthy replica in
+// turn, otherwise (or if no replica is healthy) the primary
+func (m *MountPoint) pluginFor(op, path string) plugin.ServicePlugin {
+	if len(m.replicas) == 0 || !readOps[op] {
+		return m.Plugin
+	}
+	router, ok := readRouter(m.Plugin)
+	if !ok || !router.IsRead(op, mountRelPath(m.Path, path)) {
+		return m.Plugin
+	}
+
+	start := m.nextReplica.Add(1)
+	for i := range m.replicas {
+		r := m.replicas[(start+uint64(i))%uint64(len(m.replicas))]
+		if r.checkAvailable() == nil {
+			return r.Plugin
+		}
+	}
+	return m.Plugin
+}
+
+// mountRelPath returns the path inside a mount of a path under it
+func mountRelPath(mountPath, path string) string {
+	path = filesystem.NormalizePath(path)
+	if mountPath == "/" {
+		return path
+	}
+	rel := strings.TrimPrefix(path, mountPath)
+	if rel == "" {
+		return "/"
+	}
+	return rel
+}
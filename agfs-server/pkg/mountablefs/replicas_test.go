@@ -0,0 +1,180 @@
+package mountablefs
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/api"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/memfs"
+)
+
+// routedPlugin is a memfs whose listings can be served by replicas; an
+// instance is down while its health check fails
+type routedPlugin struct {
+	*memfs.MemFSPlugin
+	down bool
+}
+
+func (p *routedPlugin) IsRead(op, path string) bool {
+	return op == "readdir"
+}
+
+func (p *routedPlugin) HealthCheck() error {
+	if p.down {
+		return errors.New("replica down")
+	}
+	return nil
+}
+
+// instanceDir returns the directory that tells instances apart
+func instanceDir(t *testing.T, infos []filesystem.FileInfo) string {
+	t.Helper()
+	for _, info := range infos {
+		if info.IsDir && (info.Name == "primary" || strings.HasPrefix(info.Name, "replica")) {
+			return info.Name
+		}
+	}
+	t.Fatalf("no instance directory in %+v", infos)
+	return ""
+}
+
+func newReplicaTestFS(t *testing.T) *MountableFS {
+	mfs := NewMountableFS(api.PoolConfig{})
+	mfs.RegisterPluginFactory("routed", func() plugin.ServicePlugin {
+		return &routedPlugin{MemFSPlugin: memfs.NewMemFSPlugin()}
+	})
+	mfs.RegisterPluginFactory("memfs", func() plugin.ServicePlugin { return memfs.NewMemFSPlugin() })
+	return mfs
+}
+
+func TestReplicaRouting(t *testing.T) {
+	mfs := newReplicaTestFS(t)
+	// Each instance gets its own directory, which tells them apart
+	err := mfs.MountPlugin("routed", "/db", map[string]interface{}{
+		"init_dirs": []string{"/primary"},
+		ReplicasKey: []interface{}{
+			map[string]interface{}{"init_dirs": []string{"/replica1"}},
+			map[string]interface{}{"init_dirs": []string{"/replica2"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reads declared by the plugin alternate between the replicas
+	seen := map[string]int{}
+	for i := 0; i < 4; i++ {
+		infos, err := mfs.ReadDir("/db")
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[instanceDir(t, infos)]++
+	}
+	if seen["replica1"] != 2 || seen["replica2"] != 2 {
+		t.Fatalf("reads served by %v", seen)
+	}
+
+	// Other reads and all writes go to the primary
+	if _, err := mfs.Stat("/db/primary"); err != nil {
+		t.Errorf("stat not served by the primary: %v", err)
+	}
+	if _, err := mfs.Stat("/db/replica1"); err == nil {
+		t.Error("stat served by a replica")
+	}
+	if err := mfs.Mkdir("/db/other", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mfs.Stat("/db/other"); err != nil {
+		t.Errorf("stat of a new directory: %v", err)
+	}
+
+	// Unhealthy replicas are skipped, and the primary serves reads if none is left
+	mount, _, _ := mfs.findMount("/db")
+	mount.replicas[0].Plugin.(*routedPlugin).down = true
+	mfs.CheckHealth()
+	for i := 0; i < 2; i++ {
+		infos, err := mfs.ReadDir("/db")
+		if err != nil || instanceDir(t, infos) != "replica2" {
+			t.Fatalf("with replica1 down: %+v, %v", infos, err)
+		}
+	}
+	mount.replicas[1].Plugin.(*routedPlugin).down = true
+	mfs.CheckHealth()
+	infos, err := mfs.ReadDir("/db")
+	if err != nil || instanceDir(t, infos) != "primary" {
+		t.Fatalf("with all replicas down: %+v, %v", infos, err)
+	}
+	if err := mount.checkAvailable(); err != nil {
+		t.Errorf("mount unavailable because of its replicas: %v", err)
+	}
+
+	report := mfs.GetMountHealth().([]MountHealth)
+	if len(report) != 1 || len(report[0].Replicas) != 2 || report[0].Replicas[0].Healthy() {
+		t.Errorf("health report = %+v", report)
+	}
+
+	if err := mfs.Unmount("/db"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplicaConfig(t *testing.T) {
+	mfs := newReplicaTestFS(t)
+
+	// Plugins that don't route reads reject replicas
+	err := mfs.MountPlugin("memfs", "/m", map[string]interface{}{
+		ReplicasKey: []interface{}{map[string]interface{}{}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "does not support read replicas") {
+		t.Errorf("memfs with replicas: %v", err)
+	}
+
+	for _, replicas := range []interface{}{
+		"dsn",
+		[]interface{}{"dsn"},
+		[]interface{}{map[string]interface{}{"unknown": true}},
+	} {
+		if err := mfs.MountPlugin("routed", "/db", map[string]interface{}{ReplicasKey: replicas}); err == nil {
+			t.Errorf("replicas %v accepted", replicas)
+			mfs.Unmount("/db")
+		}
+	}
+	if len(mfs.GetMounts()) != 0 {
+		t.Errorf("mounts left after failures: %d", len(mfs.GetMounts()))
+	}
+}
+
+func TestReplicaDeferred(t *testing.T) {
+	mfs := newReplicaTestFS(t)
+	// Replicas get none of the mount's reserved keys (lazy, ...), which the
+	// plugin would reject
+	err := mfs.MountPlugin("routed", "/db", map[string]interface{}{
+		"init_dirs": []string{"/primary"},
+		LazyKey:     true,
+		ReplicasKey: []interface{}{
+			map[string]interface{}{"init_dirs": []string{"/replica1"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mount, _, _ := mfs.findMount("/db")
+	if mount.Ready() || mount.replicas[0].Ready() {
+		t.Fatal("lazy mount initialized before its first access")
+	}
+
+	// The first access initializes the mount and its replicas
+	infos, err := mfs.ReadDir("/db")
+	if err != nil || instanceDir(t, infos) != "replica1" {
+		t.Fatalf("readdir of the lazy mount: %+v, %v", infos, err)
+	}
+	if !mount.replicas[0].Ready() {
+		t.Error("replica not ready with its mount")
+	}
+	if err := mfs.Unmount("/db"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,286 @@
+package mountablefs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+// MaxConcurrencyKey is the mount config key of how many operations the mount
+// runs at once (see TakeScheduler)
+const MaxConcurrencyKey = "max_concurrency"
+
+// Priority is the class of an operation, telling the mount's scheduler which
+// operations to run first when the mount is busy
+type Priority int
+
+const (
+	// PriorityInteractive is for operations someone waits on, e.g. an
+	// agent's ls or cat
+	PriorityInteractive Priority = iota
+	// PriorityBatch is for bulk work, e.g. imports and migrations, which
+	// yields to interactive operations
+	PriorityBatch
+)
+
+// ParsePriority parses a priority name, "interactive" or "batch"; empty is
+// interactive
+func ParsePriority(s string) (Priority, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "interactive":
+		return PriorityInteractive, nil
+	case "batch":
+		return PriorityBatch, nil
+	}
+	return 0, fmt.Errorf("invalid priority %q: expected interactive or batch", s)
+}
+
+func (p Priority) String() string {
+	if p == PriorityBatch {
+		return "batch"
+	}
+	return "interactive"
+}
+
+// Scheduler bounds the operations running at once on a mount, e.g. to the
+// size of its backend's connection pool, and lets interactive operations
+// overtake batch ones: waiting interactive operations are started first, and
+// batch operations never take the last slots, so that an agent's ls is not
+// stuck behind a bulk import saturating a TiDB pool.
+type Scheduler struct {
+	limit      int // Operations running at once
+	batchLimit int // Batch operations running at once
+
+	mu           sync.Mutex
+	running      int
+	runningBatch int
+	waiting      [2][]*schedWaiter // Waiting operations by priority, oldest first
+	waited       [2]int64          // Operations that had to wait, by priority
+}
+
+// schedWaiter is an operation waiting for a slot
+type schedWaiter struct {
+	ready   chan struct{} // Closed when the operation is granted a slot
+	granted bool
+}
+
+// SchedulerStats is a snapshot of a scheduler
+type SchedulerStats struct {
+	Limit              int   `json:"limit"`
+	BatchLimit         int   `json:"batch_limit"`
+	Running            int   `json:"running"`
+	RunningBatch       int   `json:"running_batch"`
+	WaitingInteractive int   `json:"waiting_interactive"`
+	WaitingBatch       int   `json:"waiting_batch"`
+	WaitedInteractive  int64 `json:"waited_interactive"` // Since the mount was mounted
+	WaitedBatch        int64 `json:"waited_batch"`
+}
+
+// NewScheduler creates a scheduler running at most limit operations at once.
+// A quarter of the slots, at least one, is kept for interactive operations.
+func NewScheduler(limit int) *Scheduler {
+	if limit < 1 {
+		limit = 1
+	}
+	batchLimit := limit
+	if limit > 1 {
+		batchLimit = limit - max(1, limit/4)
+	}
+	return &Scheduler{limit: limit, batchLimit: batchLimit}
+}
+
+// Acquire waits for a slot to run an operation of priority p, until ctx is
+// done. The returned function releases the slot.
+func (s *Scheduler) Acquire(ctx context.Context, p Priority) (func(), error) {
+	if p != PriorityBatch {
+		p = PriorityInteractive
+	}
+	release := func() { s.release(p) }
+
+	s.mu.Lock()
+	if len(s.waiting[p]) == 0 && s.canRun(p) {
+		s.start(p)
+		s.mu.Unlock()
+		return release, nil
+	}
+	w := &schedWaiter{ready: make(chan struct{})}
+	s.waiting[p] = append(s.waiting[p], w)
+	s.waited[p]++
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return release, nil
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	if w.granted {
+		// Granted as it was given up
+		s.mu.Unlock()
+		release()
+		return nil, ctx.Err()
+	}
+	for i, other := range s.waiting[p] {
+		if other == w {
+			s.waiting[p] = append(s.waiting[p][:i], s.waiting[p][i+1:]...)
+			break
+		}
+	}
+	// A batch operation may have been held back by this one
+	s.grant()
+	s.mu.Unlock()
+	return nil, ctx.Err()
+}
+
+// canRun reports whether an operation of priority p can start now; s.mu is
+// held
+func (s *Scheduler) canRun(p Priority) bool {
+	if s.running >= s.limit {
+		return false
+	}
+	if p == PriorityBatch {
+		return s.runningBatch < s.batchLimit && len(s.waiting[PriorityInteractive]) == 0
+	}
+	return true
+}
+
+// start counts an operation of priority p as running; s.mu is held
+func (s *Scheduler) start(p Priority) {
+	s.running++
+	if p == PriorityBatch {
+		s.runningBatch++
+	}
+}
+
+// release frees the slot of an operation of priority p
+func (s *Scheduler) release(p Priority) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running--
+	if p == PriorityBatch {
+		s.runningBatch--
+	}
+	s.grant()
+}
+
+// grant starts the waiting operations that can run, interactive ones first;
+// s.mu is held
+func (s *Scheduler) grant() {
+	for _, p := range []Priority{PriorityInteractive, PriorityBatch} {
+		for len(s.waiting[p]) > 0 && s.canRun(p) {
+			w := s.waiting[p][0]
+			s.waiting[p] = s.waiting[p][1:]
+			w.granted = true
+			s.start(p)
+			close(w.ready)
+		}
+	}
+}
+
+// Stats returns a snapshot of the scheduler
+func (s *Scheduler) Stats() SchedulerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return SchedulerStats{
+		Limit:              s.limit,
+		BatchLimit:         s.batchLimit,
+		Running:            s.running,
+		RunningBatch:       s.runningBatch,
+		WaitingInteractive: len(s.waiting[PriorityInteractive]),
+		WaitingBatch:       len(s.waiting[PriorityBatch]),
+		WaitedInteractive:  s.waited[PriorityInteractive],
+		WaitedBatch:        s.waited[PriorityBatch],
+	}
+}
+
+// TakeScheduler takes max_concurrency out of a mount config, returning a
+// scheduler for it (nil if the mount has none) and the config without the key
+func TakeScheduler(cfg map[string]interface{}) (*Scheduler, map[string]interface{}, error) {
+	value, ok := cfg[MaxConcurrencyKey]
+	if !ok {
+		return nil, cfg, nil
+	}
+
+	var limit int
+	switch v := value.(type) {
+	case int:
+		limit = v
+	case int64:
+		limit = int(v)
+	case float64:
+		limit = int(v)
+		if float64(limit) != v {
+			limit = -1
+		}
+	default:
+		limit = -1
+	}
+	if limit < 0 {
+		return nil, nil, fmt.Errorf("%s must be a non-negative integer", MaxConcurrencyKey)
+	}
+
+	rest := make(map[string]interface{}, len(cfg))
+	for k, v := range cfg {
+		if k != MaxConcurrencyKey {
+			rest[k] = v
+		}
+	}
+	if limit == 0 {
+		// Unbounded
+		return nil, rest, nil
+	}
+	return NewScheduler(limit), rest, nil
+}
+
+// SetScheduler sets the scheduler of the mount at a path; nil removes it
+func (mfs *MountableFS) SetScheduler(mountPath string, s *Scheduler) error {
+	mountPath = filesystem.NormalizePath(mountPath)
+	mount, _, found := mfs.findMount(mountPath)
+	if !found || mount.Path != mountPath {
+		return filesystem.NewNotFoundError("mount", mountPath)
+	}
+	mount.scheduler.Store(s)
+	return nil
+}
+
+// Scheduler returns the scheduler of the mount, nil if its operations are not
+// bounded
+func (m *MountPoint) Scheduler() *Scheduler {
+	return m.scheduler.Load()
+}
+
+// Schedule waits, until ctx is done, for the scheduler of the mount holding
+// path to let an operation of priority p on it run. The returned function
+// must be called once the operation is done. Paths of mounts without a
+// scheduler run right away.
+func (mfs *MountableFS) Schedule(ctx context.Context, path string, p Priority) (func(), error) {
+	mount, _, found := mfs.findMount(path)
+	if !found {
+		return func() {}, nil
+	}
+	return mount.schedule(ctx, p)
+}
+
+// ScheduleHandle is Schedule for an operation on an open handle
+func (mfs *MountableFS) ScheduleHandle(ctx context.Context, id int64, p Priority) (func(), error) {
+	mfs.handleInfosMu.RLock()
+	info, found := mfs.handleInfos[id]
+	mfs.handleInfosMu.RUnlock()
+	if !found {
+		// The operation fails on its own
+		return func() {}, nil
+	}
+	return info.mount.schedule(ctx, p)
+}
+
+func (m *MountPoint) schedule(ctx context.Context, p Priority) (func(), error) {
+	s := m.scheduler.Load()
+	if s == nil {
+		return func() {}, nil
+	}
+	return s.Acquire(ctx, p)
+}
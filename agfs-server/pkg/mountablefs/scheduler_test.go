@@ -0,0 +1,169 @@
+package mountablefs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/api"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/memfs"
+)
+
+func TestTakeScheduler(t *testing.T) {
+	s, rest, err := TakeScheduler(map[string]interface{}{"max_concurrency": 8, "other": "x"})
+	if err != nil {
+		t.Fatalf("TakeScheduler failed: %v", err)
+	}
+	if stats := s.Stats(); stats.Limit != 8 || stats.BatchLimit != 6 || len(rest) != 1 {
+		t.Errorf("unexpected scheduler %+v, rest %v", stats, rest)
+	}
+	if s, rest, err := TakeScheduler(map[string]interface{}{"max_concurrency": float64(0)}); s != nil || len(rest) != 0 || err != nil {
+		t.Errorf("expected no scheduler, got %v, %v, %v", s, rest, err)
+	}
+	for _, value := range []interface{}{-1, 1.5, "8"} {
+		if _, _, err := TakeScheduler(map[string]interface{}{"max_concurrency": value}); err == nil {
+			t.Errorf("expected %v to be rejected", value)
+		}
+	}
+}
+
+// acquireAsync acquires a slot in the background, sending on the returned
+// channel once it is granted
+func acquireAsync(ctx context.Context, s *Scheduler, p Priority) <-chan func() {
+	granted := make(chan func(), 1)
+	go func() {
+		release, err := s.Acquire(ctx, p)
+		if err != nil {
+			close(granted)
+			return
+		}
+		granted <- release
+	}()
+	return granted
+}
+
+// waitQueued waits until the scheduler has n operations waiting
+func waitQueued(t *testing.T, s *Scheduler, n int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		stats := s.Stats()
+		if stats.WaitingInteractive+stats.WaitingBatch == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d waiting operations, got %+v", n, stats)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSchedulerInteractiveFirst(t *testing.T) {
+	ctx := context.Background()
+	s := NewScheduler(4)
+
+	// Batch operations leave a slot to interactive ones
+	var releases []func()
+	for i := 0; i < 3; i++ {
+		release, err := s.Acquire(ctx, PriorityBatch)
+		if err != nil {
+			t.Fatalf("Acquire failed: %v", err)
+		}
+		releases = append(releases, release)
+	}
+	batch := acquireAsync(ctx, s, PriorityBatch)
+	waitQueued(t, s, 1)
+	release, err := s.Acquire(ctx, PriorityInteractive)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+
+	// Queued interactive operations overtake queued batch ones
+	interactive := acquireAsync(ctx, s, PriorityInteractive)
+	waitQueued(t, s, 2)
+	releases[0]()
+	select {
+	case release := <-interactive:
+		release()
+	case <-time.After(5 * time.Second):
+		t.Fatal("interactive operation not granted")
+	}
+	select {
+	case <-batch:
+		t.Fatal("batch operation granted before the interactive one was done")
+	default:
+	}
+
+	release()
+	select {
+	case release := <-batch:
+		release()
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch operation not granted")
+	}
+	for _, release := range releases[1:] {
+		release()
+	}
+	if stats := s.Stats(); stats.Running != 0 || stats.WaitedInteractive != 1 || stats.WaitedBatch != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	s := NewScheduler(1)
+	release, err := s.Acquire(context.Background(), PriorityInteractive)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+
+	// A batch operation queued behind a canceled interactive one runs
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := acquireAsync(ctx, s, PriorityInteractive)
+	waitQueued(t, s, 1)
+	batch := acquireAsync(context.Background(), s, PriorityBatch)
+	waitQueued(t, s, 2)
+	cancel()
+	if _, ok := <-canceled; ok {
+		t.Fatal("expected the canceled operation to fail")
+	}
+	release()
+	select {
+	case release := <-batch:
+		release()
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch operation not granted")
+	}
+	if stats := s.Stats(); stats.Running != 0 || stats.WaitingInteractive != 0 || stats.WaitingBatch != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestScheduleMount(t *testing.T) {
+	mfs := NewMountableFS(api.PoolConfig{})
+	mfs.RegisterPluginFactory("memfs", func() plugin.ServicePlugin { return memfs.NewMemFSPlugin() })
+	if err := mfs.MountPlugin("memfs", "/db", map[string]interface{}{"max_concurrency": 1}); err != nil {
+		t.Fatalf("MountPlugin failed: %v", err)
+	}
+	if err := mfs.MountPlugin("memfs", "/mem", map[string]interface{}{}); err != nil {
+		t.Fatalf("MountPlugin failed: %v", err)
+	}
+
+	release, err := mfs.Schedule(context.Background(), "/db/table", PriorityBatch)
+	if err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+	defer release()
+
+	// Mounts without a scheduler are not bounded
+	other, err := mfs.Schedule(context.Background(), "/mem/file", PriorityBatch)
+	if err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+	other()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := mfs.Schedule(ctx, "/db/other", PriorityInteractive); err != context.DeadlineExceeded {
+		t.Errorf("expected the full mount to time out, got %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package mountablefs
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	iradix "github.com/hashicorp/go-immutable-radix"
+	log "github.com/sirupsen/logrus"
+)
+
+// rootFSUser is implemented by plugins that reach other mounts through the
+// root file system (e.g. httpfs), so they depend on every other mount
+type rootFSUser interface {
+	SetRootFS(rootFS filesystem.FileSystem)
+}
+
+func usesRootFS(p plugin.ServicePlugin) bool {
+	if rp, ok := p.(*RenamedPlugin); ok {
+		p = rp.ServicePlugin
+	}
+	_, ok := p.(rootFSUser)
+	return ok
+}
+
+// shutdownOrder sorts mounts so that every mount is shut down before the
+// mounts it depends on: plugins using the root file system first, then
+// nested mounts before the mounts they are nested in
+func shutdownOrder(mounts []*MountPoint) []*MountPoint {
+	ordered := append([]*MountPoint(nil), mounts...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		ri, rj := usesRootFS(ordered[i].Plugin), usesRootFS(ordered[j].Plugin)
+		if ri != rj {
+			return ri
+		}
+		di, dj := strings.Count(ordered[i].Path, "/"), strings.Count(ordered[j].Path, "/")
+		if di != dj {
+			return di > dj
+		}
+		return ordered[i].Path < ordered[j].Path
+	})
+	return ordered
+}
+
+// Shutdown stops the server side of the file system: health checks, the
+// TTL sweeper and the plugin directory watcher stop, running tasks are cancelled, open handles are closed and
+// every plugin is shut down and unmounted in dependency order, which lets
+// plugins flush their queues. Once ctx is done, Shutdown stops waiting for tasks and
+// plugins and returns ctx's error; the remaining plugins are left running.
+func (mfs *MountableFS) Shutdown(ctx context.Context) error {
+	mfs.StopHealthChecks()
+	mfs.StopTTLSweeper()
+	mfs.StopHandleReaper()
+	mfs.StopPluginWatcher()
+
+	mfs.tasksMu.Lock()
+	var running []*task
+	for _, t := range mfs.tasks {
+		if t.snapshot().Status == TaskStatusRunning {
+			running = append(running, t)
+		}
+	}
+	mfs.tasksMu.Unlock()
+	if len(running) > 0 {
+		log.Infof("Shutdown: cancelling %d running task(s)", len(running))
+	}
+	for _, t := range running {
+		t.cancel()
+	}
+	for _, t := range running {
+		select {
+		case <-t.done:
+		case <-ctx.Done():
+			log.Warnf("Shutdown: gave up waiting for task %d (%s on %s)", t.info.ID, t.info.Task, t.info.Mount)
+			return ctx.Err()
+		}
+	}
+
+	if n := mfs.CloseHandlesUnder("/"); n > 0 {
+		log.Infof("Shutdown: closed %d open handle(s)", n)
+	}
+
+	mounts := shutdownOrder(mfs.GetMounts())
+	for i, mount := range mounts {
+		log.Infof("Shutdown: [%d/%d] shutting down %s at %s", i+1, len(mounts), mount.Plugin.Name(), mount.Path)
+
+		done := make(chan error, 1)
+		go func() {
+			var err error
+			if mount.init == nil || mount.init.close() {
+				err = mount.Plugin.Shutdown()
+				shutdownReplicas(mount.replicas)
+			}
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				log.Warnf("Shutdown: %s at %s: %v", mount.Plugin.Name(), mount.Path, err)
+			}
+		case <-ctx.Done():
+			log.Warnf("Shutdown: gave up waiting for %s at %s, %d plugin(s) not shut down",
+				mount.Plugin.Name(), mount.Path, len(mounts)-i)
+			return ctx.Err()
+		}
+
+		mfs.mu.Lock()
+		tree := mfs.mountTree.Load().(*iradix.Tree)
+		newTree, _, _ := tree.Delete([]byte(mount.Path))
+		mfs.mountTree.Store(newTree)
+		mfs.mu.Unlock()
+	}
+	log.Infof("Shutdown: all %d plugin(s) shut down", len(mounts))
+	return nil
+}
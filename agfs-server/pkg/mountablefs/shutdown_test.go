@@ -0,0 +1,108 @@
+package mountablefs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/api"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/memfs"
+)
+
+// recordingPlugin is a memfs that records when it is shut down
+type recordingPlugin struct {
+	*memfs.MemFSPlugin
+	path     string
+	shutdown *[]string
+	block    chan struct{} // If set, Shutdown waits for it
+}
+
+func (p *recordingPlugin) Shutdown() error {
+	if p.block != nil {
+		<-p.block
+	}
+	*p.shutdown = append(*p.shutdown, p.path)
+	return p.MemFSPlugin.Shutdown()
+}
+
+// rootFSPlugin is a recordingPlugin that depends on the root file system
+type rootFSPlugin struct {
+	*recordingPlugin
+}
+
+func (p *rootFSPlugin) SetRootFS(rootFS filesystem.FileSystem) {}
+
+func mountRecording(t *testing.T, mfs *MountableFS, path string, shutdown *[]string, rootFS bool) *recordingPlugin {
+	t.Helper()
+	p := &recordingPlugin{MemFSPlugin: memfs.NewMemFSPlugin(), path: path, shutdown: shutdown}
+	if err := p.Initialize(map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	if rootFS {
+		err = mfs.Mount(path, &rootFSPlugin{p})
+	} else {
+		err = mfs.Mount(path, p)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestShutdownOrder(t *testing.T) {
+	mfs := NewMountableFS(api.PoolConfig{})
+	var shutdown []string
+	mountRecording(t, mfs, "/data", &shutdown, false)
+	mountRecording(t, mfs, "/data/cache", &shutdown, false)
+	mountRecording(t, mfs, "/web", &shutdown, true)
+	mountRecording(t, mfs, "/queue", &shutdown, false)
+
+	if err := mfs.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/web", "/data/cache", "/data", "/queue"}
+	if !reflect.DeepEqual(shutdown, want) {
+		t.Errorf("shutdown order = %v, want %v", shutdown, want)
+	}
+	if mounts := mfs.GetMounts(); len(mounts) != 0 {
+		t.Errorf("%d mount(s) left after shutdown", len(mounts))
+	}
+}
+
+func TestShutdownCancelsTasksAndClosesHandles(t *testing.T) {
+	mfs := newTaskFS(t)
+	info, err := mfs.StartTask("/jobs", "block", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mfs.OpenHandle("/jobs/f", filesystem.O_RDWR|filesystem.O_CREATE, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mfs.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := mfs.GetTask(info.ID); info.Status != TaskStatusCancelled {
+		t.Errorf("task status = %s, want %s", info.Status, TaskStatusCancelled)
+	}
+	if handles := mfs.ListOpenHandles(); len(handles) != 0 {
+		t.Errorf("%d handle(s) left open after shutdown", len(handles))
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	mfs := NewMountableFS(api.PoolConfig{})
+	var shutdown []string
+	stuck := mountRecording(t, mfs, "/stuck", &shutdown, false)
+	stuck.block = make(chan struct{})
+	defer close(stuck.block)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := mfs.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Shutdown() = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
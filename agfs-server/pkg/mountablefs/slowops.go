@@ -0,0 +1,57 @@
+package mountablefs
+
+import (
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/middleware"
+	log "github.com/sirupsen/logrus"
+)
+
+// fsFor returns the file system of mount for running op on path, and a
+// function to call once the operation is done.
+// Operations are timed when SlowOpThreshold is set or debug logging is on:
+// those slower than the threshold are logged as warnings (every operation
+// at debug level), with a breakdown by backend for plugins whose file
+// system is filesystem.Traceable. The file system is that of a replica for
+// reads routed to one (see pluginFor), wrapped in the mount's middlewares.
+func (mfs *MountableFS) fsFor(op, path string, mount *MountPoint) (filesystem.FileSystem, func()) {
+	fs := mount.pluginFor(op, path).GetFileSystem()
+	threshold := mfs.SlowOpThreshold
+	debug := log.IsLevelEnabled(log.DebugLevel)
+	if threshold <= 0 && !debug {
+		return middleware.Wrap(fs, mount.middlewares), func() {}
+	}
+
+	var trace *filesystem.OpTrace
+	if traceable, ok := fs.(filesystem.Traceable); ok {
+		trace = &filesystem.OpTrace{}
+		fs = traceable.WithTrace(trace)
+	}
+	fs = middleware.Wrap(fs, mount.middlewares)
+
+	start := time.Now()
+	return fs, func() {
+		elapsed := time.Since(start)
+		slow := threshold > 0 && elapsed >= threshold
+		if !slow && !debug {
+			return
+		}
+
+		fields := log.Fields{
+			"op":          op,
+			"path":        path,
+			"mount":       mount.Path,
+			"plugin":      mount.Plugin.Name(),
+			"duration_ms": elapsed.Milliseconds(),
+		}
+		if breakdown := trace.String(); breakdown != "" {
+			fields["breakdown"] = breakdown
+		}
+		if slow {
+			log.WithFields(fields).Warn("slow operation")
+		} else {
+			log.WithFields(fields).Debug("operation")
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package mountablefs
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/api"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/memfs"
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+// tracedFS is a memfs whose writes take a while in a traced "backend" step
+type tracedFS struct {
+	filesystem.FileSystem
+	trace *filesystem.OpTrace
+}
+
+func (fs *tracedFS) WithTrace(trace *filesystem.OpTrace) filesystem.FileSystem {
+	return &tracedFS{FileSystem: fs.FileSystem, trace: trace}
+}
+
+func (fs *tracedFS) Write(path string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	start := time.Now()
+	time.Sleep(20 * time.Millisecond)
+	fs.trace.Span("backend", start)
+	return fs.FileSystem.Write(path, data, offset, flags)
+}
+
+type tracedPlugin struct {
+	*memfs.MemFSPlugin
+}
+
+func (p *tracedPlugin) GetFileSystem() filesystem.FileSystem {
+	return &tracedFS{FileSystem: p.MemFSPlugin.GetFileSystem()}
+}
+
+func TestSlowOpLogging(t *testing.T) {
+	hook := test.NewGlobal()
+	defer hook.Reset()
+	level := log.GetLevel()
+	log.SetLevel(log.InfoLevel)
+	defer log.SetLevel(level)
+
+	mfs := NewMountableFS(api.PoolConfig{})
+	p := &tracedPlugin{MemFSPlugin: memfs.NewMemFSPlugin()}
+	if err := p.Initialize(map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.Mount("/traced", p); err != nil {
+		t.Fatal(err)
+	}
+	mfs.SlowOpThreshold = 10 * time.Millisecond
+
+	hook.Reset()
+	if _, err := mfs.Write("/traced/a.txt", []byte("data"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mfs.Stat("/traced/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the write is slow
+	entries := hook.AllEntries()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 log entry, got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry.Level != log.WarnLevel || entry.Message != "slow operation" {
+		t.Errorf("unexpected entry: %v %q", entry.Level, entry.Message)
+	}
+	for key, want := range map[string]interface{}{"op": "write", "path": "/traced/a.txt", "mount": "/traced", "plugin": "memfs"} {
+		if entry.Data[key] != want {
+			t.Errorf("%s = %v, want %v", key, entry.Data[key], want)
+		}
+	}
+	if breakdown, _ := entry.Data["breakdown"].(string); !strings.HasPrefix(breakdown, "backend=") {
+		t.Errorf("unexpected breakdown %q", breakdown)
+	}
+
+	// Disabled
+	mfs.SlowOpThreshold = 0
+	hook.Reset()
+	if _, err := mfs.Write("/traced/a.txt", []byte("data"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatal(err)
+	}
+	if len(hook.AllEntries()) != 0 {
+		t.Errorf("expected no log entries with threshold disabled")
+	}
+}
@@ -0,0 +1,250 @@
+package mountablefs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	log "github.com/sirupsen/logrus"
+)
+
+// Task states
+const (
+	TaskStatusRunning   = "running"
+	TaskStatusSucceeded = "succeeded"
+	TaskStatusFailed    = "failed"
+	TaskStatusCancelled = "cancelled"
+)
+
+// maxFinishedTasks is the number of finished tasks kept for inspection
+const maxFinishedTasks = 100
+
+// TaskInfo is a snapshot of a background task started with StartTask
+type TaskInfo struct {
+	ID         int64             `json:"id"`
+	Mount      string            `json:"mount"`
+	Task       string            `json:"task"`
+	Args       map[string]string `json:"args,omitempty"`
+	Status     string            `json:"status"`
+	Done       int64             `json:"done"`
+	Total      int64             `json:"total"` // 0 if unknown
+	Message    string            `json:"message,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+}
+
+// task is a running or finished background task
+type task struct {
+	info   TaskInfo
+	cancel context.CancelFunc
+	done   chan struct{} // Closed once the task has finished
+	mu     sync.Mutex
+}
+
+func (t *task) snapshot() TaskInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.info
+}
+
+// MountTasks lists the tasks a mount can run
+type MountTasks struct {
+	Mount  string   `json:"mount"`
+	Plugin string   `json:"plugin"`
+	Tasks  []string `json:"tasks"`
+}
+
+// taskRunner returns the task runner of a plugin, looking through renames
+func taskRunner(p plugin.ServicePlugin) (plugin.TaskRunner, bool) {
+	if rp, ok := p.(*RenamedPlugin); ok {
+		p = rp.ServicePlugin
+	}
+	tr, ok := p.(plugin.TaskRunner)
+	return tr, ok
+}
+
+// AvailableTasks returns the tasks of every mount whose plugin has any
+func (mfs *MountableFS) AvailableTasks() []MountTasks {
+	var result []MountTasks
+	for _, mount := range mfs.GetMounts() {
+		if tr, ok := taskRunner(mount.Plugin); ok {
+			result = append(result, MountTasks{
+				Mount:  mount.Path,
+				Plugin: mount.Plugin.Name(),
+				Tasks:  tr.Tasks(),
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Mount < result[j].Mount })
+	return result
+}
+
+// StartTask starts a task of the plugin mounted at (or above) path in the
+// background and returns its initial state
+func (mfs *MountableFS) StartTask(path, name string, args map[string]string) (TaskInfo, error) {
+	mount, relPath, found := mfs.findMount(path)
+	if !found {
+		return TaskInfo{}, filesystem.NewNotFoundError("task", path)
+	}
+	if err := mount.checkAvailable(); err != nil {
+		return TaskInfo{}, err
+	}
+	tr, ok := taskRunner(mount.Plugin)
+	if !ok {
+		return TaskInfo{}, filesystem.NewNotSupportedError("task", path)
+	}
+	supported := false
+	for _, t := range tr.Tasks() {
+		if t == name {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return TaskInfo{}, filesystem.NewInvalidArgumentError("task", name,
+			fmt.Sprintf("unknown task for %s (available: %s)", mount.Plugin.Name(), strings.Join(tr.Tasks(), ", ")))
+	}
+
+	taskArgs := make(map[string]string, len(args)+1)
+	for k, v := range args {
+		taskArgs[k] = v
+	}
+	if _, ok := taskArgs["path"]; !ok {
+		taskArgs["path"] = relPath
+	}
+
+	return mfs.startTask(mount.Path, name, taskArgs, tr), nil
+}
+
+// startTask runs a task of tr on a mount in the background and returns its
+// initial state
+func (mfs *MountableFS) startTask(mountPath, name string, args map[string]string, tr plugin.TaskRunner) TaskInfo {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &task{
+		info: TaskInfo{
+			ID:        mfs.taskID.Add(1),
+			Mount:     mountPath,
+			Task:      name,
+			Args:      args,
+			Status:    TaskStatusRunning,
+			StartedAt: time.Now(),
+		},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	mfs.tasksMu.Lock()
+	mfs.tasks[t.info.ID] = t
+	mfs.tasksMu.Unlock()
+
+	log.Infof("Started task %d: %s on %s", t.info.ID, name, mountPath)
+	go mfs.runTask(ctx, t, tr)
+	return t.snapshot()
+}
+
+// runTask runs a task and records its outcome
+func (mfs *MountableFS) runTask(ctx context.Context, t *task, tr plugin.TaskRunner) {
+	defer t.cancel()
+	defer close(t.done)
+
+	progress := func(done, total int64, message string) {
+		t.mu.Lock()
+		t.info.Done, t.info.Total, t.info.Message = done, total, message
+		t.mu.Unlock()
+	}
+
+	var err error
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("task panicked: %v", r)
+			}
+		}()
+		err = tr.RunTask(ctx, t.info.Task, t.info.Args, progress)
+	}()
+
+	now := time.Now()
+	t.mu.Lock()
+	t.info.FinishedAt = &now
+	switch {
+	case ctx.Err() != nil:
+		t.info.Status = TaskStatusCancelled
+	case err != nil:
+		t.info.Status = TaskStatusFailed
+		t.info.Error = err.Error()
+	default:
+		t.info.Status = TaskStatusSucceeded
+	}
+	info := t.info
+	t.mu.Unlock()
+
+	if err != nil && info.Status == TaskStatusFailed {
+		log.Warnf("Task %d (%s on %s) failed: %v", info.ID, info.Task, info.Mount, err)
+	} else {
+		log.Infof("Task %d (%s on %s) %s in %v", info.ID, info.Task, info.Mount, info.Status, now.Sub(info.StartedAt).Round(time.Millisecond))
+	}
+	mfs.pruneTasks()
+}
+
+// pruneTasks drops the oldest finished tasks beyond maxFinishedTasks
+func (mfs *MountableFS) pruneTasks() {
+	mfs.tasksMu.Lock()
+	defer mfs.tasksMu.Unlock()
+
+	var finished []int64
+	for id, t := range mfs.tasks {
+		if t.snapshot().Status != TaskStatusRunning {
+			finished = append(finished, id)
+		}
+	}
+	if len(finished) <= maxFinishedTasks {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i] < finished[j] })
+	for _, id := range finished[:len(finished)-maxFinishedTasks] {
+		delete(mfs.tasks, id)
+	}
+}
+
+// ListTasks returns running and recently finished tasks, oldest first
+func (mfs *MountableFS) ListTasks() []TaskInfo {
+	mfs.tasksMu.Lock()
+	tasks := make([]TaskInfo, 0, len(mfs.tasks))
+	for _, t := range mfs.tasks {
+		tasks = append(tasks, t.snapshot())
+	}
+	mfs.tasksMu.Unlock()
+
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+	return tasks
+}
+
+// GetTask returns the state of a task
+func (mfs *MountableFS) GetTask(id int64) (TaskInfo, error) {
+	mfs.tasksMu.Lock()
+	t, ok := mfs.tasks[id]
+	mfs.tasksMu.Unlock()
+	if !ok {
+		return TaskInfo{}, filesystem.NewNotFoundError("task", fmt.Sprintf("%d", id))
+	}
+	return t.snapshot(), nil
+}
+
+// CancelTask asks a running task to stop; the task reports cancelled once its
+// plugin returns
+func (mfs *MountableFS) CancelTask(id int64) error {
+	mfs.tasksMu.Lock()
+	t, ok := mfs.tasks[id]
+	mfs.tasksMu.Unlock()
+	if !ok {
+		return filesystem.NewNotFoundError("task", fmt.Sprintf("%d", id))
+	}
+	t.cancel()
+	return nil
+}
@@ -0,0 +1,218 @@
+package mountablefs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/api"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/memfs"
+)
+
+// taskPlugin is a memfs with a "count" task that reports progress and a
+// "block" task that runs until cancelled
+type taskPlugin struct {
+	*memfs.MemFSPlugin
+}
+
+func (p *taskPlugin) Tasks() []string {
+	return []string{"count", "block"}
+}
+
+func (p *taskPlugin) RunTask(ctx context.Context, task string, args map[string]string, progress plugin.TaskProgress) error {
+	switch task {
+	case "count":
+		for i := int64(1); i <= 3; i++ {
+			progress(i, 3, "counting "+args["path"])
+		}
+		return nil
+	case "block":
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return errors.New("unknown task")
+}
+
+func waitForTask(t *testing.T, mfs *MountableFS, id int64) TaskInfo {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		info, err := mfs.GetTask(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Status != TaskStatusRunning {
+			return info
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("task %d did not finish", id)
+	return TaskInfo{}
+}
+
+func newTaskFS(t *testing.T) *MountableFS {
+	t.Helper()
+	mfs := NewMountableFS(api.PoolConfig{})
+	p := &taskPlugin{MemFSPlugin: memfs.NewMemFSPlugin()}
+	if err := p.Initialize(map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.Mount("/jobs", p); err != nil {
+		t.Fatal(err)
+	}
+	return mfs
+}
+
+func TestTaskRunsToCompletion(t *testing.T) {
+	mfs := newTaskFS(t)
+
+	available := mfs.AvailableTasks()
+	if len(available) != 1 || available[0].Mount != "/jobs" || len(available[0].Tasks) != 2 {
+		t.Fatalf("unexpected available tasks: %+v", available)
+	}
+
+	info, err := mfs.StartTask("/jobs/docs", "count", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Args["path"] != "/docs" {
+		t.Errorf("expected path arg /docs, got %q", info.Args["path"])
+	}
+
+	info = waitForTask(t, mfs, info.ID)
+	if info.Status != TaskStatusSucceeded {
+		t.Fatalf("expected succeeded, got %s (%s)", info.Status, info.Error)
+	}
+	if info.Done != 3 || info.Total != 3 || info.Message != "counting /docs" {
+		t.Errorf("unexpected progress: %+v", info)
+	}
+	if info.FinishedAt == nil {
+		t.Error("expected finished_at to be set")
+	}
+	if tasks := mfs.ListTasks(); len(tasks) != 1 || tasks[0].ID != info.ID {
+		t.Errorf("unexpected task list: %+v", tasks)
+	}
+}
+
+func TestTaskCancel(t *testing.T) {
+	mfs := newTaskFS(t)
+
+	info, err := mfs.StartTask("/jobs", "block", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.CancelTask(info.ID); err != nil {
+		t.Fatal(err)
+	}
+	if info = waitForTask(t, mfs, info.ID); info.Status != TaskStatusCancelled {
+		t.Fatalf("expected cancelled, got %s", info.Status)
+	}
+
+	if err := mfs.CancelTask(999); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for unknown task id, got %v", err)
+	}
+}
+
+func TestStartTaskErrors(t *testing.T) {
+	mfs := newTaskFS(t)
+
+	if _, err := mfs.StartTask("/jobs", "nope", nil); !errors.Is(err, filesystem.ErrInvalidArgument) {
+		t.Errorf("expected ErrInvalidArgument for unknown task, got %v", err)
+	}
+
+	plain := memfs.NewMemFSPlugin()
+	if err := plain.Initialize(map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.Mount("/plain", plain); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mfs.StartTask("/plain", "count", nil); !errors.Is(err, filesystem.ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported for plugin without tasks, got %v", err)
+	}
+}
+
+func TestListAndDrainOpenHandles(t *testing.T) {
+	mfs := newTaskFS(t)
+	if err := mfs.Mkdir("/jobs/a", 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"/jobs/a/one", "/jobs/a/two", "/jobs/b"} {
+		if err := mfs.Create(p); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := mfs.OpenHandle(p, filesystem.O_RDWR, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	handles := mfs.ListOpenHandles()
+	if len(handles) != 3 {
+		t.Fatalf("expected 3 open handles, got %d", len(handles))
+	}
+	if handles[0].Path != "/jobs/a/one" || handles[0].Mount != "/jobs" {
+		t.Errorf("unexpected handle info: %+v", handles[0])
+	}
+
+	if n := mfs.CloseHandlesUnder("/jobs/a"); n != 2 {
+		t.Errorf("expected 2 handles closed under /jobs/a, got %d", n)
+	}
+	handles = mfs.ListOpenHandles()
+	if len(handles) != 1 || handles[0].Path != "/jobs/b" {
+		t.Fatalf("unexpected remaining handles: %+v", handles)
+	}
+	if _, err := mfs.GetHandle(handles[0].ID); err != nil {
+		t.Errorf("remaining handle should still be usable: %v", err)
+	}
+
+	if n := mfs.CloseHandlesUnder("/"); n != 1 {
+		t.Errorf("expected 1 handle closed under /, got %d", n)
+	}
+}
+
+func TestRevokeHandles(t *testing.T) {
+	mfs := newTaskFS(t)
+	var ids []int64
+	for _, open := range []struct{ client, path string }{
+		{"fuse@host1", "/jobs/one"},
+		{"fuse@host2", "/jobs/two"},
+		{"fuse@host1", "/jobs/three"},
+	} {
+		h, err := mfs.OpenHandleFor(open.client, open.path, filesystem.O_RDWR|filesystem.O_CREATE, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, h.ID())
+	}
+
+	if handles := mfs.FindOpenHandles(HandleFilter{Client: "fuse@host1"}); len(handles) != 2 || handles[1].Path != "/jobs/three" {
+		t.Errorf("unexpected handles of fuse@host1: %+v", handles)
+	}
+	revoked := mfs.RevokeHandles(HandleFilter{Path: "/jobs", Client: "fuse@host1"}, "backend maintenance")
+	if len(revoked) != 2 || revoked[0].ID != ids[0] || revoked[1].ID != ids[2] {
+		t.Fatalf("unexpected revoked handles: %+v", revoked)
+	}
+
+	_, err := mfs.GetHandle(ids[0])
+	if !errors.Is(err, filesystem.ErrRevoked) || !strings.Contains(err.Error(), "backend maintenance") {
+		t.Errorf("expected the revoked handle to fail with ErrRevoked, got %v", err)
+	}
+	if _, err := mfs.RenewHandle(ids[2], 0); !errors.Is(err, filesystem.ErrRevoked) {
+		t.Errorf("expected renewing a revoked handle to fail with ErrRevoked, got %v", err)
+	}
+	if _, err := mfs.GetHandle(ids[1]); err != nil {
+		t.Errorf("handle of another client should still be usable: %v", err)
+	}
+
+	// Closing a revoked handle succeeds, after which it is forgotten
+	if err := mfs.CloseHandle(ids[0]); err != nil {
+		t.Errorf("closing a revoked handle failed: %v", err)
+	}
+	if _, err := mfs.GetHandle(ids[0]); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("expected ErrNotFound once closed, got %v", err)
+	}
+}
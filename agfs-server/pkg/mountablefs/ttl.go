@@ -0,0 +1,336 @@
+package mountablefs
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	log "github.com/sirupsen/logrus"
+)
+
+// Mount config keys of the TTL policy of a mount (see TakeTTLPolicy)
+const (
+	TTLKey      = "ttl"
+	TTLDirsKey  = "ttl_dirs"
+	TTLTrashKey = "ttl_trash"
+)
+
+// TTLFile is the name of a file setting the TTL of the directory it is in and
+// of its subdirectories (e.g. "24h"; "0" exempts them), which lets clients
+// set policies at runtime on mounts that have a TTL policy
+const TTLFile = ".ttl"
+
+// TrashDir is the directory of a mount that expired files are moved to when
+// its TTL policy soft-deletes them
+const TrashDir = "/.trash"
+
+// DefaultTTLSweepInterval is how often expired files are swept by default
+const DefaultTTLSweepInterval = time.Minute
+
+// TTLPolicy makes the files of a mount expire some time after their last
+// modification, e.g. for scratch areas, queue archives or agent outputs.
+// Directories are not removed, only the files in them, and read-only files
+// (such as plugin READMEs) never expire.
+type TTLPolicy struct {
+	// TTL is how long files live; 0 if they do not expire unless a
+	// directory policy says so
+	TTL time.Duration
+	// Dirs overrides TTL for directories of the mount (relative to it) and
+	// their subdirectories; TTLFile files in turn override them
+	Dirs map[string]time.Duration
+	// Trash soft-deletes expired files: they are moved to TrashDir, from
+	// where they can be restored until they are removed after Trash. If 0,
+	// expired files are removed right away.
+	Trash time.Duration
+}
+
+// parseTTL parses a TTL config value, a duration such as "90m" or "7d"
+func parseTTL(key string, value interface{}) (time.Duration, error) {
+	s, ok := value.(string)
+	if !ok {
+		return 0, fmt.Errorf("%s must be a duration string (e.g. \"24h\")", key)
+	}
+	s = strings.TrimSpace(s)
+	var d time.Duration
+	var err error
+	if days, found := strings.CutSuffix(s, "d"); found {
+		d, err = time.ParseDuration(days + "h")
+		d *= 24
+	} else {
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%s: invalid duration %q", key, s)
+	}
+	return d, nil
+}
+
+// TakeTTLPolicy takes the TTL policy (ttl, ttl_dirs, ttl_trash) out of a
+// mount config, returning it (nil if the mount has none) and the config
+// without its keys
+func TakeTTLPolicy(cfg map[string]interface{}) (*TTLPolicy, map[string]interface{}, error) {
+	_, hasTTL := cfg[TTLKey]
+	_, hasDirs := cfg[TTLDirsKey]
+	_, hasTrash := cfg[TTLTrashKey]
+	if !hasTTL && !hasDirs && !hasTrash {
+		return nil, cfg, nil
+	}
+
+	policy := &TTLPolicy{}
+	var err error
+	if hasTTL {
+		if policy.TTL, err = parseTTL(TTLKey, cfg[TTLKey]); err != nil {
+			return nil, nil, err
+		}
+	}
+	if hasTrash {
+		if policy.Trash, err = parseTTL(TTLTrashKey, cfg[TTLTrashKey]); err != nil {
+			return nil, nil, err
+		}
+	}
+	if hasDirs {
+		dirs, ok := cfg[TTLDirsKey].(map[string]interface{})
+		if !ok {
+			return nil, nil, fmt.Errorf("%s must be a map of directories to durations", TTLDirsKey)
+		}
+		policy.Dirs = make(map[string]time.Duration, len(dirs))
+		for dir, value := range dirs {
+			d, err := parseTTL(TTLDirsKey+"."+dir, value)
+			if err != nil {
+				return nil, nil, err
+			}
+			policy.Dirs[filesystem.NormalizePath(dir)] = d
+		}
+	}
+
+	rest := make(map[string]interface{}, len(cfg))
+	for k, v := range cfg {
+		if k != TTLKey && k != TTLDirsKey && k != TTLTrashKey {
+			rest[k] = v
+		}
+	}
+	return policy, rest, nil
+}
+
+// SetTTLPolicy sets the TTL policy of the mount at a path; nil removes it
+func (mfs *MountableFS) SetTTLPolicy(mountPath string, policy *TTLPolicy) error {
+	mountPath = filesystem.NormalizePath(mountPath)
+	mount, _, found := mfs.findMount(mountPath)
+	if !found || mount.Path != mountPath {
+		return filesystem.NewNotFoundError("mount", mountPath)
+	}
+	mount.ttl.Store(policy)
+	return nil
+}
+
+// StartTTLSweeper sweeps the expired files of the mounts with a TTL policy at
+// the given interval, until StopTTLSweeper is called
+func (mfs *MountableFS) StartTTLSweeper(interval time.Duration) {
+	mfs.ttlMu.Lock()
+	defer mfs.ttlMu.Unlock()
+
+	if mfs.ttlStop != nil || interval <= 0 {
+		return
+	}
+	stop := make(chan struct{})
+	mfs.ttlStop = stop
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				mfs.SweepExpired()
+			}
+		}
+	}()
+	log.Infof("TTL sweeper enabled (interval: %v)", interval)
+}
+
+// StopTTLSweeper stops the sweeping started by StartTTLSweeper
+func (mfs *MountableFS) StopTTLSweeper() {
+	mfs.ttlMu.Lock()
+	defer mfs.ttlMu.Unlock()
+
+	if mfs.ttlStop != nil {
+		close(mfs.ttlStop)
+		mfs.ttlStop = nil
+	}
+}
+
+// SweepExpired expires the files of the mounts with a TTL policy whose TTL
+// has passed, and removes the soft-deleted files whose grace period has
+// passed, returning how many files it expired and removed from the trash
+func (mfs *MountableFS) SweepExpired() (expired, purged int) {
+	return mfs.sweepExpired(time.Now())
+}
+
+func (mfs *MountableFS) sweepExpired(now time.Time) (expired, purged int) {
+	mfs.sweepMu.Lock()
+	defer mfs.sweepMu.Unlock()
+
+	for _, mount := range mfs.GetMounts() {
+		policy := mount.ttl.Load()
+		if policy == nil {
+			continue
+		}
+		if st := mount.InitStatus(); st != nil && st.State != InitStateReady {
+			continue
+		}
+		s := &ttlSweep{mount: mount, fs: mount.fileSystem(), policy: policy, now: now}
+		if policy.Trash > 0 && mount.trashed == nil {
+			mount.trashed = make(map[string]time.Time)
+		}
+		s.sweepDir("/", policy.TTL)
+		if policy.Trash > 0 {
+			s.purgeTrash()
+		}
+		if s.expired > 0 || s.purged > 0 {
+			log.Infof("TTL sweep of %s: %d file(s) expired, %d removed from trash", mount.Path, s.expired, s.purged)
+		}
+		expired += s.expired
+		purged += s.purged
+	}
+	return expired, purged
+}
+
+// ttlSweep is a sweep of the expired files of a mount
+type ttlSweep struct {
+	mount  *MountPoint
+	fs     filesystem.FileSystem
+	policy *TTLPolicy
+	now    time.Time
+
+	expired int
+	purged  int
+}
+
+// sweepDir expires the files of a directory and of its subdirectories,
+// whose TTL is ttl unless they have their own policy
+func (s *ttlSweep) sweepDir(dir string, ttl time.Duration) {
+	if d, ok := s.policy.Dirs[dir]; ok {
+		ttl = d
+	}
+	infos, err := s.fs.ReadDir(dir)
+	if err != nil {
+		log.Warnf("TTL sweep of %s: %s: %v", s.mount.Path, dir, err)
+		return
+	}
+	for _, info := range infos {
+		if info.Name == TTLFile && !info.IsDir {
+			if d, err := s.readTTLFile(path.Join(dir, TTLFile)); err != nil {
+				log.Warnf("TTL sweep of %s: %v", s.mount.Path, err)
+			} else {
+				ttl = d
+			}
+		}
+	}
+
+	for _, info := range infos {
+		child := path.Join(dir, info.Name)
+		switch {
+		case info.Name == TTLFile:
+		case child == TrashDir && s.policy.Trash > 0:
+		case info.IsDir:
+			s.sweepDir(child, ttl)
+		case info.Mode&0222 == 0:
+		case ttl > 0 && s.now.Sub(info.ModTime) > ttl:
+			if err := s.expire(child); err != nil {
+				log.Warnf("TTL sweep of %s: failed to expire %s: %v", s.mount.Path, child, err)
+			} else {
+				s.expired++
+			}
+		}
+	}
+}
+
+// readTTLFile reads the TTL a TTLFile sets
+func (s *ttlSweep) readTTLFile(p string) (time.Duration, error) {
+	data, err := s.fs.Read(p, 0, -1)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	return parseTTL(p, string(data))
+}
+
+// expire removes an expired file, or moves it to the trash if the policy
+// soft-deletes files
+func (s *ttlSweep) expire(p string) error {
+	if s.policy.Trash <= 0 {
+		return s.fs.Remove(p)
+	}
+
+	target := path.Join(TrashDir, p)
+	dir := "/"
+	for _, name := range strings.Split(strings.TrimPrefix(path.Dir(target), "/"), "/") {
+		dir = path.Join(dir, name)
+		if info, err := s.fs.Stat(dir); err == nil && info.IsDir {
+			continue
+		}
+		if err := s.fs.Mkdir(dir, 0755); err != nil {
+			return err
+		}
+	}
+	// An earlier version of the file in the trash is replaced
+	if _, err := s.fs.Stat(target); err == nil {
+		if err := s.fs.Remove(target); err != nil {
+			return err
+		}
+	}
+	if err := s.fs.Rename(p, target); err != nil {
+		return err
+	}
+	s.mount.trashed[target] = s.now
+	return nil
+}
+
+// purgeTrash removes the files that have been in the trash for longer than
+// the grace period of the policy. Files found in the trash that this server
+// did not move there (e.g. before a restart) are given a full grace period.
+func (s *ttlSweep) purgeTrash() {
+	seen := make(map[string]bool, len(s.mount.trashed))
+	s.purgeTrashDir(TrashDir, seen)
+	// Forget the files restored from the trash
+	for p := range s.mount.trashed {
+		if !seen[p] {
+			delete(s.mount.trashed, p)
+		}
+	}
+}
+
+func (s *ttlSweep) purgeTrashDir(dir string, seen map[string]bool) {
+	infos, err := s.fs.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, info := range infos {
+		child := path.Join(dir, info.Name)
+		if info.IsDir {
+			s.purgeTrashDir(child, seen)
+			continue
+		}
+		deleted, ok := s.mount.trashed[child]
+		if !ok {
+			s.mount.trashed[child] = s.now
+			seen[child] = true
+			continue
+		}
+		if s.now.Sub(deleted) <= s.policy.Trash {
+			seen[child] = true
+			continue
+		}
+		if err := s.fs.Remove(child); err != nil {
+			log.Warnf("TTL sweep of %s: failed to remove %s from trash: %v", s.mount.Path, child, err)
+			seen[child] = true
+			continue
+		}
+		s.purged++
+	}
+}
@@ -0,0 +1,127 @@
+package mountablefs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/api"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/memfs"
+)
+
+func TestTakeTTLPolicy(t *testing.T) {
+	policy, rest, err := TakeTTLPolicy(map[string]interface{}{
+		"ttl":       "1h",
+		"ttl_dirs":  map[string]interface{}{"archive/": "7d", "/keep": "0"},
+		"ttl_trash": "30m",
+		"other":     "x",
+	})
+	if err != nil {
+		t.Fatalf("TakeTTLPolicy failed: %v", err)
+	}
+	if policy.TTL != time.Hour || policy.Trash != 30*time.Minute ||
+		policy.Dirs["/archive"] != 7*24*time.Hour || policy.Dirs["/keep"] != 0 || len(policy.Dirs) != 2 {
+		t.Errorf("unexpected policy %+v", policy)
+	}
+	if len(rest) != 1 || rest["other"] != "x" {
+		t.Errorf("unexpected rest %v", rest)
+	}
+
+	if policy, _, err := TakeTTLPolicy(map[string]interface{}{"other": "x"}); policy != nil || err != nil {
+		t.Errorf("expected no policy, got %+v, %v", policy, err)
+	}
+	for _, cfg := range []map[string]interface{}{
+		{"ttl": 60},
+		{"ttl": "-1h"},
+		{"ttl_dirs": "1h"},
+		{"ttl_dirs": map[string]interface{}{"/a": "soon"}},
+	} {
+		if _, _, err := TakeTTLPolicy(cfg); err == nil {
+			t.Errorf("expected %v to be rejected", cfg)
+		}
+	}
+}
+
+func TestSweepExpired(t *testing.T) {
+	mfs := NewMountableFS(api.PoolConfig{})
+	mfs.RegisterPluginFactory("memfs", func() plugin.ServicePlugin { return memfs.NewMemFSPlugin() })
+	if err := mfs.MountPlugin("memfs", "/scratch", map[string]interface{}{
+		"ttl":      "1h",
+		"ttl_dirs": map[string]interface{}{"/archive": "48h"},
+	}); err != nil {
+		t.Fatalf("MountPlugin failed: %v", err)
+	}
+	for _, dir := range []string{"/scratch/archive", "/scratch/keep"} {
+		if err := mfs.Mkdir(dir, 0755); err != nil {
+			t.Fatalf("Mkdir failed: %v", err)
+		}
+	}
+	for _, p := range []string{"/scratch/tmp.txt", "/scratch/archive/old.log", "/scratch/keep/notes.md"} {
+		if _, err := mfs.Write(p, []byte("data"), 0, filesystem.WriteFlagCreate); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if _, err := mfs.Write("/scratch/keep/"+TTLFile, []byte("0\n"), 0, filesystem.WriteFlagCreate); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	if expired, _ := mfs.sweepExpired(time.Now()); expired != 0 {
+		t.Errorf("expected nothing to expire yet, got %d", expired)
+	}
+	if expired, _ := mfs.sweepExpired(time.Now().Add(2 * time.Hour)); expired != 1 {
+		t.Errorf("expected 1 file to expire after 2h, got %d", expired)
+	}
+	if _, err := mfs.Stat("/scratch/tmp.txt"); err == nil {
+		t.Error("expected /scratch/tmp.txt to be removed")
+	}
+	if expired, _ := mfs.sweepExpired(time.Now().Add(72 * time.Hour)); expired != 1 {
+		t.Errorf("expected the archive to expire after 72h, got %d", expired)
+	}
+	for _, p := range []string{"/scratch/keep/notes.md", "/scratch/keep/" + TTLFile, "/scratch/archive"} {
+		if _, err := mfs.Stat(p); err != nil {
+			t.Errorf("expected %s to be kept: %v", p, err)
+		}
+	}
+}
+
+func TestSweepExpiredTrash(t *testing.T) {
+	mfs := NewMountableFS(api.PoolConfig{})
+	p := memfs.NewMemFSPlugin()
+	if err := p.Initialize(map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.Mount("/tmp", p); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.SetTTLPolicy("/tmp", &TTLPolicy{TTL: time.Hour, Trash: 24 * time.Hour}); err != nil {
+		t.Fatalf("SetTTLPolicy failed: %v", err)
+	}
+	if err := mfs.SetTTLPolicy("/nowhere", &TTLPolicy{}); err == nil {
+		t.Error("expected setting the policy of a missing mount to fail")
+	}
+	if err := mfs.Mkdir("/tmp/out", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mfs.Write("/tmp/out/result.json", []byte("{}"), 0, filesystem.WriteFlagCreate); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	if expired, purged := mfs.sweepExpired(now.Add(2 * time.Hour)); expired != 1 || purged != 0 {
+		t.Errorf("got %d expired, %d purged; want 1, 0", expired, purged)
+	}
+	if got := readAll(t, mfs, "/tmp/.trash/out/result.json"); got != "{}" {
+		t.Errorf("trashed file content = %q", got)
+	}
+	// The trash is not itself expired, only purged after the grace period
+	if expired, purged := mfs.sweepExpired(now.Add(12 * time.Hour)); expired != 0 || purged != 0 {
+		t.Errorf("got %d expired, %d purged within the grace period", expired, purged)
+	}
+	if _, purged := mfs.sweepExpired(now.Add(27 * time.Hour)); purged != 1 {
+		t.Errorf("expected the trashed file to be purged, got %d", purged)
+	}
+	if _, err := mfs.Stat("/tmp/.trash/out/result.json"); err == nil {
+		t.Error("expected the trashed file to be removed")
+	}
+}
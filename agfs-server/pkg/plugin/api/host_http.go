@@ -33,8 +33,9 @@ type HTTPResponse struct {
 // Parameters:
 //   - params[0]: pointer to JSON-encoded HTTPRequest
 //
+// Requests the sandbox does not allow fail with an error in the response.
 // Returns: packed u64 (lower 32 bits = response pointer, upper 32 bits = response size)
-func HostHTTPRequest(ctx context.Context, mod wazeroapi.Module, params []uint64) []uint64 {
+func HostHTTPRequest(ctx context.Context, mod wazeroapi.Module, params []uint64, sandbox SandboxConfig) []uint64 {
 	requestPtr := uint32(params[0])
 
 	// Read request JSON from memory
@@ -61,14 +62,20 @@ func HostHTTPRequest(ctx context.Context, mod wazeroapi.Module, params []uint64)
 		req.Method = "GET"
 	}
 
+	if err := sandbox.CheckURL(req.URL); err != nil {
+		log.Warnf("host_http_request: denied request to %s: %v", req.URL, err)
+		resp := HTTPResponse{
+			Error: "request denied: " + err.Error(),
+		}
+		return packHTTPResponse(mod, &resp)
+	}
+
 	// Create HTTP client with timeout
 	timeout := time.Duration(req.Timeout) * time.Second
 	if timeout == 0 {
 		timeout = 30 * time.Second // default 30s timeout
 	}
-	client := &http.Client{
-		Timeout: timeout,
-	}
+	client := sandbox.HTTPClient(timeout)
 
 	// Create HTTP request
 	var bodyReader io.Reader
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+// SandboxConfig narrows what WASM plugins can reach through the host functions.
+// The zero value leaves them unrestricted, as they were before sandboxing.
+type SandboxConfig struct {
+	FSRoot         string   // If set, host_fs_* calls only see this subtree, as their "/"
+	DisableHTTP    bool     // host_http_request always fails
+	HTTPAllowHosts []string // If set, host_http_request may only reach these hosts ("*.example.com" matches subdomains)
+	MemoryLimitMB  int      // Memory limit of each instance in MiB (0 = wazero default, 4 GiB)
+}
+
+// MemoryLimitPages returns the memory limit in 64 KiB WASM pages, 0 if unlimited
+func (s SandboxConfig) MemoryLimitPages() uint32 {
+	if s.MemoryLimitMB <= 0 {
+		return 0
+	}
+	return uint32(s.MemoryLimitMB) * 16
+}
+
+// CheckURL returns an error if host_http_request may not request a URL
+func (s SandboxConfig) CheckURL(rawURL string) error {
+	if s.DisableHTTP {
+		return fmt.Errorf("http requests are disabled for WASM plugins")
+	}
+	if len(s.HTTPAllowHosts) == 0 {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("scheme %q is not allowed", u.Scheme)
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range s.HTTPAllowHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || (strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:])) {
+			return nil
+		}
+	}
+	return fmt.Errorf("host %q is not allowed", host)
+}
+
+// HTTPClient returns the client of host_http_request. Redirects are checked
+// against the sandbox like the first request, so an allowed host cannot
+// redirect a plugin to one that is not.
+func (s SandboxConfig) HTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			// Go's default policy
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after 10 redirects")
+			}
+			if err := s.CheckURL(req.URL.String()); err != nil {
+				return fmt.Errorf("redirect denied: %w", err)
+			}
+			return nil
+		},
+	}
+}
+
+// HostFS returns the file system the host functions of a plugin use: fs
+// confined to FSRoot, if set
+func (s SandboxConfig) HostFS(fs filesystem.FileSystem) filesystem.FileSystem {
+	if fs == nil || s.FSRoot == "" || filesystem.NormalizePath(s.FSRoot) == "/" {
+		return fs
+	}
+	return &rootedFS{fs: fs, root: filesystem.NormalizePath(s.FSRoot)}
+}
+
+// rootedFS serves a subtree of a file system as its root. Paths are cleaned
+// before being joined to the root, so ".." cannot escape it.
+type rootedFS struct {
+	fs   filesystem.FileSystem
+	root string
+}
+
+func (r *rootedFS) path(p string) string {
+	return path.Join(r.root, path.Clean("/"+p))
+}
+
+func (r *rootedFS) Create(p string) error {
+	return r.fs.Create(r.path(p))
+}
+
+func (r *rootedFS) Mkdir(p string, perm uint32) error {
+	return r.fs.Mkdir(r.path(p), perm)
+}
+
+func (r *rootedFS) Remove(p string) error {
+	return r.fs.Remove(r.path(p))
+}
+
+func (r *rootedFS) RemoveAll(p string) error {
+	if r.path(p) == r.root {
+		return filesystem.NewPermissionDeniedError("remove", p, "cannot remove the sandbox root")
+	}
+	return r.fs.RemoveAll(r.path(p))
+}
+
+func (r *rootedFS) Read(p string, offset int64, size int64) ([]byte, error) {
+	return r.fs.Read(r.path(p), offset, size)
+}
+
+func (r *rootedFS) Write(p string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	return r.fs.Write(r.path(p), data, offset, flags)
+}
+
+func (r *rootedFS) ReadDir(p string) ([]filesystem.FileInfo, error) {
+	return r.fs.ReadDir(r.path(p))
+}
+
+func (r *rootedFS) Stat(p string) (*filesystem.FileInfo, error) {
+	return r.fs.Stat(r.path(p))
+}
+
+func (r *rootedFS) Rename(oldPath, newPath string) error {
+	return r.fs.Rename(r.path(oldPath), r.path(newPath))
+}
+
+func (r *rootedFS) Chmod(p string, mode uint32) error {
+	return r.fs.Chmod(r.path(p), mode)
+}
+
+func (r *rootedFS) Open(p string) (io.ReadCloser, error) {
+	return r.fs.Open(r.path(p))
+}
+
+func (r *rootedFS) OpenWrite(p string) (io.WriteCloser, error) {
+	return r.fs.OpenWrite(r.path(p))
+}
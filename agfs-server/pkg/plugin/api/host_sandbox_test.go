@@ -0,0 +1,122 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/memfs"
+)
+
+func TestSandboxCheckURL(t *testing.T) {
+	if err := (SandboxConfig{}).CheckURL("http://anywhere.test/x"); err != nil {
+		t.Errorf("unrestricted sandbox denied a request: %v", err)
+	}
+	if err := (SandboxConfig{DisableHTTP: true}).CheckURL("http://anywhere.test/x"); err == nil {
+		t.Error("expected requests to be disabled")
+	}
+
+	sandbox := SandboxConfig{HTTPAllowHosts: []string{"api.example.com", "*.cdn.test"}}
+	for url, allowed := range map[string]bool{
+		"https://api.example.com/v1":    true,
+		"http://API.example.com:8080/":  true,
+		"https://img.cdn.test/a.png":    true,
+		"https://cdn.test/":             false,
+		"https://example.com/":          false,
+		"https://api.example.com.evil/": false,
+		"file:///etc/passwd":            false,
+		"ftp://api.example.com/file":    false,
+	} {
+		if err := sandbox.CheckURL(url); (err == nil) != allowed {
+			t.Errorf("CheckURL(%q) = %v, want allowed=%v", url, err, allowed)
+		}
+	}
+}
+
+func TestSandboxHTTPClientRedirects(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	defer internal.Close()
+	// 127.0.0.1 is allowed, localhost is not: both reach the test servers
+	target := strings.Replace(internal.URL, "127.0.0.1", "localhost", 1)
+	redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/self" {
+			http.Redirect(w, r, "/final", http.StatusFound)
+			return
+		}
+		if r.URL.Path == "/final" {
+			w.Write([]byte("allowed"))
+			return
+		}
+		http.Redirect(w, r, target, http.StatusFound)
+	}))
+	defer redirector.Close()
+
+	client := SandboxConfig{HTTPAllowHosts: []string{"127.0.0.1"}}.HTTPClient(5 * time.Second)
+	if _, err := client.Get(redirector.URL + "/open"); err == nil || !strings.Contains(err.Error(), "redirect denied") {
+		t.Errorf("expected the redirect to a host not allowed to be denied, got %v", err)
+	}
+	resp, err := client.Get(redirector.URL + "/self")
+	if err != nil {
+		t.Fatalf("redirect within allowed hosts failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("redirect within allowed hosts: status %d", resp.StatusCode)
+	}
+
+	// Unrestricted sandboxes follow redirects anywhere
+	resp, err = (SandboxConfig{}).HTTPClient(5 * time.Second).Get(redirector.URL + "/open")
+	if err != nil {
+		t.Fatalf("unrestricted redirect failed: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestSandboxHostFS(t *testing.T) {
+	fs := memfs.NewMemoryFS()
+	if err := fs.Mkdir("/plugins", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Mkdir("/plugins/data", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Write("/secret.txt", []byte("secret"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := (SandboxConfig{}).HostFS(fs); got != filesystem.FileSystem(fs) {
+		t.Error("expected the file system unchanged without FSRoot")
+	}
+	rooted := SandboxConfig{FSRoot: "/plugins/data/"}.HostFS(fs)
+
+	if _, err := rooted.Write("/out.txt", []byte("hello"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if data, _ := fs.Read("/plugins/data/out.txt", 0, -1); string(data) != "hello" {
+		t.Errorf("expected the write under the root, got %q", data)
+	}
+	if err := rooted.Rename("/out.txt", "/../../renamed.txt"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if _, err := fs.Stat("/plugins/data/renamed.txt"); err != nil {
+		t.Errorf("expected the rename to stay under the root: %v", err)
+	}
+
+	for _, path := range []string{"/secret.txt", "../secret.txt", "/../../secret.txt"} {
+		if data, err := rooted.Read(path, 0, -1); err == nil {
+			t.Errorf("Read(%q) escaped the root: %q", path, data)
+		}
+	}
+	if err := rooted.RemoveAll("/.."); !errors.Is(err, filesystem.ErrPermissionDenied) {
+		t.Errorf("expected removing the root to be denied, got %v", err)
+	}
+	if entries, err := rooted.ReadDir("/"); err != nil || len(entries) != 1 {
+		t.Errorf("ReadDir(/) = %v, %v", entries, err)
+	}
+}
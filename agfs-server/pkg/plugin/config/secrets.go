@@ -0,0 +1,222 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Prefixes of secret references in string config values
+//
+//	env:VAR                   - value of the environment variable VAR
+//	file:/path                - content of a file, without the trailing newline
+//	vault:mount/path/field    - field of a HashiCorp Vault KV secret
+//	literal:text              - text as is, for values that start with a prefix above
+const (
+	secretPrefixEnv     = "env:"
+	secretPrefixFile    = "file:"
+	secretPrefixVault   = "vault:"
+	secretPrefixLiteral = "literal:"
+)
+
+var vaultClient = &http.Client{Timeout: 10 * time.Second}
+
+// ResolveSecrets returns a copy of a plugin config with every secret reference
+// replaced by the secret it names
+// References are resolved in nested maps and lists too. The original config is
+// not modified, so it can be shown (e.g. in the mount list) without leaking
+// secrets. Errors name the config key but never the secret.
+func ResolveSecrets(cfg map[string]interface{}) (map[string]interface{}, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	resolved, err := resolveSecretsIn("", cfg)
+	if err != nil {
+		return nil, err
+	}
+	return resolved.(map[string]interface{}), nil
+}
+
+func resolveSecretsIn(key string, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		s, err := ResolveSecret(v)
+		if err != nil {
+			return nil, fmt.Errorf("config %s: %w", key, err)
+		}
+		return s, nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			r, err := resolveSecretsIn(joinKey(key, k), item)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = r
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			r, err := resolveSecretsIn(fmt.Sprintf("%s[%d]", key, i), item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = r
+		}
+		return out, nil
+	}
+	return value, nil
+}
+
+func joinKey(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+// IsSecretReference reports whether a config value refers to a secret
+// (env:, file: or vault:) instead of holding it
+func IsSecretReference(value string) bool {
+	return strings.HasPrefix(value, secretPrefixEnv) ||
+		strings.HasPrefix(value, secretPrefixFile) ||
+		strings.HasPrefix(value, secretPrefixVault)
+}
+
+// RedactedValue replaces secret values in configs shown by RedactSecrets
+const RedactedValue = "********"
+
+// RedactSecrets returns a copy of a plugin config with the values of the given
+// secret keys replaced by RedactedValue; secret references are kept, as they
+// do not reveal the secret
+func RedactSecrets(cfg map[string]interface{}, secretKeys []string) map[string]interface{} {
+	if cfg == nil || len(secretKeys) == 0 {
+		return cfg
+	}
+	out := make(map[string]interface{}, len(cfg))
+	for k, v := range cfg {
+		out[k] = v
+	}
+	for _, key := range secretKeys {
+		v, ok := out[key]
+		if !ok {
+			continue
+		}
+		if s, isString := v.(string); isString && (s == "" || IsSecretReference(s)) {
+			continue
+		}
+		out[key] = RedactedValue
+	}
+	return out
+}
+
+// ResolveSecret resolves a single config value; values that are not secret
+// references are returned unchanged
+func ResolveSecret(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, secretPrefixLiteral):
+		return strings.TrimPrefix(value, secretPrefixLiteral), nil
+
+	case strings.HasPrefix(value, secretPrefixEnv):
+		name := strings.TrimPrefix(value, secretPrefixEnv)
+		secret, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return secret, nil
+
+	case strings.HasPrefix(value, secretPrefixFile):
+		path := strings.TrimPrefix(value, secretPrefixFile)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+
+	case strings.HasPrefix(value, secretPrefixVault):
+		return readVaultSecret(strings.TrimPrefix(value, secretPrefixVault))
+	}
+	return value, nil
+}
+
+// readVaultSecret reads a field of a Vault KV secret, ref being mount/path/field
+// The server and token come from VAULT_ADDR and VAULT_TOKEN (and optionally
+// VAULT_NAMESPACE); the KV engine is version 2 unless VAULT_KV_VERSION=1.
+func readVaultSecret(ref string) (string, error) {
+	parts := strings.Split(strings.Trim(ref, "/"), "/")
+	if len(parts) < 3 {
+		return "", fmt.Errorf("invalid vault reference %q: expected vault:mount/path/field", ref)
+	}
+	mount, path, field := parts[0], strings.Join(parts[1:len(parts)-1], "/"), parts[len(parts)-1]
+
+	addr := strings.TrimSuffix(os.Getenv("VAULT_ADDR"), "/")
+	token := os.Getenv("VAULT_TOKEN")
+	if addr == "" || token == "" {
+		return "", fmt.Errorf("VAULT_ADDR and VAULT_TOKEN must be set to resolve vault references")
+	}
+	kvVersion := 2
+	if v := os.Getenv("VAULT_KV_VERSION"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || (n != 1 && n != 2) {
+			return "", fmt.Errorf("unsupported VAULT_KV_VERSION: %s (valid options: 1, 2)", v)
+		}
+		kvVersion = n
+	}
+
+	apiPath := "/v1/" + mount + "/" + path
+	if kvVersion == 2 {
+		apiPath = "/v1/" + mount + "/data/" + path
+	}
+	req, err := http.NewRequest("GET", addr+apiPath, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+
+	resp, err := vaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read vault response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("vault secret %s/%s: HTTP %d", mount, path, resp.StatusCode)
+	}
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return "", fmt.Errorf("failed to decode vault response: %w", err)
+	}
+	fields := secret.Data
+	if kvVersion == 2 {
+		fields, _ = secret.Data["data"].(map[string]interface{})
+	}
+
+	value, ok := fields[field]
+	if !ok {
+		return "", fmt.Errorf("vault secret %s/%s has no field %s", mount, path, field)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveSecrets(t *testing.T) {
+	t.Setenv("AGFS_TEST_API_KEY", "sk-123")
+	secretFile := filepath.Join(t.TempDir(), "dsn")
+	if err := os.WriteFile(secretFile, []byte("user:pass@tcp(db:4000)/app\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := map[string]interface{}{
+		"api_key": "env:AGFS_TEST_API_KEY",
+		"dsn":     "file:" + secretFile,
+		"prefix":  "literal:env:not-a-secret",
+		"plain":   "hello",
+		"port":    8080,
+		"nested":  map[string]interface{}{"token": "env:AGFS_TEST_API_KEY"},
+		"list":    []interface{}{"env:AGFS_TEST_API_KEY", 1},
+	}
+	resolved, err := ResolveSecrets(cfg)
+	if err != nil {
+		t.Fatalf("ResolveSecrets failed: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"api_key": "sk-123",
+		"dsn":     "user:pass@tcp(db:4000)/app",
+		"prefix":  "env:not-a-secret",
+		"plain":   "hello",
+	}
+	for k, v := range want {
+		if resolved[k] != v {
+			t.Errorf("%s: expected %v, got %v", k, v, resolved[k])
+		}
+	}
+	if resolved["port"] != 8080 {
+		t.Errorf("expected non-string values unchanged, got %v", resolved["port"])
+	}
+	if got := resolved["nested"].(map[string]interface{})["token"]; got != "sk-123" {
+		t.Errorf("expected nested reference resolved, got %v", got)
+	}
+	if got := resolved["list"].([]interface{})[0]; got != "sk-123" {
+		t.Errorf("expected list reference resolved, got %v", got)
+	}
+	if cfg["api_key"] != "env:AGFS_TEST_API_KEY" {
+		t.Errorf("expected the original config to keep its references")
+	}
+}
+
+func TestResolveSecretsErrors(t *testing.T) {
+	_, err := ResolveSecrets(map[string]interface{}{
+		"nested": map[string]interface{}{"token": "env:AGFS_TEST_UNSET_VARIABLE"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "nested.token") || !strings.Contains(err.Error(), "AGFS_TEST_UNSET_VARIABLE") {
+		t.Errorf("expected an error naming the key and variable, got %v", err)
+	}
+
+	if _, err := ResolveSecret("file:/nonexistent/secret"); err == nil {
+		t.Error("expected an error for a missing secret file")
+	}
+	if _, err := ResolveSecret("vault:secret"); err == nil {
+		t.Error("expected an error for an incomplete vault reference")
+	}
+}
+
+func TestResolveVaultSecret(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/app/db":
+			w.Write([]byte(`{"data": {"data": {"password": "s3cret", "port": 4000}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	t.Setenv("VAULT_ADDR", srv.URL)
+	t.Setenv("VAULT_TOKEN", "root")
+
+	if got, err := ResolveSecret("vault:secret/app/db/password"); err != nil || got != "s3cret" {
+		t.Errorf("expected s3cret, got %q, %v", got, err)
+	}
+	if got, err := ResolveSecret("vault:secret/app/db/port"); err != nil || got != "4000" {
+		t.Errorf("expected 4000, got %q, %v", got, err)
+	}
+	if _, err := ResolveSecret("vault:secret/app/db/missing"); err == nil {
+		t.Error("expected an error for a missing field")
+	}
+	if _, err := ResolveSecret("vault:secret/app/other/password"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected a 404 error, got %v", err)
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+	cfg := map[string]interface{}{
+		"password": "hunter2",
+		"token":    "env:API_TOKEN",
+		"host":     "db.local",
+		"port":     4000,
+	}
+	redacted := RedactSecrets(cfg, []string{"password", "token", "port", "missing"})
+
+	if redacted["password"] != RedactedValue || redacted["port"] != RedactedValue {
+		t.Errorf("expected secret values redacted, got %v", redacted)
+	}
+	if redacted["token"] != "env:API_TOKEN" || redacted["host"] != "db.local" {
+		t.Errorf("expected references and other values kept, got %v", redacted)
+	}
+	if _, ok := redacted["missing"]; ok {
+		t.Errorf("expected no key added for an unset secret")
+	}
+	if cfg["password"] != "hunter2" {
+		t.Errorf("expected the original config unchanged")
+	}
+}
@@ -0,0 +1,201 @@
+// Package ctl implements the control directory of mounts: a .ctl directory at
+// the root of every mount with machine-readable JSON files to inspect and
+// operate the mount (stats.json, config.json, health.json, tasks.json), so
+// that every plugin exposes its operations the same way instead of with ad-hoc
+// magic files.
+//
+// The server serves the standard files of every mount; plugins add their own
+// by implementing Provider.
+package ctl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+)
+
+// DirName is the name of the control directory at the root of a mount
+const DirName = ".ctl"
+
+// MetaType is the metadata type of the control directory and its files
+const MetaType = "ctl"
+
+// File is a JSON file of a control directory
+type File struct {
+	// Name is the file name, e.g. "stats.json"
+	Name string
+	// Read returns the value of the file, marshalled as indented JSON
+	Read func() (interface{}, error)
+	// Write applies a JSON document written to the file; nil if the file
+	// is read-only
+	Write func(data []byte) error
+}
+
+// Provider is implemented by plugins with control files of their own, listed
+// in the control directory of their mounts next to the standard ones
+type Provider interface {
+	// ControlFiles returns the plugin's control files; names taken by
+	// standard files are ignored
+	ControlFiles() []File
+}
+
+// Split checks if a path relative to a mount is in its control directory,
+// returning the file name ("" for the directory itself)
+func Split(relPath string) (name string, ok bool) {
+	rest, found := strings.CutPrefix(filesystem.NormalizePath(relPath), "/"+DirName)
+	switch {
+	case !found:
+		return "", false
+	case rest == "":
+		return "", true
+	case strings.Count(rest, "/") == 1 && len(rest) > 1:
+		return rest[1:], true
+	}
+	return "", false
+}
+
+// Dir is the control directory of a mount
+type Dir struct {
+	files map[string]File
+	names []string
+}
+
+// NewDir returns a control directory with files; of files with the same
+// name, the first one wins
+func NewDir(files ...File) *Dir {
+	d := &Dir{files: make(map[string]File, len(files))}
+	for _, f := range files {
+		if _, dup := d.files[f.Name]; dup || f.Name == "" || f.Read == nil {
+			continue
+		}
+		d.files[f.Name] = f
+		d.names = append(d.names, f.Name)
+	}
+	sort.Strings(d.names)
+	return d
+}
+
+func (d *Dir) file(op, path, name string) (File, error) {
+	f, ok := d.files[name]
+	if !ok {
+		return File{}, filesystem.NewNotFoundError(op, path)
+	}
+	return f, nil
+}
+
+// data returns the content of a file
+func (d *Dir) data(path string, f File) ([]byte, error) {
+	value, err := f.Read()
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return append(data, '\n'), nil
+}
+
+// Read reads a file of the directory; path is the full path, for errors
+func (d *Dir) Read(path, name string, offset, size int64) ([]byte, error) {
+	if name == "" {
+		return nil, fmt.Errorf("is a directory: %s", path)
+	}
+	f, err := d.file("read", path, name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.data(path, f)
+	if err != nil {
+		return nil, err
+	}
+	return plugin.ApplyRangeRead(data, offset, size)
+}
+
+// Write writes a whole JSON document to a file of the directory
+func (d *Dir) Write(path, name string, data []byte, offset int64) (int64, error) {
+	if name == "" {
+		return 0, fmt.Errorf("is a directory: %s", path)
+	}
+	f, err := d.file("write", path, name)
+	if err != nil {
+		return 0, err
+	}
+	if f.Write == nil {
+		return 0, filesystem.NewPermissionDeniedError("write", path, "read-only control file")
+	}
+	if offset > 0 {
+		return 0, filesystem.NewInvalidArgumentError("write", path, "control files must be written whole, at offset 0")
+	}
+	if err := f.Write(bytes.TrimSpace(data)); err != nil {
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
+
+// ReadDir lists the files of the directory
+func (d *Dir) ReadDir() []filesystem.FileInfo {
+	now := time.Now()
+	infos := make([]filesystem.FileInfo, 0, len(d.names))
+	for _, name := range d.names {
+		// Sizes are not known without generating the files
+		infos = append(infos, fileInfo(name, 0, d.files[name], now))
+	}
+	return infos
+}
+
+// Stat returns the file info of the directory or of a file in it
+func (d *Dir) Stat(path, name string) (*filesystem.FileInfo, error) {
+	if name == "" {
+		return &filesystem.FileInfo{
+			Name:    DirName,
+			Size:    0,
+			Mode:    0755,
+			ModTime: time.Now(),
+			IsDir:   true,
+			Meta:    filesystem.MetaData{Type: MetaType},
+		}, nil
+	}
+	f, err := d.file("stat", path, name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.data(path, f)
+	if err != nil {
+		return nil, err
+	}
+	info := fileInfo(name, int64(len(data)), f, time.Now())
+	return &info, nil
+}
+
+func fileInfo(name string, size int64, f File, now time.Time) filesystem.FileInfo {
+	mode := uint32(0444)
+	if f.Write != nil {
+		mode = 0644
+	}
+	return filesystem.FileInfo{
+		Name:    name,
+		Size:    size,
+		Mode:    mode,
+		ModTime: now,
+		IsDir:   false,
+		Meta:    filesystem.MetaData{Type: MetaType},
+	}
+}
+
+// Decode decodes a JSON document written to a control file into v, with
+// errors reported as invalid arguments
+func Decode(path string, data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return filesystem.NewInvalidArgumentError("write", path, "invalid JSON: "+err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+package grpcplugin
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/hashicorp/go-hclog"
+	goplugin "github.com/hashicorp/go-plugin"
+	log "github.com/sirupsen/logrus"
+)
+
+// ProcessPlugin is a plugin running in a separate process
+// The process is started by Load. If it exits, the next call starts it again
+// and re-initializes it with the configuration of the last Initialize, so a
+// crash costs the plugin its in-memory state but not the mount.
+type ProcessPlugin struct {
+	path string
+	name string // Reported by the process at load time
+
+	mu     sync.Mutex
+	client *goplugin.Client
+	rpc    *rpcClient
+	config map[string]interface{} // Config of the last Initialize, nil before
+}
+
+// Load starts a plugin executable and returns the plugin it serves
+func Load(path string) (*ProcessPlugin, error) {
+	p := &ProcessPlugin{path: path}
+	rpc, err := p.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	var resp stringResponse
+	if err := rpc.call("Name", &empty{}, &resp); err != nil {
+		p.kill()
+		return nil, fmt.Errorf("failed to get plugin name: %w", err)
+	}
+	p.name = resp.Value
+	return p, nil
+}
+
+// start launches the plugin process; callers hold p.mu
+func (p *ProcessPlugin) start() error {
+	client := goplugin.NewClient(&goplugin.ClientConfig{
+		HandshakeConfig:  Handshake,
+		Plugins:          goplugin.PluginSet{pluginKey: &grpcPlugin{}},
+		Cmd:              exec.Command(p.path),
+		AllowedProtocols: []goplugin.Protocol{goplugin.ProtocolGRPC},
+		Logger: hclog.New(&hclog.LoggerOptions{
+			Name:   "plugin",
+			Output: log.StandardLogger().Writer(),
+			Level:  hclog.Info,
+		}),
+	})
+
+	protocol, err := client.Client()
+	if err != nil {
+		client.Kill()
+		return fmt.Errorf("failed to start plugin process %s: %w", p.path, err)
+	}
+	raw, err := protocol.Dispense(pluginKey)
+	if err != nil {
+		client.Kill()
+		return fmt.Errorf("failed to connect to plugin process %s: %w", p.path, err)
+	}
+
+	p.client = client
+	p.rpc = raw.(*rpcClient)
+	log.Infof("Started plugin process: %s (pid: %d)", p.path, client.ReattachConfig().Pid)
+	return nil
+}
+
+// conn returns the connection to the plugin process, restarting it if it exited
+func (p *ProcessPlugin) conn() (*rpcClient, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.client != nil && !p.client.Exited() {
+		return p.rpc, nil
+	}
+	if p.client != nil {
+		log.Warnf("Plugin process %s exited, restarting it", p.path)
+		p.client.Kill()
+		p.client = nil
+	}
+
+	if err := p.start(); err != nil {
+		return nil, err
+	}
+	if p.config != nil {
+		if err := p.rpc.call("Initialize", &configRequest{Config: p.config}, &empty{}); err != nil {
+			return nil, fmt.Errorf("failed to re-initialize restarted plugin %s: %w", p.name, err)
+		}
+	}
+	return p.rpc, nil
+}
+
+// call invokes a method of the plugin service
+func (p *ProcessPlugin) call(method string, req, resp interface{}) error {
+	rpc, err := p.conn()
+	if err != nil {
+		return err
+	}
+	return rpc.call(method, req, resp)
+}
+
+// kill stops the plugin process
+func (p *ProcessPlugin) kill() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.client != nil {
+		p.client.Kill()
+		p.client = nil
+		p.rpc = nil
+	}
+}
+
+// Path returns the plugin executable
+func (p *ProcessPlugin) Path() string {
+	return p.path
+}
+
+func (p *ProcessPlugin) Name() string {
+	return p.name
+}
+
+func (p *ProcessPlugin) Validate(config map[string]interface{}) error {
+	return p.call("Validate", &configRequest{Config: config}, &empty{})
+}
+
+func (p *ProcessPlugin) Initialize(config map[string]interface{}) error {
+	if err := p.call("Initialize", &configRequest{Config: config}, &empty{}); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.config = config
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *ProcessPlugin) GetFileSystem() filesystem.FileSystem {
+	return &processFS{plugin: p}
+}
+
+func (p *ProcessPlugin) GetReadme() string {
+	var resp stringResponse
+	if err := p.call("GetReadme", &empty{}, &resp); err != nil {
+		return fmt.Sprintf("%s: README unavailable: %v", p.name, err)
+	}
+	return resp.Value
+}
+
+func (p *ProcessPlugin) GetConfigParams() []plugin.ConfigParameter {
+	var resp configParamsResponse
+	if err := p.call("GetConfigParams", &empty{}, &resp); err != nil {
+		log.Warnf("Failed to get config parameters of plugin %s: %v", p.name, err)
+		return []plugin.ConfigParameter{}
+	}
+	return resp.Params
+}
+
+// HealthCheck asks the plugin process for its health
+// A process that exited is restarted by the check, so polling health also
+// brings a crashed plugin back without waiting for a file operation.
+func (p *ProcessPlugin) HealthCheck() error {
+	return p.call("HealthCheck", &empty{}, &empty{})
+}
+
+// Shutdown shuts the plugin down and stops its process
+// A later mount starts a new process.
+func (p *ProcessPlugin) Shutdown() error {
+	p.mu.Lock()
+	running := p.client != nil && !p.client.Exited()
+	rpc := p.rpc
+	p.config = nil
+	p.mu.Unlock()
+
+	var err error
+	if running {
+		err = rpc.call("Shutdown", &empty{}, &empty{})
+	}
+	p.kill()
+	return err
+}
+
+// processFS forwards file system calls to the plugin process
+type processFS struct {
+	plugin *ProcessPlugin
+}
+
+func (fs *processFS) Create(path string) error {
+	return fs.plugin.call("Create", &pathRequest{Path: path}, &empty{})
+}
+
+func (fs *processFS) Mkdir(path string, perm uint32) error {
+	return fs.plugin.call("Mkdir", &mkdirRequest{Path: path, Perm: perm}, &empty{})
+}
+
+func (fs *processFS) Remove(path string) error {
+	return fs.plugin.call("Remove", &pathRequest{Path: path}, &empty{})
+}
+
+func (fs *processFS) RemoveAll(path string) error {
+	return fs.plugin.call("RemoveAll", &pathRequest{Path: path}, &empty{})
+}
+
+func (fs *processFS) Read(path string, offset int64, size int64) ([]byte, error) {
+	var resp readResponse
+	err := fs.plugin.call("Read", &readRequest{Path: path, Offset: offset, Size: size}, &resp)
+	return readResult(resp, err)
+}
+
+func (fs *processFS) Write(path string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	var resp writeResponse
+	err := fs.plugin.call("Write", &writeRequest{Path: path, Data: data, Offset: offset, Flags: flags}, &resp)
+	return resp.Written, err
+}
+
+func (fs *processFS) ReadDir(path string) ([]filesystem.FileInfo, error) {
+	var resp readDirResponse
+	if err := fs.plugin.call("ReadDir", &pathRequest{Path: path}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Files, nil
+}
+
+func (fs *processFS) Stat(path string) (*filesystem.FileInfo, error) {
+	var resp statResponse
+	if err := fs.plugin.call("Stat", &pathRequest{Path: path}, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Info == nil {
+		return nil, filesystem.NewNotFoundError("stat", path)
+	}
+	return resp.Info, nil
+}
+
+func (fs *processFS) Rename(oldPath, newPath string) error {
+	return fs.plugin.call("Rename", &renameRequest{OldPath: oldPath, NewPath: newPath}, &empty{})
+}
+
+func (fs *processFS) Chmod(path string, mode uint32) error {
+	return fs.plugin.call("Chmod", &chmodRequest{Path: path, Mode: mode}, &empty{})
+}
+
+func (fs *processFS) Open(path string) (io.ReadCloser, error) {
+	data, err := fs.Read(path, 0, -1)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (fs *processFS) OpenWrite(path string) (io.WriteCloser, error) {
+	return filesystem.NewBufferedWriter(path, fs.Write), nil
+}
+
+// Ensure ProcessPlugin implements plugin.ServicePlugin
+var _ plugin.ServicePlugin = (*ProcessPlugin)(nil)
+var _ plugin.HealthChecker = (*ProcessPlugin)(nil)
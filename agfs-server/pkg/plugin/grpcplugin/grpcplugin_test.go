@@ -0,0 +1,143 @@
+package grpcplugin
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/memfs"
+)
+
+// The test binary doubles as a plugin executable serving memfs
+func TestMain(m *testing.M) {
+	if os.Getenv("AGFS_TEST_PLUGIN_PROCESS") == "1" {
+		Serve(memfs.NewMemFSPlugin())
+		return
+	}
+	os.Exit(m.Run())
+}
+
+func loadTestPlugin(t *testing.T) *ProcessPlugin {
+	t.Helper()
+
+	t.Setenv("AGFS_TEST_PLUGIN_PROCESS", "1")
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := Load(exe)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	t.Cleanup(func() { p.Shutdown() })
+
+	if err := p.Validate(map[string]interface{}{}); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if err := p.Initialize(map[string]interface{}{}); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	return p
+}
+
+func TestProcessPlugin(t *testing.T) {
+	p := loadTestPlugin(t)
+	if p.Name() != memfs.PluginName {
+		t.Errorf("expected name %s, got %s", memfs.PluginName, p.Name())
+	}
+	if p.GetReadme() == "" {
+		t.Error("expected a README")
+	}
+
+	fs := p.GetFileSystem()
+	if err := fs.Mkdir("/docs", 0755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	if _, err := fs.Write("/docs/a.txt", []byte("hello"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	data, err := fs.Read("/docs/a.txt", 0, -1)
+	if err != nil && err != io.EOF {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("expected hello, got %q", data)
+	}
+	if _, err := fs.Read("/docs/a.txt", 0, 100); err != io.EOF {
+		t.Errorf("expected io.EOF from a read past the end, got %v", err)
+	}
+
+	files, err := fs.ReadDir("/docs")
+	if err != nil || len(files) != 1 || files[0].Name != "a.txt" || files[0].Size != 5 {
+		t.Fatalf("unexpected listing %+v, %v", files, err)
+	}
+
+	if _, err := fs.Stat("/missing"); err == nil || !strings.Contains(err.Error(), "/missing") {
+		t.Errorf("expected the error of the plugin, got %v", err)
+	}
+}
+
+func TestErrorStatusRoundTrip(t *testing.T) {
+	errs := []error{
+		filesystem.NewNotFoundError("stat", "/a"),
+		filesystem.NewPermissionDeniedError("write", "/a", "read-only"),
+		filesystem.NewInvalidArgumentError("size", -1, "must not be negative"),
+		filesystem.NewNotDirectoryError("/a"),
+		filesystem.NewNotSupportedError("rename", "/a"),
+		filesystem.ErrNoSpace,
+		filesystem.NewUnavailableError("/a", "backend down"),
+	}
+	for _, want := range errs {
+		got := fromStatus(toStatus(want))
+		if got.Error() != want.Error() {
+			t.Errorf("expected message %q, got %q", want, got)
+		}
+		for _, e := range errorCodes {
+			if errors.Is(want, e.err) != errors.Is(got, e.err) {
+				t.Errorf("%v: errors.Is(%v) changed across the process boundary", want, e.err)
+			}
+		}
+	}
+
+	if got := fromStatus(toStatus(os.ErrNotExist)); !errors.Is(got, filesystem.ErrNotFound) {
+		t.Errorf("expected os.ErrNotExist to become ErrNotFound, got %v", got)
+	}
+	if got := fromStatus(toStatus(errors.New("boom"))); got.Error() != "boom" || errors.Is(got, filesystem.ErrNotFound) {
+		t.Errorf("unexpected plain error %v", got)
+	}
+}
+
+func TestProcessPluginRestartsAfterCrash(t *testing.T) {
+	p := loadTestPlugin(t)
+	fs := p.GetFileSystem()
+	if err := fs.Mkdir("/before", 0755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+
+	// Kill the plugin process as a crash would
+	pid := p.client.ReattachConfig().Pid
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proc.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	p.client.Kill()
+
+	// The next call starts a new process; the old state is gone but the
+	// plugin is initialized again and usable
+	if err := fs.Mkdir("/after", 0755); err != nil {
+		t.Fatalf("Mkdir after crash failed: %v", err)
+	}
+	if _, err := fs.Stat("/before"); err == nil {
+		t.Errorf("expected state of the crashed process to be gone, got %v", err)
+	}
+	if newPid := p.client.ReattachConfig().Pid; newPid == pid {
+		t.Errorf("expected a new plugin process")
+	}
+}
@@ -0,0 +1,228 @@
+// Package grpcplugin runs AGFS plugins as separate processes
+//
+// A plugin process is an executable that serves a plugin.ServicePlugin with
+// Serve. agfs-server starts it, talks to it over gRPC through
+// hashicorp/go-plugin, and sees it as an ordinary ServicePlugin. Third parties
+// can ship such plugins without rebuilding agfs-server, and a plugin that
+// crashes only takes its own process down: it is restarted on the next call.
+//
+// Messages are JSON encoded (gRPC content subtype "json"), so the protocol
+// needs no generated code and can be served from any language that speaks
+// gRPC and the go-plugin handshake.
+package grpcplugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	goplugin "github.com/hashicorp/go-plugin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// pluginKey is the name the plugin is dispensed under
+	pluginKey = "agfs"
+
+	// serviceName is the gRPC service every plugin process serves
+	serviceName = "agfs.plugin.v1.Plugin"
+
+	// codecName is the gRPC content subtype of plugin calls
+	codecName = "json"
+)
+
+// Handshake is checked by both sides before a plugin process is used
+// It keeps agfs-server from running arbitrary executables as plugins, and a
+// plugin binary from being run by hand as a normal program.
+var Handshake = goplugin.HandshakeConfig{
+	ProtocolVersion:  1,
+	MagicCookieKey:   "AGFS_PLUGIN",
+	MagicCookieValue: "7c1f0e0e-agfs-process-plugin",
+}
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// jsonCodec encodes plugin calls as JSON
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+func (jsonCodec) Name() string { return codecName }
+
+// Messages of the plugin service
+
+type empty struct{}
+
+type configRequest struct {
+	Config map[string]interface{} `json:"config"`
+}
+
+type stringResponse struct {
+	Value string `json:"value"`
+}
+
+type configParamsResponse struct {
+	Params []plugin.ConfigParameter `json:"params"`
+}
+
+type pathRequest struct {
+	Path string `json:"path"`
+}
+
+type mkdirRequest struct {
+	Path string `json:"path"`
+	Perm uint32 `json:"perm"`
+}
+
+type readRequest struct {
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+}
+
+type readResponse struct {
+	Data []byte `json:"data"`
+	EOF  bool   `json:"eof"` // The read reached the end of the file (io.EOF)
+}
+
+type writeRequest struct {
+	Path   string               `json:"path"`
+	Data   []byte               `json:"data"`
+	Offset int64                `json:"offset"`
+	Flags  filesystem.WriteFlag `json:"flags"`
+}
+
+type writeResponse struct {
+	Written int64 `json:"written"`
+}
+
+type readDirResponse struct {
+	Files []filesystem.FileInfo `json:"files"`
+}
+
+type statResponse struct {
+	Info *filesystem.FileInfo `json:"info"`
+}
+
+type renameRequest struct {
+	OldPath string `json:"old_path"`
+	NewPath string `json:"new_path"`
+}
+
+type chmodRequest struct {
+	Path string `json:"path"`
+	Mode uint32 `json:"mode"`
+}
+
+// Errors cross the process boundary as gRPC status codes, so that errors.Is
+// against the filesystem sentinels keeps working on the server side
+var errorCodes = []struct {
+	err  error
+	code codes.Code
+}{
+	{filesystem.ErrNotFound, codes.NotFound},
+	{filesystem.ErrPermissionDenied, codes.PermissionDenied},
+	{filesystem.ErrInvalidArgument, codes.InvalidArgument},
+	{filesystem.ErrAlreadyExists, codes.AlreadyExists},
+	{filesystem.ErrNotDirectory, codes.FailedPrecondition},
+	{filesystem.ErrNotSupported, codes.Unimplemented},
+	{filesystem.ErrNoSpace, codes.ResourceExhausted},
+	{filesystem.ErrUnavailable, codes.Unavailable},
+	{filesystem.ErrQuotaExceeded, codes.OutOfRange},
+	{filesystem.ErrConflict, codes.Aborted},
+}
+
+// toStatus converts an error of the plugin into a gRPC status
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, e := range errorCodes {
+		if errors.Is(err, e.err) {
+			return status.Error(e.code, err.Error())
+		}
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return status.Error(codes.Unknown, err.Error())
+}
+
+// remoteError is an error returned by a plugin process
+type remoteError struct {
+	msg  string
+	kind error // Filesystem sentinel the error matches, if any
+}
+
+func (e *remoteError) Error() string { return e.msg }
+
+func (e *remoteError) Is(target error) bool { return e.kind != nil && target == e.kind }
+
+// fromStatus converts the error of a plugin call back into a filesystem error
+func fromStatus(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	switch st.Code() {
+	case codes.OK:
+		return nil
+	case codes.Canceled:
+		return fmt.Errorf("plugin process unavailable: %s", st.Message())
+	}
+	for _, e := range errorCodes {
+		if st.Code() == e.code {
+			return &remoteError{msg: st.Message(), kind: e.err}
+		}
+	}
+	return &remoteError{msg: st.Message()}
+}
+
+// grpcPlugin is the go-plugin definition of an AGFS plugin
+// impl is set in the plugin process only.
+type grpcPlugin struct {
+	goplugin.NetRPCUnsupportedPlugin
+	impl plugin.ServicePlugin
+}
+
+func (p *grpcPlugin) GRPCServer(broker *goplugin.GRPCBroker, s *grpc.Server) error {
+	s.RegisterService(&serviceDesc, &server{impl: p.impl})
+	return nil
+}
+
+func (p *grpcPlugin) GRPCClient(ctx context.Context, broker *goplugin.GRPCBroker, conn *grpc.ClientConn) (interface{}, error) {
+	return &rpcClient{conn: conn}, nil
+}
+
+// rpcClient calls the plugin service of one plugin process
+type rpcClient struct {
+	conn *grpc.ClientConn
+}
+
+func (c *rpcClient) call(method string, req, resp interface{}) error {
+	err := c.conn.Invoke(context.Background(), "/"+serviceName+"/"+method, req, resp, grpc.CallContentSubtype(codecName))
+	return fromStatus(err)
+}
+
+// readResult returns the data of a read with io.EOF restored
+func readResult(resp readResponse, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	if resp.EOF {
+		return resp.Data, io.EOF
+	}
+	return resp.Data, nil
+}
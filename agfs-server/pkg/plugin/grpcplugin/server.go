@@ -0,0 +1,121 @@
+package grpcplugin
+
+import (
+	"context"
+	"io"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	goplugin "github.com/hashicorp/go-plugin"
+	"google.golang.org/grpc"
+)
+
+// Serve runs a plugin in a plugin process; it is called from the main
+// function of the plugin executable and does not return
+//
+//	func main() {
+//		grpcplugin.Serve(myplugin.NewMyPlugin())
+//	}
+//
+// Stdout is used by the handshake, so plugins must log to stderr. Run by
+// hand, the executable prints a notice and exits.
+func Serve(p plugin.ServicePlugin) {
+	goplugin.Serve(&goplugin.ServeConfig{
+		HandshakeConfig: Handshake,
+		Plugins:         goplugin.PluginSet{pluginKey: &grpcPlugin{impl: p}},
+		GRPCServer:      goplugin.DefaultGRPCServer,
+	})
+}
+
+// server serves the plugin service for a plugin in its own process
+type server struct {
+	impl plugin.ServicePlugin
+}
+
+// unary builds the descriptor of a plugin service method
+func unary[Req, Resp any](name string, fn func(s *server, req *Req) (*Resp, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := new(Req)
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				resp, err := fn(srv.(*server), req.(*Req))
+				return resp, toStatus(err)
+			}
+			if interceptor == nil {
+				return handler(ctx, req)
+			}
+			return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/" + name}, handler)
+		},
+	}
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		unary("Name", func(s *server, _ *empty) (*stringResponse, error) {
+			return &stringResponse{Value: s.impl.Name()}, nil
+		}),
+		unary("Validate", func(s *server, req *configRequest) (*empty, error) {
+			return &empty{}, s.impl.Validate(req.Config)
+		}),
+		unary("Initialize", func(s *server, req *configRequest) (*empty, error) {
+			return &empty{}, s.impl.Initialize(req.Config)
+		}),
+		unary("GetReadme", func(s *server, _ *empty) (*stringResponse, error) {
+			return &stringResponse{Value: s.impl.GetReadme()}, nil
+		}),
+		unary("GetConfigParams", func(s *server, _ *empty) (*configParamsResponse, error) {
+			return &configParamsResponse{Params: s.impl.GetConfigParams()}, nil
+		}),
+		unary("HealthCheck", func(s *server, _ *empty) (*empty, error) {
+			if hc, ok := s.impl.(plugin.HealthChecker); ok {
+				return &empty{}, hc.HealthCheck()
+			}
+			return &empty{}, nil
+		}),
+		unary("Shutdown", func(s *server, _ *empty) (*empty, error) {
+			return &empty{}, s.impl.Shutdown()
+		}),
+		unary("Create", func(s *server, req *pathRequest) (*empty, error) {
+			return &empty{}, s.impl.GetFileSystem().Create(req.Path)
+		}),
+		unary("Mkdir", func(s *server, req *mkdirRequest) (*empty, error) {
+			return &empty{}, s.impl.GetFileSystem().Mkdir(req.Path, req.Perm)
+		}),
+		unary("Remove", func(s *server, req *pathRequest) (*empty, error) {
+			return &empty{}, s.impl.GetFileSystem().Remove(req.Path)
+		}),
+		unary("RemoveAll", func(s *server, req *pathRequest) (*empty, error) {
+			return &empty{}, s.impl.GetFileSystem().RemoveAll(req.Path)
+		}),
+		unary("Read", func(s *server, req *readRequest) (*readResponse, error) {
+			data, err := s.impl.GetFileSystem().Read(req.Path, req.Offset, req.Size)
+			if err == io.EOF {
+				return &readResponse{Data: data, EOF: true}, nil
+			}
+			return &readResponse{Data: data}, err
+		}),
+		unary("Write", func(s *server, req *writeRequest) (*writeResponse, error) {
+			n, err := s.impl.GetFileSystem().Write(req.Path, req.Data, req.Offset, req.Flags)
+			return &writeResponse{Written: n}, err
+		}),
+		unary("ReadDir", func(s *server, req *pathRequest) (*readDirResponse, error) {
+			files, err := s.impl.GetFileSystem().ReadDir(req.Path)
+			return &readDirResponse{Files: files}, err
+		}),
+		unary("Stat", func(s *server, req *pathRequest) (*statResponse, error) {
+			info, err := s.impl.GetFileSystem().Stat(req.Path)
+			return &statResponse{Info: info}, err
+		}),
+		unary("Rename", func(s *server, req *renameRequest) (*empty, error) {
+			return &empty{}, s.impl.GetFileSystem().Rename(req.OldPath, req.NewPath)
+		}),
+		unary("Chmod", func(s *server, req *chmodRequest) (*empty, error) {
+			return &empty{}, s.impl.GetFileSystem().Chmod(req.Path, req.Mode)
+		}),
+	},
+}
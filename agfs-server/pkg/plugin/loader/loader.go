@@ -10,6 +10,7 @@ import (
 
 	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/api"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/grpcplugin"
 	"github.com/ebitengine/purego"
 	log "github.com/sirupsen/logrus"
 )
@@ -24,6 +25,8 @@ const (
 	PluginTypeNative
 	// PluginTypeWASM represents a WebAssembly plugin (.wasm)
 	PluginTypeWASM
+	// PluginTypeProcess represents a plugin executable run as a separate process
+	PluginTypeProcess
 )
 
 // String returns the string representation of the plugin type
@@ -33,6 +36,8 @@ func (pt PluginType) String() string {
 		return "native"
 	case PluginTypeWASM:
 		return "wasm"
+	case PluginTypeProcess:
+		return "process"
 	default:
 		return "unknown"
 	}
@@ -64,6 +69,12 @@ func NewPluginLoader(poolConfig api.PoolConfig) *PluginLoader {
 	}
 }
 
+// SetWASMSandbox sets the restrictions of the host functions of WASM plugins
+// loaded from now on
+func (pl *PluginLoader) SetWASMSandbox(sandbox api.SandboxConfig) {
+	pl.wasmLoader.SetSandbox(sandbox)
+}
+
 
 // DetectPluginType detects the type of plugin based on file content and extension
 func DetectPluginType(libraryPath string) (PluginType, error) {
@@ -92,6 +103,22 @@ func DetectPluginType(libraryPath string) (PluginType, error) {
 		return PluginTypeWASM, nil
 	}
 
+	// Check shebang: a script run as a plugin process
+	if magic[0] == '#' && magic[1] == '!' {
+		if isExecutable(libraryPath) {
+			return PluginTypeProcess, nil
+		}
+		return detectPluginTypeByExtension(libraryPath), nil
+	}
+
+	// Executables share their magic number with shared libraries: a binary
+	// without a shared library extension that can be run is a plugin process
+	if detectPluginTypeByExtension(libraryPath) != PluginTypeNative && isExecutable(libraryPath) {
+		if isBinaryMagic(magic) {
+			return PluginTypeProcess, nil
+		}
+	}
+
 	// Check ELF magic number: 0x7F 'E' 'L' 'F' (Linux .so)
 	if magic[0] == 0x7F && magic[1] == 'E' && magic[2] == 'L' && magic[3] == 'F' {
 		return PluginTypeNative, nil
@@ -118,6 +145,30 @@ func DetectPluginType(libraryPath string) (PluginType, error) {
 	return detectPluginTypeByExtension(libraryPath), nil
 }
 
+// isBinaryMagic checks for the magic number of an ELF, Mach-O or PE binary
+func isBinaryMagic(magic []byte) bool {
+	switch {
+	case magic[0] == 0x7F && magic[1] == 'E' && magic[2] == 'L' && magic[3] == 'F':
+		return true
+	case magic[0] == 0xFE && magic[1] == 0xED && magic[2] == 0xFA && (magic[3] == 0xCE || magic[3] == 0xCF):
+		return true
+	case (magic[0] == 0xCE || magic[0] == 0xCF) && magic[1] == 0xFA && magic[2] == 0xED && magic[3] == 0xFE:
+		return true
+	case magic[0] == 'M' && magic[1] == 'Z':
+		return true
+	}
+	return false
+}
+
+// isExecutable checks whether a file can be run as a plugin process
+func isExecutable(path string) bool {
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(filepath.Ext(path), ".exe")
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && info.Mode().Perm()&0111 != 0
+}
+
 // detectPluginTypeByExtension detects plugin type based on file extension (fallback)
 func detectPluginTypeByExtension(libraryPath string) PluginType {
 	ext := strings.ToLower(filepath.Ext(libraryPath))
@@ -142,6 +193,8 @@ func (pl *PluginLoader) LoadPluginWithType(libraryPath string, pluginType Plugin
 		return pl.wasmLoader.LoadWASMPlugin(libraryPath, pl.poolConfig, hostFS...)
 	case PluginTypeNative:
 		return pl.loadNativePlugin(libraryPath)
+	case PluginTypeProcess:
+		return pl.loadProcessPlugin(libraryPath)
 	default:
 		return nil, fmt.Errorf("unsupported plugin type: %s", pluginType)
 	}
@@ -242,6 +295,40 @@ func (pl *PluginLoader) loadNativePlugin(libraryPath string) (plugin.ServicePlug
 	return externalPlugin, nil
 }
 
+// loadProcessPlugin starts a plugin executable as a separate process
+// Loading the same executable again returns the running plugin.
+func (pl *PluginLoader) loadProcessPlugin(path string) (plugin.ServicePlugin, error) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	if loaded, exists := pl.loadedPlugins[absPath]; exists {
+		loaded.mu.Lock()
+		loaded.RefCount++
+		loaded.mu.Unlock()
+		log.Infof("Plugin process %s already running (refCount: %d)", absPath, loaded.RefCount)
+		return loaded.Plugin, nil
+	}
+
+	processPlugin, err := grpcplugin.Load(absPath)
+	if err != nil {
+		return nil, err
+	}
+
+	pl.loadedPlugins[absPath] = &LoadedPlugin{
+		Path:     absPath,
+		Plugin:   processPlugin,
+		RefCount: 1,
+	}
+
+	log.Infof("Successfully loaded plugin process: %s (name: %s)", absPath, processPlugin.Name())
+	return processPlugin, nil
+}
+
 // UnloadPluginWithType unloads a plugin with an explicitly specified type
 func (pl *PluginLoader) UnloadPluginWithType(libraryPath string, pluginType PluginType) error {
 	log.Debugf("Unloading plugin with type %s: %s", pluginType, libraryPath)
@@ -250,7 +337,7 @@ func (pl *PluginLoader) UnloadPluginWithType(libraryPath string, pluginType Plug
 	switch pluginType {
 	case PluginTypeWASM:
 		return pl.wasmLoader.UnloadWASMPlugin(libraryPath)
-	case PluginTypeNative:
+	case PluginTypeNative, PluginTypeProcess:
 		return pl.unloadNativePlugin(libraryPath)
 	default:
 		return fmt.Errorf("unsupported plugin type: %s", pluginType)
@@ -270,7 +357,7 @@ func (pl *PluginLoader) UnloadPlugin(libraryPath string) error {
 	return pl.UnloadPluginWithType(libraryPath, pluginType)
 }
 
-// unloadNativePlugin unloads a native shared library plugin
+// unloadNativePlugin unloads a native shared library plugin or stops a plugin process
 func (pl *PluginLoader) unloadNativePlugin(libraryPath string) error {
 	pl.mu.Lock()
 	defer pl.mu.Unlock()
@@ -291,13 +378,17 @@ func (pl *PluginLoader) unloadNativePlugin(libraryPath string) error {
 	loaded.mu.Unlock()
 
 	if refCount <= 0 {
-		// Shutdown plugin
+		// Shutdown plugin (this also stops a plugin process)
 		if err := loaded.Plugin.Shutdown(); err != nil {
 			log.Warnf("Error shutting down plugin %s: %v", absPath, err)
 		}
 
 		// Remove from tracking
 		delete(pl.loadedPlugins, absPath)
+		if loaded.LibHandle == 0 {
+			log.Infof("Unloaded plugin: %s", absPath)
+			return nil
+		}
 
 		// Note: purego doesn't currently provide Dlclose, so we can't unload the library
 		// The library will remain in memory until process exit
@@ -355,7 +446,7 @@ func (pl *PluginLoader) IsLoadedWithType(libraryPath string, pluginType PluginTy
 	switch pluginType {
 	case PluginTypeWASM:
 		return pl.wasmLoader.IsLoaded(libraryPath)
-	case PluginTypeNative:
+	case PluginTypeNative, PluginTypeProcess:
 		return pl.isNativePluginLoaded(libraryPath)
 	default:
 		return false
@@ -376,7 +467,7 @@ func (pl *PluginLoader) IsLoaded(libraryPath string) bool {
 	return pl.IsLoadedWithType(libraryPath, pluginType)
 }
 
-// isNativePluginLoaded checks if a native plugin is currently loaded
+// isNativePluginLoaded checks if a native plugin or plugin process is currently loaded
 func (pl *PluginLoader) isNativePluginLoaded(libraryPath string) bool {
 	pl.mu.RLock()
 	defer pl.mu.RUnlock()
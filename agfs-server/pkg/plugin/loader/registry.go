@@ -10,6 +10,10 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// processPluginPrefix marks plugin executables in a plugin directory
+// Other executables there are not started.
+const processPluginPrefix = "agfs-plugin-"
+
 // PluginInfo contains metadata about a discovered plugin
 type PluginInfo struct {
 	Path     string
@@ -18,7 +22,8 @@ type PluginInfo struct {
 	IsLoaded bool
 }
 
-// DiscoverPlugins searches for plugin files in a directory (both native and WASM)
+// DiscoverPlugins searches for plugin files in a directory (native, WASM and
+// plugin executables named agfs-plugin-*)
 func DiscoverPlugins(dir string) ([]PluginInfo, error) {
 	if dir == "" {
 		return []PluginInfo{}, nil
@@ -64,6 +69,9 @@ func DiscoverPlugins(dir string) ([]PluginInfo, error) {
 		} else if strings.HasSuffix(info.Name(), wasmExt) {
 			pluginType = PluginTypeWASM
 			name = strings.TrimSuffix(info.Name(), wasmExt)
+		} else if strings.HasPrefix(info.Name(), processPluginPrefix) && isExecutable(path) {
+			pluginType = PluginTypeProcess
+			name = strings.TrimSuffix(strings.TrimPrefix(info.Name(), processPluginPrefix), ".exe")
 		} else {
 			// Not a plugin file, skip
 			return nil
@@ -83,8 +91,9 @@ func DiscoverPlugins(dir string) ([]PluginInfo, error) {
 		return nil, fmt.Errorf("failed to walk plugin directory: %w", err)
 	}
 
-	log.Infof("Discovered %d plugin(s) in %s (%d native, %d WASM)",
-		len(plugins), dir, countPluginsByType(plugins, PluginTypeNative), countPluginsByType(plugins, PluginTypeWASM))
+	log.Debugf("Discovered %d plugin(s) in %s (%d native, %d WASM, %d process)",
+		len(plugins), dir, countPluginsByType(plugins, PluginTypeNative), countPluginsByType(plugins, PluginTypeWASM),
+		countPluginsByType(plugins, PluginTypeProcess))
 	return plugins, nil
 }
 
@@ -28,6 +28,7 @@ type LoadedWASMPlugin struct {
 // WASMPluginLoader manages loading and unloading of WASM plugins
 type WASMPluginLoader struct {
 	loadedPlugins map[string]*LoadedWASMPlugin
+	sandbox       api.SandboxConfig // Applied to the plugins loaded after it is set
 	mu            sync.RWMutex
 }
 
@@ -38,6 +39,14 @@ func NewWASMPluginLoader() *WASMPluginLoader {
 	}
 }
 
+// SetSandbox sets the restrictions of the host functions of plugins loaded
+// from now on; plugins already loaded keep theirs
+func (wl *WASMPluginLoader) SetSandbox(sandbox api.SandboxConfig) {
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+	wl.sandbox = sandbox
+}
+
 // LoadWASMPlugin loads a plugin from a WASM file
 // If hostFS is provided, it will be exposed to the WASM plugin as host functions
 // poolConfig specifies the instance pool configuration (use api.PoolConfig{} for defaults)
@@ -78,7 +87,12 @@ func (wl *WASMPluginLoader) LoadWASMPlugin(wasmPath string, poolConfig api.PoolC
 
 	// Create a new WASM runtime
 	ctx := context.Background()
-	r := wazero.NewRuntime(ctx)
+	sandbox := wl.sandbox
+	runtimeConfig := wazero.NewRuntimeConfig()
+	if pages := sandbox.MemoryLimitPages(); pages > 0 {
+		runtimeConfig = runtimeConfig.WithMemoryLimitPages(pages)
+	}
+	r := wazero.NewRuntimeWithConfig(ctx, runtimeConfig)
 
 	// Instantiate WASI
 	if _, err := wasi_snapshot_preview1.Instantiate(ctx, r); err != nil {
@@ -97,7 +111,12 @@ func (wl *WASMPluginLoader) LoadWASMPlugin(wasmPath string, poolConfig api.PoolC
 			r.Close(ctx)
 			return nil, fmt.Errorf("hostFS is not a filesystem.FileSystem")
 		}
-		log.Infof("Registering host filesystem for WASM plugin")
+		fs = sandbox.HostFS(fs)
+		if sandbox.FSRoot != "" {
+			log.Infof("Registering host filesystem for WASM plugin, confined to %s", sandbox.FSRoot)
+		} else {
+			log.Infof("Registering host filesystem for WASM plugin")
+		}
 	} else {
 		log.Infof("No host filesystem provided, using stub functions")
 		fs = nil // Will be handled by api functions
@@ -156,7 +175,7 @@ func (wl *WASMPluginLoader) LoadWASMPlugin(wasmPath string, poolConfig api.PoolC
 			Export("host_fs_chmod").
 			NewFunctionBuilder().
 			WithFunc(func(ctx context.Context, mod wazeroapi.Module, requestPtr uint32) uint64 {
-				return api.HostHTTPRequest(ctx, mod, []uint64{uint64(requestPtr)})[0]
+				return api.HostHTTPRequest(ctx, mod, []uint64{uint64(requestPtr)}, sandbox)[0]
 			}).
 			Export("host_http_request").
 			Instantiate(ctx)
@@ -1,16 +1,28 @@
 package plugin
 
 import (
+	"context"
+
 	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
 )
 
 // ConfigParameter describes a configuration parameter for a plugin
 type ConfigParameter struct {
-	Name        string `json:"name"`        // Parameter name
-	Type        string `json:"type"`        // Parameter type (string, int, bool, etc.)
-	Required    bool   `json:"required"`    // Whether the parameter is required
-	Default     string `json:"default"`     // Default value (as string)
-	Description string `json:"description"` // Parameter description
+	Name        string   `json:"name"`                 // Parameter name
+	Type        string   `json:"type"`                 // Parameter type (string, int, float, bool, map, array)
+	Required    bool     `json:"required"`             // Whether the parameter is required
+	Default     string   `json:"default"`              // Default value (as string)
+	Description string   `json:"description"`          // Parameter description
+	Enum        []string `json:"enum,omitempty"`       // Allowed values, if the parameter takes one of a fixed set
+	Minimum     *float64 `json:"minimum,omitempty"`    // Smallest allowed value of a number
+	Maximum     *float64 `json:"maximum,omitempty"`    // Largest allowed value of a number
+	Secret      bool     `json:"secret,omitempty"`     // Value is a credential; hidden when configs are listed
+	Deprecated  string   `json:"deprecated,omitempty"` // If set, the parameter is deprecated; says what to use instead
+}
+
+// Bound returns a pointer to v, for ConfigParameter.Minimum and Maximum
+func Bound(v float64) *float64 {
+	return &v
 }
 
 // ServicePlugin defines the interface for a service that can be mounted to a path
@@ -44,6 +56,59 @@ type ServicePlugin interface {
 	Shutdown() error
 }
 
+// HealthChecker is implemented by plugins that depend on something that can
+// fail after Initialize, such as a database connection or a remote service
+// The server polls HealthCheck; while it fails, operations on the mount fail
+// fast with filesystem.ErrUnavailable instead of reaching the plugin.
+type HealthChecker interface {
+	// HealthCheck returns nil if the plugin can serve requests
+	HealthCheck() error
+}
+
+// ProbeResult is the outcome of checking one backend of a plugin
+type ProbeResult struct {
+	Backend string // What was checked, e.g. "tidb" or "openai auth"
+	Err     error  // nil if the backend is usable
+}
+
+// Prober is implemented by plugins that can check, after Initialize, that
+// every backend they depend on is reachable and accepts their credentials
+// Unlike HealthCheck, probes may be slow or cost API calls; they are run by
+// agfs-server --check-config --probe only, never while serving.
+type Prober interface {
+	// Probe checks each backend and returns one result per backend
+	Probe(ctx context.Context) []ProbeResult
+}
+
+// ReadRouter is implemented by plugins whose reads can be served by replicas
+// of their backend, such as database read replicas or replicated buckets
+// A mount of such a plugin may list replicas in its "replicas" config key;
+// each is an instance of the plugin with the mount config overridden by the
+// entry. Operations the plugin declares as reads are spread over healthy
+// replicas, everything else goes to the primary.
+type ReadRouter interface {
+	// IsRead reports whether an operation on a path only reads the backend
+	// and tolerates replication lag; op is "read", "readdir", "stat",
+	// "open" or "grep", path is relative to the mount
+	IsRead(op, path string) bool
+}
+
+// TaskProgress reports the progress of a running task: done of total units
+// (total is 0 if unknown) and a short description of the current step
+type TaskProgress func(done, total int64, message string)
+
+// TaskRunner is implemented by plugins with maintenance tasks, such as
+// reindexing a namespace, that administrators start through the admin API
+// Tasks run in the background; RunTask should stop early when ctx is done.
+type TaskRunner interface {
+	// Tasks returns the names of the tasks the plugin can run
+	Tasks() []string
+
+	// RunTask runs a task to completion; args["path"] is the path inside the
+	// mount the task was started on
+	RunTask(ctx context.Context, task string, args map[string]string, progress TaskProgress) error
+}
+
 // MountPoint represents a mounted service plugin
 type MountPoint struct {
 	Path   string
@@ -0,0 +1,229 @@
+package plugin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+)
+
+// JSONSchemaDraft is the JSON Schema dialect of the schemas made by ConfigSchema
+const JSONSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// jsonSchemaTypes maps ConfigParameter types to JSON Schema types
+var jsonSchemaTypes = map[string]string{
+	"string": "string",
+	"int":    "integer",
+	"float":  "number",
+	"bool":   "boolean",
+	"map":    "object",
+	"array":  "array",
+}
+
+// ConfigSchema returns the JSON Schema of the config of a plugin
+// Secret parameters are marked writeOnly and deprecated ones deprecated. When
+// the plugin declares parameters, other keys are rejected, as they are by the
+// plugins' Validate; a plugin without declared parameters accepts any config.
+func ConfigSchema(pluginName string, params []ConfigParameter) map[string]interface{} {
+	properties := make(map[string]interface{}, len(params))
+	required := []string{}
+	for _, p := range params {
+		properties[p.Name] = p.jsonSchema()
+		if p.Required {
+			required = append(required, p.Name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"$schema":    JSONSchemaDraft,
+		"title":      pluginName + " plugin config",
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	if len(params) > 0 {
+		schema["additionalProperties"] = false
+	}
+	return schema
+}
+
+// jsonSchema returns the JSON Schema of a single parameter
+func (p ConfigParameter) jsonSchema() map[string]interface{} {
+	prop := map[string]interface{}{}
+	if t, ok := jsonSchemaTypes[p.Type]; ok {
+		prop["type"] = t
+	}
+
+	description := p.Description
+	if p.Deprecated != "" {
+		prop["deprecated"] = true
+		description = strings.TrimSpace(description + " (deprecated: " + p.Deprecated + ")")
+	}
+	if description != "" {
+		prop["description"] = description
+	}
+	if def, ok := p.typedDefault(); ok {
+		prop["default"] = def
+	}
+	if len(p.Enum) > 0 {
+		prop["enum"] = p.Enum
+	}
+	if p.Minimum != nil {
+		prop["minimum"] = *p.Minimum
+	}
+	if p.Maximum != nil {
+		prop["maximum"] = *p.Maximum
+	}
+	if p.Secret {
+		prop["writeOnly"] = true
+	}
+	return prop
+}
+
+// typedDefault converts the string default of a parameter to its type
+func (p ConfigParameter) typedDefault() (interface{}, bool) {
+	if p.Default == "" {
+		return nil, false
+	}
+	switch p.Type {
+	case "int":
+		if v, err := strconv.ParseInt(p.Default, 10, 64); err == nil {
+			return v, true
+		}
+	case "float":
+		if v, err := strconv.ParseFloat(p.Default, 64); err == nil {
+			return v, true
+		}
+	case "bool":
+		if v, err := strconv.ParseBool(p.Default); err == nil {
+			return v, true
+		}
+	case "string":
+		return p.Default, true
+	}
+	return nil, false
+}
+
+// ValidateConfigParams checks a config against the declared parameters: that
+// required ones are set and that values have the declared type, are one of the
+// enum values and are within range
+// Unknown keys are left to the plugin's Validate. String values that are
+// secret references (env:, file:, vault:) are not checked against enums.
+func ValidateConfigParams(params []ConfigParameter, cfg map[string]interface{}) error {
+	for _, p := range params {
+		value, ok := cfg[p.Name]
+		if !ok || value == nil {
+			if p.Required {
+				return fmt.Errorf("%s is required in configuration", p.Name)
+			}
+			continue
+		}
+		if err := p.validateValue(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateValue checks a single config value against the parameter
+func (p ConfigParameter) validateValue(value interface{}) error {
+	var number float64
+	isNumber := false
+
+	switch p.Type {
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", p.Name)
+		}
+		if len(p.Enum) > 0 && !config.IsSecretReference(s) && !containsString(p.Enum, s) {
+			return fmt.Errorf("invalid %s: %s (valid options: %s)", p.Name, s, strings.Join(p.Enum, ", "))
+		}
+	case "int":
+		switch v := value.(type) {
+		case int:
+			number = float64(v)
+		case int64:
+			number = float64(v)
+		case float64:
+			if v != float64(int64(v)) {
+				return fmt.Errorf("%s must be an integer", p.Name)
+			}
+			number = v
+		default:
+			return fmt.Errorf("%s must be an integer", p.Name)
+		}
+		isNumber = true
+	case "float":
+		switch v := value.(type) {
+		case int:
+			number = float64(v)
+		case int64:
+			number = float64(v)
+		case float64:
+			number = v
+		default:
+			return fmt.Errorf("%s must be a number", p.Name)
+		}
+		isNumber = true
+	case "bool":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean", p.Name)
+		}
+	case "map":
+		if _, ok := value.(map[string]interface{}); !ok {
+			return fmt.Errorf("%s must be a map", p.Name)
+		}
+	case "array":
+		if _, ok := value.([]interface{}); !ok {
+			return fmt.Errorf("%s must be an array", p.Name)
+		}
+	}
+
+	if isNumber {
+		if p.Minimum != nil && number < *p.Minimum {
+			return fmt.Errorf("%s must be at least %v, got %v", p.Name, *p.Minimum, number)
+		}
+		if p.Maximum != nil && number > *p.Maximum {
+			return fmt.Errorf("%s must be at most %v, got %v", p.Name, *p.Maximum, number)
+		}
+		if len(p.Enum) > 0 && !containsString(p.Enum, strconv.FormatFloat(number, 'f', -1, 64)) {
+			return fmt.Errorf("invalid %s: %v (valid options: %s)", p.Name, number, strings.Join(p.Enum, ", "))
+		}
+	}
+	return nil
+}
+
+// SecretParamNames returns the names of the secret parameters
+func SecretParamNames(params []ConfigParameter) []string {
+	var names []string
+	for _, p := range params {
+		if p.Secret {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
+
+// DeprecatedParamsIn returns a warning for every deprecated parameter set in cfg
+func DeprecatedParamsIn(params []ConfigParameter, cfg map[string]interface{}) []string {
+	var warnings []string
+	for _, p := range params {
+		if _, ok := cfg[p.Name]; ok && p.Deprecated != "" {
+			warnings = append(warnings, fmt.Sprintf("config parameter %s is deprecated: %s", p.Name, p.Deprecated))
+		}
+	}
+	return warnings
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
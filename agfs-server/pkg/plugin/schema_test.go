@@ -0,0 +1,92 @@
+package plugin
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var testParams = []ConfigParameter{
+	{Name: "backend", Type: "string", Default: "sqlite", Enum: []string{"sqlite", "tidb"}},
+	{Name: "dsn", Type: "string", Required: true, Secret: true},
+	{Name: "workers", Type: "int", Default: "4", Minimum: Bound(1), Maximum: Bound(64)},
+	{Name: "rate", Type: "float", Default: "0.5"},
+	{Name: "verbose", Type: "bool", Default: "false"},
+	{Name: "old_dsn", Type: "string", Deprecated: "use dsn"},
+}
+
+func TestConfigSchema(t *testing.T) {
+	schema := ConfigSchema("sqlfs", testParams)
+
+	// The schema must survive a JSON round trip, as served by the API
+	data, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Type                 string                            `json:"type"`
+		Required             []string                          `json:"required"`
+		AdditionalProperties bool                              `json:"additionalProperties"`
+		Properties           map[string]map[string]interface{} `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Type != "object" || decoded.AdditionalProperties {
+		t.Errorf("expected a closed object schema, got %s", data)
+	}
+	if len(decoded.Required) != 1 || decoded.Required[0] != "dsn" {
+		t.Errorf("expected dsn to be required, got %v", decoded.Required)
+	}
+
+	props := decoded.Properties
+	if props["workers"]["type"] != "integer" || props["workers"]["default"] != 4.0 ||
+		props["workers"]["minimum"] != 1.0 || props["workers"]["maximum"] != 64.0 {
+		t.Errorf("unexpected workers schema %v", props["workers"])
+	}
+	if props["rate"]["type"] != "number" || props["verbose"]["default"] != false {
+		t.Errorf("unexpected typed defaults %v %v", props["rate"], props["verbose"])
+	}
+	if enum, _ := props["backend"]["enum"].([]interface{}); len(enum) != 2 {
+		t.Errorf("expected backend enum, got %v", props["backend"])
+	}
+	if props["dsn"]["writeOnly"] != true {
+		t.Errorf("expected dsn to be writeOnly, got %v", props["dsn"])
+	}
+	if props["old_dsn"]["deprecated"] != true || !strings.Contains(props["old_dsn"]["description"].(string), "use dsn") {
+		t.Errorf("expected old_dsn to be deprecated, got %v", props["old_dsn"])
+	}
+
+	if _, ok := ConfigSchema("external", nil)["additionalProperties"]; ok {
+		t.Errorf("expected a plugin without params to accept any config")
+	}
+}
+
+func TestValidateConfigParams(t *testing.T) {
+	valid := []map[string]interface{}{
+		{"dsn": "x"},
+		{"dsn": "env:DSN", "backend": "tidb", "workers": 8, "rate": 1, "verbose": true},
+		{"dsn": "x", "backend": "env:BACKEND", "workers": float64(64)},
+	}
+	for _, cfg := range valid {
+		if err := ValidateConfigParams(testParams, cfg); err != nil {
+			t.Errorf("expected %v to be valid, got %v", cfg, err)
+		}
+	}
+
+	invalid := map[string]map[string]interface{}{
+		"dsn is required":            {"backend": "sqlite"},
+		"invalid backend":            {"dsn": "x", "backend": "mysql"},
+		"workers must be at least":   {"dsn": "x", "workers": 0},
+		"workers must be at most":    {"dsn": "x", "workers": 65},
+		"workers must be an integer": {"dsn": "x", "workers": 1.5},
+		"verbose must be a boolean":  {"dsn": "x", "verbose": "yes"},
+	}
+	for want, cfg := range invalid {
+		err := ValidateConfigParams(testParams, cfg)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%v: expected an error containing %q, got %v", cfg, want, err)
+		}
+	}
+}
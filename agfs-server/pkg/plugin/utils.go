@@ -21,9 +21,9 @@ func ApplyRangeRead(data []byte, offset int64, size int64) ([]byte, error) {
 		// Read all remaining data
 		end = dataLen
 	} else {
-		end = offset + size
-		if end > dataLen {
-			end = dataLen
+		end = dataLen
+		if size < dataLen-offset {
+			end = offset + size
 		}
 	}
 
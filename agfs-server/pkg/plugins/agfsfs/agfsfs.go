@@ -0,0 +1,523 @@
+package agfsfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	agfs "github.com/c4pt0r/agfs/agfs-sdk/go"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PluginName = "agfsfs" // Name of this plugin
+)
+
+// AGFSFSPlugin mounts the namespace of a remote agfs-server, so that servers
+// on several machines or regions can be federated under one root. Unlike
+// proxyfs it passes file handles through to the remote server, writes at
+// offsets, maps remote errors back to filesystem errors and discovers what the
+// remote server supports.
+type AGFSFSPlugin struct {
+	client   *agfs.Client
+	url      string
+	root     string // Remote path mounted as the root of the plugin
+	version  string // Remote server version
+	features []string
+
+	handles  map[int64]*remoteHandle
+	mu       sync.Mutex
+	metadata plugin.PluginMetadata
+}
+
+// NewAGFSFSPlugin creates a new agfsfs plugin
+func NewAGFSFSPlugin() *AGFSFSPlugin {
+	return &AGFSFSPlugin{
+		handles: make(map[int64]*remoteHandle),
+		metadata: plugin.PluginMetadata{
+			Name:        PluginName,
+			Version:     "1.0.0",
+			Description: "Mount a remote agfs-server to federate namespaces across machines",
+			Author:      "AGFS Server",
+		},
+	}
+}
+
+func (p *AGFSFSPlugin) Name() string {
+	return p.metadata.Name
+}
+
+func (p *AGFSFSPlugin) Validate(cfg map[string]interface{}) error {
+	allowedKeys := []string{"mount_path", "url", "token", "root", "wire_compression"}
+	if err := config.ValidateOnlyKnownKeys(cfg, allowedKeys); err != nil {
+		return err
+	}
+	for _, key := range allowedKeys[1:] {
+		if err := config.ValidateStringType(cfg, key); err != nil {
+			return err
+		}
+	}
+
+	url := config.GetStringConfig(cfg, "url", "")
+	if url == "" {
+		return fmt.Errorf("url is required in configuration")
+	}
+	if !strings.Contains(url, "://") {
+		return fmt.Errorf("invalid url: %s (expected http://host:port, https://host:port or unix:///path/to/socket)", url)
+	}
+	switch config.GetStringConfig(cfg, "wire_compression", "") {
+	case "", agfs.EncodingZstd, agfs.EncodingLZ4:
+	default:
+		return fmt.Errorf("unsupported wire_compression: %s", cfg["wire_compression"])
+	}
+	return nil
+}
+
+func (p *AGFSFSPlugin) Initialize(cfg map[string]interface{}) error {
+	p.url = config.GetStringConfig(cfg, "url", "")
+	p.root = path.Clean("/" + config.GetStringConfig(cfg, "root", "/"))
+	p.client = agfs.NewClient(p.url)
+	if token := config.GetStringConfig(cfg, "token", ""); token != "" {
+		p.client.SetToken(token)
+	}
+
+	if err := p.client.Health(); err != nil {
+		return fmt.Errorf("failed to connect to remote agfs-server at %s: %w", p.url, err)
+	}
+	caps, err := p.client.GetCapabilities()
+	if err != nil {
+		return fmt.Errorf("failed to get capabilities of %s: %w", p.url, err)
+	}
+	p.version = caps.Version
+	p.features = caps.Features
+
+	if encoding := config.GetStringConfig(cfg, "wire_compression", ""); encoding != "" {
+		if err := p.client.SetCompression(encoding); err != nil {
+			log.Warnf("[agfsfs] %s does not support %s compression, transferring uncompressed", p.url, encoding)
+		}
+	}
+
+	log.Infof("[agfsfs] Initialized (url=%s, root=%s, version=%s, features=%v)", p.url, p.root, p.version, p.features)
+	return nil
+}
+
+func (p *AGFSFSPlugin) GetFileSystem() filesystem.FileSystem {
+	return &agfsFS{plugin: p}
+}
+
+func (p *AGFSFSPlugin) GetReadme() string {
+	return `AGFSFS Plugin - Remote AGFS Server
+
+This plugin mounts the namespace of another agfs-server, so that servers on
+several machines or regions can be federated under one root. All operations
+are forwarded to the remote server through the Go SDK.
+
+FEATURES:
+  - Reads, writes at offsets, appends and truncation
+  - File handles opened on the remote server (HandleFS pass-through)
+  - Streaming reads of remote streams
+  - File versions, so If-Match writes work across servers
+  - Remote errors keep their meaning: a missing remote file is a 404 here
+  - Capability discovery: features of the remote server are listed in the
+    metadata of the mount root
+
+CONFIGURATION:
+  [plugins.agfsfs]
+  enabled = true
+  path = "/regions/eu"
+
+    [plugins.agfsfs.config]
+    url = "http://eu.example.com:8080"   # or unix:///run/agfs.sock
+    token = "env:EU_AGFS_TOKEN"          # bearer token of the remote server
+    root = "/"                           # remote directory to mount
+    wire_compression = "zstd"            # zstd or lz4, if the remote has it
+
+EXAMPLES:
+  agfs:/> ls /regions/eu
+  agfs:/> echo hello > /regions/eu/memfs/greeting
+  agfs:/> stat /regions/eu          # remote version and features
+
+NOTES:
+  - Writes at an offset and file handles need the "handlefs" feature on the
+    remote server.
+  - The remote server has no watch API, so there are no change events to
+    pass through; poll file versions to detect changes.
+  - Symbolic links are not passed through: their targets are remote paths.
+  - Mounting a server into itself creates a loop; don't.
+`
+}
+
+func (p *AGFSFSPlugin) GetConfigParams() []plugin.ConfigParameter {
+	return []plugin.ConfigParameter{
+		{
+			Name:        "url",
+			Type:        "string",
+			Required:    true,
+			Default:     "",
+			Description: "Remote agfs-server address (http://, https:// or unix://)",
+		},
+		{
+			Name:        "token",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Bearer token of the remote server; use an env:, file: or vault: reference",
+			Secret:      true,
+		},
+		{
+			Name:        "root",
+			Type:        "string",
+			Required:    false,
+			Default:     "/",
+			Description: "Remote directory mounted as the root of the plugin",
+		},
+		{
+			Name:        "wire_compression",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Compress file contents on the wire if the remote supports it",
+			Enum:        []string{agfs.EncodingZstd, agfs.EncodingLZ4},
+		},
+	}
+}
+
+// HealthCheck checks that the remote server is reachable
+func (p *AGFSFSPlugin) HealthCheck() error {
+	if err := p.client.Health(); err != nil {
+		return remoteError(err)
+	}
+	return nil
+}
+
+func (p *AGFSFSPlugin) Shutdown() error {
+	p.mu.Lock()
+	handles := p.handles
+	p.handles = make(map[int64]*remoteHandle)
+	p.mu.Unlock()
+
+	for id := range handles {
+		if err := p.client.CloseHandle(id); err != nil {
+			log.Warnf("[agfsfs] Failed to close remote handle %d: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// hasFeature checks if the remote server advertised a feature
+func (p *AGFSFSPlugin) hasFeature(feature string) bool {
+	for _, f := range p.features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// remotePath returns the remote path of a path inside the plugin
+func (p *AGFSFSPlugin) remotePath(localPath string) string {
+	return path.Join(p.root, "/"+strings.TrimPrefix(localPath, "/"))
+}
+
+// remoteError maps an error of the SDK back to the filesystem error of the
+// remote status, so that handlers answer with the same status as the remote
+// server. Errors without a status (the remote server could not be reached)
+// are ErrUnavailable.
+func remoteError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, agfs.ErrNotSupported) {
+		return fmt.Errorf("remote: %w", filesystem.ErrNotSupported)
+	}
+	if errors.Is(err, agfs.ErrConflict) {
+		return fmt.Errorf("remote: %w", filesystem.ErrConflict)
+	}
+	var pathErr *agfs.PathError
+	if errors.As(err, &pathErr) {
+		for _, m := range sdkErrors {
+			if errors.Is(pathErr, m.sdk) {
+				return &remoteErr{msg: pathErr.Message, kind: m.kind}
+			}
+		}
+		return err
+	}
+
+	// Errors the SDK doesn't type yet carry the status in their message
+	var netErr net.Error
+	msg := err.Error()
+	i := strings.Index(msg, "HTTP ")
+	var status int
+	if i < 0 || errors.As(err, &netErr) {
+		return &remoteErr{msg: msg, kind: filesystem.ErrUnavailable}
+	}
+	if _, scanErr := fmt.Sscanf(msg[i:], "HTTP %d:", &status); scanErr != nil {
+		return err
+	}
+	msg = strings.TrimSpace(msg[i+len(fmt.Sprintf("HTTP %d:", status)):])
+
+	var kind error
+	switch status {
+	case 400:
+		kind = filesystem.ErrInvalidArgument
+	case 401, 403:
+		kind = filesystem.ErrPermissionDenied
+	case 404:
+		kind = filesystem.ErrNotFound
+	case 409:
+		kind = filesystem.ErrAlreadyExists
+		if strings.Contains(msg, filesystem.ErrConflict.Error()) {
+			kind = filesystem.ErrConflict
+		}
+	case 413:
+		kind = filesystem.ErrQuotaExceeded
+	case 501:
+		kind = filesystem.ErrNotSupported
+	case 503:
+		kind = filesystem.ErrUnavailable
+	case 507:
+		kind = filesystem.ErrNoSpace
+	default:
+		return err
+	}
+	return &remoteErr{msg: msg, kind: kind}
+}
+
+// sdkErrors maps the errors of the SDK to filesystem errors
+var sdkErrors = []struct{ sdk, kind error }{
+	{agfs.ErrInvalidArgument, filesystem.ErrInvalidArgument},
+	{agfs.ErrUnauthorized, filesystem.ErrPermissionDenied},
+	{agfs.ErrPermissionDenied, filesystem.ErrPermissionDenied},
+	{agfs.ErrNotFound, filesystem.ErrNotFound},
+	{agfs.ErrAlreadyExists, filesystem.ErrAlreadyExists},
+	{agfs.ErrQuotaExceeded, filesystem.ErrQuotaExceeded},
+	{agfs.ErrUnavailable, filesystem.ErrUnavailable},
+	{agfs.ErrNoSpace, filesystem.ErrNoSpace},
+}
+
+// remoteErr is an error returned by the remote server
+type remoteErr struct {
+	msg  string
+	kind error
+}
+
+func (e *remoteErr) Error() string {
+	return "remote: " + e.msg
+}
+
+func (e *remoteErr) Is(target error) bool {
+	return target == e.kind
+}
+
+// agfsFS implements the FileSystem interface on a remote server
+type agfsFS struct {
+	plugin *AGFSFSPlugin
+}
+
+func (fs *agfsFS) client() *agfs.Client {
+	return fs.plugin.client
+}
+
+// fileInfo converts a FileInfo of the SDK
+func fileInfo(src agfs.FileInfo) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    src.Name,
+		Size:    src.Size,
+		Mode:    src.Mode,
+		ModTime: src.ModTime,
+		IsDir:   src.IsDir,
+		Meta: filesystem.MetaData{
+			Name:    src.Meta.Name,
+			Type:    src.Meta.Type,
+			Content: src.Meta.Content,
+		},
+		Version: src.Version,
+	}
+}
+
+func (fs *agfsFS) Create(path string) error {
+	return remoteError(fs.client().Create(fs.plugin.remotePath(path)))
+}
+
+func (fs *agfsFS) Mkdir(path string, perm uint32) error {
+	return remoteError(fs.client().Mkdir(fs.plugin.remotePath(path), perm))
+}
+
+func (fs *agfsFS) Remove(path string) error {
+	return remoteError(fs.client().Remove(fs.plugin.remotePath(path)))
+}
+
+func (fs *agfsFS) RemoveAll(path string) error {
+	return remoteError(fs.client().RemoveAll(fs.plugin.remotePath(path)))
+}
+
+func (fs *agfsFS) Read(path string, offset int64, size int64) ([]byte, error) {
+	data, err := fs.client().Read(fs.plugin.remotePath(path), offset, size)
+	if err != nil {
+		return nil, remoteError(err)
+	}
+	return data, nil
+}
+
+// Write overwrites remote files with one request; writes at an offset and
+// appends go through a remote file handle
+func (fs *agfsFS) Write(path string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	remote := fs.plugin.remotePath(path)
+
+	// Offset -1 without flags is the legacy overwrite, which creates the file
+	legacy := flags == filesystem.WriteFlagNone && offset < 0
+	if flags&filesystem.WriteFlagExclusive != 0 || (flags&filesystem.WriteFlagCreate == 0 && !legacy) {
+		_, err := fs.client().Stat(remote)
+		if err == nil && flags&filesystem.WriteFlagExclusive != 0 {
+			return 0, filesystem.NewAlreadyExistsError("file", path)
+		}
+		if err != nil && flags&filesystem.WriteFlagCreate == 0 {
+			return 0, remoteError(err)
+		}
+	}
+	if flags&filesystem.WriteFlagAppend == 0 && (offset < 0 || (offset == 0 && flags&filesystem.WriteFlagTruncate != 0)) {
+		if _, err := fs.client().Write(remote, data); err != nil {
+			return 0, remoteError(err)
+		}
+		return int64(len(data)), nil
+	}
+
+	if !fs.plugin.hasFeature("handlefs") {
+		return 0, filesystem.NewNotSupportedError("write at offset", path)
+	}
+	openFlags := filesystem.O_WRONLY
+	if flags&filesystem.WriteFlagCreate != 0 {
+		openFlags |= filesystem.O_CREATE
+	}
+	if flags&filesystem.WriteFlagTruncate != 0 {
+		openFlags |= filesystem.O_TRUNC
+	}
+	id, err := fs.client().OpenHandle(remote, agfs.OpenFlag(openFlags), 0644)
+	if err != nil {
+		return 0, remoteError(err)
+	}
+	defer fs.client().CloseHandle(id)
+
+	if flags&filesystem.WriteFlagAppend != 0 {
+		info, err := fs.client().StatHandle(id)
+		if err != nil {
+			return 0, remoteError(err)
+		}
+		offset = info.Size
+	}
+	n, err := fs.client().WriteHandle(id, data, offset)
+	if err != nil {
+		return int64(n), remoteError(err)
+	}
+	if flags&filesystem.WriteFlagSync != 0 {
+		if err := fs.client().SyncHandle(id); err != nil {
+			return int64(n), remoteError(err)
+		}
+	}
+	return int64(n), nil
+}
+
+func (fs *agfsFS) ReadDir(path string) ([]filesystem.FileInfo, error) {
+	entries, err := fs.client().ReadDir(fs.plugin.remotePath(path))
+	if err != nil {
+		// The protocol has no status for "not a directory"
+		if info, statErr := fs.client().Stat(fs.plugin.remotePath(path)); statErr == nil && !info.IsDir {
+			return nil, filesystem.NewNotDirectoryError(path)
+		}
+		return nil, remoteError(err)
+	}
+	files := make([]filesystem.FileInfo, len(entries))
+	for i, entry := range entries {
+		files[i] = fileInfo(entry)
+	}
+	return files, nil
+}
+
+// Stat returns the remote file info; the root also lists the remote server's
+// URL, version and features
+func (fs *agfsFS) Stat(path string) (*filesystem.FileInfo, error) {
+	remote, err := fs.client().Stat(fs.plugin.remotePath(path))
+	if err != nil {
+		return nil, remoteError(err)
+	}
+	info := fileInfo(*remote)
+	if filesystem.NormalizePath(path) == "/" {
+		content := make(map[string]string, len(info.Meta.Content)+3)
+		for k, v := range info.Meta.Content {
+			content[k] = v
+		}
+		content["remote_url"] = fs.plugin.url
+		content["remote_version"] = fs.plugin.version
+		content["remote_features"] = strings.Join(fs.plugin.features, ",")
+		info.Meta = filesystem.MetaData{Name: PluginName, Type: "remote", Content: content}
+	}
+	return &info, nil
+}
+
+func (fs *agfsFS) Rename(oldPath, newPath string) error {
+	return remoteError(fs.client().Rename(fs.plugin.remotePath(oldPath), fs.plugin.remotePath(newPath)))
+}
+
+func (fs *agfsFS) Chmod(path string, mode uint32) error {
+	return remoteError(fs.client().Chmod(fs.plugin.remotePath(path), mode))
+}
+
+func (fs *agfsFS) Truncate(path string, size int64) error {
+	return remoteError(fs.client().Truncate(fs.plugin.remotePath(path), size))
+}
+
+func (fs *agfsFS) Open(path string) (io.ReadCloser, error) {
+	data, err := fs.Read(path, 0, -1)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return io.NopCloser(strings.NewReader(string(data))), nil
+}
+
+func (fs *agfsFS) OpenWrite(path string) (io.WriteCloser, error) {
+	return filesystem.NewBufferedWriter(path, fs.Write), nil
+}
+
+// OpenStream implements filesystem.Streamer with a streaming read of the
+// remote file
+func (fs *agfsFS) OpenStream(path string) (filesystem.StreamReader, error) {
+	body, err := fs.client().ReadStream(fs.plugin.remotePath(path))
+	if err != nil {
+		return nil, remoteError(err)
+	}
+	return &streamReader{body: body, buf: make([]byte, 64*1024)}, nil
+}
+
+// streamReader reads chunks of a remote stream
+type streamReader struct {
+	body io.ReadCloser
+	buf  []byte
+}
+
+// ReadChunk returns the next chunk of the stream; the timeout is not enforced
+// as HTTP response bodies have no read deadline
+func (sr *streamReader) ReadChunk(timeout time.Duration) ([]byte, bool, error) {
+	n, err := sr.body.Read(sr.buf)
+	if n > 0 {
+		return append([]byte(nil), sr.buf[:n]...), false, nil
+	}
+	if err == io.EOF {
+		return nil, true, io.EOF
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return nil, false, fmt.Errorf("read timeout")
+}
+
+func (sr *streamReader) Close() error {
+	return sr.body.Close()
+}
@@ -0,0 +1,210 @@
+package agfsfs
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem/filesystemtest"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/handlers"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/mountablefs"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/api"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/memfs"
+)
+
+// startRemote starts an agfs-server with a memfs mounted at /mem and returns
+// an agfsfs mounting its /mem directory
+func startRemote(t *testing.T) (*AGFSFSPlugin, filesystem.FileSystem) {
+	t.Helper()
+	mfs := mountablefs.NewMountableFS(api.PoolConfig{})
+	mem := memfs.NewMemFSPlugin()
+	if err := mem.Initialize(map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.Mount("/mem", mem); err != nil {
+		t.Fatal(err)
+	}
+	h := handlers.NewHandler(mfs, nil)
+	mux := http.NewServeMux()
+	h.SetupRoutes(mux)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	p := NewAGFSFSPlugin()
+	cfg := map[string]interface{}{"url": server.URL, "root": "/mem"}
+	if err := p.Validate(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Initialize(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Shutdown() })
+	return p, p.GetFileSystem()
+}
+
+func readAll(t *testing.T, fs filesystem.FileSystem, path string) string {
+	t.Helper()
+	data, err := fs.Read(path, 0, -1)
+	if err != nil && err != io.EOF {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestValidate(t *testing.T) {
+	p := NewAGFSFSPlugin()
+	for _, cfg := range []map[string]interface{}{
+		{},
+		{"url": "remote:8080"},
+		{"url": "http://remote:8080", "wire_compression": "gzip"},
+		{"url": "http://remote:8080", "base_url": "http://remote:8080"},
+	} {
+		if err := p.Validate(cfg); err == nil {
+			t.Errorf("Validate(%v) succeeded", cfg)
+		}
+	}
+	if err := p.Validate(map[string]interface{}{"url": "unix:///run/agfs.sock", "root": "/data"}); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	_, fs := startRemote(t)
+
+	if _, err := fs.Write("/a.txt", []byte("hello world"), -1, filesystem.WriteFlagCreate|filesystem.WriteFlagTruncate); err != nil {
+		t.Fatal(err)
+	}
+	if got := readAll(t, fs, "/a.txt"); got != "hello world" {
+		t.Fatalf("read = %q", got)
+	}
+
+	// Writes at an offset and appends go through remote handles
+	if _, err := fs.Write("/a.txt", []byte("WORLD"), 6, filesystem.WriteFlagNone); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Write("/a.txt", []byte("!"), 0, filesystem.WriteFlagAppend); err != nil {
+		t.Fatal(err)
+	}
+	if got := readAll(t, fs, "/a.txt"); got != "hello WORLD!" {
+		t.Fatalf("read = %q", got)
+	}
+
+	data, err := fs.Read("/a.txt", 6, 5)
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	if string(data) != "WORLD" {
+		t.Fatalf("range read = %q", data)
+	}
+
+	if err := fs.Mkdir("/dir", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Rename("/a.txt", "/dir/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := fs.ReadDir("/dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name != "b.txt" || entries[0].Size != 12 {
+		t.Fatalf("entries = %+v", entries)
+	}
+	info, err := fs.Stat("/dir/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version == "" {
+		t.Error("remote version not passed through")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	_, fs := startRemote(t)
+
+	if _, err := fs.Stat("/missing"); err == nil {
+		t.Error("stat missing succeeded")
+	}
+	if _, err := fs.Write("/x", []byte("x"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatal(err)
+	}
+	_, err := fs.Write("/x", []byte("x"), -1, filesystem.WriteFlagCreate|filesystem.WriteFlagExclusive)
+	if !errors.Is(err, filesystem.ErrAlreadyExists) {
+		t.Errorf("exclusive write: %v", err)
+	}
+
+	if err := remoteError(errors.New("HTTP 404: file not found: /x")); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("404: %v", err)
+	}
+	if err := remoteError(errors.New("HTTP 409: version conflict on /x")); !errors.Is(err, filesystem.ErrConflict) {
+		t.Errorf("409 conflict: %v", err)
+	}
+	if err := remoteError(errors.New("HTTP 503: mount /db is unavailable")); !errors.Is(err, filesystem.ErrUnavailable) {
+		t.Errorf("503: %v", err)
+	}
+}
+
+func TestHandles(t *testing.T) {
+	_, fs := startRemote(t)
+	handleFS := fs.(filesystem.HandleFS)
+
+	h, err := handleFS.OpenHandle("/h.txt", filesystem.O_RDWR|filesystem.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Write([]byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Write([]byte("def")); err != nil {
+		t.Fatal(err)
+	}
+	if pos, err := h.Seek(1, io.SeekStart); err != nil || pos != 1 {
+		t.Fatalf("seek = %d, %v", pos, err)
+	}
+	buf := make([]byte, 3)
+	if n, err := h.Read(buf); err != nil || string(buf[:n]) != "bcd" {
+		t.Fatalf("read = %q, %v", buf[:n], err)
+	}
+
+	same, err := handleFS.GetHandle(h.ID())
+	if err != nil || same != h {
+		t.Fatalf("GetHandle = %v, %v", same, err)
+	}
+	if err := handleFS.CloseHandle(h.ID()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := handleFS.GetHandle(h.ID()); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("closed handle: %v", err)
+	}
+	if got := readAll(t, fs, "/h.txt"); got != "abcdef" {
+		t.Errorf("file = %q", got)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	p, fs := startRemote(t)
+	if !p.hasFeature("handlefs") {
+		t.Fatalf("features = %v", p.features)
+	}
+	info, err := fs.Stat("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Meta.Name != PluginName || !strings.Contains(info.Meta.Content["remote_features"], "handlefs") {
+		t.Errorf("root meta = %+v", info.Meta)
+	}
+	if err := p.HealthCheck(); err != nil {
+		t.Errorf("HealthCheck: %v", err)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	filesystemtest.Run(t, func(t *testing.T) filesystem.FileSystem {
+		_, fs := startRemote(t)
+		return fs
+	}, filesystemtest.Options{})
+}
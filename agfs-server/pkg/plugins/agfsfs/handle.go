@@ -0,0 +1,161 @@
+package agfsfs
+
+import (
+	"io"
+	"sync"
+
+	agfs "github.com/c4pt0r/agfs/agfs-sdk/go"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+// OpenHandle opens a handle on the remote server; the handle keeps the ID the
+// remote server gave it
+func (fs *agfsFS) OpenHandle(path string, flags filesystem.OpenFlag, mode uint32) (filesystem.FileHandle, error) {
+	if !fs.plugin.hasFeature("handlefs") {
+		return nil, filesystem.NewNotSupportedError("openhandle", path)
+	}
+	// The handle API of the remote server takes the same flag values
+	id, err := fs.client().OpenHandle(fs.plugin.remotePath(path), agfs.OpenFlag(flags), mode)
+	if err != nil {
+		return nil, remoteError(err)
+	}
+
+	h := &remoteHandle{fs: fs, id: id, path: path, flags: flags}
+	fs.plugin.mu.Lock()
+	fs.plugin.handles[id] = h
+	fs.plugin.mu.Unlock()
+	return h, nil
+}
+
+func (fs *agfsFS) GetHandle(id int64) (filesystem.FileHandle, error) {
+	fs.plugin.mu.Lock()
+	defer fs.plugin.mu.Unlock()
+	h, ok := fs.plugin.handles[id]
+	if !ok {
+		return nil, filesystem.ErrNotFound
+	}
+	return h, nil
+}
+
+func (fs *agfsFS) CloseHandle(id int64) error {
+	h, err := fs.GetHandle(id)
+	if err != nil {
+		return err
+	}
+	return h.Close()
+}
+
+// remoteHandle is a file handle open on the remote server. The position is
+// kept locally, so that every read and write names its offset and a retried
+// request cannot move it twice.
+type remoteHandle struct {
+	fs    *agfsFS
+	id    int64
+	path  string
+	flags filesystem.OpenFlag
+
+	mu  sync.Mutex
+	pos int64
+}
+
+func (h *remoteHandle) ID() int64 {
+	return h.id
+}
+
+func (h *remoteHandle) Path() string {
+	return h.path
+}
+
+func (h *remoteHandle) Flags() filesystem.OpenFlag {
+	return h.flags
+}
+
+func (h *remoteHandle) Read(buf []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n, err := h.ReadAt(buf, h.pos)
+	h.pos += int64(n)
+	return n, err
+}
+
+func (h *remoteHandle) ReadAt(buf []byte, offset int64) (int, error) {
+	data, err := h.fs.client().ReadHandle(h.id, offset, len(buf))
+	if err != nil {
+		return 0, remoteError(err)
+	}
+	n := copy(buf, data)
+	if n == 0 && len(buf) > 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+func (h *remoteHandle) Write(data []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.flags&filesystem.O_APPEND != 0 {
+		info, err := h.Stat()
+		if err != nil {
+			return 0, err
+		}
+		h.pos = info.Size
+	}
+	n, err := h.WriteAt(data, h.pos)
+	h.pos += int64(n)
+	return n, err
+}
+
+func (h *remoteHandle) WriteAt(data []byte, offset int64) (int, error) {
+	n, err := h.fs.client().WriteHandle(h.id, data, offset)
+	if err != nil {
+		return n, remoteError(err)
+	}
+	return n, nil
+}
+
+func (h *remoteHandle) Seek(offset int64, whence int) (int64, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var base int64
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		base = h.pos
+	case io.SeekEnd:
+		info, err := h.Stat()
+		if err != nil {
+			return 0, err
+		}
+		base = info.Size
+	default:
+		return 0, filesystem.NewInvalidArgumentError("whence", whence, "must be 0, 1 or 2")
+	}
+	if base+offset < 0 {
+		return 0, filesystem.NewInvalidArgumentError("offset", offset, "negative position")
+	}
+	h.pos = base + offset
+	return h.pos, nil
+}
+
+func (h *remoteHandle) Sync() error {
+	return remoteError(h.fs.client().SyncHandle(h.id))
+}
+
+// Close closes the remote handle; it is forgotten even if the remote server
+// failed to close it, as it cannot be used again either way
+func (h *remoteHandle) Close() error {
+	h.fs.plugin.mu.Lock()
+	delete(h.fs.plugin.handles, h.id)
+	h.fs.plugin.mu.Unlock()
+	return remoteError(h.fs.client().CloseHandle(h.id))
+}
+
+func (h *remoteHandle) Stat() (*filesystem.FileInfo, error) {
+	remote, err := h.fs.client().StatHandle(h.id)
+	if err != nil {
+		return nil, remoteError(err)
+	}
+	info := fileInfo(*remote)
+	return &info, nil
+}
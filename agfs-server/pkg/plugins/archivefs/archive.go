@@ -0,0 +1,248 @@
+package archivefs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+// Supported archive formats
+const (
+	FormatZip   = "zip"
+	FormatTar   = "tar"
+	FormatTarGz = "tar.gz"
+)
+
+// archiveFormat returns the format of an archive file name, or "" if it isn't one
+func archiveFormat(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return FormatZip
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return FormatTarGz
+	case strings.HasSuffix(lower, ".tar"):
+		return FormatTar
+	}
+	return ""
+}
+
+// archiveEntry is a file or directory inside an archive
+type archiveEntry struct {
+	Name    string // Path inside the archive, without leading or trailing slashes
+	Size    int64
+	Mode    uint32
+	ModTime time.Time
+	IsDir   bool
+}
+
+// archiveIndex lists the contents of an archive
+type archiveIndex struct {
+	format   string
+	entries  map[string]*archiveEntry
+	children map[string][]string // Directory -> sorted child names ("" is the archive root)
+}
+
+func newArchiveIndex(format string) *archiveIndex {
+	return &archiveIndex{
+		format:   format,
+		entries:  make(map[string]*archiveEntry),
+		children: make(map[string][]string),
+	}
+}
+
+// add registers an entry and any implicit parent directories
+func (idx *archiveIndex) add(e *archiveEntry) {
+	e.Name = strings.Trim(path.Clean("/"+e.Name), "/")
+	if e.Name == "" {
+		return
+	}
+	if existing, ok := idx.entries[e.Name]; ok {
+		// Explicit directory headers may follow implicit ones; later file entries win (tar semantics)
+		if existing.IsDir && e.IsDir {
+			existing.Mode, existing.ModTime = e.Mode, e.ModTime
+			return
+		}
+	} else {
+		parent := path.Dir(e.Name)
+		if parent == "." {
+			parent = ""
+		}
+		idx.children[parent] = append(idx.children[parent], path.Base(e.Name))
+	}
+	idx.entries[e.Name] = e
+
+	for dir := path.Dir(e.Name); dir != "."; dir = path.Dir(dir) {
+		if _, ok := idx.entries[dir]; ok {
+			break
+		}
+		idx.add(&archiveEntry{Name: dir, Mode: 0555, ModTime: e.ModTime, IsDir: true})
+	}
+}
+
+func (idx *archiveIndex) finish() {
+	for dir := range idx.children {
+		sort.Strings(idx.children[dir])
+	}
+}
+
+// cost estimates the memory used by the index for cache accounting
+func (idx *archiveIndex) cost() int64 {
+	var n int64
+	for name := range idx.entries {
+		n += int64(2*len(name)) + 96
+	}
+	return n
+}
+
+// readerAt adapts ranged reads on an AGFS file to io.ReaderAt
+type readerAt struct {
+	fs   filesystem.FileSystem
+	path string
+}
+
+func (r *readerAt) ReadAt(p []byte, off int64) (int, error) {
+	data, err := r.fs.Read(r.path, off, int64(len(p)))
+	n := copy(p, data)
+	if err != nil && err != io.EOF {
+		return n, err
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// openZip opens a zip archive through ranged reads, without downloading it
+func openZip(fs filesystem.FileSystem, archivePath string, size int64) (*zip.Reader, error) {
+	zr, err := zip.NewReader(&readerAt{fs: fs, path: archivePath}, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip %s: %w", archivePath, err)
+	}
+	return zr, nil
+}
+
+// tarReadBuffer is the size of ranged reads when streaming a tar archive
+const tarReadBuffer = 256 * 1024
+
+// openTar streams a tar archive through ranged reads, decompressing it if needed
+func openTar(fs filesystem.FileSystem, archivePath, format string, size int64) (*tar.Reader, error) {
+	r := bufio.NewReaderSize(io.NewSectionReader(&readerAt{fs: fs, path: archivePath}, 0, size), tarReadBuffer)
+	if format != FormatTarGz {
+		return tar.NewReader(r), nil
+	}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open gzip stream %s: %w", archivePath, err)
+	}
+	return tar.NewReader(gz), nil
+}
+
+// buildIndex reads the table of contents of an archive
+// For zip only the central directory is read; tar archives are scanned once.
+func buildIndex(fs filesystem.FileSystem, archivePath, format string, size int64) (*archiveIndex, error) {
+	idx := newArchiveIndex(format)
+
+	if format == FormatZip {
+		zr, err := openZip(fs, archivePath, size)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range zr.File {
+			idx.add(&archiveEntry{
+				Name:    f.Name,
+				Size:    int64(f.UncompressedSize64),
+				Mode:    uint32(f.Mode().Perm()),
+				ModTime: f.Modified,
+				IsDir:   f.FileInfo().IsDir(),
+			})
+		}
+		idx.finish()
+		return idx, nil
+	}
+
+	tr, err := openTar(fs, archivePath, format, size)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tar %s: %w", archivePath, err)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			idx.add(&archiveEntry{Name: hdr.Name, Mode: uint32(hdr.FileInfo().Mode().Perm()), ModTime: hdr.ModTime, IsDir: true})
+		case tar.TypeReg:
+			idx.add(&archiveEntry{Name: hdr.Name, Size: hdr.Size, Mode: uint32(hdr.FileInfo().Mode().Perm()), ModTime: hdr.ModTime})
+		}
+		// Links, devices and other special entries are skipped
+	}
+	idx.finish()
+	return idx, nil
+}
+
+// extract returns the content of one file inside an archive
+func extract(fs filesystem.FileSystem, archivePath, format string, size int64, name string) ([]byte, error) {
+	if format == FormatZip {
+		zr, err := openZip(fs, archivePath, size)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range zr.File {
+			if strings.Trim(path.Clean("/"+f.Name), "/") != name {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, fmt.Errorf("failed to extract %s: %w", name, err)
+			}
+			defer rc.Close()
+			return io.ReadAll(rc)
+		}
+		return nil, filesystem.NewNotFoundError("read", name)
+	}
+
+	tr, err := openTar(fs, archivePath, format, size)
+	if err != nil {
+		return nil, err
+	}
+
+	// The last entry with a name wins, as with tar extraction
+	var data []byte
+	found := false
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tar %s: %w", archivePath, err)
+		}
+		if hdr.Typeflag != tar.TypeReg || strings.Trim(path.Clean("/"+hdr.Name), "/") != name {
+			continue
+		}
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, tr); err != nil {
+			return nil, fmt.Errorf("failed to extract %s: %w", name, err)
+		}
+		data, found = buf.Bytes(), true
+	}
+	if !found {
+		return nil, filesystem.NewNotFoundError("read", name)
+	}
+	return data, nil
+}
@@ -0,0 +1,502 @@
+package archivefs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PluginName = "archivefs" // Name of this plugin
+)
+
+// Meta values for ArchiveFS plugin
+const (
+	MetaValueDir     = "dir"     // Directory passed through from the parent filesystem
+	MetaValueArchive = "archive" // Archive presented as a directory
+	MetaValueEntry   = "entry"   // File or directory inside an archive
+)
+
+// defaultCacheSize is the default size of the extraction cache
+const defaultCacheSize = 64 * 1024 * 1024
+
+// ArchiveFSPlugin presents zip and tar archives stored on other mounts as directories
+// Paths mirror the AGFS tree; any .zip, .tar, .tar.gz or .tgz file becomes a
+// read-only directory of its contents:
+//
+//	/archivefs/s3fs/builds/release.zip/bin/app -> bin/app inside /s3fs/builds/release.zip
+//
+// Only archive files and directories are listed. Archive indexes and extracted
+// files are kept in a size-bounded LRU cache.
+type ArchiveFSPlugin struct {
+	rootFS    filesystem.FileSystem
+	source    string
+	mountPath string
+	cache     *extractCache
+	metadata  plugin.PluginMetadata
+}
+
+// NewArchiveFSPlugin creates a new archive browsing plugin
+func NewArchiveFSPlugin() *ArchiveFSPlugin {
+	return &ArchiveFSPlugin{
+		metadata: plugin.PluginMetadata{
+			Name:        PluginName,
+			Version:     "1.0.0",
+			Description: "Browse zip and tar archives on other mounts as read-only directories",
+			Author:      "AGFS Server",
+		},
+	}
+}
+
+func (a *ArchiveFSPlugin) Name() string {
+	return a.metadata.Name
+}
+
+func (a *ArchiveFSPlugin) Validate(cfg map[string]interface{}) error {
+	allowedKeys := []string{"mount_path", "source", "cache_size"}
+	if err := config.ValidateOnlyKnownKeys(cfg, allowedKeys); err != nil {
+		return err
+	}
+
+	if err := config.ValidateStringType(cfg, "source"); err != nil {
+		return err
+	}
+	if source := config.GetStringConfig(cfg, "source", "/"); !strings.HasPrefix(source, "/") {
+		return fmt.Errorf("source must be an absolute AGFS path")
+	}
+	if size, err := config.GetSizeConfig(cfg, "cache_size", defaultCacheSize); err != nil {
+		return fmt.Errorf("invalid cache_size: %w", err)
+	} else if size < 0 {
+		return fmt.Errorf("cache_size must not be negative")
+	}
+	return nil
+}
+
+func (a *ArchiveFSPlugin) Initialize(cfg map[string]interface{}) error {
+	cacheSize, err := config.GetSizeConfig(cfg, "cache_size", defaultCacheSize)
+	if err != nil {
+		return fmt.Errorf("invalid cache_size: %w", err)
+	}
+
+	a.source = path.Clean(config.GetStringConfig(cfg, "source", "/"))
+	a.mountPath = config.GetStringConfig(cfg, "mount_path", "")
+	a.cache = newExtractCache(cacheSize)
+
+	log.Infof("[archivefs] Initialized (source=%s, cache_size=%d)", a.source, cacheSize)
+	return nil
+}
+
+// SetParentFileSystem gives the plugin access to the AGFS tree holding the archives
+// This is called by the mount system, either before or after Initialize.
+func (a *ArchiveFSPlugin) SetParentFileSystem(fs filesystem.FileSystem) {
+	a.rootFS = fs
+}
+
+func (a *ArchiveFSPlugin) GetFileSystem() filesystem.FileSystem {
+	return &archiveFS{plugin: a}
+}
+
+func (a *ArchiveFSPlugin) GetReadme() string {
+	return `ArchiveFS Plugin - Browse Archives in Place
+
+This plugin mirrors the AGFS tree and presents every zip or tar archive as a
+read-only directory, so archives on any mount can be inspected without
+downloading or unpacking them first.
+
+STRUCTURE:
+  /archivefs/
+    README                          - This documentation
+    <path>/                         - Directories of the source tree
+    <path>/<name>.zip/              - Archive contents as a directory
+    <path>/<name>.tar.gz/<file>     - A file inside an archive (read-only)
+
+  Supported formats: .zip, .tar, .tar.gz, .tgz
+  Regular files that are not archives are not shown.
+
+EXAMPLES:
+  # Browse a build artifact stored on S3
+  agfs:/> ls /archivefs/s3fs/builds/release-1.2.zip
+  agfs:/> cat /archivefs/s3fs/builds/release-1.2.zip/VERSION
+
+  # Inspect a log bundle
+  agfs:/> ls /archivefs/local/bundles/incident.tar.gz/logs
+  agfs:/> grep ERROR /archivefs/local/bundles/incident.tar.gz/logs/app.log
+
+CONFIGURATION:
+  [plugins.archivefs]
+  enabled = true
+  path = "/archivefs"
+
+    [plugins.archivefs.config]
+    source = "/"            # AGFS directory mirrored by the plugin
+    cache_size = "64MB"     # bounds cached indexes and extracted files
+
+NOTES:
+  - Zip archives are read with ranged reads: listing one only fetches its
+    central directory, and reading a file fetches just that file.
+  - Tar archives have no index, so the first listing scans the whole stream
+    and reading a file scans it again unless the file is cached.
+  - Files larger than cache_size are extracted again on every read.
+  - A modified archive is detected by its size and modification time.
+`
+}
+
+func (a *ArchiveFSPlugin) GetConfigParams() []plugin.ConfigParameter {
+	return []plugin.ConfigParameter{
+		{
+			Name:        "source",
+			Type:        "string",
+			Required:    false,
+			Default:     "/",
+			Description: "AGFS directory mirrored by the plugin",
+		},
+		{
+			Name:        "cache_size",
+			Type:        "string",
+			Required:    false,
+			Default:     "64MB",
+			Description: "Maximum size of cached archive indexes and extracted files",
+		},
+	}
+}
+
+func (a *ArchiveFSPlugin) Shutdown() error {
+	if a.cache != nil {
+		a.cache.Clear()
+	}
+	return nil
+}
+
+// archiveFS implements the FileSystem interface for archive browsing
+type archiveFS struct {
+	plugin *ArchiveFSPlugin
+}
+
+// resolvedPath is a plugin path mapped onto the parent filesystem
+type resolvedPath struct {
+	source  string // Path on the parent filesystem: a directory, or the archive file
+	format  string // Archive format, empty if source is a plain directory
+	size    int64  // Archive size
+	modTime time.Time
+	inner   string // Path inside the archive, empty for its root
+}
+
+func (r *resolvedPath) isArchive() bool {
+	return r.format != ""
+}
+
+// cacheKey identifies an archive version, so cached data from older versions is never used
+func (r *resolvedPath) cacheKey(kind string) string {
+	return fmt.Sprintf("%s:%s:%d:%d:%s", kind, r.source, r.size, r.modTime.UnixNano(), r.inner)
+}
+
+// isOwnMount reports whether a parent path is this plugin's own mount
+// Descending into it would mirror the plugin inside itself.
+func (afs *archiveFS) isOwnMount(p string) bool {
+	mount := afs.plugin.mountPath
+	return mount != "" && mount != "/" && (p == mount || strings.HasPrefix(p, mount+"/"))
+}
+
+// resolve walks a path until the first component that is an archive file
+func (afs *archiveFS) resolve(op, p string) (*resolvedPath, error) {
+	rootFS := afs.plugin.rootFS
+	if rootFS == nil {
+		return nil, fmt.Errorf("archivefs has no parent filesystem; it must be mounted in AGFS")
+	}
+
+	cur := afs.plugin.source
+	trimmed := strings.Trim(path.Clean("/"+p), "/")
+	if trimmed == "" {
+		return &resolvedPath{source: cur}, nil
+	}
+
+	parts := strings.Split(trimmed, "/")
+	for i, part := range parts {
+		cur = path.Join(cur, part)
+		if afs.isOwnMount(cur) {
+			return nil, filesystem.NewNotFoundError(op, p)
+		}
+		format := archiveFormat(part)
+		if format == "" {
+			continue
+		}
+		info, err := rootFS.Stat(cur)
+		if err != nil {
+			return nil, filesystem.NewNotFoundError(op, p)
+		}
+		if info.IsDir {
+			continue
+		}
+		return &resolvedPath{
+			source:  cur,
+			format:  format,
+			size:    info.Size,
+			modTime: info.ModTime,
+			inner:   strings.Join(parts[i+1:], "/"),
+		}, nil
+	}
+	return &resolvedPath{source: cur}, nil
+}
+
+// index returns the archive index, building it on first access
+func (afs *archiveFS) index(r *resolvedPath) (*archiveIndex, error) {
+	key := (&resolvedPath{source: r.source, size: r.size, modTime: r.modTime}).cacheKey("index")
+	if cached, ok := afs.plugin.cache.Get(key); ok {
+		return cached.(*archiveIndex), nil
+	}
+
+	idx, err := buildIndex(afs.plugin.rootFS, r.source, r.format, r.size)
+	if err != nil {
+		return nil, err
+	}
+	afs.plugin.cache.Put(key, idx, idx.cost())
+	return idx, nil
+}
+
+// entry looks up the archive entry for a resolved path
+func (afs *archiveFS) entry(op, p string, r *resolvedPath) (*archiveIndex, *archiveEntry, error) {
+	idx, err := afs.index(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if r.inner == "" {
+		return idx, nil, nil
+	}
+	e, ok := idx.entries[r.inner]
+	if !ok {
+		return nil, nil, filesystem.NewNotFoundError(op, p)
+	}
+	return idx, e, nil
+}
+
+// content returns an extracted archive file, from the cache when possible
+func (afs *archiveFS) content(r *resolvedPath) ([]byte, error) {
+	key := r.cacheKey("file")
+	if cached, ok := afs.plugin.cache.Get(key); ok {
+		return cached.([]byte), nil
+	}
+
+	data, err := extract(afs.plugin.rootFS, r.source, r.format, r.size, r.inner)
+	if err != nil {
+		return nil, err
+	}
+	afs.plugin.cache.Put(key, data, int64(len(data)))
+	return data, nil
+}
+
+func dirInfo(name, metaType string, modTime time.Time, content map[string]string) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    name,
+		Size:    0,
+		Mode:    0555,
+		ModTime: modTime,
+		IsDir:   true,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: metaType, Content: content},
+	}
+}
+
+func entryInfo(e *archiveEntry) filesystem.FileInfo {
+	if e.IsDir {
+		return dirInfo(path.Base(e.Name), MetaValueEntry, e.ModTime, nil)
+	}
+	return filesystem.FileInfo{
+		Name:    path.Base(e.Name),
+		Size:    e.Size,
+		Mode:    e.Mode &^ 0222,
+		ModTime: e.ModTime,
+		IsDir:   false,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: MetaValueEntry},
+	}
+}
+
+func archiveInfo(name string, r *resolvedPath, idx *archiveIndex) filesystem.FileInfo {
+	content := map[string]string{
+		"format": r.format,
+		"source": r.source,
+		"size":   fmt.Sprintf("%d", r.size),
+	}
+	if idx != nil {
+		content["entries"] = fmt.Sprintf("%d", len(idx.entries))
+	}
+	return dirInfo(name, MetaValueArchive, r.modTime, content)
+}
+
+func (afs *archiveFS) readmeInfo() filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    "README",
+		Size:    int64(len(afs.plugin.GetReadme())),
+		Mode:    0444,
+		ModTime: time.Now(),
+		IsDir:   false,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "doc"},
+	}
+}
+
+func (afs *archiveFS) readOnly(op, p string) error {
+	return filesystem.NewPermissionDeniedError(op, p, "archivefs is read-only")
+}
+
+func (afs *archiveFS) Create(path string) error {
+	return afs.readOnly("create", path)
+}
+
+func (afs *archiveFS) Mkdir(path string, perm uint32) error {
+	return afs.readOnly("mkdir", path)
+}
+
+func (afs *archiveFS) Remove(path string) error {
+	return afs.readOnly("remove", path)
+}
+
+func (afs *archiveFS) RemoveAll(path string) error {
+	return afs.readOnly("remove", path)
+}
+
+func (afs *archiveFS) Read(path string, offset int64, size int64) ([]byte, error) {
+	if path == "/README" {
+		return plugin.ApplyRangeRead([]byte(afs.plugin.GetReadme()), offset, size)
+	}
+
+	r, err := afs.resolve("read", path)
+	if err != nil {
+		return nil, err
+	}
+	if !r.isArchive() {
+		info, err := afs.plugin.rootFS.Stat(r.source)
+		if err != nil || !info.IsDir {
+			return nil, filesystem.NewNotFoundError("read", path)
+		}
+		return nil, fmt.Errorf("is a directory: %s", path)
+	}
+
+	_, e, err := afs.entry("read", path, r)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil || e.IsDir {
+		return nil, fmt.Errorf("is a directory: %s", path)
+	}
+
+	data, err := afs.content(r)
+	if err != nil {
+		return nil, err
+	}
+	return plugin.ApplyRangeRead(data, offset, size)
+}
+
+func (afs *archiveFS) Write(path string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	return 0, afs.readOnly("write", path)
+}
+
+func (afs *archiveFS) ReadDir(p string) ([]filesystem.FileInfo, error) {
+	r, err := afs.resolve("readdir", p)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.isArchive() {
+		idx, e, err := afs.entry("readdir", p, r)
+		if err != nil {
+			return nil, err
+		}
+		if e != nil && !e.IsDir {
+			return nil, filesystem.NewNotDirectoryError(p)
+		}
+		names := idx.children[r.inner]
+		files := make([]filesystem.FileInfo, 0, len(names))
+		for _, name := range names {
+			files = append(files, entryInfo(idx.entries[path.Join(r.inner, name)]))
+		}
+		return files, nil
+	}
+
+	infos, err := afs.plugin.rootFS.ReadDir(r.source)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []filesystem.FileInfo
+	if strings.Trim(p, "/") == "" {
+		files = append(files, afs.readmeInfo())
+	}
+	for _, info := range infos {
+		child := path.Join(r.source, info.Name)
+		if afs.isOwnMount(child) {
+			continue
+		}
+		if info.IsDir {
+			files = append(files, dirInfo(info.Name, MetaValueDir, info.ModTime, nil))
+		} else if format := archiveFormat(info.Name); format != "" {
+			files = append(files, archiveInfo(info.Name, &resolvedPath{
+				source: child, format: format, size: info.Size, modTime: info.ModTime,
+			}, nil))
+		}
+	}
+	return files, nil
+}
+
+func (afs *archiveFS) Stat(p string) (*filesystem.FileInfo, error) {
+	if p == "/README" {
+		info := afs.readmeInfo()
+		return &info, nil
+	}
+
+	r, err := afs.resolve("stat", p)
+	if err != nil {
+		return nil, err
+	}
+	name := path.Base(p)
+
+	if !r.isArchive() {
+		info, err := afs.plugin.rootFS.Stat(r.source)
+		if err != nil || !info.IsDir {
+			return nil, filesystem.NewNotFoundError("stat", p)
+		}
+		result := dirInfo(name, MetaValueDir, info.ModTime, nil)
+		return &result, nil
+	}
+
+	idx, e, err := afs.entry("stat", p, r)
+	if err != nil {
+		return nil, err
+	}
+	var result filesystem.FileInfo
+	if e == nil {
+		result = archiveInfo(name, r, idx)
+	} else {
+		result = entryInfo(e)
+	}
+	return &result, nil
+}
+
+func (afs *archiveFS) Rename(oldPath, newPath string) error {
+	return afs.readOnly("rename", oldPath)
+}
+
+func (afs *archiveFS) Chmod(path string, mode uint32) error {
+	return afs.readOnly("chmod", path)
+}
+
+func (afs *archiveFS) Open(path string) (io.ReadCloser, error) {
+	data, err := afs.Read(path, 0, -1)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (afs *archiveFS) OpenWrite(path string) (io.WriteCloser, error) {
+	return nil, afs.readOnly("write", path)
+}
+
+// Ensure ArchiveFSPlugin implements ServicePlugin
+var _ plugin.ServicePlugin = (*ArchiveFSPlugin)(nil)
+var _ filesystem.FileSystem = (*archiveFS)(nil)
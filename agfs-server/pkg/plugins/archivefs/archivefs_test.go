@@ -0,0 +1,206 @@
+package archivefs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/memfs"
+)
+
+func newTestFS(t *testing.T, cfg map[string]interface{}) (*archiveFS, *memfs.MemoryFS) {
+	t.Helper()
+	p := NewArchiveFSPlugin()
+	root := memfs.NewMemoryFS()
+	p.SetParentFileSystem(root)
+	if err := p.Validate(cfg); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if err := p.Initialize(cfg); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	t.Cleanup(func() { p.Shutdown() })
+	return p.GetFileSystem().(*archiveFS), root
+}
+
+// readIgnoreEOF reads file content, ignoring io.EOF which is expected at end of file
+func readIgnoreEOF(fs filesystem.FileSystem, path string) ([]byte, error) {
+	content, err := fs.Read(path, 0, -1)
+	if err == io.EOF {
+		return content, nil
+	}
+	return content, err
+}
+
+func makeZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("zip create: %v", err)
+		}
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func makeTarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func writeFile(t *testing.T, root *memfs.MemoryFS, path string, data []byte) {
+	t.Helper()
+	if _, err := root.Write(path, data, -1, filesystem.WriteFlagCreate|filesystem.WriteFlagTruncate); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func TestArchiveFSZip(t *testing.T) {
+	fs, root := newTestFS(t, map[string]interface{}{})
+	root.Mkdir("/builds", 0755)
+	writeFile(t, root, "/builds/release.zip", makeZip(t, map[string]string{
+		"VERSION":        "1.2.0\n",
+		"bin/app":        "binary",
+		"docs/guide.md":  "# Guide",
+		"docs/api/v1.md": "v1",
+	}))
+	writeFile(t, root, "/builds/notes.txt", []byte("not an archive"))
+
+	infos, err := fs.ReadDir("/builds")
+	if err != nil || len(infos) != 1 || infos[0].Name != "release.zip" || !infos[0].IsDir || infos[0].Meta.Type != MetaValueArchive {
+		t.Fatalf("ReadDir /builds = %+v, %v", infos, err)
+	}
+
+	infos, err = fs.ReadDir("/builds/release.zip")
+	if err != nil || len(infos) != 3 || infos[0].Name != "VERSION" || infos[1].Name != "bin" || !infos[1].IsDir {
+		t.Fatalf("ReadDir archive root = %+v, %v", infos, err)
+	}
+	infos, err = fs.ReadDir("/builds/release.zip/docs")
+	if err != nil || len(infos) != 2 || infos[0].Name != "api" || infos[1].Name != "guide.md" {
+		t.Errorf("ReadDir implicit dir = %+v, %v", infos, err)
+	}
+
+	data, err := readIgnoreEOF(fs, "/builds/release.zip/docs/api/v1.md")
+	if err != nil || string(data) != "v1" {
+		t.Errorf("read = %q, %v", data, err)
+	}
+	data, err = fs.Read("/builds/release.zip/bin/app", 3, 3)
+	if (err != nil && err != io.EOF) || string(data) != "ary" {
+		t.Errorf("range read = %q, %v", data, err)
+	}
+
+	info, err := fs.Stat("/builds/release.zip")
+	if err != nil || !info.IsDir || info.Meta.Content["format"] != FormatZip || info.Meta.Content["entries"] != "7" {
+		t.Errorf("Stat archive = %+v, %v", info, err)
+	}
+	info, err = fs.Stat("/builds/release.zip/VERSION")
+	if err != nil || info.IsDir || info.Size != 6 || info.Mode&0222 != 0 {
+		t.Errorf("Stat entry = %+v, %v", info, err)
+	}
+
+	if _, err := fs.Stat("/builds/release.zip/missing"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("missing entry: expected ErrNotFound, got %v", err)
+	}
+	if _, err := fs.Stat("/builds/notes.txt"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("plain file: expected ErrNotFound, got %v", err)
+	}
+	if _, err := fs.ReadDir("/builds/release.zip/VERSION"); !errors.Is(err, filesystem.ErrNotDirectory) {
+		t.Errorf("ReadDir on entry file: expected ErrNotDirectory, got %v", err)
+	}
+	if _, err := fs.Write("/builds/release.zip/VERSION", []byte("x"), 0, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrPermissionDenied) {
+		t.Errorf("write: expected ErrPermissionDenied, got %v", err)
+	}
+}
+
+func TestArchiveFSTarGz(t *testing.T) {
+	fs, root := newTestFS(t, map[string]interface{}{"source": "/data"})
+	root.Mkdir("/data", 0755)
+	writeFile(t, root, "/data/logs.tgz", makeTarGz(t, map[string]string{
+		"./logs/app.log": "ERROR boom\n",
+		"logs/db.log":    "ok\n",
+	}))
+
+	infos, err := fs.ReadDir("/logs.tgz/logs")
+	if err != nil || len(infos) != 2 || infos[0].Name != "app.log" {
+		t.Fatalf("ReadDir = %+v, %v", infos, err)
+	}
+	data, err := readIgnoreEOF(fs, "/logs.tgz/logs/app.log")
+	if err != nil || string(data) != "ERROR boom\n" {
+		t.Errorf("read = %q, %v", data, err)
+	}
+}
+
+func TestArchiveFSCacheAndInvalidation(t *testing.T) {
+	fs, root := newTestFS(t, map[string]interface{}{"cache_size": "1KB"})
+	writeFile(t, root, "/a.zip", makeZip(t, map[string]string{"f": "first"}))
+
+	readIgnoreEOF(fs, "/a.zip/f")
+	readIgnoreEOF(fs, "/a.zip/f")
+	stats := fs.plugin.cache.Stats()
+	if stats["hits"].(uint64) < 2 {
+		t.Errorf("expected cached index and content to be reused, stats = %v", stats)
+	}
+
+	// A rewritten archive is detected by its size and modification time
+	writeFile(t, root, "/a.zip", makeZip(t, map[string]string{"f": "second version"}))
+	data, err := readIgnoreEOF(fs, "/a.zip/f")
+	if err != nil || string(data) != "second version" {
+		t.Errorf("read after rewrite = %q, %v", data, err)
+	}
+
+	// Files larger than the cache are still readable, just not cached
+	big := string(bytes.Repeat([]byte("x"), 4096))
+	writeFile(t, root, "/big.zip", makeZip(t, map[string]string{"big": big}))
+	data, err = readIgnoreEOF(fs, "/big.zip/big")
+	if err != nil || len(data) != 4096 {
+		t.Errorf("read of large entry = %d bytes, %v", len(data), err)
+	}
+	if used := fs.plugin.cache.Stats()["used_bytes"].(int64); used > 1024 {
+		t.Errorf("cache exceeded its bound: %d bytes", used)
+	}
+}
+
+func TestArchiveFSSkipsOwnMount(t *testing.T) {
+	fs, root := newTestFS(t, map[string]interface{}{"mount_path": "/archivefs"})
+	root.Mkdir("/archivefs", 0755)
+	root.Mkdir("/other", 0755)
+
+	infos, err := fs.ReadDir("/")
+	if err != nil || len(infos) != 2 || infos[0].Name != "README" || infos[1].Name != "other" {
+		t.Errorf("ReadDir / = %+v, %v", infos, err)
+	}
+	if _, err := fs.Stat("/archivefs"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("own mount: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestArchiveFSValidate(t *testing.T) {
+	p := NewArchiveFSPlugin()
+	if err := p.Validate(map[string]interface{}{"cache_size": "lots"}); err == nil {
+		t.Error("expected error for invalid cache_size")
+	}
+	if err := p.Validate(map[string]interface{}{"source": "relative"}); err == nil {
+		t.Error("expected error for relative source")
+	}
+	if err := p.Validate(map[string]interface{}{"unknown": true}); err == nil {
+		t.Error("expected error for unknown key")
+	}
+}
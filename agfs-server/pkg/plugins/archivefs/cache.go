@@ -0,0 +1,112 @@
+package archivefs
+
+import (
+	"container/list"
+	"sync"
+)
+
+// extractCache is an LRU cache bounded by total size in bytes
+// It holds archive indexes and extracted file contents. Keys include the
+// archive's size and modification time, so a changed archive never hits
+// stale entries; those simply age out.
+type extractCache struct {
+	mu        sync.Mutex
+	cache     map[string]*list.Element
+	lruList   *list.List
+	maxBytes  int64
+	usedBytes int64
+	hitCount  uint64
+	missCount uint64
+}
+
+// cacheItem is the value stored in the LRU list
+type cacheItem struct {
+	key   string
+	value interface{}
+	cost  int64
+}
+
+// newExtractCache creates a cache holding at most maxBytes; 0 disables caching
+func newExtractCache(maxBytes int64) *extractCache {
+	return &extractCache{
+		cache:    make(map[string]*list.Element),
+		lruList:  list.New(),
+		maxBytes: maxBytes,
+	}
+}
+
+// Get retrieves a cached value
+func (c *extractCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.cache[key]
+	if !ok {
+		c.missCount++
+		return nil, false
+	}
+	c.lruList.MoveToFront(elem)
+	c.hitCount++
+	return elem.Value.(*cacheItem).value, true
+}
+
+// Put adds a value to the cache, evicting least recently used entries as needed
+// Values larger than the whole cache are not stored.
+func (c *extractCache) Put(key string, value interface{}, cost int64) {
+	if cost > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.cache[key]; ok {
+		item := elem.Value.(*cacheItem)
+		c.usedBytes += cost - item.cost
+		item.value, item.cost = value, cost
+		c.lruList.MoveToFront(elem)
+	} else {
+		c.cache[key] = c.lruList.PushFront(&cacheItem{key: key, value: value, cost: cost})
+		c.usedBytes += cost
+	}
+
+	for c.usedBytes > c.maxBytes {
+		c.evictOldest()
+	}
+}
+
+// evictOldest removes the least recently used entry; caller must hold mu
+func (c *extractCache) evictOldest() {
+	elem := c.lruList.Back()
+	if elem == nil {
+		return
+	}
+	item := elem.Value.(*cacheItem)
+	c.lruList.Remove(elem)
+	delete(c.cache, item.key)
+	c.usedBytes -= item.cost
+}
+
+// Clear removes all entries
+func (c *extractCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cache = make(map[string]*list.Element)
+	c.lruList.Init()
+	c.usedBytes = 0
+}
+
+// Stats returns the cache usage and hit statistics
+func (c *extractCache) Stats() map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return map[string]interface{}{
+		"entries":    len(c.cache),
+		"used_bytes": c.usedBytes,
+		"max_bytes":  c.maxBytes,
+		"hits":       c.hitCount,
+		"misses":     c.missCount,
+	}
+}
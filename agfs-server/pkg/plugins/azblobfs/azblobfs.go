@@ -0,0 +1,760 @@
+package azblobfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PluginName = "azblobfs"
+)
+
+// AzBlobFS implements FileSystem interface using Azure Blob Storage as backend
+type AzBlobFS struct {
+	client *AzBlobClient
+	mu     sync.RWMutex
+}
+
+// NewAzBlobFS creates a new Azure Blob-backed file system
+func NewAzBlobFS(cfg AzBlobConfig) (*AzBlobFS, error) {
+	client, err := NewAzBlobClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &AzBlobFS{client: client}, nil
+}
+
+// mapError converts Azure not-found errors to filesystem.ErrNotFound
+func mapError(err error) error {
+	if isNotFound(err) {
+		return filesystem.ErrNotFound
+	}
+	return err
+}
+
+func (fs *AzBlobFS) meta() filesystem.MetaData {
+	return filesystem.MetaData{
+		Name: PluginName,
+		Type: "azblob",
+		Content: map[string]string{
+			"account":   fs.client.account,
+			"container": fs.client.container,
+			"prefix":    fs.client.rawPrefix,
+		},
+	}
+}
+
+// checkParent returns an error unless the parent directory of path exists
+func (fs *AzBlobFS) checkParent(ctx context.Context, path string) error {
+	parent := getParentPath(path)
+	if parent == "" {
+		return nil
+	}
+	exists, err := fs.client.DirectoryExists(ctx, parent)
+	if err != nil {
+		return fmt.Errorf("failed to check parent directory: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("parent directory does not exist: %s", parent)
+	}
+	return nil
+}
+
+func (fs *AzBlobFS) Create(path string) error {
+	path = filesystem.NormalizeS3Key(path)
+	ctx := context.Background()
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	exists, err := fs.client.ObjectExists(ctx, path)
+	if err != nil {
+		return fmt.Errorf("failed to check if file exists: %w", err)
+	}
+	if exists {
+		return fmt.Errorf("file already exists: %s", path)
+	}
+	if err := fs.checkParent(ctx, path); err != nil {
+		return err
+	}
+
+	return fs.client.PutObject(ctx, path, []byte{})
+}
+
+func (fs *AzBlobFS) Mkdir(path string, perm uint32) error {
+	path = filesystem.NormalizeS3Key(path)
+	ctx := context.Background()
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	exists, err := fs.client.DirectoryExists(ctx, path)
+	if err != nil {
+		return fmt.Errorf("failed to check if directory exists: %w", err)
+	}
+	if exists {
+		return fmt.Errorf("directory already exists: %s", path)
+	}
+	if err := fs.checkParent(ctx, path); err != nil {
+		return err
+	}
+
+	return fs.client.CreateDirectory(ctx, path)
+}
+
+func (fs *AzBlobFS) Remove(path string) error {
+	path = filesystem.NormalizeS3Key(path)
+	ctx := context.Background()
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	// Check if it's a file
+	exists, err := fs.client.ObjectExists(ctx, path)
+	if err != nil {
+		return fmt.Errorf("failed to check if file exists: %w", err)
+	}
+	if exists {
+		return fs.client.DeleteObject(ctx, path)
+	}
+
+	// Check if it's a directory
+	dirExists, err := fs.client.DirectoryExists(ctx, path)
+	if err != nil {
+		return fmt.Errorf("failed to check if directory exists: %w", err)
+	}
+	if !dirExists {
+		return filesystem.ErrNotFound
+	}
+
+	objects, err := fs.client.ListObjects(ctx, path)
+	if err != nil {
+		return fmt.Errorf("failed to list directory: %w", err)
+	}
+	if len(objects) > 0 {
+		return fmt.Errorf("directory not empty: %s", path)
+	}
+
+	// Delete directory marker
+	if err := fs.client.DeleteObject(ctx, path+"/"); err != nil && !isNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (fs *AzBlobFS) RemoveAll(path string) error {
+	path = filesystem.NormalizeS3Key(path)
+	ctx := context.Background()
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	// A single blob is removed directly
+	if path != "" {
+		if exists, err := fs.client.ObjectExists(ctx, path); err == nil && exists {
+			return fs.client.DeleteObject(ctx, path)
+		}
+	}
+	return fs.client.DeleteDirectory(ctx, path)
+}
+
+func (fs *AzBlobFS) Read(path string, offset int64, size int64) ([]byte, error) {
+	path = filesystem.NormalizeS3Key(path)
+	ctx := context.Background()
+
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if size == 0 {
+		return []byte{}, nil
+	}
+
+	// Ranged reads only fetch the requested bytes
+	data, err := fs.client.GetObjectRange(ctx, path, offset, size)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return data, nil
+}
+
+func (fs *AzBlobFS) Write(path string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	path = filesystem.NormalizeS3Key(path)
+	ctx := context.Background()
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	// Block blobs are replaced as a whole - offset writes are not supported
+	if offset > 0 {
+		return 0, fmt.Errorf("Azure Blob Storage does not support offset writes")
+	}
+	if path == "" || strings.HasSuffix(path, "/") {
+		return 0, fmt.Errorf("is a directory: %s", path)
+	}
+
+	if err := fs.client.PutObject(ctx, path, data); err != nil {
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
+
+func (fs *AzBlobFS) ReadDir(path string) ([]filesystem.FileInfo, error) {
+	path = filesystem.NormalizeS3Key(path)
+	ctx := context.Background()
+
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if path != "" {
+		exists, err := fs.client.DirectoryExists(ctx, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check directory: %w", err)
+		}
+		if !exists {
+			return nil, filesystem.ErrNotFound
+		}
+	}
+
+	objects, err := fs.client.ListObjects(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]filesystem.FileInfo, 0, len(objects))
+	for _, obj := range objects {
+		mode := uint32(0644)
+		if obj.IsDir {
+			mode = 0755
+		}
+		files = append(files, filesystem.FileInfo{
+			Name:    obj.Key,
+			Size:    obj.Size,
+			Mode:    mode,
+			ModTime: obj.LastModified,
+			IsDir:   obj.IsDir,
+			Meta: filesystem.MetaData{
+				Name: PluginName,
+				Type: "azblob",
+			},
+		})
+	}
+	return files, nil
+}
+
+func (fs *AzBlobFS) Stat(path string) (*filesystem.FileInfo, error) {
+	path = filesystem.NormalizeS3Key(path)
+	ctx := context.Background()
+
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if path == "" {
+		return &filesystem.FileInfo{
+			Name:    "/",
+			Mode:    0755,
+			ModTime: time.Now(),
+			IsDir:   true,
+			Meta:    fs.meta(),
+		}, nil
+	}
+
+	// Try as file first
+	props, err := fs.client.HeadObject(ctx, path)
+	if err == nil {
+		info := &filesystem.FileInfo{
+			Name: filepath.Base(path),
+			Mode: 0644,
+			Meta: fs.meta(),
+		}
+		if props.ContentLength != nil {
+			info.Size = *props.ContentLength
+		}
+		if props.LastModified != nil {
+			info.ModTime = *props.LastModified
+		}
+		if props.ContentType != nil && *props.ContentType != "" {
+			info.Meta.Content["content_type"] = *props.ContentType
+		}
+		return info, nil
+	}
+	if !isNotFound(err) {
+		return nil, err
+	}
+
+	// Try as directory
+	dirExists, err := fs.client.DirectoryExists(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check directory: %w", err)
+	}
+	if !dirExists {
+		return nil, filesystem.ErrNotFound
+	}
+
+	return &filesystem.FileInfo{
+		Name:    filepath.Base(path),
+		Mode:    0755,
+		ModTime: time.Now(),
+		IsDir:   true,
+		Meta:    fs.meta(),
+	}, nil
+}
+
+func (fs *AzBlobFS) Rename(oldPath, newPath string) error {
+	oldPath = filesystem.NormalizeS3Key(oldPath)
+	newPath = filesystem.NormalizeS3Key(newPath)
+	ctx := context.Background()
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	body, err := fs.client.GetObjectStream(ctx, oldPath, 0, -1)
+	if err != nil {
+		if isNotFound(err) {
+			return filesystem.ErrNotFound
+		}
+		return fmt.Errorf("failed to read source: %w", err)
+	}
+	defer body.Close()
+
+	// Stream the blob to its new name block by block
+	if err := fs.client.PutObjectStream(ctx, newPath, body, nil); err != nil {
+		return fmt.Errorf("failed to write destination: %w", err)
+	}
+	if err := fs.client.DeleteObject(ctx, oldPath); err != nil {
+		return fmt.Errorf("failed to delete source: %w", err)
+	}
+	return nil
+}
+
+func (fs *AzBlobFS) Chmod(path string, mode uint32) error {
+	// Blob Storage doesn't support Unix permissions
+	// This is a no-op for compatibility
+	return nil
+}
+
+func (fs *AzBlobFS) Open(path string) (io.ReadCloser, error) {
+	path = filesystem.NormalizeS3Key(path)
+
+	body, err := fs.client.GetObjectStream(context.Background(), path, 0, -1)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return body, nil
+}
+
+// OpenWrite streams data to a block blob as staged blocks
+// The blob is committed when the writer is closed.
+func (fs *AzBlobFS) OpenWrite(path string) (io.WriteCloser, error) {
+	path = filesystem.NormalizeS3Key(path)
+	if path == "" || strings.HasSuffix(path, "/") {
+		return nil, fmt.Errorf("is a directory: %s", path)
+	}
+
+	pr, pw := io.Pipe()
+	w := &azblobWriter{pw: pw, done: make(chan error, 1)}
+	go func() {
+		err := fs.client.PutObjectStream(context.Background(), path, pr, nil)
+		// Unblock pending writes if the upload failed early
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+	return w, nil
+}
+
+// azblobWriter feeds an in-progress UploadStream through a pipe
+type azblobWriter struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+func (w *azblobWriter) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+// Close ends the stream and waits for the block list to be committed
+func (w *azblobWriter) Close() error {
+	w.pw.Close()
+	return <-w.done
+}
+
+// azblobStreamReader implements filesystem.StreamReader for blobs
+type azblobStreamReader struct {
+	body      io.ReadCloser
+	chunkSize int64
+	closed    bool
+	mu        sync.Mutex
+}
+
+// ReadChunk reads the next chunk from the blob stream
+func (r *azblobStreamReader) ReadChunk(timeout time.Duration) ([]byte, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil, true, io.EOF
+	}
+
+	type readResult struct {
+		n   int
+		err error
+	}
+	buf := make([]byte, r.chunkSize)
+	resultCh := make(chan readResult, 1)
+	go func() {
+		n, err := io.ReadFull(r.body, buf)
+		resultCh <- readResult{n: n, err: err}
+	}()
+
+	select {
+	case result := <-resultCh:
+		if result.err == io.EOF || result.err == io.ErrUnexpectedEOF {
+			if result.n > 0 {
+				return buf[:result.n], true, nil
+			}
+			return nil, true, io.EOF
+		}
+		if result.err != nil {
+			return nil, false, result.err
+		}
+		return buf[:result.n], false, nil
+	case <-time.After(timeout):
+		return nil, false, fmt.Errorf("read timeout")
+	}
+}
+
+// Close closes the blob stream
+func (r *azblobStreamReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	return r.body.Close()
+}
+
+// OpenStream opens a stream for reading a blob
+// This implements the filesystem.Streamer interface
+func (fs *AzBlobFS) OpenStream(path string) (filesystem.StreamReader, error) {
+	body, err := fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &azblobStreamReader{body: body, chunkSize: 256 * 1024}, nil
+}
+
+// Truncate changes the size of the file
+// The blob is rewritten: the first size bytes are streamed from the current
+// blob and zeros are appended if it grows.
+func (fs *AzBlobFS) Truncate(path string, size int64) error {
+	path = filesystem.NormalizeS3Key(path)
+	ctx := context.Background()
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if size < 0 {
+		return filesystem.NewInvalidArgumentError("size", size, "must be non-negative")
+	}
+	if path == "" || strings.HasSuffix(path, "/") {
+		return fmt.Errorf("is a directory: %s", path)
+	}
+
+	props, err := fs.client.HeadObject(ctx, path)
+	if err != nil {
+		return mapError(err)
+	}
+	var current int64
+	if props.ContentLength != nil {
+		current = *props.ContentLength
+	}
+	if current == size {
+		return nil
+	}
+
+	var body io.Reader = strings.NewReader("")
+	if size > 0 && current > 0 {
+		r, err := fs.client.GetObjectStream(ctx, path, 0, min(size, current))
+		if err != nil {
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+		defer r.Close()
+		body = r
+	}
+	if size > current {
+		body = io.MultiReader(body, io.LimitReader(zeroReader{}, size-current))
+	}
+
+	// The ETag condition fails instead of clobbering a concurrent writer
+	if err := fs.client.PutObjectStream(ctx, path, body, props.ETag); err != nil {
+		return fmt.Errorf("failed to write truncated file: %w", err)
+	}
+	return nil
+}
+
+// zeroReader yields an endless stream of zero bytes
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// AzBlobFSPlugin wraps AzBlobFS as a plugin
+type AzBlobFSPlugin struct {
+	fs *AzBlobFS
+}
+
+// NewAzBlobFSPlugin creates a new AzBlobFS plugin
+func NewAzBlobFSPlugin() *AzBlobFSPlugin {
+	return &AzBlobFSPlugin{}
+}
+
+func (p *AzBlobFSPlugin) Name() string {
+	return PluginName
+}
+
+func (p *AzBlobFSPlugin) Validate(cfg map[string]interface{}) error {
+	allowedKeys := []string{
+		"container", "account_name", "account_key", "sas_token", "connection_string", "endpoint", "prefix",
+		"block_size", "concurrency", "mount_path",
+	}
+	if err := config.ValidateOnlyKnownKeys(cfg, allowedKeys); err != nil {
+		return err
+	}
+
+	if _, err := config.RequireString(cfg, "container"); err != nil {
+		return err
+	}
+
+	for _, key := range []string{"account_name", "account_key", "sas_token", "connection_string", "endpoint", "prefix"} {
+		if err := config.ValidateStringType(cfg, key); err != nil {
+			return err
+		}
+	}
+
+	// Credentials: a connection string, or an account name with a key or SAS token
+	if config.GetStringConfig(cfg, "connection_string", "") == "" {
+		if config.GetStringConfig(cfg, "account_name", "") == "" {
+			return fmt.Errorf("account_name or connection_string is required")
+		}
+		if config.GetStringConfig(cfg, "account_key", "") == "" && config.GetStringConfig(cfg, "sas_token", "") == "" {
+			return fmt.Errorf("account_key or sas_token is required with account_name")
+		}
+	}
+
+	blockSize, err := config.GetSizeConfig(cfg, "block_size", DefaultBlockSize)
+	if err != nil {
+		return err
+	}
+	if blockSize <= 0 {
+		return fmt.Errorf("block_size must be positive")
+	}
+
+	if err := config.ValidateIntType(cfg, "concurrency"); err != nil {
+		return err
+	}
+	if config.GetIntConfig(cfg, "concurrency", DefaultConcurrency) <= 0 {
+		return fmt.Errorf("concurrency must be positive")
+	}
+
+	return nil
+}
+
+func (p *AzBlobFSPlugin) Initialize(cfg map[string]interface{}) error {
+	blockSize, err := config.GetSizeConfig(cfg, "block_size", DefaultBlockSize)
+	if err != nil {
+		return err
+	}
+
+	azCfg := AzBlobConfig{
+		AccountName:      config.GetStringConfig(cfg, "account_name", ""),
+		AccountKey:       config.GetStringConfig(cfg, "account_key", ""),
+		SASToken:         config.GetStringConfig(cfg, "sas_token", ""),
+		ConnectionString: config.GetStringConfig(cfg, "connection_string", ""),
+		Container:        config.GetStringConfig(cfg, "container", ""),
+		Endpoint:         config.GetStringConfig(cfg, "endpoint", ""),
+		Prefix:           config.GetStringConfig(cfg, "prefix", ""),
+		BlockSize:        blockSize,
+		Concurrency:      config.GetIntConfig(cfg, "concurrency", DefaultConcurrency),
+	}
+	if azCfg.Container == "" {
+		return fmt.Errorf("container name is required")
+	}
+
+	fs, err := NewAzBlobFS(azCfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize azblobfs: %w", err)
+	}
+	p.fs = fs
+
+	log.Infof("[azblobfs] Initialized with container: %s, block size: %d, concurrency: %d", azCfg.Container, azCfg.BlockSize, azCfg.Concurrency)
+	return nil
+}
+
+func (p *AzBlobFSPlugin) GetFileSystem() filesystem.FileSystem {
+	return p.fs
+}
+
+func (p *AzBlobFSPlugin) GetReadme() string {
+	return getReadme()
+}
+
+func (p *AzBlobFSPlugin) GetConfigParams() []plugin.ConfigParameter {
+	return []plugin.ConfigParameter{
+		{
+			Name:        "container",
+			Type:        "string",
+			Required:    true,
+			Default:     "",
+			Description: "Blob container name",
+		},
+		{
+			Name:        "account_name",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Storage account name (required unless connection_string is set)",
+		},
+		{
+			Name:        "account_key",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Storage account key for shared key authentication",
+			Secret:      true,
+		},
+		{
+			Name:        "sas_token",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Shared access signature token (alternative to account_key)",
+			Secret:      true,
+		},
+		{
+			Name:        "connection_string",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Storage connection string (alternative to account_name and account_key, e.g. for Azurite)",
+		},
+		{
+			Name:        "endpoint",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Blob service endpoint (default: https://<account_name>.blob.core.windows.net)",
+		},
+		{
+			Name:        "prefix",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Blob name prefix for namespace isolation. Nested prefixes (e.g., 'team1' and 'team1/test') are automatically isolated.",
+		},
+		{
+			Name:        "block_size",
+			Type:        "string",
+			Required:    false,
+			Default:     "8MB",
+			Description: "Block size for multi-block uploads",
+		},
+		{
+			Name:        "concurrency",
+			Type:        "int",
+			Required:    false,
+			Default:     "4",
+			Description: "Number of blocks uploaded in parallel",
+		},
+	}
+}
+
+func (p *AzBlobFSPlugin) Shutdown() error {
+	return nil
+}
+
+func getReadme() string {
+	return `AzBlobFS Plugin - Azure Blob Storage-backed File System
+
+This plugin provides a file system backed by an Azure Blob Storage container.
+It mirrors s3fs: directories are simulated with "/" in blob names and
+every prefix is isolated from the others.
+
+FEATURES:
+  - Store files and directories as block blobs
+  - Range reads fetch only the requested bytes
+  - Streaming writes staged as blocks (block_size, concurrency)
+  - Truncate (blobs are rewritten)
+  - Optional blob name prefix with strict isolation for nested prefixes
+  - Works with the Azurite emulator
+
+CONFIGURATION:
+
+  Account key:
+  [plugins.azblobfs]
+  enabled = true
+  path = "/azure"
+
+    [plugins.azblobfs.config]
+    account_name = "myaccount"
+    account_key = "base64-key"
+    container = "agfs"
+    prefix = "team1"  # Optional: all blobs are stored under this prefix
+
+  SAS token:
+  [plugins.azblobfs]
+  enabled = true
+  path = "/azure"
+
+    [plugins.azblobfs.config]
+    account_name = "myaccount"
+    sas_token = "sv=2022-11-02&ss=b&srt=co&sp=rwdl&sig=..."
+    container = "agfs"
+
+  Azurite:
+  [plugins.azblobfs]
+  enabled = true
+  path = "/azure"
+
+    [plugins.azblobfs.config]
+    connection_string = "UseDevelopmentStorage=true"
+    container = "test"
+
+USAGE:
+
+  Write and read a file:
+    agfs write /azure/data/file.txt "Hello, Azure!"
+    agfs cat /azure/data/file.txt
+
+  Stream a large file:
+    agfs cat --stream /azure/videos/movie.mp4 > movie.mp4
+
+  List and remove:
+    agfs ls /azure/data
+    agfs rm -r /azure/data
+
+NOTES:
+  - Writes replace the whole blob; offset writes are not supported
+  - Permissions (chmod) are not supported
+  - Prefix "team1" stores blobs as "__PREFIX__team1__/<path>", like s3fs
+  - Rename downloads and re-uploads the blob
+`
+}
+
+// Ensure AzBlobFSPlugin implements ServicePlugin
+var _ plugin.ServicePlugin = (*AzBlobFSPlugin)(nil)
+var _ filesystem.FileSystem = (*AzBlobFS)(nil)
+var _ filesystem.Streamer = (*AzBlobFS)(nil)
+var _ filesystem.Truncater = (*AzBlobFS)(nil)
@@ -0,0 +1,204 @@
+package azblobfs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+// getTestConfig returns Azure Blob config from environment variables
+// Required: AZBLOB_TEST_CONTAINER and AZBLOB_TEST_CONNECTION_STRING
+// (e.g. "UseDevelopmentStorage=true" for Azurite)
+func getTestConfig() (AzBlobConfig, bool) {
+	container := os.Getenv("AZBLOB_TEST_CONTAINER")
+	connStr := os.Getenv("AZBLOB_TEST_CONNECTION_STRING")
+	if container == "" || connStr == "" {
+		return AzBlobConfig{}, false
+	}
+
+	return AzBlobConfig{
+		Container:        container,
+		ConnectionString: connStr,
+		Prefix:           "agfs-test",
+		BlockSize:        256 * 1024,
+		Concurrency:      2,
+	}, true
+}
+
+func newTestFS(t *testing.T) *AzBlobFS {
+	t.Helper()
+
+	cfg, ok := getTestConfig()
+	if !ok {
+		t.Skip("Azure Blob test environment not configured (set AZBLOB_TEST_CONTAINER and AZBLOB_TEST_CONNECTION_STRING)")
+	}
+
+	fs, err := NewAzBlobFS(cfg)
+	if err != nil {
+		t.Fatalf("NewAzBlobFS failed: %v", err)
+	}
+	return fs
+}
+
+func TestBuildKey(t *testing.T) {
+	plain := &AzBlobClient{}
+	if got := plain.buildKey("/a/b.txt"); got != "a/b.txt" {
+		t.Errorf("buildKey without prefix = %q", got)
+	}
+
+	isolated := &AzBlobClient{prefix: PrefixIsolationDelimiter + "team1__"}
+	if got := isolated.buildKey("/a/b.txt"); got != "__PREFIX__team1__/a/b.txt" {
+		t.Errorf("buildKey with prefix = %q", got)
+	}
+	if got := isolated.dirPrefix(""); got != "__PREFIX__team1__/" {
+		t.Errorf("dirPrefix of root = %q", got)
+	}
+}
+
+func TestAzBlobFSValidate(t *testing.T) {
+	p := NewAzBlobFSPlugin()
+	if err := p.Validate(map[string]interface{}{"account_name": "a", "account_key": "k"}); err == nil {
+		t.Error("expected error for missing container")
+	}
+	if err := p.Validate(map[string]interface{}{"container": "c"}); err == nil {
+		t.Error("expected error for missing credentials")
+	}
+	if err := p.Validate(map[string]interface{}{"container": "c", "account_name": "a"}); err == nil {
+		t.Error("expected error for account_name without key or SAS token")
+	}
+	if err := p.Validate(map[string]interface{}{"container": "c", "connection_string": "x", "concurrency": 0}); err == nil {
+		t.Error("expected error for zero concurrency")
+	}
+	if err := p.Validate(map[string]interface{}{"container": "c", "connection_string": "x", "block_size": "huge"}); err == nil {
+		t.Error("expected error for invalid block_size")
+	}
+	if err := p.Validate(map[string]interface{}{"container": "c", "account_name": "a", "sas_token": "sv=x", "block_size": "4MB", "mount_path": "/azure"}); err != nil {
+		t.Errorf("Validate failed: %v", err)
+	}
+}
+
+func TestAzBlobFSReadWrite(t *testing.T) {
+	fs := newTestFS(t)
+	path := "/rw_test.txt"
+	defer fs.Remove(path)
+
+	if _, err := fs.Write(path, []byte("Hello, World!"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	content, err := fs.Read(path, 0, -1)
+	if err != nil || string(content) != "Hello, World!" {
+		t.Errorf("Read = %q, %v", content, err)
+	}
+	content, err = fs.Read(path, 7, 5)
+	if err != nil || string(content) != "World" {
+		t.Errorf("range Read = %q, %v", content, err)
+	}
+
+	if _, err := fs.Write(path, []byte("x"), 3, filesystem.WriteFlagNone); err == nil {
+		t.Error("expected error for offset write")
+	}
+	if _, err := fs.Read("/missing.txt", 0, -1); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("missing file: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestAzBlobFSOpenWrite(t *testing.T) {
+	fs := newTestFS(t)
+	path := "/stream_test.bin"
+	defer fs.Remove(path)
+
+	// Larger than the test chunk size, so the upload takes several requests
+	data := make([]byte, 700*1024)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	w, err := fs.OpenWrite(path)
+	if err != nil {
+		t.Fatalf("OpenWrite failed: %v", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	r, err := fs.Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer r.Close()
+	got, err := io.ReadAll(r)
+	if err != nil || len(got) != len(data) || got[len(got)-1] != data[len(data)-1] {
+		t.Errorf("read back %d bytes, %v", len(got), err)
+	}
+}
+
+func TestAzBlobFSTruncate(t *testing.T) {
+	fs := newTestFS(t)
+	path := "/truncate_test.txt"
+	defer fs.Remove(path)
+
+	if _, err := fs.Write(path, []byte("Hello, World!"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	if err := fs.Truncate(path, 5); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+	if content, err := fs.Read(path, 0, -1); err != nil || string(content) != "Hello" {
+		t.Errorf("after shrink = %q, %v", content, err)
+	}
+
+	if err := fs.Truncate(path, 8); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+	if content, err := fs.Read(path, 0, -1); err != nil || string(content) != "Hello\x00\x00\x00" {
+		t.Errorf("after extend = %q, %v", content, err)
+	}
+
+	if err := fs.Truncate("/missing.txt", 0); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("missing file: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestAzBlobFSDirectories(t *testing.T) {
+	fs := newTestFS(t)
+	defer fs.RemoveAll("/dir_test")
+
+	if err := fs.Mkdir("/dir_test", 0755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	if _, err := fs.Write("/dir_test/a.txt", []byte("a"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := fs.Mkdir("/dir_test/sub", 0755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+
+	infos, err := fs.ReadDir("/dir_test")
+	if err != nil || len(infos) != 2 {
+		t.Fatalf("ReadDir = %+v, %v", infos, err)
+	}
+	if info, err := fs.Stat("/dir_test/sub"); err != nil || !info.IsDir {
+		t.Errorf("Stat dir = %+v, %v", info, err)
+	}
+	if err := fs.Remove("/dir_test"); err == nil {
+		t.Error("expected error removing non-empty directory")
+	}
+
+	if err := fs.Rename("/dir_test/a.txt", "/dir_test/b.txt"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if content, err := fs.Read("/dir_test/b.txt", 0, -1); err != nil || string(content) != "a" {
+		t.Errorf("renamed file = %q, %v", content, err)
+	}
+	if _, err := fs.Stat("/dir_test/a.txt"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("old name: expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,365 @@
+package azblobfs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// PrefixIsolationDelimiter is used to wrap prefixes in strict isolation mode
+	// It matches s3fs so that nested prefixes like "team1" and "team1/test" are completely isolated
+	PrefixIsolationDelimiter = "__PREFIX__"
+
+	// DefaultBlockSize is the size of each staged block in multi-block uploads
+	DefaultBlockSize = 8 * 1024 * 1024
+
+	// DefaultConcurrency is the number of blocks uploaded in parallel
+	DefaultConcurrency = 4
+)
+
+// AzBlobClient wraps an Azure Blob Storage container client with helper methods
+type AzBlobClient struct {
+	client      *container.Client
+	account     string
+	container   string
+	prefix      string // Effective prefix with isolation wrapping applied
+	rawPrefix   string // Original user-specified prefix (for display purposes)
+	blockSize   int64
+	concurrency int
+}
+
+// AzBlobConfig holds Azure Blob Storage client configuration
+type AzBlobConfig struct {
+	AccountName      string
+	AccountKey       string // Shared key authentication
+	SASToken         string // Shared access signature authentication
+	ConnectionString string // Alternative to AccountName/AccountKey (e.g. for Azurite)
+	Container        string
+	Endpoint         string // Optional service endpoint (default: https://<account>.blob.core.windows.net)
+	Prefix           string // Optional prefix for all blob names (will be wrapped for isolation)
+	BlockSize        int64
+	Concurrency      int
+}
+
+// newContainerClient builds a container client from the configured credentials
+func newContainerClient(cfg AzBlobConfig) (*container.Client, error) {
+	if cfg.ConnectionString != "" {
+		return container.NewClientFromConnectionString(cfg.ConnectionString, cfg.Container, nil)
+	}
+
+	if cfg.AccountName == "" {
+		return nil, fmt.Errorf("account_name or connection_string is required")
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", cfg.AccountName)
+	}
+	containerURL := strings.TrimSuffix(endpoint, "/") + "/" + cfg.Container
+
+	switch {
+	case cfg.AccountKey != "":
+		cred, err := container.NewSharedKeyCredential(cfg.AccountName, cfg.AccountKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid account key: %w", err)
+		}
+		return container.NewClientWithSharedKeyCredential(containerURL, cred, nil)
+	case cfg.SASToken != "":
+		return container.NewClientWithNoCredential(containerURL+"?"+strings.TrimPrefix(cfg.SASToken, "?"), nil)
+	default:
+		return nil, fmt.Errorf("one of account_key, sas_token or connection_string is required")
+	}
+}
+
+// NewAzBlobClient creates a new Azure Blob Storage client
+func NewAzBlobClient(cfg AzBlobConfig) (*AzBlobClient, error) {
+	ctx := context.Background()
+
+	client, err := newContainerClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure Blob client: %w", err)
+	}
+
+	// Verify container access by listing a single blob; SAS tokens scoped to a
+	// container often lack the permission to read container properties
+	pager := client.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{MaxResults: to.Ptr(int32(1))})
+	if _, err := pager.NextPage(ctx); err != nil {
+		return nil, fmt.Errorf("failed to access container %s: %w", cfg.Container, err)
+	}
+
+	log.Infof("[azblobfs] Connected to Azure Blob container: %s", client.URL())
+
+	// Normalize prefix: remove leading and trailing slashes
+	rawPrefix := strings.Trim(cfg.Prefix, "/")
+	prefix := rawPrefix
+
+	// Always apply strict prefix isolation, see s3fs
+	if rawPrefix != "" {
+		prefix = PrefixIsolationDelimiter + rawPrefix + "__"
+		log.Infof("[azblobfs] Prefix isolation applied. User prefix: %s, Effective prefix: %s", rawPrefix, prefix)
+	}
+
+	return &AzBlobClient{
+		client:      client,
+		account:     cfg.AccountName,
+		container:   cfg.Container,
+		prefix:      prefix,
+		rawPrefix:   rawPrefix,
+		blockSize:   cfg.BlockSize,
+		concurrency: cfg.Concurrency,
+	}, nil
+}
+
+// buildKey builds the full blob name with prefix
+func (c *AzBlobClient) buildKey(path string) string {
+	path = strings.TrimPrefix(path, "/")
+
+	if c.prefix == "" {
+		return path
+	}
+
+	if path == "" {
+		return c.prefix
+	}
+
+	return c.prefix + "/" + path
+}
+
+// dirPrefix returns the listing prefix for a directory path
+func (c *AzBlobClient) dirPrefix(path string) string {
+	prefix := c.buildKey(path)
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix
+}
+
+// isNotFound reports whether err means the blob does not exist
+func isNotFound(err error) bool {
+	return bloberror.HasCode(err, bloberror.BlobNotFound)
+}
+
+// GetObjectStream returns a reader over a byte range of a blob
+// size of -1 reads to the end. The caller is responsible for closing the returned ReadCloser.
+func (c *AzBlobClient) GetObjectStream(ctx context.Context, path string, offset, size int64) (io.ReadCloser, error) {
+	key := c.buildKey(path)
+
+	opts := &blob.DownloadStreamOptions{}
+	if offset > 0 || size > 0 {
+		opts.Range = blob.HTTPRange{Offset: offset}
+		if size > 0 {
+			opts.Range.Count = size
+		}
+	}
+
+	resp, err := c.client.NewBlobClient(key).DownloadStream(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blob %s: %w", key, err)
+	}
+	return resp.Body, nil
+}
+
+// GetObjectRange retrieves a byte range from a blob
+// offset: starting byte position (0-based)
+// size: number of bytes to read (-1 for all remaining bytes from offset)
+func (c *AzBlobClient) GetObjectRange(ctx context.Context, path string, offset, size int64) ([]byte, error) {
+	body, err := c.GetObjectStream(ctx, path, offset, size)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob body: %w", err)
+	}
+	return data, nil
+}
+
+// PutObject uploads a blob
+// Blobs larger than the single-request limit are staged in blockSize blocks
+func (c *AzBlobClient) PutObject(ctx context.Context, path string, data []byte) error {
+	key := c.buildKey(path)
+
+	_, err := c.client.NewBlockBlobClient(key).UploadBuffer(ctx, data, &blockblob.UploadBufferOptions{
+		BlockSize:   c.blockSize,
+		Concurrency: uint16(c.concurrency),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put blob %s: %w", key, err)
+	}
+	return nil
+}
+
+// PutObjectStream uploads a blob from a reader as staged blocks
+// At most concurrency blocks of blockSize bytes are buffered at a time.
+// A non-empty etag makes the upload fail if the blob changed in the meantime.
+func (c *AzBlobClient) PutObjectStream(ctx context.Context, path string, r io.Reader, etag *azcore.ETag) error {
+	key := c.buildKey(path)
+
+	opts := &blockblob.UploadStreamOptions{
+		BlockSize:   c.blockSize,
+		Concurrency: c.concurrency,
+	}
+	if etag != nil {
+		opts.AccessConditions = &blob.AccessConditions{
+			ModifiedAccessConditions: &blob.ModifiedAccessConditions{IfMatch: etag},
+		}
+	}
+
+	if _, err := c.client.NewBlockBlobClient(key).UploadStream(ctx, r, opts); err != nil {
+		return fmt.Errorf("failed to put blob %s: %w", key, err)
+	}
+	return nil
+}
+
+// DeleteObject deletes a blob
+func (c *AzBlobClient) DeleteObject(ctx context.Context, path string) error {
+	key := c.buildKey(path)
+
+	if _, err := c.client.NewBlobClient(key).Delete(ctx, nil); err != nil {
+		return fmt.Errorf("failed to delete blob %s: %w", key, err)
+	}
+	return nil
+}
+
+// HeadObject returns a blob's properties
+func (c *AzBlobClient) HeadObject(ctx context.Context, path string) (blob.GetPropertiesResponse, error) {
+	return c.client.NewBlobClient(c.buildKey(path)).GetProperties(ctx, nil)
+}
+
+// AzBlobObject represents a blob with metadata
+type AzBlobObject struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+	IsDir        bool
+}
+
+// ListObjects lists the immediate children of a directory
+func (c *AzBlobClient) ListObjects(ctx context.Context, path string) ([]AzBlobObject, error) {
+	prefix := c.dirPrefix(path)
+
+	var objects []AzBlobObject
+	pager := c.client.NewListBlobsHierarchyPager("/", &container.ListBlobsHierarchyOptions{Prefix: to.Ptr(prefix)})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list blobs: %w", err)
+		}
+
+		for _, p := range page.Segment.BlobPrefixes {
+			if p.Name == nil {
+				continue
+			}
+			objects = append(objects, AzBlobObject{
+				Key:          strings.TrimSuffix(strings.TrimPrefix(*p.Name, prefix), "/"),
+				LastModified: time.Now(),
+				IsDir:        true,
+			})
+		}
+
+		for _, item := range page.Segment.BlobItems {
+			if item.Name == nil {
+				continue
+			}
+			relPath := strings.TrimPrefix(*item.Name, prefix)
+			// Skip the directory marker itself
+			if relPath == "" || strings.HasSuffix(relPath, "/") {
+				continue
+			}
+
+			obj := AzBlobObject{Key: relPath}
+			if props := item.Properties; props != nil {
+				if props.ContentLength != nil {
+					obj.Size = *props.ContentLength
+				}
+				if props.LastModified != nil {
+					obj.LastModified = *props.LastModified
+				}
+			}
+			objects = append(objects, obj)
+		}
+	}
+
+	return objects, nil
+}
+
+// CreateDirectory creates a directory marker
+// Blob Storage doesn't have real directories (without hierarchical namespace),
+// so we create empty blobs ending with "/"
+func (c *AzBlobClient) CreateDirectory(ctx context.Context, path string) error {
+	key := c.dirPrefix(path)
+
+	if _, err := c.client.NewBlockBlobClient(key).Upload(ctx, streaming.NopCloser(bytes.NewReader(nil)), nil); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", key, err)
+	}
+	return nil
+}
+
+// DeleteDirectory deletes all blobs under a prefix
+func (c *AzBlobClient) DeleteDirectory(ctx context.Context, path string) error {
+	pager := c.client.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{Prefix: to.Ptr(c.dirPrefix(path))})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list blobs for deletion: %w", err)
+		}
+		for _, item := range page.Segment.BlobItems {
+			if _, err := c.client.NewBlobClient(*item.Name).Delete(ctx, nil); err != nil && !isNotFound(err) {
+				return fmt.Errorf("failed to delete blob %s: %w", *item.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ObjectExists checks if a blob exists
+func (c *AzBlobClient) ObjectExists(ctx context.Context, path string) (bool, error) {
+	_, err := c.HeadObject(ctx, path)
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// DirectoryExists checks if a directory exists (has a marker or blobs with the prefix)
+func (c *AzBlobClient) DirectoryExists(ctx context.Context, path string) (bool, error) {
+	pager := c.client.NewListBlobsHierarchyPager("/", &container.ListBlobsHierarchyOptions{
+		Prefix:     to.Ptr(c.dirPrefix(path)),
+		MaxResults: to.Ptr(int32(1)),
+	})
+	page, err := pager.NextPage(ctx)
+	if err != nil {
+		return false, err
+	}
+	return len(page.Segment.BlobItems) > 0 || len(page.Segment.BlobPrefixes) > 0, nil
+}
+
+// getParentPath returns the parent directory path
+func getParentPath(path string) string {
+	if path == "" || path == "/" {
+		return ""
+	}
+	parent := filepath.Dir(path)
+	if parent == "." {
+		return ""
+	}
+	return parent
+}
@@ -0,0 +1,793 @@
+package cronfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PluginName = "cronfs" // Name of this plugin
+)
+
+// Meta values for CronFS plugin
+const (
+	MetaValueJob     = "job"     // Job directory
+	MetaValueSpec    = "spec"    // Job spec files (schedule, command, target, payload)
+	MetaValueControl = "control" // Job control files (enabled)
+	MetaValueStatus  = "status"  // Read-only status files (last_run, next_run)
+	MetaValueRun     = "run"     // Run record in history/
+)
+
+// Files inside each job directory
+const (
+	fileSchedule = "schedule"
+	fileCommand  = "command"
+	fileTarget   = "target"
+	filePayload  = "payload"
+	fileEnabled  = "enabled"
+	fileLastRun  = "last_run"
+	fileNextRun  = "next_run"
+	dirHistory   = "history"
+)
+
+// jobFiles lists the job directory entries in display order
+var jobFiles = []string{fileSchedule, fileCommand, fileTarget, filePayload, fileEnabled, fileLastRun, fileNextRun}
+
+var jobNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// CronFSPlugin schedules recurring jobs through a file system interface
+// Each job is a directory under /jobs containing spec and control files:
+//
+//	/jobs/<name>/schedule - cron expression ("*/5 * * * *", "@hourly", "@every 30s")
+//	/jobs/<name>/command  - shell command to run (requires allow_commands)
+//	/jobs/<name>/target   - AGFS path written on every run
+//	/jobs/<name>/payload  - data written to target when there is no command
+//	/jobs/<name>/enabled  - "true" or "false"
+//	/jobs/<name>/last_run - JSON record of the most recent run
+//	/jobs/<name>/next_run - next scheduled run time
+//	/jobs/<name>/history/ - one JSON file per recent run
+//
+// Jobs are kept in memory and are lost when the server restarts.
+type CronFSPlugin struct {
+	sched    *scheduler
+	rootFS   filesystem.FileSystem
+	metadata plugin.PluginMetadata
+}
+
+// NewCronFSPlugin creates a new cron plugin
+func NewCronFSPlugin() *CronFSPlugin {
+	return &CronFSPlugin{
+		metadata: plugin.PluginMetadata{
+			Name:        PluginName,
+			Version:     "1.0.0",
+			Description: "Schedule recurring commands and AGFS writes by writing job files",
+			Author:      "AGFS Server",
+		},
+	}
+}
+
+func (c *CronFSPlugin) Name() string {
+	return c.metadata.Name
+}
+
+func (c *CronFSPlugin) Validate(cfg map[string]interface{}) error {
+	allowedKeys := []string{"mount_path", "allow_commands", "shell", "job_timeout", "history_limit"}
+	if err := config.ValidateOnlyKnownKeys(cfg, allowedKeys); err != nil {
+		return err
+	}
+
+	if err := config.ValidateBoolType(cfg, "allow_commands"); err != nil {
+		return err
+	}
+	for _, key := range []string{"shell", "job_timeout"} {
+		if err := config.ValidateStringType(cfg, key); err != nil {
+			return err
+		}
+	}
+	if err := config.ValidateIntType(cfg, "history_limit"); err != nil {
+		return err
+	}
+
+	if timeout, err := time.ParseDuration(config.GetStringConfig(cfg, "job_timeout", "5m")); err != nil || timeout <= 0 {
+		return fmt.Errorf("invalid job_timeout: must be a positive duration such as \"5m\"")
+	}
+	if limit := config.GetIntConfig(cfg, "history_limit", 20); limit <= 0 {
+		return fmt.Errorf("history_limit must be positive")
+	}
+	return nil
+}
+
+func (c *CronFSPlugin) Initialize(cfg map[string]interface{}) error {
+	timeout, err := time.ParseDuration(config.GetStringConfig(cfg, "job_timeout", "5m"))
+	if err != nil {
+		return fmt.Errorf("invalid job_timeout: %w", err)
+	}
+
+	allowCommands := config.GetBoolConfig(cfg, "allow_commands", false)
+	c.sched = newScheduler(
+		allowCommands,
+		config.GetStringConfig(cfg, "shell", "/bin/sh"),
+		timeout,
+		config.GetIntConfig(cfg, "history_limit", 20),
+	)
+	c.sched.rootFS = c.rootFS
+	c.sched.start()
+
+	log.Infof("[cronfs] Initialized (allow_commands=%v, job_timeout=%s)", allowCommands, timeout)
+	return nil
+}
+
+// SetParentFileSystem gives jobs access to the AGFS tree for target writes
+// This is called by the mount system, either before or after Initialize.
+func (c *CronFSPlugin) SetParentFileSystem(fs filesystem.FileSystem) {
+	c.rootFS = fs
+	if c.sched != nil {
+		c.sched.mu.Lock()
+		c.sched.rootFS = fs
+		c.sched.mu.Unlock()
+	}
+}
+
+func (c *CronFSPlugin) GetFileSystem() filesystem.FileSystem {
+	return &cronFS{plugin: c}
+}
+
+func (c *CronFSPlugin) GetReadme() string {
+	return `CronFS Plugin - Scheduled Jobs as Files
+
+This plugin runs recurring jobs inside the AGFS server. A job is registered
+by writing its spec files; no separate crontab or scheduler is needed.
+
+STRUCTURE:
+  /cronfs/
+    README              - This documentation
+    jobs/
+      <name>/           - A job (created by mkdir or by writing any spec file)
+        schedule        - Cron expression (read/write)
+        command         - Shell command to run (read/write, needs allow_commands)
+        target          - AGFS path written on every run (read/write)
+        payload         - Data written to target when no command is set (read/write)
+        enabled         - "true" or "false" (read/write, default true)
+        last_run        - JSON record of the most recent run (read-only)
+        next_run        - Next scheduled run time, RFC3339 (read-only)
+        history/        - One JSON file per recent run (read-only)
+
+SCHEDULES:
+  Standard 5-field cron expressions and descriptors are supported:
+    */5 * * * *         every 5 minutes
+    0 9 * * 1-5         09:00 on weekdays
+    @hourly, @daily     predefined schedules
+    @every 30s          fixed interval
+
+ACTIONS:
+  - target only:        payload is written to target on every run
+  - command only:       the command runs via the configured shell
+  - command + target:   the command output is written to target
+
+  Target writes create or overwrite the file. Writing to control files of
+  other plugins works too, e.g. a queuefs enqueue file.
+
+EXAMPLES:
+  # Heartbeat: enqueue a tick every minute
+  agfs:/> mkdir /cronfs/jobs/tick
+  agfs:/> echo "tick" > /cronfs/jobs/tick/payload
+  agfs:/> echo "/queuefs/ticks/enqueue" > /cronfs/jobs/tick/target
+  agfs:/> echo "* * * * *" > /cronfs/jobs/tick/schedule
+
+  # Snapshot disk usage to memfs every 10 minutes (allow_commands = true)
+  agfs:/> echo "df -h" > /cronfs/jobs/disk/command
+  agfs:/> echo "/memfs/disk.txt" > /cronfs/jobs/disk/target
+  agfs:/> echo "@every 10m" > /cronfs/jobs/disk/schedule
+
+  # Inspect runs
+  agfs:/> cat /cronfs/jobs/disk/last_run
+  agfs:/> ls /cronfs/jobs/disk/history
+
+  # Pause and resume
+  agfs:/> echo false > /cronfs/jobs/disk/enabled
+  agfs:/> echo true > /cronfs/jobs/disk/enabled
+
+  # Delete a job
+  agfs:/> rm -rf /cronfs/jobs/disk
+
+CONFIGURATION:
+  [plugins.cronfs]
+  enabled = true
+  path = "/cronfs"
+
+    [plugins.cronfs.config]
+    allow_commands = false   # shell commands are disabled unless set to true
+    shell = "/bin/sh"
+    job_timeout = "5m"       # commands are killed after this duration
+    history_limit = 20       # run records kept per job
+
+NOTES:
+  - Jobs live in memory and are lost when the server restarts.
+  - A run is skipped (and recorded as "skipped") if the previous run of the
+    same job is still in progress.
+`
+}
+
+func (c *CronFSPlugin) GetConfigParams() []plugin.ConfigParameter {
+	return []plugin.ConfigParameter{
+		{
+			Name:        "allow_commands",
+			Type:        "bool",
+			Required:    false,
+			Default:     "false",
+			Description: "Allow jobs to run shell commands on the server host",
+		},
+		{
+			Name:        "shell",
+			Type:        "string",
+			Required:    false,
+			Default:     "/bin/sh",
+			Description: "Shell used to run job commands",
+		},
+		{
+			Name:        "job_timeout",
+			Type:        "string",
+			Required:    false,
+			Default:     "5m",
+			Description: "Maximum duration of a job command",
+		},
+		{
+			Name:        "history_limit",
+			Type:        "int",
+			Required:    false,
+			Default:     "20",
+			Description: "Number of run records kept per job",
+		},
+	}
+}
+
+func (c *CronFSPlugin) Shutdown() error {
+	if c.sched != nil {
+		c.sched.stop()
+	}
+	return nil
+}
+
+// cronFS implements the FileSystem interface for job management
+type cronFS struct {
+	plugin *CronFSPlugin
+}
+
+// cronPath is a parsed path inside the plugin
+type cronPath struct {
+	job   string // Job name, empty for / and /jobs
+	file  string // Job file or dirHistory
+	runID string // Run file name under history/
+}
+
+// parseCronPath parses paths like /jobs/<name>/<file> and /jobs/<name>/history/<run>
+func parseCronPath(p string) (cronPath, error) {
+	p = strings.Trim(p, "/")
+	if p == "" || p == "jobs" {
+		return cronPath{}, nil
+	}
+
+	parts := strings.Split(p, "/")
+	if parts[0] != "jobs" || len(parts) > 5 {
+		return cronPath{}, filesystem.NewNotFoundError("stat", "/"+p)
+	}
+	if !jobNamePattern.MatchString(parts[1]) {
+		return cronPath{}, filesystem.NewInvalidArgumentError("job", parts[1], "must match [A-Za-z0-9._-]")
+	}
+
+	cp := cronPath{job: parts[1]}
+	if len(parts) == 2 {
+		return cp, nil
+	}
+
+	cp.file = parts[2]
+	if cp.file == dirHistory {
+		if len(parts) == 4 {
+			cp.runID = parts[3]
+		} else if len(parts) > 4 {
+			return cronPath{}, filesystem.NewNotFoundError("stat", "/"+p)
+		}
+		return cp, nil
+	}
+	if len(parts) > 3 || !isJobFile(cp.file) {
+		return cronPath{}, filesystem.NewNotFoundError("stat", "/"+p)
+	}
+	return cp, nil
+}
+
+func isJobFile(name string) bool {
+	for _, f := range jobFiles {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isWritableFile reports whether a job file accepts writes
+func isWritableFile(name string) bool {
+	switch name {
+	case fileSchedule, fileCommand, fileTarget, filePayload, fileEnabled:
+		return true
+	}
+	return false
+}
+
+// getJob returns a job by name; caller must hold sched.mu
+func (cfs *cronFS) getJob(name, op, path string) (*Job, error) {
+	job, ok := cfs.plugin.sched.jobs[name]
+	if !ok {
+		return nil, filesystem.NewNotFoundError(op, path)
+	}
+	return job, nil
+}
+
+// getOrCreateJob returns a job, registering an empty one if needed; caller must hold sched.mu
+func (cfs *cronFS) getOrCreateJob(name string) *Job {
+	if job, ok := cfs.plugin.sched.jobs[name]; ok {
+		return job
+	}
+	now := time.Now()
+	job := &Job{Name: name, Enabled: true, Created: now, Modified: now}
+	cfs.plugin.sched.jobs[name] = job
+	log.Infof("[cronfs] Created job %s", name)
+	return job
+}
+
+func (cfs *cronFS) Create(path string) error {
+	cp, err := parseCronPath(path)
+	if err != nil {
+		return err
+	}
+	if cp.job == "" || cp.file == "" || !isWritableFile(cp.file) {
+		return filesystem.NewPermissionDeniedError("create", path, "only job spec files can be created")
+	}
+
+	cfs.plugin.sched.mu.Lock()
+	defer cfs.plugin.sched.mu.Unlock()
+	cfs.getOrCreateJob(cp.job)
+	return nil
+}
+
+func (cfs *cronFS) Mkdir(path string, perm uint32) error {
+	cp, err := parseCronPath(path)
+	if err != nil {
+		return err
+	}
+	if cp.job == "" || cp.file != "" {
+		return filesystem.NewAlreadyExistsError("directory", path)
+	}
+
+	cfs.plugin.sched.mu.Lock()
+	defer cfs.plugin.sched.mu.Unlock()
+	if _, ok := cfs.plugin.sched.jobs[cp.job]; ok {
+		return filesystem.NewAlreadyExistsError("job", path)
+	}
+	cfs.getOrCreateJob(cp.job)
+	return nil
+}
+
+func (cfs *cronFS) Remove(path string) error {
+	cp, err := parseCronPath(path)
+	if err != nil {
+		return err
+	}
+	if cp.job == "" || cp.file != "" {
+		return filesystem.NewPermissionDeniedError("remove", path, "only job directories can be removed")
+	}
+	return cfs.removeJob(cp.job, path)
+}
+
+func (cfs *cronFS) RemoveAll(path string) error {
+	cp, err := parseCronPath(path)
+	if err != nil {
+		return err
+	}
+	if cp.job == "" || cp.file != "" {
+		return filesystem.NewPermissionDeniedError("remove", path, "only job directories can be removed")
+	}
+	return cfs.removeJob(cp.job, path)
+}
+
+func (cfs *cronFS) removeJob(name, path string) error {
+	sched := cfs.plugin.sched
+	sched.mu.Lock()
+	defer sched.mu.Unlock()
+
+	job, err := cfs.getJob(name, "remove", path)
+	if err != nil {
+		return err
+	}
+	if job.entryID != 0 {
+		sched.cron.Remove(job.entryID)
+	}
+	delete(sched.jobs, name)
+	log.Infof("[cronfs] Removed job %s", name)
+	return nil
+}
+
+func (cfs *cronFS) Read(path string, offset int64, size int64) ([]byte, error) {
+	if path == "/README" {
+		return plugin.ApplyRangeRead([]byte(cfs.plugin.GetReadme()), offset, size)
+	}
+
+	cp, err := parseCronPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if cp.file == "" || (cp.file == dirHistory && cp.runID == "") {
+		return nil, fmt.Errorf("is a directory: %s", path)
+	}
+
+	cfs.plugin.sched.mu.Lock()
+	data, err := cfs.readJobFile(cp, path)
+	cfs.plugin.sched.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return plugin.ApplyRangeRead(data, offset, size)
+}
+
+// readJobFile renders a job file; caller must hold sched.mu
+func (cfs *cronFS) readJobFile(cp cronPath, path string) ([]byte, error) {
+	job, err := cfs.getJob(cp.job, "read", path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cp.file {
+	case fileSchedule:
+		return withNewline(job.Schedule), nil
+	case fileCommand:
+		return withNewline(job.Command), nil
+	case fileTarget:
+		return withNewline(job.Target), nil
+	case filePayload:
+		return append([]byte(nil), job.Payload...), nil
+	case fileEnabled:
+		return []byte(strconv.FormatBool(job.Enabled) + "\n"), nil
+	case fileLastRun:
+		rec, ok := job.lastRun()
+		if !ok {
+			return []byte("{}"), nil
+		}
+		return json.MarshalIndent(rec, "", "  ")
+	case fileNextRun:
+		next := cfs.plugin.sched.nextRunTime(job)
+		if next.IsZero() {
+			return []byte{}, nil
+		}
+		return []byte(next.Format(time.RFC3339) + "\n"), nil
+	case dirHistory:
+		rec, ok := findRun(job, cp.runID)
+		if !ok {
+			return nil, filesystem.NewNotFoundError("read", path)
+		}
+		return json.MarshalIndent(rec, "", "  ")
+	}
+	return nil, filesystem.NewNotFoundError("read", path)
+}
+
+func withNewline(s string) []byte {
+	if s == "" {
+		return []byte{}
+	}
+	return []byte(s + "\n")
+}
+
+// runFileName returns the history/ file name of a run record
+// Names are zero-padded so lexical order matches run order.
+func runFileName(rec RunRecord) string {
+	return fmt.Sprintf("%06d.json", rec.ID)
+}
+
+func findRun(job *Job, name string) (RunRecord, bool) {
+	for _, rec := range job.history {
+		if runFileName(rec) == name {
+			return rec, true
+		}
+	}
+	return RunRecord{}, false
+}
+
+func (cfs *cronFS) Write(path string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	cp, err := parseCronPath(path)
+	if err != nil {
+		return 0, err
+	}
+	if cp.file == "" || cp.file == dirHistory {
+		return 0, fmt.Errorf("is a directory: %s", path)
+	}
+	if !isWritableFile(cp.file) {
+		return 0, filesystem.NewPermissionDeniedError("write", path, "read-only file")
+	}
+
+	sched := cfs.plugin.sched
+	sched.mu.Lock()
+	defer sched.mu.Unlock()
+
+	// Writing any spec file registers the job
+	job := cfs.getOrCreateJob(cp.job)
+	value := strings.TrimSpace(string(data))
+
+	switch cp.file {
+	case fileSchedule:
+		if value != "" {
+			if _, err := cronParser.Parse(value); err != nil {
+				return 0, filesystem.NewInvalidArgumentError("schedule", value, err.Error())
+			}
+		}
+		job.Schedule = value
+	case fileCommand:
+		if value != "" && !sched.allowCommands {
+			return 0, filesystem.NewPermissionDeniedError("write", path, "commands are disabled (set allow_commands = true)")
+		}
+		job.Command = value
+	case fileTarget:
+		if value != "" && !strings.HasPrefix(value, "/") {
+			return 0, filesystem.NewInvalidArgumentError("target", value, "must be an absolute AGFS path")
+		}
+		job.Target = value
+	case filePayload:
+		job.Payload = append([]byte(nil), data...)
+	case fileEnabled:
+		enabled, err := parseEnabled(value)
+		if err != nil {
+			return 0, err
+		}
+		job.Enabled = enabled
+	}
+
+	job.Modified = time.Now()
+	if err := sched.reschedule(job); err != nil {
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
+
+func parseEnabled(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "true", "1", "yes", "on":
+		return true, nil
+	case "false", "0", "no", "off":
+		return false, nil
+	}
+	return false, filesystem.NewInvalidArgumentError("enabled", s, "expected true or false")
+}
+
+func dirInfo(name, metaType string, modTime time.Time) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    name,
+		Size:    0,
+		Mode:    0755,
+		ModTime: modTime,
+		IsDir:   true,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: metaType},
+	}
+}
+
+// jobFileInfo builds the FileInfo of a job file; caller must hold sched.mu
+func (cfs *cronFS) jobFileInfo(job *Job, name string) filesystem.FileInfo {
+	mode := uint32(0644)
+	metaType := MetaValueSpec
+	modTime := job.Modified
+
+	switch name {
+	case fileEnabled:
+		metaType = MetaValueControl
+	case fileLastRun, fileNextRun:
+		mode = 0444
+		metaType = MetaValueStatus
+		if rec, ok := job.lastRun(); ok {
+			modTime = rec.FinishedAt
+		}
+	}
+
+	data, _ := cfs.readJobFile(cronPath{job: job.Name, file: name}, "")
+	return filesystem.FileInfo{
+		Name:    name,
+		Size:    int64(len(data)),
+		Mode:    mode,
+		ModTime: modTime,
+		IsDir:   false,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: metaType},
+	}
+}
+
+func runInfo(rec RunRecord) filesystem.FileInfo {
+	data, _ := json.MarshalIndent(rec, "", "  ")
+	return filesystem.FileInfo{
+		Name:    runFileName(rec),
+		Size:    int64(len(data)),
+		Mode:    0444,
+		ModTime: rec.FinishedAt,
+		IsDir:   false,
+		Meta: filesystem.MetaData{
+			Name:    PluginName,
+			Type:    MetaValueRun,
+			Content: map[string]string{"status": rec.Status},
+		},
+	}
+}
+
+func (cfs *cronFS) ReadDir(path string) ([]filesystem.FileInfo, error) {
+	cp, err := parseCronPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sched := cfs.plugin.sched
+	sched.mu.Lock()
+	defer sched.mu.Unlock()
+
+	now := time.Now()
+	trimmed := strings.Trim(path, "/")
+
+	switch {
+	case trimmed == "":
+		readme := cfs.plugin.GetReadme()
+		return []filesystem.FileInfo{
+			{
+				Name:    "README",
+				Size:    int64(len(readme)),
+				Mode:    0444,
+				ModTime: now,
+				IsDir:   false,
+				Meta:    filesystem.MetaData{Name: PluginName, Type: "doc"},
+			},
+			dirInfo("jobs", "dir", now),
+		}, nil
+
+	case cp.job == "":
+		names := make([]string, 0, len(sched.jobs))
+		for name := range sched.jobs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		files := make([]filesystem.FileInfo, 0, len(names))
+		for _, name := range names {
+			files = append(files, dirInfo(name, MetaValueJob, sched.jobs[name].Modified))
+		}
+		return files, nil
+	}
+
+	job, err := cfs.getJob(cp.job, "readdir", path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case cp.file == "":
+		files := make([]filesystem.FileInfo, 0, len(jobFiles)+1)
+		for _, name := range jobFiles {
+			files = append(files, cfs.jobFileInfo(job, name))
+		}
+		files = append(files, dirInfo(dirHistory, "dir", job.Modified))
+		return files, nil
+	case cp.file == dirHistory && cp.runID == "":
+		files := make([]filesystem.FileInfo, 0, len(job.history))
+		for _, rec := range job.history {
+			files = append(files, runInfo(rec))
+		}
+		return files, nil
+	}
+	return nil, filesystem.NewNotDirectoryError(path)
+}
+
+func (cfs *cronFS) Stat(path string) (*filesystem.FileInfo, error) {
+	now := time.Now()
+	trimmed := strings.Trim(path, "/")
+
+	if trimmed == "" {
+		info := dirInfo("/", "dir", now)
+		return &info, nil
+	}
+	if trimmed == "README" {
+		readme := cfs.plugin.GetReadme()
+		return &filesystem.FileInfo{
+			Name:    "README",
+			Size:    int64(len(readme)),
+			Mode:    0444,
+			ModTime: now,
+			IsDir:   false,
+			Meta:    filesystem.MetaData{Name: PluginName, Type: "doc"},
+		}, nil
+	}
+
+	cp, err := parseCronPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if cp.job == "" {
+		info := dirInfo("jobs", "dir", now)
+		return &info, nil
+	}
+
+	sched := cfs.plugin.sched
+	sched.mu.Lock()
+	defer sched.mu.Unlock()
+
+	job, err := cfs.getJob(cp.job, "stat", path)
+	if err != nil {
+		return nil, err
+	}
+
+	var info filesystem.FileInfo
+	switch {
+	case cp.file == "":
+		info = dirInfo(job.Name, MetaValueJob, job.Modified)
+		info.Meta.Content = map[string]string{
+			"scheduled": strconv.FormatBool(job.entryID != 0),
+		}
+	case cp.file == dirHistory && cp.runID == "":
+		info = dirInfo(dirHistory, "dir", job.Modified)
+	case cp.file == dirHistory:
+		rec, ok := findRun(job, cp.runID)
+		if !ok {
+			return nil, filesystem.NewNotFoundError("stat", path)
+		}
+		info = runInfo(rec)
+	default:
+		info = cfs.jobFileInfo(job, cp.file)
+	}
+	return &info, nil
+}
+
+func (cfs *cronFS) Rename(oldPath, newPath string) error {
+	return filesystem.NewNotSupportedError("rename", oldPath)
+}
+
+func (cfs *cronFS) Chmod(path string, mode uint32) error {
+	return filesystem.NewNotSupportedError("chmod", path)
+}
+
+// Truncate is a no-op so shell redirections like `echo x > schedule` work;
+// the following write replaces the whole value anyway.
+func (cfs *cronFS) Truncate(path string, size int64) error {
+	return nil
+}
+
+func (cfs *cronFS) Open(path string) (io.ReadCloser, error) {
+	data, err := cfs.Read(path, 0, -1)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (cfs *cronFS) OpenWrite(path string) (io.WriteCloser, error) {
+	return &cronWriter{cfs: cfs, path: path, buf: &bytes.Buffer{}}, nil
+}
+
+// cronWriter buffers a streamed write and applies it on Close
+type cronWriter struct {
+	cfs  *cronFS
+	path string
+	buf  *bytes.Buffer
+}
+
+func (cw *cronWriter) Write(p []byte) (n int, err error) {
+	return cw.buf.Write(p)
+}
+
+func (cw *cronWriter) Close() error {
+	_, err := cw.cfs.Write(cw.path, cw.buf.Bytes(), 0, filesystem.WriteFlagTruncate)
+	return err
+}
+
+// Ensure CronFSPlugin implements ServicePlugin
+var _ plugin.ServicePlugin = (*CronFSPlugin)(nil)
+var _ filesystem.FileSystem = (*cronFS)(nil)
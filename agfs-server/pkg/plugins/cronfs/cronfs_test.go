@@ -0,0 +1,169 @@
+package cronfs
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/memfs"
+)
+
+func newTestFS(t *testing.T, cfg map[string]interface{}) (*cronFS, *memfs.MemoryFS) {
+	t.Helper()
+	p := NewCronFSPlugin()
+	root := memfs.NewMemoryFS()
+	p.SetParentFileSystem(root)
+	if err := p.Validate(cfg); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if err := p.Initialize(cfg); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	t.Cleanup(func() { p.Shutdown() })
+	return p.GetFileSystem().(*cronFS), root
+}
+
+// readIgnoreEOF reads file content, ignoring io.EOF which is expected at end of file
+func readIgnoreEOF(fs filesystem.FileSystem, path string) ([]byte, error) {
+	content, err := fs.Read(path, 0, -1)
+	if err == io.EOF {
+		return content, nil
+	}
+	return content, err
+}
+
+func writeFile(t *testing.T, fs *cronFS, path, data string) {
+	t.Helper()
+	if _, err := fs.Write(path, []byte(data), 0, filesystem.WriteFlagTruncate); err != nil {
+		t.Fatalf("Write %s failed: %v", path, err)
+	}
+}
+
+func TestCronFSJobSpec(t *testing.T) {
+	fs, _ := newTestFS(t, map[string]interface{}{})
+
+	// Writing a spec file registers the job implicitly
+	writeFile(t, fs, "/jobs/tick/target", "/out.txt\n")
+	writeFile(t, fs, "/jobs/tick/schedule", "@every 1h\n")
+
+	data, _ := readIgnoreEOF(fs, "/jobs/tick/schedule")
+	if string(data) != "@every 1h\n" {
+		t.Errorf("schedule = %q", data)
+	}
+	data, _ = readIgnoreEOF(fs, "/jobs/tick/next_run")
+	if next, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data))); err != nil || next.Before(time.Now()) {
+		t.Errorf("next_run = %q, %v", data, err)
+	}
+
+	if _, err := fs.Write("/jobs/tick/schedule", []byte("every tuesday"), 0, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrInvalidArgument) {
+		t.Errorf("invalid schedule: expected ErrInvalidArgument, got %v", err)
+	}
+	if _, err := fs.Write("/jobs/tick/target", []byte("relative"), 0, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrInvalidArgument) {
+		t.Errorf("relative target: expected ErrInvalidArgument, got %v", err)
+	}
+	if _, err := fs.Write("/jobs/tick/last_run", []byte("x"), 0, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrPermissionDenied) {
+		t.Errorf("writing last_run: expected ErrPermissionDenied, got %v", err)
+	}
+
+	// Disabling the job unschedules it
+	writeFile(t, fs, "/jobs/tick/enabled", "false")
+	data, _ = readIgnoreEOF(fs, "/jobs/tick/next_run")
+	if len(data) != 0 {
+		t.Errorf("disabled job next_run = %q, want empty", data)
+	}
+
+	infos, err := fs.ReadDir("/jobs")
+	if err != nil || len(infos) != 1 || infos[0].Name != "tick" {
+		t.Errorf("ReadDir /jobs = %+v, %v", infos, err)
+	}
+	infos, err = fs.ReadDir("/jobs/tick")
+	if err != nil || len(infos) != len(jobFiles)+1 {
+		t.Errorf("ReadDir /jobs/tick = %+v, %v", infos, err)
+	}
+
+	if err := fs.RemoveAll("/jobs/tick"); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	if _, err := fs.Stat("/jobs/tick"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("removed job still exists: %v", err)
+	}
+}
+
+func TestCronFSRunWritesTarget(t *testing.T) {
+	fs, root := newTestFS(t, map[string]interface{}{"history_limit": 2})
+
+	writeFile(t, fs, "/jobs/ping/payload", "pong")
+	writeFile(t, fs, "/jobs/ping/target", "/ping.txt")
+	for i := 0; i < 3; i++ {
+		fs.plugin.sched.run("ping")
+	}
+
+	data, err := readIgnoreEOF(root, "/ping.txt")
+	if err != nil || string(data) != "pong" {
+		t.Errorf("target content = %q, %v", data, err)
+	}
+
+	var rec RunRecord
+	data, _ = readIgnoreEOF(fs, "/jobs/ping/last_run")
+	if err := json.Unmarshal(data, &rec); err != nil {
+		t.Fatalf("invalid last_run %q: %v", data, err)
+	}
+	if rec.Status != StatusSuccess || rec.ID != 3 {
+		t.Errorf("last_run = %+v", rec)
+	}
+
+	// Only history_limit runs are kept
+	infos, err := fs.ReadDir("/jobs/ping/history")
+	if err != nil || len(infos) != 2 || infos[0].Name != "000002.json" || infos[1].Name != "000003.json" {
+		t.Errorf("history = %+v, %v", infos, err)
+	}
+	if _, err := fs.Stat("/jobs/ping/history/000001.json"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("trimmed run still present: %v", err)
+	}
+}
+
+func TestCronFSCommands(t *testing.T) {
+	fs, _ := newTestFS(t, map[string]interface{}{})
+	if _, err := fs.Write("/jobs/cmd/command", []byte("echo hi"), 0, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrPermissionDenied) {
+		t.Errorf("command with allow_commands=false: expected ErrPermissionDenied, got %v", err)
+	}
+
+	fs, root := newTestFS(t, map[string]interface{}{"allow_commands": true})
+	writeFile(t, fs, "/jobs/cmd/command", "echo hello")
+	writeFile(t, fs, "/jobs/cmd/target", "/cmd.txt")
+	fs.plugin.sched.run("cmd")
+
+	data, _ := readIgnoreEOF(root, "/cmd.txt")
+	if string(data) != "hello\n" {
+		t.Errorf("command output in target = %q", data)
+	}
+
+	writeFile(t, fs, "/jobs/fail/command", "exit 3")
+	fs.plugin.sched.run("fail")
+	var rec RunRecord
+	data, _ = readIgnoreEOF(fs, "/jobs/fail/last_run")
+	json.Unmarshal(data, &rec)
+	if rec.Status != StatusFailed || rec.ExitCode != 3 {
+		t.Errorf("failed run = %+v", rec)
+	}
+}
+
+func TestCronFSSchedulerFires(t *testing.T) {
+	fs, root := newTestFS(t, map[string]interface{}{})
+	writeFile(t, fs, "/jobs/fast/payload", "tick")
+	writeFile(t, fs, "/jobs/fast/target", "/fast.txt")
+	writeFile(t, fs, "/jobs/fast/schedule", "@every 1s")
+
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if data, err := readIgnoreEOF(root, "/fast.txt"); err == nil && string(data) == "tick" {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatal("scheduled job did not run within 3s")
+}
@@ -0,0 +1,232 @@
+package cronfs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/robfig/cron/v3"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxOutputSize caps the command output kept in run records
+const maxOutputSize = 64 * 1024
+
+// Run statuses
+const (
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped" // Previous run was still in progress
+)
+
+// RunRecord describes a single execution of a job
+type RunRecord struct {
+	ID         int64     `json:"id"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	DurationMs int64     `json:"duration_ms"`
+	Status     string    `json:"status"`
+	ExitCode   int       `json:"exit_code,omitempty"`
+	Output     string    `json:"output,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Job is a recurring task registered through the jobs/<name>/ directory
+// A job is scheduled once it has a valid schedule, an action (command and/or
+// target) and is enabled.
+type Job struct {
+	Name     string
+	Schedule string
+	Command  string
+	Target   string
+	Payload  []byte
+	Enabled  bool
+	Created  time.Time
+	Modified time.Time
+
+	entryID cron.EntryID
+	running bool
+	nextRun int64 // Next run record ID
+	history []RunRecord
+}
+
+// lastRun returns the most recent run record, if any
+func (j *Job) lastRun() (RunRecord, bool) {
+	if len(j.history) == 0 {
+		return RunRecord{}, false
+	}
+	return j.history[len(j.history)-1], true
+}
+
+// runnable reports whether the job has everything it needs to be scheduled
+func (j *Job) runnable() bool {
+	return j.Enabled && j.Schedule != "" && (j.Command != "" || j.Target != "")
+}
+
+// scheduler owns the cron runner and the job table
+type scheduler struct {
+	cron          *cron.Cron
+	jobs          map[string]*Job
+	mu            sync.Mutex
+	rootFS        filesystem.FileSystem
+	allowCommands bool
+	shell         string
+	jobTimeout    time.Duration
+	historyLimit  int
+}
+
+// cronParser accepts standard 5-field expressions plus descriptors like @hourly and @every 30s
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+func newScheduler(allowCommands bool, shell string, jobTimeout time.Duration, historyLimit int) *scheduler {
+	return &scheduler{
+		cron:          cron.New(cron.WithParser(cronParser)),
+		jobs:          make(map[string]*Job),
+		allowCommands: allowCommands,
+		shell:         shell,
+		jobTimeout:    jobTimeout,
+		historyLimit:  historyLimit,
+	}
+}
+
+func (s *scheduler) start() {
+	s.cron.Start()
+}
+
+// stop stops the cron runner and waits for running jobs to finish
+func (s *scheduler) stop() {
+	<-s.cron.Stop().Done()
+}
+
+// reschedule (re)registers a job with the cron runner; caller must hold s.mu
+func (s *scheduler) reschedule(job *Job) error {
+	if job.entryID != 0 {
+		s.cron.Remove(job.entryID)
+		job.entryID = 0
+	}
+	if !job.runnable() {
+		return nil
+	}
+
+	name := job.Name
+	id, err := s.cron.AddFunc(job.Schedule, func() { s.run(name) })
+	if err != nil {
+		return fmt.Errorf("failed to schedule job %s: %w", name, err)
+	}
+	job.entryID = id
+	log.Debugf("[cronfs] Scheduled job %s (%s)", name, job.Schedule)
+	return nil
+}
+
+// nextRunTime returns when the job fires next; caller must hold s.mu
+func (s *scheduler) nextRunTime(job *Job) time.Time {
+	if job.entryID == 0 {
+		return time.Time{}
+	}
+	return s.cron.Entry(job.entryID).Next
+}
+
+// run executes a job and records the result in its history
+func (s *scheduler) run(name string) {
+	s.mu.Lock()
+	job, ok := s.jobs[name]
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	if job.running {
+		s.record(job, RunRecord{StartedAt: time.Now(), FinishedAt: time.Now(), Status: StatusSkipped,
+			Error: "previous run still in progress"})
+		s.mu.Unlock()
+		return
+	}
+	job.running = true
+	command, target, payload, rootFS := job.Command, job.Target, job.Payload, s.rootFS
+	s.mu.Unlock()
+
+	rec := s.execute(rootFS, command, target, payload)
+
+	s.mu.Lock()
+	job.running = false
+	// The job may have been deleted while it was running
+	if s.jobs[name] == job {
+		s.record(job, rec)
+	}
+	s.mu.Unlock()
+
+	if rec.Status == StatusFailed {
+		log.Warnf("[cronfs] Job %s failed: %s", name, rec.Error)
+	}
+}
+
+// record appends a run to the job history; caller must hold s.mu
+func (s *scheduler) record(job *Job, rec RunRecord) {
+	job.nextRun++
+	rec.ID = job.nextRun
+	rec.DurationMs = rec.FinishedAt.Sub(rec.StartedAt).Milliseconds()
+	job.history = append(job.history, rec)
+	if over := len(job.history) - s.historyLimit; over > 0 {
+		job.history = append([]RunRecord(nil), job.history[over:]...)
+	}
+}
+
+// execute runs the job action without holding the scheduler lock
+// If a command is set its output is written to target (when set); otherwise
+// payload is written to target.
+func (s *scheduler) execute(rootFS filesystem.FileSystem, command, target string, payload []byte) RunRecord {
+	rec := RunRecord{StartedAt: time.Now(), Status: StatusSuccess}
+
+	data := payload
+	if command != "" {
+		if !s.allowCommands {
+			rec.Status = StatusFailed
+			rec.Error = "commands are disabled (set allow_commands = true)"
+			rec.FinishedAt = time.Now()
+			return rec
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), s.jobTimeout)
+		defer cancel()
+
+		var out bytes.Buffer
+		cmd := exec.CommandContext(ctx, s.shell, "-c", command)
+		cmd.Stdout = &out
+		cmd.Stderr = &out
+		err := cmd.Run()
+		data = out.Bytes()
+		rec.Output = truncateOutput(data)
+		if err != nil {
+			rec.Status = StatusFailed
+			rec.Error = err.Error()
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				rec.ExitCode = exitErr.ExitCode()
+			}
+			rec.FinishedAt = time.Now()
+			return rec
+		}
+	}
+
+	if target != "" {
+		if rootFS == nil {
+			rec.Status = StatusFailed
+			rec.Error = "no AGFS root filesystem available to write target"
+		} else if _, err := rootFS.Write(target, data, -1, filesystem.WriteFlagCreate|filesystem.WriteFlagTruncate); err != nil {
+			rec.Status = StatusFailed
+			rec.Error = fmt.Sprintf("failed to write %s: %v", target, err)
+		}
+	}
+
+	rec.FinishedAt = time.Now()
+	return rec
+}
+
+func truncateOutput(data []byte) string {
+	if len(data) > maxOutputSize {
+		return string(data[:maxOutputSize]) + "\n...(truncated)"
+	}
+	return string(data)
+}
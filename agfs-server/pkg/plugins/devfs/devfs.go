@@ -1,8 +1,12 @@
 package devfs
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"io"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
@@ -14,12 +18,23 @@ const (
 	PluginName = "devfs"
 )
 
+// defaultMaxReadSize bounds a single read of an infinite device such as /zero
+const defaultMaxReadSize = 64 * 1024
+
 // DevFSPlugin is a minimal plugin that provides device files
-type DevFSPlugin struct{}
+type DevFSPlugin struct {
+	maxReadSize int64
+	fifos       *fifoTable
+	faults      *faultSettings
+}
 
 // NewDevFSPlugin creates a new DevFS plugin
 func NewDevFSPlugin() *DevFSPlugin {
-	return &DevFSPlugin{}
+	return &DevFSPlugin{
+		maxReadSize: defaultMaxReadSize,
+		fifos:       newFifoTable(defaultFifoSize, defaultFifoTimeout),
+		faults:      newFaultSettings(defaultErrorRate, defaultLatency),
+	}
 }
 
 func (p *DevFSPlugin) Name() string {
@@ -27,17 +42,70 @@ func (p *DevFSPlugin) Name() string {
 }
 
 func (p *DevFSPlugin) Validate(cfg map[string]interface{}) error {
-	// Only mount_path is allowed (injected by framework)
-	allowedKeys := []string{"mount_path"}
-	return config.ValidateOnlyKnownKeys(cfg, allowedKeys)
+	// mount_path is injected by framework
+	allowedKeys := []string{"mount_path", "max_read_size", "fifo_size", "fifo_timeout", "flaky_error_rate", "slow_latency"}
+	if err := config.ValidateOnlyKnownKeys(cfg, allowedKeys); err != nil {
+		return err
+	}
+	if size, err := config.GetSizeConfig(cfg, "max_read_size", defaultMaxReadSize); err != nil {
+		return fmt.Errorf("invalid max_read_size: %w", err)
+	} else if size <= 0 {
+		return fmt.Errorf("max_read_size must be positive")
+	}
+	if size, err := config.GetSizeConfig(cfg, "fifo_size", defaultFifoSize); err != nil {
+		return fmt.Errorf("invalid fifo_size: %w", err)
+	} else if size <= 0 {
+		return fmt.Errorf("fifo_size must be positive")
+	}
+	if err := config.ValidateStringType(cfg, "fifo_timeout"); err != nil {
+		return err
+	}
+	if timeout, err := time.ParseDuration(config.GetStringConfig(cfg, "fifo_timeout", "30s")); err != nil || timeout <= 0 {
+		return fmt.Errorf("invalid fifo_timeout: must be a positive duration such as \"30s\"")
+	}
+	if _, err := errorRateConfig(cfg); err != nil {
+		return fmt.Errorf("invalid flaky_error_rate: %w", err)
+	}
+	if err := config.ValidateStringType(cfg, "slow_latency"); err != nil {
+		return err
+	}
+	if _, err := parseLatency(config.GetStringConfig(cfg, "slow_latency", "1s")); err != nil {
+		return fmt.Errorf("invalid slow_latency: %w", err)
+	}
+	return nil
 }
 
-func (p *DevFSPlugin) Initialize(config map[string]interface{}) error {
+func (p *DevFSPlugin) Initialize(cfg map[string]interface{}) error {
+	size, err := config.GetSizeConfig(cfg, "max_read_size", defaultMaxReadSize)
+	if err != nil {
+		return fmt.Errorf("invalid max_read_size: %w", err)
+	}
+	p.maxReadSize = size
+
+	fifoSize, err := config.GetSizeConfig(cfg, "fifo_size", defaultFifoSize)
+	if err != nil {
+		return fmt.Errorf("invalid fifo_size: %w", err)
+	}
+	fifoTimeout, err := time.ParseDuration(config.GetStringConfig(cfg, "fifo_timeout", "30s"))
+	if err != nil {
+		return fmt.Errorf("invalid fifo_timeout: %w", err)
+	}
+	p.fifos = newFifoTable(fifoSize, fifoTimeout)
+
+	errorRate, err := errorRateConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("invalid flaky_error_rate: %w", err)
+	}
+	latency, err := parseLatency(config.GetStringConfig(cfg, "slow_latency", "1s"))
+	if err != nil {
+		return fmt.Errorf("invalid slow_latency: %w", err)
+	}
+	p.faults = newFaultSettings(errorRate, latency)
 	return nil
 }
 
 func (p *DevFSPlugin) GetFileSystem() filesystem.FileSystem {
-	return &DevFS{}
+	return &DevFS{maxReadSize: p.maxReadSize, fifos: p.fifos, faults: p.faults}
 }
 
 func (p *DevFSPlugin) GetReadme() string {
@@ -46,7 +114,18 @@ func (p *DevFSPlugin) GetReadme() string {
 This plugin provides standard Unix device files.
 
 AVAILABLE DEVICES:
-  /dev/null  - Null device (discards writes, returns EOF on reads)
+  /dev/null     - Null device (discards writes, returns EOF on reads)
+  /dev/zero     - Zero bytes on every read (discards writes)
+  /dev/full     - Zero bytes on every read; writes fail with ENOSPC
+  /dev/random   - Cryptographically secure random bytes
+  /dev/urandom  - Same as /dev/random (never blocks)
+  /dev/uuid     - A new random UUID (v4) on every read
+  /dev/ulid     - A new ULID on every read (sortable by creation time)
+  /dev/flaky    - Like /dev/zero, but operations fail at a set error rate
+  /dev/slow     - Like /dev/zero, but every operation is delayed
+  /dev/faults/  - error_rate and latency settings of flaky and slow
+  /dev/mkfifo   - Write a name to create the FIFO /dev/fifo/<name>
+  /dev/fifo/    - Named FIFOs for piping data between agents
 
 USAGE:
   Read from /dev/null:
@@ -58,18 +137,95 @@ USAGE:
   Use as redirect target:
     command > /dev/null 2>&1
 
+  Generate identifiers and tokens:
+    cat /dev/uuid
+    cat /dev/ulid
+    head -c 32 /dev/urandom | base64
+
+  Test error handling for full disks:
+    echo "test" > /dev/full        # fails with "no space left on device"
+
+  Pipe data between agents:
+    echo jobs > /dev/mkfifo        # or: touch /dev/fifo/jobs
+    echo "task 1" > /dev/fifo/jobs # agent A
+    cat /dev/fifo/jobs             # agent B, waits for data
+    rm /dev/fifo/jobs
+
+  Test behavior under failures and latency:
+    echo 0.2 > /dev/faults/error_rate   # 20% of /dev/flaky operations fail
+    echo 500ms > /dev/faults/latency    # /dev/slow operations take 500ms
+    head -c 1024 /dev/flaky
+    echo "test" > /dev/slow
+
+CONFIGURATION:
+  [plugins.devfs.config]
+  max_read_size = "64KB"   # largest single read from zero, full, random, urandom
+  fifo_size = "64KB"       # capacity of each FIFO
+  fifo_timeout = "30s"     # how long FIFO reads and writes wait
+  flaky_error_rate = 0.5   # probability that a /dev/flaky operation fails
+  slow_latency = "1s"      # delay of every /dev/slow operation
+
 CHARACTERISTICS:
   - /dev/null always exists
-  - Reads always return EOF immediately
-  - Writes are accepted and discarded
-  - Cannot be deleted, renamed, or modified
-
-VERSION: 1.0.0
+  - Reads of /dev/null always return EOF immediately
+  - /dev/zero, /dev/full, /dev/random and /dev/urandom never return EOF; each read
+    returns the requested size, capped at max_read_size (a read without a
+    size returns max_read_size bytes)
+  - /dev/uuid and /dev/ulid return one identifier and a newline per read
+  - Writes to /dev/null and /dev/zero are accepted and discarded
+  - Reading a FIFO consumes the data; a read of an empty FIFO waits up to
+    fifo_timeout for a writer and then returns EOF
+  - Writing to a full FIFO waits up to fifo_timeout for a reader to make
+    room and then fails
+  - FIFOs live in memory and are lost when the server restarts
+  - Failed /dev/flaky operations return an I/O error; settings written to
+    /dev/faults apply immediately and last until the server restarts
+  - Devices cannot be deleted, renamed, or modified; FIFOs can be removed
+
+VERSION: 1.2.0
 `
 }
 
 func (p *DevFSPlugin) GetConfigParams() []plugin.ConfigParameter {
-	return []plugin.ConfigParameter{}
+	return []plugin.ConfigParameter{
+		{
+			Name:        "max_read_size",
+			Type:        "string",
+			Required:    false,
+			Default:     "64KB",
+			Description: "Largest single read from infinite devices (zero, full, random, urandom)",
+		},
+		{
+			Name:        "fifo_size",
+			Type:        "string",
+			Required:    false,
+			Default:     "64KB",
+			Description: "Capacity of each FIFO",
+		},
+		{
+			Name:        "fifo_timeout",
+			Type:        "string",
+			Required:    false,
+			Default:     "30s",
+			Description: "How long FIFO reads wait for data and writes wait for room",
+		},
+		{
+			Name:        "flaky_error_rate",
+			Type:        "float",
+			Required:    false,
+			Default:     "0.5",
+			Description: "Probability (0-1) that an operation on /flaky fails",
+			Minimum:     plugin.Bound(0),
+			Maximum:     plugin.Bound(1),
+		},
+		{
+			Name:        "slow_latency",
+			Type:        "string",
+			Required:    false,
+			Default:     "1s",
+			Description: "Delay added to every operation on /slow",
+		},
+	}
 }
 
 func (p *DevFSPlugin) Shutdown() error {
@@ -77,34 +233,216 @@ func (p *DevFSPlugin) Shutdown() error {
 }
 
 // DevFS is a minimal filesystem that provides device files
-type DevFS struct{}
+type DevFS struct {
+	maxReadSize int64          // Bound for reads of infinite devices; 0 uses the default
+	fifos       *fifoTable     // Named FIFOs; created on first use when nil
+	faults      *faultSettings // Settings of /flaky and /slow; defaults when nil
+	stateOnce   sync.Once
+}
+
+// initState fills in defaults for a DevFS created without the plugin
+func (fs *DevFS) initState() {
+	fs.stateOnce.Do(func() {
+		if fs.fifos == nil {
+			fs.fifos = newFifoTable(defaultFifoSize, defaultFifoTimeout)
+		}
+		if fs.faults == nil {
+			fs.faults = newFaultSettings(defaultErrorRate, defaultLatency)
+		}
+	})
+}
+
+// fifoTable returns the FIFO table
+func (fs *DevFS) fifoTable() *fifoTable {
+	fs.initState()
+	return fs.fifos
+}
+
+// faultSettings returns the fault-injection settings
+func (fs *DevFS) faultSettings() *faultSettings {
+	fs.initState()
+	return fs.faults
+}
+
+// lookupFifo returns the FIFO at path, or nil
+func (fs *DevFS) lookupFifo(path string) *fifo {
+	if name := fifoName(path); name != "" {
+		return fs.fifoTable().get(name)
+	}
+	return nil
+}
+
+// readSize bounds a requested read size for an infinite device
+func (fs *DevFS) readSize(size int64) int64 {
+	max := fs.maxReadSize
+	if max <= 0 {
+		max = defaultMaxReadSize
+	}
+	if size < 0 || size > max {
+		return max
+	}
+	return size
+}
 
 func (fs *DevFS) Read(path string, offset int64, size int64) ([]byte, error) {
-	if path == "/null" {
+	if f := fs.lookupFifo(path); f != nil {
+		// FIFOs have no position; reads consume data
+		return fs.fifoTable().read(f, size)
+	}
+	if path == "/"+mkfifoCtl {
+		return nil, filesystem.NewPermissionDeniedError("read", path, "write-only control file")
+	}
+	if name := faultFile(path); name != "" {
+		return plugin.ApplyRangeRead(fs.faultSettings().get(name), offset, size)
+	}
+	if path == "/"+fifoDir || path == "/"+faultsDir {
+		return nil, fmt.Errorf("is a directory: %s", path)
+	}
+
+	d := lookupDevice(path)
+	if d == nil {
+		return nil, filesystem.ErrNotFound
+	}
+	if err := fs.faultSettings().inject(d, "read", path); err != nil {
+		return nil, err
+	}
+	if d.read == nil {
 		// Reading from /dev/null always returns EOF
 		return nil, io.EOF
 	}
-	return nil, filesystem.ErrNotFound
+	if d.infinite {
+		// Infinite devices have no position; every read is fresh
+		return d.read(fs.readSize(size)), nil
+	}
+	return plugin.ApplyRangeRead(d.read(0), offset, size)
 }
 
 func (fs *DevFS) Write(path string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
-	if path == "/null" {
-		// Writing to /dev/null succeeds but discards data
+	if f := fs.lookupFifo(path); f != nil {
+		return fs.fifoTable().write(f, data)
+	}
+	if path == "/"+mkfifoCtl {
+		// One FIFO name per line
+		for _, name := range strings.Fields(string(data)) {
+			if err := fs.fifoTable().create(name); err != nil {
+				return 0, err
+			}
+		}
+		return int64(len(data)), nil
+	}
+
+	if name := faultFile(path); name != "" {
+		if err := fs.faultSettings().set(name, data); err != nil {
+			return 0, err
+		}
 		return int64(len(data)), nil
 	}
+
+	if d := lookupDevice(path); d != nil {
+		if d.full {
+			return 0, filesystem.NewNoSpaceError("write", path)
+		}
+		if err := fs.faultSettings().inject(d, "write", path); err != nil {
+			return 0, err
+		}
+		if d.writable {
+			// Writing to /dev/null succeeds but discards data
+			return int64(len(data)), nil
+		}
+	}
 	return 0, errors.New("read-only filesystem")
 }
 
+func deviceInfo(d *device) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    d.name,
+		Size:    d.size,
+		Mode:    d.mode,
+		ModTime: time.Now(),
+		IsDir:   false,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "device"},
+	}
+}
+
+func fifoInfo(f *fifo) filesystem.FileInfo {
+	size, modTime := f.buffered()
+	return filesystem.FileInfo{
+		Name:    f.name,
+		Size:    size,
+		Mode:    0666,
+		ModTime: modTime,
+		IsDir:   false,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "fifo"},
+	}
+}
+
+func fifoDirInfo() filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    fifoDir,
+		Size:    0,
+		Mode:    0777,
+		ModTime: time.Now(),
+		IsDir:   true,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "directory"},
+	}
+}
+
+func faultsDirInfo() filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    faultsDir,
+		Size:    0,
+		Mode:    0755,
+		ModTime: time.Now(),
+		IsDir:   true,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "directory"},
+	}
+}
+
+func (fs *DevFS) faultFileInfo(name string) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    name,
+		Size:    int64(len(fs.faultSettings().get(name))),
+		Mode:    0644,
+		ModTime: time.Now(),
+		IsDir:   false,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "control"},
+	}
+}
+
+func mkfifoInfo() filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    mkfifoCtl,
+		Size:    0,
+		Mode:    0222,
+		ModTime: time.Now(),
+		IsDir:   false,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "control"},
+	}
+}
+
 func (fs *DevFS) Stat(path string) (*filesystem.FileInfo, error) {
-	if path == "/null" {
-		return &filesystem.FileInfo{
-			Name:    "null",
-			Size:    0,
-			Mode:    0666, // rw-rw-rw-
-			ModTime: time.Now(),
-			IsDir:   false,
-			Meta:    filesystem.MetaData{Name: PluginName, Type: "device"},
-		}, nil
+	if d := lookupDevice(path); d != nil {
+		info := deviceInfo(d)
+		return &info, nil
+	}
+	if f := fs.lookupFifo(path); f != nil {
+		info := fifoInfo(f)
+		return &info, nil
+	}
+	if name := faultFile(path); name != "" {
+		info := fs.faultFileInfo(name)
+		return &info, nil
+	}
+	switch path {
+	case "/" + fifoDir:
+		info := fifoDirInfo()
+		return &info, nil
+	case "/" + faultsDir:
+		info := faultsDirInfo()
+		return &info, nil
+	case "/" + mkfifoCtl:
+		info := mkfifoInfo()
+		return &info, nil
 	}
 	if path == "/" {
 		return &filesystem.FileInfo{
@@ -121,36 +459,70 @@ func (fs *DevFS) Stat(path string) (*filesystem.FileInfo, error) {
 
 func (fs *DevFS) ReadDir(path string) ([]filesystem.FileInfo, error) {
 	if path == "/" {
-		return []filesystem.FileInfo{
-			{
-				Name:    "null",
-				Size:    0,
-				Mode:    0666,
-				ModTime: time.Now(),
-				IsDir:   false,
-				Meta:    filesystem.MetaData{Name: PluginName, Type: "device"},
-			},
-		}, nil
+		infos := make([]filesystem.FileInfo, 0, len(devices)+3)
+		for _, d := range devices {
+			infos = append(infos, deviceInfo(d))
+		}
+		return append(infos, faultsDirInfo(), mkfifoInfo(), fifoDirInfo()), nil
+	}
+	if path == "/"+faultsDir {
+		return []filesystem.FileInfo{fs.faultFileInfo(faultErrorRate), fs.faultFileInfo(faultLatency)}, nil
+	}
+	if path == "/"+fifoDir {
+		fifos := fs.fifoTable().list()
+		infos := make([]filesystem.FileInfo, 0, len(fifos))
+		for _, f := range fifos {
+			infos = append(infos, fifoInfo(f))
+		}
+		return infos, nil
 	}
 	return nil, errors.New("not a directory")
 }
 
 func (fs *DevFS) Open(path string) (io.ReadCloser, error) {
-	if path == "/null" {
+	if f := fs.lookupFifo(path); f != nil {
+		return &fifoReader{table: fs.fifoTable(), f: f}, nil
+	}
+	d := lookupDevice(path)
+	if d == nil {
+		return nil, filesystem.ErrNotFound
+	}
+	if d.read == nil {
 		return &nullReader{}, nil
 	}
-	return nil, filesystem.ErrNotFound
+	data, err := fs.Read(path, 0, -1)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
 }
 
 func (fs *DevFS) OpenWrite(path string) (io.WriteCloser, error) {
-	if path == "/null" {
-		return &nullWriter{}, nil
+	if f := fs.lookupFifo(path); f != nil {
+		return &fifoWriter{table: fs.fifoTable(), f: f}, nil
+	}
+	if path == "/"+mkfifoCtl || faultFile(path) != "" {
+		return &controlWriter{fs: fs, path: path}, nil
+	}
+	if d := lookupDevice(path); d != nil {
+		if d.full {
+			return &fullWriter{path: path}, nil
+		}
+		if d.flaky || d.slow {
+			return &faultWriter{fs: fs, d: d, path: path}, nil
+		}
+		if d.writable {
+			return &nullWriter{}, nil
+		}
 	}
 	return nil, errors.New("read-only filesystem")
 }
 
-// Unsupported write operations
+// Create makes a FIFO under /fifo, like mkfifo; devices cannot be created
 func (fs *DevFS) Create(path string) error {
+	if name := fifoName(path); name != "" {
+		return fs.fifoTable().create(name)
+	}
 	return errors.New("read-only filesystem")
 }
 
@@ -159,11 +531,17 @@ func (fs *DevFS) Mkdir(path string, perm uint32) error {
 }
 
 func (fs *DevFS) Remove(path string) error {
+	if name := fifoName(path); name != "" {
+		if !fs.fifoTable().remove(name) {
+			return filesystem.NewNotFoundError("remove", path)
+		}
+		return nil
+	}
 	return errors.New("read-only filesystem")
 }
 
 func (fs *DevFS) RemoveAll(path string) error {
-	return errors.New("read-only filesystem")
+	return fs.Remove(path)
 }
 
 func (fs *DevFS) Rename(oldPath, newPath string) error {
@@ -176,10 +554,14 @@ func (fs *DevFS) Chmod(path string, mode uint32) error {
 
 // Truncate is a no-op for devfs
 func (fs *DevFS) Truncate(path string, size int64) error {
-	if path == "/null" {
+	if d := lookupDevice(path); d != nil && (d.writable || d.full) {
 		// Truncating /dev/null is allowed and does nothing
 		return nil
 	}
+	if fs.lookupFifo(path) != nil || path == "/"+mkfifoCtl || faultFile(path) != "" {
+		// Like O_TRUNC on a pipe, truncation is ignored
+		return nil
+	}
 	return errors.New("read-only filesystem")
 }
 
@@ -207,6 +589,53 @@ func (nw *nullWriter) Close() error {
 	return nil
 }
 
+// fullWriter implements io.WriteCloser for /dev/full writes
+type fullWriter struct {
+	path string
+}
+
+func (fw *fullWriter) Write(p []byte) (int, error) {
+	return 0, filesystem.NewNoSpaceError("write", fw.path)
+}
+
+func (fw *fullWriter) Close() error {
+	return nil
+}
+
+// faultWriter implements io.WriteCloser for /flaky and /slow, injecting faults on every write
+type faultWriter struct {
+	fs   *DevFS
+	d    *device
+	path string
+}
+
+func (fw *faultWriter) Write(p []byte) (int, error) {
+	if err := fw.fs.faultSettings().inject(fw.d, "write", fw.path); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (fw *faultWriter) Close() error {
+	return nil
+}
+
+// controlWriter buffers writes to a control file and applies them on Close
+type controlWriter struct {
+	fs   *DevFS
+	path string
+	buf  bytes.Buffer
+}
+
+func (cw *controlWriter) Write(p []byte) (int, error) {
+	return cw.buf.Write(p)
+}
+
+func (cw *controlWriter) Close() error {
+	_, err := cw.fs.Write(cw.path, cw.buf.Bytes(), 0, filesystem.WriteFlagNone)
+	return err
+}
+
 // Ensure DevFSPlugin implements ServicePlugin
 var _ plugin.ServicePlugin = (*DevFSPlugin)(nil)
 var _ filesystem.FileSystem = (*DevFS)(nil)
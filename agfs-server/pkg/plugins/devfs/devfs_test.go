@@ -1,10 +1,15 @@
 package devfs
 
 import (
+	"bytes"
+	"errors"
 	"io"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/google/uuid"
 )
 
 func TestDevFSRead(t *testing.T) {
@@ -83,13 +88,13 @@ func TestDevFSStat(t *testing.T) {
 func TestDevFSReadDir(t *testing.T) {
 	fs := &DevFS{}
 
-	// ReadDir root should return null
+	// ReadDir root should return all devices, null first, then faults, mkfifo and fifo
 	entries, err := fs.ReadDir("/")
 	if err != nil {
 		t.Errorf("ReadDir / failed: %v", err)
 	}
-	if len(entries) != 1 {
-		t.Errorf("Expected 1 entry, got %d", len(entries))
+	if len(entries) != len(devices)+3 {
+		t.Errorf("Expected %d entries, got %d", len(devices)+3, len(entries))
 	}
 	if len(entries) > 0 && entries[0].Name != "null" {
 		t.Errorf("Expected entry 'null', got '%s'", entries[0].Name)
@@ -238,3 +243,253 @@ func TestDevFSPlugin(t *testing.T) {
 		t.Errorf("Shutdown failed: %v", err)
 	}
 }
+
+func TestDevFSInfiniteDevices(t *testing.T) {
+	fs := &DevFS{maxReadSize: 1024}
+
+	data, err := fs.Read("/zero", 0, 100)
+	if err != nil || len(data) != 100 || !bytes.Equal(data, make([]byte, 100)) {
+		t.Errorf("Read /zero = %d bytes, %v", len(data), err)
+	}
+
+	// Reads are bounded by maxReadSize, including reads without a size
+	if data, _ := fs.Read("/zero", 0, 1<<30); len(data) != 1024 {
+		t.Errorf("Expected bounded read of 1024 bytes, got %d", len(data))
+	}
+	if data, _ := fs.Read("/urandom", 0, -1); len(data) != 1024 {
+		t.Errorf("Expected unsized read of 1024 bytes, got %d", len(data))
+	}
+
+	// Infinite devices never return EOF, whatever the offset
+	a, err := fs.Read("/random", 1<<40, 32)
+	if err != nil || len(a) != 32 {
+		t.Errorf("Read /random at large offset = %d bytes, %v", len(a), err)
+	}
+	b, _ := fs.Read("/random", 0, 32)
+	if bytes.Equal(a, b) {
+		t.Error("Expected different random reads")
+	}
+
+	if _, err := fs.Write("/zero", []byte("x"), 0, 0); err != nil {
+		t.Errorf("Write to /zero failed: %v", err)
+	}
+	if _, err := fs.Write("/random", []byte("x"), 0, 0); err == nil {
+		t.Error("Expected error writing to /random")
+	}
+}
+
+func TestDevFSIdentifiers(t *testing.T) {
+	fs := &DevFS{}
+
+	data, err := fs.Read("/uuid", 0, -1)
+	if err != nil && err != io.EOF {
+		t.Fatalf("Read /uuid failed: %v", err)
+	}
+	if _, perr := uuid.Parse(strings.TrimSpace(string(data))); perr != nil || len(data) != 37 {
+		t.Errorf("Read /uuid = %q: %v", data, perr)
+	}
+
+	first, _ := fs.Read("/ulid", 0, -1)
+	time.Sleep(2 * time.Millisecond)
+	second, _ := fs.Read("/ulid", 0, -1)
+	if len(first) != 27 || len(second) != 27 || strings.Trim(string(first), crockford+"\n") != "" {
+		t.Errorf("Read /ulid = %q, %q", first, second)
+	}
+	if string(first) >= string(second) {
+		t.Errorf("Expected ULIDs to sort by time: %q >= %q", first, second)
+	}
+
+	info, err := fs.Stat("/uuid")
+	if err != nil || info.Size != 37 || info.Mode != 0444 {
+		t.Errorf("Stat /uuid = %+v, %v", info, err)
+	}
+}
+
+func TestNewULIDEncoding(t *testing.T) {
+	// The timestamp occupies the first 10 characters
+	id := newULID(time.UnixMilli(1469918176385))
+	if id[:10] != "01ARYZ6S41" {
+		t.Errorf("Expected timestamp prefix 01ARYZ6S41, got %s", id[:10])
+	}
+}
+
+func TestDevFSPluginMaxReadSize(t *testing.T) {
+	p := NewDevFSPlugin()
+	if err := p.Validate(map[string]interface{}{"max_read_size": "nope"}); err == nil {
+		t.Error("Expected error for invalid max_read_size")
+	}
+	cfg := map[string]interface{}{"max_read_size": "4KB"}
+	if err := p.Validate(cfg); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	p.Initialize(cfg)
+	if data, _ := p.GetFileSystem().Read("/zero", 0, -1); len(data) != 4096 {
+		t.Errorf("Expected 4096 bytes, got %d", len(data))
+	}
+}
+
+func TestDevFSFull(t *testing.T) {
+	fs := &DevFS{maxReadSize: 1024}
+
+	data, err := fs.Read("/full", 0, 64)
+	if err != nil || !bytes.Equal(data, make([]byte, 64)) {
+		t.Errorf("Read /full = %d bytes, %v", len(data), err)
+	}
+
+	if _, err := fs.Write("/full", []byte("x"), 0, 0); !errors.Is(err, filesystem.ErrNoSpace) {
+		t.Errorf("Expected ErrNoSpace writing to /full, got %v", err)
+	}
+	w, err := fs.OpenWrite("/full")
+	if err != nil {
+		t.Fatalf("OpenWrite /full failed: %v", err)
+	}
+	if _, err := w.Write([]byte("x")); !errors.Is(err, filesystem.ErrNoSpace) {
+		t.Errorf("Expected ErrNoSpace from writer, got %v", err)
+	}
+	if err := fs.Truncate("/full", 0); err != nil {
+		t.Errorf("Truncate /full failed: %v", err)
+	}
+}
+
+func TestDevFSFifo(t *testing.T) {
+	fs := &DevFS{fifos: newFifoTable(8, 50*time.Millisecond)}
+
+	if _, err := fs.Write("/mkfifo", []byte("jobs\nresults\n"), 0, 0); err != nil {
+		t.Fatalf("mkfifo failed: %v", err)
+	}
+	if err := fs.Create("/fifo/jobs"); !errors.Is(err, filesystem.ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists, got %v", err)
+	}
+	if err := fs.Create("/fifo/extra"); err != nil {
+		t.Errorf("Create FIFO failed: %v", err)
+	}
+	entries, err := fs.ReadDir("/fifo")
+	if err != nil || len(entries) != 3 || entries[0].Name != "extra" || entries[1].Meta.Type != "fifo" {
+		t.Fatalf("ReadDir /fifo = %+v, %v", entries, err)
+	}
+
+	// Data is consumed in order
+	fs.Write("/fifo/jobs", []byte("abc"), 0, 0)
+	fs.Write("/fifo/jobs", []byte("de"), 0, 0)
+	if info, _ := fs.Stat("/fifo/jobs"); info.Size != 5 {
+		t.Errorf("Expected 5 buffered bytes, got %d", info.Size)
+	}
+	if data, err := fs.Read("/fifo/jobs", 0, 4); err != nil || string(data) != "abcd" {
+		t.Errorf("Read = %q, %v", data, err)
+	}
+	if data, err := fs.Read("/fifo/jobs", 0, -1); err != nil || string(data) != "e" {
+		t.Errorf("Read = %q, %v", data, err)
+	}
+
+	// An empty FIFO returns EOF after the timeout
+	if data, err := fs.Read("/fifo/jobs", 0, -1); err != io.EOF || len(data) != 0 {
+		t.Errorf("Expected EOF from empty FIFO, got %q, %v", data, err)
+	}
+
+	// A blocked reader wakes up when data arrives
+	done := make(chan string)
+	go func() {
+		data, _ := fs.Read("/fifo/results", 0, -1)
+		done <- string(data)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	fs.Write("/fifo/results", []byte("ok"), 0, 0)
+	if got := <-done; got != "ok" {
+		t.Errorf("Blocked reader got %q", got)
+	}
+
+	// Writes beyond capacity fail once no reader makes room
+	if n, err := fs.Write("/fifo/results", []byte("0123456789"), 0, 0); err == nil || n != 8 {
+		t.Errorf("Expected partial write of 8 bytes and an error, got %d, %v", n, err)
+	}
+
+	// Streaming reads drain the FIFO
+	r, err := fs.Open("/fifo/results")
+	if err != nil {
+		t.Fatalf("Open FIFO failed: %v", err)
+	}
+	if data, _ := io.ReadAll(r); string(data) != "01234567" {
+		t.Errorf("Streamed %q", data)
+	}
+
+	if err := fs.Remove("/fifo/results"); err != nil {
+		t.Errorf("Remove FIFO failed: %v", err)
+	}
+	if _, err := fs.Stat("/fifo/results"); err == nil {
+		t.Error("Expected removed FIFO to be gone")
+	}
+	if _, err := fs.Write("/mkfifo", []byte("a/b"), 0, 0); !errors.Is(err, filesystem.ErrInvalidArgument) {
+		t.Errorf("Expected ErrInvalidArgument for bad name, got %v", err)
+	}
+}
+
+func TestDevFSFaultInjection(t *testing.T) {
+	fs := &DevFS{maxReadSize: 1024, faults: newFaultSettings(1, 20*time.Millisecond)}
+
+	// An error rate of 1 fails every operation
+	if _, err := fs.Read("/flaky", 0, 16); err == nil {
+		t.Error("Expected injected read error")
+	}
+	if _, err := fs.Write("/flaky", []byte("x"), 0, 0); err == nil {
+		t.Error("Expected injected write error")
+	}
+
+	// Settings can be changed through /faults
+	if _, err := fs.Write("/faults/error_rate", []byte("0\n"), 0, 0); err != nil {
+		t.Fatalf("Write error_rate failed: %v", err)
+	}
+	if data, err := fs.Read("/flaky", 0, 16); err != nil || len(data) != 16 {
+		t.Errorf("Read /flaky = %d bytes, %v", len(data), err)
+	}
+	if data, _ := fs.Read("/faults/error_rate", 0, -1); string(data) != "0\n" {
+		t.Errorf("Read error_rate = %q", data)
+	}
+
+	start := time.Now()
+	if data, err := fs.Read("/slow", 0, 8); err != nil || len(data) != 8 {
+		t.Errorf("Read /slow = %d bytes, %v", len(data), err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Expected /slow to take at least 20ms, took %s", elapsed)
+	}
+	if data, _ := fs.Read("/faults/latency", 0, -1); string(data) != "20ms\n" {
+		t.Errorf("Read latency = %q", data)
+	}
+
+	for _, tc := range []struct{ path, value string }{
+		{"/faults/error_rate", "1.5"},
+		{"/faults/error_rate", "often"},
+		{"/faults/latency", "-1s"},
+		{"/faults/latency", "1h"},
+	} {
+		if _, err := fs.Write(tc.path, []byte(tc.value), 0, 0); !errors.Is(err, filesystem.ErrInvalidArgument) {
+			t.Errorf("Expected ErrInvalidArgument for %s=%s, got %v", tc.path, tc.value, err)
+		}
+	}
+}
+
+func TestDevFSPluginFaultConfig(t *testing.T) {
+	p := NewDevFSPlugin()
+	for _, cfg := range []map[string]interface{}{
+		{"flaky_error_rate": 2.0},
+		{"flaky_error_rate": "x"},
+		{"slow_latency": "soon"},
+	} {
+		if err := p.Validate(cfg); err == nil {
+			t.Errorf("Expected error for %v", cfg)
+		}
+	}
+
+	cfg := map[string]interface{}{"flaky_error_rate": "0.25", "slow_latency": "5ms"}
+	if err := p.Validate(cfg); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	p.Initialize(cfg)
+	fs := p.GetFileSystem()
+	if data, _ := fs.Read("/faults/error_rate", 0, -1); string(data) != "0.25\n" {
+		t.Errorf("Read error_rate = %q", data)
+	}
+	if data, _ := fs.Read("/faults/latency", 0, -1); string(data) != "5ms\n" {
+		t.Errorf("Read latency = %q", data)
+	}
+}
@@ -0,0 +1,81 @@
+package devfs
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// device describes a device file
+type device struct {
+	name     string
+	mode     uint32
+	infinite bool                 // Reads never reach EOF and are bounded by maxReadSize
+	size     int64                // Reported size of finite devices
+	read     func(n int64) []byte // Produces content; n is the bounded read size for infinite devices
+	writable bool                 // Writes are accepted and discarded
+	full     bool                 // Writes fail with ENOSPC
+	flaky    bool                 // Operations fail at the configured error rate
+	slow     bool                 // Operations are delayed by the configured latency
+}
+
+// devices lists the device files in display order
+var devices = []*device{
+	{name: "null", mode: 0666, writable: true},
+	{name: "zero", mode: 0666, infinite: true, read: zeroBytes, writable: true},
+	{name: "full", mode: 0666, infinite: true, read: zeroBytes, full: true},
+	{name: "flaky", mode: 0666, infinite: true, read: zeroBytes, writable: true, flaky: true},
+	{name: "slow", mode: 0666, infinite: true, read: zeroBytes, writable: true, slow: true},
+	{name: "random", mode: 0444, infinite: true, read: randomBytes},
+	{name: "urandom", mode: 0444, infinite: true, read: randomBytes},
+	{name: "uuid", mode: 0444, size: 37, read: func(int64) []byte { return []byte(uuid.NewString() + "\n") }},
+	{name: "ulid", mode: 0444, size: 27, read: func(int64) []byte { return []byte(newULID(time.Now()) + "\n") }},
+}
+
+// lookupDevice returns the device at path, or nil
+func lookupDevice(path string) *device {
+	for _, d := range devices {
+		if path == "/"+d.name {
+			return d
+		}
+	}
+	return nil
+}
+
+// zeroBytes returns n zero bytes
+func zeroBytes(n int64) []byte {
+	return make([]byte, n)
+}
+
+// randomBytes returns n cryptographically secure random bytes
+// Both /random and /urandom use it: crypto/rand does not block once the
+// kernel entropy pool is initialized, like modern Linux /dev/random.
+func randomBytes(n int64) []byte {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return buf
+}
+
+// crockford is the ULID base32 alphabet
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newULID returns a ULID: a 48-bit millisecond timestamp followed by 80 random bits,
+// encoded as 26 Crockford base32 characters so that ULIDs sort by creation time
+func newULID(t time.Time) string {
+	var id [16]byte
+	binary.BigEndian.PutUint64(id[:8], uint64(t.UnixMilli())<<16)
+	rand.Read(id[6:])
+
+	// 128 bits as 26 characters of 5 bits, the first carrying only 3 bits
+	out := make([]byte, 26)
+	hi := binary.BigEndian.Uint64(id[:8])
+	lo := binary.BigEndian.Uint64(id[8:])
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out)
+}
@@ -0,0 +1,130 @@
+package devfs
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+const (
+	faultsDir      = "faults"     // Directory of fault-injection settings
+	faultErrorRate = "error_rate" // Probability that an operation on /flaky fails
+	faultLatency   = "latency"    // Delay added to every operation on /slow
+
+	defaultErrorRate = 0.5
+	defaultLatency   = time.Second
+	maxLatency       = 10 * time.Minute
+)
+
+// faultSettings holds the fault-injection parameters of /flaky and /slow
+// They are set from the plugin configuration and can be changed at runtime
+// through the files in /faults.
+type faultSettings struct {
+	errorRate float64
+	latency   time.Duration
+	mu        sync.Mutex
+}
+
+func newFaultSettings(errorRate float64, latency time.Duration) *faultSettings {
+	return &faultSettings{errorRate: errorRate, latency: latency}
+}
+
+// inject applies the faults of a device to one operation
+func (s *faultSettings) inject(d *device, op, path string) error {
+	s.mu.Lock()
+	errorRate, latency := s.errorRate, s.latency
+	s.mu.Unlock()
+
+	if d.slow {
+		time.Sleep(latency)
+	}
+	if d.flaky && rand.Float64() < errorRate {
+		return fmt.Errorf("%s: %s: injected fault (input/output error)", op, path)
+	}
+	return nil
+}
+
+// faultFile returns the setting name of a path under /faults/, or ""
+func faultFile(path string) string {
+	switch path {
+	case "/" + faultsDir + "/" + faultErrorRate:
+		return faultErrorRate
+	case "/" + faultsDir + "/" + faultLatency:
+		return faultLatency
+	}
+	return ""
+}
+
+// get renders a setting as it is read from /faults
+func (s *faultSettings) get(name string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if name == faultErrorRate {
+		return []byte(strconv.FormatFloat(s.errorRate, 'g', -1, 64) + "\n")
+	}
+	return []byte(s.latency.String() + "\n")
+}
+
+// set parses and applies a setting written to /faults
+func (s *faultSettings) set(name string, data []byte) error {
+	value := strings.TrimSpace(string(data))
+	if name == faultErrorRate {
+		rate, err := parseErrorRate(value)
+		if err != nil {
+			return filesystem.NewInvalidArgumentError(faultErrorRate, value, err.Error())
+		}
+		s.mu.Lock()
+		s.errorRate = rate
+		s.mu.Unlock()
+		return nil
+	}
+
+	latency, err := parseLatency(value)
+	if err != nil {
+		return filesystem.NewInvalidArgumentError(faultLatency, value, err.Error())
+	}
+	s.mu.Lock()
+	s.latency = latency
+	s.mu.Unlock()
+	return nil
+}
+
+func parseErrorRate(s string) (float64, error) {
+	rate, err := strconv.ParseFloat(s, 64)
+	if err != nil || rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("must be a number between 0 and 1")
+	}
+	return rate, nil
+}
+
+func parseLatency(s string) (time.Duration, error) {
+	latency, err := time.ParseDuration(s)
+	if err != nil || latency < 0 || latency > maxLatency {
+		return 0, fmt.Errorf("must be a duration between 0s and %s", maxLatency)
+	}
+	return latency, nil
+}
+
+// errorRateConfig reads flaky_error_rate, which may be a number or a string
+// (dynamic mounts pass every option as a string)
+func errorRateConfig(cfg map[string]interface{}) (float64, error) {
+	switch v := cfg["flaky_error_rate"].(type) {
+	case nil:
+		return defaultErrorRate, nil
+	case float64:
+		return parseErrorRate(strconv.FormatFloat(v, 'g', -1, 64))
+	case int:
+		return parseErrorRate(strconv.Itoa(v))
+	case int64:
+		return parseErrorRate(strconv.FormatInt(v, 10))
+	case string:
+		return parseErrorRate(v)
+	default:
+		return 0, fmt.Errorf("must be a number between 0 and 1")
+	}
+}
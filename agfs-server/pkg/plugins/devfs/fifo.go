@@ -0,0 +1,224 @@
+package devfs
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+const (
+	fifoDir     = "fifo"   // Directory holding named FIFOs
+	mkfifoCtl   = "mkfifo" // Control file: write a name to create /fifo/<name>
+	fifoDirPath = "/" + fifoDir + "/"
+
+	defaultFifoSize    = 64 * 1024
+	defaultFifoTimeout = 30 * time.Second
+)
+
+// fifo is a named pipe: a bounded byte buffer shared by all clients
+// Reads consume data and block while the pipe is empty; writes block while
+// it is full. Both give up after the table's timeout.
+type fifo struct {
+	name    string
+	buf     []byte
+	removed bool
+	modTime time.Time
+	changed chan struct{} // Closed and replaced whenever buf or removed changes
+	mu      sync.Mutex
+}
+
+// notify wakes up blocked readers and writers; callers hold f.mu
+func (f *fifo) notify() {
+	close(f.changed)
+	f.changed = make(chan struct{})
+	f.modTime = time.Now()
+}
+
+// fifoTable holds the named FIFOs of a devfs instance
+type fifoTable struct {
+	size    int           // Capacity of each FIFO in bytes
+	timeout time.Duration // How long reads and writes block
+	fifos   map[string]*fifo
+	mu      sync.Mutex
+}
+
+func newFifoTable(size int64, timeout time.Duration) *fifoTable {
+	return &fifoTable{size: int(size), timeout: timeout, fifos: make(map[string]*fifo)}
+}
+
+// fifoName returns the FIFO name of a path under /fifo/, or "" if path is not one
+func fifoName(path string) string {
+	if !strings.HasPrefix(path, fifoDirPath) {
+		return ""
+	}
+	name := strings.TrimPrefix(path, fifoDirPath)
+	if name == "" || strings.Contains(name, "/") {
+		return ""
+	}
+	return name
+}
+
+// validFifoName checks a name given to mkfifo
+func validFifoName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\x00") {
+		return filesystem.NewInvalidArgumentError("name", name, "FIFO names must be non-empty and contain no slashes")
+	}
+	return nil
+}
+
+func (t *fifoTable) get(name string) *fifo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.fifos[name]
+}
+
+func (t *fifoTable) create(name string) error {
+	if err := validFifoName(name); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.fifos[name]; ok {
+		return filesystem.NewAlreadyExistsError("fifo", fifoDirPath+name)
+	}
+	t.fifos[name] = &fifo{name: name, modTime: time.Now(), changed: make(chan struct{})}
+	return nil
+}
+
+// remove deletes a FIFO; blocked readers get EOF and blocked writers an error
+func (t *fifoTable) remove(name string) bool {
+	t.mu.Lock()
+	f, ok := t.fifos[name]
+	delete(t.fifos, name)
+	t.mu.Unlock()
+	if ok {
+		f.mu.Lock()
+		f.removed = true
+		f.buf = nil
+		f.notify()
+		f.mu.Unlock()
+	}
+	return ok
+}
+
+func (t *fifoTable) list() []*fifo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list := make([]*fifo, 0, len(t.fifos))
+	for _, f := range t.fifos {
+		list = append(list, f)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	return list
+}
+
+// read takes up to size bytes (all buffered data if size < 0)
+// It waits for data while the FIFO is empty and returns io.EOF if none
+// arrives before the timeout or the FIFO is removed.
+func (t *fifoTable) read(f *fifo, size int64) ([]byte, error) {
+	timer := time.NewTimer(t.timeout)
+	defer timer.Stop()
+
+	for {
+		f.mu.Lock()
+		if len(f.buf) > 0 {
+			n := int64(len(f.buf))
+			if size >= 0 && size < n {
+				n = size
+			}
+			data := append([]byte(nil), f.buf[:n]...)
+			f.buf = append(f.buf[:0], f.buf[n:]...)
+			f.notify()
+			f.mu.Unlock()
+			return data, nil
+		}
+		if f.removed || size == 0 {
+			f.mu.Unlock()
+			return nil, io.EOF
+		}
+		changed := f.changed
+		f.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return nil, io.EOF
+		}
+	}
+}
+
+// write appends data, waiting for readers to make room while the FIFO is full
+func (t *fifoTable) write(f *fifo, data []byte) (int64, error) {
+	timer := time.NewTimer(t.timeout)
+	defer timer.Stop()
+
+	var written int64
+	for {
+		f.mu.Lock()
+		if f.removed {
+			f.mu.Unlock()
+			return written, filesystem.NewNotFoundError("write", fifoDirPath+f.name)
+		}
+		if room := t.size - len(f.buf); room > 0 {
+			n := min(room, len(data))
+			f.buf = append(f.buf, data[:n]...)
+			data = data[n:]
+			written += int64(n)
+			f.notify()
+		}
+		if len(data) == 0 {
+			f.mu.Unlock()
+			return written, nil
+		}
+		changed := f.changed
+		f.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return written, fmt.Errorf("fifo %s is full: no reader made room within %s", f.name, t.timeout)
+		}
+	}
+}
+
+// buffered returns the number of unread bytes and the last modification time
+func (f *fifo) buffered() (int64, time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return int64(len(f.buf)), f.modTime
+}
+
+// fifoReader streams a FIFO until it stays empty for the timeout or is removed
+type fifoReader struct {
+	table *fifoTable
+	f     *fifo
+}
+
+func (r *fifoReader) Read(p []byte) (int, error) {
+	data, err := r.table.read(r.f, int64(len(p)))
+	return copy(p, data), err
+}
+
+func (r *fifoReader) Close() error {
+	return nil
+}
+
+// fifoWriter writes to a FIFO as data arrives
+type fifoWriter struct {
+	table *fifoTable
+	f     *fifo
+}
+
+func (w *fifoWriter) Write(p []byte) (int, error) {
+	n, err := w.table.write(w.f, p)
+	return int(n), err
+}
+
+func (w *fifoWriter) Close() error {
+	return nil
+}
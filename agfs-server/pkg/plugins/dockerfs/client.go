@@ -0,0 +1,335 @@
+package dockerfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+// requestTimeout bounds API calls that are not streams or exec runs
+const requestTimeout = 30 * time.Second
+
+// dockerClient talks to the Docker Engine API
+// Any daemon speaking the Docker API works, including Podman's compatible socket.
+type dockerClient struct {
+	http       *http.Client
+	baseURL    string
+	apiVersion string
+}
+
+// containerSummary is an entry of GET /containers/json
+type containerSummary struct {
+	ID      string   `json:"Id"`
+	Names   []string `json:"Names"`
+	Image   string   `json:"Image"`
+	State   string   `json:"State"`
+	Created int64    `json:"Created"`
+}
+
+// Name returns the primary container name without the leading slash
+func (c containerSummary) Name() string {
+	if len(c.Names) == 0 {
+		return shortID(c.ID)
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
+}
+
+// containerDetails holds the fields of GET /containers/{id}/json used by the plugin
+type containerDetails struct {
+	ID      string `json:"Id"`
+	Name    string `json:"Name"`
+	Created string `json:"Created"`
+	State   struct {
+		Status string `json:"Status"`
+	} `json:"State"`
+	Config struct {
+		Tty bool `json:"Tty"`
+	} `json:"Config"`
+}
+
+// imageSummary is an entry of GET /images/json
+type imageSummary struct {
+	ID       string   `json:"Id"`
+	RepoTags []string `json:"RepoTags"`
+	Size     int64    `json:"Size"`
+	Created  int64    `json:"Created"`
+}
+
+// newDockerClient creates a client for a daemon address such as
+// unix:///var/run/docker.sock or tcp://127.0.0.1:2375
+func newDockerClient(host, apiVersion string) (*dockerClient, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docker host %q: %w", host, err)
+	}
+
+	transport := &http.Transport{}
+	baseURL := ""
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}
+		baseURL = "http://docker"
+	case "tcp", "http":
+		baseURL = "http://" + u.Host
+	case "https":
+		baseURL = "https://" + u.Host
+	default:
+		return nil, fmt.Errorf("unsupported docker host scheme %q (use unix://, tcp://, http:// or https://)", u.Scheme)
+	}
+
+	c := &dockerClient{http: &http.Client{Transport: transport}, baseURL: baseURL}
+	if apiVersion != "" {
+		c.apiVersion = "/" + strings.TrimPrefix(apiVersion, "/")
+	}
+	return c, nil
+}
+
+// apiError is an error response from the daemon
+type apiError struct {
+	Status  int
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("docker API error (%d): %s", e.Status, e.Message)
+}
+
+// mapError converts daemon errors to filesystem errors where one applies
+func mapError(err error, op, path string) error {
+	if apiErr, ok := err.(*apiError); ok && apiErr.Status == http.StatusNotFound {
+		return filesystem.NewNotFoundError(op, path)
+	}
+	return err
+}
+
+// do sends a request and returns the response body, or an apiError for non-2xx statuses
+func (c *dockerClient) do(ctx context.Context, method, path string, query url.Values, body interface{}) (io.ReadCloser, error) {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	endpoint := c.baseURL + c.apiVersion + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("docker API request failed: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		apiErr := &apiError{Status: resp.StatusCode}
+		data, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(data, apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = strings.TrimSpace(string(data))
+		}
+		return nil, apiErr
+	}
+	return resp.Body, nil
+}
+
+// getJSON performs a GET and returns the raw JSON body
+func (c *dockerClient) getJSON(path string, query url.Values) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	body, err := c.do(ctx, http.MethodGet, path, query, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// ListContainers lists containers, only running ones unless all is set
+func (c *dockerClient) ListContainers(all bool) ([]containerSummary, error) {
+	query := url.Values{}
+	if all {
+		query.Set("all", "1")
+	}
+	data, err := c.getJSON("/containers/json", query)
+	if err != nil {
+		return nil, err
+	}
+	var containers []containerSummary
+	if err := json.Unmarshal(data, &containers); err != nil {
+		return nil, fmt.Errorf("failed to decode container list: %w", err)
+	}
+	return containers, nil
+}
+
+// InspectContainer returns the raw inspect JSON and the decoded fields the plugin needs
+func (c *dockerClient) InspectContainer(id string) ([]byte, *containerDetails, error) {
+	data, err := c.getJSON("/containers/"+url.PathEscape(id)+"/json", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	var details containerDetails
+	if err := json.Unmarshal(data, &details); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode container %s: %w", id, err)
+	}
+	return data, &details, nil
+}
+
+// ContainerLogs returns stdout and stderr of a container, demultiplexed
+// With follow set, the returned reader stays open until ctx is cancelled or the container stops.
+func (c *dockerClient) ContainerLogs(ctx context.Context, id string, tty bool, tail int, follow bool) (io.ReadCloser, error) {
+	query := url.Values{"stdout": {"1"}, "stderr": {"1"}}
+	if tail > 0 {
+		query.Set("tail", fmt.Sprintf("%d", tail))
+	}
+	if follow {
+		query.Set("follow", "1")
+	}
+	body, err := c.do(ctx, http.MethodGet, "/containers/"+url.PathEscape(id)+"/logs", query, nil)
+	if err != nil {
+		return nil, err
+	}
+	if tty {
+		return body, nil
+	}
+	return newDemuxReader(body), nil
+}
+
+// ContainerAction runs a lifecycle action such as start, stop or restart
+func (c *dockerClient) ContainerAction(id, action string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	body, err := c.do(ctx, http.MethodPost, "/containers/"+url.PathEscape(id)+"/"+action, nil, nil)
+	if err != nil {
+		return err
+	}
+	return body.Close()
+}
+
+// Exec runs a command in a container and returns its combined output and exit code
+func (c *dockerClient) Exec(ctx context.Context, id string, cmd []string) ([]byte, int, error) {
+	created, err := c.do(ctx, http.MethodPost, "/containers/"+url.PathEscape(id)+"/exec", nil, map[string]interface{}{
+		"AttachStdout": true,
+		"AttachStderr": true,
+		"Cmd":          cmd,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	var execResp struct {
+		ID string `json:"Id"`
+	}
+	err = json.NewDecoder(created).Decode(&execResp)
+	created.Close()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decode exec response: %w", err)
+	}
+
+	stream, err := c.do(ctx, http.MethodPost, "/exec/"+execResp.ID+"/start", nil, map[string]interface{}{"Detach": false, "Tty": false})
+	if err != nil {
+		return nil, 0, err
+	}
+	output, err := io.ReadAll(newDemuxReader(stream))
+	stream.Close()
+	if err != nil {
+		return output, 0, fmt.Errorf("failed to read exec output: %w", err)
+	}
+
+	data, err := c.getJSON("/exec/"+execResp.ID+"/json", nil)
+	if err != nil {
+		return output, 0, err
+	}
+	var inspect struct {
+		ExitCode int `json:"ExitCode"`
+	}
+	if err := json.Unmarshal(data, &inspect); err != nil {
+		return output, 0, fmt.Errorf("failed to decode exec result: %w", err)
+	}
+	return output, inspect.ExitCode, nil
+}
+
+// ListImages lists the images on the daemon
+func (c *dockerClient) ListImages() ([]imageSummary, error) {
+	data, err := c.getJSON("/images/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	var images []imageSummary
+	if err := json.Unmarshal(data, &images); err != nil {
+		return nil, fmt.Errorf("failed to decode image list: %w", err)
+	}
+	return images, nil
+}
+
+// InspectImage returns the raw inspect JSON of an image
+func (c *dockerClient) InspectImage(id string) ([]byte, error) {
+	return c.getJSON("/images/"+url.PathEscape(id)+"/json", nil)
+}
+
+// demuxReader strips the 8-byte frame headers Docker adds to non-TTY stdout/stderr streams
+// stdout and stderr are interleaved in the order they arrive.
+type demuxReader struct {
+	src       io.ReadCloser
+	remaining uint32 // Bytes left in the current frame
+	header    [8]byte
+}
+
+func newDemuxReader(src io.ReadCloser) *demuxReader {
+	return &demuxReader{src: src}
+}
+
+func (d *demuxReader) Read(p []byte) (int, error) {
+	for d.remaining == 0 {
+		if _, err := io.ReadFull(d.src, d.header[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return 0, io.EOF
+			}
+			return 0, err
+		}
+		d.remaining = binary.BigEndian.Uint32(d.header[4:])
+	}
+	if uint32(len(p)) > d.remaining {
+		p = p[:d.remaining]
+	}
+	n, err := d.src.Read(p)
+	d.remaining -= uint32(n)
+	if err == io.EOF && d.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (d *demuxReader) Close() error {
+	return d.src.Close()
+}
+
+// shortID returns the 12-character form of a container or image ID
+func shortID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
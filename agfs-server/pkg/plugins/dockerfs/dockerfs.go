@@ -0,0 +1,830 @@
+package dockerfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PluginName = "dockerfs" // Name of this plugin
+)
+
+// Meta values for DockerFS plugin
+const (
+	MetaValueContainer = "container" // Container directory
+	MetaValueImage     = "image"     // Image directory
+	MetaValueInspect   = "inspect"   // Inspect JSON
+	MetaValueLogs      = "logs"      // Container logs (supports streaming)
+	MetaValueExec      = "exec"      // Exec control file
+	MetaValueControl   = "control"   // Lifecycle control file
+	MetaValueStatus    = "status"    // Read-only status files
+)
+
+// Files inside each container and image directory
+const (
+	fileInspect = "inspect"
+	fileStatus  = "status"
+	fileLogs    = "logs"
+	fileExec    = "exec"
+	fileControl = "control"
+	fileTags    = "tags"
+)
+
+var containerFiles = []string{fileInspect, fileStatus, fileLogs, fileExec, fileControl}
+var imageFiles = []string{fileInspect, fileTags}
+
+// containerActions are the lifecycle actions accepted by the control file
+var containerActions = []string{"start", "stop", "restart", "kill", "pause", "unpause"}
+
+// ExecRecord is the result of the last command run through a container's exec file
+type ExecRecord struct {
+	Command    string    `json:"command"`
+	ExitCode   int       `json:"exit_code"`
+	Output     string    `json:"output"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// DockerFSPlugin exposes containers and images of a Docker daemon as files
+//
+//	/containers/<name>/inspect - container inspect JSON
+//	/containers/<name>/status  - container state ("running", "exited", ...)
+//	/containers/<name>/logs    - recent stdout/stderr; streaming reads follow the log
+//	/containers/<name>/exec    - write a command to run it, read the last result
+//	/containers/<name>/control - write start, stop, restart, kill, pause or unpause
+//	/images/<id>/inspect       - image inspect JSON
+//	/images/<id>/tags          - repository tags, one per line
+type DockerFSPlugin struct {
+	client        *dockerClient
+	host          string
+	allContainers bool
+	logTail       int
+	allowExec     bool
+	allowControl  bool
+	shell         string
+	execTimeout   time.Duration
+
+	execResults map[string]ExecRecord // Container ID -> last exec result
+	mu          sync.Mutex
+	metadata    plugin.PluginMetadata
+}
+
+// NewDockerFSPlugin creates a new Docker plugin
+func NewDockerFSPlugin() *DockerFSPlugin {
+	return &DockerFSPlugin{
+		execResults: make(map[string]ExecRecord),
+		metadata: plugin.PluginMetadata{
+			Name:        PluginName,
+			Version:     "1.0.0",
+			Description: "Inspect containers and images, read logs and run commands through files",
+			Author:      "AGFS Server",
+		},
+	}
+}
+
+func (d *DockerFSPlugin) Name() string {
+	return d.metadata.Name
+}
+
+func (d *DockerFSPlugin) Validate(cfg map[string]interface{}) error {
+	allowedKeys := []string{"mount_path", "host", "api_version", "all_containers", "log_tail", "allow_exec", "allow_control", "shell", "exec_timeout"}
+	if err := config.ValidateOnlyKnownKeys(cfg, allowedKeys); err != nil {
+		return err
+	}
+
+	for _, key := range []string{"host", "api_version", "shell", "exec_timeout"} {
+		if err := config.ValidateStringType(cfg, key); err != nil {
+			return err
+		}
+	}
+	for _, key := range []string{"all_containers", "allow_exec", "allow_control"} {
+		if err := config.ValidateBoolType(cfg, key); err != nil {
+			return err
+		}
+	}
+	if err := config.ValidateIntType(cfg, "log_tail"); err != nil {
+		return err
+	}
+
+	if timeout, err := time.ParseDuration(config.GetStringConfig(cfg, "exec_timeout", "30s")); err != nil || timeout <= 0 {
+		return fmt.Errorf("invalid exec_timeout: must be a positive duration such as \"30s\"")
+	}
+	if tail := config.GetIntConfig(cfg, "log_tail", 1000); tail < 0 {
+		return fmt.Errorf("log_tail must not be negative")
+	}
+	return nil
+}
+
+func (d *DockerFSPlugin) Initialize(cfg map[string]interface{}) error {
+	defaultHost := os.Getenv("DOCKER_HOST")
+	if defaultHost == "" {
+		defaultHost = "unix:///var/run/docker.sock"
+	}
+	d.host = config.GetStringConfig(cfg, "host", defaultHost)
+
+	client, err := newDockerClient(d.host, config.GetStringConfig(cfg, "api_version", ""))
+	if err != nil {
+		return err
+	}
+	d.client = client
+
+	d.execTimeout, err = time.ParseDuration(config.GetStringConfig(cfg, "exec_timeout", "30s"))
+	if err != nil {
+		return fmt.Errorf("invalid exec_timeout: %w", err)
+	}
+	d.allContainers = config.GetBoolConfig(cfg, "all_containers", false)
+	d.logTail = config.GetIntConfig(cfg, "log_tail", 1000)
+	d.allowExec = config.GetBoolConfig(cfg, "allow_exec", false)
+	d.allowControl = config.GetBoolConfig(cfg, "allow_control", false)
+	d.shell = config.GetStringConfig(cfg, "shell", "/bin/sh")
+
+	log.Infof("[dockerfs] Initialized (host=%s, allow_exec=%v, allow_control=%v)", d.host, d.allowExec, d.allowControl)
+	return nil
+}
+
+func (d *DockerFSPlugin) GetFileSystem() filesystem.FileSystem {
+	return &dockerFS{plugin: d}
+}
+
+func (d *DockerFSPlugin) GetReadme() string {
+	return `DockerFS Plugin - Containers and Images as Files
+
+This plugin exposes a Docker daemon as a file system, so containers can be
+inspected, debugged and operated with cat, echo and tail instead of the
+docker CLI.
+
+STRUCTURE:
+  /dockerfs/
+    README              - This documentation
+    containers/
+      <name>/           - A container (running only, unless all_containers)
+        inspect         - Container inspect JSON (read-only)
+        status          - State: running, exited, paused, ... (read-only)
+        logs            - Last log_tail lines of stdout/stderr (read-only)
+                          Streaming reads (tail -f) follow the log
+        exec            - Write a command to run it in the container;
+                          read the JSON result of the last command
+        control         - Write start, stop, restart, kill, pause or unpause
+    images/
+      <id>/             - An image, by short ID
+        inspect         - Image inspect JSON (read-only)
+        tags            - Repository tags, one per line (read-only)
+
+  Containers can also be addressed by ID even if not listed.
+
+EXAMPLES:
+  # Find failing containers
+  agfs:/> ls /dockerfs/containers
+  agfs:/> cat /dockerfs/containers/web/status
+
+  # Read and follow logs
+  agfs:/> cat /dockerfs/containers/web/logs
+  agfs:/> tail -f /dockerfs/containers/web/logs
+
+  # Run a command (allow_exec = true)
+  agfs:/> echo "df -h /data" > /dockerfs/containers/db/exec
+  agfs:/> cat /dockerfs/containers/db/exec
+
+  # Restart a container (allow_control = true)
+  agfs:/> echo restart > /dockerfs/containers/web/control
+
+CONFIGURATION:
+  [plugins.dockerfs]
+  enabled = true
+  path = "/dockerfs"
+
+    [plugins.dockerfs.config]
+    host = "unix:///var/run/docker.sock"   # defaults to DOCKER_HOST
+    all_containers = false   # also list stopped containers
+    log_tail = 1000          # lines returned when reading logs
+    allow_exec = false       # enable the exec file
+    allow_control = false    # enable the control file
+    shell = "/bin/sh"        # shell used inside containers for exec
+    exec_timeout = "30s"
+
+NOTES:
+  - exec and control are disabled by default: anyone who can write to
+    them can run commands in containers as root.
+  - File sizes are reported as 0; content is fetched on every read.
+  - Exec results are kept in memory, one per container.
+  - Podman works through its Docker-compatible API socket.
+`
+}
+
+func (d *DockerFSPlugin) GetConfigParams() []plugin.ConfigParameter {
+	return []plugin.ConfigParameter{
+		{
+			Name:        "host",
+			Type:        "string",
+			Required:    false,
+			Default:     "unix:///var/run/docker.sock",
+			Description: "Docker daemon address (unix://, tcp://, http:// or https://), defaults to DOCKER_HOST",
+		},
+		{
+			Name:        "api_version",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Docker API version to pin, e.g. v1.43 (daemon default if empty)",
+		},
+		{
+			Name:        "all_containers",
+			Type:        "bool",
+			Required:    false,
+			Default:     "false",
+			Description: "List stopped containers as well as running ones",
+		},
+		{
+			Name:        "log_tail",
+			Type:        "int",
+			Required:    false,
+			Default:     "1000",
+			Description: "Number of log lines returned when reading logs (0 for all)",
+		},
+		{
+			Name:        "allow_exec",
+			Type:        "bool",
+			Required:    false,
+			Default:     "false",
+			Description: "Allow running commands in containers through the exec file",
+		},
+		{
+			Name:        "allow_control",
+			Type:        "bool",
+			Required:    false,
+			Default:     "false",
+			Description: "Allow starting, stopping and restarting containers through the control file",
+		},
+		{
+			Name:        "shell",
+			Type:        "string",
+			Required:    false,
+			Default:     "/bin/sh",
+			Description: "Shell used inside containers to run exec commands",
+		},
+		{
+			Name:        "exec_timeout",
+			Type:        "string",
+			Required:    false,
+			Default:     "30s",
+			Description: "Maximum duration of an exec command",
+		},
+	}
+}
+
+func (d *DockerFSPlugin) Shutdown() error {
+	return nil
+}
+
+// dockerFS implements the FileSystem interface for Docker access
+type dockerFS struct {
+	plugin *DockerFSPlugin
+}
+
+// dockerPath is a parsed path inside the plugin
+type dockerPath struct {
+	kind string // "containers" or "images", empty for /
+	name string // Container name or image ID
+	file string // File inside the container or image directory
+}
+
+// parseDockerPath parses paths like /containers/<name>/<file> and /images/<id>/<file>
+func parseDockerPath(p string) (dockerPath, error) {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return dockerPath{}, nil
+	}
+
+	parts := strings.Split(p, "/")
+	if (parts[0] != "containers" && parts[0] != "images") || len(parts) > 3 {
+		return dockerPath{}, filesystem.NewNotFoundError("stat", "/"+p)
+	}
+	dp := dockerPath{kind: parts[0]}
+	if len(parts) > 1 {
+		dp.name = parts[1]
+	}
+	if len(parts) > 2 {
+		dp.file = parts[2]
+		files := containerFiles
+		if dp.kind == "images" {
+			files = imageFiles
+		}
+		if !contains(files, dp.file) {
+			return dockerPath{}, filesystem.NewNotFoundError("stat", "/"+p)
+		}
+	}
+	return dp, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDockerTime parses the RFC3339 timestamps of inspect responses
+func parseDockerTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Now()
+	}
+	return t
+}
+
+func (dfs *dockerFS) inspectContainer(op, path, name string) ([]byte, *containerDetails, error) {
+	raw, details, err := dfs.plugin.client.InspectContainer(name)
+	if err != nil {
+		return nil, nil, mapError(err, op, path)
+	}
+	return raw, details, nil
+}
+
+func (dfs *dockerFS) inspectImage(op, path, id string) ([]byte, error) {
+	raw, err := dfs.plugin.client.InspectImage(id)
+	if err != nil {
+		return nil, mapError(err, op, path)
+	}
+	return raw, nil
+}
+
+func (dfs *dockerFS) Create(path string) error {
+	return filesystem.NewPermissionDeniedError("create", path, "files are managed by dockerfs")
+}
+
+func (dfs *dockerFS) Mkdir(path string, perm uint32) error {
+	return filesystem.NewPermissionDeniedError("mkdir", path, "containers are created with the docker CLI")
+}
+
+func (dfs *dockerFS) Remove(path string) error {
+	return filesystem.NewPermissionDeniedError("remove", path, "containers are removed with the docker CLI")
+}
+
+func (dfs *dockerFS) RemoveAll(path string) error {
+	return dfs.Remove(path)
+}
+
+func (dfs *dockerFS) Read(path string, offset int64, size int64) ([]byte, error) {
+	if path == "/README" {
+		return plugin.ApplyRangeRead([]byte(dfs.plugin.GetReadme()), offset, size)
+	}
+
+	dp, err := parseDockerPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if dp.file == "" {
+		return nil, fmt.Errorf("is a directory: %s", path)
+	}
+
+	var data []byte
+	if dp.kind == "images" {
+		data, err = dfs.readImageFile(dp, path)
+	} else {
+		data, err = dfs.readContainerFile(dp, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return plugin.ApplyRangeRead(data, offset, size)
+}
+
+func (dfs *dockerFS) readContainerFile(dp dockerPath, path string) ([]byte, error) {
+	raw, details, err := dfs.inspectContainer("read", path, dp.name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch dp.file {
+	case fileInspect:
+		return prettyJSON(raw), nil
+	case fileStatus:
+		return []byte(details.State.Status + "\n"), nil
+	case fileLogs:
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+		logs, err := dfs.plugin.client.ContainerLogs(ctx, details.ID, details.Config.Tty, dfs.plugin.logTail, false)
+		if err != nil {
+			return nil, mapError(err, "read", path)
+		}
+		defer logs.Close()
+		return io.ReadAll(logs)
+	case fileExec:
+		dfs.plugin.mu.Lock()
+		rec, ok := dfs.plugin.execResults[details.ID]
+		dfs.plugin.mu.Unlock()
+		if !ok {
+			return []byte{}, nil
+		}
+		data, _ := json.MarshalIndent(rec, "", "  ")
+		return append(data, '\n'), nil
+	case fileControl:
+		return []byte(strings.Join(containerActions, " ") + "\n"), nil
+	}
+	return nil, filesystem.NewNotFoundError("read", path)
+}
+
+func (dfs *dockerFS) readImageFile(dp dockerPath, path string) ([]byte, error) {
+	raw, err := dfs.inspectImage("read", path, dp.name)
+	if err != nil {
+		return nil, err
+	}
+	if dp.file == fileTags {
+		var image struct {
+			RepoTags []string `json:"RepoTags"`
+		}
+		if err := json.Unmarshal(raw, &image); err != nil {
+			return nil, fmt.Errorf("failed to decode image %s: %w", dp.name, err)
+		}
+		if len(image.RepoTags) == 0 {
+			return []byte{}, nil
+		}
+		return []byte(strings.Join(image.RepoTags, "\n") + "\n"), nil
+	}
+	return prettyJSON(raw), nil
+}
+
+// prettyJSON indents an API response for reading
+func prettyJSON(raw []byte) []byte {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, "", "  "); err != nil {
+		return raw
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+func (dfs *dockerFS) Write(path string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	dp, err := parseDockerPath(path)
+	if err != nil {
+		return 0, err
+	}
+	if dp.kind != "containers" || (dp.file != fileExec && dp.file != fileControl) {
+		return 0, filesystem.NewPermissionDeniedError("write", path, "read-only file")
+	}
+
+	input := strings.TrimSpace(string(data))
+	if dp.file == fileExec {
+		if !dfs.plugin.allowExec {
+			return 0, filesystem.NewPermissionDeniedError("write", path, "exec is disabled (set allow_exec = true)")
+		}
+		if input == "" {
+			return 0, filesystem.NewInvalidArgumentError("command", input, "must not be empty")
+		}
+		_, details, err := dfs.inspectContainer("write", path, dp.name)
+		if err != nil {
+			return 0, err
+		}
+		dfs.runExec(details.ID, input)
+		return int64(len(data)), nil
+	}
+
+	if !dfs.plugin.allowControl {
+		return 0, filesystem.NewPermissionDeniedError("write", path, "control is disabled (set allow_control = true)")
+	}
+	if !contains(containerActions, input) {
+		return 0, filesystem.NewInvalidArgumentError("action", input, "expected one of: "+strings.Join(containerActions, ", "))
+	}
+	if err := dfs.plugin.client.ContainerAction(dp.name, input); err != nil {
+		return 0, mapError(err, "write", path)
+	}
+	log.Infof("[dockerfs] %s container %s", input, dp.name)
+	return int64(len(data)), nil
+}
+
+// runExec runs a command in a container and records the result
+// Failures to run the command are recorded rather than returned, so the
+// caller can read them back from the exec file like any other result.
+func (dfs *dockerFS) runExec(containerID, command string) {
+	ctx, cancel := context.WithTimeout(context.Background(), dfs.plugin.execTimeout)
+	defer cancel()
+
+	rec := ExecRecord{Command: command, StartedAt: time.Now(), ExitCode: -1}
+	output, exitCode, err := dfs.plugin.client.Exec(ctx, containerID, []string{dfs.plugin.shell, "-c", command})
+	rec.FinishedAt = time.Now()
+	rec.Output = string(output)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("command timed out after %s", dfs.plugin.execTimeout)
+		}
+		rec.Error = err.Error()
+	} else {
+		rec.ExitCode = exitCode
+	}
+
+	dfs.plugin.mu.Lock()
+	dfs.plugin.execResults[containerID] = rec
+	dfs.plugin.mu.Unlock()
+}
+
+func dirInfo(name, metaType string, modTime time.Time, content map[string]string) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    name,
+		Size:    0,
+		Mode:    0755,
+		ModTime: modTime,
+		IsDir:   true,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: metaType, Content: content},
+	}
+}
+
+// fileInfo builds the FileInfo of a container or image file
+// Sizes are 0 because content is generated on every read.
+func (dfs *dockerFS) fileInfo(name string, modTime time.Time) filesystem.FileInfo {
+	mode := uint32(0444)
+	metaType := MetaValueStatus
+	switch name {
+	case fileInspect:
+		metaType = MetaValueInspect
+	case fileLogs:
+		metaType = MetaValueLogs
+	case fileExec:
+		metaType = MetaValueExec
+		if dfs.plugin.allowExec {
+			mode = 0644
+		}
+	case fileControl:
+		metaType = MetaValueControl
+		if dfs.plugin.allowControl {
+			mode = 0644
+		}
+	}
+	return filesystem.FileInfo{
+		Name:    name,
+		Size:    0,
+		Mode:    mode,
+		ModTime: modTime,
+		IsDir:   false,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: metaType},
+	}
+}
+
+func (dfs *dockerFS) ReadDir(path string) ([]filesystem.FileInfo, error) {
+	dp, err := parseDockerPath(path)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	switch {
+	case dp.kind == "":
+		readme := dfs.plugin.GetReadme()
+		return []filesystem.FileInfo{
+			{
+				Name:    "README",
+				Size:    int64(len(readme)),
+				Mode:    0444,
+				ModTime: now,
+				IsDir:   false,
+				Meta:    filesystem.MetaData{Name: PluginName, Type: "doc"},
+			},
+			dirInfo("containers", "dir", now, nil),
+			dirInfo("images", "dir", now, nil),
+		}, nil
+
+	case dp.kind == "containers" && dp.name == "":
+		containers, err := dfs.plugin.client.ListContainers(dfs.plugin.allContainers)
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(containers, func(i, j int) bool { return containers[i].Name() < containers[j].Name() })
+		files := make([]filesystem.FileInfo, 0, len(containers))
+		for _, c := range containers {
+			files = append(files, dirInfo(c.Name(), MetaValueContainer, time.Unix(c.Created, 0), map[string]string{
+				"id":    shortID(c.ID),
+				"image": c.Image,
+				"state": c.State,
+			}))
+		}
+		return files, nil
+
+	case dp.kind == "images" && dp.name == "":
+		images, err := dfs.plugin.client.ListImages()
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(images, func(i, j int) bool { return images[i].Created > images[j].Created })
+		files := make([]filesystem.FileInfo, 0, len(images))
+		for _, img := range images {
+			files = append(files, dirInfo(shortID(img.ID), MetaValueImage, time.Unix(img.Created, 0), map[string]string{
+				"tags": strings.Join(img.RepoTags, ","),
+				"size": fmt.Sprintf("%d", img.Size),
+			}))
+		}
+		return files, nil
+
+	case dp.file != "":
+		return nil, filesystem.NewNotDirectoryError(path)
+	}
+
+	info, err := dfs.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	names := containerFiles
+	if dp.kind == "images" {
+		names = imageFiles
+	}
+	files := make([]filesystem.FileInfo, 0, len(names))
+	for _, name := range names {
+		files = append(files, dfs.fileInfo(name, info.ModTime))
+	}
+	return files, nil
+}
+
+func (dfs *dockerFS) Stat(path string) (*filesystem.FileInfo, error) {
+	now := time.Now()
+	trimmed := strings.Trim(path, "/")
+
+	if trimmed == "" {
+		info := dirInfo("/", "dir", now, nil)
+		return &info, nil
+	}
+	if trimmed == "README" {
+		readme := dfs.plugin.GetReadme()
+		return &filesystem.FileInfo{
+			Name:    "README",
+			Size:    int64(len(readme)),
+			Mode:    0444,
+			ModTime: now,
+			IsDir:   false,
+			Meta:    filesystem.MetaData{Name: PluginName, Type: "doc"},
+		}, nil
+	}
+
+	dp, err := parseDockerPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if dp.name == "" {
+		info := dirInfo(dp.kind, "dir", now, nil)
+		return &info, nil
+	}
+
+	var info filesystem.FileInfo
+	if dp.kind == "images" {
+		raw, err := dfs.inspectImage("stat", path, dp.name)
+		if err != nil {
+			return nil, err
+		}
+		var image struct {
+			Created string `json:"Created"`
+		}
+		json.Unmarshal(raw, &image)
+		info = dirInfo(dp.name, MetaValueImage, parseDockerTime(image.Created), nil)
+	} else {
+		_, details, err := dfs.inspectContainer("stat", path, dp.name)
+		if err != nil {
+			return nil, err
+		}
+		info = dirInfo(dp.name, MetaValueContainer, parseDockerTime(details.Created), map[string]string{
+			"id":    shortID(details.ID),
+			"state": details.State.Status,
+		})
+	}
+
+	if dp.file != "" {
+		info = dfs.fileInfo(dp.file, info.ModTime)
+	}
+	return &info, nil
+}
+
+func (dfs *dockerFS) Rename(oldPath, newPath string) error {
+	return filesystem.NewNotSupportedError("rename", oldPath)
+}
+
+func (dfs *dockerFS) Chmod(path string, mode uint32) error {
+	return filesystem.NewNotSupportedError("chmod", path)
+}
+
+// Truncate is a no-op so shell redirections like `echo restart > control` work
+func (dfs *dockerFS) Truncate(path string, size int64) error {
+	return nil
+}
+
+func (dfs *dockerFS) Open(path string) (io.ReadCloser, error) {
+	data, err := dfs.Read(path, 0, -1)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (dfs *dockerFS) OpenWrite(path string) (io.WriteCloser, error) {
+	return &dockerWriter{dfs: dfs, path: path, buf: &bytes.Buffer{}}, nil
+}
+
+// dockerWriter buffers a streamed write and applies it on Close
+type dockerWriter struct {
+	dfs  *dockerFS
+	path string
+	buf  *bytes.Buffer
+}
+
+func (dw *dockerWriter) Write(p []byte) (n int, err error) {
+	return dw.buf.Write(p)
+}
+
+func (dw *dockerWriter) Close() error {
+	_, err := dw.dfs.Write(dw.path, dw.buf.Bytes(), 0, filesystem.WriteFlagTruncate)
+	return err
+}
+
+// OpenStream follows the logs of a container, starting with the last log_tail lines
+func (dfs *dockerFS) OpenStream(path string) (filesystem.StreamReader, error) {
+	dp, err := parseDockerPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if dp.kind != "containers" || dp.file != fileLogs {
+		return nil, fmt.Errorf("streaming is only supported for container logs: %s", path)
+	}
+	_, details, err := dfs.inspectContainer("openstream", path, dp.name)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	logs, err := dfs.plugin.client.ContainerLogs(ctx, details.ID, details.Config.Tty, dfs.plugin.logTail, true)
+	if err != nil {
+		cancel()
+		return nil, mapError(err, "openstream", path)
+	}
+	return newLogStream(ctx, cancel, logs), nil
+}
+
+// logStream adapts a followed log response to filesystem.StreamReader
+type logStream struct {
+	ch     chan []byte
+	ctx    context.Context
+	cancel context.CancelFunc
+	body   io.ReadCloser
+}
+
+func newLogStream(ctx context.Context, cancel context.CancelFunc, body io.ReadCloser) *logStream {
+	ls := &logStream{ch: make(chan []byte, 16), ctx: ctx, cancel: cancel, body: body}
+	go ls.pump()
+	return ls
+}
+
+// pump copies log chunks to the channel until the stream ends or is closed
+func (ls *logStream) pump() {
+	defer close(ls.ch)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := ls.body.Read(buf)
+		if n > 0 {
+			chunk := make([]byte, n)
+			copy(chunk, buf[:n])
+			select {
+			case ls.ch <- chunk:
+			case <-ls.ctx.Done():
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// ReadChunk implements filesystem.StreamReader
+func (ls *logStream) ReadChunk(timeout time.Duration) ([]byte, bool, error) {
+	select {
+	case data, ok := <-ls.ch:
+		if !ok {
+			return nil, true, io.EOF
+		}
+		return data, false, nil
+	case <-time.After(timeout):
+		return nil, false, fmt.Errorf("read timeout")
+	}
+}
+
+// Close implements filesystem.StreamReader
+func (ls *logStream) Close() error {
+	ls.cancel()
+	return ls.body.Close()
+}
+
+// Ensure DockerFSPlugin implements ServicePlugin
+var _ plugin.ServicePlugin = (*DockerFSPlugin)(nil)
+var _ filesystem.FileSystem = (*dockerFS)(nil)
+var _ filesystem.Streamer = (*dockerFS)(nil)
@@ -0,0 +1,238 @@
+package dockerfs
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+const webID = "0123456789abcdef0123456789abcdef"
+
+// frame encodes data in Docker's multiplexed stream format
+func frame(stream byte, data string) []byte {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(data)))
+	return append(header, data...)
+}
+
+// fakeDocker is a minimal Docker Engine API server
+type fakeDocker struct {
+	*httptest.Server
+	mu       sync.Mutex
+	actions  []string
+	execCmds [][]string
+}
+
+func startFakeDocker(t *testing.T) *fakeDocker {
+	t.Helper()
+	fd := &fakeDocker{}
+	inspect := `{"Id":"` + webID + `","Name":"/web","Created":"2024-05-01T10:00:00Z","State":{"Status":"running"},"Config":{"Tty":false}}`
+
+	fd.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/v1.43")
+		switch {
+		case path == "/containers/json":
+			if r.URL.Query().Get("all") == "1" {
+				w.Write([]byte(`[{"Id":"` + webID + `","Names":["/web"],"Image":"nginx","State":"running","Created":1714557600},{"Id":"fedcba","Names":["/old"],"Image":"busybox","State":"exited","Created":1714557000}]`))
+				return
+			}
+			w.Write([]byte(`[{"Id":"` + webID + `","Names":["/web"],"Image":"nginx","State":"running","Created":1714557600}]`))
+		case path == "/containers/web/json" || path == "/containers/"+webID+"/json":
+			w.Write([]byte(inspect))
+		case path == "/containers/"+webID+"/logs":
+			w.Write(frame(1, "hello stdout\n"))
+			w.Write(frame(2, "oops stderr\n"))
+			if r.URL.Query().Get("follow") == "1" {
+				w.(http.Flusher).Flush()
+				w.Write(frame(1, "later\n"))
+				w.(http.Flusher).Flush()
+				<-r.Context().Done()
+			}
+		case path == "/containers/"+webID+"/exec" && r.Method == http.MethodPost:
+			var body struct{ Cmd []string }
+			json.NewDecoder(r.Body).Decode(&body)
+			fd.mu.Lock()
+			fd.execCmds = append(fd.execCmds, body.Cmd)
+			fd.mu.Unlock()
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"exec1"}`))
+		case path == "/exec/exec1/start":
+			w.Write(frame(1, "total 0\n"))
+		case path == "/exec/exec1/json":
+			w.Write([]byte(`{"ExitCode":3}`))
+		case strings.HasPrefix(path, "/containers/web/") && r.Method == http.MethodPost:
+			fd.mu.Lock()
+			fd.actions = append(fd.actions, strings.TrimPrefix(path, "/containers/web/"))
+			fd.mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		case path == "/images/json":
+			w.Write([]byte(`[{"Id":"sha256:abcdef0123456789","RepoTags":["nginx:latest","nginx:1.25"],"Size":1000,"Created":1714557600}]`))
+		case path == "/images/abcdef012345/json":
+			w.Write([]byte(`{"Id":"sha256:abcdef0123456789","RepoTags":["nginx:latest","nginx:1.25"],"Created":"2024-05-01T10:00:00Z"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"No such object"}`))
+		}
+	}))
+	t.Cleanup(fd.Close)
+	return fd
+}
+
+func newTestFS(t *testing.T, cfg map[string]interface{}) *dockerFS {
+	t.Helper()
+	p := NewDockerFSPlugin()
+	if err := p.Validate(cfg); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if err := p.Initialize(cfg); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	t.Cleanup(func() { p.Shutdown() })
+	return p.GetFileSystem().(*dockerFS)
+}
+
+// readIgnoreEOF reads file content, ignoring io.EOF which is expected at end of file
+func readIgnoreEOF(fs *dockerFS, path string) ([]byte, error) {
+	content, err := fs.Read(path, 0, -1)
+	if err == io.EOF {
+		return content, nil
+	}
+	return content, err
+}
+
+func TestDockerFSContainers(t *testing.T) {
+	fd := startFakeDocker(t)
+	fs := newTestFS(t, map[string]interface{}{"host": fd.URL, "api_version": "v1.43"})
+
+	infos, err := fs.ReadDir("/containers")
+	if err != nil || len(infos) != 1 || infos[0].Name != "web" || infos[0].Meta.Content["id"] != webID[:12] {
+		t.Fatalf("ReadDir /containers = %+v, %v", infos, err)
+	}
+	infos, err = fs.ReadDir("/containers/web")
+	if err != nil || len(infos) != len(containerFiles) {
+		t.Fatalf("ReadDir /containers/web = %+v, %v", infos, err)
+	}
+
+	data, err := readIgnoreEOF(fs, "/containers/web/status")
+	if err != nil || string(data) != "running\n" {
+		t.Errorf("status = %q, %v", data, err)
+	}
+	data, err = readIgnoreEOF(fs, "/containers/web/inspect")
+	if err != nil || !strings.Contains(string(data), `"Status": "running"`) {
+		t.Errorf("inspect = %q, %v", data, err)
+	}
+	data, err = readIgnoreEOF(fs, "/containers/web/logs")
+	if err != nil || string(data) != "hello stdout\noops stderr\n" {
+		t.Errorf("logs = %q, %v", data, err)
+	}
+
+	if _, err := fs.Stat("/containers/missing"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("missing container: expected ErrNotFound, got %v", err)
+	}
+	if _, err := fs.Write("/containers/web/status", []byte("x"), 0, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrPermissionDenied) {
+		t.Errorf("write status: expected ErrPermissionDenied, got %v", err)
+	}
+
+	all := newTestFS(t, map[string]interface{}{"host": fd.URL, "all_containers": true})
+	if infos, _ := all.ReadDir("/containers"); len(infos) != 2 || infos[0].Name != "old" {
+		t.Errorf("all_containers listing = %+v", infos)
+	}
+}
+
+func TestDockerFSExecAndControl(t *testing.T) {
+	fd := startFakeDocker(t)
+
+	locked := newTestFS(t, map[string]interface{}{"host": fd.URL})
+	if _, err := locked.Write("/containers/web/exec", []byte("ls"), 0, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrPermissionDenied) {
+		t.Errorf("exec disabled: expected ErrPermissionDenied, got %v", err)
+	}
+	if _, err := locked.Write("/containers/web/control", []byte("stop"), 0, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrPermissionDenied) {
+		t.Errorf("control disabled: expected ErrPermissionDenied, got %v", err)
+	}
+
+	fs := newTestFS(t, map[string]interface{}{"host": fd.URL, "allow_exec": true, "allow_control": true})
+	if _, err := fs.Write("/containers/web/exec", []byte("ls /data\n"), 0, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("exec failed: %v", err)
+	}
+	data, err := readIgnoreEOF(fs, "/containers/web/exec")
+	if err != nil {
+		t.Fatalf("read exec: %v", err)
+	}
+	var rec ExecRecord
+	if err := json.Unmarshal(data, &rec); err != nil || rec.Command != "ls /data" || rec.ExitCode != 3 || rec.Output != "total 0\n" {
+		t.Errorf("exec record = %+v, %v", rec, err)
+	}
+	if len(fd.execCmds) != 1 || strings.Join(fd.execCmds[0], " ") != "/bin/sh -c ls /data" {
+		t.Errorf("exec commands = %v", fd.execCmds)
+	}
+
+	if _, err := fs.Write("/containers/web/control", []byte("restart\n"), 0, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("control failed: %v", err)
+	}
+	if _, err := fs.Write("/containers/web/control", []byte("explode"), 0, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrInvalidArgument) {
+		t.Errorf("bad action: expected ErrInvalidArgument, got %v", err)
+	}
+	if len(fd.actions) != 1 || fd.actions[0] != "restart" {
+		t.Errorf("actions = %v", fd.actions)
+	}
+}
+
+func TestDockerFSFollowLogs(t *testing.T) {
+	fd := startFakeDocker(t)
+	fs := newTestFS(t, map[string]interface{}{"host": fd.URL})
+
+	stream, err := fs.OpenStream("/containers/web/logs")
+	if err != nil {
+		t.Fatalf("OpenStream failed: %v", err)
+	}
+	defer stream.Close()
+
+	var got strings.Builder
+	for !strings.Contains(got.String(), "later\n") {
+		chunk, eof, err := stream.ReadChunk(2 * time.Second)
+		if err != nil || eof {
+			t.Fatalf("ReadChunk: %v (eof=%v), got so far %q", err, eof, got.String())
+		}
+		got.Write(chunk)
+	}
+	if got.String() != "hello stdout\noops stderr\nlater\n" {
+		t.Errorf("followed logs = %q", got.String())
+	}
+}
+
+func TestDockerFSImages(t *testing.T) {
+	fd := startFakeDocker(t)
+	fs := newTestFS(t, map[string]interface{}{"host": fd.URL})
+
+	infos, err := fs.ReadDir("/images")
+	if err != nil || len(infos) != 1 || infos[0].Name != "abcdef012345" {
+		t.Fatalf("ReadDir /images = %+v, %v", infos, err)
+	}
+	data, err := readIgnoreEOF(fs, "/images/abcdef012345/tags")
+	if err != nil || string(data) != "nginx:latest\nnginx:1.25\n" {
+		t.Errorf("tags = %q, %v", data, err)
+	}
+}
+
+func TestDockerFSValidate(t *testing.T) {
+	p := NewDockerFSPlugin()
+	if err := p.Validate(map[string]interface{}{"exec_timeout": "never"}); err == nil {
+		t.Error("expected error for invalid exec_timeout")
+	}
+	if err := p.Validate(map[string]interface{}{"log_tail": -1}); err == nil {
+		t.Error("expected error for negative log_tail")
+	}
+	if err := p.Initialize(map[string]interface{}{"host": "ftp://docker"}); err == nil {
+		t.Error("expected error for unsupported host scheme")
+	}
+}
@@ -0,0 +1,330 @@
+package gcsfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+const (
+	// PrefixIsolationDelimiter is used to wrap prefixes in strict isolation mode
+	// It matches s3fs so that nested prefixes like "team1" and "team1/test" are completely isolated
+	PrefixIsolationDelimiter = "__PREFIX__"
+
+	// DefaultChunkSize is the resumable upload chunk size
+	DefaultChunkSize = 16 * 1024 * 1024
+)
+
+// GCSClient wraps a Google Cloud Storage bucket handle with helper methods
+type GCSClient struct {
+	client    *storage.Client
+	bucket    *storage.BucketHandle
+	name      string // Bucket name
+	prefix    string // Effective prefix with isolation wrapping applied
+	rawPrefix string // Original user-specified prefix (for display purposes)
+	chunkSize int    // Resumable upload chunk size in bytes
+}
+
+// GCSConfig holds GCS client configuration
+type GCSConfig struct {
+	Bucket          string
+	Prefix          string // Optional prefix for all keys (will be wrapped for isolation)
+	CredentialsFile string // Optional service account key file (uses Application Default Credentials if empty)
+	CredentialsJSON string // Optional service account key JSON
+	Endpoint        string // Optional custom endpoint (for emulators such as fake-gcs-server)
+	ChunkSize       int    // Resumable upload chunk size in bytes (0 uploads in a single request)
+}
+
+// NewGCSClient creates a new GCS client
+func NewGCSClient(cfg GCSConfig) (*GCSClient, error) {
+	ctx := context.Background()
+
+	var opts []option.ClientOption
+	switch {
+	case cfg.CredentialsJSON != "":
+		opts = append(opts, option.WithAuthCredentialsJSON(option.ServiceAccount, []byte(cfg.CredentialsJSON)))
+	case cfg.CredentialsFile != "":
+		opts = append(opts, option.WithAuthCredentialsFile(option.ServiceAccount, cfg.CredentialsFile))
+	case cfg.Endpoint != "":
+		// Emulators do not authenticate requests
+		opts = append(opts, option.WithoutAuthentication())
+	}
+	if cfg.Endpoint != "" {
+		opts = append(opts, option.WithEndpoint(cfg.Endpoint))
+	}
+
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %w", err)
+	}
+
+	// Verify bucket access by listing a single object; this needs only
+	// storage.objects.list, unlike bucket.Attrs which needs storage.buckets.get
+	bucket := client.Bucket(cfg.Bucket)
+	if _, err := bucket.Objects(ctx, &storage.Query{}).Next(); err != nil && err != iterator.Done {
+		client.Close()
+		return nil, fmt.Errorf("failed to access bucket %s: %w", cfg.Bucket, err)
+	}
+
+	log.Infof("[gcsfs] Connected to GCS bucket: %s", cfg.Bucket)
+
+	// Normalize prefix: remove leading and trailing slashes
+	rawPrefix := strings.Trim(cfg.Prefix, "/")
+	prefix := rawPrefix
+
+	// Always apply strict prefix isolation, see s3fs
+	if rawPrefix != "" {
+		prefix = PrefixIsolationDelimiter + rawPrefix + "__"
+		log.Infof("[gcsfs] Prefix isolation applied. User prefix: %s, Effective prefix: %s", rawPrefix, prefix)
+	}
+
+	return &GCSClient{
+		client:    client,
+		bucket:    bucket,
+		name:      cfg.Bucket,
+		prefix:    prefix,
+		rawPrefix: rawPrefix,
+		chunkSize: cfg.ChunkSize,
+	}, nil
+}
+
+// Close releases the underlying client
+func (c *GCSClient) Close() error {
+	return c.client.Close()
+}
+
+// buildKey builds the full object name with prefix
+func (c *GCSClient) buildKey(path string) string {
+	path = strings.TrimPrefix(path, "/")
+
+	if c.prefix == "" {
+		return path
+	}
+
+	if path == "" {
+		return c.prefix
+	}
+
+	return c.prefix + "/" + path
+}
+
+// dirPrefix returns the listing prefix for a directory path
+func (c *GCSClient) dirPrefix(path string) string {
+	prefix := c.buildKey(path)
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix
+}
+
+// isNotFound reports whether err means the object does not exist
+func isNotFound(err error) bool {
+	return errors.Is(err, storage.ErrObjectNotExist)
+}
+
+// GetObject retrieves an object
+func (c *GCSClient) GetObject(ctx context.Context, path string) ([]byte, error) {
+	return c.GetObjectRange(ctx, path, 0, -1)
+}
+
+// GetObjectStream returns a reader over an object
+// The caller is responsible for closing the returned ReadCloser
+func (c *GCSClient) GetObjectStream(ctx context.Context, path string) (io.ReadCloser, error) {
+	key := c.buildKey(path)
+
+	r, err := c.bucket.Object(key).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object %s: %w", key, err)
+	}
+	return r, nil
+}
+
+// GetObjectRange retrieves a byte range from an object
+// offset: starting byte position (0-based)
+// size: number of bytes to read (-1 for all remaining bytes from offset)
+func (c *GCSClient) GetObjectRange(ctx context.Context, path string, offset, size int64) ([]byte, error) {
+	key := c.buildKey(path)
+
+	r, err := c.bucket.Object(key).NewRangeReader(ctx, offset, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object range %s: %w", key, err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object body: %w", err)
+	}
+	return data, nil
+}
+
+// NewObjectWriter returns a writer that uploads an object
+// Data is sent as a resumable upload in chunkSize pieces, so large objects
+// never need to be held in memory. The object is committed on Close.
+func (c *GCSClient) NewObjectWriter(ctx context.Context, path string) *storage.Writer {
+	w := c.bucket.Object(c.buildKey(path)).NewWriter(ctx)
+	w.ChunkSize = c.chunkSize
+	return w
+}
+
+// PutObject uploads an object
+func (c *GCSClient) PutObject(ctx context.Context, path string, data []byte) error {
+	w := c.NewObjectWriter(ctx, path)
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return fmt.Errorf("failed to put object %s: %w", c.buildKey(path), err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to put object %s: %w", c.buildKey(path), err)
+	}
+	return nil
+}
+
+// CopyObject copies an object server-side
+func (c *GCSClient) CopyObject(ctx context.Context, srcPath, dstPath string) error {
+	src := c.bucket.Object(c.buildKey(srcPath))
+	dst := c.bucket.Object(c.buildKey(dstPath))
+
+	if _, err := dst.CopierFrom(src).Run(ctx); err != nil {
+		return fmt.Errorf("failed to copy object %s: %w", src.ObjectName(), err)
+	}
+	return nil
+}
+
+// DeleteObject deletes an object
+func (c *GCSClient) DeleteObject(ctx context.Context, path string) error {
+	key := c.buildKey(path)
+
+	if err := c.bucket.Object(key).Delete(ctx); err != nil {
+		return fmt.Errorf("failed to delete object %s: %w", key, err)
+	}
+	return nil
+}
+
+// HeadObject returns an object's metadata
+func (c *GCSClient) HeadObject(ctx context.Context, path string) (*storage.ObjectAttrs, error) {
+	return c.bucket.Object(c.buildKey(path)).Attrs(ctx)
+}
+
+// GCSObject represents a GCS object with metadata
+type GCSObject struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+	IsDir        bool
+}
+
+// ListObjects lists the immediate children of a directory
+func (c *GCSClient) ListObjects(ctx context.Context, path string) ([]GCSObject, error) {
+	prefix := c.dirPrefix(path)
+
+	var objects []GCSObject
+	it := c.bucket.Objects(ctx, &storage.Query{Prefix: prefix, Delimiter: "/"})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+
+		// Synthetic directory entries only carry a prefix
+		if attrs.Prefix != "" {
+			objects = append(objects, GCSObject{
+				Key:          strings.TrimSuffix(strings.TrimPrefix(attrs.Prefix, prefix), "/"),
+				LastModified: time.Now(),
+				IsDir:        true,
+			})
+			continue
+		}
+
+		relPath := strings.TrimPrefix(attrs.Name, prefix)
+		// Skip the directory marker itself
+		if relPath == "" || strings.HasSuffix(relPath, "/") {
+			continue
+		}
+
+		objects = append(objects, GCSObject{
+			Key:          relPath,
+			Size:         attrs.Size,
+			LastModified: attrs.Updated,
+		})
+	}
+
+	return objects, nil
+}
+
+// CreateDirectory creates a directory marker
+// GCS doesn't have real directories, but we create empty objects ending with "/"
+func (c *GCSClient) CreateDirectory(ctx context.Context, path string) error {
+	key := c.dirPrefix(path)
+	w := c.bucket.Object(key).NewWriter(ctx)
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", key, err)
+	}
+	return nil
+}
+
+// DeleteDirectory deletes all objects under a prefix
+func (c *GCSClient) DeleteDirectory(ctx context.Context, path string) error {
+	it := c.bucket.Objects(ctx, &storage.Query{Prefix: c.dirPrefix(path)})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list objects for deletion: %w", err)
+		}
+		if err := c.bucket.Object(attrs.Name).Delete(ctx); err != nil && !isNotFound(err) {
+			return fmt.Errorf("failed to delete object %s: %w", attrs.Name, err)
+		}
+	}
+}
+
+// ObjectExists checks if an object exists
+func (c *GCSClient) ObjectExists(ctx context.Context, path string) (bool, error) {
+	_, err := c.HeadObject(ctx, path)
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// DirectoryExists checks if a directory exists (has a marker or objects with the prefix)
+func (c *GCSClient) DirectoryExists(ctx context.Context, path string) (bool, error) {
+	it := c.bucket.Objects(ctx, &storage.Query{Prefix: c.dirPrefix(path), Delimiter: "/"})
+	_, err := it.Next()
+	if err == iterator.Done {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// getParentPath returns the parent directory path
+func getParentPath(path string) string {
+	if path == "" || path == "/" {
+		return ""
+	}
+	parent := filepath.Dir(path)
+	if parent == "." {
+		return ""
+	}
+	return parent
+}
@@ -0,0 +1,693 @@
+package gcsfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PluginName = "gcsfs"
+)
+
+// GCSFS implements FileSystem interface using Google Cloud Storage as backend
+type GCSFS struct {
+	client *GCSClient
+	mu     sync.RWMutex
+}
+
+// NewGCSFS creates a new GCS-backed file system
+func NewGCSFS(cfg GCSConfig) (*GCSFS, error) {
+	client, err := NewGCSClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &GCSFS{client: client}, nil
+}
+
+// mapError converts GCS not-found errors to filesystem.ErrNotFound
+func mapError(err error) error {
+	if isNotFound(err) {
+		return filesystem.ErrNotFound
+	}
+	return err
+}
+
+func (fs *GCSFS) meta() filesystem.MetaData {
+	return filesystem.MetaData{
+		Name: PluginName,
+		Type: "gcs",
+		Content: map[string]string{
+			"bucket": fs.client.name,
+			"prefix": fs.client.rawPrefix,
+		},
+	}
+}
+
+// checkParent returns an error unless the parent directory of path exists
+func (fs *GCSFS) checkParent(ctx context.Context, path string) error {
+	parent := getParentPath(path)
+	if parent == "" {
+		return nil
+	}
+	exists, err := fs.client.DirectoryExists(ctx, parent)
+	if err != nil {
+		return fmt.Errorf("failed to check parent directory: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("parent directory does not exist: %s", parent)
+	}
+	return nil
+}
+
+func (fs *GCSFS) Create(path string) error {
+	path = filesystem.NormalizeS3Key(path)
+	ctx := context.Background()
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	exists, err := fs.client.ObjectExists(ctx, path)
+	if err != nil {
+		return fmt.Errorf("failed to check if file exists: %w", err)
+	}
+	if exists {
+		return fmt.Errorf("file already exists: %s", path)
+	}
+	if err := fs.checkParent(ctx, path); err != nil {
+		return err
+	}
+
+	return fs.client.PutObject(ctx, path, []byte{})
+}
+
+func (fs *GCSFS) Mkdir(path string, perm uint32) error {
+	path = filesystem.NormalizeS3Key(path)
+	ctx := context.Background()
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	exists, err := fs.client.DirectoryExists(ctx, path)
+	if err != nil {
+		return fmt.Errorf("failed to check if directory exists: %w", err)
+	}
+	if exists {
+		return fmt.Errorf("directory already exists: %s", path)
+	}
+	if err := fs.checkParent(ctx, path); err != nil {
+		return err
+	}
+
+	return fs.client.CreateDirectory(ctx, path)
+}
+
+func (fs *GCSFS) Remove(path string) error {
+	path = filesystem.NormalizeS3Key(path)
+	ctx := context.Background()
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	// Check if it's a file
+	exists, err := fs.client.ObjectExists(ctx, path)
+	if err != nil {
+		return fmt.Errorf("failed to check if file exists: %w", err)
+	}
+	if exists {
+		return fs.client.DeleteObject(ctx, path)
+	}
+
+	// Check if it's a directory
+	dirExists, err := fs.client.DirectoryExists(ctx, path)
+	if err != nil {
+		return fmt.Errorf("failed to check if directory exists: %w", err)
+	}
+	if !dirExists {
+		return filesystem.ErrNotFound
+	}
+
+	objects, err := fs.client.ListObjects(ctx, path)
+	if err != nil {
+		return fmt.Errorf("failed to list directory: %w", err)
+	}
+	if len(objects) > 0 {
+		return fmt.Errorf("directory not empty: %s", path)
+	}
+
+	// Delete directory marker
+	if err := fs.client.DeleteObject(ctx, path+"/"); err != nil && !isNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (fs *GCSFS) RemoveAll(path string) error {
+	path = filesystem.NormalizeS3Key(path)
+	ctx := context.Background()
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	// A single object is removed directly
+	if path != "" {
+		if exists, err := fs.client.ObjectExists(ctx, path); err == nil && exists {
+			return fs.client.DeleteObject(ctx, path)
+		}
+	}
+	return fs.client.DeleteDirectory(ctx, path)
+}
+
+func (fs *GCSFS) Read(path string, offset int64, size int64) ([]byte, error) {
+	path = filesystem.NormalizeS3Key(path)
+	ctx := context.Background()
+
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if size == 0 {
+		return []byte{}, nil
+	}
+	if size < 0 {
+		size = -1
+	}
+
+	// Ranged reads only fetch the requested bytes
+	data, err := fs.client.GetObjectRange(ctx, path, offset, size)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return data, nil
+}
+
+func (fs *GCSFS) Write(path string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	path = filesystem.NormalizeS3Key(path)
+	ctx := context.Background()
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	// GCS objects are immutable - only full object replacement is supported
+	if offset > 0 {
+		return 0, fmt.Errorf("GCS does not support offset writes")
+	}
+	if path == "" || strings.HasSuffix(path, "/") {
+		return 0, fmt.Errorf("is a directory: %s", path)
+	}
+
+	if err := fs.client.PutObject(ctx, path, data); err != nil {
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
+
+func (fs *GCSFS) ReadDir(path string) ([]filesystem.FileInfo, error) {
+	path = filesystem.NormalizeS3Key(path)
+	ctx := context.Background()
+
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if path != "" {
+		exists, err := fs.client.DirectoryExists(ctx, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check directory: %w", err)
+		}
+		if !exists {
+			return nil, filesystem.ErrNotFound
+		}
+	}
+
+	objects, err := fs.client.ListObjects(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]filesystem.FileInfo, 0, len(objects))
+	for _, obj := range objects {
+		mode := uint32(0644)
+		if obj.IsDir {
+			mode = 0755
+		}
+		files = append(files, filesystem.FileInfo{
+			Name:    obj.Key,
+			Size:    obj.Size,
+			Mode:    mode,
+			ModTime: obj.LastModified,
+			IsDir:   obj.IsDir,
+			Meta: filesystem.MetaData{
+				Name: PluginName,
+				Type: "gcs",
+			},
+		})
+	}
+	return files, nil
+}
+
+func (fs *GCSFS) Stat(path string) (*filesystem.FileInfo, error) {
+	path = filesystem.NormalizeS3Key(path)
+	ctx := context.Background()
+
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if path == "" {
+		return &filesystem.FileInfo{
+			Name:    "/",
+			Mode:    0755,
+			ModTime: time.Now(),
+			IsDir:   true,
+			Meta:    fs.meta(),
+		}, nil
+	}
+
+	// Try as file first
+	attrs, err := fs.client.HeadObject(ctx, path)
+	if err == nil {
+		info := &filesystem.FileInfo{
+			Name:    filepath.Base(path),
+			Size:    attrs.Size,
+			Mode:    0644,
+			ModTime: attrs.Updated,
+			Meta:    fs.meta(),
+		}
+		if attrs.ContentType != "" {
+			info.Meta.Content["content_type"] = attrs.ContentType
+		}
+		return info, nil
+	}
+	if !isNotFound(err) {
+		return nil, err
+	}
+
+	// Try as directory
+	dirExists, err := fs.client.DirectoryExists(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check directory: %w", err)
+	}
+	if !dirExists {
+		return nil, filesystem.ErrNotFound
+	}
+
+	return &filesystem.FileInfo{
+		Name:    filepath.Base(path),
+		Mode:    0755,
+		ModTime: time.Now(),
+		IsDir:   true,
+		Meta:    fs.meta(),
+	}, nil
+}
+
+func (fs *GCSFS) Rename(oldPath, newPath string) error {
+	oldPath = filesystem.NormalizeS3Key(oldPath)
+	newPath = filesystem.NormalizeS3Key(newPath)
+	ctx := context.Background()
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	exists, err := fs.client.ObjectExists(ctx, oldPath)
+	if err != nil {
+		return fmt.Errorf("failed to check source: %w", err)
+	}
+	if !exists {
+		return filesystem.ErrNotFound
+	}
+
+	// GCS copies server-side, so the data never passes through the server
+	if err := fs.client.CopyObject(ctx, oldPath, newPath); err != nil {
+		return fmt.Errorf("failed to write destination: %w", err)
+	}
+	if err := fs.client.DeleteObject(ctx, oldPath); err != nil {
+		return fmt.Errorf("failed to delete source: %w", err)
+	}
+	return nil
+}
+
+func (fs *GCSFS) Chmod(path string, mode uint32) error {
+	// GCS doesn't support Unix permissions
+	// This is a no-op for compatibility
+	return nil
+}
+
+func (fs *GCSFS) Open(path string) (io.ReadCloser, error) {
+	path = filesystem.NormalizeS3Key(path)
+
+	r, err := fs.client.GetObjectStream(context.Background(), path)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return r, nil
+}
+
+// OpenWrite streams data to GCS as a resumable upload
+// The object becomes visible when the writer is closed.
+func (fs *GCSFS) OpenWrite(path string) (io.WriteCloser, error) {
+	path = filesystem.NormalizeS3Key(path)
+	if path == "" || strings.HasSuffix(path, "/") {
+		return nil, fmt.Errorf("is a directory: %s", path)
+	}
+	return fs.client.NewObjectWriter(context.Background(), path), nil
+}
+
+// gcsStreamReader implements filesystem.StreamReader for GCS objects
+type gcsStreamReader struct {
+	body      io.ReadCloser
+	chunkSize int64
+	closed    bool
+	mu        sync.Mutex
+}
+
+// ReadChunk reads the next chunk from the GCS object stream
+func (r *gcsStreamReader) ReadChunk(timeout time.Duration) ([]byte, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil, true, io.EOF
+	}
+
+	type readResult struct {
+		n   int
+		err error
+	}
+	buf := make([]byte, r.chunkSize)
+	resultCh := make(chan readResult, 1)
+	go func() {
+		n, err := io.ReadFull(r.body, buf)
+		resultCh <- readResult{n: n, err: err}
+	}()
+
+	select {
+	case result := <-resultCh:
+		if result.err == io.EOF || result.err == io.ErrUnexpectedEOF {
+			if result.n > 0 {
+				return buf[:result.n], true, nil
+			}
+			return nil, true, io.EOF
+		}
+		if result.err != nil {
+			return nil, false, result.err
+		}
+		return buf[:result.n], false, nil
+	case <-time.After(timeout):
+		return nil, false, fmt.Errorf("read timeout")
+	}
+}
+
+// Close closes the GCS object stream
+func (r *gcsStreamReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	return r.body.Close()
+}
+
+// OpenStream opens a stream for reading a GCS object
+// This implements the filesystem.Streamer interface
+func (fs *GCSFS) OpenStream(path string) (filesystem.StreamReader, error) {
+	body, err := fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &gcsStreamReader{body: body, chunkSize: 256 * 1024}, nil
+}
+
+// Truncate changes the size of the file
+// GCS objects are immutable, so the object is rewritten: the first size
+// bytes are streamed from the current object and zeros are appended if it grows.
+func (fs *GCSFS) Truncate(path string, size int64) error {
+	path = filesystem.NormalizeS3Key(path)
+	ctx := context.Background()
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if size < 0 {
+		return filesystem.NewInvalidArgumentError("size", size, "must be non-negative")
+	}
+	if path == "" || strings.HasSuffix(path, "/") {
+		return fmt.Errorf("is a directory: %s", path)
+	}
+
+	attrs, err := fs.client.HeadObject(ctx, path)
+	if err != nil {
+		return mapError(err)
+	}
+	if attrs.Size == size {
+		return nil
+	}
+
+	var body io.Reader = strings.NewReader("")
+	if size > 0 && attrs.Size > 0 {
+		// Read the current object at the generation we just looked at
+		r, err := fs.client.bucket.Object(attrs.Name).Generation(attrs.Generation).NewRangeReader(ctx, 0, min(size, attrs.Size))
+		if err != nil {
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+		defer r.Close()
+		body = r
+	}
+	if size > attrs.Size {
+		body = io.MultiReader(body, io.LimitReader(zeroReader{}, size-attrs.Size))
+	}
+
+	// The generation precondition fails instead of clobbering a concurrent writer
+	w := fs.client.bucket.Object(attrs.Name).If(storage.Conditions{GenerationMatch: attrs.Generation}).NewWriter(ctx)
+	w.ChunkSize = fs.client.chunkSize
+	w.ContentType = attrs.ContentType
+	if _, err := io.Copy(w, body); err != nil {
+		w.Close()
+		return fmt.Errorf("failed to write truncated file: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to write truncated file: %w", err)
+	}
+	return nil
+}
+
+// zeroReader yields an endless stream of zero bytes
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// GCSFSPlugin wraps GCSFS as a plugin
+type GCSFSPlugin struct {
+	fs *GCSFS
+}
+
+// NewGCSFSPlugin creates a new GCSFS plugin
+func NewGCSFSPlugin() *GCSFSPlugin {
+	return &GCSFSPlugin{}
+}
+
+func (p *GCSFSPlugin) Name() string {
+	return PluginName
+}
+
+func (p *GCSFSPlugin) Validate(cfg map[string]interface{}) error {
+	allowedKeys := []string{
+		"bucket", "prefix", "credentials_file", "credentials_json", "endpoint", "chunk_size", "mount_path",
+	}
+	if err := config.ValidateOnlyKnownKeys(cfg, allowedKeys); err != nil {
+		return err
+	}
+
+	if _, err := config.RequireString(cfg, "bucket"); err != nil {
+		return err
+	}
+
+	for _, key := range []string{"prefix", "credentials_file", "credentials_json", "endpoint"} {
+		if err := config.ValidateStringType(cfg, key); err != nil {
+			return err
+		}
+	}
+
+	if _, err := config.GetSizeConfig(cfg, "chunk_size", DefaultChunkSize); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (p *GCSFSPlugin) Initialize(cfg map[string]interface{}) error {
+	chunkSize, err := config.GetSizeConfig(cfg, "chunk_size", DefaultChunkSize)
+	if err != nil {
+		return err
+	}
+
+	gcsCfg := GCSConfig{
+		Bucket:          config.GetStringConfig(cfg, "bucket", ""),
+		Prefix:          config.GetStringConfig(cfg, "prefix", ""),
+		CredentialsFile: config.GetStringConfig(cfg, "credentials_file", ""),
+		CredentialsJSON: config.GetStringConfig(cfg, "credentials_json", ""),
+		Endpoint:        config.GetStringConfig(cfg, "endpoint", ""),
+		ChunkSize:       int(chunkSize),
+	}
+	if gcsCfg.Bucket == "" {
+		return fmt.Errorf("bucket name is required")
+	}
+
+	fs, err := NewGCSFS(gcsCfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize gcsfs: %w", err)
+	}
+	p.fs = fs
+
+	log.Infof("[gcsfs] Initialized with bucket: %s, chunk size: %d", gcsCfg.Bucket, gcsCfg.ChunkSize)
+	return nil
+}
+
+func (p *GCSFSPlugin) GetFileSystem() filesystem.FileSystem {
+	return p.fs
+}
+
+func (p *GCSFSPlugin) GetReadme() string {
+	return getReadme()
+}
+
+func (p *GCSFSPlugin) GetConfigParams() []plugin.ConfigParameter {
+	return []plugin.ConfigParameter{
+		{
+			Name:        "bucket",
+			Type:        "string",
+			Required:    true,
+			Default:     "",
+			Description: "GCS bucket name",
+		},
+		{
+			Name:        "prefix",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Key prefix for namespace isolation. Nested prefixes (e.g., 'team1' and 'team1/test') are automatically isolated.",
+		},
+		{
+			Name:        "credentials_file",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Path to a service account key file (uses Application Default Credentials if not provided)",
+		},
+		{
+			Name:        "credentials_json",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Service account key JSON (alternative to credentials_file)",
+			Secret:      true,
+		},
+		{
+			Name:        "endpoint",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Custom endpoint for emulators (e.g., http://localhost:4443/storage/v1/)",
+		},
+		{
+			Name:        "chunk_size",
+			Type:        "string",
+			Required:    false,
+			Default:     "16MB",
+			Description: "Resumable upload chunk size (0 uploads each object in a single request)",
+		},
+	}
+}
+
+func (p *GCSFSPlugin) Shutdown() error {
+	if p.fs != nil {
+		return p.fs.client.Close()
+	}
+	return nil
+}
+
+func getReadme() string {
+	return `GCSFS Plugin - Google Cloud Storage-backed File System
+
+This plugin provides a file system backed by a Google Cloud Storage bucket.
+It mirrors s3fs: directories are simulated with "/" in object names and
+every prefix is isolated from the others.
+
+FEATURES:
+  - Store files and directories in a GCS bucket
+  - Range reads fetch only the requested bytes
+  - Streaming writes as resumable uploads (chunk_size pieces)
+  - Server-side copy for rename
+  - Truncate (objects are rewritten)
+  - Optional key prefix with strict isolation for nested prefixes
+  - Works with emulators such as fake-gcs-server
+
+CONFIGURATION:
+
+  Application Default Credentials:
+  [plugins.gcsfs]
+  enabled = true
+  path = "/gcs"
+
+    [plugins.gcsfs.config]
+    bucket = "my-bucket"
+    prefix = "agfs"  # Optional: all objects are stored under this prefix
+
+  Service account key:
+  [plugins.gcsfs]
+  enabled = true
+  path = "/gcs"
+
+    [plugins.gcsfs.config]
+    bucket = "my-bucket"
+    credentials_file = "/etc/agfs/gcs-key.json"
+    chunk_size = "32MB"
+
+  Emulator (fake-gcs-server):
+  [plugins.gcsfs]
+  enabled = true
+  path = "/gcs"
+
+    [plugins.gcsfs.config]
+    bucket = "test"
+    endpoint = "http://localhost:4443/storage/v1/"
+
+USAGE:
+
+  Write and read a file:
+    agfs write /gcs/data/file.txt "Hello, GCS!"
+    agfs cat /gcs/data/file.txt
+
+  Stream a large file:
+    agfs cat --stream /gcs/videos/movie.mp4 > movie.mp4
+
+  List and remove:
+    agfs ls /gcs/data
+    agfs rm -r /gcs/data
+
+NOTES:
+  - Writes replace the whole object; offset writes are not supported
+  - Permissions (chmod) are not supported by GCS
+  - Prefix "team1" stores objects as "__PREFIX__team1__/<path>", like s3fs
+  - Objects larger than chunk_size are uploaded in several requests and
+    become visible only when the upload completes
+`
+}
+
+// Ensure GCSFSPlugin implements ServicePlugin
+var _ plugin.ServicePlugin = (*GCSFSPlugin)(nil)
+var _ filesystem.FileSystem = (*GCSFS)(nil)
+var _ filesystem.Streamer = (*GCSFS)(nil)
+var _ filesystem.Truncater = (*GCSFS)(nil)
@@ -0,0 +1,198 @@
+package gcsfs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+// getTestConfig returns GCS config from environment variables
+// Required: GCS_TEST_BUCKET
+// Optional: GCS_TEST_ENDPOINT (e.g. fake-gcs-server), GCS_TEST_CREDENTIALS_FILE
+func getTestConfig() (GCSConfig, bool) {
+	bucket := os.Getenv("GCS_TEST_BUCKET")
+	if bucket == "" {
+		return GCSConfig{}, false
+	}
+
+	return GCSConfig{
+		Bucket:          bucket,
+		Endpoint:        os.Getenv("GCS_TEST_ENDPOINT"),
+		CredentialsFile: os.Getenv("GCS_TEST_CREDENTIALS_FILE"),
+		Prefix:          "agfs-test",
+		ChunkSize:       256 * 1024,
+	}, true
+}
+
+func newTestFS(t *testing.T) *GCSFS {
+	t.Helper()
+
+	cfg, ok := getTestConfig()
+	if !ok {
+		t.Skip("GCS test environment not configured (set GCS_TEST_BUCKET)")
+	}
+
+	fs, err := NewGCSFS(cfg)
+	if err != nil {
+		t.Fatalf("NewGCSFS failed: %v", err)
+	}
+	t.Cleanup(func() { fs.client.Close() })
+	return fs
+}
+
+func TestBuildKey(t *testing.T) {
+	plain := &GCSClient{}
+	if got := plain.buildKey("/a/b.txt"); got != "a/b.txt" {
+		t.Errorf("buildKey without prefix = %q", got)
+	}
+
+	isolated := &GCSClient{prefix: PrefixIsolationDelimiter + "team1__"}
+	if got := isolated.buildKey("/a/b.txt"); got != "__PREFIX__team1__/a/b.txt" {
+		t.Errorf("buildKey with prefix = %q", got)
+	}
+	if got := isolated.dirPrefix(""); got != "__PREFIX__team1__/" {
+		t.Errorf("dirPrefix of root = %q", got)
+	}
+}
+
+func TestGCSFSValidate(t *testing.T) {
+	p := NewGCSFSPlugin()
+	if err := p.Validate(map[string]interface{}{}); err == nil {
+		t.Error("expected error for missing bucket")
+	}
+	if err := p.Validate(map[string]interface{}{"bucket": "b", "region": "us"}); err == nil {
+		t.Error("expected error for unknown key")
+	}
+	if err := p.Validate(map[string]interface{}{"bucket": "b", "chunk_size": "lots"}); err == nil {
+		t.Error("expected error for invalid chunk_size")
+	}
+	if err := p.Validate(map[string]interface{}{"bucket": "b", "chunk_size": "8MB", "mount_path": "/gcs"}); err != nil {
+		t.Errorf("Validate failed: %v", err)
+	}
+}
+
+func TestGCSFSReadWrite(t *testing.T) {
+	fs := newTestFS(t)
+	path := "/rw_test.txt"
+	defer fs.Remove(path)
+
+	if _, err := fs.Write(path, []byte("Hello, World!"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	content, err := fs.Read(path, 0, -1)
+	if err != nil || string(content) != "Hello, World!" {
+		t.Errorf("Read = %q, %v", content, err)
+	}
+	content, err = fs.Read(path, 7, 5)
+	if err != nil || string(content) != "World" {
+		t.Errorf("range Read = %q, %v", content, err)
+	}
+
+	if _, err := fs.Write(path, []byte("x"), 3, filesystem.WriteFlagNone); err == nil {
+		t.Error("expected error for offset write")
+	}
+	if _, err := fs.Read("/missing.txt", 0, -1); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("missing file: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestGCSFSOpenWrite(t *testing.T) {
+	fs := newTestFS(t)
+	path := "/stream_test.bin"
+	defer fs.Remove(path)
+
+	// Larger than the test chunk size, so the upload takes several requests
+	data := make([]byte, 700*1024)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	w, err := fs.OpenWrite(path)
+	if err != nil {
+		t.Fatalf("OpenWrite failed: %v", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	r, err := fs.Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer r.Close()
+	got, err := io.ReadAll(r)
+	if err != nil || len(got) != len(data) || got[len(got)-1] != data[len(data)-1] {
+		t.Errorf("read back %d bytes, %v", len(got), err)
+	}
+}
+
+func TestGCSFSTruncate(t *testing.T) {
+	fs := newTestFS(t)
+	path := "/truncate_test.txt"
+	defer fs.Remove(path)
+
+	if _, err := fs.Write(path, []byte("Hello, World!"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	if err := fs.Truncate(path, 5); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+	if content, err := fs.Read(path, 0, -1); err != nil || string(content) != "Hello" {
+		t.Errorf("after shrink = %q, %v", content, err)
+	}
+
+	if err := fs.Truncate(path, 8); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+	if content, err := fs.Read(path, 0, -1); err != nil || string(content) != "Hello\x00\x00\x00" {
+		t.Errorf("after extend = %q, %v", content, err)
+	}
+
+	if err := fs.Truncate("/missing.txt", 0); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("missing file: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestGCSFSDirectories(t *testing.T) {
+	fs := newTestFS(t)
+	defer fs.RemoveAll("/dir_test")
+
+	if err := fs.Mkdir("/dir_test", 0755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	if _, err := fs.Write("/dir_test/a.txt", []byte("a"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := fs.Mkdir("/dir_test/sub", 0755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+
+	infos, err := fs.ReadDir("/dir_test")
+	if err != nil || len(infos) != 2 {
+		t.Fatalf("ReadDir = %+v, %v", infos, err)
+	}
+	if info, err := fs.Stat("/dir_test/sub"); err != nil || !info.IsDir {
+		t.Errorf("Stat dir = %+v, %v", info, err)
+	}
+	if err := fs.Remove("/dir_test"); err == nil {
+		t.Error("expected error removing non-empty directory")
+	}
+
+	if err := fs.Rename("/dir_test/a.txt", "/dir_test/b.txt"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if content, err := fs.Read("/dir_test/b.txt", 0, -1); err != nil || string(content) != "a" {
+		t.Errorf("renamed file = %q, %v", content, err)
+	}
+	if _, err := fs.Stat("/dir_test/a.txt"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("old name: expected ErrNotFound, got %v", err)
+	}
+}
@@ -533,6 +533,7 @@ func (g *Gptfs) GetConfigParams() []plugin.ConfigParameter {
 			Type:        "string",
 			Required:    true,
 			Description: "API key for OpenAI-compatible service",
+			Secret:      true,
 		},
 		{
 			Name:        "api_host",
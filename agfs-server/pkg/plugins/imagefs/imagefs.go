@@ -0,0 +1,895 @@
+package imagefs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PluginName = "imagefs" // Name of this plugin
+
+	maxVariants = 32 // Converted and resized renditions cached per image
+)
+
+// Meta values for ImageFS plugin
+const (
+	MetaValueImageDir = "image_dir" // An image directory, and its resize/
+	MetaValuePrompt   = "prompt"    // Generation request
+	MetaValueImage    = "image"     // The image and its renditions
+	MetaValueInfo     = "info"      // Image description (JSON)
+)
+
+// Files inside each image directory
+const (
+	filePrompt = "prompt"
+	fileInfo   = "info"
+	fileImage  = "image.png"
+	dirResize  = "resize"
+)
+
+// imageFiles are the files listed in an image directory once it has an image
+var imageFiles = []string{filePrompt, fileInfo, fileImage, "image.jpg", "image.gif"}
+
+var imageNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
+// ImageInfo describes an image, the content of its info file
+type ImageInfo struct {
+	Source        string    `json:"source"` // generated or imported
+	Width         int       `json:"width"`
+	Height        int       `json:"height"`
+	Provider      string    `json:"provider,omitempty"`
+	Model         string    `json:"model,omitempty"`
+	Prompt        string    `json:"prompt,omitempty"`
+	RevisedPrompt string    `json:"revised_prompt,omitempty"`
+	Seed          int64     `json:"seed,omitempty"`
+	Created       time.Time `json:"created"`
+}
+
+// Image holds an image directory
+type Image struct {
+	Name     string
+	Request  *GenerateRequest // Last generation request, nil if none
+	Info     ImageInfo
+	Modified time.Time
+
+	png      []byte            // The image, nil until generated or imported
+	pixels   image.Image       // Decoded png
+	variants map[string][]byte // Rendered conversions and resizes, by path in the directory
+
+	mu sync.Mutex // Serializes generations, and protects the image and its variants
+}
+
+// ImageFSPlugin generates images from prompts and converts and resizes them
+// Each image is a directory:
+//
+//	/<image>/prompt               - write a prompt; the write returns once the image is generated
+//	/<image>/image.png            - the image; write a PNG, JPEG or GIF to import one
+//	/<image>/image.jpg, image.gif - the image converted
+//	/<image>/info                 - size, provider, model, prompt and seed (JSON)
+//	/<image>/resize/<W>x<H>.<ext> - the image resized to fit W x H
+type ImageFSPlugin struct {
+	provider     Provider
+	defaultModel string
+	defaultSize  string
+	timeout      time.Duration
+	jpegQuality  int
+	maxDimension int // Largest width or height of a resize
+
+	images map[string]*Image
+	mu     sync.RWMutex // Protects images
+
+	metadata plugin.PluginMetadata
+}
+
+// NewImageFSPlugin creates a new image plugin
+func NewImageFSPlugin() *ImageFSPlugin {
+	return &ImageFSPlugin{
+		images: make(map[string]*Image),
+		metadata: plugin.PluginMetadata{
+			Name:        PluginName,
+			Version:     "1.0.0",
+			Description: "Generates images from prompts and converts and resizes them",
+			Author:      "AGFS Server",
+		},
+	}
+}
+
+func (i *ImageFSPlugin) Name() string {
+	return i.metadata.Name
+}
+
+func (i *ImageFSPlugin) Validate(cfg map[string]interface{}) error {
+	allowedKeys := []string{
+		"mount_path", "provider", "api_key", "api_base", "model", "size", "timeout",
+		"jpeg_quality", "max_dimension",
+	}
+	if err := config.ValidateOnlyKnownKeys(cfg, allowedKeys); err != nil {
+		return err
+	}
+
+	for _, key := range []string{"provider", "api_key", "api_base", "model", "size", "timeout"} {
+		if err := config.ValidateStringType(cfg, key); err != nil {
+			return err
+		}
+	}
+	for _, key := range []string{"jpeg_quality", "max_dimension"} {
+		if err := config.ValidateIntType(cfg, key); err != nil {
+			return err
+		}
+	}
+
+	if timeout, err := time.ParseDuration(config.GetStringConfig(cfg, "timeout", "120s")); err != nil || timeout <= 0 {
+		return fmt.Errorf("invalid timeout: must be a positive duration such as \"120s\"")
+	}
+	if q := config.GetIntConfig(cfg, "jpeg_quality", 90); q < 1 || q > 100 {
+		return fmt.Errorf("invalid jpeg_quality: must be between 1 and 100")
+	}
+	if config.GetIntConfig(cfg, "max_dimension", 4096) <= 0 {
+		return fmt.Errorf("invalid max_dimension: must be positive")
+	}
+	if size := config.GetStringConfig(cfg, "size", ""); size != "" && size != "auto" {
+		if _, _, err := parseSize(size); err != nil {
+			return err
+		}
+	}
+	_, err := NewProvider(providerConfig(cfg), &http.Client{})
+	return err
+}
+
+func providerConfig(cfg map[string]interface{}) ProviderConfig {
+	return ProviderConfig{
+		Type:    config.GetStringConfig(cfg, "provider", "openai"),
+		APIKey:  config.GetStringConfig(cfg, "api_key", ""),
+		APIBase: config.GetStringConfig(cfg, "api_base", ""),
+	}
+}
+
+func (i *ImageFSPlugin) Initialize(cfg map[string]interface{}) error {
+	var err error
+	i.timeout, err = time.ParseDuration(config.GetStringConfig(cfg, "timeout", "120s"))
+	if err != nil {
+		return fmt.Errorf("invalid timeout: %w", err)
+	}
+
+	if i.provider, err = NewProvider(providerConfig(cfg), &http.Client{}); err != nil {
+		return err
+	}
+	i.defaultModel = config.GetStringConfig(cfg, "model", defaultModel(i.provider.Type()))
+	i.defaultSize = config.GetStringConfig(cfg, "size", defaultSize(i.provider.Type()))
+	i.jpegQuality = config.GetIntConfig(cfg, "jpeg_quality", 90)
+	i.maxDimension = config.GetIntConfig(cfg, "max_dimension", 4096)
+
+	log.Infof("[imagefs] Initialized with provider=%s, model=%s", i.provider.Type(), i.defaultModel)
+	return nil
+}
+
+func (i *ImageFSPlugin) GetFileSystem() filesystem.FileSystem {
+	return &imageFS{plugin: i}
+}
+
+func (i *ImageFSPlugin) GetReadme() string {
+	return `ImageFS Plugin - Image Generation and Manipulation
+
+This plugin lets agents generate images and convert and resize them
+through files. Each image is a directory; writing a prompt to its prompt
+file generates the image, which is then read as PNG, JPEG or GIF, at its
+size or resized.
+
+STRUCTURE:
+  /imagefs/
+    README                  - This documentation
+    <image>/                - An image (mkdir, or write its prompt)
+      prompt                - Write a prompt to generate the image;
+                              read the last generation request (JSON)
+      image.png             - The image; write a PNG, JPEG or GIF to import one
+      image.jpg             - The image as JPEG (read-only)
+      image.gif             - The image as GIF (read-only)
+      info                  - Size, provider, model, prompt and seed (JSON)
+      resize/<W>x<H>.<ext>  - The image resized to fit W x H, as png, jpg or gif
+      resize/<W>x.<ext>     - ... to W pixels wide
+      resize/x<H>.<ext>     - ... to H pixels high
+
+WORKFLOW:
+  agfs:/> echo "a lighthouse at dusk, oil painting" > /imagefs/lighthouse/prompt
+  agfs:/> cp /imagefs/lighthouse/image.png /local/lighthouse.png
+  agfs:/> cp /imagefs/lighthouse/resize/256x256.jpg /local/thumb.jpg
+  agfs:/> cat /imagefs/lighthouse/info
+
+  The write to prompt returns once the image is generated. A failed
+  request returns an error from the write and leaves the image unchanged.
+
+  A prompt can also be a JSON request:
+  agfs:/> echo '{"prompt": "a red fox", "size": "1536x1024", "quality": "high"}' > /imagefs/fox/prompt
+
+    prompt           - What to generate (required)
+    negative_prompt  - What to avoid (stability, sdwebui)
+    model            - Model, instead of the configured one
+    size             - <width>x<height>, or auto (openai)
+    quality          - low, medium, high, ... (openai)
+    seed             - Seed, 0 for a random one (stability, sdwebui)
+    steps            - Sampling steps (sdwebui)
+
+  Existing images are imported by writing them to image.png:
+  agfs:/> cp /local/photo.jpg /imagefs/photo/image.png
+  agfs:/> cp /imagefs/photo/resize/800x.jpg /local/photo-small.jpg
+
+PROVIDERS:
+  openai     - OpenAI images API (gpt-image-1, dall-e-3, ...), and any
+               compatible endpoint via api_base
+  stability  - Stability AI Stable Image API; model is core, ultra or an
+               SD3 model (sd3.5-large, ...), size picks the aspect ratio
+  sdwebui    - A local Stable Diffusion server with the AUTOMATIC1111 web
+               UI API (also Forge, SD.Next), default http://127.0.0.1:7860;
+               api_key is the user:password of its --api-auth
+
+  If api_key is empty, OPENAI_API_KEY or STABILITY_API_KEY is used.
+
+CONFIGURATION:
+  [plugins.imagefs]
+  enabled = true
+  path = "/imagefs"
+
+    [plugins.imagefs.config]
+    provider = "openai"
+    api_key = "sk-..."
+    model = "gpt-image-1"
+    size = "1024x1024"
+    timeout = "120s"
+    jpeg_quality = 90
+    max_dimension = 4096
+
+NOTES:
+  - Images are kept in memory and are lost when the server restarts.
+  - Prompts and image.png are written whole: partial writes are rejected.
+`
+}
+
+func (i *ImageFSPlugin) GetConfigParams() []plugin.ConfigParameter {
+	return []plugin.ConfigParameter{
+		{
+			Name:        "provider",
+			Type:        "string",
+			Required:    false,
+			Default:     "openai",
+			Description: "Image generation provider (openai, stability, sdwebui)",
+			Enum:        []string{"openai", "stability", "sdwebui"},
+		},
+		{
+			Name:        "api_key",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "API key (falls back to OPENAI_API_KEY / STABILITY_API_KEY; user:password for sdwebui)",
+			Secret:      true,
+		},
+		{
+			Name:        "api_base",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "API base URL of the provider",
+		},
+		{
+			Name:        "model",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Default model (gpt-image-1 for openai, core for stability, the loaded checkpoint for sdwebui)",
+		},
+		{
+			Name:        "size",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Default image size <width>x<height> (1024x1024, 512x512 for sdwebui)",
+		},
+		{
+			Name:        "timeout",
+			Type:        "string",
+			Required:    false,
+			Default:     "120s",
+			Description: "Timeout of a single generation request",
+		},
+		{
+			Name:        "jpeg_quality",
+			Type:        "int",
+			Required:    false,
+			Default:     "90",
+			Description: "Quality of JPEG renditions (1-100)",
+		},
+		{
+			Name:        "max_dimension",
+			Type:        "int",
+			Required:    false,
+			Default:     "4096",
+			Description: "Largest width or height of a resized image",
+		},
+	}
+}
+
+func (i *ImageFSPlugin) Shutdown() error {
+	return nil
+}
+
+// imageFS implements the FileSystem interface for image directories
+type imageFS struct {
+	plugin *ImageFSPlugin
+}
+
+// imagePath is a parsed path: an image directory, one of its files, or a
+// file of its resize/ directory
+type imagePath struct {
+	name   string // Image directory, empty for the root
+	file   string // File in the directory, empty for the directory itself
+	resize string // File in resize/, when file is resize
+}
+
+// parseImagePath splits a path into image, file and resize file
+func parseImagePath(p string) (imagePath, error) {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return imagePath{}, nil
+	}
+
+	parts := strings.Split(p, "/")
+	if len(parts) > 3 || len(parts) == 3 && parts[1] != dirResize {
+		return imagePath{}, filesystem.NewNotFoundError("stat", "/"+p)
+	}
+	if !imageNamePattern.MatchString(parts[0]) || parts[0] == "README" {
+		return imagePath{}, filesystem.NewInvalidArgumentError("image", parts[0], "must match [A-Za-z0-9._-] and not start with '.'")
+	}
+	ip := imagePath{name: parts[0]}
+	if len(parts) == 1 {
+		return ip, nil
+	}
+	ip.file = parts[1]
+	if len(parts) == 3 {
+		ip.resize = parts[2]
+		return ip, nil
+	}
+	if ip.file == filePrompt || ip.file == fileInfo || ip.file == dirResize {
+		return ip, nil
+	}
+	if _, _, _, err := parseVariant(ip.file, false); err != nil {
+		return imagePath{}, filesystem.NewNotFoundError("stat", "/"+p)
+	}
+	return ip, nil
+}
+
+func (ifs *imageFS) getImage(name, op, path string) (*Image, error) {
+	ifs.plugin.mu.RLock()
+	defer ifs.plugin.mu.RUnlock()
+
+	img, ok := ifs.plugin.images[name]
+	if !ok {
+		return nil, filesystem.NewNotFoundError(op, path)
+	}
+	return img, nil
+}
+
+func (ifs *imageFS) getOrCreateImage(name string) *Image {
+	ifs.plugin.mu.Lock()
+	defer ifs.plugin.mu.Unlock()
+
+	img, ok := ifs.plugin.images[name]
+	if !ok {
+		img = &Image{Name: name, Modified: time.Now()}
+		ifs.plugin.images[name] = img
+	}
+	return img
+}
+
+// setImage replaces the image of an image directory; img.mu must be held
+func (img *Image) setImage(data []byte, info ImageInfo) error {
+	pixels, format, err := decodeImage(data)
+	if err != nil {
+		return err
+	}
+	if format != "png" {
+		if data, err = encodeImage(pixels, "png", 0); err != nil {
+			return err
+		}
+	}
+	info.Width, info.Height = pixels.Bounds().Dx(), pixels.Bounds().Dy()
+	info.Created = time.Now()
+
+	img.png = data
+	img.pixels = pixels
+	img.variants = make(map[string][]byte)
+	img.Info = info
+	img.Modified = info.Created
+	return nil
+}
+
+// render returns a file of the image: the image, converted or resized
+func (ifs *imageFS) render(img *Image, ip imagePath, path string) ([]byte, error) {
+	img.mu.Lock()
+	defer img.mu.Unlock()
+
+	if img.png == nil {
+		return nil, filesystem.NewNotFoundError("read", path)
+	}
+	key, name := ip.file, ip.file
+	if ip.file == dirResize {
+		key, name = dirResize+"/"+ip.resize, ip.resize
+	}
+	if key == fileImage {
+		return img.png, nil
+	}
+	if data, ok := img.variants[key]; ok {
+		return data, nil
+	}
+
+	format, width, height, err := parseVariant(name, ip.file == dirResize)
+	if err != nil {
+		return nil, filesystem.NewInvalidArgumentError("file", name, err.Error())
+	}
+	pixels := img.pixels
+	if ip.file == dirResize {
+		width, height = fitSize(img.Info.Width, img.Info.Height, width, height)
+		if width > ifs.plugin.maxDimension || height > ifs.plugin.maxDimension {
+			return nil, filesystem.NewInvalidArgumentError("size", fmt.Sprintf("%dx%d", width, height),
+				fmt.Sprintf("exceeds max_dimension %d", ifs.plugin.maxDimension))
+		}
+		pixels = resizeImage(pixels, width, height)
+	}
+
+	data, err := encodeImage(pixels, format, ifs.plugin.jpegQuality)
+	if err != nil {
+		return nil, err
+	}
+	if len(img.variants) >= maxVariants {
+		img.variants = make(map[string][]byte)
+	}
+	img.variants[key] = data
+	return data, nil
+}
+
+func (ifs *imageFS) Create(path string) error {
+	ip, err := parseImagePath(path)
+	if err != nil {
+		return err
+	}
+	if ip.file != filePrompt && ip.file != fileImage {
+		return filesystem.NewPermissionDeniedError("create", path, "only prompt and image.png can be written")
+	}
+	ifs.getOrCreateImage(ip.name)
+	return nil
+}
+
+func (ifs *imageFS) Mkdir(path string, perm uint32) error {
+	ip, err := parseImagePath(path)
+	if err != nil {
+		return err
+	}
+	if ip.name == "" || ip.file != "" {
+		return filesystem.NewAlreadyExistsError("directory", path)
+	}
+
+	ifs.plugin.mu.RLock()
+	_, exists := ifs.plugin.images[ip.name]
+	ifs.plugin.mu.RUnlock()
+	if exists {
+		return filesystem.NewAlreadyExistsError("image", path)
+	}
+	ifs.getOrCreateImage(ip.name)
+	return nil
+}
+
+func (ifs *imageFS) Remove(path string) error {
+	return ifs.RemoveAll(path)
+}
+
+func (ifs *imageFS) RemoveAll(path string) error {
+	ip, err := parseImagePath(path)
+	if err != nil {
+		return err
+	}
+	if ip.name == "" || ip.file != "" {
+		return filesystem.NewPermissionDeniedError("remove", path, "only images can be removed")
+	}
+
+	ifs.plugin.mu.Lock()
+	defer ifs.plugin.mu.Unlock()
+	if _, ok := ifs.plugin.images[ip.name]; !ok {
+		return filesystem.NewNotFoundError("remove", path)
+	}
+	delete(ifs.plugin.images, ip.name)
+	return nil
+}
+
+func (ifs *imageFS) Read(path string, offset int64, size int64) ([]byte, error) {
+	data, err := ifs.readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return plugin.ApplyRangeRead(data, offset, size)
+}
+
+func (ifs *imageFS) readFile(path string) ([]byte, error) {
+	if strings.Trim(path, "/") == "README" {
+		return []byte(ifs.plugin.GetReadme()), nil
+	}
+
+	ip, err := parseImagePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if ip.name == "" || ip.file == "" || ip.file == dirResize && ip.resize == "" {
+		return nil, fmt.Errorf("is a directory: %s", path)
+	}
+
+	img, err := ifs.getImage(ip.name, "read", path)
+	if err != nil {
+		return nil, err
+	}
+	switch ip.file {
+	case filePrompt:
+		img.mu.Lock()
+		defer img.mu.Unlock()
+		if img.Request == nil {
+			return []byte{}, nil
+		}
+		data, err := json.MarshalIndent(img.Request, "", "  ")
+		return append(data, '\n'), err
+	case fileInfo:
+		img.mu.Lock()
+		defer img.mu.Unlock()
+		if img.png == nil {
+			return nil, filesystem.NewNotFoundError("read", path)
+		}
+		data, err := json.MarshalIndent(img.Info, "", "  ")
+		return append(data, '\n'), err
+	}
+	return ifs.render(img, ip, path)
+}
+
+func (ifs *imageFS) Write(path string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	ip, err := parseImagePath(path)
+	if err != nil {
+		return 0, err
+	}
+	if ip.name == "" || ip.file == "" || ip.file == dirResize && ip.resize == "" {
+		return 0, fmt.Errorf("is a directory: %s", path)
+	}
+	if ip.file != filePrompt && ip.file != fileImage {
+		return 0, filesystem.NewPermissionDeniedError("write", path, "read-only file")
+	}
+	if offset > 0 || flags&filesystem.WriteFlagAppend != 0 {
+		return 0, filesystem.NewInvalidArgumentError("offset", offset, "prompts and images are written whole")
+	}
+
+	if ip.file == fileImage {
+		img := ifs.getOrCreateImage(ip.name)
+		img.mu.Lock()
+		defer img.mu.Unlock()
+		if err := img.setImage(data, ImageInfo{Source: "imported"}); err != nil {
+			return 0, filesystem.NewInvalidArgumentError("image", ip.name, err.Error())
+		}
+		img.Request = nil
+		return int64(len(data)), nil
+	}
+
+	req, err := parsePrompt(data)
+	if err != nil {
+		return 0, err
+	}
+	if err := ifs.generate(ifs.getOrCreateImage(ip.name), req); err != nil {
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
+
+// parsePrompt parses a prompt file: a JSON GenerateRequest, or the prompt
+// as plain text
+func parsePrompt(data []byte) (GenerateRequest, error) {
+	var req GenerateRequest
+	value := strings.TrimSpace(string(data))
+	if strings.HasPrefix(value, "{") {
+		dec := json.NewDecoder(strings.NewReader(value))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			return req, filesystem.NewInvalidArgumentError("prompt", value, err.Error())
+		}
+		req.Prompt = strings.TrimSpace(req.Prompt)
+	} else {
+		req.Prompt = value
+	}
+
+	if req.Prompt == "" {
+		return req, filesystem.NewInvalidArgumentError("prompt", "", "prompt must not be empty")
+	}
+	if req.Size != "" && req.Size != "auto" {
+		if _, _, err := parseSize(req.Size); err != nil {
+			return req, filesystem.NewInvalidArgumentError("size", req.Size, err.Error())
+		}
+	}
+	if req.Steps < 0 {
+		return req, filesystem.NewInvalidArgumentError("steps", req.Steps, "must not be negative")
+	}
+	return req, nil
+}
+
+// generate generates the image of an image directory and records it
+func (ifs *imageFS) generate(img *Image, req GenerateRequest) error {
+	img.mu.Lock()
+	defer img.mu.Unlock()
+
+	if req.Model == "" {
+		req.Model = ifs.plugin.defaultModel
+	}
+	if req.Size == "" {
+		req.Size = ifs.plugin.defaultSize
+	}
+	provider := ifs.plugin.provider
+
+	ctx, cancel := context.WithTimeout(context.Background(), ifs.plugin.timeout)
+	defer cancel()
+
+	start := time.Now()
+	generated, err := provider.Generate(ctx, req)
+	if err != nil {
+		log.Warnf("[imagefs] Generation of %s failed: %v", img.Name, err)
+		return fmt.Errorf("%s request failed: %w", provider.Type(), err)
+	}
+
+	err = img.setImage(generated.Data, ImageInfo{
+		Source:        "generated",
+		Provider:      provider.Type(),
+		Model:         req.Model,
+		Prompt:        req.Prompt,
+		RevisedPrompt: generated.RevisedPrompt,
+		Seed:          generated.Seed,
+	})
+	if err != nil {
+		return fmt.Errorf("%s returned an invalid image: %w", provider.Type(), err)
+	}
+	img.Request = &req
+
+	log.Debugf("[imagefs] %s/%s generated %s (%dx%d) in %v", provider.Type(), req.Model, img.Name,
+		img.Info.Width, img.Info.Height, time.Since(start))
+	return nil
+}
+
+func dirInfo(name string, modTime time.Time) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    name,
+		Size:    0,
+		Mode:    0755,
+		ModTime: modTime,
+		IsDir:   true,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: MetaValueImageDir},
+	}
+}
+
+func (ifs *imageFS) fileInfo(path, name string, modTime time.Time) (filesystem.FileInfo, error) {
+	data, err := ifs.readFile(path)
+	if err != nil {
+		return filesystem.FileInfo{}, err
+	}
+
+	mode := uint32(0444)
+	meta := filesystem.MetaData{Name: PluginName, Type: MetaValueImage}
+	switch name {
+	case filePrompt:
+		mode = 0644
+		meta.Type = MetaValuePrompt
+	case fileInfo:
+		meta.Type = MetaValueInfo
+	case "README":
+		meta.Type = "doc"
+	case fileImage:
+		mode = 0644
+	}
+	return filesystem.FileInfo{
+		Name:    name,
+		Size:    int64(len(data)),
+		Mode:    mode,
+		ModTime: modTime,
+		IsDir:   false,
+		Meta:    meta,
+	}, nil
+}
+
+func (ifs *imageFS) ReadDir(path string) ([]filesystem.FileInfo, error) {
+	ip, err := parseImagePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if ip.file != "" && (ip.file != dirResize || ip.resize != "") {
+		return nil, filesystem.NewNotDirectoryError(path)
+	}
+
+	if ip.name == "" {
+		readme, _ := ifs.fileInfo("/README", "README", time.Now())
+		files := []filesystem.FileInfo{readme}
+
+		ifs.plugin.mu.RLock()
+		names := make([]string, 0, len(ifs.plugin.images))
+		for n := range ifs.plugin.images {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		images := make([]*Image, 0, len(names))
+		for _, n := range names {
+			images = append(images, ifs.plugin.images[n])
+		}
+		ifs.plugin.mu.RUnlock()
+
+		for _, img := range images {
+			img.mu.Lock()
+			files = append(files, dirInfo(img.Name, img.Modified))
+			img.mu.Unlock()
+		}
+		return files, nil
+	}
+
+	img, err := ifs.getImage(ip.name, "readdir", path)
+	if err != nil {
+		return nil, err
+	}
+	img.mu.Lock()
+	modTime, hasImage := img.Modified, img.png != nil
+	var resized []string
+	for key := range img.variants {
+		if name, ok := strings.CutPrefix(key, dirResize+"/"); ok {
+			resized = append(resized, name)
+		}
+	}
+	img.mu.Unlock()
+
+	var names []string
+	if ip.file == dirResize {
+		if !hasImage {
+			return nil, filesystem.NewNotFoundError("readdir", path)
+		}
+		sort.Strings(resized)
+		names = resized
+	} else if hasImage {
+		names = imageFiles
+	} else {
+		names = []string{filePrompt}
+	}
+
+	files := make([]filesystem.FileInfo, 0, len(names)+1)
+	for _, name := range names {
+		p := "/" + ip.name + "/" + name
+		if ip.file == dirResize {
+			p = "/" + ip.name + "/" + dirResize + "/" + name
+		}
+		// Renditions are skipped if the image changes meanwhile
+		if info, err := ifs.fileInfo(p, name, modTime); err == nil {
+			files = append(files, info)
+		}
+	}
+	if ip.file == "" && hasImage {
+		files = append(files, dirInfo(dirResize, modTime))
+	}
+	return files, nil
+}
+
+func (ifs *imageFS) Stat(path string) (*filesystem.FileInfo, error) {
+	now := time.Now()
+	switch trimmed := strings.Trim(path, "/"); trimmed {
+	case "":
+		info := dirInfo("/", now)
+		info.Meta.Content = map[string]string{
+			"provider": ifs.plugin.provider.Type(),
+			"model":    ifs.plugin.defaultModel,
+		}
+		return &info, nil
+	case "README":
+		info, err := ifs.fileInfo(path, trimmed, now)
+		return &info, err
+	}
+
+	ip, err := parseImagePath(path)
+	if err != nil {
+		return nil, err
+	}
+	img, err := ifs.getImage(ip.name, "stat", path)
+	if err != nil {
+		return nil, err
+	}
+	img.mu.Lock()
+	modTime, hasImage := img.Modified, img.png != nil
+	width, height := img.Info.Width, img.Info.Height
+	img.mu.Unlock()
+
+	var info filesystem.FileInfo
+	switch {
+	case ip.file == "":
+		info = dirInfo(ip.name, modTime)
+	case ip.file == dirResize && ip.resize == "":
+		if !hasImage {
+			return nil, filesystem.NewNotFoundError("stat", path)
+		}
+		info = dirInfo(dirResize, modTime)
+	default:
+		name := ip.file
+		if ip.file == dirResize {
+			name = ip.resize
+		}
+		if info, err = ifs.fileInfo(path, name, modTime); err != nil {
+			return nil, err
+		}
+		if ip.file == fileImage {
+			info.Meta.Content = map[string]string{
+				"width":  fmt.Sprint(width),
+				"height": fmt.Sprint(height),
+			}
+		}
+	}
+	return &info, nil
+}
+
+func (ifs *imageFS) Rename(oldPath, newPath string) error {
+	oldIP, err := parseImagePath(oldPath)
+	if err != nil {
+		return err
+	}
+	newIP, err := parseImagePath(newPath)
+	if err != nil {
+		return err
+	}
+	if oldIP.name == "" || newIP.name == "" || oldIP.file != "" || newIP.file != "" {
+		return filesystem.NewNotSupportedError("rename", oldPath)
+	}
+
+	ifs.plugin.mu.Lock()
+	defer ifs.plugin.mu.Unlock()
+
+	img, ok := ifs.plugin.images[oldIP.name]
+	if !ok {
+		return filesystem.NewNotFoundError("rename", oldPath)
+	}
+	if _, exists := ifs.plugin.images[newIP.name]; exists {
+		return filesystem.NewAlreadyExistsError("image", newPath)
+	}
+	delete(ifs.plugin.images, oldIP.name)
+	img.mu.Lock()
+	img.Name = newIP.name
+	img.mu.Unlock()
+	ifs.plugin.images[newIP.name] = img
+	return nil
+}
+
+func (ifs *imageFS) Chmod(path string, mode uint32) error {
+	return filesystem.NewNotSupportedError("chmod", path)
+}
+
+// Truncate is a no-op so shell redirections like `echo prompt > prompt` work
+func (ifs *imageFS) Truncate(path string, size int64) error {
+	return nil
+}
+
+func (ifs *imageFS) Open(path string) (io.ReadCloser, error) {
+	data, err := ifs.readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (ifs *imageFS) OpenWrite(path string) (io.WriteCloser, error) {
+	return filesystem.NewBufferedWriter(path, ifs.Write), nil
+}
+
+// Ensure ImageFSPlugin implements ServicePlugin
+var _ plugin.ServicePlugin = (*ImageFSPlugin)(nil)
+var _ filesystem.FileSystem = (*imageFS)(nil)
@@ -0,0 +1,349 @@
+package imagefs
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+// testPNG returns a width x height PNG, red on the left half and blue on the right
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= width/2 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("failed to encode PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func newTestFS(t *testing.T, cfg map[string]interface{}) *imageFS {
+	t.Helper()
+	p := NewImageFSPlugin()
+	if err := p.Validate(cfg); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if err := p.Initialize(cfg); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	return p.GetFileSystem().(*imageFS)
+}
+
+// readIgnoreEOF reads file content, ignoring io.EOF which is expected at end of file
+func readIgnoreEOF(fs *imageFS, path string) ([]byte, error) {
+	content, err := fs.Read(path, 0, -1)
+	if err == io.EOF {
+		return content, nil
+	}
+	return content, err
+}
+
+// decodeFile reads and decodes an image file
+func decodeFile(t *testing.T, fs *imageFS, path string) (image.Image, string) {
+	t.Helper()
+	data, err := readIgnoreEOF(fs, path)
+	if err != nil {
+		t.Fatalf("read %s failed: %v", path, err)
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode %s failed: %v", path, err)
+	}
+	return img, format
+}
+
+func TestImageFSOpenAI(t *testing.T) {
+	var requests []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/images/generations" || r.Header.Get("Authorization") != "Bearer test-key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		requests = append(requests, body)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": []interface{}{map[string]string{
+				"b64_json":       base64.StdEncoding.EncodeToString(testPNG(t, 8, 4)),
+				"revised_prompt": "a red and blue flag",
+			}},
+		})
+	}))
+	defer srv.Close()
+
+	fs := newTestFS(t, map[string]interface{}{"api_key": "test-key", "api_base": srv.URL})
+
+	if _, err := fs.Write("/flag/prompt", []byte(`{"prompt": "a flag", "size": "1536x1024", "quality": "low"}`), 0, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("Write prompt failed: %v", err)
+	}
+	if len(requests) != 1 || requests[0]["model"] != "gpt-image-1" || requests[0]["size"] != "1536x1024" ||
+		requests[0]["quality"] != "low" || requests[0]["prompt"] != "a flag" || requests[0]["response_format"] != nil {
+		t.Errorf("requests = %+v", requests)
+	}
+
+	img, format := decodeFile(t, fs, "/flag/image.png")
+	if format != "png" || img.Bounds().Dx() != 8 || img.Bounds().Dy() != 4 {
+		t.Errorf("image.png = %s %v", format, img.Bounds())
+	}
+	if _, format := decodeFile(t, fs, "/flag/image.jpg"); format != "jpeg" {
+		t.Errorf("image.jpg format = %s", format)
+	}
+	if _, format := decodeFile(t, fs, "/flag/image.gif"); format != "gif" {
+		t.Errorf("image.gif format = %s", format)
+	}
+
+	var info ImageInfo
+	data, _ := readIgnoreEOF(fs, "/flag/info")
+	if err := json.Unmarshal(data, &info); err != nil || info.Source != "generated" || info.Width != 8 ||
+		info.Model != "gpt-image-1" || info.RevisedPrompt != "a red and blue flag" {
+		t.Errorf("info = %s, %v", data, err)
+	}
+	var req GenerateRequest
+	data, _ = readIgnoreEOF(fs, "/flag/prompt")
+	if err := json.Unmarshal(data, &req); err != nil || req.Prompt != "a flag" || req.Model != "gpt-image-1" {
+		t.Errorf("prompt = %s, %v", data, err)
+	}
+
+	// Plain text prompts use the configured defaults
+	if _, err := fs.Write("/flag/prompt", []byte("a flag again\n"), 0, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("Write prompt failed: %v", err)
+	}
+	if len(requests) != 2 || requests[1]["prompt"] != "a flag again" || requests[1]["size"] != "1024x1024" {
+		t.Errorf("second request = %+v", requests[1])
+	}
+
+	infos, err := fs.ReadDir("/flag")
+	if err != nil || len(infos) != len(imageFiles)+1 || !infos[len(infos)-1].IsDir {
+		t.Errorf("ReadDir /flag = %+v, %v", infos, err)
+	}
+	if _, err := fs.Write("/flag/image.jpg", []byte("x"), 0, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrPermissionDenied) {
+		t.Errorf("write image.jpg: expected ErrPermissionDenied, got %v", err)
+	}
+	if _, err := fs.Write("/flag/prompt", []byte(`{"prompt": "x", "colour": "red"}`), 0, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrInvalidArgument) {
+		t.Errorf("unknown request field: expected ErrInvalidArgument, got %v", err)
+	}
+	if _, err := fs.Write("/flag/prompt", []byte("more"), 10, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrInvalidArgument) {
+		t.Errorf("partial write: expected ErrInvalidArgument, got %v", err)
+	}
+}
+
+func TestImageFSRequestFailureLeavesImage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "content policy violation", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	fs := newTestFS(t, map[string]interface{}{"api_key": "k", "api_base": srv.URL})
+	if _, err := fs.Write("/a/image.png", testPNG(t, 2, 2), 0, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if _, err := fs.Write("/a/prompt", []byte("something"), 0, filesystem.WriteFlagNone); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Fatalf("expected HTTP 400 error, got %v", err)
+	}
+	var info ImageInfo
+	data, _ := readIgnoreEOF(fs, "/a/info")
+	if json.Unmarshal(data, &info); info.Source != "imported" {
+		t.Errorf("info after failed request = %s", data)
+	}
+}
+
+func TestImageFSStability(t *testing.T) {
+	var fields map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2beta/stable-image/generate/sd3" || r.Header.Get("Authorization") != "Bearer st-key" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		r.ParseMultipartForm(1 << 20)
+		fields = map[string]string{}
+		for k, v := range r.MultipartForm.Value {
+			fields[k] = v[0]
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"image":         base64.StdEncoding.EncodeToString(testPNG(t, 16, 9)),
+			"seed":          42,
+			"finish_reason": "SUCCESS",
+		})
+	}))
+	defer srv.Close()
+
+	fs := newTestFS(t, map[string]interface{}{
+		"provider": "stability",
+		"api_key":  "st-key",
+		"api_base": srv.URL,
+		"model":    "sd3.5-large",
+	})
+	prompt := `{"prompt": "a wide valley", "negative_prompt": "people", "size": "1920x1080", "seed": 42}`
+	if _, err := fs.Write("/valley/prompt", []byte(prompt), 0, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("Write prompt failed: %v", err)
+	}
+	if fields["model"] != "sd3.5-large" || fields["aspect_ratio"] != "16:9" || fields["negative_prompt"] != "people" ||
+		fields["seed"] != "42" || fields["output_format"] != "png" {
+		t.Errorf("fields = %+v", fields)
+	}
+
+	var info ImageInfo
+	data, _ := readIgnoreEOF(fs, "/valley/info")
+	if json.Unmarshal(data, &info); info.Seed != 42 || info.Width != 16 || info.Provider != "stability" {
+		t.Errorf("info = %s", data)
+	}
+}
+
+func TestImageFSSDWebUI(t *testing.T) {
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); r.URL.Path != "/sdapi/v1/txt2img" || !ok || user != "me" || pass != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"images": []string{base64.StdEncoding.EncodeToString(testPNG(t, 4, 4))},
+			"info":   `{"seed": 1234, "width": 4}`,
+		})
+	}))
+	defer srv.Close()
+
+	fs := newTestFS(t, map[string]interface{}{"provider": "sdwebui", "api_key": "me:secret", "api_base": srv.URL})
+	if _, err := fs.Write("/cat/prompt", []byte(`{"prompt": "a cat", "steps": 12}`), 0, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("Write prompt failed: %v", err)
+	}
+	if body["width"] != 512.0 || body["height"] != 512.0 || body["seed"] != -1.0 || body["steps"] != 12.0 || body["override_settings"] != nil {
+		t.Errorf("request = %+v", body)
+	}
+	var info ImageInfo
+	data, _ := readIgnoreEOF(fs, "/cat/info")
+	if json.Unmarshal(data, &info); info.Seed != 1234 {
+		t.Errorf("info = %s", data)
+	}
+}
+
+func TestImageFSImportAndResize(t *testing.T) {
+	fs := newTestFS(t, map[string]interface{}{"api_key": "k", "max_dimension": 64})
+
+	// Imported images are stored as PNG whatever their format
+	var buf bytes.Buffer
+	src, _ := png.Decode(bytes.NewReader(testPNG(t, 40, 20)))
+	jpeg.Encode(&buf, src, nil)
+	if _, err := fs.Write("/photo/image.png", buf.Bytes(), 0, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if _, format := decodeFile(t, fs, "/photo/image.png"); format != "png" {
+		t.Errorf("imported image format = %s", format)
+	}
+	if data, _ := readIgnoreEOF(fs, "/photo/prompt"); len(data) != 0 {
+		t.Errorf("prompt of an imported image = %q", data)
+	}
+
+	for _, tc := range []struct {
+		path          string
+		format        string
+		width, height int
+	}{
+		{"/photo/resize/10x10.png", "png", 10, 5},
+		{"/photo/resize/x10.jpg", "jpeg", 20, 10},
+		{"/photo/resize/60x.gif", "gif", 60, 30},
+	} {
+		img, format := decodeFile(t, fs, tc.path)
+		if format != tc.format || img.Bounds().Dx() != tc.width || img.Bounds().Dy() != tc.height {
+			t.Errorf("%s = %s %v, want %s %dx%d", tc.path, format, img.Bounds(), tc.format, tc.width, tc.height)
+		}
+	}
+
+	infos, err := fs.ReadDir("/photo/resize")
+	if err != nil || len(infos) != 3 || infos[0].Name != "10x10.png" {
+		t.Errorf("ReadDir resize = %+v, %v", infos, err)
+	}
+	if _, err := fs.Read("/photo/resize/100x100.png", 0, -1); !errors.Is(err, filesystem.ErrInvalidArgument) {
+		t.Errorf("resize beyond max_dimension: expected ErrInvalidArgument, got %v", err)
+	}
+	if _, err := fs.Read("/photo/resize/10x10.bmp", 0, -1); !errors.Is(err, filesystem.ErrInvalidArgument) {
+		t.Errorf("unknown format: expected ErrInvalidArgument, got %v", err)
+	}
+	if _, err := fs.Write("/photo/image.png", []byte("not an image"), 0, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrInvalidArgument) {
+		t.Errorf("import garbage: expected ErrInvalidArgument, got %v", err)
+	}
+
+	// Directories without an image only have a prompt
+	if err := fs.Mkdir("/empty", 0755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	if infos, err := fs.ReadDir("/empty"); err != nil || len(infos) != 1 || infos[0].Name != filePrompt {
+		t.Errorf("ReadDir /empty = %+v, %v", infos, err)
+	}
+	if _, err := fs.Read("/empty/image.png", 0, -1); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("read missing image: expected ErrNotFound, got %v", err)
+	}
+
+	if err := fs.Rename("/photo", "/picture"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if err := fs.RemoveAll("/picture"); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	if _, err := fs.Stat("/picture"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("removed image still exists: %v", err)
+	}
+}
+
+func TestResizeImage(t *testing.T) {
+	src, _ := png.Decode(bytes.NewReader(testPNG(t, 4, 2)))
+
+	// Each half averages to its color; a single pixel averages both
+	half := resizeImage(src, 2, 1)
+	if c := half.RGBAAt(0, 0); c != (color.RGBA{R: 255, A: 255}) {
+		t.Errorf("left pixel = %v", c)
+	}
+	if c := half.RGBAAt(1, 0); c != (color.RGBA{B: 255, A: 255}) {
+		t.Errorf("right pixel = %v", c)
+	}
+	if c := resizeImage(src, 1, 1).RGBAAt(0, 0); c != (color.RGBA{R: 128, B: 128, A: 255}) {
+		t.Errorf("single pixel = %v", c)
+	}
+
+	for _, tc := range []struct{ w, h, maxW, maxH, wantW, wantH int }{
+		{1024, 768, 256, 256, 256, 192},
+		{1024, 768, 0, 384, 512, 384},
+		{100, 50, 400, 0, 400, 200},
+		{1000, 1, 10, 10, 10, 1},
+	} {
+		if w, h := fitSize(tc.w, tc.h, tc.maxW, tc.maxH); w != tc.wantW || h != tc.wantH {
+			t.Errorf("fitSize(%d, %d, %d, %d) = %dx%d, want %dx%d", tc.w, tc.h, tc.maxW, tc.maxH, w, h, tc.wantW, tc.wantH)
+		}
+	}
+}
+
+func TestImageFSValidate(t *testing.T) {
+	for _, cfg := range []map[string]interface{}{
+		{"provider": "midjourney"},
+		{"timeout": "soon"},
+		{"size": "big"},
+		{"jpeg_quality": 0},
+		{"unknown": true},
+	} {
+		if err := NewImageFSPlugin().Validate(cfg); err == nil {
+			t.Errorf("expected %v to be rejected", cfg)
+		}
+	}
+}
@@ -0,0 +1,395 @@
+package imagefs
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// maxResponseBytes bounds the responses read from image APIs
+const maxResponseBytes = 64 << 20
+
+// GenerateRequest is a provider-independent image generation request,
+// the JSON written to a prompt file
+type GenerateRequest struct {
+	Prompt         string `json:"prompt"`
+	NegativePrompt string `json:"negative_prompt,omitempty"` // stability and sdwebui
+	Model          string `json:"model,omitempty"`
+	Size           string `json:"size,omitempty"`    // <width>x<height>, or auto
+	Quality        string `json:"quality,omitempty"` // openai
+	Seed           int64  `json:"seed,omitempty"`    // stability and sdwebui; 0 is random
+	Steps          int    `json:"steps,omitempty"`   // sdwebui
+}
+
+// GeneratedImage is the result of a generation request
+type GeneratedImage struct {
+	Data          []byte // Encoded image, usually PNG
+	RevisedPrompt string // The prompt as rewritten by the model, if it does
+	Seed          int64  // Seed used, if the provider reports it
+}
+
+// Provider sends generation requests to an image API
+type Provider interface {
+	// Type returns the provider type (openai, stability, sdwebui)
+	Type() string
+
+	// Generate generates an image
+	Generate(ctx context.Context, req GenerateRequest) (*GeneratedImage, error)
+}
+
+// ProviderConfig configures the provider
+type ProviderConfig struct {
+	Type    string // openai, stability or sdwebui
+	APIKey  string
+	APIBase string
+}
+
+// NewProvider creates a provider from its configuration
+// An empty api_key falls back to OPENAI_API_KEY / STABILITY_API_KEY.
+func NewProvider(cfg ProviderConfig, client *http.Client) (Provider, error) {
+	switch cfg.Type {
+	case "openai":
+		if cfg.APIBase == "" {
+			cfg.APIBase = "https://api.openai.com/v1"
+		}
+		if cfg.APIKey == "" {
+			cfg.APIKey = os.Getenv("OPENAI_API_KEY")
+		}
+		return &openAIProvider{cfg: cfg, client: client}, nil
+	case "stability":
+		if cfg.APIBase == "" {
+			cfg.APIBase = "https://api.stability.ai"
+		}
+		if cfg.APIKey == "" {
+			cfg.APIKey = os.Getenv("STABILITY_API_KEY")
+		}
+		return &stabilityProvider{cfg: cfg, client: client}, nil
+	case "sdwebui":
+		if cfg.APIBase == "" {
+			cfg.APIBase = "http://127.0.0.1:7860"
+		}
+		return &sdWebUIProvider{cfg: cfg, client: client}, nil
+	default:
+		return nil, fmt.Errorf("unsupported provider type: %s (valid options: openai, stability, sdwebui)", cfg.Type)
+	}
+}
+
+// defaultModel returns the model used when neither the config nor the
+// request names one; sdwebui uses the checkpoint loaded in the server
+func defaultModel(providerType string) string {
+	switch providerType {
+	case "openai":
+		return "gpt-image-1"
+	case "stability":
+		return "core"
+	}
+	return ""
+}
+
+// defaultSize returns the size used when neither the config nor the
+// request sets one
+func defaultSize(providerType string) string {
+	if providerType == "sdwebui" {
+		return "512x512"
+	}
+	return "1024x1024"
+}
+
+// parseSize parses a <width>x<height> size
+func parseSize(size string) (int, int, error) {
+	w, h, ok := strings.Cut(size, "x")
+	width, err1 := strconv.Atoi(w)
+	height, err2 := strconv.Atoi(h)
+	if !ok || err1 != nil || err2 != nil || width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid size %q: must be <width>x<height> or auto", size)
+	}
+	return width, height, nil
+}
+
+// do sends a request and returns the body of a successful response
+func do(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// postJSON sends a JSON request and decodes a JSON response
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	respBody, err := do(client, req)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// openAIProvider talks to the OpenAI images API
+// Any OpenAI-compatible endpoint works via api_base.
+type openAIProvider struct {
+	cfg    ProviderConfig
+	client *http.Client
+}
+
+func (p *openAIProvider) Type() string {
+	return "openai"
+}
+
+func (p *openAIProvider) Generate(ctx context.Context, req GenerateRequest) (*GeneratedImage, error) {
+	body := map[string]interface{}{
+		"model":  req.Model,
+		"prompt": req.Prompt,
+		"n":      1,
+		"size":   req.Size,
+	}
+	if req.Quality != "" {
+		body["quality"] = req.Quality
+	}
+	// DALL·E models answer with URLs unless asked otherwise; gpt-image
+	// models always answer with base64 and reject response_format
+	if strings.HasPrefix(req.Model, "dall-e") {
+		body["response_format"] = "b64_json"
+	}
+
+	headers := map[string]string{}
+	if p.cfg.APIKey != "" {
+		headers["Authorization"] = "Bearer " + p.cfg.APIKey
+	}
+
+	var resp struct {
+		Data []struct {
+			B64JSON       string `json:"b64_json"`
+			URL           string `json:"url"`
+			RevisedPrompt string `json:"revised_prompt"`
+		} `json:"data"`
+	}
+	url := strings.TrimSuffix(p.cfg.APIBase, "/") + "/images/generations"
+	if err := postJSON(ctx, p.client, url, headers, body, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Data) == 0 {
+		return nil, fmt.Errorf("response contained no images")
+	}
+
+	image := &GeneratedImage{RevisedPrompt: resp.Data[0].RevisedPrompt}
+	if resp.Data[0].B64JSON != "" {
+		data, err := base64.StdEncoding.DecodeString(resp.Data[0].B64JSON)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode image: %w", err)
+		}
+		image.Data = data
+		return image, nil
+	}
+	if resp.Data[0].URL == "" {
+		return nil, fmt.Errorf("response contained no image data")
+	}
+
+	download, err := http.NewRequestWithContext(ctx, "GET", resp.Data[0].URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if image.Data, err = do(p.client, download); err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	return image, nil
+}
+
+// stabilityProvider talks to the Stability AI Stable Image API
+// The model selects the endpoint: core, ultra, or an SD3 model (sd3.5-large, ...).
+type stabilityProvider struct {
+	cfg    ProviderConfig
+	client *http.Client
+}
+
+func (p *stabilityProvider) Type() string {
+	return "stability"
+}
+
+// stabilityAspectRatios are the aspect ratios the Stable Image API accepts
+var stabilityAspectRatios = []string{"21:9", "16:9", "3:2", "5:4", "1:1", "4:5", "2:3", "9:16", "9:21"}
+
+// aspectRatio returns the accepted aspect ratio closest to a size
+func aspectRatio(size string) string {
+	width, height, err := parseSize(size)
+	if err != nil {
+		return "1:1"
+	}
+	best, bestDiff := "1:1", math.Inf(1)
+	for _, ratio := range stabilityAspectRatios {
+		w, h, _ := strings.Cut(ratio, ":")
+		rw, _ := strconv.ParseFloat(w, 64)
+		rh, _ := strconv.ParseFloat(h, 64)
+		if diff := math.Abs(math.Log(float64(width)/float64(height)) - math.Log(rw/rh)); diff < bestDiff {
+			best, bestDiff = ratio, diff
+		}
+	}
+	return best
+}
+
+func (p *stabilityProvider) Generate(ctx context.Context, req GenerateRequest) (*GeneratedImage, error) {
+	endpoint := req.Model
+	fields := map[string]string{
+		"prompt":        req.Prompt,
+		"aspect_ratio":  aspectRatio(req.Size),
+		"output_format": "png",
+	}
+	if strings.HasPrefix(req.Model, "sd3") {
+		endpoint = "sd3"
+		fields["model"] = req.Model
+	}
+	if req.NegativePrompt != "" {
+		fields["negative_prompt"] = req.NegativePrompt
+	}
+	if req.Seed != 0 {
+		fields["seed"] = strconv.FormatInt(req.Seed, 10)
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, k := range []string{"prompt", "negative_prompt", "model", "aspect_ratio", "seed", "output_format"} {
+		if v, ok := fields[k]; ok {
+			if err := mw.WriteField(k, v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	url := strings.TrimSuffix(p.cfg.APIBase, "/") + "/v2beta/stable-image/generate/" + endpoint
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, &body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", mw.FormDataContentType())
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+p.cfg.APIKey)
+
+	respBody, err := do(p.client, httpReq)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Image        string `json:"image"`
+		Seed         int64  `json:"seed"`
+		FinishReason string `json:"finish_reason"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if resp.FinishReason != "" && resp.FinishReason != "SUCCESS" {
+		return nil, fmt.Errorf("generation did not succeed: %s", resp.FinishReason)
+	}
+	data, err := base64.StdEncoding.DecodeString(resp.Image)
+	if err != nil || len(data) == 0 {
+		return nil, fmt.Errorf("response contained no image data")
+	}
+	return &GeneratedImage{Data: data, Seed: resp.Seed}, nil
+}
+
+// sdWebUIProvider talks to a local Stable Diffusion server with the
+// AUTOMATIC1111 web UI API (also served by Forge and SD.Next)
+// api_key, if set, is the user:password of its --api-auth.
+type sdWebUIProvider struct {
+	cfg    ProviderConfig
+	client *http.Client
+}
+
+func (p *sdWebUIProvider) Type() string {
+	return "sdwebui"
+}
+
+func (p *sdWebUIProvider) Generate(ctx context.Context, req GenerateRequest) (*GeneratedImage, error) {
+	size := req.Size
+	if size == "auto" {
+		size = defaultSize(p.Type())
+	}
+	width, height, err := parseSize(size)
+	if err != nil {
+		return nil, err
+	}
+
+	seed := req.Seed
+	if seed == 0 {
+		seed = -1
+	}
+	body := map[string]interface{}{
+		"prompt":          req.Prompt,
+		"negative_prompt": req.NegativePrompt,
+		"width":           width,
+		"height":          height,
+		"seed":            seed,
+	}
+	if req.Steps > 0 {
+		body["steps"] = req.Steps
+	}
+	if req.Model != "" {
+		body["override_settings"] = map[string]string{"sd_model_checkpoint": req.Model}
+	}
+
+	headers := map[string]string{}
+	if p.cfg.APIKey != "" {
+		headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(p.cfg.APIKey))
+	}
+
+	var resp struct {
+		Images []string `json:"images"`
+		Info   string   `json:"info"` // JSON of the generation parameters
+	}
+	url := strings.TrimSuffix(p.cfg.APIBase, "/") + "/sdapi/v1/txt2img"
+	if err := postJSON(ctx, p.client, url, headers, body, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Images) == 0 {
+		return nil, fmt.Errorf("response contained no images")
+	}
+	data, err := base64.StdEncoding.DecodeString(resp.Images[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	image := &GeneratedImage{Data: data}
+	var info struct {
+		Seed int64 `json:"seed"`
+	}
+	if json.Unmarshal([]byte(resp.Info), &info) == nil {
+		image.Seed = info.Seed
+	}
+	return image, nil
+}
@@ -0,0 +1,139 @@
+package imagefs
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// formats maps file extensions to the formats images convert to
+var formats = map[string]string{
+	"png":  "png",
+	"jpg":  "jpeg",
+	"jpeg": "jpeg",
+	"gif":  "gif",
+}
+
+// decodeImage decodes a PNG, JPEG or GIF image
+func decodeImage(data []byte) (image.Image, string, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("not a PNG, JPEG or GIF image: %w", err)
+	}
+	return img, format, nil
+}
+
+// encodeImage encodes an image in format (png, jpeg or gif)
+func encodeImage(img image.Image, format string, jpegQuality int) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", format, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// parseVariant parses the name of an image file, image.<ext> or, in
+// resize/, <width>x<height>.<ext> where either dimension may be omitted.
+// It returns the format and the requested dimensions, 0 if omitted.
+func parseVariant(name string, resize bool) (format string, width, height int, err error) {
+	base, ext, ok := strings.Cut(name, ".")
+	format, known := formats[strings.ToLower(ext)]
+	if !ok || !known {
+		return "", 0, 0, fmt.Errorf("unsupported format: %s (valid options: png, jpg, gif)", ext)
+	}
+	if !resize {
+		if base != "image" {
+			return "", 0, 0, fmt.Errorf("unknown file: %s", name)
+		}
+		return format, 0, 0, nil
+	}
+
+	w, h, ok := strings.Cut(base, "x")
+	if !ok || w == "" && h == "" {
+		return "", 0, 0, fmt.Errorf("invalid size %q: must be <width>x<height>, <width>x or x<height>", base)
+	}
+	for _, dim := range []struct {
+		s string
+		v *int
+	}{{w, &width}, {h, &height}} {
+		if dim.s == "" {
+			continue
+		}
+		if *dim.v, err = strconv.Atoi(dim.s); err != nil || *dim.v <= 0 {
+			return "", 0, 0, fmt.Errorf("invalid size %q: dimensions must be positive integers", base)
+		}
+	}
+	return format, width, height, nil
+}
+
+// fitSize scales a width x height image to fit within maxWidth x maxHeight,
+// keeping its aspect ratio; a 0 bound leaves that dimension free
+func fitSize(width, height, maxWidth, maxHeight int) (int, int) {
+	scale := math.Inf(1)
+	if maxWidth > 0 {
+		scale = float64(maxWidth) / float64(width)
+	}
+	if maxHeight > 0 {
+		scale = math.Min(scale, float64(maxHeight)/float64(height))
+	}
+	w := int(math.Max(1, math.Round(float64(width)*scale)))
+	h := int(math.Max(1, math.Round(float64(height)*scale)))
+	return w, h
+}
+
+// resizeImage resizes an image by averaging the source pixels covered by
+// each destination pixel; upscaling repeats pixels
+func resizeImage(img image.Image, width, height int) *image.RGBA {
+	b := img.Bounds()
+	src, ok := img.(*image.RGBA)
+	if !ok || b.Min != (image.Point{}) {
+		src = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	}
+	sw, sh := b.Dx(), b.Dy()
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := y * sh / height
+		y1 := max((y+1)*sh/height, y0+1)
+		for x := 0; x < width; x++ {
+			x0 := x * sw / width
+			x1 := max((x+1)*sw/width, x0+1)
+
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride+x0*4 : sy*src.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += int(row[i])
+					sum[1] += int(row[i+1])
+					sum[2] += int(row[i+2])
+					sum[3] += int(row[i+3])
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			off := y*dst.Stride + x*4
+			for c := 0; c < 4; c++ {
+				dst.Pix[off+c] = uint8((sum[c] + n/2) / n)
+			}
+		}
+	}
+	return dst
+}
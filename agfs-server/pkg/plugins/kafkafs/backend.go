@@ -0,0 +1,238 @@
+package kafkafs
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Special offsets used when resetting consumer group offsets
+// The values match Kafka's ListOffsets conventions.
+const (
+	OffsetLatest   int64 = -1 // Skip to the end of the partition
+	OffsetEarliest int64 = -2 // Rewind to the beginning of the partition
+)
+
+// AllPartitions selects every partition of a topic in SetOffset
+const AllPartitions = -1
+
+var errTopicNotFound = errors.New("topic not found")
+
+// Message is a single record returned by the consume file
+type Message struct {
+	Topic     string    `json:"topic"`
+	Partition int       `json:"partition"`
+	Offset    int64     `json:"offset"`
+	Key       string    `json:"key,omitempty"`
+	Value     string    `json:"value"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// PartitionOffset describes the consumer group position on one partition
+type PartitionOffset struct {
+	Partition int   `json:"partition"`
+	Committed int64 `json:"committed"` // -1 if the group has not committed yet
+	Latest    int64 `json:"latest"`    // Offset of the next message to be produced
+	Lag       int64 `json:"lag"`
+}
+
+// KafkaBackend defines the interface for topic storage
+type KafkaBackend interface {
+	// Initialize initializes the backend with configuration
+	Initialize(config map[string]interface{}) error
+
+	// Close closes the backend connection
+	Close() error
+
+	// GetType returns the backend type name
+	GetType() string
+
+	// ListTopics returns all non-internal topics, sorted by name
+	ListTopics() ([]string, error)
+
+	// TopicExists checks if a topic exists
+	TopicExists(topic string) (bool, error)
+
+	// CreateTopic creates a new topic
+	CreateTopic(topic string) error
+
+	// DeleteTopic deletes a topic and all its messages
+	DeleteTopic(topic string) error
+
+	// Produce appends a message to a topic
+	Produce(topic string, key, value []byte) error
+
+	// Consume returns the next message for the consumer group and commits its offset
+	// Returns false if no message arrived within the poll timeout.
+	Consume(topic string) (Message, bool, error)
+
+	// GetOffsets returns the consumer group offsets for every partition of a topic
+	GetOffsets(topic string) ([]PartitionOffset, error)
+
+	// SetOffset moves the consumer group offset of a partition (or AllPartitions)
+	// offset may be OffsetEarliest or OffsetLatest.
+	SetOffset(topic string, partition int, offset int64) error
+}
+
+// MemoryBackend is an in-process single-partition broker
+// It is intended for development and tests where no Kafka cluster is available.
+type MemoryBackend struct {
+	topics  map[string][]Message
+	offsets map[string]int64 // topic -> next offset to consume for the group
+	mu      sync.Mutex
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		topics:  make(map[string][]Message),
+		offsets: make(map[string]int64),
+	}
+}
+
+func (b *MemoryBackend) Initialize(config map[string]interface{}) error {
+	return nil
+}
+
+func (b *MemoryBackend) Close() error {
+	return nil
+}
+
+func (b *MemoryBackend) GetType() string {
+	return "memory"
+}
+
+func (b *MemoryBackend) ListTopics() ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	topics := make([]string, 0, len(b.topics))
+	for name := range b.topics {
+		topics = append(topics, name)
+	}
+	sort.Strings(topics)
+	return topics, nil
+}
+
+func (b *MemoryBackend) TopicExists(topic string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	_, ok := b.topics[topic]
+	return ok, nil
+}
+
+func (b *MemoryBackend) CreateTopic(topic string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.topics[topic]; ok {
+		return fmt.Errorf("topic already exists: %s", topic)
+	}
+	b.topics[topic] = nil
+	return nil
+}
+
+func (b *MemoryBackend) DeleteTopic(topic string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.topics[topic]; !ok {
+		return errTopicNotFound
+	}
+	delete(b.topics, topic)
+	delete(b.offsets, topic)
+	return nil
+}
+
+func (b *MemoryBackend) Produce(topic string, key, value []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Mirror Kafka's auto.create.topics.enable default
+	msgs := b.topics[topic]
+	b.topics[topic] = append(msgs, Message{
+		Topic:     topic,
+		Partition: 0,
+		Offset:    int64(len(msgs)),
+		Key:       string(key),
+		Value:     string(value),
+		Timestamp: time.Now(),
+	})
+	return nil
+}
+
+func (b *MemoryBackend) Consume(topic string) (Message, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	msgs, ok := b.topics[topic]
+	if !ok {
+		return Message{}, false, errTopicNotFound
+	}
+	next := b.offsets[topic]
+	if next >= int64(len(msgs)) {
+		return Message{}, false, nil
+	}
+	b.offsets[topic] = next + 1
+	return msgs[next], true, nil
+}
+
+func (b *MemoryBackend) GetOffsets(topic string) ([]PartitionOffset, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	msgs, ok := b.topics[topic]
+	if !ok {
+		return nil, errTopicNotFound
+	}
+	committed, ok := b.offsets[topic]
+	if !ok {
+		committed = -1
+	}
+	latest := int64(len(msgs))
+	return []PartitionOffset{{
+		Partition: 0,
+		Committed: committed,
+		Latest:    latest,
+		Lag:       computeLag(committed, 0, latest),
+	}}, nil
+}
+
+func (b *MemoryBackend) SetOffset(topic string, partition int, offset int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	msgs, ok := b.topics[topic]
+	if !ok {
+		return errTopicNotFound
+	}
+	if partition != 0 && partition != AllPartitions {
+		return fmt.Errorf("partition %d does not exist", partition)
+	}
+
+	switch offset {
+	case OffsetEarliest:
+		offset = 0
+	case OffsetLatest:
+		offset = int64(len(msgs))
+	}
+	if offset < 0 || offset > int64(len(msgs)) {
+		return fmt.Errorf("offset %d out of range [0, %d]", offset, len(msgs))
+	}
+	b.offsets[topic] = offset
+	return nil
+}
+
+// computeLag returns how many messages the group still has to consume
+// A group without a committed offset starts from the first available offset.
+func computeLag(committed, first, latest int64) int64 {
+	if committed < 0 {
+		committed = first
+	}
+	if lag := latest - committed; lag > 0 {
+		return lag
+	}
+	return 0
+}
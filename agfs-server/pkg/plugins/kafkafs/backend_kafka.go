@@ -0,0 +1,466 @@
+package kafkafs
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	log "github.com/sirupsen/logrus"
+)
+
+// kafkaOpTimeout bounds admin and produce requests
+const kafkaOpTimeout = 10 * time.Second
+
+// BrokerBackend implements KafkaBackend against a real Kafka cluster
+// Each topic gets its own consumer group reader, created lazily on first consume.
+type BrokerBackend struct {
+	brokers           []string
+	groupID           string
+	startOffset       int64
+	pollTimeout       time.Duration
+	partitions        int
+	replicationFactor int
+
+	client  *kafka.Client
+	writer  *kafka.Writer
+	dialer  *kafka.Dialer
+	readers map[string]*kafka.Reader
+	mu      sync.Mutex // Protects readers
+}
+
+func NewBrokerBackend() *BrokerBackend {
+	return &BrokerBackend{
+		readers: make(map[string]*kafka.Reader),
+	}
+}
+
+func (b *BrokerBackend) Initialize(cfg map[string]interface{}) error {
+	brokers, err := parseBrokers(cfg)
+	if err != nil {
+		return err
+	}
+	b.brokers = brokers
+	b.groupID = config.GetStringConfig(cfg, "group_id", "agfs")
+	b.partitions = config.GetIntConfig(cfg, "partitions", 1)
+	b.replicationFactor = config.GetIntConfig(cfg, "replication_factor", 1)
+
+	b.startOffset = kafka.FirstOffset
+	if config.GetStringConfig(cfg, "start_offset", "earliest") == "latest" {
+		b.startOffset = kafka.LastOffset
+	}
+
+	b.pollTimeout, err = time.ParseDuration(config.GetStringConfig(cfg, "poll_timeout", "2s"))
+	if err != nil {
+		return fmt.Errorf("invalid poll_timeout: %w", err)
+	}
+
+	var tlsConfig *tls.Config
+	if config.GetBoolConfig(cfg, "enable_tls", false) {
+		tlsConfig = &tls.Config{
+			InsecureSkipVerify: config.GetBoolConfig(cfg, "tls_skip_verify", false),
+		}
+	}
+
+	var mechanism sasl.Mechanism
+	if username := config.GetStringConfig(cfg, "sasl_username", ""); username != "" {
+		mechanism = plain.Mechanism{
+			Username: username,
+			Password: config.GetStringConfig(cfg, "sasl_password", ""),
+		}
+	}
+
+	transport := &kafka.Transport{TLS: tlsConfig, SASL: mechanism}
+	b.client = &kafka.Client{
+		Addr:      kafka.TCP(b.brokers...),
+		Timeout:   kafkaOpTimeout,
+		Transport: transport,
+	}
+	b.writer = &kafka.Writer{
+		Addr:                   kafka.TCP(b.brokers...),
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireAll,
+		AllowAutoTopicCreation: true,
+		Transport:              transport,
+	}
+	b.dialer = &kafka.Dialer{
+		Timeout:       kafkaOpTimeout,
+		DualStack:     true,
+		TLS:           tlsConfig,
+		SASLMechanism: mechanism,
+	}
+
+	// Fail fast if the cluster is unreachable
+	ctx, cancel := context.WithTimeout(context.Background(), kafkaOpTimeout)
+	defer cancel()
+	if _, err := b.client.Metadata(ctx, &kafka.MetadataRequest{}); err != nil {
+		return fmt.Errorf("failed to connect to kafka brokers %v: %w", b.brokers, err)
+	}
+
+	log.Infof("[kafkafs] Connected to Kafka brokers %v (group: %s)", b.brokers, b.groupID)
+	return nil
+}
+
+// parseBrokers accepts either a comma-separated string or a list of addresses
+func parseBrokers(cfg map[string]interface{}) ([]string, error) {
+	var brokers []string
+	switch v := cfg["brokers"].(type) {
+	case string:
+		for _, addr := range strings.Split(v, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				brokers = append(brokers, addr)
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			addr, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("brokers must be a list of strings")
+			}
+			brokers = append(brokers, addr)
+		}
+	case []string:
+		brokers = v
+	case nil:
+	default:
+		return nil, fmt.Errorf("brokers must be a string or a list of strings")
+	}
+	if len(brokers) == 0 {
+		return nil, fmt.Errorf("brokers is required for the kafka backend")
+	}
+	return brokers, nil
+}
+
+func (b *BrokerBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var errs []error
+	for topic, r := range b.readers {
+		if err := r.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close reader for %s: %w", topic, err))
+		}
+		delete(b.readers, topic)
+	}
+	if b.writer != nil {
+		if err := b.writer.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (b *BrokerBackend) GetType() string {
+	return "kafka"
+}
+
+func (b *BrokerBackend) metadata(topics ...string) (*kafka.MetadataResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), kafkaOpTimeout)
+	defer cancel()
+
+	resp, err := b.client.Metadata(ctx, &kafka.MetadataRequest{Topics: topics})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch metadata: %w", err)
+	}
+	return resp, nil
+}
+
+func (b *BrokerBackend) ListTopics() ([]string, error) {
+	resp, err := b.metadata()
+	if err != nil {
+		return nil, err
+	}
+
+	topics := make([]string, 0, len(resp.Topics))
+	for _, t := range resp.Topics {
+		if t.Internal || strings.HasPrefix(t.Name, "__") {
+			continue
+		}
+		topics = append(topics, t.Name)
+	}
+	sort.Strings(topics)
+	return topics, nil
+}
+
+func (b *BrokerBackend) TopicExists(topic string) (bool, error) {
+	_, err := b.partitionIDs(topic)
+	if errors.Is(err, errTopicNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// partitionIDs returns the partition IDs of a topic
+func (b *BrokerBackend) partitionIDs(topic string) ([]int, error) {
+	resp, err := b.metadata(topic)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range resp.Topics {
+		if t.Name != topic {
+			continue
+		}
+		if errors.Is(t.Error, kafka.UnknownTopicOrPartition) {
+			return nil, errTopicNotFound
+		} else if t.Error != nil {
+			return nil, fmt.Errorf("failed to describe topic %s: %w", topic, t.Error)
+		}
+		ids := make([]int, 0, len(t.Partitions))
+		for _, p := range t.Partitions {
+			ids = append(ids, p.ID)
+		}
+		sort.Ints(ids)
+		return ids, nil
+	}
+	return nil, errTopicNotFound
+}
+
+func (b *BrokerBackend) CreateTopic(topic string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), kafkaOpTimeout)
+	defer cancel()
+
+	resp, err := b.client.CreateTopics(ctx, &kafka.CreateTopicsRequest{
+		Topics: []kafka.TopicConfig{{
+			Topic:             topic,
+			NumPartitions:     b.partitions,
+			ReplicationFactor: b.replicationFactor,
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create topic: %w", err)
+	}
+	if err := resp.Errors[topic]; err != nil {
+		if errors.Is(err, kafka.TopicAlreadyExists) {
+			return fmt.Errorf("topic already exists: %s", topic)
+		}
+		return fmt.Errorf("failed to create topic: %w", err)
+	}
+	return nil
+}
+
+func (b *BrokerBackend) DeleteTopic(topic string) error {
+	b.closeReader(topic)
+
+	ctx, cancel := context.WithTimeout(context.Background(), kafkaOpTimeout)
+	defer cancel()
+
+	resp, err := b.client.DeleteTopics(ctx, &kafka.DeleteTopicsRequest{Topics: []string{topic}})
+	if err != nil {
+		return fmt.Errorf("failed to delete topic: %w", err)
+	}
+	if err := resp.Errors[topic]; err != nil {
+		if errors.Is(err, kafka.UnknownTopicOrPartition) {
+			return errTopicNotFound
+		}
+		return fmt.Errorf("failed to delete topic: %w", err)
+	}
+	return nil
+}
+
+func (b *BrokerBackend) Produce(topic string, key, value []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), kafkaOpTimeout)
+	defer cancel()
+
+	if err := b.writer.WriteMessages(ctx, kafka.Message{Topic: topic, Key: key, Value: value}); err != nil {
+		return fmt.Errorf("failed to produce message: %w", err)
+	}
+	return nil
+}
+
+// reader returns the consumer group reader for a topic, creating it if needed
+func (b *BrokerBackend) reader(topic string) *kafka.Reader {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if r, ok := b.readers[topic]; ok {
+		return r
+	}
+	r := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     b.brokers,
+		GroupID:     b.groupID,
+		Topic:       topic,
+		Dialer:      b.dialer,
+		StartOffset: b.startOffset,
+		MaxWait:     b.pollTimeout,
+	})
+	b.readers[topic] = r
+	return r
+}
+
+func (b *BrokerBackend) closeReader(topic string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if r, ok := b.readers[topic]; ok {
+		if err := r.Close(); err != nil {
+			log.Warnf("[kafkafs] Failed to close reader for %s: %v", topic, err)
+		}
+		delete(b.readers, topic)
+	}
+}
+
+func (b *BrokerBackend) Consume(topic string) (Message, bool, error) {
+	r := b.reader(topic)
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.pollTimeout)
+	defer cancel()
+
+	m, err := r.FetchMessage(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Message{}, false, nil
+	} else if err != nil {
+		return Message{}, false, fmt.Errorf("failed to consume message: %w", err)
+	}
+
+	// Commit right away so every read hands out a new message, like queuefs dequeue
+	commitCtx, commitCancel := context.WithTimeout(context.Background(), kafkaOpTimeout)
+	defer commitCancel()
+	if err := r.CommitMessages(commitCtx, m); err != nil {
+		return Message{}, false, fmt.Errorf("failed to commit offset: %w", err)
+	}
+
+	return Message{
+		Topic:     m.Topic,
+		Partition: m.Partition,
+		Offset:    m.Offset,
+		Key:       string(m.Key),
+		Value:     string(m.Value),
+		Timestamp: m.Time,
+	}, true, nil
+}
+
+// listOffsets returns the first and next offsets of each partition
+func (b *BrokerBackend) listOffsets(topic string, partitions []int) (map[int]kafka.PartitionOffsets, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), kafkaOpTimeout)
+	defer cancel()
+
+	reqs := make([]kafka.OffsetRequest, 0, len(partitions)*2)
+	for _, p := range partitions {
+		reqs = append(reqs, kafka.FirstOffsetOf(p), kafka.LastOffsetOf(p))
+	}
+	resp, err := b.client.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+		Topics: map[string][]kafka.OffsetRequest{topic: reqs},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list offsets: %w", err)
+	}
+
+	result := make(map[int]kafka.PartitionOffsets)
+	for _, po := range resp.Topics[topic] {
+		if po.Error != nil {
+			return nil, fmt.Errorf("failed to list offsets for partition %d: %w", po.Partition, po.Error)
+		}
+		result[po.Partition] = po
+	}
+	return result, nil
+}
+
+func (b *BrokerBackend) GetOffsets(topic string) ([]PartitionOffset, error) {
+	partitions, err := b.partitionIDs(topic)
+	if err != nil {
+		return nil, err
+	}
+	bounds, err := b.listOffsets(topic, partitions)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), kafkaOpTimeout)
+	defer cancel()
+	resp, err := b.client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{
+		GroupID: b.groupID,
+		Topics:  map[string][]int{topic: partitions},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch group offsets: %w", err)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("failed to fetch group offsets: %w", resp.Error)
+	}
+
+	committed := make(map[int]int64)
+	for _, p := range resp.Topics[topic] {
+		committed[p.Partition] = p.CommittedOffset
+	}
+
+	offsets := make([]PartitionOffset, 0, len(partitions))
+	for _, p := range partitions {
+		c, ok := committed[p]
+		if !ok {
+			c = -1
+		}
+		offsets = append(offsets, PartitionOffset{
+			Partition: p,
+			Committed: c,
+			Latest:    bounds[p].LastOffset,
+			Lag:       computeLag(c, bounds[p].FirstOffset, bounds[p].LastOffset),
+		})
+	}
+	return offsets, nil
+}
+
+func (b *BrokerBackend) SetOffset(topic string, partition int, offset int64) error {
+	partitions, err := b.partitionIDs(topic)
+	if err != nil {
+		return err
+	}
+	if partition != AllPartitions {
+		found := false
+		for _, p := range partitions {
+			if p == partition {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("partition %d does not exist", partition)
+		}
+		partitions = []int{partition}
+	}
+
+	bounds, err := b.listOffsets(topic, partitions)
+	if err != nil {
+		return err
+	}
+
+	commits := make([]kafka.OffsetCommit, 0, len(partitions))
+	for _, p := range partitions {
+		target := offset
+		switch offset {
+		case OffsetEarliest:
+			target = bounds[p].FirstOffset
+		case OffsetLatest:
+			target = bounds[p].LastOffset
+		}
+		commits = append(commits, kafka.OffsetCommit{Partition: p, Offset: target})
+	}
+
+	// Offsets can only be committed from outside the group while it has no members,
+	// so leave the group first. The reader is recreated on the next consume.
+	b.closeReader(topic)
+
+	ctx, cancel := context.WithTimeout(context.Background(), kafkaOpTimeout)
+	defer cancel()
+	resp, err := b.client.OffsetCommit(ctx, &kafka.OffsetCommitRequest{
+		GroupID:      b.groupID,
+		GenerationID: -1,
+		Topics:       map[string][]kafka.OffsetCommit{topic: commits},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to commit offsets: %w", err)
+	}
+	for _, p := range resp.Topics[topic] {
+		if p.Error != nil {
+			return fmt.Errorf("failed to commit offset for partition %d: %w", p.Partition, p.Error)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,686 @@
+package kafkafs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PluginName = "kafkafs" // Name of this plugin
+)
+
+// Meta values for KafkaFS plugin
+const (
+	MetaValueTopic   = "topic"   // Topic directory
+	MetaValueControl = "control" // Topic control files (produce, consume, offsets)
+)
+
+// Control files available inside each topic directory
+const (
+	fileProduce = "produce"
+	fileConsume = "consume"
+	fileOffsets = "offsets"
+)
+
+// Kafka topic names are limited to ASCII alphanumerics, '.', '_' and '-'
+var topicNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,249}$`)
+
+// KafkaFSPlugin exposes Kafka topics through a file system interface
+// Each topic is a directory containing control files:
+//
+//	/<topic>/produce - write a message to the topic
+//	/<topic>/consume - read the next message for the consumer group
+//	/<topic>/offsets - read or reset the consumer group offsets
+//
+// Supports multiple backends:
+//   - kafka (default): A Kafka cluster (or any Kafka-compatible broker)
+//   - memory: In-process single-partition broker for development and tests
+type KafkaFSPlugin struct {
+	backend  KafkaBackend
+	groupID  string
+	metadata plugin.PluginMetadata
+}
+
+// NewKafkaFSPlugin creates a new Kafka plugin
+func NewKafkaFSPlugin() *KafkaFSPlugin {
+	return &KafkaFSPlugin{
+		metadata: plugin.PluginMetadata{
+			Name:        PluginName,
+			Version:     "1.0.0",
+			Description: "Produce and consume Kafka topics as files with consumer group offsets",
+			Author:      "AGFS Server",
+		},
+	}
+}
+
+func (k *KafkaFSPlugin) Name() string {
+	return k.metadata.Name
+}
+
+func (k *KafkaFSPlugin) Validate(cfg map[string]interface{}) error {
+	allowedKeys := []string{
+		"backend", "mount_path", "brokers", "group_id", "start_offset", "poll_timeout",
+		"partitions", "replication_factor", "enable_tls", "tls_skip_verify",
+		"sasl_username", "sasl_password",
+	}
+	if err := config.ValidateOnlyKnownKeys(cfg, allowedKeys); err != nil {
+		return err
+	}
+
+	backendType := config.GetStringConfig(cfg, "backend", "kafka")
+	if backendType != "kafka" && backendType != "memory" {
+		return fmt.Errorf("unsupported backend: %s (valid options: kafka, memory)", backendType)
+	}
+
+	for _, key := range []string{"group_id", "start_offset", "poll_timeout", "sasl_username", "sasl_password"} {
+		if err := config.ValidateStringType(cfg, key); err != nil {
+			return err
+		}
+	}
+	for _, key := range []string{"partitions", "replication_factor"} {
+		if err := config.ValidateIntType(cfg, key); err != nil {
+			return err
+		}
+	}
+	for _, key := range []string{"enable_tls", "tls_skip_verify"} {
+		if err := config.ValidateBoolType(cfg, key); err != nil {
+			return err
+		}
+	}
+
+	if start := config.GetStringConfig(cfg, "start_offset", "earliest"); start != "earliest" && start != "latest" {
+		return fmt.Errorf("invalid start_offset: %s (valid options: earliest, latest)", start)
+	}
+	if timeout, err := time.ParseDuration(config.GetStringConfig(cfg, "poll_timeout", "2s")); err != nil || timeout <= 0 {
+		return fmt.Errorf("invalid poll_timeout: must be a positive duration such as \"2s\"")
+	}
+
+	if backendType == "kafka" {
+		if _, err := parseBrokers(cfg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (k *KafkaFSPlugin) Initialize(cfg map[string]interface{}) error {
+	backendType := config.GetStringConfig(cfg, "backend", "kafka")
+
+	var backend KafkaBackend
+	switch backendType {
+	case "kafka":
+		backend = NewBrokerBackend()
+	case "memory":
+		backend = NewMemoryBackend()
+	default:
+		return fmt.Errorf("unsupported backend: %s", backendType)
+	}
+
+	if err := backend.Initialize(cfg); err != nil {
+		return fmt.Errorf("failed to initialize %s backend: %w", backendType, err)
+	}
+
+	k.backend = backend
+	k.groupID = config.GetStringConfig(cfg, "group_id", "agfs")
+
+	log.Infof("[kafkafs] Initialized with backend: %s", backendType)
+	return nil
+}
+
+func (k *KafkaFSPlugin) GetFileSystem() filesystem.FileSystem {
+	return &kafkaFS{plugin: k}
+}
+
+func (k *KafkaFSPlugin) GetReadme() string {
+	return `KafkaFS Plugin - Kafka Topics as Files
+
+This plugin lets agents produce to and consume from Kafka topics through
+a file system interface, so they can integrate with existing event buses.
+
+STRUCTURE:
+  /kafkafs/
+    README          - This documentation
+    <topic>/        - A Kafka topic
+      produce       - Write-only: each write is sent as one message
+      consume       - Read-only: each read returns the next message
+      offsets       - Consumer group offsets (read to inspect, write to reset)
+
+WORKFLOW:
+  1. Create a topic (or use an existing one):
+     mkdir /kafkafs/events
+
+  2. Produce messages:
+     echo "user signed up" > /kafkafs/events/produce
+
+  3. Consume messages (one message per read):
+     cat /kafkafs/events/consume
+     {"topic":"events","partition":0,"offset":0,"value":"user signed up","timestamp":"..."}
+
+     An empty object {} is returned when no message arrives within poll_timeout.
+
+  4. Inspect consumer group progress:
+     cat /kafkafs/events/offsets
+
+  5. Replay or skip messages:
+     echo earliest > /kafkafs/events/offsets    # rewind all partitions
+     echo latest > /kafkafs/events/offsets      # skip to the end
+     echo 42 > /kafkafs/events/offsets          # all partitions to offset 42
+     echo 1:42 > /kafkafs/events/offsets        # partition 1 to offset 42
+
+  6. Delete the topic:
+     rm -rf /kafkafs/events
+
+CONSUMER GROUPS:
+  All reads of a mount share one consumer group (group_id). Each message is
+  committed as soon as it is returned, so concurrent readers never see the
+  same message twice. Mount the plugin again with a different group_id to
+  consume the same topics independently.
+
+CONFIGURATION:
+
+  Kafka cluster:
+  [plugins.kafkafs]
+  enabled = true
+  path = "/kafkafs"
+
+    [plugins.kafkafs.config]
+    brokers = "localhost:9092"
+    group_id = "agents"
+    start_offset = "earliest"   # where new groups start: earliest or latest
+    poll_timeout = "2s"         # how long a consume read waits for a message
+
+  With TLS and SASL/PLAIN:
+    [plugins.kafkafs.config]
+    brokers = "broker1:9093,broker2:9093"
+    enable_tls = true
+    sasl_username = "user"
+    sasl_password = "secret"
+
+  In-memory (no Kafka required, for development):
+    [plugins.kafkafs.config]
+    backend = "memory"
+`
+}
+
+func (k *KafkaFSPlugin) GetConfigParams() []plugin.ConfigParameter {
+	return []plugin.ConfigParameter{
+		{
+			Name:        "backend",
+			Type:        "string",
+			Required:    false,
+			Default:     "kafka",
+			Description: "Backend type (kafka, memory)",
+			Enum:        []string{"kafka", "memory"},
+		},
+		{
+			Name:        "brokers",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Comma-separated list of Kafka broker addresses (required for kafka backend)",
+		},
+		{
+			Name:        "group_id",
+			Type:        "string",
+			Required:    false,
+			Default:     "agfs",
+			Description: "Consumer group used by the consume file",
+		},
+		{
+			Name:        "start_offset",
+			Type:        "string",
+			Required:    false,
+			Default:     "earliest",
+			Description: "Where a group without committed offsets starts (earliest, latest)",
+			Enum:        []string{"earliest", "latest"},
+		},
+		{
+			Name:        "poll_timeout",
+			Type:        "string",
+			Required:    false,
+			Default:     "2s",
+			Description: "How long a consume read waits for a message",
+		},
+		{
+			Name:        "partitions",
+			Type:        "int",
+			Required:    false,
+			Default:     "1",
+			Description: "Number of partitions for topics created with mkdir",
+		},
+		{
+			Name:        "replication_factor",
+			Type:        "int",
+			Required:    false,
+			Default:     "1",
+			Description: "Replication factor for topics created with mkdir",
+		},
+		{
+			Name:        "enable_tls",
+			Type:        "bool",
+			Required:    false,
+			Default:     "false",
+			Description: "Enable TLS for broker connections",
+		},
+		{
+			Name:        "tls_skip_verify",
+			Type:        "bool",
+			Required:    false,
+			Default:     "false",
+			Description: "Skip TLS certificate verification",
+		},
+		{
+			Name:        "sasl_username",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "SASL/PLAIN username",
+		},
+		{
+			Name:        "sasl_password",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "SASL/PLAIN password",
+			Secret:      true,
+		},
+	}
+}
+
+func (k *KafkaFSPlugin) Shutdown() error {
+	if k.backend != nil {
+		return k.backend.Close()
+	}
+	return nil
+}
+
+// kafkaFS implements the FileSystem interface for Kafka operations
+type kafkaFS struct {
+	plugin *KafkaFSPlugin
+}
+
+// parseKafkaPath splits a path into topic and control file
+// "/" returns empty topic, "/<topic>" returns empty file.
+func parseKafkaPath(p string) (topic string, file string, err error) {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return "", "", nil
+	}
+
+	parts := strings.Split(p, "/")
+	if len(parts) > 2 {
+		return "", "", filesystem.NewNotFoundError("stat", "/"+p)
+	}
+	if !topicNamePattern.MatchString(parts[0]) {
+		return "", "", filesystem.NewInvalidArgumentError("topic", parts[0], "must match [A-Za-z0-9._-]")
+	}
+	if len(parts) == 1 {
+		return parts[0], "", nil
+	}
+
+	switch parts[1] {
+	case fileProduce, fileConsume, fileOffsets:
+		return parts[0], parts[1], nil
+	}
+	return "", "", filesystem.NewNotFoundError("stat", "/"+p)
+}
+
+// mapBackendError converts backend sentinel errors into filesystem errors
+func mapBackendError(err error, op, path string) error {
+	if errors.Is(err, errTopicNotFound) {
+		return filesystem.NewNotFoundError(op, path)
+	}
+	return err
+}
+
+func (kfs *kafkaFS) requireTopic(op, path, topic string) error {
+	exists, err := kfs.plugin.backend.TopicExists(topic)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return filesystem.NewNotFoundError(op, path)
+	}
+	return nil
+}
+
+func (kfs *kafkaFS) Create(path string) error {
+	topic, file, err := parseKafkaPath(path)
+	if err != nil {
+		return err
+	}
+	if file == "" {
+		return filesystem.NewPermissionDeniedError("create", path, "only control files exist in kafkafs")
+	}
+	// Control files are virtual
+	return kfs.requireTopic("create", path, topic)
+}
+
+func (kfs *kafkaFS) Mkdir(path string, perm uint32) error {
+	topic, file, err := parseKafkaPath(path)
+	if err != nil {
+		return err
+	}
+	if topic == "" || file != "" {
+		return filesystem.NewAlreadyExistsError("directory", path)
+	}
+
+	exists, err := kfs.plugin.backend.TopicExists(topic)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return filesystem.NewAlreadyExistsError("topic", path)
+	}
+	return kfs.plugin.backend.CreateTopic(topic)
+}
+
+func (kfs *kafkaFS) Remove(path string) error {
+	topic, file, err := parseKafkaPath(path)
+	if err != nil {
+		return err
+	}
+	if file != "" {
+		return filesystem.NewPermissionDeniedError("remove", path, "cannot remove control files")
+	}
+	if topic == "" {
+		return filesystem.NewPermissionDeniedError("remove", path, "cannot remove root")
+	}
+	return fmt.Errorf("cannot remove topic with Remove: use RemoveAll instead")
+}
+
+func (kfs *kafkaFS) RemoveAll(path string) error {
+	topic, file, err := parseKafkaPath(path)
+	if err != nil {
+		return err
+	}
+	if topic == "" || file != "" {
+		return filesystem.NewPermissionDeniedError("remove", path, "only topic directories can be removed")
+	}
+	return mapBackendError(kfs.plugin.backend.DeleteTopic(topic), "remove", path)
+}
+
+func (kfs *kafkaFS) Read(path string, offset int64, size int64) ([]byte, error) {
+	if path == "/README" {
+		return plugin.ApplyRangeRead([]byte(kfs.plugin.GetReadme()), offset, size)
+	}
+
+	topic, file, err := parseKafkaPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if file == "" {
+		return nil, fmt.Errorf("is a directory: %s", path)
+	}
+
+	var data []byte
+	switch file {
+	case fileConsume:
+		data, err = kfs.consume(topic)
+	case fileOffsets:
+		data, err = kfs.offsets(topic)
+	default:
+		return nil, filesystem.NewPermissionDeniedError("read", path, "write-only file")
+	}
+	if err != nil {
+		return nil, mapBackendError(err, "read", path)
+	}
+
+	return plugin.ApplyRangeRead(data, offset, size)
+}
+
+func (kfs *kafkaFS) Write(path string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	topic, file, err := parseKafkaPath(path)
+	if err != nil {
+		return 0, err
+	}
+	if file == "" {
+		return 0, fmt.Errorf("is a directory: %s", path)
+	}
+
+	switch file {
+	case fileProduce:
+		if err := kfs.plugin.backend.Produce(topic, nil, data); err != nil {
+			return 0, err
+		}
+		return int64(len(data)), nil
+	case fileOffsets:
+		partition, target, err := parseOffsetSpec(string(data))
+		if err != nil {
+			return 0, err
+		}
+		if err := kfs.plugin.backend.SetOffset(topic, partition, target); err != nil {
+			return 0, mapBackendError(err, "write", path)
+		}
+		return int64(len(data)), nil
+	default:
+		return 0, filesystem.NewPermissionDeniedError("write", path, "read-only file")
+	}
+}
+
+// parseOffsetSpec parses an offsets control write
+// Accepted forms: "earliest", "latest", "<offset>", "<partition>:<earliest|latest|offset>"
+func parseOffsetSpec(s string) (partition int, offset int64, err error) {
+	s = strings.TrimSpace(s)
+	partition = AllPartitions
+
+	if before, after, found := strings.Cut(s, ":"); found {
+		partition, err = strconv.Atoi(strings.TrimSpace(before))
+		if err != nil || partition < 0 {
+			return 0, 0, filesystem.NewInvalidArgumentError("partition", before, "must be a non-negative integer")
+		}
+		s = strings.TrimSpace(after)
+	}
+
+	switch s {
+	case "earliest":
+		return partition, OffsetEarliest, nil
+	case "latest":
+		return partition, OffsetLatest, nil
+	}
+
+	offset, err = strconv.ParseInt(s, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, 0, filesystem.NewInvalidArgumentError("offset", s, "expected earliest, latest, <offset> or <partition>:<offset>")
+	}
+	return partition, offset, nil
+}
+
+func (kfs *kafkaFS) consume(topic string) ([]byte, error) {
+	msg, found, err := kfs.plugin.backend.Consume(topic)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		// Return empty JSON object instead of error when no message is available
+		return []byte("{}"), nil
+	}
+	return json.Marshal(msg)
+}
+
+func (kfs *kafkaFS) offsets(topic string) ([]byte, error) {
+	partitions, err := kfs.plugin.backend.GetOffsets(topic)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(map[string]interface{}{
+		"group":      kfs.plugin.groupID,
+		"topic":      topic,
+		"partitions": partitions,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func topicInfo(name string) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    name,
+		Size:    0,
+		Mode:    0755,
+		ModTime: time.Now(),
+		IsDir:   true,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: MetaValueTopic},
+	}
+}
+
+func controlInfo(name string) filesystem.FileInfo {
+	mode := uint32(0444)
+	switch name {
+	case fileProduce:
+		mode = 0222
+	case fileOffsets:
+		mode = 0644
+	}
+	return filesystem.FileInfo{
+		Name:    name,
+		Size:    0,
+		Mode:    mode,
+		ModTime: time.Now(),
+		IsDir:   false,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: MetaValueControl},
+	}
+}
+
+func (kfs *kafkaFS) ReadDir(path string) ([]filesystem.FileInfo, error) {
+	topic, file, err := parseKafkaPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if file != "" {
+		return nil, filesystem.NewNotDirectoryError(path)
+	}
+
+	if topic == "" {
+		readme := kfs.plugin.GetReadme()
+		files := []filesystem.FileInfo{{
+			Name:    "README",
+			Size:    int64(len(readme)),
+			Mode:    0444,
+			ModTime: time.Now(),
+			IsDir:   false,
+			Meta:    filesystem.MetaData{Name: PluginName, Type: "doc"},
+		}}
+
+		topics, err := kfs.plugin.backend.ListTopics()
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range topics {
+			files = append(files, topicInfo(t))
+		}
+		return files, nil
+	}
+
+	if err := kfs.requireTopic("readdir", path, topic); err != nil {
+		return nil, err
+	}
+	return []filesystem.FileInfo{
+		controlInfo(fileProduce),
+		controlInfo(fileConsume),
+		controlInfo(fileOffsets),
+	}, nil
+}
+
+func (kfs *kafkaFS) Stat(path string) (*filesystem.FileInfo, error) {
+	if path == "/README" {
+		readme := kfs.plugin.GetReadme()
+		return &filesystem.FileInfo{
+			Name:    "README",
+			Size:    int64(len(readme)),
+			Mode:    0444,
+			ModTime: time.Now(),
+			IsDir:   false,
+			Meta:    filesystem.MetaData{Name: PluginName, Type: "doc"},
+		}, nil
+	}
+
+	topic, file, err := parseKafkaPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if topic == "" {
+		info := topicInfo("/")
+		info.Meta = filesystem.MetaData{
+			Name: PluginName,
+			Content: map[string]string{
+				"backend":  kfs.plugin.backend.GetType(),
+				"group_id": kfs.plugin.groupID,
+			},
+		}
+		return &info, nil
+	}
+
+	if err := kfs.requireTopic("stat", path, topic); err != nil {
+		return nil, err
+	}
+
+	var info filesystem.FileInfo
+	if file == "" {
+		info = topicInfo(topic)
+	} else {
+		info = controlInfo(file)
+	}
+	return &info, nil
+}
+
+func (kfs *kafkaFS) Rename(oldPath, newPath string) error {
+	return filesystem.NewNotSupportedError("rename", oldPath)
+}
+
+func (kfs *kafkaFS) Chmod(path string, mode uint32) error {
+	return filesystem.NewNotSupportedError("chmod", path)
+}
+
+// Truncate is a no-op so shell redirections like `echo msg > produce` work
+func (kfs *kafkaFS) Truncate(path string, size int64) error {
+	return nil
+}
+
+func (kfs *kafkaFS) Open(path string) (io.ReadCloser, error) {
+	data, err := kfs.Read(path, 0, -1)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (kfs *kafkaFS) OpenWrite(path string) (io.WriteCloser, error) {
+	return &kafkaWriter{kfs: kfs, path: path, buf: &bytes.Buffer{}}, nil
+}
+
+// kafkaWriter buffers a streamed write and sends it as a single message on Close
+type kafkaWriter struct {
+	kfs  *kafkaFS
+	path string
+	buf  *bytes.Buffer
+}
+
+func (kw *kafkaWriter) Write(p []byte) (n int, err error) {
+	return kw.buf.Write(p)
+}
+
+func (kw *kafkaWriter) Close() error {
+	_, err := kw.kfs.Write(kw.path, kw.buf.Bytes(), -1, filesystem.WriteFlagAppend)
+	return err
+}
+
+// Ensure KafkaFSPlugin implements ServicePlugin
+var _ plugin.ServicePlugin = (*KafkaFSPlugin)(nil)
+var _ filesystem.FileSystem = (*kafkaFS)(nil)
@@ -0,0 +1,210 @@
+package kafkafs
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+func newTestFS(t *testing.T) *kafkaFS {
+	t.Helper()
+	p := NewKafkaFSPlugin()
+	cfg := map[string]interface{}{"backend": "memory", "group_id": "test"}
+	if err := p.Validate(cfg); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if err := p.Initialize(cfg); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	t.Cleanup(func() { p.Shutdown() })
+	return p.GetFileSystem().(*kafkaFS)
+}
+
+// readIgnoreEOF reads file content, ignoring io.EOF which is expected at end of file
+func readIgnoreEOF(fs *kafkaFS, path string) ([]byte, error) {
+	content, err := fs.Read(path, 0, -1)
+	if err == io.EOF {
+		return content, nil
+	}
+	return content, err
+}
+
+func consumeMessage(t *testing.T, fs *kafkaFS, topic string) Message {
+	t.Helper()
+	data, err := readIgnoreEOF(fs, "/"+topic+"/consume")
+	if err != nil {
+		t.Fatalf("consume failed: %v", err)
+	}
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatalf("invalid consume output %q: %v", data, err)
+	}
+	return msg
+}
+
+func TestKafkaFSProduceConsume(t *testing.T) {
+	fs := newTestFS(t)
+
+	if err := fs.Mkdir("/events", 0755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	if err := fs.Mkdir("/events", 0755); !errors.Is(err, filesystem.ErrAlreadyExists) {
+		t.Errorf("Mkdir existing topic: expected ErrAlreadyExists, got %v", err)
+	}
+
+	for _, v := range []string{"first", "second"} {
+		if _, err := fs.Write("/events/produce", []byte(v), -1, filesystem.WriteFlagNone); err != nil {
+			t.Fatalf("produce failed: %v", err)
+		}
+	}
+
+	if msg := consumeMessage(t, fs, "events"); msg.Value != "first" || msg.Offset != 0 {
+		t.Errorf("first consume = %+v", msg)
+	}
+	if msg := consumeMessage(t, fs, "events"); msg.Value != "second" || msg.Offset != 1 {
+		t.Errorf("second consume = %+v", msg)
+	}
+
+	data, err := readIgnoreEOF(fs, "/events/consume")
+	if err != nil || string(data) != "{}" {
+		t.Errorf("consume on drained topic = %q, %v; want {}", data, err)
+	}
+
+	if _, err := fs.Read("/events/produce", 0, -1); !errors.Is(err, filesystem.ErrPermissionDenied) {
+		t.Errorf("reading produce: expected ErrPermissionDenied, got %v", err)
+	}
+	if _, err := fs.Read("/missing/consume", 0, -1); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("consuming missing topic: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestKafkaFSOffsets(t *testing.T) {
+	fs := newTestFS(t)
+	fs.Mkdir("/orders", 0755)
+	for _, v := range []string{"a", "b", "c"} {
+		fs.Write("/orders/produce", []byte(v), -1, filesystem.WriteFlagNone)
+	}
+	consumeMessage(t, fs, "orders")
+
+	data, err := readIgnoreEOF(fs, "/orders/offsets")
+	if err != nil {
+		t.Fatalf("read offsets failed: %v", err)
+	}
+	var status struct {
+		Group      string            `json:"group"`
+		Partitions []PartitionOffset `json:"partitions"`
+	}
+	if err := json.Unmarshal(data, &status); err != nil {
+		t.Fatalf("invalid offsets output: %v", err)
+	}
+	if status.Group != "test" || len(status.Partitions) != 1 {
+		t.Fatalf("unexpected offsets: %s", data)
+	}
+	if p := status.Partitions[0]; p.Committed != 1 || p.Latest != 3 || p.Lag != 2 {
+		t.Errorf("partition offsets = %+v, want committed=1 latest=3 lag=2", p)
+	}
+
+	// Rewind and replay
+	if _, err := fs.Write("/orders/offsets", []byte("earliest\n"), -1, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("reset to earliest failed: %v", err)
+	}
+	if msg := consumeMessage(t, fs, "orders"); msg.Value != "a" {
+		t.Errorf("after rewind consumed %q, want %q", msg.Value, "a")
+	}
+
+	if _, err := fs.Write("/orders/offsets", []byte("0:2"), -1, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("reset to offset failed: %v", err)
+	}
+	if msg := consumeMessage(t, fs, "orders"); msg.Value != "c" {
+		t.Errorf("after seek consumed %q, want %q", msg.Value, "c")
+	}
+
+	if _, err := fs.Write("/orders/offsets", []byte("soon"), -1, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrInvalidArgument) {
+		t.Errorf("invalid offset spec: expected ErrInvalidArgument, got %v", err)
+	}
+	if _, err := fs.Write("/orders/offsets", []byte("3:0"), -1, filesystem.WriteFlagNone); err == nil {
+		t.Error("expected error for unknown partition")
+	}
+}
+
+func TestKafkaFSReadDirAndRemove(t *testing.T) {
+	fs := newTestFS(t)
+	fs.Mkdir("/b", 0755)
+	fs.Mkdir("/a", 0755)
+
+	infos, err := fs.ReadDir("/")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(infos) != 3 || infos[0].Name != "README" || infos[1].Name != "a" || infos[2].Name != "b" {
+		t.Errorf("unexpected root listing: %+v", infos)
+	}
+
+	infos, err = fs.ReadDir("/a")
+	if err != nil || len(infos) != 3 {
+		t.Fatalf("ReadDir topic = %+v, %v", infos, err)
+	}
+
+	if err := fs.RemoveAll("/a"); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	if _, err := fs.Stat("/a"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("removed topic still exists: %v", err)
+	}
+
+	if err := fs.Mkdir("/bad/name", 0755); err == nil {
+		t.Error("expected error for nested topic")
+	}
+	if err := fs.Mkdir("/bad name", 0755); !errors.Is(err, filesystem.ErrInvalidArgument) {
+		t.Errorf("invalid topic name: expected ErrInvalidArgument, got %v", err)
+	}
+}
+
+func TestParseOffsetSpec(t *testing.T) {
+	tests := []struct {
+		in        string
+		partition int
+		offset    int64
+		wantErr   bool
+	}{
+		{"earliest", AllPartitions, OffsetEarliest, false},
+		{"latest\n", AllPartitions, OffsetLatest, false},
+		{"42", AllPartitions, 42, false},
+		{"2:latest", 2, OffsetLatest, false},
+		{"1: 7", 1, 7, false},
+		{"-1", 0, 0, true},
+		{"x:1", 0, 0, true},
+		{"", 0, 0, true},
+	}
+	for _, tt := range tests {
+		partition, offset, err := parseOffsetSpec(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseOffsetSpec(%q) expected error", tt.in)
+			}
+			continue
+		}
+		if err != nil || partition != tt.partition || offset != tt.offset {
+			t.Errorf("parseOffsetSpec(%q) = %d, %d, %v; want %d, %d", tt.in, partition, offset, err, tt.partition, tt.offset)
+		}
+	}
+}
+
+func TestKafkaFSValidate(t *testing.T) {
+	p := NewKafkaFSPlugin()
+	if err := p.Validate(map[string]interface{}{}); err == nil {
+		t.Error("expected error for kafka backend without brokers")
+	}
+	if err := p.Validate(map[string]interface{}{"brokers": []interface{}{"a:9092", "b:9092"}}); err != nil {
+		t.Errorf("broker list should be valid: %v", err)
+	}
+	if err := p.Validate(map[string]interface{}{"backend": "memory", "start_offset": "middle"}); err == nil {
+		t.Error("expected error for invalid start_offset")
+	}
+	if err := p.Validate(map[string]interface{}{"backend": "memory", "poll_timeout": "0s"}); err == nil {
+		t.Error("expected error for non-positive poll_timeout")
+	}
+}
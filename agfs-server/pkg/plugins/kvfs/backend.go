@@ -0,0 +1,173 @@
+package kvfs
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KVEntry describes a stored key
+type KVEntry struct {
+	Key       string
+	Value     []byte    // Only populated by Get
+	Size      int64     // Size of the value in bytes
+	ModTime   time.Time // Last write time (zero if the backend doesn't track it)
+	ExpiresAt time.Time // Expiration time (zero means no TTL)
+}
+
+// KVBackend defines the interface for key-value storage backends
+type KVBackend interface {
+	// Initialize initializes the backend with configuration
+	Initialize(config map[string]interface{}) error
+
+	// Close closes the backend connection
+	Close() error
+
+	// GetType returns the backend type name
+	GetType() string
+
+	// Get returns the entry for a key, or false if it doesn't exist or has expired
+	Get(key string) (KVEntry, bool, error)
+
+	// Set stores a value
+	// ttl > 0 sets a new expiration, ttl == 0 keeps the existing expiration (if any)
+	Set(key string, value []byte, ttl time.Duration) error
+
+	// Delete removes a key, returning false if it didn't exist
+	Delete(key string) (bool, error)
+
+	// List returns all live keys starting with prefix (values are not populated)
+	List(prefix string) ([]KVEntry, error)
+
+	// Expire sets the TTL of an existing key, ttl <= 0 removes the expiration
+	Expire(key string, ttl time.Duration) error
+}
+
+// MemoryBackend implements KVBackend using in-memory storage
+type MemoryBackend struct {
+	store map[string]*KVEntry
+	mu    sync.RWMutex
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		store: make(map[string]*KVEntry),
+	}
+}
+
+func (b *MemoryBackend) Initialize(config map[string]interface{}) error {
+	// No initialization needed for memory backend
+	return nil
+}
+
+func (b *MemoryBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.store = make(map[string]*KVEntry)
+	return nil
+}
+
+func (b *MemoryBackend) GetType() string {
+	return "memory"
+}
+
+// expired reports whether an entry's TTL has elapsed
+func expired(e *KVEntry, now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && !now.Before(e.ExpiresAt)
+}
+
+func (b *MemoryBackend) Get(key string) (KVEntry, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e, exists := b.store[key]
+	if !exists {
+		return KVEntry{}, false, nil
+	}
+	if expired(e, time.Now()) {
+		delete(b.store, key)
+		return KVEntry{}, false, nil
+	}
+
+	result := *e
+	result.Value = append([]byte(nil), e.Value...)
+	return result, true, nil
+}
+
+func (b *MemoryBackend) Set(key string, value []byte, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = now.Add(ttl)
+	} else if old, exists := b.store[key]; exists && !expired(old, now) {
+		expiresAt = old.ExpiresAt
+	}
+
+	b.store[key] = &KVEntry{
+		Key:       key,
+		Value:     append([]byte(nil), value...),
+		Size:      int64(len(value)),
+		ModTime:   now,
+		ExpiresAt: expiresAt,
+	}
+	return nil
+}
+
+func (b *MemoryBackend) Delete(key string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e, exists := b.store[key]
+	if !exists {
+		return false, nil
+	}
+	delete(b.store, key)
+	return !expired(e, time.Now()), nil
+}
+
+func (b *MemoryBackend) List(prefix string) ([]KVEntry, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	var entries []KVEntry
+	for key, e := range b.store {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if expired(e, now) {
+			delete(b.store, key)
+			continue
+		}
+		entries = append(entries, KVEntry{
+			Key:       key,
+			Size:      e.Size,
+			ModTime:   e.ModTime,
+			ExpiresAt: e.ExpiresAt,
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries, nil
+}
+
+func (b *MemoryBackend) Expire(key string, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e, exists := b.store[key]
+	if !exists || expired(e, time.Now()) {
+		return errKeyNotFound
+	}
+
+	if ttl > 0 {
+		e.ExpiresAt = time.Now().Add(ttl)
+	} else {
+		e.ExpiresAt = time.Time{}
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package kvfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+)
+
+// redisOpTimeout bounds every Redis round trip
+const redisOpTimeout = 10 * time.Second
+
+// RedisBackend implements KVBackend on top of Redis
+// Values are stored as plain Redis strings so other Redis clients can share the data.
+// All keys are namespaced with key_prefix (default "agfs:kvfs:").
+type RedisBackend struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedisBackend() *RedisBackend {
+	return &RedisBackend{}
+}
+
+func (b *RedisBackend) Initialize(cfg map[string]interface{}) error {
+	opts := &redis.Options{
+		Addr:     config.GetStringConfig(cfg, "redis_addr", "127.0.0.1:6379"),
+		Username: config.GetStringConfig(cfg, "redis_username", ""),
+		Password: config.GetStringConfig(cfg, "redis_password", ""),
+		DB:       config.GetIntConfig(cfg, "redis_db", 0),
+	}
+
+	// redis_url takes precedence over the individual settings
+	if url := config.GetStringConfig(cfg, "redis_url", ""); url != "" {
+		parsed, err := redis.ParseURL(url)
+		if err != nil {
+			return fmt.Errorf("invalid redis_url: %w", err)
+		}
+		opts = parsed
+	}
+
+	b.prefix = config.GetStringConfig(cfg, "key_prefix", "agfs:kvfs:")
+	b.client = redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisOpTimeout)
+	defer cancel()
+	if err := b.client.Ping(ctx).Err(); err != nil {
+		b.client.Close()
+		return fmt.Errorf("failed to connect to redis at %s: %w", opts.Addr, err)
+	}
+
+	log.Infof("[kvfs] Connected to Redis at %s (db=%d, prefix=%q)", opts.Addr, opts.DB, b.prefix)
+	return nil
+}
+
+func (b *RedisBackend) Close() error {
+	if b.client != nil {
+		return b.client.Close()
+	}
+	return nil
+}
+
+func (b *RedisBackend) GetType() string {
+	return "redis"
+}
+
+func (b *RedisBackend) redisKey(key string) string {
+	return b.prefix + key
+}
+
+func (b *RedisBackend) Get(key string) (KVEntry, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisOpTimeout)
+	defer cancel()
+
+	rk := b.redisKey(key)
+	pipe := b.client.Pipeline()
+	getCmd := pipe.Get(ctx, rk)
+	ttlCmd := pipe.PTTL(ctx, rk)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return KVEntry{}, false, fmt.Errorf("redis get failed: %w", err)
+	}
+
+	value, err := getCmd.Bytes()
+	if errors.Is(err, redis.Nil) {
+		return KVEntry{}, false, nil
+	} else if err != nil {
+		return KVEntry{}, false, fmt.Errorf("redis get failed: %w", err)
+	}
+
+	return KVEntry{
+		Key:       key,
+		Value:     value,
+		Size:      int64(len(value)),
+		ExpiresAt: expiresAtFromTTL(ttlCmd.Val()),
+	}, true, nil
+}
+
+func (b *RedisBackend) Set(key string, value []byte, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisOpTimeout)
+	defer cancel()
+
+	// KeepTTL preserves an existing expiration, mirroring the memory backend
+	args := redis.SetArgs{KeepTTL: true}
+	if ttl > 0 {
+		args = redis.SetArgs{TTL: ttl}
+	}
+	if err := b.client.SetArgs(ctx, b.redisKey(key), value, args).Err(); err != nil {
+		return fmt.Errorf("redis set failed: %w", err)
+	}
+	return nil
+}
+
+func (b *RedisBackend) Delete(key string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisOpTimeout)
+	defer cancel()
+
+	n, err := b.client.Del(ctx, b.redisKey(key)).Result()
+	if err != nil {
+		return false, fmt.Errorf("redis del failed: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (b *RedisBackend) List(prefix string) ([]KVEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisOpTimeout)
+	defer cancel()
+
+	pattern := escapeRedisPattern(b.redisKey(prefix)) + "*"
+
+	var keys []string
+	iter := b.client.Scan(ctx, 0, pattern, 1000).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("redis scan failed: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	// Fetch sizes and TTLs in one round trip
+	pipe := b.client.Pipeline()
+	lenCmds := make([]*redis.IntCmd, len(keys))
+	ttlCmds := make([]*redis.DurationCmd, len(keys))
+	for i, k := range keys {
+		lenCmds[i] = pipe.StrLen(ctx, k)
+		ttlCmds[i] = pipe.PTTL(ctx, k)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("redis pipeline failed: %w", err)
+	}
+
+	entries := make([]KVEntry, 0, len(keys))
+	for i, k := range keys {
+		// Keys that expired between SCAN and PTTL report -2
+		if ttlCmds[i].Val() == -2 {
+			continue
+		}
+		entries = append(entries, KVEntry{
+			Key:       strings.TrimPrefix(k, b.prefix),
+			Size:      lenCmds[i].Val(),
+			ExpiresAt: expiresAtFromTTL(ttlCmds[i].Val()),
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries, nil
+}
+
+func (b *RedisBackend) Expire(key string, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisOpTimeout)
+	defer cancel()
+
+	var ok bool
+	var err error
+	if ttl > 0 {
+		ok, err = b.client.PExpire(ctx, b.redisKey(key), ttl).Result()
+	} else {
+		// PERSIST returns false both for missing keys and keys without a TTL
+		var n int64
+		n, err = b.client.Exists(ctx, b.redisKey(key)).Result()
+		if err == nil && n > 0 {
+			ok = true
+			err = b.client.Persist(ctx, b.redisKey(key)).Err()
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("redis expire failed: %w", err)
+	}
+	if !ok {
+		return errKeyNotFound
+	}
+	return nil
+}
+
+// expiresAtFromTTL converts a PTTL result into an absolute expiration time
+// Negative values mean "no expiration" (-1) or "missing key" (-2)
+func expiresAtFromTTL(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+// escapeRedisPattern escapes glob metacharacters for use in SCAN MATCH
+func escapeRedisPattern(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,259 @@
+package kvfs
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	_ "github.com/go-sql-driver/mysql" // MySQL/TiDB driver
+	_ "github.com/mattn/go-sqlite3"    // SQLite driver
+	log "github.com/sirupsen/logrus"
+)
+
+// SQLBackend implements KVBackend on a single SQL table
+// With backend "tidb" the data lives in TiKV (TiDB's storage layer), which gives
+// kvfs a distributed, durable store; "sqlite" is a single-file local alternative.
+type SQLBackend struct {
+	db          *sql.DB
+	backendType string
+	table       string
+}
+
+func NewSQLBackend() *SQLBackend {
+	return &SQLBackend{}
+}
+
+var tableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func (b *SQLBackend) Initialize(cfg map[string]interface{}) error {
+	b.backendType = config.GetStringConfig(cfg, "backend", "sqlite")
+	b.table = config.GetStringConfig(cfg, "table", "kvfs_store")
+	if !tableNamePattern.MatchString(b.table) {
+		return fmt.Errorf("invalid table name: %s", b.table)
+	}
+
+	var err error
+	switch b.backendType {
+	case "sqlite", "sqlite3":
+		dbPath := config.GetStringConfig(cfg, "db_path", "kvfs.db")
+		b.db, err = sql.Open("sqlite3", dbPath)
+		if err != nil {
+			return fmt.Errorf("failed to open SQLite database: %w", err)
+		}
+		// Enable WAL mode for better concurrency
+		if _, err := b.db.Exec("PRAGMA journal_mode=WAL"); err != nil {
+			b.db.Close()
+			return fmt.Errorf("failed to enable WAL mode: %w", err)
+		}
+	case "tidb", "mysql":
+		dsn, err := config.RequireString(cfg, "dsn")
+		if err != nil {
+			return err
+		}
+		b.db, err = sql.Open("mysql", dsn)
+		if err != nil {
+			return fmt.Errorf("failed to open TiDB database: %w", err)
+		}
+		b.db.SetMaxOpenConns(50)
+		b.db.SetMaxIdleConns(10)
+	default:
+		return fmt.Errorf("unsupported SQL backend: %s", b.backendType)
+	}
+
+	if err := b.db.Ping(); err != nil {
+		b.db.Close()
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	if _, err := b.db.Exec(b.createTableSQL()); err != nil {
+		b.db.Close()
+		return fmt.Errorf("failed to initialize schema: %w", err)
+	}
+
+	log.Infof("[kvfs] Using %s backend (table: %s)", b.backendType, b.table)
+	return nil
+}
+
+func (b *SQLBackend) isSQLite() bool {
+	return b.backendType == "sqlite" || b.backendType == "sqlite3"
+}
+
+func (b *SQLBackend) createTableSQL() string {
+	if b.isSQLite() {
+		return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			k TEXT PRIMARY KEY,
+			v BLOB NOT NULL,
+			mtime INTEGER NOT NULL,
+			expires_at INTEGER NOT NULL DEFAULT 0
+		)`, b.table)
+	}
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		k VARBINARY(1024) PRIMARY KEY,
+		v LONGBLOB NOT NULL,
+		mtime BIGINT NOT NULL,
+		expires_at BIGINT NOT NULL DEFAULT 0
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`, b.table)
+}
+
+func (b *SQLBackend) Close() error {
+	if b.db != nil {
+		return b.db.Close()
+	}
+	return nil
+}
+
+func (b *SQLBackend) GetType() string {
+	return b.backendType
+}
+
+// liveCondition filters out expired rows; expires_at = 0 means no TTL
+const liveCondition = "(expires_at = 0 OR expires_at > ?)"
+
+func (b *SQLBackend) Get(key string) (KVEntry, bool, error) {
+	now := time.Now()
+	var value []byte
+	var mtime, expiresAt int64
+	err := b.db.QueryRow(
+		fmt.Sprintf("SELECT v, mtime, expires_at FROM %s WHERE k = ? AND %s", b.table, liveCondition),
+		key, now.UnixMilli(),
+	).Scan(&value, &mtime, &expiresAt)
+	if err == sql.ErrNoRows {
+		return KVEntry{}, false, nil
+	} else if err != nil {
+		return KVEntry{}, false, fmt.Errorf("failed to get key: %w", err)
+	}
+
+	return KVEntry{
+		Key:       key,
+		Value:     value,
+		Size:      int64(len(value)),
+		ModTime:   time.UnixMilli(mtime),
+		ExpiresAt: fromUnixMilli(expiresAt),
+	}, true, nil
+}
+
+func (b *SQLBackend) Set(key string, value []byte, ttl time.Duration) error {
+	now := time.Now()
+	if value == nil {
+		value = []byte{}
+	}
+
+	// ttl == 0 keeps the current expiration of a live row
+	var err error
+	if ttl > 0 {
+		expiresAt := now.Add(ttl).UnixMilli()
+		if b.isSQLite() {
+			_, err = b.db.Exec(fmt.Sprintf(`INSERT INTO %s (k, v, mtime, expires_at) VALUES (?, ?, ?, ?)
+				ON CONFLICT(k) DO UPDATE SET v = excluded.v, mtime = excluded.mtime, expires_at = excluded.expires_at`, b.table),
+				key, value, now.UnixMilli(), expiresAt)
+		} else {
+			_, err = b.db.Exec(fmt.Sprintf(`INSERT INTO %s (k, v, mtime, expires_at) VALUES (?, ?, ?, ?)
+				ON DUPLICATE KEY UPDATE v = VALUES(v), mtime = VALUES(mtime), expires_at = VALUES(expires_at)`, b.table),
+				key, value, now.UnixMilli(), expiresAt)
+		}
+	} else {
+		nowMs := now.UnixMilli()
+		if b.isSQLite() {
+			_, err = b.db.Exec(fmt.Sprintf(`INSERT INTO %s (k, v, mtime, expires_at) VALUES (?, ?, ?, 0)
+				ON CONFLICT(k) DO UPDATE SET v = excluded.v, mtime = excluded.mtime,
+				expires_at = CASE WHEN %s.expires_at > ? THEN %s.expires_at ELSE 0 END`, b.table, b.table, b.table),
+				key, value, nowMs, nowMs)
+		} else {
+			_, err = b.db.Exec(fmt.Sprintf(`INSERT INTO %s (k, v, mtime, expires_at) VALUES (?, ?, ?, 0)
+				ON DUPLICATE KEY UPDATE v = VALUES(v), mtime = VALUES(mtime),
+				expires_at = IF(expires_at > ?, expires_at, 0)`, b.table),
+				key, value, nowMs, nowMs)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set key: %w", err)
+	}
+	return nil
+}
+
+func (b *SQLBackend) Delete(key string) (bool, error) {
+	// Expired rows are deleted too but reported as missing
+	res, err := b.db.Exec(
+		fmt.Sprintf("DELETE FROM %s WHERE k = ? AND %s", b.table, liveCondition),
+		key, time.Now().UnixMilli(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete key: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	if _, err := b.db.Exec(fmt.Sprintf("DELETE FROM %s WHERE k = ?", b.table), key); err != nil {
+		return false, fmt.Errorf("failed to delete key: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (b *SQLBackend) List(prefix string) ([]KVEntry, error) {
+	now := time.Now().UnixMilli()
+
+	// Opportunistically purge expired rows so the table doesn't grow unbounded
+	if _, err := b.db.Exec(fmt.Sprintf("DELETE FROM %s WHERE expires_at != 0 AND expires_at <= ?", b.table), now); err != nil {
+		log.Warnf("[kvfs] Failed to purge expired keys: %v", err)
+	}
+
+	rows, err := b.db.Query(
+		fmt.Sprintf(`SELECT k, LENGTH(v), mtime, expires_at FROM %s WHERE k >= ? AND k < ? AND %s ORDER BY k`,
+			b.table, liveCondition),
+		prefix, prefixEnd(prefix), now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list keys: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []KVEntry
+	for rows.Next() {
+		var key string
+		var size, mtime, expiresAt int64
+		if err := rows.Scan(&key, &size, &mtime, &expiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan key: %w", err)
+		}
+		entries = append(entries, KVEntry{
+			Key:       key,
+			Size:      size,
+			ModTime:   time.UnixMilli(mtime),
+			ExpiresAt: fromUnixMilli(expiresAt),
+		})
+	}
+	return entries, rows.Err()
+}
+
+func (b *SQLBackend) Expire(key string, ttl time.Duration) error {
+	now := time.Now()
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = now.Add(ttl).UnixMilli()
+	}
+
+	res, err := b.db.Exec(
+		fmt.Sprintf("UPDATE %s SET expires_at = ? WHERE k = ? AND %s", b.table, liveCondition),
+		expiresAt, key, now.UnixMilli(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set ttl: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errKeyNotFound
+	}
+	return nil
+}
+
+func fromUnixMilli(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
+
+// prefixEnd returns the upper bound of the keys starting with prefix, for a
+// range scan that compares bytes: unlike LIKE, which ignores case in SQLite,
+// it only matches the prefix exactly. 0xff never occurs in UTF-8 keys.
+func prefixEnd(prefix string) string {
+	return prefix + "\xff"
+}
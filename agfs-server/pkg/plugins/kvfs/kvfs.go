@@ -2,15 +2,19 @@ package kvfs
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
-	"path/filepath"
+	"path"
+	"sort"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	log "github.com/sirupsen/logrus"
 )
 
 const (
@@ -21,30 +25,48 @@ const (
 const (
 	MetaValueDir  = "dir"  // KV store directory
 	MetaValueFile = "file" // KV store data file
+	MetaValueTTL  = "ttl"  // TTL control file
 )
 
+// Top-level directories
+const (
+	keysDir = "/keys"
+	ttlDir  = "/ttl"
+)
+
+// errKeyNotFound is returned by backends when a key doesn't exist
+var errKeyNotFound = errors.New("key not found")
+
 // KVFSPlugin provides a key-value store service through a file system interface
-// Each key is represented as a file, and the file content is the value
+// Each key is represented as a file, and the file content is the value.
+// Keys containing "/" are exposed as nested directories (key prefixes).
 // Operations:
 //
 //	GET /keys/<key>    - Read value
 //	PUT /keys/<key>    - Write value
 //	DELETE /keys/<key> - Delete key
 //	GET /keys          - List all keys
+//	GET /ttl/<key>     - Read remaining TTL in seconds (-1 = no expiration)
+//	PUT /ttl/<key>     - Set TTL ("60", "5m", "0" to persist)
+//
+// Supports multiple backends:
+//   - memory (default): In-memory storage
+//   - redis: Redis server
+//   - tidb: TiDB (TiKV-backed) table
+//   - sqlite: SQLite database file
 type KVFSPlugin struct {
-	store    map[string][]byte
-	mu       sync.RWMutex
-	metadata plugin.PluginMetadata
+	backend    KVBackend
+	defaultTTL time.Duration
+	metadata   plugin.PluginMetadata
 }
 
 // NewKVFSPlugin creates a new key-value store plugin
 func NewKVFSPlugin() *KVFSPlugin {
 	return &KVFSPlugin{
-		store: make(map[string][]byte),
 		metadata: plugin.PluginMetadata{
 			Name:        PluginName,
-			Version:     "1.0.0",
-			Description: "Key-Value store service plugin",
+			Version:     "1.1.0",
+			Description: "Key-Value store service plugin with TTL support and pluggable backends",
 			Author:      "VFS Server",
 		},
 	}
@@ -55,24 +77,45 @@ func (kv *KVFSPlugin) Name() string {
 }
 
 func (kv *KVFSPlugin) Validate(cfg map[string]interface{}) error {
-	// Check for unknown parameters
-	allowedKeys := []string{"initial_data", "mount_path"}
-	for key := range cfg {
-		found := false
-		for _, allowed := range allowedKeys {
-			if key == allowed {
-				found = true
-				break
-			}
+	allowedKeys := []string{
+		"initial_data", "mount_path", "backend", "default_ttl",
+		// Redis
+		"redis_addr", "redis_url", "redis_username", "redis_password", "redis_db", "key_prefix",
+		// SQL (tidb/sqlite)
+		"dsn", "db_path", "table",
+	}
+	if err := config.ValidateOnlyKnownKeys(cfg, allowedKeys); err != nil {
+		return err
+	}
+
+	backendType := config.GetStringConfig(cfg, "backend", "memory")
+	switch backendType {
+	case "memory", "redis", "tidb", "mysql", "sqlite", "sqlite3":
+	default:
+		return fmt.Errorf("unsupported backend: %s (valid options: memory, redis, tidb, sqlite)", backendType)
+	}
+
+	for _, key := range []string{"redis_addr", "redis_url", "redis_username", "redis_password", "key_prefix", "dsn", "db_path", "table", "default_ttl"} {
+		if err := config.ValidateStringType(cfg, key); err != nil {
+			return err
 		}
-		if !found {
-			return fmt.Errorf("unknown configuration parameter: %s (allowed: %v)", key, allowedKeys)
+	}
+	if err := config.ValidateIntType(cfg, "redis_db"); err != nil {
+		return err
+	}
+
+	if (backendType == "tidb" || backendType == "mysql") && config.GetStringConfig(cfg, "dsn", "") == "" {
+		return fmt.Errorf("dsn is required for the %s backend", backendType)
+	}
+
+	if ttlStr := config.GetStringConfig(cfg, "default_ttl", ""); ttlStr != "" {
+		if _, err := parseTTL(ttlStr); err != nil {
+			return fmt.Errorf("invalid default_ttl: %w", err)
 		}
 	}
 
 	// Validate initial_data if provided
 	if val, exists := cfg["initial_data"]; exists {
-		// Check if it's a map[string]interface{} or map[string]string
 		switch val.(type) {
 		case map[string]interface{}, map[string]string:
 			// Valid types
@@ -83,13 +126,54 @@ func (kv *KVFSPlugin) Validate(cfg map[string]interface{}) error {
 	return nil
 }
 
-func (kv *KVFSPlugin) Initialize(config map[string]interface{}) error {
+func (kv *KVFSPlugin) Initialize(cfg map[string]interface{}) error {
+	backendType := config.GetStringConfig(cfg, "backend", "memory")
+
+	var backend KVBackend
+	switch backendType {
+	case "memory":
+		backend = NewMemoryBackend()
+	case "redis":
+		backend = NewRedisBackend()
+	case "tidb", "mysql", "sqlite", "sqlite3":
+		backend = NewSQLBackend()
+	default:
+		return fmt.Errorf("unsupported backend: %s", backendType)
+	}
+
+	if err := backend.Initialize(cfg); err != nil {
+		return fmt.Errorf("failed to initialize %s backend: %w", backendType, err)
+	}
+	kv.backend = backend
+
+	if ttlStr := config.GetStringConfig(cfg, "default_ttl", ""); ttlStr != "" {
+		ttl, err := parseTTL(ttlStr)
+		if err != nil {
+			return fmt.Errorf("invalid default_ttl: %w", err)
+		}
+		kv.defaultTTL = ttl
+	}
+
 	// Load initial data if provided
-	if data, ok := config["initial_data"].(map[string]string); ok {
+	initial := make(map[string]string)
+	switch data := cfg["initial_data"].(type) {
+	case map[string]string:
+		initial = data
+	case map[string]interface{}:
 		for k, v := range data {
-			kv.store[k] = []byte(v)
+			initial[k] = fmt.Sprintf("%v", v)
 		}
 	}
+	for k, v := range initial {
+		if err := validateKey(k); err != nil {
+			return fmt.Errorf("invalid key in initial_data: %w", err)
+		}
+		if err := kv.backend.Set(k, []byte(v), kv.defaultTTL); err != nil {
+			return fmt.Errorf("failed to load initial_data: %w", err)
+		}
+	}
+
+	log.Infof("[kvfs] Initialized with backend: %s", backendType)
 	return nil
 }
 
@@ -118,10 +202,33 @@ USAGE:
   Rename a key:
     mv /keys/<oldkey> /keys/<newkey>
 
+KEY PREFIXES:
+  Keys containing "/" appear as nested directories:
+    echo "alice" > /keys/users/1/name
+    ls /keys/users/1
+    rm -rf /keys/users        # deletes every key under "users/"
+
+TTL (EXPIRATION):
+  Every key has a mirror file under /ttl:
+    echo 60 > /ttl/<key>      # expire in 60 seconds
+    echo 5m > /ttl/<key>      # Go duration syntax is also accepted
+    echo 0 > /ttl/<key>       # remove the expiration
+    cat /ttl/<key>            # remaining seconds, -1 if no expiration
+
+  Writing a value keeps the key's current TTL. Set default_ttl to apply
+  a TTL to every write instead.
+
 STRUCTURE:
   /keys/     - Directory containing all key-value pairs
+  /ttl/      - TTL control files (one per key)
   /README    - This file
 
+BACKENDS:
+  memory (default) - in-process, lost on restart
+  redis            - redis_addr / redis_url, keys stored as plain strings under key_prefix
+  tidb             - dsn, values stored in a TiDB table (persisted in TiKV)
+  sqlite           - db_path, single local database file
+
 EXAMPLES:
   # Set a value
   agfs:/> echo "hello world" > /kvfs/keys/mykey
@@ -133,6 +240,9 @@ EXAMPLES:
   # List all keys
   agfs:/> ls /kvfs/keys
 
+  # Expire a session key after 30 minutes
+  agfs:/> echo 30m > /kvfs/ttl/sessions/abc
+
   # Delete a key
   agfs:/> rm /kvfs/keys/mykey
 
@@ -142,13 +252,130 @@ EXAMPLES:
 }
 
 func (kv *KVFSPlugin) GetConfigParams() []plugin.ConfigParameter {
-	return []plugin.ConfigParameter{}
+	return []plugin.ConfigParameter{
+		{
+			Name:        "backend",
+			Type:        "string",
+			Required:    false,
+			Default:     "memory",
+			Description: "Storage backend: memory, redis, tidb, sqlite",
+			Enum:        []string{"memory", "redis", "tidb", "mysql", "sqlite", "sqlite3"},
+		},
+		{
+			Name:        "default_ttl",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "TTL applied on every write (e.g. '1h'); empty means no expiration",
+		},
+		{
+			Name:        "redis_addr",
+			Type:        "string",
+			Required:    false,
+			Default:     "127.0.0.1:6379",
+			Description: "Redis server address (redis backend)",
+		},
+		{
+			Name:        "redis_url",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Redis URL, overrides redis_addr/redis_password/redis_db (redis backend)",
+		},
+		{
+			Name:        "redis_username",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Redis ACL username (redis backend)",
+		},
+		{
+			Name:        "redis_password",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Redis password (redis backend)",
+			Secret:      true,
+		},
+		{
+			Name:        "redis_db",
+			Type:        "int",
+			Required:    false,
+			Default:     "0",
+			Description: "Redis database number (redis backend)",
+		},
+		{
+			Name:        "key_prefix",
+			Type:        "string",
+			Required:    false,
+			Default:     "agfs:kvfs:",
+			Description: "Namespace prepended to every Redis key (redis backend)",
+		},
+		{
+			Name:        "dsn",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Database connection string (tidb backend)",
+			Secret:      true,
+		},
+		{
+			Name:        "db_path",
+			Type:        "string",
+			Required:    false,
+			Default:     "kvfs.db",
+			Description: "Database file path (sqlite backend)",
+		},
+		{
+			Name:        "table",
+			Type:        "string",
+			Required:    false,
+			Default:     "kvfs_store",
+			Description: "Table name (tidb/sqlite backends)",
+		},
+	}
 }
 
 func (kv *KVFSPlugin) Shutdown() error {
-	kv.mu.Lock()
-	defer kv.mu.Unlock()
-	kv.store = nil
+	if kv.backend != nil {
+		return kv.backend.Close()
+	}
+	return nil
+}
+
+// parseTTL parses a TTL given as plain seconds or a Go duration string
+// Zero or negative values mean "no expiration"
+func parseTTL(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty ttl")
+	}
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if secs <= 0 {
+			return 0, nil
+		}
+		return time.Duration(secs) * time.Second, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("expected seconds or duration (e.g. 30s, 5m): %s", s)
+	}
+	if d <= 0 {
+		return 0, nil
+	}
+	return d, nil
+}
+
+// validateKey checks that a key can be represented as a file path
+func validateKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("key name cannot be empty")
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid key: %s", key)
+		}
+	}
 	return nil
 }
 
@@ -157,125 +384,245 @@ type kvFS struct {
 	plugin *KVFSPlugin
 }
 
-func (kvfs *kvFS) Create(path string) error {
-	if path == "/" || path == "/keys" {
-		return fmt.Errorf("cannot create: %s", path)
+// splitPath splits a path into its top-level directory (/keys or /ttl) and key
+func splitPath(p string) (root string, key string) {
+	p = filesystem.NormalizePath(p)
+	for _, dir := range []string{keysDir, ttlDir} {
+		if p == dir {
+			return dir, ""
+		}
+		if strings.HasPrefix(p, dir+"/") {
+			return dir, strings.TrimPrefix(p, dir+"/")
+		}
 	}
+	return "", ""
+}
 
-	// Only allow creating files under /keys/
-	if !strings.HasPrefix(path, "/keys/") {
-		return fmt.Errorf("keys must be under /keys/ directory")
+// isPrefix reports whether any live key lives under key + "/"
+func (kvfs *kvFS) isPrefix(key string) (bool, error) {
+	entries, err := kvfs.plugin.backend.List(key + "/")
+	if err != nil {
+		return false, err
 	}
+	return len(entries) > 0, nil
+}
 
-	key := strings.TrimPrefix(path, "/keys/")
-	if key == "" {
-		return fmt.Errorf("key name cannot be empty")
+func (kvfs *kvFS) Create(p string) error {
+	root, key := splitPath(p)
+	if root != keysDir || key == "" {
+		return filesystem.NewPermissionDeniedError("create", p, "keys must be under /keys/")
+	}
+	if err := validateKey(key); err != nil {
+		return filesystem.NewInvalidArgumentError("key", key, err.Error())
 	}
 
-	kvfs.plugin.mu.Lock()
-	defer kvfs.plugin.mu.Unlock()
-
-	if _, exists := kvfs.plugin.store[key]; exists {
-		return fmt.Errorf("key already exists: %s", key)
+	if _, exists, err := kvfs.plugin.backend.Get(key); err != nil {
+		return err
+	} else if exists {
+		return filesystem.NewAlreadyExistsError("key", key)
 	}
 
-	kvfs.plugin.store[key] = []byte{}
-	return nil
+	return kvfs.plugin.backend.Set(key, []byte{}, kvfs.plugin.defaultTTL)
 }
 
-func (kvfs *kvFS) Mkdir(path string, perm uint32) error {
-	if path == "/keys" {
-		return nil // /keys directory always exists
+func (kvfs *kvFS) Mkdir(p string, perm uint32) error {
+	root, key := splitPath(p)
+	if root == keysDir {
+		// Prefixes are implicit: a directory exists as soon as a key is written under it
+		if key != "" {
+			if err := validateKey(key); err != nil {
+				return filesystem.NewInvalidArgumentError("key", key, err.Error())
+			}
+		}
+		return nil
 	}
-	return fmt.Errorf("cannot create directories in kvfs service")
+	return filesystem.NewPermissionDeniedError("mkdir", p, "directories can only be created under /keys")
 }
 
-func (kvfs *kvFS) Remove(path string) error {
-	if !strings.HasPrefix(path, "/keys/") {
-		return fmt.Errorf("can only remove keys under /keys/")
+func (kvfs *kvFS) Remove(p string) error {
+	root, key := splitPath(p)
+	if root != keysDir || key == "" {
+		return filesystem.NewPermissionDeniedError("remove", p, "can only remove keys under /keys/")
 	}
 
-	key := strings.TrimPrefix(path, "/keys/")
-	if key == "" {
-		return fmt.Errorf("key name cannot be empty")
+	deleted, err := kvfs.plugin.backend.Delete(key)
+	if err != nil {
+		return err
 	}
-
-	kvfs.plugin.mu.Lock()
-	defer kvfs.plugin.mu.Unlock()
-
-	if _, exists := kvfs.plugin.store[key]; !exists {
-		return fmt.Errorf("key not found: %s", key)
+	if !deleted {
+		isDir, err := kvfs.isPrefix(key)
+		if err != nil {
+			return err
+		}
+		if isDir {
+			return fmt.Errorf("directory not empty: %s", p)
+		}
+		return filesystem.NewNotFoundError("remove", p)
 	}
-
-	delete(kvfs.plugin.store, key)
 	return nil
 }
 
-func (kvfs *kvFS) RemoveAll(path string) error {
-	if path == "/keys" {
-		// Clear all keys
-		kvfs.plugin.mu.Lock()
-		defer kvfs.plugin.mu.Unlock()
-		kvfs.plugin.store = make(map[string][]byte)
-		return nil
+func (kvfs *kvFS) RemoveAll(p string) error {
+	root, key := splitPath(p)
+	if root != keysDir {
+		return filesystem.NewPermissionDeniedError("removeall", p, "can only remove keys under /keys/")
 	}
-	return kvfs.Remove(path)
-}
 
-func (kvfs *kvFS) Read(path string, offset int64, size int64) ([]byte, error) {
-	if path == "/" || path == "/keys" {
-		return nil, fmt.Errorf("is a directory: %s", path)
+	prefix := ""
+	if key != "" {
+		prefix = key + "/"
+		if _, err := kvfs.plugin.backend.Delete(key); err != nil {
+			return err
+		}
 	}
 
-	var data []byte
-	if path == "/README" {
-		data = []byte(kvfs.plugin.GetReadme())
-	} else if strings.HasPrefix(path, "/keys/") {
-		key := strings.TrimPrefix(path, "/keys/")
-		if key == "" {
-			return nil, fmt.Errorf("key name cannot be empty")
+	entries, err := kvfs.plugin.backend.List(prefix)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if _, err := kvfs.plugin.backend.Delete(e.Key); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		kvfs.plugin.mu.RLock()
-		value, exists := kvfs.plugin.store[key]
-		kvfs.plugin.mu.RUnlock()
+func (kvfs *kvFS) Read(p string, offset int64, size int64) ([]byte, error) {
+	if p == "/README" {
+		return plugin.ApplyRangeRead([]byte(kvfs.plugin.GetReadme()), offset, size)
+	}
 
-		if !exists {
-			return nil, fmt.Errorf("key not found: %s", key)
+	root, key := splitPath(p)
+	if root == "" {
+		return nil, filesystem.NewNotFoundError("read", p)
+	}
+	if key == "" {
+		return nil, fmt.Errorf("is a directory: %s", p)
+	}
+
+	entry, exists, err := kvfs.plugin.backend.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if isDir, _ := kvfs.isPrefix(key); isDir {
+			return nil, fmt.Errorf("is a directory: %s", p)
 		}
-		data = value
-	} else {
-		return nil, fmt.Errorf("invalid path: %s", path)
+		return nil, filesystem.NewNotFoundError("read", p)
 	}
 
+	data := entry.Value
+	if root == ttlDir {
+		data = []byte(formatTTL(entry.ExpiresAt) + "\n")
+	}
 	return plugin.ApplyRangeRead(data, offset, size)
 }
 
-func (kvfs *kvFS) Write(path string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
-	if path == "/" || path == "/keys" {
-		return 0, fmt.Errorf("cannot write to directory: %s", path)
+func (kvfs *kvFS) Write(p string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	root, key := splitPath(p)
+	if root == "" || key == "" {
+		return 0, filesystem.NewPermissionDeniedError("write", p, "keys must be under /keys/")
+	}
+	if err := validateKey(key); err != nil {
+		return 0, filesystem.NewInvalidArgumentError("key", key, err.Error())
 	}
 
-	if !strings.HasPrefix(path, "/keys/") {
-		return 0, fmt.Errorf("keys must be under /keys/ directory")
+	if root == ttlDir {
+		ttl, err := parseTTL(string(data))
+		if err != nil {
+			return 0, filesystem.NewInvalidArgumentError("ttl", strings.TrimSpace(string(data)), err.Error())
+		}
+		if err := kvfs.plugin.backend.Expire(key, ttl); err != nil {
+			if errors.Is(err, errKeyNotFound) {
+				return 0, filesystem.NewNotFoundError("write", p)
+			}
+			return 0, err
+		}
+		return int64(len(data)), nil
 	}
 
-	key := strings.TrimPrefix(path, "/keys/")
-	if key == "" {
-		return 0, fmt.Errorf("key name cannot be empty")
+	entry, exists, err := kvfs.plugin.backend.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	if exists && flags&filesystem.WriteFlagExclusive != 0 {
+		return 0, filesystem.NewAlreadyExistsError("key", key)
+	}
+	// Offset -1 without flags is the legacy overwrite, which creates the key
+	legacy := flags == filesystem.WriteFlagNone && offset < 0
+	if !exists && flags&filesystem.WriteFlagCreate == 0 && !legacy {
+		return 0, filesystem.NewNotFoundError("write", p)
 	}
 
-	kvfs.plugin.mu.Lock()
-	defer kvfs.plugin.mu.Unlock()
+	value := data
+	if exists && flags&filesystem.WriteFlagAppend != 0 {
+		value = append(entry.Value, data...)
+	}
 
 	// KV store - offset writes not supported (full value replacement)
-	kvfs.plugin.store[key] = data
+	if err := kvfs.plugin.backend.Set(key, value, kvfs.plugin.defaultTTL); err != nil {
+		return 0, err
+	}
 	return int64(len(data)), nil
 }
 
-func (kvfs *kvFS) ReadDir(path string) ([]filesystem.FileInfo, error) {
-	if path == "/" {
-		// Root directory contains /keys and README
+// formatTTL renders the remaining TTL in whole seconds, -1 if there is none
+func formatTTL(expiresAt time.Time) string {
+	if expiresAt.IsZero() {
+		return "-1"
+	}
+	remaining := time.Until(expiresAt)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return strconv.FormatInt(int64((remaining+time.Second-1)/time.Second), 10)
+}
+
+func (kvfs *kvFS) fileInfo(root string, e KVEntry) filesystem.FileInfo {
+	modTime := e.ModTime
+	if modTime.IsZero() {
+		modTime = time.Now()
+	}
+
+	info := filesystem.FileInfo{
+		Name:    path.Base(e.Key),
+		Size:    e.Size,
+		Mode:    0644,
+		ModTime: modTime,
+		IsDir:   false,
+		Meta: filesystem.MetaData{
+			Name:    PluginName,
+			Type:    MetaValueFile,
+			Content: map[string]string{"ttl": formatTTL(e.ExpiresAt)},
+		},
+	}
+	if !e.ExpiresAt.IsZero() {
+		info.Meta.Content["expires_at"] = e.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	if root == ttlDir {
+		info.Size = int64(len(formatTTL(e.ExpiresAt)) + 1)
+		info.Meta.Type = MetaValueTTL
+	}
+	return info
+}
+
+func dirInfo(name string) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    name,
+		Size:    0,
+		Mode:    0755,
+		ModTime: time.Now(),
+		IsDir:   true,
+		Meta: filesystem.MetaData{
+			Name: PluginName,
+			Type: MetaValueDir,
+		},
+	}
+}
+
+func (kvfs *kvFS) ReadDir(p string) ([]filesystem.FileInfo, error) {
+	if filesystem.NormalizePath(p) == "/" {
 		readme := kvfs.plugin.GetReadme()
 		return []filesystem.FileInfo{
 			{
@@ -289,62 +636,60 @@ func (kvfs *kvFS) ReadDir(path string) ([]filesystem.FileInfo, error) {
 					Type: "doc",
 				},
 			},
-			{
-				Name:    "keys",
-				Size:    0,
-				Mode:    0755,
-				ModTime: time.Now(),
-				IsDir:   true,
-				Meta: filesystem.MetaData{
-					Name: PluginName,
-					Type: MetaValueDir,
-				},
-			},
+			dirInfo("keys"),
+			dirInfo("ttl"),
 		}, nil
 	}
 
-	if path == "/keys" {
-		// List all keys
-		kvfs.plugin.mu.RLock()
-		defer kvfs.plugin.mu.RUnlock()
+	root, key := splitPath(p)
+	if root == "" {
+		return nil, filesystem.NewNotFoundError("readdir", p)
+	}
 
-		files := make([]filesystem.FileInfo, 0, len(kvfs.plugin.store))
-		for key, value := range kvfs.plugin.store {
-			files = append(files, filesystem.FileInfo{
-				Name:    filepath.Base(key),
-				Size:    int64(len(value)),
-				Mode:    0644,
-				ModTime: time.Now(),
-				IsDir:   false,
-				Meta: filesystem.MetaData{
-					Name: PluginName,
-					Type: MetaValueFile,
-				},
-			})
+	prefix := ""
+	if key != "" {
+		prefix = key + "/"
+	}
+
+	entries, err := kvfs.plugin.backend.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if key != "" && len(entries) == 0 {
+		if _, exists, err := kvfs.plugin.backend.Get(key); err == nil && exists {
+			return nil, filesystem.NewNotDirectoryError(p)
 		}
+		return nil, filesystem.NewNotFoundError("readdir", p)
+	}
 
-		return files, nil
+	// Collapse nested keys into their first path component
+	files := make([]filesystem.FileInfo, 0, len(entries))
+	seenDirs := make(map[string]bool)
+	for _, e := range entries {
+		rel := strings.TrimPrefix(e.Key, prefix)
+		if idx := strings.Index(rel, "/"); idx >= 0 {
+			dir := rel[:idx]
+			if !seenDirs[dir] {
+				seenDirs[dir] = true
+				files = append(files, dirInfo(dir))
+			}
+			continue
+		}
+		files = append(files, kvfs.fileInfo(root, e))
 	}
 
-	return nil, fmt.Errorf("not a directory: %s", path)
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
 }
 
-func (kvfs *kvFS) Stat(path string) (*filesystem.FileInfo, error) {
-	if path == "/" || path == "/keys" {
-		return &filesystem.FileInfo{
-			Name:    filepath.Base(path),
-			Size:    0,
-			Mode:    0755,
-			ModTime: time.Now(),
-			IsDir:   true,
-			Meta: filesystem.MetaData{
-				Name: PluginName,
-				Type: MetaValueDir,
-			},
-		}, nil
+func (kvfs *kvFS) Stat(p string) (*filesystem.FileInfo, error) {
+	p = filesystem.NormalizePath(p)
+	if p == "/" {
+		info := dirInfo("/")
+		return &info, nil
 	}
 
-	if path == "/README" {
+	if p == "/README" {
 		readme := kvfs.plugin.GetReadme()
 		return &filesystem.FileInfo{
 			Name:    "README",
@@ -359,64 +704,73 @@ func (kvfs *kvFS) Stat(path string) (*filesystem.FileInfo, error) {
 		}, nil
 	}
 
-	if !strings.HasPrefix(path, "/keys/") {
-		return nil, fmt.Errorf("invalid path: %s", path)
+	root, key := splitPath(p)
+	if root == "" {
+		return nil, filesystem.NewNotFoundError("stat", p)
 	}
-
-	key := strings.TrimPrefix(path, "/keys/")
 	if key == "" {
-		return nil, fmt.Errorf("key name cannot be empty")
+		info := dirInfo(path.Base(root))
+		return &info, nil
 	}
 
-	kvfs.plugin.mu.RLock()
-	defer kvfs.plugin.mu.RUnlock()
-
-	value, exists := kvfs.plugin.store[key]
-	if !exists {
-		return nil, fmt.Errorf("key not found: %s", key)
+	entry, exists, err := kvfs.plugin.backend.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		info := kvfs.fileInfo(root, entry)
+		return &info, nil
 	}
 
-	return &filesystem.FileInfo{
-		Name:    filepath.Base(key),
-		Size:    int64(len(value)),
-		Mode:    0644,
-		ModTime: time.Now(),
-		IsDir:   false,
-		Meta: filesystem.MetaData{
-			Name: PluginName,
-			Type: MetaValueFile,
-		},
-	}, nil
+	isDir, err := kvfs.isPrefix(key)
+	if err != nil {
+		return nil, err
+	}
+	if isDir {
+		info := dirInfo(path.Base(key))
+		return &info, nil
+	}
+	return nil, filesystem.NewNotFoundError("stat", p)
 }
 
 func (kvfs *kvFS) Rename(oldPath, newPath string) error {
-	if !strings.HasPrefix(oldPath, "/keys/") || !strings.HasPrefix(newPath, "/keys/") {
-		return fmt.Errorf("can only rename keys under /keys/")
+	oldRoot, oldKey := splitPath(oldPath)
+	newRoot, newKey := splitPath(newPath)
+	if oldRoot != keysDir || newRoot != keysDir || oldKey == "" || newKey == "" {
+		return filesystem.NewPermissionDeniedError("rename", oldPath, "can only rename keys under /keys/")
 	}
-
-	oldKey := strings.TrimPrefix(oldPath, "/keys/")
-	newKey := strings.TrimPrefix(newPath, "/keys/")
-
-	if oldKey == "" || newKey == "" {
-		return fmt.Errorf("key name cannot be empty")
+	if err := validateKey(newKey); err != nil {
+		return filesystem.NewInvalidArgumentError("key", newKey, err.Error())
 	}
 
-	kvfs.plugin.mu.Lock()
-	defer kvfs.plugin.mu.Unlock()
-
-	value, exists := kvfs.plugin.store[oldKey]
+	entry, exists, err := kvfs.plugin.backend.Get(oldKey)
+	if err != nil {
+		return err
+	}
 	if !exists {
-		return fmt.Errorf("key not found: %s", oldKey)
+		return filesystem.NewNotFoundError("rename", oldPath)
 	}
 
-	if _, exists := kvfs.plugin.store[newKey]; exists {
-		return fmt.Errorf("key already exists: %s", newKey)
+	if _, exists, err := kvfs.plugin.backend.Get(newKey); err != nil {
+		return err
+	} else if exists {
+		return filesystem.NewAlreadyExistsError("key", newKey)
 	}
 
-	kvfs.plugin.store[newKey] = value
-	delete(kvfs.plugin.store, oldKey)
+	// Carry the remaining TTL over to the new key
+	var ttl time.Duration
+	if !entry.ExpiresAt.IsZero() {
+		ttl = time.Until(entry.ExpiresAt)
+		if ttl <= 0 {
+			return filesystem.NewNotFoundError("rename", oldPath)
+		}
+	}
 
-	return nil
+	if err := kvfs.plugin.backend.Set(newKey, entry.Value, ttl); err != nil {
+		return err
+	}
+	_, err = kvfs.plugin.backend.Delete(oldKey)
+	return err
 }
 
 func (kvfs *kvFS) Chmod(path string, mode uint32) error {
@@ -431,7 +785,7 @@ func (kvfs *kvFS) Truncate(path string, size int64) error {
 
 func (kvfs *kvFS) Open(path string) (io.ReadCloser, error) {
 	data, err := kvfs.Read(path, 0, -1)
-	if err != nil {
+	if err != nil && err != io.EOF {
 		return nil, err
 	}
 	return io.NopCloser(bytes.NewReader(data)), nil
@@ -455,4 +809,3 @@ func (kw *kvWriter) Close() error {
 	_, err := kw.kvfs.Write(kw.path, kw.buf.Bytes(), -1, filesystem.WriteFlagNone)
 	return err
 }
-
@@ -0,0 +1,284 @@
+package kvfs
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem/filesystemtest"
+)
+
+// newTestFS creates an initialized kvfs with the given config
+func newTestFS(t *testing.T, cfg map[string]interface{}) *kvFS {
+	t.Helper()
+	p := NewKVFSPlugin()
+	if err := p.Validate(cfg); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if err := p.Initialize(cfg); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	t.Cleanup(func() { p.Shutdown() })
+	return p.GetFileSystem().(*kvFS)
+}
+
+// readIgnoreEOF reads file content, ignoring io.EOF which is expected at end of file
+func readIgnoreEOF(fs *kvFS, path string) ([]byte, error) {
+	content, err := fs.Read(path, 0, -1)
+	if err == io.EOF {
+		return content, nil
+	}
+	return content, err
+}
+
+func testBackends(t *testing.T) map[string]map[string]interface{} {
+	return map[string]map[string]interface{}{
+		"memory": {},
+		"sqlite": {
+			"backend": "sqlite",
+			"db_path": filepath.Join(t.TempDir(), "kvfs.db"),
+		},
+	}
+}
+
+func TestKVFSReadWrite(t *testing.T) {
+	for name, cfg := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			fs := newTestFS(t, cfg)
+
+			if _, err := fs.Write("/keys/greeting", []byte("hello"), -1, filesystem.WriteFlagNone); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			data, err := readIgnoreEOF(fs, "/keys/greeting")
+			if err != nil {
+				t.Fatalf("Read failed: %v", err)
+			}
+			if string(data) != "hello" {
+				t.Errorf("Read = %q, want %q", data, "hello")
+			}
+
+			if _, err := fs.Write("/keys/greeting", []byte(" world"), -1, filesystem.WriteFlagAppend); err != nil {
+				t.Fatalf("Append failed: %v", err)
+			}
+			data, _ = readIgnoreEOF(fs, "/keys/greeting")
+			if string(data) != "hello world" {
+				t.Errorf("Read after append = %q, want %q", data, "hello world")
+			}
+
+			if err := fs.Remove("/keys/greeting"); err != nil {
+				t.Fatalf("Remove failed: %v", err)
+			}
+			if _, err := fs.Read("/keys/greeting", 0, -1); !errors.Is(err, filesystem.ErrNotFound) {
+				t.Errorf("Read after remove: expected ErrNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestKVFSPrefixDirectories(t *testing.T) {
+	for name, cfg := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			fs := newTestFS(t, cfg)
+
+			for _, key := range []string{"users/1/name", "users/1/email", "users/2/name", "top"} {
+				if _, err := fs.Write("/keys/"+key, []byte(key), -1, filesystem.WriteFlagNone); err != nil {
+					t.Fatalf("Write %s failed: %v", key, err)
+				}
+			}
+
+			infos, err := fs.ReadDir("/keys")
+			if err != nil {
+				t.Fatalf("ReadDir failed: %v", err)
+			}
+			if len(infos) != 2 || infos[0].Name != "top" || infos[1].Name != "users" || !infos[1].IsDir {
+				t.Errorf("unexpected /keys listing: %+v", infos)
+			}
+
+			infos, err = fs.ReadDir("/keys/users/1")
+			if err != nil {
+				t.Fatalf("ReadDir failed: %v", err)
+			}
+			if len(infos) != 2 || infos[0].Name != "email" || infos[1].Name != "name" {
+				t.Errorf("unexpected /keys/users/1 listing: %+v", infos)
+			}
+
+			info, err := fs.Stat("/keys/users")
+			if err != nil || !info.IsDir {
+				t.Errorf("Stat /keys/users: expected directory, got %+v, %v", info, err)
+			}
+
+			if err := fs.Remove("/keys/users"); err == nil {
+				t.Error("Remove of non-empty prefix should fail")
+			}
+			if err := fs.RemoveAll("/keys/users"); err != nil {
+				t.Fatalf("RemoveAll failed: %v", err)
+			}
+			if _, err := fs.Stat("/keys/users/2/name"); !errors.Is(err, filesystem.ErrNotFound) {
+				t.Errorf("expected users/* to be removed, got %v", err)
+			}
+			if _, err := fs.Stat("/keys/top"); err != nil {
+				t.Errorf("RemoveAll removed unrelated key: %v", err)
+			}
+		})
+	}
+}
+
+func TestKVFSPrefixCase(t *testing.T) {
+	for name, cfg := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			fs := newTestFS(t, cfg)
+
+			// Keys that differ only in case, or contain LIKE wildcards
+			for _, key := range []string{"Users/1", "users/1", "users/2", "a%b/1", "a_b/1", "axb/1"} {
+				if _, err := fs.Write("/keys/"+key, []byte(key), -1, filesystem.WriteFlagCreate); err != nil {
+					t.Fatalf("Write %s failed: %v", key, err)
+				}
+			}
+
+			infos, err := fs.ReadDir("/keys/Users")
+			if err != nil {
+				t.Fatalf("ReadDir failed: %v", err)
+			}
+			if len(infos) != 1 || infos[0].Name != "1" {
+				t.Errorf("unexpected /keys/Users listing: %+v", infos)
+			}
+			if infos, err := fs.ReadDir("/keys/a_b"); err != nil || len(infos) != 1 {
+				t.Errorf("unexpected /keys/a_b listing: %+v, %v", infos, err)
+			}
+
+			if err := fs.RemoveAll("/keys/Users"); err != nil {
+				t.Fatalf("RemoveAll failed: %v", err)
+			}
+			for _, key := range []string{"users/1", "users/2"} {
+				if _, err := fs.Stat("/keys/" + key); err != nil {
+					t.Errorf("RemoveAll of Users removed %s: %v", key, err)
+				}
+			}
+			if err := fs.RemoveAll("/keys/a_b"); err != nil {
+				t.Fatalf("RemoveAll failed: %v", err)
+			}
+			for _, key := range []string{"a%b/1", "axb/1"} {
+				if _, err := fs.Stat("/keys/" + key); err != nil {
+					t.Errorf("RemoveAll of a_b removed %s: %v", key, err)
+				}
+			}
+		})
+	}
+}
+
+func TestKVFSTTL(t *testing.T) {
+	for name, cfg := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			fs := newTestFS(t, cfg)
+
+			if _, err := fs.Write("/keys/session", []byte("token"), -1, filesystem.WriteFlagNone); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+
+			data, _ := readIgnoreEOF(fs, "/ttl/session")
+			if string(data) != "-1\n" {
+				t.Errorf("TTL of persistent key = %q, want -1", data)
+			}
+
+			if _, err := fs.Write("/ttl/session", []byte("1h"), -1, filesystem.WriteFlagNone); err != nil {
+				t.Fatalf("Set TTL failed: %v", err)
+			}
+			data, _ = readIgnoreEOF(fs, "/ttl/session")
+			if string(data) != "3600\n" {
+				t.Errorf("TTL = %q, want 3600", data)
+			}
+
+			// Overwriting the value keeps the TTL
+			if _, err := fs.Write("/keys/session", []byte("token2"), -1, filesystem.WriteFlagNone); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			info, err := fs.Stat("/keys/session")
+			if err != nil {
+				t.Fatalf("Stat failed: %v", err)
+			}
+			if info.Meta.Content["ttl"] == "-1" {
+				t.Error("TTL was cleared by overwrite")
+			}
+
+			// A short TTL expires the key
+			if _, err := fs.Write("/ttl/session", []byte("50ms"), -1, filesystem.WriteFlagNone); err != nil {
+				t.Fatalf("Set TTL failed: %v", err)
+			}
+			time.Sleep(100 * time.Millisecond)
+			if _, err := fs.Stat("/keys/session"); !errors.Is(err, filesystem.ErrNotFound) {
+				t.Errorf("expected expired key to be gone, got %v", err)
+			}
+
+			if _, err := fs.Write("/ttl/missing", []byte("10"), -1, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrNotFound) {
+				t.Errorf("setting TTL on missing key: expected ErrNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestKVFSDefaultTTLAndInitialData(t *testing.T) {
+	fs := newTestFS(t, map[string]interface{}{
+		"default_ttl":  "10m",
+		"initial_data": map[string]interface{}{"app": "agfs", "version": 2},
+	})
+
+	data, _ := readIgnoreEOF(fs, "/keys/version")
+	if string(data) != "2" {
+		t.Errorf("initial_data value = %q, want %q", data, "2")
+	}
+	data, _ = readIgnoreEOF(fs, "/ttl/app")
+	if string(data) != "600\n" {
+		t.Errorf("default TTL = %q, want 600", data)
+	}
+}
+
+func TestKVFSRename(t *testing.T) {
+	fs := newTestFS(t, map[string]interface{}{})
+
+	fs.Write("/keys/a", []byte("1"), -1, filesystem.WriteFlagNone)
+	fs.Write("/keys/b", []byte("2"), -1, filesystem.WriteFlagNone)
+
+	if err := fs.Rename("/keys/a", "/keys/b"); !errors.Is(err, filesystem.ErrAlreadyExists) {
+		t.Errorf("Rename onto existing key: expected ErrAlreadyExists, got %v", err)
+	}
+	if err := fs.Rename("/keys/a", "/keys/dir/c"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	data, _ := readIgnoreEOF(fs, "/keys/dir/c")
+	if string(data) != "1" {
+		t.Errorf("renamed value = %q, want %q", data, "1")
+	}
+	if _, err := fs.Stat("/keys/a"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("old key still exists: %v", err)
+	}
+}
+
+func TestKVFSValidate(t *testing.T) {
+	p := NewKVFSPlugin()
+	if err := p.Validate(map[string]interface{}{"backend": "etcd"}); err == nil {
+		t.Error("expected error for unsupported backend")
+	}
+	if err := p.Validate(map[string]interface{}{"backend": "tidb"}); err == nil {
+		t.Error("expected error for tidb backend without dsn")
+	}
+	if err := p.Validate(map[string]interface{}{"default_ttl": "soon"}); err == nil {
+		t.Error("expected error for invalid default_ttl")
+	}
+	if err := p.Validate(map[string]interface{}{"unknown": true}); err == nil {
+		t.Error("expected error for unknown key")
+	}
+}
+
+func TestKVFSConformance(t *testing.T) {
+	filesystemtest.Run(t, func(t *testing.T) filesystem.FileSystem {
+		return newTestFS(t, map[string]interface{}{})
+	}, filesystemtest.Options{
+		Root:           "/keys",
+		NoDirectories:  true, // Prefixes only exist while keys are under them
+		NoOffsetWrites: true,
+		NoChmod:        true,
+	})
+}
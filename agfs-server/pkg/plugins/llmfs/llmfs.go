@@ -0,0 +1,858 @@
+package llmfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PluginName = "llmfs" // Name of this plugin
+)
+
+// Meta values for LLMFS plugin
+const (
+	MetaValueConversation = "conversation" // Conversation directory
+	MetaValueInput        = "input"        // Prompt input file
+	MetaValueOutput       = "output"       // Model response file
+	MetaValueSetting      = "setting"      // Per-conversation settings (system, model, provider)
+	MetaValueUsage        = "usage"        // Token usage accounting
+)
+
+// Files inside each conversation directory
+const (
+	fileInput    = "input"
+	fileOutput   = "output"
+	fileHistory  = "history"
+	fileSystem   = "system"
+	fileModel    = "model"
+	fileProvider = "provider"
+	fileUsage    = ".usage"
+)
+
+var conversationFiles = []string{fileInput, fileOutput, fileHistory, fileSystem, fileModel, fileProvider, fileUsage}
+
+var conversationNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
+// UsageStats accumulates token usage
+type UsageStats struct {
+	Requests         int64 `json:"requests"`
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+}
+
+func (u *UsageStats) add(usage Usage) {
+	u.Requests++
+	u.PromptTokens += usage.PromptTokens
+	u.CompletionTokens += usage.CompletionTokens
+	u.TotalTokens += usage.TotalTokens
+}
+
+// Conversation holds the state of one chat
+type Conversation struct {
+	Name     string
+	System   string
+	Model    string
+	Provider string
+	Messages []Message
+	Usage    UsageStats
+	Created  time.Time
+	Modified time.Time
+
+	mu sync.Mutex // Serializes requests so turns stay in order
+}
+
+// lastResponse returns the most recent assistant message
+func (c *Conversation) lastResponse() string {
+	for i := len(c.Messages) - 1; i >= 0; i-- {
+		if c.Messages[i].Role == "assistant" {
+			return c.Messages[i].Content
+		}
+	}
+	return ""
+}
+
+// LLMFSPlugin lets shell-based agents chat with LLMs through files
+// Each conversation is a directory:
+//
+//	/<conv>/input    - write a prompt; the write returns once the model has answered
+//	/<conv>/output   - read the latest model response
+//	/<conv>/history  - read the full conversation as JSON
+//	/<conv>/system   - read/write the system prompt
+//	/<conv>/model    - read/write the model
+//	/<conv>/provider - read/write the provider name
+//	/<conv>/.usage   - token usage of this conversation
+//	/.usage          - token usage across all conversations
+type LLMFSPlugin struct {
+	providers       map[string]Provider
+	defaultProvider string
+	defaultModel    string
+	defaultSystem   string
+	maxTokens       int
+	timeout         time.Duration
+
+	conversations map[string]*Conversation
+	usage         UsageStats
+	usageByModel  map[string]*UsageStats
+	mu            sync.RWMutex // Protects conversations and usage
+
+	metadata plugin.PluginMetadata
+}
+
+// NewLLMFSPlugin creates a new LLM plugin
+func NewLLMFSPlugin() *LLMFSPlugin {
+	return &LLMFSPlugin{
+		conversations: make(map[string]*Conversation),
+		usageByModel:  make(map[string]*UsageStats),
+		metadata: plugin.PluginMetadata{
+			Name:        PluginName,
+			Version:     "1.0.0",
+			Description: "Chat with LLMs through conversation directories with token usage accounting",
+			Author:      "AGFS Server",
+		},
+	}
+}
+
+func (l *LLMFSPlugin) Name() string {
+	return l.metadata.Name
+}
+
+func (l *LLMFSPlugin) Validate(cfg map[string]interface{}) error {
+	allowedKeys := []string{
+		"mount_path", "provider", "api_key", "api_base", "model", "system_prompt",
+		"max_tokens", "timeout", "providers",
+	}
+	if err := config.ValidateOnlyKnownKeys(cfg, allowedKeys); err != nil {
+		return err
+	}
+
+	for _, key := range []string{"provider", "api_key", "api_base", "model", "system_prompt", "timeout"} {
+		if err := config.ValidateStringType(cfg, key); err != nil {
+			return err
+		}
+	}
+	if err := config.ValidateIntType(cfg, "max_tokens"); err != nil {
+		return err
+	}
+	if err := config.ValidateMapType(cfg, "providers"); err != nil {
+		return err
+	}
+
+	if timeout, err := time.ParseDuration(config.GetStringConfig(cfg, "timeout", "120s")); err != nil || timeout <= 0 {
+		return fmt.Errorf("invalid timeout: must be a positive duration such as \"120s\"")
+	}
+
+	_, defaultName, err := parseProviders(cfg)
+	if err != nil {
+		return err
+	}
+	if defaultName == "" {
+		return fmt.Errorf("no provider configured")
+	}
+	return nil
+}
+
+// parseProviders builds provider configs from the top-level keys and the providers map
+// The top-level provider/api_key/api_base define a provider named after its type,
+// which is also the default. Entries in providers are addressed by their map key.
+func parseProviders(cfg map[string]interface{}) (map[string]ProviderConfig, string, error) {
+	configs := make(map[string]ProviderConfig)
+
+	defaultType := config.GetStringConfig(cfg, "provider", "openai")
+	configs[defaultType] = ProviderConfig{
+		Type:    defaultType,
+		APIKey:  config.GetStringConfig(cfg, "api_key", ""),
+		APIBase: config.GetStringConfig(cfg, "api_base", ""),
+	}
+
+	if raw, ok := cfg["providers"].(map[string]interface{}); ok {
+		for name, v := range raw {
+			entry, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, "", fmt.Errorf("providers.%s must be a map", name)
+			}
+			configs[name] = ProviderConfig{
+				Type:    config.GetStringConfig(entry, "type", name),
+				APIKey:  config.GetStringConfig(entry, "api_key", ""),
+				APIBase: config.GetStringConfig(entry, "api_base", ""),
+			}
+		}
+	}
+
+	for name, pc := range configs {
+		if pc.Type != "openai" && pc.Type != "anthropic" {
+			return nil, "", fmt.Errorf("provider %s: unsupported type %q (valid options: openai, anthropic)", name, pc.Type)
+		}
+	}
+	return configs, defaultType, nil
+}
+
+func (l *LLMFSPlugin) Initialize(cfg map[string]interface{}) error {
+	configs, defaultName, err := parseProviders(cfg)
+	if err != nil {
+		return err
+	}
+
+	l.timeout, err = time.ParseDuration(config.GetStringConfig(cfg, "timeout", "120s"))
+	if err != nil {
+		return fmt.Errorf("invalid timeout: %w", err)
+	}
+
+	client := &http.Client{}
+	l.providers = make(map[string]Provider, len(configs))
+	for name, pc := range configs {
+		p, err := NewProvider(pc, client)
+		if err != nil {
+			return fmt.Errorf("provider %s: %w", name, err)
+		}
+		l.providers[name] = p
+	}
+
+	l.defaultProvider = defaultName
+	defaultModel := "gpt-4o-mini"
+	if configs[defaultName].Type == "anthropic" {
+		defaultModel = "claude-3-5-haiku-latest"
+	}
+	l.defaultModel = config.GetStringConfig(cfg, "model", defaultModel)
+	l.defaultSystem = config.GetStringConfig(cfg, "system_prompt", "")
+	l.maxTokens = config.GetIntConfig(cfg, "max_tokens", 1024)
+
+	log.Infof("[llmfs] Initialized with provider=%s, model=%s, %d provider(s) configured",
+		l.defaultProvider, l.defaultModel, len(l.providers))
+	return nil
+}
+
+func (l *LLMFSPlugin) GetFileSystem() filesystem.FileSystem {
+	return &llmFS{plugin: l}
+}
+
+func (l *LLMFSPlugin) GetReadme() string {
+	return `LLMFS Plugin - Chat with LLMs through Files
+
+This plugin lets shell-based agents call LLMs without an HTTP client.
+Each conversation is a directory; writing a prompt to input sends it
+to the model together with the conversation history.
+
+STRUCTURE:
+  /llmfs/
+    README              - This documentation
+    .usage              - Token usage across all conversations (JSON)
+    <conversation>/     - A conversation (mkdir, or write to its input)
+      input             - Write-only: each write is one user message
+      output            - Read-only: latest model response
+      history           - Read-only: all messages as JSON
+      system            - System prompt (read/write)
+      model             - Model name (read/write)
+      provider          - Provider name (read/write)
+      .usage            - Token usage of this conversation (JSON)
+
+WORKFLOW:
+  agfs:/> mkdir /llmfs/review
+  agfs:/> echo "You are a strict code reviewer." > /llmfs/review/system
+  agfs:/> echo "Review: func add(a, b int) int { return a - b }" > /llmfs/review/input
+  agfs:/> cat /llmfs/review/output
+  agfs:/> echo "How would you fix it?" > /llmfs/review/input
+  agfs:/> cat /llmfs/review/output
+  agfs:/> cat /llmfs/review/.usage
+
+  The write to input returns once the model has answered, so output can be
+  read right after it. A failed request returns an error from the write
+  and leaves the conversation unchanged.
+
+  Remove a conversation with: rm -rf /llmfs/review
+
+PROVIDERS:
+  openai      - OpenAI chat completions API, and any compatible endpoint
+                (OpenRouter, vLLM, Ollama, ...) via api_base
+  anthropic   - Anthropic Messages API
+
+  If api_key is empty, OPENAI_API_KEY or ANTHROPIC_API_KEY is used.
+
+CONFIGURATION:
+  [plugins.llmfs]
+  enabled = true
+  path = "/llmfs"
+
+    [plugins.llmfs.config]
+    provider = "openai"
+    api_key = "sk-..."
+    model = "gpt-4o-mini"
+    system_prompt = "You are a helpful assistant."
+    max_tokens = 1024
+    timeout = "120s"
+
+  Additional named providers, selectable per conversation:
+    [plugins.llmfs.config.providers.claude]
+    type = "anthropic"
+    api_key = "sk-ant-..."
+
+    [plugins.llmfs.config.providers.local]
+    type = "openai"
+    api_base = "http://localhost:11434/v1"
+
+  agfs:/> echo claude > /llmfs/review/provider
+  agfs:/> echo claude-sonnet-4-5 > /llmfs/review/model
+
+NOTES:
+  - Conversations are kept in memory and are lost when the server restarts.
+`
+}
+
+func (l *LLMFSPlugin) GetConfigParams() []plugin.ConfigParameter {
+	return []plugin.ConfigParameter{
+		{
+			Name:        "provider",
+			Type:        "string",
+			Required:    false,
+			Default:     "openai",
+			Description: "Default provider type (openai, anthropic)",
+			Enum:        []string{"openai", "anthropic"},
+		},
+		{
+			Name:        "api_key",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "API key for the default provider (falls back to OPENAI_API_KEY / ANTHROPIC_API_KEY)",
+			Secret:      true,
+		},
+		{
+			Name:        "api_base",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "API base URL for the default provider",
+		},
+		{
+			Name:        "model",
+			Type:        "string",
+			Required:    false,
+			Default:     "gpt-4o-mini",
+			Description: "Default model for new conversations",
+		},
+		{
+			Name:        "system_prompt",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Default system prompt for new conversations",
+		},
+		{
+			Name:        "max_tokens",
+			Type:        "int",
+			Required:    false,
+			Default:     "1024",
+			Description: "Maximum tokens per response",
+		},
+		{
+			Name:        "timeout",
+			Type:        "string",
+			Required:    false,
+			Default:     "120s",
+			Description: "Timeout of a single model request",
+		},
+		{
+			Name:        "providers",
+			Type:        "map",
+			Required:    false,
+			Default:     "",
+			Description: "Additional named providers: name -> {type, api_key, api_base}",
+		},
+	}
+}
+
+func (l *LLMFSPlugin) Shutdown() error {
+	return nil
+}
+
+// llmFS implements the FileSystem interface for conversations
+type llmFS struct {
+	plugin *LLMFSPlugin
+}
+
+// parseLLMPath splits a path into conversation and file
+func parseLLMPath(p string) (conv string, file string, err error) {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return "", "", nil
+	}
+
+	parts := strings.Split(p, "/")
+	if len(parts) > 2 {
+		return "", "", filesystem.NewNotFoundError("stat", "/"+p)
+	}
+	if !conversationNamePattern.MatchString(parts[0]) {
+		return "", "", filesystem.NewInvalidArgumentError("conversation", parts[0], "must match [A-Za-z0-9._-] and not start with '.'")
+	}
+	if len(parts) == 1 {
+		return parts[0], "", nil
+	}
+	for _, f := range conversationFiles {
+		if f == parts[1] {
+			return parts[0], parts[1], nil
+		}
+	}
+	return "", "", filesystem.NewNotFoundError("stat", "/"+p)
+}
+
+func (lfs *llmFS) getConversation(name, op, path string) (*Conversation, error) {
+	lfs.plugin.mu.RLock()
+	defer lfs.plugin.mu.RUnlock()
+
+	conv, ok := lfs.plugin.conversations[name]
+	if !ok {
+		return nil, filesystem.NewNotFoundError(op, path)
+	}
+	return conv, nil
+}
+
+func (lfs *llmFS) getOrCreateConversation(name string) *Conversation {
+	lfs.plugin.mu.Lock()
+	defer lfs.plugin.mu.Unlock()
+
+	if conv, ok := lfs.plugin.conversations[name]; ok {
+		return conv
+	}
+	now := time.Now()
+	conv := &Conversation{
+		Name:     name,
+		System:   lfs.plugin.defaultSystem,
+		Model:    lfs.plugin.defaultModel,
+		Provider: lfs.plugin.defaultProvider,
+		Created:  now,
+		Modified: now,
+	}
+	lfs.plugin.conversations[name] = conv
+	return conv
+}
+
+func (lfs *llmFS) Create(path string) error {
+	conv, file, err := parseLLMPath(path)
+	if err != nil {
+		return err
+	}
+	if conv == "" || file == "" {
+		return filesystem.NewPermissionDeniedError("create", path, "only conversation files exist in llmfs")
+	}
+	lfs.getOrCreateConversation(conv)
+	return nil
+}
+
+func (lfs *llmFS) Mkdir(path string, perm uint32) error {
+	conv, file, err := parseLLMPath(path)
+	if err != nil {
+		return err
+	}
+	if conv == "" || file != "" {
+		return filesystem.NewAlreadyExistsError("directory", path)
+	}
+
+	lfs.plugin.mu.RLock()
+	_, exists := lfs.plugin.conversations[conv]
+	lfs.plugin.mu.RUnlock()
+	if exists {
+		return filesystem.NewAlreadyExistsError("conversation", path)
+	}
+	lfs.getOrCreateConversation(conv)
+	return nil
+}
+
+func (lfs *llmFS) Remove(path string) error {
+	return lfs.RemoveAll(path)
+}
+
+func (lfs *llmFS) RemoveAll(path string) error {
+	conv, file, err := parseLLMPath(path)
+	if err != nil {
+		return err
+	}
+	if conv == "" || file != "" {
+		return filesystem.NewPermissionDeniedError("remove", path, "only conversations can be removed")
+	}
+
+	lfs.plugin.mu.Lock()
+	defer lfs.plugin.mu.Unlock()
+	if _, ok := lfs.plugin.conversations[conv]; !ok {
+		return filesystem.NewNotFoundError("remove", path)
+	}
+	delete(lfs.plugin.conversations, conv)
+	return nil
+}
+
+func (lfs *llmFS) Read(path string, offset int64, size int64) ([]byte, error) {
+	data, err := lfs.readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return plugin.ApplyRangeRead(data, offset, size)
+}
+
+func (lfs *llmFS) readFile(path string) ([]byte, error) {
+	switch strings.Trim(path, "/") {
+	case "README":
+		return []byte(lfs.plugin.GetReadme()), nil
+	case fileUsage:
+		return lfs.globalUsage()
+	}
+
+	name, file, err := parseLLMPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" || file == "" {
+		return nil, fmt.Errorf("is a directory: %s", path)
+	}
+
+	conv, err := lfs.getConversation(name, "read", path)
+	if err != nil {
+		return nil, err
+	}
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	switch file {
+	case fileOutput:
+		return withNewline(conv.lastResponse()), nil
+	case fileHistory:
+		messages := conv.Messages
+		if messages == nil {
+			messages = []Message{}
+		}
+		return json.MarshalIndent(messages, "", "  ")
+	case fileSystem:
+		return withNewline(conv.System), nil
+	case fileModel:
+		return withNewline(conv.Model), nil
+	case fileProvider:
+		return withNewline(conv.Provider), nil
+	case fileUsage:
+		return json.MarshalIndent(conv.Usage, "", "  ")
+	}
+	return nil, filesystem.NewPermissionDeniedError("read", path, "write-only file")
+}
+
+func withNewline(s string) []byte {
+	if s == "" || strings.HasSuffix(s, "\n") {
+		return []byte(s)
+	}
+	return []byte(s + "\n")
+}
+
+func (lfs *llmFS) globalUsage() ([]byte, error) {
+	lfs.plugin.mu.RLock()
+	defer lfs.plugin.mu.RUnlock()
+
+	byModel := make(map[string]UsageStats, len(lfs.plugin.usageByModel))
+	for model, u := range lfs.plugin.usageByModel {
+		byModel[model] = *u
+	}
+	return json.MarshalIndent(struct {
+		UsageStats
+		ByModel map[string]UsageStats `json:"by_model"`
+	}{lfs.plugin.usage, byModel}, "", "  ")
+}
+
+func (lfs *llmFS) Write(path string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	name, file, err := parseLLMPath(path)
+	if err != nil {
+		return 0, err
+	}
+	if name == "" || file == "" {
+		return 0, fmt.Errorf("is a directory: %s", path)
+	}
+
+	value := strings.TrimSpace(string(data))
+	switch file {
+	case fileInput:
+		if value == "" {
+			return 0, filesystem.NewInvalidArgumentError("input", "", "prompt must not be empty")
+		}
+		if err := lfs.chat(lfs.getOrCreateConversation(name), value); err != nil {
+			return 0, err
+		}
+	case fileSystem:
+		conv := lfs.getOrCreateConversation(name)
+		conv.mu.Lock()
+		conv.System = value
+		conv.Modified = time.Now()
+		conv.mu.Unlock()
+	case fileModel:
+		if value == "" {
+			return 0, filesystem.NewInvalidArgumentError("model", "", "model must not be empty")
+		}
+		conv := lfs.getOrCreateConversation(name)
+		conv.mu.Lock()
+		conv.Model = value
+		conv.Modified = time.Now()
+		conv.mu.Unlock()
+	case fileProvider:
+		if _, ok := lfs.plugin.providers[value]; !ok {
+			return 0, filesystem.NewInvalidArgumentError("provider", value, "not configured; available: "+strings.Join(lfs.providerNames(), ", "))
+		}
+		conv := lfs.getOrCreateConversation(name)
+		conv.mu.Lock()
+		conv.Provider = value
+		conv.Modified = time.Now()
+		conv.mu.Unlock()
+	default:
+		return 0, filesystem.NewPermissionDeniedError("write", path, "read-only file")
+	}
+	return int64(len(data)), nil
+}
+
+func (lfs *llmFS) providerNames() []string {
+	names := make([]string, 0, len(lfs.plugin.providers))
+	for name := range lfs.plugin.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// chat sends a prompt with the conversation history and records the answer
+func (lfs *llmFS) chat(conv *Conversation, prompt string) error {
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	provider, ok := lfs.plugin.providers[conv.Provider]
+	if !ok {
+		return fmt.Errorf("provider not configured: %s", conv.Provider)
+	}
+
+	messages := append(append([]Message(nil), conv.Messages...), Message{Role: "user", Content: prompt})
+
+	ctx, cancel := context.WithTimeout(context.Background(), lfs.plugin.timeout)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := provider.Chat(ctx, ChatRequest{
+		Model:     conv.Model,
+		System:    conv.System,
+		Messages:  messages,
+		MaxTokens: lfs.plugin.maxTokens,
+	})
+	if err != nil {
+		log.Warnf("[llmfs] Request for conversation %s failed: %v", conv.Name, err)
+		return fmt.Errorf("%s request failed: %w", conv.Provider, err)
+	}
+
+	conv.Messages = append(messages, Message{Role: "assistant", Content: resp.Content})
+	conv.Usage.add(resp.Usage)
+	conv.Modified = time.Now()
+
+	lfs.plugin.mu.Lock()
+	lfs.plugin.usage.add(resp.Usage)
+	byModel, ok := lfs.plugin.usageByModel[conv.Model]
+	if !ok {
+		byModel = &UsageStats{}
+		lfs.plugin.usageByModel[conv.Model] = byModel
+	}
+	byModel.add(resp.Usage)
+	lfs.plugin.mu.Unlock()
+
+	log.Debugf("[llmfs] %s/%s answered in %v (%d tokens)", conv.Provider, conv.Model, time.Since(start), resp.Usage.TotalTokens)
+	return nil
+}
+
+func dirInfo(name string, modTime time.Time) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    name,
+		Size:    0,
+		Mode:    0755,
+		ModTime: modTime,
+		IsDir:   true,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: MetaValueConversation},
+	}
+}
+
+func (lfs *llmFS) fileInfo(path, name string, modTime time.Time) filesystem.FileInfo {
+	mode := uint32(0644)
+	metaType := MetaValueSetting
+	switch name {
+	case fileInput:
+		mode = 0222
+		metaType = MetaValueInput
+	case fileOutput, fileHistory:
+		mode = 0444
+		metaType = MetaValueOutput
+	case fileUsage:
+		mode = 0444
+		metaType = MetaValueUsage
+	case "README":
+		mode = 0444
+		metaType = "doc"
+	}
+
+	var size int64
+	if mode != 0222 {
+		data, _ := lfs.readFile(path)
+		size = int64(len(data))
+	}
+	return filesystem.FileInfo{
+		Name:    name,
+		Size:    size,
+		Mode:    mode,
+		ModTime: modTime,
+		IsDir:   false,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: metaType},
+	}
+}
+
+func (lfs *llmFS) ReadDir(path string) ([]filesystem.FileInfo, error) {
+	name, file, err := parseLLMPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if file != "" {
+		return nil, filesystem.NewNotDirectoryError(path)
+	}
+
+	now := time.Now()
+	if name == "" {
+		files := []filesystem.FileInfo{
+			lfs.fileInfo("/README", "README", now),
+			lfs.fileInfo("/"+fileUsage, fileUsage, now),
+		}
+
+		lfs.plugin.mu.RLock()
+		names := make([]string, 0, len(lfs.plugin.conversations))
+		for n := range lfs.plugin.conversations {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			files = append(files, dirInfo(n, lfs.plugin.conversations[n].Modified))
+		}
+		lfs.plugin.mu.RUnlock()
+		return files, nil
+	}
+
+	conv, err := lfs.getConversation(name, "readdir", path)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]filesystem.FileInfo, 0, len(conversationFiles))
+	for _, f := range conversationFiles {
+		files = append(files, lfs.fileInfo("/"+name+"/"+f, f, conv.Modified))
+	}
+	return files, nil
+}
+
+func (lfs *llmFS) Stat(path string) (*filesystem.FileInfo, error) {
+	now := time.Now()
+	switch trimmed := strings.Trim(path, "/"); trimmed {
+	case "":
+		info := dirInfo("/", now)
+		info.Meta.Content = map[string]string{
+			"provider": lfs.plugin.defaultProvider,
+			"model":    lfs.plugin.defaultModel,
+		}
+		return &info, nil
+	case "README", fileUsage:
+		info := lfs.fileInfo(path, trimmed, now)
+		return &info, nil
+	}
+
+	name, file, err := parseLLMPath(path)
+	if err != nil {
+		return nil, err
+	}
+	conv, err := lfs.getConversation(name, "stat", path)
+	if err != nil {
+		return nil, err
+	}
+
+	var info filesystem.FileInfo
+	if file == "" {
+		info = dirInfo(name, conv.Modified)
+	} else {
+		info = lfs.fileInfo(path, file, conv.Modified)
+	}
+	return &info, nil
+}
+
+func (lfs *llmFS) Rename(oldPath, newPath string) error {
+	oldName, oldFile, err := parseLLMPath(oldPath)
+	if err != nil {
+		return err
+	}
+	newName, newFile, err := parseLLMPath(newPath)
+	if err != nil {
+		return err
+	}
+	if oldName == "" || newName == "" || oldFile != "" || newFile != "" {
+		return filesystem.NewNotSupportedError("rename", oldPath)
+	}
+
+	lfs.plugin.mu.Lock()
+	defer lfs.plugin.mu.Unlock()
+
+	conv, ok := lfs.plugin.conversations[oldName]
+	if !ok {
+		return filesystem.NewNotFoundError("rename", oldPath)
+	}
+	if _, exists := lfs.plugin.conversations[newName]; exists {
+		return filesystem.NewAlreadyExistsError("conversation", newPath)
+	}
+	delete(lfs.plugin.conversations, oldName)
+	conv.mu.Lock()
+	conv.Name = newName
+	conv.mu.Unlock()
+	lfs.plugin.conversations[newName] = conv
+	return nil
+}
+
+func (lfs *llmFS) Chmod(path string, mode uint32) error {
+	return filesystem.NewNotSupportedError("chmod", path)
+}
+
+// Truncate is a no-op so shell redirections like `echo prompt > input` work
+func (lfs *llmFS) Truncate(path string, size int64) error {
+	return nil
+}
+
+func (lfs *llmFS) Open(path string) (io.ReadCloser, error) {
+	data, err := lfs.readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (lfs *llmFS) OpenWrite(path string) (io.WriteCloser, error) {
+	return &llmWriter{lfs: lfs, path: path, buf: &bytes.Buffer{}}, nil
+}
+
+// llmWriter buffers a streamed write so a prompt is sent as one message
+type llmWriter struct {
+	lfs  *llmFS
+	path string
+	buf  *bytes.Buffer
+}
+
+func (lw *llmWriter) Write(p []byte) (n int, err error) {
+	return lw.buf.Write(p)
+}
+
+func (lw *llmWriter) Close() error {
+	_, err := lw.lfs.Write(lw.path, lw.buf.Bytes(), 0, filesystem.WriteFlagNone)
+	return err
+}
+
+// Ensure LLMFSPlugin implements ServicePlugin
+var _ plugin.ServicePlugin = (*LLMFSPlugin)(nil)
+var _ filesystem.FileSystem = (*llmFS)(nil)
@@ -0,0 +1,230 @@
+package llmfs
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+// newOpenAIServer returns a fake chat completions endpoint that echoes the last user message
+func newOpenAIServer(t *testing.T, requests *[]map[string]interface{}) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		*requests = append(*requests, body)
+
+		msgs := body["messages"].([]interface{})
+		last := msgs[len(msgs)-1].(map[string]interface{})["content"].(string)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []interface{}{
+				map[string]interface{}{"message": map[string]string{"role": "assistant", "content": "echo: " + last}},
+			},
+			"usage": map[string]int{"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestFS(t *testing.T, cfg map[string]interface{}) *llmFS {
+	t.Helper()
+	p := NewLLMFSPlugin()
+	if err := p.Validate(cfg); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if err := p.Initialize(cfg); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	return p.GetFileSystem().(*llmFS)
+}
+
+// readIgnoreEOF reads file content, ignoring io.EOF which is expected at end of file
+func readIgnoreEOF(fs *llmFS, path string) ([]byte, error) {
+	content, err := fs.Read(path, 0, -1)
+	if err == io.EOF {
+		return content, nil
+	}
+	return content, err
+}
+
+func TestLLMFSConversation(t *testing.T) {
+	var requests []map[string]interface{}
+	srv := newOpenAIServer(t, &requests)
+	fs := newTestFS(t, map[string]interface{}{
+		"api_key":       "test-key",
+		"api_base":      srv.URL,
+		"model":         "test-model",
+		"system_prompt": "be brief",
+	})
+
+	if _, err := fs.Write("/chat/input", []byte("hello\n"), 0, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("Write input failed: %v", err)
+	}
+	data, _ := readIgnoreEOF(fs, "/chat/output")
+	if string(data) != "echo: hello\n" {
+		t.Errorf("output = %q", data)
+	}
+
+	if _, err := fs.Write("/chat/input", []byte("again"), 0, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("Write input failed: %v", err)
+	}
+
+	// The second request carries the system prompt and the full history
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+	msgs := requests[1]["messages"].([]interface{})
+	if len(msgs) != 4 || msgs[0].(map[string]interface{})["role"] != "system" || requests[1]["model"] != "test-model" {
+		t.Errorf("unexpected second request: %+v", requests[1])
+	}
+
+	var history []Message
+	data, _ = readIgnoreEOF(fs, "/chat/history")
+	if err := json.Unmarshal(data, &history); err != nil || len(history) != 4 {
+		t.Errorf("history = %s, %v", data, err)
+	}
+
+	var usage UsageStats
+	data, _ = readIgnoreEOF(fs, "/chat/.usage")
+	json.Unmarshal(data, &usage)
+	if usage.Requests != 2 || usage.TotalTokens != 30 {
+		t.Errorf("conversation usage = %+v", usage)
+	}
+
+	var global struct {
+		UsageStats
+		ByModel map[string]UsageStats `json:"by_model"`
+	}
+	data, _ = readIgnoreEOF(fs, "/.usage")
+	json.Unmarshal(data, &global)
+	if global.PromptTokens != 20 || global.ByModel["test-model"].CompletionTokens != 10 {
+		t.Errorf("global usage = %s", data)
+	}
+
+	if _, err := fs.Read("/chat/input", 0, -1); !errors.Is(err, filesystem.ErrPermissionDenied) {
+		t.Errorf("reading input: expected ErrPermissionDenied, got %v", err)
+	}
+	if _, err := fs.Write("/chat/input", []byte("  "), 0, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrInvalidArgument) {
+		t.Errorf("empty prompt: expected ErrInvalidArgument, got %v", err)
+	}
+}
+
+func TestLLMFSRequestFailureLeavesHistory(t *testing.T) {
+	var requests []map[string]interface{}
+	srv := newOpenAIServer(t, &requests)
+	fs := newTestFS(t, map[string]interface{}{"api_key": "wrong", "api_base": srv.URL})
+
+	if _, err := fs.Write("/c/input", []byte("hi"), 0, filesystem.WriteFlagNone); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("expected HTTP 401 error, got %v", err)
+	}
+	data, _ := readIgnoreEOF(fs, "/c/history")
+	if strings.TrimSpace(string(data)) != "[]" {
+		t.Errorf("history after failed request = %s", data)
+	}
+}
+
+func TestLLMFSAnthropicProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.URL.Path != "/messages" || r.Header.Get("x-api-key") != "ant-key" || body["system"] != "sys" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"content": []interface{}{map[string]string{"type": "text", "text": "bonjour"}},
+			"usage":   map[string]int{"input_tokens": 7, "output_tokens": 3},
+		})
+	}))
+	defer srv.Close()
+
+	fs := newTestFS(t, map[string]interface{}{
+		"api_key": "unused",
+		"providers": map[string]interface{}{
+			"claude": map[string]interface{}{"type": "anthropic", "api_key": "ant-key", "api_base": srv.URL},
+		},
+	})
+
+	fs.Write("/fr/system", []byte("sys"), 0, filesystem.WriteFlagNone)
+	if _, err := fs.Write("/fr/provider", []byte("claude\n"), 0, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("set provider failed: %v", err)
+	}
+	if _, err := fs.Write("/fr/provider", []byte("missing"), 0, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrInvalidArgument) {
+		t.Errorf("unknown provider: expected ErrInvalidArgument, got %v", err)
+	}
+	if _, err := fs.Write("/fr/input", []byte("hello"), 0, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("Write input failed: %v", err)
+	}
+
+	data, _ := readIgnoreEOF(fs, "/fr/output")
+	if string(data) != "bonjour\n" {
+		t.Errorf("output = %q", data)
+	}
+	var usage UsageStats
+	data, _ = readIgnoreEOF(fs, "/fr/.usage")
+	json.Unmarshal(data, &usage)
+	if usage.TotalTokens != 10 {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestLLMFSDirectories(t *testing.T) {
+	fs := newTestFS(t, map[string]interface{}{"api_key": "k"})
+
+	if err := fs.Mkdir("/a", 0755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	if err := fs.Mkdir("/a", 0755); !errors.Is(err, filesystem.ErrAlreadyExists) {
+		t.Errorf("Mkdir existing: expected ErrAlreadyExists, got %v", err)
+	}
+	if err := fs.Mkdir("/.hidden", 0755); !errors.Is(err, filesystem.ErrInvalidArgument) {
+		t.Errorf("Mkdir dot name: expected ErrInvalidArgument, got %v", err)
+	}
+
+	infos, err := fs.ReadDir("/")
+	if err != nil || len(infos) != 3 || infos[2].Name != "a" {
+		t.Errorf("ReadDir / = %+v, %v", infos, err)
+	}
+	infos, err = fs.ReadDir("/a")
+	if err != nil || len(infos) != len(conversationFiles) {
+		t.Errorf("ReadDir /a = %+v, %v", infos, err)
+	}
+
+	if err := fs.Rename("/a", "/b"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if err := fs.RemoveAll("/b"); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	if _, err := fs.Stat("/b"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("removed conversation still exists: %v", err)
+	}
+}
+
+func TestLLMFSValidate(t *testing.T) {
+	p := NewLLMFSPlugin()
+	if err := p.Validate(map[string]interface{}{"provider": "gemini"}); err == nil {
+		t.Error("expected error for unsupported provider")
+	}
+	if err := p.Validate(map[string]interface{}{"timeout": "soon"}); err == nil {
+		t.Error("expected error for invalid timeout")
+	}
+	if err := p.Validate(map[string]interface{}{"providers": map[string]interface{}{"x": "y"}}); err == nil {
+		t.Error("expected error for malformed providers entry")
+	}
+}
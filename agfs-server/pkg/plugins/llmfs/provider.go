@@ -0,0 +1,229 @@
+package llmfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Message is a single chat message
+type Message struct {
+	Role    string `json:"role"` // system, user or assistant
+	Content string `json:"content"`
+}
+
+// ChatRequest is a provider-independent chat completion request
+type ChatRequest struct {
+	Model     string
+	System    string
+	Messages  []Message // user/assistant turns, oldest first
+	MaxTokens int
+}
+
+// Usage reports token consumption of a request
+type Usage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+}
+
+// ChatResponse is a provider-independent chat completion response
+type ChatResponse struct {
+	Content string
+	Usage   Usage
+}
+
+// Provider sends chat requests to an LLM API
+type Provider interface {
+	// Type returns the provider type (openai, anthropic)
+	Type() string
+
+	// Chat sends a request and returns the model response
+	Chat(ctx context.Context, req ChatRequest) (ChatResponse, error)
+}
+
+// ProviderConfig configures a named provider
+type ProviderConfig struct {
+	Type    string // openai or anthropic
+	APIKey  string
+	APIBase string
+}
+
+// NewProvider creates a provider from its configuration
+// An empty api_key falls back to OPENAI_API_KEY / ANTHROPIC_API_KEY.
+func NewProvider(cfg ProviderConfig, client *http.Client) (Provider, error) {
+	switch cfg.Type {
+	case "openai":
+		if cfg.APIBase == "" {
+			cfg.APIBase = "https://api.openai.com/v1"
+		}
+		if cfg.APIKey == "" {
+			cfg.APIKey = os.Getenv("OPENAI_API_KEY")
+		}
+		return &openAIProvider{cfg: cfg, client: client}, nil
+	case "anthropic":
+		if cfg.APIBase == "" {
+			cfg.APIBase = "https://api.anthropic.com/v1"
+		}
+		if cfg.APIKey == "" {
+			cfg.APIKey = os.Getenv("ANTHROPIC_API_KEY")
+		}
+		return &anthropicProvider{cfg: cfg, client: client}, nil
+	default:
+		return nil, fmt.Errorf("unsupported provider type: %s (valid options: openai, anthropic)", cfg.Type)
+	}
+}
+
+// postJSON sends a JSON request and decodes a JSON response
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// openAIProvider talks to the OpenAI chat completions API
+// Any OpenAI-compatible endpoint (OpenRouter, vLLM, Ollama, ...) works via api_base.
+type openAIProvider struct {
+	cfg    ProviderConfig
+	client *http.Client
+}
+
+func (p *openAIProvider) Type() string {
+	return "openai"
+}
+
+func (p *openAIProvider) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	messages := make([]Message, 0, len(req.Messages)+1)
+	if req.System != "" {
+		messages = append(messages, Message{Role: "system", Content: req.System})
+	}
+	messages = append(messages, req.Messages...)
+
+	body := map[string]interface{}{
+		"model":    req.Model,
+		"messages": messages,
+	}
+	if req.MaxTokens > 0 {
+		body["max_tokens"] = req.MaxTokens
+	}
+
+	headers := map[string]string{}
+	if p.cfg.APIKey != "" {
+		headers["Authorization"] = "Bearer " + p.cfg.APIKey
+	}
+
+	var resp struct {
+		Choices []struct {
+			Message Message `json:"message"`
+		} `json:"choices"`
+		Usage Usage `json:"usage"`
+	}
+	url := strings.TrimSuffix(p.cfg.APIBase, "/") + "/chat/completions"
+	if err := postJSON(ctx, p.client, url, headers, body, &resp); err != nil {
+		return ChatResponse{}, err
+	}
+	if len(resp.Choices) == 0 {
+		return ChatResponse{}, fmt.Errorf("response contained no choices")
+	}
+
+	usage := resp.Usage
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	return ChatResponse{Content: resp.Choices[0].Message.Content, Usage: usage}, nil
+}
+
+// anthropicProvider talks to the Anthropic Messages API
+type anthropicProvider struct {
+	cfg    ProviderConfig
+	client *http.Client
+}
+
+func (p *anthropicProvider) Type() string {
+	return "anthropic"
+}
+
+func (p *anthropicProvider) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	maxTokens := req.MaxTokens
+	if maxTokens <= 0 {
+		// max_tokens is mandatory for this API
+		maxTokens = 1024
+	}
+	body := map[string]interface{}{
+		"model":      req.Model,
+		"messages":   req.Messages,
+		"max_tokens": maxTokens,
+	}
+	if req.System != "" {
+		body["system"] = req.System
+	}
+
+	headers := map[string]string{
+		"x-api-key":         p.cfg.APIKey,
+		"anthropic-version": "2023-06-01",
+	}
+
+	var resp struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		Usage struct {
+			InputTokens  int64 `json:"input_tokens"`
+			OutputTokens int64 `json:"output_tokens"`
+		} `json:"usage"`
+	}
+	url := strings.TrimSuffix(p.cfg.APIBase, "/") + "/messages"
+	if err := postJSON(ctx, p.client, url, headers, body, &resp); err != nil {
+		return ChatResponse{}, err
+	}
+
+	var sb strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			sb.WriteString(block.Text)
+		}
+	}
+	return ChatResponse{
+		Content: sb.String(),
+		Usage: Usage{
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+			TotalTokens:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
+		},
+	}, nil
+}
@@ -217,7 +217,7 @@ func (fs *LocalFS) Read(path string, offset int64, size int64) ([]byte, error) {
 
 	// Determine read size
 	readSize := size
-	if size < 0 || offset+size > fileSize {
+	if size < 0 || size > fileSize-offset {
 		readSize = fileSize - offset
 	}
 
@@ -293,7 +293,9 @@ func (fs *LocalFS) Write(path string, data []byte, offset int64, flags filesyste
 	}
 
 	if flags&filesystem.WriteFlagSync != 0 {
-		f.Sync()
+		if err := f.Sync(); err != nil {
+			return 0, fmt.Errorf("failed to sync: %w", err)
+		}
 	}
 
 	return int64(n), nil
@@ -847,7 +849,27 @@ func (fs *LocalFS) Truncate(path string, size int64) error {
 	return nil
 }
 
+// Sync flushes a file (or directory entry) to disk
+func (fs *LocalFS) Sync(path string) error {
+	localPath := fs.resolvePath(path)
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no such file: %s", path)
+		}
+		return fmt.Errorf("failed to open: %w", err)
+	}
+	defer f.Close()
+
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync: %w", err)
+	}
+	return nil
+}
+
 // Ensure LocalFSPlugin implements ServicePlugin
 var _ plugin.ServicePlugin = (*LocalFSPlugin)(nil)
 var _ filesystem.FileSystem = (*LocalFS)(nil)
 var _ filesystem.Truncater = (*LocalFS)(nil)
+var _ filesystem.Syncer = (*LocalFS)(nil)
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem/filesystemtest"
 )
 
 // readIgnoreEOF reads file content, ignoring io.EOF which is expected at end of file
@@ -729,3 +730,33 @@ func TestLocalFSRemoveAll(t *testing.T) {
 		t.Error("Directory should be removed")
 	}
 }
+
+func TestLocalFSSync(t *testing.T) {
+	dir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	fs := newTestFS(t, dir)
+	path := "/sync.txt"
+
+	// Synchronous writes and explicit syncs both succeed on a local disk
+	_, err := fs.Write(path, []byte("durable"), -1, filesystem.WriteFlagCreate|filesystem.WriteFlagSync)
+	if err != nil {
+		t.Fatalf("Synchronous write failed: %v", err)
+	}
+	if err := fs.Sync(path); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if err := fs.Sync("/"); err != nil {
+		t.Errorf("Sync of a directory failed: %v", err)
+	}
+
+	if err := fs.Sync("/missing.txt"); err == nil {
+		t.Error("Expected error for syncing a non-existent file")
+	}
+}
+
+func TestLocalFSConformance(t *testing.T) {
+	filesystemtest.Run(t, func(t *testing.T) filesystem.FileSystem {
+		return newTestFS(t, t.TempDir())
+	}, filesystemtest.Options{UntypedErrors: true})
+}
@@ -0,0 +1,636 @@
+package lockfs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PluginName = "lockfs" // Name of this plugin
+
+	mutexDir     = "mutex"     // Directory of the named mutexes
+	semaphoreDir = "semaphore" // Directory of the configured semaphores
+
+	defaultLeaseTTL = 60 * time.Second
+)
+
+// Meta values for LockFS plugin
+const (
+	MetaValueMutex     = "mutex"     // A held mutex
+	MetaValueSemaphore = "semaphore" // A semaphore directory
+	MetaValuePermit    = "permit"    // A held permit of a semaphore
+	MetaValueDirectory = "directory" // The mutex and semaphore directories
+)
+
+// lease is a held mutex or semaphore permit; it is released when it is
+// removed or not renewed before it expires
+type lease struct {
+	holder   string
+	acquired time.Time
+	renewed  time.Time
+	expires  time.Time
+}
+
+// LockFSPlugin provides named mutexes and counting semaphores as files
+//
+//	/mutex/<name>                 - exists while the mutex is held
+//	/semaphore/<name>/<holder>    - exists while <holder> holds a permit
+//
+// Creating a file acquires, writing renews the lease, removing releases.
+// Leases not renewed within lease_ttl expire, so crashed holders do not
+// keep locks forever.
+type LockFSPlugin struct {
+	leaseTTL time.Duration
+	permits  map[string]int // Semaphore -> number of permits
+
+	mutexes    map[string]*lease
+	semaphores map[string]map[string]*lease // Semaphore -> holder -> permit
+	mu         sync.Mutex                   // Protects mutexes and semaphores
+
+	metadata plugin.PluginMetadata
+}
+
+// NewLockFSPlugin creates a new lock plugin
+func NewLockFSPlugin() *LockFSPlugin {
+	return &LockFSPlugin{
+		leaseTTL:   defaultLeaseTTL,
+		permits:    make(map[string]int),
+		mutexes:    make(map[string]*lease),
+		semaphores: make(map[string]map[string]*lease),
+		metadata: plugin.PluginMetadata{
+			Name:        PluginName,
+			Version:     "1.0.0",
+			Description: "Lease-based named mutexes and counting semaphores as files",
+			Author:      "AGFS Server",
+		},
+	}
+}
+
+func (l *LockFSPlugin) Name() string {
+	return l.metadata.Name
+}
+
+func (l *LockFSPlugin) Validate(cfg map[string]interface{}) error {
+	allowedKeys := []string{"mount_path", "lease_ttl", "semaphores"}
+	if err := config.ValidateOnlyKnownKeys(cfg, allowedKeys); err != nil {
+		return err
+	}
+
+	if err := config.ValidateStringType(cfg, "lease_ttl"); err != nil {
+		return err
+	}
+	if _, err := parseLeaseTTL(cfg); err != nil {
+		return err
+	}
+	if err := config.ValidateMapType(cfg, "semaphores"); err != nil {
+		return err
+	}
+	_, err := parseSemaphores(cfg)
+	return err
+}
+
+// parseLeaseTTL reads lease_ttl
+func parseLeaseTTL(cfg map[string]interface{}) (time.Duration, error) {
+	ttl, err := time.ParseDuration(config.GetStringConfig(cfg, "lease_ttl", defaultLeaseTTL.String()))
+	if err != nil || ttl <= 0 {
+		return 0, fmt.Errorf("invalid lease_ttl: must be a positive duration such as \"60s\"")
+	}
+	return ttl, nil
+}
+
+// parseSemaphores reads semaphores, a map of semaphore names to their
+// number of permits
+func parseSemaphores(cfg map[string]interface{}) (map[string]int, error) {
+	permits := make(map[string]int)
+	semaphores, _ := cfg["semaphores"].(map[string]interface{})
+	for name, value := range semaphores {
+		if name == "" || strings.Contains(name, "/") {
+			return nil, fmt.Errorf("invalid semaphore name: %q", name)
+		}
+		n := config.GetIntConfig(semaphores, name, 0)
+		if n <= 0 {
+			return nil, fmt.Errorf("invalid permits for semaphore %s: %v (must be a positive number)", name, value)
+		}
+		permits[name] = n
+	}
+	return permits, nil
+}
+
+func (l *LockFSPlugin) Initialize(cfg map[string]interface{}) error {
+	ttl, err := parseLeaseTTL(cfg)
+	if err != nil {
+		return err
+	}
+	permits, err := parseSemaphores(cfg)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.leaseTTL = ttl
+	l.permits = permits
+	for name := range permits {
+		l.semaphores[name] = make(map[string]*lease)
+	}
+
+	log.Infof("[lockfs] Initialized with lease_ttl=%v, %d semaphores", ttl, len(permits))
+	return nil
+}
+
+func (l *LockFSPlugin) GetFileSystem() filesystem.FileSystem {
+	return &lockFS{plugin: l}
+}
+
+func (l *LockFSPlugin) GetReadme() string {
+	return `LockFS Plugin - Named Mutexes and Semaphores
+
+This plugin provides lease-based locks as files, so shell agents can
+coordinate with touch, cat and rm.
+
+STRUCTURE:
+  /README                       - This file
+  /mutex/<name>                 - Exists while the mutex is held
+  /semaphore/<name>/            - A semaphore, configured with its permits
+  /semaphore/<name>/<holder>    - Exists while <holder> holds a permit
+
+MUTEXES:
+  Acquire (fails with "already exists" while someone holds it):
+    echo agent-1 > /lockfs/mutex/deploy
+
+  Renew the lease (any write by the holder, or touch):
+    echo agent-1 > /lockfs/mutex/deploy
+
+  Check who holds it:
+    cat /lockfs/mutex/deploy
+    holder: agent-1
+    acquired: 2024-11-21T10:30:00Z
+    expires: 2024-11-21T10:31:00Z
+
+  Release:
+    rm /lockfs/mutex/deploy
+
+  The content written names the holder. Writing another name to a held
+  mutex fails, so "echo <me> > mutex/<name>" both acquires and renews.
+  Exclusive creates fail whoever holds the mutex.
+
+SEMAPHORES:
+  Semaphores are configured with their number of permits:
+
+    [plugins.lockfs.config.semaphores]
+    gpu = 2
+    builds = 4
+
+  Acquire a permit (fails with "resource temporarily unavailable" when
+  all permits are held):
+    touch /lockfs/semaphore/gpu/agent-1
+
+  Renew, check and release work like mutexes:
+    touch /lockfs/semaphore/gpu/agent-1
+    ls /lockfs/semaphore/gpu
+    rm /lockfs/semaphore/gpu/agent-1
+
+LEASES:
+  A mutex or permit not renewed within lease_ttl (default: 60s) is
+  released, so a crashed agent does not hold it forever. Holders renew
+  well within the lease, e.g. every lease_ttl/3.
+
+CONFIGURATION:
+  [plugins.lockfs]
+  enabled = true
+  path = "/lockfs"
+
+    [plugins.lockfs.config]
+    lease_ttl = "60s"
+
+    [plugins.lockfs.config.semaphores]
+    gpu = 2
+`
+}
+
+func (l *LockFSPlugin) GetConfigParams() []plugin.ConfigParameter {
+	return []plugin.ConfigParameter{
+		{
+			Name:        "lease_ttl",
+			Type:        "string",
+			Required:    false,
+			Default:     "60s",
+			Description: "How long a mutex or semaphore permit is held without being renewed",
+		},
+		{
+			Name:        "semaphores",
+			Type:        "map",
+			Required:    false,
+			Default:     "",
+			Description: "Semaphores and their number of permits: name -> permits",
+		},
+	}
+}
+
+func (l *LockFSPlugin) Shutdown() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.mutexes = make(map[string]*lease)
+	for name := range l.semaphores {
+		l.semaphores[name] = make(map[string]*lease)
+	}
+	return nil
+}
+
+// prune releases the expired leases; callers hold l.mu
+func (l *LockFSPlugin) prune(now time.Time) {
+	for name, ls := range l.mutexes {
+		if !now.Before(ls.expires) {
+			delete(l.mutexes, name)
+			log.Infof("[lockfs] Mutex %s held by %q expired", name, ls.holder)
+		}
+	}
+	for name, holders := range l.semaphores {
+		for holder, ls := range holders {
+			if !now.Before(ls.expires) {
+				delete(holders, holder)
+				log.Infof("[lockfs] Permit of semaphore %s held by %q expired", name, holder)
+			}
+		}
+	}
+}
+
+func (l *LockFSPlugin) newLease(holder string, now time.Time) *lease {
+	return &lease{holder: holder, acquired: now, renewed: now, expires: now.Add(l.leaseTTL)}
+}
+
+func (l *LockFSPlugin) renew(ls *lease, now time.Time) {
+	ls.renewed = now
+	ls.expires = now.Add(l.leaseTTL)
+}
+
+// lockPath is a path of the mount
+//
+//	/                          - root (dir == "")
+//	/mutex, /mutex/<name>      - dir == mutexDir
+//	/semaphore, /semaphore/<name>, /semaphore/<name>/<holder>
+//	                           - dir == semaphoreDir
+type lockPath struct {
+	dir    string
+	name   string // Mutex or semaphore
+	holder string // Semaphore permit holder
+}
+
+// parseLockPath splits a path of the mount; ok is false for paths that
+// cannot exist
+func parseLockPath(p string) (lockPath, bool) {
+	p = strings.Trim(filesystem.NormalizePath(p), "/")
+	if p == "" {
+		return lockPath{}, true
+	}
+	parts := strings.Split(p, "/")
+	lp := lockPath{dir: parts[0]}
+	switch {
+	case lp.dir == mutexDir && len(parts) <= 2:
+	case lp.dir == semaphoreDir && len(parts) <= 3:
+	default:
+		return lockPath{}, false
+	}
+	if len(parts) > 1 {
+		lp.name = parts[1]
+	}
+	if len(parts) > 2 {
+		lp.holder = parts[2]
+	}
+	return lp, true
+}
+
+// isLock returns true for the files of held mutexes and permits
+func (lp lockPath) isLock() bool {
+	return lp.dir == mutexDir && lp.name != "" || lp.dir == semaphoreDir && lp.holder != ""
+}
+
+// lockFS implements the FileSystem interface for lock operations
+type lockFS struct {
+	plugin *LockFSPlugin
+}
+
+func dirInfo(name, metaType string, content map[string]string) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    name,
+		Mode:    0755,
+		ModTime: time.Now(),
+		IsDir:   true,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: metaType, Content: content},
+	}
+}
+
+func leaseInfo(name, metaType string, ls *lease) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    name,
+		Size:    int64(len(formatLease(ls))),
+		Mode:    0644,
+		ModTime: ls.renewed,
+		IsDir:   false,
+		Meta: filesystem.MetaData{Name: PluginName, Type: metaType, Content: map[string]string{
+			"holder":  ls.holder,
+			"expires": ls.expires.Format(time.RFC3339),
+		}},
+	}
+}
+
+// formatLease returns the content of a lock file: its holder and lease
+func formatLease(ls *lease) string {
+	return fmt.Sprintf("holder: %s\nacquired: %s\nexpires: %s\n",
+		ls.holder, ls.acquired.Format(time.RFC3339), ls.expires.Format(time.RFC3339))
+}
+
+// semaphoreContent returns the meta content of a semaphore directory;
+// callers hold l.mu
+func (l *LockFSPlugin) semaphoreContent(name string) map[string]string {
+	return map[string]string{
+		"permits":   strconv.Itoa(l.permits[name]),
+		"available": strconv.Itoa(l.permits[name] - len(l.semaphores[name])),
+	}
+}
+
+// lookup returns the lease of a held mutex or permit; callers hold l.mu
+func (l *LockFSPlugin) lookup(lp lockPath) (*lease, bool) {
+	if lp.dir == mutexDir {
+		ls, ok := l.mutexes[lp.name]
+		return ls, ok
+	}
+	ls, ok := l.semaphores[lp.name][lp.holder]
+	return ls, ok
+}
+
+// acquire takes or renews a mutex or permit. owner names the holder of a
+// mutex; permits are held by their file name. Unless exclusive is set, the
+// holder renews its lease.
+func (lfs *lockFS) acquire(p string, lp lockPath, owner string, exclusive bool) error {
+	l := lfs.plugin
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.prune(now)
+
+	if lp.dir == mutexDir {
+		if ls, held := l.mutexes[lp.name]; held {
+			if exclusive || owner != "" && ls.holder != "" && owner != ls.holder {
+				return filesystem.NewAlreadyExistsError("mutex", p+" (held by "+ls.holder+")")
+			}
+			if ls.holder == "" {
+				ls.holder = owner
+			}
+			l.renew(ls, now)
+			return nil
+		}
+		l.mutexes[lp.name] = l.newLease(owner, now)
+		return nil
+	}
+
+	holders, ok := l.semaphores[lp.name]
+	if !ok {
+		return filesystem.NewNotFoundError("acquire", "/"+semaphoreDir+"/"+lp.name)
+	}
+	if ls, held := holders[lp.holder]; held {
+		if exclusive {
+			return filesystem.NewAlreadyExistsError("permit", p)
+		}
+		l.renew(ls, now)
+		return nil
+	}
+	if len(holders) >= l.permits[lp.name] {
+		return filesystem.NewUnavailableError(p, fmt.Sprintf("all %d permits of semaphore %s are held", l.permits[lp.name], lp.name))
+	}
+	holders[lp.holder] = l.newLease(lp.holder, now)
+	return nil
+}
+
+// release removes a held mutex or permit
+func (lfs *lockFS) release(p string, lp lockPath) error {
+	l := lfs.plugin
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune(time.Now())
+	if _, held := l.lookup(lp); !held {
+		return filesystem.NewNotFoundError("release", p)
+	}
+	if lp.dir == mutexDir {
+		delete(l.mutexes, lp.name)
+	} else {
+		delete(l.semaphores[lp.name], lp.holder)
+	}
+	return nil
+}
+
+func (lfs *lockFS) Create(p string) error {
+	lp, ok := parseLockPath(p)
+	if !ok || !lp.isLock() {
+		return filesystem.NewPermissionDeniedError("create", p, "only mutexes and semaphore permits can be created")
+	}
+	return lfs.acquire(p, lp, "", true)
+}
+
+func (lfs *lockFS) Mkdir(p string, perm uint32) error {
+	if _, err := lfs.Stat(p); err == nil {
+		return filesystem.NewAlreadyExistsError("directory", p)
+	}
+	return filesystem.NewPermissionDeniedError("mkdir", p, "mutexes are files; semaphores are configured with semaphores")
+}
+
+func (lfs *lockFS) Remove(p string) error {
+	lp, ok := parseLockPath(p)
+	if !ok {
+		return filesystem.NewNotFoundError("remove", p)
+	}
+	if !lp.isLock() {
+		return filesystem.NewPermissionDeniedError("remove", p, "only mutexes and semaphore permits can be removed")
+	}
+	return lfs.release(p, lp)
+}
+
+func (lfs *lockFS) RemoveAll(p string) error {
+	return lfs.Remove(p)
+}
+
+func (lfs *lockFS) Read(p string, offset int64, size int64) ([]byte, error) {
+	if filesystem.NormalizePath(p) == "/README" {
+		return plugin.ApplyRangeRead([]byte(lfs.plugin.GetReadme()), offset, size)
+	}
+
+	lp, ok := parseLockPath(p)
+	if !ok {
+		return nil, filesystem.NewNotFoundError("read", p)
+	}
+	if !lp.isLock() {
+		return nil, fmt.Errorf("is a directory: %s", p)
+	}
+
+	l := lfs.plugin
+	l.mu.Lock()
+	l.prune(time.Now())
+	ls, held := l.lookup(lp)
+	var data []byte
+	if held {
+		data = []byte(formatLease(ls))
+	}
+	l.mu.Unlock()
+
+	if !held {
+		return nil, filesystem.NewNotFoundError("read", p)
+	}
+	return plugin.ApplyRangeRead(data, offset, size)
+}
+
+// Write acquires or renews a mutex or permit; the content written to a
+// mutex names its holder
+func (lfs *lockFS) Write(p string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	lp, ok := parseLockPath(p)
+	if !ok || !lp.isLock() {
+		return 0, filesystem.NewPermissionDeniedError("write", p, "only mutexes and semaphore permits can be written")
+	}
+	owner := strings.TrimSpace(string(data))
+	if err := lfs.acquire(p, lp, owner, flags&filesystem.WriteFlagExclusive != 0); err != nil {
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
+
+func (lfs *lockFS) ReadDir(p string) ([]filesystem.FileInfo, error) {
+	lp, ok := parseLockPath(p)
+	if !ok {
+		return nil, filesystem.NewNotFoundError("readdir", p)
+	}
+	if lp.isLock() {
+		return nil, filesystem.NewNotDirectoryError(p)
+	}
+
+	l := lfs.plugin
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune(time.Now())
+
+	var files []filesystem.FileInfo
+	switch {
+	case lp.dir == "":
+		readme := l.GetReadme()
+		files = []filesystem.FileInfo{
+			{
+				Name:    "README",
+				Size:    int64(len(readme)),
+				Mode:    0444,
+				ModTime: time.Now(),
+				Meta:    filesystem.MetaData{Name: PluginName, Type: "doc"},
+			},
+			dirInfo(mutexDir, MetaValueDirectory, nil),
+			dirInfo(semaphoreDir, MetaValueDirectory, nil),
+		}
+	case lp.dir == mutexDir:
+		for name, ls := range l.mutexes {
+			files = append(files, leaseInfo(name, MetaValueMutex, ls))
+		}
+	case lp.name == "":
+		for name := range l.semaphores {
+			files = append(files, dirInfo(name, MetaValueSemaphore, l.semaphoreContent(name)))
+		}
+	default:
+		holders, ok := l.semaphores[lp.name]
+		if !ok {
+			return nil, filesystem.NewNotFoundError("readdir", p)
+		}
+		for holder, ls := range holders {
+			files = append(files, leaseInfo(holder, MetaValuePermit, ls))
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
+
+func (lfs *lockFS) Stat(p string) (*filesystem.FileInfo, error) {
+	if filesystem.NormalizePath(p) == "/README" {
+		return &filesystem.FileInfo{
+			Name:    "README",
+			Size:    int64(len(lfs.plugin.GetReadme())),
+			Mode:    0444,
+			ModTime: time.Now(),
+			Meta:    filesystem.MetaData{Name: PluginName, Type: "doc"},
+		}, nil
+	}
+
+	lp, ok := parseLockPath(p)
+	if !ok {
+		return nil, filesystem.NewNotFoundError("stat", p)
+	}
+
+	l := lfs.plugin
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune(time.Now())
+
+	var info filesystem.FileInfo
+	switch {
+	case lp.dir == "":
+		info = dirInfo("/", MetaValueDirectory, nil)
+	case lp.name == "":
+		info = dirInfo(lp.dir, MetaValueDirectory, nil)
+	case lp.dir == mutexDir:
+		ls, held := l.mutexes[lp.name]
+		if !held {
+			return nil, filesystem.NewNotFoundError("stat", p)
+		}
+		info = leaseInfo(lp.name, MetaValueMutex, ls)
+	case lp.holder == "":
+		if _, ok := l.semaphores[lp.name]; !ok {
+			return nil, filesystem.NewNotFoundError("stat", p)
+		}
+		info = dirInfo(lp.name, MetaValueSemaphore, l.semaphoreContent(lp.name))
+	default:
+		ls, held := l.semaphores[lp.name][lp.holder]
+		if !held {
+			return nil, filesystem.NewNotFoundError("stat", p)
+		}
+		info = leaseInfo(lp.holder, MetaValuePermit, ls)
+	}
+	return &info, nil
+}
+
+func (lfs *lockFS) Rename(oldPath, newPath string) error {
+	return filesystem.NewNotSupportedError("rename", oldPath)
+}
+
+func (lfs *lockFS) Chmod(path string, mode uint32) error {
+	return nil
+}
+
+// Truncate is a no-op, so that shell redirections, which truncate before
+// writing, can acquire and renew locks
+func (lfs *lockFS) Truncate(path string, size int64) error {
+	return nil
+}
+
+func (lfs *lockFS) Open(path string) (io.ReadCloser, error) {
+	data, err := lfs.Read(path, 0, -1)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (lfs *lockFS) OpenWrite(path string) (io.WriteCloser, error) {
+	return filesystem.NewBufferedWriter(path, lfs.Write), nil
+}
+
+var _ plugin.ServicePlugin = (*LockFSPlugin)(nil)
+var _ filesystem.FileSystem = (*lockFS)(nil)
+var _ filesystem.Truncater = (*lockFS)(nil)
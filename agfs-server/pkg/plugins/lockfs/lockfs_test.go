@@ -0,0 +1,149 @@
+package lockfs
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+func newTestFS(t *testing.T, cfg map[string]interface{}) *lockFS {
+	t.Helper()
+	p := NewLockFSPlugin()
+	if err := p.Validate(cfg); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if err := p.Initialize(cfg); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	t.Cleanup(func() { p.Shutdown() })
+	return p.GetFileSystem().(*lockFS)
+}
+
+// readIgnoreEOF reads file content, ignoring io.EOF which is expected at end of file
+func readIgnoreEOF(fs *lockFS, path string) (string, error) {
+	content, err := fs.Read(path, 0, -1)
+	if err == io.EOF {
+		err = nil
+	}
+	return string(content), err
+}
+
+func TestLockFSMutex(t *testing.T) {
+	fs := newTestFS(t, map[string]interface{}{"lease_ttl": "100ms"})
+	write := func(owner string, flags filesystem.WriteFlag) error {
+		_, err := fs.Write("/mutex/deploy", []byte(owner+"\n"), -1, flags)
+		return err
+	}
+
+	if err := write("agent-1", filesystem.WriteFlagCreate|filesystem.WriteFlagTruncate); err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+	data, err := readIgnoreEOF(fs, "/mutex/deploy")
+	if err != nil || !strings.HasPrefix(data, "holder: agent-1\n") {
+		t.Fatalf("read = %q, %v", data, err)
+	}
+
+	// Others cannot take a held mutex; the holder renews it
+	if err := write("agent-2", filesystem.WriteFlagCreate); !errors.Is(err, filesystem.ErrAlreadyExists) {
+		t.Errorf("acquire by another holder: expected ErrAlreadyExists, got %v", err)
+	}
+	if err := fs.Create("/mutex/deploy"); !errors.Is(err, filesystem.ErrAlreadyExists) {
+		t.Errorf("exclusive create: expected ErrAlreadyExists, got %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if err := write("agent-1", filesystem.WriteFlagCreate); err != nil {
+		t.Errorf("renew failed: %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if infos, err := fs.ReadDir("/mutex"); err != nil || len(infos) != 1 || infos[0].Name != "deploy" {
+		t.Fatalf("renewed mutex expired: %+v, %v", infos, err)
+	}
+
+	// Releasing lets the next holder in
+	if err := fs.Remove("/mutex/deploy"); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if err := fs.Remove("/mutex/deploy"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("second release: expected ErrNotFound, got %v", err)
+	}
+	if err := fs.Create("/mutex/deploy"); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if err := write("agent-2", filesystem.WriteFlagCreate); err != nil {
+		t.Errorf("naming the holder after create failed: %v", err)
+	}
+
+	// Leases that are not renewed expire
+	time.Sleep(120 * time.Millisecond)
+	if _, err := fs.Stat("/mutex/deploy"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("expected expired mutex to be released, got %v", err)
+	}
+	if err := write("agent-3", filesystem.WriteFlagCreate); err != nil {
+		t.Errorf("acquire after expiry failed: %v", err)
+	}
+}
+
+func TestLockFSSemaphore(t *testing.T) {
+	fs := newTestFS(t, map[string]interface{}{
+		"semaphores": map[string]interface{}{"gpu": 2},
+	})
+
+	for _, holder := range []string{"a", "b"} {
+		if err := fs.Create("/semaphore/gpu/" + holder); err != nil {
+			t.Fatalf("acquire %s failed: %v", holder, err)
+		}
+	}
+	if err := fs.Create("/semaphore/gpu/c"); !errors.Is(err, filesystem.ErrUnavailable) {
+		t.Errorf("acquire beyond permits: expected ErrUnavailable, got %v", err)
+	}
+	if err := fs.Create("/semaphore/gpu/a"); !errors.Is(err, filesystem.ErrAlreadyExists) {
+		t.Errorf("second acquire by holder: expected ErrAlreadyExists, got %v", err)
+	}
+	if _, err := fs.Write("/semaphore/gpu/a", nil, -1, filesystem.WriteFlagCreate); err != nil {
+		t.Errorf("renew failed: %v", err)
+	}
+
+	info, err := fs.Stat("/semaphore/gpu")
+	if err != nil || !info.IsDir || info.Meta.Content["available"] != "0" {
+		t.Fatalf("stat = %+v, %v", info, err)
+	}
+	if data, _ := readIgnoreEOF(fs, "/semaphore/gpu/b"); !strings.HasPrefix(data, "holder: b\n") {
+		t.Errorf("read = %q", data)
+	}
+
+	if err := fs.Remove("/semaphore/gpu/a"); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if err := fs.Create("/semaphore/gpu/c"); err != nil {
+		t.Errorf("acquire after release failed: %v", err)
+	}
+	infos, err := fs.ReadDir("/semaphore/gpu")
+	if err != nil || len(infos) != 2 || infos[0].Name != "b" || infos[1].Name != "c" {
+		t.Errorf("ReadDir = %+v, %v", infos, err)
+	}
+
+	if err := fs.Create("/semaphore/unknown/a"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("unconfigured semaphore: expected ErrNotFound, got %v", err)
+	}
+	if err := fs.Create("/other"); !errors.Is(err, filesystem.ErrPermissionDenied) {
+		t.Errorf("create outside locks: expected ErrPermissionDenied, got %v", err)
+	}
+}
+
+func TestLockFSValidate(t *testing.T) {
+	for _, cfg := range []map[string]interface{}{
+		{"lease_ttl": "soon"},
+		{"lease_ttl": "0s"},
+		{"semaphores": map[string]interface{}{"gpu": 0}},
+		{"semaphores": map[string]interface{}{"a/b": 1}},
+		{"unknown": true},
+	} {
+		if err := NewLockFSPlugin().Validate(cfg); err == nil {
+			t.Errorf("expected %v to be rejected", cfg)
+		}
+	}
+}
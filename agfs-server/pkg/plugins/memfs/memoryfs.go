@@ -75,11 +75,11 @@ func (mfs *MemoryFS) getNode(path string) (*Node, error) {
 
 	for _, part := range parts {
 		if !current.IsDir {
-			return nil, fmt.Errorf("not a directory: %s", path)
+			return nil, filesystem.NewNotDirectoryError(path)
 		}
 		next, exists := current.Children[part]
 		if !exists {
-			return nil, fmt.Errorf("no such file or directory: %s", path)
+			return nil, filesystem.NewNotFoundError("", path)
 		}
 		current = next
 	}
@@ -104,7 +104,7 @@ func (mfs *MemoryFS) getParentNode(path string) (*Node, string, error) {
 	}
 
 	if !parent.IsDir {
-		return nil, "", fmt.Errorf("parent is not a directory")
+		return nil, "", filesystem.NewNotDirectoryError(dir)
 	}
 
 	return parent, base, nil
@@ -121,7 +121,7 @@ func (mfs *MemoryFS) Create(path string) error {
 	}
 
 	if _, exists := parent.Children[name]; exists {
-		return fmt.Errorf("file already exists: %s", path)
+		return filesystem.NewAlreadyExistsError("file", path)
 	}
 
 	parent.Children[name] = &Node{
@@ -147,7 +147,7 @@ func (mfs *MemoryFS) Mkdir(path string, perm uint32) error {
 	}
 
 	if _, exists := parent.Children[name]; exists {
-		return fmt.Errorf("directory already exists: %s", path)
+		return filesystem.NewAlreadyExistsError("directory", path)
 	}
 
 	parent.Children[name] = &Node{
@@ -177,7 +177,7 @@ func (mfs *MemoryFS) Remove(path string) error {
 
 	node, exists := parent.Children[name]
 	if !exists {
-		return fmt.Errorf("no such file or directory: %s", path)
+		return filesystem.NewNotFoundError("remove", path)
 	}
 
 	if node.IsDir && len(node.Children) > 0 {
@@ -205,7 +205,7 @@ func (mfs *MemoryFS) RemoveAll(path string) error {
 	}
 
 	if _, exists := parent.Children[name]; !exists {
-		return fmt.Errorf("no such file or directory: %s", path)
+		return filesystem.NewNotFoundError("removeall", path)
 	}
 
 	delete(parent.Children, name)
@@ -223,7 +223,7 @@ func (mfs *MemoryFS) Read(path string, offset int64, size int64) ([]byte, error)
 	}
 
 	if node.IsDir {
-		return nil, fmt.Errorf("is a directory: %s", path)
+		return nil, filesystem.NewInvalidArgumentError("path", path, "is a directory")
 	}
 
 	return plugin.ApplyRangeRead(node.Data, offset, size)
@@ -247,12 +247,12 @@ func (mfs *MemoryFS) Write(path string, data []byte, offset int64, flags filesys
 
 	// Handle exclusive flag
 	if exists && flags&filesystem.WriteFlagExclusive != 0 {
-		return 0, fmt.Errorf("file already exists: %s", path)
+		return 0, filesystem.NewAlreadyExistsError("file", path)
 	}
 
 	if !exists {
 		if flags&filesystem.WriteFlagCreate == 0 {
-			return 0, fmt.Errorf("file not found: %s", path)
+			return 0, filesystem.NewNotFoundError("write", path)
 		}
 		// Create the file
 		node = &Node{
@@ -267,7 +267,7 @@ func (mfs *MemoryFS) Write(path string, data []byte, offset int64, flags filesys
 	}
 
 	if node.IsDir {
-		return 0, fmt.Errorf("is a directory: %s", path)
+		return 0, filesystem.NewInvalidArgumentError("path", path, "is a directory")
 	}
 
 	// Handle truncate flag
@@ -282,10 +282,10 @@ func (mfs *MemoryFS) Write(path string, data []byte, offset int64, flags filesys
 
 	// Handle offset write
 	if offset < 0 {
-		// Overwrite mode (default): replace entire content
-		node.Data = data
-	} else {
-		// Offset write mode
+		// Overwrite mode (default): replace entire content; the caller owns data
+		node.Data = append([]byte(nil), data...)
+	} else if len(data) > 0 {
+		// Offset write mode; like pwrite, an empty write does not extend the file
 		newSize := offset + int64(len(data))
 		if newSize > int64(len(node.Data)) {
 			newData := make([]byte, newSize)
@@ -311,7 +311,7 @@ func (mfs *MemoryFS) ReadDir(path string) ([]filesystem.FileInfo, error) {
 	}
 
 	if !node.IsDir {
-		return nil, fmt.Errorf("not a directory: %s", path)
+		return nil, filesystem.NewNotDirectoryError(path)
 	}
 
 	var infos []filesystem.FileInfo
@@ -377,7 +377,7 @@ func (mfs *MemoryFS) Rename(oldPath, newPath string) error {
 
 	node, exists := oldParent.Children[oldName]
 	if !exists {
-		return fmt.Errorf("no such file or directory: %s", oldPath)
+		return filesystem.NewNotFoundError("rename", oldPath)
 	}
 
 	newParent, newName, err := mfs.getParentNode(newPath)
@@ -386,7 +386,7 @@ func (mfs *MemoryFS) Rename(oldPath, newPath string) error {
 	}
 
 	if _, exists := newParent.Children[newName]; exists {
-		return fmt.Errorf("file already exists: %s", newPath)
+		return filesystem.NewAlreadyExistsError("file", newPath)
 	}
 
 	// Move the node
@@ -422,7 +422,7 @@ func (mfs *MemoryFS) Truncate(path string, size int64) error {
 	}
 
 	if node.IsDir {
-		return fmt.Errorf("is a directory: %s", path)
+		return filesystem.NewInvalidArgumentError("path", path, "is a directory")
 	}
 
 	currentSize := int64(len(node.Data))
@@ -463,7 +463,7 @@ func (m *memoryReadCloser) Close() error {
 // Open opens a file for reading
 func (mfs *MemoryFS) Open(path string) (io.ReadCloser, error) {
 	data, err := mfs.Read(path, 0, -1)
-	if err != nil {
+	if err != nil && err != io.EOF {
 		return nil, err
 	}
 	return &memoryReadCloser{bytes.NewReader(data)}, nil
@@ -601,6 +601,10 @@ func (h *MemoryFileHandle) Write(data []byte) (int, error) {
 	if accessMode != filesystem.O_WRONLY && accessMode != filesystem.O_RDWR {
 		return 0, fmt.Errorf("handle not opened for writing")
 	}
+	// An empty write does not extend the file
+	if len(data) == 0 {
+		return 0, nil
+	}
 
 	h.mfs.mu.Lock()
 	defer h.mfs.mu.Unlock()
@@ -645,6 +649,10 @@ func (h *MemoryFileHandle) WriteAt(data []byte, offset int64) (int, error) {
 	if accessMode != filesystem.O_WRONLY && accessMode != filesystem.O_RDWR {
 		return 0, fmt.Errorf("handle not opened for writing")
 	}
+	// An empty write does not extend the file
+	if len(data) == 0 {
+		return 0, nil
+	}
 
 	h.mfs.mu.Lock()
 	defer h.mfs.mu.Unlock()
@@ -760,14 +768,14 @@ func (mfs *MemoryFS) OpenHandle(path string, flags filesystem.OpenFlag, mode uin
 
 	// Handle O_EXCL: fail if file exists
 	if flags&filesystem.O_EXCL != 0 && fileExists {
-		return nil, fmt.Errorf("file already exists: %s", path)
+		return nil, filesystem.NewAlreadyExistsError("file", path)
 	}
 
 	// Handle O_CREATE: create file if it doesn't exist
 	if flags&filesystem.O_CREATE != 0 && !fileExists {
 		parent, name, err := mfs.getParentNode(path)
 		if err != nil {
-			return nil, fmt.Errorf("parent directory not found: %s", path)
+			return nil, filesystem.NewNotFoundError("open", filepath.Dir(path))
 		}
 		node = &Node{
 			Name:     name,
@@ -779,11 +787,11 @@ func (mfs *MemoryFS) OpenHandle(path string, flags filesystem.OpenFlag, mode uin
 		}
 		parent.Children[name] = node
 	} else if !fileExists {
-		return nil, fmt.Errorf("file not found: %s", path)
+		return nil, filesystem.NewNotFoundError("open", path)
 	}
 
 	if node.IsDir {
-		return nil, fmt.Errorf("is a directory: %s", path)
+		return nil, filesystem.NewInvalidArgumentError("path", path, "is a directory")
 	}
 
 	// Handle O_TRUNC: truncate file
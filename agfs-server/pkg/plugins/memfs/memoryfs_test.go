@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem/filesystemtest"
 )
 
 // readIgnoreEOF reads file content, ignoring io.EOF which is expected at end of file
@@ -1011,3 +1012,9 @@ func TestMemoryFSOpen(t *testing.T) {
 		t.Fatalf("Reader.Close failed: %v", err)
 	}
 }
+
+func TestMemoryFSConformance(t *testing.T) {
+	filesystemtest.Run(t, func(t *testing.T) filesystem.FileSystem {
+		return NewMemoryFS()
+	}, filesystemtest.Options{})
+}
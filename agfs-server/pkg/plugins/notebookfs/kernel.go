@@ -0,0 +1,262 @@
+package notebookfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// gatewayClient talks to a Jupyter Kernel Gateway or Jupyter Server
+// Kernels are managed over the REST API and code runs through the kernel's
+// WebSocket channels using the Jupyter messaging protocol.
+type gatewayClient struct {
+	baseURL *url.URL
+	token   string
+	http    *http.Client
+	session string
+}
+
+func newGatewayClient(rawURL, token string) (*gatewayClient, error) {
+	u, err := url.Parse(strings.TrimSuffix(rawURL, "/"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid gateway_url %q: must be an http(s) URL", rawURL)
+	}
+	return &gatewayClient{
+		baseURL: u,
+		token:   token,
+		http:    &http.Client{Timeout: 30 * time.Second},
+		session: uuid.NewString(),
+	}, nil
+}
+
+// gatewayError is a non-2xx response from the gateway
+type gatewayError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *gatewayError) Error() string {
+	return fmt.Sprintf("kernel gateway error (status %d): %s", e.StatusCode, e.Message)
+}
+
+func (g *gatewayClient) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, g.baseURL.String()+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if g.token != "" {
+		req.Header.Set("Authorization", "token "+g.token)
+	}
+
+	resp, err := g.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("kernel gateway request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &gatewayError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+// StartKernel starts a kernel and returns its ID
+func (g *gatewayClient) StartKernel(ctx context.Context, name string) (string, error) {
+	var kernel struct {
+		ID string `json:"id"`
+	}
+	if err := g.do(ctx, http.MethodPost, "/api/kernels", map[string]string{"name": name}, &kernel); err != nil {
+		return "", err
+	}
+	if kernel.ID == "" {
+		return "", fmt.Errorf("kernel gateway returned no kernel id")
+	}
+	return kernel.ID, nil
+}
+
+// RestartKernel restarts a kernel, clearing its state
+func (g *gatewayClient) RestartKernel(ctx context.Context, id string) error {
+	return g.do(ctx, http.MethodPost, "/api/kernels/"+url.PathEscape(id)+"/restart", nil, nil)
+}
+
+// DeleteKernel shuts a kernel down
+func (g *gatewayClient) DeleteKernel(ctx context.Context, id string) error {
+	return g.do(ctx, http.MethodDelete, "/api/kernels/"+url.PathEscape(id), nil, nil)
+}
+
+// kernelMessage is a Jupyter protocol message as sent over the WebSocket channels
+type kernelMessage struct {
+	Header       messageHeader          `json:"header"`
+	ParentHeader messageHeader          `json:"parent_header"`
+	Metadata     map[string]interface{} `json:"metadata"`
+	Content      map[string]interface{} `json:"content"`
+	Channel      string                 `json:"channel"`
+	Buffers      []interface{}          `json:"buffers"`
+}
+
+type messageHeader struct {
+	MsgID    string `json:"msg_id,omitempty"`
+	MsgType  string `json:"msg_type,omitempty"`
+	Session  string `json:"session,omitempty"`
+	Username string `json:"username,omitempty"`
+	Version  string `json:"version,omitempty"`
+	Date     string `json:"date,omitempty"`
+}
+
+// kernelConn is an open connection to a kernel's channels
+type kernelConn struct {
+	ws      *websocket.Conn
+	session string
+}
+
+// Connect opens the kernel's WebSocket channels
+func (g *gatewayClient) Connect(ctx context.Context, id string) (*kernelConn, error) {
+	u := *g.baseURL
+	if u.Scheme == "https" {
+		u.Scheme = "wss"
+	} else {
+		u.Scheme = "ws"
+	}
+	u.Path += "/api/kernels/" + url.PathEscape(id) + "/channels"
+	u.RawQuery = url.Values{"session_id": {g.session}}.Encode()
+
+	header := http.Header{}
+	if g.token != "" {
+		header.Set("Authorization", "token "+g.token)
+	}
+
+	ws, resp, err := websocket.DefaultDialer.DialContext(ctx, u.String(), header)
+	if err != nil {
+		if resp != nil {
+			return nil, &gatewayError{StatusCode: resp.StatusCode, Message: "cannot connect to kernel " + id}
+		}
+		return nil, fmt.Errorf("cannot connect to kernel %s: %w", id, err)
+	}
+	return &kernelConn{ws: ws, session: g.session}, nil
+}
+
+func (c *kernelConn) Close() error {
+	return c.ws.Close()
+}
+
+// execution is the outcome of running one piece of code
+type execution struct {
+	Status         string                   // "ok", "error" or "aborted"
+	ExecutionCount int                      // Kernel execution counter, 0 if unknown
+	Outputs        []map[string]interface{} // nbformat outputs
+	ErrorName      string
+	ErrorValue     string
+}
+
+// Execute runs code and collects its outputs in nbformat form
+// It returns once the kernel has replied and gone idle, or when ctx expires.
+func (c *kernelConn) Execute(ctx context.Context, code string) (*execution, error) {
+	msgID := uuid.NewString()
+	req := kernelMessage{
+		Header: messageHeader{
+			MsgID:    msgID,
+			MsgType:  "execute_request",
+			Session:  c.session,
+			Username: "agfs",
+			Version:  "5.3",
+			Date:     time.Now().UTC().Format(time.RFC3339Nano),
+		},
+		Metadata: map[string]interface{}{},
+		Content: map[string]interface{}{
+			"code":             code,
+			"silent":           false,
+			"store_history":    true,
+			"user_expressions": map[string]interface{}{},
+			"allow_stdin":      false,
+			"stop_on_error":    true,
+		},
+		Channel: "shell",
+		Buffers: []interface{}{},
+	}
+	if err := c.ws.WriteJSON(req); err != nil {
+		return nil, fmt.Errorf("failed to send execute request: %w", err)
+	}
+
+	// Unblock the reader when the context expires
+	stop := context.AfterFunc(ctx, func() { c.ws.SetReadDeadline(time.Now()) })
+	defer stop()
+
+	exec := &execution{Outputs: []map[string]interface{}{}}
+	replied, idle := false, false
+	for !replied || !idle {
+		var msg kernelMessage
+		if err := c.ws.ReadJSON(&msg); err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("execution timed out: %w", ctx.Err())
+			}
+			return nil, fmt.Errorf("kernel connection lost: %w", err)
+		}
+		if msg.ParentHeader.MsgID != msgID {
+			continue
+		}
+
+		content := msg.Content
+		switch msg.Header.MsgType {
+		case "execute_reply":
+			replied = true
+			exec.Status, _ = content["status"].(string)
+			if n, ok := content["execution_count"].(float64); ok {
+				exec.ExecutionCount = int(n)
+			}
+		case "status":
+			idle = content["execution_state"] == "idle"
+		case "stream":
+			exec.Outputs = append(exec.Outputs, map[string]interface{}{
+				"output_type": "stream",
+				"name":        content["name"],
+				"text":        splitLines(multilineString(content["text"])),
+			})
+		case "execute_result":
+			exec.Outputs = append(exec.Outputs, map[string]interface{}{
+				"output_type":     "execute_result",
+				"data":            content["data"],
+				"metadata":        content["metadata"],
+				"execution_count": content["execution_count"],
+			})
+		case "display_data":
+			exec.Outputs = append(exec.Outputs, map[string]interface{}{
+				"output_type": "display_data",
+				"data":        content["data"],
+				"metadata":    content["metadata"],
+			})
+		case "error":
+			exec.ErrorName, _ = content["ename"].(string)
+			exec.ErrorValue, _ = content["evalue"].(string)
+			exec.Outputs = append(exec.Outputs, map[string]interface{}{
+				"output_type": "error",
+				"ename":       content["ename"],
+				"evalue":      content["evalue"],
+				"traceback":   content["traceback"],
+			})
+		}
+	}
+	return exec, nil
+}
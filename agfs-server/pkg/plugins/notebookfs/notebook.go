@@ -0,0 +1,247 @@
+package notebookfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Cell types defined by nbformat
+const (
+	cellCode     = "code"
+	cellMarkdown = "markdown"
+	cellRaw      = "raw"
+)
+
+// languageExtensions maps kernel languages to cell file extensions
+// Used when the notebook has no language_info.file_extension.
+var languageExtensions = map[string]string{
+	"python":     ".py",
+	"r":          ".r",
+	"julia":      ".jl",
+	"javascript": ".js",
+	"typescript": ".ts",
+	"scala":      ".scala",
+	"go":         ".go",
+	"bash":       ".sh",
+	"rust":       ".rs",
+}
+
+// cellNamePattern matches cell file names such as "003.py"
+var cellNamePattern = regexp.MustCompile(`^(\d+)(\.[A-Za-z0-9]+)$`)
+
+// ansiPattern matches terminal color codes found in tracebacks
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// notebook is a parsed .ipynb document
+// The document is kept as generic JSON so that fields this plugin does not
+// know about survive a rewrite.
+type notebook struct {
+	doc   map[string]interface{}
+	cells []map[string]interface{}
+}
+
+// parseNotebook parses an nbformat 4 document
+func parseNotebook(data []byte) (*notebook, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid notebook: %w", err)
+	}
+	if major, _ := doc["nbformat"].(float64); major != 4 {
+		return nil, fmt.Errorf("unsupported notebook format %v (only nbformat 4 is supported)", doc["nbformat"])
+	}
+
+	rawCells, _ := doc["cells"].([]interface{})
+	nb := &notebook{doc: doc, cells: make([]map[string]interface{}, 0, len(rawCells))}
+	for i, c := range rawCells {
+		cell, ok := c.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid notebook: cell %d is not an object", i)
+		}
+		nb.cells = append(nb.cells, cell)
+	}
+	return nb, nil
+}
+
+// marshal encodes the notebook the way Jupyter saves it: sorted keys, one-space indent
+func (nb *notebook) marshal() ([]byte, error) {
+	cells := make([]interface{}, len(nb.cells))
+	for i, c := range nb.cells {
+		cells[i] = c
+	}
+	nb.doc["cells"] = cells
+
+	data, err := json.MarshalIndent(nb.doc, "", " ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// metaString returns a string from nested notebook metadata
+func (nb *notebook) metaString(keys ...string) string {
+	var cur interface{} = nb.doc["metadata"]
+	for _, key := range keys {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		cur = m[key]
+	}
+	s, _ := cur.(string)
+	return s
+}
+
+// kernelName returns the kernel recorded in the notebook, if any
+func (nb *notebook) kernelName() string {
+	return nb.metaString("kernelspec", "name")
+}
+
+// codeExtension returns the file extension used for code cells
+func (nb *notebook) codeExtension() string {
+	if ext := nb.metaString("language_info", "file_extension"); ext != "" {
+		return ext
+	}
+	lang := nb.metaString("language_info", "name")
+	if lang == "" {
+		lang = nb.metaString("kernelspec", "language")
+	}
+	if ext, ok := languageExtensions[strings.ToLower(lang)]; ok {
+		return ext
+	}
+	return ".py"
+}
+
+// cellExtension returns the file extension of a cell
+func (nb *notebook) cellExtension(cell map[string]interface{}) string {
+	switch cell["cell_type"] {
+	case cellMarkdown:
+		return ".md"
+	case cellRaw:
+		return ".txt"
+	default:
+		return nb.codeExtension()
+	}
+}
+
+// cellName returns the file name of the i-th cell
+func (nb *notebook) cellName(i int) string {
+	return fmt.Sprintf("%03d%s", i, nb.cellExtension(nb.cells[i]))
+}
+
+// findCell returns the index of the cell with the given file name
+func (nb *notebook) findCell(name string) (int, bool) {
+	m := cellNamePattern.FindStringSubmatch(name)
+	if m == nil {
+		return 0, false
+	}
+	i, err := strconv.Atoi(m[1])
+	if err != nil || i >= len(nb.cells) || nb.cellName(i) != name {
+		return 0, false
+	}
+	return i, true
+}
+
+// newCell builds an empty cell for a file name that follows the existing cells
+// The extension selects the type: .md for markdown, .txt for raw, anything else for code.
+func (nb *notebook) newCell(name string) (map[string]interface{}, bool) {
+	m := cellNamePattern.FindStringSubmatch(name)
+	if m == nil {
+		return nil, false
+	}
+	if i, err := strconv.Atoi(m[1]); err != nil || i != len(nb.cells) {
+		return nil, false
+	}
+
+	cell := map[string]interface{}{
+		"metadata": map[string]interface{}{},
+		"source":   []interface{}{},
+	}
+	switch m[2] {
+	case ".md":
+		cell["cell_type"] = cellMarkdown
+	case ".txt":
+		cell["cell_type"] = cellRaw
+	default:
+		cell["cell_type"] = cellCode
+		cell["execution_count"] = nil
+		cell["outputs"] = []interface{}{}
+	}
+	return cell, true
+}
+
+// multilineString joins an nbformat multiline string, which is either a string or a list of lines
+func multilineString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case []interface{}:
+		var b strings.Builder
+		for _, line := range s {
+			if str, ok := line.(string); ok {
+				b.WriteString(str)
+			}
+		}
+		return b.String()
+	}
+	return ""
+}
+
+// splitLines splits text into lines that keep their newlines, as Jupyter stores sources
+func splitLines(text string) []interface{} {
+	lines := []interface{}{}
+	for text != "" {
+		i := strings.IndexByte(text, '\n')
+		if i < 0 {
+			lines = append(lines, text)
+			break
+		}
+		lines = append(lines, text[:i+1])
+		text = text[i+1:]
+	}
+	return lines
+}
+
+func cellSource(cell map[string]interface{}) string {
+	return multilineString(cell["source"])
+}
+
+func setCellSource(cell map[string]interface{}, source string) {
+	cell["source"] = splitLines(source)
+}
+
+// outputsText renders the outputs of a code cell as plain text
+func outputsText(cell map[string]interface{}) string {
+	outputs, _ := cell["outputs"].([]interface{})
+
+	var b strings.Builder
+	for _, o := range outputs {
+		out, ok := o.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var text string
+		switch out["output_type"] {
+		case "stream":
+			text = multilineString(out["text"])
+		case "execute_result", "display_data":
+			if data, ok := out["data"].(map[string]interface{}); ok {
+				text = multilineString(data["text/plain"])
+			}
+		case "error":
+			text = fmt.Sprintf("%v: %v\n", out["ename"], out["evalue"])
+			if tb, ok := out["traceback"].([]interface{}); ok {
+				for _, line := range tb {
+					text += fmt.Sprintf("%v\n", line)
+				}
+			}
+		}
+		if text != "" && !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		b.WriteString(text)
+	}
+	return ansiPattern.ReplaceAllString(b.String(), "")
+}
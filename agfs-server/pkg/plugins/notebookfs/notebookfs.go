@@ -0,0 +1,1054 @@
+package notebookfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/localfs"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PluginName = "notebookfs" // Name of this plugin
+)
+
+// Meta values for NotebookFS plugin
+const (
+	MetaValueDir      = "dir"      // Directory passed through from the backing filesystem
+	MetaValueNotebook = "notebook" // Notebook presented as a directory
+	MetaValueCell     = "cell"     // One notebook cell
+	MetaValueOutputs  = "outputs"  // Rendered outputs of a code cell
+	MetaValueControl  = "control"  // The execute control file
+)
+
+const (
+	executeFile         = "execute"
+	outputsDir          = "outputs"
+	notebookExt         = ".ipynb"
+	defaultKernelName   = "python3"
+	defaultExecTimeout  = 60 * time.Second
+	checkpointsDirName  = ".ipynb_checkpoints"
+	maxExecuteRequestSz = 1024
+)
+
+// CellResult is the outcome of running one cell
+type CellResult struct {
+	Cell           string `json:"cell"`
+	Status         string `json:"status"`
+	ExecutionCount int    `json:"execution_count,omitempty"`
+	Output         string `json:"output"`
+	Error          string `json:"error,omitempty"`
+}
+
+// ExecutionRecord is the result of the last request written to a notebook's execute file
+type ExecutionRecord struct {
+	Request    string       `json:"request"`
+	Kernel     string       `json:"kernel"`
+	Cells      []CellResult `json:"cells"`
+	Error      string       `json:"error,omitempty"`
+	StartedAt  time.Time    `json:"started_at"`
+	FinishedAt time.Time    `json:"finished_at"`
+}
+
+// NotebookFSPlugin presents Jupyter notebooks as directories of cells
+// Paths mirror a directory tree (an AGFS path or a local directory); every
+// .ipynb file becomes a directory:
+//
+//	/nb/analysis.ipynb/000.md       - markdown cell
+//	/nb/analysis.ipynb/001.py       - code cell (read/write)
+//	/nb/analysis.ipynb/outputs/001  - rendered outputs of cell 001
+//	/nb/analysis.ipynb/execute      - write "1", "0-3", "all" or "restart"
+//
+// Code runs on kernels of a Jupyter Kernel Gateway (or Jupyter Server); each
+// notebook gets its own kernel so state carries over between executions.
+type NotebookFSPlugin struct {
+	rootFS      filesystem.FileSystem
+	localFS     filesystem.FileSystem // Set when local_path is configured
+	source      string
+	mountPath   string
+	gateway     *gatewayClient
+	kernelName  string
+	timeout     time.Duration
+	saveOutputs bool
+
+	nbMu    sync.Mutex                 // Serializes notebook read-modify-write cycles
+	mu      sync.Mutex                 // Protects kernels and records
+	kernels map[string]string          // Notebook path -> kernel ID
+	records map[string]ExecutionRecord // Notebook path -> last execution
+	execMu  map[string]*sync.Mutex     // Notebook path -> execution lock
+
+	metadata plugin.PluginMetadata
+}
+
+// NewNotebookFSPlugin creates a new notebook plugin
+func NewNotebookFSPlugin() *NotebookFSPlugin {
+	return &NotebookFSPlugin{
+		kernels: make(map[string]string),
+		records: make(map[string]ExecutionRecord),
+		execMu:  make(map[string]*sync.Mutex),
+		metadata: plugin.PluginMetadata{
+			Name:        PluginName,
+			Version:     "1.0.0",
+			Description: "Edit and run Jupyter notebook cells as files",
+			Author:      "AGFS Server",
+		},
+	}
+}
+
+func (n *NotebookFSPlugin) Name() string {
+	return n.metadata.Name
+}
+
+func (n *NotebookFSPlugin) Validate(cfg map[string]interface{}) error {
+	allowedKeys := []string{"mount_path", "source", "local_path", "gateway_url", "token", "kernel_name", "execute_timeout", "save_outputs"}
+	if err := config.ValidateOnlyKnownKeys(cfg, allowedKeys); err != nil {
+		return err
+	}
+
+	for _, key := range []string{"source", "local_path", "gateway_url", "token", "kernel_name", "execute_timeout"} {
+		if err := config.ValidateStringType(cfg, key); err != nil {
+			return err
+		}
+	}
+	if err := config.ValidateBoolType(cfg, "save_outputs"); err != nil {
+		return err
+	}
+
+	if _, ok := cfg["source"]; ok {
+		if _, ok := cfg["local_path"]; ok {
+			return fmt.Errorf("source and local_path are mutually exclusive")
+		}
+	}
+	if source := config.GetStringConfig(cfg, "source", "/"); !strings.HasPrefix(source, "/") {
+		return fmt.Errorf("source must be an absolute AGFS path")
+	}
+	if gw := config.GetStringConfig(cfg, "gateway_url", ""); gw != "" {
+		if _, err := newGatewayClient(gw, ""); err != nil {
+			return err
+		}
+	}
+	if t := config.GetStringConfig(cfg, "execute_timeout", ""); t != "" {
+		d, err := time.ParseDuration(t)
+		if err != nil {
+			return fmt.Errorf("invalid execute_timeout: %w", err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("execute_timeout must be positive")
+		}
+	}
+	return nil
+}
+
+func (n *NotebookFSPlugin) Initialize(cfg map[string]interface{}) error {
+	n.source = path.Clean(config.GetStringConfig(cfg, "source", "/"))
+	n.mountPath = config.GetStringConfig(cfg, "mount_path", "")
+	n.kernelName = config.GetStringConfig(cfg, "kernel_name", "")
+	n.saveOutputs = config.GetBoolConfig(cfg, "save_outputs", true)
+
+	n.timeout = defaultExecTimeout
+	if t := config.GetStringConfig(cfg, "execute_timeout", ""); t != "" {
+		d, err := time.ParseDuration(t)
+		if err != nil {
+			return fmt.Errorf("invalid execute_timeout: %w", err)
+		}
+		n.timeout = d
+	}
+
+	if localPath := config.GetStringConfig(cfg, "local_path", ""); localPath != "" {
+		lfs, err := localfs.NewLocalFS(localPath)
+		if err != nil {
+			return fmt.Errorf("invalid local_path: %w", err)
+		}
+		n.localFS = lfs
+		n.source = "/"
+	}
+
+	if gw := config.GetStringConfig(cfg, "gateway_url", ""); gw != "" {
+		client, err := newGatewayClient(gw, config.GetStringConfig(cfg, "token", ""))
+		if err != nil {
+			return err
+		}
+		n.gateway = client
+	}
+
+	log.Infof("[notebookfs] Initialized (source=%s, local=%v, gateway=%v, timeout=%s)",
+		n.source, n.localFS != nil, n.gateway != nil, n.timeout)
+	return nil
+}
+
+// SetParentFileSystem gives the plugin access to the AGFS tree holding the notebooks
+// This is called by the mount system, either before or after Initialize.
+func (n *NotebookFSPlugin) SetParentFileSystem(fs filesystem.FileSystem) {
+	n.rootFS = fs
+}
+
+func (n *NotebookFSPlugin) GetFileSystem() filesystem.FileSystem {
+	return &notebookFS{plugin: n}
+}
+
+func (n *NotebookFSPlugin) GetReadme() string {
+	return `NotebookFS Plugin - Jupyter Notebook Cells as Files
+
+This plugin presents every .ipynb file in a directory tree as a directory
+with one file per cell, so agents can read, edit and run notebooks with
+ordinary file operations.
+
+STRUCTURE:
+  /notebookfs/
+    README                        - This documentation
+    <path>/                       - Directories of the source tree
+    <path>/<name>.ipynb/          - A notebook as a directory
+      000.md                      - Markdown cell
+      001.py                      - Code cell (extension from the kernel language)
+      002.txt                     - Raw cell
+      outputs/001                 - Outputs of code cell 001 as text (read-only)
+      execute                     - Control file (see below)
+
+  Cells are numbered from 000 in notebook order. Writing a cell file
+  updates the cell's source in the .ipynb file.
+
+EXAMPLES:
+  # Read and edit cells
+  agfs:/> ls /notebookfs/work/analysis.ipynb
+  agfs:/> cat /notebookfs/work/analysis.ipynb/001.py
+  agfs:/> echo "df.describe()" > /notebookfs/work/analysis.ipynb/002.py
+
+  # Append a new cell (the next number; .md for markdown, .txt for raw)
+  agfs:/> echo "print('done')" > /notebookfs/work/analysis.ipynb/005.py
+
+  # Run cells and inspect the results
+  agfs:/> echo 2 > /notebookfs/work/analysis.ipynb/execute
+  agfs:/> echo 0-4 > /notebookfs/work/analysis.ipynb/execute
+  agfs:/> echo all > /notebookfs/work/analysis.ipynb/execute
+  agfs:/> cat /notebookfs/work/analysis.ipynb/execute
+  agfs:/> cat /notebookfs/work/analysis.ipynb/outputs/002
+
+  # Start over with a fresh kernel
+  agfs:/> echo restart > /notebookfs/work/analysis.ipynb/execute
+
+CONFIGURATION:
+  [plugins.notebookfs]
+  enabled = true
+  path = "/notebookfs"
+
+    [plugins.notebookfs.config]
+    source = "/"                               # AGFS directory holding notebooks
+    # local_path = "/home/me/notebooks"        # or a local directory instead
+    gateway_url = "http://localhost:8888"      # Jupyter Kernel Gateway / Server
+    token = ""                                 # gateway auth token
+    kernel_name = ""                           # default: the notebook's kernelspec
+    execute_timeout = "60s"                    # per cell
+    save_outputs = true                        # write outputs back to the .ipynb
+
+NOTES:
+  - Each notebook runs on its own kernel, started on first execution and
+    kept until restart or server shutdown.
+  - Execution stops at the first cell that raises an error.
+  - Removing a cell file deletes the cell; later cells are renumbered.
+  - Without gateway_url, cells can be read and edited but not executed.
+`
+}
+
+func (n *NotebookFSPlugin) GetConfigParams() []plugin.ConfigParameter {
+	return []plugin.ConfigParameter{
+		{
+			Name:        "source",
+			Type:        "string",
+			Required:    false,
+			Default:     "/",
+			Description: "AGFS directory holding notebooks",
+		},
+		{
+			Name:        "local_path",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Local directory holding notebooks (instead of source)",
+		},
+		{
+			Name:        "gateway_url",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Jupyter Kernel Gateway or Jupyter Server URL used to execute cells",
+		},
+		{
+			Name:        "token",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Gateway authentication token",
+			Secret:      true,
+		},
+		{
+			Name:        "kernel_name",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Kernel to start (default: the notebook's kernelspec, then python3)",
+		},
+		{
+			Name:        "execute_timeout",
+			Type:        "string",
+			Required:    false,
+			Default:     "60s",
+			Description: "Maximum run time of a single cell",
+		},
+		{
+			Name:        "save_outputs",
+			Type:        "bool",
+			Required:    false,
+			Default:     "true",
+			Description: "Write execution outputs back to the notebook file",
+		},
+	}
+}
+
+func (n *NotebookFSPlugin) Shutdown() error {
+	n.mu.Lock()
+	kernels := n.kernels
+	n.kernels = make(map[string]string)
+	n.mu.Unlock()
+
+	if n.gateway != nil {
+		for nbPath, id := range kernels {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := n.gateway.DeleteKernel(ctx, id); err != nil {
+				log.Warnf("[notebookfs] Failed to shut down kernel for %s: %v", nbPath, err)
+			}
+			cancel()
+		}
+	}
+	return nil
+}
+
+// backend returns the filesystem holding the notebooks
+func (n *NotebookFSPlugin) backend() (filesystem.FileSystem, error) {
+	if n.localFS != nil {
+		return n.localFS, nil
+	}
+	if n.rootFS == nil {
+		return nil, fmt.Errorf("notebookfs has no parent filesystem; it must be mounted in AGFS")
+	}
+	return n.rootFS, nil
+}
+
+// notebookFS implements the FileSystem interface for notebook access
+type notebookFS struct {
+	plugin *NotebookFSPlugin
+}
+
+// resolvedPath is a plugin path mapped onto the backing filesystem
+type resolvedPath struct {
+	source   string // Path on the backing filesystem: a directory, or the notebook file
+	notebook bool   // Whether source is a notebook
+	inner    string // Path inside the notebook, empty for its root
+}
+
+// isOwnMount reports whether a parent path is this plugin's own mount
+func (nfs *notebookFS) isOwnMount(p string) bool {
+	if nfs.plugin.localFS != nil {
+		return false
+	}
+	mount := nfs.plugin.mountPath
+	return mount != "" && mount != "/" && (p == mount || strings.HasPrefix(p, mount+"/"))
+}
+
+// resolve walks a path until the first component that is a notebook file
+func (nfs *notebookFS) resolve(op, p string) (filesystem.FileSystem, *resolvedPath, error) {
+	backend, err := nfs.plugin.backend()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cur := nfs.plugin.source
+	trimmed := strings.Trim(path.Clean("/"+p), "/")
+	if trimmed == "" {
+		return backend, &resolvedPath{source: cur}, nil
+	}
+
+	parts := strings.Split(trimmed, "/")
+	for i, part := range parts {
+		cur = path.Join(cur, part)
+		if nfs.isOwnMount(cur) || part == checkpointsDirName {
+			return nil, nil, filesystem.NewNotFoundError(op, p)
+		}
+		if !strings.HasSuffix(part, notebookExt) {
+			continue
+		}
+		info, err := backend.Stat(cur)
+		if err != nil {
+			return nil, nil, filesystem.NewNotFoundError(op, p)
+		}
+		if info.IsDir {
+			continue
+		}
+		return backend, &resolvedPath{source: cur, notebook: true, inner: strings.Join(parts[i+1:], "/")}, nil
+	}
+	return backend, &resolvedPath{source: cur}, nil
+}
+
+// readFile reads a whole file from the backing filesystem
+func readFile(fs filesystem.FileSystem, p string) ([]byte, error) {
+	data, err := fs.Read(p, 0, -1)
+	if err == io.EOF {
+		err = nil
+	}
+	return data, err
+}
+
+func (nfs *notebookFS) load(fs filesystem.FileSystem, nbPath string) (*notebook, error) {
+	data, err := readFile(fs, nbPath)
+	if err != nil {
+		return nil, err
+	}
+	return parseNotebook(data)
+}
+
+func (nfs *notebookFS) save(fs filesystem.FileSystem, nbPath string, nb *notebook) error {
+	data, err := nb.marshal()
+	if err != nil {
+		return err
+	}
+	_, err = fs.Write(nbPath, data, -1, filesystem.WriteFlagCreate|filesystem.WriteFlagTruncate)
+	return err
+}
+
+// update applies fn to a notebook and saves it if fn succeeds
+func (nfs *notebookFS) update(fs filesystem.FileSystem, nbPath string, fn func(nb *notebook) error) error {
+	nfs.plugin.nbMu.Lock()
+	defer nfs.plugin.nbMu.Unlock()
+
+	nb, err := nfs.load(fs, nbPath)
+	if err != nil {
+		return err
+	}
+	if err := fn(nb); err != nil {
+		return err
+	}
+	return nfs.save(fs, nbPath, nb)
+}
+
+func dirInfo(name, metaType string, modTime time.Time, content map[string]string) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    name,
+		Size:    0,
+		Mode:    0755,
+		ModTime: modTime,
+		IsDir:   true,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: metaType, Content: content},
+	}
+}
+
+func fileInfo(name, metaType string, size int64, mode uint32, modTime time.Time, content map[string]string) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    name,
+		Size:    size,
+		Mode:    mode,
+		ModTime: modTime,
+		IsDir:   false,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: metaType, Content: content},
+	}
+}
+
+func (nfs *notebookFS) readmeInfo() filesystem.FileInfo {
+	return fileInfo("README", "doc", int64(len(nfs.plugin.GetReadme())), 0444, time.Now(), nil)
+}
+
+func cellInfo(nb *notebook, i int, modTime time.Time) filesystem.FileInfo {
+	cell := nb.cells[i]
+	cellType, _ := cell["cell_type"].(string)
+	return fileInfo(nb.cellName(i), MetaValueCell, int64(len(cellSource(cell))), 0644, modTime,
+		map[string]string{"cell_type": cellType})
+}
+
+// codeCellByNumber finds a code cell by its number without extension, as used under outputs/
+func codeCellByNumber(nb *notebook, name string) (int, bool) {
+	i, err := strconv.Atoi(name)
+	if err != nil || i < 0 || i >= len(nb.cells) || fmt.Sprintf("%03d", i) != name || nb.cells[i]["cell_type"] != cellCode {
+		return 0, false
+	}
+	return i, true
+}
+
+// notebookEntry is a resolved path inside a notebook
+type notebookEntry struct {
+	kind string // MetaValueNotebook, MetaValueCell, MetaValueOutputs (dir when index < 0), MetaValueControl
+	cell int
+}
+
+// entry looks up the path inside a notebook
+func (nfs *notebookFS) entry(op, p string, nb *notebook, inner string) (*notebookEntry, error) {
+	switch {
+	case inner == "":
+		return &notebookEntry{kind: MetaValueNotebook}, nil
+	case inner == executeFile:
+		return &notebookEntry{kind: MetaValueControl}, nil
+	case inner == outputsDir:
+		return &notebookEntry{kind: MetaValueOutputs, cell: -1}, nil
+	case strings.HasPrefix(inner, outputsDir+"/"):
+		if i, ok := codeCellByNumber(nb, strings.TrimPrefix(inner, outputsDir+"/")); ok {
+			return &notebookEntry{kind: MetaValueOutputs, cell: i}, nil
+		}
+	default:
+		if i, ok := nb.findCell(inner); ok {
+			return &notebookEntry{kind: MetaValueCell, cell: i}, nil
+		}
+	}
+	return nil, filesystem.NewNotFoundError(op, p)
+}
+
+func (nfs *notebookFS) executionRecord(nbPath string) []byte {
+	nfs.plugin.mu.Lock()
+	rec, ok := nfs.plugin.records[nbPath]
+	nfs.plugin.mu.Unlock()
+	if !ok {
+		return []byte{}
+	}
+	data, _ := json.MarshalIndent(rec, "", "  ")
+	return append(data, '\n')
+}
+
+func (nfs *notebookFS) Create(p string) error {
+	fs, r, err := nfs.resolve("create", p)
+	if err != nil {
+		return err
+	}
+	if !r.notebook || r.inner == "" || strings.Contains(r.inner, "/") {
+		return filesystem.NewPermissionDeniedError("create", p, "only cells can be created")
+	}
+	return nfs.update(fs, r.source, func(nb *notebook) error {
+		if _, ok := nb.findCell(r.inner); ok || r.inner == executeFile {
+			return filesystem.NewAlreadyExistsError("file", p)
+		}
+		cell, ok := nb.newCell(r.inner)
+		if !ok {
+			return filesystem.NewInvalidArgumentError("name", r.inner, fmt.Sprintf("new cells must be named %03d.<ext>", len(nb.cells)))
+		}
+		nb.cells = append(nb.cells, cell)
+		return nil
+	})
+}
+
+func (nfs *notebookFS) Mkdir(p string, perm uint32) error {
+	return filesystem.NewPermissionDeniedError("mkdir", p, "notebookfs does not create directories")
+}
+
+func (nfs *notebookFS) Remove(p string) error {
+	fs, r, err := nfs.resolve("remove", p)
+	if err != nil {
+		return err
+	}
+	if !r.notebook || r.inner == "" {
+		return filesystem.NewPermissionDeniedError("remove", p, "only cells can be removed")
+	}
+	return nfs.update(fs, r.source, func(nb *notebook) error {
+		e, err := nfs.entry("remove", p, nb, r.inner)
+		if err != nil {
+			return err
+		}
+		if e.kind != MetaValueCell {
+			return filesystem.NewPermissionDeniedError("remove", p, "only cells can be removed")
+		}
+		nb.cells = append(nb.cells[:e.cell], nb.cells[e.cell+1:]...)
+		return nil
+	})
+}
+
+func (nfs *notebookFS) RemoveAll(p string) error {
+	return nfs.Remove(p)
+}
+
+func (nfs *notebookFS) Read(p string, offset int64, size int64) ([]byte, error) {
+	if p == "/README" {
+		return plugin.ApplyRangeRead([]byte(nfs.plugin.GetReadme()), offset, size)
+	}
+
+	fs, r, err := nfs.resolve("read", p)
+	if err != nil {
+		return nil, err
+	}
+	if !r.notebook {
+		if info, err := fs.Stat(r.source); err != nil || !info.IsDir {
+			return nil, filesystem.NewNotFoundError("read", p)
+		}
+		return nil, fmt.Errorf("is a directory: %s", p)
+	}
+
+	nb, err := nfs.load(fs, r.source)
+	if err != nil {
+		return nil, err
+	}
+	e, err := nfs.entry("read", p, nb, r.inner)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	switch {
+	case e.kind == MetaValueCell:
+		data = []byte(cellSource(nb.cells[e.cell]))
+	case e.kind == MetaValueControl:
+		data = nfs.executionRecord(r.source)
+	case e.kind == MetaValueOutputs && e.cell >= 0:
+		data = []byte(outputsText(nb.cells[e.cell]))
+	default:
+		return nil, fmt.Errorf("is a directory: %s", p)
+	}
+	return plugin.ApplyRangeRead(data, offset, size)
+}
+
+func (nfs *notebookFS) Write(p string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	fs, r, err := nfs.resolve("write", p)
+	if err != nil {
+		return 0, err
+	}
+	if !r.notebook || r.inner == "" {
+		return 0, filesystem.NewPermissionDeniedError("write", p, "only cells and the execute file are writable")
+	}
+
+	if r.inner == executeFile {
+		if err := nfs.execute(fs, r.source, string(data)); err != nil {
+			return 0, err
+		}
+		return int64(len(data)), nil
+	}
+
+	err = nfs.update(fs, r.source, func(nb *notebook) error {
+		i, ok := nb.findCell(r.inner)
+		if !ok {
+			cell, isNew := nb.newCell(r.inner)
+			if !isNew || flags&filesystem.WriteFlagCreate == 0 {
+				if _, err := nfs.entry("write", p, nb, r.inner); err == nil {
+					return filesystem.NewPermissionDeniedError("write", p, "outputs are read-only")
+				}
+				return filesystem.NewNotFoundError("write", p)
+			}
+			nb.cells = append(nb.cells, cell)
+			i = len(nb.cells) - 1
+		}
+
+		source := []byte(cellSource(nb.cells[i]))
+		if flags&filesystem.WriteFlagTruncate != 0 {
+			source = source[:0]
+		}
+		if flags&filesystem.WriteFlagAppend != 0 {
+			offset = int64(len(source))
+		}
+		if offset < 0 {
+			source = data
+		} else {
+			if end := offset + int64(len(data)); end > int64(len(source)) {
+				grown := make([]byte, end)
+				copy(grown, source)
+				source = grown
+			}
+			copy(source[offset:], data)
+		}
+		setCellSource(nb.cells[i], string(source))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
+
+func (nfs *notebookFS) ReadDir(p string) ([]filesystem.FileInfo, error) {
+	fs, r, err := nfs.resolve("readdir", p)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.notebook {
+		info, err := fs.Stat(r.source)
+		if err != nil {
+			return nil, err
+		}
+		nb, err := nfs.load(fs, r.source)
+		if err != nil {
+			return nil, err
+		}
+		e, err := nfs.entry("readdir", p, nb, r.inner)
+		if err != nil {
+			return nil, err
+		}
+
+		var files []filesystem.FileInfo
+		switch {
+		case e.kind == MetaValueNotebook:
+			for i := range nb.cells {
+				files = append(files, cellInfo(nb, i, info.ModTime))
+			}
+			files = append(files,
+				dirInfo(outputsDir, MetaValueOutputs, info.ModTime, nil),
+				fileInfo(executeFile, MetaValueControl, int64(len(nfs.executionRecord(r.source))), 0644, info.ModTime, nil))
+		case e.kind == MetaValueOutputs && e.cell < 0:
+			for i, cell := range nb.cells {
+				if cell["cell_type"] == cellCode {
+					files = append(files, fileInfo(fmt.Sprintf("%03d", i), MetaValueOutputs,
+						int64(len(outputsText(cell))), 0444, info.ModTime, nil))
+				}
+			}
+		default:
+			return nil, filesystem.NewNotDirectoryError(p)
+		}
+		return files, nil
+	}
+
+	infos, err := fs.ReadDir(r.source)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []filesystem.FileInfo
+	if strings.Trim(p, "/") == "" {
+		files = append(files, nfs.readmeInfo())
+	}
+	for _, info := range infos {
+		child := path.Join(r.source, info.Name)
+		if nfs.isOwnMount(child) || info.Name == checkpointsDirName {
+			continue
+		}
+		if info.IsDir {
+			files = append(files, dirInfo(info.Name, MetaValueDir, info.ModTime, nil))
+		} else if strings.HasSuffix(info.Name, notebookExt) {
+			files = append(files, dirInfo(info.Name, MetaValueNotebook, info.ModTime, map[string]string{"source": child}))
+		}
+	}
+	return files, nil
+}
+
+func (nfs *notebookFS) Stat(p string) (*filesystem.FileInfo, error) {
+	if p == "/README" {
+		info := nfs.readmeInfo()
+		return &info, nil
+	}
+
+	fs, r, err := nfs.resolve("stat", p)
+	if err != nil {
+		return nil, err
+	}
+	name := path.Base(p)
+
+	info, err := fs.Stat(r.source)
+	if err != nil || (!r.notebook && !info.IsDir) {
+		return nil, filesystem.NewNotFoundError("stat", p)
+	}
+	if !r.notebook {
+		result := dirInfo(name, MetaValueDir, info.ModTime, nil)
+		return &result, nil
+	}
+
+	nb, err := nfs.load(fs, r.source)
+	if err != nil {
+		return nil, err
+	}
+	e, err := nfs.entry("stat", p, nb, r.inner)
+	if err != nil {
+		return nil, err
+	}
+
+	var result filesystem.FileInfo
+	switch {
+	case e.kind == MetaValueNotebook:
+		kernel := nfs.plugin.kernelFor(nb)
+		result = dirInfo(name, MetaValueNotebook, info.ModTime, map[string]string{
+			"source": r.source,
+			"cells":  strconv.Itoa(len(nb.cells)),
+			"kernel": kernel,
+		})
+	case e.kind == MetaValueCell:
+		result = cellInfo(nb, e.cell, info.ModTime)
+	case e.kind == MetaValueControl:
+		result = fileInfo(executeFile, MetaValueControl, int64(len(nfs.executionRecord(r.source))), 0644, info.ModTime, nil)
+	case e.cell < 0:
+		result = dirInfo(outputsDir, MetaValueOutputs, info.ModTime, nil)
+	default:
+		result = fileInfo(name, MetaValueOutputs, int64(len(outputsText(nb.cells[e.cell]))), 0444, info.ModTime, nil)
+	}
+	return &result, nil
+}
+
+func (nfs *notebookFS) Rename(oldPath, newPath string) error {
+	return filesystem.NewNotSupportedError("rename", oldPath)
+}
+
+func (nfs *notebookFS) Chmod(path string, mode uint32) error {
+	return nil
+}
+
+func (nfs *notebookFS) Truncate(p string, size int64) error {
+	fs, r, err := nfs.resolve("truncate", p)
+	if err != nil {
+		return err
+	}
+	if !r.notebook || r.inner == "" {
+		return filesystem.NewPermissionDeniedError("truncate", p, "only cells can be truncated")
+	}
+	if r.inner == executeFile {
+		return nil
+	}
+	return nfs.update(fs, r.source, func(nb *notebook) error {
+		i, ok := nb.findCell(r.inner)
+		if !ok {
+			return filesystem.NewNotFoundError("truncate", p)
+		}
+		source := []byte(cellSource(nb.cells[i]))
+		if size < int64(len(source)) {
+			source = source[:size]
+		} else {
+			source = append(source, make([]byte, size-int64(len(source)))...)
+		}
+		setCellSource(nb.cells[i], string(source))
+		return nil
+	})
+}
+
+func (nfs *notebookFS) Open(path string) (io.ReadCloser, error) {
+	data, err := nfs.Read(path, 0, -1)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (nfs *notebookFS) OpenWrite(path string) (io.WriteCloser, error) {
+	return &notebookWriter{fs: nfs, path: path}, nil
+}
+
+// notebookWriter buffers writes and applies them on Close
+type notebookWriter struct {
+	fs   *notebookFS
+	path string
+	buf  bytes.Buffer
+}
+
+func (w *notebookWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *notebookWriter) Close() error {
+	_, err := w.fs.Write(w.path, w.buf.Bytes(), 0, filesystem.WriteFlagCreate|filesystem.WriteFlagTruncate)
+	return err
+}
+
+// kernelFor returns the kernel a notebook runs on
+func (n *NotebookFSPlugin) kernelFor(nb *notebook) string {
+	if n.kernelName != "" {
+		return n.kernelName
+	}
+	if name := nb.kernelName(); name != "" {
+		return name
+	}
+	return defaultKernelName
+}
+
+// executionLock returns the lock serializing executions of one notebook
+func (n *NotebookFSPlugin) executionLock(nbPath string) *sync.Mutex {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	m, ok := n.execMu[nbPath]
+	if !ok {
+		m = &sync.Mutex{}
+		n.execMu[nbPath] = m
+	}
+	return m
+}
+
+// connect opens the channels of a notebook's kernel, starting a kernel when
+// the notebook has none or its kernel is gone
+func (n *NotebookFSPlugin) connect(ctx context.Context, nbPath, kernelName string) (*kernelConn, error) {
+	n.mu.Lock()
+	id, ok := n.kernels[nbPath]
+	n.mu.Unlock()
+
+	if ok {
+		conn, err := n.gateway.Connect(ctx, id)
+		var gwErr *gatewayError
+		if err == nil || !errors.As(err, &gwErr) || gwErr.StatusCode != http.StatusNotFound {
+			return conn, err
+		}
+		log.Infof("[notebookfs] Kernel %s for %s is gone, starting a new one", id, nbPath)
+	}
+
+	id, err := n.gateway.StartKernel(ctx, kernelName)
+	if err != nil {
+		return nil, err
+	}
+	n.mu.Lock()
+	n.kernels[nbPath] = id
+	n.mu.Unlock()
+	log.Infof("[notebookfs] Started %s kernel %s for %s", kernelName, id, nbPath)
+
+	return n.gateway.Connect(ctx, id)
+}
+
+// parseExecuteRequest returns the cells selected by an execute request
+// Accepted forms: "N", "N-M" (inclusive), "all". Non-code cells are skipped.
+func parseExecuteRequest(req string, nb *notebook) ([]int, error) {
+	lo, hi := 0, len(nb.cells)-1
+	if req != "all" {
+		first, last, isRange := strings.Cut(req, "-")
+		var err1, err2 error
+		lo, err1 = strconv.Atoi(first)
+		hi, err2 = lo, nil
+		if isRange {
+			hi, err2 = strconv.Atoi(last)
+		}
+		if err1 != nil || err2 != nil || lo < 0 || hi < lo {
+			return nil, filesystem.NewInvalidArgumentError("execute", req, `expected "N", "N-M", "all" or "restart"`)
+		}
+		if hi >= len(nb.cells) {
+			return nil, filesystem.NewInvalidArgumentError("execute", req, fmt.Sprintf("notebook has %d cells", len(nb.cells)))
+		}
+	}
+
+	var cells []int
+	for i := lo; i <= hi; i++ {
+		if nb.cells[i]["cell_type"] == cellCode {
+			cells = append(cells, i)
+		}
+	}
+	return cells, nil
+}
+
+// execute runs the cells selected by req on the notebook's kernel
+func (nfs *notebookFS) execute(fs filesystem.FileSystem, nbPath, req string) error {
+	n := nfs.plugin
+	if n.gateway == nil {
+		return filesystem.NewPermissionDeniedError("execute", nbPath, "no gateway_url configured")
+	}
+	req = strings.TrimSpace(req)
+	if len(req) > maxExecuteRequestSz {
+		return filesystem.NewInvalidArgumentError("execute", req[:32]+"...", "request too long")
+	}
+
+	lock := n.executionLock(nbPath)
+	lock.Lock()
+	defer lock.Unlock()
+
+	nfs.plugin.nbMu.Lock()
+	nb, err := nfs.load(fs, nbPath)
+	nfs.plugin.nbMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	rec := ExecutionRecord{Request: req, Kernel: n.kernelFor(nb), Cells: []CellResult{}, StartedAt: time.Now()}
+	defer func() {
+		rec.FinishedAt = time.Now()
+		n.mu.Lock()
+		n.records[nbPath] = rec
+		n.mu.Unlock()
+	}()
+
+	if req == "restart" {
+		n.mu.Lock()
+		id, ok := n.kernels[nbPath]
+		n.mu.Unlock()
+		if ok {
+			ctx, cancel := context.WithTimeout(context.Background(), n.timeout)
+			defer cancel()
+			if err := n.gateway.RestartKernel(ctx, id); err != nil {
+				rec.Error = err.Error()
+				return err
+			}
+		}
+		return nil
+	}
+
+	cells, err := parseExecuteRequest(req, nb)
+	if err != nil {
+		rec.Error = err.Error()
+		return err
+	}
+	if len(cells) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), n.timeout)
+	conn, err := n.connect(ctx, nbPath, rec.Kernel)
+	cancel()
+	if err != nil {
+		rec.Error = err.Error()
+		return err
+	}
+	defer conn.Close()
+
+	sources := make(map[int]string)
+	results := make(map[int]*execution)
+	for _, i := range cells {
+		source := cellSource(nb.cells[i])
+		ctx, cancel := context.WithTimeout(context.Background(), n.timeout)
+		exec, err := conn.Execute(ctx, source)
+		cancel()
+		if err != nil {
+			rec.Cells = append(rec.Cells, CellResult{Cell: nb.cellName(i), Status: "error", Error: err.Error()})
+			rec.Error = err.Error()
+			break
+		}
+
+		sources[i], results[i] = source, exec
+		result := CellResult{
+			Cell:           nb.cellName(i),
+			Status:         exec.Status,
+			ExecutionCount: exec.ExecutionCount,
+			Output:         outputsText(map[string]interface{}{"outputs": toInterfaces(exec.Outputs)}),
+		}
+		if exec.ErrorName != "" {
+			result.Error = exec.ErrorName + ": " + exec.ErrorValue
+		}
+		rec.Cells = append(rec.Cells, result)
+		if exec.Status != "ok" {
+			break
+		}
+	}
+
+	if n.saveOutputs && len(results) > 0 {
+		// Cells edited while executing keep their new source and old outputs
+		err := nfs.update(fs, nbPath, func(nb *notebook) error {
+			for i, exec := range results {
+				if i >= len(nb.cells) || nb.cells[i]["cell_type"] != cellCode || cellSource(nb.cells[i]) != sources[i] {
+					continue
+				}
+				nb.cells[i]["outputs"] = toInterfaces(exec.Outputs)
+				if exec.ExecutionCount > 0 {
+					nb.cells[i]["execution_count"] = exec.ExecutionCount
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			rec.Error = fmt.Sprintf("failed to save outputs: %v", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func toInterfaces(outputs []map[string]interface{}) []interface{} {
+	list := make([]interface{}, len(outputs))
+	for i, o := range outputs {
+		list[i] = o
+	}
+	return list
+}
+
+// Ensure NotebookFSPlugin implements ServicePlugin
+var _ plugin.ServicePlugin = (*NotebookFSPlugin)(nil)
+var _ filesystem.FileSystem = (*notebookFS)(nil)
+var _ filesystem.Truncater = (*notebookFS)(nil)
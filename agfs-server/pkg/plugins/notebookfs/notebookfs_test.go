@@ -0,0 +1,341 @@
+package notebookfs
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/memfs"
+	"github.com/gorilla/websocket"
+)
+
+const testNotebook = `{
+ "cells": [
+  {"cell_type": "markdown", "metadata": {}, "source": ["# Title\n", "Intro"]},
+  {"cell_type": "code", "execution_count": null, "metadata": {}, "outputs": [], "source": ["x = 1\n", "print(x)"]},
+  {"cell_type": "code", "execution_count": null, "metadata": {}, "outputs": [], "source": "x + 1"}
+ ],
+ "metadata": {
+  "kernelspec": {"display_name": "Python 3", "language": "python", "name": "python3"},
+  "language_info": {"file_extension": ".py", "name": "python"}
+ },
+ "nbformat": 4,
+ "nbformat_minor": 5
+}`
+
+// fakeGateway is a minimal Jupyter Kernel Gateway
+// Code is "executed" by echoing it on stdout; code containing "raise" fails.
+type fakeGateway struct {
+	mu       sync.Mutex
+	started  []string
+	restarts int
+	deleted  []string
+	count    int
+}
+
+func (g *fakeGateway) handler(t *testing.T) http.Handler {
+	upgrader := websocket.Upgrader{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/kernels", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Name string `json:"name"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		g.mu.Lock()
+		g.started = append(g.started, req.Name)
+		id := "kernel-" + req.Name
+		g.mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"id": id, "name": req.Name})
+	})
+	mux.HandleFunc("POST /api/kernels/{id}/restart", func(w http.ResponseWriter, r *http.Request) {
+		g.mu.Lock()
+		g.restarts++
+		g.count = 0
+		g.mu.Unlock()
+		w.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("DELETE /api/kernels/{id}", func(w http.ResponseWriter, r *http.Request) {
+		g.mu.Lock()
+		g.deleted = append(g.deleted, r.PathValue("id"))
+		g.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /api/kernels/{id}/channels", func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		defer ws.Close()
+		for {
+			var req kernelMessage
+			if err := ws.ReadJSON(&req); err != nil {
+				return
+			}
+			code, _ := req.Content["code"].(string)
+			g.mu.Lock()
+			g.count++
+			count := g.count
+			g.mu.Unlock()
+
+			reply := func(msgType string, content map[string]interface{}) {
+				ws.WriteJSON(kernelMessage{
+					Header:       messageHeader{MsgID: msgType, MsgType: msgType},
+					ParentHeader: req.Header,
+					Content:      content,
+					Channel:      "iopub",
+				})
+			}
+			reply("status", map[string]interface{}{"execution_state": "busy"})
+			if strings.Contains(code, "raise") {
+				reply("error", map[string]interface{}{"ename": "ValueError", "evalue": "boom", "traceback": []string{"\x1b[0;31mValueError\x1b[0m: boom"}})
+				reply("execute_reply", map[string]interface{}{"status": "error", "execution_count": count})
+			} else {
+				reply("stream", map[string]interface{}{"name": "stdout", "text": "ran: " + code + "\n"})
+				reply("execute_result", map[string]interface{}{"data": map[string]interface{}{"text/plain": "42"}, "metadata": map[string]interface{}{}, "execution_count": count})
+				reply("execute_reply", map[string]interface{}{"status": "ok", "execution_count": count})
+			}
+			reply("status", map[string]interface{}{"execution_state": "idle"})
+		}
+	})
+	return mux
+}
+
+func newTestFS(t *testing.T, cfg map[string]interface{}) (*notebookFS, *memfs.MemoryFS) {
+	t.Helper()
+	p := NewNotebookFSPlugin()
+	root := memfs.NewMemoryFS()
+	p.SetParentFileSystem(root)
+	if err := p.Validate(cfg); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if err := p.Initialize(cfg); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	t.Cleanup(func() { p.Shutdown() })
+
+	root.Mkdir("/work", 0755)
+	if _, err := root.Write("/work/analysis.ipynb", []byte(testNotebook), -1, filesystem.WriteFlagCreate|filesystem.WriteFlagTruncate); err != nil {
+		t.Fatalf("write notebook: %v", err)
+	}
+	return p.GetFileSystem().(*notebookFS), root
+}
+
+// readIgnoreEOF reads file content, ignoring io.EOF which is expected at end of file
+func readIgnoreEOF(fs filesystem.FileSystem, path string) ([]byte, error) {
+	content, err := fs.Read(path, 0, -1)
+	if err == io.EOF {
+		return content, nil
+	}
+	return content, err
+}
+
+func loadNotebook(t *testing.T, root *memfs.MemoryFS) *notebook {
+	t.Helper()
+	data, err := readIgnoreEOF(root, "/work/analysis.ipynb")
+	if err != nil {
+		t.Fatalf("read notebook: %v", err)
+	}
+	nb, err := parseNotebook(data)
+	if err != nil {
+		t.Fatalf("parse notebook: %v", err)
+	}
+	return nb
+}
+
+func TestNotebookFSListing(t *testing.T) {
+	fs, root := newTestFS(t, map[string]interface{}{})
+	root.Write("/work/notes.txt", []byte("x"), -1, filesystem.WriteFlagCreate)
+	root.Mkdir("/work/.ipynb_checkpoints", 0755)
+
+	files, err := fs.ReadDir("/work")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(files) != 1 || files[0].Name != "analysis.ipynb" || !files[0].IsDir || files[0].Meta.Type != MetaValueNotebook {
+		t.Fatalf("unexpected listing: %+v", files)
+	}
+
+	files, err = fs.ReadDir("/work/analysis.ipynb")
+	if err != nil {
+		t.Fatalf("ReadDir notebook failed: %v", err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	if got := strings.Join(names, ","); got != "000.md,001.py,002.py,outputs,execute" {
+		t.Fatalf("unexpected notebook listing: %s", got)
+	}
+
+	data, err := readIgnoreEOF(fs, "/work/analysis.ipynb/001.py")
+	if err != nil || string(data) != "x = 1\nprint(x)" {
+		t.Fatalf("unexpected cell content: %q, %v", data, err)
+	}
+
+	info, err := fs.Stat("/work/analysis.ipynb")
+	if err != nil || info.Meta.Content["cells"] != "3" || info.Meta.Content["kernel"] != "python3" {
+		t.Fatalf("unexpected notebook stat: %+v, %v", info, err)
+	}
+
+	if _, err := fs.Stat("/work/analysis.ipynb/001.md"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Fatalf("expected not found for wrong extension, got %v", err)
+	}
+	if _, err := fs.Stat("/work/notes.txt"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Fatalf("expected non-notebook files to be hidden, got %v", err)
+	}
+}
+
+func TestNotebookFSEditCells(t *testing.T) {
+	fs, root := newTestFS(t, map[string]interface{}{})
+
+	if _, err := fs.Write("/work/analysis.ipynb/002.py", []byte("x * 2\n"), -1, filesystem.WriteFlagTruncate); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	nb := loadNotebook(t, root)
+	if got := cellSource(nb.cells[2]); got != "x * 2\n" {
+		t.Fatalf("unexpected saved source: %q", got)
+	}
+	if nb.kernelName() != "python3" {
+		t.Fatalf("notebook metadata lost")
+	}
+
+	// Appending a cell requires the next number
+	if _, err := fs.Write("/work/analysis.ipynb/007.py", []byte("y"), -1, filesystem.WriteFlagCreate); err == nil {
+		t.Fatalf("expected error for out-of-order cell")
+	}
+	if _, err := fs.Write("/work/analysis.ipynb/003.md", []byte("## Done\n"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatalf("append cell failed: %v", err)
+	}
+	if err := fs.Create("/work/analysis.ipynb/004.py"); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	nb = loadNotebook(t, root)
+	if len(nb.cells) != 5 || nb.cells[3]["cell_type"] != cellMarkdown || nb.cells[4]["cell_type"] != cellCode {
+		t.Fatalf("unexpected cells after append: %+v", nb.cells)
+	}
+
+	// Removing a cell renumbers the following cells
+	if err := fs.Remove("/work/analysis.ipynb/000.md"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	data, err := readIgnoreEOF(fs, "/work/analysis.ipynb/000.py")
+	if err != nil || string(data) != "x = 1\nprint(x)" {
+		t.Fatalf("unexpected content after renumbering: %q, %v", data, err)
+	}
+
+	if err := fs.Truncate("/work/analysis.ipynb/000.py", 5); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+	data, _ = readIgnoreEOF(fs, "/work/analysis.ipynb/000.py")
+	if string(data) != "x = 1" {
+		t.Fatalf("unexpected content after truncate: %q", data)
+	}
+
+	if _, err := fs.Write("/work/analysis.ipynb/outputs/000", []byte("x"), -1, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrPermissionDenied) {
+		t.Fatalf("expected outputs to be read-only, got %v", err)
+	}
+}
+
+func TestNotebookFSExecute(t *testing.T) {
+	gw := &fakeGateway{}
+	server := httptest.NewServer(gw.handler(t))
+	defer server.Close()
+
+	fs, root := newTestFS(t, map[string]interface{}{"gateway_url": server.URL})
+
+	if _, err := fs.Write("/work/analysis.ipynb/execute", []byte("all\n"), -1, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	data, err := readIgnoreEOF(fs, "/work/analysis.ipynb/execute")
+	if err != nil {
+		t.Fatalf("read execute failed: %v", err)
+	}
+	var rec ExecutionRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		t.Fatalf("invalid execution record %q: %v", data, err)
+	}
+	if rec.Request != "all" || rec.Kernel != "python3" || len(rec.Cells) != 2 || rec.Cells[0].Cell != "001.py" || rec.Cells[1].Status != "ok" {
+		t.Fatalf("unexpected execution record: %+v", rec)
+	}
+	if rec.Cells[1].Output != "ran: x + 1\n42\n" {
+		t.Fatalf("unexpected output: %q", rec.Cells[1].Output)
+	}
+
+	out, err := readIgnoreEOF(fs, "/work/analysis.ipynb/outputs/002")
+	if err != nil || string(out) != "ran: x + 1\n42\n" {
+		t.Fatalf("unexpected saved outputs: %q, %v", out, err)
+	}
+	if nb := loadNotebook(t, root); nb.cells[2]["execution_count"] != float64(2) {
+		t.Fatalf("execution count not saved: %v", nb.cells[2]["execution_count"])
+	}
+
+	// Errors stop execution
+	fs.Write("/work/analysis.ipynb/001.py", []byte("raise ValueError('boom')"), -1, filesystem.WriteFlagTruncate)
+	if _, err := fs.Write("/work/analysis.ipynb/execute", []byte("1-2"), -1, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	data, _ = readIgnoreEOF(fs, "/work/analysis.ipynb/execute")
+	rec = ExecutionRecord{}
+	json.Unmarshal(data, &rec)
+	if len(rec.Cells) != 1 || rec.Cells[0].Status != "error" || rec.Cells[0].Error != "ValueError: boom" {
+		t.Fatalf("unexpected execution record after error: %+v", rec)
+	}
+	out, _ = readIgnoreEOF(fs, "/work/analysis.ipynb/outputs/001")
+	if !strings.HasPrefix(string(out), "ValueError: boom\nValueError: boom") {
+		t.Fatalf("unexpected error output: %q", out)
+	}
+
+	// The kernel is reused until restart
+	if _, err := fs.Write("/work/analysis.ipynb/execute", []byte("restart"), -1, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("restart failed: %v", err)
+	}
+	gw.mu.Lock()
+	started, restarts := len(gw.started), gw.restarts
+	gw.mu.Unlock()
+	if started != 1 || restarts != 1 {
+		t.Fatalf("expected 1 kernel start and 1 restart, got %d and %d", started, restarts)
+	}
+
+	for _, req := range []string{"5", "2-1", "x"} {
+		if _, err := fs.Write("/work/analysis.ipynb/execute", []byte(req), -1, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrInvalidArgument) {
+			t.Fatalf("expected invalid argument for %q, got %v", req, err)
+		}
+	}
+}
+
+func TestNotebookFSExecuteWithoutGateway(t *testing.T) {
+	fs, _ := newTestFS(t, map[string]interface{}{})
+	if _, err := fs.Write("/work/analysis.ipynb/execute", []byte("all"), -1, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrPermissionDenied) {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+}
+
+func TestNotebookFSValidate(t *testing.T) {
+	p := NewNotebookFSPlugin()
+	tests := []map[string]interface{}{
+		{"unknown": true},
+		{"source": "relative"},
+		{"source": "/", "local_path": "/tmp"},
+		{"gateway_url": "ftp://host"},
+		{"execute_timeout": "soon"},
+		{"execute_timeout": "-1s"},
+		{"save_outputs": "yes"},
+	}
+	for _, cfg := range tests {
+		if err := p.Validate(cfg); err == nil {
+			t.Errorf("expected error for %v", cfg)
+		}
+	}
+	if err := p.Validate(map[string]interface{}{"gateway_url": "http://localhost:8888", "execute_timeout": "30s"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package observabilityfs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// promClient talks to the Prometheus HTTP query API
+// VictoriaMetrics, Thanos, Mimir and other servers implementing the same API work too.
+type promClient struct {
+	http        *http.Client
+	baseURL     string
+	bearerToken string
+	username    string
+	password    string
+}
+
+func newPromClient(rawURL, bearerToken, username, password string, timeout time.Duration) (*promClient, error) {
+	u, err := url.Parse(strings.TrimSuffix(rawURL, "/"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid url %q: must be an http(s) URL", rawURL)
+	}
+	return &promClient{
+		http:        &http.Client{Timeout: timeout},
+		baseURL:     u.String(),
+		bearerToken: bearerToken,
+		username:    username,
+		password:    password,
+	}, nil
+}
+
+// apiError is an error response from the query API
+type apiError struct {
+	Status    int
+	ErrorType string
+	Message   string
+}
+
+func (e *apiError) Error() string {
+	if e.ErrorType != "" {
+		return fmt.Sprintf("query failed (%s): %s", e.ErrorType, e.Message)
+	}
+	return fmt.Sprintf("query failed (status %d): %s", e.Status, e.Message)
+}
+
+// queryResult is the data of a successful query response
+type queryResult struct {
+	ResultType string          `json:"resultType"`
+	Result     json.RawMessage `json:"result"`
+}
+
+// series is one element of a vector or matrix result
+type series struct {
+	Metric map[string]string `json:"metric"`
+	Value  []interface{}     `json:"value,omitempty"`  // [timestamp, "value"] for vectors
+	Values [][]interface{}   `json:"values,omitempty"` // [[timestamp, "value"], ...] for matrices
+}
+
+// Query evaluates a query; a zero start runs an instant query at end,
+// otherwise a range query from start to end in steps of step.
+func (c *promClient) Query(ctx context.Context, q *Query) (*queryResult, error) {
+	params := url.Values{"query": {q.Expr}}
+	endpoint := "/api/v1/query"
+	if q.Start.IsZero() {
+		params.Set("time", formatTime(q.End))
+	} else {
+		endpoint = "/api/v1/query_range"
+		params.Set("start", formatTime(q.Start))
+		params.Set("end", formatTime(q.End))
+		params.Set("step", strconv.FormatFloat(q.Step.Seconds(), 'f', -1, 64))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+endpoint, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if c.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	} else if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("query request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read query response: %w", err)
+	}
+
+	var envelope struct {
+		Status    string      `json:"status"`
+		Data      queryResult `json:"data"`
+		ErrorType string      `json:"errorType"`
+		Error     string      `json:"error"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		msg := strings.TrimSpace(string(body))
+		if len(msg) > 512 {
+			msg = msg[:512]
+		}
+		return nil, &apiError{Status: resp.StatusCode, Message: msg}
+	}
+	if envelope.Status != "success" {
+		return nil, &apiError{Status: resp.StatusCode, ErrorType: envelope.ErrorType, Message: envelope.Error}
+	}
+	return &envelope.Data, nil
+}
+
+func formatTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixMilli())/1000, 'f', -1, 64)
+}
+
+// parseSample converts a [timestamp, "value"] pair
+func parseSample(pair []interface{}) (time.Time, string, bool) {
+	if len(pair) != 2 {
+		return time.Time{}, "", false
+	}
+	ts, ok := pair[0].(float64)
+	if !ok {
+		return time.Time{}, "", false
+	}
+	value, ok := pair[1].(string)
+	if !ok {
+		return time.Time{}, "", false
+	}
+	sec, frac := math.Modf(ts)
+	return time.Unix(int64(sec), int64(math.Round(frac*1000))*int64(time.Millisecond)).UTC(), value, true
+}
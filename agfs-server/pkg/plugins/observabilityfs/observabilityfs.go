@@ -0,0 +1,637 @@
+package observabilityfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PluginName = "observabilityfs" // Name of this plugin
+)
+
+// Meta values for ObservabilityFS plugin
+const (
+	MetaValueQuery     = "query"     // The query control file
+	MetaValueResult    = "result"    // Result of the last query
+	MetaValueDashboard = "dashboard" // Dashboard directory
+	MetaValuePanel     = "panel"     // Dashboard panel, evaluated on read
+)
+
+const (
+	fileQuery      = "query"
+	fileResultJSON = "result.json"
+	fileResultCSV  = "result.csv"
+	dirDashboards  = "dashboards"
+)
+
+// Files of each dashboard panel, by extension
+const (
+	extJSON  = ".json"
+	extCSV   = ".csv"
+	extQuery = ".query"
+)
+
+var panelExtensions = []string{extJSON, extCSV, extQuery}
+
+// lastQuery is the state of the most recent write to the query file
+type lastQuery struct {
+	text       string
+	resultJSON []byte
+	resultCSV  []byte
+	modTime    time.Time
+}
+
+// ObservabilityFSPlugin maps Prometheus-compatible queries to files
+//
+//	/query                               - write PromQL (or a JSON spec) to run it
+//	/result.json, /result.csv            - result of the last query
+//	/dashboards/<dashboard>/<panel>.csv  - predefined queries, evaluated on read
+type ObservabilityFSPlugin struct {
+	client     *promClient
+	url        string
+	timeout    time.Duration
+	dashboards map[string]map[string]*querySpec // Dashboard -> panel -> query
+
+	last     lastQuery
+	mu       sync.Mutex
+	metadata plugin.PluginMetadata
+}
+
+// NewObservabilityFSPlugin creates a new metrics query plugin
+func NewObservabilityFSPlugin() *ObservabilityFSPlugin {
+	return &ObservabilityFSPlugin{
+		metadata: plugin.PluginMetadata{
+			Name:        PluginName,
+			Version:     "1.0.0",
+			Description: "Query Prometheus-compatible metrics backends through files",
+			Author:      "AGFS Server",
+		},
+	}
+}
+
+func (o *ObservabilityFSPlugin) Name() string {
+	return o.metadata.Name
+}
+
+func (o *ObservabilityFSPlugin) Validate(cfg map[string]interface{}) error {
+	allowedKeys := []string{"mount_path", "url", "bearer_token", "username", "password", "timeout", "dashboards"}
+	if err := config.ValidateOnlyKnownKeys(cfg, allowedKeys); err != nil {
+		return err
+	}
+
+	for _, key := range []string{"url", "bearer_token", "username", "password", "timeout"} {
+		if err := config.ValidateStringType(cfg, key); err != nil {
+			return err
+		}
+	}
+	if err := config.ValidateMapType(cfg, "dashboards"); err != nil {
+		return err
+	}
+
+	rawURL, err := config.RequireString(cfg, "url")
+	if err != nil {
+		return err
+	}
+	if _, err := newPromClient(rawURL, "", "", "", time.Second); err != nil {
+		return err
+	}
+	if timeout, err := time.ParseDuration(config.GetStringConfig(cfg, "timeout", "30s")); err != nil || timeout <= 0 {
+		return fmt.Errorf("invalid timeout: must be a positive duration such as \"30s\"")
+	}
+
+	_, err = parseDashboards(cfg)
+	return err
+}
+
+// parseDashboards reads the dashboards map
+// Each panel is either a PromQL string (an instant query) or a map with the
+// querySpec fields, e.g. {query = "...", range = "1h", step = "1m"}.
+func parseDashboards(cfg map[string]interface{}) (map[string]map[string]*querySpec, error) {
+	dashboards := make(map[string]map[string]*querySpec)
+	raw, ok := cfg["dashboards"].(map[string]interface{})
+	if !ok {
+		return dashboards, nil
+	}
+
+	for name, v := range raw {
+		if err := validateName(name); err != nil {
+			return nil, fmt.Errorf("dashboard %q: %w", name, err)
+		}
+		panels, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("dashboards.%s must be a map of panels", name)
+		}
+
+		dashboards[name] = make(map[string]*querySpec, len(panels))
+		for panel, pv := range panels {
+			if err := validateName(panel); err != nil {
+				return nil, fmt.Errorf("panel %s.%s: %w", name, panel, err)
+			}
+			var spec querySpec
+			switch p := pv.(type) {
+			case string:
+				spec.Query = p
+			case map[string]interface{}:
+				if err := config.ValidateOnlyKnownKeys(p, []string{"query", "time", "start", "end", "range", "step"}); err != nil {
+					return nil, fmt.Errorf("panel %s.%s: %w", name, panel, err)
+				}
+				spec = querySpec{
+					Query: config.GetStringConfig(p, "query", ""),
+					Time:  config.GetStringConfig(p, "time", ""),
+					Start: config.GetStringConfig(p, "start", ""),
+					End:   config.GetStringConfig(p, "end", ""),
+					Range: config.GetStringConfig(p, "range", ""),
+					Step:  config.GetStringConfig(p, "step", ""),
+				}
+			default:
+				return nil, fmt.Errorf("panel %s.%s must be a query string or a map", name, panel)
+			}
+			if strings.TrimSpace(spec.Query) == "" {
+				return nil, fmt.Errorf("panel %s.%s: missing query", name, panel)
+			}
+			if _, err := spec.resolve(time.Now()); err != nil {
+				return nil, fmt.Errorf("panel %s.%s: %w", name, panel, err)
+			}
+			dashboards[name][panel] = &spec
+		}
+	}
+	return dashboards, nil
+}
+
+// validateName checks that a configured name can be used as a file name
+func validateName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\x00") {
+		return fmt.Errorf("invalid name")
+	}
+	return nil
+}
+
+func (o *ObservabilityFSPlugin) Initialize(cfg map[string]interface{}) error {
+	timeout, err := time.ParseDuration(config.GetStringConfig(cfg, "timeout", "30s"))
+	if err != nil {
+		return fmt.Errorf("invalid timeout: %w", err)
+	}
+	o.timeout = timeout
+
+	o.url, err = config.RequireString(cfg, "url")
+	if err != nil {
+		return err
+	}
+	o.client, err = newPromClient(o.url,
+		config.GetStringConfig(cfg, "bearer_token", ""),
+		config.GetStringConfig(cfg, "username", ""),
+		config.GetStringConfig(cfg, "password", ""),
+		timeout)
+	if err != nil {
+		return err
+	}
+
+	o.dashboards, err = parseDashboards(cfg)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("[observabilityfs] Initialized (url=%s, %d dashboard(s))", o.url, len(o.dashboards))
+	return nil
+}
+
+func (o *ObservabilityFSPlugin) GetFileSystem() filesystem.FileSystem {
+	return &observabilityFS{plugin: o}
+}
+
+func (o *ObservabilityFSPlugin) GetReadme() string {
+	return `ObservabilityFS Plugin - Query Metrics through Files
+
+This plugin runs PromQL queries against Prometheus, VictoriaMetrics or any
+server implementing the Prometheus query API, and returns results as CSV
+or JSON files. Predefined dashboards appear as read-only directories.
+
+STRUCTURE:
+  /observabilityfs/
+    README                          - This documentation
+    query                           - Write a query to run it; read the last query
+    result.json                     - Result of the last query (JSON)
+    result.csv                      - Result of the last query (CSV)
+    dashboards/
+      <dashboard>/
+        <panel>.csv                 - Panel result (CSV), queried on every read
+        <panel>.json                - Panel result (JSON), queried on every read
+        <panel>.query               - The panel's query spec (JSON)
+
+QUERIES:
+  Plain PromQL runs an instant query at the current time:
+    up{job="api"} == 0
+
+  A JSON object selects the time or a range:
+    {"query": "rate(http_requests_total[5m])", "range": "1h", "step": "1m"}
+    {"query": "up", "start": "2024-05-01T10:00:00Z", "end": "now-30m"}
+    {"query": "up", "time": "-1d"}
+
+  Times accept RFC3339, Unix seconds, "now", or offsets such as "-1h".
+  Without a step, range queries return about 250 points per series.
+
+EXAMPLES:
+  agfs:/> echo 'sum by (job) (up)' > /observabilityfs/query
+  agfs:/> cat /observabilityfs/result.csv
+  timestamp,job,value
+  2024-05-01T10:00:00Z,api,3
+  2024-05-01T10:00:00Z,worker,2
+
+  agfs:/> ls /observabilityfs/dashboards/api
+  agfs:/> cat /observabilityfs/dashboards/api/error_rate.csv
+
+CONFIGURATION:
+  [plugins.observabilityfs]
+  enabled = true
+  path = "/observabilityfs"
+
+    [plugins.observabilityfs.config]
+    url = "http://localhost:9090"   # Prometheus-compatible query API
+    bearer_token = ""               # or username/password for basic auth
+    timeout = "30s"
+
+    [plugins.observabilityfs.config.dashboards.api]
+    up = 'up{job="api"}'
+    error_rate = { query = 'sum(rate(http_requests_total{code=~"5.."}[5m]))', range = "1h", step = "1m" }
+
+NOTES:
+  - CSV columns are timestamp, the series labels (__name__ first) and value.
+  - A failed query is returned as the write error and shown in result.json.
+  - query and result files are shared by all clients of the mount.
+`
+}
+
+func (o *ObservabilityFSPlugin) GetConfigParams() []plugin.ConfigParameter {
+	return []plugin.ConfigParameter{
+		{
+			Name:        "url",
+			Type:        "string",
+			Required:    true,
+			Default:     "",
+			Description: "Base URL of the Prometheus-compatible query API",
+		},
+		{
+			Name:        "bearer_token",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Bearer token sent with every query",
+			Secret:      true,
+		},
+		{
+			Name:        "username",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Basic auth username",
+		},
+		{
+			Name:        "password",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Basic auth password",
+			Secret:      true,
+		},
+		{
+			Name:        "timeout",
+			Type:        "string",
+			Required:    false,
+			Default:     "30s",
+			Description: "Query timeout",
+		},
+	}
+}
+
+func (o *ObservabilityFSPlugin) Shutdown() error {
+	return nil
+}
+
+// run evaluates a query spec and renders the result in both formats
+func (o *ObservabilityFSPlugin) run(spec *querySpec) (jsonData, csvData []byte, err error) {
+	q, err := spec.resolve(time.Now())
+	if err != nil {
+		return nil, nil, filesystem.NewInvalidArgumentError("query", spec.Query, err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+	res, err := o.client.Query(ctx, q)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if jsonData, err = resultJSON(q, res); err != nil {
+		return nil, nil, err
+	}
+	if csvData, err = resultCSV(res); err != nil {
+		return nil, nil, err
+	}
+	return jsonData, csvData, nil
+}
+
+// observabilityFS implements the FileSystem interface for metrics queries
+type observabilityFS struct {
+	plugin *ObservabilityFSPlugin
+}
+
+// node is a resolved path
+type node struct {
+	kind      string // "", MetaValueQuery, MetaValueResult, MetaValueDashboard or MetaValuePanel
+	name      string
+	isDir     bool
+	dashboard string
+	panel     *querySpec
+	ext       string
+}
+
+func (ofs *observabilityFS) resolve(op, path string) (*node, error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) == 1 && parts[0] == "" {
+		return &node{name: "/", isDir: true}, nil
+	}
+
+	switch {
+	case len(parts) == 1 && parts[0] == "README":
+		return &node{kind: "doc", name: "README"}, nil
+	case len(parts) == 1 && parts[0] == fileQuery:
+		return &node{kind: MetaValueQuery, name: fileQuery}, nil
+	case len(parts) == 1 && (parts[0] == fileResultJSON || parts[0] == fileResultCSV):
+		return &node{kind: MetaValueResult, name: parts[0]}, nil
+	case parts[0] == dirDashboards && len(parts) == 1:
+		return &node{kind: MetaValueDashboard, name: dirDashboards, isDir: true}, nil
+	case parts[0] == dirDashboards && len(parts) <= 3:
+		panels, ok := ofs.plugin.dashboards[parts[1]]
+		if !ok {
+			break
+		}
+		if len(parts) == 2 {
+			return &node{kind: MetaValueDashboard, name: parts[1], isDir: true, dashboard: parts[1]}, nil
+		}
+		for _, ext := range panelExtensions {
+			if spec, ok := panels[strings.TrimSuffix(parts[2], ext)]; ok && strings.HasSuffix(parts[2], ext) {
+				return &node{kind: MetaValuePanel, name: parts[2], dashboard: parts[1], panel: spec, ext: ext}, nil
+			}
+		}
+	}
+	return nil, filesystem.NewNotFoundError(op, path)
+}
+
+func (ofs *observabilityFS) content(n *node) ([]byte, error) {
+	p := ofs.plugin
+	switch n.kind {
+	case "doc":
+		return []byte(p.GetReadme()), nil
+	case MetaValueQuery, MetaValueResult:
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		switch n.name {
+		case fileQuery:
+			return []byte(p.last.text), nil
+		case fileResultJSON:
+			return p.last.resultJSON, nil
+		default:
+			return p.last.resultCSV, nil
+		}
+	case MetaValuePanel:
+		if n.ext == extQuery {
+			data, err := json.MarshalIndent(n.panel, "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			return append(data, '\n'), nil
+		}
+		jsonData, csvData, err := p.run(n.panel)
+		if err != nil {
+			return nil, err
+		}
+		if n.ext == extJSON {
+			return jsonData, nil
+		}
+		return csvData, nil
+	}
+	return nil, fmt.Errorf("is a directory: %s", n.name)
+}
+
+func (ofs *observabilityFS) Create(path string) error {
+	return filesystem.NewPermissionDeniedError("create", path, "observabilityfs does not support creating files")
+}
+
+func (ofs *observabilityFS) Mkdir(path string, perm uint32) error {
+	return filesystem.NewPermissionDeniedError("mkdir", path, "dashboards are defined in the plugin configuration")
+}
+
+func (ofs *observabilityFS) Remove(path string) error {
+	return filesystem.NewPermissionDeniedError("remove", path, "observabilityfs is read-only except for the query file")
+}
+
+func (ofs *observabilityFS) RemoveAll(path string) error {
+	return ofs.Remove(path)
+}
+
+func (ofs *observabilityFS) Read(path string, offset int64, size int64) ([]byte, error) {
+	n, err := ofs.resolve("read", path)
+	if err != nil {
+		return nil, err
+	}
+	if n.isDir {
+		return nil, fmt.Errorf("is a directory: %s", path)
+	}
+	data, err := ofs.content(n)
+	if err != nil {
+		return nil, err
+	}
+	return plugin.ApplyRangeRead(data, offset, size)
+}
+
+func (ofs *observabilityFS) Write(path string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	n, err := ofs.resolve("write", path)
+	if err != nil {
+		return 0, err
+	}
+	if n.kind != MetaValueQuery {
+		return 0, filesystem.NewPermissionDeniedError("write", path, "only the query file is writable")
+	}
+
+	spec, err := parseQuerySpec(data)
+	if err != nil {
+		return 0, filesystem.NewInvalidArgumentError("query", strings.TrimSpace(string(data)), err.Error())
+	}
+
+	p := ofs.plugin
+	jsonData, csvData, err := p.run(spec)
+	last := lastQuery{
+		text:       strings.TrimSpace(string(data)) + "\n",
+		resultJSON: jsonData,
+		resultCSV:  csvData,
+		modTime:    time.Now(),
+	}
+	if err != nil {
+		errJSON, _ := json.MarshalIndent(map[string]string{"query": spec.Query, "error": err.Error()}, "", "  ")
+		last.resultJSON = append(errJSON, '\n')
+		last.resultCSV = nil
+	}
+
+	p.mu.Lock()
+	p.last = last
+	p.mu.Unlock()
+
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
+
+func (ofs *observabilityFS) ReadDir(path string) ([]filesystem.FileInfo, error) {
+	n, err := ofs.resolve("readdir", path)
+	if err != nil {
+		return nil, err
+	}
+	if !n.isDir {
+		return nil, filesystem.NewNotDirectoryError(path)
+	}
+
+	var names []string
+	switch {
+	case n.kind == "":
+		names = []string{"README", fileQuery, fileResultJSON, fileResultCSV, dirDashboards}
+	case n.dashboard == "":
+		for name := range ofs.plugin.dashboards {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		names = prefixAll(dirDashboards+"/", names)
+	default:
+		var panels []string
+		for panel := range ofs.plugin.dashboards[n.dashboard] {
+			panels = append(panels, panel)
+		}
+		sort.Strings(panels)
+		for _, panel := range panels {
+			for _, ext := range panelExtensions {
+				names = append(names, panel+ext)
+			}
+		}
+		names = prefixAll(dirDashboards+"/"+n.dashboard+"/", names)
+	}
+
+	files := make([]filesystem.FileInfo, 0, len(names))
+	for _, name := range names {
+		info, err := ofs.Stat("/" + name)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, *info)
+	}
+	return files, nil
+}
+
+func prefixAll(prefix string, names []string) []string {
+	for i, name := range names {
+		names[i] = prefix + name
+	}
+	return names
+}
+
+func (ofs *observabilityFS) Stat(path string) (*filesystem.FileInfo, error) {
+	n, err := ofs.resolve("stat", path)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &filesystem.FileInfo{
+		Name:    n.name,
+		Mode:    0444,
+		ModTime: time.Now(),
+		IsDir:   n.isDir,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: n.kind},
+	}
+	if n.isDir {
+		info.Mode = 0555
+		return info, nil
+	}
+
+	switch n.kind {
+	case "doc":
+		info.Size = int64(len(ofs.plugin.GetReadme()))
+	case MetaValueQuery, MetaValueResult:
+		p := ofs.plugin
+		p.mu.Lock()
+		if !p.last.modTime.IsZero() {
+			info.ModTime = p.last.modTime
+		}
+		p.mu.Unlock()
+		data, _ := ofs.content(n)
+		info.Size = int64(len(data))
+		if n.kind == MetaValueQuery {
+			info.Mode = 0644
+		}
+	case MetaValuePanel:
+		// Panels are evaluated on read, so their size is not known up front
+		info.Meta.Content = map[string]string{"dashboard": n.dashboard, "query": n.panel.Query}
+	}
+	return info, nil
+}
+
+func (ofs *observabilityFS) Rename(oldPath, newPath string) error {
+	return filesystem.NewNotSupportedError("rename", oldPath)
+}
+
+func (ofs *observabilityFS) Chmod(path string, mode uint32) error {
+	return nil
+}
+
+func (ofs *observabilityFS) Truncate(path string, size int64) error {
+	// Shell redirection truncates before writing; the write replaces the query anyway
+	if n, err := ofs.resolve("truncate", path); err != nil {
+		return err
+	} else if n.kind != MetaValueQuery {
+		return filesystem.NewPermissionDeniedError("truncate", path, "only the query file is writable")
+	}
+	return nil
+}
+
+func (ofs *observabilityFS) Open(path string) (io.ReadCloser, error) {
+	data, err := ofs.Read(path, 0, -1)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (ofs *observabilityFS) OpenWrite(path string) (io.WriteCloser, error) {
+	return &queryWriter{fs: ofs, path: path}, nil
+}
+
+// queryWriter buffers a query and runs it on Close
+type queryWriter struct {
+	fs   *observabilityFS
+	path string
+	buf  bytes.Buffer
+}
+
+func (w *queryWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *queryWriter) Close() error {
+	_, err := w.fs.Write(w.path, w.buf.Bytes(), -1, filesystem.WriteFlagTruncate)
+	return err
+}
+
+// Ensure ObservabilityFSPlugin implements ServicePlugin
+var _ plugin.ServicePlugin = (*ObservabilityFSPlugin)(nil)
+var _ filesystem.FileSystem = (*observabilityFS)(nil)
+var _ filesystem.Truncater = (*observabilityFS)(nil)
@@ -0,0 +1,224 @@
+package observabilityfs
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+// fakeProm answers instant and range queries with fixed results
+// Queries containing "bad(" fail with a bad_data error.
+type fakeProm struct {
+	mu       sync.Mutex
+	requests []string // endpoint?query=...
+	auth     string
+}
+
+func (f *fakeProm) handler() http.Handler {
+	mux := http.NewServeMux()
+	reply := func(w http.ResponseWriter, r *http.Request, resultType, result string) {
+		r.ParseForm()
+		f.mu.Lock()
+		f.requests = append(f.requests, r.URL.Path+"?"+r.Form.Encode())
+		f.auth = r.Header.Get("Authorization")
+		f.mu.Unlock()
+
+		if strings.Contains(r.Form.Get("query"), "bad(") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status":"error","errorType":"bad_data","error":"parse error: unknown function"}`))
+			return
+		}
+		w.Write([]byte(`{"status":"success","data":{"resultType":"` + resultType + `","result":` + result + `}}`))
+	}
+	mux.HandleFunc("/api/v1/query", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, r, "vector", `[
+			{"metric":{"__name__":"up","job":"api","instance":"a:80"},"value":[1714557600,"1"]},
+			{"metric":{"__name__":"up","job":"worker"},"value":[1714557600.5,"0"]}
+		]`)
+	})
+	mux.HandleFunc("/api/v1/query_range", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, r, "matrix", `[
+			{"metric":{"job":"api"},"values":[[1714557600,"0.5"],[1714557660,"0.75"]]}
+		]`)
+	})
+	return mux
+}
+
+func newTestFS(t *testing.T, cfg map[string]interface{}) (*observabilityFS, *fakeProm) {
+	t.Helper()
+	prom := &fakeProm{}
+	server := httptest.NewServer(prom.handler())
+	t.Cleanup(server.Close)
+
+	cfg["url"] = server.URL
+	p := NewObservabilityFSPlugin()
+	if err := p.Validate(cfg); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if err := p.Initialize(cfg); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	t.Cleanup(func() { p.Shutdown() })
+	return p.GetFileSystem().(*observabilityFS), prom
+}
+
+// readIgnoreEOF reads file content, ignoring io.EOF which is expected at end of file
+func readIgnoreEOF(fs filesystem.FileSystem, path string) ([]byte, error) {
+	content, err := fs.Read(path, 0, -1)
+	if err == io.EOF {
+		return content, nil
+	}
+	return content, err
+}
+
+func TestObservabilityFSInstantQuery(t *testing.T) {
+	fs, prom := newTestFS(t, map[string]interface{}{"bearer_token": "secret"})
+
+	if _, err := fs.Write("/query", []byte("up\n"), -1, filesystem.WriteFlagTruncate); err != nil {
+		t.Fatalf("Write query failed: %v", err)
+	}
+
+	csvData, err := readIgnoreEOF(fs, "/result.csv")
+	if err != nil {
+		t.Fatalf("read result.csv failed: %v", err)
+	}
+	want := "timestamp,__name__,instance,job,value\n" +
+		"2024-05-01T10:00:00Z,up,a:80,api,1\n" +
+		"2024-05-01T10:00:00.5Z,up,,worker,0\n"
+	if string(csvData) != want {
+		t.Fatalf("unexpected CSV:\n%s", csvData)
+	}
+
+	jsonData, _ := readIgnoreEOF(fs, "/result.json")
+	var result struct {
+		Query      string            `json:"query"`
+		ResultType string            `json:"result_type"`
+		Result     []json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(jsonData, &result); err != nil {
+		t.Fatalf("invalid result.json: %v", err)
+	}
+	if result.Query != "up" || result.ResultType != "vector" || len(result.Result) != 2 {
+		t.Fatalf("unexpected result.json: %s", jsonData)
+	}
+
+	if q, _ := readIgnoreEOF(fs, "/query"); string(q) != "up\n" {
+		t.Fatalf("unexpected query content: %q", q)
+	}
+	if prom.auth != "Bearer secret" {
+		t.Fatalf("expected bearer auth, got %q", prom.auth)
+	}
+}
+
+func TestObservabilityFSRangeQuery(t *testing.T) {
+	fs, prom := newTestFS(t, map[string]interface{}{})
+
+	spec := `{"query": "rate(x[5m])", "start": "2024-05-01T10:00:00Z", "end": "2024-05-01T11:00:00Z", "step": "1m"}`
+	if _, err := fs.Write("/query", []byte(spec), -1, filesystem.WriteFlagTruncate); err != nil {
+		t.Fatalf("Write query failed: %v", err)
+	}
+	if got := prom.requests[0]; got != "/api/v1/query_range?end=1714561200&query=rate%28x%5B5m%5D%29&start=1714557600&step=60" {
+		t.Fatalf("unexpected request: %s", got)
+	}
+
+	csvData, _ := readIgnoreEOF(fs, "/result.csv")
+	want := "timestamp,job,value\n2024-05-01T10:00:00Z,api,0.5\n2024-05-01T10:01:00Z,api,0.75\n"
+	if string(csvData) != want {
+		t.Fatalf("unexpected CSV:\n%s", csvData)
+	}
+
+	for _, bad := range []string{`{"query": "up", "range": "-1h"}`, `{"query": "up", "time": "now", "range": "1h"}`, `{"query": ""}`, `{"expr": "up"}`} {
+		if _, err := fs.Write("/query", []byte(bad), -1, filesystem.WriteFlagTruncate); !errors.Is(err, filesystem.ErrInvalidArgument) {
+			t.Errorf("expected invalid argument for %s, got %v", bad, err)
+		}
+	}
+}
+
+func TestObservabilityFSQueryError(t *testing.T) {
+	fs, _ := newTestFS(t, map[string]interface{}{})
+
+	_, err := fs.Write("/query", []byte("bad(up)"), -1, filesystem.WriteFlagTruncate)
+	if err == nil || !strings.Contains(err.Error(), "unknown function") {
+		t.Fatalf("expected query error, got %v", err)
+	}
+	jsonData, _ := readIgnoreEOF(fs, "/result.json")
+	if !strings.Contains(string(jsonData), "unknown function") {
+		t.Fatalf("expected error in result.json, got %s", jsonData)
+	}
+	if csvData, _ := readIgnoreEOF(fs, "/result.csv"); len(csvData) != 0 {
+		t.Fatalf("expected empty CSV after error, got %q", csvData)
+	}
+}
+
+func TestObservabilityFSDashboards(t *testing.T) {
+	fs, prom := newTestFS(t, map[string]interface{}{
+		"dashboards": map[string]interface{}{
+			"api": map[string]interface{}{
+				"up":     `up{job="api"}`,
+				"errors": map[string]interface{}{"query": "rate(errors[5m])", "range": "1h", "step": "5m"},
+			},
+		},
+	})
+
+	files, err := fs.ReadDir("/dashboards")
+	if err != nil || len(files) != 1 || files[0].Name != "api" || !files[0].IsDir {
+		t.Fatalf("unexpected dashboards listing: %+v, %v", files, err)
+	}
+
+	files, err = fs.ReadDir("/dashboards/api")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	if got := strings.Join(names, ","); got != "errors.json,errors.csv,errors.query,up.json,up.csv,up.query" {
+		t.Fatalf("unexpected panel listing: %s", got)
+	}
+
+	csvData, err := readIgnoreEOF(fs, "/dashboards/api/errors.csv")
+	if err != nil || !strings.HasPrefix(string(csvData), "timestamp,job,value\n") {
+		t.Fatalf("unexpected panel CSV: %q, %v", csvData, err)
+	}
+	if req := prom.requests[0]; !strings.HasPrefix(req, "/api/v1/query_range?") || !strings.Contains(req, "step=300") {
+		t.Fatalf("unexpected panel request: %s", req)
+	}
+
+	specData, _ := readIgnoreEOF(fs, "/dashboards/api/up.query")
+	if _, err := fs.Write("/query", specData, -1, filesystem.WriteFlagTruncate); err != nil {
+		t.Fatalf("panel spec should be a valid query: %v", err)
+	}
+
+	if _, err := fs.Write("/dashboards/api/up.csv", []byte("x"), -1, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrPermissionDenied) {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+	if _, err := fs.Stat("/dashboards/api/missing.csv"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestObservabilityFSValidate(t *testing.T) {
+	p := NewObservabilityFSPlugin()
+	tests := []map[string]interface{}{
+		{},
+		{"url": "localhost:9090"},
+		{"url": "http://localhost:9090", "timeout": "soon"},
+		{"url": "http://localhost:9090", "unknown": true},
+		{"url": "http://localhost:9090", "dashboards": map[string]interface{}{"api": "up"}},
+		{"url": "http://localhost:9090", "dashboards": map[string]interface{}{"api": map[string]interface{}{"p": map[string]interface{}{"query": "up", "range": "x"}}}},
+		{"url": "http://localhost:9090", "dashboards": map[string]interface{}{"a/b": map[string]interface{}{"p": "up"}}},
+	}
+	for _, cfg := range tests {
+		if err := p.Validate(cfg); err == nil {
+			t.Errorf("expected error for %v", cfg)
+		}
+	}
+}
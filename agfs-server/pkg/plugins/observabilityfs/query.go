@@ -0,0 +1,234 @@
+package observabilityfs
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxDefaultPoints is the number of samples per series a range query
+// returns when no step is given
+const maxDefaultPoints = 250
+
+// Query is a resolved query: instant at End when Start is zero, otherwise a range query
+type Query struct {
+	Expr  string
+	Start time.Time
+	End   time.Time
+	Step  time.Duration
+}
+
+// querySpec is a query as written to the query file or configured for a dashboard panel
+// Times accept RFC3339, Unix seconds, "now", or offsets from now such as "-1h" and "now-1h".
+type querySpec struct {
+	Query string `json:"query"`
+	Time  string `json:"time,omitempty"`  // Instant query evaluation time
+	Start string `json:"start,omitempty"` // Range query start
+	End   string `json:"end,omitempty"`   // Range query end (default: now)
+	Range string `json:"range,omitempty"` // Shortcut for start = end - range
+	Step  string `json:"step,omitempty"`  // Range query resolution
+}
+
+// parseQuerySpec parses a write to the query file: plain PromQL, or a JSON querySpec
+func parseQuerySpec(data []byte) (*querySpec, error) {
+	text := strings.TrimSpace(string(data))
+	if text == "" {
+		return nil, fmt.Errorf("empty query")
+	}
+	if !strings.HasPrefix(text, "{") {
+		return &querySpec{Query: text}, nil
+	}
+
+	var spec querySpec
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("invalid query JSON: %w", err)
+	}
+	if strings.TrimSpace(spec.Query) == "" {
+		return nil, fmt.Errorf("missing \"query\"")
+	}
+	return &spec, nil
+}
+
+// resolve turns the spec into absolute times relative to now
+func (s *querySpec) resolve(now time.Time) (*Query, error) {
+	q := &Query{Expr: strings.TrimSpace(s.Query), End: now}
+
+	if s.Start == "" && s.Range == "" {
+		if s.End != "" || s.Step != "" {
+			return nil, fmt.Errorf("end and step require start or range")
+		}
+		if s.Time != "" {
+			t, err := parseTime(s.Time, now)
+			if err != nil {
+				return nil, fmt.Errorf("invalid time: %w", err)
+			}
+			q.End = t
+		}
+		return q, nil
+	}
+
+	if s.Time != "" {
+		return nil, fmt.Errorf("time cannot be combined with start or range")
+	}
+	if s.End != "" {
+		t, err := parseTime(s.End, now)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end: %w", err)
+		}
+		q.End = t
+	}
+	switch {
+	case s.Start != "" && s.Range != "":
+		return nil, fmt.Errorf("start and range are mutually exclusive")
+	case s.Start != "":
+		t, err := parseTime(s.Start, now)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start: %w", err)
+		}
+		q.Start = t
+	default:
+		d, err := time.ParseDuration(s.Range)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid range %q: must be a positive duration such as \"1h\"", s.Range)
+		}
+		q.Start = q.End.Add(-d)
+	}
+	if !q.Start.Before(q.End) {
+		return nil, fmt.Errorf("start must be before end")
+	}
+
+	if s.Step != "" {
+		d, err := time.ParseDuration(s.Step)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid step %q: must be a positive duration such as \"1m\"", s.Step)
+		}
+		q.Step = d
+	} else {
+		q.Step = (q.End.Sub(q.Start) / maxDefaultPoints).Truncate(time.Second)
+		if q.Step < time.Second {
+			q.Step = time.Second
+		}
+	}
+	return q, nil
+}
+
+// parseTime parses an absolute or relative time
+func parseTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "now" {
+		return now, nil
+	}
+	if rel := strings.TrimPrefix(s, "now"); strings.HasPrefix(rel, "-") || strings.HasPrefix(rel, "+") {
+		d, err := time.ParseDuration(rel)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(d), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.UnixMilli(int64(f * 1000)), nil
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time %q", s)
+}
+
+// resultJSON renders a query result as indented JSON
+func resultJSON(q *Query, res *queryResult) ([]byte, error) {
+	out := map[string]interface{}{
+		"query":       q.Expr,
+		"result_type": res.ResultType,
+		"result":      res.Result,
+	}
+	if q.Start.IsZero() {
+		out["time"] = q.End.UTC().Format(time.RFC3339)
+	} else {
+		out["start"] = q.Start.UTC().Format(time.RFC3339)
+		out["end"] = q.End.UTC().Format(time.RFC3339)
+		out["step"] = q.Step.String()
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// resultCSV renders a query result as CSV with one row per sample
+// Columns are timestamp, one column per label (sorted, __name__ first) and value.
+func resultCSV(res *queryResult) ([]byte, error) {
+	var rows []csvRow
+	switch res.ResultType {
+	case "vector", "matrix":
+		var list []series
+		if err := json.Unmarshal(res.Result, &list); err != nil {
+			return nil, fmt.Errorf("invalid %s result: %w", res.ResultType, err)
+		}
+		for _, s := range list {
+			samples := s.Values
+			if res.ResultType == "vector" {
+				samples = [][]interface{}{s.Value}
+			}
+			for _, pair := range samples {
+				if ts, value, ok := parseSample(pair); ok {
+					rows = append(rows, csvRow{labels: s.Metric, ts: ts, value: value})
+				}
+			}
+		}
+	case "scalar", "string":
+		var pair []interface{}
+		if err := json.Unmarshal(res.Result, &pair); err != nil {
+			return nil, fmt.Errorf("invalid %s result: %w", res.ResultType, err)
+		}
+		if ts, value, ok := parseSample(pair); ok {
+			rows = append(rows, csvRow{ts: ts, value: value})
+		}
+	default:
+		return nil, fmt.Errorf("unsupported result type %q", res.ResultType)
+	}
+
+	labelSet := make(map[string]bool)
+	for _, r := range rows {
+		for name := range r.labels {
+			labelSet[name] = true
+		}
+	}
+	labels := make([]string, 0, len(labelSet))
+	for name := range labelSet {
+		labels = append(labels, name)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if (labels[i] == "__name__") != (labels[j] == "__name__") {
+			return labels[i] == "__name__"
+		}
+		return labels[i] < labels[j]
+	})
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(append(append([]string{"timestamp"}, labels...), "value"))
+	for _, r := range rows {
+		record := make([]string, 0, len(labels)+2)
+		record = append(record, r.ts.Format(time.RFC3339Nano))
+		for _, name := range labels {
+			record = append(record, r.labels[name])
+		}
+		w.Write(append(record, r.value))
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+type csvRow struct {
+	labels map[string]string
+	ts     time.Time
+	value  string
+}
@@ -0,0 +1,693 @@
+package promptfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PluginName = "promptfs" // Name of this plugin
+)
+
+// Meta values for PromptFS plugin
+const (
+	MetaValuePrompt   = "prompt"   // Prompt directory
+	MetaValueTemplate = "template" // Latest template
+	MetaValueVersion  = "version"  // Immutable template version
+	MetaValueTag      = "tag"      // Tag pointing at a version
+	MetaValueRender   = "render"   // Render endpoint
+)
+
+// Entries inside each prompt directory
+const (
+	fileTemplate = "template"
+	fileRender   = "render"
+	dirVersions  = "versions"
+	dirTags      = "tags"
+)
+
+// Reserved keys in the JSON written to render
+const (
+	renderKeyVersion = "_version"
+	renderKeyTag     = "_tag"
+)
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
+// PromptFSPlugin stores versioned prompt templates
+// Each prompt is a directory:
+//
+//	/<prompt>/template      - read the latest version; write to add a new version
+//	/<prompt>/versions/vN   - read-only content of version N
+//	/<prompt>/tags/<tag>    - read/write the version a tag points to
+//	/<prompt>/render        - write JSON variables, read the rendered prompt
+type PromptFSPlugin struct {
+	store  *promptStore
+	dbPath string
+
+	rendered map[string]string // Last rendered output per prompt
+	mu       sync.RWMutex      // Protects rendered
+
+	metadata plugin.PluginMetadata
+}
+
+// NewPromptFSPlugin creates a new prompt template plugin
+func NewPromptFSPlugin() *PromptFSPlugin {
+	return &PromptFSPlugin{
+		rendered: make(map[string]string),
+		metadata: plugin.PluginMetadata{
+			Name:        PluginName,
+			Version:     "1.0.0",
+			Description: "Versioned prompt template store with tagging and variable interpolation",
+			Author:      "AGFS Server",
+		},
+	}
+}
+
+func (p *PromptFSPlugin) Name() string {
+	return p.metadata.Name
+}
+
+func (p *PromptFSPlugin) Validate(cfg map[string]interface{}) error {
+	allowedKeys := []string{"mount_path", "db_path"}
+	if err := config.ValidateOnlyKnownKeys(cfg, allowedKeys); err != nil {
+		return err
+	}
+	return config.ValidateStringType(cfg, "db_path")
+}
+
+func (p *PromptFSPlugin) Initialize(cfg map[string]interface{}) error {
+	p.dbPath = config.GetStringConfig(cfg, "db_path", "promptfs.db")
+
+	store, err := openPromptStore(p.dbPath)
+	if err != nil {
+		return err
+	}
+	p.store = store
+
+	log.Infof("[promptfs] Initialized with SQLite database: %s", p.dbPath)
+	return nil
+}
+
+func (p *PromptFSPlugin) GetFileSystem() filesystem.FileSystem {
+	return &promptFS{plugin: p}
+}
+
+func (p *PromptFSPlugin) GetReadme() string {
+	return `PromptFS Plugin - Versioned Prompt Template Store
+
+This plugin manages prompt templates like files. Every write to a
+template creates a new immutable version; tags such as prod or staging
+point at versions, and templates are rendered by writing variables.
+
+STRUCTURE:
+  /promptfs/
+    README                - This documentation
+    <prompt>/             - A prompt (mkdir, or write to its template)
+      template            - Latest version (read); write to add a version
+      versions/
+        v1, v2, ...       - Read-only content of each version
+      tags/
+        <tag>             - Version the tag points to (read/write/rm)
+      render              - Write JSON variables, read the rendered prompt
+
+WORKFLOW:
+  agfs:/> echo 'Summarize for {{.audience}}: {{.text}}' > /promptfs/summarize/template
+  agfs:/> echo 'Summarize in {{.words}} words for {{.audience}}: {{.text}}' > /promptfs/summarize/template
+  agfs:/> ls /promptfs/summarize/versions
+  v1  v2
+  agfs:/> echo 1 > /promptfs/summarize/tags/prod
+  agfs:/> echo v2 > /promptfs/summarize/tags/staging
+  agfs:/> echo '{"audience": "engineers", "text": "...", "_tag": "prod"}' > /promptfs/summarize/render
+  agfs:/> cat /promptfs/summarize/render
+
+  Writing content identical to the latest version does not create a
+  new version. Versions are immutable; remove a tag with rm and a whole
+  prompt with rm -rf.
+
+RENDERING:
+  Templates use Go text/template syntax: {{.name}}, {{if .x}}...{{end}},
+  {{range .items}}...{{end}}. Referencing a missing variable is an error.
+
+  The JSON object written to render supplies the variables. Two keys are
+  reserved and select the version to render (default: latest):
+    _version   - Version number, e.g. 3 or "v3"
+    _tag       - Tag name, e.g. "prod"
+
+  The rendered output is kept per prompt until the next render or restart.
+
+CONFIGURATION:
+  [plugins.promptfs]
+  enabled = true
+  path = "/promptfs"
+
+    [plugins.promptfs.config]
+    db_path = "promptfs.db"
+`
+}
+
+func (p *PromptFSPlugin) GetConfigParams() []plugin.ConfigParameter {
+	return []plugin.ConfigParameter{
+		{
+			Name:        "db_path",
+			Type:        "string",
+			Required:    false,
+			Default:     "promptfs.db",
+			Description: "SQLite database file storing prompts, versions and tags",
+		},
+	}
+}
+
+func (p *PromptFSPlugin) Shutdown() error {
+	if p.store != nil {
+		return p.store.Close()
+	}
+	return nil
+}
+
+// promptFS implements the FileSystem interface for prompt templates
+type promptFS struct {
+	plugin *PromptFSPlugin
+}
+
+// promptPath is a parsed promptfs path
+type promptPath struct {
+	prompt string // Prompt name, empty for the root
+	file   string // template, render, versions or tags; empty for the prompt directory
+	entry  string // Version (vN) or tag name inside versions/ or tags/
+}
+
+func parsePromptPath(p string) (promptPath, error) {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return promptPath{}, nil
+	}
+
+	parts := strings.Split(p, "/")
+	if len(parts) > 3 {
+		return promptPath{}, filesystem.NewNotFoundError("stat", "/"+p)
+	}
+	if !namePattern.MatchString(parts[0]) {
+		return promptPath{}, filesystem.NewInvalidArgumentError("prompt", parts[0], "must match [A-Za-z0-9._-] and not start with '.'")
+	}
+	pp := promptPath{prompt: parts[0]}
+	if len(parts) == 1 {
+		return pp, nil
+	}
+
+	pp.file = parts[1]
+	switch pp.file {
+	case fileTemplate, fileRender:
+		if len(parts) == 3 {
+			return promptPath{}, filesystem.NewNotFoundError("stat", "/"+p)
+		}
+	case dirVersions, dirTags:
+		if len(parts) == 3 {
+			pp.entry = parts[2]
+		}
+	default:
+		return promptPath{}, filesystem.NewNotFoundError("stat", "/"+p)
+	}
+	return pp, nil
+}
+
+// parseVersion accepts "3" or "v3"
+func parseVersion(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(s), "v"))
+	if err != nil || n <= 0 {
+		return 0, filesystem.NewInvalidArgumentError("version", s, "must be a positive version number such as 3 or v3")
+	}
+	return n, nil
+}
+
+func versionName(v int) string {
+	return "v" + strconv.Itoa(v)
+}
+
+// mapStoreError converts store sentinel errors into filesystem errors
+func mapStoreError(err error, op, path string) error {
+	if errors.Is(err, errPromptNotFound) || errors.Is(err, errVersionNotFound) || errors.Is(err, errTagNotFound) {
+		return filesystem.NewNotFoundError(op, path)
+	}
+	return err
+}
+
+func (pfs *promptFS) getPrompt(name, op, path string) (PromptInfo, error) {
+	info, err := pfs.plugin.store.GetPrompt(name)
+	if err != nil {
+		return PromptInfo{}, mapStoreError(err, op, path)
+	}
+	return info, nil
+}
+
+func (pfs *promptFS) Create(path string) error {
+	pp, err := parsePromptPath(path)
+	if err != nil {
+		return err
+	}
+	switch {
+	case pp.prompt == "" || pp.file == "":
+		return filesystem.NewPermissionDeniedError("create", path, "only prompt files exist in promptfs")
+	case pp.file == dirTags && pp.entry != "":
+		// Tags are created by writing a version to them
+		return nil
+	case pp.file == fileTemplate || pp.file == fileRender:
+		_, err := pfs.plugin.store.CreatePrompt(pp.prompt)
+		return err
+	}
+	return filesystem.NewPermissionDeniedError("create", path, "versions are created by writing to template")
+}
+
+func (pfs *promptFS) Mkdir(path string, perm uint32) error {
+	pp, err := parsePromptPath(path)
+	if err != nil {
+		return err
+	}
+	if pp.prompt == "" || pp.file != "" {
+		return filesystem.NewAlreadyExistsError("directory", path)
+	}
+
+	created, err := pfs.plugin.store.CreatePrompt(pp.prompt)
+	if err != nil {
+		return err
+	}
+	if !created {
+		return filesystem.NewAlreadyExistsError("prompt", path)
+	}
+	return nil
+}
+
+func (pfs *promptFS) Remove(path string) error {
+	pp, err := parsePromptPath(path)
+	if err != nil {
+		return err
+	}
+	if pp.file == dirTags && pp.entry != "" {
+		if err := pfs.plugin.store.DeleteTag(pp.prompt, pp.entry); err != nil {
+			return mapStoreError(err, "remove", path)
+		}
+		return nil
+	}
+	return pfs.RemoveAll(path)
+}
+
+func (pfs *promptFS) RemoveAll(path string) error {
+	pp, err := parsePromptPath(path)
+	if err != nil {
+		return err
+	}
+	if pp.prompt == "" || pp.file != "" {
+		if pp.file == dirTags && pp.entry != "" {
+			return pfs.Remove(path)
+		}
+		return filesystem.NewPermissionDeniedError("remove", path, "only prompts and tags can be removed")
+	}
+
+	if err := pfs.plugin.store.DeletePrompt(pp.prompt); err != nil {
+		return mapStoreError(err, "remove", path)
+	}
+	pfs.plugin.mu.Lock()
+	delete(pfs.plugin.rendered, pp.prompt)
+	pfs.plugin.mu.Unlock()
+	return nil
+}
+
+func (pfs *promptFS) Read(path string, offset int64, size int64) ([]byte, error) {
+	data, err := pfs.readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return plugin.ApplyRangeRead(data, offset, size)
+}
+
+func (pfs *promptFS) readFile(path string) ([]byte, error) {
+	if strings.Trim(path, "/") == "README" {
+		return []byte(pfs.plugin.GetReadme()), nil
+	}
+
+	pp, err := parsePromptPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if pp.prompt == "" || pp.file == "" || (pp.file == dirVersions || pp.file == dirTags) && pp.entry == "" {
+		return nil, fmt.Errorf("is a directory: %s", path)
+	}
+	if _, err := pfs.getPrompt(pp.prompt, "read", path); err != nil {
+		return nil, err
+	}
+
+	store := pfs.plugin.store
+	switch pp.file {
+	case fileTemplate:
+		v, err := store.GetVersion(pp.prompt, 0)
+		if errors.Is(err, errVersionNotFound) {
+			return []byte{}, nil
+		} else if err != nil {
+			return nil, err
+		}
+		return []byte(v.Content), nil
+	case fileRender:
+		pfs.plugin.mu.RLock()
+		defer pfs.plugin.mu.RUnlock()
+		return []byte(pfs.plugin.rendered[pp.prompt]), nil
+	case dirVersions:
+		n, err := parseVersion(pp.entry)
+		if err != nil || pp.entry != versionName(n) {
+			return nil, filesystem.NewNotFoundError("read", path)
+		}
+		v, err := store.GetVersion(pp.prompt, n)
+		if err != nil {
+			return nil, mapStoreError(err, "read", path)
+		}
+		return []byte(v.Content), nil
+	default: // dirTags
+		version, _, err := store.GetTag(pp.prompt, pp.entry)
+		if err != nil {
+			return nil, mapStoreError(err, "read", path)
+		}
+		return []byte(versionName(version) + "\n"), nil
+	}
+}
+
+func (pfs *promptFS) Write(path string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	pp, err := parsePromptPath(path)
+	if err != nil {
+		return 0, err
+	}
+	if pp.prompt == "" || pp.file == "" || (pp.file == dirVersions || pp.file == dirTags) && pp.entry == "" {
+		return 0, fmt.Errorf("is a directory: %s", path)
+	}
+
+	switch pp.file {
+	case fileTemplate:
+		content := string(data)
+		if strings.TrimSpace(content) == "" {
+			return 0, filesystem.NewInvalidArgumentError("template", "", "template must not be empty")
+		}
+		if _, err := newTemplate(pp.prompt, content); err != nil {
+			return 0, filesystem.NewInvalidArgumentError("template", pp.prompt, err.Error())
+		}
+		version, added, err := pfs.plugin.store.AddVersion(pp.prompt, content)
+		if err != nil {
+			return 0, err
+		}
+		if added {
+			log.Debugf("[promptfs] %s: stored version %d", pp.prompt, version)
+		}
+	case fileRender:
+		if _, err := pfs.getPrompt(pp.prompt, "write", path); err != nil {
+			return 0, err
+		}
+		output, err := pfs.render(pp.prompt, data)
+		if err != nil {
+			return 0, err
+		}
+		pfs.plugin.mu.Lock()
+		pfs.plugin.rendered[pp.prompt] = output
+		pfs.plugin.mu.Unlock()
+	case dirTags:
+		if !namePattern.MatchString(pp.entry) {
+			return 0, filesystem.NewInvalidArgumentError("tag", pp.entry, "must match [A-Za-z0-9._-] and not start with '.'")
+		}
+		if _, err := pfs.getPrompt(pp.prompt, "write", path); err != nil {
+			return 0, err
+		}
+		version, err := parseVersion(string(data))
+		if err != nil {
+			return 0, err
+		}
+		if err := pfs.plugin.store.SetTag(pp.prompt, pp.entry, version); err != nil {
+			if errors.Is(err, errVersionNotFound) {
+				return 0, filesystem.NewInvalidArgumentError("version", versionName(version), "no such version of "+pp.prompt)
+			}
+			return 0, err
+		}
+	default:
+		return 0, filesystem.NewPermissionDeniedError("write", path, "versions are immutable; write to template instead")
+	}
+	return int64(len(data)), nil
+}
+
+func newTemplate(name, content string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=error").Parse(content)
+}
+
+// render interpolates JSON variables into the selected version of a prompt
+func (pfs *promptFS) render(name string, data []byte) (string, error) {
+	vars := make(map[string]interface{})
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 {
+		if err := json.Unmarshal(trimmed, &vars); err != nil {
+			return "", filesystem.NewInvalidArgumentError("render", name, "variables must be a JSON object: "+err.Error())
+		}
+	}
+
+	version := 0
+	if raw, ok := vars[renderKeyVersion]; ok {
+		n, err := parseVersion(fmt.Sprint(raw))
+		if err != nil {
+			return "", err
+		}
+		version = n
+		delete(vars, renderKeyVersion)
+	}
+	if raw, ok := vars[renderKeyTag]; ok {
+		if version != 0 {
+			return "", filesystem.NewInvalidArgumentError("render", name, "_version and _tag are mutually exclusive")
+		}
+		tag := fmt.Sprint(raw)
+		n, _, err := pfs.plugin.store.GetTag(name, tag)
+		if errors.Is(err, errTagNotFound) {
+			return "", filesystem.NewInvalidArgumentError("_tag", tag, "no such tag on "+name)
+		} else if err != nil {
+			return "", err
+		}
+		version = n
+		delete(vars, renderKeyTag)
+	}
+
+	v, err := pfs.plugin.store.GetVersion(name, version)
+	if errors.Is(err, errVersionNotFound) {
+		return "", filesystem.NewInvalidArgumentError("_version", versionName(version), "no such version of "+name)
+	} else if err != nil {
+		return "", err
+	}
+
+	tmpl, err := newTemplate(name, v.Content)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s %s: %w", name, versionName(v.Version), err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", filesystem.NewInvalidArgumentError("render", name, err.Error())
+	}
+	return buf.String(), nil
+}
+
+func dirInfo(name string, modTime time.Time, metaType string) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    name,
+		Size:    0,
+		Mode:    0755,
+		ModTime: modTime,
+		IsDir:   true,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: metaType},
+	}
+}
+
+func fileInfo(name string, size int64, mode uint32, modTime time.Time, metaType string) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    name,
+		Size:    size,
+		Mode:    mode,
+		ModTime: modTime,
+		IsDir:   false,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: metaType},
+	}
+}
+
+func (pfs *promptFS) promptEntries(info PromptInfo) []filesystem.FileInfo {
+	content, _ := pfs.readFile("/" + info.Name + "/" + fileTemplate)
+	rendered, _ := pfs.readFile("/" + info.Name + "/" + fileRender)
+	return []filesystem.FileInfo{
+		fileInfo(fileTemplate, int64(len(content)), 0644, info.Modified, MetaValueTemplate),
+		fileInfo(fileRender, int64(len(rendered)), 0644, info.Modified, MetaValueRender),
+		dirInfo(dirVersions, info.Modified, MetaValueVersion),
+		dirInfo(dirTags, info.Modified, MetaValueTag),
+	}
+}
+
+func (pfs *promptFS) ReadDir(path string) ([]filesystem.FileInfo, error) {
+	pp, err := parsePromptPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	store := pfs.plugin.store
+	if pp.prompt == "" {
+		prompts, err := store.ListPrompts()
+		if err != nil {
+			return nil, err
+		}
+		readme := pfs.plugin.GetReadme()
+		files := []filesystem.FileInfo{fileInfo("README", int64(len(readme)), 0444, time.Now(), "doc")}
+		for _, info := range prompts {
+			files = append(files, dirInfo(info.Name, info.Modified, MetaValuePrompt))
+		}
+		return files, nil
+	}
+
+	info, err := pfs.getPrompt(pp.prompt, "readdir", path)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case pp.file == "":
+		return pfs.promptEntries(info), nil
+	case pp.file == dirVersions && pp.entry == "":
+		versions, err := store.ListVersions(pp.prompt)
+		if err != nil {
+			return nil, err
+		}
+		files := make([]filesystem.FileInfo, 0, len(versions))
+		for _, v := range versions {
+			files = append(files, fileInfo(versionName(v.Version), int64(len(v.Content)), 0444, v.Created, MetaValueVersion))
+		}
+		return files, nil
+	case pp.file == dirTags && pp.entry == "":
+		tags, err := store.ListTags(pp.prompt)
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(tags))
+		for tag := range tags {
+			names = append(names, tag)
+		}
+		sort.Strings(names)
+		files := make([]filesystem.FileInfo, 0, len(names))
+		for _, tag := range names {
+			_, modified, _ := store.GetTag(pp.prompt, tag)
+			size := int64(len(versionName(tags[tag])) + 1)
+			files = append(files, fileInfo(tag, size, 0644, modified, MetaValueTag))
+		}
+		return files, nil
+	}
+	return nil, filesystem.NewNotDirectoryError(path)
+}
+
+func (pfs *promptFS) Stat(path string) (*filesystem.FileInfo, error) {
+	switch strings.Trim(path, "/") {
+	case "":
+		info := dirInfo("/", time.Now(), MetaValuePrompt)
+		info.Meta.Content = map[string]string{"db_path": pfs.plugin.dbPath}
+		return &info, nil
+	case "README":
+		info := fileInfo("README", int64(len(pfs.plugin.GetReadme())), 0444, time.Now(), "doc")
+		return &info, nil
+	}
+
+	pp, err := parsePromptPath(path)
+	if err != nil {
+		return nil, err
+	}
+	prompt, err := pfs.getPrompt(pp.prompt, "stat", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if pp.file == "" {
+		info := dirInfo(prompt.Name, prompt.Modified, MetaValuePrompt)
+		info.Meta.Content = map[string]string{"latest_version": strconv.Itoa(prompt.Latest)}
+		return &info, nil
+	}
+	if pp.entry == "" {
+		for _, info := range pfs.promptEntries(prompt) {
+			if info.Name == pp.file {
+				if pp.file == fileTemplate {
+					info.Meta.Content = map[string]string{"version": strconv.Itoa(prompt.Latest)}
+				}
+				return &info, nil
+			}
+		}
+	}
+
+	// Entries inside versions/ or tags/
+	if pp.file == dirVersions {
+		n, err := parseVersion(pp.entry)
+		if err != nil || pp.entry != versionName(n) {
+			return nil, filesystem.NewNotFoundError("stat", path)
+		}
+		v, err := pfs.plugin.store.GetVersion(pp.prompt, n)
+		if err != nil {
+			return nil, mapStoreError(err, "stat", path)
+		}
+		info := fileInfo(pp.entry, int64(len(v.Content)), 0444, v.Created, MetaValueVersion)
+		return &info, nil
+	}
+	version, modified, err := pfs.plugin.store.GetTag(pp.prompt, pp.entry)
+	if err != nil {
+		return nil, mapStoreError(err, "stat", path)
+	}
+	info := fileInfo(pp.entry, int64(len(versionName(version))+1), 0644, modified, MetaValueTag)
+	info.Meta.Content = map[string]string{"version": versionName(version)}
+	return &info, nil
+}
+
+func (pfs *promptFS) Rename(oldPath, newPath string) error {
+	return filesystem.NewNotSupportedError("rename", oldPath)
+}
+
+func (pfs *promptFS) Chmod(path string, mode uint32) error {
+	return filesystem.NewNotSupportedError("chmod", path)
+}
+
+// Truncate is a no-op so shell redirections like `echo ... > template` work
+func (pfs *promptFS) Truncate(path string, size int64) error {
+	return nil
+}
+
+func (pfs *promptFS) Open(path string) (io.ReadCloser, error) {
+	data, err := pfs.readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (pfs *promptFS) OpenWrite(path string) (io.WriteCloser, error) {
+	return &promptWriter{pfs: pfs, path: path, buf: &bytes.Buffer{}}, nil
+}
+
+// promptWriter buffers a streamed write so a template becomes a single version
+type promptWriter struct {
+	pfs  *promptFS
+	path string
+	buf  *bytes.Buffer
+}
+
+func (pw *promptWriter) Write(p []byte) (n int, err error) {
+	return pw.buf.Write(p)
+}
+
+func (pw *promptWriter) Close() error {
+	_, err := pw.pfs.Write(pw.path, pw.buf.Bytes(), 0, filesystem.WriteFlagNone)
+	return err
+}
+
+// Ensure PromptFSPlugin implements ServicePlugin
+var _ plugin.ServicePlugin = (*PromptFSPlugin)(nil)
+var _ filesystem.FileSystem = (*promptFS)(nil)
@@ -0,0 +1,149 @@
+package promptfs
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+func newTestFS(t *testing.T, dbPath string) *promptFS {
+	t.Helper()
+	p := NewPromptFSPlugin()
+	cfg := map[string]interface{}{"db_path": dbPath}
+	if err := p.Validate(cfg); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if err := p.Initialize(cfg); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	t.Cleanup(func() { p.Shutdown() })
+	return p.GetFileSystem().(*promptFS)
+}
+
+// readIgnoreEOF reads file content, ignoring io.EOF which is expected at end of file
+func readIgnoreEOF(fs *promptFS, path string) ([]byte, error) {
+	content, err := fs.Read(path, 0, -1)
+	if err == io.EOF {
+		return content, nil
+	}
+	return content, err
+}
+
+func writeFile(t *testing.T, fs *promptFS, path, data string) {
+	t.Helper()
+	if _, err := fs.Write(path, []byte(data), 0, filesystem.WriteFlagTruncate); err != nil {
+		t.Fatalf("Write %s failed: %v", path, err)
+	}
+}
+
+func TestPromptFSVersions(t *testing.T) {
+	fs := newTestFS(t, filepath.Join(t.TempDir(), "prompts.db"))
+
+	writeFile(t, fs, "/greet/template", "Hello {{.name}}")
+	writeFile(t, fs, "/greet/template", "Hello {{.name}}") // identical: no new version
+	writeFile(t, fs, "/greet/template", "Hi {{.name}}!")
+
+	infos, err := fs.ReadDir("/greet/versions")
+	if err != nil || len(infos) != 2 || infos[0].Name != "v1" || infos[1].Name != "v2" {
+		t.Fatalf("ReadDir versions = %+v, %v", infos, err)
+	}
+	data, _ := readIgnoreEOF(fs, "/greet/template")
+	if string(data) != "Hi {{.name}}!" {
+		t.Errorf("template = %q", data)
+	}
+	data, _ = readIgnoreEOF(fs, "/greet/versions/v1")
+	if string(data) != "Hello {{.name}}" {
+		t.Errorf("v1 = %q", data)
+	}
+
+	if _, err := fs.Write("/greet/versions/v1", []byte("x"), 0, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrPermissionDenied) {
+		t.Errorf("writing a version: expected ErrPermissionDenied, got %v", err)
+	}
+	if _, err := fs.Write("/greet/template", []byte("{{.broken"), 0, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrInvalidArgument) {
+		t.Errorf("invalid template: expected ErrInvalidArgument, got %v", err)
+	}
+	if _, err := fs.Stat("/greet/versions/v3"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("missing version: expected ErrNotFound, got %v", err)
+	}
+
+	info, err := fs.Stat("/greet")
+	if err != nil || info.Meta.Content["latest_version"] != "2" {
+		t.Errorf("Stat /greet = %+v, %v", info, err)
+	}
+}
+
+func TestPromptFSTagsAndRender(t *testing.T) {
+	fs := newTestFS(t, filepath.Join(t.TempDir(), "prompts.db"))
+
+	writeFile(t, fs, "/sum/template", "Summarize for {{.audience}}")
+	writeFile(t, fs, "/sum/template", "Summarize in {{.words}} words for {{.audience}}")
+	writeFile(t, fs, "/sum/tags/prod", "1\n")
+	writeFile(t, fs, "/sum/tags/staging", "v2")
+
+	data, _ := readIgnoreEOF(fs, "/sum/tags/prod")
+	if string(data) != "v1\n" {
+		t.Errorf("prod tag = %q", data)
+	}
+	if _, err := fs.Write("/sum/tags/prod", []byte("9"), 0, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrInvalidArgument) {
+		t.Errorf("tag to missing version: expected ErrInvalidArgument, got %v", err)
+	}
+
+	writeFile(t, fs, "/sum/render", `{"audience": "engineers", "_tag": "prod"}`)
+	data, _ = readIgnoreEOF(fs, "/sum/render")
+	if string(data) != "Summarize for engineers" {
+		t.Errorf("render prod = %q", data)
+	}
+
+	writeFile(t, fs, "/sum/render", `{"audience": "execs", "words": 50}`)
+	data, _ = readIgnoreEOF(fs, "/sum/render")
+	if string(data) != "Summarize in 50 words for execs" {
+		t.Errorf("render latest = %q", data)
+	}
+
+	if _, err := fs.Write("/sum/render", []byte(`{"audience": "x"}`), 0, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrInvalidArgument) {
+		t.Errorf("missing variable: expected ErrInvalidArgument, got %v", err)
+	}
+	if _, err := fs.Write("/sum/render", []byte(`not json`), 0, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrInvalidArgument) {
+		t.Errorf("invalid JSON: expected ErrInvalidArgument, got %v", err)
+	}
+
+	if err := fs.Remove("/sum/tags/staging"); err != nil {
+		t.Fatalf("Remove tag failed: %v", err)
+	}
+	infos, err := fs.ReadDir("/sum/tags")
+	if err != nil || len(infos) != 1 || infos[0].Name != "prod" {
+		t.Errorf("ReadDir tags = %+v, %v", infos, err)
+	}
+}
+
+func TestPromptFSPersistence(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "prompts.db")
+
+	p := NewPromptFSPlugin()
+	if err := p.Initialize(map[string]interface{}{"db_path": dbPath}); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	fs := p.GetFileSystem().(*promptFS)
+	writeFile(t, fs, "/a/template", "one")
+	writeFile(t, fs, "/a/tags/prod", "1")
+	p.Shutdown()
+
+	fs = newTestFS(t, dbPath)
+	data, _ := readIgnoreEOF(fs, "/a/tags/prod")
+	if string(data) != "v1\n" {
+		t.Errorf("tag after reopen = %q", data)
+	}
+
+	if err := fs.Mkdir("/a", 0755); !errors.Is(err, filesystem.ErrAlreadyExists) {
+		t.Errorf("Mkdir existing: expected ErrAlreadyExists, got %v", err)
+	}
+	if err := fs.RemoveAll("/a"); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	if _, err := fs.Stat("/a"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("removed prompt still exists: %v", err)
+	}
+}
@@ -0,0 +1,301 @@
+package promptfs
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3" // SQLite driver
+)
+
+var (
+	errPromptNotFound  = errors.New("prompt not found")
+	errVersionNotFound = errors.New("version not found")
+	errTagNotFound     = errors.New("tag not found")
+)
+
+// PromptVersion is one immutable revision of a prompt template
+type PromptVersion struct {
+	Version int
+	Content string
+	Created time.Time
+}
+
+// PromptInfo summarizes a prompt
+type PromptInfo struct {
+	Name     string
+	Latest   int // Latest version number, 0 if no version yet
+	Created  time.Time
+	Modified time.Time
+}
+
+// promptStore persists prompts, versions and tags in SQLite
+type promptStore struct {
+	db *sql.DB
+}
+
+const promptSchema = `
+CREATE TABLE IF NOT EXISTS prompts (
+	name TEXT PRIMARY KEY,
+	created INTEGER NOT NULL,
+	modified INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS prompt_versions (
+	name TEXT NOT NULL,
+	version INTEGER NOT NULL,
+	content TEXT NOT NULL,
+	created INTEGER NOT NULL,
+	PRIMARY KEY (name, version)
+);
+CREATE TABLE IF NOT EXISTS prompt_tags (
+	name TEXT NOT NULL,
+	tag TEXT NOT NULL,
+	version INTEGER NOT NULL,
+	modified INTEGER NOT NULL,
+	PRIMARY KEY (name, tag)
+);
+`
+
+func openPromptStore(dbPath string) (*promptStore, error) {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+	// A single connection keeps writes serialized and makes ":memory:" usable
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec("PRAGMA journal_mode=WAL"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to enable WAL mode: %w", err)
+	}
+	if _, err := db.Exec(promptSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize schema: %w", err)
+	}
+	return &promptStore{db: db}, nil
+}
+
+func (s *promptStore) Close() error {
+	return s.db.Close()
+}
+
+// CreatePrompt registers an empty prompt; returns false if it already exists
+func (s *promptStore) CreatePrompt(name string) (bool, error) {
+	now := time.Now().UnixMilli()
+	res, err := s.db.Exec("INSERT OR IGNORE INTO prompts (name, created, modified) VALUES (?, ?, ?)", name, now, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to create prompt: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// DeletePrompt removes a prompt with all its versions and tags
+func (s *promptStore) DeletePrompt(name string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("DELETE FROM prompts WHERE name = ?", name)
+	if err != nil {
+		return fmt.Errorf("failed to delete prompt: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errPromptNotFound
+	}
+	for _, table := range []string{"prompt_versions", "prompt_tags"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE name = ?", name); err != nil {
+			return fmt.Errorf("failed to delete prompt: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+const promptInfoQuery = `SELECT p.name, p.created, p.modified,
+	COALESCE((SELECT MAX(version) FROM prompt_versions v WHERE v.name = p.name), 0)
+	FROM prompts p`
+
+func scanPromptInfo(row interface{ Scan(...interface{}) error }) (PromptInfo, error) {
+	var info PromptInfo
+	var created, modified int64
+	if err := row.Scan(&info.Name, &created, &modified, &info.Latest); err != nil {
+		return PromptInfo{}, err
+	}
+	info.Created = time.UnixMilli(created)
+	info.Modified = time.UnixMilli(modified)
+	return info, nil
+}
+
+// GetPrompt returns a prompt summary
+func (s *promptStore) GetPrompt(name string) (PromptInfo, error) {
+	info, err := scanPromptInfo(s.db.QueryRow(promptInfoQuery+" WHERE p.name = ?", name))
+	if err == sql.ErrNoRows {
+		return PromptInfo{}, errPromptNotFound
+	} else if err != nil {
+		return PromptInfo{}, fmt.Errorf("failed to get prompt: %w", err)
+	}
+	return info, nil
+}
+
+// ListPrompts returns all prompts sorted by name
+func (s *promptStore) ListPrompts() ([]PromptInfo, error) {
+	rows, err := s.db.Query(promptInfoQuery + " ORDER BY p.name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prompts: %w", err)
+	}
+	defer rows.Close()
+
+	var prompts []PromptInfo
+	for rows.Next() {
+		info, err := scanPromptInfo(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan prompt: %w", err)
+		}
+		prompts = append(prompts, info)
+	}
+	return prompts, rows.Err()
+}
+
+// AddVersion stores content as a new version unless it equals the latest one
+// Returns the resulting latest version number. The prompt is created if needed.
+func (s *promptStore) AddVersion(name, content string) (int, bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UnixMilli()
+	if _, err := tx.Exec("INSERT OR IGNORE INTO prompts (name, created, modified) VALUES (?, ?, ?)", name, now, now); err != nil {
+		return 0, false, fmt.Errorf("failed to create prompt: %w", err)
+	}
+
+	var latest int
+	var latestContent sql.NullString
+	err = tx.QueryRow(`SELECT version, content FROM prompt_versions WHERE name = ?
+		ORDER BY version DESC LIMIT 1`, name).Scan(&latest, &latestContent)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, false, fmt.Errorf("failed to read latest version: %w", err)
+	}
+	if latest > 0 && latestContent.String == content {
+		return latest, false, nil
+	}
+
+	version := latest + 1
+	if _, err := tx.Exec("INSERT INTO prompt_versions (name, version, content, created) VALUES (?, ?, ?, ?)",
+		name, version, content, now); err != nil {
+		return 0, false, fmt.Errorf("failed to add version: %w", err)
+	}
+	if _, err := tx.Exec("UPDATE prompts SET modified = ? WHERE name = ?", now, name); err != nil {
+		return 0, false, fmt.Errorf("failed to update prompt: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, false, err
+	}
+	return version, true, nil
+}
+
+// GetVersion returns a specific version; version 0 selects the latest
+func (s *promptStore) GetVersion(name string, version int) (PromptVersion, error) {
+	query := "SELECT version, content, created FROM prompt_versions WHERE name = ? AND version = ?"
+	args := []interface{}{name, version}
+	if version == 0 {
+		query = "SELECT version, content, created FROM prompt_versions WHERE name = ? ORDER BY version DESC LIMIT 1"
+		args = args[:1]
+	}
+
+	var v PromptVersion
+	var created int64
+	err := s.db.QueryRow(query, args...).Scan(&v.Version, &v.Content, &created)
+	if err == sql.ErrNoRows {
+		return PromptVersion{}, errVersionNotFound
+	} else if err != nil {
+		return PromptVersion{}, fmt.Errorf("failed to get version: %w", err)
+	}
+	v.Created = time.UnixMilli(created)
+	return v, nil
+}
+
+// ListVersions returns all versions of a prompt, oldest first
+func (s *promptStore) ListVersions(name string) ([]PromptVersion, error) {
+	rows, err := s.db.Query("SELECT version, content, created FROM prompt_versions WHERE name = ? ORDER BY version", name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list versions: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []PromptVersion
+	for rows.Next() {
+		var v PromptVersion
+		var created int64
+		if err := rows.Scan(&v.Version, &v.Content, &created); err != nil {
+			return nil, fmt.Errorf("failed to scan version: %w", err)
+		}
+		v.Created = time.UnixMilli(created)
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// SetTag points a tag at an existing version
+func (s *promptStore) SetTag(name, tag string, version int) error {
+	if _, err := s.GetVersion(name, version); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`INSERT INTO prompt_tags (name, tag, version, modified) VALUES (?, ?, ?, ?)
+		ON CONFLICT(name, tag) DO UPDATE SET version = excluded.version, modified = excluded.modified`,
+		name, tag, version, time.Now().UnixMilli())
+	if err != nil {
+		return fmt.Errorf("failed to set tag: %w", err)
+	}
+	return nil
+}
+
+// GetTag returns the version a tag points to
+func (s *promptStore) GetTag(name, tag string) (int, time.Time, error) {
+	var version int
+	var modified int64
+	err := s.db.QueryRow("SELECT version, modified FROM prompt_tags WHERE name = ? AND tag = ?", name, tag).
+		Scan(&version, &modified)
+	if err == sql.ErrNoRows {
+		return 0, time.Time{}, errTagNotFound
+	} else if err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to get tag: %w", err)
+	}
+	return version, time.UnixMilli(modified), nil
+}
+
+// ListTags returns tag -> version for a prompt
+func (s *promptStore) ListTags(name string) (map[string]int, error) {
+	rows, err := s.db.Query("SELECT tag, version FROM prompt_tags WHERE name = ?", name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := make(map[string]int)
+	for rows.Next() {
+		var tag string
+		var version int
+		if err := rows.Scan(&tag, &version); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags[tag] = version
+	}
+	return tags, rows.Err()
+}
+
+// DeleteTag removes a tag
+func (s *promptStore) DeleteTag(name, tag string) error {
+	res, err := s.db.Exec("DELETE FROM prompt_tags WHERE name = ? AND tag = ?", name, tag)
+	if err != nil {
+		return fmt.Errorf("failed to delete tag: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errTagNotFound
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -50,21 +51,125 @@ type QueueBackend interface {
 
 	// QueueExists checks if a queue exists (even if empty)
 	QueueExists(queueName string) (bool, error)
+
+	// CreateGroup creates a consumer group of a queue
+	// The group receives every message enqueued from then on.
+	CreateGroup(queueName, group string) error
+
+	// RemoveGroup removes a consumer group and its pending messages
+	RemoveGroup(queueName, group string) error
+
+	// ListGroups returns the consumer groups of a queue
+	ListGroups(queueName string) ([]string, error)
+
+	// Receive leases the next visible message of a consumer group
+	// The message is hidden from the group until the lease expires, after
+	// which it is delivered again unless it was acknowledged.
+	Receive(queueName, group string, visibility time.Duration) (Delivery, bool, error)
+
+	// Ack removes a delivered message from a consumer group
+	Ack(queueName, group, messageID string) (bool, error)
+
+	// Nack ends the lease of a delivered message so that it is delivered again
+	Nack(queueName, group, messageID string) (bool, error)
+
+	// GroupStats returns the number of visible and leased messages of a group
+	GroupStats(queueName, group string) (ready int, inflight int, err error)
+
+	// ListMessages returns the messages of a queue, or of one of its consumer
+	// groups, in delivery order without removing them
+	ListMessages(queueName, group string) ([]QueueMessage, error)
+
+	// RestoreMessages appends messages to a queue, or directly to one of its
+	// consumer groups, keeping their IDs and timestamps (for migrations)
+	RestoreMessages(queueName, group string, msgs []QueueMessage) error
+
+	// CreateSubscriber creates a subscriber of a queue's topic, reading from
+	// the next message enqueued. While a queue has subscribers, its messages
+	// are kept in a stream within the retention limits, and every subscriber
+	// reads them at its own cursor.
+	CreateSubscriber(queueName, subscriber string) error
+
+	// RemoveSubscriber removes a subscriber; the stream goes with the last one
+	RemoveSubscriber(queueName, subscriber string) error
+
+	// ListSubscribers returns the subscribers of a queue
+	ListSubscribers(queueName string) ([]string, error)
+
+	// ReadStream returns the message at a subscriber's cursor, moving the
+	// cursor past it if advance is set
+	ReadStream(queueName, subscriber string, advance bool) (StreamMessage, bool, error)
+
+	// SubscriberLag returns the number of retained messages a subscriber has not read
+	SubscriberLag(queueName, subscriber string) (int, error)
+
+	// Seek moves a subscriber's cursor to an offset of the stream
+	Seek(queueName, subscriber string, offset int64) error
+
+	// StreamBounds returns the offset of the oldest retained message of a
+	// queue's stream and the offset the next message will get
+	StreamBounds(queueName string) (first int64, next int64, err error)
+}
+
+// Delivery is a message leased to a consumer of a group
+type Delivery struct {
+	QueueMessage
+	DeliveryCount int       `json:"delivery_count"`
+	AckDeadline   time.Time `json:"ack_deadline"`
+}
+
+// groupMessage is a message of a consumer group (for memory backend)
+type groupMessage struct {
+	msg        QueueMessage
+	visibleAt  time.Time // Zero until delivered; the lease deadline afterwards
+	deliveries int
+}
+
+// topicStream is the stream of a queue with subscribers (for memory backend)
+type topicStream struct {
+	messages    []QueueMessage   // Retained messages, oldest first
+	next        int64            // Offset of the next message enqueued
+	subscribers map[string]int64 // Subscriber -> offset of the next message it reads
+}
+
+// first returns the offset of the oldest retained message
+func (s *topicStream) first() int64 {
+	return s.next - int64(len(s.messages))
+}
+
+// trim drops the messages beyond the retention limits
+func (s *topicStream) trim(retention topicRetention, now time.Time) {
+	drop := len(s.messages) - retention.maxMessages
+	if drop < 0 {
+		drop = 0
+	}
+	if cutoff := retention.cutoff(now); !cutoff.IsZero() {
+		for drop < len(s.messages) && s.messages[drop].Timestamp.Before(cutoff) {
+			drop++
+		}
+	}
+	s.messages = s.messages[drop:]
 }
 
 // MemoryBackend implements QueueBackend using in-memory storage
 type MemoryBackend struct {
-	queues map[string]*Queue
+	queues    map[string]*Queue
+	retention topicRetention
 }
 
 func NewMemoryBackend() *MemoryBackend {
 	return &MemoryBackend{
-		queues: make(map[string]*Queue),
+		queues:    make(map[string]*Queue),
+		retention: topicRetention{maxMessages: defaultTopicMaxMessages, maxAge: defaultTopicMaxAge},
 	}
 }
 
 func (b *MemoryBackend) Initialize(config map[string]interface{}) error {
-	// No initialization needed for memory backend
+	retention, err := parseTopicRetention(config)
+	if err != nil {
+		return err
+	}
+	b.retention = retention
 	return nil
 }
 
@@ -84,6 +189,7 @@ func (b *MemoryBackend) getOrCreateQueue(queueName string) *Queue {
 	queue := &Queue{
 		messages:        []QueueMessage{},
 		lastEnqueueTime: time.Time{},
+		groups:          make(map[string][]*groupMessage),
 	}
 	b.queues[queueName] = queue
 	return queue
@@ -94,7 +200,18 @@ func (b *MemoryBackend) Enqueue(queueName string, msg QueueMessage) error {
 	queue.mu.Lock()
 	defer queue.mu.Unlock()
 
-	queue.messages = append(queue.messages, msg)
+	// Queues with consumer groups or subscribers deliver to them instead
+	for group, messages := range queue.groups {
+		queue.groups[group] = append(messages, &groupMessage{msg: msg})
+	}
+	if queue.stream != nil {
+		queue.stream.messages = append(queue.stream.messages, msg)
+		queue.stream.next++
+		queue.stream.trim(b.retention, time.Now())
+	}
+	if len(queue.groups) == 0 && queue.stream == nil {
+		queue.messages = append(queue.messages, msg)
+	}
 
 	// Update lastEnqueueTime
 	if msg.Timestamp.After(queue.lastEnqueueTime) {
@@ -163,6 +280,16 @@ func (b *MemoryBackend) Clear(queueName string) error {
 
 	queue.messages = []QueueMessage{}
 	queue.lastEnqueueTime = time.Time{}
+	for group := range queue.groups {
+		queue.groups[group] = nil
+	}
+	// Subscribers are kept too, caught up with the emptied stream
+	if queue.stream != nil {
+		queue.stream.messages = nil
+		for subscriber := range queue.stream.subscribers {
+			queue.stream.subscribers[subscriber] = queue.stream.next
+		}
+	}
 	return nil
 }
 
@@ -218,13 +345,340 @@ func (b *MemoryBackend) QueueExists(queueName string) (bool, error) {
 	return exists, nil
 }
 
-// TiDBBackend implements QueueBackend using TiDB database
+func (b *MemoryBackend) CreateGroup(queueName, group string) error {
+	queue, exists := b.queues[queueName]
+	if !exists {
+		return fmt.Errorf("queue does not exist: %s", queueName)
+	}
+
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	if _, exists := queue.groups[group]; !exists {
+		queue.groups[group] = nil
+	}
+	return nil
+}
+
+func (b *MemoryBackend) RemoveGroup(queueName, group string) error {
+	queue, exists := b.queues[queueName]
+	if !exists {
+		return nil
+	}
+
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	delete(queue.groups, group)
+	return nil
+}
+
+func (b *MemoryBackend) ListGroups(queueName string) ([]string, error) {
+	queue, exists := b.queues[queueName]
+	if !exists {
+		return nil, nil
+	}
+
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	groups := make([]string, 0, len(queue.groups))
+	for group := range queue.groups {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	return groups, nil
+}
+
+// lockGroup locks a queue and returns the messages of one of its groups
+// The caller unlocks queue.mu if the group exists.
+func (b *MemoryBackend) lockGroup(queueName, group string) (*Queue, []*groupMessage, bool) {
+	queue, exists := b.queues[queueName]
+	if !exists {
+		return nil, nil, false
+	}
+	queue.mu.Lock()
+	messages, exists := queue.groups[group]
+	if !exists {
+		queue.mu.Unlock()
+		return nil, nil, false
+	}
+	return queue, messages, true
+}
+
+func (b *MemoryBackend) Receive(queueName, group string, visibility time.Duration) (Delivery, bool, error) {
+	queue, messages, exists := b.lockGroup(queueName, group)
+	if !exists {
+		return Delivery{}, false, fmt.Errorf("consumer group does not exist: %s/%s", queueName, group)
+	}
+	defer queue.mu.Unlock()
+
+	now := time.Now()
+	for _, m := range messages {
+		if m.visibleAt.After(now) {
+			continue
+		}
+		m.visibleAt = now.Add(visibility)
+		m.deliveries++
+		return Delivery{QueueMessage: m.msg, DeliveryCount: m.deliveries, AckDeadline: m.visibleAt}, true, nil
+	}
+	return Delivery{}, false, nil
+}
+
+func (b *MemoryBackend) Ack(queueName, group, messageID string) (bool, error) {
+	queue, messages, exists := b.lockGroup(queueName, group)
+	if !exists {
+		return false, fmt.Errorf("consumer group does not exist: %s/%s", queueName, group)
+	}
+	defer queue.mu.Unlock()
+
+	for i, m := range messages {
+		if m.msg.ID == messageID && m.deliveries > 0 {
+			queue.groups[group] = append(messages[:i:i], messages[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (b *MemoryBackend) Nack(queueName, group, messageID string) (bool, error) {
+	queue, messages, exists := b.lockGroup(queueName, group)
+	if !exists {
+		return false, fmt.Errorf("consumer group does not exist: %s/%s", queueName, group)
+	}
+	defer queue.mu.Unlock()
+
+	now := time.Now()
+	for _, m := range messages {
+		if m.msg.ID == messageID && m.visibleAt.After(now) {
+			m.visibleAt = time.Time{}
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (b *MemoryBackend) GroupStats(queueName, group string) (int, int, error) {
+	queue, messages, exists := b.lockGroup(queueName, group)
+	if !exists {
+		return 0, 0, fmt.Errorf("consumer group does not exist: %s/%s", queueName, group)
+	}
+	defer queue.mu.Unlock()
+
+	now := time.Now()
+	inflight := 0
+	for _, m := range messages {
+		if m.visibleAt.After(now) {
+			inflight++
+		}
+	}
+	return len(messages) - inflight, inflight, nil
+}
+
+func (b *MemoryBackend) ListMessages(queueName, group string) ([]QueueMessage, error) {
+	if group == "" {
+		queue, exists := b.queues[queueName]
+		if !exists {
+			return nil, nil
+		}
+		queue.mu.Lock()
+		defer queue.mu.Unlock()
+		return append([]QueueMessage(nil), queue.messages...), nil
+	}
+
+	queue, messages, exists := b.lockGroup(queueName, group)
+	if !exists {
+		return nil, fmt.Errorf("consumer group does not exist: %s/%s", queueName, group)
+	}
+	defer queue.mu.Unlock()
+
+	msgs := make([]QueueMessage, 0, len(messages))
+	for _, m := range messages {
+		msgs = append(msgs, m.msg)
+	}
+	return msgs, nil
+}
+
+func (b *MemoryBackend) RestoreMessages(queueName, group string, msgs []QueueMessage) error {
+	if group == "" {
+		queue := b.getOrCreateQueue(queueName)
+		queue.mu.Lock()
+		defer queue.mu.Unlock()
+
+		queue.messages = append(queue.messages, msgs...)
+		for _, msg := range msgs {
+			if msg.Timestamp.After(queue.lastEnqueueTime) {
+				queue.lastEnqueueTime = msg.Timestamp
+			}
+		}
+		return nil
+	}
+
+	queue, messages, exists := b.lockGroup(queueName, group)
+	if !exists {
+		return fmt.Errorf("consumer group does not exist: %s/%s", queueName, group)
+	}
+	defer queue.mu.Unlock()
+
+	for _, msg := range msgs {
+		messages = append(messages, &groupMessage{msg: msg})
+	}
+	queue.groups[group] = messages
+	return nil
+}
+
+func (b *MemoryBackend) CreateSubscriber(queueName, subscriber string) error {
+	queue, exists := b.queues[queueName]
+	if !exists {
+		return fmt.Errorf("queue does not exist: %s", queueName)
+	}
+
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	if queue.stream == nil {
+		queue.stream = &topicStream{subscribers: make(map[string]int64)}
+	}
+	if _, exists := queue.stream.subscribers[subscriber]; !exists {
+		queue.stream.subscribers[subscriber] = queue.stream.next
+	}
+	return nil
+}
+
+func (b *MemoryBackend) RemoveSubscriber(queueName, subscriber string) error {
+	queue, exists := b.queues[queueName]
+	if !exists {
+		return nil
+	}
+
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	if queue.stream == nil {
+		return nil
+	}
+	delete(queue.stream.subscribers, subscriber)
+	if len(queue.stream.subscribers) == 0 {
+		queue.stream = nil
+	}
+	return nil
+}
+
+func (b *MemoryBackend) ListSubscribers(queueName string) ([]string, error) {
+	queue, exists := b.queues[queueName]
+	if !exists {
+		return nil, nil
+	}
+
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	if queue.stream == nil {
+		return nil, nil
+	}
+	subscribers := make([]string, 0, len(queue.stream.subscribers))
+	for subscriber := range queue.stream.subscribers {
+		subscribers = append(subscribers, subscriber)
+	}
+	sort.Strings(subscribers)
+	return subscribers, nil
+}
+
+// lockStream locks a queue and returns its stream, with expired messages
+// dropped, and the cursor of one of its subscribers
+// The caller unlocks queue.mu if the subscriber exists.
+func (b *MemoryBackend) lockStream(queueName, subscriber string) (*Queue, *topicStream, int64, bool) {
+	queue, exists := b.queues[queueName]
+	if !exists {
+		return nil, nil, 0, false
+	}
+	queue.mu.Lock()
+	if queue.stream == nil {
+		queue.mu.Unlock()
+		return nil, nil, 0, false
+	}
+	cursor, exists := queue.stream.subscribers[subscriber]
+	if !exists {
+		queue.mu.Unlock()
+		return nil, nil, 0, false
+	}
+	queue.stream.trim(b.retention, time.Now())
+	return queue, queue.stream, cursor, true
+}
+
+func (b *MemoryBackend) ReadStream(queueName, subscriber string, advance bool) (StreamMessage, bool, error) {
+	queue, stream, cursor, exists := b.lockStream(queueName, subscriber)
+	if !exists {
+		return StreamMessage{}, false, fmt.Errorf("subscriber does not exist: %s/%s", queueName, subscriber)
+	}
+	defer queue.mu.Unlock()
+
+	// Messages dropped before the subscriber read them are skipped
+	var missed int64
+	if first := stream.first(); cursor < first {
+		missed = first - cursor
+		cursor = first
+	}
+	if cursor >= stream.next {
+		return StreamMessage{}, false, nil
+	}
+
+	msg := StreamMessage{QueueMessage: stream.messages[cursor-stream.first()], Offset: cursor, Missed: missed}
+	if advance {
+		stream.subscribers[subscriber] = cursor + 1
+	}
+	return msg, true, nil
+}
+
+func (b *MemoryBackend) SubscriberLag(queueName, subscriber string) (int, error) {
+	queue, stream, cursor, exists := b.lockStream(queueName, subscriber)
+	if !exists {
+		return 0, fmt.Errorf("subscriber does not exist: %s/%s", queueName, subscriber)
+	}
+	defer queue.mu.Unlock()
+
+	if first := stream.first(); cursor < first {
+		cursor = first
+	}
+	return int(stream.next - cursor), nil
+}
+
+func (b *MemoryBackend) Seek(queueName, subscriber string, offset int64) error {
+	queue, stream, _, exists := b.lockStream(queueName, subscriber)
+	if !exists {
+		return fmt.Errorf("subscriber does not exist: %s/%s", queueName, subscriber)
+	}
+	defer queue.mu.Unlock()
+
+	stream.subscribers[subscriber] = offset
+	return nil
+}
+
+func (b *MemoryBackend) StreamBounds(queueName string) (int64, int64, error) {
+	queue, exists := b.queues[queueName]
+	if !exists {
+		return 0, 0, nil
+	}
+
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	if queue.stream == nil {
+		return 0, 0, nil
+	}
+	queue.stream.trim(b.retention, time.Now())
+	return queue.stream.first(), queue.stream.next, nil
+}
+
+// TiDBBackend implements QueueBackend using a SQL database (TiDB, MySQL or SQLite)
+// Dialect differences are handled by its DBBackend.
 type TiDBBackend struct {
 	db          *sql.DB
 	backend     DBBackend
 	backendType string
 	tableCache  map[string]string // queueName -> tableName cache
 	cacheMu     sync.RWMutex      // protects tableCache
+	retention   topicRetention    // Limits of the streams of queues with subscribers
 }
 
 func NewTiDBBackend() *TiDBBackend {
@@ -243,6 +697,12 @@ func (b *TiDBBackend) Initialize(config map[string]interface{}) error {
 	}
 	b.backendType = backendType
 
+	retention, err := parseTopicRetention(config)
+	if err != nil {
+		return err
+	}
+	b.retention = retention
+
 	// Create database backend
 	backend, err := CreateBackend(config)
 	if err != nil {
@@ -275,6 +735,14 @@ func (b *TiDBBackend) Close() error {
 	return nil
 }
 
+// Ping checks the database connection
+func (b *TiDBBackend) Ping() error {
+	if b.db == nil {
+		return fmt.Errorf("not connected")
+	}
+	return b.db.Ping()
+}
+
 func (b *TiDBBackend) GetType() string {
 	return b.backendType
 }
@@ -311,6 +779,15 @@ func (b *TiDBBackend) getTableName(queueName string, forceRefresh bool) (string,
 	return tableName, nil
 }
 
+// lockClause returns the clause that makes concurrent consumers skip the rows
+// locked by each other; SQLite needs none as it has a single writer
+func (b *TiDBBackend) lockClause() string {
+	if b.backend.SupportsSkipLocked() {
+		return " FOR UPDATE SKIP LOCKED"
+	}
+	return ""
+}
+
 // invalidateCache removes a queue from the cache
 func (b *TiDBBackend) invalidateCache(queueName string) {
 	b.cacheMu.Lock()
@@ -332,6 +809,16 @@ func (b *TiDBBackend) Enqueue(queueName string, msg QueueMessage) error {
 		return fmt.Errorf("failed to get queue table name: %w", err)
 	}
 
+	// Queues with consumer groups or subscribers deliver to them instead
+	grouped, err := b.enqueueGroups(queueName, msg, msgData)
+	if err != nil {
+		return err
+	}
+	published, err := b.publish(queueName, msg, msgData)
+	if err != nil || grouped || published {
+		return err
+	}
+
 	// Insert message into queue table
 	insertSQL := fmt.Sprintf(
 		"INSERT INTO %s (message_id, data, timestamp, deleted) VALUES (?, ?, ?, 0)",
@@ -367,8 +854,8 @@ func (b *TiDBBackend) Dequeue(queueName string) (QueueMessage, bool, error) {
 	var data string
 
 	querySQL := fmt.Sprintf(
-		"SELECT id, data FROM %s WHERE deleted = 0 ORDER BY id LIMIT 1 FOR UPDATE SKIP LOCKED",
-		tableName,
+		"SELECT id, data FROM %s WHERE deleted = 0 ORDER BY id LIMIT 1%s",
+		tableName, b.lockClause(),
 	)
 	err = tx.QueryRow(querySQL).Scan(&id, &data)
 
@@ -469,6 +956,24 @@ func (b *TiDBBackend) Clear(queueName string) error {
 	if err != nil {
 		return fmt.Errorf("failed to clear queue: %w", err)
 	}
+
+	// Consumer groups are cleared too, but kept
+	if _, err := b.db.Exec("DELETE FROM queuefs_group_messages WHERE queue_name = ?", queueName); err != nil {
+		return fmt.Errorf("failed to clear consumer groups: %w", err)
+	}
+
+	// So are subscribers, caught up with the emptied stream
+	if _, err := b.db.Exec("DELETE FROM queuefs_stream_messages WHERE queue_name = ?", queueName); err != nil {
+		return fmt.Errorf("failed to clear stream: %w", err)
+	}
+	_, err = b.db.Exec(
+		`UPDATE queuefs_subscribers SET next_offset = (SELECT next_offset FROM queuefs_streams WHERE queue_name = ?)
+		WHERE queue_name = ?`,
+		queueName, queueName,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to clear subscribers: %w", err)
+	}
 	return nil
 }
 
@@ -513,7 +1018,7 @@ func (b *TiDBBackend) GetLastEnqueueTime(queueName string) (time.Time, error) {
 
 	var timestamp int64
 	querySQL := fmt.Sprintf(
-		"SELECT MAX(timestamp) FROM %s WHERE deleted = 0",
+		"SELECT COALESCE(MAX(timestamp), 0) FROM %s WHERE deleted = 0",
 		tableName,
 	)
 	err = b.db.QueryRow(querySQL).Scan(&timestamp)
@@ -527,6 +1032,13 @@ func (b *TiDBBackend) GetLastEnqueueTime(queueName string) (time.Time, error) {
 	return time.Unix(timestamp, 0), nil
 }
 
+// queuefsTables are the shared tables holding rows of every queue
+var queuefsTables = []string{
+	"queuefs_group_messages", "queuefs_groups",
+	"queuefs_stream_messages", "queuefs_subscribers", "queuefs_streams",
+	"queuefs_registry",
+}
+
 func (b *TiDBBackend) RemoveQueue(queueName string) error {
 	if queueName == "" {
 		// Remove all queues: drop all queue tables and clear registry
@@ -565,9 +1077,13 @@ func (b *TiDBBackend) RemoveQueue(queueName string) error {
 		b.tableCache = make(map[string]string)
 		b.cacheMu.Unlock()
 
-		// Clear registry
-		_, err = b.db.Exec("DELETE FROM queuefs_registry")
-		return err
+		// Clear consumer groups, streams and registry
+		for _, table := range queuefsTables {
+			if _, err := b.db.Exec("DELETE FROM " + table); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
 
 	// Remove queue and nested queues
@@ -608,12 +1124,17 @@ func (b *TiDBBackend) RemoveQueue(queueName string) error {
 		b.invalidateCache(q.queueName)
 	}
 
-	// Remove from registry
-	_, err = b.db.Exec(
-		"DELETE FROM queuefs_registry WHERE queue_name = ? OR queue_name LIKE ?",
-		queueName, queueName+"/%",
-	)
-	return err
+	// Remove consumer groups, streams and registry entries
+	for _, table := range queuefsTables {
+		_, err = b.db.Exec(
+			"DELETE FROM "+table+" WHERE queue_name = ? OR queue_name LIKE ?",
+			queueName, queueName+"/%",
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (b *TiDBBackend) CreateQueue(queueName string) error {
@@ -621,14 +1142,15 @@ func (b *TiDBBackend) CreateQueue(queueName string) error {
 	tableName := sanitizeTableName(queueName)
 
 	// Create the queue table
-	createTableSQL := getCreateTableSQL(tableName)
-	if _, err := b.db.Exec(createTableSQL); err != nil {
-		return fmt.Errorf("failed to create queue table: %w", err)
+	for _, createTableSQL := range b.backend.GetCreateTableSQL(tableName) {
+		if _, err := b.db.Exec(createTableSQL); err != nil {
+			return fmt.Errorf("failed to create queue table: %w", err)
+		}
 	}
 
 	// Register in queuefs_registry
 	_, err := b.db.Exec(
-		"INSERT IGNORE INTO queuefs_registry (queue_name, table_name) VALUES (?, ?)",
+		b.backend.GetInsertIgnoreSQL()+" INTO queuefs_registry (queue_name, table_name) VALUES (?, ?)",
 		queueName, tableName,
 	)
 	if err != nil {
@@ -665,3 +1187,521 @@ func (b *TiDBBackend) QueueExists(queueName string) (bool, error) {
 	}
 	return count > 0, nil
 }
+
+func (b *TiDBBackend) CreateGroup(queueName, group string) error {
+	exists, err := b.QueueExists(queueName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("queue does not exist: %s", queueName)
+	}
+
+	_, err = b.db.Exec(
+		b.backend.GetInsertIgnoreSQL()+" INTO queuefs_groups (queue_name, group_name) VALUES (?, ?)",
+		queueName, group,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create consumer group: %w", err)
+	}
+	return nil
+}
+
+func (b *TiDBBackend) RemoveGroup(queueName, group string) error {
+	if _, err := b.db.Exec(
+		"DELETE FROM queuefs_group_messages WHERE queue_name = ? AND group_name = ?",
+		queueName, group,
+	); err != nil {
+		return fmt.Errorf("failed to remove consumer group messages: %w", err)
+	}
+	if _, err := b.db.Exec(
+		"DELETE FROM queuefs_groups WHERE queue_name = ? AND group_name = ?",
+		queueName, group,
+	); err != nil {
+		return fmt.Errorf("failed to remove consumer group: %w", err)
+	}
+	return nil
+}
+
+func (b *TiDBBackend) ListGroups(queueName string) ([]string, error) {
+	rows, err := b.db.Query(
+		"SELECT group_name FROM queuefs_groups WHERE queue_name = ? ORDER BY group_name",
+		queueName,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list consumer groups: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []string
+	for rows.Next() {
+		var group string
+		if err := rows.Scan(&group); err != nil {
+			return nil, fmt.Errorf("failed to scan consumer group: %w", err)
+		}
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
+
+// checkGroup returns an error if a consumer group does not exist
+func (b *TiDBBackend) checkGroup(queueName, group string) error {
+	var count int
+	err := b.db.QueryRow(
+		"SELECT COUNT(*) FROM queuefs_groups WHERE queue_name = ? AND group_name = ?",
+		queueName, group,
+	).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to check consumer group: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("consumer group does not exist: %s/%s", queueName, group)
+	}
+	return nil
+}
+
+// enqueueGroups copies a message to every consumer group of a queue
+// It returns false if the queue has no consumer groups.
+func (b *TiDBBackend) enqueueGroups(queueName string, msg QueueMessage, msgData []byte) (bool, error) {
+	result, err := b.db.Exec(
+		`INSERT INTO queuefs_group_messages (queue_name, group_name, message_id, data)
+		SELECT queue_name, group_name, ?, ? FROM queuefs_groups WHERE queue_name = ?`,
+		msg.ID, string(msgData), queueName,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to enqueue message to consumer groups: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (b *TiDBBackend) Receive(queueName, group string, visibility time.Duration) (Delivery, bool, error) {
+	if err := b.checkGroup(queueName, group); err != nil {
+		return Delivery{}, false, err
+	}
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return Delivery{}, false, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Skip rows leased by concurrent consumers, like Dequeue
+	now := time.Now()
+	var id int64
+	var data string
+	var deliveries int
+	err = tx.QueryRow(
+		`SELECT id, data, delivery_count FROM queuefs_group_messages
+		WHERE queue_name = ? AND group_name = ? AND visible_at <= ?
+		ORDER BY id LIMIT 1`+b.lockClause(),
+		queueName, group, now.UnixMilli(),
+	).Scan(&id, &data, &deliveries)
+	if err == sql.ErrNoRows {
+		return Delivery{}, false, nil
+	} else if err != nil {
+		return Delivery{}, false, fmt.Errorf("failed to query message: %w", err)
+	}
+
+	deadline := now.Add(visibility)
+	_, err = tx.Exec(
+		"UPDATE queuefs_group_messages SET visible_at = ?, delivery_count = delivery_count + 1 WHERE id = ?",
+		deadline.UnixMilli(), id,
+	)
+	if err != nil {
+		return Delivery{}, false, fmt.Errorf("failed to lease message: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return Delivery{}, false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	delivery := Delivery{DeliveryCount: deliveries + 1, AckDeadline: deadline}
+	if err := json.Unmarshal([]byte(data), &delivery.QueueMessage); err != nil {
+		return Delivery{}, false, fmt.Errorf("failed to unmarshal message: %w", err)
+	}
+	return delivery, true, nil
+}
+
+func (b *TiDBBackend) Ack(queueName, group, messageID string) (bool, error) {
+	if err := b.checkGroup(queueName, group); err != nil {
+		return false, err
+	}
+
+	result, err := b.db.Exec(
+		"DELETE FROM queuefs_group_messages WHERE queue_name = ? AND group_name = ? AND message_id = ? AND delivery_count > 0",
+		queueName, group, messageID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to acknowledge message: %w", err)
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func (b *TiDBBackend) Nack(queueName, group, messageID string) (bool, error) {
+	if err := b.checkGroup(queueName, group); err != nil {
+		return false, err
+	}
+
+	result, err := b.db.Exec(
+		"UPDATE queuefs_group_messages SET visible_at = 0 WHERE queue_name = ? AND group_name = ? AND message_id = ? AND visible_at > ?",
+		queueName, group, messageID, time.Now().UnixMilli(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to release message: %w", err)
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func (b *TiDBBackend) GroupStats(queueName, group string) (int, int, error) {
+	if err := b.checkGroup(queueName, group); err != nil {
+		return 0, 0, err
+	}
+
+	var total, inflight int
+	err := b.db.QueryRow(
+		`SELECT COUNT(*), COALESCE(SUM(CASE WHEN visible_at > ? THEN 1 ELSE 0 END), 0)
+		FROM queuefs_group_messages WHERE queue_name = ? AND group_name = ?`,
+		time.Now().UnixMilli(), queueName, group,
+	).Scan(&total, &inflight)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get consumer group stats: %w", err)
+	}
+	return total - inflight, inflight, nil
+}
+
+func (b *TiDBBackend) ListMessages(queueName, group string) ([]QueueMessage, error) {
+	var rows *sql.Rows
+	if group == "" {
+		tableName, err := b.getTableName(queueName, false)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to get queue table name: %w", err)
+		}
+		rows, err = b.db.Query(fmt.Sprintf("SELECT data FROM %s WHERE deleted = 0 ORDER BY id", tableName))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list messages: %w", err)
+		}
+	} else {
+		if err := b.checkGroup(queueName, group); err != nil {
+			return nil, err
+		}
+		var err error
+		rows, err = b.db.Query(
+			"SELECT data FROM queuefs_group_messages WHERE queue_name = ? AND group_name = ? ORDER BY id",
+			queueName, group,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list messages: %w", err)
+		}
+	}
+	defer rows.Close()
+
+	var msgs []QueueMessage
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		var msg QueueMessage
+		if err := json.Unmarshal([]byte(data), &msg); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal message: %w", err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, rows.Err()
+}
+
+func (b *TiDBBackend) RestoreMessages(queueName, group string, msgs []QueueMessage) error {
+	var insertSQL string
+	if group == "" {
+		tableName, err := b.getTableName(queueName, false)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("queue does not exist: %s", queueName)
+		} else if err != nil {
+			return fmt.Errorf("failed to get queue table name: %w", err)
+		}
+		insertSQL = fmt.Sprintf("INSERT INTO %s (message_id, data, timestamp, deleted) VALUES (?, ?, ?, 0)", tableName)
+	} else {
+		if err := b.checkGroup(queueName, group); err != nil {
+			return err
+		}
+		insertSQL = "INSERT INTO queuefs_group_messages (queue_name, group_name, message_id, data) VALUES (?, ?, ?, ?)"
+	}
+
+	// All messages or none, so that a failed migration can be run again
+	tx, err := b.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, msg := range msgs {
+		msgData, err := json.Marshal(msg)
+		if err != nil {
+			return fmt.Errorf("failed to marshal message: %w", err)
+		}
+		args := []interface{}{msg.ID, string(msgData), msg.Timestamp.Unix()}
+		if group != "" {
+			args = []interface{}{queueName, group, msg.ID, string(msgData)}
+		}
+		if _, err := tx.Exec(insertSQL, args...); err != nil {
+			return fmt.Errorf("failed to restore message: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (b *TiDBBackend) CreateSubscriber(queueName, subscriber string) error {
+	exists, err := b.QueueExists(queueName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("queue does not exist: %s", queueName)
+	}
+
+	insertIgnore := b.backend.GetInsertIgnoreSQL()
+	if _, err := b.db.Exec(insertIgnore+" INTO queuefs_streams (queue_name, next_offset) VALUES (?, 0)", queueName); err != nil {
+		return fmt.Errorf("failed to create stream: %w", err)
+	}
+	_, err = b.db.Exec(
+		insertIgnore+` INTO queuefs_subscribers (queue_name, subscriber_name, next_offset)
+		SELECT queue_name, ?, next_offset FROM queuefs_streams WHERE queue_name = ?`,
+		subscriber, queueName,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create subscriber: %w", err)
+	}
+	return nil
+}
+
+func (b *TiDBBackend) RemoveSubscriber(queueName, subscriber string) error {
+	if _, err := b.db.Exec(
+		"DELETE FROM queuefs_subscribers WHERE queue_name = ? AND subscriber_name = ?",
+		queueName, subscriber,
+	); err != nil {
+		return fmt.Errorf("failed to remove subscriber: %w", err)
+	}
+
+	// The stream goes with the last subscriber
+	var count int
+	if err := b.db.QueryRow("SELECT COUNT(*) FROM queuefs_subscribers WHERE queue_name = ?", queueName).Scan(&count); err != nil {
+		return fmt.Errorf("failed to count subscribers: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+	for _, table := range []string{"queuefs_stream_messages", "queuefs_streams"} {
+		if _, err := b.db.Exec("DELETE FROM "+table+" WHERE queue_name = ?", queueName); err != nil {
+			return fmt.Errorf("failed to remove stream: %w", err)
+		}
+	}
+	return nil
+}
+
+func (b *TiDBBackend) ListSubscribers(queueName string) ([]string, error) {
+	rows, err := b.db.Query(
+		"SELECT subscriber_name FROM queuefs_subscribers WHERE queue_name = ? ORDER BY subscriber_name",
+		queueName,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscribers: %w", err)
+	}
+	defer rows.Close()
+
+	var subscribers []string
+	for rows.Next() {
+		var subscriber string
+		if err := rows.Scan(&subscriber); err != nil {
+			return nil, fmt.Errorf("failed to scan subscriber: %w", err)
+		}
+		subscribers = append(subscribers, subscriber)
+	}
+	return subscribers, rows.Err()
+}
+
+// cutoffMillis returns the publish time in Unix milliseconds before which
+// stream messages have expired, 0 if they do not expire
+func (b *TiDBBackend) cutoffMillis(now time.Time) int64 {
+	cutoff := b.retention.cutoff(now)
+	if cutoff.IsZero() {
+		return 0
+	}
+	return cutoff.UnixMilli()
+}
+
+// publish appends a message to the stream of a queue, dropping the messages
+// beyond the retention limits
+// It returns false if the queue has no subscribers.
+func (b *TiDBBackend) publish(queueName string, msg QueueMessage, msgData []byte) (bool, error) {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Taking the next offset locks the stream, so offsets have no gaps
+	result, err := tx.Exec("UPDATE queuefs_streams SET next_offset = next_offset + 1 WHERE queue_name = ?", queueName)
+	if err != nil {
+		return false, fmt.Errorf("failed to publish message: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	var offset int64
+	if err := tx.QueryRow("SELECT next_offset - 1 FROM queuefs_streams WHERE queue_name = ?", queueName).Scan(&offset); err != nil {
+		return false, fmt.Errorf("failed to publish message: %w", err)
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO queuefs_stream_messages (queue_name, stream_offset, message_id, data, published_at) VALUES (?, ?, ?, ?, ?)",
+		queueName, offset, msg.ID, string(msgData), msg.Timestamp.UnixMilli(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to publish message: %w", err)
+	}
+	_, err = tx.Exec(
+		"DELETE FROM queuefs_stream_messages WHERE queue_name = ? AND (stream_offset <= ? OR published_at < ?)",
+		queueName, offset-int64(b.retention.maxMessages), b.cutoffMillis(time.Now()),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to apply stream retention: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// subscriberCursor returns the offset of the next message of a subscriber
+func (b *TiDBBackend) subscriberCursor(queueName, subscriber string) (int64, error) {
+	var cursor int64
+	err := b.db.QueryRow(
+		"SELECT next_offset FROM queuefs_subscribers WHERE queue_name = ? AND subscriber_name = ?",
+		queueName, subscriber,
+	).Scan(&cursor)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("subscriber does not exist: %s/%s", queueName, subscriber)
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to get subscriber: %w", err)
+	}
+	return cursor, nil
+}
+
+func (b *TiDBBackend) ReadStream(queueName, subscriber string, advance bool) (StreamMessage, bool, error) {
+	for {
+		cursor, err := b.subscriberCursor(queueName, subscriber)
+		if err != nil {
+			return StreamMessage{}, false, err
+		}
+		first, next, err := b.StreamBounds(queueName)
+		if err != nil {
+			return StreamMessage{}, false, err
+		}
+
+		// Messages dropped before the subscriber read them are skipped
+		offset := cursor
+		var missed int64
+		if offset < first {
+			missed = first - offset
+			offset = first
+		}
+		if offset >= next {
+			return StreamMessage{}, false, nil
+		}
+
+		var data string
+		err = b.db.QueryRow(
+			"SELECT data FROM queuefs_stream_messages WHERE queue_name = ? AND stream_offset = ?",
+			queueName, offset,
+		).Scan(&data)
+		if err == sql.ErrNoRows {
+			// Dropped since the bounds were read
+			continue
+		} else if err != nil {
+			return StreamMessage{}, false, fmt.Errorf("failed to read stream: %w", err)
+		}
+
+		if advance {
+			// Another reader of the subscriber may have moved the cursor
+			result, err := b.db.Exec(
+				"UPDATE queuefs_subscribers SET next_offset = ? WHERE queue_name = ? AND subscriber_name = ? AND next_offset = ?",
+				offset+1, queueName, subscriber, cursor,
+			)
+			if err != nil {
+				return StreamMessage{}, false, fmt.Errorf("failed to move subscriber cursor: %w", err)
+			}
+			if n, err := result.RowsAffected(); err != nil {
+				return StreamMessage{}, false, err
+			} else if n == 0 {
+				continue
+			}
+		}
+
+		msg := StreamMessage{Offset: offset, Missed: missed}
+		if err := json.Unmarshal([]byte(data), &msg.QueueMessage); err != nil {
+			return StreamMessage{}, false, fmt.Errorf("failed to unmarshal message: %w", err)
+		}
+		return msg, true, nil
+	}
+}
+
+func (b *TiDBBackend) SubscriberLag(queueName, subscriber string) (int, error) {
+	cursor, err := b.subscriberCursor(queueName, subscriber)
+	if err != nil {
+		return 0, err
+	}
+	first, next, err := b.StreamBounds(queueName)
+	if err != nil {
+		return 0, err
+	}
+	if cursor < first {
+		cursor = first
+	}
+	return int(next - cursor), nil
+}
+
+func (b *TiDBBackend) Seek(queueName, subscriber string, offset int64) error {
+	if _, err := b.subscriberCursor(queueName, subscriber); err != nil {
+		return err
+	}
+	_, err := b.db.Exec(
+		"UPDATE queuefs_subscribers SET next_offset = ? WHERE queue_name = ? AND subscriber_name = ?",
+		offset, queueName, subscriber,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to move subscriber cursor: %w", err)
+	}
+	return nil
+}
+
+func (b *TiDBBackend) StreamBounds(queueName string) (int64, int64, error) {
+	var next int64
+	err := b.db.QueryRow("SELECT next_offset FROM queuefs_streams WHERE queue_name = ?", queueName).Scan(&next)
+	if err == sql.ErrNoRows {
+		return 0, 0, nil
+	} else if err != nil {
+		return 0, 0, fmt.Errorf("failed to get stream: %w", err)
+	}
+
+	// Expired messages count as dropped even before the next publish deletes them
+	var first sql.NullInt64
+	err = b.db.QueryRow(
+		"SELECT MIN(stream_offset) FROM queuefs_stream_messages WHERE queue_name = ? AND published_at >= ?",
+		queueName, b.cutoffMillis(time.Now()),
+	).Scan(&first)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get stream: %w", err)
+	}
+	if !first.Valid {
+		return next, next, nil
+	}
+	return first.Int64, next, nil
+}
@@ -24,6 +24,15 @@ type DBBackend interface {
 
 	// GetDriverName returns the driver name
 	GetDriverName() string
+
+	// GetCreateTableSQL returns the SQL statements to create a queue table
+	GetCreateTableSQL(tableName string) []string
+
+	// GetInsertIgnoreSQL returns the INSERT variant that skips duplicate keys
+	GetInsertIgnoreSQL() string
+
+	// SupportsSkipLocked returns whether SELECT ... FOR UPDATE SKIP LOCKED is available
+	SupportsSkipLocked() bool
 }
 
 // SQLiteDBBackend implements DBBackend for SQLite
@@ -40,15 +49,27 @@ func (b *SQLiteDBBackend) GetDriverName() string {
 func (b *SQLiteDBBackend) Open(cfg map[string]interface{}) (*sql.DB, error) {
 	dbPath := config.GetStringConfig(cfg, "db_path", "queue.db")
 
-	db, err := sql.Open("sqlite3", dbPath)
+	// Wait for locks held by other processes instead of failing at once
+	dsn := dbPath
+	if !strings.Contains(dsn, "?") {
+		dsn += "?_busy_timeout=5000"
+	}
+	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
 	}
 
-	// Enable WAL mode for better concurrency
-	if _, err := db.Exec("PRAGMA journal_mode=WAL"); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("failed to enable WAL mode: %w", err)
+	// SQLite has a single writer, and a transaction that reads before it writes
+	// can fail when another connection writes first: serialize all access on
+	// one connection instead
+	db.SetMaxOpenConns(1)
+
+	// Enable WAL mode: committed messages are durable and readers do not block the writer
+	for _, pragma := range []string{"PRAGMA journal_mode=WAL", "PRAGMA synchronous=FULL"} {
+		if _, err := db.Exec(pragma); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to configure SQLite database (%s): %w", pragma, err)
+		}
 	}
 
 	return db, nil
@@ -56,26 +77,78 @@ func (b *SQLiteDBBackend) Open(cfg map[string]interface{}) (*sql.DB, error) {
 
 func (b *SQLiteDBBackend) GetInitSQL() []string {
 	return []string{
-		// Queue metadata table to track all queues (including empty ones)
-		`CREATE TABLE IF NOT EXISTS queue_metadata (
+		// Queue registry table to track all queue tables
+		`CREATE TABLE IF NOT EXISTS queuefs_registry (
 			queue_name TEXT PRIMARY KEY,
-			created_at INTEGER DEFAULT (strftime('%s', 'now')),
-			last_updated INTEGER DEFAULT (strftime('%s', 'now'))
+			table_name TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// Consumer groups of queues
+		`CREATE TABLE IF NOT EXISTS queuefs_groups (
+			queue_name TEXT NOT NULL,
+			group_name TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (queue_name, group_name)
 		)`,
-		// Queue messages table
-		`CREATE TABLE IF NOT EXISTS queue_messages (
+		// Messages of consumer groups until they are acknowledged
+		`CREATE TABLE IF NOT EXISTS queuefs_group_messages (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			queue_name TEXT NOT NULL,
+			group_name TEXT NOT NULL,
 			message_id TEXT NOT NULL,
-			data TEXT NOT NULL,
-			timestamp INTEGER NOT NULL,
-			created_at INTEGER DEFAULT (strftime('%s', 'now'))
+			data BLOB NOT NULL,
+			visible_at INTEGER NOT NULL DEFAULT 0,
+			delivery_count INTEGER NOT NULL DEFAULT 0
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_queuefs_group_visible ON queuefs_group_messages(queue_name, group_name, visible_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_queuefs_group_message ON queuefs_group_messages(queue_name, group_name, message_id)`,
+		// Streams of queues with subscribers
+		`CREATE TABLE IF NOT EXISTS queuefs_streams (
+			queue_name TEXT PRIMARY KEY,
+			next_offset INTEGER NOT NULL DEFAULT 0
+		)`,
+		`CREATE TABLE IF NOT EXISTS queuefs_subscribers (
+			queue_name TEXT NOT NULL,
+			subscriber_name TEXT NOT NULL,
+			next_offset INTEGER NOT NULL DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (queue_name, subscriber_name)
+		)`,
+		// Messages retained in streams
+		`CREATE TABLE IF NOT EXISTS queuefs_stream_messages (
+			queue_name TEXT NOT NULL,
+			stream_offset INTEGER NOT NULL,
+			message_id TEXT NOT NULL,
+			data BLOB NOT NULL,
+			published_at INTEGER NOT NULL,
+			PRIMARY KEY (queue_name, stream_offset)
 		)`,
-		`CREATE INDEX IF NOT EXISTS idx_queue_name ON queue_messages(queue_name)`,
-		`CREATE INDEX IF NOT EXISTS idx_queue_order ON queue_messages(queue_name, id)`,
 	}
 }
 
+func (b *SQLiteDBBackend) GetCreateTableSQL(tableName string) []string {
+	return []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			message_id TEXT NOT NULL,
+			data BLOB NOT NULL,
+			timestamp INTEGER NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			deleted INTEGER DEFAULT 0,
+			deleted_at TIMESTAMP NULL
+		)`, tableName),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_deleted_id ON %s(deleted, id)", tableName, tableName),
+	}
+}
+
+func (b *SQLiteDBBackend) GetInsertIgnoreSQL() string {
+	return "INSERT OR IGNORE"
+}
+
+func (b *SQLiteDBBackend) SupportsSkipLocked() bool {
+	return false
+}
+
 // TiDBDBBackend implements DBBackend for TiDB
 type TiDBDBBackend struct{}
 
@@ -207,9 +280,73 @@ func (b *TiDBDBBackend) GetInitSQL() []string {
 			table_name VARCHAR(255) NOT NULL,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+		// Consumer groups of queues
+		`CREATE TABLE IF NOT EXISTS queuefs_groups (
+			queue_name VARCHAR(255) NOT NULL,
+			group_name VARCHAR(255) NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (queue_name, group_name)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+		// Messages of consumer groups until they are acknowledged
+		// visible_at is the lease deadline in Unix milliseconds (0 before the first delivery)
+		`CREATE TABLE IF NOT EXISTS queuefs_group_messages (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			queue_name VARCHAR(255) NOT NULL,
+			group_name VARCHAR(255) NOT NULL,
+			message_id VARCHAR(64) NOT NULL,
+			data LONGBLOB NOT NULL,
+			visible_at BIGINT NOT NULL DEFAULT 0,
+			delivery_count INT NOT NULL DEFAULT 0,
+			INDEX idx_group_visible (queue_name, group_name, visible_at),
+			INDEX idx_group_message (queue_name, group_name, message_id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+		// Streams of queues with subscribers
+		// next_offset is the offset the next message enqueued gets
+		`CREATE TABLE IF NOT EXISTS queuefs_streams (
+			queue_name VARCHAR(255) PRIMARY KEY,
+			next_offset BIGINT NOT NULL DEFAULT 0
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+		// Subscribers and their cursors: the offset of the next message they read
+		`CREATE TABLE IF NOT EXISTS queuefs_subscribers (
+			queue_name VARCHAR(255) NOT NULL,
+			subscriber_name VARCHAR(255) NOT NULL,
+			next_offset BIGINT NOT NULL DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (queue_name, subscriber_name)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+		// Messages retained in streams; published_at is in Unix milliseconds
+		`CREATE TABLE IF NOT EXISTS queuefs_stream_messages (
+			queue_name VARCHAR(255) NOT NULL,
+			stream_offset BIGINT NOT NULL,
+			message_id VARCHAR(64) NOT NULL,
+			data LONGBLOB NOT NULL,
+			published_at BIGINT NOT NULL,
+			PRIMARY KEY (queue_name, stream_offset)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
 	}
 }
 
+func (b *TiDBDBBackend) GetCreateTableSQL(tableName string) []string {
+	return []string{fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		message_id VARCHAR(64) NOT NULL,
+		data LONGBLOB NOT NULL,
+		timestamp BIGINT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		deleted TINYINT(1) DEFAULT 0,
+		deleted_at TIMESTAMP NULL,
+		INDEX idx_deleted_id (deleted, id)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`, tableName)}
+}
+
+func (b *TiDBDBBackend) GetInsertIgnoreSQL() string {
+	return "INSERT IGNORE"
+}
+
+func (b *TiDBDBBackend) SupportsSkipLocked() bool {
+	return true
+}
+
 // Helper functions
 
 func extractDatabaseName(dsn string, configDB string) string {
@@ -241,20 +378,6 @@ func sanitizeTableName(queueName string) string {
 	return "queuefs_queue_" + tableName
 }
 
-// getCreateTableSQL returns the SQL to create a queue table
-func getCreateTableSQL(tableName string) string {
-	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
-		id BIGINT AUTO_INCREMENT PRIMARY KEY,
-		message_id VARCHAR(64) NOT NULL,
-		data LONGBLOB NOT NULL,
-		timestamp BIGINT NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		deleted TINYINT(1) DEFAULT 0,
-		deleted_at TIMESTAMP NULL,
-		INDEX idx_deleted_id (deleted, id)
-	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`, tableName)
-}
-
 // CreateBackend creates the appropriate database backend
 func CreateBackend(cfg map[string]interface{}) (DBBackend, error) {
 	backendType := config.GetStringConfig(cfg, "backend", "memory")
@@ -0,0 +1,369 @@
+package queuefs
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+const (
+	groupsDir = "groups" // Directory of a queue's consumer groups
+
+	defaultVisibilityTimeout = 30 * time.Second
+)
+
+// Meta values for consumer groups
+const (
+	MetaValueGroup = "group" // Consumer group directories
+)
+
+// Control files of a consumer group directory
+var groupOperations = map[string]bool{
+	"dequeue": true, // Read to lease the next message
+	"ack":     true, // Write message IDs to acknowledge them
+	"nack":    true, // Write message IDs to have them delivered again at once
+	"size":    true, // Messages waiting for delivery
+	"pending": true, // Messages delivered but not yet acknowledged
+}
+
+// groupPath is a path inside a queue's groups directory
+//
+//	/<queue>/groups                  - consumer groups of <queue>
+//	/<queue>/groups/<group>          - a consumer group
+//	/<queue>/groups/<group>/<file>   - a control file of the group
+type groupPath struct {
+	queueName string
+	group     string // Empty for the groups directory itself
+	operation string // Empty for directories
+}
+
+// parseGroupPath checks whether a path is inside a groups directory
+// "groups" is reserved: it cannot be used as a queue name.
+func parseGroupPath(path string) (groupPath, bool) {
+	parts := strings.Split(strings.TrimPrefix(filepath.Clean(path), "/"), "/")
+	for i := len(parts) - 1; i >= 1 && i >= len(parts)-3; i-- {
+		if parts[i] != groupsDir {
+			continue
+		}
+		g := groupPath{queueName: strings.Join(parts[:i], "/")}
+		if i+1 < len(parts) {
+			g.group = parts[i+1]
+		}
+		if i+2 < len(parts) {
+			g.operation = parts[i+2]
+		}
+		return g, true
+	}
+	return groupPath{}, false
+}
+
+// parseVisibilityTimeout reads visibility_timeout, which may be a duration
+// string or a number of seconds
+func parseVisibilityTimeout(cfg map[string]interface{}) (time.Duration, error) {
+	timeout, err := durationConfig(cfg, "visibility_timeout", defaultVisibilityTimeout)
+	if err != nil {
+		return 0, err
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("invalid visibility_timeout: must be positive")
+	}
+	return timeout, nil
+}
+
+// durationConfig reads a duration that may be given as a duration string or
+// a number of seconds
+func durationConfig(cfg map[string]interface{}, key string, defaultValue time.Duration) (time.Duration, error) {
+	switch v := cfg[key].(type) {
+	case nil:
+		return defaultValue, nil
+	case string:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s: %w", key, err)
+		}
+		return d, nil
+	case int:
+		return time.Duration(v) * time.Second, nil
+	case int64:
+		return time.Duration(v) * time.Second, nil
+	case float64:
+		return time.Duration(v * float64(time.Second)), nil
+	default:
+		return 0, fmt.Errorf("invalid %s: must be a duration such as \"30s\"", key)
+	}
+}
+
+func groupDirInfo(name, metaType string) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    name,
+		Mode:    0755,
+		ModTime: time.Now(),
+		IsDir:   true,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: metaType},
+	}
+}
+
+func groupFileInfo(operation string, size int64) filesystem.FileInfo {
+	mode := uint32(0444)
+	fileType := MetaValueQueueStatus
+	switch operation {
+	case "ack", "nack":
+		mode = 0222
+		fileType = MetaValueQueueControl
+	case "dequeue":
+		fileType = MetaValueQueueControl
+	}
+	return filesystem.FileInfo{
+		Name:    operation,
+		Size:    size,
+		Mode:    mode,
+		ModTime: time.Now(),
+		Meta:    filesystem.MetaData{Name: PluginName, Type: fileType},
+	}
+}
+
+// groupExists checks that a queue and, if named, its consumer group exist
+// Callers hold the plugin lock.
+func (qfs *queueFS) groupExists(g groupPath) (bool, error) {
+	exists, err := qfs.plugin.backend.QueueExists(g.queueName)
+	if err != nil || !exists || g.group == "" {
+		return exists, err
+	}
+	groups, err := qfs.plugin.backend.ListGroups(g.queueName)
+	if err != nil {
+		return false, err
+	}
+	for _, group := range groups {
+		if group == g.group {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (qfs *queueFS) groupStat(path string, g groupPath) (*filesystem.FileInfo, error) {
+	qfs.plugin.mu.RLock()
+	defer qfs.plugin.mu.RUnlock()
+
+	exists, err := qfs.groupExists(g)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, filesystem.NewNotFoundError("stat", path)
+	}
+
+	switch {
+	case g.group == "":
+		info := groupDirInfo(groupsDir, MetaValueGroup)
+		return &info, nil
+	case g.operation == "":
+		info := groupDirInfo(g.group, MetaValueGroup)
+		return &info, nil
+	case !groupOperations[g.operation]:
+		return nil, filesystem.NewNotFoundError("stat", path)
+	}
+
+	var size int64
+	if g.operation == "size" || g.operation == "pending" {
+		data, err := qfs.groupCount(g)
+		if err != nil {
+			return nil, err
+		}
+		size = int64(len(data))
+	}
+	info := groupFileInfo(g.operation, size)
+	return &info, nil
+}
+
+func (qfs *queueFS) groupReadDir(path string, g groupPath) ([]filesystem.FileInfo, error) {
+	if g.operation != "" {
+		return nil, filesystem.NewNotDirectoryError(path)
+	}
+
+	qfs.plugin.mu.RLock()
+	defer qfs.plugin.mu.RUnlock()
+
+	exists, err := qfs.groupExists(g)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, filesystem.NewNotFoundError("readdir", path)
+	}
+
+	if g.group == "" {
+		groups, err := qfs.plugin.backend.ListGroups(g.queueName)
+		if err != nil {
+			return nil, err
+		}
+		files := make([]filesystem.FileInfo, 0, len(groups))
+		for _, group := range groups {
+			files = append(files, groupDirInfo(group, MetaValueGroup))
+		}
+		return files, nil
+	}
+
+	files := make([]filesystem.FileInfo, 0, len(groupOperations))
+	for _, operation := range []string{"dequeue", "ack", "nack", "size", "pending"} {
+		var size int64
+		if operation == "size" || operation == "pending" {
+			data, err := qfs.groupCount(groupPath{queueName: g.queueName, group: g.group, operation: operation})
+			if err != nil {
+				return nil, err
+			}
+			size = int64(len(data))
+		}
+		files = append(files, groupFileInfo(operation, size))
+	}
+	return files, nil
+}
+
+func (qfs *queueFS) groupMkdir(path string, g groupPath) error {
+	if g.operation != "" || g.group == "" && g.queueName == "" {
+		return filesystem.NewInvalidArgumentError("path", path, "not a consumer group directory")
+	}
+
+	qfs.plugin.mu.Lock()
+	defer qfs.plugin.mu.Unlock()
+
+	exists, err := qfs.plugin.backend.QueueExists(g.queueName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return filesystem.NewNotFoundError("mkdir", "/"+g.queueName)
+	}
+	// The groups directory always exists
+	if g.group == "" {
+		return nil
+	}
+	return qfs.plugin.backend.CreateGroup(g.queueName, g.group)
+}
+
+func (qfs *queueFS) groupRemoveAll(g groupPath) error {
+	if g.operation != "" {
+		return fmt.Errorf("cannot remove control files: %s", g.operation)
+	}
+
+	qfs.plugin.mu.Lock()
+	defer qfs.plugin.mu.Unlock()
+
+	groups := []string{g.group}
+	if g.group == "" {
+		var err error
+		if groups, err = qfs.plugin.backend.ListGroups(g.queueName); err != nil {
+			return err
+		}
+	}
+	for _, group := range groups {
+		if err := qfs.plugin.backend.RemoveGroup(g.queueName, group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupRead reads a control file of a consumer group
+func (qfs *queueFS) groupRead(path string, g groupPath) ([]byte, error) {
+	switch g.operation {
+	case "":
+		return nil, fmt.Errorf("is a directory: %s", path)
+	case "dequeue":
+		return qfs.receive(g)
+	case "size", "pending":
+		qfs.plugin.mu.RLock()
+		defer qfs.plugin.mu.RUnlock()
+		return qfs.groupCount(g)
+	case "ack", "nack":
+		return nil, fmt.Errorf("permission denied: %s is write-only", path)
+	default:
+		return nil, filesystem.NewNotFoundError("read", path)
+	}
+}
+
+// groupWrite writes a control file of a consumer group
+func (qfs *queueFS) groupWrite(path string, g groupPath, data []byte) error {
+	switch g.operation {
+	case "ack", "nack":
+		return qfs.settle(g, data)
+	case "":
+		return fmt.Errorf("is a directory: %s", path)
+	default:
+		return fmt.Errorf("cannot write to: %s", path)
+	}
+}
+
+// receive leases the next message of a group for the visibility timeout
+func (qfs *queueFS) receive(g groupPath) ([]byte, error) {
+	qfs.plugin.mu.Lock()
+	defer qfs.plugin.mu.Unlock()
+
+	delivery, found, err := qfs.plugin.backend.Receive(g.queueName, g.group, qfs.plugin.visibilityTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		// Return empty JSON object instead of error for empty group, like dequeue
+		return []byte("{}"), nil
+	}
+	return json.Marshal(delivery)
+}
+
+// groupCount returns the size or pending count of a group; callers hold the plugin lock
+func (qfs *queueFS) groupCount(g groupPath) ([]byte, error) {
+	ready, inflight, err := qfs.plugin.backend.GroupStats(g.queueName, g.group)
+	if err != nil {
+		return nil, err
+	}
+	if g.operation == "pending" {
+		return []byte(strconv.Itoa(inflight)), nil
+	}
+	return []byte(strconv.Itoa(ready)), nil
+}
+
+// settle acknowledges or releases the messages written to ack or nack
+// Each line holds a message ID, or a message as read from dequeue.
+func (qfs *queueFS) settle(g groupPath, data []byte) error {
+	qfs.plugin.mu.Lock()
+	defer qfs.plugin.mu.Unlock()
+
+	var unknown []string
+	for _, line := range strings.Split(string(data), "\n") {
+		id := strings.TrimSpace(line)
+		if strings.HasPrefix(id, "{") {
+			var msg QueueMessage
+			if err := json.Unmarshal([]byte(id), &msg); err != nil {
+				return filesystem.NewInvalidArgumentError(g.operation, id, "expected a message ID or a message read from dequeue")
+			}
+			id = msg.ID
+		}
+		if id == "" {
+			continue
+		}
+
+		var settled bool
+		var err error
+		if g.operation == "ack" {
+			settled, err = qfs.plugin.backend.Ack(g.queueName, g.group, id)
+		} else {
+			settled, err = qfs.plugin.backend.Nack(g.queueName, g.group, id)
+		}
+		if err != nil {
+			return err
+		}
+		if !settled {
+			unknown = append(unknown, id)
+		}
+	}
+
+	if len(unknown) > 0 {
+		return fmt.Errorf("%w: %s: not delivered to group %s or already settled", filesystem.ErrNotFound, strings.Join(unknown, ", "), g.group)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package queuefs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	log "github.com/sirupsen/logrus"
+)
+
+// snapshotFile is a read-only file at the mount root holding every queue as
+// JSON; a new mount imports it with migrate_from
+const snapshotFile = ".snapshot"
+
+// queueSnapshot is the content of a queue store, for moving queues between backends
+type queueSnapshot struct {
+	Backend   string          `json:"backend"`
+	CreatedAt time.Time       `json:"created_at"`
+	Queues    []snapshotQueue `json:"queues"`
+}
+
+type snapshotQueue struct {
+	Name     string          `json:"name"`
+	Messages []QueueMessage  `json:"messages"`
+	Groups   []snapshotGroup `json:"groups,omitempty"`
+}
+
+// snapshotGroup holds the unacknowledged messages of a consumer group
+// Leases are not kept: leased messages are delivered again after a migration.
+type snapshotGroup struct {
+	Name     string         `json:"name"`
+	Messages []QueueMessage `json:"messages"`
+}
+
+// takeSnapshot reads every queue of a backend without consuming messages
+// Callers hold the plugin lock.
+func takeSnapshot(backend QueueBackend) (*queueSnapshot, error) {
+	queues, err := backend.ListQueues("")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(queues)
+
+	snap := &queueSnapshot{Backend: backend.GetType(), CreatedAt: time.Now(), Queues: []snapshotQueue{}}
+	for _, name := range queues {
+		q := snapshotQueue{Name: name}
+		if q.Messages, err = backend.ListMessages(name, ""); err != nil {
+			return nil, err
+		}
+
+		groups, err := backend.ListGroups(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range groups {
+			msgs, err := backend.ListMessages(name, group)
+			if err != nil {
+				return nil, err
+			}
+			q.Groups = append(q.Groups, snapshotGroup{Name: group, Messages: msgs})
+		}
+		snap.Queues = append(snap.Queues, q)
+	}
+	return snap, nil
+}
+
+// restoreSnapshot creates the queues and groups of a snapshot with their messages
+func restoreSnapshot(backend QueueBackend, snap *queueSnapshot) error {
+	for _, q := range snap.Queues {
+		if err := backend.CreateQueue(q.Name); err != nil {
+			return fmt.Errorf("failed to create queue %s: %w", q.Name, err)
+		}
+		if err := backend.RestoreMessages(q.Name, "", q.Messages); err != nil {
+			return fmt.Errorf("failed to restore queue %s: %w", q.Name, err)
+		}
+		for _, g := range q.Groups {
+			if err := backend.CreateGroup(q.Name, g.Name); err != nil {
+				return fmt.Errorf("failed to create group %s/%s: %w", q.Name, g.Name, err)
+			}
+			if err := backend.RestoreMessages(q.Name, g.Name, g.Messages); err != nil {
+				return fmt.Errorf("failed to restore group %s/%s: %w", q.Name, g.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// migrateFrom imports a snapshot file into a backend that has no queues yet
+// A backend that already has queues was migrated before (or is in use), so
+// restarting with the same configuration does not import the file twice.
+func migrateFrom(backend QueueBackend, path string) error {
+	queues, err := backend.ListQueues("")
+	if err != nil {
+		return err
+	}
+	if len(queues) > 0 {
+		log.Infof("[queuefs] Skipping migration from %s: %s backend already has %d queues", path, backend.GetType(), len(queues))
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	var snap queueSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("invalid snapshot %s: %w", path, err)
+	}
+	if err := restoreSnapshot(backend, &snap); err != nil {
+		return err
+	}
+
+	log.Infof("[queuefs] Migrated %d queues from %s snapshot %s", len(snap.Queues), snap.Backend, path)
+	return nil
+}
+
+// readSnapshot returns the snapshot served by the snapshot file
+func (qfs *queueFS) readSnapshot() ([]byte, error) {
+	qfs.plugin.mu.RLock()
+	defer qfs.plugin.mu.RUnlock()
+
+	snap, err := takeSnapshot(qfs.plugin.backend)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(snap, "", "  ")
+}
+
+func snapshotFileInfo(size int64) *filesystem.FileInfo {
+	return &filesystem.FileInfo{
+		Name:    snapshotFile,
+		Size:    size,
+		Mode:    0444,
+		ModTime: time.Now(),
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "snapshot"},
+	}
+}
@@ -38,6 +38,8 @@ const (
 //	                      This can be used for implementing poll offset logic
 //	/queue_name/size    - read to get queue size
 //	/queue_name/clear   - write to this file to clear the queue
+//	/queue_name/groups/ - consumer groups with acknowledged delivery (see groups.go)
+//	/queue_name/subscribers/ - topic subscribers with their own cursors (see topics.go)
 //
 // Supports multiple backends:
 //   - memory (default): In-memory storage
@@ -47,13 +49,17 @@ type QueueFSPlugin struct {
 	backend  QueueBackend
 	mu       sync.RWMutex // Protects backend operations
 	metadata plugin.PluginMetadata
+
+	visibilityTimeout time.Duration // How long a consumer group delivery stays leased
 }
 
 // Queue represents a single message queue (for memory backend)
 type Queue struct {
 	messages        []QueueMessage
 	mu              sync.Mutex
-	lastEnqueueTime time.Time // Tracks the timestamp of the most recently enqueued message
+	lastEnqueueTime time.Time                  // Tracks the timestamp of the most recently enqueued message
+	groups          map[string][]*groupMessage // Consumer group -> messages not yet acknowledged
+	stream          *topicStream               // Messages kept for subscribers, nil without subscribers
 }
 
 type QueueMessage struct {
@@ -65,6 +71,7 @@ type QueueMessage struct {
 // NewQueueFSPlugin creates a new queue plugin
 func NewQueueFSPlugin() *QueueFSPlugin {
 	return &QueueFSPlugin{
+		visibilityTimeout: defaultVisibilityTimeout,
 		metadata: plugin.PluginMetadata{
 			Name:        PluginName,
 			Version:     "1.0.0",
@@ -81,7 +88,8 @@ func (q *QueueFSPlugin) Name() string {
 func (q *QueueFSPlugin) Validate(cfg map[string]interface{}) error {
 	// Allowed configuration keys
 	allowedKeys := []string{
-		"backend", "mount_path",
+		"backend", "mount_path", "visibility_timeout", "migrate_from",
+		"topic_max_messages", "topic_max_age",
 		// Database-related keys
 		"db_path", "dsn", "user", "password", "host", "port", "database",
 		"enable_tls", "tls_server_name", "tls_skip_verify",
@@ -103,6 +111,16 @@ func (q *QueueFSPlugin) Validate(cfg map[string]interface{}) error {
 		return fmt.Errorf("unsupported backend: %s (valid options: memory, tidb, mysql, sqlite)", backendType)
 	}
 
+	if _, err := parseVisibilityTimeout(cfg); err != nil {
+		return err
+	}
+	if _, err := parseTopicRetention(cfg); err != nil {
+		return err
+	}
+	if err := config.ValidateStringType(cfg, "migrate_from"); err != nil {
+		return err
+	}
+
 	// Validate database-related parameters if backend is not memory
 	if backendType != "memory" {
 		for _, key := range []string{"db_path", "dsn", "user", "password", "host", "database", "tls_server_name"} {
@@ -130,9 +148,14 @@ func (q *QueueFSPlugin) Validate(cfg map[string]interface{}) error {
 func (q *QueueFSPlugin) Initialize(cfg map[string]interface{}) error {
 	backendType := config.GetStringConfig(cfg, "backend", "memory")
 
+	visibilityTimeout, err := parseVisibilityTimeout(cfg)
+	if err != nil {
+		return err
+	}
+	q.visibilityTimeout = visibilityTimeout
+
 	// Create appropriate backend
 	var backend QueueBackend
-	var err error
 
 	switch backendType {
 	case "memory":
@@ -150,6 +173,14 @@ func (q *QueueFSPlugin) Initialize(cfg map[string]interface{}) error {
 
 	q.backend = backend
 
+	// Import queues from another backend, e.g. the memory backend this mount used before
+	if path := config.GetStringConfig(cfg, "migrate_from", ""); path != "" {
+		if err := migrateFrom(backend, path); err != nil {
+			backend.Close()
+			return fmt.Errorf("failed to migrate queues from %s: %w", path, err)
+		}
+	}
+
 	log.Infof("[queuefs] Initialized with backend: %s", backendType)
 	return nil
 }
@@ -167,12 +198,15 @@ Each queue is a directory containing control files for queue operations.
 STRUCTURE:
   /queuefs/
     README          - This documentation
+    .snapshot       - Read-only JSON of all queues (not listed, see MIGRATING FROM MEMORY)
     <queue_name>/   - A queue directory
       enqueue       - Write-only file to enqueue messages
       dequeue       - Read-only file to dequeue messages
       peek          - Read-only file to peek at next message
       size          - Read-only file showing queue size
       clear         - Write-only file to clear all messages
+      groups/       - Consumer groups (see CONSUMER GROUPS)
+      subscribers/  - Topic subscribers (see TOPICS)
 
 WORKFLOW:
   1. Create a queue:
@@ -202,6 +236,67 @@ NESTED QUEUES:
     echo "error: timeout" > /queuefs/logs/errors/enqueue
     cat /queuefs/logs/errors/dequeue
 
+CONSUMER GROUPS:
+  A queue can have named consumer groups for at-least-once delivery. Each
+  group receives every message enqueued after it was created, and consumers
+  within a group share its messages:
+
+    <queue>/groups/<group>/dequeue  - Read the next message; it is leased
+                                      for visibility_timeout
+    <queue>/groups/<group>/ack      - Write message IDs (one per line) to
+                                      acknowledge them
+    <queue>/groups/<group>/nack     - Write message IDs to release them for
+                                      immediate redelivery
+    <queue>/groups/<group>/size     - Messages waiting for delivery
+    <queue>/groups/<group>/pending  - Messages leased but not acknowledged
+
+  A message that is not acknowledged before its lease expires is delivered
+  again, with a higher delivery_count. Acknowledge only after the work is
+  done; a crashed consumer then loses nothing.
+
+  Once a queue has consumer groups, enqueued messages go to the groups only,
+  and the queue's own dequeue serves the messages enqueued before.
+  "groups" cannot be used as the name of a nested queue.
+
+    mkdir /queuefs/jobs/groups/indexer
+    echo "doc-42" > /queuefs/jobs/enqueue
+    cat /queuefs/jobs/groups/indexer/dequeue
+    {"id":"0190...","data":"doc-42","timestamp":"...","delivery_count":1,"ack_deadline":"..."}
+    echo 0190... > /queuefs/jobs/groups/indexer/ack
+
+    rm -r /queuefs/jobs/groups/indexer   # Delete the group
+
+TOPICS:
+  A queue with subscribers is also a topic for broadcasting: every
+  subscriber reads every message at its own cursor, and reading does not
+  take a message from the others:
+
+    <queue>/subscribers/<name>/next  - Read the message at the cursor and
+                                       move past it
+    <queue>/subscribers/<name>/peek  - Read the message at the cursor
+    <queue>/subscribers/<name>/lag   - Messages not read yet
+    <queue>/subscribers/<name>/seek  - Write "earliest", "latest" or an
+                                       offset to move the cursor
+
+  A new subscriber reads from the next message enqueued. Messages carry
+  their offset in the stream, which grows by one per message. The stream
+  keeps the last topic_max_messages messages, for at most topic_max_age;
+  a subscriber that falls behind skips what was dropped, and the next
+  message it reads counts the skipped messages in "missed".
+
+  Like consumer groups, subscribers take over the queue's messages: its
+  own dequeue only serves the messages enqueued before. Removing the last
+  subscriber drops the stream. Streams are not part of the snapshot.
+  "subscribers" cannot be used as the name of a nested queue.
+
+    mkdir /queuefs/events/subscribers/planner
+    mkdir /queuefs/events/subscribers/reviewer
+    echo "build finished" > /queuefs/events/enqueue
+    cat /queuefs/events/subscribers/planner/next
+    {"id":"0190...","data":"build finished","timestamp":"...","offset":0}
+    cat /queuefs/events/subscribers/reviewer/next    # Same message
+    echo earliest > /queuefs/events/subscribers/planner/seek   # Replay
+
 BACKENDS:
 
   Memory Backend (default):
@@ -210,7 +305,7 @@ BACKENDS:
   path = "/queuefs"
   # No additional config needed for memory backend
 
-  SQLite Backend:
+  SQLite Backend (persistent, WAL mode):
   [plugins.queuefs]
   enabled = true
   path = "/queuefs"
@@ -247,6 +342,21 @@ BACKENDS:
     enable_tls = true
     tls_server_name = "gateway01.us-west-2.prod.aws.tidbcloud.com"
 
+MIGRATING FROM MEMORY:
+  /.snapshot holds every queue, consumer group and pending message as JSON;
+  reading it consumes nothing. To move a running memory queue to sqlite:
+
+    1. Stop the producers and save the snapshot:
+       cat /queuefs/.snapshot > /var/lib/agfs/queues.json
+    2. Remount with the durable backend and migrate_from:
+       backend = "sqlite"
+       db_path = "/var/lib/agfs/queue.db"
+       migrate_from = "/var/lib/agfs/queues.json"
+
+  The snapshot is imported only into a backend without queues, so keeping
+  migrate_from in the configuration does not import it again on restart.
+  Messages leased to a consumer group are delivered again after the move.
+
 EXAMPLES:
   # Create multiple queues
   agfs:/> mkdir /queuefs/orders
@@ -275,7 +385,7 @@ EXAMPLES:
 
 BACKEND COMPARISON:
   - memory: Fastest, no persistence, lost on restart
-  - sqlite: Good for single server, persistent, file-based
+  - sqlite: Good for single server, persistent (WAL), file-based
   - tidb: Best for production, distributed, scalable, persistent
 `
 }
@@ -288,6 +398,35 @@ func (q *QueueFSPlugin) GetConfigParams() []plugin.ConfigParameter {
 			Required:    false,
 			Default:     "memory",
 			Description: "Queue backend (memory, tidb, mysql, sqlite, sqlite3)",
+			Enum:        []string{"memory", "tidb", "mysql", "sqlite", "sqlite3"},
+		},
+		{
+			Name:        "visibility_timeout",
+			Type:        "string",
+			Required:    false,
+			Default:     "30s",
+			Description: "How long a message read from a consumer group stays hidden before it is delivered again",
+		},
+		{
+			Name:        "topic_max_messages",
+			Type:        "int",
+			Required:    false,
+			Default:     "1000",
+			Description: "Most messages a queue with subscribers keeps for them",
+		},
+		{
+			Name:        "topic_max_age",
+			Type:        "string",
+			Required:    false,
+			Default:     "24h",
+			Description: "How long a queue with subscribers keeps messages for them (0 for no limit)",
+		},
+		{
+			Name:        "migrate_from",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Snapshot file (saved from /.snapshot) to import when the backend has no queues yet",
 		},
 		{
 			Name:        "db_path",
@@ -302,6 +441,7 @@ func (q *QueueFSPlugin) GetConfigParams() []plugin.ConfigParameter {
 			Required:    false,
 			Default:     "",
 			Description: "Database connection string (DSN)",
+			Secret:      true,
 		},
 		{
 			Name:        "user",
@@ -316,6 +456,7 @@ func (q *QueueFSPlugin) GetConfigParams() []plugin.ConfigParameter {
 			Required:    false,
 			Default:     "",
 			Description: "Database password",
+			Secret:      true,
 		},
 		{
 			Name:        "host",
@@ -362,6 +503,20 @@ func (q *QueueFSPlugin) GetConfigParams() []plugin.ConfigParameter {
 	}
 }
 
+// HealthCheck reports whether the database of a database backend is reachable
+// The memory backend is always healthy.
+func (q *QueueFSPlugin) HealthCheck() error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if b, ok := q.backend.(*TiDBBackend); ok {
+		if err := b.Ping(); err != nil {
+			return fmt.Errorf("%s backend unreachable: %w", b.GetType(), err)
+		}
+	}
+	return nil
+}
+
 func (q *QueueFSPlugin) Shutdown() error {
 	q.mu.Lock()
 	defer q.mu.Unlock()
@@ -429,6 +584,21 @@ func isValidQueueOperation(op string) bool {
 }
 
 func (qfs *queueFS) Create(path string) error {
+	if g, ok := parseGroupPath(path); ok {
+		if g.operation != "" && groupOperations[g.operation] {
+			// Control files are virtual, no need to create
+			return nil
+		}
+		return fmt.Errorf("cannot create files in queuefs: %s", path)
+	}
+	if sub, ok := parseSubscriberPath(path); ok {
+		if sub.operation != "" && subscriberOperations[sub.operation] {
+			// Control files are virtual, no need to create
+			return nil
+		}
+		return fmt.Errorf("cannot create files in queuefs: %s", path)
+	}
+
 	_, operation, isDir, err := parseQueuePath(path)
 	if err != nil {
 		return err
@@ -447,6 +617,13 @@ func (qfs *queueFS) Create(path string) error {
 }
 
 func (qfs *queueFS) Mkdir(path string, perm uint32) error {
+	if g, ok := parseGroupPath(path); ok {
+		return qfs.groupMkdir(path, g)
+	}
+	if sub, ok := parseSubscriberPath(path); ok {
+		return qfs.subscriberMkdir(path, sub)
+	}
+
 	queueName, _, isDir, err := parseQueuePath(path)
 	if err != nil {
 		return err
@@ -456,7 +633,7 @@ func (qfs *queueFS) Mkdir(path string, perm uint32) error {
 		return fmt.Errorf("cannot create directory: %s is not a valid directory path", path)
 	}
 
-	if queueName == "" {
+	if queueName == "" || queueName == snapshotFile {
 		return fmt.Errorf("invalid queue name")
 	}
 
@@ -468,6 +645,13 @@ func (qfs *queueFS) Mkdir(path string, perm uint32) error {
 }
 
 func (qfs *queueFS) Remove(path string) error {
+	if g, ok := parseGroupPath(path); ok && g.operation == "" && g.group != "" {
+		return qfs.groupRemoveAll(g)
+	}
+	if sub, ok := parseSubscriberPath(path); ok && sub.operation == "" && sub.subscriber != "" {
+		return qfs.subscriberRemoveAll(sub)
+	}
+
 	_, operation, isDir, err := parseQueuePath(path)
 	if err != nil {
 		return err
@@ -485,6 +669,13 @@ func (qfs *queueFS) Remove(path string) error {
 }
 
 func (qfs *queueFS) RemoveAll(path string) error {
+	if g, ok := parseGroupPath(path); ok {
+		return qfs.groupRemoveAll(g)
+	}
+	if sub, ok := parseSubscriberPath(path); ok {
+		return qfs.subscriberRemoveAll(sub)
+	}
+
 	queueName, _, isDir, err := parseQueuePath(path)
 	if err != nil {
 		return err
@@ -507,6 +698,30 @@ func (qfs *queueFS) Read(path string, offset int64, size int64) ([]byte, error)
 		return plugin.ApplyRangeRead(data, offset, size)
 	}
 
+	if path == "/"+snapshotFile {
+		data, err := qfs.readSnapshot()
+		if err != nil {
+			return nil, err
+		}
+		return plugin.ApplyRangeRead(data, offset, size)
+	}
+
+	if g, ok := parseGroupPath(path); ok {
+		data, err := qfs.groupRead(path, g)
+		if err != nil {
+			return nil, err
+		}
+		return plugin.ApplyRangeRead(data, offset, size)
+	}
+
+	if sub, ok := parseSubscriberPath(path); ok {
+		data, err := qfs.subscriberRead(path, sub)
+		if err != nil {
+			return nil, err
+		}
+		return plugin.ApplyRangeRead(data, offset, size)
+	}
+
 	queueName, operation, isDir, err := parseQueuePath(path)
 	if err != nil {
 		return nil, err
@@ -544,6 +759,24 @@ func (qfs *queueFS) Read(path string, offset int64, size int64) ([]byte, error)
 }
 
 func (qfs *queueFS) Write(path string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	if path == "/"+snapshotFile {
+		return 0, filesystem.NewPermissionDeniedError("write", path, "read-only; import a snapshot with migrate_from")
+	}
+
+	if g, ok := parseGroupPath(path); ok {
+		if err := qfs.groupWrite(path, g, data); err != nil {
+			return 0, err
+		}
+		return int64(len(data)), nil
+	}
+
+	if sub, ok := parseSubscriberPath(path); ok {
+		if err := qfs.subscriberWrite(path, sub, data); err != nil {
+			return 0, err
+		}
+		return int64(len(data)), nil
+	}
+
 	queueName, operation, isDir, err := parseQueuePath(path)
 	if err != nil {
 		return 0, err
@@ -579,6 +812,13 @@ func (qfs *queueFS) Write(path string, data []byte, offset int64, flags filesyst
 }
 
 func (qfs *queueFS) ReadDir(path string) ([]filesystem.FileInfo, error) {
+	if g, ok := parseGroupPath(path); ok {
+		return qfs.groupReadDir(path, g)
+	}
+	if sub, ok := parseSubscriberPath(path); ok {
+		return qfs.subscriberReadDir(path, sub)
+	}
+
 	queueName, _, isDir, err := parseQueuePath(path)
 	if err != nil {
 		return nil, err
@@ -749,6 +989,8 @@ func (qfs *queueFS) getQueueControlFiles(queueName string, now time.Time) ([]fil
 			IsDir:   false,
 			Meta:    filesystem.MetaData{Name: PluginName, Type: MetaValueQueueControl},
 		},
+		groupDirInfo(groupsDir, MetaValueGroup),
+		groupDirInfo(subscribersDir, MetaValueSubscriber),
 	}
 
 	return files, nil
@@ -784,6 +1026,21 @@ func (qfs *queueFS) Stat(path string) (*filesystem.FileInfo, error) {
 		}, nil
 	}
 
+	if path == "/"+snapshotFile {
+		data, err := qfs.readSnapshot()
+		if err != nil {
+			return nil, err
+		}
+		return snapshotFileInfo(int64(len(data))), nil
+	}
+
+	if g, ok := parseGroupPath(path); ok {
+		return qfs.groupStat(path, g)
+	}
+	if sub, ok := parseSubscriberPath(path); ok {
+		return qfs.subscriberStat(path, sub)
+	}
+
 	queueName, operation, isDir, err := parseQueuePath(path)
 	if err != nil {
 		return nil, err
@@ -1015,7 +1272,9 @@ type queueFileHandle struct {
 	qfs       *queueFS
 	path      string
 	queueName string
-	operation string // "enqueue", "dequeue", "peek", "size", "clear"
+	group      string // Consumer group, empty for the queue's own control files
+	subscriber string // Topic subscriber, empty for the queue's own control files
+	operation  string // "enqueue", "dequeue", "peek", "size", "clear", or a group or subscriber operation
 	flags     filesystem.OpenFlag
 
 	// For dequeue/peek: cached message data (read once, return from cache)
@@ -1041,6 +1300,19 @@ var queueHandleManager = &handleManager{
 
 // OpenHandle opens a file and returns a handle for stateful operations
 func (qfs *queueFS) OpenHandle(path string, flags filesystem.OpenFlag, mode uint32) (filesystem.FileHandle, error) {
+	if g, ok := parseGroupPath(path); ok {
+		if !groupOperations[g.operation] {
+			return nil, fmt.Errorf("cannot open as file: %s", path)
+		}
+		return qfs.newHandle(path, g.queueName, g.group, "", g.operation, flags), nil
+	}
+	if sub, ok := parseSubscriberPath(path); ok {
+		if !subscriberOperations[sub.operation] {
+			return nil, fmt.Errorf("cannot open as file: %s", path)
+		}
+		return qfs.newHandle(path, sub.queueName, "", sub.subscriber, sub.operation, flags), nil
+	}
+
 	queueName, operation, isDir, err := parseQueuePath(path)
 	if err != nil {
 		return nil, err
@@ -1059,6 +1331,10 @@ func (qfs *queueFS) OpenHandle(path string, flags filesystem.OpenFlag, mode uint
 		return nil, fmt.Errorf("unknown operation: %s", operation)
 	}
 
+	return qfs.newHandle(path, queueName, "", "", operation, flags), nil
+}
+
+func (qfs *queueFS) newHandle(path, queueName, group, subscriber, operation string, flags filesystem.OpenFlag) *queueFileHandle {
 	queueHandleManager.mu.Lock()
 	defer queueHandleManager.mu.Unlock()
 
@@ -1066,16 +1342,18 @@ func (qfs *queueFS) OpenHandle(path string, flags filesystem.OpenFlag, mode uint
 	queueHandleManager.nextID++
 
 	handle := &queueFileHandle{
-		id:        id,
-		qfs:       qfs,
-		path:      path,
-		queueName: queueName,
-		operation: operation,
-		flags:     flags,
+		id:         id,
+		qfs:        qfs,
+		path:       path,
+		queueName:  queueName,
+		group:      group,
+		subscriber: subscriber,
+		operation:  operation,
+		flags:      flags,
 	}
 
 	queueHandleManager.handles[id] = handle
-	return handle, nil
+	return handle
 }
 
 // GetHandle retrieves an existing handle by its ID
@@ -1125,31 +1403,8 @@ func (h *queueFileHandle) Read(buf []byte) (int, error) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	// For dequeue/peek: fetch data once and cache it
-	if !h.readDone {
-		var data []byte
-		var err error
-
-		switch h.operation {
-		case "dequeue":
-			data, err = h.qfs.dequeue(h.queueName)
-		case "peek":
-			data, err = h.qfs.peek(h.queueName)
-		case "size":
-			data, err = h.qfs.size(h.queueName)
-		case "enqueue", "clear":
-			// These are write-only operations
-			return 0, io.EOF
-		default:
-			return 0, fmt.Errorf("unsupported read operation: %s", h.operation)
-		}
-
-		if err != nil {
-			return 0, err
-		}
-
-		h.readBuffer = data
-		h.readDone = true
+	if err := h.fetch(); err != nil {
+		return 0, err
 	}
 
 	// Return from cache, tracking position for sequential reads
@@ -1166,31 +1421,8 @@ func (h *queueFileHandle) ReadAt(buf []byte, offset int64) (int, error) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	// For dequeue/peek: fetch data once and cache it
-	if !h.readDone {
-		var data []byte
-		var err error
-
-		switch h.operation {
-		case "dequeue":
-			data, err = h.qfs.dequeue(h.queueName)
-		case "peek":
-			data, err = h.qfs.peek(h.queueName)
-		case "size":
-			data, err = h.qfs.size(h.queueName)
-		case "enqueue", "clear":
-			// These are write-only operations
-			return 0, io.EOF
-		default:
-			return 0, fmt.Errorf("unsupported read operation: %s", h.operation)
-		}
-
-		if err != nil {
-			return 0, err
-		}
-
-		h.readBuffer = data
-		h.readDone = true
+	if err := h.fetch(); err != nil {
+		return 0, err
 	}
 
 	// Return from cache
@@ -1202,6 +1434,49 @@ func (h *queueFileHandle) ReadAt(buf []byte, offset int64) (int, error) {
 	return n, nil
 }
 
+// fetch reads the control file once and caches the data, so that a dequeue
+// through a handle removes only one message however the data is read
+// Write-only files read as empty.
+func (h *queueFileHandle) fetch() error {
+	if h.readDone {
+		return nil
+	}
+
+	var data []byte
+	var err error
+
+	switch {
+	case h.group != "":
+		if h.operation == "ack" || h.operation == "nack" {
+			break
+		}
+		data, err = h.qfs.groupRead(h.path, groupPath{queueName: h.queueName, group: h.group, operation: h.operation})
+	case h.subscriber != "":
+		if h.operation == "seek" {
+			break
+		}
+		data, err = h.qfs.subscriberRead(h.path, subscriberPath{queueName: h.queueName, subscriber: h.subscriber, operation: h.operation})
+	case h.operation == "dequeue":
+		data, err = h.qfs.dequeue(h.queueName)
+	case h.operation == "peek":
+		data, err = h.qfs.peek(h.queueName)
+	case h.operation == "size":
+		data, err = h.qfs.size(h.queueName)
+	case h.operation == "enqueue", h.operation == "clear":
+		// These are write-only operations
+	default:
+		return fmt.Errorf("unsupported read operation: %s", h.operation)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	h.readBuffer = data
+	h.readDone = true
+	return nil
+}
+
 func (h *queueFileHandle) Write(data []byte) (int, error) {
 	return h.WriteAt(data, 0)
 }
@@ -1210,6 +1485,19 @@ func (h *queueFileHandle) WriteAt(data []byte, offset int64) (int, error) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	if h.group != "" {
+		if err := h.qfs.groupWrite(h.path, groupPath{queueName: h.queueName, group: h.group, operation: h.operation}, data); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+	if h.subscriber != "" {
+		if err := h.qfs.subscriberWrite(h.path, subscriberPath{queueName: h.queueName, subscriber: h.subscriber, operation: h.operation}, data); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+
 	switch h.operation {
 	case "enqueue":
 		_, err := h.qfs.enqueue(h.queueName, data)
@@ -0,0 +1,402 @@
+package queuefs
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+func newTestFS(t *testing.T, cfg map[string]interface{}) *queueFS {
+	t.Helper()
+
+	p := NewQueueFSPlugin()
+	if err := p.Validate(cfg); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if err := p.Initialize(cfg); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	t.Cleanup(func() { p.Shutdown() })
+	return p.GetFileSystem().(*queueFS)
+}
+
+func readDelivery(t *testing.T, fs *queueFS, path string) Delivery {
+	t.Helper()
+
+	data, err := fs.Read(path, 0, -1)
+	if err != nil && err != io.EOF {
+		t.Fatalf("Read %s failed: %v", path, err)
+	}
+	var d Delivery
+	if err := json.Unmarshal(data, &d); err != nil {
+		t.Fatalf("invalid delivery %q: %v", data, err)
+	}
+	return d
+}
+
+func readString(t *testing.T, fs *queueFS, path string) string {
+	t.Helper()
+
+	data, err := fs.Read(path, 0, -1)
+	if err != nil && err != io.EOF {
+		t.Fatalf("Read %s failed: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestParseGroupPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want groupPath
+		ok   bool
+	}{
+		{"/jobs/groups", groupPath{queueName: "jobs"}, true},
+		{"/jobs/groups/workers", groupPath{queueName: "jobs", group: "workers"}, true},
+		{"/a/b/groups/workers/ack", groupPath{queueName: "a/b", group: "workers", operation: "ack"}, true},
+		{"/groups", groupPath{}, false},
+		{"/jobs/dequeue", groupPath{}, false},
+		{"/jobs/groups/a/b/c", groupPath{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseGroupPath(tt.path)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseGroupPath(%q) = %+v, %v; want %+v, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestConsumerGroups(t *testing.T) {
+	fs := newTestFS(t, map[string]interface{}{"visibility_timeout": "100ms"})
+
+	if err := fs.Mkdir("/jobs", 0755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	if err := fs.Mkdir("/missing/groups/g", 0755); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("expected not found for group of missing queue, got %v", err)
+	}
+	for _, group := range []string{"indexer", "auditor"} {
+		if err := fs.Mkdir("/jobs/groups/"+group, 0755); err != nil {
+			t.Fatalf("Mkdir group failed: %v", err)
+		}
+	}
+
+	groups, err := fs.ReadDir("/jobs/groups")
+	if err != nil || len(groups) != 2 || groups[0].Name != "auditor" || !groups[0].IsDir {
+		t.Fatalf("unexpected groups %+v, %v", groups, err)
+	}
+
+	for _, msg := range []string{"one", "two"} {
+		if _, err := fs.Write("/jobs/enqueue", []byte(msg), -1, filesystem.WriteFlagNone); err != nil {
+			t.Fatalf("enqueue failed: %v", err)
+		}
+	}
+
+	// Every group gets every message; the queue itself keeps none
+	if size := readString(t, fs, "/jobs/size"); size != "0" {
+		t.Errorf("expected queue size 0, got %s", size)
+	}
+	if size := readString(t, fs, "/jobs/groups/auditor/size"); size != "2" {
+		t.Errorf("expected group size 2, got %s", size)
+	}
+
+	first := readDelivery(t, fs, "/jobs/groups/indexer/dequeue")
+	if first.Data != "one" || first.DeliveryCount != 1 || first.AckDeadline.IsZero() {
+		t.Fatalf("unexpected delivery %+v", first)
+	}
+	second := readDelivery(t, fs, "/jobs/groups/indexer/dequeue")
+	if second.Data != "two" {
+		t.Fatalf("expected second message, got %+v", second)
+	}
+	if data := readString(t, fs, "/jobs/groups/indexer/dequeue"); data != "{}" {
+		t.Fatalf("expected no visible message, got %s", data)
+	}
+	if pending := readString(t, fs, "/jobs/groups/indexer/pending"); pending != "2" {
+		t.Errorf("expected 2 pending, got %s", pending)
+	}
+
+	// Acknowledge the first message by ID; the second one is redelivered
+	if _, err := fs.Write("/jobs/groups/indexer/ack", []byte(first.ID+"\n"), -1, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("ack failed: %v", err)
+	}
+	if _, err := fs.Write("/jobs/groups/indexer/ack", []byte(first.ID), -1, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("expected second ack to fail, got %v", err)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	again := readDelivery(t, fs, "/jobs/groups/indexer/dequeue")
+	if again.ID != second.ID || again.DeliveryCount != 2 {
+		t.Fatalf("expected redelivery of %s, got %+v", second.ID, again)
+	}
+
+	// A message read from dequeue can be acknowledged as is
+	raw, _ := json.Marshal(again)
+	if _, err := fs.Write("/jobs/groups/indexer/ack", raw, -1, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("ack of message failed: %v", err)
+	}
+	if size := readString(t, fs, "/jobs/groups/indexer/size"); size != "0" {
+		t.Errorf("expected empty group, got %s", size)
+	}
+
+	// The other group is unaffected
+	if d := readDelivery(t, fs, "/jobs/groups/auditor/dequeue"); d.Data != "one" {
+		t.Errorf("expected auditor to get first message, got %+v", d)
+	}
+
+	if err := fs.RemoveAll("/jobs/groups/auditor"); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	if _, err := fs.Stat("/jobs/groups/auditor"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("expected removed group to be gone, got %v", err)
+	}
+}
+
+func TestConsumerGroupNackAndHandles(t *testing.T) {
+	fs := newTestFS(t, map[string]interface{}{})
+
+	fs.Mkdir("/jobs", 0755)
+	fs.Mkdir("/jobs/groups/workers", 0755)
+	fs.Write("/jobs/enqueue", []byte("task"), -1, filesystem.WriteFlagNone)
+
+	h, err := fs.OpenHandle("/jobs/groups/workers/dequeue", filesystem.O_RDONLY, 0)
+	if err != nil {
+		t.Fatalf("OpenHandle failed: %v", err)
+	}
+	data, err := io.ReadAll(h.(io.Reader))
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	h.Close()
+	var d Delivery
+	if err := json.Unmarshal(data, &d); err != nil || d.Data != "task" {
+		t.Fatalf("unexpected delivery %q, %v", data, err)
+	}
+
+	// Nack makes the message visible again before its lease expires
+	w, err := fs.OpenHandle("/jobs/groups/workers/nack", filesystem.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("OpenHandle failed: %v", err)
+	}
+	if _, err := w.Write([]byte(d.ID)); err != nil {
+		t.Fatalf("nack failed: %v", err)
+	}
+	w.Close()
+
+	if again := readDelivery(t, fs, "/jobs/groups/workers/dequeue"); again.ID != d.ID || again.DeliveryCount != 2 {
+		t.Fatalf("expected redelivery after nack, got %+v", again)
+	}
+}
+
+func readStreamMessage(t *testing.T, fs *queueFS, path string) StreamMessage {
+	t.Helper()
+
+	data, err := fs.Read(path, 0, -1)
+	if err != nil && err != io.EOF {
+		t.Fatalf("Read %s failed: %v", path, err)
+	}
+	var msg StreamMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatalf("invalid stream message %q: %v", data, err)
+	}
+	return msg
+}
+
+func TestTopicSubscribers(t *testing.T) {
+	for _, backend := range []string{"memory", "sqlite"} {
+		t.Run(backend, func(t *testing.T) {
+			cfg := map[string]interface{}{"backend": backend, "topic_max_messages": 3}
+			if backend == "sqlite" {
+				cfg["db_path"] = filepath.Join(t.TempDir(), "queue.db")
+			}
+			fs := newTestFS(t, cfg)
+
+			fs.Mkdir("/events", 0755)
+			fs.Write("/events/enqueue", []byte("before"), -1, filesystem.WriteFlagNone)
+			for _, name := range []string{"planner", "reviewer"} {
+				if err := fs.Mkdir("/events/subscribers/"+name, 0755); err != nil {
+					t.Fatalf("Mkdir subscriber failed: %v", err)
+				}
+			}
+			subscribers, err := fs.ReadDir("/events/subscribers")
+			if err != nil || len(subscribers) != 2 || subscribers[0].Name != "planner" {
+				t.Fatalf("unexpected subscribers %+v, %v", subscribers, err)
+			}
+
+			for _, msg := range []string{"one", "two"} {
+				fs.Write("/events/enqueue", []byte(msg), -1, filesystem.WriteFlagNone)
+			}
+
+			// Every subscriber reads every message published after it subscribed
+			for _, name := range []string{"planner", "reviewer"} {
+				dir := "/events/subscribers/" + name
+				if lag := readString(t, fs, dir+"/lag"); lag != "2" {
+					t.Errorf("%s: expected lag 2, got %s", name, lag)
+				}
+				if msg := readStreamMessage(t, fs, dir+"/peek"); msg.Data != "one" || msg.Offset != 0 {
+					t.Errorf("%s: unexpected peek %+v", name, msg)
+				}
+				for i, want := range []string{"one", "two"} {
+					if msg := readStreamMessage(t, fs, dir+"/next"); msg.Data != want || msg.Offset != int64(i) {
+						t.Errorf("%s: expected %s at %d, got %+v", name, want, i, msg)
+					}
+				}
+				if data := readString(t, fs, dir+"/next"); data != "{}" {
+					t.Errorf("%s: expected to be caught up, got %s", name, data)
+				}
+			}
+			// The queue keeps the messages enqueued before the subscribers only
+			if size := readString(t, fs, "/events/size"); size != "1" {
+				t.Errorf("expected queue size 1, got %s", size)
+			}
+
+			// A lagging subscriber skips the messages dropped by retention
+			for _, msg := range []string{"three", "four", "five", "six"} {
+				fs.Write("/events/enqueue", []byte(msg), -1, filesystem.WriteFlagNone)
+			}
+			if lag := readString(t, fs, "/events/subscribers/planner/lag"); lag != "3" {
+				t.Errorf("expected lag 3 within retention, got %s", lag)
+			}
+			msg := readStreamMessage(t, fs, "/events/subscribers/planner/next")
+			if msg.Data != "four" || msg.Offset != 3 || msg.Missed != 1 {
+				t.Errorf("expected to miss one message, got %+v", msg)
+			}
+
+			// Seek replays the retained messages, or skips them
+			if _, err := fs.Write("/events/subscribers/reviewer/seek", []byte("earliest\n"), -1, filesystem.WriteFlagNone); err != nil {
+				t.Fatalf("seek failed: %v", err)
+			}
+			if msg := readStreamMessage(t, fs, "/events/subscribers/reviewer/next"); msg.Data != "four" || msg.Missed != 0 {
+				t.Errorf("expected replay from the oldest retained message, got %+v", msg)
+			}
+			fs.Write("/events/subscribers/reviewer/seek", []byte("latest"), -1, filesystem.WriteFlagNone)
+			if lag := readString(t, fs, "/events/subscribers/reviewer/lag"); lag != "0" {
+				t.Errorf("expected lag 0 after seeking to latest, got %s", lag)
+			}
+			if _, err := fs.Write("/events/subscribers/reviewer/seek", []byte("99"), -1, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrInvalidArgument) {
+				t.Errorf("expected invalid seek past the stream, got %v", err)
+			}
+
+			// Without subscribers, the queue gets its messages back
+			if err := fs.RemoveAll("/events/subscribers"); err != nil {
+				t.Fatalf("RemoveAll failed: %v", err)
+			}
+			if _, err := fs.Stat("/events/subscribers/planner"); !errors.Is(err, filesystem.ErrNotFound) {
+				t.Errorf("expected removed subscriber to be gone, got %v", err)
+			}
+			fs.Write("/events/enqueue", []byte("seven"), -1, filesystem.WriteFlagNone)
+			if size := readString(t, fs, "/events/size"); size != "2" {
+				t.Errorf("expected queue size 2, got %s", size)
+			}
+		})
+	}
+}
+
+func TestTopicRetentionAge(t *testing.T) {
+	fs := newTestFS(t, map[string]interface{}{"topic_max_age": "50ms"})
+
+	fs.Mkdir("/events", 0755)
+	fs.Mkdir("/events/subscribers/watcher", 0755)
+	fs.Write("/events/enqueue", []byte("stale"), -1, filesystem.WriteFlagNone)
+	time.Sleep(80 * time.Millisecond)
+	fs.Write("/events/enqueue", []byte("fresh"), -1, filesystem.WriteFlagNone)
+
+	msg := readStreamMessage(t, fs, "/events/subscribers/watcher/next")
+	if msg.Data != "fresh" || msg.Missed != 1 {
+		t.Errorf("expected the stale message to be dropped, got %+v", msg)
+	}
+
+	if err := NewQueueFSPlugin().Validate(map[string]interface{}{"topic_max_messages": 0}); err == nil {
+		t.Error("expected topic_max_messages 0 to be rejected")
+	}
+}
+
+func TestSQLiteBackendPersistence(t *testing.T) {
+	cfg := map[string]interface{}{
+		"backend": "sqlite",
+		"db_path": filepath.Join(t.TempDir(), "queue.db"),
+	}
+
+	fs := newTestFS(t, cfg)
+	if err := fs.Mkdir("/jobs", 0755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	for _, msg := range []string{"one", "two", "three"} {
+		if _, err := fs.Write("/jobs/enqueue", []byte(msg), -1, filesystem.WriteFlagNone); err != nil {
+			t.Fatalf("enqueue failed: %v", err)
+		}
+	}
+	if d := readDelivery(t, fs, "/jobs/dequeue"); d.Data != "one" {
+		t.Fatalf("expected first message, got %+v", d)
+	}
+	fs.plugin.Shutdown()
+
+	// A new mount on the same database sees the remaining messages
+	fs = newTestFS(t, cfg)
+	if size := readString(t, fs, "/jobs/size"); size != "2" {
+		t.Fatalf("expected 2 messages after restart, got %s", size)
+	}
+	if d := readDelivery(t, fs, "/jobs/peek"); d.Data != "two" {
+		t.Errorf("expected second message, got %+v", d)
+	}
+	if _, err := fs.Stat("/jobs"); err != nil {
+		t.Errorf("Stat failed: %v", err)
+	}
+
+	// Consumer groups work on SQLite too
+	if err := fs.Mkdir("/jobs/groups/workers", 0755); err != nil {
+		t.Fatalf("Mkdir group failed: %v", err)
+	}
+	fs.Write("/jobs/enqueue", []byte("four"), -1, filesystem.WriteFlagNone)
+	d := readDelivery(t, fs, "/jobs/groups/workers/dequeue")
+	if d.Data != "four" || d.DeliveryCount != 1 {
+		t.Fatalf("unexpected delivery %+v", d)
+	}
+	if _, err := fs.Write("/jobs/groups/workers/ack", []byte(d.ID), -1, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("ack failed: %v", err)
+	}
+}
+
+func TestMigrateFromMemory(t *testing.T) {
+	mem := newTestFS(t, map[string]interface{}{})
+	mem.Mkdir("/jobs", 0755)
+	mem.Mkdir("/logs/errors", 0755)
+	mem.Write("/jobs/enqueue", []byte("before groups"), -1, filesystem.WriteFlagNone)
+	mem.Mkdir("/jobs/groups/workers", 0755)
+	mem.Write("/jobs/enqueue", []byte("for workers"), -1, filesystem.WriteFlagNone)
+	want := readDelivery(t, mem, "/jobs/peek")
+
+	snapshot := readString(t, mem, "/"+snapshotFile)
+	path := filepath.Join(t.TempDir(), "queues.json")
+	if err := os.WriteFile(path, []byte(snapshot), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := map[string]interface{}{
+		"backend":      "sqlite",
+		"db_path":      filepath.Join(t.TempDir(), "queue.db"),
+		"migrate_from": path,
+	}
+	fs := newTestFS(t, cfg)
+
+	if got := readDelivery(t, fs, "/jobs/dequeue"); got.ID != want.ID || got.Data != "before groups" {
+		t.Errorf("expected %+v after migration, got %+v", want, got)
+	}
+	if d := readDelivery(t, fs, "/jobs/groups/workers/dequeue"); d.Data != "for workers" {
+		t.Errorf("expected group message after migration, got %+v", d)
+	}
+	if _, err := fs.Stat("/logs/errors"); err != nil {
+		t.Errorf("expected empty nested queue to be migrated: %v", err)
+	}
+	fs.plugin.Shutdown()
+
+	// Restarting with the same configuration does not import again
+	fs = newTestFS(t, cfg)
+	if size := readString(t, fs, "/jobs/size"); size != "0" {
+		t.Errorf("expected no second import, got size %s", size)
+	}
+}
@@ -0,0 +1,358 @@
+package queuefs
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+const (
+	subscribersDir = "subscribers" // Directory of a queue's topic subscribers
+
+	defaultTopicMaxMessages = 1000
+	defaultTopicMaxAge      = 24 * time.Hour
+)
+
+// Meta values for topic subscribers
+const (
+	MetaValueSubscriber = "subscriber" // Subscriber directories
+)
+
+// Control files of a subscriber directory
+var subscriberOperations = map[string]bool{
+	"next": true, // Read the message at the cursor and move past it
+	"peek": true, // Read the message at the cursor
+	"lag":  true, // Retained messages not read yet
+	"seek": true, // Write "earliest", "latest" or an offset to move the cursor
+}
+
+// StreamMessage is a message of a topic as read by a subscriber
+type StreamMessage struct {
+	QueueMessage
+	Offset int64 `json:"offset"`           // Position in the stream; increases by one per message
+	Missed int64 `json:"missed,omitempty"` // Messages dropped by retention before the subscriber read them
+}
+
+// topicRetention limits the messages a topic keeps for its subscribers
+type topicRetention struct {
+	maxMessages int           // Most messages kept
+	maxAge      time.Duration // Age after which messages are dropped, 0 for no limit
+}
+
+// parseTopicRetention reads topic_max_messages and topic_max_age
+func parseTopicRetention(cfg map[string]interface{}) (topicRetention, error) {
+	retention := topicRetention{maxMessages: defaultTopicMaxMessages}
+
+	switch v := cfg["topic_max_messages"].(type) {
+	case nil:
+	case int:
+		retention.maxMessages = v
+	case int64:
+		retention.maxMessages = int(v)
+	case float64:
+		retention.maxMessages = int(v)
+	default:
+		return topicRetention{}, fmt.Errorf("invalid topic_max_messages: must be a number")
+	}
+	if retention.maxMessages <= 0 {
+		return topicRetention{}, fmt.Errorf("invalid topic_max_messages: must be positive")
+	}
+
+	maxAge, err := durationConfig(cfg, "topic_max_age", defaultTopicMaxAge)
+	if err != nil {
+		return topicRetention{}, err
+	}
+	if maxAge < 0 {
+		return topicRetention{}, fmt.Errorf("invalid topic_max_age: must not be negative")
+	}
+	retention.maxAge = maxAge
+	return retention, nil
+}
+
+// cutoff returns the time before which messages have expired, zero if
+// messages do not expire
+func (r topicRetention) cutoff(now time.Time) time.Time {
+	if r.maxAge <= 0 {
+		return time.Time{}
+	}
+	return now.Add(-r.maxAge)
+}
+
+// subscriberPath is a path inside a queue's subscribers directory
+//
+//	/<queue>/subscribers                       - subscribers of <queue>
+//	/<queue>/subscribers/<subscriber>          - a subscriber
+//	/<queue>/subscribers/<subscriber>/<file>   - a control file of the subscriber
+type subscriberPath struct {
+	queueName  string
+	subscriber string // Empty for the subscribers directory itself
+	operation  string // Empty for directories
+}
+
+// parseSubscriberPath checks whether a path is inside a subscribers directory
+// "subscribers" is reserved: it cannot be used as a queue name.
+func parseSubscriberPath(path string) (subscriberPath, bool) {
+	parts := strings.Split(strings.TrimPrefix(filepath.Clean(path), "/"), "/")
+	for i := len(parts) - 1; i >= 1 && i >= len(parts)-3; i-- {
+		if parts[i] != subscribersDir {
+			continue
+		}
+		s := subscriberPath{queueName: strings.Join(parts[:i], "/")}
+		if i+1 < len(parts) {
+			s.subscriber = parts[i+1]
+		}
+		if i+2 < len(parts) {
+			s.operation = parts[i+2]
+		}
+		return s, true
+	}
+	return subscriberPath{}, false
+}
+
+func subscriberFileInfo(operation string, size int64) filesystem.FileInfo {
+	mode := uint32(0444)
+	fileType := MetaValueQueueControl
+	switch operation {
+	case "seek":
+		mode = 0222
+	case "lag":
+		fileType = MetaValueQueueStatus
+	}
+	return filesystem.FileInfo{
+		Name:    operation,
+		Size:    size,
+		Mode:    mode,
+		ModTime: time.Now(),
+		Meta:    filesystem.MetaData{Name: PluginName, Type: fileType},
+	}
+}
+
+// subscriberExists checks that a queue and, if named, its subscriber exist
+// Callers hold the plugin lock.
+func (qfs *queueFS) subscriberExists(s subscriberPath) (bool, error) {
+	exists, err := qfs.plugin.backend.QueueExists(s.queueName)
+	if err != nil || !exists || s.subscriber == "" {
+		return exists, err
+	}
+	subscribers, err := qfs.plugin.backend.ListSubscribers(s.queueName)
+	if err != nil {
+		return false, err
+	}
+	for _, subscriber := range subscribers {
+		if subscriber == s.subscriber {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (qfs *queueFS) subscriberStat(path string, s subscriberPath) (*filesystem.FileInfo, error) {
+	qfs.plugin.mu.RLock()
+	defer qfs.plugin.mu.RUnlock()
+
+	exists, err := qfs.subscriberExists(s)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, filesystem.NewNotFoundError("stat", path)
+	}
+
+	switch {
+	case s.subscriber == "":
+		info := groupDirInfo(subscribersDir, MetaValueSubscriber)
+		return &info, nil
+	case s.operation == "":
+		info := groupDirInfo(s.subscriber, MetaValueSubscriber)
+		return &info, nil
+	case !subscriberOperations[s.operation]:
+		return nil, filesystem.NewNotFoundError("stat", path)
+	}
+
+	var size int64
+	if s.operation == "lag" {
+		data, err := qfs.subscriberLag(s)
+		if err != nil {
+			return nil, err
+		}
+		size = int64(len(data))
+	}
+	info := subscriberFileInfo(s.operation, size)
+	return &info, nil
+}
+
+func (qfs *queueFS) subscriberReadDir(path string, s subscriberPath) ([]filesystem.FileInfo, error) {
+	if s.operation != "" {
+		return nil, filesystem.NewNotDirectoryError(path)
+	}
+
+	qfs.plugin.mu.RLock()
+	defer qfs.plugin.mu.RUnlock()
+
+	exists, err := qfs.subscriberExists(s)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, filesystem.NewNotFoundError("readdir", path)
+	}
+
+	if s.subscriber == "" {
+		subscribers, err := qfs.plugin.backend.ListSubscribers(s.queueName)
+		if err != nil {
+			return nil, err
+		}
+		files := make([]filesystem.FileInfo, 0, len(subscribers))
+		for _, subscriber := range subscribers {
+			files = append(files, groupDirInfo(subscriber, MetaValueSubscriber))
+		}
+		return files, nil
+	}
+
+	files := make([]filesystem.FileInfo, 0, len(subscriberOperations))
+	for _, operation := range []string{"next", "peek", "lag", "seek"} {
+		var size int64
+		if operation == "lag" {
+			data, err := qfs.subscriberLag(s)
+			if err != nil {
+				return nil, err
+			}
+			size = int64(len(data))
+		}
+		files = append(files, subscriberFileInfo(operation, size))
+	}
+	return files, nil
+}
+
+func (qfs *queueFS) subscriberMkdir(path string, s subscriberPath) error {
+	if s.operation != "" || s.subscriber == "" && s.queueName == "" {
+		return filesystem.NewInvalidArgumentError("path", path, "not a subscriber directory")
+	}
+
+	qfs.plugin.mu.Lock()
+	defer qfs.plugin.mu.Unlock()
+
+	exists, err := qfs.plugin.backend.QueueExists(s.queueName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return filesystem.NewNotFoundError("mkdir", "/"+s.queueName)
+	}
+	// The subscribers directory always exists
+	if s.subscriber == "" {
+		return nil
+	}
+	return qfs.plugin.backend.CreateSubscriber(s.queueName, s.subscriber)
+}
+
+func (qfs *queueFS) subscriberRemoveAll(s subscriberPath) error {
+	if s.operation != "" {
+		return fmt.Errorf("cannot remove control files: %s", s.operation)
+	}
+
+	qfs.plugin.mu.Lock()
+	defer qfs.plugin.mu.Unlock()
+
+	subscribers := []string{s.subscriber}
+	if s.subscriber == "" {
+		var err error
+		if subscribers, err = qfs.plugin.backend.ListSubscribers(s.queueName); err != nil {
+			return err
+		}
+	}
+	for _, subscriber := range subscribers {
+		if err := qfs.plugin.backend.RemoveSubscriber(s.queueName, subscriber); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subscriberRead reads a control file of a subscriber
+func (qfs *queueFS) subscriberRead(path string, s subscriberPath) ([]byte, error) {
+	switch s.operation {
+	case "":
+		return nil, fmt.Errorf("is a directory: %s", path)
+	case "next", "peek":
+		return qfs.readStream(s)
+	case "lag":
+		qfs.plugin.mu.RLock()
+		defer qfs.plugin.mu.RUnlock()
+		return qfs.subscriberLag(s)
+	case "seek":
+		return nil, fmt.Errorf("permission denied: %s is write-only", path)
+	default:
+		return nil, filesystem.NewNotFoundError("read", path)
+	}
+}
+
+// subscriberWrite writes a control file of a subscriber
+func (qfs *queueFS) subscriberWrite(path string, s subscriberPath, data []byte) error {
+	switch s.operation {
+	case "seek":
+		return qfs.seek(s, strings.TrimSpace(string(data)))
+	case "":
+		return fmt.Errorf("is a directory: %s", path)
+	default:
+		return fmt.Errorf("cannot write to: %s", path)
+	}
+}
+
+// readStream reads the message at a subscriber's cursor; next moves the
+// cursor past it
+func (qfs *queueFS) readStream(s subscriberPath) ([]byte, error) {
+	qfs.plugin.mu.Lock()
+	defer qfs.plugin.mu.Unlock()
+
+	msg, found, err := qfs.plugin.backend.ReadStream(s.queueName, s.subscriber, s.operation == "next")
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		// Return empty JSON object instead of error when caught up, like dequeue
+		return []byte("{}"), nil
+	}
+	return json.Marshal(msg)
+}
+
+// subscriberLag returns the lag of a subscriber; callers hold the plugin lock
+func (qfs *queueFS) subscriberLag(s subscriberPath) ([]byte, error) {
+	lag, err := qfs.plugin.backend.SubscriberLag(s.queueName, s.subscriber)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(strconv.Itoa(lag)), nil
+}
+
+// seek moves a subscriber's cursor to the oldest retained message
+// ("earliest"), past the latest one ("latest") or to an offset
+func (qfs *queueFS) seek(s subscriberPath, position string) error {
+	qfs.plugin.mu.Lock()
+	defer qfs.plugin.mu.Unlock()
+
+	first, next, err := qfs.plugin.backend.StreamBounds(s.queueName)
+	if err != nil {
+		return err
+	}
+
+	var offset int64
+	switch position {
+	case "earliest":
+		offset = first
+	case "latest", "":
+		offset = next
+	default:
+		offset, err = strconv.ParseInt(position, 10, 64)
+		if err != nil || offset < 0 || offset > next {
+			return filesystem.NewInvalidArgumentError("seek", position,
+				fmt.Sprintf("expected earliest, latest or an offset up to %d", next))
+		}
+	}
+	return qfs.plugin.backend.Seek(s.queueName, s.subscriber, offset)
+}
@@ -0,0 +1,711 @@
+package remotefs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	"github.com/pkg/sftp"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PluginName = "remotefs" // Name of this plugin
+)
+
+// RemoteFS implements FileSystem by proxying a directory on another machine over SFTP
+// The SSH connection is established lazily and re-established once per operation
+// if it was lost, so a restarted remote host does not require a remount.
+type RemoteFS struct {
+	cfg       SSHConfig
+	remoteDir string // Absolute remote directory exposed as the root
+
+	conn *sshConn
+	mu   sync.Mutex // Protects conn
+}
+
+// newRemoteFS connects to the remote host and resolves the exposed directory
+func newRemoteFS(cfg SSHConfig, remoteDir string) (*RemoteFS, error) {
+	r := &RemoteFS{cfg: cfg}
+	c, err := r.client()
+	if err != nil {
+		return nil, err
+	}
+
+	if remoteDir == "" {
+		remoteDir = "."
+	}
+	abs, err := c.RealPath(remoteDir)
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("failed to resolve remote_dir %s: %w", remoteDir, err)
+	}
+	info, err := c.Stat(abs)
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("failed to stat remote_dir %s: %w", abs, err)
+	}
+	if !info.IsDir() {
+		r.Close()
+		return nil, fmt.Errorf("remote_dir is not a directory: %s", abs)
+	}
+	r.remoteDir = abs
+	return r, nil
+}
+
+// client returns the SFTP client, connecting if necessary
+func (r *RemoteFS) client() (*sftp.Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn == nil {
+		conn, err := dial(r.cfg)
+		if err != nil {
+			return nil, err
+		}
+		r.conn = conn
+		log.Infof("[remotefs] Connected to %s@%s:%d", r.cfg.User, r.cfg.Host, r.cfg.Port)
+	}
+	return r.conn.sftp, nil
+}
+
+// dropIfDead discards the connection if it no longer responds
+// Returns true if the connection was dropped and the operation may be retried.
+func (r *RemoteFS) dropIfDead(c *sftp.Client) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn == nil || r.conn.sftp != c {
+		// Another operation already reconnected
+		return true
+	}
+	if r.conn.alive() {
+		return false
+	}
+	log.Warnf("[remotefs] Connection to %s lost, reconnecting", r.cfg.Host)
+	r.conn.Close()
+	r.conn = nil
+	return true
+}
+
+// do runs fn with an SFTP client, retrying once on a fresh connection if the old one died
+func (r *RemoteFS) do(fn func(c *sftp.Client) error) error {
+	c, err := r.client()
+	if err != nil {
+		return err
+	}
+	err = fn(c)
+	if err == nil || serverReplied(err) || !r.dropIfDead(c) {
+		return err
+	}
+
+	if c, err = r.client(); err != nil {
+		return err
+	}
+	return fn(c)
+}
+
+// serverReplied reports whether err is a status returned by the SFTP server,
+// which proves the connection is still alive
+func serverReplied(err error) bool {
+	var status *sftp.StatusError
+	return errors.As(err, &status) ||
+		errors.Is(err, os.ErrNotExist) ||
+		errors.Is(err, os.ErrPermission) ||
+		errors.Is(err, os.ErrExist) ||
+		errors.Is(err, filesystem.ErrNotDirectory)
+}
+
+// Close closes the SSH connection
+func (r *RemoteFS) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn = nil
+	return err
+}
+
+// resolvePath maps a virtual path to the remote path, confined to remoteDir
+func (r *RemoteFS) resolvePath(p string) string {
+	return path.Join(r.remoteDir, path.Clean("/"+p))
+}
+
+// mapError converts SFTP errors into filesystem errors
+func mapError(err error, op, p string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, os.ErrNotExist):
+		return filesystem.NewNotFoundError(op, p)
+	case errors.Is(err, os.ErrPermission):
+		return filesystem.NewPermissionDeniedError(op, p, "denied by remote host")
+	case errors.Is(err, os.ErrExist):
+		return filesystem.NewAlreadyExistsError("file", p)
+	}
+	return fmt.Errorf("%s %s: %w", op, p, err)
+}
+
+func (r *RemoteFS) toFileInfo(info os.FileInfo, remotePath string) filesystem.FileInfo {
+	fi := filesystem.FileInfo{
+		Name:    info.Name(),
+		Size:    info.Size(),
+		Mode:    uint32(info.Mode().Perm()),
+		ModTime: info.ModTime(),
+		IsDir:   info.IsDir(),
+		Meta: filesystem.MetaData{
+			Name: PluginName,
+			Type: "remote",
+		},
+	}
+	if remotePath != "" {
+		fi.Meta.Content = map[string]string{
+			"host":        r.cfg.Host,
+			"remote_path": remotePath,
+		}
+	}
+	return fi
+}
+
+func (r *RemoteFS) Create(p string) error {
+	remotePath := r.resolvePath(p)
+	return mapError(r.do(func(c *sftp.Client) error {
+		f, err := c.OpenFile(remotePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+		if err != nil {
+			return err
+		}
+		return f.Close()
+	}), "create", p)
+}
+
+func (r *RemoteFS) Mkdir(p string, perm uint32) error {
+	remotePath := r.resolvePath(p)
+	return mapError(r.do(func(c *sftp.Client) error {
+		if _, err := c.Stat(remotePath); err == nil {
+			return os.ErrExist
+		}
+		if err := c.Mkdir(remotePath); err != nil {
+			return err
+		}
+		if perm != 0 {
+			return c.Chmod(remotePath, os.FileMode(perm))
+		}
+		return nil
+	}), "mkdir", p)
+}
+
+func (r *RemoteFS) Remove(p string) error {
+	remotePath := r.resolvePath(p)
+	if remotePath == r.remoteDir {
+		return filesystem.NewPermissionDeniedError("remove", p, "cannot remove the mount root")
+	}
+	return mapError(r.do(func(c *sftp.Client) error {
+		info, err := c.Stat(remotePath)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			entries, err := c.ReadDir(remotePath)
+			if err != nil {
+				return err
+			}
+			if len(entries) > 0 {
+				return fmt.Errorf("directory not empty")
+			}
+			return c.RemoveDirectory(remotePath)
+		}
+		return c.Remove(remotePath)
+	}), "remove", p)
+}
+
+func (r *RemoteFS) RemoveAll(p string) error {
+	remotePath := r.resolvePath(p)
+	if remotePath == r.remoteDir {
+		return filesystem.NewPermissionDeniedError("remove", p, "cannot remove the mount root")
+	}
+	return mapError(r.do(func(c *sftp.Client) error {
+		return c.RemoveAll(remotePath)
+	}), "remove", p)
+}
+
+func (r *RemoteFS) Read(p string, offset int64, size int64) ([]byte, error) {
+	remotePath := r.resolvePath(p)
+
+	var data []byte
+	var eof bool
+	err := r.do(func(c *sftp.Client) error {
+		f, err := c.Open(remotePath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return fmt.Errorf("is a directory")
+		}
+
+		fileSize := info.Size()
+		if offset < 0 {
+			offset = 0
+		}
+		if offset >= fileSize {
+			data, eof = []byte{}, true
+			return nil
+		}
+
+		readSize := size
+		if size < 0 || offset+size > fileSize {
+			readSize = fileSize - offset
+		}
+		buf := make([]byte, readSize)
+		n, err := f.ReadAt(buf, offset)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		data = buf[:n]
+		eof = offset+int64(n) >= fileSize
+		return nil
+	})
+	if err != nil {
+		return nil, mapError(err, "read", p)
+	}
+	if eof {
+		return data, io.EOF
+	}
+	return data, nil
+}
+
+func (r *RemoteFS) Write(p string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	remotePath := r.resolvePath(p)
+
+	openFlags := os.O_WRONLY
+	if flags&filesystem.WriteFlagCreate != 0 {
+		openFlags |= os.O_CREATE
+	}
+	if flags&filesystem.WriteFlagExclusive != 0 {
+		openFlags |= os.O_EXCL
+	}
+	if flags&filesystem.WriteFlagTruncate != 0 {
+		openFlags |= os.O_TRUNC
+	}
+	if flags&filesystem.WriteFlagAppend != 0 {
+		openFlags |= os.O_APPEND
+	}
+	// Default behavior: create and truncate
+	if flags == filesystem.WriteFlagNone && offset < 0 {
+		openFlags |= os.O_CREATE | os.O_TRUNC
+	}
+
+	var written int
+	err := r.do(func(c *sftp.Client) error {
+		if info, err := c.Stat(remotePath); err == nil && info.IsDir() {
+			return fmt.Errorf("is a directory")
+		}
+
+		f, err := c.OpenFile(remotePath, openFlags)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if offset >= 0 && flags&filesystem.WriteFlagAppend == 0 {
+			written, err = f.WriteAt(data, offset)
+		} else {
+			if flags&filesystem.WriteFlagAppend != 0 {
+				// Not all SFTP servers honor O_APPEND, so seek explicitly
+				if _, err := f.Seek(0, io.SeekEnd); err != nil {
+					return err
+				}
+			}
+			written, err = f.Write(data)
+		}
+		return err
+	})
+	if err != nil {
+		return 0, mapError(err, "write", p)
+	}
+	return int64(written), nil
+}
+
+func (r *RemoteFS) ReadDir(p string) ([]filesystem.FileInfo, error) {
+	remotePath := r.resolvePath(p)
+
+	var files []filesystem.FileInfo
+	err := r.do(func(c *sftp.Client) error {
+		info, err := c.Stat(remotePath)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return filesystem.NewNotDirectoryError(p)
+		}
+
+		entries, err := c.ReadDir(remotePath)
+		if err != nil {
+			return err
+		}
+		files = make([]filesystem.FileInfo, 0, len(entries))
+		for _, entry := range entries {
+			files = append(files, r.toFileInfo(entry, ""))
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, filesystem.ErrNotDirectory) {
+			return nil, err
+		}
+		return nil, mapError(err, "readdir", p)
+	}
+	return files, nil
+}
+
+func (r *RemoteFS) Stat(p string) (*filesystem.FileInfo, error) {
+	remotePath := r.resolvePath(p)
+
+	var info os.FileInfo
+	err := r.do(func(c *sftp.Client) error {
+		var err error
+		info, err = c.Stat(remotePath)
+		return err
+	})
+	if err != nil {
+		return nil, mapError(err, "stat", p)
+	}
+	fi := r.toFileInfo(info, remotePath)
+	return &fi, nil
+}
+
+func (r *RemoteFS) Rename(oldPath, newPath string) error {
+	oldRemote := r.resolvePath(oldPath)
+	newRemote := r.resolvePath(newPath)
+	return mapError(r.do(func(c *sftp.Client) error {
+		// Plain SFTP rename fails if the target exists; prefer POSIX semantics when available
+		if _, ok := c.HasExtension("posix-rename@openssh.com"); ok {
+			return c.PosixRename(oldRemote, newRemote)
+		}
+		return c.Rename(oldRemote, newRemote)
+	}), "rename", oldPath)
+}
+
+func (r *RemoteFS) Chmod(p string, mode uint32) error {
+	remotePath := r.resolvePath(p)
+	return mapError(r.do(func(c *sftp.Client) error {
+		return c.Chmod(remotePath, os.FileMode(mode))
+	}), "chmod", p)
+}
+
+// Truncate changes the size of the file
+func (r *RemoteFS) Truncate(p string, size int64) error {
+	remotePath := r.resolvePath(p)
+	return mapError(r.do(func(c *sftp.Client) error {
+		return c.Truncate(remotePath, size)
+	}), "truncate", p)
+}
+
+func (r *RemoteFS) Open(p string) (io.ReadCloser, error) {
+	remotePath := r.resolvePath(p)
+
+	var f *sftp.File
+	err := r.do(func(c *sftp.Client) error {
+		var err error
+		f, err = c.Open(remotePath)
+		return err
+	})
+	if err != nil {
+		return nil, mapError(err, "open", p)
+	}
+	return f, nil
+}
+
+func (r *RemoteFS) OpenWrite(p string) (io.WriteCloser, error) {
+	remotePath := r.resolvePath(p)
+
+	var f *sftp.File
+	err := r.do(func(c *sftp.Client) error {
+		var err error
+		f, err = c.OpenFile(remotePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+		return err
+	})
+	if err != nil {
+		return nil, mapError(err, "open", p)
+	}
+	return f, nil
+}
+
+// Symlink creates a symbolic link at linkPath pointing to targetPath
+// targetPath is stored as-is on the remote host.
+func (r *RemoteFS) Symlink(targetPath, linkPath string) error {
+	linkRemote := r.resolvePath(linkPath)
+	return mapError(r.do(func(c *sftp.Client) error {
+		return c.Symlink(targetPath, linkRemote)
+	}), "symlink", linkPath)
+}
+
+// Readlink reads the target of a symbolic link
+func (r *RemoteFS) Readlink(linkPath string) (string, error) {
+	linkRemote := r.resolvePath(linkPath)
+
+	var target string
+	err := r.do(func(c *sftp.Client) error {
+		var err error
+		target, err = c.ReadLink(linkRemote)
+		return err
+	})
+	if err != nil {
+		return "", mapError(err, "readlink", linkPath)
+	}
+	return target, nil
+}
+
+// RemoteFSPlugin wraps RemoteFS as a plugin
+type RemoteFSPlugin struct {
+	fs       *RemoteFS
+	metadata plugin.PluginMetadata
+}
+
+// NewRemoteFSPlugin creates a new RemoteFS plugin
+func NewRemoteFSPlugin() *RemoteFSPlugin {
+	return &RemoteFSPlugin{
+		metadata: plugin.PluginMetadata{
+			Name:        PluginName,
+			Version:     "1.0.0",
+			Description: "Proxy a directory on another machine over SSH/SFTP",
+			Author:      "AGFS Server",
+		},
+	}
+}
+
+func (p *RemoteFSPlugin) Name() string {
+	return p.metadata.Name
+}
+
+func (p *RemoteFSPlugin) Validate(cfg map[string]interface{}) error {
+	allowedKeys := []string{
+		"mount_path", "host", "port", "user", "password", "private_key", "private_key_passphrase",
+		"known_hosts", "host_key_fingerprint", "insecure_ignore_host_key", "remote_dir", "timeout",
+	}
+	if err := config.ValidateOnlyKnownKeys(cfg, allowedKeys); err != nil {
+		return err
+	}
+
+	for _, key := range []string{"host", "user", "password", "private_key", "private_key_passphrase",
+		"known_hosts", "host_key_fingerprint", "remote_dir", "timeout"} {
+		if err := config.ValidateStringType(cfg, key); err != nil {
+			return err
+		}
+	}
+	if err := config.ValidateIntType(cfg, "port"); err != nil {
+		return err
+	}
+	if err := config.ValidateBoolType(cfg, "insecure_ignore_host_key"); err != nil {
+		return err
+	}
+
+	if config.GetStringConfig(cfg, "host", "") == "" {
+		return fmt.Errorf("host is required in configuration")
+	}
+	if config.GetStringConfig(cfg, "user", "") == "" {
+		return fmt.Errorf("user is required in configuration")
+	}
+	if port := config.GetIntConfig(cfg, "port", 22); port <= 0 || port > 65535 {
+		return fmt.Errorf("invalid port: %d", port)
+	}
+	if timeout, err := time.ParseDuration(config.GetStringConfig(cfg, "timeout", "10s")); err != nil || timeout <= 0 {
+		return fmt.Errorf("invalid timeout: must be a positive duration such as \"10s\"")
+	}
+	return nil
+}
+
+func (p *RemoteFSPlugin) Initialize(cfg map[string]interface{}) error {
+	timeout, err := time.ParseDuration(config.GetStringConfig(cfg, "timeout", "10s"))
+	if err != nil {
+		return fmt.Errorf("invalid timeout: %w", err)
+	}
+
+	sshCfg := SSHConfig{
+		Host:                  config.GetStringConfig(cfg, "host", ""),
+		Port:                  config.GetIntConfig(cfg, "port", 22),
+		User:                  config.GetStringConfig(cfg, "user", ""),
+		Password:              config.GetStringConfig(cfg, "password", ""),
+		PrivateKeyPath:        config.GetStringConfig(cfg, "private_key", ""),
+		PrivateKeyPassphrase:  config.GetStringConfig(cfg, "private_key_passphrase", ""),
+		KnownHostsPath:        config.GetStringConfig(cfg, "known_hosts", ""),
+		HostKeyFingerprint:    config.GetStringConfig(cfg, "host_key_fingerprint", ""),
+		InsecureIgnoreHostKey: config.GetBoolConfig(cfg, "insecure_ignore_host_key", false),
+		Timeout:               timeout,
+	}
+
+	fs, err := newRemoteFS(sshCfg, config.GetStringConfig(cfg, "remote_dir", ""))
+	if err != nil {
+		return fmt.Errorf("failed to initialize remotefs: %w", err)
+	}
+	p.fs = fs
+
+	if sshCfg.InsecureIgnoreHostKey {
+		log.Warnf("[remotefs] Host key verification is disabled for %s", sshCfg.Host)
+	}
+	log.Infof("[remotefs] Initialized with %s@%s:%d:%s", sshCfg.User, sshCfg.Host, sshCfg.Port, fs.remoteDir)
+	return nil
+}
+
+func (p *RemoteFSPlugin) GetFileSystem() filesystem.FileSystem {
+	return p.fs
+}
+
+func (p *RemoteFSPlugin) GetReadme() string {
+	return `RemoteFS Plugin - Remote Directory over SSH/SFTP
+
+This plugin exposes a directory on another machine through SSH/SFTP,
+so an AGFS server can federate filesystems on hosts that can't run
+their own AGFS server. Only an SSH server is needed on the remote side.
+
+FEATURES:
+  - Full file operations: read, write, mkdir, rm, mv, chmod, truncate
+  - Symlinks
+  - Password, private key or ssh-agent authentication
+  - Host key verification via known_hosts or a pinned fingerprint
+  - Automatic reconnect when the connection drops
+
+CONFIGURATION:
+  [plugins.remotefs]
+  enabled = true
+  path = "/remote"
+
+    [plugins.remotefs.config]
+    host = "build-box.internal"
+    port = 22
+    user = "agfs"
+    private_key = "~/.ssh/id_ed25519"
+    remote_dir = "/srv/data"
+
+  Host key verification (in order of precedence):
+    insecure_ignore_host_key = true       # Disable verification (testing only)
+    host_key_fingerprint = "SHA256:..."   # Pin the key (ssh-keygen -lf key.pub)
+    known_hosts = "~/.ssh/known_hosts"    # Default
+
+  Authentication: private_key (with optional private_key_passphrase),
+  password, or the ssh-agent at SSH_AUTH_SOCK if neither is set.
+
+USAGE:
+  agfs:/> ls /remote
+  agfs:/> cat /remote/logs/app.log
+  agfs:/> echo "hello" > /remote/notes.txt
+  agfs:/> mv /remote/notes.txt /remote/archive/notes.txt
+
+NOTES:
+  - remote_dir defaults to the remote user's home directory
+  - Paths cannot escape remote_dir
+  - Remote permissions apply: operations run as the SSH user
+`
+}
+
+func (p *RemoteFSPlugin) GetConfigParams() []plugin.ConfigParameter {
+	return []plugin.ConfigParameter{
+		{
+			Name:        "host",
+			Type:        "string",
+			Required:    true,
+			Default:     "",
+			Description: "Remote SSH host",
+		},
+		{
+			Name:        "port",
+			Type:        "int",
+			Required:    false,
+			Default:     "22",
+			Description: "Remote SSH port",
+		},
+		{
+			Name:        "user",
+			Type:        "string",
+			Required:    true,
+			Default:     "",
+			Description: "SSH user",
+		},
+		{
+			Name:        "password",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "SSH password",
+			Secret:      true,
+		},
+		{
+			Name:        "private_key",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Path to the SSH private key",
+			Secret:      true,
+		},
+		{
+			Name:        "private_key_passphrase",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Passphrase of the private key",
+			Secret:      true,
+		},
+		{
+			Name:        "known_hosts",
+			Type:        "string",
+			Required:    false,
+			Default:     "~/.ssh/known_hosts",
+			Description: "known_hosts file used to verify the host key",
+		},
+		{
+			Name:        "host_key_fingerprint",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Pinned SHA256 host key fingerprint (overrides known_hosts)",
+		},
+		{
+			Name:        "insecure_ignore_host_key",
+			Type:        "bool",
+			Required:    false,
+			Default:     "false",
+			Description: "Disable host key verification (testing only)",
+		},
+		{
+			Name:        "remote_dir",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Remote directory to expose (defaults to the user's home)",
+		},
+		{
+			Name:        "timeout",
+			Type:        "string",
+			Required:    false,
+			Default:     "10s",
+			Description: "SSH connection timeout",
+		},
+	}
+}
+
+func (p *RemoteFSPlugin) Shutdown() error {
+	if p.fs != nil {
+		return p.fs.Close()
+	}
+	return nil
+}
+
+// Ensure RemoteFSPlugin implements ServicePlugin
+var _ plugin.ServicePlugin = (*RemoteFSPlugin)(nil)
+var _ filesystem.FileSystem = (*RemoteFS)(nil)
+var _ filesystem.Truncater = (*RemoteFS)(nil)
+var _ filesystem.Symlinker = (*RemoteFS)(nil)
@@ -0,0 +1,277 @@
+package remotefs
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// testSSHServer is an in-process SSH server offering the sftp subsystem
+type testSSHServer struct {
+	host        string
+	port        int
+	fingerprint string
+
+	conns []net.Conn
+	mu    sync.Mutex
+}
+
+func startSSHServer(t *testing.T) *testSSHServer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate host key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("host key signer: %v", err)
+	}
+
+	cfg := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == "agfs" && string(pass) == "secret" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("password rejected for %s", c.User())
+		},
+	}
+	cfg.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	host, portStr, _ := net.SplitHostPort(ln.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	srv := &testSSHServer{host: host, port: port, fingerprint: ssh.FingerprintSHA256(signer.PublicKey())}
+
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			srv.mu.Lock()
+			srv.conns = append(srv.conns, nc)
+			srv.mu.Unlock()
+			go serveSSH(nc, cfg)
+		}
+	}()
+	return srv
+}
+
+// dropConnections closes all client connections, simulating a network failure
+func (s *testSSHServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.conns {
+		c.Close()
+	}
+	s.conns = nil
+}
+
+func serveSSH(nc net.Conn, cfg *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(nc, cfg)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newCh := range chans {
+		if newCh.ChannelType() != "session" {
+			newCh.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		ch, chReqs, err := newCh.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range chReqs {
+				isSFTP := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				req.Reply(isSFTP, nil)
+				if isSFTP {
+					go func() {
+						server, err := sftp.NewServer(ch)
+						if err != nil {
+							return
+						}
+						server.Serve()
+						server.Close()
+					}()
+				}
+			}
+		}()
+	}
+}
+
+func newTestFS(t *testing.T, srv *testSSHServer, remoteDir string) *RemoteFS {
+	t.Helper()
+	p := NewRemoteFSPlugin()
+	cfg := map[string]interface{}{
+		"host":                 srv.host,
+		"port":                 srv.port,
+		"user":                 "agfs",
+		"password":             "secret",
+		"host_key_fingerprint": srv.fingerprint,
+		"remote_dir":           remoteDir,
+	}
+	if err := p.Validate(cfg); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if err := p.Initialize(cfg); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	t.Cleanup(func() { p.Shutdown() })
+	return p.GetFileSystem().(*RemoteFS)
+}
+
+// readIgnoreEOF reads file content, ignoring io.EOF which is expected at end of file
+func readIgnoreEOF(fs filesystem.FileSystem, path string) ([]byte, error) {
+	content, err := fs.Read(path, 0, -1)
+	if err == io.EOF {
+		return content, nil
+	}
+	return content, err
+}
+
+func TestRemoteFSBasicOperations(t *testing.T) {
+	srv := startSSHServer(t)
+	dir := t.TempDir()
+	fs := newTestFS(t, srv, dir)
+
+	if err := fs.Mkdir("/docs", 0755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	if err := fs.Mkdir("/docs", 0755); !errors.Is(err, filesystem.ErrAlreadyExists) {
+		t.Errorf("Mkdir existing: expected ErrAlreadyExists, got %v", err)
+	}
+	if _, err := fs.Write("/docs/a.txt", []byte("hello world"), -1, filesystem.WriteFlagCreate|filesystem.WriteFlagTruncate); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if _, err := fs.Write("/docs/a.txt", []byte("!"), -1, filesystem.WriteFlagAppend); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	// Changes land on the remote filesystem
+	local, err := os.ReadFile(filepath.Join(dir, "docs", "a.txt"))
+	if err != nil || string(local) != "hello world!" {
+		t.Errorf("remote content = %q, %v", local, err)
+	}
+
+	data, err := fs.Read("/docs/a.txt", 6, 5)
+	if err != nil || string(data) != "world" {
+		t.Errorf("range read = %q, %v", data, err)
+	}
+	data, err = readIgnoreEOF(fs, "/docs/a.txt")
+	if err != nil || string(data) != "hello world!" {
+		t.Errorf("full read = %q, %v", data, err)
+	}
+
+	infos, err := fs.ReadDir("/docs")
+	if err != nil || len(infos) != 1 || infos[0].Name != "a.txt" || infos[0].Size != 12 {
+		t.Errorf("ReadDir = %+v, %v", infos, err)
+	}
+	if _, err := fs.ReadDir("/docs/a.txt"); !errors.Is(err, filesystem.ErrNotDirectory) {
+		t.Errorf("ReadDir on file: expected ErrNotDirectory, got %v", err)
+	}
+
+	if err := fs.Rename("/docs/a.txt", "/docs/b.txt"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if err := fs.Truncate("/docs/b.txt", 5); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+	if err := fs.Chmod("/docs/b.txt", 0600); err != nil {
+		t.Fatalf("Chmod failed: %v", err)
+	}
+	info, err := fs.Stat("/docs/b.txt")
+	if err != nil || info.Size != 5 || info.Mode != 0600 {
+		t.Errorf("Stat = %+v, %v", info, err)
+	}
+
+	if err := fs.Remove("/docs"); err == nil {
+		t.Error("Remove of non-empty directory should fail")
+	}
+	if err := fs.RemoveAll("/docs"); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	if _, err := fs.Stat("/docs"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("Stat removed: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestRemoteFSConfinedToRemoteDir(t *testing.T) {
+	srv := startSSHServer(t)
+	parent := t.TempDir()
+	os.WriteFile(filepath.Join(parent, "outside.txt"), []byte("secret"), 0644)
+	exposed := filepath.Join(parent, "exposed")
+	os.Mkdir(exposed, 0755)
+
+	fs := newTestFS(t, srv, exposed)
+	if _, err := fs.Read("/../outside.txt", 0, -1); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("escaping remote_dir: expected ErrNotFound, got %v", err)
+	}
+	if err := fs.RemoveAll("/"); !errors.Is(err, filesystem.ErrPermissionDenied) {
+		t.Errorf("removing root: expected ErrPermissionDenied, got %v", err)
+	}
+}
+
+func TestRemoteFSReconnects(t *testing.T) {
+	srv := startSSHServer(t)
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "f.txt"), []byte("data"), 0644)
+	fs := newTestFS(t, srv, dir)
+
+	srv.dropConnections()
+
+	data, err := readIgnoreEOF(fs, "/f.txt")
+	if err != nil || string(data) != "data" {
+		t.Errorf("read after reconnect = %q, %v", data, err)
+	}
+}
+
+func TestRemoteFSHostKeyAndAuth(t *testing.T) {
+	srv := startSSHServer(t)
+	base := map[string]interface{}{
+		"host": srv.host, "port": srv.port, "user": "agfs", "remote_dir": t.TempDir(),
+	}
+
+	cfg := map[string]interface{}{"password": "secret", "host_key_fingerprint": "SHA256:wrong"}
+	for k, v := range base {
+		cfg[k] = v
+	}
+	if err := NewRemoteFSPlugin().Initialize(cfg); err == nil {
+		t.Error("expected host key mismatch error")
+	}
+
+	cfg = map[string]interface{}{"password": "wrong", "insecure_ignore_host_key": true}
+	for k, v := range base {
+		cfg[k] = v
+	}
+	if err := NewRemoteFSPlugin().Initialize(cfg); err == nil {
+		t.Error("expected authentication error")
+	}
+
+	p := NewRemoteFSPlugin()
+	if err := p.Validate(map[string]interface{}{"user": "x"}); err == nil {
+		t.Error("expected error for missing host")
+	}
+	if err := p.Validate(map[string]interface{}{"host": "h", "user": "x", "port": 70000}); err == nil {
+		t.Error("expected error for invalid port")
+	}
+}
@@ -0,0 +1,157 @@
+package remotefs
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSHConfig holds the SSH connection settings
+type SSHConfig struct {
+	Host                  string
+	Port                  int
+	User                  string
+	Password              string
+	PrivateKeyPath        string
+	PrivateKeyPassphrase  string
+	KnownHostsPath        string
+	HostKeyFingerprint    string // SHA256 fingerprint (as printed by ssh-keygen -lf) to pin instead of known_hosts
+	InsecureIgnoreHostKey bool
+	Timeout               time.Duration
+}
+
+// sshConn is an SSH connection with an SFTP session on top of it
+type sshConn struct {
+	ssh  *ssh.Client
+	sftp *sftp.Client
+}
+
+func (c *sshConn) Close() error {
+	sftpErr := c.sftp.Close()
+	sshErr := c.ssh.Close()
+	return errors.Join(sftpErr, sshErr)
+}
+
+// alive reports whether the underlying SSH connection still responds
+func (c *sshConn) alive() bool {
+	_, _, err := c.ssh.SendRequest("keepalive@openssh.com", true, nil)
+	return err == nil
+}
+
+// authMethods builds the SSH auth methods from the config
+// Falls back to the ssh-agent at SSH_AUTH_SOCK if neither a password nor a key is configured.
+func authMethods(cfg SSHConfig) ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+
+	if cfg.PrivateKeyPath != "" {
+		keyPath := expandHome(cfg.PrivateKeyPath)
+		pem, err := os.ReadFile(keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private key: %w", err)
+		}
+		var signer ssh.Signer
+		if cfg.PrivateKeyPassphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(pem, []byte(cfg.PrivateKeyPassphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(pem)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key %s: %w", keyPath, err)
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+
+	if cfg.Password != "" {
+		methods = append(methods, ssh.Password(cfg.Password))
+	}
+
+	if len(methods) == 0 {
+		sock := os.Getenv("SSH_AUTH_SOCK")
+		if sock == "" {
+			return nil, fmt.Errorf("no authentication configured: set password, private_key or SSH_AUTH_SOCK")
+		}
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to ssh-agent: %w", err)
+		}
+		methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+	return methods, nil
+}
+
+// hostKeyCallback builds the host key verification from the config
+func hostKeyCallback(cfg SSHConfig) (ssh.HostKeyCallback, error) {
+	if cfg.InsecureIgnoreHostKey {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	if cfg.HostKeyFingerprint != "" {
+		want := cfg.HostKeyFingerprint
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			got := ssh.FingerprintSHA256(key)
+			if subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+				return fmt.Errorf("host key mismatch for %s: got %s, want %s", hostname, got, want)
+			}
+			return nil
+		}, nil
+	}
+
+	path := cfg.KnownHostsPath
+	if path == "" {
+		path = "~/.ssh/known_hosts"
+	}
+	callback, err := knownhosts.New(expandHome(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known_hosts (set host_key_fingerprint or insecure_ignore_host_key to skip): %w", err)
+	}
+	return callback, nil
+}
+
+// dial opens an SSH connection and starts an SFTP session
+func dial(cfg SSHConfig) (*sshConn, error) {
+	auth, err := authMethods(cfg)
+	if err != nil {
+		return nil, err
+	}
+	hostKey, err := hostKeyCallback(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	client, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            cfg.User,
+		Auth:            auth,
+		HostKeyCallback: hostKey,
+		Timeout:         cfg.Timeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+
+	sftpClient, err := sftp.NewClient(client)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to start SFTP session on %s: %w", addr, err)
+	}
+	return &sshConn{ssh: client, sftp: sftpClient}, nil
+}
+
+func expandHome(p string) string {
+	if len(p) >= 2 && p[:2] == "~/" {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, p[2:])
+		}
+	}
+	return p
+}
@@ -0,0 +1,148 @@
+package s3fs
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+// Reads as of a past time are answered from the versions of the objects,
+// which needs versioning enabled on the bucket. Directories are not
+// versioned: subdirectories are listed as they are now.
+
+// versionAt returns the version of a file that was current at asOf, nil if
+// the file did not exist then or was deleted; versions are those of the file
+func versionAt(versions []ObjectVersion, asOf time.Time) *ObjectVersion {
+	var current *ObjectVersion
+	for i := range versions {
+		v := &versions[i]
+		if v.LastModified.After(asOf) {
+			continue
+		}
+		if current == nil || v.LastModified.After(current.LastModified) {
+			current = v
+		}
+	}
+	if current == nil || current.IsDeleteMarker {
+		return nil
+	}
+	return current
+}
+
+// fileVersionPath returns the versions directory of a file from its
+// normalized S3 path
+func fileVersionPath(key string) versionPath {
+	if i := strings.LastIndex(key, "/"); i >= 0 {
+		return versionPath{dir: key[:i], name: key[i+1:]}
+	}
+	return versionPath{name: key}
+}
+
+// checkAsOfPath rejects the virtual files, which have no history
+func checkAsOfPath(key string) error {
+	if _, ok := parseVersionPath(key); ok || key == presignCtl {
+		return filesystem.NewInvalidArgumentError("path", "/"+key, "has no history")
+	}
+	return nil
+}
+
+// fileAt returns the version of a file that was current at asOf
+func (fs *S3FS) fileAt(op string, v versionPath, asOf time.Time) (*ObjectVersion, error) {
+	versions, err := fs.client.ListObjectVersions(context.Background(), v.dir, v.name)
+	if err != nil {
+		return nil, err
+	}
+	version := versionAt(versions, asOf)
+	if version == nil {
+		return nil, filesystem.NewNotFoundError(op, "/"+v.file()+"@"+asOf.Format(time.RFC3339))
+	}
+	return version, nil
+}
+
+// asOfInfo returns the info of a file from the version current at a time
+func asOfInfo(name string, v ObjectVersion) filesystem.FileInfo {
+	info := versionFileInfo(v)
+	info.Name = name
+	info.Meta.Type = "s3"
+	return info
+}
+
+// ReadAsOf reads the version of a file that was current at asOf
+func (fs *S3FS) ReadAsOf(filePath string, asOf time.Time, offset, size int64) ([]byte, error) {
+	key := filesystem.NormalizeS3Key(filePath)
+	if err := checkAsOfPath(key); err != nil {
+		return nil, err
+	}
+	v := fileVersionPath(key)
+	version, err := fs.fileAt("read", v, asOf)
+	if err != nil {
+		return nil, err
+	}
+	v.versionID = version.VersionID
+	return fs.versionRead(v, offset, size)
+}
+
+// StatAsOf returns the info of the version of a file that was current at
+// asOf; directories are returned as they are now
+func (fs *S3FS) StatAsOf(filePath string, asOf time.Time) (*filesystem.FileInfo, error) {
+	key := filesystem.NormalizeS3Key(filePath)
+	if key == "" {
+		return fs.Stat(filePath)
+	}
+	if err := checkAsOfPath(key); err != nil {
+		return nil, err
+	}
+	v := fileVersionPath(key)
+	version, err := fs.fileAt("stat", v, asOf)
+	if err == nil {
+		info := asOfInfo(v.name, *version)
+		return &info, nil
+	}
+	if !errors.Is(err, filesystem.ErrNotFound) {
+		return nil, err
+	}
+	if info, statErr := fs.Stat(filePath); statErr == nil && info.IsDir {
+		return info, nil
+	}
+	return nil, err
+}
+
+// ReadDirAsOf lists the files a directory had at asOf, and its current
+// subdirectories
+func (fs *S3FS) ReadDirAsOf(dirPath string, asOf time.Time) ([]filesystem.FileInfo, error) {
+	key := filesystem.NormalizeS3Key(dirPath)
+	if err := checkAsOfPath(key); err != nil {
+		return nil, err
+	}
+	all, err := fs.client.ListObjectVersions(context.Background(), key, "")
+	if err != nil {
+		return nil, err
+	}
+	byKey := make(map[string][]ObjectVersion)
+	for _, v := range all {
+		byKey[v.Key] = append(byKey[v.Key], v)
+	}
+
+	var files []filesystem.FileInfo
+	for name, versions := range byKey {
+		if version := versionAt(versions, asOf); version != nil {
+			files = append(files, asOfInfo(name, *version))
+		}
+	}
+	current, err := fs.ReadDir(dirPath)
+	if err == nil {
+		for _, info := range current {
+			if info.IsDir {
+				files = append(files, info)
+			}
+		}
+	} else if len(files) == 0 {
+		return nil, filesystem.NewNotFoundError("readdir", "/"+key+"@"+asOf.Format(time.RFC3339))
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
@@ -12,6 +12,20 @@ import (
 type DirCacheEntry struct {
 	Files   []filesystem.FileInfo
 	ModTime time.Time
+	index   map[string]int // File name to position in Files
+}
+
+func newDirCacheEntry(files []filesystem.FileInfo) *DirCacheEntry {
+	entry := &DirCacheEntry{Files: files, ModTime: time.Now()}
+	entry.reindex()
+	return entry
+}
+
+func (e *DirCacheEntry) reindex() {
+	e.index = make(map[string]int, len(e.Files))
+	for i, f := range e.Files {
+		e.index[f.Name] = i
+	}
 }
 
 // StatCacheEntry represents a cached stat result
@@ -103,21 +117,14 @@ func (c *ListDirCache) Put(path string, files []filesystem.FileInfo) {
 	// Check if entry already exists
 	if elem, ok := c.cache[path]; ok {
 		item := elem.Value.(*dirCacheItem)
-		item.entry.Files = files
-		item.entry.ModTime = time.Now()
+		item.entry = newDirCacheEntry(files)
 		c.lruList.MoveToFront(elem)
 		return
 	}
 
-	// Create new entry
-	entry := &DirCacheEntry{
-		Files:   files,
-		ModTime: time.Now(),
-	}
-
 	item := &dirCacheItem{
 		path:  path,
-		entry: entry,
+		entry: newDirCacheEntry(files),
 	}
 
 	elem := c.lruList.PushFront(item)
@@ -134,6 +141,94 @@ func (c *ListDirCache) Put(path string, files []filesystem.FileInfo) {
 	}
 }
 
+// fresh returns the unexpired listing of a directory; callers hold c.mu
+func (c *ListDirCache) fresh(dir string) *DirCacheEntry {
+	elem, ok := c.cache[dir]
+	if !ok {
+		return nil
+	}
+	entry := elem.Value.(*dirCacheItem).entry
+	if time.Since(entry.ModTime) > c.ttl {
+		return nil
+	}
+	return entry
+}
+
+// Lookup finds an entry in the cached listing of its directory
+// listed reports whether the directory listing is cached; if it is and info
+// is nil, the entry does not exist.
+func (c *ListDirCache) Lookup(dir, name string) (info *filesystem.FileInfo, listed bool) {
+	if !c.enabled {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.fresh(dir)
+	if entry == nil {
+		return nil, false
+	}
+	c.hitCount++
+	if i, ok := entry.index[name]; ok {
+		copied := entry.Files[i]
+		return &copied, true
+	}
+	return nil, true
+}
+
+// Upsert adds or replaces an entry in the cached listing of its directory
+// This is a write-through update after a local mutation: it keeps large
+// listings cached instead of dropping them. Listings that are not cached are
+// left alone, and the entry's expiry time does not change.
+func (c *ListDirCache) Upsert(dir string, info filesystem.FileInfo) {
+	if !c.enabled {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.fresh(dir)
+	if entry == nil {
+		return
+	}
+	// Copy on write: Get hands out copies, but keep the slice immutable anyway
+	files := make([]filesystem.FileInfo, len(entry.Files), len(entry.Files)+1)
+	copy(files, entry.Files)
+	if i, ok := entry.index[info.Name]; ok {
+		files[i] = info
+	} else {
+		entry.index[info.Name] = len(files)
+		files = append(files, info)
+	}
+	entry.Files = files
+}
+
+// Delete removes an entry from the cached listing of its directory
+func (c *ListDirCache) Delete(dir, name string) {
+	if !c.enabled {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.fresh(dir)
+	if entry == nil {
+		return
+	}
+	i, ok := entry.index[name]
+	if !ok {
+		return
+	}
+	files := make([]filesystem.FileInfo, 0, len(entry.Files)-1)
+	files = append(files, entry.Files[:i]...)
+	files = append(files, entry.Files[i+1:]...)
+	entry.Files = files
+	entry.reindex()
+}
+
 // Invalidate removes a specific path from the cache
 func (c *ListDirCache) Invalidate(path string) {
 	if !c.enabled {
@@ -5,7 +5,9 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/url"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -233,17 +235,113 @@ func (c *S3Client) GetObjectRange(ctx context.Context, path string, offset, size
 	return data, nil
 }
 
-// PutObject uploads an object to S3
-func (c *S3Client) PutObject(ctx context.Context, path string, data []byte) error {
+// PutObject uploads an object to S3 and returns its ETag
+func (c *S3Client) PutObject(ctx context.Context, path string, data []byte) (string, error) {
 	key := c.buildKey(path)
 
-	_, err := c.client.PutObject(ctx, &s3.PutObjectInput{
+	result, err := c.client.PutObject(ctx, &s3.PutObjectInput{
 		Bucket: aws.String(c.bucket),
 		Key:    aws.String(key),
 		Body:   bytes.NewReader(data),
 	})
 	if err != nil {
-		return fmt.Errorf("failed to put object %s: %w", key, err)
+		return "", fmt.Errorf("failed to put object %s: %w", key, err)
+	}
+
+	return aws.ToString(result.ETag), nil
+}
+
+// GetObjectStreamFrom returns a stream of an S3 object starting at offset
+// The caller is responsible for closing the returned ReadCloser
+func (c *S3Client) GetObjectStreamFrom(ctx context.Context, path string, offset int64) (io.ReadCloser, error) {
+	key := c.buildKey(path)
+
+	result, err := c.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-", offset)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object range %s: %w", key, err)
+	}
+
+	return result.Body, nil
+}
+
+// CreateMultipartUpload starts a multipart upload and returns its upload ID
+func (c *S3Client) CreateMultipartUpload(ctx context.Context, path string) (string, error) {
+	key := c.buildKey(path)
+
+	result, err := c.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create multipart upload %s: %w", key, err)
+	}
+
+	return aws.ToString(result.UploadId), nil
+}
+
+// UploadPart uploads one part of a multipart upload and returns its ETag
+func (c *S3Client) UploadPart(ctx context.Context, path, uploadID string, partNumber int32, data []byte) (string, error) {
+	key := c.buildKey(path)
+
+	result, err := c.client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(c.bucket),
+		Key:        aws.String(key),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int32(partNumber),
+		Body:       bytes.NewReader(data),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload part %d of %s: %w", partNumber, key, err)
+	}
+
+	return aws.ToString(result.ETag), nil
+}
+
+// CompleteMultipartUpload assembles the uploaded parts into the final object
+// and returns its ETag
+// parts maps part numbers to their ETags
+func (c *S3Client) CompleteMultipartUpload(ctx context.Context, path, uploadID string, parts map[int32]string) (string, error) {
+	key := c.buildKey(path)
+
+	completed := make([]types.CompletedPart, 0, len(parts))
+	for number, etag := range parts {
+		completed = append(completed, types.CompletedPart{
+			PartNumber: aws.Int32(number),
+			ETag:       aws.String(etag),
+		})
+	}
+	sort.Slice(completed, func(i, j int) bool {
+		return *completed[i].PartNumber < *completed[j].PartNumber
+	})
+
+	result, err := c.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(c.bucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to complete multipart upload %s: %w", key, err)
+	}
+
+	return aws.ToString(result.ETag), nil
+}
+
+// AbortMultipartUpload discards a multipart upload and its uploaded parts
+func (c *S3Client) AbortMultipartUpload(ctx context.Context, path, uploadID string) error {
+	key := c.buildKey(path)
+
+	_, err := c.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(c.bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to abort multipart upload %s: %w", key, err)
 	}
 
 	return nil
@@ -285,6 +383,8 @@ type S3Object struct {
 	Size         int64
 	LastModified time.Time
 	IsDir        bool
+	ETag         string
+	StorageClass string
 }
 
 // ListObjects lists objects with a given prefix
@@ -350,6 +450,8 @@ func (c *S3Client) ListObjects(ctx context.Context, path string) ([]S3Object, er
 				Size:         aws.ToInt64(obj.Size),
 				LastModified: aws.ToTime(obj.LastModified),
 				IsDir:        false,
+				ETag:         aws.ToString(obj.ETag),
+				StorageClass: string(obj.StorageClass),
 			})
 		}
 	}
@@ -357,6 +459,160 @@ func (c *S3Client) ListObjects(ctx context.Context, path string) ([]S3Object, er
 	return objects, nil
 }
 
+// ObjectVersion describes one version of an S3 object
+type ObjectVersion struct {
+	Key            string // Relative to the listed directory
+	VersionID      string
+	Size           int64
+	LastModified   time.Time
+	ETag           string
+	StorageClass   string
+	IsLatest       bool
+	IsDeleteMarker bool
+}
+
+// ListObjectVersions lists the versions and delete markers of the immediate
+// children of a directory
+// Versions are returned in S3 order, newest first for each key, with delete
+// markers after them. If name is not empty, only versions of that child are
+// returned.
+func (c *S3Client) ListObjectVersions(ctx context.Context, dir, name string) ([]ObjectVersion, error) {
+	prefix := c.buildKey(dir)
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	input := &s3.ListObjectVersionsInput{
+		Bucket:    aws.String(c.bucket),
+		Prefix:    aws.String(prefix + name),
+		Delimiter: aws.String("/"),
+	}
+
+	var versions []ObjectVersion
+	for {
+		page, err := c.client.ListObjectVersions(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list object versions: %w", err)
+		}
+
+		for _, v := range page.Versions {
+			key := strings.TrimPrefix(aws.ToString(v.Key), prefix)
+			if key == "" || strings.HasSuffix(key, "/") || (name != "" && key != name) {
+				continue
+			}
+			versions = append(versions, ObjectVersion{
+				Key:          key,
+				VersionID:    aws.ToString(v.VersionId),
+				Size:         aws.ToInt64(v.Size),
+				LastModified: aws.ToTime(v.LastModified),
+				ETag:         aws.ToString(v.ETag),
+				StorageClass: string(v.StorageClass),
+				IsLatest:     aws.ToBool(v.IsLatest),
+			})
+		}
+		for _, m := range page.DeleteMarkers {
+			key := strings.TrimPrefix(aws.ToString(m.Key), prefix)
+			if key == "" || strings.HasSuffix(key, "/") || (name != "" && key != name) {
+				continue
+			}
+			versions = append(versions, ObjectVersion{
+				Key:            key,
+				VersionID:      aws.ToString(m.VersionId),
+				LastModified:   aws.ToTime(m.LastModified),
+				IsLatest:       aws.ToBool(m.IsLatest),
+				IsDeleteMarker: true,
+			})
+		}
+
+		if !aws.ToBool(page.IsTruncated) {
+			break
+		}
+		input.KeyMarker = page.NextKeyMarker
+		input.VersionIdMarker = page.NextVersionIdMarker
+	}
+
+	return versions, nil
+}
+
+// GetObjectVersion retrieves a byte range of a specific object version
+// size: number of bytes to read (-1 for all remaining bytes from offset)
+func (c *S3Client) GetObjectVersion(ctx context.Context, path, versionID string, offset, size int64) ([]byte, error) {
+	key := c.buildKey(path)
+
+	input := &s3.GetObjectInput{
+		Bucket:    aws.String(c.bucket),
+		Key:       aws.String(key),
+		VersionId: aws.String(versionID),
+	}
+	if offset > 0 || size > 0 {
+		if size < 0 {
+			input.Range = aws.String(fmt.Sprintf("bytes=%d-", offset))
+		} else {
+			input.Range = aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+size-1))
+		}
+	}
+
+	result, err := c.client.GetObject(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object %s version %s: %w", key, versionID, err)
+	}
+	defer result.Body.Close()
+
+	data, err := io.ReadAll(result.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object body: %w", err)
+	}
+
+	return data, nil
+}
+
+// RestoreObjectVersion makes a copy of an old version the current version
+// S3 copies objects of up to 5GB in a single request.
+func (c *S3Client) RestoreObjectVersion(ctx context.Context, path, versionID string) error {
+	key := c.buildKey(path)
+
+	_, err := c.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(c.bucket),
+		Key:        aws.String(key),
+		CopySource: aws.String(url.PathEscape(c.bucket+"/"+key) + "?versionId=" + url.QueryEscape(versionID)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to restore %s version %s: %w", key, versionID, err)
+	}
+
+	return nil
+}
+
+// PresignGetObject returns a URL that downloads an object without credentials
+func (c *S3Client) PresignGetObject(ctx context.Context, path string, expires time.Duration) (string, error) {
+	key := c.buildKey(path)
+
+	req, err := s3.NewPresignClient(c.client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", fmt.Errorf("failed to presign GET for %s: %w", key, err)
+	}
+
+	return req.URL, nil
+}
+
+// PresignPutObject returns a URL that uploads an object without credentials
+func (c *S3Client) PresignPutObject(ctx context.Context, path string, expires time.Duration) (string, error) {
+	key := c.buildKey(path)
+
+	req, err := s3.NewPresignClient(c.client).PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", fmt.Errorf("failed to presign PUT for %s: %w", key, err)
+	}
+
+	return req.URL, nil
+}
+
 // CreateDirectory creates a directory marker in S3
 // S3 doesn't have real directories, but we create empty objects ending with "/"
 func (c *S3Client) CreateDirectory(ctx context.Context, path string) error {
@@ -0,0 +1,379 @@
+package s3fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	log "github.com/sirupsen/logrus"
+)
+
+// ============================================================================
+// HandleFS Implementation
+// ============================================================================
+
+// s3FileHandle is an open S3 object
+// Reads are served from a ranged GET that is kept open while reading
+// sequentially. Writes go through an s3fsWriter: they must be sequential,
+// large files are uploaded in parts while writing continues, and the object
+// is stored on Close. The writer holds the upload state, so if a part or the
+// final commit fails, Sync and Close can be retried without losing the parts
+// that were already uploaded.
+type s3FileHandle struct {
+	id    int64
+	fs    *S3FS
+	path  string
+	flags filesystem.OpenFlag
+	pos   int64
+
+	w *s3fsWriter // nil for read-only handles
+
+	// Open body for sequential reads
+	body    io.ReadCloser
+	bodyPos int64
+
+	closed bool
+	mu     sync.Mutex
+}
+
+func (h *s3FileHandle) ID() int64 {
+	return h.id
+}
+
+func (h *s3FileHandle) Path() string {
+	return h.path
+}
+
+func (h *s3FileHandle) Flags() filesystem.OpenFlag {
+	return h.flags
+}
+
+// Read reads from the current position, streaming the object
+func (h *s3FileHandle) Read(buf []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return 0, fmt.Errorf("handle is closed")
+	}
+	if h.flags&filesystem.O_WRONLY != 0 {
+		return 0, fmt.Errorf("handle not open for reading")
+	}
+
+	if h.body == nil || h.bodyPos != h.pos {
+		h.closeBody()
+		body, err := h.fs.client.GetObjectStreamFrom(context.Background(), filesystem.NormalizeS3Key(h.path), h.pos)
+		if err != nil {
+			if isInvalidRange(err) {
+				return 0, io.EOF
+			}
+			if isNotFound(err) {
+				return 0, filesystem.ErrNotFound
+			}
+			return 0, err
+		}
+		h.body = body
+		h.bodyPos = h.pos
+	}
+
+	n, err := h.body.Read(buf)
+	h.pos += int64(n)
+	h.bodyPos += int64(n)
+	if err != nil && err != io.EOF {
+		h.closeBody()
+	}
+	return n, err
+}
+
+// ReadAt reads from the specified offset with a ranged GET
+func (h *s3FileHandle) ReadAt(buf []byte, offset int64) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return 0, fmt.Errorf("handle is closed")
+	}
+	if h.flags&filesystem.O_WRONLY != 0 {
+		return 0, fmt.Errorf("handle not open for reading")
+	}
+	if len(buf) == 0 {
+		return 0, nil
+	}
+
+	data, err := h.fs.Read(h.path, offset, int64(len(buf)))
+	if err != nil {
+		if isInvalidRange(err) {
+			return 0, io.EOF
+		}
+		return 0, err
+	}
+
+	n := copy(buf, data)
+	if n < len(buf) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Write writes at the current position
+func (h *s3FileHandle) Write(data []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n, err := h.writeAt(data, h.pos)
+	h.pos += int64(n)
+	return n, err
+}
+
+// WriteAt writes at the specified offset, which must be the end of the data
+// written so far
+func (h *s3FileHandle) WriteAt(data []byte, offset int64) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.writeAt(data, offset)
+}
+
+func (h *s3FileHandle) writeAt(data []byte, offset int64) (int, error) {
+	if h.closed {
+		return 0, fmt.Errorf("handle is closed")
+	}
+	if h.w == nil {
+		return 0, fmt.Errorf("handle not open for writing")
+	}
+	if h.flags&filesystem.O_APPEND != 0 {
+		offset = h.w.size
+	}
+	if offset != h.w.size {
+		return 0, fmt.Errorf("s3fs only supports sequential writes: got offset %d, expected %d", offset, h.w.size)
+	}
+	return h.w.Write(data)
+}
+
+// Seek moves the read/write position
+func (h *s3FileHandle) Seek(offset int64, whence int) (int64, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return 0, fmt.Errorf("handle is closed")
+	}
+
+	var base int64
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		base = h.pos
+	case io.SeekEnd:
+		if h.w != nil {
+			base = h.w.size
+		} else {
+			info, err := h.fs.Stat(h.path)
+			if err != nil {
+				return 0, err
+			}
+			base = info.Size
+		}
+	default:
+		return 0, fmt.Errorf("invalid whence: %d", whence)
+	}
+
+	if base+offset < 0 {
+		return 0, fmt.Errorf("negative position")
+	}
+	h.pos = base + offset
+	return h.pos, nil
+}
+
+// Sync uploads buffered data where possible and retries failed parts
+func (h *s3FileHandle) Sync() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return fmt.Errorf("handle is closed")
+	}
+	if h.w == nil {
+		return nil
+	}
+	return h.w.Sync()
+}
+
+// Close stores the written object and releases the handle
+// If storing fails the handle stays open, so Close can be retried.
+func (h *s3FileHandle) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil
+	}
+	if h.w != nil {
+		if err := h.w.commit(); err != nil {
+			return err
+		}
+	}
+
+	h.closeBody()
+	h.closed = true
+
+	h.fs.handlesMu.Lock()
+	delete(h.fs.handles, h.id)
+	h.fs.handlesMu.Unlock()
+	return nil
+}
+
+// Stat returns file information, including data not yet uploaded
+func (h *s3FileHandle) Stat() (*filesystem.FileInfo, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, fmt.Errorf("handle is closed")
+	}
+
+	info, err := h.fs.Stat(h.path)
+	if err != nil {
+		return nil, err
+	}
+	if h.w != nil && h.w.dirty {
+		copied := *info
+		copied.Size = h.w.size
+		info = &copied
+	}
+	return info, nil
+}
+
+func (h *s3FileHandle) closeBody() {
+	if h.body != nil {
+		h.body.Close()
+		h.body = nil
+	}
+}
+
+// OpenHandle opens a file and returns a handle for stateful operations
+// Writing replaces the whole object: writes must start at offset 0 (or at
+// the end of the object with O_APPEND) and continue sequentially.
+func (fs *S3FS) OpenHandle(path string, flags filesystem.OpenFlag, mode uint32) (filesystem.FileHandle, error) {
+	// Versions and the control file are served by the plain file operations
+	key := filesystem.NormalizeS3Key(path)
+	if _, ok := parseVersionPath(key); ok || key == presignCtl {
+		return nil, filesystem.NewNotSupportedError("open", path)
+	}
+
+	info, err := fs.Stat(path)
+	exists := err == nil
+	if err != nil && !errors.Is(err, filesystem.ErrNotFound) {
+		return nil, err
+	}
+	if exists && info.IsDir {
+		return nil, fmt.Errorf("is a directory: %s", path)
+	}
+
+	if flags&filesystem.O_EXCL != 0 && flags&filesystem.O_CREATE != 0 && exists {
+		return nil, filesystem.NewAlreadyExistsError("file", path)
+	}
+	if !exists {
+		if flags&filesystem.O_CREATE == 0 {
+			return nil, filesystem.NewNotFoundError("open", path)
+		}
+		// Create the object now so that it is visible while being written
+		if err := fs.Create(path); err != nil {
+			return nil, err
+		}
+	}
+
+	handle := &s3FileHandle{fs: fs, path: path, flags: flags}
+
+	if flags&(filesystem.O_WRONLY|filesystem.O_RDWR) != 0 {
+		w := newS3fsWriter(fs, path)
+		w.dirty = flags&filesystem.O_TRUNC != 0
+		handle.w = w
+
+		// Appending rewrites the object, so start with its current content
+		if exists && flags&filesystem.O_APPEND != 0 && flags&filesystem.O_TRUNC == 0 && info.Size > 0 {
+			if err := handle.preload(); err != nil {
+				w.abort()
+				return nil, err
+			}
+		}
+	}
+
+	fs.handlesMu.Lock()
+	handle.id = fs.nextHandleID
+	fs.nextHandleID++
+	fs.handles[handle.id] = handle
+	fs.handlesMu.Unlock()
+
+	log.Debugf("[s3fs] Opened handle %d for %s (flags: %d)", handle.id, path, flags)
+	return handle, nil
+}
+
+// preload copies the current object into the writer of an append handle
+func (h *s3FileHandle) preload() error {
+	body, err := h.fs.client.GetObjectStream(context.Background(), h.w.path)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if _, err := io.Copy(h.w, body); err != nil {
+		return fmt.Errorf("failed to read %s for append: %w", h.path, err)
+	}
+	h.w.dirty = false
+	return nil
+}
+
+// GetHandle retrieves an existing handle by its ID
+func (fs *S3FS) GetHandle(id int64) (filesystem.FileHandle, error) {
+	fs.handlesMu.Lock()
+	defer fs.handlesMu.Unlock()
+
+	handle, ok := fs.handles[id]
+	if !ok {
+		return nil, filesystem.ErrNotFound
+	}
+	return handle, nil
+}
+
+// CloseHandle closes a handle by its ID
+func (fs *S3FS) CloseHandle(id int64) error {
+	fs.handlesMu.Lock()
+	handle, ok := fs.handles[id]
+	fs.handlesMu.Unlock()
+
+	if !ok {
+		return filesystem.ErrNotFound
+	}
+	return handle.Close()
+}
+
+// abortHandles drops all open handles and discards their unfinished uploads
+func (fs *S3FS) abortHandles() {
+	fs.handlesMu.Lock()
+	handles := fs.handles
+	fs.handles = make(map[int64]*s3FileHandle)
+	fs.handlesMu.Unlock()
+
+	for _, handle := range handles {
+		handle.mu.Lock()
+		if handle.w != nil && !handle.w.closed {
+			handle.w.abort()
+		}
+		handle.closeBody()
+		handle.closed = true
+		handle.mu.Unlock()
+	}
+}
+
+func isNotFound(err error) bool {
+	return strings.Contains(err.Error(), "NoSuchKey") || strings.Contains(err.Error(), "NotFound")
+}
+
+func isInvalidRange(err error) bool {
+	return strings.Contains(err.Error(), "InvalidRange")
+}
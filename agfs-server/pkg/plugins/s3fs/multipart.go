@@ -0,0 +1,210 @@
+package s3fs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	minPartSize  = 5 * 1024 * 1024        // S3 minimum for every part but the last
+	maxPartSize  = 5 * 1024 * 1024 * 1024 // S3 maximum part size
+	maxParts     = 10000                  // S3 maximum number of parts per upload
+	partAttempts = 3                      // Attempts per part before it is left for a later retry
+)
+
+// MultipartConfig controls when and how objects are uploaded in parts
+type MultipartConfig struct {
+	Threshold   int64 // Objects of at least this size use multipart upload
+	PartSize    int64 // Size of every part except the last
+	Concurrency int   // Number of parts uploaded in parallel
+}
+
+// DefaultMultipartConfig returns default multipart upload configuration
+func DefaultMultipartConfig() MultipartConfig {
+	return MultipartConfig{
+		Threshold:   64 * 1024 * 1024,
+		PartSize:    16 * 1024 * 1024,
+		Concurrency: 4,
+	}
+}
+
+// parseMultipartConfig reads the multipart_* options of the plugin config
+func parseMultipartConfig(cfg map[string]interface{}) (MultipartConfig, error) {
+	mp := DefaultMultipartConfig()
+
+	threshold, err := config.GetSizeConfig(cfg, "multipart_threshold", mp.Threshold)
+	if err != nil {
+		return mp, fmt.Errorf("invalid multipart_threshold: %w", err)
+	}
+	partSize, err := config.GetSizeConfig(cfg, "multipart_part_size", mp.PartSize)
+	if err != nil {
+		return mp, fmt.Errorf("invalid multipart_part_size: %w", err)
+	}
+	if partSize < minPartSize || partSize > maxPartSize {
+		return mp, fmt.Errorf("multipart_part_size must be between 5MB and 5GB")
+	}
+	if threshold < 0 {
+		return mp, fmt.Errorf("multipart_threshold must not be negative")
+	}
+	concurrency := config.GetIntConfig(cfg, "multipart_concurrency", mp.Concurrency)
+	if concurrency < 1 {
+		return mp, fmt.Errorf("multipart_concurrency must be at least 1")
+	}
+
+	return MultipartConfig{Threshold: threshold, PartSize: partSize, Concurrency: concurrency}, nil
+}
+
+// multipartUpload tracks an in-progress multipart upload
+// Parts are uploaded in the background, at most Concurrency at a time. A part
+// that still fails after partAttempts is kept in memory, and the next wait
+// retries it, so a transient error resumes the upload instead of restarting it.
+type multipartUpload struct {
+	client   *S3Client
+	path     string
+	uploadID string
+	sem      chan struct{}
+	wg       sync.WaitGroup
+
+	mu     sync.Mutex
+	etags  map[int32]string // Uploaded parts
+	failed map[int32][]byte // Parts waiting for a retry
+	err    error            // Most recent part error
+}
+
+func startMultipartUpload(ctx context.Context, client *S3Client, path string, concurrency int) (*multipartUpload, error) {
+	uploadID, err := client.CreateMultipartUpload(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("[s3fs] Started multipart upload for %s (upload: %s)", path, uploadID)
+
+	return &multipartUpload{
+		client:   client,
+		path:     path,
+		uploadID: uploadID,
+		sem:      make(chan struct{}, concurrency),
+		etags:    make(map[int32]string),
+		failed:   make(map[int32][]byte),
+	}, nil
+}
+
+// upload sends a part in the background
+// It blocks while Concurrency parts are already in flight, which bounds the
+// memory held by a writer to about Concurrency+1 parts.
+func (u *multipartUpload) upload(number int32, data []byte) {
+	u.sem <- struct{}{}
+	u.wg.Add(1)
+	go func() {
+		defer func() {
+			<-u.sem
+			u.wg.Done()
+		}()
+
+		var etag string
+		var err error
+		for attempt := 0; attempt < partAttempts; attempt++ {
+			if attempt > 0 {
+				time.Sleep(time.Duration(1<<attempt) * 100 * time.Millisecond)
+			}
+			etag, err = u.client.UploadPart(context.Background(), u.path, u.uploadID, number, data)
+			if err == nil {
+				break
+			}
+		}
+
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		if err != nil {
+			log.Warnf("[s3fs] Part %d of %s failed: %v", number, u.path, err)
+			u.failed[number] = data
+			u.err = err
+			return
+		}
+		delete(u.failed, number)
+		u.etags[number] = etag
+	}()
+}
+
+// wait blocks until all parts in flight are done
+// Parts that failed earlier are retried first; an error is returned only if
+// some part is still missing afterwards.
+func (u *multipartUpload) wait() error {
+	u.wg.Wait()
+
+	u.mu.Lock()
+	retry := make(map[int32][]byte, len(u.failed))
+	for number, data := range u.failed {
+		retry[number] = data
+	}
+	u.err = nil
+	u.mu.Unlock()
+
+	for number, data := range retry {
+		u.upload(number, data)
+	}
+	u.wg.Wait()
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.err
+}
+
+// complete waits for all parts, assembles the object and returns its ETag
+func (u *multipartUpload) complete(ctx context.Context) (string, error) {
+	if err := u.wait(); err != nil {
+		return "", err
+	}
+
+	u.mu.Lock()
+	parts := make(map[int32]string, len(u.etags))
+	for number, etag := range u.etags {
+		parts[number] = etag
+	}
+	u.mu.Unlock()
+
+	etag, err := u.client.CompleteMultipartUpload(ctx, u.path, u.uploadID, parts)
+	if err != nil {
+		return "", err
+	}
+	log.Debugf("[s3fs] Completed multipart upload for %s (%d parts)", u.path, len(parts))
+	return etag, nil
+}
+
+// abort discards the upload and every part uploaded so far
+func (u *multipartUpload) abort(ctx context.Context) error {
+	u.wg.Wait()
+	return u.client.AbortMultipartUpload(ctx, u.path, u.uploadID)
+}
+
+// putObjectMultipart uploads data as a multipart upload with parallel parts
+// and returns the ETag of the object
+func putObjectMultipart(ctx context.Context, client *S3Client, path string, data []byte, cfg MultipartConfig) (string, error) {
+	if int64(len(data)) > cfg.PartSize*maxParts {
+		return "", fmt.Errorf("object of %d bytes needs more than %d parts of %d bytes", len(data), maxParts, cfg.PartSize)
+	}
+
+	u, err := startMultipartUpload(ctx, client, path, cfg.Concurrency)
+	if err != nil {
+		return "", err
+	}
+
+	number := int32(0)
+	for offset := int64(0); offset < int64(len(data)); offset += cfg.PartSize {
+		number++
+		u.upload(number, data[offset:min(offset+cfg.PartSize, int64(len(data)))])
+	}
+
+	etag, err := u.complete(ctx)
+	if err != nil {
+		if abortErr := u.abort(ctx); abortErr != nil {
+			log.Warnf("[s3fs] %v", abortErr)
+		}
+		return "", err
+	}
+	return etag, nil
+}
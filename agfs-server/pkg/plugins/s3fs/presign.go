@@ -0,0 +1,178 @@
+package s3fs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	presignCtl = ".presign" // Control file at the mount root: write a request, read the URL
+
+	defaultPresignExpiry = 15 * time.Minute
+	maxPresignExpiry     = 7 * 24 * time.Hour // Longest validity of a SigV4 presigned URL
+)
+
+// PresignConfig controls the .presign control file
+type PresignConfig struct {
+	Enabled bool
+	Expiry  time.Duration // Validity of URLs whose request does not specify one
+}
+
+// DefaultPresignConfig returns default presign configuration
+func DefaultPresignConfig() PresignConfig {
+	return PresignConfig{Enabled: true, Expiry: defaultPresignExpiry}
+}
+
+// parsePresignConfig reads the presign_* options of the plugin config
+func parsePresignConfig(cfg map[string]interface{}) (PresignConfig, error) {
+	pc := DefaultPresignConfig()
+	pc.Enabled = config.GetBoolConfig(cfg, "presign_enabled", pc.Enabled)
+
+	if s := config.GetStringConfig(cfg, "presign_expiry", ""); s != "" {
+		expiry, err := parsePresignExpiry(s)
+		if err != nil {
+			return pc, fmt.Errorf("invalid presign_expiry: %w", err)
+		}
+		pc.Expiry = expiry
+	}
+	return pc, nil
+}
+
+func parsePresignExpiry(s string) (time.Duration, error) {
+	expiry, err := time.ParseDuration(s)
+	if err != nil || expiry <= 0 || expiry > maxPresignExpiry {
+		return 0, fmt.Errorf("must be a duration between 1s and %s", maxPresignExpiry)
+	}
+	return expiry, nil
+}
+
+// presignRequest is a request written to the .presign control file
+//
+//	[GET|PUT] <path> [expiry]
+//
+// The method defaults to GET and the expiry to presign_expiry. The path is
+// relative to the mount and may contain spaces.
+type presignRequest struct {
+	method  string
+	path    string
+	expires time.Duration
+}
+
+func parsePresignRequest(data []byte, defaultExpiry time.Duration) (presignRequest, error) {
+	req := presignRequest{method: "GET", expires: defaultExpiry}
+	rest := strings.TrimSpace(string(data))
+
+	if method, after, ok := strings.Cut(rest, " "); ok {
+		if m := strings.ToUpper(method); m == "GET" || m == "PUT" {
+			req.method = m
+			rest = strings.TrimSpace(after)
+		}
+	}
+	if i := strings.LastIndexAny(rest, " \t"); i >= 0 {
+		if expiry, err := time.ParseDuration(rest[i+1:]); err == nil {
+			if expiry, err = parsePresignExpiry(rest[i+1:]); err != nil {
+				return req, filesystem.NewInvalidArgumentError("expiry", rest[i+1:], err.Error())
+			}
+			req.expires = expiry
+			rest = strings.TrimSpace(rest[:i])
+		}
+	}
+
+	req.path = filesystem.NormalizeS3Key(rest)
+	if req.path == "" || strings.HasSuffix(rest, "/") {
+		return req, filesystem.NewInvalidArgumentError("presign", string(data), "expected [GET|PUT] <path> [expiry]")
+	}
+	return req, nil
+}
+
+func presignCtlInfo(size int64) *filesystem.FileInfo {
+	return &filesystem.FileInfo{
+		Name:    presignCtl,
+		Size:    size,
+		Mode:    0600,
+		ModTime: time.Now(),
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "control"},
+	}
+}
+
+// presignStat returns the control file, sized to the last URL
+func (fs *S3FS) presignStat() (*filesystem.FileInfo, error) {
+	if !fs.presign.Enabled {
+		return nil, filesystem.ErrNotFound
+	}
+
+	fs.presignMu.Lock()
+	defer fs.presignMu.Unlock()
+	return presignCtlInfo(int64(len(fs.presigned))), nil
+}
+
+// presignRead returns the last URL generated through the control file
+func (fs *S3FS) presignRead(offset, size int64) ([]byte, error) {
+	if !fs.presign.Enabled {
+		return nil, filesystem.ErrNotFound
+	}
+
+	fs.presignMu.Lock()
+	defer fs.presignMu.Unlock()
+
+	data := fs.presigned
+	if offset >= int64(len(data)) {
+		return []byte{}, nil
+	}
+	data = data[offset:]
+	if size >= 0 && size < int64(len(data)) {
+		data = data[:size]
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// presignWrite generates a presigned URL for the request in data
+// The URL is kept until the next request and is read back from the control
+// file. Downloads are checked against the bucket first, so that a missing
+// file fails here rather than when the URL is used.
+func (fs *S3FS) presignWrite(data []byte) error {
+	if !fs.presign.Enabled {
+		return filesystem.NewPermissionDeniedError("write", "/"+presignCtl, "presigning is disabled (set presign_enabled = true)")
+	}
+
+	req, err := parsePresignRequest(data, fs.presign.Expiry)
+	if err != nil {
+		return err
+	}
+	if _, ok := parseVersionPath(req.path); ok || req.path == presignCtl {
+		return filesystem.NewInvalidArgumentError("path", "/"+req.path, "not an S3 object")
+	}
+
+	ctx := context.Background()
+	var url string
+	if req.method == "GET" {
+		info, err := fs.Stat(req.path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir {
+			return filesystem.NewInvalidArgumentError("path", "/"+req.path, "is a directory")
+		}
+		url, err = fs.client.PresignGetObject(ctx, req.path, req.expires)
+		if err != nil {
+			return err
+		}
+	} else {
+		url, err = fs.client.PresignPutObject(ctx, req.path, req.expires)
+		if err != nil {
+			return err
+		}
+	}
+	log.Debugf("[s3fs] Presigned %s for %s (expires in %s)", req.method, req.path, req.expires)
+
+	fs.presignMu.Lock()
+	fs.presigned = []byte(url + "\n")
+	fs.presignMu.Unlock()
+	return nil
+}
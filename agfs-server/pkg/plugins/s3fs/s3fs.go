@@ -29,6 +29,19 @@ type S3FS struct {
 	// Caches for performance optimization
 	dirCache  *ListDirCache
 	statCache *StatCache
+
+	// Multipart upload settings for large objects
+	multipart MultipartConfig
+
+	// Presigned URLs through the .presign control file
+	presign   PresignConfig
+	presigned []byte // Last generated URL
+	presignMu sync.Mutex
+
+	// Handle management
+	handles      map[int64]*s3FileHandle
+	handlesMu    sync.Mutex
+	nextHandleID int64
 }
 
 // CacheConfig holds cache configuration
@@ -66,6 +79,11 @@ func NewS3FSWithCache(cfg S3Config, cacheCfg CacheConfig) (*S3FS, error) {
 		pluginName: PluginName,
 		dirCache:   NewListDirCache(cacheCfg.MaxSize, cacheCfg.DirCacheTTL, cacheCfg.Enabled),
 		statCache:  NewStatCache(cacheCfg.MaxSize*5, cacheCfg.StatCacheTTL, cacheCfg.Enabled),
+
+		multipart:    DefaultMultipartConfig(),
+		presign:      DefaultPresignConfig(),
+		handles:      make(map[int64]*s3FileHandle),
+		nextHandleID: 1,
 	}, nil
 }
 
@@ -73,6 +91,10 @@ func (fs *S3FS) Create(path string) error {
 	path = filesystem.NormalizeS3Key(path)
 	ctx := context.Background()
 
+	if err := virtualReadOnly("create", path); err != nil {
+		return err
+	}
+
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
@@ -98,11 +120,9 @@ func (fs *S3FS) Create(path string) error {
 	}
 
 	// Create empty file
-	err = fs.client.PutObject(ctx, path, []byte{})
+	etag, err := fs.client.PutObject(ctx, path, []byte{})
 	if err == nil {
-		// Invalidate caches
-		fs.dirCache.Invalidate(parent)
-		fs.statCache.Invalidate(path)
+		fs.cacheObject(path, S3Object{LastModified: time.Now(), ETag: etag})
 	}
 	return err
 }
@@ -111,6 +131,10 @@ func (fs *S3FS) Mkdir(path string, perm uint32) error {
 	path = filesystem.NormalizeS3Key(path)
 	ctx := context.Background()
 
+	if err := virtualReadOnly("mkdir", path); err != nil {
+		return err
+	}
+
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
@@ -138,9 +162,7 @@ func (fs *S3FS) Mkdir(path string, perm uint32) error {
 	// Create directory marker
 	err = fs.client.CreateDirectory(ctx, path)
 	if err == nil {
-		// Invalidate caches
-		fs.dirCache.Invalidate(parent)
-		fs.statCache.Invalidate(path)
+		fs.cacheObject(path, S3Object{LastModified: time.Now(), IsDir: true})
 	}
 	return err
 }
@@ -149,11 +171,13 @@ func (fs *S3FS) Remove(path string) error {
 	path = filesystem.NormalizeS3Key(path)
 	ctx := context.Background()
 
+	if err := virtualReadOnly("remove", path); err != nil {
+		return err
+	}
+
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
-	parent := getParentPath(path)
-
 	// Check if it's a file
 	exists, err := fs.client.ObjectExists(ctx, path)
 	if err != nil {
@@ -164,8 +188,7 @@ func (fs *S3FS) Remove(path string) error {
 		// It's a file, delete it
 		err = fs.client.DeleteObject(ctx, path)
 		if err == nil {
-			fs.dirCache.Invalidate(parent)
-			fs.statCache.Invalidate(path)
+			fs.uncacheObject(path)
 		}
 		return err
 	}
@@ -193,9 +216,8 @@ func (fs *S3FS) Remove(path string) error {
 	// Delete directory marker
 	err = fs.client.DeleteObject(ctx, path+"/")
 	if err == nil {
-		fs.dirCache.Invalidate(parent)
+		fs.uncacheObject(path)
 		fs.dirCache.Invalidate(path)
-		fs.statCache.Invalidate(path)
 	}
 	return err
 }
@@ -204,13 +226,16 @@ func (fs *S3FS) RemoveAll(path string) error {
 	path = filesystem.NormalizeS3Key(path)
 	ctx := context.Background()
 
+	if err := virtualReadOnly("remove", path); err != nil {
+		return err
+	}
+
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
 	err := fs.client.DeleteDirectory(ctx, path)
 	if err == nil {
-		parent := getParentPath(path)
-		fs.dirCache.Invalidate(parent)
+		fs.uncacheObject(path)
 		fs.dirCache.InvalidatePrefix(path)
 		fs.statCache.InvalidatePrefix(path)
 	}
@@ -221,6 +246,13 @@ func (fs *S3FS) Read(path string, offset int64, size int64) ([]byte, error) {
 	path = filesystem.NormalizeS3Key(path)
 	ctx := context.Background()
 
+	if v, ok := parseVersionPath(path); ok {
+		return fs.versionRead(v, offset, size)
+	}
+	if path == presignCtl {
+		return fs.presignRead(offset, size)
+	}
+
 	fs.mu.RLock()
 	defer fs.mu.RUnlock()
 
@@ -252,6 +284,22 @@ func (fs *S3FS) Write(path string, data []byte, offset int64, flags filesystem.W
 	path = filesystem.NormalizeS3Key(path)
 	ctx := context.Background()
 
+	if v, ok := parseVersionPath(path); ok {
+		if v.versionID != restoreCtl {
+			return 0, filesystem.NewPermissionDeniedError("write", "/"+path, "versions are read-only")
+		}
+		if err := fs.versionRestore(v, data); err != nil {
+			return 0, err
+		}
+		return int64(len(data)), nil
+	}
+	if path == presignCtl {
+		if err := fs.presignWrite(data); err != nil {
+			return 0, err
+		}
+		return int64(len(data)), nil
+	}
+
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
@@ -268,15 +316,19 @@ func (fs *S3FS) Write(path string, data []byte, offset int64, flags filesystem.W
 	}
 
 	// Write to S3 directly - S3 will create parent "directories" implicitly
-	err := fs.client.PutObject(ctx, path, data)
+	// Large objects are uploaded in parallel parts
+	var etag string
+	var err error
+	if len(data) > 0 && int64(len(data)) >= fs.multipart.Threshold {
+		etag, err = putObjectMultipart(ctx, fs.client, path, data, fs.multipart)
+	} else {
+		etag, err = fs.client.PutObject(ctx, path, data)
+	}
 	if err != nil {
 		return 0, err
 	}
 
-	// Invalidate caches
-	parent := getParentPath(path)
-	fs.dirCache.Invalidate(parent)
-	fs.statCache.Invalidate(path)
+	fs.cacheObject(path, S3Object{Size: int64(len(data)), LastModified: time.Now(), ETag: etag})
 
 	return int64(len(data)), nil
 }
@@ -285,6 +337,13 @@ func (fs *S3FS) ReadDir(path string) ([]filesystem.FileInfo, error) {
 	path = filesystem.NormalizeS3Key(path)
 	ctx := context.Background()
 
+	if v, ok := parseVersionPath(path); ok {
+		return fs.versionReadDir(v)
+	}
+	if path == presignCtl {
+		return nil, filesystem.NewNotDirectoryError("/" + presignCtl)
+	}
+
 	fs.mu.RLock()
 	defer fs.mu.RUnlock()
 
@@ -293,8 +352,14 @@ func (fs *S3FS) ReadDir(path string) ([]filesystem.FileInfo, error) {
 		return cached, nil
 	}
 
-	// Check if directory exists
-	if path != "" {
+	// List objects
+	objects, err := fs.client.ListObjects(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only an empty listing needs a check whether the directory exists
+	if len(objects) == 0 && path != "" {
 		exists, err := fs.client.DirectoryExists(ctx, path)
 		if err != nil {
 			return nil, fmt.Errorf("failed to check directory: %w", err)
@@ -304,29 +369,9 @@ func (fs *S3FS) ReadDir(path string) ([]filesystem.FileInfo, error) {
 		}
 	}
 
-	// List objects
-	objects, err := fs.client.ListObjects(ctx, path)
-	if err != nil {
-		return nil, err
-	}
-
 	var files []filesystem.FileInfo
 	for _, obj := range objects {
-		mode := uint32(0644)
-		if obj.IsDir {
-			mode = 0755
-		}
-		files = append(files, filesystem.FileInfo{
-			Name:    obj.Key,
-			Size:    obj.Size,
-			Mode:    mode,
-			ModTime: obj.LastModified,
-			IsDir:   obj.IsDir,
-			Meta: filesystem.MetaData{
-				Name: PluginName,
-				Type: "s3",
-			},
-		})
+		files = append(files, objectInfo(obj))
 	}
 
 	// Cache the result
@@ -339,6 +384,13 @@ func (fs *S3FS) Stat(path string) (*filesystem.FileInfo, error) {
 	path = filesystem.NormalizeS3Key(path)
 	ctx := context.Background()
 
+	if v, ok := parseVersionPath(path); ok {
+		return fs.versionStat(v)
+	}
+	if path == presignCtl {
+		return fs.presignStat()
+	}
+
 	fs.mu.RLock()
 	defer fs.mu.RUnlock()
 
@@ -367,6 +419,16 @@ func (fs *S3FS) Stat(path string) (*filesystem.FileInfo, error) {
 		return cached, nil
 	}
 
+	// A cached listing of the parent directory answers without a request,
+	// including for paths that do not exist
+	if info, listed := fs.dirCache.Lookup(getParentPath(path), filepath.Base(path)); listed {
+		if info == nil {
+			return nil, filesystem.ErrNotFound
+		}
+		info.Meta.Content = fs.bucketMeta(info.Meta.Content)
+		return info, nil
+	}
+
 	// Try as file first
 	head, err := fs.client.HeadObject(ctx, path)
 	if err == nil {
@@ -380,12 +442,18 @@ func (fs *S3FS) Stat(path string) (*filesystem.FileInfo, error) {
 				Name: PluginName,
 				Type: "s3",
 				Content: map[string]string{
-					"region": fs.client.region,
-					"bucket": fs.client.bucket,
-					"prefix": fs.client.rawPrefix,
+					"region":        fs.client.region,
+					"bucket":        fs.client.bucket,
+					"prefix":        fs.client.rawPrefix,
+					"etag":          aws.ToString(head.ETag),
+					"storage_class": storageClass(string(head.StorageClass)),
 				},
 			},
 		}
+		// Only set when the bucket has versioning enabled
+		if versionID := aws.ToString(head.VersionId); versionID != "" {
+			info.Meta.Content["version_id"] = versionID
+		}
 		fs.statCache.Put(path, info)
 		return info, nil
 	}
@@ -425,6 +493,12 @@ func (fs *S3FS) Rename(oldPath, newPath string) error {
 	newPath = filesystem.NormalizeS3Key(newPath)
 	ctx := context.Background()
 
+	for _, p := range []string{oldPath, newPath} {
+		if err := virtualReadOnly("rename", p); err != nil {
+			return err
+		}
+	}
+
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
@@ -444,7 +518,7 @@ func (fs *S3FS) Rename(oldPath, newPath string) error {
 	}
 
 	// Put to new location
-	err = fs.client.PutObject(ctx, newPath, data)
+	etag, err := fs.client.PutObject(ctx, newPath, data)
 	if err != nil {
 		return fmt.Errorf("failed to write destination: %w", err)
 	}
@@ -455,13 +529,8 @@ func (fs *S3FS) Rename(oldPath, newPath string) error {
 		return fmt.Errorf("failed to delete source: %w", err)
 	}
 
-	// Invalidate caches
-	oldParent := getParentPath(oldPath)
-	newParent := getParentPath(newPath)
-	fs.dirCache.Invalidate(oldParent)
-	fs.dirCache.Invalidate(newParent)
-	fs.statCache.Invalidate(oldPath)
-	fs.statCache.Invalidate(newPath)
+	fs.uncacheObject(oldPath)
+	fs.cacheObject(newPath, S3Object{Size: int64(len(data)), LastModified: time.Now(), ETag: etag})
 
 	return nil
 }
@@ -481,23 +550,248 @@ func (fs *S3FS) Open(path string) (io.ReadCloser, error) {
 }
 
 func (fs *S3FS) OpenWrite(path string) (io.WriteCloser, error) {
-	return &s3fsWriter{fs: fs, path: path}, nil
+	return newS3fsWriter(fs, path), nil
 }
 
+// s3fsWriter buffers written data and uploads it as one object
+// Small objects are uploaded on Close. Once the data reaches the multipart
+// threshold, a multipart upload is started and full parts are uploaded while
+// writing continues, so large objects never need to fit in memory.
 type s3fsWriter struct {
-	fs   *S3FS
-	path string
-	buf  []byte
+	fs       *S3FS
+	path     string
+	buf      []byte
+	size     int64 // Bytes written so far
+	dirty    bool  // Data not yet stored in S3
+	upload   *multipartUpload
+	nextPart int32
+	closed   bool
+}
+
+func newS3fsWriter(fs *S3FS, path string) *s3fsWriter {
+	return &s3fsWriter{fs: fs, path: filesystem.NormalizeS3Key(path), dirty: true}
 }
 
+// Write buffers p and uploads any full parts
+// The data is accepted even when starting an upload fails; the upload is
+// retried on the next Write, Sync or Close.
 func (w *s3fsWriter) Write(p []byte) (n int, err error) {
+	if w.closed {
+		return 0, fmt.Errorf("writer is closed")
+	}
 	w.buf = append(w.buf, p...)
-	return len(p), nil
+	w.size += int64(len(p))
+	w.dirty = true
+	return len(p), w.uploadParts()
+}
+
+// uploadParts starts a multipart upload once the threshold is reached and
+// hands every full part in the buffer to it
+func (w *s3fsWriter) uploadParts() error {
+	cfg := w.fs.multipart
+	if w.upload == nil {
+		if len(w.buf) == 0 || int64(len(w.buf)) < cfg.Threshold {
+			return nil
+		}
+		upload, err := startMultipartUpload(context.Background(), w.fs.client, w.path, cfg.Concurrency)
+		if err != nil {
+			return err
+		}
+		w.upload = upload
+	}
+
+	for int64(len(w.buf)) >= cfg.PartSize {
+		if w.nextPart >= maxParts {
+			return fmt.Errorf("object exceeds %d parts of %d bytes", maxParts, cfg.PartSize)
+		}
+		w.nextPart++
+		part := make([]byte, cfg.PartSize)
+		copy(part, w.buf)
+		w.upload.upload(w.nextPart, part)
+		w.buf = w.buf[:copy(w.buf, w.buf[cfg.PartSize:])]
+	}
+	return nil
+}
+
+// Sync makes the data written so far durable where S3 allows it
+// Small objects are uploaded as they are; for multipart uploads, all full
+// parts are uploaded and failed parts retried. The last partial part stays
+// buffered, as S3 only accepts small parts at the end of an upload.
+func (w *s3fsWriter) Sync() error {
+	if w.closed {
+		return fmt.Errorf("writer is closed")
+	}
+	if err := w.uploadParts(); err != nil {
+		return err
+	}
+	if w.upload != nil {
+		return w.upload.wait()
+	}
+	if !w.dirty {
+		return nil
+	}
+	if _, err := w.fs.Write(w.path, w.buf, -1, filesystem.WriteFlagCreate|filesystem.WriteFlagTruncate); err != nil {
+		return err
+	}
+	w.dirty = false
+	return nil
+}
+
+// commit stores the object; on failure the writer stays open so that the
+// caller can retry without losing the parts uploaded so far
+func (w *s3fsWriter) commit() error {
+	if w.closed {
+		return nil
+	}
+	if err := w.uploadParts(); err != nil {
+		return err
+	}
+
+	if w.upload == nil {
+		if w.dirty {
+			if _, err := w.fs.Write(w.path, w.buf, -1, filesystem.WriteFlagCreate|filesystem.WriteFlagTruncate); err != nil {
+				return err
+			}
+		}
+		w.closed = true
+		w.buf = nil
+		return nil
+	}
+
+	if len(w.buf) > 0 || w.nextPart == 0 {
+		w.nextPart++
+		w.upload.upload(w.nextPart, w.buf)
+		w.buf = nil
+	}
+	// Wait for the parts before taking the file system lock
+	if err := w.upload.wait(); err != nil {
+		return err
+	}
+	if err := w.fs.completeUpload(w.path, w.upload, w.size); err != nil {
+		return err
+	}
+	w.closed = true
+	return nil
+}
+
+// abort discards the writer's multipart upload, if any
+func (w *s3fsWriter) abort() {
+	w.closed = true
+	w.buf = nil
+	if w.upload != nil {
+		if err := w.upload.abort(context.Background()); err != nil {
+			log.Warnf("[s3fs] %v", err)
+		}
+	}
 }
 
 func (w *s3fsWriter) Close() error {
-	_, err := w.fs.Write(w.path, w.buf, -1, filesystem.WriteFlagCreate|filesystem.WriteFlagTruncate)
-	return err
+	if err := w.commit(); err != nil {
+		w.abort()
+		return err
+	}
+	return nil
+}
+
+// completeUpload finishes a multipart upload and updates caches
+func (fs *S3FS) completeUpload(path string, upload *multipartUpload, size int64) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	etag, err := upload.complete(context.Background())
+	if err != nil {
+		return err
+	}
+
+	fs.cacheObject(path, S3Object{Size: size, LastModified: time.Now(), ETag: etag})
+	return nil
+}
+
+// virtualReadOnly rejects modifications of the virtual .versions directories
+// and the .presign control file, which are not S3 objects
+func virtualReadOnly(op, path string) error {
+	key := filesystem.NormalizeS3Key(path)
+	if _, ok := parseVersionPath(key); ok {
+		return filesystem.NewPermissionDeniedError(op, path, "versions are read-only")
+	}
+	if key == presignCtl {
+		return filesystem.NewPermissionDeniedError(op, path, "control file")
+	}
+	return nil
+}
+
+// objectInfo converts a listed object into file information
+func objectInfo(obj S3Object) filesystem.FileInfo {
+	info := filesystem.FileInfo{
+		Name:    obj.Key,
+		Size:    obj.Size,
+		Mode:    0644,
+		ModTime: obj.LastModified,
+		IsDir:   obj.IsDir,
+		Meta: filesystem.MetaData{
+			Name: PluginName,
+			Type: "s3",
+		},
+	}
+	if obj.IsDir {
+		info.Mode = 0755
+		return info
+	}
+	info.Meta.Content = map[string]string{
+		"etag":          obj.ETag,
+		"storage_class": storageClass(obj.StorageClass),
+	}
+	return info
+}
+
+// bucketMeta adds the mount's location to the metadata of a file
+func (fs *S3FS) bucketMeta(content map[string]string) map[string]string {
+	merged := map[string]string{
+		"region": fs.client.region,
+		"bucket": fs.client.bucket,
+		"prefix": fs.client.rawPrefix,
+	}
+	for k, v := range content {
+		merged[k] = v
+	}
+	return merged
+}
+
+// cacheObject writes a file or directory stored by this mount through to the
+// cached listings, so that they stay valid instead of being listed again
+// Parent directories exist implicitly once a key is written, so they are
+// added to the listings of their own parents as well. Callers hold fs.mu.
+func (fs *S3FS) cacheObject(path string, obj S3Object) {
+	obj.Key = filepath.Base(path)
+	parent := getParentPath(path)
+	fs.dirCache.Upsert(parent, objectInfo(obj))
+	fs.statCache.Invalidate(path)
+
+	for dir := parent; dir != ""; dir = getParentPath(dir) {
+		name := filepath.Base(dir)
+		if info, listed := fs.dirCache.Lookup(getParentPath(dir), name); listed && info == nil {
+			fs.dirCache.Upsert(getParentPath(dir), objectInfo(S3Object{Key: name, LastModified: time.Now(), IsDir: true}))
+		}
+	}
+}
+
+// uncacheObject removes a deleted file or directory from the cached listing
+// of its parent. A parent without a marker object disappears with its last
+// entry, so unless it is known to have entries left, it is dropped from the
+// caches too. Callers hold fs.mu.
+func (fs *S3FS) uncacheObject(path string) {
+	parent := getParentPath(path)
+	fs.dirCache.Delete(parent, filepath.Base(path))
+	fs.statCache.Invalidate(path)
+
+	if parent == "" {
+		return
+	}
+	if files, ok := fs.dirCache.Get(parent); !ok || len(files) == 0 {
+		fs.dirCache.Invalidate(parent)
+		fs.dirCache.Invalidate(getParentPath(parent))
+		fs.statCache.Invalidate(parent)
+	}
 }
 
 // S3FSPlugin wraps S3FS as a plugin
@@ -520,6 +814,8 @@ func (p *S3FSPlugin) Validate(cfg map[string]interface{}) error {
 	allowedKeys := []string{
 		"bucket", "region", "access_key_id", "secret_access_key", "endpoint", "prefix", "disable_ssl", "mount_path",
 		"cache_enabled", "cache_ttl", "stat_cache_ttl", "cache_max_size", "use_path_request_style",
+		"multipart_threshold", "multipart_part_size", "multipart_concurrency",
+		"presign_enabled", "presign_expiry",
 	}
 	if err := config.ValidateOnlyKnownKeys(cfg, allowedKeys); err != nil {
 		return err
@@ -531,19 +827,30 @@ func (p *S3FSPlugin) Validate(cfg map[string]interface{}) error {
 	}
 
 	// Validate optional string parameters
-	for _, key := range []string{"region", "access_key_id", "secret_access_key", "endpoint", "prefix"} {
+	for _, key := range []string{"region", "access_key_id", "secret_access_key", "endpoint", "prefix", "presign_expiry"} {
 		if err := config.ValidateStringType(cfg, key); err != nil {
 			return err
 		}
 	}
 
 	// Validate boolean parameters
-	for _, key := range []string{"disable_ssl", "use_path_request_style", "cache_enabled"} {
+	for _, key := range []string{"disable_ssl", "use_path_request_style", "cache_enabled", "presign_enabled"} {
 		if err := config.ValidateBoolType(cfg, key); err != nil {
 			return err
 		}
 	}
 
+	// Validate multipart upload parameters
+	if err := config.ValidateIntType(cfg, "multipart_concurrency"); err != nil {
+		return err
+	}
+	if _, err := parseMultipartConfig(cfg); err != nil {
+		return err
+	}
+	if _, err := parsePresignConfig(cfg); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -574,11 +881,22 @@ func (p *S3FSPlugin) Initialize(config map[string]interface{}) error {
 		MaxSize:      getIntConfig(config, "cache_max_size", 1000),
 	}
 
+	multipartCfg, err := parseMultipartConfig(config)
+	if err != nil {
+		return err
+	}
+	presignCfg, err := parsePresignConfig(config)
+	if err != nil {
+		return err
+	}
+
 	// Create S3FS instance with cache
 	fs, err := NewS3FSWithCache(cfg, cacheCfg)
 	if err != nil {
 		return fmt.Errorf("failed to initialize s3fs: %w", err)
 	}
+	fs.multipart = multipartCfg
+	fs.presign = presignCfg
 	p.fs = fs
 
 	log.Infof("[s3fs] Initialized with bucket: %s, region: %s, cache: %v", cfg.Bucket, cfg.Region, cacheCfg.Enabled)
@@ -622,6 +940,7 @@ func (p *S3FSPlugin) GetConfigParams() []plugin.ConfigParameter {
 			Required:    false,
 			Default:     "",
 			Description: "AWS secret access key (uses env AWS_SECRET_ACCESS_KEY if not provided)",
+			Secret:      true,
 		},
 		{
 			Name:        "endpoint",
@@ -679,10 +998,60 @@ func (p *S3FSPlugin) GetConfigParams() []plugin.ConfigParameter {
 			Default:     "1000",
 			Description: "Maximum number of entries in each cache",
 		},
+		{
+			Name:        "multipart_threshold",
+			Type:        "string",
+			Required:    false,
+			Default:     "64MB",
+			Description: "Objects of at least this size are uploaded in parts (e.g., '64MB')",
+		},
+		{
+			Name:        "multipart_part_size",
+			Type:        "string",
+			Required:    false,
+			Default:     "16MB",
+			Description: "Size of each multipart upload part, between 5MB and 5GB",
+		},
+		{
+			Name:        "multipart_concurrency",
+			Type:        "int",
+			Required:    false,
+			Default:     "4",
+			Description: "Number of parts uploaded in parallel",
+		},
+		{
+			Name:        "presign_enabled",
+			Type:        "bool",
+			Required:    false,
+			Default:     "true",
+			Description: "Enable presigned URLs through the /.presign control file",
+		},
+		{
+			Name:        "presign_expiry",
+			Type:        "string",
+			Required:    false,
+			Default:     "15m",
+			Description: "Validity of presigned URLs when the request does not specify one (at most 168h)",
+		},
 	}
 }
 
+// Probe checks that the bucket is still accessible with the configured credentials
+func (p *S3FSPlugin) Probe(ctx context.Context) []plugin.ProbeResult {
+	c := p.fs.client
+	return []plugin.ProbeResult{{Backend: "s3 bucket", Err: checkBucketAccess(ctx, c.client, c.bucket)}}
+}
+
+// IsRead implements plugin.ReadRouter: objects can be read, listed and
+// stat'ed from a replica bucket (e.g. a cross-region replication target)
+func (p *S3FSPlugin) IsRead(op, path string) bool {
+	return true
+}
+
 func (p *S3FSPlugin) Shutdown() error {
+	if p.fs != nil {
+		p.fs.abortHandles()
+	}
 	return nil
 }
 
@@ -849,6 +1218,9 @@ ADVANTAGES:
   - Geographic redundancy
   - Pay-per-use pricing
   - Efficient streaming for large files with minimal memory footprint
+  - Parallel multipart upload for large files
+  - Presigned URLs for direct transfers (/.presign)
+  - Previous object versions under <dir>/.versions (versioned buckets)
   - Versioning and lifecycle policies (via S3 bucket settings)
 `
 }
@@ -998,6 +1370,14 @@ func (fs *S3FS) Truncate(path string, size int64) error {
 	path = filesystem.NormalizeS3Key(path)
 	ctx := context.Background()
 
+	// Writing to the control file replaces its content anyway
+	if path == presignCtl && fs.presign.Enabled {
+		return nil
+	}
+	if err := virtualReadOnly("truncate", path); err != nil {
+		return err
+	}
+
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
@@ -1041,15 +1421,12 @@ func (fs *S3FS) Truncate(path string, size int64) error {
 	}
 
 	// Write back to S3
-	err = fs.client.PutObject(ctx, path, newData)
+	etag, err := fs.client.PutObject(ctx, path, newData)
 	if err != nil {
 		return fmt.Errorf("failed to write truncated file: %w", err)
 	}
 
-	// Invalidate caches
-	parent := getParentPath(path)
-	fs.dirCache.Invalidate(parent)
-	fs.statCache.Invalidate(path)
+	fs.cacheObject(path, S3Object{Size: size, LastModified: time.Now(), ETag: etag})
 
 	return nil
 }
@@ -1059,3 +1436,4 @@ var _ plugin.ServicePlugin = (*S3FSPlugin)(nil)
 var _ filesystem.FileSystem = (*S3FS)(nil)
 var _ filesystem.Streamer = (*S3FS)(nil)
 var _ filesystem.Truncater = (*S3FS)(nil)
+var _ filesystem.HandleFS = (*S3FS)(nil)
@@ -1,8 +1,14 @@
 package s3fs
 
 import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
 )
@@ -406,3 +412,434 @@ func TestPrefixIsolation(t *testing.T) {
 		}
 	})
 }
+
+// TestParseMultipartConfig tests multipart option parsing and validation
+func TestParseMultipartConfig(t *testing.T) {
+	cfg, err := parseMultipartConfig(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("parseMultipartConfig failed: %v", err)
+	}
+	if cfg != DefaultMultipartConfig() {
+		t.Errorf("expected defaults, got %+v", cfg)
+	}
+
+	cfg, err = parseMultipartConfig(map[string]interface{}{
+		"multipart_threshold":   "1GB",
+		"multipart_part_size":   "8MB",
+		"multipart_concurrency": 16,
+	})
+	if err != nil {
+		t.Fatalf("parseMultipartConfig failed: %v", err)
+	}
+	want := MultipartConfig{Threshold: 1 << 30, PartSize: 8 << 20, Concurrency: 16}
+	if cfg != want {
+		t.Errorf("got %+v, want %+v", cfg, want)
+	}
+
+	p := NewS3FSPlugin()
+	for _, bad := range []map[string]interface{}{
+		{"bucket": "b", "multipart_part_size": "1MB"},
+		{"bucket": "b", "multipart_part_size": "6GB"},
+		{"bucket": "b", "multipart_threshold": "big"},
+		{"bucket": "b", "multipart_concurrency": 0},
+		{"bucket": "b", "multipart_concurrency": "4"},
+	} {
+		if err := p.Validate(bad); err == nil {
+			t.Errorf("expected error for %v", bad)
+		}
+	}
+}
+
+// TestS3FSMultipartWrite tests that large writes are uploaded in parts
+func TestS3FSMultipartWrite(t *testing.T) {
+	fs := newTestFS(t)
+	fs.multipart = MultipartConfig{Threshold: minPartSize, PartSize: minPartSize, Concurrency: 3}
+	path := "/multipart_test.bin"
+
+	defer fs.Remove(path)
+
+	data := make([]byte, 3*minPartSize+1234)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+
+	if _, err := fs.Write(path, data, -1, filesystem.WriteFlagCreate|filesystem.WriteFlagTruncate); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	got, err := readIgnoreEOF(fs, path)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("content mismatch: got %d bytes, want %d", len(got), len(data))
+	}
+}
+
+// TestS3FSHandleWrite tests streaming a large file through a write handle
+// the way FUSE does: many small sequential writes followed by Close
+func TestS3FSHandleWrite(t *testing.T) {
+	fs := newTestFS(t)
+	fs.multipart = MultipartConfig{Threshold: minPartSize, PartSize: minPartSize, Concurrency: 2}
+	path := "/handle_write_test.bin"
+
+	defer fs.Remove(path)
+	fs.Remove(path)
+
+	data := make([]byte, 2*minPartSize+4321)
+	for i := range data {
+		data[i] = byte(i % 253)
+	}
+
+	handle, err := fs.OpenHandle(path, filesystem.O_WRONLY|filesystem.O_CREATE|filesystem.O_TRUNC, 0644)
+	if err != nil {
+		t.Fatalf("OpenHandle failed: %v", err)
+	}
+	const chunk = 128 * 1024
+	for offset := 0; offset < len(data); offset += chunk {
+		end := min(offset+chunk, len(data))
+		if _, err := handle.WriteAt(data[offset:end], int64(offset)); err != nil {
+			t.Fatalf("WriteAt(%d) failed: %v", offset, err)
+		}
+	}
+
+	if _, err := handle.WriteAt([]byte("x"), 10); err == nil {
+		t.Error("expected error for non-sequential write")
+	}
+	if info, err := handle.Stat(); err != nil || info.Size != int64(len(data)) {
+		t.Errorf("unexpected handle stat: %+v, %v", info, err)
+	}
+	if err := handle.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if err := fs.CloseHandle(handle.ID()); err != nil {
+		t.Fatalf("CloseHandle failed: %v", err)
+	}
+	if _, err := fs.GetHandle(handle.ID()); err != filesystem.ErrNotFound {
+		t.Errorf("expected closed handle to be gone, got %v", err)
+	}
+
+	// Read it back through a read handle
+	reader, err := fs.OpenHandle(path, filesystem.O_RDONLY, 0)
+	if err != nil {
+		t.Fatalf("OpenHandle for read failed: %v", err)
+	}
+	defer reader.Close()
+
+	got, err := io.ReadAll(reader.(io.Reader))
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("content mismatch: got %d bytes, want %d", len(got), len(data))
+	}
+}
+
+// TestParseVersionPath tests recognition of paths inside .versions directories
+func TestParseVersionPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want versionPath
+		ok   bool
+	}{
+		{".versions", versionPath{}, true},
+		{"docs/.versions", versionPath{dir: "docs"}, true},
+		{"docs/.versions/a.txt", versionPath{dir: "docs", name: "a.txt"}, true},
+		{"docs/.versions/a.txt/v1", versionPath{dir: "docs", name: "a.txt", versionID: "v1"}, true},
+		{"docs/.versions/a.txt/3%2Fab%3D", versionPath{dir: "docs", name: "a.txt", versionID: "3/ab="}, true},
+		{"docs/.versions/a.txt/restore", versionPath{dir: "docs", name: "a.txt", versionID: restoreCtl}, true},
+		{"docs/a.txt", versionPath{}, false},
+		{".versions/a/b/c", versionPath{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseVersionPath(tt.path)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseVersionPath(%q) = %+v, %v; want %+v, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestVersionAt(t *testing.T) {
+	at := func(minute int) time.Time { return time.Date(2026, 1, 1, 0, minute, 0, 0, time.UTC) }
+	// S3 order: versions newest first, then delete markers
+	versions := []ObjectVersion{
+		{VersionID: "v3", LastModified: at(30)},
+		{VersionID: "v2", LastModified: at(10)},
+		{VersionID: "v1", LastModified: at(0)},
+		{VersionID: "d1", LastModified: at(20), IsDeleteMarker: true},
+	}
+	tests := []struct {
+		asOf time.Time
+		want string
+	}{
+		{at(0).Add(-time.Second), ""},
+		{at(0), "v1"},
+		{at(15), "v2"},
+		{at(25), ""}, // Deleted
+		{at(40), "v3"},
+	}
+	for _, tt := range tests {
+		got := ""
+		if v := versionAt(versions, tt.asOf); v != nil {
+			got = v.VersionID
+		}
+		if got != tt.want {
+			t.Errorf("versionAt(%s) = %q, want %q", tt.asOf, got, tt.want)
+		}
+	}
+}
+
+// TestS3FSReadAsOf tests reads as of a past time
+// The test bucket must have versioning enabled.
+func TestS3FSReadAsOf(t *testing.T) {
+	fs := newTestFS(t)
+	path := "/asof_test.txt"
+
+	defer fs.Remove(path)
+
+	if _, err := fs.Write(path, []byte("one"), -1, filesystem.WriteFlagCreate|filesystem.WriteFlagTruncate); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	info, err := fs.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Meta.Content["version_id"] == "" {
+		t.Skip("test bucket does not have versioning enabled")
+	}
+	// S3 timestamps have a resolution of a second
+	time.Sleep(1100 * time.Millisecond)
+	asOf := time.Now()
+	time.Sleep(1100 * time.Millisecond)
+	if _, err := fs.Write(path, []byte("two"), -1, filesystem.WriteFlagCreate|filesystem.WriteFlagTruncate); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	data, err := fs.ReadAsOf(path, asOf, 0, -1)
+	if err != nil && err != io.EOF {
+		t.Fatalf("ReadAsOf failed: %v", err)
+	}
+	if string(data) != "one" {
+		t.Errorf("expected the first content, got %q", data)
+	}
+	if _, err := fs.StatAsOf(path, info.ModTime.Add(-time.Minute)); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("expected the file not to exist before its first write, got %v", err)
+	}
+	files, err := fs.ReadDirAsOf("/", asOf)
+	if err != nil {
+		t.Fatalf("ReadDirAsOf failed: %v", err)
+	}
+	found := false
+	for _, f := range files {
+		found = found || (f.Name == "asof_test.txt" && f.Size == 3)
+	}
+	if !found {
+		t.Errorf("expected asof_test.txt in the listing, got %+v", files)
+	}
+}
+
+// TestS3FSVersions tests listing, reading and restoring object versions
+// The test bucket must have versioning enabled.
+func TestS3FSVersions(t *testing.T) {
+	fs := newTestFS(t)
+	path := "/versions_test.txt"
+
+	defer fs.Remove(path)
+
+	for _, content := range []string{"one", "two", "three"} {
+		if _, err := fs.Write(path, []byte(content), -1, filesystem.WriteFlagCreate|filesystem.WriteFlagTruncate); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	info, err := fs.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Meta.Content["etag"] == "" || info.Meta.Content["storage_class"] == "" {
+		t.Errorf("expected etag and storage class in meta, got %v", info.Meta.Content)
+	}
+	if info.Meta.Content["version_id"] == "" {
+		t.Skip("test bucket does not have versioning enabled")
+	}
+
+	versions, err := fs.ReadDir("/.versions/versions_test.txt")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(versions) < 4 || versions[len(versions)-1].Name != restoreCtl {
+		t.Fatalf("expected versions and restore file, got %+v", versions)
+	}
+
+	// Versions are listed newest first
+	oldest := versions[2].Name
+	data, err := fs.Read("/.versions/versions_test.txt/"+oldest, 0, -1)
+	if err != nil || string(data) != "one" {
+		t.Fatalf("expected oldest version content, got %q, %v", data, err)
+	}
+
+	if _, err := fs.Write("/.versions/versions_test.txt/"+oldest, []byte("x"), -1, filesystem.WriteFlagNone); err == nil {
+		t.Error("expected versions to be read-only")
+	}
+
+	if _, err := fs.Write("/.versions/versions_test.txt/restore", []byte(oldest+"\n"), -1, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	data, err = readIgnoreEOF(fs, path)
+	if err != nil || string(data) != "one" {
+		t.Fatalf("expected restored content, got %q, %v", data, err)
+	}
+}
+
+func TestListDirCacheWriteThrough(t *testing.T) {
+	c := NewListDirCache(10, time.Minute, true)
+
+	// Nothing is known about directories that were never listed
+	if info, listed := c.Lookup("docs", "a.txt"); info != nil || listed {
+		t.Fatalf("expected no listing, got %+v, %v", info, listed)
+	}
+	c.Upsert("docs", filesystem.FileInfo{Name: "a.txt"})
+	if _, ok := c.Get("docs"); ok {
+		t.Fatal("Upsert must not create a listing")
+	}
+
+	c.Put("docs", []filesystem.FileInfo{{Name: "a.txt", Size: 1}, {Name: "b.txt", Size: 2}})
+	if info, listed := c.Lookup("docs", "b.txt"); info == nil || !listed || info.Size != 2 {
+		t.Fatalf("expected b.txt, got %+v, %v", info, listed)
+	}
+	if info, listed := c.Lookup("docs", "c.txt"); info != nil || !listed {
+		t.Fatalf("expected negative lookup, got %+v, %v", info, listed)
+	}
+
+	c.Upsert("docs", filesystem.FileInfo{Name: "a.txt", Size: 10})
+	c.Upsert("docs", filesystem.FileInfo{Name: "c.txt", Size: 3})
+	c.Delete("docs", "b.txt")
+
+	files, _ := c.Get("docs")
+	if len(files) != 2 || files[0].Name != "a.txt" || files[0].Size != 10 || files[1].Name != "c.txt" {
+		t.Fatalf("unexpected listing %+v", files)
+	}
+	if info, _ := c.Lookup("docs", "c.txt"); info == nil || info.Size != 3 {
+		t.Fatalf("expected c.txt after delete reindexed, got %+v", info)
+	}
+
+	// Write-through does not extend the lifetime of a listing
+	short := NewListDirCache(10, 50*time.Millisecond, true)
+	short.Put("docs", nil)
+	time.Sleep(30 * time.Millisecond)
+	short.Upsert("docs", filesystem.FileInfo{Name: "a.txt"})
+	time.Sleep(30 * time.Millisecond)
+	if _, listed := short.Lookup("docs", "a.txt"); listed {
+		t.Fatal("expected listing to expire")
+	}
+}
+
+// TestS3FSCacheWriteThrough tests that local changes keep cached listings valid
+func TestS3FSCacheWriteThrough(t *testing.T) {
+	fs := newTestFS(t)
+	dir := "/cache_test"
+	fs.RemoveAll(dir)
+	defer fs.RemoveAll(dir)
+
+	if err := fs.Mkdir(dir, 0755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	if _, err := fs.Write(dir+"/a.txt", []byte("a"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if _, err := fs.ReadDir(dir); err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+
+	if _, err := fs.Write(dir+"/b.txt", []byte("bb"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if _, err := fs.Write(dir+"/sub/c.txt", []byte("c"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := fs.Remove(dir + "/a.txt"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+
+	files, err := fs.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	names := make(map[string]filesystem.FileInfo)
+	for _, f := range files {
+		names[f.Name] = f
+	}
+	if len(names) != 2 || names["b.txt"].Size != 2 || !names["sub"].IsDir {
+		t.Fatalf("unexpected listing %+v", files)
+	}
+
+	info, err := fs.Stat(dir + "/b.txt")
+	if err != nil || info.Size != 2 || info.Meta.Content["etag"] == "" || info.Meta.Content["bucket"] == "" {
+		t.Fatalf("unexpected stat %+v, %v", info, err)
+	}
+	if _, err := fs.Stat(dir + "/a.txt"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Fatalf("expected removed file to be gone, got %v", err)
+	}
+}
+
+func TestParsePresignRequest(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    presignRequest
+		wantErr bool
+	}{
+		{"/data/a.bin", presignRequest{method: "GET", path: "data/a.bin", expires: defaultPresignExpiry}, false},
+		{"GET /data/a.bin 1h\n", presignRequest{method: "GET", path: "data/a.bin", expires: time.Hour}, false},
+		{"put data/a.bin", presignRequest{method: "PUT", path: "data/a.bin", expires: defaultPresignExpiry}, false},
+		{"PUT /my file.txt 30m", presignRequest{method: "PUT", path: "my file.txt", expires: 30 * time.Minute}, false},
+		{"GET /a.bin 200h", presignRequest{}, true},
+		{"GET /data/", presignRequest{}, true},
+		{"", presignRequest{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parsePresignRequest([]byte(tt.in), defaultPresignExpiry)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parsePresignRequest(%q) = %+v; want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parsePresignRequest(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+// TestS3FSPresign tests generating presigned URLs through the control file
+func TestS3FSPresign(t *testing.T) {
+	fs := newTestFS(t)
+	path := "/presign_test.txt"
+	defer fs.Remove(path)
+
+	if _, err := fs.Write(path, []byte("hello"), -1, filesystem.WriteFlagCreate); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if _, err := fs.Write("/.presign", []byte("GET "+path+" 5m"), -1, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("presign failed: %v", err)
+	}
+
+	url, err := fs.Read("/.presign", 0, -1)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	resp, err := http.Get(strings.TrimSpace(string(url)))
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "hello" {
+		t.Fatalf("unexpected response %d %q", resp.StatusCode, body)
+	}
+
+	if _, err := fs.Write("/.presign", []byte("GET /presign_missing.txt"), -1, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("expected not found for missing file, got %v", err)
+	}
+	if err := fs.Remove("/.presign"); err == nil {
+		t.Error("expected control file removal to fail")
+	}
+}
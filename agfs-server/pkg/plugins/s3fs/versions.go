@@ -0,0 +1,242 @@
+package s3fs
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+const (
+	versionsDir = ".versions" // Virtual directory listing previous versions of a directory's files
+	restoreCtl  = "restore"   // Control file: write a version ID to restore it
+)
+
+// versionPath is a path inside a virtual .versions directory
+//
+//	<dir>/.versions                        - files of <dir> that have versions
+//	<dir>/.versions/<name>                 - versions of <dir>/<name>
+//	<dir>/.versions/<name>/<versionId>     - content of one version
+//	<dir>/.versions/<name>/restore         - restore control file
+//
+// Version IDs are path-escaped, as S3 does not rule out "/" in them.
+type versionPath struct {
+	dir       string // Directory holding the .versions directory
+	name      string // File name, empty for the .versions directory itself
+	versionID string // Version ID or restoreCtl, empty for a file's version directory
+}
+
+// file returns the S3 path of the versioned file
+func (v versionPath) file() string {
+	if v.dir == "" {
+		return v.name
+	}
+	return v.dir + "/" + v.name
+}
+
+// parseVersionPath checks whether a normalized S3 path is inside a .versions
+// directory
+func parseVersionPath(path string) (versionPath, bool) {
+	parts := strings.Split(path, "/")
+	for i := len(parts) - 1; i >= 0 && i >= len(parts)-3; i-- {
+		if parts[i] != versionsDir {
+			continue
+		}
+		v := versionPath{dir: strings.Join(parts[:i], "/")}
+		if i+1 < len(parts) {
+			v.name = parts[i+1]
+		}
+		if i+2 < len(parts) {
+			v.versionID = unescapeVersionID(parts[i+2])
+		}
+		return v, true
+	}
+	return versionPath{}, false
+}
+
+func versionDirInfo(name string) *filesystem.FileInfo {
+	return &filesystem.FileInfo{
+		Name:    name,
+		Mode:    0555,
+		ModTime: time.Now(),
+		IsDir:   true,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "versions"},
+	}
+}
+
+func versionFileInfo(v ObjectVersion) filesystem.FileInfo {
+	content := map[string]string{
+		"version_id":    v.VersionID,
+		"etag":          v.ETag,
+		"storage_class": storageClass(v.StorageClass),
+	}
+	if v.IsLatest {
+		content["is_latest"] = "true"
+	}
+	return filesystem.FileInfo{
+		Name:    url.PathEscape(v.VersionID),
+		Size:    v.Size,
+		Mode:    0444,
+		ModTime: v.LastModified,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "version", Content: content},
+	}
+}
+
+func unescapeVersionID(s string) string {
+	if id, err := url.PathUnescape(s); err == nil {
+		return id
+	}
+	return s
+}
+
+// storageClass returns the storage class S3 reports, which is omitted for STANDARD
+func storageClass(class string) string {
+	if class == "" {
+		return "STANDARD"
+	}
+	return class
+}
+
+// listVersions returns the readable versions of a file, newest first
+// Delete markers are skipped since they have no content.
+func (fs *S3FS) listVersions(ctx context.Context, v versionPath) ([]ObjectVersion, error) {
+	all, err := fs.client.ListObjectVersions(ctx, v.dir, v.name)
+	if err != nil {
+		return nil, err
+	}
+	var versions []ObjectVersion
+	for _, version := range all {
+		if !version.IsDeleteMarker {
+			versions = append(versions, version)
+		}
+	}
+	return versions, nil
+}
+
+func (fs *S3FS) findVersion(ctx context.Context, v versionPath) (*ObjectVersion, error) {
+	versions, err := fs.listVersions(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+	for i := range versions {
+		if versions[i].VersionID == v.versionID {
+			return &versions[i], nil
+		}
+	}
+	return nil, filesystem.NewNotFoundError("stat", "/"+v.file()+"@"+v.versionID)
+}
+
+func (fs *S3FS) versionStat(v versionPath) (*filesystem.FileInfo, error) {
+	ctx := context.Background()
+
+	switch {
+	case v.name == "":
+		return versionDirInfo(versionsDir), nil
+	case v.versionID == "":
+		versions, err := fs.listVersions(ctx, v)
+		if err != nil {
+			return nil, err
+		}
+		if len(versions) == 0 {
+			return nil, filesystem.NewNotFoundError("stat", "/"+v.file())
+		}
+		return versionDirInfo(v.name), nil
+	case v.versionID == restoreCtl:
+		return &filesystem.FileInfo{
+			Name:    restoreCtl,
+			Mode:    0200,
+			ModTime: time.Now(),
+			Meta:    filesystem.MetaData{Name: PluginName, Type: "control"},
+		}, nil
+	}
+
+	version, err := fs.findVersion(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+	info := versionFileInfo(*version)
+	return &info, nil
+}
+
+func (fs *S3FS) versionReadDir(v versionPath) ([]filesystem.FileInfo, error) {
+	ctx := context.Background()
+
+	if v.versionID != "" {
+		return nil, filesystem.NewNotDirectoryError("/" + v.file() + "/" + v.versionID)
+	}
+
+	if v.name == "" {
+		all, err := fs.client.ListObjectVersions(ctx, v.dir, "")
+		if err != nil {
+			return nil, err
+		}
+		var files []filesystem.FileInfo
+		seen := make(map[string]bool)
+		for _, version := range all {
+			if version.IsDeleteMarker || seen[version.Key] {
+				continue
+			}
+			seen[version.Key] = true
+			files = append(files, *versionDirInfo(version.Key))
+		}
+		return files, nil
+	}
+
+	versions, err := fs.listVersions(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, filesystem.NewNotFoundError("readdir", "/"+v.file())
+	}
+	files := make([]filesystem.FileInfo, 0, len(versions)+1)
+	for _, version := range versions {
+		files = append(files, versionFileInfo(version))
+	}
+	restore, _ := fs.versionStat(versionPath{dir: v.dir, name: v.name, versionID: restoreCtl})
+	files = append(files, *restore)
+	return files, nil
+}
+
+func (fs *S3FS) versionRead(v versionPath, offset, size int64) ([]byte, error) {
+	if v.name == "" || v.versionID == "" {
+		return nil, filesystem.NewInvalidArgumentError("path", "/"+v.file(), "is a directory")
+	}
+	if v.versionID == restoreCtl {
+		return nil, filesystem.NewPermissionDeniedError("read", "/"+v.file()+"/"+restoreCtl, "write a version ID to restore it")
+	}
+
+	data, err := fs.client.GetObjectVersion(context.Background(), v.file(), v.versionID, offset, size)
+	if err != nil {
+		if isNotFound(err) || strings.Contains(err.Error(), "NoSuchVersion") || strings.Contains(err.Error(), "InvalidArgument") {
+			return nil, filesystem.NewNotFoundError("read", "/"+v.file()+"@"+v.versionID)
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// versionRestore copies a previous version over the current one
+func (fs *S3FS) versionRestore(v versionPath, data []byte) error {
+	versionID := unescapeVersionID(strings.TrimSpace(string(data)))
+	if versionID == "" {
+		return filesystem.NewInvalidArgumentError("version", versionID, "expected a version ID")
+	}
+
+	ctx := context.Background()
+	if _, err := fs.findVersion(ctx, versionPath{dir: v.dir, name: v.name, versionID: versionID}); err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err := fs.client.RestoreObjectVersion(ctx, v.file(), versionID); err != nil {
+		return err
+	}
+	fs.dirCache.Invalidate(v.dir)
+	fs.statCache.Invalidate(v.file())
+	return nil
+}
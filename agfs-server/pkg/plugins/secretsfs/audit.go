@@ -0,0 +1,78 @@
+package secretsfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Audit record statuses
+const (
+	AuditStatusOK       = "ok"
+	AuditStatusNotFound = "not_found"
+	AuditStatusError    = "error"
+)
+
+// AuditRecord describes one access to a secret value
+type AuditRecord struct {
+	Time    time.Time `json:"time"`
+	Op      string    `json:"op"`
+	Path    string    `json:"path"`
+	Backend string    `json:"backend"`
+	Status  string    `json:"status"`
+	Bytes   int       `json:"bytes"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// auditLogger writes audit records to the server log and optionally to a JSON lines file
+type auditLogger struct {
+	file *os.File
+	mu   sync.Mutex // Serializes file appends
+}
+
+func newAuditLogger(path string) (*auditLogger, error) {
+	a := &auditLogger{}
+	if path == "" {
+		return a, nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	a.file = f
+	return a, nil
+}
+
+func (a *auditLogger) record(rec AuditRecord) {
+	log.WithFields(log.Fields{
+		"op":      rec.Op,
+		"path":    rec.Path,
+		"backend": rec.Backend,
+		"status":  rec.Status,
+		"bytes":   rec.Bytes,
+	}).Info("[secretsfs] audit")
+
+	if a.file == nil {
+		return
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.file.Write(append(line, '\n')); err != nil {
+		log.Warnf("[secretsfs] Failed to write audit log: %v", err)
+	}
+}
+
+func (a *auditLogger) Close() error {
+	if a.file == nil {
+		return nil
+	}
+	return a.file.Close()
+}
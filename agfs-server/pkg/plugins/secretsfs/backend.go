@@ -0,0 +1,118 @@
+package secretsfs
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+)
+
+// errSecretNotFound is returned by backends for missing secrets and directories
+var errSecretNotFound = errors.New("secret not found")
+
+// SecretEntry describes an entry in a secrets listing
+type SecretEntry struct {
+	Name  string
+	IsDir bool
+}
+
+// SecretsBackend defines the interface for secret providers
+// Paths are slash-separated and relative to the backend root, without
+// leading or trailing slashes ("" is the root).
+type SecretsBackend interface {
+	// Initialize initializes the backend with configuration
+	Initialize(config map[string]interface{}) error
+
+	// Close releases backend resources
+	Close() error
+
+	// GetType returns the backend type name
+	GetType() string
+
+	// List returns the entries directly under dir, or errSecretNotFound
+	List(dir string) ([]SecretEntry, error)
+
+	// Get returns the value of the secret at path, or errSecretNotFound
+	Get(path string) ([]byte, error)
+}
+
+// childEntries builds the listing of dir from a flat set of slash-separated names
+// Returns errSecretNotFound if no name lives under dir.
+func childEntries(names []string, dir string) ([]SecretEntry, error) {
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+
+	seen := make(map[string]bool)
+	var entries []SecretEntry
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) || name == prefix {
+			continue
+		}
+		rest := strings.TrimPrefix(name, prefix)
+		child, _, isDir := strings.Cut(rest, "/")
+		if child == "" || seen[child] {
+			continue
+		}
+		seen[child] = true
+		entries = append(entries, SecretEntry{Name: child, IsDir: isDir})
+	}
+
+	if dir != "" && len(entries) == 0 {
+		return nil, errSecretNotFound
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+// EnvBackend exposes environment variables with a common prefix as secrets
+// AGFS_SECRET_DB_PASSWORD becomes /DB_PASSWORD with the default prefix.
+type EnvBackend struct {
+	prefix string
+}
+
+func NewEnvBackend() *EnvBackend {
+	return &EnvBackend{}
+}
+
+func (b *EnvBackend) Initialize(cfg map[string]interface{}) error {
+	b.prefix = config.GetStringConfig(cfg, "env_prefix", "AGFS_SECRET_")
+	return nil
+}
+
+func (b *EnvBackend) Close() error {
+	return nil
+}
+
+func (b *EnvBackend) GetType() string {
+	return "env"
+}
+
+func (b *EnvBackend) names() []string {
+	var names []string
+	for _, kv := range os.Environ() {
+		key, _, _ := strings.Cut(kv, "=")
+		if name := strings.TrimPrefix(key, b.prefix); name != key && name != "" && !strings.Contains(name, "/") {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (b *EnvBackend) List(dir string) ([]SecretEntry, error) {
+	return childEntries(b.names(), dir)
+}
+
+func (b *EnvBackend) Get(path string) ([]byte, error) {
+	if path == "" || strings.Contains(path, "/") {
+		return nil, errSecretNotFound
+	}
+	value, ok := os.LookupEnv(b.prefix + path)
+	if !ok {
+		return nil, errSecretNotFound
+	}
+	return []byte(value), nil
+}
@@ -0,0 +1,128 @@
+package secretsfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+)
+
+// awsRequestTimeout bounds a single Secrets Manager call
+const awsRequestTimeout = 10 * time.Second
+
+// AWSBackend reads secrets from AWS Secrets Manager
+// Secret names containing "/" (prod/db/password) are exposed as nested paths.
+type AWSBackend struct {
+	client *secretsmanager.Client
+	prefix string // Name prefix ending in "/"; only secrets below it are exposed, with it stripped
+}
+
+func NewAWSBackend() *AWSBackend {
+	return &AWSBackend{}
+}
+
+func (b *AWSBackend) Initialize(cfg map[string]interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), awsRequestTimeout)
+	defer cancel()
+
+	opts := []func(*awsconfig.LoadOptions) error{}
+	if region := config.GetStringConfig(cfg, "aws_region", ""); region != "" {
+		opts = append(opts, awsconfig.WithRegion(region))
+	}
+	accessKey := config.GetStringConfig(cfg, "aws_access_key_id", "")
+	secretKey := config.GetStringConfig(cfg, "aws_secret_access_key", "")
+	if accessKey != "" && secretKey != "" {
+		opts = append(opts, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(accessKey, secretKey, ""),
+		))
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	clientOpts := []func(*secretsmanager.Options){}
+	// Custom endpoint for LocalStack and similar services
+	if endpoint := config.GetStringConfig(cfg, "aws_endpoint", ""); endpoint != "" {
+		clientOpts = append(clientOpts, func(o *secretsmanager.Options) {
+			o.BaseEndpoint = aws.String(endpoint)
+		})
+	}
+
+	b.client = secretsmanager.NewFromConfig(awsCfg, clientOpts...)
+	if prefix := strings.Trim(config.GetStringConfig(cfg, "aws_name_prefix", ""), "/"); prefix != "" {
+		b.prefix = prefix + "/"
+	}
+	return nil
+}
+
+func (b *AWSBackend) Close() error {
+	return nil
+}
+
+func (b *AWSBackend) GetType() string {
+	return "aws"
+}
+
+// names returns all secret names below the configured prefix, with the prefix stripped
+func (b *AWSBackend) names() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), awsRequestTimeout)
+	defer cancel()
+
+	input := &secretsmanager.ListSecretsInput{}
+	if b.prefix != "" {
+		input.Filters = []types.Filter{{Key: types.FilterNameStringTypeName, Values: []string{b.prefix}}}
+	}
+
+	var names []string
+	paginator := secretsmanager.NewListSecretsPaginator(b.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list secrets: %w", err)
+		}
+		for _, s := range page.SecretList {
+			// The name filter is a prefix match on words, so check again
+			name := aws.ToString(s.Name)
+			if strings.HasPrefix(name, b.prefix) {
+				names = append(names, strings.TrimPrefix(name, b.prefix))
+			}
+		}
+	}
+	return names, nil
+}
+
+func (b *AWSBackend) List(dir string) ([]SecretEntry, error) {
+	names, err := b.names()
+	if err != nil {
+		return nil, err
+	}
+	return childEntries(names, dir)
+}
+
+func (b *AWSBackend) Get(path string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), awsRequestTimeout)
+	defer cancel()
+
+	out, err := b.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(b.prefix + path)})
+	if err != nil {
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return nil, errSecretNotFound
+		}
+		return nil, fmt.Errorf("failed to get secret: %w", err)
+	}
+	if out.SecretString != nil {
+		return []byte(*out.SecretString), nil
+	}
+	return out.SecretBinary, nil
+}
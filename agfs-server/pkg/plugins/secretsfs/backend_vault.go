@@ -0,0 +1,175 @@
+package secretsfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+)
+
+// VaultBackend reads secrets from a HashiCorp Vault KV secrets engine over its HTTP API
+// Each Vault secret appears as a directory with one file per field:
+// secret/data/app/db {"user": "u", "password": "p"} becomes /app/db/user and /app/db/password.
+type VaultBackend struct {
+	addr      string
+	token     string
+	namespace string
+	mount     string
+	prefix    string // Optional path prefix inside the mount
+	kvVersion int
+	client    *http.Client
+}
+
+func NewVaultBackend() *VaultBackend {
+	return &VaultBackend{}
+}
+
+func (b *VaultBackend) Initialize(cfg map[string]interface{}) error {
+	b.addr = strings.TrimSuffix(config.GetStringConfig(cfg, "vault_addr", os.Getenv("VAULT_ADDR")), "/")
+	b.token = config.GetStringConfig(cfg, "vault_token", os.Getenv("VAULT_TOKEN"))
+	b.namespace = config.GetStringConfig(cfg, "vault_namespace", os.Getenv("VAULT_NAMESPACE"))
+	b.mount = strings.Trim(config.GetStringConfig(cfg, "vault_mount", "secret"), "/")
+	b.prefix = strings.Trim(config.GetStringConfig(cfg, "vault_path", ""), "/")
+	b.kvVersion = config.GetIntConfig(cfg, "vault_kv_version", 2)
+
+	if b.addr == "" {
+		return fmt.Errorf("vault_addr is required (or set VAULT_ADDR)")
+	}
+	if b.token == "" {
+		return fmt.Errorf("vault_token is required (or set VAULT_TOKEN)")
+	}
+	if b.kvVersion != 1 && b.kvVersion != 2 {
+		return fmt.Errorf("unsupported vault_kv_version: %d (valid options: 1, 2)", b.kvVersion)
+	}
+	b.client = &http.Client{Timeout: 10 * time.Second}
+	return nil
+}
+
+func (b *VaultBackend) Close() error {
+	return nil
+}
+
+func (b *VaultBackend) GetType() string {
+	return "vault"
+}
+
+// apiPath builds the API path for a secret path; kind is "data" or "metadata" for KV v2
+func (b *VaultBackend) apiPath(kind, path string) string {
+	full := strings.Trim(b.prefix+"/"+path, "/")
+	if b.kvVersion == 2 {
+		return "/v1/" + b.mount + "/" + kind + "/" + full
+	}
+	return "/v1/" + b.mount + "/" + full
+}
+
+// request sends a Vault API request, returning errSecretNotFound on 404
+func (b *VaultBackend) request(method, apiPath string, out interface{}) error {
+	req, err := http.NewRequest(method, b.addr+apiPath, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", b.token)
+	if b.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", b.namespace)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read vault response: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return errSecretNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("vault HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to decode vault response: %w", err)
+	}
+	return nil
+}
+
+// readSecret returns the fields of the secret at path
+func (b *VaultBackend) readSecret(path string) (map[string]interface{}, error) {
+	if path == "" {
+		return nil, errSecretNotFound
+	}
+	var resp struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := b.request("GET", b.apiPath("data", path), &resp); err != nil {
+		return nil, err
+	}
+	if b.kvVersion == 1 {
+		return resp.Data, nil
+	}
+	fields, _ := resp.Data["data"].(map[string]interface{})
+	if fields == nil {
+		// Deleted or destroyed version
+		return nil, errSecretNotFound
+	}
+	return fields, nil
+}
+
+func (b *VaultBackend) List(dir string) ([]SecretEntry, error) {
+	var resp struct {
+		Data struct {
+			Keys []string `json:"keys"`
+		} `json:"data"`
+	}
+	err := b.request("LIST", b.apiPath("metadata", dir), &resp)
+	if err == nil {
+		entries := make([]SecretEntry, 0, len(resp.Data.Keys))
+		for _, key := range resp.Data.Keys {
+			// Folders end with "/", secrets are listed as directories of fields
+			entries = append(entries, SecretEntry{Name: strings.TrimSuffix(key, "/"), IsDir: true})
+		}
+		return entries, nil
+	}
+	if err != errSecretNotFound {
+		return nil, err
+	}
+
+	// Not a folder: list the fields of the secret itself
+	fields, err := b.readSecret(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]SecretEntry, 0, len(fields))
+	for name := range fields {
+		entries = append(entries, SecretEntry{Name: name})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+func (b *VaultBackend) Get(path string) ([]byte, error) {
+	idx := strings.LastIndex(path, "/")
+	if idx < 0 {
+		return nil, errSecretNotFound
+	}
+	fields, err := b.readSecret(path[:idx])
+	if err != nil {
+		return nil, err
+	}
+	value, ok := fields[path[idx+1:]]
+	if !ok {
+		return nil, errSecretNotFound
+	}
+	if s, ok := value.(string); ok {
+		return []byte(s), nil
+	}
+	return json.Marshal(value)
+}
@@ -0,0 +1,561 @@
+package secretsfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PluginName = "secretsfs" // Name of this plugin
+)
+
+// Meta values for SecretsFS plugin
+const (
+	MetaValueSecret    = "secret"    // Secret value file
+	MetaValueDirectory = "directory" // Secret folder
+)
+
+// cachedListing is a directory listing kept for metadata_ttl
+type cachedListing struct {
+	entries []SecretEntry
+	expires time.Time
+}
+
+// SecretsFSPlugin exposes secrets from an external provider as read-only files
+// Listings are cached for metadata_ttl; secret values are fetched on every read
+// and never cached, and every read is recorded in the audit log.
+type SecretsFSPlugin struct {
+	backend     SecretsBackend
+	metadataTTL time.Duration
+	audit       *auditLogger
+
+	listings map[string]cachedListing
+	mu       sync.Mutex // Protects listings
+
+	metadata plugin.PluginMetadata
+}
+
+// NewSecretsFSPlugin creates a new secrets plugin
+func NewSecretsFSPlugin() *SecretsFSPlugin {
+	return &SecretsFSPlugin{
+		listings: make(map[string]cachedListing),
+		metadata: plugin.PluginMetadata{
+			Name:        PluginName,
+			Version:     "1.0.0",
+			Description: "Read-only secrets from Vault, AWS Secrets Manager or environment variables with audit logging",
+			Author:      "AGFS Server",
+		},
+	}
+}
+
+func (s *SecretsFSPlugin) Name() string {
+	return s.metadata.Name
+}
+
+func (s *SecretsFSPlugin) Validate(cfg map[string]interface{}) error {
+	allowedKeys := []string{
+		"mount_path", "backend", "metadata_ttl", "audit_log",
+		// env
+		"env_prefix",
+		// vault
+		"vault_addr", "vault_token", "vault_namespace", "vault_mount", "vault_path", "vault_kv_version",
+		// aws
+		"aws_region", "aws_access_key_id", "aws_secret_access_key", "aws_endpoint", "aws_name_prefix",
+	}
+	if err := config.ValidateOnlyKnownKeys(cfg, allowedKeys); err != nil {
+		return err
+	}
+
+	backendType := config.GetStringConfig(cfg, "backend", "env")
+	switch backendType {
+	case "env", "vault", "aws":
+	default:
+		return fmt.Errorf("unsupported backend: %s (valid options: env, vault, aws)", backendType)
+	}
+
+	for _, key := range []string{
+		"metadata_ttl", "audit_log", "env_prefix",
+		"vault_addr", "vault_token", "vault_namespace", "vault_mount", "vault_path",
+		"aws_region", "aws_access_key_id", "aws_secret_access_key", "aws_endpoint", "aws_name_prefix",
+	} {
+		if err := config.ValidateStringType(cfg, key); err != nil {
+			return err
+		}
+	}
+	if err := config.ValidateIntType(cfg, "vault_kv_version"); err != nil {
+		return err
+	}
+
+	if ttl, err := time.ParseDuration(config.GetStringConfig(cfg, "metadata_ttl", "10s")); err != nil || ttl < 0 {
+		return fmt.Errorf("invalid metadata_ttl: must be a non-negative duration such as \"10s\"")
+	}
+	return nil
+}
+
+func (s *SecretsFSPlugin) Initialize(cfg map[string]interface{}) error {
+	backendType := config.GetStringConfig(cfg, "backend", "env")
+
+	var backend SecretsBackend
+	switch backendType {
+	case "env":
+		backend = NewEnvBackend()
+	case "vault":
+		backend = NewVaultBackend()
+	case "aws":
+		backend = NewAWSBackend()
+	default:
+		return fmt.Errorf("unsupported backend: %s", backendType)
+	}
+	if err := backend.Initialize(cfg); err != nil {
+		return fmt.Errorf("failed to initialize %s backend: %w", backendType, err)
+	}
+	s.backend = backend
+
+	ttl, err := time.ParseDuration(config.GetStringConfig(cfg, "metadata_ttl", "10s"))
+	if err != nil {
+		return fmt.Errorf("invalid metadata_ttl: %w", err)
+	}
+	s.metadataTTL = ttl
+
+	auditPath := config.GetStringConfig(cfg, "audit_log", "")
+	if s.audit, err = newAuditLogger(auditPath); err != nil {
+		return err
+	}
+
+	log.Infof("[secretsfs] Initialized with backend=%s, metadata_ttl=%v, audit_log=%q", backendType, ttl, auditPath)
+	return nil
+}
+
+func (s *SecretsFSPlugin) GetFileSystem() filesystem.FileSystem {
+	return &secretsFS{plugin: s}
+}
+
+func (s *SecretsFSPlugin) GetReadme() string {
+	return `SecretsFS Plugin - Secrets as Read-Only Files
+
+This plugin exposes secrets from an external provider as read-only
+files, so agents and scripts can read credentials with cat instead of
+provider SDKs. Every read of a secret value is audit logged.
+
+STRUCTURE:
+  /secretsfs/
+    README                  - This documentation
+    <path>/<secret>         - Secret values (read-only)
+
+BACKENDS:
+  env     - Environment variables with a prefix (default AGFS_SECRET_):
+            AGFS_SECRET_DB_PASSWORD -> /secretsfs/DB_PASSWORD
+  vault   - HashiCorp Vault KV engine (v1 or v2). Each Vault secret is a
+            directory with one file per field:
+            secret/app/db {user, password} -> /secretsfs/app/db/password
+  aws     - AWS Secrets Manager. Names with "/" become nested paths:
+            prod/db/password -> /secretsfs/prod/db/password
+
+USAGE:
+  agfs:/> ls /secretsfs/app/db
+  agfs:/> cat /secretsfs/app/db/password
+
+CACHING:
+  Directory listings and file metadata are cached for metadata_ttl
+  (default 10s) so rotated or added secrets appear quickly. Secret values
+  are never cached: each read fetches the current value from the backend.
+  File sizes are reported as 0 so that listing never reads secret values.
+
+AUDIT LOG:
+  Each read is logged to the server log and, when audit_log is set,
+  appended to that file as a JSON line:
+  {"time":"...","op":"read","path":"/app/db/password","backend":"vault","status":"ok","bytes":12}
+
+CONFIGURATION:
+  [plugins.secretsfs]
+  enabled = true
+  path = "/secretsfs"
+
+    [plugins.secretsfs.config]
+    backend = "vault"
+    vault_addr = "https://vault.example.com:8200"
+    vault_token = "hvs...."
+    vault_mount = "secret"
+    metadata_ttl = "10s"
+    audit_log = "/var/log/agfs/secrets-audit.log"
+
+  AWS Secrets Manager:
+    [plugins.secretsfs.config]
+    backend = "aws"
+    aws_region = "us-east-1"
+    aws_name_prefix = "prod"
+
+  Environment variables:
+    [plugins.secretsfs.config]
+    backend = "env"
+    env_prefix = "AGFS_SECRET_"
+`
+}
+
+func (s *SecretsFSPlugin) GetConfigParams() []plugin.ConfigParameter {
+	return []plugin.ConfigParameter{
+		{
+			Name:        "backend",
+			Type:        "string",
+			Required:    false,
+			Default:     "env",
+			Description: "Secrets provider (env, vault, aws)",
+			Enum:        []string{"env", "vault", "aws"},
+		},
+		{
+			Name:        "metadata_ttl",
+			Type:        "string",
+			Required:    false,
+			Default:     "10s",
+			Description: "How long directory listings are cached (0s disables caching)",
+		},
+		{
+			Name:        "audit_log",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "File to append JSON audit records to (server log only if empty)",
+		},
+		{
+			Name:        "env_prefix",
+			Type:        "string",
+			Required:    false,
+			Default:     "AGFS_SECRET_",
+			Description: "Environment variable prefix (env backend)",
+		},
+		{
+			Name:        "vault_addr",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Vault address (vault backend, falls back to VAULT_ADDR)",
+		},
+		{
+			Name:        "vault_token",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Vault token (vault backend, falls back to VAULT_TOKEN)",
+			Secret:      true,
+		},
+		{
+			Name:        "vault_namespace",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Vault Enterprise namespace (vault backend)",
+		},
+		{
+			Name:        "vault_mount",
+			Type:        "string",
+			Required:    false,
+			Default:     "secret",
+			Description: "KV secrets engine mount (vault backend)",
+		},
+		{
+			Name:        "vault_path",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Path inside the mount to expose (vault backend)",
+		},
+		{
+			Name:        "vault_kv_version",
+			Type:        "int",
+			Required:    false,
+			Default:     "2",
+			Description: "KV engine version, 1 or 2 (vault backend)",
+			Enum:        []string{"1", "2"},
+		},
+		{
+			Name:        "aws_region",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "AWS region (aws backend, defaults to the AWS environment)",
+		},
+		{
+			Name:        "aws_access_key_id",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "AWS access key ID (aws backend, defaults to the AWS credential chain)",
+		},
+		{
+			Name:        "aws_secret_access_key",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "AWS secret access key (aws backend)",
+			Secret:      true,
+		},
+		{
+			Name:        "aws_endpoint",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Custom Secrets Manager endpoint, e.g. LocalStack (aws backend)",
+		},
+		{
+			Name:        "aws_name_prefix",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Only expose secrets below this name prefix (aws backend)",
+		},
+	}
+}
+
+func (s *SecretsFSPlugin) Shutdown() error {
+	var errs []error
+	if s.backend != nil {
+		errs = append(errs, s.backend.Close())
+	}
+	if s.audit != nil {
+		errs = append(errs, s.audit.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// secretsFS implements the read-only FileSystem interface for secrets
+type secretsFS struct {
+	plugin *SecretsFSPlugin
+}
+
+func cleanSecretPath(p string) string {
+	return strings.Trim(path.Clean("/"+p), "/")
+}
+
+// list returns the entries of dir, using the metadata cache
+func (sfs *secretsFS) list(dir string) ([]SecretEntry, error) {
+	p := sfs.plugin
+	p.mu.Lock()
+	cached, ok := p.listings[dir]
+	p.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.entries, nil
+	}
+
+	entries, err := p.backend.List(dir)
+	if err != nil {
+		return nil, err
+	}
+	if p.metadataTTL > 0 {
+		p.mu.Lock()
+		p.listings[dir] = cachedListing{entries: entries, expires: time.Now().Add(p.metadataTTL)}
+		p.mu.Unlock()
+	}
+	return entries, nil
+}
+
+// lookup finds the entry for a path via its parent listing
+func (sfs *secretsFS) lookup(p string) (SecretEntry, error) {
+	if p == "" {
+		return SecretEntry{IsDir: true}, nil
+	}
+	dir, name := path.Split(p)
+	entries, err := sfs.list(strings.TrimSuffix(dir, "/"))
+	if err != nil {
+		return SecretEntry{}, err
+	}
+	for _, e := range entries {
+		if e.Name == name {
+			return e, nil
+		}
+	}
+	return SecretEntry{}, errSecretNotFound
+}
+
+func mapBackendError(err error, op, p string) error {
+	if errors.Is(err, errSecretNotFound) {
+		return filesystem.NewNotFoundError(op, p)
+	}
+	return err
+}
+
+func (sfs *secretsFS) readOnly(op, p string) error {
+	return filesystem.NewPermissionDeniedError(op, p, "secretsfs is read-only")
+}
+
+func (sfs *secretsFS) Create(p string) error {
+	return sfs.readOnly("create", p)
+}
+
+func (sfs *secretsFS) Mkdir(p string, perm uint32) error {
+	return sfs.readOnly("mkdir", p)
+}
+
+func (sfs *secretsFS) Remove(p string) error {
+	return sfs.readOnly("remove", p)
+}
+
+func (sfs *secretsFS) RemoveAll(p string) error {
+	return sfs.readOnly("remove", p)
+}
+
+func (sfs *secretsFS) Read(p string, offset int64, size int64) ([]byte, error) {
+	data, err := sfs.readFile(p)
+	if err != nil {
+		return nil, err
+	}
+	return plugin.ApplyRangeRead(data, offset, size)
+}
+
+// readFile fetches a secret value from the backend and audit logs the access
+func (sfs *secretsFS) readFile(p string) ([]byte, error) {
+	clean := cleanSecretPath(p)
+	if clean == "README" {
+		return []byte(sfs.plugin.GetReadme()), nil
+	}
+	if clean == "" {
+		return nil, fmt.Errorf("is a directory: %s", p)
+	}
+
+	data, err := sfs.plugin.backend.Get(clean)
+
+	rec := AuditRecord{
+		Time:    time.Now(),
+		Op:      "read",
+		Path:    "/" + clean,
+		Backend: sfs.plugin.backend.GetType(),
+		Status:  AuditStatusOK,
+		Bytes:   len(data),
+	}
+	switch {
+	case errors.Is(err, errSecretNotFound):
+		rec.Status = AuditStatusNotFound
+	case err != nil:
+		rec.Status = AuditStatusError
+		rec.Error = err.Error()
+	}
+	sfs.plugin.audit.record(rec)
+
+	if errors.Is(err, errSecretNotFound) {
+		if entry, lerr := sfs.lookup(clean); lerr == nil && entry.IsDir {
+			return nil, fmt.Errorf("is a directory: %s", p)
+		}
+		return nil, filesystem.NewNotFoundError("read", p)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (sfs *secretsFS) Write(p string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	return 0, sfs.readOnly("write", p)
+}
+
+func (sfs *secretsFS) entryInfo(e SecretEntry, modTime time.Time) filesystem.FileInfo {
+	info := filesystem.FileInfo{
+		Name:    e.Name,
+		Size:    0,
+		Mode:    0400,
+		ModTime: modTime,
+		IsDir:   e.IsDir,
+		Meta: filesystem.MetaData{
+			Name: PluginName,
+			Type: MetaValueSecret,
+			Content: map[string]string{
+				"backend":      sfs.plugin.backend.GetType(),
+				"metadata_ttl": sfs.plugin.metadataTTL.String(),
+			},
+		},
+	}
+	if e.IsDir {
+		info.Mode = 0555
+		info.Meta.Type = MetaValueDirectory
+	}
+	return info
+}
+
+func readmeInfo(readme string) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    "README",
+		Size:    int64(len(readme)),
+		Mode:    0444,
+		ModTime: time.Now(),
+		IsDir:   false,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "doc"},
+	}
+}
+
+func (sfs *secretsFS) ReadDir(p string) ([]filesystem.FileInfo, error) {
+	clean := cleanSecretPath(p)
+	entries, err := sfs.list(clean)
+	if err != nil {
+		if errors.Is(err, errSecretNotFound) {
+			if entry, lerr := sfs.lookup(clean); lerr == nil && !entry.IsDir {
+				return nil, filesystem.NewNotDirectoryError(p)
+			}
+		}
+		return nil, mapBackendError(err, "readdir", p)
+	}
+
+	now := time.Now()
+	files := make([]filesystem.FileInfo, 0, len(entries)+1)
+	if clean == "" {
+		files = append(files, readmeInfo(sfs.plugin.GetReadme()))
+	}
+	for _, e := range entries {
+		files = append(files, sfs.entryInfo(e, now))
+	}
+	return files, nil
+}
+
+func (sfs *secretsFS) Stat(p string) (*filesystem.FileInfo, error) {
+	clean := cleanSecretPath(p)
+	if clean == "README" {
+		info := readmeInfo(sfs.plugin.GetReadme())
+		return &info, nil
+	}
+
+	entry, err := sfs.lookup(clean)
+	if err != nil {
+		return nil, mapBackendError(err, "stat", p)
+	}
+	if clean == "" {
+		entry.Name = "/"
+	}
+	info := sfs.entryInfo(entry, time.Now())
+	return &info, nil
+}
+
+func (sfs *secretsFS) Rename(oldPath, newPath string) error {
+	return sfs.readOnly("rename", oldPath)
+}
+
+func (sfs *secretsFS) Chmod(p string, mode uint32) error {
+	return sfs.readOnly("chmod", p)
+}
+
+func (sfs *secretsFS) Truncate(p string, size int64) error {
+	return sfs.readOnly("truncate", p)
+}
+
+func (sfs *secretsFS) Open(p string) (io.ReadCloser, error) {
+	data, err := sfs.readFile(p)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (sfs *secretsFS) OpenWrite(p string) (io.WriteCloser, error) {
+	return nil, sfs.readOnly("write", p)
+}
+
+// Ensure SecretsFSPlugin implements ServicePlugin
+var _ plugin.ServicePlugin = (*SecretsFSPlugin)(nil)
+var _ filesystem.FileSystem = (*secretsFS)(nil)
@@ -0,0 +1,214 @@
+package secretsfs
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+func newTestFS(t *testing.T, cfg map[string]interface{}) *secretsFS {
+	t.Helper()
+	p := NewSecretsFSPlugin()
+	if err := p.Validate(cfg); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if err := p.Initialize(cfg); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	t.Cleanup(func() { p.Shutdown() })
+	return p.GetFileSystem().(*secretsFS)
+}
+
+// readIgnoreEOF reads file content, ignoring io.EOF which is expected at end of file
+func readIgnoreEOF(fs *secretsFS, path string) ([]byte, error) {
+	content, err := fs.Read(path, 0, -1)
+	if err == io.EOF {
+		return content, nil
+	}
+	return content, err
+}
+
+func TestSecretsFSEnvBackendAndAudit(t *testing.T) {
+	t.Setenv("AGFSTEST_DB_PASSWORD", "hunter2")
+	t.Setenv("AGFSTEST_API_KEY", "abc")
+	auditPath := filepath.Join(t.TempDir(), "audit.log")
+	fs := newTestFS(t, map[string]interface{}{
+		"env_prefix": "AGFSTEST_",
+		"audit_log":  auditPath,
+	})
+
+	infos, err := fs.ReadDir("/")
+	if err != nil || len(infos) != 3 || infos[1].Name != "API_KEY" || infos[2].Name != "DB_PASSWORD" {
+		t.Fatalf("ReadDir / = %+v, %v", infos, err)
+	}
+	if infos[2].Mode != 0400 || infos[2].Size != 0 {
+		t.Errorf("secret info = %+v", infos[2])
+	}
+
+	data, err := readIgnoreEOF(fs, "/DB_PASSWORD")
+	if err != nil || string(data) != "hunter2" {
+		t.Errorf("read = %q, %v", data, err)
+	}
+	if _, err := fs.Read("/MISSING", 0, -1); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("missing secret: expected ErrNotFound, got %v", err)
+	}
+	if _, err := fs.Write("/DB_PASSWORD", []byte("x"), 0, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrPermissionDenied) {
+		t.Errorf("write: expected ErrPermissionDenied, got %v", err)
+	}
+	if err := fs.Remove("/DB_PASSWORD"); !errors.Is(err, filesystem.ErrPermissionDenied) {
+		t.Errorf("remove: expected ErrPermissionDenied, got %v", err)
+	}
+
+	// Only reads of secret values are audited, not listings or stats
+	fs.Stat("/API_KEY")
+	raw, err := os.ReadFile(auditPath)
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(raw)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 audit records, got %d: %s", len(lines), raw)
+	}
+	var rec AuditRecord
+	json.Unmarshal([]byte(lines[0]), &rec)
+	if rec.Path != "/DB_PASSWORD" || rec.Status != AuditStatusOK || rec.Bytes != 7 || rec.Backend != "env" {
+		t.Errorf("first audit record = %+v", rec)
+	}
+	json.Unmarshal([]byte(lines[1]), &rec)
+	if rec.Status != AuditStatusNotFound {
+		t.Errorf("second audit record = %+v", rec)
+	}
+}
+
+func TestSecretsFSMetadataTTL(t *testing.T) {
+	t.Setenv("AGFSTTL_ONE", "1")
+	fs := newTestFS(t, map[string]interface{}{"env_prefix": "AGFSTTL_", "metadata_ttl": "1h"})
+
+	if infos, _ := fs.ReadDir("/"); len(infos) != 2 {
+		t.Fatalf("ReadDir / = %+v", infos)
+	}
+	t.Setenv("AGFSTTL_TWO", "2")
+
+	// Listings are cached, values are always fetched fresh
+	if infos, _ := fs.ReadDir("/"); len(infos) != 2 {
+		t.Errorf("cached listing changed: %+v", infos)
+	}
+	if data, err := readIgnoreEOF(fs, "/TWO"); err != nil || string(data) != "2" {
+		t.Errorf("read of new secret = %q, %v", data, err)
+	}
+
+	uncached := newTestFS(t, map[string]interface{}{"env_prefix": "AGFSTTL_", "metadata_ttl": "0s"})
+	if infos, _ := uncached.ReadDir("/"); len(infos) != 3 {
+		t.Errorf("uncached listing = %+v", infos)
+	}
+}
+
+func TestSecretsFSVaultBackend(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		switch {
+		case r.Method == "LIST" && r.URL.Path == "/v1/secret/metadata/":
+			w.Write([]byte(`{"data":{"keys":["app/"]}}`))
+		case r.Method == "LIST" && r.URL.Path == "/v1/secret/metadata/app":
+			w.Write([]byte(`{"data":{"keys":["db"]}}`))
+		case r.Method == "GET" && r.URL.Path == "/v1/secret/data/app/db":
+			w.Write([]byte(`{"data":{"data":{"user":"admin","password":"s3cret","port":5432}}}`))
+		default:
+			http.Error(w, `{"errors":[]}`, http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	fs := newTestFS(t, map[string]interface{}{"backend": "vault", "vault_addr": srv.URL, "vault_token": "root"})
+
+	infos, err := fs.ReadDir("/app/db")
+	if err != nil || len(infos) != 3 || infos[0].Name != "password" || infos[0].IsDir {
+		t.Fatalf("ReadDir /app/db = %+v, %v", infos, err)
+	}
+	data, err := readIgnoreEOF(fs, "/app/db/password")
+	if err != nil || string(data) != "s3cret" {
+		t.Errorf("password = %q, %v", data, err)
+	}
+	data, _ = readIgnoreEOF(fs, "/app/db/port")
+	if string(data) != "5432" {
+		t.Errorf("port = %q", data)
+	}
+
+	info, err := fs.Stat("/app/db")
+	if err != nil || !info.IsDir {
+		t.Errorf("Stat /app/db = %+v, %v", info, err)
+	}
+	if _, err := fs.Read("/app/db", 0, -1); err == nil || !strings.Contains(err.Error(), "is a directory") {
+		t.Errorf("reading a secret directory: got %v", err)
+	}
+	if _, err := fs.Stat("/app/other"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("Stat missing: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestSecretsFSAWSBackend(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		switch r.Header.Get("X-Amz-Target") {
+		case "secretsmanager.ListSecrets":
+			w.Write([]byte(`{"SecretList":[{"Name":"prod/db/password"},{"Name":"prod/api"},{"Name":"dev/api"}]}`))
+		case "secretsmanager.GetSecretValue":
+			if body["SecretId"] == "prod/db/password" {
+				w.Write([]byte(`{"Name":"prod/db/password","SecretString":"pw"}`))
+				return
+			}
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"ResourceNotFoundException","message":"not found"}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	fs := newTestFS(t, map[string]interface{}{
+		"backend":               "aws",
+		"aws_region":            "us-east-1",
+		"aws_access_key_id":     "test",
+		"aws_secret_access_key": "test",
+		"aws_endpoint":          srv.URL,
+		"aws_name_prefix":       "prod",
+	})
+
+	infos, err := fs.ReadDir("/")
+	if err != nil || len(infos) != 3 || infos[1].Name != "api" || infos[2].Name != "db" || !infos[2].IsDir {
+		t.Fatalf("ReadDir / = %+v, %v", infos, err)
+	}
+	data, err := readIgnoreEOF(fs, "/db/password")
+	if err != nil || string(data) != "pw" {
+		t.Errorf("password = %q, %v", data, err)
+	}
+	if _, err := fs.Read("/api", 0, -1); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("missing secret: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestSecretsFSValidate(t *testing.T) {
+	p := NewSecretsFSPlugin()
+	if err := p.Validate(map[string]interface{}{"backend": "gcp"}); err == nil {
+		t.Error("expected error for unsupported backend")
+	}
+	if err := p.Validate(map[string]interface{}{"metadata_ttl": "soon"}); err == nil {
+		t.Error("expected error for invalid metadata_ttl")
+	}
+	if err := p.Initialize(map[string]interface{}{"backend": "vault", "vault_addr": "", "vault_token": ""}); err == nil && os.Getenv("VAULT_ADDR") == "" {
+		t.Error("expected error for vault without address")
+	}
+}
@@ -18,6 +18,7 @@ type ServerInfoFSPlugin struct {
 	startTime      time.Time
 	version        string
 	trafficMonitor TrafficStatsProvider
+	mountHealth    MountHealthProvider
 }
 
 // TrafficStatsProvider provides traffic statistics
@@ -25,6 +26,11 @@ type TrafficStatsProvider interface {
 	GetStats() interface{}
 }
 
+// MountHealthProvider provides the health of mounted plugins
+type MountHealthProvider interface {
+	GetMountHealth() interface{}
+}
+
 // NewServerInfoFSPlugin creates a new ServerInfoFS plugin
 func NewServerInfoFSPlugin() *ServerInfoFSPlugin {
 	return &ServerInfoFSPlugin{
@@ -38,6 +44,11 @@ func (p *ServerInfoFSPlugin) SetTrafficMonitor(tm TrafficStatsProvider) {
 	p.trafficMonitor = tm
 }
 
+// SetMountHealthProvider sets the source of mount health for the plugin
+func (p *ServerInfoFSPlugin) SetMountHealthProvider(mh MountHealthProvider) {
+	p.mountHealth = mh
+}
+
 func (p *ServerInfoFSPlugin) Name() string {
 	return "serverinfofs"
 }
@@ -87,12 +98,16 @@ USAGE:
   View real-time traffic:
     cat /traffic
 
+  View mount health:
+    cat /mounts
+
 FILES:
   /version  - Server version information
   /uptime   - Server uptime since start
   /info     - Complete server information (JSON)
   /stats    - Runtime statistics (goroutines, memory)
   /traffic  - Real-time network traffic statistics
+  /mounts   - Mounted plugins and their health checks (JSON)
   /README   - This file
 
 EXAMPLES:
@@ -124,6 +139,21 @@ EXAMPLES:
     "total_upload_bytes": 536870912,
     "uptime_seconds": 3600
   }
+
+  # Check mount health
+  agfs:/> cat /serverinfofs/mounts
+  [
+    {
+      "path": "/vectorfs",
+      "plugin": "vectorfs",
+      "health": {
+        "status": "unhealthy",
+        "error": "TiDB unreachable: dial tcp 10.0.0.5:4000: connect: connection refused",
+        "consecutive_failures": 3,
+        ...
+      }
+    }
+  ]
 `
 }
 
@@ -147,12 +177,13 @@ const (
 	fileVersion    = "/version"
 	fileStats      = "/stats"
 	fileTraffic    = "/traffic"
+	fileMounts     = "/mounts"
 	fileReadme     = "/README"
 )
 
 func (fs *serverInfoFS) isValidPath(path string) bool {
 	switch path {
-	case "/", fileServerInfo, fileUptime, fileVersion, fileStats, fileTraffic, fileReadme:
+	case "/", fileServerInfo, fileUptime, fileVersion, fileStats, fileTraffic, fileMounts, fileReadme:
 		return true
 	default:
 		return false
@@ -235,6 +266,16 @@ func (fs *serverInfoFS) Read(path string, offset int64, size int64) ([]byte, err
 			}
 		}
 
+	case fileMounts:
+		if fs.plugin.mountHealth == nil {
+			data = []byte("Mount health not available")
+		} else {
+			data, err = json.MarshalIndent(fs.plugin.mountHealth.GetMountHealth(), "", "  ")
+			if err != nil {
+				return nil, err
+			}
+		}
+
 	case fileReadme:
 		data = []byte(fs.plugin.GetReadme())
 
@@ -284,6 +325,7 @@ func (fs *serverInfoFS) ReadDir(path string) ([]filesystem.FileInfo, error) {
 	versionData, _ := fs.Read(fileVersion, 0, -1)
 	statsData, _ := fs.Read(fileStats, 0, -1)
 	trafficData, _ := fs.Read(fileTraffic, 0, -1)
+	mountsData, _ := fs.Read(fileMounts, 0, -1)
 
 	return []filesystem.FileInfo{
 		{
@@ -334,6 +376,14 @@ func (fs *serverInfoFS) ReadDir(path string) ([]filesystem.FileInfo, error) {
 			IsDir:   false,
 			Meta:    filesystem.MetaData{Name: "serverinfofs", Type: "traffic"},
 		},
+		{
+			Name:    "mounts",
+			Size:    int64(len(mountsData)),
+			Mode:    0444,
+			ModTime: now,
+			IsDir:   false,
+			Meta:    filesystem.MetaData{Name: "serverinfofs", Type: "info"},
+		},
 	}, nil
 }
 
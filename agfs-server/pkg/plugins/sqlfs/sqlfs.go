@@ -121,6 +121,7 @@ func (p *SQLFSPlugin) GetConfigParams() []plugin.ConfigParameter {
 			Required:    false,
 			Default:     "sqlite",
 			Description: "Database backend (sqlite, sqlite3, tidb)",
+			Enum:        []string{"sqlite", "sqlite3", "tidb", "mysql"},
 		},
 		{
 			Name:        "db_path",
@@ -135,6 +136,7 @@ func (p *SQLFSPlugin) GetConfigParams() []plugin.ConfigParameter {
 			Required:    false,
 			Default:     "",
 			Description: "Database connection string (DSN)",
+			Secret:      true,
 		},
 		{
 			Name:        "user",
@@ -149,6 +151,7 @@ func (p *SQLFSPlugin) GetConfigParams() []plugin.ConfigParameter {
 			Required:    false,
 			Default:     "",
 			Description: "Database password",
+			Secret:      true,
 		},
 		{
 			Name:        "host",
@@ -163,6 +166,8 @@ func (p *SQLFSPlugin) GetConfigParams() []plugin.ConfigParameter {
 			Required:    false,
 			Default:     "",
 			Description: "Database port",
+			Minimum:     plugin.Bound(1),
+			Maximum:     plugin.Bound(65535),
 		},
 		{
 			Name:        "database",
@@ -195,6 +200,17 @@ func (p *SQLFSPlugin) GetConfigParams() []plugin.ConfigParameter {
 	}
 }
 
+// HealthCheck reports whether the database is reachable
+func (p *SQLFSPlugin) HealthCheck() error {
+	if p.fs == nil {
+		return nil
+	}
+	if err := p.fs.Ping(); err != nil {
+		return fmt.Errorf("%s database unreachable: %w", p.backend.GetDriverName(), err)
+	}
+	return nil
+}
+
 func (p *SQLFSPlugin) Shutdown() error {
 	if p.fs != nil {
 		return p.fs.Close()
@@ -316,6 +332,11 @@ func (fs *SQLFS) Close() error {
 	return nil
 }
 
+// Ping checks the database connection
+func (fs *SQLFS) Ping() error {
+	return fs.db.Ping()
+}
+
 // getParentPath returns the parent directory path
 func getParentPath(path string) string {
 	if path == "/" {
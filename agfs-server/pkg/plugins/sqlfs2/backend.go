@@ -22,14 +22,18 @@ type Backend interface {
 	// GetTableColumns retrieves column names and types for a table
 	GetTableColumns(db *sql.DB, dbName, tableName string) ([]ColumnInfo, error)
 
+	// GetCatalog retrieves the tables and columns of all user databases
+	GetCatalog(db *sql.DB) (*Catalog, error)
+
 	// Name returns the backend name
 	Name() string
 }
 
 // ColumnInfo contains information about a table column
 type ColumnInfo struct {
-	Name string
-	Type string
+	Name       string
+	Type       string
+	PrimaryKey bool // Part of the primary key
 }
 
 // newBackend creates a backend instance based on the backend type
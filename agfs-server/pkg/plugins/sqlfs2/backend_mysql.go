@@ -129,13 +129,18 @@ func (b *MySQLBackend) GetTableColumns(db *sql.DB, dbName, tableName string) ([]
 	var columns []ColumnInfo
 	for rows.Next() {
 		var field, colType string
-		var null, key, extra interface{}
+		var null, extra interface{}
+		var key sql.NullString
 		var dflt interface{}
 
 		if err := rows.Scan(&field, &colType, &null, &key, &dflt, &extra); err != nil {
 			return nil, err
 		}
-		columns = append(columns, ColumnInfo{Name: field, Type: colType})
+		columns = append(columns, ColumnInfo{Name: field, Type: colType, PrimaryKey: key.String == "PRI"})
 	}
 	return columns, nil
 }
+
+func (b *MySQLBackend) GetCatalog(db *sql.DB) (*Catalog, error) {
+	return mysqlCatalog(db)
+}
@@ -80,7 +80,79 @@ func (b *SQLiteBackend) GetTableColumns(db *sql.DB, dbName, tableName string) ([
 		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
 			return nil, err
 		}
-		columns = append(columns, ColumnInfo{Name: name, Type: colType})
+		columns = append(columns, ColumnInfo{Name: name, Type: colType, PrimaryKey: pk > 0})
 	}
 	return columns, nil
 }
+
+func (b *SQLiteBackend) GetCatalog(db *sql.DB) (*Catalog, error) {
+	catalog := &Catalog{}
+
+	rows, err := db.Query("SELECT name, type FROM sqlite_master WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		t := CatalogTable{Database: "main"}
+		if err := rows.Scan(&t.Name, &t.Type); err != nil {
+			return nil, err
+		}
+		catalog.Tables = append(catalog.Tables, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Row estimates are only known if ANALYZE has been run
+	estimates := make(map[string]int64)
+	if statRows, err := db.Query("SELECT tbl, stat FROM sqlite_stat1"); err == nil {
+		for statRows.Next() {
+			var tbl, stat string
+			if err := statRows.Scan(&tbl, &stat); err != nil {
+				continue
+			}
+			var n int64
+			if _, err := fmt.Sscan(stat, &n); err == nil {
+				estimates[tbl] = n
+			}
+		}
+		statRows.Close()
+	}
+
+	for i := range catalog.Tables {
+		t := &catalog.Tables[i]
+		if n, ok := estimates[t.Name]; ok {
+			t.RowEstimate = &n
+		}
+
+		colRows, err := db.Query("SELECT cid, name, type, \"notnull\", dflt_value, pk FROM pragma_table_info(?)", t.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get columns of %s: %w", t.Name, err)
+		}
+		for colRows.Next() {
+			var cid, notNull, pk int
+			var dflt sql.NullString
+			c := CatalogColumn{Database: "main", Table: t.Name}
+			if err := colRows.Scan(&cid, &c.Name, &c.Type, &notNull, &dflt, &pk); err != nil {
+				colRows.Close()
+				return nil, err
+			}
+			c.Position = cid + 1
+			c.Nullable = notNull == 0 && pk == 0
+			if pk > 0 {
+				c.Key = "PRI"
+			}
+			if dflt.Valid {
+				c.Default = &dflt.String
+			}
+			catalog.Columns = append(catalog.Columns, c)
+		}
+		err = colRows.Err()
+		colRows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return catalog, nil
+}
@@ -231,17 +231,22 @@ func (b *TiDBBackend) GetTableColumns(db *sql.DB, dbName, tableName string) ([]C
 	var columns []ColumnInfo
 	for rows.Next() {
 		var field, colType string
-		var null, key, extra interface{}
+		var null, extra interface{}
+		var key sql.NullString
 		var dflt interface{}
 
 		if err := rows.Scan(&field, &colType, &null, &key, &dflt, &extra); err != nil {
 			return nil, err
 		}
-		columns = append(columns, ColumnInfo{Name: field, Type: colType})
+		columns = append(columns, ColumnInfo{Name: field, Type: colType, PrimaryKey: key.String == "PRI"})
 	}
 	return columns, nil
 }
 
+func (b *TiDBBackend) GetCatalog(db *sql.DB) (*Catalog, error) {
+	return mysqlCatalog(db)
+}
+
 // extractDatabaseName extracts database name from DSN or config
 func extractDatabaseName(dsn string, configDB string) string {
 	if dsn != "" {
@@ -0,0 +1,284 @@
+package sqlfs2
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+const (
+	// catalogDir is the root-level directory with the schema of all databases
+	catalogDir = ".catalog"
+
+	// catalogTTL is how long a built catalog is served before it is rebuilt,
+	// so that a file read in chunks sees one version of it
+	catalogTTL = 5 * time.Second
+)
+
+// catalogFiles are the files in /.catalog
+var catalogFiles = []string{"tables.json", "columns.json", "schema.json"}
+
+// Catalog is the schema of all user databases of a backend
+type Catalog struct {
+	Tables  []CatalogTable
+	Columns []CatalogColumn
+}
+
+// CatalogTable describes a table or view. Sizes and row counts are the
+// backend's estimates and are omitted if it has none.
+type CatalogTable struct {
+	Database    string `json:"database"`
+	Name        string `json:"name"`
+	Type        string `json:"type"` // table or view
+	Engine      string `json:"engine,omitempty"`
+	RowEstimate *int64 `json:"row_estimate,omitempty"`
+	DataBytes   *int64 `json:"data_bytes,omitempty"`
+	IndexBytes  *int64 `json:"index_bytes,omitempty"`
+	Comment     string `json:"comment,omitempty"`
+}
+
+// CatalogColumn describes a column of a table or view
+type CatalogColumn struct {
+	Database string  `json:"database"`
+	Table    string  `json:"table"`
+	Name     string  `json:"name"`
+	Position int     `json:"position"` // 1-based
+	Type     string  `json:"type"`
+	Nullable bool    `json:"nullable"`
+	Key      string  `json:"key,omitempty"` // PRI, UNI or MUL
+	Default  *string `json:"default,omitempty"`
+	Extra    string  `json:"extra,omitempty"`
+	Comment  string  `json:"comment,omitempty"`
+}
+
+// catalogCache holds the files of the last built catalog
+type catalogCache struct {
+	mu    sync.Mutex
+	built time.Time
+	files map[string][]byte
+}
+
+// isCatalogFile checks if the given name is a file in /.catalog
+func isCatalogFile(name string) bool {
+	for _, f := range catalogFiles {
+		if name == f {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCatalogPath checks if a path is /.catalog or a file in it, returning
+// the file name ("" for the directory)
+func parseCatalogPath(path string) (name string, ok bool) {
+	path = strings.Trim(path, "/")
+	if path == catalogDir {
+		return "", true
+	}
+	if name, found := strings.CutPrefix(path, catalogDir+"/"); found {
+		return name, true
+	}
+	return "", false
+}
+
+// catalogFile returns the content of a catalog file, building the catalog if
+// the cached one is older than catalogTTL
+func (p *SQLFS2Plugin) catalogFile(name string) ([]byte, error) {
+	if !isCatalogFile(name) {
+		return nil, filesystem.NewNotFoundError("read", "/"+catalogDir+"/"+name)
+	}
+
+	p.catalog.mu.Lock()
+	defer p.catalog.mu.Unlock()
+
+	if p.catalog.files == nil || time.Since(p.catalog.built) > catalogTTL {
+		catalog, err := p.backend.GetCatalog(p.db)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build catalog: %w", err)
+		}
+		files, err := renderCatalog(p.backend.Name(), catalog, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		p.catalog.files = files
+		p.catalog.built = time.Now()
+	}
+	return p.catalog.files[name], nil
+}
+
+// renderCatalog renders the catalog files: tables.json and columns.json list
+// all tables and all columns, and schema.json nests the columns in their
+// tables and the tables in their databases
+func renderCatalog(backend string, catalog *Catalog, now time.Time) (map[string][]byte, error) {
+	type schemaTable struct {
+		CatalogTable
+		Columns []CatalogColumn `json:"columns"`
+	}
+	type schemaDatabase struct {
+		Name   string         `json:"name"`
+		Tables []*schemaTable `json:"tables"`
+	}
+
+	tables := catalog.Tables
+	if tables == nil {
+		tables = []CatalogTable{}
+	}
+	columns := catalog.Columns
+	if columns == nil {
+		columns = []CatalogColumn{}
+	}
+
+	var databases []*schemaDatabase
+	dbIndex := make(map[string]*schemaDatabase)
+	tableIndex := make(map[[2]string]*schemaTable)
+	for _, t := range tables {
+		db, ok := dbIndex[t.Database]
+		if !ok {
+			db = &schemaDatabase{Name: t.Database}
+			dbIndex[t.Database] = db
+			databases = append(databases, db)
+		}
+		st := &schemaTable{CatalogTable: t, Columns: []CatalogColumn{}}
+		db.Tables = append(db.Tables, st)
+		tableIndex[[2]string{t.Database, t.Name}] = st
+	}
+	for _, c := range columns {
+		if st, ok := tableIndex[[2]string{c.Database, c.Table}]; ok {
+			st.Columns = append(st.Columns, c)
+		}
+	}
+	sort.Slice(databases, func(i, j int) bool { return databases[i].Name < databases[j].Name })
+	if databases == nil {
+		databases = []*schemaDatabase{}
+	}
+
+	contents := map[string]interface{}{
+		"tables.json":  map[string]interface{}{"backend": backend, "generated_at": now, "tables": tables},
+		"columns.json": map[string]interface{}{"backend": backend, "generated_at": now, "columns": columns},
+		"schema.json":  map[string]interface{}{"backend": backend, "generated_at": now, "databases": databases},
+	}
+	files := make(map[string][]byte, len(contents))
+	for name, content := range contents {
+		data, err := json.MarshalIndent(content, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to render %s: %w", name, err)
+		}
+		files[name] = append(data, '\n')
+	}
+	return files, nil
+}
+
+// catalogFileInfo returns the FileInfo of /.catalog or a file in it
+func (fs *sqlfs2FS) catalogFileInfo(name string) (*filesystem.FileInfo, error) {
+	if name == "" {
+		return &filesystem.FileInfo{
+			Name:    catalogDir,
+			Size:    0,
+			Mode:    0555,
+			ModTime: time.Now(),
+			IsDir:   true,
+			Meta:    filesystem.MetaData{Name: PluginName, Type: "catalog"},
+		}, nil
+	}
+
+	data, err := fs.plugin.catalogFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return &filesystem.FileInfo{
+		Name:    name,
+		Size:    int64(len(data)),
+		Mode:    0444,
+		ModTime: time.Now(),
+		IsDir:   false,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "catalog-file"},
+	}, nil
+}
+
+// mysqlSystemSchemas are the schemas left out of the catalog of MySQL and TiDB
+const mysqlSystemSchemas = "'information_schema', 'mysql', 'performance_schema', 'sys', 'metrics_schema'"
+
+// mysqlCatalog builds the catalog of a MySQL-compatible backend from
+// information_schema
+func mysqlCatalog(db *sql.DB) (*Catalog, error) {
+	catalog := &Catalog{}
+
+	rows, err := db.Query(`SELECT TABLE_SCHEMA, TABLE_NAME, TABLE_TYPE, ENGINE, TABLE_ROWS,
+			DATA_LENGTH, INDEX_LENGTH, TABLE_COMMENT
+		FROM information_schema.TABLES
+		WHERE LOWER(TABLE_SCHEMA) NOT IN (` + mysqlSystemSchemas + `)
+		ORDER BY TABLE_SCHEMA, TABLE_NAME`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tables: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t CatalogTable
+		var tableType string
+		var engine, comment sql.NullString
+		var tableRows, dataLength, indexLength sql.NullInt64
+		if err := rows.Scan(&t.Database, &t.Name, &tableType, &engine, &tableRows,
+			&dataLength, &indexLength, &comment); err != nil {
+			return nil, err
+		}
+		t.Type = "table"
+		if tableType == "VIEW" {
+			t.Type = "view"
+		}
+		t.Engine = engine.String
+		t.Comment = comment.String
+		t.RowEstimate = nullInt64Ptr(tableRows)
+		t.DataBytes = nullInt64Ptr(dataLength)
+		t.IndexBytes = nullInt64Ptr(indexLength)
+		catalog.Tables = append(catalog.Tables, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	colRows, err := db.Query(`SELECT TABLE_SCHEMA, TABLE_NAME, COLUMN_NAME, ORDINAL_POSITION,
+			COLUMN_TYPE, IS_NULLABLE, COLUMN_KEY, COLUMN_DEFAULT, EXTRA, COLUMN_COMMENT
+		FROM information_schema.COLUMNS
+		WHERE LOWER(TABLE_SCHEMA) NOT IN (` + mysqlSystemSchemas + `)
+		ORDER BY TABLE_SCHEMA, TABLE_NAME, ORDINAL_POSITION`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query columns: %w", err)
+	}
+	defer colRows.Close()
+
+	for colRows.Next() {
+		var c CatalogColumn
+		var nullable string
+		var key, extra, comment, dflt sql.NullString
+		if err := colRows.Scan(&c.Database, &c.Table, &c.Name, &c.Position, &c.Type,
+			&nullable, &key, &dflt, &extra, &comment); err != nil {
+			return nil, err
+		}
+		c.Nullable = nullable == "YES"
+		c.Key = key.String
+		c.Extra = extra.String
+		c.Comment = comment.String
+		if dflt.Valid {
+			c.Default = &dflt.String
+		}
+		catalog.Columns = append(catalog.Columns, c)
+	}
+	if err := colRows.Err(); err != nil {
+		return nil, err
+	}
+	return catalog, nil
+}
+
+func nullInt64Ptr(n sql.NullInt64) *int64 {
+	if !n.Valid {
+		return nil
+	}
+	return &n.Int64
+}
@@ -0,0 +1,320 @@
+package sqlfs2
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+)
+
+// errReadOnly is returned by statements that would modify a read-only
+// connection
+var errReadOnly = errors.New("connection is read-only: only SELECT, SHOW, DESCRIBE and EXPLAIN are allowed")
+
+// connectionConfigs returns the configs of the named connections of a mount,
+// or nil if it has none. The keys of the mount config other than connections
+// apply to every connection, which can override them.
+func connectionConfigs(cfg map[string]interface{}) (map[string]map[string]interface{}, error) {
+	if err := config.ValidateMapType(cfg, "connections"); err != nil {
+		return nil, err
+	}
+	raw, ok := cfg["connections"].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("connections must name at least one connection")
+	}
+
+	configs := make(map[string]map[string]interface{}, len(raw))
+	for name, v := range raw {
+		if name == "" || strings.HasPrefix(name, ".") || strings.Contains(name, "/") {
+			return nil, fmt.Errorf("connections: invalid connection name %q", name)
+		}
+		conn, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("connections.%s must be a map", name)
+		}
+		if _, nested := conn["connections"]; nested {
+			return nil, fmt.Errorf("connections.%s: connections cannot be nested", name)
+		}
+
+		merged := make(map[string]interface{}, len(cfg)+len(conn))
+		for key, value := range cfg {
+			if key != "connections" {
+				merged[key] = value
+			}
+		}
+		for key, value := range conn {
+			merged[key] = value
+		}
+		configs[name] = merged
+	}
+	return configs, nil
+}
+
+// checkWritable returns errReadOnly if the session is read-only and the
+// statement is not a query
+func (s *Session) checkWritable(stmt string) error {
+	if s.readOnly && !isQueryStatement(stmt) {
+		return errReadOnly
+	}
+	return nil
+}
+
+// connectionsFS is the file system of a mount with named connections: each
+// connection is a top-level directory with the file system of its database
+// server
+type connectionsFS struct {
+	plugin       *SQLFS2Plugin
+	fss          map[string]*sqlfs2FS
+	handles      map[int64]*connectionHandle
+	handlesMu    sync.Mutex
+	nextHandleID int64
+}
+
+func newConnectionsFS(p *SQLFS2Plugin) *connectionsFS {
+	fss := make(map[string]*sqlfs2FS, len(p.connections))
+	for name, conn := range p.connections {
+		fss[name] = conn.GetFileSystem().(*sqlfs2FS)
+	}
+	return &connectionsFS{
+		plugin:       p,
+		fss:          fss,
+		handles:      make(map[int64]*connectionHandle),
+		nextHandleID: 1,
+	}
+}
+
+// splitConnectionPath splits a path into its connection name and the path in
+// the file system of the connection: /prod/db/table -> ("prod", "/db/table")
+func splitConnectionPath(path string) (name, rest string) {
+	path = strings.TrimPrefix(path, "/")
+	name, rest, _ = strings.Cut(path, "/")
+	return name, "/" + rest
+}
+
+// resolve returns the file system of the connection of a path and the path
+// in it
+func (cfs *connectionsFS) resolve(op, path string) (*sqlfs2FS, string, error) {
+	name, rest := splitConnectionPath(path)
+	fs, ok := cfs.fss[name]
+	if !ok {
+		return nil, "", filesystem.NewNotFoundError(op, path)
+	}
+	return fs, rest, nil
+}
+
+// isRoot checks if a path is the root directory, which lists the connections
+func isRoot(path string) bool {
+	return strings.Trim(path, "/") == ""
+}
+
+func (cfs *connectionsFS) Create(path string) error {
+	return fmt.Errorf("operation not supported: create")
+}
+
+func (cfs *connectionsFS) Mkdir(path string, perm uint32) error {
+	return fmt.Errorf("operation not supported: mkdir")
+}
+
+func (cfs *connectionsFS) Remove(path string) error {
+	return fmt.Errorf("operation not supported: remove")
+}
+
+func (cfs *connectionsFS) RemoveAll(path string) error {
+	fs, rest, err := cfs.resolve("removeall", path)
+	if err != nil {
+		return err
+	}
+	if isRoot(rest) {
+		return fmt.Errorf("cannot remove connection: %s", path)
+	}
+	return fs.RemoveAll(rest)
+}
+
+func (cfs *connectionsFS) Read(path string, offset int64, size int64) ([]byte, error) {
+	if isRoot(path) {
+		return nil, filesystem.NewInvalidArgumentError("path", path, "is a directory")
+	}
+	fs, rest, err := cfs.resolve("read", path)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Read(rest, offset, size)
+}
+
+func (cfs *connectionsFS) Write(path string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	if isRoot(path) {
+		return 0, fmt.Errorf("cannot write to directory: %s", path)
+	}
+	fs, rest, err := cfs.resolve("write", path)
+	if err != nil {
+		return 0, err
+	}
+	return fs.Write(rest, data, offset, flags)
+}
+
+func (cfs *connectionsFS) ReadDir(path string) ([]filesystem.FileInfo, error) {
+	if isRoot(path) {
+		names := make([]string, 0, len(cfs.fss))
+		for name := range cfs.fss {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		entries := make([]filesystem.FileInfo, 0, len(names))
+		for _, name := range names {
+			entries = append(entries, cfs.connectionInfo(name))
+		}
+		return entries, nil
+	}
+	fs, rest, err := cfs.resolve("readdir", path)
+	if err != nil {
+		return nil, err
+	}
+	return fs.ReadDir(rest)
+}
+
+func (cfs *connectionsFS) Stat(path string) (*filesystem.FileInfo, error) {
+	if isRoot(path) {
+		return &filesystem.FileInfo{
+			Name:    "/",
+			Size:    0,
+			Mode:    0755,
+			ModTime: time.Now(),
+			IsDir:   true,
+			Meta:    filesystem.MetaData{Name: PluginName},
+		}, nil
+	}
+	fs, rest, err := cfs.resolve("stat", path)
+	if err != nil {
+		return nil, err
+	}
+	if isRoot(rest) {
+		info := cfs.connectionInfo(strings.Trim(path, "/"))
+		return &info, nil
+	}
+	return fs.Stat(rest)
+}
+
+// connectionInfo returns the file info of the directory of a connection;
+// read-only connections have no write permission
+func (cfs *connectionsFS) connectionInfo(name string) filesystem.FileInfo {
+	mode := uint32(0755)
+	if cfs.plugin.connections[name].readOnly {
+		mode = 0555
+	}
+	return filesystem.FileInfo{
+		Name:    name,
+		Size:    0,
+		Mode:    mode,
+		ModTime: time.Now(),
+		IsDir:   true,
+		Meta: filesystem.MetaData{
+			Name:    PluginName,
+			Type:    "connection",
+			Content: map[string]string{"backend": cfs.plugin.connections[name].backend.Name()},
+		},
+	}
+}
+
+func (cfs *connectionsFS) Rename(oldPath, newPath string) error {
+	return fmt.Errorf("operation not supported: rename")
+}
+
+func (cfs *connectionsFS) Chmod(path string, mode uint32) error {
+	return fmt.Errorf("operation not supported: chmod")
+}
+
+// Truncate is a no-op, like in the file system of a connection
+func (cfs *connectionsFS) Truncate(path string, size int64) error {
+	return nil
+}
+
+func (cfs *connectionsFS) Open(path string) (io.ReadCloser, error) {
+	fs, rest, err := cfs.resolve("open", path)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Open(rest)
+}
+
+func (cfs *connectionsFS) OpenWrite(path string) (io.WriteCloser, error) {
+	return filesystem.NewBufferedWriter(path, cfs.Write), nil
+}
+
+// connectionHandle is a handle of the file system of a connection, with an
+// ID unique across connections and its path in the mount
+type connectionHandle struct {
+	filesystem.FileHandle
+	id   int64
+	path string
+	cfs  *connectionsFS
+}
+
+// ID returns the identifier of the handle in the mount
+func (h *connectionHandle) ID() int64 {
+	return h.id
+}
+
+// Path returns the path of the handle in the mount
+func (h *connectionHandle) Path() string {
+	return h.path
+}
+
+// Close closes the handle of the connection and forgets it
+func (h *connectionHandle) Close() error {
+	h.cfs.handlesMu.Lock()
+	delete(h.cfs.handles, h.id)
+	h.cfs.handlesMu.Unlock()
+	return h.FileHandle.Close()
+}
+
+// OpenHandle opens a handle in the file system of the connection of path
+func (cfs *connectionsFS) OpenHandle(path string, flags filesystem.OpenFlag, mode uint32) (filesystem.FileHandle, error) {
+	fs, rest, err := cfs.resolve("openhandle", path)
+	if err != nil {
+		return nil, err
+	}
+	local, err := fs.OpenHandle(rest, flags, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	cfs.handlesMu.Lock()
+	defer cfs.handlesMu.Unlock()
+	handle := &connectionHandle{FileHandle: local, id: cfs.nextHandleID, path: path, cfs: cfs}
+	cfs.nextHandleID++
+	cfs.handles[handle.id] = handle
+	return handle, nil
+}
+
+// GetHandle retrieves an existing handle by its ID
+func (cfs *connectionsFS) GetHandle(id int64) (filesystem.FileHandle, error) {
+	cfs.handlesMu.Lock()
+	defer cfs.handlesMu.Unlock()
+
+	handle, exists := cfs.handles[id]
+	if !exists {
+		return nil, filesystem.ErrNotFound
+	}
+	return handle, nil
+}
+
+// CloseHandle closes a handle by its ID
+func (cfs *connectionsFS) CloseHandle(id int64) error {
+	handle, err := cfs.GetHandle(id)
+	if err != nil {
+		return err
+	}
+	return handle.Close()
+}
+
+var _ filesystem.HandleFS = (*connectionsFS)(nil)
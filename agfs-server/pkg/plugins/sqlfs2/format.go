@@ -0,0 +1,204 @@
+package sqlfs2
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+// Result formats
+const (
+	formatJSON     = "json"
+	formatCSV      = "csv"
+	formatMarkdown = "markdown"
+)
+
+// resultFiles maps the session files of results to their format: result is
+// in the format of the session (see the format file), the others in the
+// format of their extension
+var resultFiles = map[string]string{
+	"result":      "",
+	"result.json": formatJSON,
+	"result.csv":  formatCSV,
+	"result.md":   formatMarkdown,
+}
+
+// isResultFile checks if the given name is a session file of results
+func isResultFile(name string) bool {
+	_, ok := resultFiles[name]
+	return ok
+}
+
+// resultFileInfo returns the file info of a result file of a session in
+// the format of its extension
+func resultFileInfo(name string, now time.Time) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    name,
+		Size:    0,
+		Mode:    0444,
+		ModTime: now,
+		IsDir:   false,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "result"},
+	}
+}
+
+// parseFormat parses what is written to the format file
+func parseFormat(s string) (string, error) {
+	switch f := strings.ToLower(strings.TrimSpace(s)); f {
+	case formatJSON, formatCSV, formatMarkdown:
+		return f, nil
+	case "md":
+		return formatMarkdown, nil
+	default:
+		return "", fmt.Errorf("unknown format: %q (expected json, csv or markdown)", f)
+	}
+}
+
+// resultTable is the result of the last statement of a session in tabular
+// form, with its columns in order, to render it as CSV or Markdown
+type resultTable struct {
+	columns []string
+	rows    [][]interface{}
+}
+
+// newResultTable returns the table of the rows of a query
+func newResultTable(columns []string, rows []map[string]interface{}) *resultTable {
+	table := &resultTable{columns: columns}
+	for _, row := range rows {
+		values := make([]interface{}, len(columns))
+		for i, col := range columns {
+			values[i] = row[col]
+		}
+		table.rows = append(table.rows, values)
+	}
+	return table
+}
+
+// mapTable returns the one-row table of the result of a statement that is
+// not a query, e.g. {"rows_affected": 1, "last_insert_id": 3}
+func mapTable(m map[string]interface{}) *resultTable {
+	table := &resultTable{}
+	for col := range m {
+		table.columns = append(table.columns, col)
+	}
+	sort.Strings(table.columns)
+	return newResultTable(table.columns, []map[string]interface{}{m})
+}
+
+// setResult stores the result of the last statement of a session: its JSON
+// and its table, or clears it if jsonData is nil. Must be called with mu held.
+func (s *Session) setResult(jsonData []byte, table *resultTable) {
+	s.result = jsonData
+	s.resultTable = table
+	if jsonData == nil {
+		s.resultTable = nil
+	}
+}
+
+// readResult returns the result of the last statement of a session as the
+// result file name asks for
+func (s *Session) readResult(name string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	format := resultFiles[name]
+	if format == "" {
+		format = s.format
+	}
+	if s.result == nil {
+		return []byte{}, nil
+	}
+	switch format {
+	case formatCSV:
+		return s.resultTable.csv()
+	case formatMarkdown:
+		return s.resultTable.markdown(), nil
+	default:
+		return s.result, nil
+	}
+}
+
+// readFormat returns the content of the format file of a session
+func (s *Session) readFormat() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return []byte(s.format + "\n")
+}
+
+// csv renders the table as CSV with a header row; NULL is an empty field
+func (t *resultTable) csv() ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(t.columns); err != nil {
+		return nil, err
+	}
+	record := make([]string, len(t.columns))
+	for _, row := range t.rows {
+		for i, v := range row {
+			record[i] = formatCell(v, "")
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// markdown renders the table as a GitHub-flavored Markdown table
+func (t *resultTable) markdown() []byte {
+	var b strings.Builder
+	writeRow := func(cells []string) {
+		b.WriteString("|")
+		for _, cell := range cells {
+			b.WriteString(" ")
+			b.WriteString(cell)
+			b.WriteString(" |")
+		}
+		b.WriteString("\n")
+	}
+
+	header := make([]string, len(t.columns))
+	separator := make([]string, len(t.columns))
+	for i, col := range t.columns {
+		header[i] = markdownEscape(col)
+		separator[i] = "---"
+	}
+	writeRow(header)
+	writeRow(separator)
+
+	cells := make([]string, len(t.columns))
+	for _, row := range t.rows {
+		for i, v := range row {
+			cells[i] = markdownEscape(formatCell(v, "NULL"))
+		}
+		writeRow(cells)
+	}
+	return []byte(b.String())
+}
+
+// formatCell formats a value of a result as text, null for NULL
+func formatCell(v interface{}, null string) string {
+	switch v := v.(type) {
+	case nil:
+		return null
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// markdownEscape escapes the pipes and line breaks of a table cell
+func markdownEscape(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	s = strings.ReplaceAll(s, "\r\n", "<br>")
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
@@ -0,0 +1,403 @@
+package sqlfs2
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// migrationsDir is the directory of a database with its migration files
+	migrationsDir = ".migrations"
+
+	// migrationsStatusFile is the file in .migrations with the state of the
+	// migrations of a database
+	migrationsStatusFile = "status.json"
+
+	// migrationsTable is the table tracking the migrations of a database
+	migrationsTable = "_agfs_migrations"
+)
+
+// Migration states
+const (
+	migrationPending = "pending"
+	migrationApplied = "applied"
+	migrationFailed  = "failed"
+)
+
+// migrationName matches the names of migration files: a version number,
+// optionally followed by an underscore and a description
+var migrationName = regexp.MustCompile(`^([0-9]+)(_[A-Za-z0-9_-]+)?\.sql$`)
+
+// migration is a migration file of a database, as tracked in migrationsTable
+type migration struct {
+	Version   int64  `json:"version"`
+	Name      string `json:"name"`
+	Checksum  string `json:"checksum"` // SHA-256 of the SQL
+	Status    string `json:"status"`   // pending, applied or failed
+	Error     string `json:"error,omitempty"`
+	AppliedAt string `json:"applied_at,omitempty"`
+	body      string
+}
+
+// parseMigrationsPath checks if a path is /<db>/.migrations or a file in it,
+// returning the file name ("" for the directory)
+func parseMigrationsPath(path string) (dbName, name string, ok bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[1] != migrationsDir || parts[0] == "" {
+		return "", "", false
+	}
+	if len(parts) == 3 {
+		return parts[0], parts[2], true
+	}
+	return parts[0], "", true
+}
+
+// parseMigrationVersion returns the version of a migration file name
+func parseMigrationVersion(path, name string) (int64, error) {
+	m := migrationName.FindStringSubmatch(name)
+	if m == nil {
+		return 0, filesystem.NewInvalidArgumentError("path", path,
+			"migration files are named <version>_<description>.sql, e.g. 0001_create_users.sql")
+	}
+	version, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return 0, filesystem.NewInvalidArgumentError("path", path, "migration version out of range")
+	}
+	return version, nil
+}
+
+// loadMigrations returns the migrations of a database in version order, none
+// if the tracking table does not exist yet
+func (fs *sqlfs2FS) loadMigrations(dbName string) ([]*migration, error) {
+	exists, err := fs.tableExists(dbName, migrationsTable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check table existence: %w", err)
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	rows, err := fs.plugin.db.Query(fmt.Sprintf(
+		"SELECT version, name, body, checksum, status, error, applied_at FROM %s.%s ORDER BY version",
+		dbName, migrationsTable))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	defer rows.Close()
+
+	var migrations []*migration
+	for rows.Next() {
+		m := &migration{}
+		var errMsg, appliedAt sql.NullString
+		if err := rows.Scan(&m.Version, &m.Name, &m.body, &m.Checksum, &m.Status, &errMsg, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to load migrations: %w", err)
+		}
+		m.Error = errMsg.String
+		m.AppliedAt = appliedAt.String
+		migrations = append(migrations, m)
+	}
+	return migrations, rows.Err()
+}
+
+// findMigration returns the migration of a file name, or nil
+func findMigration(migrations []*migration, name string) *migration {
+	for _, m := range migrations {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// migrationsStatus renders status.json: the counts of migrations by state,
+// the highest applied version and every migration
+func migrationsStatus(dbName string, migrations []*migration) ([]byte, error) {
+	status := struct {
+		Database       string       `json:"database"`
+		CurrentVersion int64        `json:"current_version"`
+		Applied        int          `json:"applied"`
+		Pending        int          `json:"pending"`
+		Failed         int          `json:"failed"`
+		Migrations     []*migration `json:"migrations"`
+	}{Database: dbName, Migrations: migrations}
+	if status.Migrations == nil {
+		status.Migrations = []*migration{}
+	}
+	for _, m := range migrations {
+		switch m.Status {
+		case migrationApplied:
+			status.Applied++
+			status.CurrentVersion = max(status.CurrentVersion, m.Version)
+		case migrationPending:
+			status.Pending++
+		case migrationFailed:
+			status.Failed++
+		}
+	}
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// readMigrationFile returns the content of status.json or of a migration file
+func (fs *sqlfs2FS) readMigrationFile(path, dbName, name string) ([]byte, error) {
+	migrations, err := fs.loadMigrations(dbName)
+	if err != nil {
+		return nil, err
+	}
+	if name == migrationsStatusFile {
+		return migrationsStatus(dbName, migrations)
+	}
+	m := findMigration(migrations, name)
+	if m == nil {
+		return nil, filesystem.NewNotFoundError("read", path)
+	}
+	return []byte(m.body), nil
+}
+
+// writeMigration stores a migration file and applies the pending migrations
+// of the database in version order. A migration that fails is marked failed
+// and blocks the ones after it until it is rewritten or removed. Applied
+// migrations cannot be changed, and new ones must have a version above the
+// applied ones.
+func (fs *sqlfs2FS) writeMigration(path, dbName, name string, data []byte, offset int64) error {
+	if fs.plugin.readOnly {
+		return errReadOnly
+	}
+	if name == migrationsStatusFile {
+		return fmt.Errorf("%s is read-only", migrationsStatusFile)
+	}
+	if offset != 0 {
+		return filesystem.NewInvalidArgumentError("write", path, "migrations must be written whole, at offset 0")
+	}
+	version, err := parseMigrationVersion(path, name)
+	if err != nil {
+		return err
+	}
+	body := string(data)
+	if strings.TrimSpace(body) == "" {
+		return filesystem.NewInvalidArgumentError("write", path, "empty migration")
+	}
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+
+	fs.plugin.migrationsMu.Lock()
+	defer fs.plugin.migrationsMu.Unlock()
+
+	if err := fs.plugin.backend.SwitchDatabase(fs.plugin.db, dbName); err != nil {
+		return err
+	}
+	if _, err := fs.plugin.db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s.%s (
+		version BIGINT NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		body TEXT NOT NULL,
+		checksum CHAR(64) NOT NULL,
+		status VARCHAR(16) NOT NULL,
+		error TEXT,
+		applied_at VARCHAR(64)
+	)`, dbName, migrationsTable)); err != nil {
+		return fmt.Errorf("failed to create %s: %w", migrationsTable, err)
+	}
+	migrations, err := fs.loadMigrations(dbName)
+	if err != nil {
+		return err
+	}
+
+	var latest *migration
+	for _, m := range migrations {
+		if m.Status == migrationApplied {
+			latest = m
+		}
+		if m.Version != version {
+			continue
+		}
+		switch {
+		case m.Name != name:
+			return filesystem.NewInvalidArgumentError("write", path,
+				fmt.Sprintf("version %d is already used by %s", version, m.Name))
+		case m.Status == migrationApplied && m.Checksum == checksum:
+			return nil
+		case m.Status == migrationApplied:
+			return filesystem.NewInvalidArgumentError("write", path,
+				"applied migrations cannot be changed, write a new one")
+		}
+	}
+	if latest != nil && version < latest.Version && findMigration(migrations, name) == nil {
+		return filesystem.NewInvalidArgumentError("write", path,
+			fmt.Sprintf("version %d is below the applied %s", version, latest.Name))
+	}
+
+	table := dbName + "." + migrationsTable
+	if findMigration(migrations, name) != nil {
+		_, err = fs.plugin.db.Exec(fmt.Sprintf("UPDATE %s SET body = ?, checksum = ?, status = ?, error = NULL WHERE version = ?", table),
+			body, checksum, migrationPending, version)
+	} else {
+		_, err = fs.plugin.db.Exec(fmt.Sprintf("INSERT INTO %s (version, name, body, checksum, status) VALUES (?, ?, ?, ?, ?)", table),
+			version, name, body, checksum, migrationPending)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store migration: %w", err)
+	}
+	return fs.applyMigrations(dbName)
+}
+
+// applyMigrations applies the pending and failed migrations of a database in
+// version order, stopping at the first that fails. Must be called with
+// migrationsMu held.
+func (fs *sqlfs2FS) applyMigrations(dbName string) error {
+	migrations, err := fs.loadMigrations(dbName)
+	if err != nil {
+		return err
+	}
+	table := dbName + "." + migrationsTable
+	for _, m := range migrations {
+		if m.Status == migrationApplied {
+			continue
+		}
+		if err := fs.applyMigration(dbName, m); err != nil {
+			if _, uerr := fs.plugin.db.Exec(fmt.Sprintf("UPDATE %s SET status = ?, error = ? WHERE version = ?", table),
+				migrationFailed, err.Error(), m.Version); uerr != nil {
+				log.Warnf("[sqlfs2] Failed to record the failure of migration %s: %v", m.Name, uerr)
+			}
+			return fmt.Errorf("migration %s failed: %w", m.Name, err)
+		}
+		log.Infof("[sqlfs2] Applied migration %s to %s", m.Name, dbName)
+	}
+	return nil
+}
+
+// applyMigration runs the statements of a migration and marks it applied, in
+// one transaction. Backends that commit DDL implicitly, like MySQL, can keep
+// the statements before a failed one.
+func (fs *sqlfs2FS) applyMigration(dbName string, m *migration) error {
+	statements, err := splitStatements(m.body, fs.plugin.backend.Name() != "sqlite")
+	if err != nil {
+		return err
+	}
+	tx, err := fs.plugin.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if fs.plugin.backend.Name() != "sqlite" {
+		if _, err := tx.Exec(fmt.Sprintf("USE `%s`", dbName)); err != nil {
+			return fmt.Errorf("failed to switch to database %s: %w", dbName, err)
+		}
+	}
+	for i, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("statement %d: %w", i+1, err)
+		}
+	}
+	if _, err := tx.Exec(fmt.Sprintf("UPDATE %s.%s SET status = ?, error = NULL, applied_at = ? WHERE version = ?", dbName, migrationsTable),
+		migrationApplied, time.Now().UTC().Format(time.RFC3339), m.Version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// removeMigration removes a migration that is not applied
+func (fs *sqlfs2FS) removeMigration(path, dbName, name string) error {
+	if fs.plugin.readOnly {
+		return errReadOnly
+	}
+	fs.plugin.migrationsMu.Lock()
+	defer fs.plugin.migrationsMu.Unlock()
+
+	migrations, err := fs.loadMigrations(dbName)
+	if err != nil {
+		return err
+	}
+	m := findMigration(migrations, name)
+	if m == nil {
+		return filesystem.NewNotFoundError("remove", path)
+	}
+	if m.Status == migrationApplied {
+		return filesystem.NewInvalidArgumentError("remove", path, "applied migrations cannot be removed")
+	}
+	_, err = fs.plugin.db.Exec(fmt.Sprintf("DELETE FROM %s.%s WHERE version = ?", dbName, migrationsTable), m.Version)
+	return err
+}
+
+// listMigrations lists status.json and the migration files of a database
+func (fs *sqlfs2FS) listMigrations(dbName string) ([]filesystem.FileInfo, error) {
+	migrations, err := fs.loadMigrations(dbName)
+	if err != nil {
+		return nil, err
+	}
+	status, err := migrationsStatus(dbName, migrations)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	entries := []filesystem.FileInfo{migrationFileInfo(migrationsStatusFile, int64(len(status)), 0444, now)}
+	for _, m := range migrations {
+		entries = append(entries, migrationFileInfo(m.Name, int64(len(m.body)), migrationMode(m), now))
+	}
+	return entries, nil
+}
+
+// migrationsFileInfo returns the FileInfo of .migrations or a file in it
+func (fs *sqlfs2FS) migrationsFileInfo(path, dbName, name string) (*filesystem.FileInfo, error) {
+	if name == "" {
+		return &filesystem.FileInfo{
+			Name:    migrationsDir,
+			Size:    0,
+			Mode:    0755,
+			ModTime: time.Now(),
+			IsDir:   true,
+			Meta:    filesystem.MetaData{Name: PluginName, Type: "migrations"},
+		}, nil
+	}
+
+	migrations, err := fs.loadMigrations(dbName)
+	if err != nil {
+		return nil, err
+	}
+	if name == migrationsStatusFile {
+		status, err := migrationsStatus(dbName, migrations)
+		if err != nil {
+			return nil, err
+		}
+		info := migrationFileInfo(name, int64(len(status)), 0444, time.Now())
+		return &info, nil
+	}
+	m := findMigration(migrations, name)
+	if m == nil {
+		return nil, filesystem.NewNotFoundError("stat", path)
+	}
+	info := migrationFileInfo(name, int64(len(m.body)), migrationMode(m), time.Now())
+	return &info, nil
+}
+
+// migrationMode is the mode of a migration file: applied ones are read-only
+func migrationMode(m *migration) uint32 {
+	if m.Status == migrationApplied {
+		return 0444
+	}
+	return 0644
+}
+
+func migrationFileInfo(name string, size int64, mode uint32, now time.Time) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    name,
+		Size:    size,
+		Mode:    mode,
+		ModTime: now,
+		IsDir:   false,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "migration"},
+	}
+}
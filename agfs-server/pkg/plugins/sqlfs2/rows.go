@@ -0,0 +1,340 @@
+package sqlfs2
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+const (
+	// rowsDir is the directory of a table with a JSON file per row, named
+	// after its primary key, when rows_by_pk is enabled
+	rowsDir = "rows_by_pk"
+
+	// rowExt is the extension of row files
+	rowExt = ".json"
+
+	// maxListedRows is how many rows a listing of rows_by_pk shows, in
+	// primary key order; rows beyond it can still be read by name
+	maxListedRows = 1000
+)
+
+// parseRowsPath checks if a path is /<db>/<table>/rows_by_pk or a row file in
+// it, returning the primary key of the row ("" for the directory). Keys are
+// path-escaped in file names, so that keys with a slash have a file too.
+func parseRowsPath(path string) (dbName, tableName, key string, isRow, ok bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 3 || len(parts) > 4 || parts[2] != rowsDir || parts[0] == "" || parts[1] == "" {
+		return "", "", "", false, false
+	}
+	if len(parts) == 3 {
+		return parts[0], parts[1], "", false, true
+	}
+	name, found := strings.CutSuffix(parts[3], rowExt)
+	if !found || name == "" {
+		return "", "", "", false, false
+	}
+	key, err := url.PathUnescape(name)
+	if err != nil {
+		return "", "", "", false, false
+	}
+	return parts[0], parts[1], key, true, true
+}
+
+// rowFileName returns the name of the file of a row
+func rowFileName(key string) string {
+	return url.PathEscape(key) + rowExt
+}
+
+// rowsPath returns the parsed rows path of path, if rows_by_pk is enabled
+func (fs *sqlfs2FS) rowsPath(path string) (dbName, tableName, key string, isRow, ok bool) {
+	if !fs.plugin.rowsByPK {
+		return "", "", "", false, false
+	}
+	return parseRowsPath(path)
+}
+
+// primaryKey returns the columns of a table and the name of its primary key,
+// which must be a single column
+func (fs *sqlfs2FS) primaryKey(path, dbName, tableName string) ([]ColumnInfo, string, error) {
+	exists, err := fs.tableExists(dbName, tableName)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to check table existence: %w", err)
+	}
+	if !exists {
+		return nil, "", filesystem.NewNotFoundError("stat", path)
+	}
+	columns, err := fs.plugin.backend.GetTableColumns(fs.plugin.db, dbName, tableName)
+	if err != nil {
+		return nil, "", err
+	}
+	var keys []string
+	for _, col := range columns {
+		if col.PrimaryKey {
+			keys = append(keys, col.Name)
+		}
+	}
+	if len(keys) != 1 {
+		return nil, "", filesystem.NewInvalidArgumentError("path", path,
+			fmt.Sprintf("%s needs a single-column primary key, table '%s.%s' has %d", rowsDir, dbName, tableName, len(keys)))
+	}
+	return columns, keys[0], nil
+}
+
+// hasRowsDir reports whether a table is listed with a rows_by_pk directory
+func (fs *sqlfs2FS) hasRowsDir(dbName, tableName string) bool {
+	if !fs.plugin.rowsByPK {
+		return false
+	}
+	_, _, err := fs.primaryKey("", dbName, tableName)
+	return err == nil
+}
+
+// readRow returns the JSON of the row of a primary key
+func (fs *sqlfs2FS) readRow(path, dbName, tableName, key string) ([]byte, error) {
+	_, pk, err := fs.primaryKey(path, dbName, tableName)
+	if err != nil {
+		return nil, err
+	}
+	if err := fs.plugin.backend.SwitchDatabase(fs.plugin.db, dbName); err != nil {
+		return nil, err
+	}
+
+	rows, err := fs.plugin.db.Query(fmt.Sprintf("SELECT * FROM %s.%s WHERE %s = ?", dbName, tableName, pk), key)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, filesystem.NewNotFoundError("read", path)
+	}
+	row, err := scanRow(rows, columns)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the order of the columns rather than sorting them by name
+	var buf bytes.Buffer
+	buf.WriteString("{\n")
+	for i, col := range columns {
+		name, _ := json.Marshal(col)
+		value, err := json.Marshal(row[col])
+		if err != nil {
+			return nil, fmt.Errorf("json marshal error: %w", err)
+		}
+		fmt.Fprintf(&buf, "  %s: %s", name, value)
+		if i < len(columns)-1 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('\n')
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes(), nil
+}
+
+// scanRow scans the current row of rows, with text as strings
+func scanRow(rows *sql.Rows, columns []string) (map[string]interface{}, error) {
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+	if err := rows.Scan(valuePtrs...); err != nil {
+		return nil, fmt.Errorf("scan error: %w", err)
+	}
+	row := make(map[string]interface{}, len(columns))
+	for i, col := range columns {
+		if b, ok := values[i].([]byte); ok {
+			row[col] = string(b)
+		} else {
+			row[col] = values[i]
+		}
+	}
+	return row, nil
+}
+
+// writeRow upserts the row of a primary key from a JSON object: the row is
+// inserted if it does not exist, else the columns in the object are updated
+// and the others left as they are. The row is looked up first, in the same
+// transaction, as an INSERT with a conflict clause would fail the NOT NULL
+// constraints of the columns left out.
+func (fs *sqlfs2FS) writeRow(path, dbName, tableName, key string, data []byte, offset int64) error {
+	if fs.plugin.readOnly {
+		return errReadOnly
+	}
+	if offset != 0 {
+		return filesystem.NewInvalidArgumentError("write", path, "rows must be written whole, at offset 0")
+	}
+	columns, pk, err := fs.primaryKey(path, dbName, tableName)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber() // Keep large integers exact
+	var record map[string]interface{}
+	if err := decoder.Decode(&record); err != nil || record == nil {
+		return filesystem.NewInvalidArgumentError("write", path, "a row must be a JSON object of column values")
+	}
+
+	known := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		known[col.Name] = true
+	}
+	for name, value := range record {
+		if !known[name] {
+			return filesystem.NewInvalidArgumentError("write", path, fmt.Sprintf("unknown column %q", name))
+		}
+		switch v := value.(type) {
+		case map[string]interface{}, []interface{}:
+			// Nested values are stored as JSON text
+			nested, _ := json.Marshal(v)
+			record[name] = string(nested)
+		}
+	}
+	if value, ok := record[pk]; ok && value != nil && fmt.Sprint(value) != key {
+		return filesystem.NewInvalidArgumentError("write", path,
+			fmt.Sprintf("%s is %v in the row but %q in the file name", pk, value, key))
+	}
+	record[pk] = key
+
+	// Columns in table order, the primary key first
+	names := []string{pk}
+	values := []interface{}{key}
+	for _, col := range columns {
+		if value, ok := record[col.Name]; ok && col.Name != pk {
+			names = append(names, col.Name)
+			values = append(values, value)
+		}
+	}
+
+	if err := fs.plugin.backend.SwitchDatabase(fs.plugin.db, dbName); err != nil {
+		return err
+	}
+	tx, err := fs.plugin.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	table := dbName + "." + tableName
+	var exists int
+	err = tx.QueryRow(fmt.Sprintf("SELECT 1 FROM %s WHERE %s = ?", table, pk), key).Scan(&exists)
+	switch {
+	case err == sql.ErrNoRows:
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ")
+		_, err = tx.Exec(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(names, ", "), placeholders), values...)
+	case err == nil && len(names) > 1:
+		sets := make([]string, len(names)-1)
+		for i, name := range names[1:] {
+			sets[i] = name + " = ?"
+		}
+		_, err = tx.Exec(fmt.Sprintf("UPDATE %s SET %s WHERE %s = ?", table, strings.Join(sets, ", "), pk),
+			append(values[1:], key)...)
+	}
+	if err != nil {
+		return fmt.Errorf("upsert error: %w", err)
+	}
+	return tx.Commit()
+}
+
+// removeRow deletes the row of a primary key
+func (fs *sqlfs2FS) removeRow(path, dbName, tableName, key string) error {
+	if fs.plugin.readOnly {
+		return errReadOnly
+	}
+	_, pk, err := fs.primaryKey(path, dbName, tableName)
+	if err != nil {
+		return err
+	}
+	if err := fs.plugin.backend.SwitchDatabase(fs.plugin.db, dbName); err != nil {
+		return err
+	}
+	result, err := fs.plugin.db.Exec(fmt.Sprintf("DELETE FROM %s.%s WHERE %s = ?", dbName, tableName, pk), key)
+	if err != nil {
+		return fmt.Errorf("delete error: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return filesystem.NewNotFoundError("remove", path)
+	}
+	return nil
+}
+
+// listRows lists the files of the first maxListedRows rows of a table
+func (fs *sqlfs2FS) listRows(path, dbName, tableName string) ([]filesystem.FileInfo, error) {
+	_, pk, err := fs.primaryKey(path, dbName, tableName)
+	if err != nil {
+		return nil, err
+	}
+	if err := fs.plugin.backend.SwitchDatabase(fs.plugin.db, dbName); err != nil {
+		return nil, err
+	}
+	rows, err := fs.plugin.db.Query(fmt.Sprintf("SELECT %s FROM %s.%s ORDER BY %s LIMIT %d",
+		pk, dbName, tableName, pk, maxListedRows))
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	entries := []filesystem.FileInfo{}
+	for rows.Next() {
+		var key sql.NullString
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		if !key.Valid {
+			continue
+		}
+		entries = append(entries, rowFileInfo(rowFileName(key.String), 0, now))
+	}
+	return entries, rows.Err()
+}
+
+// rowsFileInfo returns the FileInfo of rows_by_pk or a row file in it
+func (fs *sqlfs2FS) rowsFileInfo(path, dbName, tableName, key string, isRow bool) (*filesystem.FileInfo, error) {
+	if !isRow {
+		if _, _, err := fs.primaryKey(path, dbName, tableName); err != nil {
+			return nil, err
+		}
+		return &filesystem.FileInfo{
+			Name:    rowsDir,
+			Size:    0,
+			Mode:    0755,
+			ModTime: time.Now(),
+			IsDir:   true,
+			Meta:    filesystem.MetaData{Name: PluginName, Type: "rows"},
+		}, nil
+	}
+
+	data, err := fs.readRow(path, dbName, tableName, key)
+	if err != nil {
+		return nil, err
+	}
+	info := rowFileInfo(rowFileName(key), int64(len(data)), time.Now())
+	return &info, nil
+}
+
+func rowFileInfo(name string, size int64, now time.Time) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    name,
+		Size:    size,
+		Mode:    0644,
+		ModTime: now,
+		IsDir:   false,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "row"},
+	}
+}
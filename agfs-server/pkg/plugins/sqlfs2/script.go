@@ -0,0 +1,214 @@
+package sqlfs2
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// scriptSavepoint is the savepoint a script is rolled back to if one of its
+// statements fails
+const scriptSavepoint = "sqlfs2_script"
+
+// statementResult is the outcome of one statement of a script
+type statementResult struct {
+	Index        int    `json:"index"` // 1-based
+	SQL          string `json:"sql"`
+	Status       string `json:"status"`         // ok, error or skipped
+	Rows         *int64 `json:"rows,omitempty"` // Rows returned by a query
+	RowsAffected *int64 `json:"rows_affected,omitempty"`
+	LastInsertID *int64 `json:"last_insert_id,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// scriptResult is the content of last_script_result
+type scriptResult struct {
+	Statements int               `json:"statements"`
+	Succeeded  int               `json:"succeeded"`
+	RolledBack bool              `json:"rolled_back"`
+	Results    []statementResult `json:"results"`
+}
+
+// isQueryStatement checks if a statement returns rows
+func isQueryStatement(stmt string) bool {
+	upperSQL := strings.ToUpper(stmt)
+	return strings.HasPrefix(upperSQL, "SELECT") ||
+		strings.HasPrefix(upperSQL, "SHOW") ||
+		strings.HasPrefix(upperSQL, "DESCRIBE") ||
+		strings.HasPrefix(upperSQL, "EXPLAIN")
+}
+
+// splitStatements splits a script into statements at the semicolons that are
+// outside of string literals, quoted identifiers and comments. Line comments
+// ("--" and, with hashComments, "#") and block comments are removed, except
+// MySQL's executable comments ("/*!" and optimizer hints "/*+"); statements
+// that are empty without comments are dropped.
+func splitStatements(script string, hashComments bool) ([]string, error) {
+	var statements []string
+	var current strings.Builder
+
+	flush := func() {
+		if stmt := strings.TrimSpace(current.String()); stmt != "" {
+			statements = append(statements, stmt)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			// Quoted: ends at the next unescaped quote; a doubled quote is
+			// an escaped quote, and so is a backslash in strings
+			end := -1
+			for j := i + 1; j < len(script); j++ {
+				if script[j] == '\\' && c != '`' {
+					j++
+					continue
+				}
+				if script[j] == c {
+					if j+1 < len(script) && script[j+1] == c {
+						j++
+						continue
+					}
+					end = j
+					break
+				}
+			}
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated %c quote", c)
+			}
+			current.WriteString(script[i : end+1])
+			i = end
+
+		case c == '-' && strings.HasPrefix(script[i:], "--"), c == '#' && hashComments:
+			// Line comment, up to (not including) the newline
+			end := strings.IndexByte(script[i:], '\n')
+			if end == -1 {
+				i = len(script)
+			} else {
+				i += end - 1
+			}
+			current.WriteByte(' ')
+
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			end += i + 4
+			if strings.HasPrefix(script[i:], "/*!") || strings.HasPrefix(script[i:], "/*+") {
+				current.WriteString(script[i:end])
+			} else {
+				current.WriteByte(' ')
+			}
+			i = end - 1
+
+		case c == ';':
+			flush()
+
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+	return statements, nil
+}
+
+// executeScript executes the statements of a script in the transaction of a
+// session. If a statement fails, the statements before it are rolled back and
+// the ones after it are skipped. The result is stored in the session's
+// last_script_result. Must be called with session.mu held.
+func (fs *sqlfs2FS) executeScript(session *Session, script string) error {
+	statements, err := splitStatements(script, fs.plugin.backend.Name() != "sqlite")
+	if err != nil {
+		session.lastError = err.Error()
+		return fmt.Errorf("invalid script: %w", err)
+	}
+	if len(statements) == 0 {
+		session.lastError = "empty SQL script"
+		return fmt.Errorf("empty SQL script")
+	}
+
+	if _, err := session.tx.Exec("SAVEPOINT " + scriptSavepoint); err != nil {
+		session.lastError = err.Error()
+		return fmt.Errorf("failed to start script: %w", err)
+	}
+
+	result := scriptResult{Statements: len(statements)}
+	var failed error
+	for i, stmt := range statements {
+		r := statementResult{Index: i + 1, SQL: stmt}
+		if failed != nil {
+			r.Status = "skipped"
+			result.Results = append(result.Results, r)
+			continue
+		}
+
+		if err := executeStatement(session, stmt, &r); err != nil {
+			r.Status, r.Error = "error", err.Error()
+			failed = fmt.Errorf("statement %d: %w", i+1, err)
+		} else {
+			r.Status = "ok"
+			result.Succeeded++
+		}
+		result.Results = append(result.Results, r)
+	}
+
+	if failed != nil {
+		if _, err := session.tx.Exec("ROLLBACK TO SAVEPOINT " + scriptSavepoint); err != nil {
+			failed = fmt.Errorf("%v (rollback failed: %v)", failed, err)
+		} else {
+			result.RolledBack = true
+		}
+	}
+	if _, err := session.tx.Exec("RELEASE SAVEPOINT " + scriptSavepoint); err != nil && failed == nil {
+		failed = fmt.Errorf("failed to finish script: %w", err)
+	}
+
+	jsonData, _ := json.MarshalIndent(result, "", "  ")
+	session.scriptResult = append(jsonData, '\n')
+	if failed != nil {
+		session.lastError = failed.Error()
+		return fmt.Errorf("script error: %w", failed)
+	}
+	session.lastError = ""
+	return nil
+}
+
+// executeStatement executes one statement of a script, recording its row
+// counts in r
+func executeStatement(session *Session, stmt string, r *statementResult) error {
+	if isQueryStatement(stmt) {
+		rows, err := session.tx.Query(stmt)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		var n int64
+		for rows.Next() {
+			n++
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		r.Rows = &n
+		return nil
+	}
+
+	if err := session.checkWritable(stmt); err != nil {
+		return err
+	}
+	result, err := session.tx.Exec(stmt)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil {
+		r.RowsAffected = &rowsAffected
+	}
+	if lastInsertID, err := result.LastInsertId(); err == nil && lastInsertID != 0 {
+		r.LastInsertID = &lastInsertID
+	}
+	return nil
+}
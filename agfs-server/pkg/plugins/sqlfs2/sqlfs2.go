@@ -2,6 +2,7 @@ package sqlfs2
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -22,14 +23,18 @@ const (
 
 // Session represents a Plan 9 style session for SQL operations
 type Session struct {
-	id         int64            // Numeric session ID
-	dbName     string
-	tableName  string
-	tx         *sql.Tx          // SQL transaction
-	result     []byte           // Query result (JSON)
-	lastError  string           // Error message
-	lastAccess time.Time        // Last access time
-	mu         sync.Mutex
+	id           int64 // Numeric session ID
+	dbName       string
+	tableName    string
+	tx           *sql.Tx      // SQL transaction
+	result       []byte       // Query result (JSON)
+	resultTable  *resultTable // Query result, to render it in other formats
+	format       string       // Format of the result file (see format.go)
+	scriptResult []byte       // Per-statement results of the last script (JSON)
+	lastError    string       // Error message
+	lastAccess   time.Time    // Last access time
+	readOnly     bool         // Only queries can be executed (see connections.go)
+	mu           sync.Mutex
 }
 
 // Touch updates the last access time. Must be called with mu held.
@@ -50,6 +55,7 @@ type SessionManager struct {
 	sessions map[string]*Session // key: "dbName/tableName/sid"
 	nextID   int64
 	timeout  time.Duration // Configurable timeout (0 = no timeout)
+	readOnly bool          // Sessions are read-only
 	mu       sync.RWMutex
 	stopCh   chan struct{}
 }
@@ -113,8 +119,9 @@ func (sm *SessionManager) CreateSession(db *sql.DB, dbName, tableName string) (*
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	// Start a new transaction
-	tx, err := db.Begin()
+	// Start a new transaction; read-only transactions are enforced by the
+	// server on MySQL and TiDB
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: sm.readOnly})
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
@@ -127,7 +134,9 @@ func (sm *SessionManager) CreateSession(db *sql.DB, dbName, tableName string) (*
 		dbName:     dbName,
 		tableName:  tableName,
 		tx:         tx,
+		format:     formatJSON,
 		lastAccess: time.Now(),
+		readOnly:   sm.readOnly,
 	}
 
 	key := fmt.Sprintf("%s/%s/%d", dbName, tableName, id)
@@ -193,11 +202,17 @@ func (sm *SessionManager) ListSessions(dbName, tableName string) []string {
 
 // SQLFS2Plugin provides a SQL interface through file system operations
 // Directory structure: /sqlfs2/<dbName>/<tableName>/{ctl, schema, count, <sid>/...}
+// With named connections: /sqlfs2/<connection>/<dbName>/...
 type SQLFS2Plugin struct {
 	db             *sql.DB
 	backend        Backend
 	config         map[string]interface{}
-	sessionManager *SessionManager // Shared across all filesystem instances
+	sessionManager *SessionManager          // Shared across all filesystem instances
+	catalog        catalogCache             // Files of /.catalog
+	readOnly       bool                     // Only queries can be executed
+	rowsByPK       bool                     // Tables have a rows_by_pk directory (see rows.go)
+	migrationsMu   sync.Mutex               // Serializes the migrations of databases (see migrations.go)
+	connections    map[string]*SQLFS2Plugin // Named connections, nil without them
 }
 
 // NewSQLFS2Plugin creates a new SQLFS2 plugin
@@ -211,11 +226,22 @@ func (p *SQLFS2Plugin) Name() string {
 
 func (p *SQLFS2Plugin) Validate(cfg map[string]interface{}) error {
 	allowedKeys := []string{"backend", "db_path", "dsn", "user", "password", "host", "port", "database",
-		"enable_tls", "tls_server_name", "tls_skip_verify", "mount_path", "session_timeout"}
+		"enable_tls", "tls_server_name", "tls_skip_verify", "mount_path", "session_timeout", "read_only", "rows_by_pk", "connections"}
 	if err := config.ValidateOnlyKnownKeys(cfg, allowedKeys); err != nil {
 		return err
 	}
 
+	// Each named connection must be valid on its own
+	connections, err := connectionConfigs(cfg)
+	if err != nil {
+		return err
+	}
+	for name, connCfg := range connections {
+		if err := p.Validate(connCfg); err != nil {
+			return fmt.Errorf("connections.%s: %w", name, err)
+		}
+	}
+
 	// Validate backend type
 	backendType := config.GetStringConfig(cfg, "backend", "sqlite")
 	validBackends := map[string]bool{
@@ -243,7 +269,7 @@ func (p *SQLFS2Plugin) Validate(cfg map[string]interface{}) error {
 	}
 
 	// Validate optional boolean parameters
-	for _, key := range []string{"enable_tls", "tls_skip_verify"} {
+	for _, key := range []string{"enable_tls", "tls_skip_verify", "read_only", "rows_by_pk"} {
 		if err := config.ValidateBoolType(cfg, key); err != nil {
 			return err
 		}
@@ -254,6 +280,16 @@ func (p *SQLFS2Plugin) Validate(cfg map[string]interface{}) error {
 
 func (p *SQLFS2Plugin) Initialize(cfg map[string]interface{}) error {
 	p.config = cfg
+	p.readOnly = config.GetBoolConfig(cfg, "read_only", false)
+	p.rowsByPK = config.GetBoolConfig(cfg, "rows_by_pk", false)
+
+	connections, err := connectionConfigs(cfg)
+	if err != nil {
+		return err
+	}
+	if connections != nil {
+		return p.initializeConnections(connections)
+	}
 
 	backendType := config.GetStringConfig(cfg, "backend", "sqlite")
 
@@ -279,12 +315,32 @@ func (p *SQLFS2Plugin) Initialize(cfg map[string]interface{}) error {
 		}
 	}
 	p.sessionManager = NewSessionManager(timeout)
+	p.sessionManager.readOnly = p.readOnly
 
-	log.Infof("[sqlfs2] Initialized with backend: %s", backendType)
+	log.Infof("[sqlfs2] Initialized with backend: %s (read-only: %v)", backendType, p.readOnly)
+	return nil
+}
+
+// initializeConnections connects to the databases of the named connections,
+// closing those already connected if one fails
+func (p *SQLFS2Plugin) initializeConnections(connections map[string]map[string]interface{}) error {
+	p.connections = make(map[string]*SQLFS2Plugin, len(connections))
+	for name, connCfg := range connections {
+		conn := NewSQLFS2Plugin()
+		if err := conn.Initialize(connCfg); err != nil {
+			p.Shutdown()
+			return fmt.Errorf("connection %s: %w", name, err)
+		}
+		p.connections[name] = conn
+		log.Infof("[sqlfs2] Connection %s ready", name)
+	}
 	return nil
 }
 
 func (p *SQLFS2Plugin) GetFileSystem() filesystem.FileSystem {
+	if p.connections != nil {
+		return newConnectionsFS(p)
+	}
 	return &sqlfs2FS{
 		plugin:         p,
 		handles:        make(map[int64]*SQLFileHandle),
@@ -305,6 +361,7 @@ func (p *SQLFS2Plugin) GetConfigParams() []plugin.ConfigParameter {
 			Required:    false,
 			Default:     "sqlite",
 			Description: "Database backend (sqlite, sqlite3, mysql, tidb)",
+			Enum:        []string{"sqlite", "sqlite3", "mysql", "tidb"},
 		},
 		{
 			Name:        "db_path",
@@ -319,6 +376,7 @@ func (p *SQLFS2Plugin) GetConfigParams() []plugin.ConfigParameter {
 			Required:    false,
 			Default:     "",
 			Description: "Database connection string (DSN)",
+			Secret:      true,
 		},
 		{
 			Name:        "user",
@@ -333,6 +391,7 @@ func (p *SQLFS2Plugin) GetConfigParams() []plugin.ConfigParameter {
 			Required:    false,
 			Default:     "",
 			Description: "Database password",
+			Secret:      true,
 		},
 		{
 			Name:        "host",
@@ -383,10 +442,42 @@ func (p *SQLFS2Plugin) GetConfigParams() []plugin.ConfigParameter {
 			Default:     "",
 			Description: "Session timeout duration (e.g., '10m', '1h'). Empty means no timeout.",
 		},
+		{
+			Name:        "read_only",
+			Type:        "bool",
+			Required:    false,
+			Default:     "false",
+			Description: "Only allow queries (SELECT, SHOW, DESCRIBE, EXPLAIN)",
+		},
+		{
+			Name:        "rows_by_pk",
+			Type:        "bool",
+			Required:    false,
+			Default:     "false",
+			Description: "Show each row of tables with a single-column primary key as rows_by_pk/<pk>.json (read = SELECT, write = UPSERT, rm = DELETE)",
+		},
+		{
+			Name:        "connections",
+			Type:        "map",
+			Required:    false,
+			Default:     "",
+			Description: "Named connections shown as top-level directories: name -> connection config (overrides the keys above)",
+			Secret:      true,
+		},
 	}
 }
 
 func (p *SQLFS2Plugin) Shutdown() error {
+	if p.connections != nil {
+		var firstErr error
+		for _, conn := range p.connections {
+			if err := conn.Shutdown(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	}
+
 	if p.sessionManager != nil {
 		p.sessionManager.Stop()
 	}
@@ -396,6 +487,38 @@ func (p *SQLFS2Plugin) Shutdown() error {
 	return nil
 }
 
+// IsRead implements plugin.ReadRouter: the schema, count and catalog files
+// and the stat of databases and tables can be served by a read replica.
+// Listings and session files stay on the primary, which holds the sessions.
+func (p *SQLFS2Plugin) IsRead(op, path string) bool {
+	if p.connections != nil {
+		name, rest := splitConnectionPath(path)
+		conn, ok := p.connections[name]
+		return ok && conn.IsRead(op, rest)
+	}
+	if _, ok := parseCatalogPath(path); ok {
+		return op == "read" || op == "stat"
+	}
+	if _, _, _, _, ok := (&sqlfs2FS{plugin: p}).rowsPath(path); ok {
+		return op == "read" || op == "stat"
+	}
+	// The state of migrations is read from the primary, which applies them
+	if _, _, ok := parseMigrationsPath(path); ok {
+		return false
+	}
+	dbName, tableName, sid, operation, err := (&sqlfs2FS{}).parsePath(path)
+	if err != nil || sid != "" {
+		return false
+	}
+	switch op {
+	case "read":
+		return tableName != "" && (operation == "schema" || operation == "count")
+	case "stat":
+		return dbName != "" && (operation == "" || operation == "schema" || operation == "count")
+	}
+	return false
+}
+
 // sqlfs2FS implements the FileSystem interface for SQL operations
 type sqlfs2FS struct {
 	plugin         *SQLFS2Plugin
@@ -430,7 +553,8 @@ func isRootLevelFile(name string) bool {
 
 // isSessionFile checks if the given name is a session-level file
 func isSessionFile(name string) bool {
-	return name == "ctl" || name == "query" || name == "result" || name == "data" || name == "error"
+	return name == "ctl" || name == "query" || isResultFile(name) || name == "format" || name == "data" || name == "error" ||
+		name == "execute" || name == "last_script_result"
 }
 
 // isDatabaseLevelFile checks if the given name is a database-level special file
@@ -458,6 +582,8 @@ func isDatabaseLevelFile(name string) bool {
 //   /dbName/tableName/<sid>/ctl    -> (dbName, tableName, sid, "ctl")
 //   /dbName/tableName/<sid>/data   -> (dbName, tableName, sid, "data")
 //   /dbName/tableName/<sid>/error  -> (dbName, tableName, sid, "error")
+//   /dbName/tableName/<sid>/execute -> (dbName, tableName, sid, "execute")
+//   /dbName/tableName/<sid>/last_script_result -> (dbName, tableName, sid, "last_script_result")
 func (fs *sqlfs2FS) parsePath(path string) (dbName, tableName, sid, operation string, err error) {
 	path = strings.TrimPrefix(path, "/")
 	parts := strings.Split(path, "/")
@@ -555,6 +681,37 @@ func (fs *sqlfs2FS) tableExists(dbName, tableName string) (bool, error) {
 }
 
 func (fs *sqlfs2FS) Read(path string, offset int64, size int64) ([]byte, error) {
+	if name, ok := parseCatalogPath(path); ok {
+		if name == "" {
+			return nil, filesystem.NewInvalidArgumentError("path", path, "is a directory")
+		}
+		data, err := fs.plugin.catalogFile(name)
+		if err != nil {
+			return nil, err
+		}
+		return plugin.ApplyRangeRead(data, offset, size)
+	}
+	if dbName, tableName, key, isRow, ok := fs.rowsPath(path); ok {
+		if !isRow {
+			return nil, filesystem.NewInvalidArgumentError("path", path, "is a directory")
+		}
+		data, err := fs.readRow(path, dbName, tableName, key)
+		if err != nil {
+			return nil, err
+		}
+		return plugin.ApplyRangeRead(data, offset, size)
+	}
+	if dbName, name, ok := parseMigrationsPath(path); ok {
+		if name == "" {
+			return nil, filesystem.NewInvalidArgumentError("path", path, "is a directory")
+		}
+		data, err := fs.readMigrationFile(path, dbName, name)
+		if err != nil {
+			return nil, err
+		}
+		return plugin.ApplyRangeRead(data, offset, size)
+	}
+
 	dbName, tableName, sid, operation, err := fs.parsePath(path)
 	if err != nil {
 		return nil, err
@@ -589,9 +746,19 @@ func (fs *sqlfs2FS) Read(path string, offset int64, size int64) ([]byte, error)
 		}
 
 		switch operation {
-		case "result":
+		case "result", "result.json", "result.csv", "result.md":
+			result, err := session.readResult(operation)
+			if err != nil {
+				return nil, err
+			}
+			return plugin.ApplyRangeRead(result, offset, size)
+
+		case "format":
+			return plugin.ApplyRangeRead(session.readFormat(), offset, size)
+
+		case "last_script_result":
 			session.mu.Lock()
-			result := session.result
+			result := session.scriptResult
 			session.mu.Unlock()
 
 			if result == nil {
@@ -610,7 +777,7 @@ func (fs *sqlfs2FS) Read(path string, offset int64, size int64) ([]byte, error)
 			data := []byte(errMsg + "\n")
 			return plugin.ApplyRangeRead(data, offset, size)
 
-		case "query", "data", "ctl":
+		case "query", "data", "ctl", "execute":
 			return nil, fmt.Errorf("%s is write-only", operation)
 
 		case "":
@@ -656,9 +823,19 @@ func (fs *sqlfs2FS) Read(path string, offset int64, size int64) ([]byte, error)
 		}
 
 		switch operation {
-		case "result":
+		case "result", "result.json", "result.csv", "result.md":
+			result, err := session.readResult(operation)
+			if err != nil {
+				return nil, err
+			}
+			return plugin.ApplyRangeRead(result, offset, size)
+
+		case "format":
+			return plugin.ApplyRangeRead(session.readFormat(), offset, size)
+
+		case "last_script_result":
 			session.mu.Lock()
-			result := session.result
+			result := session.scriptResult
 			session.mu.Unlock()
 
 			if result == nil {
@@ -677,7 +854,7 @@ func (fs *sqlfs2FS) Read(path string, offset int64, size int64) ([]byte, error)
 			data := []byte(errMsg + "\n")
 			return plugin.ApplyRangeRead(data, offset, size)
 
-		case "query", "data", "ctl":
+		case "query", "data", "ctl", "execute":
 			return nil, fmt.Errorf("%s is write-only", operation)
 
 		case "":
@@ -769,9 +946,19 @@ func (fs *sqlfs2FS) Read(path string, offset int64, size int64) ([]byte, error)
 	}
 
 	switch operation {
-	case "result":
+	case "result", "result.json", "result.csv", "result.md":
+		result, err := session.readResult(operation)
+		if err != nil {
+			return nil, err
+		}
+		return plugin.ApplyRangeRead(result, offset, size)
+
+	case "format":
+		return plugin.ApplyRangeRead(session.readFormat(), offset, size)
+
+	case "last_script_result":
 		session.mu.Lock()
-		result := session.result
+		result := session.scriptResult
 		session.mu.Unlock()
 
 		if result == nil {
@@ -790,7 +977,7 @@ func (fs *sqlfs2FS) Read(path string, offset int64, size int64) ([]byte, error)
 		data := []byte(errMsg + "\n")
 		return plugin.ApplyRangeRead(data, offset, size)
 
-	case "query", "data", "ctl":
+	case "query", "data", "ctl", "execute":
 		return nil, fmt.Errorf("%s is write-only", operation)
 
 	case "":
@@ -803,6 +990,28 @@ func (fs *sqlfs2FS) Read(path string, offset int64, size int64) ([]byte, error)
 }
 
 func (fs *sqlfs2FS) Write(path string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	if _, ok := parseCatalogPath(path); ok {
+		return 0, fmt.Errorf("%s is read-only", catalogDir)
+	}
+	if dbName, tableName, key, isRow, ok := fs.rowsPath(path); ok {
+		if !isRow {
+			return 0, fmt.Errorf("cannot write to directory: %s", path)
+		}
+		if err := fs.writeRow(path, dbName, tableName, key, data, offset); err != nil {
+			return 0, err
+		}
+		return int64(len(data)), nil
+	}
+	if dbName, name, ok := parseMigrationsPath(path); ok {
+		if name == "" {
+			return 0, fmt.Errorf("cannot write to directory: %s", path)
+		}
+		if err := fs.writeMigration(path, dbName, name, data, offset); err != nil {
+			return 0, err
+		}
+		return int64(len(data)), nil
+	}
+
 	dbName, tableName, sid, operation, err := fs.parsePath(path)
 	if err != nil {
 		return 0, err
@@ -863,7 +1072,7 @@ func (fs *sqlfs2FS) Write(path string, data []byte, offset int64, flags filesyst
 				rows, err := session.tx.Query(sqlStmt)
 				if err != nil {
 					session.lastError = err.Error()
-					session.result = nil
+					session.setResult(nil, nil)
 					return 0, fmt.Errorf("query error: %w", err)
 				}
 				defer rows.Close()
@@ -871,7 +1080,7 @@ func (fs *sqlfs2FS) Write(path string, data []byte, offset int64, flags filesyst
 				columns, err := rows.Columns()
 				if err != nil {
 					session.lastError = err.Error()
-					session.result = nil
+					session.setResult(nil, nil)
 					return 0, fmt.Errorf("failed to get columns: %w", err)
 				}
 
@@ -885,7 +1094,7 @@ func (fs *sqlfs2FS) Write(path string, data []byte, offset int64, flags filesyst
 
 					if err := rows.Scan(valuePtrs...); err != nil {
 						session.lastError = err.Error()
-						session.result = nil
+						session.setResult(nil, nil)
 						return 0, fmt.Errorf("scan error: %w", err)
 					}
 
@@ -903,23 +1112,28 @@ func (fs *sqlfs2FS) Write(path string, data []byte, offset int64, flags filesyst
 
 				if err := rows.Err(); err != nil {
 					session.lastError = err.Error()
-					session.result = nil
+					session.setResult(nil, nil)
 					return 0, fmt.Errorf("rows error: %w", err)
 				}
 
 				jsonData, err := json.MarshalIndent(results, "", "  ")
 				if err != nil {
 					session.lastError = err.Error()
-					session.result = nil
+					session.setResult(nil, nil)
 					return 0, fmt.Errorf("json marshal error: %w", err)
 				}
-				session.result = append(jsonData, '\n')
+				session.setResult(append(jsonData, '\n'), newResultTable(columns, results))
 				session.lastError = ""
 			} else {
+				if err := session.checkWritable(sqlStmt); err != nil {
+					session.lastError = err.Error()
+					session.setResult(nil, nil)
+					return 0, err
+				}
 				result, err := session.tx.Exec(sqlStmt)
 				if err != nil {
 					session.lastError = err.Error()
-					session.result = nil
+					session.setResult(nil, nil)
 					return 0, fmt.Errorf("execution error: %w", err)
 				}
 
@@ -931,13 +1145,27 @@ func (fs *sqlfs2FS) Write(path string, data []byte, offset int64, flags filesyst
 					"last_insert_id": lastInsertId,
 				}
 				jsonData, _ := json.MarshalIndent(resultMap, "", "  ")
-				session.result = append(jsonData, '\n')
+				session.setResult(append(jsonData, '\n'), mapTable(resultMap))
 				session.lastError = ""
 			}
 
 			return int64(len(data)), nil
 
-		case "result", "error":
+		case "execute":
+			if err := fs.executeScript(session, string(data)); err != nil {
+				return 0, err
+			}
+			return int64(len(data)), nil
+
+		case "format":
+			format, err := parseFormat(string(data))
+			if err != nil {
+				return 0, err
+			}
+			session.format = format
+			return int64(len(data)), nil
+
+		case "result", "result.json", "result.csv", "result.md", "error", "last_script_result":
 			return 0, fmt.Errorf("%s is read-only", operation)
 
 		case "":
@@ -1003,7 +1231,7 @@ func (fs *sqlfs2FS) Write(path string, data []byte, offset int64, flags filesyst
 				rows, err := session.tx.Query(sqlStmt)
 				if err != nil {
 					session.lastError = err.Error()
-					session.result = nil
+					session.setResult(nil, nil)
 					return 0, fmt.Errorf("query error: %w", err)
 				}
 				defer rows.Close()
@@ -1011,7 +1239,7 @@ func (fs *sqlfs2FS) Write(path string, data []byte, offset int64, flags filesyst
 				columns, err := rows.Columns()
 				if err != nil {
 					session.lastError = err.Error()
-					session.result = nil
+					session.setResult(nil, nil)
 					return 0, fmt.Errorf("failed to get columns: %w", err)
 				}
 
@@ -1025,7 +1253,7 @@ func (fs *sqlfs2FS) Write(path string, data []byte, offset int64, flags filesyst
 
 					if err := rows.Scan(valuePtrs...); err != nil {
 						session.lastError = err.Error()
-						session.result = nil
+						session.setResult(nil, nil)
 						return 0, fmt.Errorf("scan error: %w", err)
 					}
 
@@ -1043,23 +1271,28 @@ func (fs *sqlfs2FS) Write(path string, data []byte, offset int64, flags filesyst
 
 				if err := rows.Err(); err != nil {
 					session.lastError = err.Error()
-					session.result = nil
+					session.setResult(nil, nil)
 					return 0, fmt.Errorf("rows error: %w", err)
 				}
 
 				jsonData, err := json.MarshalIndent(results, "", "  ")
 				if err != nil {
 					session.lastError = err.Error()
-					session.result = nil
+					session.setResult(nil, nil)
 					return 0, fmt.Errorf("json marshal error: %w", err)
 				}
-				session.result = append(jsonData, '\n')
+				session.setResult(append(jsonData, '\n'), newResultTable(columns, results))
 				session.lastError = ""
 			} else {
+				if err := session.checkWritable(sqlStmt); err != nil {
+					session.lastError = err.Error()
+					session.setResult(nil, nil)
+					return 0, err
+				}
 				result, err := session.tx.Exec(sqlStmt)
 				if err != nil {
 					session.lastError = err.Error()
-					session.result = nil
+					session.setResult(nil, nil)
 					return 0, fmt.Errorf("execution error: %w", err)
 				}
 
@@ -1071,13 +1304,27 @@ func (fs *sqlfs2FS) Write(path string, data []byte, offset int64, flags filesyst
 					"last_insert_id": lastInsertId,
 				}
 				jsonData, _ := json.MarshalIndent(resultMap, "", "  ")
-				session.result = append(jsonData, '\n')
+				session.setResult(append(jsonData, '\n'), mapTable(resultMap))
 				session.lastError = ""
 			}
 
 			return int64(len(data)), nil
 
-		case "result", "error":
+		case "execute":
+			if err := fs.executeScript(session, string(data)); err != nil {
+				return 0, err
+			}
+			return int64(len(data)), nil
+
+		case "format":
+			format, err := parseFormat(string(data))
+			if err != nil {
+				return 0, err
+			}
+			session.format = format
+			return int64(len(data)), nil
+
+		case "result", "result.json", "result.csv", "result.md", "error", "last_script_result":
 			return 0, fmt.Errorf("%s is read-only", operation)
 
 		case "":
@@ -1144,7 +1391,7 @@ func (fs *sqlfs2FS) Write(path string, data []byte, offset int64, flags filesyst
 			rows, err := session.tx.Query(sqlStmt)
 			if err != nil {
 				session.lastError = err.Error()
-				session.result = nil
+				session.setResult(nil, nil)
 				return 0, fmt.Errorf("query error: %w", err)
 			}
 			defer rows.Close()
@@ -1153,7 +1400,7 @@ func (fs *sqlfs2FS) Write(path string, data []byte, offset int64, flags filesyst
 			columns, err := rows.Columns()
 			if err != nil {
 				session.lastError = err.Error()
-				session.result = nil
+				session.setResult(nil, nil)
 				return 0, fmt.Errorf("failed to get columns: %w", err)
 			}
 
@@ -1168,7 +1415,7 @@ func (fs *sqlfs2FS) Write(path string, data []byte, offset int64, flags filesyst
 
 				if err := rows.Scan(valuePtrs...); err != nil {
 					session.lastError = err.Error()
-					session.result = nil
+					session.setResult(nil, nil)
 					return 0, fmt.Errorf("scan error: %w", err)
 				}
 
@@ -1186,7 +1433,7 @@ func (fs *sqlfs2FS) Write(path string, data []byte, offset int64, flags filesyst
 
 			if err := rows.Err(); err != nil {
 				session.lastError = err.Error()
-				session.result = nil
+				session.setResult(nil, nil)
 				return 0, fmt.Errorf("rows error: %w", err)
 			}
 
@@ -1194,17 +1441,22 @@ func (fs *sqlfs2FS) Write(path string, data []byte, offset int64, flags filesyst
 			jsonData, err := json.MarshalIndent(results, "", "  ")
 			if err != nil {
 				session.lastError = err.Error()
-				session.result = nil
+				session.setResult(nil, nil)
 				return 0, fmt.Errorf("json marshal error: %w", err)
 			}
-			session.result = append(jsonData, '\n')
+			session.setResult(append(jsonData, '\n'), newResultTable(columns, results))
 			session.lastError = ""
 		} else {
 			// Execute DML statement (INSERT, UPDATE, DELETE, etc.)
+			if err := session.checkWritable(sqlStmt); err != nil {
+				session.lastError = err.Error()
+				session.setResult(nil, nil)
+				return 0, err
+			}
 			result, err := session.tx.Exec(sqlStmt)
 			if err != nil {
 				session.lastError = err.Error()
-				session.result = nil
+				session.setResult(nil, nil)
 				return 0, fmt.Errorf("execution error: %w", err)
 			}
 
@@ -1217,13 +1469,18 @@ func (fs *sqlfs2FS) Write(path string, data []byte, offset int64, flags filesyst
 				"last_insert_id": lastInsertId,
 			}
 			jsonData, _ := json.MarshalIndent(resultMap, "", "  ")
-			session.result = append(jsonData, '\n')
+			session.setResult(append(jsonData, '\n'), mapTable(resultMap))
 			session.lastError = ""
 		}
 
 		return int64(len(data)), nil
 
 	case "data":
+		if session.readOnly {
+			session.lastError = errReadOnly.Error()
+			return 0, errReadOnly
+		}
+
 		// Insert JSON data
 		columns, err := fs.plugin.backend.GetTableColumns(fs.plugin.db, dbName, tableName)
 		if err != nil {
@@ -1333,7 +1590,7 @@ func (fs *sqlfs2FS) Write(path string, data []byte, offset int64, flags filesyst
 
 			if _, err := session.tx.Exec(insertSQL, values...); err != nil {
 				session.lastError = fmt.Sprintf("insert error at record %d: %v", idx+1, err)
-				session.result = nil
+				session.setResult(nil, nil)
 				return 0, fmt.Errorf("insert error at record %d: %w", idx+1, err)
 			}
 			insertedCount++
@@ -1344,12 +1601,26 @@ func (fs *sqlfs2FS) Write(path string, data []byte, offset int64, flags filesyst
 			"inserted_count": insertedCount,
 		}
 		jsonData, _ := json.MarshalIndent(resultMap, "", "  ")
-		session.result = append(jsonData, '\n')
+		session.setResult(append(jsonData, '\n'), mapTable(resultMap))
 		session.lastError = ""
 
 		return int64(len(data)), nil
 
-	case "result", "error":
+	case "execute":
+		if err := fs.executeScript(session, string(data)); err != nil {
+			return 0, err
+		}
+		return int64(len(data)), nil
+
+	case "format":
+		format, err := parseFormat(string(data))
+		if err != nil {
+			return 0, err
+		}
+		session.format = format
+		return int64(len(data)), nil
+
+	case "result", "result.json", "result.csv", "result.md", "error", "last_script_result":
 		return 0, fmt.Errorf("%s is read-only", operation)
 
 	case "":
@@ -1369,10 +1640,31 @@ func (fs *sqlfs2FS) Mkdir(path string, perm uint32) error {
 }
 
 func (fs *sqlfs2FS) Remove(path string) error {
+	// Removing a row file deletes the row
+	if dbName, tableName, key, isRow, ok := fs.rowsPath(path); ok && isRow {
+		return fs.removeRow(path, dbName, tableName, key)
+	}
+	// Removing a migration file drops a migration that is not applied
+	if dbName, name, ok := parseMigrationsPath(path); ok && name != "" {
+		return fs.removeMigration(path, dbName, name)
+	}
 	return fmt.Errorf("operation not supported: remove")
 }
 
 func (fs *sqlfs2FS) RemoveAll(path string) error {
+	if dbName, tableName, key, isRow, ok := fs.rowsPath(path); ok {
+		if !isRow {
+			return fmt.Errorf("operation not supported: remove rows one by one")
+		}
+		return fs.removeRow(path, dbName, tableName, key)
+	}
+	if dbName, name, ok := parseMigrationsPath(path); ok {
+		if name == "" {
+			return fmt.Errorf("operation not supported: remove migrations one by one")
+		}
+		return fs.removeMigration(path, dbName, name)
+	}
+
 	dbName, tableName, sid, operation, err := fs.parsePath(path)
 	if err != nil {
 		return err
@@ -1390,6 +1682,11 @@ func (fs *sqlfs2FS) RemoveAll(path string) error {
 		return fs.sessionManager.CloseSession(dbName, "", sid)
 	}
 
+	// Databases and tables of read-only connections cannot be dropped
+	if fs.plugin.readOnly && dbName != "" && sid == "" && operation == "" {
+		return errReadOnly
+	}
+
 	// Support removing database (DROP DATABASE)
 	// Path should be /dbName
 	if dbName != "" && tableName == "" && sid == "" && operation == "" {
@@ -1433,6 +1730,33 @@ func (fs *sqlfs2FS) RemoveAll(path string) error {
 }
 
 func (fs *sqlfs2FS) ReadDir(path string) ([]filesystem.FileInfo, error) {
+	if name, ok := parseCatalogPath(path); ok {
+		if name != "" {
+			return nil, fmt.Errorf("not a directory: %s", path)
+		}
+		var entries []filesystem.FileInfo
+		for _, file := range catalogFiles {
+			info, err := fs.catalogFileInfo(file)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, *info)
+		}
+		return entries, nil
+	}
+	if dbName, tableName, _, isRow, ok := fs.rowsPath(path); ok {
+		if isRow {
+			return nil, fmt.Errorf("not a directory: %s", path)
+		}
+		return fs.listRows(path, dbName, tableName)
+	}
+	if dbName, name, ok := parseMigrationsPath(path); ok {
+		if name != "" {
+			return nil, fmt.Errorf("not a directory: %s", path)
+		}
+		return fs.listMigrations(dbName)
+	}
+
 	dbName, tableName, sid, operation, err := fs.parsePath(path)
 	if err != nil {
 		return nil, err
@@ -1453,6 +1777,10 @@ func (fs *sqlfs2FS) ReadDir(path string) ([]filesystem.FileInfo, error) {
 			},
 		}
 
+		// Add the catalog of all databases
+		catalogInfo, _ := fs.catalogFileInfo("")
+		entries = append(entries, *catalogInfo)
+
 		// Add root-level sessions
 		sids := fs.sessionManager.ListSessions("", "")
 		for _, s := range sids {
@@ -1516,6 +1844,17 @@ func (fs *sqlfs2FS) ReadDir(path string) ([]filesystem.FileInfo, error) {
 				IsDir:   false,
 				Meta:    filesystem.MetaData{Name: PluginName, Type: "result"},
 			},
+			resultFileInfo("result.json", now),
+			resultFileInfo("result.csv", now),
+			resultFileInfo("result.md", now),
+			{
+				Name:    "format",
+				Size:    0,
+				Mode:    0644,
+				ModTime: now,
+				IsDir:   false,
+				Meta:    filesystem.MetaData{Name: PluginName, Type: "format"},
+			},
 			{
 				Name:    "error",
 				Size:    0,
@@ -1524,6 +1863,22 @@ func (fs *sqlfs2FS) ReadDir(path string) ([]filesystem.FileInfo, error) {
 				IsDir:   false,
 				Meta:    filesystem.MetaData{Name: PluginName, Type: "error"},
 			},
+			{
+				Name:    "execute",
+				Size:    0,
+				Mode:    0222,
+				ModTime: now,
+				IsDir:   false,
+				Meta:    filesystem.MetaData{Name: PluginName, Type: "execute"},
+			},
+			{
+				Name:    "last_script_result",
+				Size:    0,
+				Mode:    0444,
+				ModTime: now,
+				IsDir:   false,
+				Meta:    filesystem.MetaData{Name: PluginName, Type: "last_script_result"},
+			},
 		}, nil
 	}
 
@@ -1538,6 +1893,14 @@ func (fs *sqlfs2FS) ReadDir(path string) ([]filesystem.FileInfo, error) {
 				IsDir:   false,
 				Meta:    filesystem.MetaData{Name: PluginName, Type: "ctl"},
 			},
+			{
+				Name:    migrationsDir,
+				Size:    0,
+				Mode:    0755,
+				ModTime: now,
+				IsDir:   true,
+				Meta:    filesystem.MetaData{Name: PluginName, Type: "migrations"},
+			},
 		}
 
 		// Add database-level sessions
@@ -1603,6 +1966,17 @@ func (fs *sqlfs2FS) ReadDir(path string) ([]filesystem.FileInfo, error) {
 				IsDir:   false,
 				Meta:    filesystem.MetaData{Name: PluginName, Type: "result"},
 			},
+			resultFileInfo("result.json", now),
+			resultFileInfo("result.csv", now),
+			resultFileInfo("result.md", now),
+			{
+				Name:    "format",
+				Size:    0,
+				Mode:    0644,
+				ModTime: now,
+				IsDir:   false,
+				Meta:    filesystem.MetaData{Name: PluginName, Type: "format"},
+			},
 			{
 				Name:    "error",
 				Size:    0,
@@ -1611,6 +1985,22 @@ func (fs *sqlfs2FS) ReadDir(path string) ([]filesystem.FileInfo, error) {
 				IsDir:   false,
 				Meta:    filesystem.MetaData{Name: PluginName, Type: "error"},
 			},
+			{
+				Name:    "execute",
+				Size:    0,
+				Mode:    0222,
+				ModTime: now,
+				IsDir:   false,
+				Meta:    filesystem.MetaData{Name: PluginName, Type: "execute"},
+			},
+			{
+				Name:    "last_script_result",
+				Size:    0,
+				Mode:    0444,
+				ModTime: now,
+				IsDir:   false,
+				Meta:    filesystem.MetaData{Name: PluginName, Type: "last_script_result"},
+			},
 		}, nil
 	}
 
@@ -1652,6 +2042,18 @@ func (fs *sqlfs2FS) ReadDir(path string) ([]filesystem.FileInfo, error) {
 			},
 		}
 
+		// Add the rows of tables with a primary key
+		if fs.hasRowsDir(dbName, tableName) {
+			entries = append(entries, filesystem.FileInfo{
+				Name:    rowsDir,
+				Size:    0,
+				Mode:    0755,
+				ModTime: now,
+				IsDir:   true,
+				Meta:    filesystem.MetaData{Name: PluginName, Type: "rows"},
+			})
+		}
+
 		// Add active session directories
 		sids := fs.sessionManager.ListSessions(dbName, tableName)
 		for _, s := range sids {
@@ -1700,6 +2102,17 @@ func (fs *sqlfs2FS) ReadDir(path string) ([]filesystem.FileInfo, error) {
 				IsDir:   false,
 				Meta:    filesystem.MetaData{Name: PluginName, Type: "result"},
 			},
+			resultFileInfo("result.json", now),
+			resultFileInfo("result.csv", now),
+			resultFileInfo("result.md", now),
+			{
+				Name:    "format",
+				Size:    0,
+				Mode:    0644,
+				ModTime: now,
+				IsDir:   false,
+				Meta:    filesystem.MetaData{Name: PluginName, Type: "format"},
+			},
 			{
 				Name:    "data",
 				Size:    0,
@@ -1716,6 +2129,22 @@ func (fs *sqlfs2FS) ReadDir(path string) ([]filesystem.FileInfo, error) {
 				IsDir:   false,
 				Meta:    filesystem.MetaData{Name: PluginName, Type: "error"},
 			},
+			{
+				Name:    "execute",
+				Size:    0,
+				Mode:    0222,
+				ModTime: now,
+				IsDir:   false,
+				Meta:    filesystem.MetaData{Name: PluginName, Type: "execute"},
+			},
+			{
+				Name:    "last_script_result",
+				Size:    0,
+				Mode:    0444,
+				ModTime: now,
+				IsDir:   false,
+				Meta:    filesystem.MetaData{Name: PluginName, Type: "last_script_result"},
+			},
 		}, nil
 	}
 
@@ -1723,6 +2152,16 @@ func (fs *sqlfs2FS) ReadDir(path string) ([]filesystem.FileInfo, error) {
 }
 
 func (fs *sqlfs2FS) Stat(path string) (*filesystem.FileInfo, error) {
+	if name, ok := parseCatalogPath(path); ok {
+		return fs.catalogFileInfo(name)
+	}
+	if dbName, tableName, key, isRow, ok := fs.rowsPath(path); ok {
+		return fs.rowsFileInfo(path, dbName, tableName, key, isRow)
+	}
+	if dbName, name, ok := parseMigrationsPath(path); ok {
+		return fs.migrationsFileInfo(path, dbName, name)
+	}
+
 	dbName, tableName, sid, operation, err := fs.parsePath(path)
 	if err != nil {
 		return nil, err
@@ -1778,9 +2217,11 @@ func (fs *sqlfs2FS) Stat(path string) (*filesystem.FileInfo, error) {
 		}
 		var mode uint32
 		switch operation {
-		case "ctl", "query":
+		case "ctl", "query", "execute":
 			mode = 0222 // write-only
-		case "result", "error":
+		case "format":
+			mode = 0644
+		case "result", "result.json", "result.csv", "result.md", "error", "last_script_result":
 			mode = 0444 // read-only
 		default:
 			return nil, fmt.Errorf("unknown session file: %s", operation)
@@ -1843,9 +2284,11 @@ func (fs *sqlfs2FS) Stat(path string) (*filesystem.FileInfo, error) {
 		}
 		var mode uint32
 		switch operation {
-		case "ctl", "query":
+		case "ctl", "query", "execute":
 			mode = 0222 // write-only
-		case "result", "error":
+		case "format":
+			mode = 0644
+		case "result", "result.json", "result.csv", "result.md", "error", "last_script_result":
 			mode = 0444 // read-only
 		default:
 			return nil, fmt.Errorf("unknown session file: %s", operation)
@@ -1920,9 +2363,11 @@ func (fs *sqlfs2FS) Stat(path string) (*filesystem.FileInfo, error) {
 
 		var mode uint32
 		switch operation {
-		case "ctl", "query", "data":
+		case "ctl", "query", "data", "execute":
 			mode = 0222 // write-only
-		case "result", "error":
+		case "format":
+			mode = 0644
+		case "result", "result.json", "result.csv", "result.md", "error", "last_script_result":
 			mode = 0444 // read-only
 		default:
 			return nil, fmt.Errorf("unknown session file: %s", operation)
@@ -1974,15 +2419,27 @@ This plugin provides a Plan 9 style SQL interface through file system operations
 Each SQL session is represented as a directory with control files.
 
 DIRECTORY STRUCTURE:
+  /sqlfs2/.catalog/
+    tables.json      # Read-only: all tables, sizes and row estimates
+    columns.json     # Read-only: all columns
+    schema.json      # Read-only: databases > tables > columns
+  /sqlfs2/<dbName>/.migrations/
+    status.json      # Read-only: applied, pending and failed migrations
+    <version>_<name>.sql  # Write to apply migrations in version order
   /sqlfs2/<dbName>/<tableName>/
     ctl              # Read to create new session, returns session ID
     schema           # Read-only: table structure (CREATE TABLE)
     count            # Read-only: row count
+    rows_by_pk/      # With rows_by_pk: <pk>.json per row (read, write to upsert, rm)
     <sid>/           # Session directory (numeric ID)
       ctl            # Write "close" to close session
       query          # Write SQL to execute
-      result         # Read query results (JSON)
+      result         # Read query results (JSON, or as set in format)
+      result.csv     # Read query results as CSV (also result.md, result.json)
+      format         # Read or write the format of result: json, csv or markdown
       data           # Write JSON to insert
+      execute        # Write a multi-statement SQL script
+      last_script_result  # Read per-statement results of the last script
       error          # Read error messages
 
 BASIC WORKFLOW:
@@ -1995,11 +2452,24 @@ BASIC WORKFLOW:
 
   # Read results
   cat /sqlfs2/mydb/users/$sid/result
+  cat /sqlfs2/mydb/users/$sid/result.md    # as a Markdown table
 
   # Close session
   echo close > /sqlfs2/mydb/users/$sid/ctl
   # or: rm -rf /sqlfs2/mydb/users/$sid
 
+  # Discover the schema of all databases in one read
+  cat /sqlfs2/.catalog/schema.json
+
+  # Evolve the schema with numbered migration files
+  echo 'ALTER TABLE users ADD COLUMN email TEXT' > /sqlfs2/mydb/.migrations/0002_add_email.sql
+  cat /sqlfs2/mydb/.migrations/status.json
+
+  # With rows_by_pk = true: rows as files, by primary key
+  cat /sqlfs2/mydb/users/rows_by_pk/42.json
+  echo '{"name": "alice"}' > /sqlfs2/mydb/users/rows_by_pk/42.json  # upsert
+  rm /sqlfs2/mydb/users/rows_by_pk/42.json                          # delete
+
 CONFIGURATION:
 
   SQLite Backend:
@@ -2038,6 +2508,27 @@ CONFIGURATION:
     database = "test"
     enable_tls = true  # For TiDB Cloud
 
+  Named Connections (one directory per connection):
+  [plugins.sqlfs2]
+  enabled = true
+  path = "/sqlfs2"
+
+    [plugins.sqlfs2.config]
+    backend = "mysql"        # Keys here apply to every connection
+    session_timeout = "10m"
+
+    [plugins.sqlfs2.config.connections.prod]
+    host = "db.prod"
+    user = "reader"
+    password = "env:PROD_DB_PASSWORD"
+    read_only = true         # Only SELECT, SHOW, DESCRIBE and EXPLAIN
+
+    [plugins.sqlfs2.config.connections.staging]
+    host = "db.staging"
+    user = "root"
+
+  # Each connection is a top-level directory: /sqlfs2/prod/mydb/users/ctl
+
 USAGE EXAMPLES:
 
   # View table schema
@@ -2067,6 +2558,13 @@ USAGE EXAMPLES:
   {"name": "Frank", "age": 35}
   EOF
 
+  # Run a script atomically, statement by statement
+  cat <<EOF > /sqlfs2/mydb/users/$sid/execute
+  UPDATE users SET age = age + 1;
+  DELETE FROM users WHERE age > 100;
+  EOF
+  cat /sqlfs2/mydb/users/$sid/last_script_result  # rows_affected per statement
+
   # Check for errors
   cat /sqlfs2/mydb/users/$sid/error
 
@@ -2099,6 +2597,8 @@ ADVANTAGES:
   - Auto-generate INSERT from JSON documents
   - NDJSON streaming for large imports
   - Configurable session timeout
+  - Several databases with their own credentials in one mount
+  - Read-only connections
 `
 }
 
@@ -2425,6 +2925,9 @@ func (h *SQLFileHandle) executeBufferedSQL() error {
 	if sqlStmt == "" {
 		return nil
 	}
+	if h.fs.plugin.readOnly && (h.operation != "query" || !isQueryStatement(sqlStmt)) {
+		return errReadOnly
+	}
 
 	switch h.operation {
 	case "query":
@@ -2653,6 +3156,17 @@ func (h *SQLFileHandle) Stat() (*filesystem.FileInfo, error) {
 
 // OpenHandle opens a file and returns a handle with a new transaction
 func (fs *sqlfs2FS) OpenHandle(path string, flags filesystem.OpenFlag, mode uint32) (filesystem.FileHandle, error) {
+	// Catalog, row and migration files are served by Read and Write
+	if _, ok := parseCatalogPath(path); ok {
+		return nil, filesystem.ErrNotSupported
+	}
+	if _, _, _, _, ok := fs.rowsPath(path); ok {
+		return nil, filesystem.ErrNotSupported
+	}
+	if _, _, ok := parseMigrationsPath(path); ok {
+		return nil, filesystem.ErrNotSupported
+	}
+
 	dbName, tableName, sid, operation, err := fs.parsePath(path)
 	if err != nil {
 		return nil, err
@@ -2689,7 +3203,7 @@ func (fs *sqlfs2FS) OpenHandle(path string, flags filesystem.OpenFlag, mode uint
 	}
 
 	// Start a new transaction
-	tx, err := fs.plugin.db.Begin()
+	tx, err := fs.plugin.db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: fs.plugin.readOnly})
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
@@ -0,0 +1,610 @@
+package sqlfs2
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		hash   bool
+		want   []string
+	}{
+		{"simple", "SELECT 1; SELECT 2", false, []string{"SELECT 1", "SELECT 2"}},
+		{"trailing semicolon", "SELECT 1;\n\n", false, []string{"SELECT 1"}},
+		{"semicolon in string", "INSERT INTO t VALUES ('a;b'); SELECT 1", false,
+			[]string{"INSERT INTO t VALUES ('a;b')", "SELECT 1"}},
+		{"escaped quotes", `INSERT INTO t VALUES ('it''s;', "say \";\""); SELECT 1`, false,
+			[]string{`INSERT INTO t VALUES ('it''s;', "say \";\"")`, "SELECT 1"}},
+		{"quoted identifier", "SELECT `a;b` FROM t; SELECT 2", false, []string{"SELECT `a;b` FROM t", "SELECT 2"}},
+		{"line comment", "-- setup; ignored\nSELECT 1; -- done;\n", false, []string{"SELECT 1"}},
+		{"block comment", "/* a; b */ SELECT /* ; */ 1;", false, []string{"SELECT   1"}},
+		{"executable comment", "SELECT /*+ MAX_EXECUTION_TIME(1) */ 1", false,
+			[]string{"SELECT /*+ MAX_EXECUTION_TIME(1) */ 1"}},
+		{"hash comment", "# note; here\nSELECT 1", true, []string{"SELECT 1"}},
+		{"hash without hash comments", "SELECT '#'; SELECT 2", false, []string{"SELECT '#'", "SELECT 2"}},
+		{"empty", " ; ;\n-- nothing\n", false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitStatements(tt.script, tt.hash)
+			if err != nil {
+				t.Fatalf("splitStatements failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	for _, script := range []string{"SELECT 'unterminated", "SELECT 1 /* open"} {
+		if _, err := splitStatements(script, false); err == nil {
+			t.Errorf("expected error for %q", script)
+		}
+	}
+}
+
+func newTestFS(t *testing.T) filesystem.FileSystem {
+	t.Helper()
+	p := NewSQLFS2Plugin()
+	if err := p.Initialize(map[string]interface{}{
+		"backend": "sqlite",
+		"db_path": filepath.Join(t.TempDir(), "test.db"),
+	}); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	t.Cleanup(func() { p.Shutdown() })
+	return p.GetFileSystem()
+}
+
+func readScriptResult(t *testing.T, fs filesystem.FileSystem, sid string) scriptResult {
+	t.Helper()
+	data, err := fs.Read("/"+sid+"/last_script_result", 0, -1)
+	if err != nil && err != io.EOF {
+		t.Fatalf("failed to read last_script_result: %v", err)
+	}
+	var result scriptResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("invalid last_script_result %q: %v", data, err)
+	}
+	return result
+}
+
+func TestExecuteScript(t *testing.T) {
+	fs := newTestFS(t)
+	data, err := fs.Read("/ctl", 0, -1)
+	if err != nil && err != io.EOF {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	sid := strings.TrimSpace(string(data))
+
+	script := `
+		CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);
+		-- two users
+		INSERT INTO users (name) VALUES ('alice'), ('bob; the builder');
+		SELECT * FROM users;
+	`
+	if _, err := fs.Write("/"+sid+"/execute", []byte(script), 0, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("script failed: %v", err)
+	}
+	result := readScriptResult(t, fs, sid)
+	if result.Statements != 3 || result.Succeeded != 3 || result.RolledBack {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if r := result.Results[1]; r.RowsAffected == nil || *r.RowsAffected != 2 {
+		t.Errorf("expected 2 rows affected, got %+v", r)
+	}
+	if r := result.Results[2]; r.Rows == nil || *r.Rows != 2 {
+		t.Errorf("expected 2 rows, got %+v", r)
+	}
+
+	// A failing statement rolls back the whole script
+	script = `
+		INSERT INTO users (name) VALUES ('carol');
+		INSERT INTO missing (name) VALUES ('dave');
+		DELETE FROM users;
+	`
+	if _, err := fs.Write("/"+sid+"/execute", []byte(script), 0, filesystem.WriteFlagNone); err == nil {
+		t.Fatal("expected script error")
+	}
+	result = readScriptResult(t, fs, sid)
+	if result.Succeeded != 1 || !result.RolledBack {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	statuses := []string{result.Results[0].Status, result.Results[1].Status, result.Results[2].Status}
+	if !reflect.DeepEqual(statuses, []string{"ok", "error", "skipped"}) {
+		t.Errorf("unexpected statuses: %v", statuses)
+	}
+	if result.Results[1].Error == "" {
+		t.Error("expected error of failed statement")
+	}
+	errMsg, _ := fs.Read("/"+sid+"/error", 0, -1)
+	if !strings.Contains(string(errMsg), "statement 2") {
+		t.Errorf("expected error file to name the statement, got %q", errMsg)
+	}
+
+	if _, err := fs.Write("/"+sid+"/query", []byte("SELECT COUNT(*) AS n FROM users"), 0, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	count, _ := fs.Read("/"+sid+"/result", 0, -1)
+	if !strings.Contains(string(count), `"n": 2`) {
+		t.Errorf("expected the failed script to be rolled back, got %s", count)
+	}
+}
+
+func TestResultFormats(t *testing.T) {
+	fs := newTestFS(t)
+	data, err := fs.Read("/ctl", 0, -1)
+	if err != nil && err != io.EOF {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	sid := strings.TrimSpace(string(data))
+	read := func(name string) string {
+		t.Helper()
+		data, err := fs.Read("/"+sid+"/"+name, 0, -1)
+		if err != nil && err != io.EOF {
+			t.Fatalf("failed to read %s: %v", name, err)
+		}
+		return string(data)
+	}
+
+	script := `
+		CREATE TABLE notes (title TEXT, body TEXT, stars INTEGER);
+		INSERT INTO notes VALUES ('a|b', 'line 1
+line 2', 3), ('plain, with comma', NULL, 1);
+	`
+	if _, err := fs.Write("/"+sid+"/execute", []byte(script), 0, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("script failed: %v", err)
+	}
+	query := "SELECT title, body, stars FROM notes ORDER BY stars DESC"
+	if _, err := fs.Write("/"+sid+"/query", []byte(query), 0, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+
+	wantCSV := "title,body,stars\na|b,\"line 1\nline 2\",3\n\"plain, with comma\",,1\n"
+	if got := read("result.csv"); got != wantCSV {
+		t.Errorf("result.csv = %q, want %q", got, wantCSV)
+	}
+	wantMarkdown := "| title | body | stars |\n| --- | --- | --- |\n| a\\|b | line 1<br>line 2 | 3 |\n| plain, with comma | NULL | 1 |\n"
+	if got := read("result.md"); got != wantMarkdown {
+		t.Errorf("result.md = %q, want %q", got, wantMarkdown)
+	}
+	if got := read("result"); got != read("result.json") || !strings.HasPrefix(got, "[") {
+		t.Errorf("expected result in JSON by default, got %q", got)
+	}
+
+	// The format file sets the format of result
+	if got := read("format"); got != "json\n" {
+		t.Errorf("format = %q, want json", got)
+	}
+	if _, err := fs.Write("/"+sid+"/format", []byte("md\n"), 0, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("failed to set format: %v", err)
+	}
+	if got := read("result"); got != wantMarkdown {
+		t.Errorf("result in markdown = %q", got)
+	}
+	if _, err := fs.Write("/"+sid+"/format", []byte("xml"), 0, filesystem.WriteFlagNone); err == nil {
+		t.Error("expected error for unknown format")
+	}
+
+	// Results of other statements are one-row tables
+	if _, err := fs.Write("/"+sid+"/query", []byte("DELETE FROM notes"), 0, filesystem.WriteFlagNone); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if got := read("result.csv"); !strings.HasPrefix(got, "last_insert_id,rows_affected\n") || !strings.HasSuffix(got, ",2\n") {
+		t.Errorf("unexpected result.csv of delete: %q", got)
+	}
+
+	entries, err := fs.ReadDir("/" + sid)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	names := make(map[string]bool)
+	for _, e := range entries {
+		names[e.Name] = true
+	}
+	for _, name := range []string{"result.csv", "result.md", "result.json", "format"} {
+		if !names[name] {
+			t.Errorf("expected %s in session directory", name)
+		}
+	}
+	if info, err := fs.Stat("/" + sid + "/format"); err != nil || info.Mode != 0644 {
+		t.Errorf("unexpected stat of format: %+v, %v", info, err)
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	fs := newTestFS(t)
+	db := fs.(*sqlfs2FS).plugin.db
+	for _, stmt := range []string{
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL, age INTEGER DEFAULT 0)",
+		"CREATE VIEW adults AS SELECT * FROM users WHERE age >= 18",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("failed to create schema: %v", err)
+		}
+	}
+
+	entries, err := fs.ReadDir("/.catalog")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != len(catalogFiles) {
+		t.Errorf("expected %d catalog files, got %d", len(catalogFiles), len(entries))
+	}
+
+	data, err := fs.Read("/.catalog/schema.json", 0, -1)
+	if err != nil && err != io.EOF {
+		t.Fatalf("failed to read schema.json: %v", err)
+	}
+	var schema struct {
+		Backend   string `json:"backend"`
+		Databases []struct {
+			Name   string `json:"name"`
+			Tables []struct {
+				CatalogTable
+				Columns []CatalogColumn `json:"columns"`
+			} `json:"tables"`
+		} `json:"databases"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("invalid schema.json: %v", err)
+	}
+	if schema.Backend != "sqlite" || len(schema.Databases) != 1 || schema.Databases[0].Name != "main" {
+		t.Fatalf("unexpected schema: %s", data)
+	}
+	tables := schema.Databases[0].Tables
+	if len(tables) != 2 || tables[0].Name != "adults" || tables[0].Type != "view" || tables[1].Name != "users" {
+		t.Fatalf("unexpected tables: %s", data)
+	}
+	columns := tables[1].Columns
+	if len(columns) != 3 {
+		t.Fatalf("expected 3 columns, got %d", len(columns))
+	}
+	if columns[0].Key != "PRI" || columns[1].Nullable || !columns[2].Nullable ||
+		columns[2].Default == nil || *columns[2].Default != "0" {
+		t.Errorf("unexpected columns: %+v", columns)
+	}
+
+	info, err := fs.Stat("/.catalog/tables.json")
+	if err != nil || info.Size == 0 {
+		t.Errorf("unexpected stat of tables.json: %+v, %v", info, err)
+	}
+	if _, err := fs.Read("/.catalog/missing.json", 0, -1); err == nil {
+		t.Error("expected error for unknown catalog file")
+	}
+	if _, err := fs.Write("/.catalog/tables.json", []byte("{}"), 0, filesystem.WriteFlagNone); err == nil {
+		t.Error("expected catalog to be read-only")
+	}
+}
+
+func TestIsRead(t *testing.T) {
+	p := NewSQLFS2Plugin()
+	tests := []struct {
+		op, path string
+		want     bool
+	}{
+		{"read", "/main/users/schema", true},
+		{"read", "/main/users/count", true},
+		{"stat", "/main/users", true},
+		{"stat", "/main", true},
+		{"read", "/.catalog/tables.json", true},
+		{"readdir", "/.catalog", false},
+		{"readdir", "/main/users", false},  // lists sessions
+		{"read", "/main/users/ctl", false}, // creates a session
+		{"read", "/main/users/1/result", false},
+		{"stat", "/main/users/1", false},
+	}
+	for _, tt := range tests {
+		if got := p.IsRead(tt.op, tt.path); got != tt.want {
+			t.Errorf("IsRead(%s, %s) = %v, want %v", tt.op, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestConnections(t *testing.T) {
+	dir := t.TempDir()
+	cfg := map[string]interface{}{
+		"backend": "sqlite",
+		"connections": map[string]interface{}{
+			"prod":    map[string]interface{}{"db_path": filepath.Join(dir, "prod.db"), "read_only": true},
+			"staging": map[string]interface{}{"db_path": filepath.Join(dir, "staging.db")},
+		},
+	}
+	p := NewSQLFS2Plugin()
+	if err := p.Validate(cfg); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if err := p.Initialize(cfg); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	t.Cleanup(func() { p.Shutdown() })
+	fs := p.GetFileSystem()
+
+	entries, err := fs.ReadDir("/")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Name != "prod" || entries[1].Name != "staging" {
+		t.Fatalf("unexpected root entries: %+v", entries)
+	}
+	if entries[0].Mode != 0555 || entries[1].Mode != 0755 {
+		t.Errorf("modes = %o, %o, want 555, 755", entries[0].Mode, entries[1].Mode)
+	}
+
+	newSession := func(conn string) string {
+		t.Helper()
+		data, err := fs.Read("/"+conn+"/ctl", 0, -1)
+		if err != nil && err != io.EOF {
+			t.Fatalf("failed to create session on %s: %v", conn, err)
+		}
+		return "/" + conn + "/" + strings.TrimSpace(string(data))
+	}
+	write := func(path, data string) error {
+		_, err := fs.Write(path, []byte(data), 0, filesystem.WriteFlagNone)
+		return err
+	}
+
+	staging := newSession("staging")
+	if err := write(staging+"/query", "CREATE TABLE t (x INTEGER)"); err != nil {
+		t.Fatalf("create table on staging failed: %v", err)
+	}
+	if err := write(staging+"/query", "SELECT x FROM t"); err != nil {
+		t.Errorf("query on staging failed: %v", err)
+	}
+
+	prod := newSession("prod")
+	if err := write(prod+"/query", "CREATE TABLE t (x INTEGER)"); err == nil {
+		t.Error("expected create table on read-only prod to fail")
+	}
+	if err := write(prod+"/execute", "SELECT 1; DELETE FROM sqlite_master"); err == nil {
+		t.Error("expected script with a write on read-only prod to fail")
+	}
+	if err := write(prod+"/query", "SELECT 1 AS one"); err != nil {
+		t.Errorf("query on read-only prod failed: %v", err)
+	}
+	if err := fs.RemoveAll("/prod/main"); err == nil {
+		t.Error("expected dropping a database of read-only prod to fail")
+	}
+
+	// Each connection has its own database
+	if err := write(prod+"/query", "SELECT x FROM t"); err == nil {
+		t.Error("table of staging visible in prod")
+	}
+	if _, err := fs.Stat("/test/main"); err == nil {
+		t.Error("expected unknown connection to be not found")
+	}
+	if !p.IsRead("read", "/prod/main/t/schema") || p.IsRead("read", "/prod/ctl") {
+		t.Error("IsRead does not route the paths of connections")
+	}
+}
+
+func TestConnectionConfigs(t *testing.T) {
+	p := NewSQLFS2Plugin()
+	for _, cfg := range []map[string]interface{}{
+		{"connections": map[string]interface{}{}},
+		{"connections": map[string]interface{}{".catalog": map[string]interface{}{}}},
+		{"connections": map[string]interface{}{"prod": "sqlite"}},
+		{"connections": map[string]interface{}{"prod": map[string]interface{}{"backend": "oracle"}}},
+		{"connections": map[string]interface{}{"prod": map[string]interface{}{"connections": map[string]interface{}{}}}},
+	} {
+		if err := p.Validate(cfg); err == nil {
+			t.Errorf("expected Validate(%v) to fail", cfg)
+		}
+	}
+
+	configs, err := connectionConfigs(map[string]interface{}{
+		"backend": "mysql",
+		"user":    "root",
+		"connections": map[string]interface{}{
+			"prod": map[string]interface{}{"user": "reader"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("connectionConfigs failed: %v", err)
+	}
+	want := map[string]interface{}{"backend": "mysql", "user": "reader"}
+	if !reflect.DeepEqual(configs["prod"], want) {
+		t.Errorf("prod config = %v, want %v", configs["prod"], want)
+	}
+}
+
+func TestRowsByPK(t *testing.T) {
+	fs := newTestFS(t)
+	p := fs.(*sqlfs2FS).plugin
+	p.rowsByPK = true
+	for _, stmt := range []string{
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL, age INTEGER DEFAULT 0)",
+		"CREATE TABLE tags (name TEXT PRIMARY KEY, color TEXT)",
+		"CREATE TABLE log (msg TEXT)",
+		"INSERT INTO users (id, name, age) VALUES (1, 'alice', 30), (2, 'bob', 25)",
+	} {
+		if _, err := p.db.Exec(stmt); err != nil {
+			t.Fatalf("failed to create schema: %v", err)
+		}
+	}
+	write := func(path, data string) error {
+		_, err := fs.Write(path, []byte(data), 0, filesystem.WriteFlagNone)
+		return err
+	}
+	readRow := func(path string) map[string]interface{} {
+		t.Helper()
+		data, err := fs.Read(path, 0, -1)
+		if err != nil && err != io.EOF {
+			t.Fatalf("failed to read %s: %v", path, err)
+		}
+		var row map[string]interface{}
+		if err := json.Unmarshal(data, &row); err != nil {
+			t.Fatalf("invalid row %q: %v", data, err)
+		}
+		return row
+	}
+
+	entries, err := fs.ReadDir("/main/users/rows_by_pk")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Name != "1.json" || entries[1].Name != "2.json" {
+		t.Fatalf("unexpected rows: %+v", entries)
+	}
+	if row := readRow("/main/users/rows_by_pk/1.json"); row["name"] != "alice" || row["age"] != float64(30) {
+		t.Errorf("unexpected row: %v", row)
+	}
+	if _, err := fs.Read("/main/users/rows_by_pk/3.json", 0, -1); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("expected missing row to be not found, got %v", err)
+	}
+
+	// Writes insert new rows and update the given columns of existing ones
+	if err := write("/main/users/rows_by_pk/3.json", `{"name": "carol"}`); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if err := write("/main/users/rows_by_pk/1.json", `{"id": 1, "age": 31}`); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if row := readRow("/main/users/rows_by_pk/1.json"); row["name"] != "alice" || row["age"] != float64(31) {
+		t.Errorf("unexpected updated row: %v", row)
+	}
+	if row := readRow("/main/users/rows_by_pk/3.json"); row["name"] != "carol" || row["age"] != float64(0) {
+		t.Errorf("unexpected inserted row: %v", row)
+	}
+	for _, data := range []string{`{"id": 2}`, `{"email": "x"}`, `[1]`, `not json`} {
+		if err := write("/main/users/rows_by_pk/1.json", data); !errors.Is(err, filesystem.ErrInvalidArgument) {
+			t.Errorf("expected %s to be rejected, got %v", data, err)
+		}
+	}
+
+	// Text keys are path-escaped
+	if err := write("/main/tags/rows_by_pk/a%2Fb.json", `{"color": "red"}`); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	entries, err = fs.ReadDir("/main/tags/rows_by_pk")
+	if err != nil || len(entries) != 1 || entries[0].Name != "a%2Fb.json" {
+		t.Errorf("unexpected tag rows: %+v, %v", entries, err)
+	}
+
+	if err := fs.Remove("/main/users/rows_by_pk/2.json"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if err := fs.Remove("/main/users/rows_by_pk/2.json"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("expected removed row to be not found, got %v", err)
+	}
+	info, err := fs.Stat("/main/users/rows_by_pk/3.json")
+	if err != nil || info.IsDir || info.Size == 0 {
+		t.Errorf("unexpected stat of a row: %+v, %v", info, err)
+	}
+
+	// Only tables with a single-column primary key have rows
+	if _, err := fs.Stat("/main/log/rows_by_pk"); err == nil {
+		t.Error("expected table without a primary key to have no rows_by_pk")
+	}
+	entries, err = fs.ReadDir("/main/log")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.Name == rowsDir {
+			t.Error("rows_by_pk listed in a table without a primary key")
+		}
+	}
+	if !p.IsRead("read", "/main/users/rows_by_pk/1.json") || p.IsRead("write", "/main/users/rows_by_pk/1.json") {
+		t.Error("IsRead does not route row files")
+	}
+
+	p.readOnly = true
+	if err := write("/main/users/rows_by_pk/4.json", `{"name": "dan"}`); err != errReadOnly {
+		t.Errorf("expected write on a read-only mount to fail, got %v", err)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	fs := newTestFS(t)
+	write := func(path, data string) error {
+		_, err := fs.Write(path, []byte(data), 0, filesystem.WriteFlagNone)
+		return err
+	}
+	status := func() map[string]interface{} {
+		t.Helper()
+		data, err := fs.Read("/main/.migrations/status.json", 0, -1)
+		if err != nil && err != io.EOF {
+			t.Fatalf("failed to read status.json: %v", err)
+		}
+		var s map[string]interface{}
+		if err := json.Unmarshal(data, &s); err != nil {
+			t.Fatalf("invalid status.json %q: %v", data, err)
+		}
+		return s
+	}
+
+	if s := status(); s["applied"] != float64(0) || len(s["migrations"].([]interface{})) != 0 {
+		t.Errorf("unexpected initial status: %v", s)
+	}
+	if err := write("/main/.migrations/0001_users.sql",
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);\nINSERT INTO users (name) VALUES ('alice');"); err != nil {
+		t.Fatalf("migration 1 failed: %v", err)
+	}
+	if _, err := fs.Stat("/main/users"); err != nil {
+		t.Errorf("migration 1 not applied: %v", err)
+	}
+
+	// A failed migration is rolled back and blocks the ones after it
+	if err := write("/main/.migrations/0002_age.sql", "ALTER TABLE users ADD COLUMN age INTEGER; SELECT nope FROM users"); err == nil {
+		t.Fatal("expected migration 2 to fail")
+	}
+	if err := write("/main/.migrations/0003_tags.sql", "CREATE TABLE tags (name TEXT)"); err == nil {
+		t.Fatal("expected migration 3 to be blocked by migration 2")
+	}
+	if s := status(); s["applied"] != float64(1) || s["failed"] != float64(1) || s["pending"] != float64(1) {
+		t.Errorf("unexpected status after a failure: %v", s)
+	}
+	if err := write("/main/.migrations/0002_age.sql", "ALTER TABLE users ADD COLUMN age INTEGER"); err != nil {
+		t.Fatalf("rewritten migration 2 failed: %v", err)
+	}
+	if s := status(); s["applied"] != float64(3) || s["current_version"] != float64(3) {
+		t.Errorf("unexpected status once applied: %v", s)
+	}
+
+	entries, err := fs.ReadDir("/main/.migrations")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 4 || entries[0].Name != "status.json" || entries[1].Name != "0001_users.sql" || entries[3].Mode != 0444 {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+	data, err := fs.Read("/main/.migrations/0003_tags.sql", 0, -1)
+	if (err != nil && err != io.EOF) || string(data) != "CREATE TABLE tags (name TEXT)" {
+		t.Errorf("unexpected migration content %q, %v", data, err)
+	}
+
+	// Applied migrations are immutable; rewriting one unchanged is a no-op
+	if err := write("/main/.migrations/0003_tags.sql", "CREATE TABLE tags (name TEXT)"); err != nil {
+		t.Errorf("rewriting an applied migration unchanged failed: %v", err)
+	}
+	for path, sql := range map[string]string{
+		"/main/.migrations/0003_tags.sql":  "DROP TABLE tags",
+		"/main/.migrations/0003_other.sql": "SELECT 1",
+		"/main/.migrations/0000_early.sql": "SELECT 1",
+		"/main/.migrations/notes.sql":      "SELECT 1",
+		"/main/.migrations/0004_empty.sql": " ",
+	} {
+		if err := write(path, sql); !errors.Is(err, filesystem.ErrInvalidArgument) {
+			t.Errorf("expected %s to be rejected, got %v", path, err)
+		}
+	}
+	if err := fs.Remove("/main/.migrations/0001_users.sql"); !errors.Is(err, filesystem.ErrInvalidArgument) {
+		t.Errorf("expected removing an applied migration to fail, got %v", err)
+	}
+
+	fs.(*sqlfs2FS).plugin.readOnly = true
+	if err := write("/main/.migrations/0004_more.sql", "SELECT 1"); err != errReadOnly {
+		t.Errorf("expected migration on a read-only mount to fail, got %v", err)
+	}
+}
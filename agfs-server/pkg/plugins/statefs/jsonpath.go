@@ -0,0 +1,545 @@
+package statefs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a parsed JSONPath query: a list of selectors applied in turn
+// to the values selected so far
+//
+// The supported syntax is the common subset of JSONPath implementations:
+//
+//	$                  the document ($ may be omitted: "a.b" is "$.a.b")
+//	.name, ['name']    an object member
+//	[0], [-1]          an array element, negative from the end
+//	[0,2], ['a','b']   several members or elements
+//	[1:3], [::2]       an array slice (start:end:step, step > 0)
+//	.*, [*]            all members or elements
+//	..name, ..*        recursive descent
+//	[?(@.a > 1)]       members or elements matching a filter; filters compare
+//	                   @ (or $) paths with literals or paths using ==, !=,
+//	                   <, <=, >, >=, test existence with a bare path and are
+//	                   combined with && and ||
+type jsonPath []selector
+
+// selector selects values from a value
+type selector struct {
+	recursive bool // Apply to the value and all its descendants
+	wildcard  bool
+	union     []unionItem
+	slice     *sliceSpec
+	filter    filterExpr
+}
+
+type unionItem struct {
+	name    string
+	index   int
+	isIndex bool
+}
+
+type sliceSpec struct {
+	start, end *int
+	step       int
+}
+
+// filterExpr is a disjunction of conjunctions of comparisons
+type filterExpr [][]comparison
+
+type comparison struct {
+	left  operand
+	op    string // Empty to test that left exists
+	right operand
+}
+
+type operand struct {
+	path     jsonPath // nil for literals
+	absolute bool     // Path is relative to the document ($), not the candidate (@)
+	literal  interface{}
+}
+
+// parseJSONPath parses a JSONPath query
+func parseJSONPath(expr string) (jsonPath, error) {
+	expr = strings.TrimSpace(expr)
+	switch {
+	case expr == "":
+		return nil, fmt.Errorf("empty JSONPath")
+	case expr[0] == '$':
+	case expr[0] == '.' || expr[0] == '[':
+		expr = "$" + expr
+	default:
+		expr = "$." + expr
+	}
+	return parseRelativePath(expr[1:])
+}
+
+// parseRelativePath parses the selectors following $ or @
+func parseRelativePath(s string) (jsonPath, error) {
+	var path jsonPath
+	for pos := 0; pos < len(s); {
+		var sel selector
+		switch {
+		case strings.HasPrefix(s[pos:], ".."):
+			sel.recursive = true
+			pos += 2
+			if pos < len(s) && s[pos] == '[' {
+				break
+			}
+			pos = parseMember(s, pos, &sel)
+		case s[pos] == '.':
+			pos = parseMember(s, pos+1, &sel)
+		case s[pos] == '[':
+		default:
+			return nil, fmt.Errorf("unexpected %q at offset %d of JSONPath", s[pos:], pos)
+		}
+
+		if pos < len(s) && s[pos] == '[' && !sel.wildcard && sel.union == nil {
+			end := matchingBracket(s, pos)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated [ in JSONPath")
+			}
+			if err := parseBracket(strings.TrimSpace(s[pos+1:end]), &sel); err != nil {
+				return nil, err
+			}
+			pos = end + 1
+		}
+		if !sel.wildcard && sel.union == nil && sel.slice == nil && sel.filter == nil {
+			return nil, fmt.Errorf("missing member name in JSONPath")
+		}
+		path = append(path, sel)
+	}
+	return path, nil
+}
+
+// parseMember parses the name or * after a dot and returns the offset
+// following it
+func parseMember(s string, pos int, sel *selector) int {
+	if pos < len(s) && s[pos] == '*' {
+		sel.wildcard = true
+		return pos + 1
+	}
+	end := pos
+	for end < len(s) && s[end] != '.' && s[end] != '[' {
+		end++
+	}
+	if end > pos {
+		sel.union = []unionItem{{name: s[pos:end]}}
+	}
+	return end
+}
+
+// matchingBracket returns the offset of the ] closing the [ at pos, -1 if
+// there is none
+func matchingBracket(s string, pos int) int {
+	depth := 0
+	var quote byte
+	for i := pos; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[' || c == '(':
+			depth++
+		case c == ']' || c == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitTopLevel splits s at the occurrences of sep outside quotes,
+// brackets and parentheses
+func splitTopLevel(s, sep string) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[' || c == '(':
+			depth++
+		case c == ']' || c == ')':
+			depth--
+		case depth == 0 && strings.HasPrefix(s[i:], sep):
+			parts = append(parts, s[start:i])
+			i += len(sep) - 1
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func parseBracket(content string, sel *selector) error {
+	switch {
+	case content == "*":
+		sel.wildcard = true
+		return nil
+	case strings.HasPrefix(content, "?"):
+		filter := strings.TrimSpace(content[1:])
+		if strings.HasPrefix(filter, "(") && matchingBracket(filter, 0) == len(filter)-1 {
+			filter = filter[1 : len(filter)-1]
+		}
+		expr, err := parseFilter(filter)
+		if err != nil {
+			return err
+		}
+		sel.filter = expr
+		return nil
+	}
+
+	if parts := splitTopLevel(content, ":"); len(parts) > 1 {
+		return parseSlice(parts, sel)
+	}
+
+	for _, item := range splitTopLevel(content, ",") {
+		item = strings.TrimSpace(item)
+		if name, ok := unquote(item); ok {
+			sel.union = append(sel.union, unionItem{name: name})
+			continue
+		}
+		index, err := strconv.Atoi(item)
+		if err != nil {
+			return fmt.Errorf("invalid JSONPath selector [%s]", content)
+		}
+		sel.union = append(sel.union, unionItem{index: index, isIndex: true})
+	}
+	return nil
+}
+
+func parseSlice(parts []string, sel *selector) error {
+	if len(parts) > 3 {
+		return fmt.Errorf("invalid JSONPath slice [%s]", strings.Join(parts, ":"))
+	}
+	spec := &sliceSpec{step: 1}
+	bounds := []**int{&spec.start, &spec.end}
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return fmt.Errorf("invalid JSONPath slice [%s]", strings.Join(parts, ":"))
+		}
+		if i == 2 {
+			if n <= 0 {
+				return fmt.Errorf("JSONPath slice step must be positive")
+			}
+			spec.step = n
+			continue
+		}
+		*bounds[i] = &n
+	}
+	sel.slice = spec
+	return nil
+}
+
+// unquote returns the content of a single or double quoted string
+func unquote(s string) (string, bool) {
+	if len(s) < 2 || s[0] != s[len(s)-1] || s[0] != '\'' && s[0] != '"' {
+		return "", false
+	}
+	if s[0] == '\'' {
+		inner := strings.ReplaceAll(s[1:len(s)-1], `\'`, `'`)
+		s = strconv.Quote(inner)
+	}
+	var unquoted string
+	if err := json.Unmarshal([]byte(s), &unquoted); err != nil {
+		return "", false
+	}
+	return unquoted, true
+}
+
+var comparisonOperators = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+func parseFilter(s string) (filterExpr, error) {
+	var expr filterExpr
+	for _, disjunct := range splitTopLevel(s, "||") {
+		var conjunction []comparison
+		for _, conjunct := range splitTopLevel(disjunct, "&&") {
+			c, err := parseComparison(strings.TrimSpace(conjunct))
+			if err != nil {
+				return nil, err
+			}
+			conjunction = append(conjunction, c)
+		}
+		expr = append(expr, conjunction)
+	}
+	return expr, nil
+}
+
+func parseComparison(s string) (comparison, error) {
+	for _, op := range comparisonOperators {
+		parts := splitTopLevel(s, op)
+		if len(parts) != 2 {
+			continue
+		}
+		left, err := parseOperand(strings.TrimSpace(parts[0]))
+		if err != nil {
+			return comparison{}, err
+		}
+		right, err := parseOperand(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return comparison{}, err
+		}
+		return comparison{left: left, op: op, right: right}, nil
+	}
+
+	left, err := parseOperand(s)
+	if err != nil {
+		return comparison{}, err
+	}
+	if left.path == nil {
+		return comparison{}, fmt.Errorf("invalid JSONPath filter %q: expected a path or a comparison", s)
+	}
+	return comparison{left: left}, nil
+}
+
+func parseOperand(s string) (operand, error) {
+	if s != "" && (s[0] == '@' || s[0] == '$') {
+		path, err := parseRelativePath(s[1:])
+		if err != nil {
+			return operand{}, err
+		}
+		if path == nil {
+			path = jsonPath{}
+		}
+		return operand{path: path, absolute: s[0] == '$'}, nil
+	}
+	if str, ok := unquote(s); ok {
+		return operand{literal: str}, nil
+	}
+	literal, err := decodeJSON([]byte(s))
+	if err != nil {
+		return operand{}, fmt.Errorf("invalid JSONPath filter operand %q", s)
+	}
+	return operand{literal: literal}, nil
+}
+
+// query returns the values a path selects in a document, in document order
+// (object members in key order)
+func (p jsonPath) query(doc interface{}) []interface{} {
+	return p.eval(doc, doc)
+}
+
+func (p jsonPath) eval(root, node interface{}) []interface{} {
+	nodes := []interface{}{node}
+	for _, sel := range p {
+		var next []interface{}
+		for _, n := range nodes {
+			candidates := []interface{}{n}
+			if sel.recursive {
+				candidates = descendants(n, nil)
+			}
+			for _, c := range candidates {
+				next = sel.apply(root, c, next)
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// descendants appends a value and, depth first, all values it contains
+func descendants(v interface{}, out []interface{}) []interface{} {
+	out = append(out, v)
+	for _, child := range children(v) {
+		out = descendants(child, out)
+	}
+	return out
+}
+
+// children returns the members of an object or the elements of an array
+func children(v interface{}) []interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		values := make([]interface{}, 0, len(v))
+		for _, k := range sortedKeys(v) {
+			values = append(values, v[k])
+		}
+		return values
+	case []interface{}:
+		return v
+	default:
+		return nil
+	}
+}
+
+// apply appends the values sel selects from v
+func (sel selector) apply(root, v interface{}, out []interface{}) []interface{} {
+	switch {
+	case sel.wildcard:
+		return append(out, children(v)...)
+	case sel.filter != nil:
+		for _, child := range children(v) {
+			if sel.filter.matches(root, child) {
+				out = append(out, child)
+			}
+		}
+		return out
+	case sel.slice != nil:
+		if s, ok := v.([]interface{}); ok {
+			start, end := sel.slice.bounds(len(s))
+			for i := start; i < end; i += sel.slice.step {
+				out = append(out, s[i])
+			}
+		}
+		return out
+	}
+
+	for _, item := range sel.union {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if member, ok := v[item.name]; ok && !item.isIndex {
+				out = append(out, member)
+			}
+		case []interface{}:
+			i := item.index
+			if i < 0 {
+				i += len(v)
+			}
+			if item.isIndex && i >= 0 && i < len(v) {
+				out = append(out, v[i])
+			}
+		}
+	}
+	return out
+}
+
+// bounds resolves the start and end of a slice of an array of length n
+func (s *sliceSpec) bounds(n int) (int, int) {
+	resolve := func(bound *int, def int) int {
+		if bound == nil {
+			return def
+		}
+		i := *bound
+		if i < 0 {
+			i += n
+		}
+		if i < 0 {
+			return 0
+		}
+		if i > n {
+			return n
+		}
+		return i
+	}
+	return resolve(s.start, 0), resolve(s.end, n)
+}
+
+func (f filterExpr) matches(root, v interface{}) bool {
+	for _, conjunction := range f {
+		matched := true
+		for _, c := range conjunction {
+			if !c.matches(root, v) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (c comparison) matches(root, v interface{}) bool {
+	left, ok := c.left.value(root, v)
+	if c.op == "" || !ok {
+		return ok
+	}
+	right, ok := c.right.value(root, v)
+	if !ok {
+		return false
+	}
+
+	switch c.op {
+	case "==":
+		return jsonEqual(left, right)
+	case "!=":
+		return !jsonEqual(left, right)
+	}
+
+	cmp, ok := compareValues(left, right)
+	if !ok {
+		return false
+	}
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+// value returns the value of an operand for a candidate v; ok is false
+// when its path selects nothing
+func (o operand) value(root, v interface{}) (interface{}, bool) {
+	if o.path == nil {
+		return o.literal, true
+	}
+	node := v
+	if o.absolute {
+		node = root
+	}
+	values := o.path.eval(root, node)
+	if len(values) == 0 {
+		return nil, false
+	}
+	return values[0], true
+}
+
+// compareValues orders two numbers or two strings; ok is false for other
+// values, which have no order
+func compareValues(a, b interface{}) (int, bool) {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return 0, false
+		}
+		af, aerr := a.Float64()
+		bf, berr := b.Float64()
+		if aerr != nil || berr != nil {
+			return 0, false
+		}
+		switch {
+		case af < bf:
+			return -1, true
+		case af > bf:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		b, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(a, b), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,382 @@
+package statefs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+// decodeJSON decodes a JSON value, keeping numbers as written
+func decodeJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after the JSON value")
+	}
+	return v, nil
+}
+
+// encodeJSON encodes a document the way it is stored and read
+func encodeJSON(v interface{}) ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// compactJSON encodes a value on one line, for error messages
+func compactJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// jsonEqual compares two decoded JSON values; numbers are compared by value
+func jsonEqual(a, b interface{}) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		af, aerr := a.Float64()
+		bf, berr := b.Float64()
+		if aerr != nil || berr != nil {
+			return a == b
+		}
+		return af == bf
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, av := range a {
+			bv, ok := b[k]
+			if !ok || !jsonEqual(av, bv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !jsonEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+// deepCopy copies a decoded JSON value, so that copies do not share
+// objects and arrays
+func deepCopy(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = deepCopy(e)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, e := range v {
+			s[i] = deepCopy(e)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+// sortedKeys returns the keys of an object in order, so that results do
+// not depend on map iteration
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into its unescaped tokens;
+// "" points to the whole document
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("JSON pointer %q must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses an array index token; "-" (past the end) is only
+// accepted when allowEnd is set, and returns len(s)
+func arrayIndex(token string, s []interface{}, allowEnd bool) (int, error) {
+	if token == "-" && allowEnd {
+		return len(s), nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || token != strconv.Itoa(i) {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	max := len(s) - 1
+	if allowEnd {
+		max = len(s)
+	}
+	if i > max {
+		return 0, fmt.Errorf("array index %d out of range", i)
+	}
+	return i, nil
+}
+
+// getPointer returns the value a pointer points to
+func getPointer(doc interface{}, tokens []string) (interface{}, error) {
+	node := doc
+	for _, token := range tokens {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			v, ok := n[token]
+			if !ok {
+				return nil, fmt.Errorf("member %q does not exist", token)
+			}
+			node = v
+		case []interface{}:
+			i, err := arrayIndex(token, n, false)
+			if err != nil {
+				return nil, err
+			}
+			node = n[i]
+		default:
+			return nil, fmt.Errorf("cannot index %s with %q", compactJSON(node), token)
+		}
+	}
+	return node, nil
+}
+
+// updatePointer calls fn with the parent of the value a non-empty pointer
+// points to and its last token. fn returns the updated parent, which
+// replaces the old one in the document (arrays may be reallocated).
+func updatePointer(node interface{}, tokens []string, fn func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 1 {
+		return fn(node, tokens[0])
+	}
+	switch n := node.(type) {
+	case map[string]interface{}:
+		child, ok := n[tokens[0]]
+		if !ok {
+			return nil, fmt.Errorf("member %q does not exist", tokens[0])
+		}
+		updated, err := updatePointer(child, tokens[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[tokens[0]] = updated
+		return n, nil
+	case []interface{}:
+		i, err := arrayIndex(tokens[0], n, false)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := updatePointer(n[i], tokens[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = updated
+		return n, nil
+	default:
+		return nil, fmt.Errorf("cannot index %s with %q", compactJSON(node), tokens[0])
+	}
+}
+
+func addValue(doc interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	return updatePointer(doc, tokens, func(parent interface{}, token string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			p[token] = value
+			return p, nil
+		case []interface{}:
+			i, err := arrayIndex(token, p, true)
+			if err != nil {
+				return nil, err
+			}
+			p = append(p, nil)
+			copy(p[i+1:], p[i:])
+			p[i] = value
+			return p, nil
+		default:
+			return nil, fmt.Errorf("cannot add %q to %s", token, compactJSON(parent))
+		}
+	})
+}
+
+func removeValue(doc interface{}, tokens []string) (interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("cannot remove the whole document")
+	}
+	return updatePointer(doc, tokens, func(parent interface{}, token string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := p[token]; !ok {
+				return nil, fmt.Errorf("member %q does not exist", token)
+			}
+			delete(p, token)
+			return p, nil
+		case []interface{}:
+			i, err := arrayIndex(token, p, false)
+			if err != nil {
+				return nil, err
+			}
+			return append(p[:i], p[i+1:]...), nil
+		default:
+			return nil, fmt.Errorf("cannot remove %q from %s", token, compactJSON(parent))
+		}
+	})
+}
+
+func replaceValue(doc interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	return updatePointer(doc, tokens, func(parent interface{}, token string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := p[token]; !ok {
+				return nil, fmt.Errorf("member %q does not exist", token)
+			}
+			p[token] = value
+			return p, nil
+		case []interface{}:
+			i, err := arrayIndex(token, p, false)
+			if err != nil {
+				return nil, err
+			}
+			p[i] = value
+			return p, nil
+		default:
+			return nil, fmt.Errorf("cannot replace %q in %s", token, compactJSON(parent))
+		}
+	})
+}
+
+// patchOperation is an operation of an RFC 6902 JSON Patch
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// applyPatch applies an RFC 6902 JSON Patch to a document. Operations are
+// applied in order; if one fails, the error is returned and the document
+// must be discarded. A failed "test" operation is a conflict.
+func applyPatch(path string, doc interface{}, patch []byte) (interface{}, error) {
+	var ops []patchOperation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, filesystem.NewInvalidArgumentError("patch", path, "expected a JSON array of patch operations: "+err.Error())
+	}
+
+	for i, op := range ops {
+		var err error
+		doc, err = applyOperation(path, doc, op)
+		if err != nil {
+			if _, ok := err.(*filesystem.ConflictError); ok {
+				return nil, err
+			}
+			return nil, filesystem.NewInvalidArgumentError("patch", path, fmt.Sprintf("operation %d (%s): %v", i, op.Op, err))
+		}
+	}
+	return doc, nil
+}
+
+func applyOperation(path string, doc interface{}, op patchOperation) (interface{}, error) {
+	if op.Path == nil {
+		return nil, fmt.Errorf("missing path")
+	}
+	tokens, err := parsePointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, fmt.Errorf("missing value")
+		}
+		if value, err = decodeJSON(op.Value); err != nil {
+			return nil, err
+		}
+	case "move", "copy":
+		if op.From == nil {
+			return nil, fmt.Errorf("missing from")
+		}
+		from, err := parsePointer(*op.From)
+		if err != nil {
+			return nil, err
+		}
+		if value, err = getPointer(doc, from); err != nil {
+			return nil, err
+		}
+		if op.Op == "copy" {
+			value = deepCopy(value)
+			break
+		}
+		if *op.Path == *op.From {
+			return doc, nil
+		}
+		if strings.HasPrefix(*op.Path, *op.From+"/") {
+			return nil, fmt.Errorf("cannot move %s into itself", *op.From)
+		}
+		if doc, err = removeValue(doc, from); err != nil {
+			return nil, err
+		}
+	case "remove":
+	default:
+		return nil, fmt.Errorf("unknown operation")
+	}
+
+	switch op.Op {
+	case "add", "move", "copy":
+		return addValue(doc, tokens, value)
+	case "remove":
+		return removeValue(doc, tokens)
+	case "replace":
+		return replaceValue(doc, tokens, value)
+	default: // test
+		actual, err := getPointer(doc, tokens)
+		if err != nil {
+			return nil, filesystem.NewConflictError("patch", path+*op.Path, compactJSON(value), "")
+		}
+		if !jsonEqual(actual, value) {
+			return nil, filesystem.NewConflictError("patch", path+*op.Path, compactJSON(value), compactJSON(actual))
+		}
+		return doc, nil
+	}
+}
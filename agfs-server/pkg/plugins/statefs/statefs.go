@@ -0,0 +1,631 @@
+package statefs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PluginName = "statefs" // Name of this plugin
+
+	queriesDir = "queries" // Directory of the saved queries
+
+	patchSuffix = "?patch" // Writing to <document>?patch applies a JSON Patch
+	queryPrefix = "?path=" // Reading <document>?path=<jsonpath> queries the document
+)
+
+// Meta values for StateFS plugin
+const (
+	MetaValueDocument  = "document"  // A JSON document
+	MetaValueQuery     = "query"     // A saved query
+	MetaValueDirectory = "directory" // The root and queries directories
+)
+
+// StateFSPlugin stores JSON documents in SQLite, for structured agent state
+//
+//	/<document>                    - a JSON document
+//	/<document>?patch              - write an RFC 6902 JSON Patch to apply it
+//	/<document>?path=<jsonpath>    - read the values a JSONPath selects
+//	/queries/<name>                - a saved query: write "<document> <jsonpath>",
+//	                                 read its current results
+type StateFSPlugin struct {
+	store    *store
+	metadata plugin.PluginMetadata
+}
+
+// NewStateFSPlugin creates a new JSON document store plugin
+func NewStateFSPlugin() *StateFSPlugin {
+	return &StateFSPlugin{
+		metadata: plugin.PluginMetadata{
+			Name:        PluginName,
+			Version:     "1.0.0",
+			Description: "JSON document store with JSON Patch writes and JSONPath queries",
+			Author:      "AGFS Server",
+		},
+	}
+}
+
+func (s *StateFSPlugin) Name() string {
+	return s.metadata.Name
+}
+
+func (s *StateFSPlugin) Validate(cfg map[string]interface{}) error {
+	allowedKeys := []string{"mount_path", "db_path"}
+	if err := config.ValidateOnlyKnownKeys(cfg, allowedKeys); err != nil {
+		return err
+	}
+	return config.ValidateStringType(cfg, "db_path")
+}
+
+func (s *StateFSPlugin) Initialize(cfg map[string]interface{}) error {
+	dbPath := config.GetStringConfig(cfg, "db_path", "statefs.db")
+	st, err := openStore(dbPath)
+	if err != nil {
+		return err
+	}
+	s.store = st
+
+	log.Infof("[statefs] Initialized with SQLite database %s", dbPath)
+	return nil
+}
+
+func (s *StateFSPlugin) GetFileSystem() filesystem.FileSystem {
+	return &stateFS{plugin: s}
+}
+
+func (s *StateFSPlugin) GetReadme() string {
+	return `StateFS Plugin - JSON Document Store
+
+This plugin stores JSON documents in SQLite, for structured agent memory:
+read and update parts of a document without rewriting or embedding it.
+
+STRUCTURE:
+  /README                         - This file
+  /<document>                     - A JSON document
+  /<document>?patch               - Write a JSON Patch to apply it
+  /<document>?path=<jsonpath>     - Read the values a JSONPath selects
+  /queries/<name>                 - A saved query of a document
+
+DOCUMENTS:
+  Write a JSON value to create or replace a document:
+    echo '{"tasks": [{"title": "triage", "done": false}]}' > /statefs/agent-1
+
+  Read it back (indented):
+    cat /statefs/agent-1
+
+  touch creates an empty object. Writes that are not valid JSON fail, as do
+  partial writes (offsets and appends): documents are replaced whole.
+
+PATCHES:
+  Write an RFC 6902 JSON Patch to <document>?patch:
+    echo '[{"op": "add", "path": "/tasks/-", "value": {"title": "fix", "done": false}},
+           {"op": "replace", "path": "/tasks/0/done", "value": true}]' > '/statefs/agent-1?patch'
+
+  Operations: add, remove, replace, move, copy and test, with RFC 6901 JSON
+  Pointer paths ("-" appends to an array). A patch applies atomically: if
+  an operation fails, the document is left unchanged. A failed test fails
+  with a conflict, so a patch can be conditioned on the current state:
+    [{"op": "test", "path": "/owner", "value": "agent-1"}, ...]
+
+QUERIES:
+  Read <document>?path=<jsonpath> to get the JSON array of matches:
+    cat '/statefs/agent-1?path=$.tasks[?(@.done == false)].title'
+    [
+      "fix"
+    ]
+
+  Supported JSONPath: $ (optional), .name, ['name'], [0], [-1], [0,2],
+  [start:end:step], * wildcards, .. recursive descent and filters
+  [?(@.a > 1 && @.b == 'x')] with ==, !=, <, <=, >, >=, && and ||.
+
+  Save a query as a file by writing "<document> <jsonpath>"; reading it
+  returns the current matches:
+    echo 'agent-1 $.tasks[?(@.done == false)]' > /statefs/queries/open-tasks
+    cat /statefs/queries/open-tasks
+
+  Over HTTP, pass the whole path, query included, as the path parameter
+  (URL-encoded).
+
+CONFIGURATION:
+  [plugins.statefs]
+  enabled = true
+  path = "/statefs"
+
+    [plugins.statefs.config]
+    db_path = "statefs.db"
+`
+}
+
+func (s *StateFSPlugin) GetConfigParams() []plugin.ConfigParameter {
+	return []plugin.ConfigParameter{
+		{
+			Name:        "db_path",
+			Type:        "string",
+			Required:    false,
+			Default:     "statefs.db",
+			Description: "SQLite database file of the documents",
+		},
+	}
+}
+
+func (s *StateFSPlugin) Shutdown() error {
+	if s.store != nil {
+		return s.store.close()
+	}
+	return nil
+}
+
+// pathKind is what a path of the mount names
+type pathKind int
+
+const (
+	pathRoot pathKind = iota
+	pathReadme
+	pathDocument
+	pathQueries
+	pathQuery
+)
+
+// statePath is a parsed path of the mount
+type statePath struct {
+	kind  pathKind
+	name  string // Document or saved query
+	patch bool   // <document>?patch
+	query string // JSONPath of <document>?path=<jsonpath>
+}
+
+// parseStatePath parses a path of the mount; the ?patch and ?path= suffixes
+// are split before the path is normalized, since JSONPaths may contain
+// slashes and dots
+func parseStatePath(p string) (statePath, error) {
+	base, suffix, hasSuffix := strings.Cut(p, "?")
+	base = strings.Trim(filesystem.NormalizePath(base), "/")
+
+	var sp statePath
+	switch {
+	case base == "":
+		sp.kind = pathRoot
+	case base == "README":
+		sp.kind = pathReadme
+	case base == queriesDir:
+		sp.kind = pathQueries
+	case strings.HasPrefix(base, queriesDir+"/") && !strings.Contains(base[len(queriesDir)+1:], "/"):
+		sp.kind = pathQuery
+		sp.name = base[len(queriesDir)+1:]
+	case !strings.Contains(base, "/"):
+		sp.kind = pathDocument
+		sp.name = base
+	default:
+		return statePath{}, filesystem.NewNotFoundError("stat", p)
+	}
+
+	if !hasSuffix {
+		return sp, nil
+	}
+	suffix = "?" + suffix
+	switch {
+	case sp.kind != pathDocument:
+		return statePath{}, filesystem.NewInvalidArgumentError("path", p, "only documents can be patched or queried")
+	case suffix == patchSuffix:
+		sp.patch = true
+	case strings.HasPrefix(suffix, queryPrefix):
+		sp.query = strings.TrimPrefix(suffix, queryPrefix)
+		if sp.query == "" {
+			return statePath{}, filesystem.NewInvalidArgumentError("path", p, "empty JSONPath")
+		}
+	default:
+		return statePath{}, filesystem.NewInvalidArgumentError("path", p,
+			fmt.Sprintf("expected %s or %s<jsonpath>", patchSuffix, queryPrefix))
+	}
+	return sp, nil
+}
+
+// stateFS implements the FileSystem interface for JSON documents
+type stateFS struct {
+	plugin *StateFSPlugin
+}
+
+func dirInfo(name string) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    name,
+		Mode:    0755,
+		ModTime: time.Now(),
+		IsDir:   true,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: MetaValueDirectory},
+	}
+}
+
+func readmeInfo(size int) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    "README",
+		Size:    int64(size),
+		Mode:    0444,
+		ModTime: time.Now(),
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "doc"},
+	}
+}
+
+func documentInfo(doc *document) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    doc.name,
+		Size:    doc.size,
+		Mode:    0644,
+		ModTime: doc.updatedAt,
+		Meta: filesystem.MetaData{Name: PluginName, Type: MetaValueDocument, Content: map[string]string{
+			"version": strconv.FormatInt(doc.version, 10),
+		}},
+	}
+}
+
+func queryInfo(q *savedQuery, size int) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    q.name,
+		Size:    int64(size),
+		Mode:    0644,
+		ModTime: q.updatedAt,
+		Meta: filesystem.MetaData{Name: PluginName, Type: MetaValueQuery, Content: map[string]string{
+			"document":   q.document,
+			"expression": q.expression,
+		}},
+	}
+}
+
+// runQuery returns the JSON array of the values a JSONPath selects in a
+// document
+func (sfs *stateFS) runQuery(p, name, expression string) ([]byte, error) {
+	path, err := parseJSONPath(expression)
+	if err != nil {
+		return nil, filesystem.NewInvalidArgumentError("path", expression, err.Error())
+	}
+	doc, err := sfs.plugin.store.getDocument(name)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, filesystem.NewNotFoundError("query", p)
+	}
+	value, err := decodeJSON(doc.data)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt document %s: %w", name, err)
+	}
+
+	results := path.query(value)
+	if results == nil {
+		results = []interface{}{}
+	}
+	return encodeJSON(results)
+}
+
+// runSavedQuery returns the results of a saved query, nil if it does not
+// exist
+func (sfs *stateFS) runSavedQuery(p, name string) (*savedQuery, []byte, error) {
+	q, err := sfs.plugin.store.getQuery(name)
+	if err != nil || q == nil {
+		return nil, nil, err
+	}
+	data, err := sfs.runQuery(p, q.document, q.expression)
+	return q, data, err
+}
+
+func (sfs *stateFS) Create(p string) error {
+	sp, err := parseStatePath(p)
+	if err != nil {
+		return err
+	}
+	if sp.kind != pathDocument || sp.patch || sp.query != "" {
+		return filesystem.NewPermissionDeniedError("create", p, "only documents can be created")
+	}
+	return sfs.plugin.store.updateDocument(sp.name, func(current *document) ([]byte, error) {
+		if current != nil {
+			return nil, filesystem.NewAlreadyExistsError("document", p)
+		}
+		return []byte("{}\n"), nil
+	})
+}
+
+func (sfs *stateFS) Mkdir(p string, perm uint32) error {
+	if _, err := sfs.Stat(p); err == nil {
+		return filesystem.NewAlreadyExistsError("directory", p)
+	}
+	return filesystem.NewPermissionDeniedError("mkdir", p, "documents are files at the root of the mount")
+}
+
+func (sfs *stateFS) Remove(p string) error {
+	sp, err := parseStatePath(p)
+	if err != nil {
+		return err
+	}
+
+	var found bool
+	switch {
+	case sp.kind == pathDocument && !sp.patch && sp.query == "":
+		found, err = sfs.plugin.store.deleteDocument(sp.name)
+	case sp.kind == pathQuery:
+		found, err = sfs.plugin.store.deleteQuery(sp.name)
+	default:
+		return filesystem.NewPermissionDeniedError("remove", p, "only documents and saved queries can be removed")
+	}
+	if err != nil {
+		return err
+	}
+	if !found {
+		return filesystem.NewNotFoundError("remove", p)
+	}
+	return nil
+}
+
+func (sfs *stateFS) RemoveAll(p string) error {
+	return sfs.Remove(p)
+}
+
+func (sfs *stateFS) Read(p string, offset int64, size int64) ([]byte, error) {
+	sp, err := parseStatePath(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	switch sp.kind {
+	case pathReadme:
+		data = []byte(sfs.plugin.GetReadme())
+	case pathRoot, pathQueries:
+		return nil, fmt.Errorf("is a directory: %s", p)
+	case pathQuery:
+		var q *savedQuery
+		q, data, err = sfs.runSavedQuery(p, sp.name)
+		if err != nil {
+			return nil, err
+		}
+		if q == nil {
+			return nil, filesystem.NewNotFoundError("read", p)
+		}
+	default:
+		if sp.query != "" {
+			if data, err = sfs.runQuery(p, sp.name, sp.query); err != nil {
+				return nil, err
+			}
+			break
+		}
+		doc, err := sfs.plugin.store.getDocument(sp.name)
+		if err != nil {
+			return nil, err
+		}
+		if doc == nil {
+			return nil, filesystem.NewNotFoundError("read", p)
+		}
+		data = doc.data
+	}
+	return plugin.ApplyRangeRead(data, offset, size)
+}
+
+// Write replaces a document, applies a JSON Patch to it or saves a query
+func (sfs *stateFS) Write(p string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	sp, err := parseStatePath(p)
+	if err != nil {
+		return 0, err
+	}
+	if offset > 0 || flags&filesystem.WriteFlagAppend != 0 {
+		return 0, filesystem.NewInvalidArgumentError("offset", offset, "documents and queries are written whole")
+	}
+
+	switch {
+	case sp.kind == pathQuery:
+		err = sfs.saveQuery(p, sp.name, data, flags)
+	case sp.kind != pathDocument:
+		return 0, filesystem.NewPermissionDeniedError("write", p, "only documents and saved queries can be written")
+	case sp.query != "":
+		return 0, filesystem.NewPermissionDeniedError("write", p, "query results are read-only; patch the document instead")
+	case sp.patch:
+		err = sfs.patch(p, sp.name, data)
+	default:
+		err = sfs.replace(p, sp.name, data, flags)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
+
+// replace stores a JSON value as a document, keeping its member order
+func (sfs *stateFS) replace(p, name string, data []byte, flags filesystem.WriteFlag) error {
+	if _, err := decodeJSON(data); err != nil {
+		return filesystem.NewInvalidArgumentError("document", p, "not valid JSON: "+err.Error())
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, bytes.TrimSpace(data), "", "  "); err != nil {
+		return filesystem.NewInvalidArgumentError("document", p, "not valid JSON: "+err.Error())
+	}
+	indented.WriteByte('\n')
+
+	return sfs.plugin.store.updateDocument(name, func(current *document) ([]byte, error) {
+		switch {
+		case current == nil && flags&filesystem.WriteFlagCreate == 0:
+			return nil, filesystem.NewNotFoundError("write", p)
+		case current != nil && flags&filesystem.WriteFlagExclusive != 0:
+			return nil, filesystem.NewAlreadyExistsError("document", p)
+		}
+		return indented.Bytes(), nil
+	})
+}
+
+// patch applies a JSON Patch to a document atomically
+func (sfs *stateFS) patch(p, name string, patch []byte) error {
+	return sfs.plugin.store.updateDocument(name, func(current *document) ([]byte, error) {
+		if current == nil {
+			return nil, filesystem.NewNotFoundError("patch", p)
+		}
+		doc, err := decodeJSON(current.data)
+		if err != nil {
+			return nil, fmt.Errorf("corrupt document %s: %w", name, err)
+		}
+		if doc, err = applyPatch("/"+name, doc, patch); err != nil {
+			return nil, err
+		}
+		return encodeJSON(doc)
+	})
+}
+
+// saveQuery saves "<document> <jsonpath>" as a query
+func (sfs *stateFS) saveQuery(p, name string, data []byte, flags filesystem.WriteFlag) error {
+	document, expression, _ := strings.Cut(strings.TrimSpace(string(data)), " ")
+	expression = strings.TrimSpace(expression)
+	if document == "" || strings.Contains(document, "/") || expression == "" {
+		return filesystem.NewInvalidArgumentError("query", p, "expected \"<document> <jsonpath>\"")
+	}
+	if _, err := parseJSONPath(expression); err != nil {
+		return filesystem.NewInvalidArgumentError("query", expression, err.Error())
+	}
+
+	if flags&filesystem.WriteFlagExclusive != 0 {
+		existing, err := sfs.plugin.store.getQuery(name)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return filesystem.NewAlreadyExistsError("query", p)
+		}
+	}
+	return sfs.plugin.store.putQuery(savedQuery{name: name, document: document, expression: expression})
+}
+
+func (sfs *stateFS) ReadDir(p string) ([]filesystem.FileInfo, error) {
+	sp, err := parseStatePath(p)
+	if err != nil {
+		return nil, err
+	}
+
+	switch sp.kind {
+	case pathRoot:
+		docs, err := sfs.plugin.store.listDocuments()
+		if err != nil {
+			return nil, err
+		}
+		files := []filesystem.FileInfo{readmeInfo(len(sfs.plugin.GetReadme())), dirInfo(queriesDir)}
+		for i := range docs {
+			files = append(files, documentInfo(&docs[i]))
+		}
+		return files, nil
+	case pathQueries:
+		queries, err := sfs.plugin.store.listQueries()
+		if err != nil {
+			return nil, err
+		}
+		files := make([]filesystem.FileInfo, 0, len(queries))
+		for i := range queries {
+			// Results are computed on read; listing does not run every query
+			files = append(files, queryInfo(&queries[i], 0))
+		}
+		return files, nil
+	default:
+		if _, err := sfs.Stat(p); err != nil {
+			return nil, err
+		}
+		return nil, filesystem.NewNotDirectoryError(p)
+	}
+}
+
+func (sfs *stateFS) Stat(p string) (*filesystem.FileInfo, error) {
+	sp, err := parseStatePath(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var info filesystem.FileInfo
+	switch sp.kind {
+	case pathRoot:
+		info = dirInfo("/")
+	case pathReadme:
+		info = readmeInfo(len(sfs.plugin.GetReadme()))
+	case pathQueries:
+		info = dirInfo(queriesDir)
+	case pathQuery:
+		q, data, err := sfs.runSavedQuery(p, sp.name)
+		if q == nil && err == nil {
+			return nil, filesystem.NewNotFoundError("stat", p)
+		}
+		if q == nil {
+			return nil, err
+		}
+		// A query of a missing document still exists; it reads as an error
+		info = queryInfo(q, len(data))
+	default:
+		doc, err := sfs.plugin.store.getDocument(sp.name)
+		if err != nil {
+			return nil, err
+		}
+		if doc == nil {
+			return nil, filesystem.NewNotFoundError("stat", p)
+		}
+		info = documentInfo(doc)
+		if sp.query != "" {
+			data, err := sfs.runQuery(p, sp.name, sp.query)
+			if err != nil {
+				return nil, err
+			}
+			info.Name = sp.name + queryPrefix + sp.query
+			info.Size = int64(len(data))
+			info.Mode = 0444
+		}
+	}
+	return &info, nil
+}
+
+// Rename renames a document
+func (sfs *stateFS) Rename(oldPath, newPath string) error {
+	from, err := parseStatePath(oldPath)
+	if err != nil {
+		return err
+	}
+	to, err := parseStatePath(newPath)
+	if err != nil {
+		return err
+	}
+	if from.kind != pathDocument || to.kind != pathDocument || from.patch || to.patch || from.query != "" || to.query != "" {
+		return filesystem.NewNotSupportedError("rename", oldPath)
+	}
+
+	found, err := sfs.plugin.store.renameDocument(from.name, to.name)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return filesystem.NewNotFoundError("rename", oldPath)
+	}
+	return nil
+}
+
+func (sfs *stateFS) Chmod(path string, mode uint32) error {
+	return nil
+}
+
+// Truncate is a no-op, so that shell redirections, which truncate before
+// writing, can replace documents: an empty document is not valid JSON
+func (sfs *stateFS) Truncate(path string, size int64) error {
+	return nil
+}
+
+func (sfs *stateFS) Open(path string) (io.ReadCloser, error) {
+	data, err := sfs.Read(path, 0, -1)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (sfs *stateFS) OpenWrite(path string) (io.WriteCloser, error) {
+	return filesystem.NewBufferedWriter(path, sfs.Write), nil
+}
+
+var _ plugin.ServicePlugin = (*StateFSPlugin)(nil)
+var _ filesystem.FileSystem = (*stateFS)(nil)
+var _ filesystem.Truncater = (*stateFS)(nil)
@@ -0,0 +1,237 @@
+package statefs
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+func newTestFS(t *testing.T) *stateFS {
+	t.Helper()
+	cfg := map[string]interface{}{"db_path": filepath.Join(t.TempDir(), "state.db")}
+	p := NewStateFSPlugin()
+	if err := p.Validate(cfg); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if err := p.Initialize(cfg); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	t.Cleanup(func() { p.Shutdown() })
+	return p.GetFileSystem().(*stateFS)
+}
+
+// readIgnoreEOF reads file content, ignoring io.EOF which is expected at end of file
+func readIgnoreEOF(fs *stateFS, path string) (string, error) {
+	content, err := fs.Read(path, 0, -1)
+	if err == io.EOF {
+		err = nil
+	}
+	return string(content), err
+}
+
+// readJSON reads a file and compacts its JSON content
+func readJSON(t *testing.T, fs *stateFS, path string) string {
+	t.Helper()
+	data, err := readIgnoreEOF(fs, path)
+	if err != nil {
+		t.Fatalf("read %s failed: %v", path, err)
+	}
+	v, err := decodeJSON([]byte(data))
+	if err != nil {
+		t.Fatalf("read %s: invalid JSON %q: %v", path, data, err)
+	}
+	out, _ := json.Marshal(v)
+	return string(out)
+}
+
+func write(fs *stateFS, path, data string) error {
+	_, err := fs.Write(path, []byte(data), -1, filesystem.WriteFlagCreate|filesystem.WriteFlagTruncate)
+	return err
+}
+
+func TestStateFSDocuments(t *testing.T) {
+	fs := newTestFS(t)
+
+	if err := write(fs, "/agent", `{"b": 1, "a": [true, null]}`); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	data, err := readIgnoreEOF(fs, "/agent")
+	if want := "{\n  \"b\": 1,\n  \"a\": [\n    true,\n    null\n  ]\n}\n"; err != nil || data != want {
+		t.Fatalf("read = %q, %v; want %q", data, err, want)
+	}
+	info, err := fs.Stat("/agent")
+	if err != nil || info.Size != int64(len(data)) || info.Meta.Content["version"] != "1" {
+		t.Fatalf("stat = %+v, %v", info, err)
+	}
+
+	if err := write(fs, "/agent", `{"b": `); !errors.Is(err, filesystem.ErrInvalidArgument) {
+		t.Errorf("invalid JSON: expected ErrInvalidArgument, got %v", err)
+	}
+	if _, err := fs.Write("/agent", []byte("{}"), 3, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrInvalidArgument) {
+		t.Errorf("write at offset: expected ErrInvalidArgument, got %v", err)
+	}
+	if _, err := fs.Write("/missing", []byte("{}"), -1, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("write without create: expected ErrNotFound, got %v", err)
+	}
+	if err := fs.Create("/agent"); !errors.Is(err, filesystem.ErrAlreadyExists) {
+		t.Errorf("create existing: expected ErrAlreadyExists, got %v", err)
+	}
+
+	if err := fs.Create("/empty"); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if got := readJSON(t, fs, "/empty"); got != "{}" {
+		t.Errorf("created document = %s", got)
+	}
+	if err := fs.Rename("/empty", "/renamed"); err != nil {
+		t.Fatalf("rename failed: %v", err)
+	}
+	infos, err := fs.ReadDir("/")
+	if err != nil || len(infos) != 4 || infos[2].Name != "agent" || infos[3].Name != "renamed" {
+		t.Fatalf("ReadDir = %+v, %v", infos, err)
+	}
+
+	if err := fs.Remove("/renamed"); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+	if _, err := fs.Stat("/renamed"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("removed document: expected ErrNotFound, got %v", err)
+	}
+	if err := fs.Create("/a/b"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("nested document: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestStateFSPatch(t *testing.T) {
+	fs := newTestFS(t)
+	if err := write(fs, "/agent", `{"owner": "a", "tasks": [{"id": 1}], "notes": {"x": 1}}`); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	patch := `[
+		{"op": "add", "path": "/tasks/-", "value": {"id": 2}},
+		{"op": "add", "path": "/tasks/0", "value": {"id": 0}},
+		{"op": "replace", "path": "/owner", "value": "b"},
+		{"op": "copy", "from": "/notes", "path": "/backup"},
+		{"op": "move", "from": "/notes/x", "path": "/notes/y"},
+		{"op": "remove", "path": "/tasks/1"},
+		{"op": "test", "path": "/backup/x", "value": 1.0}
+	]`
+	if err := write(fs, "/agent?patch", patch); err != nil {
+		t.Fatalf("patch failed: %v", err)
+	}
+	want := `{"backup":{"x":1},"notes":{"y":1},"owner":"b","tasks":[{"id":0},{"id":2}]}`
+	if got := readJSON(t, fs, "/agent"); got != want {
+		t.Fatalf("patched document = %s, want %s", got, want)
+	}
+	if info, _ := fs.Stat("/agent"); info.Meta.Content["version"] != "2" {
+		t.Errorf("version = %s, want 2", info.Meta.Content["version"])
+	}
+
+	// A patch applies whole or not at all
+	for _, tc := range []struct {
+		patch string
+		err   error
+	}{
+		{`[{"op": "replace", "path": "/owner", "value": "c"}, {"op": "test", "path": "/owner", "value": "b"}]`, filesystem.ErrConflict},
+		{`[{"op": "replace", "path": "/owner", "value": "c"}, {"op": "remove", "path": "/missing"}]`, filesystem.ErrInvalidArgument},
+		{`[{"op": "add", "path": "/tasks/5", "value": 1}]`, filesystem.ErrInvalidArgument},
+		{`[{"op": "move", "from": "/notes", "path": "/notes/z"}]`, filesystem.ErrInvalidArgument},
+		{`[{"op": "frobnicate", "path": "/owner"}]`, filesystem.ErrInvalidArgument},
+		{`{"op": "add"}`, filesystem.ErrInvalidArgument},
+	} {
+		if err := write(fs, "/agent?patch", tc.patch); !errors.Is(err, tc.err) {
+			t.Errorf("patch %s: expected %v, got %v", tc.patch, tc.err, err)
+		}
+	}
+	if got := readJSON(t, fs, "/agent"); got != want {
+		t.Errorf("document changed by failed patches: %s", got)
+	}
+
+	if err := write(fs, "/missing?patch", `[]`); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("patch missing document: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestStateFSQuery(t *testing.T) {
+	fs := newTestFS(t)
+	doc := `{
+		"owner": "a",
+		"tasks": [
+			{"title": "triage", "done": true, "priority": 2},
+			{"title": "fix", "done": false, "priority": 1},
+			{"title": "ship", "done": false, "priority": 3, "tags": ["release"]}
+		],
+		"a/b": {"c": "slash"}
+	}`
+	if err := write(fs, "/agent", doc); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	for _, tc := range []struct {
+		path string
+		want string
+	}{
+		{"$", "[" + readJSON(t, fs, "/agent") + "]"},
+		{"$.owner", `["a"]`},
+		{"owner", `["a"]`},
+		{"$.tasks[0].title", `["triage"]`},
+		{"$.tasks[-1].title", `["ship"]`},
+		{"$.tasks[*].title", `["triage","fix","ship"]`},
+		{"$.tasks[0,2].priority", `[2,3]`},
+		{"$.tasks[1:].title", `["fix","ship"]`},
+		{"$.tasks[::2].title", `["triage","ship"]`},
+		{"$..tags[0]", `["release"]`},
+		{"$['a/b'].c", `["slash"]`},
+		{"$.tasks[?(@.done == false)].title", `["fix","ship"]`},
+		{"$.tasks[?(@.priority >= 2 && @.done == false)].title", `["ship"]`},
+		{"$.tasks[?(@.priority < 2 || @.title == 'triage')].title", `["triage","fix"]`},
+		{"$.tasks[?(@.tags)].title", `["ship"]`},
+		{"$.tasks[?(@.title != $.owner)].priority", `[2,1,3]`},
+		{"$.missing", `[]`},
+	} {
+		if got := readJSON(t, fs, "/agent?path="+tc.path); got != tc.want {
+			t.Errorf("query %s = %s, want %s", tc.path, got, tc.want)
+		}
+	}
+
+	for _, path := range []string{"/agent?path=$.tasks[", "/agent?path=$.", "/agent?other", "/queries?path=$"} {
+		if _, err := fs.Read(path, 0, -1); !errors.Is(err, filesystem.ErrInvalidArgument) {
+			t.Errorf("read %s: expected ErrInvalidArgument, got %v", path, err)
+		}
+	}
+	if err := write(fs, "/agent?path=$.owner", `"b"`); !errors.Is(err, filesystem.ErrPermissionDenied) {
+		t.Errorf("write query: expected ErrPermissionDenied, got %v", err)
+	}
+
+	// Saved queries read the current results
+	if err := write(fs, "/queries/open", "agent $.tasks[?(@.done == false)].title\n"); err != nil {
+		t.Fatalf("save query failed: %v", err)
+	}
+	if got := readJSON(t, fs, "/queries/open"); got != `["fix","ship"]` {
+		t.Errorf("saved query = %s", got)
+	}
+	if err := write(fs, "/agent?patch", `[{"op": "replace", "path": "/tasks/1/done", "value": true}]`); err != nil {
+		t.Fatalf("patch failed: %v", err)
+	}
+	if got := readJSON(t, fs, "/queries/open"); got != `["ship"]` {
+		t.Errorf("saved query after patch = %s", got)
+	}
+	info, err := fs.Stat("/queries/open")
+	if err != nil || info.Meta.Content["document"] != "agent" {
+		t.Errorf("stat query = %+v, %v", info, err)
+	}
+	if err := write(fs, "/queries/bad", "agent"); !errors.Is(err, filesystem.ErrInvalidArgument) {
+		t.Errorf("query without expression: expected ErrInvalidArgument, got %v", err)
+	}
+	if err := fs.Remove("/queries/open"); err != nil {
+		t.Fatalf("remove query failed: %v", err)
+	}
+	if infos, err := fs.ReadDir("/queries"); err != nil || len(infos) != 0 {
+		t.Errorf("ReadDir = %+v, %v", infos, err)
+	}
+}
@@ -0,0 +1,241 @@
+package statefs
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3" // SQLite driver
+)
+
+// document is a stored JSON document
+type document struct {
+	name      string
+	data      []byte // Indented JSON, as read
+	size      int64
+	version   int64 // Incremented by every write
+	updatedAt time.Time
+}
+
+// savedQuery is a JSONPath query of a document saved as a file
+type savedQuery struct {
+	name       string
+	document   string
+	expression string
+	updatedAt  time.Time
+}
+
+// store keeps documents and saved queries in SQLite
+type store struct {
+	db *sql.DB
+}
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS statefs_documents (
+		name TEXT PRIMARY KEY,
+		data TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		updated_at INTEGER NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS statefs_queries (
+		name TEXT PRIMARY KEY,
+		document TEXT NOT NULL,
+		expression TEXT NOT NULL,
+		updated_at INTEGER NOT NULL
+	)`,
+}
+
+func openStore(dbPath string) (*store, error) {
+	// Wait for locks held by other processes instead of failing at once, and
+	// take the write lock when a transaction starts so that read-modify-write
+	// transactions (patches) cannot interleave
+	dsn := dbPath
+	if !strings.Contains(dsn, "?") {
+		dsn += "?_busy_timeout=5000&_txlock=immediate"
+	}
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	statements := append([]string{"PRAGMA journal_mode=WAL"}, schema...)
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to initialize schema: %w", err)
+		}
+	}
+	return &store{db: db}, nil
+}
+
+func (s *store) close() error {
+	return s.db.Close()
+}
+
+// getDocument returns a document, nil if it does not exist
+func (s *store) getDocument(name string) (*document, error) {
+	doc := &document{name: name}
+	var updatedAt int64
+	err := s.db.QueryRow(`SELECT data, version, updated_at FROM statefs_documents WHERE name = ?`, name).
+		Scan(&doc.data, &doc.version, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document %s: %w", name, err)
+	}
+	doc.size = int64(len(doc.data))
+	doc.updatedAt = time.UnixMilli(updatedAt)
+	return doc, nil
+}
+
+// listDocuments returns the documents, without their data, by name
+func (s *store) listDocuments() ([]document, error) {
+	rows, err := s.db.Query(`SELECT name, LENGTH(CAST(data AS BLOB)), version, updated_at FROM statefs_documents ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+	defer rows.Close()
+
+	var docs []document
+	for rows.Next() {
+		var doc document
+		var updatedAt int64
+		if err := rows.Scan(&doc.name, &doc.size, &doc.version, &updatedAt); err != nil {
+			return nil, err
+		}
+		doc.updatedAt = time.UnixMilli(updatedAt)
+		docs = append(docs, doc)
+	}
+	return docs, rows.Err()
+}
+
+// updateDocument replaces a document with the data fn returns for its
+// current version (nil if it does not exist), in one transaction
+func (s *store) updateDocument(name string, fn func(current *document) ([]byte, error)) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var current *document
+	var data []byte
+	var version, updatedAt int64
+	err = tx.QueryRow(`SELECT data, version, updated_at FROM statefs_documents WHERE name = ?`, name).
+		Scan(&data, &version, &updatedAt)
+	switch {
+	case err == nil:
+		current = &document{name: name, data: data, size: int64(len(data)), version: version, updatedAt: time.UnixMilli(updatedAt)}
+	case err != sql.ErrNoRows:
+		return fmt.Errorf("failed to read document %s: %w", name, err)
+	}
+
+	updated, err := fn(current)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`INSERT INTO statefs_documents (name, data, version, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET data = excluded.data, version = excluded.version, updated_at = excluded.updated_at`,
+		name, string(updated), version+1, time.Now().UnixMilli()); err != nil {
+		return fmt.Errorf("failed to write document %s: %w", name, err)
+	}
+	return tx.Commit()
+}
+
+// deleteDocument deletes a document; found is false if it did not exist
+func (s *store) deleteDocument(name string) (bool, error) {
+	res, err := s.db.Exec(`DELETE FROM statefs_documents WHERE name = ?`, name)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete document %s: %w", name, err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// renameDocument renames a document, replacing the one named newName
+func (s *store) renameDocument(oldName, newName string) (bool, error) {
+	if oldName == newName {
+		doc, err := s.getDocument(oldName)
+		return doc != nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM statefs_documents WHERE name = ?`, newName); err != nil {
+		return false, err
+	}
+	res, err := tx.Exec(`UPDATE statefs_documents SET name = ?, updated_at = ? WHERE name = ?`,
+		newName, time.Now().UnixMilli(), oldName)
+	if err != nil {
+		return false, fmt.Errorf("failed to rename document %s: %w", oldName, err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// getQuery returns a saved query, nil if it does not exist
+func (s *store) getQuery(name string) (*savedQuery, error) {
+	q := &savedQuery{name: name}
+	var updatedAt int64
+	err := s.db.QueryRow(`SELECT document, expression, updated_at FROM statefs_queries WHERE name = ?`, name).
+		Scan(&q.document, &q.expression, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read query %s: %w", name, err)
+	}
+	q.updatedAt = time.UnixMilli(updatedAt)
+	return q, nil
+}
+
+// listQueries returns the saved queries by name
+func (s *store) listQueries() ([]savedQuery, error) {
+	rows, err := s.db.Query(`SELECT name, document, expression, updated_at FROM statefs_queries ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queries: %w", err)
+	}
+	defer rows.Close()
+
+	var queries []savedQuery
+	for rows.Next() {
+		var q savedQuery
+		var updatedAt int64
+		if err := rows.Scan(&q.name, &q.document, &q.expression, &updatedAt); err != nil {
+			return nil, err
+		}
+		q.updatedAt = time.UnixMilli(updatedAt)
+		queries = append(queries, q)
+	}
+	return queries, rows.Err()
+}
+
+func (s *store) putQuery(q savedQuery) error {
+	_, err := s.db.Exec(`INSERT INTO statefs_queries (name, document, expression, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET document = excluded.document, expression = excluded.expression, updated_at = excluded.updated_at`,
+		q.name, q.document, q.expression, time.Now().UnixMilli())
+	if err != nil {
+		return fmt.Errorf("failed to save query %s: %w", q.name, err)
+	}
+	return nil
+}
+
+// deleteQuery deletes a saved query; found is false if it did not exist
+func (s *store) deleteQuery(name string) (bool, error) {
+	res, err := s.db.Exec(`DELETE FROM statefs_queries WHERE name = ?`, name)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete query %s: %w", name, err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
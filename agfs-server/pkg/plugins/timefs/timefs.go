@@ -0,0 +1,410 @@
+package timefs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PluginName = "timefs" // Name of this plugin
+)
+
+// Meta values for TimeFS plugin
+const (
+	MetaValueClock = "clock" // Current time files
+	MetaValueTimer = "timer" // Blocking timer files
+)
+
+// timeFormats maps the files under /formats to how they render a time
+var timeFormats = map[string]func(t time.Time) string{
+	"rfc3339":     func(t time.Time) string { return t.Format(time.RFC3339) },
+	"rfc3339nano": func(t time.Time) string { return t.Format(time.RFC3339Nano) },
+	"rfc1123":     func(t time.Time) string { return t.Format(time.RFC1123Z) },
+	"http":        func(t time.Time) string { return t.UTC().Format(http.TimeFormat) },
+	"date":        func(t time.Time) string { return t.Format("2006-01-02") },
+	"time":        func(t time.Time) string { return t.Format("15:04:05") },
+	"kitchen":     func(t time.Time) string { return t.Format(time.Kitchen) },
+	"unix":        func(t time.Time) string { return strconv.FormatInt(t.Unix(), 10) },
+	"unix_ms":     func(t time.Time) string { return strconv.FormatInt(t.UnixMilli(), 10) },
+	"unix_ns":     func(t time.Time) string { return strconv.FormatInt(t.UnixNano(), 10) },
+}
+
+// TimeFSPlugin exposes clocks and timers as files
+//
+//	/now                - current time in the configured format
+//	/epoch              - current Unix time in seconds
+//	/formats/<name>     - current time in a specific format
+//	/timers/<duration>  - reading blocks for the duration, then returns the time
+type TimeFSPlugin struct {
+	location  *time.Location
+	nowFormat string
+	maxTimer  time.Duration
+	metadata  plugin.PluginMetadata
+}
+
+// NewTimeFSPlugin creates a new time plugin
+func NewTimeFSPlugin() *TimeFSPlugin {
+	return &TimeFSPlugin{
+		location:  time.Local,
+		nowFormat: "rfc3339",
+		maxTimer:  time.Hour,
+		metadata: plugin.PluginMetadata{
+			Name:        PluginName,
+			Version:     "1.0.0",
+			Description: "Clocks, timers and sleeps as files",
+			Author:      "AGFS Server",
+		},
+	}
+}
+
+func (t *TimeFSPlugin) Name() string {
+	return t.metadata.Name
+}
+
+func (t *TimeFSPlugin) Validate(cfg map[string]interface{}) error {
+	allowedKeys := []string{"mount_path", "timezone", "now_format", "max_timer"}
+	if err := config.ValidateOnlyKnownKeys(cfg, allowedKeys); err != nil {
+		return err
+	}
+
+	for _, key := range []string{"timezone", "now_format", "max_timer"} {
+		if err := config.ValidateStringType(cfg, key); err != nil {
+			return err
+		}
+	}
+
+	if _, err := time.LoadLocation(config.GetStringConfig(cfg, "timezone", "Local")); err != nil {
+		return fmt.Errorf("invalid timezone: %w", err)
+	}
+	if format := config.GetStringConfig(cfg, "now_format", "rfc3339"); timeFormats[format] == nil {
+		return fmt.Errorf("invalid now_format %q: must be one of %s", format, strings.Join(formatNames(), ", "))
+	}
+	if max, err := time.ParseDuration(config.GetStringConfig(cfg, "max_timer", "1h")); err != nil || max <= 0 {
+		return fmt.Errorf("invalid max_timer: must be a positive duration such as \"1h\"")
+	}
+	return nil
+}
+
+func (t *TimeFSPlugin) Initialize(cfg map[string]interface{}) error {
+	location, err := time.LoadLocation(config.GetStringConfig(cfg, "timezone", "Local"))
+	if err != nil {
+		return fmt.Errorf("invalid timezone: %w", err)
+	}
+	maxTimer, err := time.ParseDuration(config.GetStringConfig(cfg, "max_timer", "1h"))
+	if err != nil {
+		return fmt.Errorf("invalid max_timer: %w", err)
+	}
+
+	t.location = location
+	t.nowFormat = config.GetStringConfig(cfg, "now_format", "rfc3339")
+	t.maxTimer = maxTimer
+
+	log.Infof("[timefs] Initialized (timezone=%s, max_timer=%s)", location, maxTimer)
+	return nil
+}
+
+func (t *TimeFSPlugin) GetFileSystem() filesystem.FileSystem {
+	return &timeFS{plugin: t}
+}
+
+func (t *TimeFSPlugin) GetReadme() string {
+	return `TimeFS Plugin - Clocks and Timers as Files
+
+This plugin exposes the server clock and blocking timers as files, so
+scripts and agents can read the time or wait without busy polling.
+
+STRUCTURE:
+  /timefs/
+    README              - This documentation
+    now                 - Current time in now_format (default RFC 3339)
+    epoch               - Current Unix time in seconds
+    formats/
+      rfc3339           - 2024-05-01T10:00:00+02:00
+      rfc3339nano       - 2024-05-01T10:00:00.123456789+02:00
+      rfc1123           - Wed, 01 May 2024 10:00:00 +0200
+      http              - Wed, 01 May 2024 08:00:00 GMT
+      date              - 2024-05-01
+      time              - 10:00:00
+      kitchen           - 10:00AM
+      unix              - Unix seconds
+      unix_ms           - Unix milliseconds
+      unix_ns           - Unix nanoseconds
+    timers/
+      <duration>        - Reading blocks for the duration, e.g. timers/30s,
+                          then returns the wake-up time
+
+EXAMPLES:
+  agfs:/> cat /timefs/now
+  2024-05-01T10:00:00+02:00
+
+  # Wait 5 seconds between polls
+  agfs:/> cat /timefs/timers/5s
+
+  # Timestamp a file name
+  agfs:/> cp report.txt /memfs/report-$(cat /timefs/formats/date).txt
+
+CONFIGURATION:
+  [plugins.timefs]
+  enabled = true
+  path = "/timefs"
+
+    [plugins.timefs.config]
+    timezone = "Local"       # IANA name such as "UTC" or "Europe/Berlin"
+    now_format = "rfc3339"   # any name under formats/
+    max_timer = "1h"         # longest allowed timer
+
+NOTES:
+  - Durations use Go syntax: 500ms, 30s, 5m, 1h30m.
+  - Only the read at offset 0 blocks, so clients reading in chunks wait once.
+  - Clients with request timeouts shorter than a timer will give up first;
+    keep timers below the client timeout or chain several shorter ones.
+`
+}
+
+func (t *TimeFSPlugin) GetConfigParams() []plugin.ConfigParameter {
+	return []plugin.ConfigParameter{
+		{
+			Name:        "timezone",
+			Type:        "string",
+			Required:    false,
+			Default:     "Local",
+			Description: "IANA time zone used for formatted times",
+		},
+		{
+			Name:        "now_format",
+			Type:        "string",
+			Required:    false,
+			Default:     "rfc3339",
+			Description: "Format of the now file (one of the names under formats/)",
+		},
+		{
+			Name:        "max_timer",
+			Type:        "string",
+			Required:    false,
+			Default:     "1h",
+			Description: "Longest duration a timer may block",
+		},
+	}
+}
+
+func (t *TimeFSPlugin) Shutdown() error {
+	return nil
+}
+
+func formatNames() []string {
+	names := make([]string, 0, len(timeFormats))
+	for name := range timeFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// timeFS implements the FileSystem interface for clocks and timers
+type timeFS struct {
+	plugin *TimeFSPlugin
+}
+
+func (tfs *timeFS) now() time.Time {
+	return time.Now().In(tfs.plugin.location)
+}
+
+// parseTimer parses the duration of a timers/<duration> file
+func (tfs *timeFS) parseTimer(name string) (time.Duration, error) {
+	d, err := time.ParseDuration(name)
+	if err != nil || d < 0 {
+		return 0, filesystem.NewInvalidArgumentError("duration", name, "expected a duration such as 500ms, 30s or 5m")
+	}
+	if d > tfs.plugin.maxTimer {
+		return 0, filesystem.NewInvalidArgumentError("duration", name, fmt.Sprintf("exceeds max_timer (%s)", tfs.plugin.maxTimer))
+	}
+	return d, nil
+}
+
+// content renders a clock file, or returns false if path is not one
+func (tfs *timeFS) content(path string) ([]byte, bool) {
+	now := tfs.now()
+	switch {
+	case path == "/now":
+		return []byte(timeFormats[tfs.plugin.nowFormat](now) + "\n"), true
+	case path == "/epoch":
+		return []byte(strconv.FormatInt(now.Unix(), 10) + "\n"), true
+	case strings.HasPrefix(path, "/formats/"):
+		if format, ok := timeFormats[strings.TrimPrefix(path, "/formats/")]; ok {
+			return []byte(format(now) + "\n"), true
+		}
+	}
+	return nil, false
+}
+
+func (tfs *timeFS) readOnly(op, path string) error {
+	return filesystem.NewPermissionDeniedError(op, path, "timefs is read-only")
+}
+
+func (tfs *timeFS) Create(path string) error {
+	return tfs.readOnly("create", path)
+}
+
+func (tfs *timeFS) Mkdir(path string, perm uint32) error {
+	return tfs.readOnly("mkdir", path)
+}
+
+func (tfs *timeFS) Remove(path string) error {
+	return tfs.readOnly("remove", path)
+}
+
+func (tfs *timeFS) RemoveAll(path string) error {
+	return tfs.readOnly("remove", path)
+}
+
+func (tfs *timeFS) Read(path string, offset int64, size int64) ([]byte, error) {
+	path = "/" + strings.Trim(path, "/")
+	if path == "/README" {
+		return plugin.ApplyRangeRead([]byte(tfs.plugin.GetReadme()), offset, size)
+	}
+	if data, ok := tfs.content(path); ok {
+		return plugin.ApplyRangeRead(data, offset, size)
+	}
+
+	if strings.HasPrefix(path, "/timers/") {
+		d, err := tfs.parseTimer(strings.TrimPrefix(path, "/timers/"))
+		if err != nil {
+			return nil, err
+		}
+		// Follow-up reads of the same file must not wait again
+		if offset > 0 {
+			return []byte{}, io.EOF
+		}
+		time.Sleep(d)
+		return plugin.ApplyRangeRead([]byte(timeFormats["rfc3339nano"](tfs.now())+"\n"), 0, size)
+	}
+
+	switch path {
+	case "/", "/formats", "/timers":
+		return nil, fmt.Errorf("is a directory: %s", path)
+	}
+	return nil, filesystem.NewNotFoundError("read", path)
+}
+
+func (tfs *timeFS) Write(path string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	return 0, tfs.readOnly("write", path)
+}
+
+func dirInfo(name string, modTime time.Time) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    name,
+		Size:    0,
+		Mode:    0555,
+		ModTime: modTime,
+		IsDir:   true,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "dir"},
+	}
+}
+
+func fileInfo(name, metaType string, size int64, modTime time.Time) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    name,
+		Size:    size,
+		Mode:    0444,
+		ModTime: modTime,
+		IsDir:   false,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: metaType},
+	}
+}
+
+func (tfs *timeFS) ReadDir(path string) ([]filesystem.FileInfo, error) {
+	now := tfs.now()
+
+	switch "/" + strings.Trim(path, "/") {
+	case "/":
+		nowData, _ := tfs.content("/now")
+		epochData, _ := tfs.content("/epoch")
+		return []filesystem.FileInfo{
+			fileInfo("README", "doc", int64(len(tfs.plugin.GetReadme())), now),
+			fileInfo("now", MetaValueClock, int64(len(nowData)), now),
+			fileInfo("epoch", MetaValueClock, int64(len(epochData)), now),
+			dirInfo("formats", now),
+			dirInfo("timers", now),
+		}, nil
+	case "/formats":
+		names := formatNames()
+		files := make([]filesystem.FileInfo, 0, len(names))
+		for _, name := range names {
+			data, _ := tfs.content("/formats/" + name)
+			files = append(files, fileInfo(name, MetaValueClock, int64(len(data)), now))
+		}
+		return files, nil
+	case "/timers":
+		// Any duration works; timers are not listed
+		return []filesystem.FileInfo{}, nil
+	}
+
+	if _, err := tfs.Stat(path); err != nil {
+		return nil, err
+	}
+	return nil, filesystem.NewNotDirectoryError(path)
+}
+
+func (tfs *timeFS) Stat(path string) (*filesystem.FileInfo, error) {
+	now := tfs.now()
+	path = "/" + strings.Trim(path, "/")
+
+	var info filesystem.FileInfo
+	switch {
+	case path == "/":
+		info = dirInfo("/", now)
+	case path == "/formats" || path == "/timers":
+		info = dirInfo(strings.TrimPrefix(path, "/"), now)
+	case path == "/README":
+		info = fileInfo("README", "doc", int64(len(tfs.plugin.GetReadme())), now)
+	case strings.HasPrefix(path, "/timers/"):
+		name := strings.TrimPrefix(path, "/timers/")
+		if _, err := tfs.parseTimer(name); err != nil {
+			return nil, err
+		}
+		// The size is unknown until the timer fires; 0 keeps stat from blocking
+		info = fileInfo(name, MetaValueTimer, 0, now)
+	default:
+		data, ok := tfs.content(path)
+		if !ok {
+			return nil, filesystem.NewNotFoundError("stat", path)
+		}
+		info = fileInfo(path[strings.LastIndex(path, "/")+1:], MetaValueClock, int64(len(data)), now)
+	}
+	return &info, nil
+}
+
+func (tfs *timeFS) Rename(oldPath, newPath string) error {
+	return tfs.readOnly("rename", oldPath)
+}
+
+func (tfs *timeFS) Chmod(path string, mode uint32) error {
+	return tfs.readOnly("chmod", path)
+}
+
+func (tfs *timeFS) Open(path string) (io.ReadCloser, error) {
+	data, err := tfs.Read(path, 0, -1)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (tfs *timeFS) OpenWrite(path string) (io.WriteCloser, error) {
+	return nil, tfs.readOnly("write", path)
+}
+
+// Ensure TimeFSPlugin implements ServicePlugin
+var _ plugin.ServicePlugin = (*TimeFSPlugin)(nil)
+var _ filesystem.FileSystem = (*timeFS)(nil)
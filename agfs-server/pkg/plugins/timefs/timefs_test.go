@@ -0,0 +1,120 @@
+package timefs
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+func newTestFS(t *testing.T, cfg map[string]interface{}) *timeFS {
+	t.Helper()
+	p := NewTimeFSPlugin()
+	if err := p.Validate(cfg); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if err := p.Initialize(cfg); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	t.Cleanup(func() { p.Shutdown() })
+	return p.GetFileSystem().(*timeFS)
+}
+
+// readIgnoreEOF reads file content, ignoring io.EOF which is expected at end of file
+func readIgnoreEOF(fs *timeFS, path string) ([]byte, error) {
+	content, err := fs.Read(path, 0, -1)
+	if err == io.EOF {
+		return content, nil
+	}
+	return content, err
+}
+
+func TestTimeFSClocks(t *testing.T) {
+	fs := newTestFS(t, map[string]interface{}{"timezone": "UTC"})
+
+	data, err := readIgnoreEOF(fs, "/now")
+	if err != nil {
+		t.Fatalf("read /now: %v", err)
+	}
+	now, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+	if err != nil || time.Since(now) > time.Minute || !strings.HasSuffix(strings.TrimSpace(string(data)), "Z") {
+		t.Errorf("/now = %q, %v", data, err)
+	}
+
+	data, _ = readIgnoreEOF(fs, "/epoch")
+	epoch, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil || time.Now().Unix()-epoch > 60 {
+		t.Errorf("/epoch = %q, %v", data, err)
+	}
+
+	data, _ = readIgnoreEOF(fs, "/formats/date")
+	if _, err := time.Parse("2006-01-02", strings.TrimSpace(string(data))); err != nil {
+		t.Errorf("/formats/date = %q, %v", data, err)
+	}
+
+	infos, err := fs.ReadDir("/formats")
+	if err != nil || len(infos) != len(timeFormats) || infos[0].Name != "date" {
+		t.Errorf("ReadDir /formats = %+v, %v", infos, err)
+	}
+	if _, err := fs.Stat("/formats/martian"); !errors.Is(err, filesystem.ErrNotFound) {
+		t.Errorf("unknown format: expected ErrNotFound, got %v", err)
+	}
+	if _, err := fs.Write("/now", []byte("x"), 0, filesystem.WriteFlagNone); !errors.Is(err, filesystem.ErrPermissionDenied) {
+		t.Errorf("write: expected ErrPermissionDenied, got %v", err)
+	}
+}
+
+func TestTimeFSNowFormat(t *testing.T) {
+	fs := newTestFS(t, map[string]interface{}{"now_format": "unix_ms"})
+	data, _ := readIgnoreEOF(fs, "/now")
+	if ms, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err != nil || time.Now().UnixMilli()-ms > 60000 {
+		t.Errorf("/now as unix_ms = %q, %v", data, err)
+	}
+}
+
+func TestTimeFSTimers(t *testing.T) {
+	fs := newTestFS(t, map[string]interface{}{"max_timer": "1s"})
+
+	start := time.Now()
+	data, err := readIgnoreEOF(fs, "/timers/150ms")
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("timer returned after %s", elapsed)
+	}
+	if _, perr := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(data))); err != nil || perr != nil {
+		t.Errorf("timer content = %q, %v", data, err)
+	}
+
+	// Reads past the start return immediately
+	start = time.Now()
+	if _, err := fs.Read("/timers/500ms", 10, 10); err != io.EOF || time.Since(start) > 100*time.Millisecond {
+		t.Errorf("offset read: err=%v after %s", err, time.Since(start))
+	}
+
+	info, err := fs.Stat("/timers/30s")
+	if !errors.Is(err, filesystem.ErrInvalidArgument) {
+		t.Errorf("timer above max_timer: expected ErrInvalidArgument, got %+v, %v", info, err)
+	}
+	if _, err := fs.Stat("/timers/soon"); !errors.Is(err, filesystem.ErrInvalidArgument) {
+		t.Errorf("invalid duration: expected ErrInvalidArgument, got %v", err)
+	}
+	if info, err := fs.Stat("/timers/1s"); err != nil || info.IsDir || info.Meta.Type != MetaValueTimer {
+		t.Errorf("Stat timer = %+v, %v", info, err)
+	}
+}
+
+func TestTimeFSValidate(t *testing.T) {
+	p := NewTimeFSPlugin()
+	if err := p.Validate(map[string]interface{}{"timezone": "Mars/Olympus"}); err == nil {
+		t.Error("expected error for unknown timezone")
+	}
+	if err := p.Validate(map[string]interface{}{"now_format": "roman"}); err == nil {
+		t.Error("expected error for unknown now_format")
+	}
+	if err := p.Validate(map[string]interface{}{"max_timer": "-1s"}); err == nil {
+		t.Error("expected error for negative max_timer")
+	}
+}
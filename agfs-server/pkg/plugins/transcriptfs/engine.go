@@ -0,0 +1,336 @@
+package transcriptfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+)
+
+// Transcript is the transcription of an audio or video file
+type Transcript struct {
+	Source   string    `json:"source"` // Input file, e.g. in/meeting.mp3
+	Language string    `json:"language,omitempty"`
+	Duration float64   `json:"duration,omitempty"` // Seconds
+	Text     string    `json:"text"`
+	Segments []Segment `json:"segments"` // Empty if the engine gives no timestamps
+}
+
+// Segment is a timed part of a transcript
+type Segment struct {
+	Start   float64 `json:"start"` // Seconds from the start of the file
+	End     float64 `json:"end"`
+	Speaker string  `json:"speaker,omitempty"` // Set by engines that separate speakers
+	Text    string  `json:"text"`
+}
+
+// transcriber turns audio and video files into transcripts
+type transcriber interface {
+	// transcribe transcribes the file at input, reporting its progress
+	transcribe(ctx context.Context, input string, progress plugin.TaskProgress) (*Transcript, error)
+}
+
+// newTranscriber creates the transcription engine configured by provider
+func newTranscriber(cfg map[string]interface{}) (transcriber, error) {
+	switch provider := config.GetStringConfig(cfg, "provider", "openai"); provider {
+	case "openai":
+		apiKey := config.GetStringConfig(cfg, "api_key", "")
+		if apiKey == "" {
+			apiKey = os.Getenv("OPENAI_API_KEY")
+		}
+		if apiKey == "" {
+			return nil, fmt.Errorf("api_key is required when using the openai provider")
+		}
+		diarize := config.GetBoolConfig(cfg, "diarize", false)
+		defaultModel := "whisper-1"
+		if diarize {
+			defaultModel = "gpt-4o-transcribe-diarize"
+		}
+		return &whisperAPI{
+			apiKey:   apiKey,
+			apiBase:  strings.TrimSuffix(config.GetStringConfig(cfg, "api_base", "https://api.openai.com/v1"), "/"),
+			model:    config.GetStringConfig(cfg, "model", defaultModel),
+			language: config.GetStringConfig(cfg, "language", ""),
+			diarize:  diarize,
+			client:   &http.Client{},
+		}, nil
+	case "command":
+		command := config.GetStringConfig(cfg, "command", "")
+		if command == "" {
+			return nil, fmt.Errorf("command is required when using the command provider")
+		}
+		return &whisperCommand{
+			shell:   config.GetStringConfig(cfg, "shell", "/bin/sh"),
+			command: command,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported provider: %s (valid options: openai, command)", provider)
+	}
+}
+
+// whisperOutput is the JSON transcript of the OpenAI transcription API
+// (verbose_json and diarized_json) and of the whisper and whisperx commands
+type whisperOutput struct {
+	Language string    `json:"language"`
+	Duration float64   `json:"duration"`
+	Text     string    `json:"text"`
+	Segments []Segment `json:"segments"`
+}
+
+func (o whisperOutput) transcript() *Transcript {
+	t := &Transcript{Language: o.Language, Duration: o.Duration, Text: strings.TrimSpace(o.Text), Segments: o.Segments}
+	for i := range t.Segments {
+		t.Segments[i].Text = strings.TrimSpace(t.Segments[i].Text)
+	}
+	if t.Text == "" {
+		t.Text = segmentsText(t.Segments)
+	}
+	if t.Duration == 0 && len(t.Segments) > 0 {
+		t.Duration = t.Segments[len(t.Segments)-1].End
+	}
+	return t
+}
+
+// whisperCppOutput is the JSON transcript of whisper.cpp (-oj)
+type whisperCppOutput struct {
+	Result struct {
+		Language string `json:"language"`
+	} `json:"result"`
+	Transcription []struct {
+		Offsets struct {
+			From int64 `json:"from"` // Milliseconds
+			To   int64 `json:"to"`
+		} `json:"offsets"`
+		Text string `json:"text"`
+	} `json:"transcription"`
+}
+
+func (o whisperCppOutput) transcript() *Transcript {
+	t := &Transcript{Language: o.Result.Language}
+	for _, s := range o.Transcription {
+		t.Segments = append(t.Segments, Segment{
+			Start: float64(s.Offsets.From) / 1000,
+			End:   float64(s.Offsets.To) / 1000,
+			Text:  strings.TrimSpace(s.Text),
+		})
+	}
+	t.Text = segmentsText(t.Segments)
+	if len(t.Segments) > 0 {
+		t.Duration = t.Segments[len(t.Segments)-1].End
+	}
+	return t
+}
+
+// segmentsText joins the text of segments
+func segmentsText(segments []Segment) string {
+	texts := make([]string, 0, len(segments))
+	for _, s := range segments {
+		if s.Text != "" {
+			texts = append(texts, s.Text)
+		}
+	}
+	return strings.Join(texts, " ")
+}
+
+// parseTranscript parses the output of a transcription engine: one of the
+// JSON formats of Whisper implementations, or plain text without timestamps
+func parseTranscript(data []byte) *Transcript {
+	var probe map[string]json.RawMessage
+	if json.Unmarshal(data, &probe) == nil {
+		if _, ok := probe["transcription"]; ok {
+			var out whisperCppOutput
+			if json.Unmarshal(data, &out) == nil {
+				return out.transcript()
+			}
+		}
+		var out whisperOutput
+		if json.Unmarshal(data, &out) == nil && (out.Text != "" || len(out.Segments) > 0) {
+			return out.transcript()
+		}
+	}
+	return &Transcript{Text: strings.TrimSpace(string(data))}
+}
+
+// formatTimestamp formats seconds as hh:mm:ss.mmm
+func formatTimestamp(seconds float64) string {
+	d := time.Duration(seconds * float64(time.Second)).Round(time.Millisecond)
+	return fmt.Sprintf("%02d:%02d:%02d.%03d",
+		int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60, d.Milliseconds()%1000)
+}
+
+// formatText formats a transcript as text: a line per segment with its
+// timestamps and speaker, or the bare text if there are no segments
+func formatText(t *Transcript) string {
+	if len(t.Segments) == 0 {
+		return t.Text + "\n"
+	}
+	var b strings.Builder
+	for _, s := range t.Segments {
+		fmt.Fprintf(&b, "[%s --> %s] ", formatTimestamp(s.Start), formatTimestamp(s.End))
+		if s.Speaker != "" {
+			fmt.Fprintf(&b, "%s: ", s.Speaker)
+		}
+		b.WriteString(s.Text)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// whisperAPI transcribes with the OpenAI audio transcriptions API, or any
+// compatible endpoint via api_base
+type whisperAPI struct {
+	apiKey   string
+	apiBase  string
+	model    string
+	language string
+	diarize  bool // Ask for speaker segments (diarized_json)
+	client   *http.Client
+}
+
+// progressReader reports the bytes read from a file being uploaded
+type progressReader struct {
+	r        io.Reader
+	done     int64
+	total    int64
+	progress plugin.TaskProgress
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.done += int64(n)
+	p.progress(p.done, p.total, "uploading")
+	return n, err
+}
+
+func (w *whisperAPI) transcribe(ctx context.Context, input string, progress plugin.TaskProgress) (*Transcript, error) {
+	f, err := os.Open(input)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]string{"model": w.model, "response_format": "verbose_json"}
+	if w.diarize {
+		fields["response_format"] = "diarized_json"
+		fields["chunking_strategy"] = "auto"
+	} else {
+		fields["timestamp_granularities[]"] = "segment"
+	}
+	if w.language != "" {
+		fields["language"] = w.language
+	}
+
+	// Stream the file into the request rather than reading it into memory
+	body, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		err := func() error {
+			for _, k := range []string{"model", "response_format", "chunking_strategy", "timestamp_granularities[]", "language"} {
+				if v, ok := fields[k]; ok {
+					if err := mw.WriteField(k, v); err != nil {
+						return err
+					}
+				}
+			}
+			part, err := mw.CreateFormFile("file", filepath.Base(input))
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(part, &progressReader{r: f, total: info.Size(), progress: progress}); err != nil {
+				return err
+			}
+			return mw.Close()
+		}()
+		pw.CloseWithError(err)
+	}()
+	defer body.Close()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", w.apiBase+"/audio/transcriptions", body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+w.apiKey)
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	progress(info.Size(), info.Size(), "transcribing")
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("transcription API error (status %d): %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var out whisperOutput
+	if err := json.Unmarshal(respBody, &out); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return out.transcript(), nil
+}
+
+// whisperCommand transcribes with a local command, such as whisper,
+// whisper.cpp or whisperx, run through the shell. {input} is replaced by
+// the path of the file and {output_dir} by a directory for its output:
+// a JSON file written there is read as the transcript, otherwise the
+// command's output.
+type whisperCommand struct {
+	shell   string
+	command string
+}
+
+// shellQuote quotes a path for the shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func (c *whisperCommand) transcribe(ctx context.Context, input string, progress plugin.TaskProgress) (*Transcript, error) {
+	dir, err := os.MkdirTemp("", "transcriptfs-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	command := strings.NewReplacer("{input}", shellQuote(input), "{output_dir}", shellQuote(dir)).Replace(c.command)
+	progress(0, 0, "transcribing")
+	cmd := exec.CommandContext(ctx, c.shell, "-c", command)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("transcription command failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	outputs, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(outputs) > 0 {
+		sort.Strings(outputs)
+		if out, err = os.ReadFile(outputs[0]); err != nil {
+			return nil, err
+		}
+	}
+	return parseTranscript(out), nil
+}
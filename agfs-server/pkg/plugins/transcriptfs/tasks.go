@@ -0,0 +1,218 @@
+package transcriptfs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/mountablefs"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	log "github.com/sirupsen/logrus"
+)
+
+// TranscribeTask transcribes a file of in/ into out/. Its arg is the path
+// of the file, /in/<name>.
+const TranscribeTask = "transcribe"
+
+// SetParentFileSystem gives the plugin access to the task framework of the
+// AGFS tree, which runs the transcriptions of the files written to in/
+func (t *TranscriptFSPlugin) SetParentFileSystem(fs filesystem.FileSystem) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rootFS = fs
+}
+
+// Tasks implements plugin.TaskRunner
+func (t *TranscriptFSPlugin) Tasks() []string {
+	return []string{TranscribeTask}
+}
+
+// RunTask implements plugin.TaskRunner
+func (t *TranscriptFSPlugin) RunTask(ctx context.Context, task string, args map[string]string, progress plugin.TaskProgress) error {
+	if task != TranscribeTask {
+		return fmt.Errorf("unknown task: %s", task)
+	}
+	tp := parsePath(args["path"])
+	if tp.dir != inDir || tp.name == "" {
+		return fmt.Errorf("transcribe needs the path of a file in %s/", inDir)
+	}
+	input := filepath.Join(t.dataDir, inDir, tp.name)
+	info, err := os.Stat(input)
+	if err != nil {
+		return fmt.Errorf("input not found: %s/%s", inDir, tp.name)
+	}
+
+	// Shutdown stops transcriptions; timeout bounds each one
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	stop := context.AfterFunc(t.ctx, cancel)
+	defer stop()
+
+	progress(0, info.Size(), "queued")
+	select {
+	case t.slots <- struct{}{}:
+		defer func() { <-t.slots }()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	start := time.Now()
+	transcript, err := t.engine.transcribe(ctx, input, progress)
+	if err != nil {
+		return err
+	}
+	transcript.Source = inDir + "/" + tp.name
+
+	data, err := json.MarshalIndent(transcript, "", "  ")
+	if err != nil {
+		return err
+	}
+	flags := filesystem.WriteFlagCreate | filesystem.WriteFlagTruncate
+	if _, err := t.baseFS.Write(path.Join("/", outDir, tp.name+jsonSuffix), append(data, '\n'), -1, flags); err != nil {
+		return fmt.Errorf("failed to write transcript: %w", err)
+	}
+	if _, err := t.baseFS.Write(path.Join("/", outDir, tp.name+textSuffix), []byte(formatText(transcript)), -1, flags); err != nil {
+		return fmt.Errorf("failed to write transcript: %w", err)
+	}
+
+	progress(info.Size(), info.Size(), fmt.Sprintf("wrote %s/%s%s", outDir, tp.name, textSuffix))
+	log.Infof("[transcriptfs] Transcribed %s/%s (%d segments) in %v",
+		inDir, tp.name, len(transcript.Segments), time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// tasks returns the task framework of the AGFS tree the plugin is mounted in
+func (t *TranscriptFSPlugin) tasks() (*mountablefs.MountableFS, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	mfs, ok := t.rootFS.(*mountablefs.MountableFS)
+	if !ok {
+		return nil, filesystem.NewNotSupportedError("transcribe", t.mountPath)
+	}
+	return mfs, nil
+}
+
+// schedule starts the transcription of an input after delay, unless it is
+// written to again first; whole-file writes start it at once
+func (t *TranscriptFSPlugin) schedule(name string, delay time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if timer, ok := t.timers[name]; ok {
+		timer.Stop()
+		delete(t.timers, name)
+	}
+	if delay <= 0 {
+		go t.start(name)
+		return
+	}
+	t.timers[name] = time.AfterFunc(delay, func() {
+		t.mu.Lock()
+		delete(t.timers, name)
+		t.mu.Unlock()
+		t.start(name)
+	})
+}
+
+// start starts the transcription of an input, cancelling the one of its
+// previous content
+func (t *TranscriptFSPlugin) start(name string) {
+	if info, err := t.baseFS.Stat(path.Join("/", inDir, name)); err != nil || info.Size == 0 {
+		return
+	}
+	mfs, err := t.tasks()
+	if err != nil {
+		log.Warnf("[transcriptfs] Cannot transcribe %s/%s: %v", inDir, name, err)
+		return
+	}
+
+	t.cancel(name)
+	info, err := mfs.StartTask(path.Join(t.mountPath, inDir, name), TranscribeTask, nil)
+	if err != nil {
+		log.Warnf("[transcriptfs] Failed to start transcription of %s/%s: %v", inDir, name, err)
+		return
+	}
+
+	t.mu.Lock()
+	t.running[name] = info.ID
+	t.mu.Unlock()
+}
+
+// cancel stops the pending or running transcription of an input
+func (t *TranscriptFSPlugin) cancel(name string) {
+	t.mu.Lock()
+	if timer, ok := t.timers[name]; ok {
+		timer.Stop()
+		delete(t.timers, name)
+	}
+	id, running := t.running[name]
+	delete(t.running, name)
+	t.mu.Unlock()
+
+	if !running {
+		return
+	}
+	if mfs, err := t.tasks(); err == nil {
+		// Finished tasks ignore the cancellation
+		mfs.CancelTask(id)
+	}
+}
+
+// transcriptions returns the last transcription task of each input
+func (t *TranscriptFSPlugin) transcriptions() map[string]mountablefs.TaskInfo {
+	last := make(map[string]mountablefs.TaskInfo)
+	mfs, err := t.tasks()
+	if err != nil {
+		return last
+	}
+	for _, task := range mfs.ListTasks() {
+		if task.Mount != t.mountPath || task.Task != TranscribeTask {
+			continue
+		}
+		if tp := parsePath(task.Args["path"]); tp.dir == inDir && tp.name != "" {
+			last[tp.name] = task
+		}
+	}
+	return last
+}
+
+func formatProgress(name string, task mountablefs.TaskInfo) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "source: %s/%s\n", inDir, name)
+	fmt.Fprintf(&b, "state: %s\n", task.Status)
+	fmt.Fprintf(&b, "task: %d\n", task.ID)
+	fmt.Fprintf(&b, "progress: %d/%d\n", task.Done, task.Total)
+	if task.Message != "" {
+		fmt.Fprintf(&b, "message: %s\n", task.Message)
+	}
+	fmt.Fprintf(&b, "started: %s\n", task.StartedAt.Format(time.RFC3339))
+	if task.FinishedAt != nil {
+		fmt.Fprintf(&b, "finished: %s\n", task.FinishedAt.Format(time.RFC3339))
+	}
+	if task.Error != "" {
+		fmt.Fprintf(&b, "error: %s\n", task.Error)
+	}
+	return b.String()
+}
+
+func progressInfo(name string, task mountablefs.TaskInfo) filesystem.FileInfo {
+	modTime := task.StartedAt
+	if task.FinishedAt != nil {
+		modTime = *task.FinishedAt
+	}
+	return filesystem.FileInfo{
+		Name:    name + progressSuffix,
+		Size:    int64(len(formatProgress(name, task))),
+		Mode:    0444,
+		ModTime: modTime,
+		Meta: filesystem.MetaData{Name: PluginName, Type: MetaValueProgress, Content: map[string]string{
+			"state": task.Status,
+		}},
+	}
+}
@@ -0,0 +1,639 @@
+package transcriptfs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/localfs"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PluginName = "transcriptfs" // Name of this plugin
+
+	inDir  = "in"  // Directory of the audio and video files to transcribe
+	outDir = "out" // Directory of the transcripts
+
+	textSuffix     = ".txt"      // out/<file>.txt: the transcript as timestamped lines
+	jsonSuffix     = ".json"     // out/<file>.json: the transcript with its segments
+	progressSuffix = ".progress" // out/<file>.progress: the state of the transcription
+
+	defaultSettleDelay = 5 * time.Second
+	defaultTimeout     = 30 * time.Minute
+	defaultConcurrency = 2
+)
+
+// Meta values for TranscriptFS plugin
+const (
+	MetaValueInput      = "input"      // An audio or video file of in/
+	MetaValueTranscript = "transcript" // A transcript of out/
+	MetaValueProgress   = "progress"   // The progress file of a transcription
+	MetaValueDirectory  = "directory"  // The root, in/ and out/
+)
+
+// TranscriptFSPlugin transcribes the audio and video files written to in/
+//
+//	/in/<file>              - audio or video to transcribe
+//	/out/<file>.txt         - its transcript, a timestamped line per segment
+//	/out/<file>.json        - its transcript with segments and speakers
+//	/out/<file>.progress    - the state of its transcription
+//
+// Files are stored in data_dir; transcriptions run as background tasks of
+// the AGFS task framework.
+type TranscriptFSPlugin struct {
+	dataDir     string
+	mountPath   string
+	settleDelay time.Duration // Quiet time after partial writes before transcribing
+	timeout     time.Duration
+	baseFS      *localfs.LocalFS
+	engine      transcriber
+	slots       chan struct{} // Limits concurrent transcriptions
+
+	rootFS  filesystem.FileSystem
+	timers  map[string]*time.Timer // Input -> pending transcription
+	running map[string]int64       // Input -> task of its last transcription
+	mu      sync.Mutex             // Protects rootFS, timers and running
+
+	ctx     context.Context // Done on shutdown
+	stopAll context.CancelFunc
+
+	metadata plugin.PluginMetadata
+}
+
+// NewTranscriptFSPlugin creates a new transcription plugin
+func NewTranscriptFSPlugin() *TranscriptFSPlugin {
+	return &TranscriptFSPlugin{
+		timers:  make(map[string]*time.Timer),
+		running: make(map[string]int64),
+		metadata: plugin.PluginMetadata{
+			Name:        PluginName,
+			Version:     "1.0.0",
+			Description: "Transcribes audio and video files into timestamped transcripts",
+			Author:      "AGFS Server",
+		},
+	}
+}
+
+func (t *TranscriptFSPlugin) Name() string {
+	return t.metadata.Name
+}
+
+func (t *TranscriptFSPlugin) Validate(cfg map[string]interface{}) error {
+	allowedKeys := []string{
+		"mount_path", "data_dir", "provider", "api_key", "api_base", "model", "language", "diarize",
+		"command", "shell", "concurrency", "timeout", "settle_delay",
+	}
+	if err := config.ValidateOnlyKnownKeys(cfg, allowedKeys); err != nil {
+		return err
+	}
+
+	for _, key := range []string{"data_dir", "provider", "api_key", "api_base", "model", "language", "command", "shell", "timeout", "settle_delay"} {
+		if err := config.ValidateStringType(cfg, key); err != nil {
+			return err
+		}
+	}
+	if err := config.ValidateBoolType(cfg, "diarize"); err != nil {
+		return err
+	}
+	if err := config.ValidateIntType(cfg, "concurrency"); err != nil {
+		return err
+	}
+	if _, err := config.RequireString(cfg, "data_dir"); err != nil {
+		return err
+	}
+	if config.GetIntConfig(cfg, "concurrency", defaultConcurrency) <= 0 {
+		return fmt.Errorf("invalid concurrency: must be positive")
+	}
+	if _, err := parseDurations(cfg); err != nil {
+		return err
+	}
+	_, err := newTranscriber(cfg)
+	return err
+}
+
+// parseDurations reads timeout and settle_delay
+func parseDurations(cfg map[string]interface{}) ([2]time.Duration, error) {
+	var durations [2]time.Duration
+	for i, key := range []string{"timeout", "settle_delay"} {
+		def := defaultTimeout
+		if key == "settle_delay" {
+			def = defaultSettleDelay
+		}
+		d, err := time.ParseDuration(config.GetStringConfig(cfg, key, def.String()))
+		if err != nil || d < 0 || d == 0 && key == "timeout" {
+			return durations, fmt.Errorf("invalid %s: must be a duration such as %q", key, def.String())
+		}
+		durations[i] = d
+	}
+	return durations, nil
+}
+
+func (t *TranscriptFSPlugin) Initialize(cfg map[string]interface{}) error {
+	durations, err := parseDurations(cfg)
+	if err != nil {
+		return err
+	}
+	engine, err := newTranscriber(cfg)
+	if err != nil {
+		return err
+	}
+
+	t.dataDir = config.GetStringConfig(cfg, "data_dir", "")
+	for _, dir := range []string{inDir, outDir} {
+		if err := os.MkdirAll(filepath.Join(t.dataDir, dir), 0755); err != nil {
+			return fmt.Errorf("failed to create %s directory: %w", dir, err)
+		}
+	}
+	if t.baseFS, err = localfs.NewLocalFS(t.dataDir); err != nil {
+		return fmt.Errorf("failed to initialize localfs: %w", err)
+	}
+
+	t.mountPath = config.GetStringConfig(cfg, "mount_path", "/transcriptfs")
+	t.timeout, t.settleDelay = durations[0], durations[1]
+	t.engine = engine
+	t.slots = make(chan struct{}, config.GetIntConfig(cfg, "concurrency", defaultConcurrency))
+	t.ctx, t.stopAll = context.WithCancel(context.Background())
+
+	log.Infof("[transcriptfs] Initialized with data_dir=%s, provider=%s",
+		t.dataDir, config.GetStringConfig(cfg, "provider", "openai"))
+	return nil
+}
+
+func (t *TranscriptFSPlugin) GetFileSystem() filesystem.FileSystem {
+	return &transcriptFS{plugin: t}
+}
+
+func (t *TranscriptFSPlugin) GetReadme() string {
+	return `TranscriptFS Plugin - Audio and Video Transcription
+
+This plugin transcribes the audio and video files dropped into in/ with
+the OpenAI transcription API (Whisper) or a local model, and writes
+timestamped transcripts with speaker segments to out/.
+
+STRUCTURE:
+  /README                  - This file
+  /in/<file>               - Audio or video to transcribe
+  /out/<file>.txt          - Its transcript, a timestamped line per segment
+  /out/<file>.json         - Its transcript with segments and speakers
+  /out/<file>.progress     - The state of its transcription
+
+USAGE:
+  Drop a file:
+    cp meeting.mp3 /transcriptfs/in/
+
+  Follow the transcription:
+    cat /transcriptfs/out/meeting.mp3.progress
+    source: in/meeting.mp3
+    state: running
+    task: 12
+    progress: 1048576/5242880
+    message: uploading
+    started: 2024-11-21T10:30:00Z
+
+  Read the transcript:
+    cat /transcriptfs/out/meeting.mp3.txt
+    [00:00:00.000 --> 00:00:04.200] A: Let's get started.
+    [00:00:04.200 --> 00:00:07.900] B: Sure, first the release.
+
+  Writing a file again transcribes its new content; removing it from in/
+  cancels its transcription. Transcriptions are background tasks: they are
+  also listed, and can be cancelled, with the task admin API.
+
+  Whole-file writes start the transcription at once. Files written in
+  parts (e.g. through FUSE) are transcribed once they have not been
+  written to for settle_delay (default: 5s).
+
+PROVIDERS:
+  openai (default): the OpenAI audio transcriptions API, or a compatible
+  endpoint via api_base. diarize = true asks for speaker segments, with
+  the gpt-4o-transcribe-diarize model by default.
+
+  command: a local model, run through the shell. {input} is replaced by
+  the path of the file and {output_dir} by a directory for its output; a
+  JSON file written there (whisper, whisperx or whisper.cpp format) is
+  read as the transcript, otherwise the command's output:
+    command = "whisper {input} --model base --output_format json --output_dir {output_dir}"
+    command = "whisper-cli -m ggml-base.bin -f {input} -oj -of {output_dir}/out"
+
+CONFIGURATION:
+  [plugins.transcriptfs]
+  enabled = true
+  path = "/transcriptfs"
+
+    [plugins.transcriptfs.config]
+    data_dir = "/var/lib/agfs/transcripts"
+    provider = "openai"
+    api_key = "sk-..."
+    language = "en"
+    concurrency = 2
+`
+}
+
+func (t *TranscriptFSPlugin) GetConfigParams() []plugin.ConfigParameter {
+	return []plugin.ConfigParameter{
+		{
+			Name:        "data_dir",
+			Type:        "string",
+			Required:    true,
+			Description: "Directory storing in/ and out/",
+		},
+		{
+			Name:        "provider",
+			Type:        "string",
+			Required:    false,
+			Default:     "openai",
+			Description: "Transcription engine: the OpenAI transcription API, or a local command",
+			Enum:        []string{"openai", "command"},
+		},
+		{
+			Name:        "api_key",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "OpenAI API key (default: OPENAI_API_KEY)",
+			Secret:      true,
+		},
+		{
+			Name:        "api_base",
+			Type:        "string",
+			Required:    false,
+			Default:     "https://api.openai.com/v1",
+			Description: "Base URL of an OpenAI-compatible transcription API",
+		},
+		{
+			Name:        "model",
+			Type:        "string",
+			Required:    false,
+			Default:     "whisper-1",
+			Description: "Transcription model (gpt-4o-transcribe-diarize with diarize)",
+		},
+		{
+			Name:        "language",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "ISO-639-1 language of the audio, detected if empty",
+		},
+		{
+			Name:        "diarize",
+			Type:        "bool",
+			Required:    false,
+			Default:     "false",
+			Description: "Ask the API for speaker segments",
+		},
+		{
+			Name:        "command",
+			Type:        "string",
+			Required:    false,
+			Default:     "",
+			Description: "Local transcription command, with {input} and {output_dir} placeholders",
+		},
+		{
+			Name:        "shell",
+			Type:        "string",
+			Required:    false,
+			Default:     "/bin/sh",
+			Description: "Shell running the local transcription command",
+		},
+		{
+			Name:        "concurrency",
+			Type:        "int",
+			Required:    false,
+			Default:     "2",
+			Description: "Transcriptions running at once",
+		},
+		{
+			Name:        "timeout",
+			Type:        "string",
+			Required:    false,
+			Default:     "30m",
+			Description: "How long one transcription may take",
+		},
+		{
+			Name:        "settle_delay",
+			Type:        "string",
+			Required:    false,
+			Default:     "5s",
+			Description: "Quiet time after a partial write before the file is transcribed",
+		},
+	}
+}
+
+func (t *TranscriptFSPlugin) Shutdown() error {
+	t.mu.Lock()
+	for name, timer := range t.timers {
+		timer.Stop()
+		delete(t.timers, name)
+	}
+	t.mu.Unlock()
+
+	if t.stopAll != nil {
+		t.stopAll()
+	}
+	return nil
+}
+
+// transcriptPath is a path of the mount
+//
+//	/                       - root (dir == "")
+//	/README                 - dir == "README"
+//	/in, /in/<name>         - dir == inDir
+//	/out, /out/<name>       - dir == outDir
+type transcriptPath struct {
+	dir  string
+	name string
+}
+
+// parsePath splits a path of the mount; dir is "" for paths that cannot
+// exist other than the root
+func parsePath(p string) transcriptPath {
+	parts := strings.SplitN(strings.Trim(filesystem.NormalizePath(p), "/"), "/", 2)
+	tp := transcriptPath{dir: parts[0]}
+	if len(parts) > 1 {
+		tp.name = parts[1]
+	}
+	switch {
+	case tp.dir == "README" && tp.name == "":
+	case tp.dir == inDir || tp.dir == outDir:
+		if strings.Contains(tp.name, "/") {
+			return transcriptPath{dir: "invalid"}
+		}
+	case tp.dir != "":
+		return transcriptPath{dir: "invalid"}
+	}
+	return tp
+}
+
+func (tp transcriptPath) isFile() bool {
+	return tp.name != "" || tp.dir == "README"
+}
+
+// progressInput returns the input whose progress file a file of out/ is
+func (tp transcriptPath) progressInput() (string, bool) {
+	if tp.dir != outDir || !strings.HasSuffix(tp.name, progressSuffix) {
+		return "", false
+	}
+	return strings.TrimSuffix(tp.name, progressSuffix), true
+}
+
+// transcriptFS implements the FileSystem interface for transcriptions
+type transcriptFS struct {
+	plugin *TranscriptFSPlugin
+}
+
+func dirInfo(name string) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    name,
+		Mode:    0755,
+		ModTime: time.Now(),
+		IsDir:   true,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: MetaValueDirectory},
+	}
+}
+
+func readmeInfo(size int) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    "README",
+		Size:    int64(size),
+		Mode:    0444,
+		ModTime: time.Now(),
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "doc"},
+	}
+}
+
+// fileInfo retags the info of a stored file as this plugin's
+func fileInfo(info filesystem.FileInfo, dir string) filesystem.FileInfo {
+	metaType := MetaValueInput
+	if dir == outDir {
+		metaType = MetaValueTranscript
+	}
+	info.Meta = filesystem.MetaData{Name: PluginName, Type: metaType}
+	return info
+}
+
+func (tfs *transcriptFS) Create(p string) error {
+	tp := parsePath(p)
+	if tp.dir != inDir || tp.name == "" {
+		return filesystem.NewPermissionDeniedError("create", p, "only files of in/ can be created")
+	}
+	return tfs.plugin.baseFS.Create(p)
+}
+
+func (tfs *transcriptFS) Mkdir(p string, perm uint32) error {
+	if _, err := tfs.Stat(p); err == nil {
+		return filesystem.NewAlreadyExistsError("directory", p)
+	}
+	return filesystem.NewPermissionDeniedError("mkdir", p, "in/ and out/ hold files only")
+}
+
+func (tfs *transcriptFS) Remove(p string) error {
+	tp := parsePath(p)
+	if tp.dir == "invalid" {
+		return filesystem.NewNotFoundError("remove", p)
+	}
+	if _, ok := tp.progressInput(); ok || tp.name == "" {
+		return filesystem.NewPermissionDeniedError("remove", p, "only files of in/ and out/ can be removed")
+	}
+	if tp.dir == inDir {
+		tfs.plugin.cancel(tp.name)
+	}
+	return tfs.plugin.baseFS.Remove(p)
+}
+
+func (tfs *transcriptFS) RemoveAll(p string) error {
+	return tfs.Remove(p)
+}
+
+func (tfs *transcriptFS) Read(p string, offset int64, size int64) ([]byte, error) {
+	tp := parsePath(p)
+	switch {
+	case tp.dir == "README":
+		return plugin.ApplyRangeRead([]byte(tfs.plugin.GetReadme()), offset, size)
+	case tp.dir == "invalid":
+		return nil, filesystem.NewNotFoundError("read", p)
+	case !tp.isFile():
+		return nil, fmt.Errorf("is a directory: %s", p)
+	}
+
+	if name, ok := tp.progressInput(); ok {
+		task, ok := tfs.plugin.transcriptions()[name]
+		if !ok {
+			return nil, filesystem.NewNotFoundError("read", p)
+		}
+		return plugin.ApplyRangeRead([]byte(formatProgress(name, task)), offset, size)
+	}
+	return tfs.plugin.baseFS.Read(p, offset, size)
+}
+
+// Write stores a file of in/ and schedules its transcription
+func (tfs *transcriptFS) Write(p string, data []byte, offset int64, flags filesystem.WriteFlag) (int64, error) {
+	tp := parsePath(p)
+	if tp.dir != inDir || tp.name == "" {
+		return 0, filesystem.NewPermissionDeniedError("write", p, "only files of in/ can be written")
+	}
+	n, err := tfs.plugin.baseFS.Write(p, data, offset, flags)
+	if err != nil {
+		return n, err
+	}
+
+	delay := tfs.plugin.settleDelay
+	if offset < 0 && flags&filesystem.WriteFlagAppend == 0 {
+		delay = 0
+	}
+	tfs.plugin.schedule(tp.name, delay)
+	return n, nil
+}
+
+func (tfs *transcriptFS) ReadDir(p string) ([]filesystem.FileInfo, error) {
+	tp := parsePath(p)
+	switch {
+	case tp.dir == "invalid":
+		return nil, filesystem.NewNotFoundError("readdir", p)
+	case tp.isFile():
+		return nil, filesystem.NewNotDirectoryError(p)
+	case tp.dir == "":
+		return []filesystem.FileInfo{
+			readmeInfo(len(tfs.plugin.GetReadme())),
+			dirInfo(inDir),
+			dirInfo(outDir),
+		}, nil
+	}
+
+	stored, err := tfs.plugin.baseFS.ReadDir(p)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]filesystem.FileInfo, 0, len(stored))
+	for _, info := range stored {
+		files = append(files, fileInfo(info, tp.dir))
+	}
+	if tp.dir == outDir {
+		for name, task := range tfs.plugin.transcriptions() {
+			files = append(files, progressInfo(name, task))
+		}
+		sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	}
+	return files, nil
+}
+
+func (tfs *transcriptFS) Stat(p string) (*filesystem.FileInfo, error) {
+	tp := parsePath(p)
+	var info filesystem.FileInfo
+	switch {
+	case tp.dir == "invalid":
+		return nil, filesystem.NewNotFoundError("stat", p)
+	case tp.dir == "":
+		info = dirInfo("/")
+	case tp.dir == "README":
+		info = readmeInfo(len(tfs.plugin.GetReadme()))
+	case tp.name == "":
+		info = dirInfo(tp.dir)
+	default:
+		if name, ok := tp.progressInput(); ok {
+			task, ok := tfs.plugin.transcriptions()[name]
+			if !ok {
+				return nil, filesystem.NewNotFoundError("stat", p)
+			}
+			info = progressInfo(name, task)
+			break
+		}
+		stored, err := tfs.plugin.baseFS.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		info = fileInfo(*stored, tp.dir)
+	}
+	return &info, nil
+}
+
+// Rename renames a file within in/ or out/; renaming an input transcribes
+// it under its new name
+func (tfs *transcriptFS) Rename(oldPath, newPath string) error {
+	from, to := parsePath(oldPath), parsePath(newPath)
+	_, fromProgress := from.progressInput()
+	_, toProgress := to.progressInput()
+	if from.name == "" || to.name == "" || from.dir != to.dir || fromProgress || toProgress {
+		return filesystem.NewNotSupportedError("rename", oldPath)
+	}
+	if from.dir == inDir {
+		tfs.plugin.cancel(from.name)
+	}
+	if err := tfs.plugin.baseFS.Rename(oldPath, newPath); err != nil {
+		return err
+	}
+	if to.dir == inDir {
+		tfs.plugin.schedule(to.name, 0)
+	}
+	return nil
+}
+
+func (tfs *transcriptFS) Chmod(path string, mode uint32) error {
+	return nil
+}
+
+func (tfs *transcriptFS) Truncate(p string, size int64) error {
+	tp := parsePath(p)
+	if tp.dir != inDir || tp.name == "" {
+		return filesystem.NewPermissionDeniedError("truncate", p, "only files of in/ can be written")
+	}
+	return tfs.plugin.baseFS.Truncate(p, size)
+}
+
+func (tfs *transcriptFS) Open(p string) (io.ReadCloser, error) {
+	tp := parsePath(p)
+	if _, ok := tp.progressInput(); ok || tp.dir == "README" {
+		data, err := tfs.Read(p, 0, -1)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	if !tp.isFile() || tp.dir == "invalid" {
+		return nil, filesystem.NewNotFoundError("open", p)
+	}
+	return tfs.plugin.baseFS.Open(p)
+}
+
+// OpenWrite streams a file into in/; it is transcribed once closed
+func (tfs *transcriptFS) OpenWrite(p string) (io.WriteCloser, error) {
+	tp := parsePath(p)
+	if tp.dir != inDir || tp.name == "" {
+		return nil, filesystem.NewPermissionDeniedError("write", p, "only files of in/ can be written")
+	}
+	w, err := tfs.plugin.baseFS.OpenWrite(p)
+	if err != nil {
+		return nil, err
+	}
+	return &inputWriter{WriteCloser: w, plugin: tfs.plugin, name: tp.name}, nil
+}
+
+// inputWriter schedules the transcription of an input when it is closed
+type inputWriter struct {
+	io.WriteCloser
+	plugin *TranscriptFSPlugin
+	name   string
+}
+
+func (w *inputWriter) Close() error {
+	if err := w.WriteCloser.Close(); err != nil {
+		return err
+	}
+	w.plugin.schedule(w.name, 0)
+	return nil
+}
+
+var _ plugin.ServicePlugin = (*TranscriptFSPlugin)(nil)
+var _ plugin.TaskRunner = (*TranscriptFSPlugin)(nil)
+var _ filesystem.FileSystem = (*transcriptFS)(nil)
+var _ filesystem.Truncater = (*transcriptFS)(nil)
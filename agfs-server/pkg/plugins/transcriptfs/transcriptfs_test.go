@@ -0,0 +1,208 @@
+package transcriptfs
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/mountablefs"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/api"
+)
+
+// newTestFS mounts the plugin at /transcripts of a MountableFS, which runs
+// its transcription tasks
+func newTestFS(t *testing.T, cfg map[string]interface{}) *transcriptFS {
+	t.Helper()
+	cfg["data_dir"] = t.TempDir()
+	cfg["mount_path"] = "/transcripts"
+	p := NewTranscriptFSPlugin()
+	if err := p.Validate(cfg); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if err := p.Initialize(cfg); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	t.Cleanup(func() { p.Shutdown() })
+
+	mfs := mountablefs.NewMountableFS(api.PoolConfig{})
+	if err := mfs.Mount("/transcripts", p); err != nil {
+		t.Fatalf("Mount failed: %v", err)
+	}
+	return p.GetFileSystem().(*transcriptFS)
+}
+
+// readIgnoreEOF reads file content, ignoring io.EOF which is expected at end of file
+func readIgnoreEOF(fs *transcriptFS, path string) (string, error) {
+	content, err := fs.Read(path, 0, -1)
+	if err == io.EOF {
+		err = nil
+	}
+	return string(content), err
+}
+
+// waitForState waits until the progress file of an input reports state
+func waitForState(t *testing.T, fs *transcriptFS, name, state string) string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		progress, _ := readIgnoreEOF(fs, "/out/"+name+".progress")
+		if strings.Contains(progress, "state: "+state+"\n") {
+			return progress
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("transcription of %s did not reach %s: %q", name, state, progress)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTranscriptFSOpenAI(t *testing.T) {
+	var mu sync.Mutex
+	var forms []map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/audio/transcriptions" || r.Header.Get("Authorization") != "Bearer test-key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		audio, _ := io.ReadAll(file)
+		mu.Lock()
+		forms = append(forms, map[string]string{
+			"model":           r.FormValue("model"),
+			"response_format": r.FormValue("response_format"),
+			"filename":        header.Filename,
+			"audio":           string(audio),
+		})
+		mu.Unlock()
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"text": "Let's get started. Sure.",
+			"segments": []map[string]interface{}{
+				{"start": 0, "end": 4.2, "speaker": "A", "text": " Let's get started."},
+				{"start": 4.2, "end": 61.5, "speaker": "B", "text": " Sure."},
+			},
+		})
+	}))
+	t.Cleanup(srv.Close)
+
+	fs := newTestFS(t, map[string]interface{}{
+		"api_key":  "test-key",
+		"api_base": srv.URL,
+		"diarize":  true,
+	})
+
+	if _, err := fs.Write("/in/meeting.mp3", []byte("ID3 audio"), -1, filesystem.WriteFlagCreate|filesystem.WriteFlagTruncate); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	progress := waitForState(t, fs, "meeting.mp3", "succeeded")
+	if !strings.Contains(progress, "source: in/meeting.mp3\n") || !strings.Contains(progress, "progress: 9/9\n") {
+		t.Errorf("progress = %q", progress)
+	}
+
+	mu.Lock()
+	if len(forms) != 1 || forms[0]["model"] != "gpt-4o-transcribe-diarize" || forms[0]["response_format"] != "diarized_json" ||
+		forms[0]["filename"] != "meeting.mp3" || forms[0]["audio"] != "ID3 audio" {
+		t.Errorf("requests = %+v", forms)
+	}
+	mu.Unlock()
+
+	text, err := readIgnoreEOF(fs, "/out/meeting.mp3.txt")
+	want := "[00:00:00.000 --> 00:00:04.200] A: Let's get started.\n[00:00:04.200 --> 00:01:01.500] B: Sure.\n"
+	if err != nil || text != want {
+		t.Errorf("transcript = %q, %v; want %q", text, err, want)
+	}
+	data, err := readIgnoreEOF(fs, "/out/meeting.mp3.json")
+	var transcript Transcript
+	if err != nil || json.Unmarshal([]byte(data), &transcript) != nil ||
+		transcript.Source != "in/meeting.mp3" || transcript.Duration != 61.5 || len(transcript.Segments) != 2 {
+		t.Errorf("JSON transcript = %q, %v", data, err)
+	}
+
+	infos, err := fs.ReadDir("/out")
+	if err != nil || len(infos) != 3 || infos[0].Name != "meeting.mp3.json" || infos[1].Name != "meeting.mp3.progress" {
+		t.Errorf("ReadDir = %+v, %v", infos, err)
+	}
+	if _, err := fs.Write("/out/meeting.mp3.txt", []byte("x"), -1, filesystem.WriteFlagCreate); !errors.Is(err, filesystem.ErrPermissionDenied) {
+		t.Errorf("write to out/: expected ErrPermissionDenied, got %v", err)
+	}
+	if err := fs.Remove("/out/meeting.mp3.txt"); err != nil {
+		t.Errorf("remove transcript failed: %v", err)
+	}
+}
+
+func TestTranscriptFSCommand(t *testing.T) {
+	// The "model" copies its input, a whisper.cpp transcript, to its output
+	fs := newTestFS(t, map[string]interface{}{
+		"provider":     "command",
+		"command":      "cat {input} > {output_dir}/out.json",
+		"settle_delay": "50ms",
+	})
+
+	transcript := `{"result": {"language": "en"}, "transcription": [{"offsets": {"from": 0, "to": 1500}, "text": " Hello"}]}`
+	// Partial writes are transcribed once the file settles
+	for i := 0; i < len(transcript); i += 20 {
+		end := i + 20
+		if end > len(transcript) {
+			end = len(transcript)
+		}
+		if _, err := fs.Write("/in/hello.wav", []byte(transcript[i:end]), int64(i), filesystem.WriteFlagCreate); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+	waitForState(t, fs, "hello.wav", "succeeded")
+	if text, err := readIgnoreEOF(fs, "/out/hello.wav.txt"); err != nil || text != "[00:00:00.000 --> 00:00:01.500] Hello\n" {
+		t.Errorf("transcript = %q, %v", text, err)
+	}
+	tasks := fs.plugin.transcriptions()
+	if len(tasks) != 1 || tasks["hello.wav"].ID != 1 {
+		t.Errorf("expected a single transcription task, got %+v", tasks)
+	}
+
+	// Output that is not JSON is a transcript without timestamps
+	if _, err := fs.Write("/in/broken.wav", []byte("noise"), -1, filesystem.WriteFlagCreate|filesystem.WriteFlagTruncate); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	waitForState(t, fs, "broken.wav", "succeeded")
+	if text, _ := readIgnoreEOF(fs, "/out/broken.wav.txt"); text != "noise\n" {
+		t.Errorf("plain text transcript = %q", text)
+	}
+
+	// Failures are reported in the progress file
+	fs.plugin.engine = &whisperCommand{shell: "/bin/sh", command: "echo failed >&2; exit 3"}
+	if _, err := fs.Write("/in/broken.wav", []byte("more noise"), -1, filesystem.WriteFlagCreate|filesystem.WriteFlagTruncate); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if progress := waitForState(t, fs, "broken.wav", "failed"); !strings.Contains(progress, "error: transcription command failed") {
+		t.Errorf("progress = %q", progress)
+	}
+}
+
+func TestTranscriptFSValidate(t *testing.T) {
+	for _, cfg := range []map[string]interface{}{
+		{"data_dir": "/tmp", "provider": "command"},
+		{"data_dir": "/tmp", "provider": "cloud", "api_key": "k"},
+		{"data_dir": "/tmp", "api_key": "k", "timeout": "0s"},
+		{"data_dir": "/tmp", "api_key": "k", "concurrency": 0},
+		{"api_key": "k"},
+		{"data_dir": "/tmp", "api_key": "k", "unknown": true},
+	} {
+		if err := NewTranscriptFSPlugin().Validate(cfg); err == nil {
+			t.Errorf("expected %v to be rejected", cfg)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package vectorfs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+)
+
+// membersFile is the virtual file of an alias directory listing the
+// namespaces the alias stands for
+const membersFile = ".members"
+
+// namespaceAliases returns the namespace_aliases configuration: alias ->
+// namespaces searched together under its name
+func namespaceAliases(cfg map[string]interface{}) (map[string][]string, error) {
+	if err := config.ValidateMapType(cfg, "namespace_aliases"); err != nil {
+		return nil, err
+	}
+	raw, _ := cfg["namespace_aliases"].(map[string]interface{})
+	aliases := make(map[string][]string, len(raw))
+	for alias, v := range raw {
+		if alias == "" || alias == "README" || strings.Contains(alias, "/") {
+			return nil, fmt.Errorf("namespace_aliases: invalid alias name %q", alias)
+		}
+		list, ok := v.([]interface{})
+		if !ok || len(list) == 0 {
+			return nil, fmt.Errorf("namespace_aliases.%s must be a list of namespaces", alias)
+		}
+		for _, item := range list {
+			namespace, ok := item.(string)
+			if !ok || namespace == "" || strings.Contains(namespace, "/") {
+				return nil, fmt.Errorf("namespace_aliases.%s must be a list of namespaces", alias)
+			}
+			if _, isAlias := raw[namespace]; isAlias {
+				return nil, fmt.Errorf("namespace_aliases.%s: %s is an alias, not a namespace", alias, namespace)
+			}
+			aliases[alias] = append(aliases[alias], namespace)
+		}
+	}
+	return aliases, nil
+}
+
+// searchedNamespaces returns the namespaces a search at path searches: all
+// of them at the root, those of an alias, or the namespace of the path
+func (vfs *vectorFS) searchedNamespaces(namespace, relativePath string) ([]string, error) {
+	members, isAlias := vfs.plugin.aliases[namespace]
+	switch {
+	case namespace == "":
+	case isAlias && relativePath == "":
+	case !strings.HasPrefix(relativePath, "docs"):
+		return nil, fmt.Errorf("vector search only supported in docs/ directory")
+	case !isAlias:
+		return []string{namespace}, nil
+	}
+
+	existing, err := vfs.plugin.tidbClient.ListNamespaces()
+	if err != nil {
+		return nil, err
+	}
+	if namespace == "" {
+		return existing, nil
+	}
+	// Namespaces of the alias that do not exist (yet) have nothing to search
+	exists := make(map[string]bool, len(existing))
+	for _, ns := range existing {
+		exists[ns] = true
+	}
+	var namespaces []string
+	for _, ns := range members {
+		if exists[ns] {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces, nil
+}
+
+// aliasNames returns the configured aliases, sorted
+func (v *VectorFSPlugin) aliasNames() []string {
+	names := make([]string, 0, len(v.aliases))
+	for alias := range v.aliases {
+		names = append(names, alias)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// aliasMembers returns the content of the .members file of an alias
+func (v *VectorFSPlugin) aliasMembers(alias string) string {
+	return strings.Join(v.aliases[alias], "\n") + "\n"
+}
+
+// aliasError is returned by operations on an alias other than search and
+// reading its members
+func aliasError(alias string) error {
+	return fmt.Errorf("%s is a namespace alias, it only supports search: use one of its namespaces (see %s/%s)", alias, alias, membersFile)
+}
+
+// aliasInfo returns the file info of an alias directory
+func aliasInfo(alias string) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    alias,
+		Mode:    0555,
+		ModTime: time.Now(),
+		IsDir:   true,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "alias"},
+	}
+}
+
+// membersInfo returns the file info of the .members file of an alias
+func (v *VectorFSPlugin) membersInfo(alias string) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    membersFile,
+		Size:    int64(len(v.aliasMembers(alias))),
+		Mode:    0444,
+		ModTime: time.Now(),
+		IsDir:   false,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "status"},
+	}
+}
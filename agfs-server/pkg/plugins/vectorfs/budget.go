@@ -0,0 +1,239 @@
+package vectorfs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+)
+
+// statsFile is the virtual file at the root and in each namespace showing
+// the embedding usage and its caps
+const statsFile = ".stats"
+
+// Config keys of the embedding caps, over the last hour; 0 for no cap
+var budgetKeys = []string{
+	"embedding_max_requests_per_hour",
+	"embedding_max_tokens_per_hour",
+	"embedding_namespace_max_requests_per_hour",
+	"embedding_namespace_max_tokens_per_hour",
+}
+
+// budgetLimits are the caps of an embeddingBudget, 0 for none
+type budgetLimits struct {
+	requests, tokens     int64 // Of all namespaces
+	nsRequests, nsTokens int64 // Of each namespace
+}
+
+// budgetLimitsFromConfig returns the embedding caps of a config
+func budgetLimitsFromConfig(cfg map[string]interface{}) (budgetLimits, error) {
+	var values [4]int64
+	for i, key := range budgetKeys {
+		if err := config.ValidateIntType(cfg, key); err != nil {
+			return budgetLimits{}, err
+		}
+		values[i] = int64(config.GetIntConfig(cfg, key, 0))
+		if values[i] < 0 {
+			return budgetLimits{}, fmt.Errorf("%s must not be negative", key)
+		}
+	}
+	return budgetLimits{requests: values[0], tokens: values[1], nsRequests: values[2], nsTokens: values[3]}, nil
+}
+
+// usageBucket is the usage of one minute
+type usageBucket struct {
+	minute           int64 // Minutes since the Unix epoch
+	requests, tokens int64
+}
+
+// usageWindow tracks embedding usage over the last hour, by minute, and
+// since the plugin started
+type usageWindow struct {
+	buckets [60]usageBucket
+
+	requests, tokens, rejected int64 // Since the plugin started
+}
+
+func (u *usageWindow) add(now time.Time, requests, tokens int64) {
+	minute := now.Unix() / 60
+	b := &u.buckets[minute%60]
+	if b.minute != minute {
+		*b = usageBucket{minute: minute}
+	}
+	b.requests += requests
+	b.tokens += tokens
+	u.requests += requests
+	u.tokens += tokens
+}
+
+// lastHour returns the usage of the last hour, and when its oldest minute
+// leaves the window
+func (u *usageWindow) lastHour(now time.Time) (requests, tokens int64, frees time.Time) {
+	minute := now.Unix() / 60
+	oldest := minute
+	for _, b := range u.buckets {
+		if b.minute <= minute-60 || b.minute > minute {
+			continue
+		}
+		requests += b.requests
+		tokens += b.tokens
+		if b.requests != 0 || b.tokens != 0 {
+			oldest = min(oldest, b.minute)
+		}
+	}
+	return requests, tokens, time.Unix((oldest+60)*60, 0)
+}
+
+// embeddingBudget counts the embedding requests and tokens of all namespaces
+// and of each, and caps them over the last hour, so that a runaway agent
+// writing documents or searching in a loop cannot run up the provider's
+// bill. Tokens are estimated before a request and corrected with the usage
+// the provider reports.
+type embeddingBudget struct {
+	limits budgetLimits
+
+	mu         sync.Mutex
+	global     usageWindow
+	namespaces map[string]*usageWindow
+	now        func() time.Time
+}
+
+func newEmbeddingBudget(limits budgetLimits) *embeddingBudget {
+	return &embeddingBudget{limits: limits, namespaces: make(map[string]*usageWindow), now: time.Now}
+}
+
+// estimateTokens estimates the tokens of texts, at about four bytes a token
+func estimateTokens(texts []string) int64 {
+	var n int64
+	for _, text := range texts {
+		n += int64(len(text)+3) / 4
+	}
+	return n
+}
+
+// namespace returns the usage of a namespace; b.mu is held
+func (b *embeddingBudget) namespace(name string) *usageWindow {
+	u := b.namespaces[name]
+	if u == nil {
+		u = &usageWindow{}
+		b.namespaces[name] = u
+	}
+	return u
+}
+
+// reserve counts a request of an estimated number of tokens for namespace
+// ("" for searches of several namespaces, which only count globally), or
+// fails with a QuotaExceeded error if it would exceed a cap
+func (b *embeddingBudget) reserve(namespace string, tokens int64) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+
+	var ns *usageWindow
+	if namespace != "" {
+		ns = b.namespace(namespace)
+	}
+	if err := b.check(now, &b.global, "/", "embedding_max", b.limits.requests, b.limits.tokens, tokens); err != nil {
+		b.global.rejected++
+		if ns != nil {
+			ns.rejected++
+		}
+		return err
+	}
+	if ns != nil {
+		if err := b.check(now, ns, namespace, "embedding_namespace_max", b.limits.nsRequests, b.limits.nsTokens, tokens); err != nil {
+			b.global.rejected++
+			ns.rejected++
+			return err
+		}
+		ns.add(now, 1, tokens)
+	}
+	b.global.add(now, 1, tokens)
+	return nil
+}
+
+// check fails if one more request of tokens would exceed a cap of u
+func (b *embeddingBudget) check(now time.Time, u *usageWindow, path, prefix string, maxRequests, maxTokens, tokens int64) error {
+	requests, used, frees := u.lastHour(now)
+	var err error
+	switch {
+	case maxRequests > 0 && requests+1 > maxRequests:
+		err = filesystem.NewQuotaExceededError("embed", path, prefix+"_requests_per_hour", requests+1, maxRequests)
+	case maxTokens > 0 && used+tokens > maxTokens:
+		err = filesystem.NewQuotaExceededError("embed", path, prefix+"_tokens_per_hour", used+tokens, maxTokens)
+	default:
+		return nil
+	}
+	return fmt.Errorf("%w: embedding budget exhausted, more frees up in %s", err, frees.Sub(now).Round(time.Second))
+}
+
+// settle corrects the tokens reserved for a request of namespace with the
+// tokens it used
+func (b *embeddingBudget) settle(namespace string, reserved, used int64) {
+	if b == nil || reserved == used {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	b.global.add(now, 0, used-reserved)
+	if namespace != "" {
+		b.namespace(namespace).add(now, 0, used-reserved)
+	}
+}
+
+// stats formats the usage of a namespace for its .stats file, or the global
+// usage and that of every namespace for namespace ""
+func (b *embeddingBudget) stats(namespace string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+
+	var sb strings.Builder
+	if namespace != "" {
+		u := b.namespaces[namespace]
+		if u == nil {
+			u = &usageWindow{}
+		}
+		formatUsage(&sb, now, u, b.limits.nsRequests, b.limits.nsTokens)
+		return sb.String()
+	}
+
+	formatUsage(&sb, now, &b.global, b.limits.requests, b.limits.tokens)
+	names := make([]string, 0, len(b.namespaces))
+	for name := range b.namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) > 0 {
+		sb.WriteString("namespaces:\n")
+	}
+	for _, name := range names {
+		requests, tokens, _ := b.namespaces[name].lastHour(now)
+		fmt.Fprintf(&sb, "  %s: %d requests, %d tokens in the last hour\n", name, requests, tokens)
+	}
+	return sb.String()
+}
+
+func formatUsage(sb *strings.Builder, now time.Time, u *usageWindow, maxRequests, maxTokens int64) {
+	requests, tokens, _ := u.lastHour(now)
+	fmt.Fprintf(sb, "requests_last_hour: %d%s\n", requests, limitSuffix(maxRequests))
+	fmt.Fprintf(sb, "tokens_last_hour: %d%s\n", tokens, limitSuffix(maxTokens))
+	fmt.Fprintf(sb, "requests_total: %d\n", u.requests)
+	fmt.Fprintf(sb, "tokens_total: %d\n", u.tokens)
+	fmt.Fprintf(sb, "rejected_total: %d\n", u.rejected)
+}
+
+func limitSuffix(limit int64) string {
+	if limit <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" / %d", limit)
+}
@@ -0,0 +1,199 @@
+package vectorfs
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+// chunksDir is the virtual directory in docs/ showing the stored chunks of
+// each document, to check what was indexed when search results look wrong
+const chunksDir = ".chunks"
+
+// isChunksPath checks if a path relative to a namespace is in docs/.chunks
+func isChunksPath(relativePath string) bool {
+	return relativePath == "docs/"+chunksDir || strings.HasPrefix(relativePath, "docs/"+chunksDir+"/")
+}
+
+// chunksFileName returns the document name of a path relative to a
+// namespace in docs/.chunks, "" for the directory itself
+func chunksFileName(relativePath string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(relativePath, "docs/"+chunksDir), "/")
+}
+
+// readChunksReport returns the chunks report of a document: its indexing
+// state, then each stored chunk with its index, stable ID, byte offset in the
+// document, length, embedding status and text
+func (vfs *vectorFS) readChunksReport(namespace, fileName string) ([]byte, error) {
+	meta, err := vfs.plugin.tidbClient.GetFileMetadataByName(namespace, fileName)
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := vfs.plugin.tidbClient.ListChunks(namespace, meta.FileDigest)
+	if err != nil {
+		return nil, err
+	}
+	var content string
+	if len(chunks) > 0 {
+		data, err := vfs.plugin.s3Client.DownloadDocument(context.Background(), namespace, meta.FileDigest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download document from S3: %w", err)
+		}
+		content = string(data)
+	}
+
+	state := "indexed"
+	if len(chunks) == 0 {
+		switch {
+		case vfs.plugin.isIndexing(namespace, meta.FileDigest):
+			state = "indexing"
+		case meta.FileSize == 0:
+			state = "empty, not indexed"
+		default:
+			state = "not indexed"
+		}
+	}
+	model := vfs.plugin.indexer.embedderFor(meta.Language).model
+	return []byte(formatChunksReport(meta, state, model, vfs.plugin.embeddingClient.GetDimension(), content, chunks)), nil
+}
+
+// formatChunksReport formats the chunks report of a document whose content
+// is content (only needed to locate chunks)
+func formatChunksReport(meta *FileMetadata, state, model string, dimension int, content string, chunks []ChunkData) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "document: %s\n", meta.FileName)
+	fmt.Fprintf(&b, "digest: %s\n", meta.FileDigest)
+	if meta.Language != "" {
+		fmt.Fprintf(&b, "language: %s\n", meta.Language)
+	}
+	fmt.Fprintf(&b, "embedding model: %s\n", model)
+	fmt.Fprintf(&b, "status: %s\n", state)
+	fmt.Fprintf(&b, "chunks: %d\n", len(chunks))
+
+	offsets := chunkOffsets(content, chunks)
+	for i, chunk := range chunks {
+		offset := "unknown"
+		if offsets[i] >= 0 {
+			offset = fmt.Sprintf("%d", offsets[i])
+		}
+		var embedding string
+		switch n := len(chunk.Embedding); {
+		case n == 0:
+			embedding = "missing"
+		case n != dimension:
+			embedding = fmt.Sprintf("%d dims, expected %d", n, dimension)
+		default:
+			embedding = fmt.Sprintf("%d dims", n)
+		}
+		fmt.Fprintf(&b, "\n--- chunk %d (id %s, offset %s, %d bytes, embedding: %s)\n%s\n",
+			chunk.ChunkIndex, chunkID(chunk.ChunkText), offset, len(chunk.ChunkText), embedding, chunk.ChunkText)
+	}
+	return b.String()
+}
+
+// chunkOffsets locates chunks in the content they were made of, in order;
+// the offset of a chunk whose text is not found verbatim (long paragraphs
+// are split on whitespace, which is normalized) is -1
+func chunkOffsets(content string, chunks []ChunkData) []int {
+	offsets := make([]int, len(chunks))
+	pos := 0
+	for i, chunk := range chunks {
+		offsets[i] = -1
+		if chunk.ChunkText == "" {
+			continue
+		}
+		if j := strings.Index(content[pos:], chunk.ChunkText); j >= 0 {
+			offsets[i] = pos + j
+			pos += j + len(chunk.ChunkText)
+		}
+	}
+	return offsets
+}
+
+// readChunksDir lists docs/.chunks or one of its subdirectories, which
+// mirror docs/ with a chunks report per document
+func (vfs *vectorFS) readChunksDir(namespace, relativePath string) ([]filesystem.FileInfo, error) {
+	var subPrefix string
+	if dir := chunksFileName(relativePath); dir != "" {
+		subPrefix = dir + "/"
+	}
+	files, err := vfs.plugin.tidbClient.ListFilesWithPrefix(namespace, subPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	seenDirs := make(map[string]bool)
+	var fileInfos []filesystem.FileInfo
+	for _, f := range files {
+		name := strings.TrimPrefix(f.FileName, subPrefix)
+		if i := strings.Index(name, "/"); i != -1 {
+			if dirName := name[:i]; !seenDirs[dirName] {
+				seenDirs[dirName] = true
+				fileInfos = append(fileInfos, chunksDirInfo(dirName))
+			}
+			continue
+		}
+		// The size of a report is only known once it is made, by Stat
+		fileInfos = append(fileInfos, chunksInfo(name, 0, f.UpdatedAt))
+	}
+	if subPrefix != "" && len(fileInfos) == 0 {
+		return nil, filesystem.ErrNotFound
+	}
+	return fileInfos, nil
+}
+
+// statChunks stats docs/.chunks, a chunks report or a directory of them
+func (vfs *vectorFS) statChunks(namespace, relativePath string) (*filesystem.FileInfo, error) {
+	fileName := chunksFileName(relativePath)
+	if fileName == "" {
+		fi := chunksDirInfo(chunksDir)
+		fi.Meta.Type = "chunks"
+		return &fi, nil
+	}
+
+	meta, err := vfs.plugin.tidbClient.GetFileMetadataByName(namespace, fileName)
+	if err == nil {
+		report, err := vfs.readChunksReport(namespace, fileName)
+		if err != nil {
+			return nil, err
+		}
+		fi := chunksInfo(filepath.Base(fileName), int64(len(report)), meta.UpdatedAt)
+		return &fi, nil
+	}
+
+	hasFiles, err := vfs.plugin.tidbClient.HasFilesWithPrefix(namespace, fileName+"/")
+	if err != nil {
+		return nil, err
+	}
+	if !hasFiles {
+		return nil, filesystem.ErrNotFound
+	}
+	fi := chunksDirInfo(filepath.Base(fileName))
+	return &fi, nil
+}
+
+func chunksDirInfo(name string) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    name,
+		Mode:    0555,
+		ModTime: time.Now(),
+		IsDir:   true,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "directory"},
+	}
+}
+
+// chunksInfo returns the file info of the chunks report of a document
+func chunksInfo(name string, size int64, modTime time.Time) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    name,
+		Size:    size,
+		Mode:    0444,
+		ModTime: modTime,
+		IsDir:   false,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "chunks"},
+	}
+}
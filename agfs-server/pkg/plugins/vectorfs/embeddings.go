@@ -2,54 +2,143 @@ package vectorfs
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
+// openAIAPIBase is the API base URL of the openai provider
+const openAIAPIBase = "https://api.openai.com/v1"
+
+// Defaults of the http provider, a local inference server that is usually
+// fed many small batches at once
+const (
+	defaultHTTPBatchSize   = 32
+	defaultHTTPConcurrency = 4
+)
+
 // EmbeddingConfig holds embedding configuration
 type EmbeddingConfig struct {
-	Provider  string // Provider name (openai)
-	APIKey    string // API key
-	Model     string // Model name
-	Dimension int    // Embedding dimension
+	Provider    string // Provider name (openai, or http for an OpenAI-compatible server)
+	APIKey      string // API key (optional for http)
+	APIBase     string // API base URL of the http provider, e.g. http://localhost:8081/v1
+	Model       string // Model name
+	Dimension   int    // Embedding dimension
+	BatchSize   int    // Texts per request, 0 for no limit (default 32 for http)
+	Concurrency int    // Requests in flight, 0 for no limit (default 4 for http)
 }
 
 // EmbeddingClient handles embedding generation
 type EmbeddingClient struct {
 	provider  string
 	apiKey    string
+	apiBase   string
 	model     string
 	dimension int
+	batchSize int           // Texts per request, 0 for no limit
+	sem       chan struct{} // Bounds the requests in flight; nil for no limit
 	client    *http.Client
+	budget    *embeddingBudget // Caps and counts the requests; nil for none
 }
 
-// NewEmbeddingClient creates a new embedding client
+// NewEmbeddingClient creates a new embedding client. The openai provider
+// calls the OpenAI API; the http provider calls a self-hosted server with an
+// OpenAI-compatible embeddings endpoint (APIBase + "/embeddings"), such as
+// text-embeddings-inference or vLLM.
 func NewEmbeddingClient(cfg EmbeddingConfig) (*EmbeddingClient, error) {
-	if cfg.Provider != "openai" {
+	apiBase := strings.TrimSuffix(cfg.APIBase, "/")
+	batchSize, concurrency := cfg.BatchSize, cfg.Concurrency
+	switch cfg.Provider {
+	case "openai":
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("API key is required")
+		}
+		if apiBase == "" {
+			apiBase = openAIAPIBase
+		}
+	case "http":
+		if apiBase == "" {
+			return nil, fmt.Errorf("embedding_api_base is required with the http embedding provider")
+		}
+		if batchSize == 0 {
+			batchSize = defaultHTTPBatchSize
+		}
+		if concurrency == 0 {
+			concurrency = defaultHTTPConcurrency
+		}
+	default:
 		return nil, fmt.Errorf("unsupported embedding provider: %s", cfg.Provider)
 	}
-
-	if cfg.APIKey == "" {
-		return nil, fmt.Errorf("API key is required")
+	if batchSize < 0 || concurrency < 0 {
+		return nil, fmt.Errorf("embedding batch size and concurrency must not be negative")
 	}
 
 	log.Infof("[vectorfs/embedding] Initialized %s embedding client (model: %s, dim: %d)",
 		cfg.Provider, cfg.Model, cfg.Dimension)
 
-	return &EmbeddingClient{
+	e := &EmbeddingClient{
 		provider:  cfg.Provider,
 		apiKey:    cfg.APIKey,
+		apiBase:   apiBase,
 		model:     cfg.Model,
 		dimension: cfg.Dimension,
+		batchSize: batchSize,
 		client: &http.Client{
 			Timeout: 60 * time.Second, // Prevent indefinite blocking on API calls
 		},
-	}, nil
+	}
+	if concurrency > 0 {
+		e.sem = make(chan struct{}, concurrency)
+	}
+	return e, nil
+}
+
+// CheckAuth checks that the provider accepts the API key, without generating
+// an embedding. For the http provider, it checks that the server embeds a
+// probe text with the configured dimension instead.
+func (e *EmbeddingClient) CheckAuth(ctx context.Context) error {
+	if e.provider == "http" {
+		embeddings, _, err := e.embed(ctx, []string{"agfs probe"})
+		if err != nil {
+			return err
+		}
+		if len(embeddings[0]) != e.dimension {
+			return fmt.Errorf("embedding server returned %d dimensions, expected embedding_dim %d", len(embeddings[0]), e.dimension)
+		}
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", e.apiBase+"/models/"+e.model, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+e.apiKey)
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("OpenAI API error (status %d): %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// probeName names the provider in probe results
+func (e *EmbeddingClient) probeName() string {
+	if e.provider == "http" {
+		return "embedding server"
+	}
+	return "openai auth"
 }
 
 // GetDimension returns the embedding dimension
@@ -57,39 +146,66 @@ func (e *EmbeddingClient) GetDimension() int {
 	return e.dimension
 }
 
-// GenerateEmbedding generates an embedding for the given text
-func (e *EmbeddingClient) GenerateEmbedding(text string) ([]float32, error) {
-	switch e.provider {
-	case "openai":
-		return e.generateOpenAIEmbedding(text)
-	default:
-		return nil, fmt.Errorf("unsupported provider: %s", e.provider)
+// GenerateEmbedding generates an embedding for the given text, on the
+// embedding budget of namespace ("" for searches of several namespaces)
+func (e *EmbeddingClient) GenerateEmbedding(namespace, text string) ([]float32, error) {
+	var embeddings [][]float32
+	err := e.spend(namespace, []string{text}, func() (tokens int, err error) {
+		embeddings, tokens, err = e.embed(context.Background(), []string{text})
+		return tokens, err
+	})
+	if err != nil {
+		return nil, err
 	}
+	return embeddings[0], nil
 }
 
-// GenerateBatchEmbeddings generates embeddings for multiple texts
-func (e *EmbeddingClient) GenerateBatchEmbeddings(texts []string) ([][]float32, error) {
+// GenerateBatchEmbeddings generates embeddings for multiple texts, on the
+// embedding budget of namespace, in requests of up to the batch size
+func (e *EmbeddingClient) GenerateBatchEmbeddings(namespace string, texts []string) ([][]float32, error) {
 	if len(texts) == 0 {
 		return nil, nil
 	}
 
-	switch e.provider {
-	case "openai":
-		return e.generateOpenAIBatchEmbeddingsImpl(texts)
-	default:
-		return nil, fmt.Errorf("unsupported provider: %s", e.provider)
+	batchSize := e.batchSize
+	if batchSize <= 0 {
+		batchSize = len(texts)
+	}
+	embeddings := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += batchSize {
+		batch := texts[start:min(start+batchSize, len(texts))]
+		err := e.spend(namespace, batch, func() (int, error) {
+			batchEmbeddings, tokens, err := e.embed(context.Background(), batch)
+			embeddings = append(embeddings, batchEmbeddings...)
+			return tokens, err
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
+	return embeddings, nil
 }
 
-// OpenAI API structures
-type openAIEmbeddingRequest struct {
-	Input string `json:"input"`
-	Model string `json:"model"`
+// spend runs a request embedding texts if the budget of namespace allows it,
+// then counts the tokens it used (none if it failed)
+func (e *EmbeddingClient) spend(namespace string, texts []string, request func() (int, error)) error {
+	estimate := estimateTokens(texts)
+	if err := e.budget.reserve(namespace, estimate); err != nil {
+		return err
+	}
+	tokens, err := request()
+	if err == nil && tokens == 0 {
+		// The provider did not report its usage
+		tokens = int(estimate)
+	}
+	e.budget.settle(namespace, estimate, int64(tokens))
+	return err
 }
 
+// OpenAI API structures, also spoken by the servers of the http provider
 type openAIBatchEmbeddingRequest struct {
 	Input []string `json:"input"`
-	Model string   `json:"model"`
+	Model string   `json:"model,omitempty"`
 }
 
 type openAIEmbeddingResponse struct {
@@ -104,98 +220,72 @@ type openAIEmbeddingResponse struct {
 	} `json:"usage"`
 }
 
-// generateOpenAIEmbedding generates embedding using OpenAI API
-func (e *EmbeddingClient) generateOpenAIEmbedding(text string) ([]float32, error) {
-	requestBody := openAIEmbeddingRequest{
-		Input: text,
+// embed generates the embeddings of texts with one request to the
+// embeddings endpoint, returning the tokens it used (0 if the server does
+// not say)
+func (e *EmbeddingClient) embed(ctx context.Context, texts []string) ([][]float32, int, error) {
+	requestBody := openAIBatchEmbeddingRequest{
+		Input: texts,
 		Model: e.model,
 	}
 
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return nil, 0, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/embeddings", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", e.apiBase+"/embeddings", bytes.NewBuffer(jsonData))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+e.apiKey)
-
-	resp, err := e.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("OpenAI API error (status %d): %s", resp.StatusCode, string(body))
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
 	}
 
-	var response openAIEmbeddingResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if len(response.Data) == 0 {
-		return nil, fmt.Errorf("no embedding returned from API")
+	if e.sem != nil {
+		select {
+		case e.sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		}
+		defer func() { <-e.sem }()
 	}
 
-	log.Debugf("[vectorfs/embedding] Generated embedding (tokens: %d)", response.Usage.TotalTokens)
-	return response.Data[0].Embedding, nil
-}
-
-// generateOpenAIBatchEmbeddingsImpl generates embeddings for multiple texts using OpenAI
-func (e *EmbeddingClient) generateOpenAIBatchEmbeddingsImpl(texts []string) ([][]float32, error) {
-	// OpenAI supports batch requests
-	requestBody := openAIBatchEmbeddingRequest{
-		Input: texts,
-		Model: e.model,
-	}
-
-	jsonData, err := json.Marshal(requestBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/embeddings", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+e.apiKey)
-
 	resp, err := e.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, 0, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("OpenAI API error (status %d): %s", resp.StatusCode, string(body))
+		if e.provider == "http" {
+			return nil, 0, fmt.Errorf("embedding server error (status %d): %s", resp.StatusCode, string(body))
+		}
+		return nil, 0, fmt.Errorf("OpenAI API error (status %d): %s", resp.StatusCode, string(body))
 	}
 
 	var response openAIEmbeddingResponse
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+		return nil, 0, fmt.Errorf("failed to decode response: %w", err)
 	}
 
 	if len(response.Data) != len(texts) {
-		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(response.Data))
+		return nil, 0, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(response.Data))
 	}
 
 	// Sort by index to ensure order matches input
 	embeddings := make([][]float32, len(texts))
 	for _, data := range response.Data {
+		if data.Index < 0 || data.Index >= len(texts) {
+			return nil, 0, fmt.Errorf("embedding index %d out of range", data.Index)
+		}
 		embeddings[data.Index] = data.Embedding
 	}
 
 	log.Debugf("[vectorfs/embedding] Generated %d embeddings (tokens: %d)",
 		len(embeddings), response.Usage.TotalTokens)
-	return embeddings, nil
+	return embeddings, response.Usage.TotalTokens, nil
 }
@@ -3,9 +3,11 @@ package vectorfs
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -15,6 +17,13 @@ type Indexer struct {
 	tidbClient      *TiDBClient
 	embeddingClient *EmbeddingClient
 	chunkerConfig   ChunkerConfig
+
+	// Embedding clients of languages routed to their own model (language_models)
+	languageClients map[string]*EmbeddingClient
+
+	// Serializes changes to the references of each content digest, so that
+	// content is not deleted while a file starts to reference it again
+	contents keyedMutex
 }
 
 // NewIndexer creates a new indexer
@@ -32,18 +41,110 @@ func NewIndexer(
 	}
 }
 
+// embedderFor returns the embedding client for documents in language
+func (idx *Indexer) embedderFor(language string) *EmbeddingClient {
+	if client, ok := idx.languageClients[language]; ok {
+		return client
+	}
+	return idx.embeddingClient
+}
+
+// embeddingModels returns the embedding model of each routed language, and
+// under "" the model of other languages
+func (idx *Indexer) embeddingModels() map[string]string {
+	models := map[string]string{"": idx.embeddingClient.model}
+	for language, client := range idx.languageClients {
+		models[language] = client.model
+	}
+	return models
+}
+
+// modelSearch is a vector search among the documents embedded by one model
+type modelSearch struct {
+	client *EmbeddingClient
+	filter LanguageFilter
+}
+
+// searchesFor returns the searches to run for a query restricted to
+// languages, or for all documents if languages is empty: one per embedding
+// model used by the documents searched
+func (idx *Indexer) searchesFor(languages []string) []modelSearch {
+	if len(languages) == 0 {
+		searches := []modelSearch{{client: idx.embeddingClient}}
+		var routed []string
+		for language := range idx.languageClients {
+			routed = append(routed, language)
+		}
+		sort.Strings(routed)
+		for _, language := range routed {
+			searches = append(searches, modelSearch{
+				client: idx.languageClients[language],
+				filter: LanguageFilter{Only: []string{language}},
+			})
+		}
+		searches[0].filter.Except = routed
+		return searches
+	}
+
+	var searches []modelSearch
+	byClient := make(map[*EmbeddingClient]int)
+	for _, language := range languages {
+		client := idx.embedderFor(language)
+		i, ok := byClient[client]
+		if !ok {
+			i = len(searches)
+			byClient[client] = i
+			searches = append(searches, modelSearch{client: client})
+		}
+		searches[i].filter.Only = append(searches[i].filter.Only, language)
+	}
+	return searches
+}
+
 // PrepareDocument uploads document to S3 and registers metadata in TiDB (synchronous phase).
 // After this completes, the file is visible via ls/cat.
 // Returns (alreadyExists, error) - if alreadyExists is true, no further indexing is needed.
-func (idx *Indexer) PrepareDocument(namespace, digest, fileName, content string) (bool, error) {
-	ctx := context.Background()
-
+// Content previously written to the file is released (see releaseContent).
+// language is the detected document language, "" if unknown.
+// Backend calls are recorded in trace, which may be nil.
+func (idx *Indexer) PrepareDocument(namespace, digest, fileName, content, language string, trace *filesystem.OpTrace) (bool, error) {
 	log.Infof("[vectorfs/indexer] Preparing document: %s (namespace: %s, digest: %s)",
 		fileName, namespace, digest)
 
+	start := time.Now()
+	prevDigest, err := idx.tidbClient.GetFileDigest(namespace, fileName)
+	trace.Span("tidb", start)
+	if err != nil {
+		return false, fmt.Errorf("failed to get file metadata: %w", err)
+	}
+
+	contentExists, err := idx.addReference(namespace, digest, fileName, content, language, trace)
+	if err != nil {
+		return false, err
+	}
+
+	if prevDigest != "" && prevDigest != digest {
+		idx.releaseContent(namespace, prevDigest, trace)
+	}
+
+	log.Infof("[vectorfs/indexer] Document prepared (metadata): %s", fileName)
+	// Return contentExists to indicate if chunk indexing can be skipped
+	return contentExists, nil
+}
+
+// addReference makes fileName reference the content with digest, uploading
+// the content unless another file already references it, which it reports
+func (idx *Indexer) addReference(namespace, digest, fileName, content, language string, trace *filesystem.OpTrace) (bool, error) {
+	ctx := context.Background()
+	key := lockKey(namespace, digest)
+	idx.contents.lock(key)
+	defer idx.contents.unlock(key)
+
 	// Check if content already indexed (same digest = same content)
 	// If so, skip S3 upload but still create file metadata for the new filename
+	start := time.Now()
 	contentExists, err := idx.tidbClient.FileExists(namespace, digest)
+	trace.Span("tidb", start)
 	if err != nil {
 		return false, fmt.Errorf("failed to check if file exists: %w", err)
 	}
@@ -52,7 +153,9 @@ func (idx *Indexer) PrepareDocument(namespace, digest, fileName, content string)
 
 	if !contentExists {
 		// Upload to S3 only if content doesn't exist
+		start = time.Now()
 		err = idx.s3Client.UploadDocument(ctx, namespace, digest, []byte(content))
+		trace.Span("s3 upload", start)
 		if err != nil {
 			return false, fmt.Errorf("failed to upload to S3: %w", err)
 		}
@@ -69,30 +172,96 @@ func (idx *Indexer) PrepareDocument(namespace, digest, fileName, content string)
 		FileName:   fileName,
 		S3Key:      s3Key,
 		FileSize:   int64(len(content)),
+		Language:   language,
 		CreatedAt:  now,
 		UpdatedAt:  now,
 	}
 
+	start = time.Now()
 	err = idx.tidbClient.InsertFileMetadata(namespace, metadata)
+	trace.Span("tidb", start)
 	if err != nil {
 		return false, fmt.Errorf("failed to insert file metadata: %w", err)
 	}
-
-	log.Infof("[vectorfs/indexer] Document prepared (metadata): %s", fileName)
-	// Return contentExists to indicate if chunk indexing can be skipped
 	return contentExists, nil
 }
 
+// RemoveDocument deletes a file; its content is released
+func (idx *Indexer) RemoveDocument(namespace, fileName string) error {
+	digest, err := idx.tidbClient.GetFileDigest(namespace, fileName)
+	if err != nil {
+		return fmt.Errorf("failed to get file metadata: %w", err)
+	}
+	if digest == "" {
+		return fmt.Errorf("file not found: %s", fileName)
+	}
+
+	if err := idx.tidbClient.DeleteFileByName(namespace, fileName); err != nil {
+		return fmt.Errorf("failed to delete file metadata: %w", err)
+	}
+	idx.releaseContent(namespace, digest, nil)
+
+	log.Infof("[vectorfs/indexer] Removed document: %s (namespace: %s)", fileName, namespace)
+	return nil
+}
+
+// releaseContent deletes the chunks and S3 object of the content with
+// digest if no file references it any more. The file has been written or
+// deleted at this point, so failures are only logged: they leave unused
+// chunks or objects behind, not a broken index.
+func (idx *Indexer) releaseContent(namespace, digest string, trace *filesystem.OpTrace) {
+	key := lockKey(namespace, digest)
+	idx.contents.lock(key)
+	defer idx.contents.unlock(key)
+
+	start := time.Now()
+	referenced, err := idx.tidbClient.FileExists(namespace, digest)
+	if err == nil && !referenced {
+		err = idx.tidbClient.DeleteFileChunks(namespace, digest)
+	}
+	if err == nil && !referenced {
+		err = idx.tidbClient.DeleteSummary(namespace, digest)
+	}
+	trace.Span("tidb", start)
+	if err != nil {
+		log.Warnf("[vectorfs/indexer] Failed to release content %s: %v", digest, err)
+		return
+	}
+	if referenced {
+		return
+	}
+
+	start = time.Now()
+	err = idx.s3Client.DeleteDocument(context.Background(), namespace, digest)
+	trace.Span("s3 delete", start)
+	if err != nil {
+		log.Warnf("[vectorfs/indexer] Failed to delete unreferenced content %s from S3: %v", digest, err)
+		return
+	}
+	log.Infof("[vectorfs/indexer] Deleted unreferenced content: %s", digest)
+}
+
 // IndexChunks performs chunking, embedding generation, and stores chunks in TiDB (async phase).
 // This is called after PrepareDocument to enable vector search on the document.
-func (idx *Indexer) IndexChunks(namespace, digest, fileName, content string) error {
+func (idx *Indexer) IndexChunks(namespace, digest, fileName, content, language string) error {
+	chunks, err := idx.EmbedChunks(namespace, digest, fileName, content, language)
+	if err != nil {
+		return err
+	}
+	_, err = idx.StoreChunks(namespace, digest, fileName, chunks)
+	return err
+}
+
+// EmbedChunks splits a document into chunks and generates their embeddings
+// with the model for its language; empty documents have no chunks
+func (idx *Indexer) EmbedChunks(namespace, digest, fileName, content, language string) ([]ChunkData, error) {
 	log.Infof("[vectorfs/indexer] Indexing chunks for document: %s (namespace: %s, digest: %s)",
 		fileName, namespace, digest)
 
 	// Skip empty files - they have no content to index
 	if strings.TrimSpace(content) == "" {
 		log.Infof("[vectorfs/indexer] Skipping empty file: %s", fileName)
-		return nil
+		return nil, nil
 	}
 
 	// Chunk the document
@@ -105,9 +274,9 @@ func (idx *Indexer) IndexChunks(namespace, digest, fileName, content string) err
 		chunkTexts = append(chunkTexts, chunk.Text)
 	}
 
-	embeddings, err := idx.embeddingClient.GenerateBatchEmbeddings(chunkTexts)
+	embeddings, err := idx.embedderFor(language).GenerateBatchEmbeddings(namespace, chunkTexts)
 	if err != nil {
-		return fmt.Errorf("failed to generate embeddings: %w", err)
+		return nil, fmt.Errorf("failed to generate embeddings: %w", err)
 	}
 
 	// Prepare chunk data for batch insert
@@ -119,30 +288,178 @@ func (idx *Indexer) IndexChunks(namespace, digest, fileName, content string) err
 			Embedding:  embeddings[i],
 		}
 	}
+	return chunkDataList, nil
+}
 
-	// Batch insert all chunks (reduces N database round-trips to 1-2)
-	err = idx.tidbClient.InsertChunksBatch(namespace, digest, chunkDataList)
+// StoreChunks stores the chunks made by EmbedChunks in TiDB, unless the
+// content is not referenced by any file any more (it was overwritten or
+// deleted while being indexed) or is already indexed. It reports whether
+// the chunks were stored.
+func (idx *Indexer) StoreChunks(namespace, digest, fileName string, chunks []ChunkData) (bool, error) {
+	key := lockKey(namespace, digest)
+	idx.contents.lock(key)
+	defer idx.contents.unlock(key)
+
+	referenced, err := idx.tidbClient.FileExists(namespace, digest)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if file exists: %w", err)
+	}
+	if !referenced {
+		log.Infof("[vectorfs/indexer] Dropped chunks of %s: content no longer referenced", fileName)
+		return false, nil
+	}
+	indexed, err := idx.tidbClient.HasChunks(namespace, digest)
 	if err != nil {
-		return fmt.Errorf("failed to batch insert chunks: %w", err)
+		return false, fmt.Errorf("failed to check for chunks: %w", err)
+	}
+	if indexed {
+		log.Infof("[vectorfs/indexer] Dropped chunks of %s: content already indexed", fileName)
+		return false, nil
+	}
+
+	// Batch insert all chunks (reduces N database round-trips to 1-2)
+	if err := idx.tidbClient.InsertChunksBatch(namespace, digest, chunks); err != nil {
+		return false, fmt.Errorf("failed to batch insert chunks: %w", err)
 	}
 
 	log.Infof("[vectorfs/indexer] Successfully indexed document: %s (%d chunks)",
 		fileName, len(chunks))
-	return nil
+	return true, nil
+}
+
+// ReplaceChunks replaces the chunks of a content with new ones made by
+// EmbedChunks, unless the content is not referenced by any file any more.
+// It reports whether the chunks were replaced.
+func (idx *Indexer) ReplaceChunks(namespace, digest, fileName string, chunks []ChunkData) (bool, error) {
+	key := lockKey(namespace, digest)
+	idx.contents.lock(key)
+	defer idx.contents.unlock(key)
+
+	referenced, err := idx.tidbClient.FileExists(namespace, digest)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if file exists: %w", err)
+	}
+	if !referenced {
+		log.Infof("[vectorfs/indexer] Dropped new chunks of %s: content no longer referenced", fileName)
+		return false, nil
+	}
+	if err := idx.tidbClient.ReplaceChunks(namespace, digest, chunks); err != nil {
+		return false, fmt.Errorf("failed to replace chunks: %w", err)
+	}
+
+	log.Infof("[vectorfs/indexer] Reindexed document: %s (%d chunks)", fileName, len(chunks))
+	return true, nil
+}
+
+// StoreSummary stores the summary of a document, unless its content is not
+// referenced by any file any more. It reports whether the summary was
+// stored.
+func (idx *Indexer) StoreSummary(namespace, digest, fileName, summary, model string) (bool, error) {
+	key := lockKey(namespace, digest)
+	idx.contents.lock(key)
+	defer idx.contents.unlock(key)
+
+	referenced, err := idx.tidbClient.FileExists(namespace, digest)
+	if err != nil || !referenced {
+		return false, err
+	}
+	if err := idx.tidbClient.InsertSummary(namespace, digest, summary, model); err != nil {
+		return false, err
+	}
+
+	log.Infof("[vectorfs/indexer] Stored summary of document: %s", fileName)
+	return true, nil
+}
+
+// ImportContent stores content read from an export (see transfer.go) with its
+// chunks and summary, unless the namespace already has them, and makes its
+// files reference it. Content previously referenced by the files is
+// released. It reports whether the content is indexed; if not, it must be
+// queued for indexing. The caller holds the write locks of the files.
+func (idx *Indexer) ImportContent(namespace string, c *importedContent) (bool, error) {
+	prevDigests := make(map[string]bool)
+	for _, f := range c.files {
+		prevDigest, err := idx.tidbClient.GetFileDigest(namespace, f.FileName)
+		if err != nil {
+			return false, fmt.Errorf("failed to get file metadata: %w", err)
+		}
+		if prevDigest != "" && prevDigest != c.digest {
+			prevDigests[prevDigest] = true
+		}
+	}
+
+	indexed, err := idx.importContent(namespace, c)
+	if err != nil {
+		return false, err
+	}
+
+	for prevDigest := range prevDigests {
+		idx.releaseContent(namespace, prevDigest, nil)
+	}
+	return indexed, nil
+}
+
+func (idx *Indexer) importContent(namespace string, c *importedContent) (bool, error) {
+	key := lockKey(namespace, c.digest)
+	idx.contents.lock(key)
+	defer idx.contents.unlock(key)
+
+	referenced, err := idx.tidbClient.FileExists(namespace, c.digest)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if file exists: %w", err)
+	}
+	if !referenced {
+		if err := idx.s3Client.UploadDocument(context.Background(), namespace, c.digest, c.data); err != nil {
+			return false, err
+		}
+	}
+
+	indexed, err := idx.tidbClient.HasChunks(namespace, c.digest)
+	if err != nil {
+		return false, fmt.Errorf("failed to check for chunks: %w", err)
+	}
+	if !indexed && len(c.chunks) > 0 {
+		if err := idx.tidbClient.InsertChunksBatch(namespace, c.digest, c.chunks); err != nil {
+			return false, err
+		}
+		indexed = true
+	}
+
+	if c.summary != "" {
+		exists, err := idx.tidbClient.HasSummary(namespace, c.digest)
+		if err != nil {
+			return false, err
+		}
+		if !exists {
+			if err := idx.tidbClient.InsertSummary(namespace, c.digest, c.summary, c.summaryModel); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	for _, f := range c.files {
+		f.FileDigest = c.digest
+		f.S3Key = idx.s3Client.buildKey(namespace, c.digest)
+		if err := idx.tidbClient.InsertFileMetadata(namespace, f); err != nil {
+			return false, err
+		}
+	}
+	return indexed, nil
 }
 
 // IndexDocument indexes a document (upload to S3, chunk, generate embeddings, store in TiDB)
 // Deprecated: Use PrepareDocument + IndexChunks for better performance.
 // This method is kept for backward compatibility.
 func (idx *Indexer) IndexDocument(namespace, digest, fileName, content string) error {
-	alreadyExists, err := idx.PrepareDocument(namespace, digest, fileName, content)
+	language := detectLanguage(content)
+	alreadyExists, err := idx.PrepareDocument(namespace, digest, fileName, content, language, nil)
 	if err != nil {
 		return err
 	}
 	if alreadyExists {
 		return nil
 	}
-	return idx.IndexChunks(namespace, digest, fileName, content)
+	return idx.IndexChunks(namespace, digest, fileName, content, language)
 }
 
 // DeleteDocument removes a document from the index
@@ -0,0 +1,160 @@
+package vectorfs
+
+import (
+	"strings"
+	"unicode"
+)
+
+// languageSampleSize is how many letters of a document detectLanguage looks at
+const languageSampleSize = 4096
+
+// scriptLanguages maps scripts used by a single common language to it
+var scriptLanguages = []struct {
+	script   *unicode.RangeTable
+	language string
+}{
+	{unicode.Hangul, "ko"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Greek, "el"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Thai, "th"},
+}
+
+// latinStopwords are frequent words of languages written in the Latin
+// script, which tell them apart
+var latinStopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "in", "that", "it", "for", "with", "are", "this"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "mit", "ein", "eine", "den", "auf", "sich"},
+	"fr": {"le", "la", "les", "et", "est", "des", "une", "du", "dans", "pour", "pas", "que"},
+	"es": {"el", "la", "los", "las", "y", "es", "del", "una", "para", "con", "por", "que"},
+	"it": {"il", "di", "che", "e", "la", "per", "una", "sono", "non", "della", "gli", "con"},
+	"pt": {"o", "os", "as", "e", "do", "da", "em", "um", "uma", "para", "com", "não"},
+	"nl": {"de", "het", "een", "en", "van", "is", "niet", "op", "voor", "met", "zijn", "dat"},
+}
+
+// latinStopwordIndex maps each stopword to the languages it belongs to
+var latinStopwordIndex = func() map[string][]string {
+	index := make(map[string][]string)
+	for language, words := range latinStopwords {
+		for _, w := range words {
+			index[w] = append(index[w], language)
+		}
+	}
+	return index
+}()
+
+// detectLanguage returns the ISO 639-1 code of the main language of text,
+// or "" if it cannot be determined. It relies on the script of the text,
+// and on frequent words for languages written in the Latin script.
+func detectLanguage(text string) string {
+	var han, kana, cyrillic, ukrainian, latin, letters int
+	scripts := make([]int, len(scriptLanguages))
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		if letters > languageSampleSize {
+			break
+		}
+		switch {
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+			if strings.ContainsRune("іїєґІЇЄҐ", r) {
+				ukrainian++
+			}
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		default:
+			for i, sl := range scriptLanguages {
+				if unicode.Is(sl.script, r) {
+					scripts[i]++
+					break
+				}
+			}
+		}
+	}
+	if letters == 0 {
+		return ""
+	}
+
+	// Pick the language of the most used script
+	best, count := "", 0
+	if cjk := han + kana; cjk > 0 {
+		// Japanese mixes kanji with kana; Chinese has no kana
+		best, count = "zh", cjk
+		if kana*10 >= cjk {
+			best = "ja"
+		}
+	}
+	if cyrillic > count {
+		best, count = "ru", cyrillic
+		if ukrainian > 0 {
+			best = "uk"
+		}
+	}
+	for i, n := range scripts {
+		if n > count {
+			best, count = scriptLanguages[i].language, n
+		}
+	}
+	if latin > count {
+		return detectLatinLanguage(text)
+	}
+	return best
+}
+
+// detectLatinLanguage tells languages written in the Latin script apart by
+// counting their stopwords
+func detectLatinLanguage(text string) string {
+	scores := make(map[string]int)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	if len(words) > languageSampleSize/4 {
+		words = words[:languageSampleSize/4]
+	}
+	for _, w := range words {
+		for _, language := range latinStopwordIndex[w] {
+			scores[language]++
+		}
+	}
+
+	best, bestScore, runnerUp := "", 0, 0
+	for language, score := range scores {
+		if score > bestScore || (score == bestScore && language < best) {
+			best, bestScore, runnerUp = language, score, bestScore
+		} else if score > runnerUp {
+			runnerUp = score
+		}
+	}
+	// Too few stopwords, or no clear winner
+	if bestScore < 3 || bestScore == runnerUp {
+		return ""
+	}
+	return best
+}
+
+// parseSearchQuery splits the language filters out of a search query:
+// "lang:zh kubernetes" searches documents in Chinese for "kubernetes", and
+// "lang:en,de" or "lang:en lang:de" documents in English or German
+func parseSearchQuery(query string) (text string, languages []string) {
+	var words []string
+	for _, word := range strings.Fields(query) {
+		if rest, ok := strings.CutPrefix(word, "lang:"); ok && rest != "" {
+			for _, language := range strings.Split(rest, ",") {
+				if language = strings.ToLower(strings.TrimSpace(language)); language != "" {
+					languages = append(languages, language)
+				}
+			}
+			continue
+		}
+		words = append(words, word)
+	}
+	return strings.Join(words, " "), languages
+}
@@ -0,0 +1,315 @@
+package vectorfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ocrTimeout bounds the recognition of one document
+	ocrTimeout = 5 * time.Minute
+
+	// ocrMaxPages is how many pages of a PDF are recognized
+	ocrMaxPages = 100
+
+	// ocrPDFResolution is the resolution, in DPI, PDF pages are rendered at
+	// to be recognized by tesseract
+	ocrPDFResolution = 300
+
+	// visionPagesPerRequest is how many PDF pages Cloud Vision recognizes
+	// per synchronous request
+	visionPagesPerRequest = 5
+)
+
+// Kinds of documents recognized by OCR
+const (
+	ocrImage = "image"
+	ocrPDF   = "pdf"
+)
+
+// ocrKind returns the kind of document to recognize the text of by OCR,
+// ocrImage or ocrPDF, or "" for other documents, which are indexed as text
+func ocrKind(data []byte) string {
+	switch contentType := http.DetectContentType(data); {
+	case contentType == "application/pdf":
+		return ocrPDF
+	case strings.HasPrefix(contentType, "image/"):
+		return ocrImage
+	}
+	// TIFF, common for scans, is not detected by http.DetectContentType
+	if bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*")) {
+		return ocrImage
+	}
+	return ""
+}
+
+// ocrEngine recognizes the text of images and scanned PDFs
+type ocrEngine interface {
+	// recognize returns the text of a document of kind ocrImage or ocrPDF
+	recognize(ctx context.Context, kind string, data []byte) (string, error)
+	// check checks that the engine is usable, without recognizing anything
+	check(ctx context.Context) error
+}
+
+// newOCR creates the OCR engine configured by the ocr_* options, or returns
+// nil if OCR is disabled
+func newOCR(cfg map[string]interface{}) (ocrEngine, error) {
+	if !config.GetBoolConfig(cfg, "ocr_enabled", false) {
+		return nil, nil
+	}
+
+	switch provider := config.GetStringConfig(cfg, "ocr_provider", "tesseract"); provider {
+	case "tesseract":
+		return &tesseractOCR{
+			command:   config.GetStringConfig(cfg, "ocr_command", "tesseract"),
+			languages: config.GetStringConfig(cfg, "ocr_languages", "eng"),
+		}, nil
+	case "google":
+		apiKey := config.GetStringConfig(cfg, "ocr_api_key", "")
+		if apiKey == "" {
+			return nil, fmt.Errorf("ocr_api_key is required when using google OCR provider")
+		}
+		return &visionOCR{
+			apiKey:  apiKey,
+			apiBase: strings.TrimSuffix(config.GetStringConfig(cfg, "ocr_api_base", "https://vision.googleapis.com/v1"), "/"),
+			client:  &http.Client{Timeout: ocrTimeout},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported OCR provider: %s", provider)
+	}
+}
+
+// recognizeTask replaces the data of a queued image or scanned PDF with its
+// recognized text, and stores the language of the text. It reports whether
+// the document can be indexed.
+func (v *VectorFSPlugin) recognizeTask(worker int, task *indexTask) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), ocrTimeout)
+	defer cancel()
+
+	start := time.Now()
+	text, err := v.ocr.recognize(ctx, task.ocrKind, []byte(task.data))
+	if err != nil {
+		log.Errorf("[vectorfs] Worker %d failed to recognize text of %s: %v", worker, task.fileName, err)
+		return false
+	}
+	log.Infof("[vectorfs] Worker %d recognized %d bytes of text in %s (%v)",
+		worker, len(text), task.fileName, time.Since(start).Round(time.Millisecond))
+
+	task.data = text
+	task.language = detectLanguage(text)
+	if task.language != "" {
+		if err := v.tidbClient.SetContentLanguage(task.namespace, task.digest, task.language); err != nil {
+			log.Warnf("[vectorfs] Worker %d failed to store language of %s: %v", worker, task.fileName, err)
+		}
+	}
+	return true
+}
+
+// tesseractOCR recognizes text with the tesseract command; PDFs are read
+// with pdftotext and, if they have no text layer, rendered with pdftoppm
+// (poppler-utils) and recognized page by page
+type tesseractOCR struct {
+	command   string
+	languages string // tesseract language codes, e.g. "eng+chi_sim"
+}
+
+func (t *tesseractOCR) check(ctx context.Context) error {
+	_, err := exec.LookPath(t.command)
+	return err
+}
+
+func (t *tesseractOCR) recognize(ctx context.Context, kind string, data []byte) (string, error) {
+	if kind == ocrImage {
+		return t.run(ctx, bytes.NewReader(data), "stdin")
+	}
+
+	dir, err := os.MkdirTemp("", "vectorfs-ocr-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	pdf := filepath.Join(dir, "document.pdf")
+	if err := os.WriteFile(pdf, data, 0600); err != nil {
+		return "", err
+	}
+
+	// Only scanned PDFs need OCR, others have a text layer
+	if out, err := exec.CommandContext(ctx, "pdftotext", "-l", fmt.Sprint(ocrMaxPages), pdf, "-").Output(); err == nil {
+		if text := string(out); strings.TrimSpace(text) != "" {
+			return text, nil
+		}
+	}
+
+	render := exec.CommandContext(ctx, "pdftoppm", "-r", fmt.Sprint(ocrPDFResolution), "-l", fmt.Sprint(ocrMaxPages),
+		"-png", pdf, filepath.Join(dir, "page"))
+	if out, err := render.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("pdftoppm failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	pages, err := filepath.Glob(filepath.Join(dir, "page-*.png"))
+	if err != nil {
+		return "", err
+	}
+	// Page numbers are zero-padded to the same width, so names sort in order
+	sort.Strings(pages)
+
+	var b strings.Builder
+	for _, page := range pages {
+		text, err := t.run(ctx, nil, page)
+		if err != nil {
+			return "", fmt.Errorf("page %s: %w", strings.TrimPrefix(filepath.Base(page), "page-"), err)
+		}
+		b.WriteString(text)
+		b.WriteString("\n\n")
+	}
+	return b.String(), nil
+}
+
+// run recognizes the image at input ("stdin" to read it from stdin)
+func (t *tesseractOCR) run(ctx context.Context, stdin io.Reader, input string) (string, error) {
+	cmd := exec.CommandContext(ctx, t.command, input, "stdout", "-l", t.languages)
+	cmd.Stdin = stdin
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %v: %s", t.command, err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// visionOCR recognizes text with the Google Cloud Vision API
+type visionOCR struct {
+	apiKey  string
+	apiBase string
+	client  *http.Client
+}
+
+// visionFeatures requests dense text recognition, suited to documents
+var visionFeatures = []map[string]string{{"type": "DOCUMENT_TEXT_DETECTION"}}
+
+// visionResponse is a response to an image annotation request
+type visionResponse struct {
+	FullTextAnnotation struct {
+		Text string `json:"text"`
+	} `json:"fullTextAnnotation"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (o *visionOCR) check(ctx context.Context) error {
+	// An empty batch is accepted with a valid key, and costs nothing
+	var resp struct{}
+	return o.call(ctx, "images:annotate", map[string]interface{}{"requests": []interface{}{}}, &resp)
+}
+
+func (o *visionOCR) recognize(ctx context.Context, kind string, data []byte) (string, error) {
+	content := base64.StdEncoding.EncodeToString(data)
+	if kind == ocrImage {
+		var resp struct {
+			Responses []visionResponse `json:"responses"`
+		}
+		req := map[string]interface{}{"requests": []interface{}{map[string]interface{}{
+			"image":    map[string]string{"content": content},
+			"features": visionFeatures,
+		}}}
+		if err := o.call(ctx, "images:annotate", req, &resp); err != nil {
+			return "", err
+		}
+		return visionText(resp.Responses)
+	}
+
+	// PDFs are recognized a few pages per request; the first response tells
+	// how many pages there are
+	var b strings.Builder
+	totalPages := visionPagesPerRequest
+	for first := 1; first <= totalPages && first <= ocrMaxPages; first += visionPagesPerRequest {
+		var pages []int
+		for p := first; p < first+visionPagesPerRequest && p <= totalPages && p <= ocrMaxPages; p++ {
+			pages = append(pages, p)
+		}
+		var resp struct {
+			Responses []struct {
+				Responses  []visionResponse `json:"responses"`
+				TotalPages int              `json:"totalPages"`
+			} `json:"responses"`
+		}
+		req := map[string]interface{}{"requests": []interface{}{map[string]interface{}{
+			"inputConfig": map[string]string{"content": content, "mimeType": "application/pdf"},
+			"features":    visionFeatures,
+			"pages":       pages,
+		}}}
+		if err := o.call(ctx, "files:annotate", req, &resp); err != nil {
+			return "", err
+		}
+		if len(resp.Responses) == 0 {
+			return "", fmt.Errorf("cloud vision returned no response")
+		}
+		text, err := visionText(resp.Responses[0].Responses)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(text)
+		totalPages = resp.Responses[0].TotalPages
+	}
+	return b.String(), nil
+}
+
+// visionText joins the text recognized in images or pages
+func visionText(responses []visionResponse) (string, error) {
+	var b strings.Builder
+	for _, r := range responses {
+		if r.Error != nil {
+			return "", fmt.Errorf("cloud vision error: %s", r.Error.Message)
+		}
+		b.WriteString(r.FullTextAnnotation.Text)
+		b.WriteString("\n\n")
+	}
+	return b.String(), nil
+}
+
+// call sends a request to a Cloud Vision method and decodes its response
+func (o *visionOCR) call(ctx context.Context, method string, req, resp interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", o.apiBase+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Goog-Api-Key", o.apiKey)
+
+	httpResp, err := o.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("cloud vision API error (status %d): %s", httpResp.StatusCode, string(respBody))
+	}
+	if err := json.Unmarshal(respBody, resp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package vectorfs
+
+import "sync"
+
+// keyedMutex serializes operations per key, e.g. writes to the same file
+// or changes to the references of the same content, while operations on
+// different keys run concurrently. The zero value is ready to use.
+//
+// VectorFS takes at most a file lock and then a content lock, and never
+// two locks of the same keyedMutex, so that locking cannot deadlock.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+// keyedLock is the lock of one key, kept while anyone holds or waits for it
+type keyedLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock locks key; the caller must call unlock with the same key
+func (m *keyedMutex) lock(key string) {
+	m.mu.Lock()
+	if m.locks == nil {
+		m.locks = make(map[string]*keyedLock)
+	}
+	l := m.locks[key]
+	if l == nil {
+		l = &keyedLock{}
+		m.locks[key] = l
+	}
+	l.refs++
+	m.mu.Unlock()
+
+	l.Lock()
+}
+
+// unlock unlocks key
+func (m *keyedMutex) unlock(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l := m.locks[key]
+	l.Unlock()
+	l.refs--
+	if l.refs == 0 {
+		delete(m.locks, key)
+	}
+}
+
+// lockKey is the key of a file or content of a namespace
+func lockKey(namespace, name string) string {
+	return namespace + "/" + name
+}
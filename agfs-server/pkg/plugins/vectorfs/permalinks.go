@@ -0,0 +1,117 @@
+package vectorfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+)
+
+// permalinkDir is the directory of a namespace serving chunks by their
+// stable IDs, so that answers can cite them: <namespace>/chunks/<id>
+const permalinkDir = "chunks"
+
+// chunkIDLen is the length of stable chunk IDs in hex digits
+const chunkIDLen = 16
+
+// chunkID returns the stable ID of a chunk: a hash of its text, so that it
+// survives reindexes, renames and edits of other parts of its document
+func chunkID(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])[:chunkIDLen]
+}
+
+// isChunkID checks if a name is a stable chunk ID
+func isChunkID(name string) bool {
+	if len(name) != chunkIDLen {
+		return false
+	}
+	for _, c := range name {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// permalinkPath returns the path of the permalink of a chunk in a namespace
+func (v *VectorFSPlugin) permalinkPath(namespace, id string) string {
+	return strings.TrimSuffix(v.mountPath, "/") + "/" + namespace + "/" + permalinkDir + "/" + id
+}
+
+// readPermalink returns the permalink file of a chunk: where it is found,
+// then its text
+func (vfs *vectorFS) readPermalink(namespace, id string) ([]byte, time.Time, error) {
+	if !isChunkID(id) {
+		return nil, time.Time{}, filesystem.ErrNotFound
+	}
+	refs, err := vfs.plugin.tidbClient.GetChunksByID(namespace, id)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if len(refs) == 0 {
+		return nil, time.Time{}, filesystem.ErrNotFound
+	}
+	return []byte(formatPermalink(id, refs)), latestUpdate(refs), nil
+}
+
+// formatPermalink formats the permalink file of a chunk found in refs
+func formatPermalink(id string, refs []ChunkRef) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "id: %s\n", id)
+	for _, ref := range refs {
+		fmt.Fprintf(&b, "source: docs/%s (chunk %d)\n", ref.FileName, ref.ChunkIndex)
+	}
+	if refs[0].Language != "" {
+		fmt.Fprintf(&b, "language: %s\n", refs[0].Language)
+	}
+	fmt.Fprintf(&b, "\n%s\n", refs[0].ChunkText)
+	return b.String()
+}
+
+// latestUpdate returns the time the latest of the files of refs was updated
+func latestUpdate(refs []ChunkRef) time.Time {
+	var latest time.Time
+	for _, ref := range refs {
+		if ref.UpdatedAt.After(latest) {
+			latest = ref.UpdatedAt
+		}
+	}
+	return latest
+}
+
+// statPermalink stats the permalink directory, or the permalink of a chunk
+func (vfs *vectorFS) statPermalink(namespace, relativePath string) (*filesystem.FileInfo, error) {
+	id := strings.TrimPrefix(strings.TrimPrefix(relativePath, permalinkDir), "/")
+	if id == "" {
+		fi := permalinkDirInfo()
+		return &fi, nil
+	}
+	data, modTime, err := vfs.readPermalink(namespace, id)
+	if err != nil {
+		return nil, err
+	}
+	return &filesystem.FileInfo{
+		Name:    id,
+		Size:    int64(len(data)),
+		Mode:    0444,
+		ModTime: modTime,
+		IsDir:   false,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "chunk"},
+	}, nil
+}
+
+// permalinkDirInfo returns the file info of the permalink directory, which
+// is not listed: chunks are only read by their IDs
+func permalinkDirInfo() filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    permalinkDir,
+		Mode:    0555,
+		ModTime: time.Now(),
+		IsDir:   true,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "permalinks"},
+	}
+}
@@ -0,0 +1,166 @@
+package vectorfs
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+)
+
+// binarySniffLen is how much of a document is checked for binary content
+const binarySniffLen = 8 << 10
+
+// writePolicy decides which documents written to docs/ are accepted, so that
+// binary blobs written by accident are rejected with a useful error instead
+// of being indexed into garbage chunks at the cost of embedding calls
+type writePolicy struct {
+	maxSize      int64    // Bytes of a document, 0 for no limit
+	extensions   []string // Allowed extensions, lower case with their dot; empty allows any
+	contentTypes []string // Allowed detected MIME types, e.g. "text/*"; empty allows any
+	rejectBinary bool     // Reject content that is not text, unless it is recognized by OCR
+}
+
+// newWritePolicy returns the write policy of a config: max_document_size,
+// allowed_extensions, allowed_content_types and reject_binary (default true)
+func newWritePolicy(cfg map[string]interface{}) (*writePolicy, error) {
+	p := &writePolicy{}
+	var err error
+	if p.maxSize, err = config.GetSizeConfig(cfg, "max_document_size", 0); err != nil {
+		return nil, err
+	}
+	if p.maxSize < 0 {
+		return nil, fmt.Errorf("max_document_size must not be negative")
+	}
+	if err := config.ValidateBoolType(cfg, "reject_binary"); err != nil {
+		return nil, err
+	}
+	p.rejectBinary = config.GetBoolConfig(cfg, "reject_binary", true)
+
+	if p.extensions, err = stringList(cfg, "allowed_extensions"); err != nil {
+		return nil, err
+	}
+	for i, ext := range p.extensions {
+		ext = strings.ToLower(ext)
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		p.extensions[i] = ext
+	}
+	if p.contentTypes, err = stringList(cfg, "allowed_content_types"); err != nil {
+		return nil, err
+	}
+	for i, t := range p.contentTypes {
+		if !strings.Contains(t, "/") {
+			return nil, fmt.Errorf("allowed_content_types: invalid MIME type %q (e.g. text/* or application/json)", t)
+		}
+		p.contentTypes[i] = strings.ToLower(t)
+	}
+	return p, nil
+}
+
+// stringList returns a config value that is a list of strings
+func stringList(cfg map[string]interface{}, key string) ([]string, error) {
+	if err := config.ValidateArrayType(cfg, key); err != nil {
+		return nil, err
+	}
+	raw, _ := cfg[key].([]interface{})
+	list := make([]string, 0, len(raw))
+	for _, item := range raw {
+		s, ok := item.(string)
+		if !ok || strings.TrimSpace(s) == "" {
+			return nil, fmt.Errorf("%s must be a list of strings", key)
+		}
+		list = append(list, strings.TrimSpace(s))
+	}
+	return list, nil
+}
+
+// check checks a document written to path, ocr telling whether its content
+// is an image or scanned PDF that OCR will recognize
+func (p *writePolicy) check(filePath string, data []byte, ocr bool) error {
+	if p == nil {
+		return nil
+	}
+	if p.maxSize > 0 && int64(len(data)) > p.maxSize {
+		return filesystem.NewQuotaExceededError("write", filePath, "max_document_size", int64(len(data)), p.maxSize)
+	}
+
+	if len(p.extensions) > 0 {
+		ext := strings.ToLower(path.Ext(filePath))
+		if !contains(p.extensions, ext) {
+			if ext == "" {
+				ext = "no extension"
+			}
+			return filesystem.NewInvalidArgumentError("write", filePath,
+				fmt.Sprintf("documents with %s are not allowed (allowed_extensions: %s)", ext, strings.Join(p.extensions, ", ")))
+		}
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+	if len(p.contentTypes) > 0 {
+		detected, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+		if !p.allowsType(detected) {
+			return filesystem.NewInvalidArgumentError("write", filePath,
+				fmt.Sprintf("content of type %s is not allowed (allowed_content_types: %s)", detected, strings.Join(p.contentTypes, ", ")))
+		}
+	}
+	if p.rejectBinary && !ocr {
+		if reason := binaryReason(data); reason != "" {
+			return filesystem.NewInvalidArgumentError("write", filePath,
+				"binary content ("+reason+"): only text documents are indexed (set reject_binary = false to index it anyway)")
+		}
+	}
+	return nil
+}
+
+// allowsType checks a detected MIME type against the allowed ones
+func (p *writePolicy) allowsType(detected string) bool {
+	for _, allowed := range p.contentTypes {
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok {
+			if strings.HasPrefix(detected, prefix+"/") {
+				return true
+			}
+		} else if allowed == detected {
+			return true
+		}
+	}
+	return false
+}
+
+// binaryReason returns why the start of data looks binary, "" if it looks
+// like text: a NUL byte, or bytes that are not UTF-8
+func binaryReason(data []byte) string {
+	sample := data[:min(len(data), binarySniffLen)]
+	if i := bytes.IndexByte(sample, 0); i >= 0 {
+		return fmt.Sprintf("NUL byte at offset %d", i)
+	}
+	for i := 0; i < len(sample); {
+		r, size := utf8.DecodeRune(sample[i:])
+		if r == utf8.RuneError && size == 1 {
+			// A rune cut at the end of the sample is fine
+			if len(sample) < len(data) && len(sample)-i < utf8.UTFMax && !utf8.FullRune(sample[i:]) {
+				break
+			}
+			return fmt.Sprintf("invalid UTF-8 at offset %d", i)
+		}
+		i += size
+	}
+	return ""
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,260 @@
+package vectorfs
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/mountablefs"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+// reindexFile is the control file of a namespace starting a reindex when
+// written to, and returning the state of the last one when read
+const reindexFile = ".reindex"
+
+// ReindexTask re-chunks and re-embeds all documents of a namespace, e.g.
+// after changing chunk_size or embedding_model. Its args are the path of the
+// namespace and optionally rate, the embedding requests per second.
+const ReindexTask = "reindex"
+
+// defaultReindexRate is the default of reindex_rate, in embedding requests
+// per second
+const defaultReindexRate = 5.0
+
+// SetParentFileSystem gives the plugin access to the task framework of the
+// AGFS tree, which runs reindexes started through .reindex
+func (v *VectorFSPlugin) SetParentFileSystem(fs filesystem.FileSystem) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.rootFS = fs
+}
+
+// Tasks implements plugin.TaskRunner
+func (v *VectorFSPlugin) Tasks() []string {
+	return []string{ReindexTask}
+}
+
+// RunTask implements plugin.TaskRunner
+func (v *VectorFSPlugin) RunTask(ctx context.Context, task string, args map[string]string, progress plugin.TaskProgress) error {
+	if task != ReindexTask {
+		return fmt.Errorf("unknown task: %s", task)
+	}
+	namespace, _, err := parsePath(args["path"])
+	if err != nil {
+		return err
+	}
+	if namespace == "" {
+		return fmt.Errorf("reindex needs the path of a namespace")
+	}
+	limit := v.reindexRate
+	if s, ok := args["rate"]; ok {
+		if limit, err = strconv.ParseFloat(s, 64); err != nil {
+			return fmt.Errorf("invalid rate %q: must be embedding requests per second", s)
+		}
+	}
+	exists, err := v.tidbClient.NamespaceExists(namespace)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("namespace not found: %s", namespace)
+	}
+
+	v.reindexMu.Lock()
+	if v.reindexing[namespace] {
+		v.reindexMu.Unlock()
+		return fmt.Errorf("reindex of namespace %s already running", namespace)
+	}
+	v.reindexing[namespace] = true
+	v.transferWg.Add(1)
+	v.reindexMu.Unlock()
+	defer func() {
+		v.reindexMu.Lock()
+		delete(v.reindexing, namespace)
+		v.reindexMu.Unlock()
+		v.transferWg.Done()
+	}()
+
+	return v.reindex(ctx, namespace, newRateLimiter(limit), progress)
+}
+
+// newRateLimiter returns a limiter of perSecond events per second, without
+// limit if perSecond is not positive
+func newRateLimiter(perSecond float64) *rate.Limiter {
+	if perSecond <= 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+	return rate.NewLimiter(rate.Limit(perSecond), 1)
+}
+
+// reindex re-chunks and re-embeds each content of a namespace with the
+// current chunking and embedding configuration. The chunks of a content are
+// replaced at once, so searches keep working while the reindex runs. A
+// content that fails is skipped, and the reindex fails once the others are
+// done.
+func (v *VectorFSPlugin) reindex(ctx context.Context, namespace string, limiter *rate.Limiter, progress plugin.TaskProgress) error {
+	files, err := v.tidbClient.ListFiles(namespace)
+	if err != nil {
+		return fmt.Errorf("failed to list files: %w", err)
+	}
+
+	// Files with the same content share its chunks
+	contents := make(map[string]FileMetadata)
+	for _, meta := range files {
+		if _, ok := contents[meta.FileDigest]; !ok {
+			contents[meta.FileDigest] = meta
+		}
+	}
+	digests := make([]string, 0, len(contents))
+	for digest := range contents {
+		digests = append(digests, digest)
+	}
+	sort.Strings(digests)
+
+	total := int64(len(digests))
+	log.Infof("[vectorfs] Reindexing %d content(s) of namespace %s", total, namespace)
+
+	var failed, chunks int
+	var lastErr error
+	for i, digest := range digests {
+		if v.shuttingDown() {
+			return fmt.Errorf("interrupted by shutdown after %d of %d contents", i, total)
+		}
+		meta := contents[digest]
+		progress(int64(i), total, "reindexing "+meta.FileName)
+
+		n, err := v.reindexContent(ctx, namespace, meta, limiter)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			log.Warnf("[vectorfs] Failed to reindex %s in namespace %s: %v", meta.FileName, namespace, err)
+			failed++
+			lastErr = err
+			continue
+		}
+		chunks += n
+	}
+
+	summary := fmt.Sprintf("reindexed %d content(s) into %d chunks", total-int64(failed), chunks)
+	progress(total, total, summary)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d content(s) failed to reindex, last error: %w", failed, total, lastErr)
+	}
+	log.Infof("[vectorfs] Namespace %s %s", namespace, summary)
+	return nil
+}
+
+// reindexContent replaces the chunks of one content, returning their number
+func (v *VectorFSPlugin) reindexContent(ctx context.Context, namespace string, meta FileMetadata, limiter *rate.Limiter) (int, error) {
+	data, err := v.s3Client.DownloadDocument(ctx, namespace, meta.FileDigest)
+	if err != nil {
+		return 0, fmt.Errorf("failed to download document from S3: %w", err)
+	}
+	content := string(data)
+	if v.ocr != nil {
+		if kind := ocrKind(data); kind != "" {
+			if content, err = v.ocr.recognize(ctx, kind, data); err != nil {
+				return 0, fmt.Errorf("failed to recognize text: %w", err)
+			}
+		}
+	}
+	language := meta.Language
+	if language == "" {
+		language = detectLanguage(content)
+	}
+
+	if err := limiter.Wait(ctx); err != nil {
+		return 0, err
+	}
+	chunks, err := v.indexer.EmbedChunks(namespace, meta.FileDigest, meta.FileName, content, language)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := v.indexer.ReplaceChunks(namespace, meta.FileDigest, meta.FileName, chunks); err != nil {
+		return 0, err
+	}
+	return len(chunks), nil
+}
+
+// tasks returns the task framework of the AGFS tree the plugin is mounted in
+func (v *VectorFSPlugin) tasks() (*mountablefs.MountableFS, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	mfs, ok := v.rootFS.(*mountablefs.MountableFS)
+	if !ok {
+		return nil, filesystem.NewNotSupportedError("reindex", v.mountPath)
+	}
+	return mfs, nil
+}
+
+// parseReindexArgs parses what is written to .reindex: nothing, or
+// space-separated key=value task args such as "rate=2"
+func parseReindexArgs(data string) (map[string]string, error) {
+	args := make(map[string]string)
+	for _, field := range strings.Fields(data) {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || key != "rate" {
+			return nil, filesystem.NewInvalidArgumentError(reindexFile, field, "expected rate=<embedding requests per second>")
+		}
+		args[key] = value
+	}
+	return args, nil
+}
+
+// StartReindex starts a reindex task for a namespace
+func (v *VectorFSPlugin) StartReindex(namespace string, args map[string]string) (mountablefs.TaskInfo, error) {
+	mfs, err := v.tasks()
+	if err != nil {
+		return mountablefs.TaskInfo{}, err
+	}
+	return mfs.StartTask(path.Join(v.mountPath, namespace), ReindexTask, args)
+}
+
+// getReindexStatus returns the state of the last reindex of a namespace
+func (v *VectorFSPlugin) getReindexStatus(namespace string) string {
+	mfs, err := v.tasks()
+	if err != nil {
+		return "idle\n"
+	}
+	var last *mountablefs.TaskInfo
+	for _, t := range mfs.ListTasks() {
+		if t.Mount != v.mountPath || t.Task != ReindexTask {
+			continue
+		}
+		if ns, _, _ := parsePath(t.Args["path"]); ns == namespace {
+			t := t
+			last = &t
+		}
+	}
+	if last == nil {
+		return "idle\n"
+	}
+	return formatReindexStatus(*last)
+}
+
+func formatReindexStatus(t mountablefs.TaskInfo) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "state: %s\n", t.Status)
+	fmt.Fprintf(&b, "task: %d\n", t.ID)
+	fmt.Fprintf(&b, "progress: %d/%d\n", t.Done, t.Total)
+	if t.Message != "" {
+		fmt.Fprintf(&b, "message: %s\n", t.Message)
+	}
+	fmt.Fprintf(&b, "started: %s\n", t.StartedAt.Format(time.RFC3339))
+	if t.FinishedAt != nil {
+		fmt.Fprintf(&b, "finished: %s\n", t.FinishedAt.Format(time.RFC3339))
+	}
+	if t.Error != "" {
+		fmt.Fprintf(&b, "error: %s\n", t.Error)
+	}
+	return b.String()
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -80,6 +81,21 @@ func NewS3Client(cfg S3Config) (*S3Client, error) {
 	}, nil
 }
 
+// CheckBucket checks that the bucket exists and the credentials can access it
+// Uses ListObjectsV2 rather than HeadBucket, which some S3-compatible services
+// don't support
+func (c *S3Client) CheckBucket(ctx context.Context) error {
+	_, err := c.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(c.bucket),
+		Prefix:  aws.String(c.keyPrefix + "/"),
+		MaxKeys: aws.Int32(1),
+	})
+	if err != nil {
+		return fmt.Errorf("cannot access bucket %s: %w", c.bucket, err)
+	}
+	return nil
+}
+
 // buildKey constructs the S3 key: keyPrefix/namespace/digest
 func (c *S3Client) buildKey(namespace, digest string) string {
 	return fmt.Sprintf("%s/%s/%s", c.keyPrefix, namespace, digest)
@@ -143,6 +159,95 @@ func (c *S3Client) DocumentExists(ctx context.Context, namespace, digest string)
 	return true, nil
 }
 
+// objectLocation resolves an object location given as "s3://bucket/key" or
+// as a key in the bucket of the client
+func (c *S3Client) objectLocation(location string) (bucket, key string, err error) {
+	bucket, key = c.bucket, strings.TrimPrefix(location, "/")
+	if rest, ok := strings.CutPrefix(location, "s3://"); ok {
+		bucket, key, _ = strings.Cut(rest, "/")
+	}
+	if bucket == "" || key == "" {
+		return "", "", fmt.Errorf("invalid object location %q, expected s3://bucket/key or a key", location)
+	}
+	return bucket, key, nil
+}
+
+// UploadObject uploads an object to a location (see objectLocation)
+func (c *S3Client) UploadObject(ctx context.Context, location string, body io.ReadSeeker) error {
+	bucket, key, err := c.objectLocation(location)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   body,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload to S3: %w", err)
+	}
+
+	log.Debugf("[vectorfs/s3] Uploaded object: s3://%s/%s", bucket, key)
+	return nil
+}
+
+// OpenObject opens an object at a location (see objectLocation) for reading
+func (c *S3Client) OpenObject(ctx context.Context, location string) (io.ReadCloser, error) {
+	bucket, key, err := c.objectLocation(location)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := c.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download from S3: %w", err)
+	}
+	return result.Body, nil
+}
+
+// s3Object is an object listed by ListObjects
+type s3Object struct {
+	Key  string
+	ETag string
+	Size int64
+}
+
+// ListObjects lists the objects under a prefix, given as "s3://bucket/prefix"
+// or as a prefix in the bucket of the client; it returns their bucket
+func (c *S3Client) ListObjects(ctx context.Context, location string) (string, []s3Object, error) {
+	bucket, prefix := c.bucket, strings.TrimPrefix(location, "/")
+	if rest, ok := strings.CutPrefix(location, "s3://"); ok {
+		bucket, prefix, _ = strings.Cut(rest, "/")
+	}
+	if bucket == "" {
+		return "", nil, fmt.Errorf("invalid location %q, expected s3://bucket/prefix or a prefix", location)
+	}
+
+	var objects []s3Object
+	paginator := s3.NewListObjectsV2Paginator(c.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to list s3://%s/%s: %w", bucket, prefix, err)
+		}
+		for _, obj := range page.Contents {
+			objects = append(objects, s3Object{
+				Key:  aws.ToString(obj.Key),
+				ETag: strings.Trim(aws.ToString(obj.ETag), `"`),
+				Size: aws.ToInt64(obj.Size),
+			})
+		}
+	}
+	return bucket, objects, nil
+}
+
 // DeleteDocument deletes a document from S3
 func (c *S3Client) DeleteDocument(ctx context.Context, namespace, digest string) error {
 	key := c.buildKey(namespace, digest)
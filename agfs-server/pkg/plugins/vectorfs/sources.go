@@ -0,0 +1,758 @@
+package vectorfs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	"github.com/robfig/cron/v3"
+	log "github.com/sirupsen/logrus"
+)
+
+// sourcesFile is the control file of a namespace showing the status of its
+// sources; writing to it syncs them right away
+const sourcesFile = ".sources"
+
+// defaultSourceSchedule is the refresh schedule of sources without one
+const defaultSourceSchedule = "@every 1h"
+
+// sourceScheduleParser accepts standard 5-field expressions plus descriptors
+// like @hourly and @every 30m, like cronfs
+var sourceScheduleParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// sourceConfig configures a source of a namespace, whose documents are
+// synced into docs/<dir>/
+type sourceConfig struct {
+	Name     string
+	Type     string // s3, git or urls
+	Schedule string
+	Dir      string // Directory of the documents under docs/ (default: Name)
+	Delete   bool   // Remove the documents that disappear from the source
+
+	Location string   // s3: "s3://bucket/prefix" or a prefix in the plugin's bucket
+	Repo     string   // git: URL of the repository
+	Branch   string   // git: branch, default branch of the repository if empty
+	Path     string   // git: subdirectory of the repository
+	URLs     []string // urls: pages
+	Sitemap  string   // urls: sitemap listing pages (a sitemap index is followed)
+}
+
+// sourceKeys are the keys of a source configuration
+var sourceKeys = map[string]bool{
+	"name": true, "type": true, "schedule": true, "dir": true, "delete": true,
+	"location": true, "repo": true, "branch": true, "path": true, "urls": true, "sitemap": true,
+}
+
+// sourcesFromConfig returns the sources configuration: namespace -> sources
+// of its documents
+func sourcesFromConfig(cfg map[string]interface{}) (map[string][]sourceConfig, error) {
+	if err := config.ValidateMapType(cfg, "sources"); err != nil {
+		return nil, err
+	}
+	raw, _ := cfg["sources"].(map[string]interface{})
+	sources := make(map[string][]sourceConfig, len(raw))
+	for namespace, v := range raw {
+		if namespace == "" || namespace == "README" || strings.Contains(namespace, "/") {
+			return nil, fmt.Errorf("sources: invalid namespace name %q", namespace)
+		}
+		list, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("sources.%s must be a list of sources", namespace)
+		}
+		names := make(map[string]bool)
+		for i, item := range list {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("sources.%s[%d] must be a table", namespace, i)
+			}
+			src, err := parseSourceConfig(m)
+			if err != nil {
+				return nil, fmt.Errorf("sources.%s[%d]: %w", namespace, i, err)
+			}
+			if names[src.Name] {
+				return nil, fmt.Errorf("sources.%s: duplicate source %q", namespace, src.Name)
+			}
+			names[src.Name] = true
+			sources[namespace] = append(sources[namespace], src)
+		}
+	}
+	return sources, nil
+}
+
+// parseSourceConfig parses the configuration of a source
+func parseSourceConfig(m map[string]interface{}) (sourceConfig, error) {
+	for key := range m {
+		if !sourceKeys[key] {
+			return sourceConfig{}, fmt.Errorf("unknown key %q", key)
+		}
+	}
+	for _, key := range []string{"name", "type", "schedule", "dir", "location", "repo", "branch", "path", "sitemap"} {
+		if _, ok := m[key].(string); m[key] != nil && !ok {
+			return sourceConfig{}, fmt.Errorf("%s must be a string", key)
+		}
+	}
+	if err := config.ValidateBoolType(m, "delete"); err != nil {
+		return sourceConfig{}, err
+	}
+	urls, err := stringList(m, "urls")
+	if err != nil {
+		return sourceConfig{}, err
+	}
+
+	src := sourceConfig{
+		Name:     config.GetStringConfig(m, "name", ""),
+		Type:     config.GetStringConfig(m, "type", ""),
+		Schedule: config.GetStringConfig(m, "schedule", defaultSourceSchedule),
+		Dir:      strings.Trim(config.GetStringConfig(m, "dir", ""), "/"),
+		Delete:   config.GetBoolConfig(m, "delete", true),
+		Location: config.GetStringConfig(m, "location", ""),
+		Repo:     config.GetStringConfig(m, "repo", ""),
+		Branch:   config.GetStringConfig(m, "branch", ""),
+		Path:     strings.Trim(config.GetStringConfig(m, "path", ""), "/"),
+		URLs:     urls,
+		Sitemap:  config.GetStringConfig(m, "sitemap", ""),
+	}
+	if src.Name == "" || strings.Contains(src.Name, "/") {
+		return src, fmt.Errorf("invalid source name %q", src.Name)
+	}
+	if src.Dir == "" {
+		src.Dir = src.Name
+	}
+	if src.Dir != path.Clean(src.Dir) || strings.HasPrefix(src.Dir, ".") {
+		return src, fmt.Errorf("invalid dir %q", src.Dir)
+	}
+	if _, err := sourceScheduleParser.Parse(src.Schedule); err != nil {
+		return src, fmt.Errorf("invalid schedule %q: %w", src.Schedule, err)
+	}
+
+	switch src.Type {
+	case "s3":
+		if src.Location == "" {
+			return src, fmt.Errorf("location is required for s3 sources")
+		}
+	case "git":
+		if src.Repo == "" {
+			return src, fmt.Errorf("repo is required for git sources")
+		}
+	case "urls":
+		if len(src.URLs) == 0 && src.Sitemap == "" {
+			return src, fmt.Errorf("urls or sitemap is required for urls sources")
+		}
+		for _, u := range append([]string{src.Sitemap}, src.URLs...) {
+			if u != "" && !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+				return src, fmt.Errorf("invalid URL %q", u)
+			}
+		}
+	default:
+		return src, fmt.Errorf("unsupported source type %q (supported: s3, git, urls)", src.Type)
+	}
+	return src, nil
+}
+
+// sourceDocument is a document of a source
+type sourceDocument struct {
+	name     string // Path under the directory of the source
+	location string // Where the connector fetches it from
+	version  string // Changes with the content (ETag, blob hash, lastmod), "" if unknown
+}
+
+// sourceConnector lists and fetches the documents of a source
+type sourceConnector interface {
+	list(ctx context.Context) ([]sourceDocument, error)
+	fetch(ctx context.Context, doc sourceDocument) ([]byte, error)
+}
+
+// documentStore is where sources sync their documents to: the documents of
+// the plugin, under docs/ of a namespace
+type documentStore interface {
+	prepare(namespace string) error                // Creates the namespace if needed
+	list(namespace, dir string) ([]string, error)  // Names of the documents under dir
+	put(namespace, name string, data []byte) error // Writes and indexes a document
+	remove(namespace, name string) error           // Removes a document
+}
+
+// sourceStats counts what the last sync of a source did
+type sourceStats struct {
+	documents, added, updated, removed, unchanged, skipped, failed int
+}
+
+// source is a configured source of a namespace with its sync state
+type source struct {
+	namespace string
+	cfg       sourceConfig
+	conn      sourceConnector
+	entry     cron.EntryID
+
+	syncMu sync.Mutex        // Serializes syncs
+	synced map[string]string // Document name -> version synced, nil until the first sync
+
+	mu       sync.Mutex
+	running  bool
+	started  time.Time
+	finished time.Time
+	stats    sourceStats
+	err      error
+}
+
+// sync pulls the new and changed documents of the source into the store, and
+// removes those that disappeared from it if configured to
+func (s *source) sync(ctx context.Context, store documentStore) error {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	if err := store.prepare(s.namespace); err != nil {
+		return err
+	}
+	// After a restart, what the source synced before is known by name only,
+	// so that documents that disappeared meanwhile are still removed
+	if s.synced == nil {
+		names, err := store.list(s.namespace, s.cfg.Dir)
+		if err != nil {
+			return err
+		}
+		s.synced = make(map[string]string, len(names))
+		for _, name := range names {
+			s.synced[name] = ""
+		}
+	}
+
+	docs, err := s.conn.list(ctx)
+	if err != nil {
+		return err
+	}
+
+	var stats sourceStats
+	var firstErr error
+	listed := make(map[string]bool, len(docs))
+	for _, doc := range docs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if listed[doc.name] {
+			continue
+		}
+		listed[doc.name] = true
+		stats.documents++
+
+		last, known := s.synced[doc.name]
+		if doc.version != "" && last == doc.version {
+			stats.unchanged++
+			continue
+		}
+		data, err := s.conn.fetch(ctx, doc)
+		if err != nil {
+			stats.failed++
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %w", doc.name, err)
+			}
+			continue
+		}
+		version := doc.version
+		if version == "" {
+			sum := sha256.Sum256(data)
+			version = "sha256:" + hex.EncodeToString(sum[:])
+			if last == version {
+				stats.unchanged++
+				continue
+			}
+		}
+
+		err = store.put(s.namespace, path.Join(s.cfg.Dir, doc.name), data)
+		switch {
+		case err == nil && known:
+			stats.updated++
+		case err == nil:
+			stats.added++
+		case errors.Is(err, filesystem.ErrInvalidArgument) || errors.Is(err, filesystem.ErrQuotaExceeded):
+			// Rejected by the write policy; tried again once it changes
+			stats.skipped++
+			log.Debugf("[vectorfs] Source %s/%s skipped %s: %v", s.namespace, s.cfg.Name, doc.name, err)
+		default:
+			stats.failed++
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %w", doc.name, err)
+			}
+			continue
+		}
+		s.synced[doc.name] = version
+	}
+
+	if s.cfg.Delete {
+		for name := range s.synced {
+			if listed[name] {
+				continue
+			}
+			err := store.remove(s.namespace, path.Join(s.cfg.Dir, name))
+			if err != nil && !errors.Is(err, filesystem.ErrNotFound) {
+				stats.failed++
+				if firstErr == nil {
+					firstErr = fmt.Errorf("%s: %w", name, err)
+				}
+				continue
+			}
+			delete(s.synced, name)
+			stats.removed++
+		}
+	}
+
+	s.mu.Lock()
+	s.stats = stats
+	s.mu.Unlock()
+	if stats.failed > 0 {
+		return fmt.Errorf("%d document(s) failed, first: %w", stats.failed, firstErr)
+	}
+	return nil
+}
+
+// String returns the status of the source, as shown in .sources
+func (s *source) String(next time.Time) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "name: %s\ntype: %s\nschedule: %s\ndir: docs/%s\n", s.cfg.Name, s.cfg.Type, s.cfg.Schedule, s.cfg.Dir)
+	state := "idle"
+	switch {
+	case s.running:
+		state = "running"
+	case s.finished.IsZero():
+	case s.err != nil:
+		state = "failed"
+	default:
+		state = "done"
+	}
+	fmt.Fprintf(&b, "state: %s\n", state)
+	if !s.started.IsZero() {
+		fmt.Fprintf(&b, "last_sync: %s\n", s.started.Format(time.RFC3339))
+	}
+	if !next.IsZero() {
+		fmt.Fprintf(&b, "next_sync: %s\n", next.Format(time.RFC3339))
+	}
+	if !s.finished.IsZero() {
+		st := s.stats
+		fmt.Fprintf(&b, "documents: %d\nadded: %d\nupdated: %d\nremoved: %d\nunchanged: %d\nskipped: %d\nfailed: %d\n",
+			st.documents, st.added, st.updated, st.removed, st.unchanged, st.skipped, st.failed)
+	}
+	if s.err != nil {
+		fmt.Fprintf(&b, "error: %v\n", s.err)
+	}
+	return b.String()
+}
+
+// newSourceConnector returns the connector of a source
+func (v *VectorFSPlugin) newSourceConnector(cfg sourceConfig) sourceConnector {
+	switch cfg.Type {
+	case "s3":
+		return &s3Source{client: v.s3Client, location: cfg.Location}
+	case "git":
+		sum := sha256.Sum256([]byte(cfg.Repo + "\x00" + cfg.Branch))
+		return &gitSource{
+			repo:   cfg.Repo,
+			branch: cfg.Branch,
+			path:   cfg.Path,
+			dir:    filepath.Join(os.TempDir(), "agfs-vectorfs-sources", hex.EncodeToString(sum[:8])),
+		}
+	default:
+		return &urlSource{urls: cfg.URLs, sitemap: cfg.Sitemap, client: &http.Client{Timeout: time.Minute}}
+	}
+}
+
+// startSources schedules the syncs of the configured sources, and syncs them
+// once right away
+func (v *VectorFSPlugin) startSources(configs map[string][]sourceConfig) error {
+	v.sources = make(map[string][]*source, len(configs))
+	if len(configs) == 0 {
+		return nil
+	}
+	v.sourceCron = cron.New(cron.WithParser(sourceScheduleParser))
+	for namespace, list := range configs {
+		for _, cfg := range list {
+			s := &source{namespace: namespace, cfg: cfg, conn: v.newSourceConnector(cfg)}
+			id, err := v.sourceCron.AddFunc(cfg.Schedule, func() { v.startSourceSync(s) })
+			if err != nil {
+				return fmt.Errorf("source %s/%s: invalid schedule: %w", namespace, cfg.Name, err)
+			}
+			s.entry = id
+			v.sources[namespace] = append(v.sources[namespace], s)
+		}
+	}
+	v.sourceCron.Start()
+	for _, list := range v.sources {
+		for _, s := range list {
+			v.startSourceSync(s)
+		}
+	}
+	return nil
+}
+
+// stopSources stops the schedule of the sources and waits for running syncs,
+// which the shutdown interrupts
+func (v *VectorFSPlugin) stopSources() {
+	if v.sourceCron != nil {
+		<-v.sourceCron.Stop().Done()
+	}
+	// No sync starts once shutting down
+	v.sourcesMu.Lock()
+	v.sourcesMu.Unlock()
+	v.sourceWg.Wait()
+}
+
+// startSourceSync syncs a source in the background, unless it is already
+// being synced
+func (v *VectorFSPlugin) startSourceSync(s *source) {
+	v.sourcesMu.Lock()
+	defer v.sourcesMu.Unlock()
+	if v.shuttingDown() {
+		return
+	}
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.started = time.Now()
+	s.mu.Unlock()
+
+	v.sourceWg.Add(1)
+	go func() {
+		defer v.sourceWg.Done()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			select {
+			case <-v.shutdown:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		err := s.sync(ctx, pluginStore{v})
+		s.mu.Lock()
+		s.running = false
+		s.finished = time.Now()
+		s.err = err
+		stats := s.stats
+		s.mu.Unlock()
+		if err != nil {
+			log.Errorf("[vectorfs] Sync of source %s/%s failed: %v", s.namespace, s.cfg.Name, err)
+			return
+		}
+		log.Infof("[vectorfs] Synced source %s/%s: %d added, %d updated, %d removed",
+			s.namespace, s.cfg.Name, stats.added, stats.updated, stats.removed)
+	}()
+}
+
+// SyncSources syncs the sources of a namespace right away, or only the one
+// named if name is not empty
+func (v *VectorFSPlugin) SyncSources(namespace, name string) error {
+	list := v.sources[namespace]
+	if len(list) == 0 {
+		return fmt.Errorf("namespace %s has no sources", namespace)
+	}
+	found := false
+	for _, s := range list {
+		if name == "" || s.cfg.Name == name {
+			found = true
+			v.startSourceSync(s)
+		}
+	}
+	if !found {
+		return filesystem.NewInvalidArgumentError(sourcesFile, name, "no such source")
+	}
+	return nil
+}
+
+// getSourcesStatus returns the status of the sources of a namespace
+func (v *VectorFSPlugin) getSourcesStatus(namespace string) string {
+	list := v.sources[namespace]
+	if len(list) == 0 {
+		return "no sources\n"
+	}
+	var blocks []string
+	for _, s := range list {
+		var next time.Time
+		if v.sourceCron != nil {
+			next = v.sourceCron.Entry(s.entry).Next
+		}
+		blocks = append(blocks, s.String(next))
+	}
+	return strings.Join(blocks, "\n")
+}
+
+// pluginStore is the documentStore of the plugin: documents are written like
+// any other, so that the write policy applies and they are indexed
+type pluginStore struct {
+	v *VectorFSPlugin
+}
+
+func (p pluginStore) prepare(namespace string) error {
+	exists, err := p.v.tidbClient.NamespaceExists(namespace)
+	if err != nil || exists {
+		return err
+	}
+	log.Infof("[vectorfs] Creating namespace %s for its sources", namespace)
+	return p.v.tidbClient.CreateNamespace(namespace, p.v.embeddingClient.GetDimension())
+}
+
+func (p pluginStore) list(namespace, dir string) ([]string, error) {
+	files, err := p.v.tidbClient.ListFilesWithPrefix(namespace, dir+"/")
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		name := strings.TrimPrefix(f.FileName, dir+"/")
+		if path.Base(name) != ".keep" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func (p pluginStore) put(namespace, name string, data []byte) error {
+	vfs := &vectorFS{plugin: p.v}
+	_, err := vfs.Write("/"+namespace+"/docs/"+name, data, -1, filesystem.WriteFlagCreate|filesystem.WriteFlagTruncate)
+	return err
+}
+
+func (p pluginStore) remove(namespace, name string) error {
+	vfs := &vectorFS{plugin: p.v}
+	return vfs.Remove("/" + namespace + "/docs/" + name)
+}
+
+// s3Source syncs the objects under an S3 prefix; their names are their keys
+// relative to the prefix
+type s3Source struct {
+	client   *S3Client
+	location string
+}
+
+func (s *s3Source) list(ctx context.Context) ([]sourceDocument, error) {
+	bucket, objects, err := s.client.ListObjects(ctx, s.location)
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.TrimPrefix(s.location, "/")
+	if rest, ok := strings.CutPrefix(s.location, "s3://"); ok {
+		_, prefix, _ = strings.Cut(rest, "/")
+	}
+	var docs []sourceDocument
+	for _, obj := range objects {
+		name := strings.TrimPrefix(strings.TrimPrefix(obj.Key, prefix), "/")
+		if name == "" || strings.HasSuffix(obj.Key, "/") {
+			continue // Directory marker
+		}
+		docs = append(docs, sourceDocument{name: name, location: "s3://" + bucket + "/" + obj.Key, version: obj.ETag})
+	}
+	return docs, nil
+}
+
+func (s *s3Source) fetch(ctx context.Context, doc sourceDocument) ([]byte, error) {
+	body, err := s.client.OpenObject(ctx, doc.location)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// gitSource syncs the files of a git repository, from a shallow clone kept
+// in a local directory; their versions are their blob hashes
+type gitSource struct {
+	repo   string
+	branch string
+	path   string // Subdirectory synced, the whole repository if empty
+	dir    string // Local clone
+}
+
+// git runs a git command
+func (g *gitSource) git(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// update clones the repository, or fetches its latest commit
+func (g *gitSource) update(ctx context.Context) error {
+	if _, err := os.Stat(filepath.Join(g.dir, ".git")); err != nil {
+		os.RemoveAll(g.dir)
+		if err := os.MkdirAll(filepath.Dir(g.dir), 0755); err != nil {
+			return err
+		}
+		args := []string{"clone", "--depth", "1", "--quiet"}
+		if g.branch != "" {
+			args = append(args, "--branch", g.branch)
+		}
+		_, err := g.git(ctx, append(args, "--", g.repo, g.dir)...)
+		return err
+	}
+	ref := g.branch
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if _, err := g.git(ctx, "-C", g.dir, "fetch", "--depth", "1", "--quiet", "origin", ref); err != nil {
+		return err
+	}
+	_, err := g.git(ctx, "-C", g.dir, "reset", "--hard", "--quiet", "FETCH_HEAD")
+	return err
+}
+
+func (g *gitSource) list(ctx context.Context) ([]sourceDocument, error) {
+	if err := g.update(ctx); err != nil {
+		return nil, err
+	}
+	args := []string{"-C", g.dir, "ls-files", "-s", "-z"}
+	if g.path != "" {
+		args = append(args, "--", g.path)
+	}
+	out, err := g.git(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// "<mode> <hash> <stage>\t<path>" entries
+	var docs []sourceDocument
+	for _, entry := range strings.Split(string(out), "\x00") {
+		meta, file, ok := strings.Cut(entry, "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) != 3 || fields[0] != "100644" && fields[0] != "100755" {
+			continue // Symlinks and submodules
+		}
+		name := file
+		if g.path != "" {
+			name = strings.TrimPrefix(file, g.path+"/")
+		}
+		docs = append(docs, sourceDocument{name: name, location: file, version: fields[1]})
+	}
+	return docs, nil
+}
+
+func (g *gitSource) fetch(ctx context.Context, doc sourceDocument) ([]byte, error) {
+	return os.ReadFile(filepath.Join(g.dir, filepath.FromSlash(doc.location)))
+}
+
+// urlSource syncs web pages, listed or found in a sitemap; their names are
+// their hosts and paths, e.g. example.com/guide/index.html
+type urlSource struct {
+	urls    []string
+	sitemap string
+	client  *http.Client
+}
+
+// maxSitemapDepth bounds the sitemap indexes followed
+const maxSitemapDepth = 2
+
+// sitemap is a sitemap or a sitemap index
+type sitemap struct {
+	URLs []struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// urlDocumentName returns the name of the document of a page
+func urlDocumentName(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid URL %q", rawURL)
+	}
+	p := u.Path
+	if p == "" || strings.HasSuffix(p, "/") {
+		p += "index.html"
+	}
+	return strings.TrimPrefix(path.Join(u.Host, path.Clean("/"+p)), "/"), nil
+}
+
+func (s *urlSource) list(ctx context.Context) ([]sourceDocument, error) {
+	var docs []sourceDocument
+	add := func(loc, version string) {
+		name, err := urlDocumentName(loc)
+		if err != nil {
+			log.Warnf("[vectorfs] Skipping page: %v", err)
+			return
+		}
+		docs = append(docs, sourceDocument{name: name, location: loc, version: version})
+	}
+	for _, u := range s.urls {
+		add(u, "")
+	}
+	if s.sitemap != "" {
+		if err := s.readSitemap(ctx, s.sitemap, 0, add); err != nil {
+			return nil, err
+		}
+	}
+	return docs, nil
+}
+
+// readSitemap adds the pages of a sitemap, following sitemap indexes
+func (s *urlSource) readSitemap(ctx context.Context, loc string, depth int, add func(loc, version string)) error {
+	data, err := s.get(ctx, loc)
+	if err != nil {
+		return fmt.Errorf("sitemap %s: %w", loc, err)
+	}
+	var sm sitemap
+	if err := xml.Unmarshal(data, &sm); err != nil {
+		return fmt.Errorf("sitemap %s: %w", loc, err)
+	}
+	for _, u := range sm.URLs {
+		if u.Loc = strings.TrimSpace(u.Loc); u.Loc != "" {
+			add(u.Loc, strings.TrimSpace(u.LastMod))
+		}
+	}
+	for _, child := range sm.Sitemaps {
+		if depth+1 >= maxSitemapDepth {
+			return fmt.Errorf("sitemap %s: sitemap indexes nested too deep", loc)
+		}
+		if err := s.readSitemap(ctx, strings.TrimSpace(child.Loc), depth+1, add); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// get downloads a URL
+func (s *urlSource) get(ctx context.Context, loc string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, loc, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (s *urlSource) fetch(ctx context.Context, doc sourceDocument) ([]byte, error) {
+	return s.get(ctx, doc.location)
+}
@@ -0,0 +1,227 @@
+package vectorfs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/plugins/llmfs"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// summariesDir is the virtual directory of document summaries in docs/
+	summariesDir = ".summaries"
+
+	// summaryExt is appended to document names to name their summary
+	summaryExt = ".md"
+
+	// summaryInputLimit is how much of a document, in bytes, is sent to the
+	// model to summarize it
+	summaryInputLimit = 32 * 1024
+
+	// summaryTimeout bounds a summary request
+	summaryTimeout = 2 * time.Minute
+)
+
+// summaryPrompt is the system prompt of summary requests
+const summaryPrompt = `You summarize documents so that readers can decide whether to read them.
+Write a concise Markdown summary of the document: one sentence on what it is, then its key points as a short bullet list.
+Write in the language of the document. Output only the summary.`
+
+// summarizer generates document summaries with an LLM
+type summarizer struct {
+	provider  llmfs.Provider
+	model     string
+	maxTokens int
+}
+
+// newSummarizer creates the summarizer configured by the summary_* options,
+// or returns nil if summaries are disabled
+func newSummarizer(cfg map[string]interface{}) (*summarizer, error) {
+	if !config.GetBoolConfig(cfg, "summary_enabled", false) {
+		return nil, nil
+	}
+
+	providerType := config.GetStringConfig(cfg, "summary_provider", "openai")
+	apiKey := config.GetStringConfig(cfg, "summary_api_key", "")
+	if apiKey == "" && providerType == "openai" {
+		apiKey = config.GetStringConfig(cfg, "openai_api_key", "")
+	}
+	provider, err := llmfs.NewProvider(llmfs.ProviderConfig{
+		Type:    providerType,
+		APIKey:  apiKey,
+		APIBase: config.GetStringConfig(cfg, "summary_api_base", ""),
+	}, &http.Client{Timeout: summaryTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("summary provider: %w", err)
+	}
+
+	return &summarizer{
+		provider:  provider,
+		model:     config.GetStringConfig(cfg, "summary_model", "gpt-4o-mini"),
+		maxTokens: config.GetIntConfig(cfg, "summary_max_tokens", 400),
+	}, nil
+}
+
+// summarize returns a summary of a document
+func (s *summarizer) summarize(fileName, content string) (string, error) {
+	if len(content) > summaryInputLimit {
+		content = strings.ToValidUTF8(content[:summaryInputLimit], "") + "\n\n[truncated]"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), summaryTimeout)
+	defer cancel()
+	resp, err := s.provider.Chat(ctx, llmfs.ChatRequest{
+		Model:     s.model,
+		System:    summaryPrompt,
+		Messages:  []llmfs.Message{{Role: "user", Content: "Document: " + fileName + "\n\n" + content}},
+		MaxTokens: s.maxTokens,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	summary := strings.TrimSpace(resp.Content)
+	if summary == "" {
+		return "", fmt.Errorf("model returned an empty summary")
+	}
+	log.Debugf("[vectorfs] Summarized %s (%d tokens)", fileName, resp.Usage.TotalTokens)
+	return summary + "\n", nil
+}
+
+// summarizeTask generates the summary of a queued document, unless its
+// content already has one
+func (v *VectorFSPlugin) summarizeTask(worker int, task indexTask) {
+	if strings.TrimSpace(task.data) == "" {
+		return
+	}
+	if exists, err := v.tidbClient.HasSummary(task.namespace, task.digest); err != nil || exists {
+		return
+	}
+
+	summary, err := v.summarizer.summarize(task.fileName, task.data)
+	if err != nil {
+		log.Errorf("[vectorfs] Worker %d failed to summarize %s: %v", worker, task.fileName, err)
+		return
+	}
+	if _, err := v.indexer.StoreSummary(task.namespace, task.digest, task.fileName, summary, v.summarizer.model); err != nil {
+		log.Errorf("[vectorfs] Worker %d failed to store summary of %s: %v", worker, task.fileName, err)
+	}
+}
+
+// summaryFileName returns the document name of a path relative to
+// docs/.summaries, e.g. "guides/k8s.txt" for "guides/k8s.txt.md"
+func summaryFileName(path string) (string, bool) {
+	fileName := strings.TrimSuffix(path, summaryExt)
+	return fileName, fileName != path && fileName != "" && !strings.HasSuffix(fileName, "/")
+}
+
+// isSummaryPath checks if a path relative to a namespace is in docs/.summaries
+func isSummaryPath(relativePath string) bool {
+	return relativePath == "docs/"+summariesDir || strings.HasPrefix(relativePath, "docs/"+summariesDir+"/")
+}
+
+// readSummaryDir lists docs/.summaries or one of its subdirectories, which
+// mirror docs/ with a summary per document
+func (vfs *vectorFS) readSummaryDir(namespace, relativePath string) ([]filesystem.FileInfo, error) {
+	if vfs.plugin.summarizer == nil {
+		return nil, filesystem.ErrNotFound
+	}
+	var subPrefix string
+	if relativePath != "docs/"+summariesDir {
+		subPrefix = strings.TrimPrefix(relativePath, "docs/"+summariesDir+"/") + "/"
+	}
+
+	summaries, err := vfs.plugin.tidbClient.ListSummariesWithPrefix(namespace, subPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	seenDirs := make(map[string]bool)
+	var fileInfos []filesystem.FileInfo
+	for _, s := range summaries {
+		name := strings.TrimPrefix(s.FileName, subPrefix)
+		if i := strings.Index(name, "/"); i != -1 {
+			if dirName := name[:i]; !seenDirs[dirName] {
+				seenDirs[dirName] = true
+				fileInfos = append(fileInfos, filesystem.FileInfo{
+					Name:    dirName,
+					Mode:    0555,
+					ModTime: s.CreatedAt,
+					IsDir:   true,
+					Meta:    filesystem.MetaData{Name: PluginName, Type: "directory"},
+				})
+			}
+			continue
+		}
+		fileInfos = append(fileInfos, summaryInfo(name, s))
+	}
+	if subPrefix != "" && len(fileInfos) == 0 {
+		return nil, filesystem.ErrNotFound
+	}
+	return fileInfos, nil
+}
+
+// statSummary stats docs/.summaries, a summary or a directory of summaries
+func (vfs *vectorFS) statSummary(namespace, relativePath string) (*filesystem.FileInfo, error) {
+	if vfs.plugin.summarizer == nil {
+		return nil, filesystem.ErrNotFound
+	}
+	if relativePath == "docs/"+summariesDir {
+		return &filesystem.FileInfo{
+			Name:    summariesDir,
+			Mode:    0555,
+			ModTime: time.Now(),
+			IsDir:   true,
+			Meta:    filesystem.MetaData{Name: PluginName, Type: "summaries"},
+		}, nil
+	}
+
+	path := strings.TrimPrefix(relativePath, "docs/"+summariesDir+"/")
+	if fileName, ok := summaryFileName(path); ok {
+		_, info, err := vfs.plugin.tidbClient.GetSummary(namespace, fileName)
+		if err == nil {
+			fi := summaryInfo(filepath.Base(path), *info)
+			return &fi, nil
+		}
+		if err != filesystem.ErrNotFound {
+			return nil, err
+		}
+	}
+
+	summaries, err := vfs.plugin.tidbClient.ListSummariesWithPrefix(namespace, path+"/")
+	if err != nil {
+		return nil, err
+	}
+	if len(summaries) == 0 {
+		return nil, filesystem.ErrNotFound
+	}
+	return &filesystem.FileInfo{
+		Name:    filepath.Base(path),
+		Mode:    0555,
+		ModTime: time.Now(),
+		IsDir:   true,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "directory"},
+	}, nil
+}
+
+// summaryInfo returns the file info of the summary of a document
+func summaryInfo(name string, s SummaryInfo) filesystem.FileInfo {
+	if !strings.HasSuffix(name, summaryExt) {
+		name += summaryExt
+	}
+	return filesystem.FileInfo{
+		Name:    name,
+		Size:    s.Size,
+		Mode:    0444,
+		ModTime: s.CreatedAt,
+		IsDir:   false,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "summary"},
+	}
+}
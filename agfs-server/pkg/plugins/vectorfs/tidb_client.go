@@ -3,9 +3,11 @@ package vectorfs
 import (
 	"database/sql"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
 	_ "github.com/go-sql-driver/mysql"
 	log "github.com/sirupsen/logrus"
 )
@@ -26,6 +28,7 @@ type FileMetadata struct {
 	FileName   string
 	S3Key      string
 	FileSize   int64
+	Language   string // ISO 639-1 code of the document language, "" if unknown
 	CreatedAt  time.Time
 	UpdatedAt  time.Time
 }
@@ -34,11 +37,44 @@ type FileMetadata struct {
 type VectorMatch struct {
 	FileDigest string
 	FileName   string
+	Language   string
 	ChunkText  string
 	ChunkIndex int
 	Distance   float64
 }
 
+// LanguageFilter restricts a vector search to documents in some languages,
+// or in any language but some; the zero value matches all documents
+type LanguageFilter struct {
+	Only   []string
+	Except []string
+}
+
+// where returns the SQL condition of the filter on the metadata table m,
+// with its arguments
+func (f LanguageFilter) where() (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	for _, set := range []struct {
+		op        string
+		languages []string
+	}{{"IN", f.Only}, {"NOT IN", f.Except}} {
+		if len(set.languages) == 0 {
+			continue
+		}
+		placeholders := make([]string, len(set.languages))
+		for i, language := range set.languages {
+			placeholders[i] = "?"
+			args = append(args, language)
+		}
+		conds = append(conds, fmt.Sprintf("m.language %s (%s)", set.op, strings.Join(placeholders, ", ")))
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conds, " AND "), args
+}
+
 // NewTiDBClient creates a new TiDB client
 func NewTiDBClient(cfg TiDBConfig) (*TiDBClient, error) {
 	db, err := sql.Open("mysql", cfg.DSN)
@@ -69,6 +105,11 @@ func (c *TiDBClient) Close() error {
 	return nil
 }
 
+// Ping checks that TiDB is reachable
+func (c *TiDBClient) Ping() error {
+	return c.db.Ping()
+}
+
 // sanitizeTableName sanitizes namespace name for use as table suffix
 func sanitizeTableName(namespace string) string {
 	// Replace invalid characters with underscore
@@ -81,6 +122,43 @@ func sanitizeTableName(namespace string) string {
 	return replacer.Replace(namespace)
 }
 
+// metaTableSchema is the schema of the metadata table of a namespace: one
+// row per file, referencing its content by digest. Files with the same
+// content share its S3 object and chunks, which are deleted when no file
+// references the digest any more.
+const metaTableSchema = `
+		CREATE TABLE %s (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			file_digest VARCHAR(64) NOT NULL,
+			file_name VARCHAR(1024) NOT NULL,
+			s3_key VARCHAR(1024) NOT NULL,
+			file_size BIGINT NOT NULL DEFAULT 0,
+			language VARCHAR(16) NOT NULL DEFAULT '',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			UNIQUE INDEX idx_file_name (file_name),
+			INDEX idx_file_digest (file_digest)
+		)
+	`
+
+// summaryTableSchema is the schema of the table of document summaries of a
+// namespace, which like chunks belong to content rather than files
+const summaryTableSchema = `
+		CREATE TABLE IF NOT EXISTS %s (
+			file_digest VARCHAR(64) PRIMARY KEY,
+			summary TEXT NOT NULL,
+			model VARCHAR(255) NOT NULL DEFAULT '',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`
+
+// SummaryInfo describes the summary of a file
+type SummaryInfo struct {
+	FileName  string
+	Size      int64
+	CreatedAt time.Time
+}
+
 // CreateNamespace creates tables for a new namespace (fails if already exists)
 func (c *TiDBClient) CreateNamespace(namespace string, embeddingDim int) error {
 	tableSuffix := sanitizeTableName(namespace)
@@ -97,19 +175,7 @@ func (c *TiDBClient) CreateNamespace(namespace string, embeddingDim int) error {
 	}
 
 	// Create metadata table
-	createMetaSQL := fmt.Sprintf(`
-		CREATE TABLE %s (
-			file_digest VARCHAR(64) PRIMARY KEY,
-			file_name VARCHAR(1024) NOT NULL,
-			s3_key VARCHAR(1024) NOT NULL,
-			file_size BIGINT NOT NULL DEFAULT 0,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-			INDEX idx_file_name (file_name)
-		)
-	`, metaTable)
-
-	if _, err := c.db.Exec(createMetaSQL); err != nil {
+	if _, err := c.db.Exec(fmt.Sprintf(metaTableSchema, metaTable)); err != nil {
 		return fmt.Errorf("failed to create metadata table: %w", err)
 	}
 
@@ -121,8 +187,10 @@ func (c *TiDBClient) CreateNamespace(namespace string, embeddingDim int) error {
 			chunk_index INT NOT NULL,
 			chunk_text TEXT NOT NULL,
 			embedding VECTOR(%d) NOT NULL,
+			stable_id VARCHAR(16) NOT NULL DEFAULT '',
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			INDEX idx_file_digest (file_digest),
+			INDEX idx_stable_id (stable_id),
 			VECTOR INDEX idx_embedding ((VEC_COSINE_DISTANCE(embedding)))
 		)
 	`, chunksTable, embeddingDim)
@@ -131,6 +199,10 @@ func (c *TiDBClient) CreateNamespace(namespace string, embeddingDim int) error {
 		return fmt.Errorf("failed to create chunks table: %w", err)
 	}
 
+	if _, err := c.db.Exec(fmt.Sprintf(summaryTableSchema, "tbl_summary_"+tableSuffix)); err != nil {
+		return fmt.Errorf("failed to create summary table: %w", err)
+	}
+
 	log.Infof("[vectorfs/tidb] Created tables for namespace: %s", namespace)
 	return nil
 }
@@ -141,6 +213,10 @@ func (c *TiDBClient) DeleteNamespace(namespace string) error {
 	metaTable := fmt.Sprintf("tbl_meta_%s", tableSuffix)
 	chunksTable := fmt.Sprintf("tbl_chunks_%s", tableSuffix)
 
+	if _, err := c.db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS tbl_summary_%s", tableSuffix)); err != nil {
+		return fmt.Errorf("failed to drop summary table: %w", err)
+	}
+
 	// Drop chunks table first (has foreign key reference)
 	if _, err := c.db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", chunksTable)); err != nil {
 		return fmt.Errorf("failed to drop chunks table: %w", err)
@@ -176,6 +252,107 @@ func (c *TiDBClient) NamespaceExists(namespace string) (bool, error) {
 	return count > 0, nil
 }
 
+// hasColumn checks if a table has a column
+func (c *TiDBClient) hasColumn(table, column string) (bool, error) {
+	var count int
+	err := c.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM information_schema.columns
+		WHERE table_schema = DATABASE()
+		AND table_name = ?
+		AND column_name = ?
+	`, table, column).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check schema of table %s: %w", table, err)
+	}
+	return count > 0, nil
+}
+
+// UpgradeNamespace migrates the tables of a namespace created by an older
+// version to the current schema: metadata tables that allowed a single file
+// per content digest are rebuilt, the language column is added, chunks get
+// their stable IDs, and the summary table is created
+func (c *TiDBClient) UpgradeNamespace(namespace string) error {
+	tableSuffix := sanitizeTableName(namespace)
+	metaTable := fmt.Sprintf("tbl_meta_%s", tableSuffix)
+
+	hasID, err := c.hasColumn(metaTable, "id")
+	if err != nil {
+		return err
+	}
+	if !hasID {
+		if err := c.rebuildMetaTable(namespace); err != nil {
+			return err
+		}
+	}
+
+	hasLanguage, err := c.hasColumn(metaTable, "language")
+	if err != nil {
+		return err
+	}
+	if !hasLanguage {
+		query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN language VARCHAR(16) NOT NULL DEFAULT '' AFTER file_size", metaTable)
+		if _, err := c.db.Exec(query); err != nil {
+			return fmt.Errorf("failed to add language column to %s: %w", metaTable, err)
+		}
+		log.Infof("[vectorfs/tidb] Added language column to metadata table of namespace: %s", namespace)
+	}
+
+	chunksTable := fmt.Sprintf("tbl_chunks_%s", tableSuffix)
+	hasStableID, err := c.hasColumn(chunksTable, "stable_id")
+	if err != nil {
+		return err
+	}
+	if !hasStableID {
+		// IDs of existing chunks are computed like chunkID does
+		steps := []string{
+			fmt.Sprintf("ALTER TABLE %s ADD COLUMN stable_id VARCHAR(16) NOT NULL DEFAULT ''", chunksTable),
+			fmt.Sprintf("ALTER TABLE %s ADD INDEX idx_stable_id (stable_id)", chunksTable),
+			fmt.Sprintf("UPDATE %s SET stable_id = LEFT(SHA2(chunk_text, 256), %d)", chunksTable, chunkIDLen),
+		}
+		for _, step := range steps {
+			if _, err := c.db.Exec(step); err != nil {
+				return fmt.Errorf("failed to add stable IDs to %s: %w", chunksTable, err)
+			}
+		}
+		log.Infof("[vectorfs/tidb] Added stable IDs to chunks of namespace: %s", namespace)
+	}
+
+	if _, err := c.db.Exec(fmt.Sprintf(summaryTableSchema, "tbl_summary_"+tableSuffix)); err != nil {
+		return fmt.Errorf("failed to create summary table of namespace %s: %w", namespace, err)
+	}
+	return nil
+}
+
+// rebuildMetaTable copies the metadata of a namespace into a table with the
+// current schema, which then replaces the old table
+func (c *TiDBClient) rebuildMetaTable(namespace string) error {
+	tableSuffix := sanitizeTableName(namespace)
+	metaTable := fmt.Sprintf("tbl_meta_%s", tableSuffix)
+
+	// Copy into a new table, then swap the tables
+	newTable := fmt.Sprintf("tmp_meta_%s", tableSuffix)
+	oldTable := fmt.Sprintf("tmp_old_meta_%s", tableSuffix)
+	steps := []string{
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", newTable),
+		fmt.Sprintf(metaTableSchema, newTable),
+		fmt.Sprintf(`
+			INSERT IGNORE INTO %s (file_digest, file_name, s3_key, file_size, created_at, updated_at)
+			SELECT file_digest, file_name, s3_key, file_size, created_at, updated_at FROM %s
+		`, newTable, metaTable),
+		fmt.Sprintf("RENAME TABLE %s TO %s, %s TO %s", metaTable, oldTable, newTable, metaTable),
+		fmt.Sprintf("DROP TABLE %s", oldTable),
+	}
+	for _, step := range steps {
+		if _, err := c.db.Exec(step); err != nil {
+			return fmt.Errorf("failed to upgrade metadata table %s: %w", metaTable, err)
+		}
+	}
+
+	log.Infof("[vectorfs/tidb] Upgraded metadata table of namespace: %s", namespace)
+	return nil
+}
+
 // ListNamespaces lists all namespaces (by finding all tbl_meta_* tables)
 func (c *TiDBClient) ListNamespaces() ([]string, error) {
 	query := `
@@ -206,7 +383,9 @@ func (c *TiDBClient) ListNamespaces() ([]string, error) {
 	return namespaces, nil
 }
 
-// FileExists checks if a file (by digest) is already indexed
+// FileExists checks if any file references the content with digest, in
+// which case its S3 object exists and its chunks are indexed (or queued
+// for indexing)
 func (c *TiDBClient) FileExists(namespace, digest string) (bool, error) {
 	tableSuffix := sanitizeTableName(namespace)
 	metaTable := fmt.Sprintf("tbl_meta_%s", tableSuffix)
@@ -222,23 +401,25 @@ func (c *TiDBClient) FileExists(namespace, digest string) (bool, error) {
 	return count > 0, nil
 }
 
-// InsertFileMetadata inserts file metadata
+// InsertFileMetadata inserts file metadata, replacing that of a file with
+// the same name
 func (c *TiDBClient) InsertFileMetadata(namespace string, meta FileMetadata) error {
 	tableSuffix := sanitizeTableName(namespace)
 	metaTable := fmt.Sprintf("tbl_meta_%s", tableSuffix)
 
 	query := fmt.Sprintf(`
-		INSERT INTO %s (file_digest, file_name, s3_key, file_size, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?)
+		INSERT INTO %s (file_digest, file_name, s3_key, file_size, language, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
 		ON DUPLICATE KEY UPDATE
-			file_name = VALUES(file_name),
+			file_digest = VALUES(file_digest),
 			s3_key = VALUES(s3_key),
 			file_size = VALUES(file_size),
+			language = VALUES(language),
 			updated_at = VALUES(updated_at)
 	`, metaTable)
 
 	_, err := c.db.Exec(query, meta.FileDigest, meta.FileName, meta.S3Key, meta.FileSize,
-		meta.CreatedAt, meta.UpdatedAt)
+		meta.Language, meta.CreatedAt, meta.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("failed to insert file metadata: %w", err)
 	}
@@ -246,6 +427,17 @@ func (c *TiDBClient) InsertFileMetadata(namespace string, meta FileMetadata) err
 	return nil
 }
 
+// SetContentLanguage sets the language of the files referencing the content
+// with digest, once it is known (e.g. after OCR)
+func (c *TiDBClient) SetContentLanguage(namespace, digest, language string) error {
+	metaTable := fmt.Sprintf("tbl_meta_%s", sanitizeTableName(namespace))
+
+	query := fmt.Sprintf("UPDATE %s SET language = ? WHERE file_digest = ?", metaTable)
+
+	_, err := c.db.Exec(query, language, digest)
+	return err
+}
+
 // ChunkData represents a chunk to be inserted
 type ChunkData struct {
 	ChunkIndex int
@@ -263,11 +455,11 @@ func (c *TiDBClient) InsertChunk(namespace, fileDigest string, chunkIndex int, c
 
 	// Use parameterized query - TiDB accepts vector as string parameter
 	query := fmt.Sprintf(`
-		INSERT INTO %s (file_digest, chunk_index, chunk_text, embedding)
-		VALUES (?, ?, ?, ?)
+		INSERT INTO %s (file_digest, chunk_index, chunk_text, embedding, stable_id)
+		VALUES (?, ?, ?, ?, ?)
 	`, chunksTable)
 
-	_, err := c.db.Exec(query, fileDigest, chunkIndex, chunkText, embeddingStr)
+	_, err := c.db.Exec(query, fileDigest, chunkIndex, chunkText, embeddingStr, chunkID(chunkText))
 	if err != nil {
 		return fmt.Errorf("failed to insert chunk: %w", err)
 	}
@@ -278,6 +470,35 @@ func (c *TiDBClient) InsertChunk(namespace, fileDigest string, chunkIndex int, c
 // InsertChunksBatch inserts multiple chunks in a single batch operation
 // This significantly reduces database round-trips compared to individual inserts
 func (c *TiDBClient) InsertChunksBatch(namespace, fileDigest string, chunks []ChunkData) error {
+	return insertChunks(c.db, namespace, fileDigest, chunks)
+}
+
+// ReplaceChunks replaces the chunks of a content in one transaction, so that
+// searches see either the old chunks or the new ones
+func (c *TiDBClient) ReplaceChunks(namespace, fileDigest string, chunks []ChunkData) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	chunksTable := fmt.Sprintf("tbl_chunks_%s", sanitizeTableName(namespace))
+	if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE file_digest = ?", chunksTable), fileDigest); err != nil {
+		return fmt.Errorf("failed to delete chunks: %w", err)
+	}
+	if err := insertChunks(tx, namespace, fileDigest, chunks); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// execer is implemented by *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// insertChunks inserts chunks in batches with db
+func insertChunks(db execer, namespace, fileDigest string, chunks []ChunkData) error {
 	if len(chunks) == 0 {
 		return nil
 	}
@@ -298,19 +519,19 @@ func (c *TiDBClient) InsertChunksBatch(namespace, fileDigest string, chunks []Ch
 
 		// Build placeholders and args
 		placeholders := make([]string, len(batch))
-		args := make([]interface{}, 0, len(batch)*4)
+		args := make([]interface{}, 0, len(batch)*5)
 
 		for j, chunk := range batch {
-			placeholders[j] = "(?, ?, ?, ?)"
-			args = append(args, fileDigest, chunk.ChunkIndex, chunk.ChunkText, formatVector(chunk.Embedding))
+			placeholders[j] = "(?, ?, ?, ?, ?)"
+			args = append(args, fileDigest, chunk.ChunkIndex, chunk.ChunkText, formatVector(chunk.Embedding), chunkID(chunk.ChunkText))
 		}
 
 		query := fmt.Sprintf(`
-			INSERT INTO %s (file_digest, chunk_index, chunk_text, embedding)
+			INSERT INTO %s (file_digest, chunk_index, chunk_text, embedding, stable_id)
 			VALUES %s
 		`, chunksTable, strings.Join(placeholders, ", "))
 
-		_, err := c.db.Exec(query, args...)
+		_, err := db.Exec(query, args...)
 		if err != nil {
 			return fmt.Errorf("failed to batch insert chunks (batch starting at %d): %w", i, err)
 		}
@@ -329,29 +550,56 @@ func formatVector(vec []float32) string {
 	return fmt.Sprintf("[%s]", strings.Join(strVals, ","))
 }
 
-// VectorSearch performs vector similarity search
-func (c *TiDBClient) VectorSearch(namespace string, queryEmbedding []float32, limit int) ([]VectorMatch, error) {
+// parseVector converts a vector string, as returned by TiDB, to a float32 array
+func parseVector(s string) ([]float32, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "[") || !strings.HasSuffix(s, "]") {
+		return nil, fmt.Errorf("invalid vector: %.32q", s)
+	}
+	s = strings.TrimSpace(s[1 : len(s)-1])
+	if s == "" {
+		return []float32{}, nil
+	}
+	parts := strings.Split(s, ",")
+	vec := make([]float32, len(parts))
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vector element %d: %w", i, err)
+		}
+		vec[i] = float32(f)
+	}
+	return vec, nil
+}
+
+// VectorSearch performs vector similarity search among the documents that
+// match filter
+func (c *TiDBClient) VectorSearch(namespace string, queryEmbedding []float32, limit int, filter LanguageFilter) ([]VectorMatch, error) {
 	tableSuffix := sanitizeTableName(namespace)
 	metaTable := fmt.Sprintf("tbl_meta_%s", tableSuffix)
 	chunksTable := fmt.Sprintf("tbl_chunks_%s", tableSuffix)
 
 	embeddingStr := formatVector(queryEmbedding)
+	where, whereArgs := filter.where()
 
 	// Use parameterized query for vector parameter
 	query := fmt.Sprintf(`
 		SELECT
 			c.file_digest,
 			m.file_name,
+			m.language,
 			c.chunk_text,
 			c.chunk_index,
 			VEC_COSINE_DISTANCE(c.embedding, ?) AS distance
 		FROM %s c
 		JOIN %s m ON c.file_digest = m.file_digest
+		%s
 		ORDER BY distance
 		LIMIT ?
-	`, chunksTable, metaTable)
+	`, chunksTable, metaTable, where)
 
-	rows, err := c.db.Query(query, embeddingStr, limit)
+	args := append([]interface{}{embeddingStr}, whereArgs...)
+	rows, err := c.db.Query(query, append(args, limit)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute vector search: %w", err)
 	}
@@ -360,7 +608,7 @@ func (c *TiDBClient) VectorSearch(namespace string, queryEmbedding []float32, li
 	var results []VectorMatch
 	for rows.Next() {
 		var match VectorMatch
-		if err := rows.Scan(&match.FileDigest, &match.FileName, &match.ChunkText,
+		if err := rows.Scan(&match.FileDigest, &match.FileName, &match.Language, &match.ChunkText,
 			&match.ChunkIndex, &match.Distance); err != nil {
 			return nil, err
 		}
@@ -377,7 +625,7 @@ func (c *TiDBClient) ListFiles(namespace string) ([]FileMetadata, error) {
 	metaTable := fmt.Sprintf("tbl_meta_%s", tableSuffix)
 
 	query := fmt.Sprintf(`
-		SELECT file_digest, file_name, s3_key, file_size, created_at, updated_at
+		SELECT file_digest, file_name, s3_key, file_size, language, created_at, updated_at
 		FROM %s
 		ORDER BY updated_at DESC
 	`, metaTable)
@@ -392,7 +640,7 @@ func (c *TiDBClient) ListFiles(namespace string) ([]FileMetadata, error) {
 	for rows.Next() {
 		var file FileMetadata
 		if err := rows.Scan(&file.FileDigest, &file.FileName, &file.S3Key, &file.FileSize,
-			&file.CreatedAt, &file.UpdatedAt); err != nil {
+			&file.Language, &file.CreatedAt, &file.UpdatedAt); err != nil {
 			return nil, err
 		}
 		files = append(files, file)
@@ -409,7 +657,7 @@ func (c *TiDBClient) ListFilesWithPrefix(namespace, prefix string) ([]FileMetada
 
 	// Use LIKE for prefix matching - the index on file_name will be used
 	query := fmt.Sprintf(`
-		SELECT file_digest, file_name, s3_key, file_size, created_at, updated_at
+		SELECT file_digest, file_name, s3_key, file_size, language, created_at, updated_at
 		FROM %s
 		WHERE file_name LIKE ?
 		ORDER BY file_name
@@ -430,7 +678,7 @@ func (c *TiDBClient) ListFilesWithPrefix(namespace, prefix string) ([]FileMetada
 	for rows.Next() {
 		var file FileMetadata
 		if err := rows.Scan(&file.FileDigest, &file.FileName, &file.S3Key, &file.FileSize,
-			&file.CreatedAt, &file.UpdatedAt); err != nil {
+			&file.Language, &file.CreatedAt, &file.UpdatedAt); err != nil {
 			return nil, err
 		}
 		files = append(files, file)
@@ -468,6 +716,215 @@ func (c *TiDBClient) HasFilesWithPrefix(namespace, prefix string) (bool, error)
 	return true, nil
 }
 
+// HasChunks checks if the chunks of the content with digest are indexed
+func (c *TiDBClient) HasChunks(namespace, digest string) (bool, error) {
+	tableSuffix := sanitizeTableName(namespace)
+	chunksTable := fmt.Sprintf("tbl_chunks_%s", tableSuffix)
+
+	query := fmt.Sprintf("SELECT 1 FROM %s WHERE file_digest = ? LIMIT 1", chunksTable)
+
+	var exists int
+	err := c.db.QueryRow(query, digest).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ListChunks returns the chunks of the content with digest, with their
+// embeddings, in order
+func (c *TiDBClient) ListChunks(namespace, digest string) ([]ChunkData, error) {
+	chunksTable := fmt.Sprintf("tbl_chunks_%s", sanitizeTableName(namespace))
+
+	query := fmt.Sprintf(`
+		SELECT chunk_index, chunk_text, embedding
+		FROM %s
+		WHERE file_digest = ?
+		ORDER BY chunk_index
+	`, chunksTable)
+
+	rows, err := c.db.Query(query, digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chunks: %w", err)
+	}
+	defer rows.Close()
+
+	var chunks []ChunkData
+	for rows.Next() {
+		var chunk ChunkData
+		var embedding string
+		if err := rows.Scan(&chunk.ChunkIndex, &chunk.ChunkText, &embedding); err != nil {
+			return nil, err
+		}
+		if chunk.Embedding, err = parseVector(embedding); err != nil {
+			return nil, fmt.Errorf("chunk %d of %s: %w", chunk.ChunkIndex, digest, err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, rows.Err()
+}
+
+// ChunkRef is a chunk of a file, found by its stable ID
+type ChunkRef struct {
+	FileName   string
+	Language   string
+	ChunkIndex int
+	ChunkText  string
+	UpdatedAt  time.Time
+}
+
+// GetChunksByID returns the chunks with a stable ID, in the files that
+// reference their content; chunks with the same text share their ID
+func (c *TiDBClient) GetChunksByID(namespace, id string) ([]ChunkRef, error) {
+	tableSuffix := sanitizeTableName(namespace)
+	metaTable := fmt.Sprintf("tbl_meta_%s", tableSuffix)
+	chunksTable := fmt.Sprintf("tbl_chunks_%s", tableSuffix)
+
+	query := fmt.Sprintf(`
+		SELECT m.file_name, m.language, c.chunk_index, c.chunk_text, m.updated_at
+		FROM %s c
+		JOIN %s m ON c.file_digest = m.file_digest
+		WHERE c.stable_id = ?
+		ORDER BY m.file_name, c.chunk_index
+	`, chunksTable, metaTable)
+
+	rows, err := c.db.Query(query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chunk %s: %w", id, err)
+	}
+	defer rows.Close()
+
+	var refs []ChunkRef
+	for rows.Next() {
+		var ref ChunkRef
+		if err := rows.Scan(&ref.FileName, &ref.Language, &ref.ChunkIndex, &ref.ChunkText, &ref.UpdatedAt); err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
+
+// InsertSummary stores the summary of the content with digest, replacing any
+// previous one
+func (c *TiDBClient) InsertSummary(namespace, digest, summary, model string) error {
+	summaryTable := fmt.Sprintf("tbl_summary_%s", sanitizeTableName(namespace))
+
+	query := fmt.Sprintf("REPLACE INTO %s (file_digest, summary, model) VALUES (?, ?, ?)", summaryTable)
+
+	if _, err := c.db.Exec(query, digest, summary, model); err != nil {
+		return fmt.Errorf("failed to insert summary: %w", err)
+	}
+	return nil
+}
+
+// HasSummary checks if the content with digest has a summary
+func (c *TiDBClient) HasSummary(namespace, digest string) (bool, error) {
+	summaryTable := fmt.Sprintf("tbl_summary_%s", sanitizeTableName(namespace))
+
+	query := fmt.Sprintf("SELECT 1 FROM %s WHERE file_digest = ?", summaryTable)
+
+	var exists int
+	err := c.db.QueryRow(query, digest).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetSummary returns the summary of a file, or filesystem.ErrNotFound if the
+// file does not exist or has no summary (yet)
+func (c *TiDBClient) GetSummary(namespace, fileName string) (string, *SummaryInfo, error) {
+	tableSuffix := sanitizeTableName(namespace)
+
+	query := fmt.Sprintf(`
+		SELECT s.summary, s.created_at
+		FROM tbl_meta_%s m
+		JOIN tbl_summary_%s s ON s.file_digest = m.file_digest
+		WHERE m.file_name = ?
+	`, tableSuffix, tableSuffix)
+
+	var summary string
+	info := &SummaryInfo{FileName: fileName}
+	err := c.db.QueryRow(query, fileName).Scan(&summary, &info.CreatedAt)
+	if err == sql.ErrNoRows {
+		return "", nil, filesystem.ErrNotFound
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	info.Size = int64(len(summary))
+	return summary, info, nil
+}
+
+// ListSummariesWithPrefix lists the summaries of the files whose name has a
+// prefix ("" for all files)
+func (c *TiDBClient) ListSummariesWithPrefix(namespace, prefix string) ([]SummaryInfo, error) {
+	tableSuffix := sanitizeTableName(namespace)
+
+	query := fmt.Sprintf(`
+		SELECT m.file_name, LENGTH(s.summary), s.created_at
+		FROM tbl_meta_%s m
+		JOIN tbl_summary_%s s ON s.file_digest = m.file_digest
+		WHERE m.file_name LIKE ?
+		ORDER BY m.file_name
+	`, tableSuffix, tableSuffix)
+
+	// Escape special LIKE characters in prefix and add wildcard
+	escapedPrefix := strings.ReplaceAll(prefix, "%", "\\%")
+	escapedPrefix = strings.ReplaceAll(escapedPrefix, "_", "\\_")
+
+	rows, err := c.db.Query(query, escapedPrefix+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []SummaryInfo
+	for rows.Next() {
+		var info SummaryInfo
+		if err := rows.Scan(&info.FileName, &info.Size, &info.CreatedAt); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, info)
+	}
+	return summaries, rows.Err()
+}
+
+// GetContentSummary returns the summary of the content with digest and the
+// model that generated it, or filesystem.ErrNotFound if it has none
+func (c *TiDBClient) GetContentSummary(namespace, digest string) (string, string, error) {
+	summaryTable := fmt.Sprintf("tbl_summary_%s", sanitizeTableName(namespace))
+
+	query := fmt.Sprintf("SELECT summary, model FROM %s WHERE file_digest = ?", summaryTable)
+
+	var summary, model string
+	err := c.db.QueryRow(query, digest).Scan(&summary, &model)
+	if err == sql.ErrNoRows {
+		return "", "", filesystem.ErrNotFound
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return summary, model, nil
+}
+
+// DeleteSummary deletes the summary of the content with digest
+func (c *TiDBClient) DeleteSummary(namespace, digest string) error {
+	summaryTable := fmt.Sprintf("tbl_summary_%s", sanitizeTableName(namespace))
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE file_digest = ?", summaryTable)
+
+	_, err := c.db.Exec(query, digest)
+	return err
+}
+
 // DeleteFileChunks deletes all chunks for a file
 func (c *TiDBClient) DeleteFileChunks(namespace, fileDigest string) error {
 	tableSuffix := sanitizeTableName(namespace)
@@ -490,44 +947,32 @@ func (c *TiDBClient) DeleteFileMetadata(namespace, fileDigest string) error {
 	return err
 }
 
-// DeleteFileByName deletes all versions of a file by name (used before writing new content)
+// DeleteFileByName deletes the metadata of a file; its content stays
+// shared by other files with the same digest, if any
 func (c *TiDBClient) DeleteFileByName(namespace, fileName string) error {
 	tableSuffix := sanitizeTableName(namespace)
 	metaTable := fmt.Sprintf("tbl_meta_%s", tableSuffix)
-	chunksTable := fmt.Sprintf("tbl_chunks_%s", tableSuffix)
 
-	// First get all digests for this filename
-	query := fmt.Sprintf("SELECT file_digest FROM %s WHERE file_name = ?", metaTable)
-	rows, err := c.db.Query(query, fileName)
-	if err != nil {
-		return err
-	}
-	defer rows.Close()
+	query := fmt.Sprintf("DELETE FROM %s WHERE file_name = ?", metaTable)
 
-	var digests []string
-	for rows.Next() {
-		var digest string
-		if err := rows.Scan(&digest); err != nil {
-			return err
-		}
-		digests = append(digests, digest)
-	}
+	_, err := c.db.Exec(query, fileName)
+	return err
+}
 
-	// Delete chunks and metadata for each digest
-	for _, digest := range digests {
-		// Delete chunks
-		chunkQuery := fmt.Sprintf("DELETE FROM %s WHERE file_digest = ?", chunksTable)
-		if _, err := c.db.Exec(chunkQuery, digest); err != nil {
-			return err
-		}
-		// Delete metadata
-		metaQuery := fmt.Sprintf("DELETE FROM %s WHERE file_digest = ?", metaTable)
-		if _, err := c.db.Exec(metaQuery, digest); err != nil {
-			return err
-		}
-	}
+// GetFileDigest returns the content digest of a file, or "" if there is no
+// file with the name
+func (c *TiDBClient) GetFileDigest(namespace, fileName string) (string, error) {
+	tableSuffix := sanitizeTableName(namespace)
+	metaTable := fmt.Sprintf("tbl_meta_%s", tableSuffix)
 
-	return nil
+	query := fmt.Sprintf("SELECT file_digest FROM %s WHERE file_name = ?", metaTable)
+
+	var digest string
+	err := c.db.QueryRow(query, fileName).Scan(&digest)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return digest, err
 }
 
 // GetFileMetadataByName retrieves file metadata by file name (returns the latest version)
@@ -536,7 +981,7 @@ func (c *TiDBClient) GetFileMetadataByName(namespace, fileName string) (*FileMet
 	metaTable := fmt.Sprintf("tbl_meta_%s", tableSuffix)
 
 	query := fmt.Sprintf(`
-		SELECT file_digest, file_name, s3_key, file_size, created_at, updated_at
+		SELECT file_digest, file_name, s3_key, file_size, language, created_at, updated_at
 		FROM %s
 		WHERE file_name = ?
 		ORDER BY updated_at DESC
@@ -549,6 +994,7 @@ func (c *TiDBClient) GetFileMetadataByName(namespace, fileName string) (*FileMet
 		&meta.FileName,
 		&meta.S3Key,
 		&meta.FileSize,
+		&meta.Language,
 		&meta.CreatedAt,
 		&meta.UpdatedAt,
 	)
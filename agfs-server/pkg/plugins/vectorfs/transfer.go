@@ -0,0 +1,467 @@
+package vectorfs
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	log "github.com/sirupsen/logrus"
+)
+
+// Control files of a namespace: writing to them starts an export or import,
+// reading them returns the status of the last one
+const (
+	exportFile = ".export"
+	importFile = ".import"
+)
+
+// exportFormatVersion is the version of the export format written, and the
+// newest one that can be imported
+const exportFormatVersion = 1
+
+// exportRecord is one line of an export, a gzipped NDJSON file. An export
+// starts with a header, followed by each content with its chunks, summary
+// and the files referencing it:
+//
+//	{"type":"header","version":1,"namespace":"docs","embedding_dim":1536,"models":{"":"text-embedding-3-small"}}
+//	{"type":"content","digest":"9f86...","language":"en","data":"<base64>"}
+//	{"type":"chunk","digest":"9f86...","index":0,"text":"...","embedding":[0.01,...]}
+//	{"type":"summary","digest":"9f86...","summary":"...","model":"gpt-4o-mini"}
+//	{"type":"file","digest":"9f86...","name":"guides/k8s.txt","size":1024,"language":"en",...}
+type exportRecord struct {
+	Type string `json:"type"` // header, content, chunk, summary or file
+
+	// Header
+	Version      int               `json:"version,omitempty"`
+	Namespace    string            `json:"namespace,omitempty"`
+	EmbeddingDim int               `json:"embedding_dim,omitempty"`
+	Models       map[string]string `json:"models,omitempty"` // Embedding model per language, "" for other languages
+
+	Digest    string     `json:"digest,omitempty"`
+	Language  string     `json:"language,omitempty"`
+	Data      []byte     `json:"data,omitempty"`
+	Index     int        `json:"index,omitempty"`
+	Text      string     `json:"text,omitempty"`
+	Embedding []float32  `json:"embedding,omitempty"`
+	Summary   string     `json:"summary,omitempty"`
+	Model     string     `json:"model,omitempty"`
+	Name      string     `json:"name,omitempty"`
+	Size      int64      `json:"size,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// importedContent is a content read from an export with what belongs to it
+type importedContent struct {
+	digest       string
+	language     string
+	data         []byte
+	chunks       []ChunkData
+	summary      string
+	summaryModel string
+	files        []FileMetadata
+}
+
+// transferStatus is the status of an export or import
+type transferStatus struct {
+	mu        sync.Mutex
+	location  string
+	state     string // running, done or failed
+	started   time.Time
+	finished  time.Time
+	err       error
+	files     int
+	contents  int
+	chunks    int
+	summaries int
+	queued    int // Imported contents queued for indexing
+}
+
+// update changes the status under its lock
+func (s *transferStatus) update(fn func(s *transferStatus)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s)
+}
+
+func (s *transferStatus) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "state: %s\n", s.state)
+	fmt.Fprintf(&b, "location: %s\n", s.location)
+	fmt.Fprintf(&b, "started: %s\n", s.started.Format(time.RFC3339))
+	if !s.finished.IsZero() {
+		fmt.Fprintf(&b, "finished: %s\n", s.finished.Format(time.RFC3339))
+	}
+	fmt.Fprintf(&b, "files: %d\ncontents: %d\nchunks: %d\nsummaries: %d\n", s.files, s.contents, s.chunks, s.summaries)
+	if s.queued > 0 {
+		fmt.Fprintf(&b, "queued for indexing: %d\n", s.queued)
+	}
+	if s.err != nil {
+		fmt.Fprintf(&b, "error: %v\n", s.err)
+	}
+	return b.String()
+}
+
+// getTransferStatus returns the status of the last export or import
+// (kind is exportFile or importFile) of a namespace
+func (v *VectorFSPlugin) getTransferStatus(namespace, kind string) string {
+	v.transfersMu.Lock()
+	status := v.transfers[lockKey(namespace, kind)]
+	v.transfersMu.Unlock()
+
+	if status == nil {
+		return "idle\n"
+	}
+	return status.String()
+}
+
+// startTransfer runs an export or import of a namespace in the background;
+// only one of each kind can run at a time in a namespace
+func (v *VectorFSPlugin) startTransfer(namespace, kind, location string, run func(status *transferStatus) error) error {
+	key := lockKey(namespace, kind)
+	status := &transferStatus{location: location, state: "running", started: time.Now()}
+
+	v.transfersMu.Lock()
+	if prev := v.transfers[key]; prev != nil {
+		prev.mu.Lock()
+		running := prev.state == "running"
+		prev.mu.Unlock()
+		if running {
+			v.transfersMu.Unlock()
+			return fmt.Errorf("%s of namespace %s already running", strings.TrimPrefix(kind, "."), namespace)
+		}
+	}
+	v.transfers[key] = status
+	v.transferWg.Add(1)
+	v.transfersMu.Unlock()
+
+	go func() {
+		defer v.transferWg.Done()
+		err := run(status)
+		status.update(func(s *transferStatus) {
+			s.finished = time.Now()
+			s.err = err
+			s.state = "done"
+			if err != nil {
+				s.state = "failed"
+			}
+		})
+		if err != nil {
+			log.Errorf("[vectorfs] %s of namespace %s failed: %v", strings.TrimPrefix(kind, "."), namespace, err)
+		} else {
+			log.Infof("[vectorfs] %s of namespace %s done: %s", strings.TrimPrefix(kind, "."), namespace, location)
+		}
+	}()
+	return nil
+}
+
+// shuttingDown checks if the plugin is shutting down, which interrupts
+// exports and imports
+func (v *VectorFSPlugin) shuttingDown() bool {
+	select {
+	case <-v.shutdown:
+		return true
+	default:
+		return false
+	}
+}
+
+// StartExport starts to export a namespace to an S3 location, given as
+// "s3://bucket/key" or a key in the plugin's bucket; if empty, a location
+// under the plugin's key prefix is chosen. It returns the location.
+func (v *VectorFSPlugin) StartExport(namespace, location string) (string, error) {
+	if location == "" {
+		location = fmt.Sprintf("s3://%s/%s/_exports/%s/%s.ndjson.gz", v.s3Client.bucket, v.s3Client.keyPrefix,
+			namespace, time.Now().UTC().Format("20060102T150405Z"))
+	}
+	if _, _, err := v.s3Client.objectLocation(location); err != nil {
+		return "", err
+	}
+
+	err := v.startTransfer(namespace, exportFile, location, func(status *transferStatus) error {
+		return v.export(namespace, location, status)
+	})
+	return location, err
+}
+
+// export writes the export of a namespace to a temporary file, then uploads it
+func (v *VectorFSPlugin) export(namespace, location string, status *transferStatus) error {
+	tmp, err := os.CreateTemp("", "vectorfs-export-*.ndjson.gz")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	gz := gzip.NewWriter(tmp)
+	if err := v.writeExport(namespace, gz, status); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return v.s3Client.UploadObject(context.Background(), location, tmp)
+}
+
+// writeExport writes the records of a namespace export to w
+func (v *VectorFSPlugin) writeExport(namespace string, w io.Writer, status *transferStatus) error {
+	enc := json.NewEncoder(w)
+
+	if err := enc.Encode(exportRecord{
+		Type:         "header",
+		Version:      exportFormatVersion,
+		Namespace:    namespace,
+		EmbeddingDim: v.embeddingClient.GetDimension(),
+		Models:       v.indexer.embeddingModels(),
+	}); err != nil {
+		return err
+	}
+
+	files, err := v.tidbClient.ListFiles(namespace)
+	if err != nil {
+		return err
+	}
+	byDigest := make(map[string][]FileMetadata)
+	var digests []string
+	for _, f := range files {
+		if _, ok := byDigest[f.FileDigest]; !ok {
+			digests = append(digests, f.FileDigest)
+		}
+		byDigest[f.FileDigest] = append(byDigest[f.FileDigest], f)
+	}
+	sort.Strings(digests)
+
+	ctx := context.Background()
+	for _, digest := range digests {
+		if v.shuttingDown() {
+			return fmt.Errorf("interrupted by shutdown")
+		}
+		files := byDigest[digest]
+
+		data, err := v.s3Client.DownloadDocument(ctx, namespace, digest)
+		if err != nil {
+			// Skip content deleted since the files were listed
+			if referenced, _ := v.tidbClient.FileExists(namespace, digest); !referenced {
+				continue
+			}
+			return err
+		}
+		chunks, err := v.tidbClient.ListChunks(namespace, digest)
+		if err != nil {
+			return err
+		}
+		summary, summaryModel, err := v.tidbClient.GetContentSummary(namespace, digest)
+		if err != nil && err != filesystem.ErrNotFound {
+			return err
+		}
+
+		records := []exportRecord{{Type: "content", Digest: digest, Language: files[0].Language, Data: data}}
+		for _, chunk := range chunks {
+			records = append(records, exportRecord{Type: "chunk", Digest: digest, Index: chunk.ChunkIndex,
+				Text: chunk.ChunkText, Embedding: chunk.Embedding})
+		}
+		if summary != "" {
+			records = append(records, exportRecord{Type: "summary", Digest: digest, Summary: summary, Model: summaryModel})
+		}
+		for _, f := range files {
+			f := f
+			records = append(records, exportRecord{Type: "file", Digest: digest, Name: f.FileName, Size: f.FileSize,
+				Language: f.Language, CreatedAt: &f.CreatedAt, UpdatedAt: &f.UpdatedAt})
+		}
+		for _, record := range records {
+			if err := enc.Encode(record); err != nil {
+				return err
+			}
+		}
+
+		status.update(func(s *transferStatus) {
+			s.contents++
+			s.files += len(files)
+			s.chunks += len(chunks)
+			if summary != "" {
+				s.summaries++
+			}
+		})
+	}
+	return nil
+}
+
+// StartImport starts to import an export, at an S3 location given as
+// "s3://bucket/key" or a key in the plugin's bucket, into a namespace
+func (v *VectorFSPlugin) StartImport(namespace, location string) error {
+	if location == "" {
+		return fmt.Errorf("write the location of an export to %s", importFile)
+	}
+	if _, _, err := v.s3Client.objectLocation(location); err != nil {
+		return err
+	}
+
+	return v.startTransfer(namespace, importFile, location, func(status *transferStatus) error {
+		body, err := v.s3Client.OpenObject(context.Background(), location)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return fmt.Errorf("not an export: %w", err)
+		}
+		return v.readExport(namespace, gz, status)
+	})
+}
+
+// readExport imports the records of an export read from r into a namespace.
+// Chunks are imported if they were embedded by the model the namespace uses
+// for their language; otherwise, or if the export has none, the content is
+// queued for indexing.
+func (v *VectorFSPlugin) readExport(namespace string, r io.Reader, status *transferStatus) error {
+	dec := json.NewDecoder(r)
+
+	var header exportRecord
+	if err := dec.Decode(&header); err != nil {
+		return fmt.Errorf("failed to read export header: %w", err)
+	}
+	if header.Type != "header" {
+		return fmt.Errorf("not an export: no header")
+	}
+	if header.Version > exportFormatVersion {
+		return fmt.Errorf("unsupported export version %d", header.Version)
+	}
+
+	// compatible checks if chunks of a language can be used as exported
+	models := v.indexer.embeddingModels()
+	compatible := func(language string) bool {
+		if header.EmbeddingDim != v.embeddingClient.GetDimension() {
+			return false
+		}
+		source, ok := header.Models[language]
+		if !ok {
+			source = header.Models[""]
+		}
+		target, ok := models[language]
+		if !ok {
+			target = models[""]
+		}
+		return source == target
+	}
+
+	var current *importedContent
+	for {
+		var record exportRecord
+		err := dec.Decode(&record)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read export: %w", err)
+		}
+
+		if record.Type == "content" {
+			if err := v.importContent(namespace, current, status); err != nil {
+				return err
+			}
+			if v.shuttingDown() {
+				return fmt.Errorf("interrupted by shutdown")
+			}
+			if len(record.Data) > 0 {
+				hash := sha256.Sum256(record.Data)
+				if hex.EncodeToString(hash[:]) != record.Digest {
+					return fmt.Errorf("corrupt export: digest mismatch for content %s", record.Digest)
+				}
+			}
+			current = &importedContent{digest: record.Digest, language: record.Language, data: record.Data}
+			continue
+		}
+		if current == nil || record.Digest != current.digest {
+			return fmt.Errorf("corrupt export: %s record of content %s out of place", record.Type, record.Digest)
+		}
+
+		switch record.Type {
+		case "chunk":
+			if compatible(current.language) {
+				current.chunks = append(current.chunks, ChunkData{ChunkIndex: record.Index, ChunkText: record.Text,
+					Embedding: record.Embedding})
+			}
+		case "summary":
+			current.summary, current.summaryModel = record.Summary, record.Model
+		case "file":
+			if record.Name == "" || isSummaryPath("docs/"+record.Name) {
+				return fmt.Errorf("corrupt export: invalid file name %q", record.Name)
+			}
+			meta := FileMetadata{FileName: record.Name, FileSize: record.Size, Language: record.Language,
+				CreatedAt: time.Now(), UpdatedAt: time.Now()}
+			if record.CreatedAt != nil {
+				meta.CreatedAt = *record.CreatedAt
+			}
+			if record.UpdatedAt != nil {
+				meta.UpdatedAt = *record.UpdatedAt
+			}
+			current.files = append(current.files, meta)
+		default:
+			return fmt.Errorf("corrupt export: unknown record type %q", record.Type)
+		}
+	}
+	return v.importContent(namespace, current, status)
+}
+
+// importContent imports a content with its files, under the write locks of
+// the files, and queues it for indexing if needed
+func (v *VectorFSPlugin) importContent(namespace string, c *importedContent, status *transferStatus) error {
+	// Content no file references any more is not imported
+	if c == nil || len(c.files) == 0 {
+		return nil
+	}
+
+	// Lock the files in order, so that imports cannot deadlock each other
+	sort.Slice(c.files, func(i, j int) bool { return c.files[i].FileName < c.files[j].FileName })
+	for _, f := range c.files {
+		v.writes.lock(lockKey(namespace, f.FileName))
+	}
+	indexed, err := v.indexer.ImportContent(namespace, c)
+	for _, f := range c.files {
+		v.writes.unlock(lockKey(namespace, f.FileName))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to import content %s: %w", c.digest, err)
+	}
+
+	if !indexed {
+		v.queueIndexTask(indexTask{
+			namespace: namespace,
+			digest:    c.digest,
+			fileName:  c.files[0].FileName,
+			data:      string(c.data),
+			language:  c.language,
+		})
+	}
+
+	status.update(func(s *transferStatus) {
+		s.contents++
+		s.files += len(c.files)
+		s.chunks += len(c.chunks)
+		if c.summary != "" {
+			s.summaries++
+		}
+		if !indexed {
+			s.queued++
+		}
+	})
+	return nil
+}
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"io"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -15,6 +17,7 @@ import (
 	"github.com/c4pt0r/agfs/agfs-server/pkg/mountablefs"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin"
 	"github.com/c4pt0r/agfs/agfs-server/pkg/plugin/config"
+	"github.com/robfig/cron/v3"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -28,6 +31,8 @@ type indexTask struct {
 	digest    string
 	fileName  string
 	data      string
+	language  string
+	ocrKind   string // Kind of image or scanned PDF to recognize the text of, if any
 }
 
 // indexingFileInfo tracks a file being indexed
@@ -40,7 +45,12 @@ type VectorFSPlugin struct {
 	s3Client        *S3Client
 	tidbClient      *TiDBClient
 	embeddingClient *EmbeddingClient
+	budget          *embeddingBudget // Embedding usage and its caps (see budget.go)
 	indexer         *Indexer
+	summarizer      *summarizer         // nil if summaries are disabled
+	ocr             ocrEngine           // nil if OCR is disabled
+	policy          *writePolicy        // Documents accepted in docs/ (see policy.go)
+	aliases         map[string][]string // Namespace aliases: alias -> namespaces (see aliases.go)
 	mu              sync.RWMutex
 	metadata        plugin.PluginMetadata
 
@@ -52,6 +62,27 @@ type VectorFSPlugin struct {
 	// Indexing status tracking: namespace -> (digest -> fileInfo)
 	indexingStatus   map[string]map[string]*indexingFileInfo
 	indexingStatusMu sync.RWMutex
+
+	// Serializes writes to the same file
+	writes keyedMutex
+
+	// Last export and import of each namespace (see transfer.go)
+	transfers   map[string]*transferStatus
+	transfersMu sync.Mutex
+	transferWg  sync.WaitGroup
+
+	// Reindexes run as tasks of the AGFS tree (see reindex.go)
+	rootFS      filesystem.FileSystem
+	mountPath   string
+	reindexRate float64         // Embedding requests per second, 0 for no limit
+	reindexing  map[string]bool // Namespaces being reindexed
+	reindexMu   sync.Mutex
+
+	// Sources of the documents of namespaces, synced on a schedule (see sources.go)
+	sources    map[string][]*source
+	sourceCron *cron.Cron
+	sourcesMu  sync.Mutex
+	sourceWg   sync.WaitGroup
 }
 
 // NewVectorFSPlugin creates a new VectorFS plugin
@@ -80,14 +111,66 @@ func (v *VectorFSPlugin) Validate(cfg map[string]interface{}) error {
 		"tidb_dsn", "tidb_host", "tidb_port", "tidb_user", "tidb_password", "tidb_database",
 		// Embedding configuration
 		"embedding_provider", "openai_api_key", "embedding_model", "embedding_dim",
+		"embedding_api_base", "embedding_api_key", "embedding_batch_size", "embedding_concurrency",
 		// Chunking configuration
 		"chunk_size", "chunk_overlap",
 		// Worker pool configuration
 		"index_workers",
+		// Reindex configuration
+		"reindex_rate",
+		// Embedding caps
+		"embedding_max_requests_per_hour", "embedding_max_tokens_per_hour",
+		"embedding_namespace_max_requests_per_hour", "embedding_namespace_max_tokens_per_hour",
+		// Write policy
+		"max_document_size", "allowed_extensions", "allowed_content_types", "reject_binary",
+		// Language configuration
+		"language_models",
+		// Namespace aliases
+		"namespace_aliases",
+		// Document sources
+		"sources",
+		// Summary configuration
+		"summary_enabled", "summary_provider", "summary_model", "summary_api_key", "summary_api_base", "summary_max_tokens",
+		// OCR configuration
+		"ocr_enabled", "ocr_provider", "ocr_command", "ocr_languages", "ocr_api_key", "ocr_api_base",
 	}
 	if err := config.ValidateOnlyKnownKeys(cfg, allowedKeys); err != nil {
 		return err
 	}
+	if _, err := languageModels(cfg); err != nil {
+		return err
+	}
+	aliases, err := namespaceAliases(cfg)
+	if err != nil {
+		return err
+	}
+	sources, err := sourcesFromConfig(cfg)
+	if err != nil {
+		return err
+	}
+	for namespace := range sources {
+		if _, ok := aliases[namespace]; ok {
+			return fmt.Errorf("sources.%s: %s is an alias, not a namespace", namespace, namespace)
+		}
+	}
+	if _, err := budgetLimitsFromConfig(cfg); err != nil {
+		return err
+	}
+	if _, err := newWritePolicy(cfg); err != nil {
+		return err
+	}
+	if err := config.ValidateBoolType(cfg, "summary_enabled"); err != nil {
+		return err
+	}
+	if _, err := newSummarizer(cfg); err != nil {
+		return err
+	}
+	if err := config.ValidateBoolType(cfg, "ocr_enabled"); err != nil {
+		return err
+	}
+	if _, err := newOCR(cfg); err != nil {
+		return err
+	}
 
 	// Validate S3 configuration
 	if config.GetStringConfig(cfg, "s3_bucket", "") == "" {
@@ -101,15 +184,48 @@ func (v *VectorFSPlugin) Validate(cfg map[string]interface{}) error {
 
 	// Validate embedding configuration
 	provider := config.GetStringConfig(cfg, "embedding_provider", "openai")
-	if provider == "openai" {
+	switch provider {
+	case "openai":
 		if config.GetStringConfig(cfg, "openai_api_key", "") == "" {
 			return fmt.Errorf("openai_api_key is required when using openai provider")
 		}
+	case "http":
+		if config.GetStringConfig(cfg, "embedding_api_base", "") == "" {
+			return fmt.Errorf("embedding_api_base is required when using http provider")
+		}
+	default:
+		return fmt.Errorf("unsupported embedding provider: %s (supported: openai, http)", provider)
+	}
+	for _, key := range []string{"embedding_batch_size", "embedding_concurrency"} {
+		if err := config.ValidateIntType(cfg, key); err != nil {
+			return err
+		}
+		if config.GetIntConfig(cfg, key, 0) < 0 {
+			return fmt.Errorf("%s must not be negative", key)
+		}
 	}
 
 	return nil
 }
 
+// languageModels returns the language_models configuration: language ->
+// embedding model of the documents in that language
+func languageModels(cfg map[string]interface{}) (map[string]string, error) {
+	if err := config.ValidateMapType(cfg, "language_models"); err != nil {
+		return nil, err
+	}
+	raw, _ := cfg["language_models"].(map[string]interface{})
+	models := make(map[string]string, len(raw))
+	for language, v := range raw {
+		model, ok := v.(string)
+		if !ok || model == "" {
+			return nil, fmt.Errorf("language_models.%s must be a model name", language)
+		}
+		models[strings.ToLower(language)] = model
+	}
+	return models, nil
+}
+
 func (v *VectorFSPlugin) Initialize(cfg map[string]interface{}) error {
 	// Initialize S3 client
 	s3Config := S3Config{
@@ -138,19 +254,53 @@ func (v *VectorFSPlugin) Initialize(cfg map[string]interface{}) error {
 	}
 	v.tidbClient = tidbClient
 
+	v.aliases, err = namespaceAliases(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Upgrade namespaces created by older versions
+	namespaces, err := tidbClient.ListNamespaces()
+	if err != nil {
+		return fmt.Errorf("failed to list namespaces: %w", err)
+	}
+	for _, namespace := range namespaces {
+		if err := tidbClient.UpgradeNamespace(namespace); err != nil {
+			return err
+		}
+		if _, ok := v.aliases[namespace]; ok {
+			log.Warnf("[vectorfs] Alias %s hides the namespace of the same name", namespace)
+		}
+	}
+
 	// Initialize embedding client
 	embeddingConfig := EmbeddingConfig{
-		Provider: config.GetStringConfig(cfg, "embedding_provider", "openai"),
-		APIKey:   config.GetStringConfig(cfg, "openai_api_key", ""),
-		Model:    config.GetStringConfig(cfg, "embedding_model", "text-embedding-3-small"),
-		Dimension: config.GetIntConfig(cfg, "embedding_dim", 1536),
+		Provider:    config.GetStringConfig(cfg, "embedding_provider", "openai"),
+		APIKey:      config.GetStringConfig(cfg, "openai_api_key", ""),
+		APIBase:     config.GetStringConfig(cfg, "embedding_api_base", ""),
+		Model:       config.GetStringConfig(cfg, "embedding_model", "text-embedding-3-small"),
+		Dimension:   config.GetIntConfig(cfg, "embedding_dim", 1536),
+		BatchSize:   config.GetIntConfig(cfg, "embedding_batch_size", 0),
+		Concurrency: config.GetIntConfig(cfg, "embedding_concurrency", 0),
+	}
+	if embeddingConfig.Provider == "http" {
+		embeddingConfig.APIKey = config.GetStringConfig(cfg, "embedding_api_key", "")
 	}
 
 	embeddingClient, err := NewEmbeddingClient(embeddingConfig)
 	if err != nil {
 		return fmt.Errorf("failed to initialize embedding client: %w", err)
 	}
+	limits, err := budgetLimitsFromConfig(cfg)
+	if err != nil {
+		return err
+	}
+	v.budget = newEmbeddingBudget(limits)
+	embeddingClient.budget = v.budget
 	v.embeddingClient = embeddingClient
+	if v.policy, err = newWritePolicy(cfg); err != nil {
+		return err
+	}
 
 	// Initialize indexer
 	chunkerConfig := ChunkerConfig{
@@ -160,8 +310,40 @@ func (v *VectorFSPlugin) Initialize(cfg map[string]interface{}) error {
 
 	v.indexer = NewIndexer(v.s3Client, v.tidbClient, v.embeddingClient, chunkerConfig)
 
+	// Route languages to their own embedding models; the models must
+	// produce embeddings of the same dimension
+	models, err := languageModels(cfg)
+	if err != nil {
+		return err
+	}
+	v.indexer.languageClients = make(map[string]*EmbeddingClient, len(models))
+	for language, model := range models {
+		languageConfig := embeddingConfig
+		languageConfig.Model = model
+		client, err := NewEmbeddingClient(languageConfig)
+		if err != nil {
+			return fmt.Errorf("failed to initialize embedding client for language %s: %w", language, err)
+		}
+		client.budget = v.budget
+		client.sem = embeddingClient.sem // Same server: share its concurrency
+		v.indexer.languageClients[language] = client
+	}
+
+	v.summarizer, err = newSummarizer(cfg)
+	if err != nil {
+		return err
+	}
+	v.ocr, err = newOCR(cfg)
+	if err != nil {
+		return err
+	}
+
 	// Initialize indexing status tracking
 	v.indexingStatus = make(map[string]map[string]*indexingFileInfo)
+	v.transfers = make(map[string]*transferStatus)
+	v.reindexing = make(map[string]bool)
+	v.mountPath = config.GetStringConfig(cfg, "mount_path", "/vectorfs")
+	v.reindexRate = config.GetFloat64Config(cfg, "reindex_rate", defaultReindexRate)
 
 	// Initialize worker pool for async indexing
 	workerCount := config.GetIntConfig(cfg, "index_workers", 4)
@@ -174,6 +356,14 @@ func (v *VectorFSPlugin) Initialize(cfg map[string]interface{}) error {
 		go v.indexWorker(i)
 	}
 
+	sources, err := sourcesFromConfig(cfg)
+	if err != nil {
+		return err
+	}
+	if err := v.startSources(sources); err != nil {
+		return err
+	}
+
 	log.Infof("[vectorfs] Initialized successfully with %d index workers", workerCount)
 	return nil
 }
@@ -192,6 +382,31 @@ func (v *VectorFSPlugin) addIndexingTask(namespace, digest, fileName string) {
 	}
 }
 
+// queueIndexTask queues a document for indexing
+func (v *VectorFSPlugin) queueIndexTask(task indexTask) {
+	// Register task in indexing status before queuing
+	v.addIndexingTask(task.namespace, task.digest, task.fileName)
+
+	// Non-blocking send to queue with proper overflow handling
+	select {
+	case v.indexQueue <- task:
+		// Task queued successfully
+	default:
+		// Queue is full - use a goroutine with shutdown awareness to avoid leak
+		log.Warnf("[vectorfs] Index queue full, document %s will be indexed when queue has space", task.fileName)
+		go func(t indexTask) {
+			select {
+			case v.indexQueue <- t:
+				// Task eventually queued
+			case <-v.shutdown:
+				// System shutting down, remove from indexing status
+				v.removeIndexingTask(t.namespace, t.digest)
+				log.Warnf("[vectorfs] Shutdown while waiting to queue %s, task dropped", t.fileName)
+			}
+		}(task)
+	}
+}
+
 // removeIndexingTask removes a file from the indexing status
 func (v *VectorFSPlugin) removeIndexingTask(namespace, digest string) {
 	v.indexingStatusMu.Lock()
@@ -205,6 +420,14 @@ func (v *VectorFSPlugin) removeIndexingTask(namespace, digest string) {
 	}
 }
 
+// isIndexing checks if the content with digest is being indexed
+func (v *VectorFSPlugin) isIndexing(namespace, digest string) bool {
+	v.indexingStatusMu.RLock()
+	defer v.indexingStatusMu.RUnlock()
+	_, ok := v.indexingStatus[namespace][digest]
+	return ok
+}
+
 // getIndexingStatus returns the indexing status for a namespace
 func (v *VectorFSPlugin) getIndexingStatus(namespace string) string {
 	v.indexingStatusMu.RLock()
@@ -233,19 +456,46 @@ func (v *VectorFSPlugin) indexWorker(id int) {
 	for {
 		select {
 		case <-v.shutdown:
-			log.Debugf("[vectorfs] Index worker %d shutting down", id)
-			return
-		case task := <-v.indexQueue:
-			err := v.indexer.IndexChunks(task.namespace, task.digest, task.fileName, task.data)
-			if err != nil {
-				log.Errorf("[vectorfs] Worker %d failed to index chunks for %s: %v", id, task.fileName, err)
+			// Flush what is already queued so that written documents
+			// are not left unindexed
+			for {
+				select {
+				case task := <-v.indexQueue:
+					v.indexTask(id, task)
+				default:
+					log.Debugf("[vectorfs] Index worker %d shutting down", id)
+					return
+				}
 			}
-			// Remove from indexing status regardless of success/failure
-			v.removeIndexingTask(task.namespace, task.digest)
+		case task := <-v.indexQueue:
+			v.indexTask(id, task)
 		}
 	}
 }
 
+// indexTask indexes the chunks of one queued document; content that was
+// overwritten or deleted meanwhile is not indexed (see Indexer.StoreChunks)
+func (v *VectorFSPlugin) indexTask(worker int, task indexTask) {
+	// Remove from indexing status regardless of success/failure
+	defer v.removeIndexingTask(task.namespace, task.digest)
+
+	// Skip generating embeddings for content that is already gone
+	if referenced, err := v.tidbClient.FileExists(task.namespace, task.digest); err == nil && !referenced {
+		log.Infof("[vectorfs] Worker %d skipped %s: the file was overwritten or deleted", worker, task.fileName)
+		return
+	}
+
+	if task.ocrKind != "" && !v.recognizeTask(worker, &task) {
+		return
+	}
+	if err := v.indexer.IndexChunks(task.namespace, task.digest, task.fileName, task.data, task.language); err != nil {
+		log.Errorf("[vectorfs] Worker %d failed to index chunks for %s: %v", worker, task.fileName, err)
+	}
+	if v.summarizer != nil {
+		v.summarizeTask(worker, task)
+	}
+}
+
 func (v *VectorFSPlugin) GetFileSystem() filesystem.FileSystem {
 	return &vectorFS{plugin: v}
 }
@@ -256,14 +506,25 @@ func (v *VectorFSPlugin) GetReadme() string {
 This plugin provides semantic search capabilities for documents using:
 - S3 for document storage
 - TiDB Cloud vector index for fast similarity search
-- OpenAI embeddings (default)
+- OpenAI embeddings (default), or a self-hosted embedding server
 
 STRUCTURE:
   /vectorfs/
     README              - This documentation
+    .stats              - Embedding usage of all namespaces, and its caps
     <namespace>/        - Project/namespace directory
-      docs/             - Document directory (auto-indexed on write)
+    <alias>/            - Namespace alias (search only)
+      .members          - Namespaces of the alias
+      docs/             - Document directory (auto-indexed on write; images and
+                          scanned PDFs too, with ocr_enabled)
+        .chunks/<file>  - Stored chunks of a document, with offsets and embedding status
       .indexing         - Indexing status (virtual file)
+      .export           - Write to export the namespace to S3, read for status
+      .import           - Write an export's S3 location to import it, read for status
+      .reindex          - Write to re-chunk and re-embed all documents, read for status
+      .sources          - Status of the sources synced into docs/, write to sync now
+      .stats            - Embedding requests and tokens of the namespace, and its caps
+      chunks/<id>       - Chunk cited by its stable ID (chunk_id of search results)
 
 WORKFLOW:
   1. Create a namespace (project):
@@ -276,10 +537,38 @@ WORKFLOW:
      grep 'how to deploy' /vectorfs/my_project/docs
 
      This will perform vector similarity search and return relevant chunks.
+     Add lang:<code> to only search documents in some languages:
+     grep 'lang:zh,ja 部署' /vectorfs/my_project/docs
+
+     Add k:<n> to return up to n results, and min_score:<s> to only return
+     results with a similarity of at least s (0 to 1):
+     grep 'k:20 min_score:0.75 how to deploy' /vectorfs/my_project/docs
+
+     Search at the root to search all namespaces, or in an alias to search
+     its namespaces; results are merged by score:
+     grep 'how to deploy' /vectorfs/
+     grep 'how to deploy' /vectorfs/all_docs
 
   4. Read indexed documents:
      cat /vectorfs/my_project/docs/document.txt
 
+  5. With summary_enabled, skim documents through their summaries:
+     cat /vectorfs/my_project/docs/.summaries/document.txt.md
+
+  6. Check what was indexed of a document, when search results look wrong:
+     cat /vectorfs/my_project/docs/.chunks/document.txt
+
+  7. Export a namespace, and import it into another one or another server
+     without re-embedding its documents:
+     echo > /vectorfs/my_project/.export
+     cat /vectorfs/my_project/.export          # location: s3://...
+     echo s3://bucket/key > /vectorfs/other_project/.import
+
+  8. After changing chunk_size or embedding_model, reindex a namespace in
+     the background (optionally at another rate of embedding requests):
+     echo rate=2 > /vectorfs/my_project/.reindex
+     cat /vectorfs/my_project/.reindex         # state, progress: 12/40
+
 CONFIGURATION:
   [plugins.vectorfs]
   enabled = true
@@ -302,14 +591,58 @@ CONFIGURATION:
     embedding_model = "text-embedding-3-small"
     embedding_dim = 1536
 
+    # Or a self-hosted server with an OpenAI-compatible API (TEI, vLLM)
+    # embedding_provider = "http"
+    # embedding_api_base = "http://localhost:8081/v1"
+    # embedding_batch_size = 32
+    # embedding_concurrency = 4
+
     # Chunking (optional)
     chunk_size = 512
     chunk_overlap = 50
 
+    # Embedding requests per second of reindexes (optional, 0 for no limit)
+    reindex_rate = 5
+
+    # Caps on embedding requests and tokens over the last hour, of all
+    # namespaces and of each (optional, 0 for no cap); requests beyond them
+    # fail with "quota exceeded" until the hour frees up
+    embedding_max_tokens_per_hour = 2000000
+    embedding_namespace_max_requests_per_hour = 1000
+
+    # Documents accepted in docs/ (optional); binary content is rejected
+    # unless reject_binary = false, except images and PDFs when OCR is on
+    max_document_size = "10MB"
+    allowed_extensions = [".md", ".txt", ".pdf"]
+    allowed_content_types = ["text/*", "application/pdf"]
+
+    # Document summaries (optional)
+    summary_enabled = true
+    summary_model = "gpt-4o-mini"
+
+    # Text recognition of images and scanned PDFs (optional): the tesseract
+    # command (and poppler-utils for PDFs), or ocr_provider = "google" with
+    # ocr_api_key for Google Cloud Vision
+    ocr_enabled = true
+    ocr_languages = "eng+chi_sim"
+
+    # Embedding models of documents in some languages (optional)
+    [plugins.vectorfs.config.language_models]
+    zh = "my-chinese-embedding-model"
+
+    # Namespaces searched together under an alias (optional)
+    [plugins.vectorfs.config.namespace_aliases]
+    all_docs = ["wiki", "tickets", "runbooks"]
+
 FEATURES:
   - Automatic indexing on file write
+  - Size, extension and content type checks on write, rejecting binary
+    blobs before they are chunked and embedded
   - Deduplication using file digest (SHA256)
-  - Semantic search via grep command
+  - Language detection, with per-language search filters and models
+  - OCR of images and scanned PDFs (optional)
+  - Semantic search via grep command, across namespaces at the root or
+    in namespace aliases
   - S3 storage for scalability
   - TiDB Cloud vector index for fast search
 
@@ -325,35 +658,106 @@ func (v *VectorFSPlugin) GetConfigParams() []plugin.ConfigParameter {
 	return []plugin.ConfigParameter{
 		// S3 parameters
 		{Name: "s3_access_key", Type: "string", Required: false, Default: "", Description: "S3 access key"},
-		{Name: "s3_secret_key", Type: "string", Required: false, Default: "", Description: "S3 secret key"},
+		{Name: "s3_secret_key", Type: "string", Required: false, Default: "", Description: "S3 secret key", Secret: true},
 		{Name: "s3_bucket", Type: "string", Required: true, Default: "", Description: "S3 bucket name"},
 		{Name: "s3_key_prefix", Type: "string", Required: false, Default: "vectorfs", Description: "S3 key prefix"},
 		{Name: "s3_region", Type: "string", Required: false, Default: "us-east-1", Description: "S3 region"},
 		{Name: "s3_endpoint", Type: "string", Required: false, Default: "", Description: "Custom S3 endpoint"},
 		// TiDB parameters
-		{Name: "tidb_dsn", Type: "string", Required: true, Default: "", Description: "TiDB connection string (DSN)"},
+		{Name: "tidb_dsn", Type: "string", Required: true, Default: "", Description: "TiDB connection string (DSN)", Secret: true},
 		// Embedding parameters
-		{Name: "embedding_provider", Type: "string", Required: false, Default: "openai", Description: "Embedding provider (openai)"},
-		{Name: "openai_api_key", Type: "string", Required: true, Default: "", Description: "OpenAI API key"},
-		{Name: "embedding_model", Type: "string", Required: false, Default: "text-embedding-3-small", Description: "OpenAI embedding model"},
+		{Name: "embedding_provider", Type: "string", Required: false, Default: "openai", Description: "Embedding provider: the OpenAI API, or a self-hosted server with an OpenAI-compatible API (text-embeddings-inference, vLLM)", Enum: []string{"openai", "http"}},
+		{Name: "openai_api_key", Type: "string", Required: false, Default: "", Description: "OpenAI API key (required with the openai provider)", Secret: true},
+		{Name: "embedding_model", Type: "string", Required: false, Default: "text-embedding-3-small", Description: "Embedding model"},
 		{Name: "embedding_dim", Type: "int", Required: false, Default: "1536", Description: "Embedding dimension"},
+		{Name: "embedding_api_base", Type: "string", Required: false, Default: "", Description: "API base URL of the http provider, e.g. http://localhost:8081/v1 (required with it)"},
+		{Name: "embedding_api_key", Type: "string", Required: false, Default: "", Description: "Bearer token of the http provider, if its server requires one", Secret: true},
+		{Name: "embedding_batch_size", Type: "int", Required: false, Default: "0", Description: "Texts per embedding request, 0 for no limit (http provider: 32)", Minimum: plugin.Bound(0)},
+		{Name: "embedding_concurrency", Type: "int", Required: false, Default: "0", Description: "Embedding requests in flight, 0 for no limit (http provider: 4)", Minimum: plugin.Bound(0)},
 		// Chunking parameters
 		{Name: "chunk_size", Type: "int", Required: false, Default: "512", Description: "Chunk size in tokens"},
 		{Name: "chunk_overlap", Type: "int", Required: false, Default: "50", Description: "Chunk overlap in tokens"},
 		// Worker pool parameters
 		{Name: "index_workers", Type: "int", Required: false, Default: "4", Description: "Number of concurrent indexing workers"},
+		// Reindex parameters
+		{Name: "reindex_rate", Type: "float", Required: false, Default: "5", Description: "Embedding requests per second of reindexes (0 for no limit)"},
+		// Embedding caps
+		{Name: "embedding_max_requests_per_hour", Type: "int", Required: false, Default: "0", Description: "Embedding requests of all namespaces in the last hour (0 for no cap)"},
+		{Name: "embedding_max_tokens_per_hour", Type: "int", Required: false, Default: "0", Description: "Embedding tokens of all namespaces in the last hour (0 for no cap)"},
+		{Name: "embedding_namespace_max_requests_per_hour", Type: "int", Required: false, Default: "0", Description: "Embedding requests of each namespace in the last hour (0 for no cap)"},
+		{Name: "embedding_namespace_max_tokens_per_hour", Type: "int", Required: false, Default: "0", Description: "Embedding tokens of each namespace in the last hour (0 for no cap)"},
+		// Write policy
+		{Name: "max_document_size", Type: "string", Required: false, Default: "0", Description: "Maximum size of a document written to docs/, e.g. 10MB (0 for no limit)"},
+		{Name: "allowed_extensions", Type: "array", Required: false, Default: "", Description: "Extensions of the documents accepted, e.g. [\".md\", \".txt\"] (empty for any)"},
+		{Name: "allowed_content_types", Type: "array", Required: false, Default: "", Description: "Detected MIME types of the documents accepted, e.g. [\"text/*\"] (empty for any)"},
+		{Name: "reject_binary", Type: "bool", Required: false, Default: "true", Description: "Reject documents that are not text (NUL bytes or invalid UTF-8), except images and PDFs when OCR is enabled"},
+		// Language parameters
+		// Summary parameters
+		{Name: "summary_enabled", Type: "bool", Required: false, Default: "false", Description: "Generate a summary of each document at index time, in docs/.summaries/"},
+		{Name: "summary_provider", Type: "string", Required: false, Default: "openai", Description: "LLM provider of summaries", Enum: []string{"openai", "anthropic"}},
+		{Name: "summary_model", Type: "string", Required: false, Default: "gpt-4o-mini", Description: "Model generating summaries"},
+		{Name: "summary_api_key", Type: "string", Required: false, Default: "", Description: "API key of the summary provider (default: openai_api_key for openai, else $ANTHROPIC_API_KEY)", Secret: true},
+		{Name: "summary_api_base", Type: "string", Required: false, Default: "", Description: "Custom API base URL of the summary provider"},
+		{Name: "summary_max_tokens", Type: "int", Required: false, Default: "400", Description: "Maximum length of a summary in tokens"},
+		// OCR parameters
+		{Name: "ocr_enabled", Type: "bool", Required: false, Default: "false", Description: "Recognize and index the text of images and scanned PDFs written to docs/"},
+		{Name: "ocr_provider", Type: "string", Required: false, Default: "tesseract", Description: "OCR provider: the tesseract command, or Google Cloud Vision", Enum: []string{"tesseract", "google"}},
+		{Name: "ocr_command", Type: "string", Required: false, Default: "tesseract", Description: "Path of the tesseract command (PDFs also need pdftotext and pdftoppm)"},
+		{Name: "ocr_languages", Type: "string", Required: false, Default: "eng", Description: "Tesseract languages of the documents, e.g. eng+chi_sim"},
+		{Name: "ocr_api_key", Type: "string", Required: false, Default: "", Description: "Google Cloud Vision API key", Secret: true},
+		{Name: "ocr_api_base", Type: "string", Required: false, Default: "https://vision.googleapis.com/v1", Description: "Custom API base URL of Google Cloud Vision"},
+		{Name: "language_models", Type: "map", Required: false, Default: "", Description: "Embedding models of documents in some languages: language -> model (same dimension as embedding_model)"},
+		{Name: "namespace_aliases", Type: "map", Required: false, Default: "", Description: "Namespaces searched together under an alias: alias -> list of namespaces"},
+		{Name: "sources", Type: "map", Required: false, Default: "", Description: "External sources synced into docs/ on a schedule: namespace -> list of sources (s3 prefix, git repo, urls/sitemap)"},
 	}
 }
 
+// HealthCheck reports whether TiDB is reachable; while it is not, the mount
+// fails fast instead of returning driver errors
+func (v *VectorFSPlugin) HealthCheck() error {
+	v.mu.RLock()
+	client := v.tidbClient
+	v.mu.RUnlock()
+
+	if client == nil {
+		return nil
+	}
+	if err := client.Ping(); err != nil {
+		return fmt.Errorf("TiDB unreachable: %w", err)
+	}
+	return nil
+}
+
+// Probe checks TiDB, the S3 bucket and the embedding provider's credentials
+func (v *VectorFSPlugin) Probe(ctx context.Context) []plugin.ProbeResult {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	results := []plugin.ProbeResult{
+		{Backend: "tidb", Err: v.tidbClient.Ping()},
+		{Backend: "s3 bucket", Err: v.s3Client.CheckBucket(ctx)},
+		{Backend: v.embeddingClient.probeName(), Err: v.embeddingClient.CheckAuth(ctx)},
+	}
+	if v.ocr != nil {
+		results = append(results, plugin.ProbeResult{Backend: "ocr", Err: v.ocr.check(ctx)})
+	}
+	return results
+}
+
 func (v *VectorFSPlugin) Shutdown() error {
 	v.mu.Lock()
 	defer v.mu.Unlock()
 
-	// Shutdown worker pool
+	// Shutdown worker pool; workers flush the queue before exiting. The
+	// queue is not closed, since writes racing with shutdown may still send.
 	if v.shutdown != nil {
+		if queued := len(v.indexQueue); queued > 0 {
+			log.Infof("[vectorfs] Flushing %d queued index task(s)", queued)
+		}
 		close(v.shutdown)
-		close(v.indexQueue)
-		v.workerWg.Wait() // Wait for all workers to finish
+		v.transferWg.Wait() // Exports and imports stop at the next content
+		v.stopSources()     // Syncs stop at the next document
+		v.workerWg.Wait()   // Wait for all workers to finish
 		log.Info("[vectorfs] All index workers shut down")
 	}
 
@@ -364,7 +768,9 @@ func (v *VectorFSPlugin) Shutdown() error {
 	return nil
 }
 
-// CustomGrep implements the CustomGrepper interface using vector search
+// CustomGrep implements the CustomGrepper interface using vector search. A
+// search at the root searches all namespaces, and one in an alias all of
+// its namespaces; their results are merged by score.
 func (vfs *vectorFS) CustomGrep(path, query string, limit int) ([]mountablefs.CustomGrepResult, error) {
 	// Parse path to get namespace
 	namespace, relativePath, err := parsePath(path)
@@ -372,42 +778,136 @@ func (vfs *vectorFS) CustomGrep(path, query string, limit int) ([]mountablefs.Cu
 		return nil, err
 	}
 
-	// Only support search in docs/ directory
-	if !strings.HasPrefix(relativePath, "docs") && relativePath != "docs" {
-		return nil, fmt.Errorf("vector search only supported in docs/ directory")
+	// Only support search in docs/ directories
+	namespaces, err := vfs.searchedNamespaces(namespace, relativePath)
+	if err != nil {
+		return nil, err
 	}
 
-	// Use VectorSearch method (dependency injection point)
-	return vfs.VectorSearch(namespace, query, limit)
+	return vfs.searchNamespaces(namespaces, query, limit)
+}
+
+// searchOptions are the options of a search given in its query
+type searchOptions struct {
+	limit    int     // Maximum number of results, 0 for the limit of the request
+	minScore float64 // Minimum similarity (1 - cosine distance) of results
+}
+
+// parseSearchOptions splits the options out of a search query: "k:20" returns
+// up to 20 results instead of the limit of the request, and
+// "min_score:0.75" only results with a similarity of at least 0.75, so
+// that agents can trade recall for noise
+func parseSearchOptions(query string) (string, searchOptions, error) {
+	var opts searchOptions
+	var words []string
+	for _, word := range strings.Fields(query) {
+		if v, ok := strings.CutPrefix(word, "k:"); ok && v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				return "", opts, filesystem.NewInvalidArgumentError("query", word, "k must be a positive number of results")
+			}
+			opts.limit = n
+			continue
+		}
+		if v, ok := strings.CutPrefix(word, "min_score:"); ok && v != "" {
+			score, err := strconv.ParseFloat(v, 64)
+			if err != nil || score < -1 || score > 1 {
+				return "", opts, filesystem.NewInvalidArgumentError("query", word, "min_score must be a similarity between -1 and 1")
+			}
+			opts.minScore = score
+			continue
+		}
+		words = append(words, word)
+	}
+	return strings.Join(words, " "), opts, nil
 }
 
 // VectorSearch performs vector similarity search using embeddings
 // This method can be injected/replaced for testing or alternative implementations
 // limit specifies the maximum number of results to return
+// The query may restrict the search to some languages (see parseSearchQuery),
+// and set its own limit and minimum score (see parseSearchOptions).
 func (vfs *vectorFS) VectorSearch(namespace, query string, limit int) ([]mountablefs.CustomGrepResult, error) {
-	// Generate embedding for query
-	queryEmbedding, err := vfs.plugin.embeddingClient.GenerateEmbedding(query)
+	return vfs.searchNamespaces([]string{namespace}, query, limit)
+}
+
+// namespaceMatch is a search result in one of the namespaces searched
+type namespaceMatch struct {
+	VectorMatch
+	namespace string
+}
+
+// searchNamespaces performs a vector search in namespaces, whose results
+// are merged by score
+func (vfs *vectorFS) searchNamespaces(namespaces []string, query string, limit int) ([]mountablefs.CustomGrepResult, error) {
+	query, opts, err := parseSearchOptions(query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
+		return nil, err
+	}
+	if opts.limit > 0 {
+		limit = opts.limit
+	}
+	text, languages := parseSearchQuery(query)
+	if text == "" {
+		return nil, fmt.Errorf("search query is empty")
 	}
 
-	// Perform vector search in TiDB
-	results, err := vfs.plugin.tidbClient.VectorSearch(namespace, queryEmbedding, limit)
-	if err != nil {
-		return nil, fmt.Errorf("failed to perform vector search: %w", err)
+	// Searches of several namespaces only count on the global budget
+	var budgetNamespace string
+	if len(namespaces) == 1 {
+		budgetNamespace = namespaces[0]
+	}
+
+	// Embeddings of different models cannot be compared, so documents are
+	// searched per model with an embedding of the query by that model
+	var results []namespaceMatch
+	for _, search := range vfs.plugin.indexer.searchesFor(languages) {
+		start := time.Now()
+		queryEmbedding, err := search.client.GenerateEmbedding(budgetNamespace, text)
+		vfs.trace.Span("embedding", start)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate query embedding: %w", err)
+		}
+
+		for _, namespace := range namespaces {
+			start = time.Now()
+			matches, err := vfs.plugin.tidbClient.VectorSearch(namespace, queryEmbedding, limit, search.filter)
+			vfs.trace.Span("tidb search", start)
+			if err != nil {
+				return nil, fmt.Errorf("failed to perform vector search in %s: %w", namespace, err)
+			}
+			for _, match := range matches {
+				results = append(results, namespaceMatch{VectorMatch: match, namespace: namespace})
+			}
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Distance < results[j].Distance })
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	if opts.minScore != 0 {
+		n := sort.Search(len(results), func(i int) bool { return 1.0-results[i].Distance < opts.minScore })
+		results = results[:n]
 	}
 
 	// Convert to CustomGrepResult format
 	var matches []mountablefs.CustomGrepResult
 	for _, result := range results {
+		metadata := map[string]interface{}{
+			"distance": result.Distance,
+			"score":    1.0 - result.Distance, // Convert distance to similarity score
+		}
+		if result.Language != "" {
+			metadata["language"] = result.Language
+		}
+		id := chunkID(result.ChunkText)
+		metadata["chunk_id"] = id
+		metadata["permalink"] = vfs.plugin.permalinkPath(result.namespace, id)
 		matches = append(matches, mountablefs.CustomGrepResult{
-			File:    namespace + "/docs/" + result.FileName,
-			Line:    result.ChunkIndex + 1, // 1-indexed line numbers
-			Content: result.ChunkText,
-			Metadata: map[string]interface{}{
-				"distance": result.Distance,
-				"score":    1.0 - result.Distance, // Convert distance to similarity score
-			},
+			File:     result.namespace + "/docs/" + result.FileName,
+			Line:     result.ChunkIndex + 1, // 1-indexed line numbers
+			Content:  result.ChunkText,
+			Metadata: metadata,
 		})
 	}
 
@@ -417,6 +917,12 @@ func (vfs *vectorFS) VectorSearch(namespace, query string, limit int) ([]mountab
 // vectorFS implements the FileSystem interface for vector operations
 type vectorFS struct {
 	plugin *VectorFSPlugin
+	trace  *filesystem.OpTrace // Backend calls of the current operation, nil if not traced
+}
+
+// WithTrace implements filesystem.Traceable
+func (vfs *vectorFS) WithTrace(trace *filesystem.OpTrace) filesystem.FileSystem {
+	return &vectorFS{plugin: vfs.plugin, trace: trace}
 }
 
 // parsePath parses a path like "/namespace/docs/file.txt" into (namespace, "docs/file.txt")
@@ -454,6 +960,10 @@ func (vfs *vectorFS) Mkdir(path string, perm uint32) error {
 		return err
 	}
 
+	if _, ok := vfs.plugin.aliases[namespace]; ok {
+		return aliasError(namespace)
+	}
+
 	// If creating subdirectory under docs/, create a placeholder file
 	// so the directory is visible in listings and Stat operations
 	if relativePath != "" {
@@ -479,7 +989,31 @@ func (vfs *vectorFS) Mkdir(path string, perm uint32) error {
 }
 
 func (vfs *vectorFS) Remove(path string) error {
-	return fmt.Errorf("remove not supported in vectorfs (use rm -r to delete entire namespace)")
+	namespace, relativePath, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := vfs.plugin.aliases[namespace]; ok {
+		return aliasError(namespace)
+	}
+
+	// Only documents can be removed individually
+	fileName := strings.TrimPrefix(relativePath, "docs/")
+	if isSummaryPath(relativePath) {
+		return fmt.Errorf("summaries are read-only, remove the document instead")
+	}
+	if isChunksPath(relativePath) {
+		return fmt.Errorf("chunks are read-only, remove the document instead")
+	}
+	if !strings.HasPrefix(relativePath, "docs/") || fileName == "" {
+		return fmt.Errorf("can only remove files in docs/ (use rm -r to delete entire namespace)")
+	}
+
+	writeKey := lockKey(namespace, fileName)
+	vfs.plugin.writes.lock(writeKey)
+	defer vfs.plugin.writes.unlock(writeKey)
+	return vfs.plugin.indexer.RemoveDocument(namespace, fileName)
 }
 
 func (vfs *vectorFS) RemoveAll(path string) error {
@@ -488,6 +1022,10 @@ func (vfs *vectorFS) RemoveAll(path string) error {
 		return err
 	}
 
+	if _, ok := vfs.plugin.aliases[namespace]; ok {
+		return aliasError(namespace)
+	}
+
 	// Only allow removing entire namespace (not subdirectories)
 	if relativePath != "" {
 		return fmt.Errorf("can only remove entire namespace, not subdirectories (path: %s)", path)
@@ -507,23 +1045,83 @@ func (vfs *vectorFS) Read(path string, offset int64, size int64) ([]byte, error)
 		data := []byte(vfs.plugin.GetReadme())
 		return plugin.ApplyRangeRead(data, offset, size)
 	}
+	if path == "/"+statsFile {
+		return plugin.ApplyRangeRead([]byte(vfs.plugin.budget.stats("")), offset, size)
+	}
 
 	namespace, relativePath, err := parsePath(path)
 	if err != nil {
 		return nil, err
 	}
 
+	if _, ok := vfs.plugin.aliases[namespace]; ok {
+		if relativePath != membersFile {
+			return nil, aliasError(namespace)
+		}
+		return plugin.ApplyRangeRead([]byte(vfs.plugin.aliasMembers(namespace)), offset, size)
+	}
+
 	// Handle virtual .indexing file
 	if relativePath == ".indexing" {
 		status := vfs.plugin.getIndexingStatus(namespace)
 		return []byte(status), nil
 	}
 
+	// Export and import status
+	if relativePath == exportFile || relativePath == importFile {
+		status := vfs.plugin.getTransferStatus(namespace, relativePath)
+		return plugin.ApplyRangeRead([]byte(status), offset, size)
+	}
+	if relativePath == reindexFile {
+		return plugin.ApplyRangeRead([]byte(vfs.plugin.getReindexStatus(namespace)), offset, size)
+	}
+	if relativePath == statsFile {
+		return plugin.ApplyRangeRead([]byte(vfs.plugin.budget.stats(namespace)), offset, size)
+	}
+	if relativePath == sourcesFile {
+		return plugin.ApplyRangeRead([]byte(vfs.plugin.getSourcesStatus(namespace)), offset, size)
+	}
+
+	// Chunks cited by their stable IDs
+	if id, ok := strings.CutPrefix(relativePath, permalinkDir+"/"); ok {
+		data, _, err := vfs.readPermalink(namespace, id)
+		if err != nil {
+			return nil, err
+		}
+		return plugin.ApplyRangeRead(data, offset, size)
+	}
+
 	// Only allow reading from docs/ directory
 	if !strings.HasPrefix(relativePath, "docs/") {
 		return nil, fmt.Errorf("can only read files from docs/ directory")
 	}
 
+	// Summaries
+	if isSummaryPath(relativePath) {
+		fileName, ok := summaryFileName(strings.TrimPrefix(relativePath, "docs/"+summariesDir+"/"))
+		if !ok {
+			return nil, fmt.Errorf("cannot read directory, specify a file")
+		}
+		summary, _, err := vfs.plugin.tidbClient.GetSummary(namespace, fileName)
+		if err != nil {
+			return nil, err
+		}
+		return plugin.ApplyRangeRead([]byte(summary), offset, size)
+	}
+
+	// Chunks of a document
+	if isChunksPath(relativePath) {
+		fileName := chunksFileName(relativePath)
+		if fileName == "" {
+			return nil, fmt.Errorf("cannot read directory, specify a file")
+		}
+		report, err := vfs.readChunksReport(namespace, fileName)
+		if err != nil {
+			return nil, err
+		}
+		return plugin.ApplyRangeRead(report, offset, size)
+	}
+
 	// Extract filename from path (support subdirectories)
 	// relativePath format: "docs/subdir/file.txt" or "docs/file.txt"
 	fileName := strings.TrimPrefix(relativePath, "docs/")
@@ -532,14 +1130,18 @@ func (vfs *vectorFS) Read(path string, offset int64, size int64) ([]byte, error)
 	}
 
 	// Get file metadata from TiDB (includes S3 key and digest)
+	start := time.Now()
 	meta, err := vfs.plugin.tidbClient.GetFileMetadataByName(namespace, fileName)
+	vfs.trace.Span("tidb", start)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get file metadata: %w", err)
 	}
 
 	// Download document from S3 using digest
 	ctx := context.Background()
+	start = time.Now()
 	data, err := vfs.plugin.s3Client.DownloadDocument(ctx, namespace, meta.FileDigest)
+	vfs.trace.Span("s3 download", start)
 	if err != nil {
 		return nil, fmt.Errorf("failed to download document from S3: %w", err)
 	}
@@ -562,11 +1164,63 @@ func (vfs *vectorFS) Write(path string, data []byte, offset int64, flags filesys
 
 	log.Debugf("[vectorfs] Write parsed: namespace=%s, relativePath=%s", namespace, relativePath)
 
+	if _, ok := vfs.plugin.aliases[namespace]; ok {
+		return 0, aliasError(namespace)
+	}
+
+	// Start an export or import
+	switch relativePath {
+	case exportFile:
+		location, err := vfs.plugin.StartExport(namespace, strings.TrimSpace(string(data)))
+		if err != nil {
+			return 0, err
+		}
+		log.Infof("[vectorfs] Exporting namespace %s to %s", namespace, location)
+		return int64(len(data)), nil
+	case importFile:
+		if err := vfs.plugin.StartImport(namespace, strings.TrimSpace(string(data))); err != nil {
+			return 0, err
+		}
+		log.Infof("[vectorfs] Importing %s into namespace %s", strings.TrimSpace(string(data)), namespace)
+		return int64(len(data)), nil
+	case reindexFile:
+		args, err := parseReindexArgs(string(data))
+		if err != nil {
+			return 0, err
+		}
+		task, err := vfs.plugin.StartReindex(namespace, args)
+		if err != nil {
+			return 0, err
+		}
+		log.Infof("[vectorfs] Reindexing namespace %s (task %d)", namespace, task.ID)
+		return int64(len(data)), nil
+	case sourcesFile:
+		if err := vfs.plugin.SyncSources(namespace, strings.TrimSpace(string(data))); err != nil {
+			return 0, err
+		}
+		return int64(len(data)), nil
+	}
+
 	// Only allow writing to docs/ directory
 	if !strings.HasPrefix(relativePath, "docs/") {
 		log.Errorf("[vectorfs] Write rejected: path=%s not in docs/", path)
 		return 0, fmt.Errorf("can only write files to docs/ directory")
 	}
+	if isSummaryPath(relativePath) {
+		return 0, fmt.Errorf("summaries are read-only, they are generated from documents")
+	}
+	if isChunksPath(relativePath) {
+		return 0, fmt.Errorf("chunks are read-only, they are generated from documents")
+	}
+
+	var kind string
+	if vfs.plugin.ocr != nil {
+		kind = ocrKind(data)
+	}
+	if err := vfs.plugin.policy.check(path, data, kind != ""); err != nil {
+		log.Warnf("[vectorfs] Write rejected: path=%s: %v", path, err)
+		return 0, err
+	}
 
 	// Calculate file digest - include filename for empty files to avoid collision
 	// (all empty files would have the same content hash otherwise)
@@ -584,19 +1238,24 @@ func (vfs *vectorFS) Write(path string, data []byte, offset int64, flags filesys
 	// relativePath format: "docs/subdir/file.txt" -> fileName: "subdir/file.txt"
 	fileName := strings.TrimPrefix(relativePath, "docs/")
 	content := string(data)
+	// The language of images and scanned PDFs is that of their text,
+	// detected once it is recognized
+	var language string
+	if kind == "" {
+		language = detectLanguage(content)
+	}
 
 	log.Debugf("[vectorfs] Write: namespace=%s, fileName=%s, digest=%s, len=%d", namespace, fileName, digest[:16], len(data))
 
-	// Delete any existing versions of this file before writing new content
-	// This prevents duplicate entries with different digests for the same filename
-	if err := vfs.plugin.tidbClient.DeleteFileByName(namespace, fileName); err != nil {
-		log.Warnf("[vectorfs] Failed to delete old versions of %s: %v", fileName, err)
-		// Continue anyway - the write might still succeed
-	}
+	// Serialize writes to the file, so that the index reflects the latest one
+	writeKey := lockKey(namespace, fileName)
+	vfs.plugin.writes.lock(writeKey)
+	defer vfs.plugin.writes.unlock(writeKey)
 
-	// Phase 1 (synchronous): Upload to S3 and register metadata in TiDB
+	// Phase 1 (synchronous): Upload to S3 and register metadata in TiDB,
+	// replacing the file's previous content
 	// After this, the file is immediately visible via ls/cat
-	alreadyExists, err := vfs.plugin.indexer.PrepareDocument(namespace, digest, fileName, content)
+	alreadyExists, err := vfs.plugin.indexer.PrepareDocument(namespace, digest, fileName, content, language, vfs.trace)
 	if err != nil {
 		log.Errorf("[vectorfs] PrepareDocument failed: %v", err)
 		return 0, fmt.Errorf("failed to prepare document: %w", err)
@@ -609,34 +1268,14 @@ func (vfs *vectorFS) Write(path string, data []byte, offset int64, flags filesys
 	}
 
 	// Phase 2 (async): Queue chunk indexing for vector search
-	task := indexTask{
+	vfs.plugin.queueIndexTask(indexTask{
 		namespace: namespace,
 		digest:    digest,
 		fileName:  fileName,
 		data:      content,
-	}
-
-	// Register task in indexing status before queuing
-	vfs.plugin.addIndexingTask(namespace, digest, fileName)
-
-	// Non-blocking send to queue with proper overflow handling
-	select {
-	case vfs.plugin.indexQueue <- task:
-		// Task queued successfully
-	default:
-		// Queue is full - use a goroutine with shutdown awareness to avoid leak
-		log.Warnf("[vectorfs] Index queue full, document %s will be indexed when queue has space", fileName)
-		go func(t indexTask) {
-			select {
-			case vfs.plugin.indexQueue <- t:
-				// Task eventually queued
-			case <-vfs.plugin.shutdown:
-				// System shutting down, remove from indexing status
-				vfs.plugin.removeIndexingTask(t.namespace, t.digest)
-				log.Warnf("[vectorfs] Shutdown while waiting to queue %s, task dropped", t.fileName)
-			}
-		}(task)
-	}
+		language:  language,
+		ocrKind:   kind,
+	})
 
 	return int64(len(data)), nil
 }
@@ -661,6 +1300,7 @@ func (vfs *vectorFS) ReadDir(path string) ([]filesystem.FileInfo, error) {
 				IsDir:   false,
 				Meta:    filesystem.MetaData{Name: PluginName, Type: "doc"},
 			},
+			vfs.statsInfo(""),
 		}
 
 		// List all namespaces (get from TiDB)
@@ -679,10 +1319,21 @@ func (vfs *vectorFS) ReadDir(path string) ([]filesystem.FileInfo, error) {
 				Meta:    filesystem.MetaData{Name: PluginName, Type: "namespace"},
 			})
 		}
+		for _, alias := range vfs.plugin.aliasNames() {
+			files = append(files, aliasInfo(alias))
+		}
 
 		return files, nil
 	}
 
+	// Alias directory
+	if _, ok := vfs.plugin.aliases[namespace]; ok {
+		if relativePath != "" {
+			return nil, aliasError(namespace)
+		}
+		return []filesystem.FileInfo{vfs.plugin.membersInfo(namespace)}, nil
+	}
+
 	// Namespace directory
 	if relativePath == "" {
 		indexingStatus := vfs.plugin.getIndexingStatus(namespace)
@@ -703,9 +1354,30 @@ func (vfs *vectorFS) ReadDir(path string) ([]filesystem.FileInfo, error) {
 				IsDir:   false,
 				Meta:    filesystem.MetaData{Name: PluginName, Type: "status"},
 			},
+			vfs.controlInfo(namespace, exportFile),
+			vfs.controlInfo(namespace, importFile),
+			vfs.controlInfo(namespace, reindexFile),
+			vfs.controlInfo(namespace, sourcesFile),
+			vfs.statsInfo(namespace),
+			permalinkDirInfo(),
 		}, nil
 	}
 
+	// Chunks are only read by their IDs, not listed
+	if relativePath == permalinkDir {
+		return []filesystem.FileInfo{}, nil
+	}
+
+	// Summaries directory or subdirectory
+	if isSummaryPath(relativePath) {
+		return vfs.readSummaryDir(namespace, relativePath)
+	}
+
+	// Chunks directory or subdirectory
+	if isChunksPath(relativePath) {
+		return vfs.readChunksDir(namespace, relativePath)
+	}
+
 	// docs/ directory or subdirectory under docs/
 	if relativePath == "docs" || strings.HasPrefix(relativePath, "docs/") {
 		// Determine the subdirectory prefix we're listing
@@ -731,6 +1403,26 @@ func (vfs *vectorFS) ReadDir(path string) ([]filesystem.FileInfo, error) {
 		// Track unique entries at this level
 		seenDirs := make(map[string]bool)
 		var fileInfos []filesystem.FileInfo
+		if subPrefix == "" && vfs.plugin.summarizer != nil {
+			fileInfos = append(fileInfos, filesystem.FileInfo{
+				Name:    summariesDir,
+				Size:    0,
+				Mode:    0555,
+				ModTime: now,
+				IsDir:   true,
+				Meta:    filesystem.MetaData{Name: PluginName, Type: "summaries"},
+			})
+		}
+		if subPrefix == "" {
+			fileInfos = append(fileInfos, filesystem.FileInfo{
+				Name:    chunksDir,
+				Size:    0,
+				Mode:    0555,
+				ModTime: now,
+				IsDir:   true,
+				Meta:    filesystem.MetaData{Name: PluginName, Type: "chunks"},
+			})
+		}
 
 		for _, f := range files {
 			fileName := f.FileName
@@ -797,12 +1489,29 @@ func (vfs *vectorFS) Stat(path string) (*filesystem.FileInfo, error) {
 			Meta:    filesystem.MetaData{Name: PluginName, Type: "doc"},
 		}, nil
 	}
+	if path == "/"+statsFile {
+		fi := vfs.statsInfo("")
+		return &fi, nil
+	}
 
 	namespace, relativePath, err := parsePath(path)
 	if err != nil {
 		return nil, err
 	}
 
+	// Alias directory and its members
+	if _, ok := vfs.plugin.aliases[namespace]; ok {
+		switch relativePath {
+		case "":
+			fi := aliasInfo(namespace)
+			return &fi, nil
+		case membersFile:
+			fi := vfs.plugin.membersInfo(namespace)
+			return &fi, nil
+		}
+		return nil, filesystem.ErrNotFound
+	}
+
 	// Namespace directory
 	if relativePath == "" {
 		exists, err := vfs.plugin.tidbClient.NamespaceExists(namespace)
@@ -820,6 +1529,11 @@ func (vfs *vectorFS) Stat(path string) (*filesystem.FileInfo, error) {
 		}, nil
 	}
 
+	// Permalinks of chunks
+	if relativePath == permalinkDir || strings.HasPrefix(relativePath, permalinkDir+"/") {
+		return vfs.statPermalink(namespace, relativePath)
+	}
+
 	// docs directory
 	if relativePath == "docs" {
 		return &filesystem.FileInfo{
@@ -845,6 +1559,26 @@ func (vfs *vectorFS) Stat(path string) (*filesystem.FileInfo, error) {
 		}, nil
 	}
 
+	// Export, import, reindex and sources control files
+	if relativePath == exportFile || relativePath == importFile || relativePath == reindexFile || relativePath == sourcesFile {
+		fi := vfs.controlInfo(namespace, relativePath)
+		return &fi, nil
+	}
+	if relativePath == statsFile {
+		fi := vfs.statsInfo(namespace)
+		return &fi, nil
+	}
+
+	// Summaries
+	if isSummaryPath(relativePath) {
+		return vfs.statSummary(namespace, relativePath)
+	}
+
+	// Chunks
+	if isChunksPath(relativePath) {
+		return vfs.statChunks(namespace, relativePath)
+	}
+
 	// Handle files and subdirectories under docs/
 	if strings.HasPrefix(relativePath, "docs/") {
 		fileName := strings.TrimPrefix(relativePath, "docs/")
@@ -856,14 +1590,18 @@ func (vfs *vectorFS) Stat(path string) (*filesystem.FileInfo, error) {
 		meta, err := vfs.plugin.tidbClient.GetFileMetadataByName(namespace, fileName)
 		if err == nil {
 			// File exists
-			return &filesystem.FileInfo{
+			info := &filesystem.FileInfo{
 				Name:    filepath.Base(fileName),
 				Size:    meta.FileSize,
 				Mode:    0644,
 				ModTime: meta.UpdatedAt,
 				IsDir:   false,
 				Meta:    filesystem.MetaData{Name: PluginName, Type: "document"},
-			}, nil
+			}
+			if meta.Language != "" {
+				info.Meta.Content = map[string]string{"language": meta.Language}
+			}
+			return info, nil
 		}
 
 		// Check if this is a virtual directory (any file has this prefix)
@@ -892,6 +1630,41 @@ func (vfs *vectorFS) Stat(path string) (*filesystem.FileInfo, error) {
 	return nil, filesystem.ErrNotFound
 }
 
+// controlInfo returns the file info of the export, import, reindex or
+// sources control file
+func (vfs *vectorFS) controlInfo(namespace, name string) filesystem.FileInfo {
+	var status string
+	switch name {
+	case reindexFile:
+		status = vfs.plugin.getReindexStatus(namespace)
+	case sourcesFile:
+		status = vfs.plugin.getSourcesStatus(namespace)
+	default:
+		status = vfs.plugin.getTransferStatus(namespace, name)
+	}
+	return filesystem.FileInfo{
+		Name:    name,
+		Size:    int64(len(status)),
+		Mode:    0644,
+		ModTime: time.Now(),
+		IsDir:   false,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "control"},
+	}
+}
+
+// statsInfo returns the file info of the .stats file of a namespace, or of
+// the root for namespace ""
+func (vfs *vectorFS) statsInfo(namespace string) filesystem.FileInfo {
+	return filesystem.FileInfo{
+		Name:    statsFile,
+		Size:    int64(len(vfs.plugin.budget.stats(namespace))),
+		Mode:    0444,
+		ModTime: time.Now(),
+		IsDir:   false,
+		Meta:    filesystem.MetaData{Name: PluginName, Type: "status"},
+	}
+}
+
 func (vfs *vectorFS) Rename(oldPath, newPath string) error {
 	return fmt.Errorf("rename not supported in vectorfs")
 }
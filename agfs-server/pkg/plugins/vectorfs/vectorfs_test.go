@@ -1,15 +1,22 @@
 package vectorfs
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"reflect"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/c4pt0r/agfs/agfs-server/pkg/filesystem"
+	"github.com/c4pt0r/agfs/agfs-server/pkg/mountablefs"
 	_ "github.com/go-sql-driver/mysql"
 )
 
@@ -362,6 +369,563 @@ func TestIndexingStatus(t *testing.T) {
 	}
 }
 
+// ============================================================================
+// Unit Tests for Write Ordering
+// ============================================================================
+
+func TestKeyedMutex(t *testing.T) {
+	var m keyedMutex
+	m.lock("ns/a.txt")
+
+	locked := make(chan struct{})
+	go func() {
+		m.lock("ns/a.txt")
+		close(locked)
+		m.unlock("ns/a.txt")
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("second lock of the same key did not wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Other keys are not blocked
+	m.lock("ns/b.txt")
+	m.unlock("ns/b.txt")
+
+	m.unlock("ns/a.txt")
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("second lock did not proceed")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.locks) != 0 {
+		t.Errorf("expected locks to be released, got %d", len(m.locks))
+	}
+}
+
+// ============================================================================
+// Unit Tests for Language Detection
+// ============================================================================
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{"The quick brown fox jumps over the lazy dog, and this is the end of it.", "en"},
+		{"Der schnelle braune Fuchs springt über den faulen Hund, und das ist nicht alles.", "de"},
+		{"Le renard brun est rapide et les chiens sont dans la maison pour une nuit.", "fr"},
+		{"El zorro es rápido y los perros están en la casa para una noche con la familia.", "es"},
+		{"Kubernetes 是一个开源的容器编排平台，用于自动化部署和管理。", "zh"},
+		{"Kubernetesはコンテナを自動的にデプロイするためのプラットフォームです。", "ja"},
+		{"쿠버네티스는 컨테이너 오케스트레이션 플랫폼입니다.", "ko"},
+		{"Это платформа для автоматического развертывания контейнеров.", "ru"},
+		{"Це платформа для автоматичного розгортання контейнерів і їх керування.", "uk"},
+		{"kubectl apply -f deployment.yaml", ""}, // Too few words to tell
+		{"12345 !!!", ""},
+	}
+
+	for _, tt := range tests {
+		if got := detectLanguage(tt.text); got != tt.expected {
+			t.Errorf("detectLanguage(%q) = %q, want %q", tt.text, got, tt.expected)
+		}
+	}
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		query     string
+		text      string
+		languages []string
+	}{
+		{"how to deploy", "how to deploy", nil},
+		{"lang:zh 部署", "部署", []string{"zh"}},
+		{"deploy lang:EN,de guide", "deploy guide", []string{"en", "de"}},
+		{"lang:en lang:fr deploy", "deploy", []string{"en", "fr"}},
+		{"lang: deploy", "lang: deploy", nil},
+	}
+
+	for _, tt := range tests {
+		text, languages := parseSearchQuery(tt.query)
+		if text != tt.text || !reflect.DeepEqual(languages, tt.languages) {
+			t.Errorf("parseSearchQuery(%q) = %q, %v, want %q, %v", tt.query, text, languages, tt.text, tt.languages)
+		}
+	}
+}
+
+func TestParseSearchOptions(t *testing.T) {
+	tests := []struct {
+		query string
+		text  string
+		opts  searchOptions
+	}{
+		{"how to deploy", "how to deploy", searchOptions{}},
+		{"k:20 how to deploy", "how to deploy", searchOptions{limit: 20}},
+		{"deploy min_score:0.75 lang:en", "deploy lang:en", searchOptions{minScore: 0.75}},
+		{"k: deploy", "k: deploy", searchOptions{}},
+	}
+	for _, tt := range tests {
+		text, opts, err := parseSearchOptions(tt.query)
+		if err != nil || text != tt.text || opts != tt.opts {
+			t.Errorf("parseSearchOptions(%q) = %q, %+v, %v, want %q, %+v", tt.query, text, opts, err, tt.text, tt.opts)
+		}
+	}
+
+	for _, query := range []string{"k:0 deploy", "k:many deploy", "min_score:2 deploy", "min_score:high deploy"} {
+		if _, _, err := parseSearchOptions(query); !errors.Is(err, filesystem.ErrInvalidArgument) {
+			t.Errorf("parseSearchOptions(%q): expected ErrInvalidArgument, got %v", query, err)
+		}
+	}
+}
+
+func TestSearchesFor(t *testing.T) {
+	defaultClient, zhClient := &EmbeddingClient{model: "default"}, &EmbeddingClient{model: "zh"}
+	idx := &Indexer{
+		embeddingClient: defaultClient,
+		languageClients: map[string]*EmbeddingClient{"zh": zhClient},
+	}
+
+	// All documents: one search per model
+	searches := idx.searchesFor(nil)
+	if len(searches) != 2 {
+		t.Fatalf("expected 2 searches, got %d", len(searches))
+	}
+	if searches[0].client != defaultClient || !reflect.DeepEqual(searches[0].filter, LanguageFilter{Except: []string{"zh"}}) {
+		t.Errorf("unexpected default search: %+v", searches[0])
+	}
+	if searches[1].client != zhClient || !reflect.DeepEqual(searches[1].filter, LanguageFilter{Only: []string{"zh"}}) {
+		t.Errorf("unexpected zh search: %+v", searches[1])
+	}
+
+	// Filtered: languages grouped by model
+	searches = idx.searchesFor([]string{"en", "zh", "de"})
+	if len(searches) != 2 {
+		t.Fatalf("expected 2 searches, got %d", len(searches))
+	}
+	if searches[0].client != defaultClient || !reflect.DeepEqual(searches[0].filter.Only, []string{"en", "de"}) {
+		t.Errorf("unexpected default search: %+v", searches[0])
+	}
+
+	where, args := LanguageFilter{Only: []string{"en", "de"}, Except: []string{"zh"}}.where()
+	if where != "WHERE m.language IN (?, ?) AND m.language NOT IN (?)" || len(args) != 3 {
+		t.Errorf("unexpected filter condition: %q %v", where, args)
+	}
+	if where, _ := (LanguageFilter{}).where(); where != "" {
+		t.Errorf("expected no condition for empty filter, got %q", where)
+	}
+}
+
+// ============================================================================
+// Summary Tests
+// ============================================================================
+
+func TestSummaryFileName(t *testing.T) {
+	tests := []struct {
+		path     string
+		fileName string
+		ok       bool
+	}{
+		{"doc.txt.md", "doc.txt", true},
+		{"guides/k8s.txt.md", "guides/k8s.txt", true},
+		{"notes.md.md", "notes.md", true},
+		{"guides", "guides", false},
+		{"doc.txt", "doc.txt", false},
+		{".md", "", false},
+		{"guides/.md", "guides/", false},
+	}
+	for _, tt := range tests {
+		fileName, ok := summaryFileName(tt.path)
+		if fileName != tt.fileName || ok != tt.ok {
+			t.Errorf("summaryFileName(%q) = %q, %v, want %q, %v", tt.path, fileName, ok, tt.fileName, tt.ok)
+		}
+	}
+
+	if !isSummaryPath("docs/.summaries") || !isSummaryPath("docs/.summaries/a.txt.md") {
+		t.Error("expected docs/.summaries paths to be summary paths")
+	}
+	if isSummaryPath("docs/.summariesx") || isSummaryPath("docs/a/.summaries/b.md") {
+		t.Error("expected other paths not to be summary paths")
+	}
+}
+
+func TestNewSummarizer(t *testing.T) {
+	s, err := newSummarizer(map[string]interface{}{"openai_api_key": "sk-test"})
+	if err != nil || s != nil {
+		t.Fatalf("expected no summarizer when disabled, got %v, %v", s, err)
+	}
+
+	s, err = newSummarizer(map[string]interface{}{
+		"summary_enabled": true,
+		"openai_api_key":  "sk-test",
+		"summary_model":   "gpt-test",
+	})
+	if err != nil {
+		t.Fatalf("newSummarizer failed: %v", err)
+	}
+	if s.model != "gpt-test" || s.maxTokens != 400 {
+		t.Errorf("unexpected summarizer settings: model=%s maxTokens=%d", s.model, s.maxTokens)
+	}
+
+	if _, err := newSummarizer(map[string]interface{}{"summary_enabled": true, "summary_provider": "unknown"}); err == nil {
+		t.Error("expected error for unknown provider")
+	}
+}
+
+// ============================================================================
+// Chunks Tests
+// ============================================================================
+
+func TestChunksFileName(t *testing.T) {
+	if got := chunksFileName("docs/.chunks"); got != "" {
+		t.Errorf("chunksFileName(docs/.chunks) = %q, want \"\"", got)
+	}
+	if got := chunksFileName("docs/.chunks/guides/k8s.txt"); got != "guides/k8s.txt" {
+		t.Errorf("chunksFileName(docs/.chunks/guides/k8s.txt) = %q, want guides/k8s.txt", got)
+	}
+	if !isChunksPath("docs/.chunks") || !isChunksPath("docs/.chunks/a.txt") {
+		t.Error("expected docs/.chunks paths to be chunks paths")
+	}
+	if isChunksPath("docs/.chunksx") || isChunksPath("docs/a/.chunks/b.txt") {
+		t.Error("expected other paths not to be chunks paths")
+	}
+}
+
+func TestChunkOffsets(t *testing.T) {
+	content := "first paragraph\n\nsecond paragraph\n\nfirst paragraph"
+	chunks := []ChunkData{
+		{ChunkIndex: 0, ChunkText: "first paragraph"},
+		{ChunkIndex: 1, ChunkText: "second paragraph"},
+		{ChunkIndex: 2, ChunkText: "first paragraph"},
+		{ChunkIndex: 3, ChunkText: "normalized  text"},
+	}
+	offsets := chunkOffsets(content, chunks)
+	want := []int{0, 17, 35, -1}
+	for i := range want {
+		if offsets[i] != want[i] {
+			t.Errorf("offset of chunk %d = %d, want %d", i, offsets[i], want[i])
+		}
+	}
+}
+
+func TestFormatChunksReport(t *testing.T) {
+	meta := &FileMetadata{FileName: "doc.txt", FileDigest: "abc"}
+	chunks := []ChunkData{
+		{ChunkIndex: 0, ChunkText: "hello", Embedding: []float32{0.1, 0.2}},
+		{ChunkIndex: 1, ChunkText: "world", Embedding: []float32{0.1}},
+		{ChunkIndex: 2, ChunkText: "gone"},
+	}
+	report := formatChunksReport(meta, "indexed", "test-model", 2, "hello world", chunks)
+	for _, want := range []string{
+		"document: doc.txt\n",
+		"embedding model: test-model\n",
+		"status: indexed\n",
+		"chunks: 3\n",
+		"--- chunk 0 (id 2cf24dba5fb0a30e, offset 0, 5 bytes, embedding: 2 dims)\nhello\n",
+		"--- chunk 1 (id 486ea46224d1bb4f, offset 6, 5 bytes, embedding: 1 dims, expected 2)\nworld\n",
+		"--- chunk 2 (id " + chunkID("gone") + ", offset unknown, 4 bytes, embedding: missing)\ngone\n",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report missing %q:\n%s", want, report)
+		}
+	}
+}
+
+func TestChunkPermalinks(t *testing.T) {
+	id := chunkID("hello")
+	if id != "2cf24dba5fb0a30e" || !isChunkID(id) {
+		t.Errorf("unexpected chunk ID %q", id)
+	}
+	for _, name := range []string{"", "2cf24dba5fb0a30", "2CF24DBA5FB0A30E", "2cf24dba5fb0a30g", "../docs/a.txt"} {
+		if isChunkID(name) {
+			t.Errorf("expected %q not to be a chunk ID", name)
+		}
+	}
+
+	v := &VectorFSPlugin{mountPath: "/vectorfs/"}
+	if p := v.permalinkPath("wiki", id); p != "/vectorfs/wiki/chunks/"+id {
+		t.Errorf("unexpected permalink %q", p)
+	}
+
+	older := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+	refs := []ChunkRef{
+		{FileName: "a.txt", Language: "en", ChunkIndex: 2, ChunkText: "hello", UpdatedAt: newer},
+		{FileName: "copies/a.txt", Language: "en", ChunkIndex: 2, ChunkText: "hello", UpdatedAt: older},
+	}
+	want := "id: 2cf24dba5fb0a30e\nsource: docs/a.txt (chunk 2)\nsource: docs/copies/a.txt (chunk 2)\nlanguage: en\n\nhello\n"
+	if got := formatPermalink(id, refs); got != want {
+		t.Errorf("formatPermalink = %q, want %q", got, want)
+	}
+	if !latestUpdate(refs).Equal(newer) {
+		t.Errorf("unexpected modification time %v", latestUpdate(refs))
+	}
+}
+
+// ============================================================================
+// OCR Tests
+// ============================================================================
+
+func TestOCRKind(t *testing.T) {
+	tests := []struct {
+		data string
+		kind string
+	}{
+		{"\x89PNG\r\n\x1a\n\x00\x00", ocrImage},
+		{"\xff\xd8\xff\xe0\x00\x10JFIF", ocrImage},
+		{"II*\x00\x08\x00\x00\x00", ocrImage},
+		{"%PDF-1.7\n", ocrPDF},
+		{"plain text document", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if kind := ocrKind([]byte(tt.data)); kind != tt.kind {
+			t.Errorf("ocrKind(%q) = %q, want %q", tt.data, kind, tt.kind)
+		}
+	}
+}
+
+func TestNewOCR(t *testing.T) {
+	engine, err := newOCR(map[string]interface{}{})
+	if err != nil || engine != nil {
+		t.Fatalf("expected no OCR when disabled, got %v, %v", engine, err)
+	}
+
+	engine, err = newOCR(map[string]interface{}{"ocr_enabled": true, "ocr_languages": "eng+deu"})
+	if err != nil {
+		t.Fatalf("newOCR failed: %v", err)
+	}
+	if tess, ok := engine.(*tesseractOCR); !ok || tess.command != "tesseract" || tess.languages != "eng+deu" {
+		t.Errorf("unexpected OCR engine: %#v", engine)
+	}
+
+	if _, err := newOCR(map[string]interface{}{"ocr_enabled": true, "ocr_provider": "google"}); err == nil {
+		t.Error("expected error for google provider without API key")
+	}
+	if _, err := newOCR(map[string]interface{}{"ocr_enabled": true, "ocr_provider": "unknown"}); err == nil {
+		t.Error("expected error for unknown provider")
+	}
+}
+
+func TestVisionOCR(t *testing.T) {
+	var pdfRequests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Goog-Api-Key") != "test-key" {
+			http.Error(w, "invalid key", http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/images:annotate":
+			fmt.Fprint(w, `{"responses":[{"fullTextAnnotation":{"text":"screenshot text"}}]}`)
+		case "/files:annotate":
+			pdfRequests++
+			fmt.Fprintf(w, `{"responses":[{"responses":[{"fullTextAnnotation":{"text":"page text %d"}}],"totalPages":7}]}`, pdfRequests)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	engine, err := newOCR(map[string]interface{}{
+		"ocr_enabled":  true,
+		"ocr_provider": "google",
+		"ocr_api_key":  "test-key",
+		"ocr_api_base": server.URL + "/",
+	})
+	if err != nil {
+		t.Fatalf("newOCR failed: %v", err)
+	}
+	ctx := context.Background()
+	if err := engine.check(ctx); err != nil {
+		t.Errorf("check failed: %v", err)
+	}
+
+	text, err := engine.recognize(ctx, ocrImage, []byte("\x89PNG"))
+	if err != nil || !strings.Contains(text, "screenshot text") {
+		t.Errorf("recognize image = %q, %v", text, err)
+	}
+
+	// 7 pages take two requests of up to 5 pages
+	text, err = engine.recognize(ctx, ocrPDF, []byte("%PDF-1.7"))
+	if err != nil {
+		t.Fatalf("recognize PDF failed: %v", err)
+	}
+	if pdfRequests != 2 || !strings.Contains(text, "page text 1") || !strings.Contains(text, "page text 2") {
+		t.Errorf("recognize PDF = %q after %d request(s)", text, pdfRequests)
+	}
+
+	engine.(*visionOCR).apiKey = "wrong-key"
+	if err := engine.check(ctx); err == nil {
+		t.Error("expected check to fail with an invalid key")
+	}
+}
+
+// ============================================================================
+// Namespace Alias Tests
+// ============================================================================
+
+func TestNamespaceAliases(t *testing.T) {
+	aliases, err := namespaceAliases(map[string]interface{}{
+		"namespace_aliases": map[string]interface{}{
+			"all_docs": []interface{}{"wiki", "tickets"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("namespaceAliases failed: %v", err)
+	}
+	if !reflect.DeepEqual(aliases, map[string][]string{"all_docs": {"wiki", "tickets"}}) {
+		t.Errorf("unexpected aliases: %v", aliases)
+	}
+
+	for _, raw := range []interface{}{
+		"wiki",
+		map[string]interface{}{"all_docs": "wiki"},
+		map[string]interface{}{"all_docs": []interface{}{}},
+		map[string]interface{}{"all_docs": []interface{}{"a/b"}},
+		map[string]interface{}{"README": []interface{}{"wiki"}},
+		map[string]interface{}{"a": []interface{}{"b"}, "b": []interface{}{"wiki"}},
+	} {
+		if _, err := namespaceAliases(map[string]interface{}{"namespace_aliases": raw}); err == nil {
+			t.Errorf("expected error for namespace_aliases %v", raw)
+		}
+	}
+}
+
+func TestAliasFileSystem(t *testing.T) {
+	vfs := &vectorFS{plugin: &VectorFSPlugin{aliases: map[string][]string{"all_docs": {"wiki", "tickets"}}}}
+
+	fi, err := vfs.Stat("/all_docs")
+	if err != nil || !fi.IsDir || fi.Meta.Type != "alias" {
+		t.Errorf("Stat(/all_docs) = %+v, %v", fi, err)
+	}
+	entries, err := vfs.ReadDir("/all_docs")
+	if err != nil || len(entries) != 1 || entries[0].Name != membersFile {
+		t.Errorf("ReadDir(/all_docs) = %+v, %v", entries, err)
+	}
+	data, err := vfs.Read("/all_docs/"+membersFile, 0, -1)
+	if (err != nil && err != io.EOF) || string(data) != "wiki\ntickets\n" {
+		t.Errorf("Read(.members) = %q, %v", data, err)
+	}
+
+	if _, err := vfs.Write("/all_docs/docs/a.txt", []byte("a"), 0, filesystem.WriteFlagCreate); err == nil {
+		t.Error("expected write to an alias to fail")
+	}
+	if err := vfs.Mkdir("/all_docs", 0755); err == nil {
+		t.Error("expected mkdir of an alias to fail")
+	}
+	if err := vfs.RemoveAll("/all_docs"); err == nil {
+		t.Error("expected removal of an alias to fail")
+	}
+	if _, err := vfs.Read("/all_docs/docs/a.txt", 0, -1); err == nil {
+		t.Error("expected read of a document through an alias to fail")
+	}
+
+	if _, err := vfs.searchedNamespaces("wiki", ""); err == nil {
+		t.Error("expected search outside docs/ to fail")
+	}
+	namespaces, err := vfs.searchedNamespaces("wiki", "docs/guides")
+	if err != nil || !reflect.DeepEqual(namespaces, []string{"wiki"}) {
+		t.Errorf("searchedNamespaces(wiki) = %v, %v", namespaces, err)
+	}
+}
+
+// ============================================================================
+// Export/Import Tests
+// ============================================================================
+
+func TestParseVector(t *testing.T) {
+	vec := []float32{0.5, -1.25, 3}
+	parsed, err := parseVector(formatVector(vec))
+	if err != nil {
+		t.Fatalf("parseVector failed: %v", err)
+	}
+	if !reflect.DeepEqual(parsed, vec) {
+		t.Errorf("expected %v, got %v", vec, parsed)
+	}
+
+	if parsed, err := parseVector("[]"); err != nil || len(parsed) != 0 {
+		t.Errorf("expected empty vector, got %v, %v", parsed, err)
+	}
+	for _, s := range []string{"", "1,2", "[1,x]"} {
+		if _, err := parseVector(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
+
+func TestObjectLocation(t *testing.T) {
+	c := &S3Client{bucket: "docs", keyPrefix: "vectorfs"}
+	tests := []struct {
+		location string
+		bucket   string
+		key      string
+		wantErr  bool
+	}{
+		{"s3://backups/ns/export.ndjson.gz", "backups", "ns/export.ndjson.gz", false},
+		{"exports/ns.ndjson.gz", "docs", "exports/ns.ndjson.gz", false},
+		{"/exports/ns.ndjson.gz", "docs", "exports/ns.ndjson.gz", false},
+		{"s3://backups", "", "", true},
+		{"s3:///key", "", "", true},
+	}
+	for _, tt := range tests {
+		bucket, key, err := c.objectLocation(tt.location)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("objectLocation(%q) error = %v, wantErr %v", tt.location, err, tt.wantErr)
+			continue
+		}
+		if bucket != tt.bucket || key != tt.key {
+			t.Errorf("objectLocation(%q) = %q, %q, want %q, %q", tt.location, bucket, key, tt.bucket, tt.key)
+		}
+	}
+}
+
+func TestReadExportHeader(t *testing.T) {
+	v := &VectorFSPlugin{}
+	for _, input := range []string{
+		"",
+		`{"type":"content","digest":"abc"}`,
+		`{"type":"header","version":99}`,
+	} {
+		if err := v.readExport("ns", strings.NewReader(input), &transferStatus{}); err == nil {
+			t.Errorf("expected error for export %q", input)
+		}
+	}
+}
+
+func TestTransferStatus(t *testing.T) {
+	v := &VectorFSPlugin{transfers: make(map[string]*transferStatus), shutdown: make(chan struct{})}
+	if status := v.getTransferStatus("ns", exportFile); status != "idle\n" {
+		t.Errorf("expected idle, got %q", status)
+	}
+
+	release := make(chan struct{})
+	run := func(status *transferStatus) error {
+		status.update(func(s *transferStatus) { s.files = 2 })
+		<-release
+		return nil
+	}
+	if err := v.startTransfer("ns", exportFile, "s3://b/k", run); err != nil {
+		t.Fatalf("startTransfer failed: %v", err)
+	}
+	if err := v.startTransfer("ns", exportFile, "s3://b/k", run); err == nil {
+		t.Error("expected error when an export is already running")
+	}
+	if !strings.Contains(v.getTransferStatus("ns", exportFile), "state: running") {
+		t.Errorf("expected running export, got %q", v.getTransferStatus("ns", exportFile))
+	}
+
+	close(release)
+	v.transferWg.Wait()
+	status := v.getTransferStatus("ns", exportFile)
+	if !strings.Contains(status, "state: done") || !strings.Contains(status, "files: 2") {
+		t.Errorf("unexpected status %q", status)
+	}
+}
+
 // ============================================================================
 // Integration Tests (require database connection)
 // ============================================================================
@@ -637,9 +1201,478 @@ func TestTiDBLargeBatchInsert(t *testing.T) {
 	}
 }
 
+// TestTiDBSharedContent tests that files with the same content reference it
+// independently
+func TestTiDBSharedContent(t *testing.T) {
+	dsn := getTestDSN()
+	if dsn == "" {
+		t.Skip("Skipping database test: TIDB_TEST_DSN not set")
+	}
+
+	client, err := NewTiDBClient(TiDBConfig{DSN: dsn})
+	if err != nil {
+		t.Fatalf("Failed to connect to TiDB: %v", err)
+	}
+	defer client.Close()
+
+	namespace := fmt.Sprintf("test_shared_%d", time.Now().UnixNano())
+	if err := client.CreateNamespace(namespace, 3); err != nil {
+		t.Fatalf("Failed to create namespace: %v", err)
+	}
+	defer client.DeleteNamespace(namespace)
+
+	// Two files with the same content
+	now := time.Now()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		err := client.InsertFileMetadata(namespace, FileMetadata{
+			FileDigest: "d1", FileName: name, S3Key: "k1", FileSize: 3, CreatedAt: now, UpdatedAt: now,
+		})
+		if err != nil {
+			t.Fatalf("Failed to insert %s: %v", name, err)
+		}
+	}
+	files, err := client.ListFiles(namespace)
+	if err != nil || len(files) != 2 {
+		t.Fatalf("Expected 2 files, got %d (%v)", len(files), err)
+	}
+
+	// Overwrite a.txt with other content, then delete b.txt
+	err = client.InsertFileMetadata(namespace, FileMetadata{
+		FileDigest: "d2", FileName: "a.txt", S3Key: "k2", FileSize: 3, CreatedAt: now, UpdatedAt: now,
+	})
+	if err != nil {
+		t.Fatalf("Failed to overwrite a.txt: %v", err)
+	}
+	if digest, _ := client.GetFileDigest(namespace, "a.txt"); digest != "d2" {
+		t.Errorf("Expected a.txt to reference d2, got %q", digest)
+	}
+	if referenced, _ := client.FileExists(namespace, "d1"); !referenced {
+		t.Error("Expected d1 to be referenced by b.txt")
+	}
+
+	if err := client.DeleteFileByName(namespace, "b.txt"); err != nil {
+		t.Fatalf("Failed to delete b.txt: %v", err)
+	}
+	if referenced, _ := client.FileExists(namespace, "d1"); referenced {
+		t.Error("Expected d1 to be unreferenced")
+	}
+	if digest, _ := client.GetFileDigest(namespace, "b.txt"); digest != "" {
+		t.Errorf("Expected b.txt to be gone, got digest %q", digest)
+	}
+}
+
 // getTestDSN returns the test database DSN from environment
 func getTestDSN() string {
 	// Set TIDB_TEST_DSN environment variable for integration testing
 	// Example: export TIDB_TEST_DSN="user:pass@tcp(localhost:4000)/test?parseTime=true"
 	return os.Getenv("TIDB_TEST_DSN")
 }
+
+func TestReindexControl(t *testing.T) {
+	args, err := parseReindexArgs("rate=2\n")
+	if err != nil || !reflect.DeepEqual(args, map[string]string{"rate": "2"}) {
+		t.Errorf("parseReindexArgs = %v, %v", args, err)
+	}
+	if args, err := parseReindexArgs(""); err != nil || len(args) != 0 {
+		t.Errorf("parseReindexArgs of empty input = %v, %v", args, err)
+	}
+	if _, err := parseReindexArgs("workers=2"); err == nil {
+		t.Error("expected error for an unknown argument")
+	}
+
+	v := NewVectorFSPlugin()
+	if status := v.getReindexStatus("ns"); status != "idle\n" {
+		t.Errorf("expected idle without a task framework, got %q", status)
+	}
+	if _, err := v.StartReindex("ns", nil); err == nil {
+		t.Error("expected error without a task framework")
+	}
+	if err := v.RunTask(context.Background(), ReindexTask, map[string]string{"path": "/"}, nil); err == nil {
+		t.Error("expected error for a reindex without namespace")
+	}
+	if err := v.RunTask(context.Background(), ReindexTask, map[string]string{"path": "/ns", "rate": "fast"}, nil); err == nil {
+		t.Error("expected error for an invalid rate")
+	}
+
+	finished := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	status := formatReindexStatus(mountablefs.TaskInfo{
+		ID: 7, Status: mountablefs.TaskStatusFailed, Done: 3, Total: 3,
+		Message: "reindexed 2 content(s) into 10 chunks", Error: "1 of 3 content(s) failed to reindex",
+		StartedAt: finished.Add(-time.Minute), FinishedAt: &finished,
+	})
+	for _, want := range []string{"state: failed\n", "task: 7\n", "progress: 3/3\n", "finished: 2025-01-02T03:04:05Z\n", "error: 1 of 3"} {
+		if !strings.Contains(status, want) {
+			t.Errorf("status %q does not contain %q", status, want)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(0)
+	for i := 0; i < 100; i++ {
+		if !limiter.Allow() {
+			t.Fatal("expected no limit for a rate of 0")
+		}
+	}
+	limiter = newRateLimiter(1)
+	if !limiter.Allow() || limiter.Allow() {
+		t.Error("expected a burst of one request")
+	}
+}
+
+func TestEmbeddingBudget(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	budget := newEmbeddingBudget(budgetLimits{tokens: 100, nsRequests: 2})
+	budget.now = func() time.Time { return now }
+
+	// Namespaces have their own request cap
+	for i := 0; i < 2; i++ {
+		if err := budget.reserve("a", 10); err != nil {
+			t.Fatalf("reserve %d failed: %v", i, err)
+		}
+	}
+	err := budget.reserve("a", 10)
+	if !errors.Is(err, filesystem.ErrQuotaExceeded) || !strings.Contains(err.Error(), "embedding_namespace_max_requests_per_hour") {
+		t.Errorf("expected the namespace request cap to be exceeded, got %v", err)
+	}
+	if err := budget.reserve("b", 10); err != nil {
+		t.Errorf("expected another namespace to have its own cap, got %v", err)
+	}
+
+	// Tokens are settled with the usage reported, and capped globally
+	budget.settle("b", 10, 60)
+	err = budget.reserve("", 30)
+	if !errors.Is(err, filesystem.ErrQuotaExceeded) || !strings.Contains(err.Error(), "embedding_max_tokens_per_hour") {
+		t.Errorf("expected the global token cap to be exceeded, got %v", err)
+	}
+
+	stats := budget.stats("a")
+	for _, want := range []string{"requests_last_hour: 2 / 2\n", "tokens_last_hour: 20\n", "rejected_total: 1\n"} {
+		if !strings.Contains(stats, want) {
+			t.Errorf("expected %q in stats of a:\n%s", want, stats)
+		}
+	}
+	stats = budget.stats("")
+	for _, want := range []string{"tokens_last_hour: 80 / 100\n", "rejected_total: 2\n", "  b: 1 requests, 60 tokens in the last hour\n"} {
+		if !strings.Contains(stats, want) {
+			t.Errorf("expected %q in global stats:\n%s", want, stats)
+		}
+	}
+
+	// Usage leaves the window after an hour
+	now = now.Add(time.Hour)
+	if err := budget.reserve("a", 90); err != nil {
+		t.Errorf("expected the budget to be free after an hour, got %v", err)
+	}
+	if stats := budget.stats("a"); !strings.Contains(stats, "requests_last_hour: 1 / 2\n") || !strings.Contains(stats, "requests_total: 3\n") {
+		t.Errorf("unexpected stats after an hour:\n%s", stats)
+	}
+
+	// Clients without a budget are not capped
+	var none *embeddingBudget
+	if err := none.reserve("a", 1<<40); err != nil {
+		t.Errorf("expected no cap without a budget, got %v", err)
+	}
+}
+
+func TestBudgetLimitsFromConfig(t *testing.T) {
+	limits, err := budgetLimitsFromConfig(map[string]interface{}{
+		"embedding_max_tokens_per_hour":             1000,
+		"embedding_namespace_max_requests_per_hour": 10,
+	})
+	if err != nil || limits != (budgetLimits{tokens: 1000, nsRequests: 10}) {
+		t.Errorf("unexpected limits %+v, %v", limits, err)
+	}
+	for _, cfg := range []map[string]interface{}{
+		{"embedding_max_requests_per_hour": -1},
+		{"embedding_max_requests_per_hour": "many"},
+	} {
+		if _, err := budgetLimitsFromConfig(cfg); err == nil {
+			t.Errorf("expected %v to be rejected", cfg)
+		}
+	}
+}
+
+func TestHTTPEmbeddingProvider(t *testing.T) {
+	var mu sync.Mutex
+	var batches []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" || r.Header.Get("Authorization") != "" {
+			t.Errorf("unexpected request %s with Authorization %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		var req openAIBatchEmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		mu.Lock()
+		batches = append(batches, len(req.Input))
+		mu.Unlock()
+
+		// Embeddings in reverse order, each holding the length of its text
+		var resp openAIEmbeddingResponse
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			resp.Data = append(resp.Data, struct {
+				Embedding []float32 `json:"embedding"`
+				Index     int       `json:"index"`
+			}{Embedding: []float32{float32(len(req.Input[i])), 0}, Index: i})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	if _, err := NewEmbeddingClient(EmbeddingConfig{Provider: "http"}); err == nil {
+		t.Error("expected the http provider to require an API base")
+	}
+	client, err := NewEmbeddingClient(EmbeddingConfig{Provider: "http", APIBase: server.URL + "/v1/", Dimension: 2, BatchSize: 2})
+	if err != nil {
+		t.Fatalf("NewEmbeddingClient failed: %v", err)
+	}
+	if cap(client.sem) != defaultHTTPConcurrency {
+		t.Errorf("expected a concurrency of %d, got %d", defaultHTTPConcurrency, cap(client.sem))
+	}
+
+	embeddings, err := client.GenerateBatchEmbeddings("ns", []string{"a", "bb", "ccc", "dddd", "eeeee"})
+	if err != nil {
+		t.Fatalf("GenerateBatchEmbeddings failed: %v", err)
+	}
+	for i, embedding := range embeddings {
+		if embedding[0] != float32(i+1) {
+			t.Errorf("embedding %d is out of order: %v", i, embedding)
+		}
+	}
+	if fmt.Sprint(batches) != "[2 2 1]" {
+		t.Errorf("expected batches of 2, got %v", batches)
+	}
+
+	if err := client.CheckAuth(context.Background()); err != nil {
+		t.Errorf("CheckAuth failed: %v", err)
+	}
+	client.dimension = 1536
+	if err := client.CheckAuth(context.Background()); err == nil || !strings.Contains(err.Error(), "2 dimensions") {
+		t.Errorf("expected a dimension mismatch, got %v", err)
+	}
+}
+
+func TestWritePolicy(t *testing.T) {
+	policy, err := newWritePolicy(map[string]interface{}{
+		"max_document_size":     "1KB",
+		"allowed_extensions":    []interface{}{".md", "TXT", ".png"},
+		"allowed_content_types": []interface{}{"text/*", "image/png"},
+	})
+	if err != nil {
+		t.Fatalf("newWritePolicy failed: %v", err)
+	}
+	png := append([]byte("\x89PNG\r\n\x1a\n"), 0, 0, 0, 13)
+
+	for _, tc := range []struct {
+		path string
+		data []byte
+		ocr  bool
+		err  error // nil if accepted
+	}{
+		{"/ns/docs/a.md", []byte("# Title\n\nSome text, ünïcode too"), false, nil},
+		{"/ns/docs/b.TXT", []byte("plain"), false, nil},
+		{"/ns/docs/empty.md", nil, false, nil},
+		{"/ns/docs/big.md", []byte(strings.Repeat("x", 1025)), false, filesystem.ErrQuotaExceeded},
+		{"/ns/docs/a.go", []byte("package main"), false, filesystem.ErrInvalidArgument},
+		{"/ns/docs/README", []byte("text"), false, filesystem.ErrInvalidArgument},
+		{"/ns/docs/data.md", []byte("{\x00\x01}"), false, filesystem.ErrInvalidArgument},
+		{"/ns/docs/latin1.txt", []byte("caf\xe9 au lait"), false, filesystem.ErrInvalidArgument},
+		{"/ns/docs/scan.png", png, false, filesystem.ErrInvalidArgument},
+		{"/ns/docs/scan.png", png, true, nil},
+		{"/ns/docs/doc.md", []byte("%PDF-1.4\n"), false, filesystem.ErrInvalidArgument},
+	} {
+		err := policy.check(tc.path, tc.data, tc.ocr)
+		if tc.err == nil && err != nil {
+			t.Errorf("%s: expected to be accepted, got %v", tc.path, err)
+		} else if tc.err != nil && !errors.Is(err, tc.err) {
+			t.Errorf("%s: expected %v, got %v", tc.path, tc.err, err)
+		}
+	}
+
+	// A rune cut at the end of the sniffed prefix is still text
+	text := []byte(strings.Repeat("a", binarySniffLen-1) + "é and more")
+	if reason := binaryReason(text); reason != "" {
+		t.Errorf("expected text, got %s", reason)
+	}
+	// Binary content is accepted once reject_binary is off
+	lenient, err := newWritePolicy(map[string]interface{}{"reject_binary": false})
+	if err != nil {
+		t.Fatalf("newWritePolicy failed: %v", err)
+	}
+	if err := lenient.check("/ns/docs/blob", []byte{0, 1, 2}, false); err != nil {
+		t.Errorf("expected binary content to be accepted, got %v", err)
+	}
+
+	for _, cfg := range []map[string]interface{}{
+		{"max_document_size": -1},
+		{"allowed_extensions": ".md"},
+		{"allowed_extensions": []interface{}{1}},
+		{"allowed_content_types": []interface{}{"text"}},
+		{"reject_binary": "yes"},
+	} {
+		if _, err := newWritePolicy(cfg); err == nil {
+			t.Errorf("expected %v to be rejected", cfg)
+		}
+	}
+}
+
+func TestSourcesFromConfig(t *testing.T) {
+	sources, err := sourcesFromConfig(map[string]interface{}{
+		"sources": map[string]interface{}{
+			"wiki": []interface{}{
+				map[string]interface{}{"name": "handbook", "type": "git", "repo": "https://example.com/handbook.git", "path": "/docs/"},
+				map[string]interface{}{"name": "site", "type": "urls", "sitemap": "https://example.com/sitemap.xml", "schedule": "0 3 * * *", "dir": "web/site", "delete": false},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("sourcesFromConfig failed: %v", err)
+	}
+	wiki := sources["wiki"]
+	if len(wiki) != 2 {
+		t.Fatalf("expected 2 sources, got %+v", sources)
+	}
+	if wiki[0].Dir != "handbook" || wiki[0].Path != "docs" || wiki[0].Schedule != defaultSourceSchedule || !wiki[0].Delete {
+		t.Errorf("unexpected defaults %+v", wiki[0])
+	}
+	if wiki[1].Dir != "web/site" || wiki[1].Delete {
+		t.Errorf("unexpected source %+v", wiki[1])
+	}
+
+	for _, src := range []map[string]interface{}{
+		{"type": "git", "repo": "r"},
+		{"name": "a", "type": "ftp"},
+		{"name": "a", "type": "s3"},
+		{"name": "a", "type": "urls", "urls": []interface{}{"ftp://example.com"}},
+		{"name": "a", "type": "s3", "location": "p", "schedule": "every hour"},
+		{"name": "a", "type": "s3", "location": "p", "dir": "../up"},
+		{"name": "a", "type": "s3", "location": "p", "bucket": "b"},
+	} {
+		cfg := map[string]interface{}{"sources": map[string]interface{}{"ns": []interface{}{src}}}
+		if _, err := sourcesFromConfig(cfg); err == nil {
+			t.Errorf("expected %v to be rejected", src)
+		}
+	}
+	dup := map[string]interface{}{"name": "a", "type": "s3", "location": "p"}
+	if _, err := sourcesFromConfig(map[string]interface{}{"sources": map[string]interface{}{"ns": []interface{}{dup, dup}}}); err == nil {
+		t.Error("expected duplicate sources to be rejected")
+	}
+}
+
+// memoryStore is a documentStore keeping documents in memory
+type memoryStore struct {
+	docs   map[string]string
+	reject string // Name of a document rejected by the write policy
+}
+
+func (m *memoryStore) prepare(namespace string) error { return nil }
+
+func (m *memoryStore) list(namespace, dir string) ([]string, error) {
+	var names []string
+	for name := range m.docs {
+		if strings.HasPrefix(name, dir+"/") {
+			names = append(names, strings.TrimPrefix(name, dir+"/"))
+		}
+	}
+	return names, nil
+}
+
+func (m *memoryStore) put(namespace, name string, data []byte) error {
+	if name == m.reject {
+		return filesystem.NewInvalidArgumentError("write", name, "extension not allowed")
+	}
+	m.docs[name] = string(data)
+	return nil
+}
+
+func (m *memoryStore) remove(namespace, name string) error {
+	delete(m.docs, name)
+	return nil
+}
+
+func TestURLSourceSync(t *testing.T) {
+	pages := map[string]string{"/guide/": "guide v1", "/faq": "faq", "/logo.png": "png"}
+	lastmod := "2026-10-01"
+	var fetched []string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/sitemap.xml" {
+			fmt.Fprintf(w, `<?xml version="1.0"?><urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`+
+				`<url><loc>%[1]s/guide/</loc><lastmod>%[2]s</lastmod></url><url><loc>%[1]s/logo.png</loc></url></urlset>`,
+				"http://"+r.Host, lastmod)
+			return
+		}
+		fetched = append(fetched, r.URL.Path)
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, page)
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	s := &source{
+		namespace: "wiki",
+		cfg:       sourceConfig{Name: "site", Type: "urls", Dir: "site", Delete: true},
+		conn:      &urlSource{urls: []string{server.URL + "/faq"}, sitemap: server.URL + "/sitemap.xml", client: server.Client()},
+	}
+	store := &memoryStore{
+		docs:   map[string]string{"site/" + host + "/old.html": "gone", "other/kept.md": "kept"},
+		reject: "site/" + host + "/logo.png",
+	}
+	if err := s.sync(context.Background(), store); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	want := map[string]string{
+		"site/" + host + "/guide/index.html": "guide v1",
+		"site/" + host + "/faq":              "faq",
+		"other/kept.md":                      "kept",
+	}
+	if !reflect.DeepEqual(store.docs, want) {
+		t.Errorf("unexpected documents %v", store.docs)
+	}
+	if st := s.stats; st.documents != 3 || st.added != 2 || st.skipped != 1 || st.removed != 1 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+
+	// Pages of the sitemap with an unchanged lastmod are not fetched again;
+	// the others are, but only written when their content changed
+	mu.Lock()
+	fetched = nil
+	pages["/guide/"] = "guide v2"
+	pages["/faq"] = "faq v2"
+	mu.Unlock()
+	if err := s.sync(context.Background(), store); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if store.docs["site/"+host+"/guide/index.html"] != "guide v1" || store.docs["site/"+host+"/faq"] != "faq v2" {
+		t.Errorf("unexpected documents %v", store.docs)
+	}
+	if !reflect.DeepEqual(fetched, []string{"/faq", "/logo.png"}) {
+		t.Errorf("expected /guide/ not to be fetched, got %v", fetched)
+	}
+	if st := s.stats; st.updated != 1 || st.unchanged != 2 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+
+	// A failed page is reported, and kept
+	mu.Lock()
+	delete(pages, "/faq")
+	mu.Unlock()
+	err := s.sync(context.Background(), store)
+	if err == nil || !strings.Contains(err.Error(), "HTTP 404") {
+		t.Errorf("expected the failed page to be reported, got %v", err)
+	}
+	s.finished, s.err = time.Now(), err
+	if _, ok := store.docs["site/"+host+"/faq"]; !ok {
+		t.Error("expected the failed page to be kept")
+	}
+	if status := s.String(time.Time{}); !strings.Contains(status, "name: site\n") || !strings.Contains(status, "state: failed\n") || !strings.Contains(status, "failed: 1\n") {
+		t.Errorf("unexpected status %q", status)
+	}
+}
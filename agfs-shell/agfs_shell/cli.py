@@ -186,7 +186,8 @@ def main():
     initial_env = parse_env_vars(args.env_vars)
 
     # Initialize shell with configuration
-    shell = Shell(server_url=config.server_url, timeout=config.timeout, initial_env=initial_env)
+    shell = Shell(server_url=config.server_url, timeout=config.timeout, initial_env=initial_env,
+                  token=config.token)
 
     # Check if webapp mode is requested
     if args.webapp:
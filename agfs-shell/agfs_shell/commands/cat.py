@@ -52,6 +52,10 @@ def cmd_cat(process: Process) -> int:
                     except KeyboardInterrupt:
                         # Re-raise to allow proper signal propagation in script mode
                         raise
+                    finally:
+                        # Stop downloading if we stop early (e.g. broken pipe)
+                        if hasattr(stream, 'close'):
+                            stream.close()
                 else:
                     # Fallback to local filesystem
                     with open(filename, 'rb') as f:
@@ -61,6 +65,9 @@ def cmd_cat(process: Process) -> int:
                                 break
                             process.stdout.write(chunk)
                             process.stdout.flush()
+            except BrokenPipeError:
+                # The reader of our output has exited, stop quietly
+                raise
             except Exception as e:
                 # Extract meaningful error message
                 error_msg = str(e)
@@ -0,0 +1,63 @@
+"""
+ENQUEUE command - add messages to a QueueFS queue.
+"""
+
+from ..process import Process
+from ..command_decorators import command
+from ..utils.plugin_files import resolve_arg_path
+from . import register_command
+
+
+@command()
+@register_command('enqueue')
+def cmd_enqueue(process: Process) -> int:
+    """
+    Add a message to a QueueFS queue
+
+    Usage: enqueue [-l] QUEUE [MESSAGE...]
+
+    Writes MESSAGE to QUEUE/enqueue. Without MESSAGE, the message is read
+    from stdin.
+
+    Options:
+        -l          Enqueue each line of stdin as a separate message
+
+    Examples:
+        enqueue /queuefs/jobs 'resize image-42.png'
+        echo '{"task": "reindex"}' | enqueue /queuefs/jobs
+        cat tasks.txt | enqueue -l /queuefs/jobs
+    """
+    if not process.filesystem:
+        process.stderr.write("enqueue: filesystem not available\n")
+        return 1
+
+    args = process.args[:]
+    per_line = False
+    if args and args[0] == '-l':
+        per_line = True
+        args = args[1:]
+
+    if not args:
+        process.stderr.write("enqueue: missing operand\n")
+        process.stderr.write("Usage: enqueue [-l] QUEUE [MESSAGE...]\n")
+        return 1
+
+    path = resolve_arg_path(process, args[0]).rstrip('/') + "/enqueue"
+    if len(args) > 1:
+        if per_line:
+            process.stderr.write("enqueue: -l reads messages from stdin\n")
+            return 1
+        messages = [' '.join(args[1:]).encode('utf-8')]
+    elif per_line:
+        messages = [line.rstrip(b'\r\n') for line in process.stdin.readlines()]
+        messages = [m for m in messages if m]
+    else:
+        messages = [process.stdin.read()]
+
+    for message in messages:
+        try:
+            process.filesystem.write_file(path, message)
+        except Exception as e:
+            process.stderr.write(f"enqueue: {path}: {e}\n")
+            return 1
+    return 0
@@ -31,9 +31,17 @@ def cmd_head(process: Process) -> int:
                 return 1
         i += 1
 
-    # Read lines from stdin
-    lines = process.stdin.readlines()
-    for line in lines[:n]:
+    if n < 0:
+        # All but the last -n lines: needs the whole input
+        for line in process.stdin.readlines()[:n]:
+            process.stdout.write(line)
+        return 0
+
+    # Read only the lines we need, so a streaming producer can stop early
+    for _ in range(n):
+        line = process.stdin.readline()
+        if not line:
+            break
         process.stdout.write(line)
 
     return 0
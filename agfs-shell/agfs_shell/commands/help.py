@@ -37,10 +37,11 @@ def cmd_help(process: Process) -> int:
         # Group commands by category for better organization
         categories = {
             'File Operations': ['ls', 'tree', 'cat', 'mkdir', 'rm', 'mv', 'cp', 'stat', 'upload', 'download'],
-            'Text Processing': ['grep', 'wc', 'head', 'tail', 'sort', 'uniq', 'tr', 'rev', 'cut', 'jq', 'tee'],
+            'Text Processing': ['grep', 'wc', 'head', 'tail', 'watch', 'sort', 'uniq', 'tr', 'rev', 'cut', 'jq', 'tee'],
             'System': ['pwd', 'cd', 'echo', 'env', 'export', 'unset', 'sleep', 'basename', 'dirname', 'date'],
             'Testing': ['test'],
             'AGFS Management': ['mount', 'plugins'],
+            'Plugin Helpers': ['sql', 'search', 'enqueue'],
             'Control Flow': ['break', 'continue', 'exit', 'return', 'local'],
         }
 
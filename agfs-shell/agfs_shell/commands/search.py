@@ -0,0 +1,84 @@
+"""
+SEARCH command - semantic search in a VectorFS namespace.
+"""
+
+from ..process import Process
+from ..command_decorators import command
+from ..utils.plugin_files import resolve_arg_path, search_path
+from . import register_command
+
+
+@command()
+@register_command('search')
+def cmd_search(process: Process) -> int:
+    """
+    Semantic search in a VectorFS namespace
+
+    Usage: search [-n NUM] NAMESPACE QUERY...
+
+    Searches the documents of NAMESPACE (its docs/ directory, or a
+    directory inside it) and prints the most relevant chunks as
+    file:line: text [score], best match first.
+
+    Options:
+        -n NUM      Return top NUM results (default 10)
+
+    Examples:
+        search /vectorfs/project 'how to deploy containers'
+        search -n 3 /vectorfs/project database migration
+        search /vectorfs/project/docs/guides 'rollback'
+        search /vectorfs/project 'k:20 min_score:0.75 rollback'
+
+    The query may hold options of the search, such as k:<n> (up to n
+    results) and min_score:<s> (only results scoring at least s).
+    """
+    if not process.filesystem:
+        process.stderr.write("search: filesystem not available\n")
+        return 1
+
+    args = process.args[:]
+    limit = 0  # 0 means the server default
+    if args and args[0] == '-n':
+        if len(args) < 2:
+            process.stderr.write("search: option '-n' requires an argument\n")
+            return 2
+        try:
+            limit = int(args[1])
+        except ValueError:
+            limit = -1
+        if limit <= 0:
+            process.stderr.write("search: invalid number for -n\n")
+            return 2
+        args = args[2:]
+
+    if len(args) < 2:
+        process.stderr.write("Usage: search [-n NUM] NAMESPACE QUERY...\n")
+        return 2
+
+    path = search_path(resolve_arg_path(process, args[0]))
+    query = ' '.join(args[1:])
+
+    try:
+        result = process.filesystem.grep(
+            path=path,
+            pattern=query,
+            recursive=True,
+            stream=False,
+            limit=limit
+        )
+    except Exception as e:
+        process.stderr.write(f"search: {path}: {e}\n")
+        return 1
+
+    matches = result.get('matches', [])
+    if not matches:
+        process.stderr.write("No matches found\n")
+        return 1
+
+    for match in matches:
+        line = f"{match.get('file', '')}:{match.get('line', 0)}: {match.get('content', '')}"
+        score = (match.get('metadata') or {}).get('score')
+        if score is not None:
+            line += f" [score: {score:.3f}]"
+        process.stdout.write(line + "\n")
+    return 0
@@ -0,0 +1,59 @@
+"""
+SQL command - run a query through a SQLFS2 session.
+"""
+
+from ..process import Process
+from ..command_decorators import command
+from ..utils.plugin_files import SQLError, resolve_arg_path, run_sql
+from . import register_command
+
+
+@command()
+@register_command('sql')
+def cmd_sql(process: Process) -> int:
+    """
+    Run a SQL query on a SQLFS2 mount, database or table
+
+    Usage: sql PATH [QUERY...]
+
+    Opens a session in PATH, writes QUERY to its query file, prints the
+    result (JSON) and closes the session. Without QUERY, the query is
+    read from stdin.
+
+    Examples:
+        sql /sqlfs2/mydb/users 'SELECT * FROM users WHERE age > 18'
+        sql /sqlfs2/mydb 'SHOW TABLES'
+        sql /sqlfs2/mydb/users 'SELECT name FROM users' | jq '.[].name'
+        cat report.sql | sql /sqlfs2/mydb
+    """
+    if not process.filesystem:
+        process.stderr.write("sql: filesystem not available\n")
+        return 1
+
+    if not process.args:
+        process.stderr.write("sql: missing operand\n")
+        process.stderr.write("Usage: sql PATH [QUERY...]\n")
+        return 1
+
+    path = resolve_arg_path(process, process.args[0])
+    if len(process.args) > 1:
+        query = ' '.join(process.args[1:])
+    else:
+        query = process.stdin.read().decode('utf-8')
+    if not query.strip():
+        process.stderr.write("sql: empty query\n")
+        return 1
+
+    try:
+        result = run_sql(process.filesystem, path, query)
+    except SQLError as e:
+        process.stderr.write(f"sql: {e}\n")
+        return 1
+    except Exception as e:
+        process.stderr.write(f"sql: {path}: {e}\n")
+        return 1
+
+    process.stdout.write(result)
+    if result and not result.endswith(b'\n'):
+        process.stdout.write(b'\n')
+    return 0
@@ -2,9 +2,9 @@
 TAIL command - output the last part of files.
 """
 
-import time
 from ..process import Process
 from ..command_decorators import command
+from ..utils.watch import DEFAULT_INTERVAL, follow_appends, follow_queue, is_queue_dir
 from . import register_command
 
 
@@ -14,18 +14,32 @@ def cmd_tail(process: Process) -> int:
     """
     Output the last part of files
 
-    Usage: tail [-n count] [-f] [-F] [file...]
+    Usage: tail [-n count] [-f] [-F] [--consume] [-s seconds] [file...]
 
     Options:
         -n count    Output the last count lines (default: 10)
         -f          Follow mode: show last n lines, then continuously follow
+                    (restarts from the beginning if the file is truncated)
+                    On a QueueFS queue directory, prints its size and the
+                    message at its head each time they change, without
+                    taking messages from its consumers
         -F          Stream mode: for streamfs/streamrotatefs only
                     Continuously reads from the stream without loading history
                     Ideal for infinite streams like /streamfs/* or /streamrotate/*
+        --consume   With -f on a queue: dequeue and print its messages as
+                    they arrive, taking them from other consumers
+        -s seconds  Polling interval in follow mode (default: 0.1, 1 for queues)
+
+    Examples:
+        tail -f /local/tmp/app.log
+        tail -f /queuefs/jobs              # Watch a queue
+        tail -f --consume /queuefs/jobs    # Dequeue its messages
     """
     n = 10  # default
+    interval = None  # default depends on what is followed
     follow = False
     stream_only = False  # -F flag: skip reading history
+    consume = False  # --consume flag: dequeue the messages of a followed queue
     files = []
 
     # Parse flags
@@ -40,9 +54,22 @@ def cmd_tail(process: Process) -> int:
             except ValueError:
                 process.stderr.write(f"tail: invalid number: {args[i + 1]}\n")
                 return 1
+        elif args[i] == '-s' and i + 1 < len(args):
+            try:
+                interval = float(args[i + 1])
+            except ValueError:
+                interval = -1
+            if interval <= 0:
+                process.stderr.write(f"tail: invalid interval: {args[i + 1]}\n")
+                return 1
+            i += 2
+            continue
         elif args[i] == '-f':
             follow = True
             i += 1
+        elif args[i] == '--consume':
+            consume = True
+            i += 1
         elif args[i] == '-F':
             follow = True
             stream_only = True
@@ -73,16 +100,9 @@ def cmd_tail(process: Process) -> int:
                     process.stderr.write(b"tail: filesystem not available\n")
                     return 1
 
-                # Use streaming mode to read entire file
-                stream = process.filesystem.read_file(filename, stream=True)
-                chunks = []
-                for chunk in stream:
-                    if chunk:
-                        chunks.append(chunk)
-                content = b''.join(chunks)
-                lines = content.decode('utf-8', errors='replace').splitlines(keepends=True)
-                for line in lines[-n:]:
-                    process.stdout.write(line)
+                # The server reads the end of the file only
+                content, _ = process.filesystem.tail_file(filename, n)
+                process.stdout.write(content)
             except Exception as e:
                 process.stderr.write(f"tail: {filename}: {str(e)}\n")
                 return 1
@@ -120,53 +140,29 @@ def cmd_tail(process: Process) -> int:
                         else:
                             process.stderr.write(f"tail: {filename}: {error_msg}\n".encode())
                         return 1
+                elif is_queue_dir(process.filesystem, filename):
+                    # -f on a queue: watch it, or dequeue its messages with --consume
+                    if consume:
+                        process.stderr.write(f"==> Consuming messages of {filename} <==\n".encode())
+                    else:
+                        process.stderr.write(f"==> Following {filename} <==\n".encode())
+                    for message in follow_queue(process.filesystem, filename,
+                                                interval=interval or DEFAULT_INTERVAL,
+                                                consume=consume):
+                        process.stdout.write(message + b"\n")
+                        process.stdout.flush()
                 else:
                     # -f mode: Traditional follow mode
                     # First, output the last n lines
-                    stream = process.filesystem.read_file(filename, stream=True)
-                    chunks = []
-                    for chunk in stream:
-                        if chunk:
-                            chunks.append(chunk)
-                    content = b''.join(chunks)
-                    lines = content.decode('utf-8', errors='replace').splitlines(keepends=True)
-                    for line in lines[-n:]:
-                        process.stdout.write(line)
+                    content, offset = process.filesystem.tail_file(filename, n)
+                    process.stdout.write(content)
                     process.stdout.flush()
 
-                    # Get current file size
-                    file_info = process.filesystem.get_file_info(filename)
-                    current_size = file_info.get('size', 0)
-
-                    # Now continuously poll for new content
-                    try:
-                        while True:
-                            time.sleep(0.1)  # Poll every 100ms
-
-                            # Check file size
-                            try:
-                                file_info = process.filesystem.get_file_info(filename)
-                                new_size = file_info.get('size', 0)
-                            except Exception:
-                                # File might not exist yet, keep waiting
-                                continue
-
-                            if new_size > current_size:
-                                # Read new content from offset using streaming
-                                stream = process.filesystem.read_file(
-                                    filename,
-                                    offset=current_size,
-                                    size=new_size - current_size,
-                                    stream=True
-                                )
-                                for chunk in stream:
-                                    if chunk:
-                                        process.stdout.write(chunk)
-                                process.stdout.flush()
-                                current_size = new_size
-                    except KeyboardInterrupt:
-                        # Re-raise to allow proper signal propagation in script mode
-                        raise
+                    # Now continuously poll for appended content
+                    for data in follow_appends(process.filesystem, filename, offset,
+                                               interval=interval or 0.1):
+                        process.stdout.write(data)
+                        process.stdout.flush()
             else:
                 # No filesystem - should not happen in normal usage
                 process.stderr.write(b"tail: filesystem not available\n")
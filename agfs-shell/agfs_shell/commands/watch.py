@@ -0,0 +1,81 @@
+"""
+WATCH command - print a file whenever its content changes.
+"""
+
+import datetime
+from ..process import Process
+from ..command_decorators import command
+from ..utils.watch import DEFAULT_INTERVAL, follow_changes
+from . import register_command
+
+
+@command(needs_path_resolution=True, supports_streaming=True)
+@register_command('watch')
+def cmd_watch(process: Process) -> int:
+    """
+    Print a file now and whenever its content changes
+
+    Usage: watch [-n seconds] [-c count] file
+
+    Suited to small status files that are rewritten rather than appended
+    to, such as VectorFS .indexing or QueueFS size and peek. Use tail -f
+    for files that grow, like logs.
+
+    Options:
+        -n seconds  Polling interval (default: 1)
+        -c count    Exit after printing count versions of the file
+
+    Examples:
+        watch /vectorfs/project/.indexing
+        watch -n 5 /queuefs/jobs/size
+    """
+    interval = DEFAULT_INTERVAL
+    count = 0  # 0 means forever
+    args = process.args[:]
+    while args and args[0] in ('-n', '-c'):
+        opt = args.pop(0)
+        if not args:
+            process.stderr.write(f"watch: option '{opt}' requires an argument\n")
+            return 2
+        value = args.pop(0)
+        try:
+            if opt == '-n':
+                interval = float(value)
+                valid = interval > 0
+            else:
+                count = int(value)
+                valid = count > 0
+        except ValueError:
+            valid = False
+        if not valid:
+            process.stderr.write(f"watch: invalid value for {opt}: {value}\n")
+            return 2
+
+    if len(args) != 1:
+        process.stderr.write("Usage: watch [-n seconds] [-c count] file\n")
+        return 2
+
+    if not process.filesystem:
+        process.stderr.write("watch: filesystem not available\n")
+        return 1
+
+    path = args[0]
+    try:
+        process.filesystem.get_file_info(path)
+    except Exception as e:
+        process.stderr.write(f"watch: {path}: {e}\n")
+        return 1
+
+    printed = 0
+    for content in follow_changes(process.filesystem, path, interval=interval):
+        now = datetime.datetime.now().strftime('%H:%M:%S')
+        process.stdout.write(f"==> {path} ({now}) <==\n")
+        process.stdout.write(content)
+        if content and not content.endswith(b'\n'):
+            process.stdout.write(b'\n')
+        process.stdout.flush()
+
+        printed += 1
+        if count and printed >= count:
+            break
+    return 0
@@ -5,6 +5,7 @@ import shlex
 from typing import List, Optional
 from .builtins import BUILTINS
 from .filesystem import AGFSFileSystem
+from .utils.plugin_files import PLUGIN_COMMANDS, plugin_mounts
 
 
 class ShellCompleter:
@@ -39,8 +40,8 @@ class ShellCompleter:
                 # Beginning of line - complete command names
                 self.matches = self._complete_command(text)
             else:
-                # Middle of line - complete paths
-                self.matches = self._complete_path(text)
+                # Middle of line - complete arguments
+                self.matches = self._complete_argument(line[:begin_idx], text)
 
         # Return the next match
         if state < len(self.matches):
@@ -55,6 +56,46 @@ class ShellCompleter:
         matches = [cmd for cmd in self.command_names if cmd.startswith(text)]
         return matches
 
+    def _complete_argument(self, before: str, text: str) -> List[str]:
+        """Complete a command argument, given the line before it"""
+        words = before.split()
+        if words and words[0] in PLUGIN_COMMANDS:
+            # Plugin helper - complete its mounts, namespaces, tables or queues
+            return self._complete_plugin_arg(words, text)
+        return self._complete_path(text)
+
+    def _complete_plugin_arg(self, words: List[str], text: str) -> List[str]:
+        """
+        Complete the arguments of sql, search and enqueue
+
+        The path argument completes to directories of the command's plugin:
+        with nothing typed, the plugin's mounts are offered. The arguments
+        after the path are free text (queries, messages) and not completed.
+        """
+        # Skip options and their values to find the position of the argument
+        positional = []
+        skip_next = False
+        for word in words[1:]:
+            if skip_next:
+                skip_next = False
+            elif word == '-n':
+                skip_next = True
+            elif not word.startswith('-'):
+                positional.append(word)
+        if positional or text.startswith('-'):
+            return []
+
+        if not text:
+            mounts = plugin_mounts(self.filesystem, PLUGIN_COMMANDS[words[0]])
+            if mounts:
+                return [self._quote_if_needed(m.rstrip('/') + '/') for m in mounts]
+        return self._complete_path(text, dirs_only=True)
+
+    @staticmethod
+    def _is_dir(entry: dict) -> bool:
+        """Check if a directory listing entry is a directory"""
+        return bool(entry.get('isDir')) or entry.get('type') == 'directory'
+
     def _needs_quoting(self, path: str) -> bool:
         """Check if a path needs to be quoted"""
         # Characters that require quoting in shell
@@ -68,8 +109,8 @@ class ShellCompleter:
             return shlex.quote(path)
         return path
 
-    def _complete_path(self, text: str) -> List[str]:
-        """Complete AGFS paths"""
+    def _complete_path(self, text: str, dirs_only: bool = False) -> List[str]:
+        """Complete AGFS paths, or only directories if dirs_only is set"""
         # Get current working directory (virtual path in chroot mode)
         virtual_cwd = self.shell.cwd if self.shell else '/'
 
@@ -126,6 +167,8 @@ class ShellCompleter:
             matches = []
             for entry in entries:
                 name = entry.get('name', '')
+                if dirs_only and not self._is_dir(entry):
+                    continue
                 if name and name.startswith(partial):
                     # Construct absolute virtual path (what user sees)
                     if virtual_dir == '/':
@@ -136,7 +179,7 @@ class ShellCompleter:
                         abs_path = f"{dir_clean}/{name}"
 
                     # Add trailing slash for directories
-                    if entry.get('type') == 'directory':
+                    if self._is_dir(entry):
                         abs_path += '/'
 
                     # Convert to relative path if needed
@@ -11,6 +11,9 @@ class Config:
         # Support both AGFS_API_URL (preferred) and AGFS_SERVER_URL (backward compatibility)
         self.server_url = os.getenv('AGFS_API_URL') or os.getenv('AGFS_SERVER_URL', 'http://localhost:8080')
 
+        # Bearer token, for servers whose listener requires one
+        self.token = os.getenv('AGFS_TOKEN') or None
+
         # Request timeout in seconds (default: 30)
         # Can be overridden via AGFS_TIMEOUT environment variable
         # Increased default for better support of large file transfers
@@ -1,14 +1,15 @@
 """AGFS File System abstraction layer"""
 
-from typing import BinaryIO, Iterator, Optional, Union
+from typing import BinaryIO, Iterator, Optional, Tuple, Union
 
 from pyagfs import AGFSClient, AGFSClientError
+from pyagfs.exceptions import AGFSNotSupportedError
 
 
 class AGFSFileSystem:
     """Abstraction layer for AGFS file system operations"""
 
-    def __init__(self, server_url: str = "http://localhost:8080", timeout: int = 30):
+    def __init__(self, server_url: str = "http://localhost:8080", timeout: int = 30, token: Optional[str] = None):
         """
         Initialize AGFS file system
 
@@ -17,9 +18,10 @@ class AGFSFileSystem:
             timeout: Request timeout in seconds (default: 30)
                     - Increased from 5 to 30 for better support of large file transfers
                     - Each 8KB chunk upload/download should complete within this time
+            token: Bearer token, for servers whose listener requires one
         """
         self.server_url = server_url
-        self.client = AGFSClient(server_url, timeout=timeout)
+        self.client = AGFSClient(server_url, timeout=timeout, token=token)
         self._connected = False
 
     def check_connection(self) -> bool:
@@ -81,6 +83,29 @@ class AGFSFileSystem:
             # SDK error already includes path, don't duplicate it
             raise AGFSClientError(str(e))
 
+    def tail_file(self, path: str, lines: int) -> Tuple[bytes, int]:
+        """
+        Read the last lines of a file
+
+        The server reads the end of the file only; from servers that cannot
+        tail files, the whole file is read instead.
+
+        Returns:
+            The lines and the offset of the end of the file, to follow it from
+
+        Raises:
+            AGFSClientError: If file cannot be read
+        """
+        try:
+            return self.client.tail(path, lines)
+        except AGFSNotSupportedError:
+            content = b''.join(self.read_file(path, stream=True))
+            tail = content.splitlines(keepends=True)[-lines:] if lines > 0 else []
+            return b''.join(tail), len(content)
+        except AGFSClientError as e:
+            # SDK error already includes path, don't duplicate it
+            raise AGFSClientError(str(e))
+
     def write_file(
         self,
         path: str,
@@ -9,6 +9,40 @@ from .streams import InputStream, OutputStream, ErrorStream
 from .control_flow import ControlFlowException
 
 
+class Pipe(queue.Queue):
+    """
+    Bounded queue of chunks between two processes
+
+    When the reading process exits early (e.g. head), the pipe is closed and
+    further writes raise BrokenPipeError, so the writer stops instead of
+    blocking on a full queue forever.
+    """
+
+    def __init__(self, maxsize: int = 10):
+        super().__init__(maxsize=maxsize)
+        self.closed = threading.Event()
+
+    def send(self, item):
+        """Put a chunk (or None for EOF), waiting for room in the pipe"""
+        while True:
+            if self.closed.is_set():
+                raise BrokenPipeError("pipe closed by reader")
+            try:
+                self.put(item, timeout=0.1)
+                return
+            except queue.Full:
+                continue
+
+    def close_reader(self):
+        """Mark the pipe closed by its reader and drop unread chunks"""
+        self.closed.set()
+        try:
+            while True:
+                self.get_nowait()
+        except queue.Empty:
+            pass
+
+
 class StreamingPipeline:
     """
     True streaming pipeline implementation
@@ -47,7 +81,7 @@ class StreamingPipeline:
             return self.processes[0].execute()
 
         # Create pipes (queues) between processes
-        self.pipes = [queue.Queue(maxsize=10) for _ in range(len(self.processes) - 1)]
+        self.pipes = [Pipe(maxsize=10) for _ in range(len(self.processes) - 1)]
         self.exit_codes = [None] * len(self.processes)
 
         # Create wrapper streams that read from/write to queues
@@ -101,71 +135,71 @@ class StreamingPipeline:
             # Signal EOF to next process by properly closing stdout
             # This ensures any buffered data is flushed before EOF
             if index < len(self.processes) - 1:
-                if isinstance(process.stdout, StreamingOutputStream):
-                    process.stdout.close()  # flush remaining buffer and send EOF
-                else:
-                    self.pipes[index].put(None)  # EOF marker
+                try:
+                    if isinstance(process.stdout, StreamingOutputStream):
+                        process.stdout.close()  # flush remaining buffer and send EOF
+                    else:
+                        self.pipes[index].send(None)  # EOF marker
+                except BrokenPipeError:
+                    # The next process has already exited
+                    pass
+
+            # Let the previous process know nobody reads its output any more
+            if index > 0:
+                self.pipes[index - 1].close_reader()
 
 
 class StreamingInputStream(InputStream):
     """Input stream that reads from a queue in chunks"""
 
-    def __init__(self, pipe: queue.Queue):
+    def __init__(self, pipe: Pipe):
         super().__init__(None)
         self.pipe = pipe
-        self._buffer = io.BytesIO()
+        self._pending = bytearray()  # Received but not yet read data
         self._eof = False
 
+    def _fill(self) -> bool:
+        """Wait for the next chunk; returns False at EOF"""
+        if self._eof:
+            return False
+        chunk = self.pipe.get()
+        if chunk is None:  # EOF
+            self._eof = True
+            return False
+        self._pending += chunk
+        return True
+
+    def _take(self, size: int) -> bytes:
+        data = bytes(self._pending[:size])
+        del self._pending[:size]
+        return data
+
     def read(self, size: int = -1) -> bytes:
         """Read from the queue-based pipe"""
         if size == -1:
             # Read all available data
-            chunks = []
-            while not self._eof:
-                chunk = self.pipe.get()
-                if chunk is None:  # EOF
-                    self._eof = True
-                    break
-                chunks.append(chunk)
-            return b''.join(chunks)
-        else:
-            # Read specific number of bytes
-            data = b''
-            while len(data) < size and not self._eof:
-                # Check if we have buffered data
-                buffered = self._buffer.read(size - len(data))
-                if buffered:
-                    data += buffered
-                    if len(data) >= size:
-                        break
-
-                # Get more data from queue
-                chunk = self.pipe.get()
-                if chunk is None:  # EOF
-                    self._eof = True
-                    break
-
-                # Put in buffer
-                self._buffer = io.BytesIO(chunk)
-
-            return data
+            while self._fill():
+                pass
+            return self._take(len(self._pending))
+
+        # Read specific number of bytes
+        while len(self._pending) < size and self._fill():
+            pass
+        return self._take(size)
 
     def readline(self) -> bytes:
         """Read a line from the pipe"""
-        line = []
-        while not self._eof:
-            byte = self.read(1)
-            if not byte:
-                break
-            line.append(byte)
-            if byte == b'\n':
-                break
-        return b''.join(line)
+        while True:
+            newline = self._pending.find(b'\n')
+            if newline >= 0:
+                return self._take(newline + 1)
+            if not self._fill():
+                return self._take(len(self._pending))
 
     def readlines(self) -> list:
         """Read all lines from the pipe"""
         lines = []
-        while not self._eof:
+        while True:
             line = self.readline()
             if not line:
                 break
@@ -176,7 +210,7 @@ class StreamingInputStream(InputStream):
 class StreamingOutputStream(OutputStream):
     """Output stream that writes to a queue in chunks"""
 
-    def __init__(self, pipe: queue.Queue, chunk_size: int = 8192):
+    def __init__(self, pipe: Pipe, chunk_size: int = 8192):
         super().__init__(None)
         self.pipe = pipe
         self.chunk_size = chunk_size
@@ -201,14 +235,14 @@ class StreamingOutputStream(OutputStream):
         """Flush buffered data to the queue"""
         self._buffer.seek(0)
         data = self._buffer.read()
-        if data:
-            self.pipe.put(data)
         self._buffer = io.BytesIO()
+        if data:
+            self.pipe.send(data)
 
     def close(self):
         """Close the stream and flush remaining data"""
         self.flush()
-        self.pipe.put(None)  # EOF marker
+        self.pipe.send(None)  # EOF marker
 
 
 class Pipeline:
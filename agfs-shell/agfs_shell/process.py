@@ -71,6 +71,10 @@ class Process:
         except ControlFlowException:
             # Let control flow exceptions (break, continue, return) propagate
             raise
+        except BrokenPipeError:
+            # The next command in the pipeline stopped reading (e.g. head):
+            # stop quietly, like a process killed by SIGPIPE
+            self.exit_code = 141
         except Exception as e:
             self.stderr.write(f"Error executing '{self.command}': {str(e)}\n")
             self.exit_code = 1
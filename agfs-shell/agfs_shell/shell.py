@@ -8,7 +8,7 @@ from rich.console import Console
 from .parser import CommandParser
 from .pipeline import Pipeline
 from .process import Process
-from .streams import InputStream, OutputStream, ErrorStream
+from .streams import InputStream, OutputStream, ErrorStream, AGFSInputStream, AGFSOutputStream
 from .builtins import get_builtin
 from .filesystem import AGFSFileSystem
 from .command_decorators import CommandMetadata
@@ -33,10 +33,11 @@ from .expression import ExpressionExpander
 class Shell:
     """Simple shell with pipeline support"""
 
-    def __init__(self, server_url: str = "http://localhost:8080", timeout: int = 30, initial_env: dict = None):
+    def __init__(self, server_url: str = "http://localhost:8080", timeout: int = 30, initial_env: dict = None,
+                 token: str = None):
         self.parser = CommandParser()
         self.running = True
-        self.filesystem = AGFSFileSystem(server_url, timeout=timeout)
+        self.filesystem = AGFSFileSystem(server_url, timeout=timeout, token=token)
         self.server_url = server_url
         self.cwd = '/'  # Current working directory (virtual path when chroot is set)
         self.chroot_root = None  # None means no chroot, otherwise absolute path to chroot root
@@ -1962,11 +1963,12 @@ class Shell:
                 return 1
 
         # Resolve paths in redirections
+        stdin_stream = None
         if 'stdin' in redirections:
             input_file = self.resolve_path(redirections['stdin'])
             try:
-                # Use AGFS to read input file
-                stdin_data = self.filesystem.read_file(input_file)
+                # Stream the input file from AGFS instead of loading it
+                stdin_stream = AGFSInputStream(self.filesystem, input_file)
             except AGFSClientError as e:
                 error_msg = self.filesystem.get_error_message(e)
                 self.console.print(f"[red]shell: {error_msg}[/red]", highlight=False)
@@ -2014,14 +2016,23 @@ class Shell:
                 args = resolved_args
 
             # Create streams
-            if i == 0 and stdin_data is not None:
+            if i == 0 and stdin_stream is not None:
+                stdin = stdin_stream
+            elif i == 0 and stdin_data is not None:
                 stdin = InputStream.from_bytes(stdin_data)
             else:
                 stdin = InputStream.from_bytes(b'')
 
             # For streaming output: if no redirections and last command in pipeline,
-            # output directly to real stdout for real-time streaming
-            if 'stdout' not in redirections and i == len(commands) - 1:
+            # output directly to real stdout for real-time streaming; with an
+            # output redirection, stream the last command's output to the file
+            if i == len(commands) - 1 and 'stdout' in redirections:
+                stdout = AGFSOutputStream(
+                    self.filesystem,
+                    self.resolve_path(redirections['stdout']),
+                    append=redirections.get('stdout_mode', 'write') == 'append'
+                )
+            elif 'stdout' not in redirections and i == len(commands) - 1:
                 stdout = OutputStream.from_stdout()
             else:
                 stdout = OutputStream.to_buffer()
@@ -2046,39 +2057,22 @@ class Shell:
             processes.append(process)
 
         # Special case: direct streaming from stdin to file
-        # When: single streaming-capable command with no args, stdin from pipe, output to file
-        # Implementation: Loop and write chunks (like agfs-shell's write --stream)
+        # When: single streaming-capable command with no args, stdin from terminal, output to file
         # Using metadata instead of hardcoded check for 'cat'
         if ('stdout' in redirections and
             len(processes) == 1 and
             CommandMetadata.supports_streaming(processes[0].command) and
             not processes[0].args and
-            stdin_data is None):
-
-            output_file = self.resolve_path(redirections['stdout'])
-            mode = redirections.get('stdout_mode', 'write')
+            stdin_data is None and stdin_stream is None):
 
+            output = processes[0].stdout
             try:
-                # Streaming write: read chunks and write each one separately
-                # This enables true streaming (each chunk sent immediately to server)
-                chunk_size = 8192  # 8KB chunks
-                total_bytes = 0
-                is_first_chunk = True
-                write_response = None
-
                 while True:
-                    chunk = sys.stdin.buffer.read(chunk_size)
+                    chunk = sys.stdin.buffer.read(8192)
                     if not chunk:
                         break
-
-                    # First chunk: overwrite or append based on mode
-                    # Subsequent chunks: always append
-                    append = (mode == 'append') or (not is_first_chunk)
-
-                    # Write chunk immediately (separate HTTP request per chunk)
-                    write_response = self.filesystem.write_file(output_file, chunk, append=append)
-                    total_bytes += len(chunk)
-                    is_first_chunk = False
+                    output.write(chunk)
+                output.close()
 
                 exit_code = 0
                 stderr_data = b''
@@ -2087,31 +2081,32 @@ class Shell:
                 self.console.print(f"[red]shell: {error_msg}[/red]", highlight=False)
                 return 1
             except Exception as e:
-                self.console.print(f"[red]shell: {output_file}: {str(e)}[/red]", highlight=False)
+                self.console.print(f"[red]shell: {output.path}: {str(e)}[/red]", highlight=False)
                 return 1
         else:
             # Normal execution path
             pipeline = Pipeline(processes)
-            exit_code = pipeline.execute()
+            try:
+                exit_code = pipeline.execute()
+            finally:
+                if stdin_stream is not None:
+                    stdin_stream.close()
 
             # Get results
             stdout_data = pipeline.get_stdout()
             stderr_data = pipeline.get_stderr()
 
-            # Handle output redirection (>)
+            # Handle output redirection (>): finish streaming the output to the file
             if 'stdout' in redirections:
-                output_file = self.resolve_path(redirections['stdout'])
-                mode = redirections.get('stdout_mode', 'write')
-                append = (mode == 'append')
+                output = processes[-1].stdout
                 try:
-                    # Use AGFS to write output file
-                    self.filesystem.write_file(output_file, stdout_data, append=append)
+                    output.close()
                 except AGFSClientError as e:
                     error_msg = self.filesystem.get_error_message(e)
                     self.console.print(f"[red]shell: {error_msg}[/red]", highlight=False)
                     return 1
                 except Exception as e:
-                    self.console.print(f"[red]shell: {output_file}: {str(e)}[/red]", highlight=False)
+                    self.console.print(f"[red]shell: {output.path}: {str(e)}[/red]", highlight=False)
                     return 1
 
         # Output handling
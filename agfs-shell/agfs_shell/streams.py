@@ -2,6 +2,8 @@
 
 import sys
 import io
+import queue
+import threading
 from typing import Optional, Union, BinaryIO, TextIO, TYPE_CHECKING
 
 if TYPE_CHECKING:
@@ -187,10 +189,63 @@ class ErrorStream(Stream):
         return cls(None)
 
 
+class _ChunkReader(io.RawIOBase):
+    """Raw reader over an iterator of byte chunks"""
+
+    def __init__(self, chunks):
+        self._chunks = iter(chunks)
+        self._pending = b''
+
+    def readable(self) -> bool:
+        return True
+
+    def readinto(self, b) -> int:
+        while not self._pending:
+            try:
+                self._pending = next(self._chunks)
+            except StopIteration:
+                return 0
+        n = min(len(b), len(self._pending))
+        b[:n] = self._pending[:n]
+        self._pending = self._pending[n:]
+        return n
+
+    def close(self):
+        if hasattr(self._chunks, 'close'):
+            self._chunks.close()
+        super().close()
+
+
+class AGFSInputStream(InputStream):
+    """Input stream that reads an AGFS file in streaming mode (for < redirection)"""
+
+    def __init__(self, filesystem: 'AGFSFileSystem', path: str):
+        """
+        Open an AGFS file for reading
+
+        Raises:
+            AGFSClientError: If the file cannot be read
+        """
+        chunks = filesystem.read_file(path, stream=True)
+        super().__init__(io.BufferedReader(_ChunkReader(chunks)))
+
+
 class AGFSOutputStream(OutputStream):
-    """Output stream that writes directly to AGFS file in streaming mode"""
+    """
+    Output stream that writes to an AGFS file in streaming mode
+
+    Everything written goes to the server in a single upload whose body is
+    streamed from a bounded queue by a background thread, so large outputs
+    (e.g. `cat /s3fs/big.ndjson | head -n 1000000 > /sqlfs2/...`) need
+    neither memory nor temp files, and virtual files that act on the whole
+    write (SQLFS2 data, QueueFS enqueue) receive it in one piece.
+    close() finishes the upload and raises its error, if any.
+    """
 
-    def __init__(self, filesystem: 'AGFSFileSystem', path: str, append: bool = False):
+    _EOF = object()
+
+    def __init__(self, filesystem: 'AGFSFileSystem', path: str, append: bool = False,
+                 queue_size: int = 16):
         """
         Initialize AGFS output stream
 
@@ -198,35 +253,55 @@ class AGFSOutputStream(OutputStream):
             filesystem: AGFS filesystem instance
             path: Target file path in AGFS
             append: If True, append to file; if False, overwrite
+            queue_size: Number of written chunks buffered ahead of the upload
         """
-        # Don't call super().__init__ as we handle buffering differently
+        # Don't call super().__init__ as we don't use a file or buffer
         self.mode = 'wb'
         self._fd = None
         self._file = None
-        self._buffer = io.BytesIO()  # Temporary buffer
+        self._buffer = None
         self._last_char = None  # Track last written character
         self.filesystem = filesystem
         self.path = path
         self.append = append
-        self._chunks = []  # Collect chunks
-        self._total_size = 0
+        self._queue = queue.Queue(maxsize=queue_size)
+        self._thread = None
+        self._error = None
+        self._closed = False
+
+    def _chunks(self):
+        while True:
+            chunk = self._queue.get()
+            if chunk is self._EOF:
+                return
+            yield chunk
+
+    def _upload(self):
+        try:
+            self.filesystem.write_file(self.path, self._chunks(), append=self.append)
+        except Exception as e:
+            self._error = e
+            # Unblock the writer: drain what it queues until it sees the error
+            while self._queue.get() is not self._EOF:
+                pass
+
+    def _start(self):
+        if self._thread is None:
+            self._thread = threading.Thread(target=self._upload, name=f"upload-{self.path}", daemon=True)
+            self._thread.start()
 
     def write(self, data: Union[bytes, str]) -> int:
-        """Write data to buffer"""
+        """Queue data for upload, waiting while the upload is behind"""
         if isinstance(data, str):
             data = data.encode('utf-8')
-
-        # Track last character for newline checking
-        if data and len(data) > 0:
-            self._last_char = data[-1:]
-
-        # Add to chunks
-        self._chunks.append(data)
-        self._total_size += len(data)
-
-        # Also write to buffer for get_value() compatibility
-        self._buffer.write(data)
-
+        if not data:
+            return 0
+        if self._error is not None:
+            raise self._error
+
+        self._last_char = data[-1:]
+        self._start()
+        self._queue.put(data)
         return len(data)
 
     def ends_with_newline(self) -> bool:
@@ -234,27 +309,21 @@ class AGFSOutputStream(OutputStream):
         return self._last_char == b'\n' if self._last_char else True
 
     def flush(self):
-        """Flush accumulated data to AGFS"""
-        if not self._chunks:
-            return
-
-        # Combine all chunks
-        data = b''.join(self._chunks)
+        """No-op: data is uploaded as it is written"""
 
-        # Write to AGFS
-        try:
-            self.filesystem.write_file(self.path, data, append=self.append)
-            # After first write, switch to append mode for subsequent flushes
-            self.append = True
-            # Clear chunks
-            self._chunks = []
-            self._total_size = 0
-        except Exception as e:
-            # Re-raise to let caller handle
-            raise
+    def get_value(self) -> bytes:
+        """Output went to the AGFS file"""
+        return b''
 
     def close(self):
-        """Close stream and flush remaining data"""
-        self.flush()
-        if self._buffer is not None:
-            self._buffer.close()
+        """Finish the upload, raising its error if it failed"""
+        if self._closed:
+            return
+        self._closed = True
+
+        # Write (create or truncate) the file even if nothing was written
+        self._start()
+        self._queue.put(self._EOF)
+        self._thread.join()
+        if self._error is not None:
+            raise self._error
@@ -2,4 +2,4 @@
 Utility functions for agfs-shell commands.
 """
 
-__all__ = ['formatters']
+__all__ = ['formatters', 'plugin_files']
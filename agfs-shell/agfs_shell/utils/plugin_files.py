@@ -0,0 +1,109 @@
+"""
+Helpers for commands that wrap the virtual-file protocols of AGFS plugins.
+
+SQLFS2 runs queries through session directories (ctl, query, result, error),
+VectorFS searches the docs/ directory of a namespace, and QueueFS enqueues
+messages by writing to <queue>/enqueue. The sql, search and enqueue commands
+and tab completion use these helpers so users don't need to know the paths.
+"""
+
+import os
+
+from pyagfs import AGFSClientError
+
+
+# Plugins whose mounts the plugin-aware commands operate on
+PLUGIN_COMMANDS = {
+    'sql': 'sqlfs2',
+    'search': 'vectorfs',
+    'enqueue': 'queuefs',
+}
+
+
+class SQLError(Exception):
+    """A query failed; the message is the error reported by SQLFS2"""
+
+
+def resolve_arg_path(process, path: str) -> str:
+    """
+    Resolve a path argument against the shell's cwd (and chroot).
+
+    Used by commands that take a path next to free-form arguments, which the
+    shell's automatic path resolution would mangle.
+    """
+    if process.shell is not None:
+        return process.shell.resolve_path(path)
+    if not path.startswith('/'):
+        path = os.path.join(getattr(process, 'cwd', '/'), path)
+    return os.path.normpath(path)
+
+
+def plugin_mounts(filesystem, plugin_name: str) -> list:
+    """
+    Return the mount paths of a plugin, sorted.
+
+    Returns an empty list if the mounts cannot be listed.
+    """
+    try:
+        mounts = filesystem.client.mounts()
+    except Exception:
+        return []
+    return sorted(m.get('path', '') for m in mounts if m.get('pluginName') == plugin_name)
+
+
+def run_sql(filesystem, path: str, query: str) -> bytes:
+    """
+    Run a query in a new SQLFS2 session and return its result.
+
+    Args:
+        filesystem: AGFS file system
+        path: SQLFS2 mount, database or table directory to open the session in
+        query: SQL to run
+
+    Returns:
+        The contents of the session's result file (JSON)
+
+    Raises:
+        SQLError: If the query fails
+        AGFSClientError: If the session cannot be opened
+    """
+    base = path.rstrip('/')
+    sid = filesystem.read_file(f"{base}/ctl").decode('utf-8').strip()
+    if not sid:
+        raise AGFSClientError(f"no session ID returned by {base}/ctl")
+    session = f"{base}/{sid}"
+
+    try:
+        try:
+            filesystem.write_file(f"{session}/query", query.encode('utf-8'))
+        except AGFSClientError as e:
+            raise SQLError(_session_error(filesystem, session) or str(e))
+        return filesystem.read_file(f"{session}/result")
+    finally:
+        try:
+            filesystem.write_file(f"{session}/ctl", b"close")
+        except Exception:
+            # Idle sessions are cleaned up by the server anyway
+            pass
+
+
+def _session_error(filesystem, session: str) -> str:
+    """Return the last error of a SQLFS2 session, or '' if there is none"""
+    try:
+        return filesystem.read_file(f"{session}/error").decode('utf-8').strip()
+    except Exception:
+        return ''
+
+
+def search_path(path: str) -> str:
+    """
+    Return the directory to search for a VectorFS namespace path.
+
+    VectorFS only searches docs/ directories, so a namespace is searched
+    through its docs/ directory.
+    """
+    path = path.rstrip('/') or '/'
+    parts = path.split('/')
+    if 'docs' in parts:
+        return path
+    return f"{path}/docs"
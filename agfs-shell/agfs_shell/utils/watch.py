@@ -0,0 +1,112 @@
+"""
+Change following for tail -f and watch.
+
+AGFS has no change-event API, so files are followed by polling: appended
+data is detected from the file size, changed content of virtual files
+(e.g. VectorFS .indexing or QueueFS size) by re-reading them, and QueueFS
+queues by reading their peek and size files, or by dequeuing their messages
+when asked to consume them.
+"""
+
+import time
+from typing import Callable, Iterator
+
+
+# Default polling interval in seconds
+DEFAULT_INTERVAL = 1.0
+
+# What QueueFS returns when reading dequeue or peek on an empty queue
+EMPTY_QUEUE_MESSAGE = b'{}'
+
+
+def is_queue_dir(filesystem, path: str) -> bool:
+    """Check if path is a QueueFS queue directory (one with a dequeue file)"""
+    try:
+        entries = filesystem.list_directory(path)
+    except Exception:
+        return False
+    return any(e.get('name') == 'dequeue' and not e.get('isDir') for e in entries)
+
+
+def follow_appends(filesystem, path: str, offset: int, interval: float = 0.1,
+                   sleep: Callable[[float], None] = time.sleep) -> Iterator[bytes]:
+    """
+    Yield data appended to a file from offset on, forever.
+
+    If the file shrinks (truncated or rotated), following restarts from
+    its beginning. A file that is missing is waited for.
+    """
+    while True:
+        sleep(interval)
+        try:
+            size = filesystem.get_file_info(path).get('size', 0)
+        except Exception:
+            # File might not exist (yet or any more), keep waiting
+            continue
+
+        if size < offset:
+            offset = 0
+        if size > offset:
+            data = filesystem.read_file(path, offset=offset, size=size - offset)
+            if data:
+                offset += len(data)
+                yield data
+
+
+def follow_changes(filesystem, path: str, interval: float = DEFAULT_INTERVAL,
+                   sleep: Callable[[float], None] = time.sleep) -> Iterator[bytes]:
+    """
+    Yield the content of a file now and every time it changes, forever.
+
+    Unlike follow_appends, this re-reads the whole file, which suits small
+    virtual files whose content is replaced rather than appended to.
+    """
+    last = None
+    while True:
+        try:
+            content = filesystem.read_file(path)
+        except Exception:
+            content = None
+        if content is not None and content != last:
+            last = content
+            yield content
+        sleep(interval)
+
+
+def follow_queue(filesystem, queue: str, interval: float = DEFAULT_INTERVAL,
+                 sleep: Callable[[float], None] = time.sleep,
+                 consume: bool = False) -> Iterator[bytes]:
+    """
+    Yield what happens to a QueueFS queue, forever.
+
+    By default the queue is only observed: a line with its size and the
+    message at its head is yielded every time either changes, and the
+    messages stay for the queue's consumers. With consume, messages are
+    dequeued and yielded as they arrive, each one delivered to this
+    follower only.
+
+    Read errors are retried at the next poll.
+    """
+    queue = queue.rstrip('/')
+    last = None
+    while True:
+        try:
+            if consume:
+                message = filesystem.read_file(queue + '/dequeue').strip()
+                if message and message != EMPTY_QUEUE_MESSAGE:
+                    yield message
+                    # Drain the queue without waiting
+                    continue
+            else:
+                size = filesystem.read_file(queue + '/size').strip()
+                head = filesystem.read_file(queue + '/peek').strip()
+                state = size + b' queued'
+                if head and head != EMPTY_QUEUE_MESSAGE:
+                    state += b', head: ' + head
+                if state != last:
+                    last = state
+                    yield state
+        except Exception:
+            # Queue might be unavailable for a while, keep polling
+            pass
+        sleep(interval)
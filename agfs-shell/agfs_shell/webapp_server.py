@@ -52,8 +52,8 @@ class ShellSession:
             # Complete command names
             return self.completer._complete_command(text)
         else:
-            # Complete paths
-            return self.completer._complete_path(text)
+            # Complete arguments (paths, or plugin directories for sql/search/enqueue)
+            return self.completer._complete_argument(before_cursor[:len(before_cursor) - len(text)], text)
 
     async def handle_command(self, command: str):
         """Execute a command and send output to WebSocket"""
@@ -1,4 +1,5 @@
 import unittest
+from agfs_shell.builtins import BUILTINS
 from agfs_shell.pipeline import Pipeline
 from agfs_shell.process import Process
 from agfs_shell.streams import InputStream, OutputStream, ErrorStream
@@ -71,5 +72,36 @@ class TestPipeline(unittest.TestCase):
         self.assertEqual(pipeline.execute(), 0)
         self.assertEqual(pipeline.get_stdout(), b"")
 
+    def test_reader_exits_early(self):
+        # endless producer | head -n 2: the producer must stop, not block
+        def produce(proc):
+            while True:
+                proc.stdout.write(b"line\n" * 1000)
+        p1 = Process("yes", [], executor=produce)
+        p2 = Process("head", ["-n", "2"], executor=BUILTINS['head'])
+
+        pipeline = Pipeline([p1, p2])
+
+        self.assertEqual(pipeline.execute(), 0)
+        self.assertEqual(pipeline.get_stdout(), b"line\nline\n")
+        self.assertEqual(pipeline.exit_codes[0], 141)
+        self.assertEqual(p1.get_stderr(), b"")
+
+    def test_readline_across_chunks(self):
+        def produce(proc):
+            for chunk in [b"ab", b"c\nde", b"f\n", b"tail"]:
+                proc.stdout.write(chunk)
+                proc.stdout.flush()
+            return 0
+
+        def consume(proc):
+            proc.stdout.write(b"|".join(proc.stdin.readlines()))
+            return 0
+
+        pipeline = Pipeline([Process("p", [], executor=produce), Process("c", [], executor=consume)])
+
+        self.assertEqual(pipeline.execute(), 0)
+        self.assertEqual(pipeline.get_stdout(), b"abc\n|def\n|tail")
+
 if __name__ == '__main__':
     unittest.main()
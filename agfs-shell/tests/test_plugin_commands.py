@@ -0,0 +1,125 @@
+import unittest
+from unittest.mock import Mock
+from pyagfs import AGFSClientError
+from agfs_shell.builtins import BUILTINS
+from agfs_shell.completer import ShellCompleter
+from agfs_shell.process import Process
+from agfs_shell.streams import InputStream, OutputStream, ErrorStream
+
+
+class TestPluginCommands(unittest.TestCase):
+    def create_process(self, command, args, input_data="", filesystem=None):
+        stdin = InputStream.from_string(input_data)
+        stdout = OutputStream.to_buffer()
+        stderr = ErrorStream.to_buffer()
+        process = Process(command, args, stdin, stdout, stderr, filesystem=filesystem)
+        process.cwd = '/'
+        return process
+
+    def sql_fs(self, query_error=None):
+        """A file system with a SQLFS2 table at /sqlfs2/db/users"""
+        fs = Mock()
+        files = {
+            '/sqlfs2/db/users/ctl': b'7\n',
+            '/sqlfs2/db/users/7/result': b'[{"name":"alice"}]',
+            '/sqlfs2/db/users/7/error': b'no such column: nme',
+        }
+        fs.read_file.side_effect = lambda path: files[path]
+
+        def write_file(path, data):
+            if path.endswith('/query') and query_error:
+                raise AGFSClientError(query_error)
+        fs.write_file.side_effect = write_file
+        return fs
+
+    def test_sql(self):
+        fs = self.sql_fs()
+        proc = self.create_process("sql", ["/sqlfs2/db/users", "SELECT", "name", "FROM", "users"], filesystem=fs)
+        self.assertEqual(BUILTINS['sql'](proc), 0)
+        self.assertEqual(proc.get_stdout(), b'[{"name":"alice"}]\n')
+
+        writes = [c.args for c in fs.write_file.call_args_list]
+        self.assertEqual(writes, [
+            ('/sqlfs2/db/users/7/query', b'SELECT name FROM users'),
+            ('/sqlfs2/db/users/7/ctl', b'close'),
+        ])
+
+    def test_sql_query_from_stdin(self):
+        fs = self.sql_fs()
+        proc = self.create_process("sql", ["/sqlfs2/db/users"], "SELECT 1\n", filesystem=fs)
+        self.assertEqual(BUILTINS['sql'](proc), 0)
+        fs.write_file.assert_any_call('/sqlfs2/db/users/7/query', b'SELECT 1\n')
+
+    def test_sql_error_closes_session(self):
+        fs = self.sql_fs(query_error="query failed")
+        proc = self.create_process("sql", ["/sqlfs2/db/users", "SELECT nme FROM users"], filesystem=fs)
+        self.assertEqual(BUILTINS['sql'](proc), 1)
+        self.assertIn(b"no such column: nme", proc.get_stderr())
+        fs.write_file.assert_called_with('/sqlfs2/db/users/7/ctl', b'close')
+
+    def test_search(self):
+        fs = Mock()
+        fs.grep.return_value = {
+            'matches': [{'file': '/vectorfs/proj/docs/a.md', 'line': 1, 'content': 'deploy with helm',
+                         'metadata': {'score': 0.91}}],
+            'count': 1,
+        }
+        proc = self.create_process("search", ["-n", "3", "/vectorfs/proj", "how", "to", "deploy"], filesystem=fs)
+        self.assertEqual(BUILTINS['search'](proc), 0)
+        fs.grep.assert_called_once_with(path='/vectorfs/proj/docs', pattern='how to deploy',
+                                        recursive=True, stream=False, limit=3)
+        self.assertEqual(proc.get_stdout(), b"/vectorfs/proj/docs/a.md:1: deploy with helm [score: 0.910]\n")
+
+        # A directory inside docs/ is searched as is
+        fs.grep.reset_mock()
+        proc = self.create_process("search", ["/vectorfs/proj/docs/guides", "rollback"], filesystem=fs)
+        BUILTINS['search'](proc)
+        self.assertEqual(fs.grep.call_args.kwargs['path'], '/vectorfs/proj/docs/guides')
+
+    def test_enqueue(self):
+        fs = Mock()
+        proc = self.create_process("enqueue", ["/queuefs/jobs", "hello", "world"], filesystem=fs)
+        self.assertEqual(BUILTINS['enqueue'](proc), 0)
+        fs.write_file.assert_called_once_with('/queuefs/jobs/enqueue', b'hello world')
+
+        fs = Mock()
+        proc = self.create_process("enqueue", ["-l", "/queuefs/jobs/"], "one\n\ntwo\n", filesystem=fs)
+        self.assertEqual(BUILTINS['enqueue'](proc), 0)
+        self.assertEqual([c.args for c in fs.write_file.call_args_list], [
+            ('/queuefs/jobs/enqueue', b'one'),
+            ('/queuefs/jobs/enqueue', b'two'),
+        ])
+
+
+class TestPluginCompletion(unittest.TestCase):
+    def setUp(self):
+        self.fs = Mock()
+        self.fs.client.mounts.return_value = [
+            {'path': '/sqlfs2', 'pluginName': 'sqlfs2'},
+            {'path': '/queue', 'pluginName': 'queuefs'},
+        ]
+        self.fs.list_directory.return_value = [
+            {'name': 'users', 'isDir': True},
+            {'name': 'ctl', 'isDir': False},
+        ]
+        self.completer = ShellCompleter(self.fs)
+
+    def test_mounts_offered_for_empty_path(self):
+        self.assertEqual(self.completer._complete_argument("sql ", ""), ['/sqlfs2/'])
+        self.assertEqual(self.completer._complete_argument("enqueue -l ", ""), ['/queue/'])
+
+    def test_only_directories_completed(self):
+        self.assertEqual(self.completer._complete_argument("sql ", "/sqlfs2/db/"), ['/sqlfs2/db/users/'])
+        self.fs.list_directory.assert_called_with('/sqlfs2/db/')
+
+    def test_query_not_completed(self):
+        self.assertEqual(self.completer._complete_argument("sql /sqlfs2/db/users ", "SEL"), [])
+        self.assertEqual(self.completer._complete_argument("search -n 3 /vectorfs/p ", "de"), [])
+
+    def test_other_commands_complete_files(self):
+        self.assertEqual(self.completer._complete_argument("cat ", "/sqlfs2/db/"),
+                         ['/sqlfs2/db/ctl', '/sqlfs2/db/users/'])
+
+
+if __name__ == '__main__':
+    unittest.main()
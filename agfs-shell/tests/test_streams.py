@@ -0,0 +1,64 @@
+import unittest
+from unittest.mock import Mock
+from agfs_shell.streams import AGFSInputStream, AGFSOutputStream
+
+
+class TestAGFSStreams(unittest.TestCase):
+    def test_output_stream_uploads_once(self):
+        uploads = []
+        fs = Mock()
+        fs.write_file.side_effect = lambda path, data, append=False: uploads.append((path, b"".join(data), append))
+
+        out = AGFSOutputStream(fs, "/sqlfs2/db/events/1/data", queue_size=2)
+        for i in range(100):
+            out.write(f'{{"n": {i}}}\n')
+        out.flush()
+        out.close()
+
+        self.assertEqual(len(uploads), 1)
+        path, data, append = uploads[0]
+        self.assertEqual(path, "/sqlfs2/db/events/1/data")
+        self.assertEqual(data.count(b"\n"), 100)
+        self.assertFalse(append)
+        self.assertTrue(out.ends_with_newline())
+
+    def test_output_stream_empty_creates_file(self):
+        uploads = []
+        fs = Mock()
+        fs.write_file.side_effect = lambda path, data, append=False: uploads.append(b"".join(data))
+
+        out = AGFSOutputStream(fs, "/local/empty.txt")
+        out.close()
+
+        self.assertEqual(uploads, [b""])
+
+    def test_output_stream_reports_upload_error(self):
+        def fail(path, data, append=False):
+            next(iter(data))
+            raise IOError("disk full")
+        fs = Mock()
+        fs.write_file.side_effect = fail
+
+        out = AGFSOutputStream(fs, "/local/out.txt", queue_size=1)
+        try:
+            # Writes fail fast once the upload has failed
+            for _ in range(10):
+                out.write(b"x" * 10)
+        except IOError:
+            pass
+        with self.assertRaises(IOError):
+            out.close()
+
+    def test_input_stream_reads_chunks(self):
+        fs = Mock()
+        fs.read_file.return_value = iter([b"one\ntw", b"o\n", b"three"])
+
+        stdin = AGFSInputStream(fs, "/s3fs/data.ndjson")
+
+        self.assertEqual(stdin.readline(), b"one\n")
+        self.assertEqual(stdin.readlines(), [b"two\n", b"three"])
+        fs.read_file.assert_called_once_with("/s3fs/data.ndjson", stream=True)
+
+
+if __name__ == '__main__':
+    unittest.main()
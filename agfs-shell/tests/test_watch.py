@@ -0,0 +1,90 @@
+import itertools
+import unittest
+from unittest.mock import Mock
+from agfs_shell.builtins import BUILTINS
+from agfs_shell.process import Process
+from agfs_shell.streams import InputStream, OutputStream, ErrorStream
+from agfs_shell.utils.watch import follow_appends, follow_changes, follow_queue, is_queue_dir
+
+
+def no_sleep(seconds):
+    pass
+
+
+class TestWatchHelpers(unittest.TestCase):
+    def test_follow_appends(self):
+        fs = Mock()
+        sizes = iter([5, 5, 9, 3, 6])
+        content = {5: b'hello', 9: b'hello new', 3: b'abc', 6: b'abcdef'}
+        state = {'size': 0}
+
+        def read_file(path, offset=0, size=-1):
+            return content[state['size']][offset:offset + size]
+
+        def get_file_info(path):
+            state['size'] = next(sizes)
+            return {'size': state['size']}
+        fs.get_file_info.side_effect = get_file_info
+        fs.read_file.side_effect = read_file
+
+        chunks = list(itertools.islice(follow_appends(fs, '/log', 5, sleep=no_sleep), 3))
+        # Appended data, then the whole file after truncation, then its growth
+        self.assertEqual(chunks, [b' new', b'abc', b'def'])
+
+    def test_follow_changes(self):
+        fs = Mock()
+        fs.read_file.side_effect = [b'1/3', b'1/3', b'2/3', b'2/3', b'3/3']
+        versions = list(itertools.islice(follow_changes(fs, '/vectorfs/p/.indexing', sleep=no_sleep), 3))
+        self.assertEqual(versions, [b'1/3', b'2/3', b'3/3'])
+
+    def test_follow_queue(self):
+        fs = Mock()
+        files = {
+            '/queuefs/jobs/size': [b'1\n', b'1\n', b'2\n', b'0\n'],
+            '/queuefs/jobs/peek': [b'{"id":"1"}', b'{"id":"1"}', b'{"id":"1"}', b'{}'],
+        }
+        fs.read_file.side_effect = lambda path: files[path].pop(0)
+        states = list(itertools.islice(follow_queue(fs, '/queuefs/jobs/', sleep=no_sleep), 3))
+        self.assertEqual(states, [b'1 queued, head: {"id":"1"}', b'2 queued, head: {"id":"1"}', b'0 queued'])
+        # Observing a queue never dequeues its messages
+        self.assertNotIn('/queuefs/jobs/dequeue', [c.args[0] for c in fs.read_file.call_args_list])
+
+    def test_follow_queue_consume(self):
+        fs = Mock()
+        fs.read_file.side_effect = [b'{"id":"1"}', b'{}', Exception("timeout"), b'{}\n', b'{"id":"2"}']
+        messages = list(itertools.islice(follow_queue(fs, '/queuefs/jobs/', sleep=no_sleep, consume=True), 2))
+        # A failed read does not end the follow
+        self.assertEqual(messages, [b'{"id":"1"}', b'{"id":"2"}'])
+        fs.read_file.assert_called_with('/queuefs/jobs/dequeue')
+
+    def test_is_queue_dir(self):
+        fs = Mock()
+        fs.list_directory.return_value = [{'name': 'enqueue'}, {'name': 'dequeue'}]
+        self.assertTrue(is_queue_dir(fs, '/queuefs/jobs'))
+        fs.list_directory.side_effect = Exception("not a directory")
+        self.assertFalse(is_queue_dir(fs, '/local/app.log'))
+
+
+class TestWatchCommand(unittest.TestCase):
+    def test_watch_prints_changes(self):
+        fs = Mock()
+        fs.get_file_info.return_value = {'size': 3}
+        fs.read_file.side_effect = [b'idle', b'idle', b'indexing 1/2']
+        proc = Process("watch", ["-n", "0.001", "-c", "2", "/vectorfs/p/.indexing"],
+                       InputStream.from_string(""), OutputStream.to_buffer(), ErrorStream.to_buffer(),
+                       filesystem=fs)
+        self.assertEqual(BUILTINS['watch'](proc), 0)
+        lines = proc.get_stdout().decode().splitlines()
+        self.assertEqual(len(lines), 4)
+        self.assertTrue(lines[0].startswith("==> /vectorfs/p/.indexing ("))
+        self.assertEqual(lines[1], "idle")
+        self.assertEqual(lines[3], "indexing 1/2")
+
+    def test_watch_usage(self):
+        proc = Process("watch", ["-n", "0"], InputStream.from_string(""), OutputStream.to_buffer(),
+                       ErrorStream.to_buffer(), filesystem=Mock())
+        self.assertEqual(BUILTINS['watch'](proc), 2)
+
+
+if __name__ == '__main__':
+    unittest.main()